@@ -0,0 +1,271 @@
+// Package agentupdate serves signed agent binaries to remote agents updating
+// themselves, and negotiates the protocol version agents and the server speak.
+package agentupdate
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Protocol versions of the agent API. ProtocolVersion is the one this server
+// speaks; agents speaking an older version down to MinProtocolVersion are still
+// served, and older agents must update before their metrics are accepted.
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+// Compatible reports whether the server accepts an agent speaking protocol
+func Compatible(protocol int) bool {
+	return protocol >= MinProtocolVersion && protocol <= ProtocolVersion
+}
+
+var (
+	// ErrNoRelease is returned when there is no release for a version or platform
+	ErrNoRelease = errors.New("no agent release")
+
+	// ErrBadSignature is returned for a release whose binary doesn't match its
+	// signature, which is never served
+	ErrBadSignature = errors.New("agent release signature does not verify")
+)
+
+// Release is an agent binary for one platform. Signature is the base64 Ed25519
+// signature of the binary's SHA-256 digest, made with the release signing key;
+// agents check it against the public key they were built with before
+// replacing themselves.
+type Release struct {
+	Version   string `json:"version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+
+	path string
+}
+
+// Path returns the path of the release's binary
+func (r *Release) Path() string {
+	return r.path
+}
+
+// BinaryName is the file name of the agent binary for a platform
+func BinaryName(goos, goarch string) string {
+	name := "ssts-agent-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Store serves the releases in a directory laid out as
+//
+//	<dir>/<version>/ssts-agent-<os>-<arch>[.exe]
+//	<dir>/<version>/ssts-agent-<os>-<arch>[.exe].sig
+//
+// where each .sig file holds the binary's signature, base64 encoded. Releases
+// are published by copying them into the directory; no restart is needed.
+type Store struct {
+	dir string
+	key ed25519.PublicKey
+
+	mu       sync.Mutex
+	verified map[string]verifiedBinary // by path, so binaries are hashed once until they change
+}
+
+// verifiedBinary is a binary whose signature was checked, with the file state
+// it was checked at
+type verifiedBinary struct {
+	size    int64
+	modTime time.Time
+	release Release
+}
+
+// NewStore creates a store serving the releases in dir, verified with the
+// base64-encoded Ed25519 public key
+func NewStore(dir, publicKey string) (*Store, error) {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, key: key, verified: make(map[string]verifiedBinary)}, nil
+}
+
+// ParsePublicKey decodes a base64-encoded Ed25519 public key
+func ParsePublicKey(publicKey string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent release public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid agent release public key: %d bytes, want %d", len(key), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Latest returns the newest release for a platform. When the newest release
+// fails verification ErrBadSignature is returned rather than falling back to an
+// older one, so a tampered release stops updates instead of going unnoticed.
+func (s *Store) Latest(goos, goarch string) (*Release, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNoRelease
+		}
+		return nil, fmt.Errorf("failed to list agent releases: %w", err)
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return CompareVersions(versions[i], versions[j]) > 0 })
+
+	for _, version := range versions {
+		release, err := s.Release(version, goos, goarch)
+		if errors.Is(err, ErrNoRelease) {
+			continue
+		}
+		return release, err
+	}
+	return nil, ErrNoRelease
+}
+
+// Release returns a verified release of a version for a platform
+func (s *Store) Release(version, goos, goarch string) (*Release, error) {
+	if version == "" || version != filepath.Base(version) || strings.HasPrefix(version, ".") ||
+		goos != filepath.Base(goos) || goarch != filepath.Base(goarch) {
+		return nil, ErrNoRelease
+	}
+
+	path := filepath.Join(s.dir, version, BinaryName(goos, goarch))
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil, ErrNoRelease
+	}
+
+	s.mu.Lock()
+	cached, ok := s.verified[path]
+	s.mu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		release := cached.release
+		return &release, nil
+	}
+
+	release, err := s.verify(path, version, goos, goarch)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.verified[path] = verifiedBinary{size: info.Size(), modTime: info.ModTime(), release: *release}
+	s.mu.Unlock()
+	return release, nil
+}
+
+// verify hashes a binary and checks its signature
+func (s *Store) verify(path, version, goos, goarch string) (*Release, error) {
+	encoded, err := os.ReadFile(path + ".sig")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: missing signature", ErrBadSignature, path)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBadSignature, path, err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open agent release: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent release: %w", err)
+	}
+	digest := hash.Sum(nil)
+
+	if !ed25519.Verify(s.key, digest, signature) {
+		return nil, fmt.Errorf("%w: %s", ErrBadSignature, path)
+	}
+
+	return &Release{
+		Version:   version,
+		OS:        goos,
+		Arch:      goarch,
+		Size:      size,
+		SHA256:    hex.EncodeToString(digest),
+		Signature: base64.StdEncoding.EncodeToString(signature),
+		path:      path,
+	}, nil
+}
+
+// CompareVersions compares two dotted versions such as 1.4.2 or v1.10.0-rc1,
+// returning -1, 0 or 1. Numeric parts compare as numbers and others as strings;
+// a version with a pre-release suffix is older than the same version without.
+func CompareVersions(a, b string) int {
+	a, preA, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	b, preB, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var partA, partB string
+		if i < len(partsA) {
+			partA = partsA[i]
+		}
+		if i < len(partsB) {
+			partB = partsB[i]
+		}
+		if c := comparePart(partA, partB); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return comparePart(preA, preB)
+}
+
+// comparePart compares one part of a version, missing parts counting as 0
+func comparePart(a, b string) int {
+	if a == "" {
+		a = "0"
+	}
+	if b == "" {
+		b = "0"
+	}
+	numA, errA := strconv.Atoi(a)
+	numB, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		switch {
+		case numA < numB:
+			return -1
+		case numA > numB:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
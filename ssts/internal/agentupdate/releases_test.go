@@ -0,0 +1,136 @@
+package agentupdate
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.10.0", "1.9.9", 1},
+		{"1.2.3", "1.3", -1},
+		{"2.0.0-rc1", "2.0.0", -1},
+		{"2.0.0-rc2", "2.0.0-rc1", 1},
+		{"2.0.0-rc1", "1.9.0", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// publish writes a release binary signed with key into dir
+func publish(t *testing.T, dir, version, goos, goarch string, binary []byte, key ed25519.PrivateKey) string {
+	t.Helper()
+
+	path := filepath.Join(dir, version, BinaryName(goos, goarch))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, binary, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(binary)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, digest[:]))
+	if err := os.WriteFile(path+".sig", []byte(signature+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestStore(t *testing.T) (*Store, string, ed25519.PrivateKey) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	store, err := NewStore(dir, base64.StdEncoding.EncodeToString(public))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, dir, private
+}
+
+func TestStoreLatest(t *testing.T) {
+	store, dir, key := newTestStore(t)
+
+	if _, err := store.Latest("linux", "amd64"); !errors.Is(err, ErrNoRelease) {
+		t.Fatalf("Latest() on an empty store = %v, want ErrNoRelease", err)
+	}
+
+	publish(t, dir, "1.9.0", "linux", "amd64", []byte("old"), key)
+	publish(t, dir, "1.10.0", "linux", "amd64", []byte("new"), key)
+	publish(t, dir, "1.11.0", "windows", "amd64", []byte("windows"), key)
+
+	release, err := store.Latest("linux", "amd64")
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	digest := sha256.Sum256([]byte("new"))
+	if release.Version != "1.10.0" || release.Size != 3 || release.SHA256 != hex.EncodeToString(digest[:]) {
+		t.Errorf("Latest() = %+v, want 1.10.0", release)
+	}
+
+	if _, err := store.Latest("darwin", "arm64"); !errors.Is(err, ErrNoRelease) {
+		t.Errorf("Latest() for a platform without releases = %v, want ErrNoRelease", err)
+	}
+}
+
+func TestStoreRejectsBadSignatures(t *testing.T) {
+	store, dir, key := newTestStore(t)
+	publish(t, dir, "1.0.0", "linux", "amd64", []byte("good"), key)
+
+	// A tampered newer release stops updates rather than falling back
+	path := publish(t, dir, "1.1.0", "linux", "amd64", []byte("good"), key)
+	if err := os.WriteFile(path, []byte("evil"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Latest("linux", "amd64"); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Latest() with a tampered release = %v, want ErrBadSignature", err)
+	}
+
+	// Releases signed with another key
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	publish(t, dir, "1.2.0", "linux", "arm64", []byte("other"), other)
+	if _, err := store.Release("1.2.0", "linux", "arm64"); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Release() signed with another key = %v, want ErrBadSignature", err)
+	}
+
+	// Unsigned releases
+	os.Remove(filepath.Join(dir, "1.0.0", BinaryName("linux", "amd64")) + ".sig")
+	if _, err := store.Release("1.0.0", "linux", "amd64"); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Release() without a signature = %v, want ErrBadSignature", err)
+	}
+}
+
+func TestStoreReleasePaths(t *testing.T) {
+	store, dir, key := newTestStore(t)
+	publish(t, dir, "1.0.0", "linux", "amd64", []byte("agent"), key)
+
+	for _, params := range [][3]string{
+		{"..", "linux", "amd64"},
+		{"1.0.0/../1.0.0", "linux", "amd64"},
+		{"1.0.0", "../linux", "amd64"},
+		{"", "linux", "amd64"},
+	} {
+		if _, err := store.Release(params[0], params[1], params[2]); !errors.Is(err, ErrNoRelease) {
+			t.Errorf("Release(%q) = %v, want ErrNoRelease", params, err)
+		}
+	}
+}
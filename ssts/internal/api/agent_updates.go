@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/agentupdate"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Headers a release download carries, for agents to check the binary before
+// replacing themselves with it
+const (
+	agentReleaseSHA256Header    = "X-Agent-SHA256"
+	agentReleaseSignatureHeader = "X-Agent-Signature"
+)
+
+// AgentHandshakeRequest is what an agent reports about itself when it starts,
+// and again periodically so it is offered updates while idle
+type AgentHandshakeRequest struct {
+	Version         string `json:"version" binding:"required"`
+	ProtocolVersion int    `json:"protocol_version" binding:"required,min=1"`
+	OS              string `json:"os" binding:"required"`
+	Arch            string `json:"arch" binding:"required"`
+	Idle            bool   `json:"idle"` // no test is running on the agent's host, so it may restart
+}
+
+// AgentHandshakeResponse tells an agent whether the server accepts its protocol
+// version, and offers it a newer release when one should be installed
+type AgentHandshakeResponse struct {
+	ProtocolVersion    int                  `json:"protocol_version"`
+	MinProtocolVersion int                  `json:"min_protocol_version"`
+	Compatible         bool                 `json:"compatible"`
+	Update             *agentupdate.Release `json:"update,omitempty"`
+	UpdateURL          string               `json:"update_url,omitempty"` // where to download the update's binary
+}
+
+// @Summary Agent handshake
+// @Description Negotiate the protocol version with a remote agent and record the version it runs. Authenticate with an agent token as a bearer token. When agent updates are enabled, agents running an older version than the newest release for their platform are offered it while idle, and always when the server no longer accepts their protocol version. Agents must verify the update's signature before installing it.
+// @Tags ingest
+// @Accept json
+// @Produce json
+// @Param handshake body AgentHandshakeRequest true "Agent"
+// @Success 200 {object} AgentHandshakeResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/agent/handshake [post]
+func (s *Server) agentHandshake(c *gin.Context) {
+	agent := c.MustGet(contextAgentKey).(*models.AgentToken)
+
+	var request AgentHandshakeRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	handshake := models.AgentHandshake{
+		Version:         request.Version,
+		ProtocolVersion: request.ProtocolVersion,
+		Platform:        request.OS + "/" + request.Arch,
+		At:              time.Now(),
+	}
+	if err := s.repo.RecordAgentHandshake(agent.ID, handshake); err != nil {
+		s.logger.Error("Failed to record agent handshake", zap.String("agent_id", agent.ID), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to record handshake")
+		return
+	}
+
+	response := AgentHandshakeResponse{
+		ProtocolVersion:    agentupdate.ProtocolVersion,
+		MinProtocolVersion: agentupdate.MinProtocolVersion,
+		Compatible:         agentupdate.Compatible(request.ProtocolVersion),
+	}
+
+	// Agents are only restarted between tests, unless they can't push metrics at all
+	if s.agentUpdates != nil && (request.Idle || !response.Compatible) {
+		release, err := s.agentUpdates.Latest(request.OS, request.Arch)
+		switch {
+		case err == nil:
+			if agentupdate.CompareVersions(release.Version, request.Version) > 0 {
+				response.Update = release
+				response.UpdateURL = apiBase(c) + "/agent/releases/" + release.Version + "/" + release.OS + "/" + release.Arch
+			}
+		case errors.Is(err, agentupdate.ErrNoRelease):
+		default:
+			s.logger.Error("Failed to get agent release",
+				zap.String("platform", handshake.Platform), zap.Error(err))
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Download agent release
+// @Description Download an agent binary. Authenticate with an agent token as a bearer token. The binary's SHA-256 digest and base64 Ed25519 signature are returned in the X-Agent-SHA256 and X-Agent-Signature headers; only releases whose signature verifies are served.
+// @Tags ingest
+// @Produce octet-stream
+// @Param version path string true "Release version"
+// @Param os path string true "Operating system, e.g. linux"
+// @Param arch path string true "Architecture, e.g. amd64"
+// @Success 200 {file} file
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/agent/releases/{version}/{os}/{arch} [get]
+func (s *Server) downloadAgentRelease(c *gin.Context) {
+	if s.agentUpdates == nil {
+		respondError(c, http.StatusNotFound, "Agent updates are not enabled")
+		return
+	}
+
+	release, err := s.agentUpdates.Release(c.Param("version"), c.Param("os"), c.Param("arch"))
+	if err != nil {
+		if errors.Is(err, agentupdate.ErrNoRelease) {
+			respondError(c, http.StatusNotFound, "Agent release not found")
+		} else {
+			s.logger.Error("Failed to get agent release", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get agent release")
+		}
+		return
+	}
+
+	c.Header(agentReleaseSHA256Header, release.SHA256)
+	c.Header(agentReleaseSignatureHeader, release.Signature)
+	c.FileAttachment(release.Path(), filepath.Base(release.Path()))
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pranavgopavaram/ssts/internal/agentupdate"
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+const testAgentToken = models.AgentTokenPrefix + "test"
+
+// newAgentUpdateTestServer creates a server serving agent releases signed with
+// the returned key, and an agent token for testAgentToken
+func newAgentUpdateTestServer(t *testing.T, configure func(*config.Config)) (*Server, string, ed25519.PrivateKey) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	server, repo := newAuthTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.AgentUpdates.Enabled = true
+		cfg.Ingest.AgentUpdates.ReleaseDir = dir
+		cfg.Ingest.AgentUpdates.PublicKey = base64.StdEncoding.EncodeToString(public)
+		if configure != nil {
+			configure(cfg)
+		}
+	})
+	project, _ := repo.GetProjectByName(models.DefaultProjectName)
+	repo.CreateAgentToken(&models.AgentToken{ID: "a1", Name: "rack-1", ProjectID: project.ID, TokenHash: hashAgentToken(testAgentToken)})
+	return server, dir, private
+}
+
+// publishAgentRelease writes a signed agent binary into a release directory
+func publishAgentRelease(t *testing.T, dir, version, goos, goarch string, binary []byte, key ed25519.PrivateKey) {
+	t.Helper()
+
+	path := filepath.Join(dir, version, agentupdate.BinaryName(goos, goarch))
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	digest := sha256.Sum256(binary)
+	if err := os.WriteFile(path, binary, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".sig", []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, digest[:]))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveAgent serves a request authenticated with testAgentToken
+func (s *Server) serveAgent(method, path string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testAgentToken)
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAgentHandshakeOffersUpdates(t *testing.T) {
+	server, dir, key := newAgentUpdateTestServer(t, nil)
+	publishAgentRelease(t, dir, "1.2.0", "linux", "amd64", []byte("agent 1.2.0"), key)
+
+	handshake := func(request AgentHandshakeRequest) AgentHandshakeResponse {
+		t.Helper()
+		rec := server.serveAgent(http.MethodPost, "/api/v1/agent/handshake", request)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("handshake: status %d: %s", rec.Code, rec.Body)
+		}
+		var response AgentHandshakeResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return response
+	}
+
+	request := AgentHandshakeRequest{Version: "1.1.0", ProtocolVersion: agentupdate.ProtocolVersion, OS: "linux", Arch: "amd64"}
+	if response := handshake(request); !response.Compatible || response.Update != nil {
+		t.Errorf("busy agent: %+v, want compatible without an update", response)
+	}
+
+	request.Idle = true
+	response := handshake(request)
+	if response.Update == nil || response.Update.Version != "1.2.0" || response.UpdateURL != "/api/v1/agent/releases/1.2.0/linux/amd64" {
+		t.Fatalf("idle agent: %+v, want 1.2.0 offered", response)
+	}
+
+	request.Version = "1.2.0"
+	if response := handshake(request); response.Update != nil {
+		t.Errorf("up to date agent: offered %+v", response.Update)
+	}
+
+	// Agents speaking a protocol the server does not accept are offered updates even while busy
+	request = AgentHandshakeRequest{Version: "0.9.0", ProtocolVersion: agentupdate.ProtocolVersion + 1, OS: "linux", Arch: "amd64"}
+	if response := handshake(request); response.Compatible || response.Update == nil {
+		t.Errorf("incompatible agent: %+v, want an update", response)
+	}
+
+	agents, _ := server.repo.ListAgentTokens()
+	if len(agents) != 1 || agents[0].AgentVersion != "0.9.0" || agents[0].Platform != "linux/amd64" || agents[0].LastHandshake == nil {
+		t.Errorf("recorded agent = %+v, want the last handshake", agents)
+	}
+
+	rec := server.serveAgent(http.MethodGet, response.UpdateURL, nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "agent 1.2.0" {
+		t.Fatalf("download: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get(agentReleaseSHA256Header) != response.Update.SHA256 || rec.Header().Get(agentReleaseSignatureHeader) != response.Update.Signature {
+		t.Errorf("download headers = %v, want the release's digest and signature", rec.Header())
+	}
+
+	if rec := server.serveAgent(http.MethodGet, "/api/v1/agent/releases/1.3.0/linux/amd64", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown release: status %d, want 404", rec.Code)
+	}
+	if rec := server.serve(http.MethodGet, response.UpdateURL, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("download without a token: status %d, want 401", rec.Code)
+	}
+}
+
+func TestIngestRequiresCompatibleAgent(t *testing.T) {
+	points := IngestMetricsRequest{Points: []models.MetricPoint{}}
+
+	server, _, _ := newAgentUpdateTestServer(t, nil)
+	if rec := server.serveAgent(http.MethodPost, "/api/v1/ingest/metrics", points); rec.Code != http.StatusOK {
+		t.Errorf("agent without a handshake: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	server.serveAgent(http.MethodPost, "/api/v1/agent/handshake",
+		AgentHandshakeRequest{Version: "0.9.0", ProtocolVersion: agentupdate.ProtocolVersion + 1, OS: "linux", Arch: "amd64"})
+	rec := server.serveAgent(http.MethodPost, "/api/v1/ingest/metrics", points)
+	var body ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusUpgradeRequired || body.Code != CodeAgentUpgrade {
+		t.Errorf("incompatible agent: status %d, want 426: %s", rec.Code, rec.Body)
+	}
+
+	server, _, _ = newAgentUpdateTestServer(t, func(cfg *config.Config) { cfg.Ingest.RequireHandshake = true })
+	if rec := server.serveAgent(http.MethodPost, "/api/v1/ingest/metrics", points); rec.Code != http.StatusUpgradeRequired {
+		t.Errorf("required handshake missing: status %d, want 426", rec.Code)
+	}
+	server.serveAgent(http.MethodPost, "/api/v1/agent/handshake",
+		AgentHandshakeRequest{Version: "1.0.0", ProtocolVersion: agentupdate.ProtocolVersion, OS: "linux", Arch: "amd64"})
+	if rec := server.serveAgent(http.MethodPost, "/api/v1/ingest/metrics", points); rec.Code != http.StatusOK {
+		t.Errorf("after a handshake: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// AcknowledgeAlertRequest is the body for acknowledging an alert
+type AcknowledgeAlertRequest struct {
+	AcknowledgedBy string `json:"acknowledged_by" binding:"required"`
+}
+
+// CreateMuteRuleRequest is the body for creating an alert mute rule
+type CreateMuteRuleRequest struct {
+	Type      string `json:"type"`
+	Severity  string `json:"severity"`
+	Reason    string `json:"reason"`
+	Duration  string `json:"duration"` // e.g. "2h"; empty mutes until the rule is deleted
+	CreatedBy string `json:"created_by"`
+}
+
+// @Summary List alerts
+// @Description Get the safety alert history of the project, including host-wide alerts, with optional filtering
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param type query string false "Filter by alert type"
+// @Param severity query string false "Filter by severity (comma-separated for several)"
+// @Param acknowledged query bool false "Filter by acknowledgment state"
+// @Param include_muted query bool false "Include alerts suppressed by mute rules"
+// @Param since query string false "Only alerts at or after this time (RFC3339)"
+// @Param until query string false "Only alerts at or before this time (RFC3339)"
+// @Param sort query string false "Comma-separated sort fields, prefix with - for descending (timestamp, type, severity)"
+// @Param limit query int false "Limit number of results" default(50)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {array} models.Alert
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/alerts [get]
+func (s *Server) listAlerts(c *gin.Context) {
+	sort, err := database.ParseSort(c.Query("sort"), database.AlertSortFields)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	since, err := parseTimeQuery(c, "since")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	until, err := parseTimeQuery(c, "until")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filter := database.AlertFilter{
+		ProjectID:    currentProjectID(c),
+		Type:         c.Query("type"),
+		IncludeMuted: c.Query("include_muted") == "true",
+		Since:        since,
+		Until:        until,
+		Sort:         sort,
+		Limit:        parseIntQuery(c, "limit", 50),
+		Offset:       parseIntQuery(c, "offset", 0),
+	}
+
+	for _, severity := range strings.Split(c.Query("severity"), ",") {
+		if severity = strings.TrimSpace(severity); severity != "" {
+			filter.Severities = append(filter.Severities, severity)
+		}
+	}
+
+	if value := c.Query("acknowledged"); value != "" {
+		acknowledged, err := strconv.ParseBool(value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid acknowledged: expected true or false")
+			return
+		}
+		filter.Acknowledged = &acknowledged
+	}
+
+	alerts, err := s.repo.ListAlerts(filter)
+	if err != nil {
+		s.logger.Error("Failed to list alerts", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list alerts")
+		return
+	}
+
+	setPagination(c, filter.Limit, filter.Offset, len(alerts))
+	c.JSON(http.StatusOK, alerts)
+}
+
+// @Summary Get alert
+// @Description Get a specific alert of the project, or a host-wide alert, by ID
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Success 200 {object} models.Alert
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/alerts/{id} [get]
+func (s *Server) getAlert(c *gin.Context) {
+	alert := s.projectAlert(c)
+	if alert == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// projectAlert returns the alert named by the id parameter if it belongs to the
+// current project or is host-wide, responding with an error and returning nil if not
+func (s *Server) projectAlert(c *gin.Context) *models.Alert {
+	alert, err := s.repo.GetAlert(c.Param("id"))
+	if err == nil && alert.ProjectID != "" && alert.ProjectID != currentProjectID(c) {
+		err = database.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Alert not found")
+		} else {
+			s.logger.Error("Failed to get alert", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get alert")
+		}
+		return nil
+	}
+	return alert
+}
+
+// @Summary Acknowledge alert
+// @Description Record that an operator has seen and taken ownership of an alert. Host-wide alerts concern every project, so acknowledging them takes an admin.
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Param request body AcknowledgeAlertRequest true "Acknowledgment"
+// @Success 200 {object} models.Alert
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/alerts/{id}/acknowledge [post]
+func (s *Server) acknowledgeAlert(c *gin.Context) {
+	var request AcknowledgeAlertRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	alert := s.projectAlert(c)
+	if alert == nil {
+		return
+	}
+	if alert.ProjectID == "" && !s.checkServerAdmin(c) {
+		return
+	}
+
+	alert, err := s.repo.AcknowledgeAlert(alert.ID, request.AcknowledgedBy)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Alert not found")
+		} else {
+			s.logger.Error("Failed to acknowledge alert", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to acknowledge alert")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// @Summary List alert mute rules
+// @Description Get the mute rules that are currently in effect
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.AlertMuteRule
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/alerts/mutes [get]
+func (s *Server) listAlertMuteRules(c *gin.Context) {
+	rules, err := s.repo.ListActiveAlertMuteRules()
+	if err != nil {
+		s.logger.Error("Failed to list alert mute rules", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list alert mute rules")
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// @Summary Create alert mute rule
+// @Description Suppress alerts matching a type and/or severity, optionally for a limited time. Mute rules apply to every project, so they take an admin.
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param rule body CreateMuteRuleRequest true "Mute rule"
+// @Success 201 {object} models.AlertMuteRule
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/alerts/mutes [post]
+func (s *Server) createAlertMuteRule(c *gin.Context) {
+	var request CreateMuteRuleRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if request.Type == "" && request.Severity == "" {
+		respondError(c, http.StatusBadRequest, "A mute rule must match a type or a severity")
+		return
+	}
+
+	rule := models.AlertMuteRule{
+		Type:      request.Type,
+		Severity:  request.Severity,
+		Reason:    request.Reason,
+		CreatedBy: request.CreatedBy,
+	}
+
+	if request.Duration != "" {
+		duration, err := time.ParseDuration(request.Duration)
+		if err != nil || duration <= 0 {
+			respondError(c, http.StatusBadRequest, "Invalid duration")
+			return
+		}
+		expiresAt := time.Now().Add(duration)
+		rule.ExpiresAt = &expiresAt
+	}
+
+	if err := s.repo.CreateAlertMuteRule(&rule); err != nil {
+		s.logger.Error("Failed to create alert mute rule", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to create alert mute rule")
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// @Summary Delete alert mute rule
+// @Description Remove a mute rule so matching alerts are delivered again
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param id path string true "Mute rule ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/alerts/mutes/{id} [delete]
+func (s *Server) deleteAlertMuteRule(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := s.repo.DeleteAlertMuteRule(id); err != nil {
+		s.logger.Error("Failed to delete alert mute rule", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to delete alert mute rule")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
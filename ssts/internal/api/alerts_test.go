@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestListAlerts(t *testing.T) {
+	server, repo := newTestServer(t)
+	now := time.Now().UTC()
+	for _, alert := range []models.Alert{
+		{ID: "cpu-warning", Type: "cpu", Severity: "warning", Timestamp: now.Add(-3 * time.Hour)},
+		{ID: "cpu-error", Type: "cpu", Severity: "error", Timestamp: now.Add(-2 * time.Hour)},
+		{ID: "memory-critical", Type: "memory", Severity: "critical", Timestamp: now.Add(-time.Hour)},
+		{ID: "disk-muted", Type: "disk", Severity: "warning", Timestamp: now, Muted: true},
+	} {
+		repo.CreateAlert(&alert)
+	}
+	repo.AcknowledgeAlert("cpu-error", "alice")
+
+	ids := func(body []byte) []string {
+		var alerts []models.Alert
+		json.Unmarshal(body, &alerts)
+		ids := []string{}
+		for _, alert := range alerts {
+			ids = append(ids, alert.ID)
+		}
+		return ids
+	}
+
+	since := now.Add(-90 * time.Minute).Format(time.RFC3339)
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"newest first, muted hidden", "", []string{"memory-critical", "cpu-error", "cpu-warning"}},
+		{"muted included", "include_muted=true", []string{"disk-muted", "memory-critical", "cpu-error", "cpu-warning"}},
+		{"type", "type=cpu", []string{"cpu-error", "cpu-warning"}},
+		{"several severities", "severity=critical,+error", []string{"memory-critical", "cpu-error"}},
+		{"acknowledged", "acknowledged=true", []string{"cpu-error"}},
+		{"unacknowledged", "acknowledged=false", []string{"memory-critical", "cpu-warning"}},
+		{"since", "since=" + since, []string{"memory-critical"}},
+		{"sorted by type", "sort=type,-severity", []string{"cpu-warning", "cpu-error", "memory-critical"}},
+		{"paginated", "limit=1&offset=1", []string{"cpu-error"}},
+		{"no match", "type=gpu", []string{}},
+	}
+	for _, tt := range tests {
+		rec := server.serve(http.MethodGet, "/api/v1/alerts?"+tt.query, nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status %d: %s", tt.name, rec.Code, rec.Body)
+			continue
+		}
+		if got := ids(rec.Body.Bytes()); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"acknowledged=maybe", "since=yesterday", "until=2024-13-01", "sort=message"} {
+		if rec := server.serve(http.MethodGet, "/api/v1/alerts?"+query, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestAcknowledgeAlert(t *testing.T) {
+	server, repo := newTestServer(t)
+	repo.CreateAlert(&models.Alert{ID: "a1", Type: "cpu", Severity: "warning", Timestamp: time.Now()})
+
+	tests := []struct {
+		name string
+		id   string
+		body interface{}
+		want int
+	}{
+		{"no body", "a1", nil, http.StatusBadRequest},
+		{"no operator", "a1", AcknowledgeAlertRequest{}, http.StatusBadRequest},
+		{"missing alert", "missing", AcknowledgeAlertRequest{AcknowledgedBy: "alice"}, http.StatusNotFound},
+		{"acknowledged", "a1", AcknowledgeAlertRequest{AcknowledgedBy: "alice"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := server.serve(http.MethodPost, "/api/v1/alerts/"+tt.id+"/acknowledge", tt.body); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	alert, _ := repo.GetAlert("a1")
+	if !alert.Acknowledged || alert.AcknowledgedBy == nil || *alert.AcknowledgedBy != "alice" || alert.AcknowledgedAt == nil {
+		t.Errorf("acknowledged alert = %+v", alert)
+	}
+	if rec := server.serve(http.MethodGet, "/api/v1/alerts/missing", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("get missing alert: status %d, want 404", rec.Code)
+	}
+}
+
+func TestAlertMuteRules(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		name    string
+		request CreateMuteRuleRequest
+		want    int
+	}{
+		{"matches nothing", CreateMuteRuleRequest{Reason: "maintenance"}, http.StatusBadRequest},
+		{"invalid duration", CreateMuteRuleRequest{Type: "cpu", Duration: "soon"}, http.StatusBadRequest},
+		{"negative duration", CreateMuteRuleRequest{Type: "cpu", Duration: "-1h"}, http.StatusBadRequest},
+		{"until deleted", CreateMuteRuleRequest{Type: "cpu"}, http.StatusCreated},
+		{"for a while", CreateMuteRuleRequest{Severity: "warning", Duration: "2h"}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		rec := server.serve(http.MethodPost, "/api/v1/alerts/mutes", tt.request)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if rec.Code != http.StatusCreated {
+			continue
+		}
+		var rule models.AlertMuteRule
+		json.Unmarshal(rec.Body.Bytes(), &rule)
+		if (rule.ExpiresAt != nil) != (tt.request.Duration != "") {
+			t.Errorf("%s: expires at %v", tt.name, rule.ExpiresAt)
+		}
+	}
+
+	var rules []models.AlertMuteRule
+	json.Unmarshal(server.serve(http.MethodGet, "/api/v1/alerts/mutes", nil).Body.Bytes(), &rules)
+	if len(rules) != 2 {
+		t.Fatalf("active mute rules = %+v, want 2", rules)
+	}
+	if rec := server.serve(http.MethodDelete, "/api/v1/alerts/mutes/"+rules[0].ID, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d: %s", rec.Code, rec.Body)
+	}
+	json.Unmarshal(server.serve(http.MethodGet, "/api/v1/alerts/mutes", nil).Body.Bytes(), &rules)
+	if len(rules) != 1 {
+		t.Errorf("mute rules after deleting one = %+v", rules)
+	}
+}
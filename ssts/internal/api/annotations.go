@@ -0,0 +1,165 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// CreateAnnotationRequest is the body for annotating an execution
+type CreateAnnotationRequest struct {
+	Text      string     `json:"text" binding:"required"`
+	Timestamp *time.Time `json:"timestamp"` // defaults to now
+}
+
+// ExecutionMetricsResponse is an execution's metrics with its annotations
+type ExecutionMetricsResponse struct {
+	Metrics     []models.MetricPoint `json:"metrics"`
+	Annotations []models.Annotation  `json:"annotations"`
+}
+
+// annotatedExecution returns the running or finished execution named by the id
+// parameter. It responds with an error and returns nil if there is none in the
+// current project.
+func (s *Server) annotatedExecution(c *gin.Context) *models.TestExecution {
+	id := c.Param("id")
+
+	if execution, err := s.orchestrator.GetTestStatus(id); err == nil {
+		return execution
+	}
+
+	execution, err := s.repo.GetTestExecution(id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Execution not found")
+		} else {
+			s.logger.Error("Failed to get execution", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get execution")
+		}
+		return nil
+	}
+	if execution.ProjectID != currentProjectID(c) {
+		respondError(c, http.StatusNotFound, "Execution not found")
+		return nil
+	}
+	return execution
+}
+
+// @Summary List execution annotations
+// @Description Get the annotations of an execution in time order
+// @Tags executions
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Success 200 {array} models.Annotation
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/annotations [get]
+func (s *Server) listAnnotations(c *gin.Context) {
+	execution := s.annotatedExecution(c)
+	if execution == nil {
+		return
+	}
+
+	annotations, err := s.repo.ListAnnotations(execution.ID)
+	if err != nil {
+		s.logger.Error("Failed to list annotations", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list annotations")
+		return
+	}
+
+	c.JSON(http.StatusOK, annotations)
+}
+
+// @Summary Annotate execution
+// @Description Attach a timestamped note, such as "changed thermal paste", to a running or finished execution. The timestamp must fall within the execution. Connected WebSocket clients receive it as an annotation message.
+// @Tags executions
+// @Accept json
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Param annotation body CreateAnnotationRequest true "Annotation"
+// @Success 201 {object} models.Annotation
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/annotations [post]
+func (s *Server) createAnnotation(c *gin.Context) {
+	var request CreateAnnotationRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+	if len(request.Text) > models.MaxAnnotationLength {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Annotation text is longer than %d characters", models.MaxAnnotationLength))
+		return
+	}
+
+	execution := s.annotatedExecution(c)
+	if execution == nil {
+		return
+	}
+
+	timestamp := time.Now()
+	if request.Timestamp != nil {
+		timestamp = *request.Timestamp
+	}
+	if execution.StartTime != nil && timestamp.Before(*execution.StartTime) {
+		respondError(c, http.StatusBadRequest, "Timestamp is before the execution started")
+		return
+	}
+	if execution.EndTime != nil && timestamp.After(*execution.EndTime) {
+		respondError(c, http.StatusBadRequest, "Timestamp is after the execution ended")
+		return
+	}
+
+	annotation := models.Annotation{
+		ExecutionID: execution.ID,
+		Timestamp:   timestamp,
+		Text:        request.Text,
+		CreatedBy:   c.GetString(contextUserIDKey),
+	}
+
+	if err := s.repo.CreateAnnotation(&annotation); err != nil {
+		s.logger.Error("Failed to create annotation", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to create annotation")
+		return
+	}
+
+	s.wsHub.BroadcastAnnotation(execution.TestID, "created", annotation)
+	c.JSON(http.StatusCreated, annotation)
+}
+
+// @Summary Delete execution annotation
+// @Description Delete an annotation from an execution. Connected WebSocket clients receive an annotation message with action deleted.
+// @Tags executions
+// @Param id path string true "Execution ID"
+// @Param annotation_id path string true "Annotation ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/annotations/{annotation_id} [delete]
+func (s *Server) deleteAnnotation(c *gin.Context) {
+	execution := s.annotatedExecution(c)
+	if execution == nil {
+		return
+	}
+
+	annotation := models.Annotation{ID: c.Param("annotation_id"), ExecutionID: execution.ID}
+	if err := s.repo.DeleteAnnotation(annotation.ExecutionID, annotation.ID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Annotation not found")
+		} else {
+			s.logger.Error("Failed to delete annotation", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to delete annotation")
+		}
+		return
+	}
+
+	s.wsHub.BroadcastAnnotation(execution.TestID, "deleted", annotation)
+	c.Status(http.StatusNoContent)
+}
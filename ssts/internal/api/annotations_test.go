@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestCreateAnnotation(t *testing.T) {
+	server, repo := newTestServer(t)
+	project, _ := repo.GetProjectByName(models.DefaultProjectName)
+
+	start := time.Now().Add(-time.Hour).UTC()
+	end := start.Add(30 * time.Minute)
+	finished := models.TestExecution{TestID: "t1", ProjectID: project.ID, Status: models.StatusCompleted, StartTime: &start, EndTime: &end}
+	repo.CreateTestExecution(&finished)
+	elsewhere := models.TestExecution{TestID: "t2", ProjectID: "other", Status: models.StatusCompleted, StartTime: &start, EndTime: &end}
+	repo.CreateTestExecution(&elsewhere)
+
+	at := func(d time.Duration) *time.Time {
+		timestamp := start.Add(d)
+		return &timestamp
+	}
+
+	tests := []struct {
+		name        string
+		executionID string
+		body        interface{}
+		want        int
+	}{
+		{"no body", finished.ID, nil, http.StatusBadRequest},
+		{"no text", finished.ID, CreateAnnotationRequest{Timestamp: at(time.Minute)}, http.StatusBadRequest},
+		{"text too long", finished.ID, CreateAnnotationRequest{Text: strings.Repeat("x", models.MaxAnnotationLength+1)}, http.StatusBadRequest},
+		{"missing execution", "missing", CreateAnnotationRequest{Text: "fan ramped up"}, http.StatusNotFound},
+		{"execution in another project", elsewhere.ID, CreateAnnotationRequest{Text: "fan ramped up", Timestamp: at(time.Minute)}, http.StatusNotFound},
+		{"before the start", finished.ID, CreateAnnotationRequest{Text: "fan ramped up", Timestamp: at(-time.Minute)}, http.StatusBadRequest},
+		{"after the end", finished.ID, CreateAnnotationRequest{Text: "fan ramped up", Timestamp: at(31 * time.Minute)}, http.StatusBadRequest},
+		{"defaults to now, after the end", finished.ID, CreateAnnotationRequest{Text: "fan ramped up"}, http.StatusBadRequest},
+		{"longest text", finished.ID, CreateAnnotationRequest{Text: strings.Repeat("x", models.MaxAnnotationLength), Timestamp: at(20 * time.Minute)}, http.StatusCreated},
+		{"within the execution", finished.ID, CreateAnnotationRequest{Text: "changed thermal paste", Timestamp: at(10 * time.Minute)}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		rec := server.serve(http.MethodPost, "/api/v1/executions/"+tt.executionID+"/annotations", tt.body)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if rec.Code != http.StatusCreated {
+			continue
+		}
+		var annotation models.Annotation
+		json.Unmarshal(rec.Body.Bytes(), &annotation)
+		if annotation.ID == "" || annotation.ExecutionID != finished.ID {
+			t.Errorf("%s: created %+v", tt.name, annotation)
+		}
+	}
+
+	rec := server.serve(http.MethodGet, "/api/v1/executions/"+finished.ID+"/annotations", nil)
+	var annotations []models.Annotation
+	json.Unmarshal(rec.Body.Bytes(), &annotations)
+	if rec.Code != http.StatusOK || len(annotations) != 2 || annotations[0].Text != "changed thermal paste" {
+		t.Errorf("annotations in time order: status %d: %+v", rec.Code, annotations)
+	}
+	if annotations, _ := repo.ListAnnotations(elsewhere.ID); len(annotations) != 0 {
+		t.Errorf("annotated an execution in another project: %+v", annotations)
+	}
+}
+
+func TestListAndDeleteAnnotations(t *testing.T) {
+	server, repo := newTestServer(t)
+	project, _ := repo.GetProjectByName(models.DefaultProjectName)
+
+	execution := models.TestExecution{TestID: "t1", ProjectID: project.ID, Status: models.StatusCompleted}
+	repo.CreateTestExecution(&execution)
+	other := models.TestExecution{TestID: "t1", ProjectID: project.ID, Status: models.StatusCompleted}
+	repo.CreateTestExecution(&other)
+	elsewhere := models.TestExecution{TestID: "t2", ProjectID: "other", Status: models.StatusCompleted}
+	repo.CreateTestExecution(&elsewhere)
+
+	annotation := models.Annotation{ExecutionID: execution.ID, Timestamp: time.Now(), Text: "fan ramped up"}
+	repo.CreateAnnotation(&annotation)
+	hidden := models.Annotation{ExecutionID: elsewhere.ID, Timestamp: time.Now(), Text: "hidden"}
+	repo.CreateAnnotation(&hidden)
+
+	lists := []struct {
+		name        string
+		executionID string
+		want        int
+		count       int
+	}{
+		{"annotated", execution.ID, http.StatusOK, 1},
+		{"not annotated", other.ID, http.StatusOK, 0},
+		{"missing execution", "missing", http.StatusNotFound, 0},
+		{"execution in another project", elsewhere.ID, http.StatusNotFound, 0},
+	}
+	for _, tt := range lists {
+		rec := server.serve(http.MethodGet, "/api/v1/executions/"+tt.executionID+"/annotations", nil)
+		var annotations []models.Annotation
+		json.Unmarshal(rec.Body.Bytes(), &annotations)
+		if rec.Code != tt.want || len(annotations) != tt.count {
+			t.Errorf("list %s: status %d with %d annotations, want %d with %d", tt.name, rec.Code, len(annotations), tt.want, tt.count)
+		}
+	}
+
+	deletes := []struct {
+		name         string
+		executionID  string
+		annotationID string
+		want         int
+	}{
+		{"missing annotation", execution.ID, "missing", http.StatusNotFound},
+		{"annotation of another execution", other.ID, annotation.ID, http.StatusNotFound},
+		{"execution in another project", elsewhere.ID, hidden.ID, http.StatusNotFound},
+		{"deleted", execution.ID, annotation.ID, http.StatusNoContent},
+		{"already deleted", execution.ID, annotation.ID, http.StatusNotFound},
+	}
+	for _, tt := range deletes {
+		rec := server.serve(http.MethodDelete, "/api/v1/executions/"+tt.executionID+"/annotations/"+tt.annotationID, nil)
+		if rec.Code != tt.want {
+			t.Errorf("delete %s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+	if annotations, _ := repo.ListAnnotations(elsewhere.ID); len(annotations) != 1 {
+		t.Errorf("deleted an annotation in another project")
+	}
+}
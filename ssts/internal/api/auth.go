@@ -0,0 +1,228 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Token types; only access tokens authenticate API requests
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+// tokenHeader is the fixed JOSE header of the HS256 tokens the server issues
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// tokenClaims are the claims of the tokens issued at login
+type tokenClaims struct {
+	Subject   string `json:"sub"` // user ID
+	Type      string `json:"typ"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// LoginRequest is the body for signing in
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// RefreshRequest is the body for exchanging a refresh token for a new access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse returns the tokens of a session
+type TokenResponse struct {
+	AccessToken  string       `json:"access_token"`
+	RefreshToken string       `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time    `json:"expires_at"` // when the access token expires
+	User         *models.User `json:"user,omitempty"`
+}
+
+// signToken issues an HS256 JWT for the claims
+func signToken(secret string, claims tokenClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + tokenSignature(secret, unsigned), nil
+}
+
+// parseToken verifies a token issued by signToken and returns its claims
+func parseToken(secret, token string, now time.Time) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, errors.New("malformed token")
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(tokenSignature(secret, parts[0]+"."+parts[1]))) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed token")
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" {
+		return nil, errors.New("malformed token")
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+	return &claims, nil
+}
+
+// tokenSignature returns the base64url HMAC-SHA256 of a token's header and payload
+func tokenSignature(secret, unsigned string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issueTokens issues an access token, and a refresh token if refresh is set
+func (s *Server) issueTokens(userID string, refresh bool) (TokenResponse, error) {
+	now := time.Now()
+	expires := now.Add(s.config.Auth.TokenExpiry)
+
+	access, err := signToken(s.config.Auth.JWTSecret, tokenClaims{Subject: userID, Type: tokenTypeAccess, IssuedAt: now.Unix(), ExpiresAt: expires.Unix()})
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	response := TokenResponse{AccessToken: access, ExpiresAt: expires}
+
+	if refresh {
+		response.RefreshToken, err = signToken(s.config.Auth.JWTSecret, tokenClaims{Subject: userID, Type: tokenTypeRefresh, IssuedAt: now.Unix(), ExpiresAt: now.Add(s.config.Auth.RefreshExpiry).Unix()})
+		if err != nil {
+			return TokenResponse{}, err
+		}
+	}
+	return response, nil
+}
+
+// authMiddleware authenticates requests with an access token given as a bearer
+// token, recording the caller's user ID for the handlers and role checks
+func (s *Server) authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			abortWithError(c, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		claims, err := parseToken(s.config.Auth.JWTSecret, strings.TrimPrefix(header, "Bearer "), time.Now())
+		if err != nil || claims.Type != tokenTypeAccess {
+			abortWithError(c, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+
+		c.Set(contextUserIDKey, claims.Subject)
+		c.Next()
+	}
+}
+
+// @Summary Sign in
+// @Description Exchange a username and password for an access token and a refresh token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body LoginRequest true "Credentials"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/login [post]
+func (s *Server) login(c *gin.Context) {
+	var request LoginRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	user, err := s.repo.GetUserByUsername(request.Username)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		s.logger.Error("Failed to get user", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to sign in")
+		return
+	}
+	if user == nil || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(request.Password)) != nil {
+		respondError(c, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	response, err := s.issueTokens(user.ID, true)
+	if err != nil {
+		s.logger.Error("Failed to issue token", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to sign in")
+		return
+	}
+
+	now := time.Now()
+	user.LastLogin = &now
+	if err := s.repo.UpdateUser(user); err != nil {
+		s.logger.Warn("Failed to record login", zap.String("user_id", user.ID), zap.Error(err))
+	}
+
+	response.User = redactUser(user)
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Sign out
+// @Description Tokens are stateless, so signing out only asks the client to discard them; they stay valid until they expire
+// @Tags auth
+// @Success 204 "No Content"
+// @Router /api/v1/auth/logout [post]
+func (s *Server) logout(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Refresh token
+// @Description Exchange a refresh token for a new access token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/auth/refresh [post]
+func (s *Server) refreshToken(c *gin.Context) {
+	var request RefreshRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	claims, err := parseToken(s.config.Auth.JWTSecret, request.RefreshToken, time.Now())
+	if err != nil || claims.Type != tokenTypeRefresh {
+		respondError(c, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+
+	// Users deleted since signing in can't refresh
+	if _, err := s.repo.GetUser(claims.Subject); err != nil {
+		respondError(c, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+
+	response, err := s.issueTokens(claims.Subject, false)
+	if err != nil {
+		s.logger.Error("Failed to issue token", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to refresh token")
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
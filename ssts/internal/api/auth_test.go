@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// newAuthTestServer creates a test server with auth enabled, an admin user
+// "root" and a regular user "bob", both with the password "secret"
+func newAuthTestServer(t *testing.T, configure func(*config.Config)) (*Server, *database.MemoryRepository) {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "test-secret"
+	if configure != nil {
+		configure(cfg)
+	}
+	repo := database.NewMemoryRepository()
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(plugins.NewCPUStressPlugin())
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.CreateUser(&models.User{ID: "root", Username: "root", Email: "root@example.com", PasswordHash: string(hash), Role: models.UserRoleAdmin})
+	repo.CreateUser(&models.User{ID: "bob", Username: "bob", Email: "bob@example.com", PasswordHash: string(hash), Role: "user"})
+
+	return NewServer(cfg, repo, core.NewOrchestrator(cfg, repo, pluginMgr, zap.NewNop()), zap.NewNop()), repo
+}
+
+// serveAs serves a request with an access token for userID, or none when empty
+func (s *Server) serveAs(userID, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		tokens, _ := s.issueTokens(userID, false)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	}
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestParseToken(t *testing.T) {
+	now := time.Now()
+	valid, _ := signToken("secret", tokenClaims{Subject: "alice", Type: tokenTypeAccess, ExpiresAt: now.Add(time.Hour).Unix()})
+	expired, _ := signToken("secret", tokenClaims{Subject: "alice", Type: tokenTypeAccess, ExpiresAt: now.Add(-time.Second).Unix()})
+	noSubject, _ := signToken("secret", tokenClaims{Type: tokenTypeAccess, ExpiresAt: now.Add(time.Hour).Unix()})
+
+	claims, err := parseToken("secret", valid, now)
+	if err != nil || claims.Subject != "alice" {
+		t.Fatalf("parseToken(valid) = %+v, %v", claims, err)
+	}
+
+	tests := map[string]struct {
+		secret string
+		token  string
+	}{
+		"wrong secret": {"other", valid},
+		"tampered":     {"secret", valid[:len(valid)-2] + "xx"},
+		"expired":      {"secret", expired},
+		"no subject":   {"secret", noSubject},
+		"malformed":    {"secret", "not-a-token"},
+		"empty":        {"secret", ""},
+	}
+	for name, tt := range tests {
+		if claims, err := parseToken(tt.secret, tt.token, now); err == nil {
+			t.Errorf("%s: parseToken = %+v, want an error", name, claims)
+		}
+	}
+}
+
+func TestLoginAndAuthentication(t *testing.T) {
+	server, _ := newAuthTestServer(t, nil)
+
+	if rec := server.serve(http.MethodPost, "/api/v1/auth/login", LoginRequest{Username: "bob", Password: "wrong"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status %d, want 401", rec.Code)
+	}
+	if rec := server.serve(http.MethodPost, "/api/v1/auth/login", LoginRequest{Username: "nobody", Password: "secret"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unknown user: status %d, want 401", rec.Code)
+	}
+
+	rec := server.serve(http.MethodPost, "/api/v1/auth/login", LoginRequest{Username: "bob", Password: "secret"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", rec.Code, rec.Body)
+	}
+	var tokens TokenResponse
+	json.Unmarshal(rec.Body.Bytes(), &tokens)
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("login response = %s", rec.Body)
+	}
+
+	call := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/projects", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.engine.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := call(""); code != http.StatusUnauthorized {
+		t.Errorf("no token: status %d, want 401", code)
+	}
+	if code := call(tokens.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refresh token as access token: status %d, want 401", code)
+	}
+	if code := call(tokens.AccessToken); code != http.StatusOK {
+		t.Errorf("access token: status %d, want 200", code)
+	}
+
+	rec = server.serve(http.MethodPost, "/api/v1/auth/refresh", RefreshRequest{RefreshToken: tokens.RefreshToken})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := server.serve(http.MethodPost, "/api/v1/auth/refresh", RefreshRequest{RefreshToken: tokens.AccessToken}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh with an access token: status %d, want 401", rec.Code)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	// Without auth there is no identity, so admin routes are refused
+	server, _ := newTestServer(t)
+	if rec := server.serve(http.MethodPost, "/api/v1/agents", map[string]string{"name": "rack-1"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("auth disabled: status %d, want 401", rec.Code)
+	}
+
+	server, _ = newAuthTestServer(t, nil)
+	tests := []struct {
+		user string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"bob", http.StatusForbidden},
+		{"deleted", http.StatusForbidden},
+		{"root", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := server.serveAs(tt.user, http.MethodGet, "/api/v1/agents", nil); rec.Code != tt.want {
+			t.Errorf("user %q: status %d, want %d: %s", tt.user, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestRegisterExternalPlugin(t *testing.T) {
+	allowed := t.TempDir()
+	outside := t.TempDir()
+
+	script := []byte("#!/bin/sh\nexit 0\n")
+	sum := sha256.Sum256(script)
+	checksum := hex.EncodeToString(sum[:])
+	for _, dir := range []string{allowed, outside} {
+		if err := os.WriteFile(filepath.Join(dir, "plugin"), script, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A link inside the allowed directory to a binary outside it
+	if err := os.Symlink(filepath.Join(outside, "plugin"), filepath.Join(allowed, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	enabled := func(cfg *config.Config) {
+		cfg.Plugins.External = config.ExternalPluginsConfig{Enabled: true, AllowedDirs: []string{allowed}}
+	}
+	tests := []struct {
+		name      string
+		configure func(*config.Config)
+		request   RegisterPluginRequest
+		want      int
+	}{
+		{"disabled by default", nil, RegisterPluginRequest{Name: "ext", BinaryPath: filepath.Join(allowed, "plugin"), Checksum: checksum}, http.StatusForbidden},
+		{"outside allowed dirs", enabled, RegisterPluginRequest{Name: "ext", BinaryPath: filepath.Join(outside, "plugin"), Checksum: checksum}, http.StatusForbidden},
+		{"symlink out of allowed dirs", enabled, RegisterPluginRequest{Name: "ext", BinaryPath: filepath.Join(allowed, "link"), Checksum: checksum}, http.StatusForbidden},
+		{"traversal out of allowed dirs", enabled, RegisterPluginRequest{Name: "ext", BinaryPath: allowed + "/../" + filepath.Base(outside) + "/plugin", Checksum: checksum}, http.StatusForbidden},
+		{"no checksum", enabled, RegisterPluginRequest{Name: "ext", BinaryPath: filepath.Join(allowed, "plugin")}, http.StatusBadRequest},
+		{"wrong checksum", enabled, RegisterPluginRequest{Name: "ext", BinaryPath: filepath.Join(allowed, "plugin"), Checksum: hex.EncodeToString(make([]byte, 32))}, http.StatusBadRequest},
+		{"allowed", enabled, RegisterPluginRequest{Name: "ext", BinaryPath: filepath.Join(allowed, "plugin"), Checksum: checksum}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		server, _ := newAuthTestServer(t, tt.configure)
+		if rec := server.serveAs("root", http.MethodPost, "/api/v1/plugins", tt.request); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
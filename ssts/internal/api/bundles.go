@@ -0,0 +1,460 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/secrets"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// bundleVersion is the version of the bundle format written by exports
+const bundleVersion = 1
+
+// Ways of resolving a bundle test whose name is already taken in the project
+const (
+	bundleConflictSkip      = "skip"      // keep the existing test
+	bundleConflictOverwrite = "overwrite" // replace the existing test's configuration
+	bundleConflictRename    = "rename"    // import under a new name, e.g. "burn-in (2)"
+)
+
+// Bundle is a catalog of test configurations exported or imported as one YAML or
+// JSON document, so teams can share and version their tests in git. IDs, projects
+// and timestamps are left out; tests are identified by name, and a test
+// prerequisite's test_id holds the name of the required test.
+type Bundle struct {
+	Version int          `json:"version"`
+	Tests   []BundleTest `json:"tests"`
+
+	// This server has no suites or schedules; bundles from servers that do are
+	// imported without them, and each is reported as skipped
+	Suites    []map[string]interface{} `json:"suites,omitempty"`
+	Schedules []map[string]interface{} `json:"schedules,omitempty"`
+}
+
+// BundleTest is a test configuration in a bundle
+type BundleTest struct {
+	Name          string                 `json:"name"`
+	Description   string                 `json:"description,omitempty"`
+	Plugin        string                 `json:"plugin"`
+	Config        map[string]interface{} `json:"config,omitempty"`
+	Duration      string                 `json:"duration"` // e.g. 10m
+	Safety        *models.SafetyLimits   `json:"safety,omitempty"`
+	Labels        models.Labels          `json:"labels,omitempty"`
+	Prerequisites *models.Prerequisites  `json:"prerequisites,omitempty"`
+	SLA           *models.SLA            `json:"sla,omitempty"`
+}
+
+// BundleImportResult reports what was done with one entry of an imported bundle
+type BundleImportResult struct {
+	Kind         string `json:"kind"` // test, suite or schedule
+	Name         string `json:"name"`
+	ID           string `json:"id,omitempty"`
+	Action       string `json:"action"`                  // created, overwritten, renamed or skipped
+	OriginalName string `json:"original_name,omitempty"` // set when renamed
+	Reason       string `json:"reason,omitempty"`        // set when skipped
+}
+
+// BundleImportResponse lists the outcome for every entry of an imported bundle
+type BundleImportResponse struct {
+	Results []BundleImportResult `json:"results"`
+}
+
+// @Summary Export bundle
+// @Description Export the project's test configurations as a bundle for sharing or keeping in git. The tests can be narrowed with the same query parameters as listing tests. Test prerequisites refer to the required test by name.
+// @Tags bundles
+// @Produce json
+// @Produce application/yaml
+// @Param format query string false "yaml (default) or json"
+// @Param label query []string false "Label selectors, e.g. env=prod" collectionFormat(multi)
+// @Success 200 {object} Bundle
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/bundles [get]
+func (s *Server) exportBundle(c *gin.Context) {
+	format := c.DefaultQuery("format", "yaml")
+	if format != "yaml" && format != "json" {
+		respondError(c, http.StatusBadRequest, "format must be yaml or json")
+		return
+	}
+
+	filter, err := testFilter(c.Request.URL.Query())
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.ProjectID = currentProjectID(c)
+
+	tests, err := s.repo.ListTestConfigurationsFiltered(filter)
+	if err != nil {
+		s.logger.Error("Failed to list tests", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list tests")
+		return
+	}
+
+	// Prerequisites may name tests outside the export, so look names up project-wide
+	all, err := s.repo.ListTestConfigurationsFiltered(database.TestFilter{ProjectID: filter.ProjectID})
+	if err != nil {
+		s.logger.Error("Failed to list tests", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list tests")
+		return
+	}
+	names := make(map[string]string, len(all))
+	for _, test := range all {
+		names[test.ID] = test.Name
+	}
+
+	bundle := Bundle{Version: bundleVersion, Tests: make([]BundleTest, 0, len(tests))}
+	for _, test := range tests {
+		bundle.Tests = append(bundle.Tests, newBundleTest(test, names))
+	}
+
+	data, err := json.Marshal(bundle)
+	if err == nil && format == "yaml" {
+		data, err = jsonToYAML(data)
+	}
+	if err != nil {
+		s.logger.Error("Failed to encode bundle", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to encode bundle")
+		return
+	}
+
+	contentType := "application/json"
+	if format == "yaml" {
+		contentType = "application/yaml"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="ssts-bundle.%s"`, format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// @Summary Import bundle
+// @Description Import a bundle of test configurations, as YAML or JSON, into the project. Tests whose name is already taken are skipped, overwritten or imported under a new name, as on_conflict says. Nothing is imported if any test is invalid.
+// @Tags bundles
+// @Accept json
+// @Accept application/yaml
+// @Produce json
+// @Param on_conflict query string false "skip (default), overwrite or rename"
+// @Param bundle body Bundle true "Bundle"
+// @Success 200 {object} BundleImportResponse
+// @Failure 400 {object} ErrorResponse "Details lists the invalid fields"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/bundles [post]
+func (s *Server) importBundle(c *gin.Context) {
+	onConflict := c.DefaultQuery("on_conflict", bundleConflictSkip)
+	if onConflict != bundleConflictSkip && onConflict != bundleConflictOverwrite && onConflict != bundleConflictRename {
+		respondError(c, http.StatusBadRequest, "on_conflict must be skip, overwrite or rename")
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	bundle, err := parseBundle(body)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Validate every test before importing any
+	tests := make([]models.TestConfiguration, len(bundle.Tests))
+	seen := make(map[string]bool, len(bundle.Tests))
+	var fieldErrs []FieldError
+	for i, bundleTest := range bundle.Tests {
+		prefix := fmt.Sprintf("tests[%d].", i)
+		test, errs := bundleTest.toTestConfiguration()
+		if len(errs) == 0 {
+			errs = s.validateTestConfiguration(&test)
+		}
+		if seen[bundleTest.Name] {
+			errs = append(errs, FieldError{Field: "name", Message: "is given to more than one test in the bundle"})
+		}
+		seen[bundleTest.Name] = true
+		for _, e := range errs {
+			fieldErrs = append(fieldErrs, FieldError{Field: prefix + e.Field, Message: e.Message})
+		}
+		tests[i] = test
+	}
+	if len(fieldErrs) > 0 {
+		respondErrorCode(c, http.StatusBadRequest, errorCode(http.StatusBadRequest), "Invalid bundle", fieldErrs)
+		return
+	}
+
+	projectID := currentProjectID(c)
+	existing, err := s.repo.ListTestConfigurationsFiltered(database.TestFilter{ProjectID: projectID})
+	if err != nil {
+		s.logger.Error("Failed to list tests", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list tests")
+		return
+	}
+	byName := make(map[string]models.TestConfiguration, len(existing))
+	taken := make(map[string]bool, len(existing))
+	for _, test := range existing {
+		byName[test.Name] = test
+		taken[test.Name] = true
+	}
+	for _, test := range tests {
+		taken[test.Name] = true
+	}
+
+	// Decide what happens to each test, and the ID it ends up with, before saving
+	// any, so prerequisites can refer to tests later in the bundle
+	results := make([]BundleImportResult, len(tests))
+	ids := make(map[string]string, len(tests)) // bundle name -> ID
+	for i := range tests {
+		test := &tests[i]
+		result := BundleImportResult{Kind: "test", Name: test.Name, Action: "created"}
+		if current, ok := byName[test.Name]; ok {
+			switch onConflict {
+			case bundleConflictSkip:
+				result.Action = "skipped"
+				result.ID = current.ID
+				result.Reason = "a test with this name exists"
+			case bundleConflictOverwrite:
+				result.Action = "overwritten"
+				restoreTestSecrets(test, current)
+				test.ID = current.ID
+				test.Created = current.Created
+				test.CreatedBy = current.CreatedBy
+			case bundleConflictRename:
+				result.Action = "renamed"
+				result.OriginalName = test.Name
+				test.Name = uniqueName(test.Name, taken)
+				result.Name = test.Name
+			}
+		}
+		if test.ID == "" && result.Action != "skipped" {
+			test.ID = uuid.New().String()
+		}
+		if result.ID == "" {
+			result.ID = test.ID
+		}
+		taken[test.Name] = true
+		ids[bundle.Tests[i].Name] = result.ID
+		results[i] = result
+	}
+
+	now := time.Now()
+	for i := range tests {
+		test := &tests[i]
+		if results[i].Action == "skipped" {
+			continue
+		}
+		for j, prerequisite := range test.Prerequisites.Tests {
+			if id, ok := ids[prerequisite.TestID]; ok {
+				test.Prerequisites.Tests[j].TestID = id
+			} else if current, ok := byName[prerequisite.TestID]; ok {
+				test.Prerequisites.Tests[j].TestID = current.ID
+			}
+		}
+
+		test.ProjectID = projectID
+		test.Updated = now
+		if results[i].Action == "overwritten" {
+			err = s.repo.UpdateTestConfiguration(test)
+		} else {
+			test.Created = now
+			test.CreatedBy = c.GetString(contextUserIDKey)
+			err = s.repo.CreateTestConfiguration(test)
+		}
+		if err != nil {
+			s.logger.Error("Failed to import test", zap.String("name", test.Name), zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to import test "+test.Name)
+			return
+		}
+	}
+
+	for _, suite := range bundle.Suites {
+		results = append(results, unsupportedBundleEntry("suite", suite))
+	}
+	for _, schedule := range bundle.Schedules {
+		results = append(results, unsupportedBundleEntry("schedule", schedule))
+	}
+
+	c.JSON(http.StatusOK, BundleImportResponse{Results: results})
+}
+
+// parseBundle decodes a bundle from YAML or JSON. YAML is decoded generically and
+// re-encoded as JSON, so bundles are read with the same field names either way.
+func parseBundle(data []byte) (*Bundle, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if document == nil {
+		return nil, fmt.Errorf("bundle is empty")
+	}
+
+	converted, err := json.Marshal(yamlToJSONValue(document))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+
+	var bundle Bundle
+	decoder := json.NewDecoder(bytes.NewReader(converted))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if bundle.Version > bundleVersion {
+		return nil, fmt.Errorf("bundle version %d is newer than this server supports (%d)", bundle.Version, bundleVersion)
+	}
+	return &bundle, nil
+}
+
+// yamlToJSONValue converts what YAML decodes into values JSON can encode: YAML
+// allows non-string mapping keys, which JSON objects don't
+func yamlToJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = yamlToJSONValue(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = yamlToJSONValue(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = yamlToJSONValue(item)
+		}
+		return v
+	}
+	return value
+}
+
+// jsonToYAML re-encodes a JSON document as block-style YAML, keeping the order
+// of object keys
+func jsonToYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	clearYAMLStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// clearYAMLStyle drops the flow and quoting styles a node decoded from JSON has
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
+
+// newBundleTest converts a test configuration for a bundle, replacing the IDs of
+// required tests with their names
+func newBundleTest(test models.TestConfiguration, names map[string]string) BundleTest {
+	bundleTest := BundleTest{
+		Name:        test.Name,
+		Description: test.Description,
+		Plugin:      test.Plugin,
+		Duration:    test.Duration.String(),
+		Labels:      test.Labels,
+	}
+	if len(test.Config) > 0 {
+		json.Unmarshal(secrets.RedactConfig(test.Config), &bundleTest.Config)
+	}
+	if test.Safety != (models.SafetyLimits{}) {
+		safety := test.Safety
+		bundleTest.Safety = &safety
+	}
+	if !test.Prerequisites.Empty() {
+		prerequisites := test.Prerequisites
+		prerequisites.Tests = make([]models.TestPrerequisite, len(test.Prerequisites.Tests))
+		for i, prerequisite := range test.Prerequisites.Tests {
+			if name, ok := names[prerequisite.TestID]; ok {
+				prerequisite.TestID = name
+			}
+			prerequisites.Tests[i] = prerequisite
+		}
+		bundleTest.Prerequisites = &prerequisites
+	}
+	if !test.SLA.Empty() {
+		sla := test.SLA
+		bundleTest.SLA = &sla
+	}
+	return bundleTest
+}
+
+// toTestConfiguration converts a bundle test into a test configuration without
+// an ID or project
+func (t BundleTest) toTestConfiguration() (models.TestConfiguration, []FieldError) {
+	test := models.TestConfiguration{
+		Name:        t.Name,
+		Description: t.Description,
+		Plugin:      t.Plugin,
+		Labels:      t.Labels,
+	}
+
+	var errs []FieldError
+	if t.Duration != "" {
+		duration, err := time.ParseDuration(t.Duration)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "duration", Message: "must be a duration such as 10m"})
+		}
+		test.Duration = duration
+	}
+	if t.Config != nil {
+		config, err := json.Marshal(t.Config)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "config", Message: err.Error()})
+		}
+		test.Config = config
+	}
+	if t.Safety != nil {
+		test.Safety = *t.Safety
+	}
+	if t.Prerequisites != nil {
+		test.Prerequisites = *t.Prerequisites
+	}
+	if t.SLA != nil {
+		test.SLA = *t.SLA
+	}
+	return test, errs
+}
+
+// uniqueName returns the first of "name (2)", "name (3)", ... not taken
+func uniqueName(name string, taken map[string]bool) string {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", name, n)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// unsupportedBundleEntry reports a bundle entry of a kind this server doesn't have
+func unsupportedBundleEntry(kind string, entry map[string]interface{}) BundleImportResult {
+	name, _ := entry["name"].(string)
+	return BundleImportResult{
+		Kind:   kind,
+		Name:   strings.TrimSpace(name),
+		Action: "skipped",
+		Reason: kind + "s are not supported by this server",
+	}
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+const testBundle = `
+version: 1
+tests:
+  - name: warm-up
+    plugin: cpu-stress
+    duration: 1m
+    config:
+      workers: 1
+      algorithm: prime
+      intensity: 20
+  - name: burn
+    description: full load after warming up
+    plugin: cpu-stress
+    duration: 10m
+    labels:
+      env: lab
+    config:
+      workers: 2
+      algorithm: prime
+      intensity: 90
+    prerequisites:
+      tests:
+        - test_id: warm-up
+suites:
+  - name: nightly
+`
+
+// importBundleRequest posts a YAML bundle
+func (s *Server) importBundleRequest(t *testing.T, bundle, onConflict string) (*httptest.ResponseRecorder, BundleImportResponse) {
+	t.Helper()
+	path := "/api/v1/bundles"
+	if onConflict != "" {
+		path += "?on_conflict=" + onConflict
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(bundle))
+	req.Header.Set("Content-Type", "application/yaml")
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+
+	var resp BundleImportResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec, resp
+}
+
+func testsByName(t *testing.T, repo *database.MemoryRepository) map[string]models.TestConfiguration {
+	t.Helper()
+	tests, err := repo.ListTestConfigurationsFiltered(database.TestFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]models.TestConfiguration, len(tests))
+	for _, test := range tests {
+		byName[test.Name] = test
+	}
+	return byName
+}
+
+func TestImportBundle(t *testing.T) {
+	server, repo := newTestServer(t)
+
+	rec, resp := server.importBundleRequest(t, testBundle, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import: status %d: %s", rec.Code, rec.Body)
+	}
+	if len(resp.Results) != 3 || resp.Results[0].Action != "created" || resp.Results[1].Action != "created" {
+		t.Fatalf("results = %+v", resp.Results)
+	}
+	if suite := resp.Results[2]; suite.Kind != "suite" || suite.Name != "nightly" || suite.Action != "skipped" {
+		t.Errorf("suite result = %+v, want it skipped", suite)
+	}
+
+	tests := testsByName(t, repo)
+	burn, warmUp := tests["burn"], tests["warm-up"]
+	if burn.Labels["env"] != "lab" || burn.Description != "full load after warming up" {
+		t.Errorf("imported burn = %+v", burn)
+	}
+	if len(burn.Prerequisites.Tests) != 1 || burn.Prerequisites.Tests[0].TestID != warmUp.ID {
+		t.Errorf("burn prerequisites = %+v, want warm-up's ID %q", burn.Prerequisites.Tests, warmUp.ID)
+	}
+
+	// Importing again skips the tests that now exist
+	_, resp = server.importBundleRequest(t, testBundle, "skip")
+	if resp.Results[0].Action != "skipped" || resp.Results[0].ID != warmUp.ID {
+		t.Errorf("re-import results = %+v", resp.Results)
+	}
+
+	_, resp = server.importBundleRequest(t, strings.Replace(testBundle, "intensity: 90", "intensity: 75", 1), "overwrite")
+	if resp.Results[1].Action != "overwritten" || resp.Results[1].ID != burn.ID {
+		t.Errorf("overwrite results = %+v", resp.Results)
+	}
+	if updated := testsByName(t, repo)["burn"]; !strings.Contains(string(updated.Config), `"intensity":75`) {
+		t.Errorf("overwritten config = %s", updated.Config)
+	}
+
+	_, resp = server.importBundleRequest(t, testBundle, "rename")
+	if resp.Results[1].Action != "renamed" || resp.Results[1].Name != "burn (2)" || resp.Results[1].OriginalName != "burn" {
+		t.Errorf("rename results = %+v", resp.Results)
+	}
+	tests = testsByName(t, repo)
+	if len(tests) != 4 {
+		t.Fatalf("got %d tests after renaming, want 4", len(tests))
+	}
+	// The renamed copy depends on the renamed warm-up imported with it
+	if renamed := tests["burn (2)"]; renamed.Prerequisites.Tests[0].TestID != tests["warm-up (2)"].ID {
+		t.Errorf("renamed prerequisites = %+v", renamed.Prerequisites.Tests)
+	}
+}
+
+func TestImportBundleRejectsInvalidTests(t *testing.T) {
+	server, repo := newTestServer(t)
+
+	for name, bundle := range map[string]string{
+		"unknown plugin": strings.Replace(testBundle, "plugin: cpu-stress", "plugin: gpu-stress", 1),
+		"bad duration":   strings.Replace(testBundle, "duration: 1m", "duration: soon", 1),
+		"duplicate name": strings.Replace(testBundle, "name: burn", "name: warm-up", 1),
+		"unknown field":  strings.Replace(testBundle, "plugin: cpu-stress", "plugin: cpu-stress\n    schedule: nightly", 1),
+		"newer version":  strings.Replace(testBundle, "version: 1", "version: 99", 1),
+	} {
+		if rec, _ := server.importBundleRequest(t, bundle, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", name, rec.Code, rec.Body)
+		}
+	}
+	if tests := testsByName(t, repo); len(tests) != 0 {
+		t.Errorf("invalid bundles imported %d tests", len(tests))
+	}
+}
+
+func TestExportBundleRoundTrip(t *testing.T) {
+	server, repo := newTestServer(t)
+	if rec, _ := server.importBundleRequest(t, testBundle, ""); rec.Code != http.StatusOK {
+		t.Fatalf("import: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec := server.serve(http.MethodGet, "/api/v1/bundles", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export: status %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/yaml" {
+		t.Errorf("content type = %q", ct)
+	}
+	exported := rec.Body.String()
+	if !strings.Contains(exported, "test_id: warm-up") || !strings.Contains(exported, "duration: 10m0s") {
+		t.Errorf("exported bundle doesn't name prerequisites or durations readably:\n%s", exported)
+	}
+
+	// The export imports into another server as the same tests
+	other, otherRepo := newTestServer(t)
+	if rec, resp := other.importBundleRequest(t, exported, ""); rec.Code != http.StatusOK || len(resp.Results) != 2 {
+		t.Fatalf("import of export: status %d: %s", rec.Code, rec.Body)
+	}
+	original, copied := testsByName(t, repo)["burn"], testsByName(t, otherRepo)["burn"]
+	if copied.Duration != original.Duration || string(copied.Config) != string(original.Config) || copied.Prerequisites.Tests[0].TestID != testsByName(t, otherRepo)["warm-up"].ID {
+		t.Errorf("round trip changed burn: %+v, want %+v", copied, original)
+	}
+
+	rec = server.serve(http.MethodGet, "/api/v1/bundles?format=json&label=env=lab", nil)
+	var bundle Bundle
+	if err := json.Unmarshal(rec.Body.Bytes(), &bundle); err != nil {
+		t.Fatalf("json export: %v: %s", err, rec.Body)
+	}
+	if len(bundle.Tests) != 1 || bundle.Tests[0].Name != "burn" {
+		t.Errorf("label filtered export = %+v", bundle.Tests)
+	}
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Downsampling methods of execution charts
+const (
+	chartMethodLTTB    = "lttb"    // keeps the points that shape the line, peaks included
+	chartMethodAverage = "average" // averages equal time windows
+)
+
+// Bounds on the points per chart series
+const (
+	defaultChartPoints = 500
+	minChartPoints     = 3
+	maxChartPoints     = 5000
+)
+
+// chartIgnoredTags are tags every point of an execution carries, which don't
+// tell its series apart
+var chartIgnoredTags = map[string]bool{"execution_id": true, "test_id": true}
+
+// ExecutionCharts is an execution's metrics downsampled for charting
+type ExecutionCharts struct {
+	ExecutionID string        `json:"execution_id"`
+	Method      string        `json:"method"`
+	Points      int           `json:"points"` // most points of each series
+	Series      []ChartSeries `json:"series"`
+}
+
+// ChartSeries is one numeric field of the points with one source, type and set of tags
+type ChartSeries struct {
+	Key       string            `json:"key"` // source.type.field, as in comparisons
+	Tags      map[string]string `json:"tags,omitempty"`
+	RawPoints int               `json:"raw_points"` // points before downsampling
+	Points    []ChartPoint      `json:"points"`
+}
+
+// ChartPoint is one value of a chart series
+type ChartPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// @Summary Get execution charts
+// @Description Get an execution's metrics as series downsampled on the server to at most the given number of points each, sized for charting rather than sending every raw point to the browser. Each numeric field of each source, type and tag set is a series keyed by source.type.field, covering CPU, memory and I/O as well as the plugin's own measurements. lttb (largest triangle three buckets) keeps the points that give the line its shape, peaks included; average averages equal time windows.
+// @Tags executions
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Param points query int false "Most points per series, 3 to 5000" default(500)
+// @Param method query string false "lttb or average" default(lttb)
+// @Param metrics query string false "Comma-separated series keys to include (source.type.field); all by default"
+// @Param start query string false "Start time (RFC3339)"
+// @Param end query string false "End time (RFC3339)"
+// @Param window query string false "Named window instead of start and end: steady_state or last_run"
+// @Success 200 {object} ExecutionCharts
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "The execution has no steady state"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/charts [get]
+func (s *Server) getExecutionCharts(c *gin.Context) {
+	id := c.Param("id")
+
+	points := defaultChartPoints
+	if value := c.Query("points"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < minChartPoints || parsed > maxChartPoints {
+			respondError(c, http.StatusBadRequest, "points must be between 3 and 5000")
+			return
+		}
+		points = parsed
+	}
+	method := c.DefaultQuery("method", chartMethodLTTB)
+	if method != chartMethodLTTB && method != chartMethodAverage {
+		respondError(c, http.StatusBadRequest, "method must be lttb or average")
+		return
+	}
+
+	var wanted map[string]bool
+	if value := c.Query("metrics"); value != "" {
+		wanted = make(map[string]bool)
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				wanted[key] = true
+			}
+		}
+	}
+
+	timeRange, ok := s.executionMetricsRange(c, id)
+	if !ok {
+		return
+	}
+
+	metrics, err := s.orchestrator.GetTestMetrics(id, timeRange)
+	if err != nil {
+		if errors.Is(err, core.ErrExecutionNotFound) {
+			respondError(c, http.StatusNotFound, "Execution not found")
+		} else {
+			s.logger.Error("Failed to get execution metrics", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get execution metrics")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ExecutionCharts{
+		ExecutionID: id,
+		Method:      method,
+		Points:      points,
+		Series:      chartSeries(metrics, points, method, wanted),
+	})
+}
+
+// chartSeries groups the numeric fields of metric points into series, sorted by
+// key and tags, and downsamples each
+func chartSeries(metrics []models.MetricPoint, points int, method string, wanted map[string]bool) []ChartSeries {
+	byID := make(map[string]*ChartSeries)
+	for _, point := range metrics {
+		var tags map[string]string
+		for name, value := range point.Tags {
+			if chartIgnoredTags[name] {
+				continue
+			}
+			if tags == nil {
+				tags = make(map[string]string, len(point.Tags))
+			}
+			tags[name] = value
+		}
+		tagID := chartTagID(tags)
+
+		for field, raw := range point.Fields {
+			value, ok := numericValue(raw)
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			key := point.Source + "." + point.Type + "." + field
+			if wanted != nil && !wanted[key] {
+				continue
+			}
+
+			id := key + "{" + tagID + "}"
+			series := byID[id]
+			if series == nil {
+				series = &ChartSeries{Key: key, Tags: tags}
+				byID[id] = series
+			}
+			series.Points = append(series.Points, ChartPoint{Time: point.Timestamp, Value: value})
+		}
+	}
+
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	result := make([]ChartSeries, 0, len(ids))
+	for _, id := range ids {
+		series := byID[id]
+		sort.SliceStable(series.Points, func(i, j int) bool { return series.Points[i].Time.Before(series.Points[j].Time) })
+		series.RawPoints = len(series.Points)
+		if method == chartMethodAverage {
+			series.Points = averageWindows(series.Points, points)
+		} else {
+			series.Points = lttb(series.Points, points)
+		}
+		result = append(result, *series)
+	}
+	return result
+}
+
+// chartTagID writes tags in a stable order, telling tag sets apart
+func chartTagID(tags map[string]string) string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + tags[name]
+	}
+	return strings.Join(parts, ",")
+}
+
+// lttb downsamples points sorted by time to threshold points with Largest
+// Triangle Three Buckets: the first and last points are kept, and from each
+// bucket in between the point forming the largest triangle with the point kept
+// before it and the average of the next bucket
+func lttb(points []ChartPoint, threshold int) []ChartPoint {
+	if threshold >= len(points) || threshold < minChartPoints {
+		return points
+	}
+
+	origin := points[0].Time
+	x := func(i int) float64 { return points[i].Time.Sub(origin).Seconds() }
+
+	sampled := make([]ChartPoint, 0, threshold)
+	sampled = append(sampled, points[0])
+	bucketSize := float64(len(points)-2) / float64(threshold-2)
+	kept := 0
+	for bucket := 0; bucket < threshold-2; bucket++ {
+		nextStart := int(float64(bucket+1)*bucketSize) + 1
+		nextEnd := int(float64(bucket+2)*bucketSize) + 1
+		if nextEnd > len(points) {
+			nextEnd = len(points)
+		}
+		if nextStart >= nextEnd {
+			nextStart = nextEnd - 1
+		}
+		var avgX, avgY float64
+		for i := nextStart; i < nextEnd; i++ {
+			avgX += x(i)
+			avgY += points[i].Value
+		}
+		avgX /= float64(nextEnd - nextStart)
+		avgY /= float64(nextEnd - nextStart)
+
+		start := int(float64(bucket)*bucketSize) + 1
+		end := int(float64(bucket+1)*bucketSize) + 1
+		keptX, keptY := x(kept), points[kept].Value
+		largest, chosen := -1.0, start
+		for i := start; i < end; i++ {
+			area := math.Abs((keptX-avgX)*(points[i].Value-keptY) - (keptX-x(i))*(avgY-keptY))
+			if area > largest {
+				largest, chosen = area, i
+			}
+		}
+		sampled = append(sampled, points[chosen])
+		kept = chosen
+	}
+	return append(sampled, points[len(points)-1])
+}
+
+// averageWindows downsamples points sorted by time by splitting their time span
+// into windows equal windows, replacing the points of each by their average at
+// their average time. Windows without points are left out.
+func averageWindows(points []ChartPoint, windows int) []ChartPoint {
+	if windows >= len(points) {
+		return points
+	}
+
+	origin := points[0].Time
+	width := points[len(points)-1].Time.Sub(origin) / time.Duration(windows)
+	if width <= 0 {
+		width = 1
+	}
+
+	averaged := make([]ChartPoint, 0, windows)
+	var offsets time.Duration
+	var sum float64
+	count, current := 0, -1
+	flush := func() {
+		if count > 0 {
+			averaged = append(averaged, ChartPoint{
+				Time:  origin.Add(offsets / time.Duration(count)),
+				Value: sum / float64(count),
+			})
+		}
+		offsets, sum, count = 0, 0, 0
+	}
+	for _, point := range points {
+		offset := point.Time.Sub(origin)
+		window := int(offset / width)
+		if window >= windows {
+			window = windows - 1
+		}
+		if window != current {
+			flush()
+			current = window
+		}
+		offsets += offset
+		sum += point.Value
+		count++
+	}
+	flush()
+	return averaged
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// sinePoints is a second-by-second sine wave with a spike at spike
+func sinePoints(n, spike int) []ChartPoint {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := make([]ChartPoint, n)
+	for i := range points {
+		points[i] = ChartPoint{Time: start.Add(time.Duration(i) * time.Second), Value: math.Sin(float64(i) / 50)}
+	}
+	points[spike].Value = 100
+	return points
+}
+
+func TestLTTBKeepsEndsAndPeaks(t *testing.T) {
+	points := sinePoints(10000, 4321)
+
+	sampled := lttb(points, 200)
+
+	if len(sampled) != 200 {
+		t.Fatalf("got %d points, want 200", len(sampled))
+	}
+	if sampled[0] != points[0] || sampled[len(sampled)-1] != points[len(points)-1] {
+		t.Errorf("first and last points were not kept")
+	}
+	spike := false
+	for i, point := range sampled {
+		if i > 0 && !point.Time.After(sampled[i-1].Time) {
+			t.Fatalf("point %d at %v is not after the one before it", i, point.Time)
+		}
+		spike = spike || point.Value == 100
+	}
+	if !spike {
+		t.Errorf("the spike was dropped")
+	}
+
+	if short := lttb(points[:100], 200); len(short) != 100 {
+		t.Errorf("a series shorter than the threshold has %d points, want all 100", len(short))
+	}
+}
+
+func TestAverageWindows(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var points []ChartPoint
+	for i := 0; i < 100; i++ {
+		points = append(points, ChartPoint{Time: start.Add(time.Duration(i) * time.Second), Value: float64(i / 10)})
+	}
+
+	averaged := averageWindows(points, 10)
+
+	if len(averaged) != 10 {
+		t.Fatalf("got %d points, want 10: %+v", len(averaged), averaged)
+	}
+	for i, point := range averaged {
+		if point.Value != float64(i) {
+			t.Errorf("window %d = %v, want %d", i, point.Value, i)
+		}
+	}
+}
+
+func TestChartSeriesSplitsFieldsAndTags(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var metrics []models.MetricPoint
+	for i := 0; i < 1000; i++ {
+		at := start.Add(time.Duration(i) * time.Second)
+		metrics = append(metrics,
+			models.MetricPoint{Timestamp: at, Source: "system", Type: "cpu", Tags: map[string]string{"execution_id": "e1"},
+				Fields: map[string]interface{}{"usage_percent": float64(i % 100), "model": "xeon"}},
+			models.MetricPoint{Timestamp: at, Source: "plugin", Type: "io_operation", Tags: map[string]string{"execution_id": "e1", "operation": "read"},
+				Fields: map[string]interface{}{"latency_us": 10}},
+			models.MetricPoint{Timestamp: at, Source: "plugin", Type: "io_operation", Tags: map[string]string{"execution_id": "e1", "operation": "write"},
+				Fields: map[string]interface{}{"latency_us": 20}},
+		)
+	}
+
+	series := chartSeries(metrics, 100, chartMethodLTTB, nil)
+
+	if len(series) != 3 {
+		t.Fatalf("got %d series, want cpu usage and read and write latency: %+v", len(series), series)
+	}
+	want := []struct{ key, operation string }{
+		{"plugin.io_operation.latency_us", "read"},
+		{"plugin.io_operation.latency_us", "write"},
+		{"system.cpu.usage_percent", ""},
+	}
+	for i, w := range want {
+		if series[i].Key != w.key || series[i].Tags["operation"] != w.operation {
+			t.Errorf("series %d = %s %v, want %s operation %q", i, series[i].Key, series[i].Tags, w.key, w.operation)
+		}
+		if _, ok := series[i].Tags["execution_id"]; ok {
+			t.Errorf("series %d is tagged with the execution", i)
+		}
+		if series[i].RawPoints != 1000 || len(series[i].Points) != 100 {
+			t.Errorf("series %d has %d of %d points, want 100 of 1000", i, len(series[i].Points), series[i].RawPoints)
+		}
+	}
+
+	if only := chartSeries(metrics, 100, chartMethodAverage, map[string]bool{"system.cpu.usage_percent": true}); len(only) != 1 || len(only[0].Points) != 100 {
+		t.Errorf("filtered series = %+v, want cpu usage in 100 points", only)
+	}
+}
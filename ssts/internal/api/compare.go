@@ -0,0 +1,340 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// maxCompareExecutions bounds how many executions can be compared at once
+const maxCompareExecutions = 10
+
+// ExecutionComparison is the response for comparing several executions
+type ExecutionComparison struct {
+	Resolution string                        `json:"resolution"`
+	Executions []ComparedExecution           `json:"executions"`
+	Series     map[string][]ComparisonSeries `json:"series"` // keyed by metric, one series per execution
+	SystemDiff []SystemDifference            `json:"system_diff,omitempty"`
+}
+
+// SystemDifference is a kernel or OS setting that differs between the compared
+// executions, e.g. vm.swappiness or io_scheduler.sda
+type SystemDifference struct {
+	Setting string            `json:"setting"`
+	Values  map[string]string `json:"values"` // keyed by execution ID; empty when unset
+}
+
+// ComparedExecution describes one execution in a comparison
+type ComparedExecution struct {
+	ID        string                   `json:"id"`
+	TestID    string                   `json:"test_id"`
+	Status    models.ExecutionStatus   `json:"status"`
+	StartTime *time.Time               `json:"start_time"`
+	Duration  time.Duration            `json:"duration"`
+	Summary   map[string]MetricSummary `json:"summary"` // keyed by metric
+	System    *models.SystemSnapshot   `json:"system,omitempty"`
+}
+
+// MetricSummary holds summary statistics for a single metric of one execution
+type MetricSummary struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	P50    float64 `json:"p50"`
+	P95    float64 `json:"p95"`
+}
+
+// ComparisonSeries is one execution's values for a metric, aligned on offset from its start
+type ComparisonSeries struct {
+	ExecutionID string            `json:"execution_id"`
+	Points      []ComparisonPoint `json:"points"`
+}
+
+// ComparisonPoint is a single value at an offset (in seconds) from the start of the execution
+type ComparisonPoint struct {
+	Offset float64 `json:"offset"`
+	Value  float64 `json:"value"`
+}
+
+// @Summary Compare executions
+// @Description Align the metric time series of several executions on relative time and summarize each, for overlay charts. Kernel and OS settings that differ between the executions are listed in system_diff.
+// @Tags executions
+// @Accept json
+// @Produce json
+// @Param ids query string true "Comma-separated execution IDs (2-10)"
+// @Param metrics query string false "Comma-separated metric keys to include (source.type.field); all by default"
+// @Param resolution query string false "Bucket width used to align series" default(1s)
+// @Success 200 {object} ExecutionComparison
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/compare [get]
+func (s *Server) compareExecutions(c *gin.Context) {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(c.Query("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 || len(ids) > maxCompareExecutions {
+		respondError(c, http.StatusBadRequest, "ids must list between 2 and 10 distinct execution IDs")
+		return
+	}
+
+	resolution := time.Second
+	if value := c.Query("resolution"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			respondError(c, http.StatusBadRequest, "Invalid resolution")
+			return
+		}
+		resolution = parsed
+	}
+
+	var wanted map[string]bool
+	if value := c.Query("metrics"); value != "" {
+		wanted = make(map[string]bool)
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				wanted[key] = true
+			}
+		}
+	}
+
+	comparison := ExecutionComparison{
+		Resolution: resolution.String(),
+		Executions: make([]ComparedExecution, 0, len(ids)),
+		Series:     make(map[string][]ComparisonSeries),
+	}
+
+	for _, id := range ids {
+		execution, err := s.orchestrator.GetTestStatus(id)
+		if err != nil {
+			if errors.Is(err, core.ErrExecutionNotFound) {
+				respondError(c, http.StatusNotFound, "Execution not found: "+id)
+			} else {
+				s.logger.Error("Failed to get execution", zap.Error(err))
+				respondError(c, http.StatusInternalServerError, "Failed to get execution")
+			}
+			return
+		}
+		if execution.ProjectID != currentProjectID(c) {
+			respondError(c, http.StatusNotFound, "Execution not found: "+id)
+			return
+		}
+
+		metrics, err := s.orchestrator.GetTestMetrics(id, models.TimeRange{})
+		if err != nil {
+			s.logger.Error("Failed to get execution metrics", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get execution metrics")
+			return
+		}
+
+		series := alignMetrics(metrics, executionStart(execution, metrics), resolution, wanted)
+
+		compared := ComparedExecution{
+			ID:        execution.ID,
+			TestID:    execution.TestID,
+			Status:    execution.Status,
+			StartTime: execution.StartTime,
+			Duration:  execution.Duration,
+			Summary:   make(map[string]MetricSummary, len(series)),
+			System:    execution.System,
+		}
+
+		for key, points := range series {
+			compared.Summary[key] = summarizePoints(points)
+			comparison.Series[key] = append(comparison.Series[key], ComparisonSeries{
+				ExecutionID: execution.ID,
+				Points:      points,
+			})
+		}
+
+		comparison.Executions = append(comparison.Executions, compared)
+	}
+
+	comparison.SystemDiff = diffSystems(comparison.Executions)
+
+	c.JSON(http.StatusOK, comparison)
+}
+
+// diffSystems lists the settings whose values differ between the executions'
+// system snapshots, sorted by setting. Executions recorded before snapshots
+// were taken have none and are left out.
+func diffSystems(executions []ComparedExecution) []SystemDifference {
+	settings := make(map[string]map[string]string)
+	snapshots := 0
+	for _, execution := range executions {
+		if execution.System == nil {
+			continue
+		}
+		snapshots++
+		for setting, value := range execution.System.Settings() {
+			if settings[setting] == nil {
+				settings[setting] = make(map[string]string)
+			}
+			settings[setting][execution.ID] = value
+		}
+	}
+
+	var diff []SystemDifference
+	for setting, values := range settings {
+		distinct := make(map[string]bool)
+		for _, value := range values {
+			distinct[value] = true
+		}
+		if len(distinct) < 2 && len(values) == snapshots {
+			continue
+		}
+		for _, execution := range executions {
+			if _, ok := values[execution.ID]; !ok && execution.System != nil {
+				values[execution.ID] = ""
+			}
+		}
+		diff = append(diff, SystemDifference{Setting: setting, Values: values})
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i].Setting < diff[j].Setting })
+	return diff
+}
+
+// executionStart returns the reference time that offsets are measured from
+func executionStart(execution *models.TestExecution, metrics []models.MetricPoint) time.Time {
+	if execution.StartTime != nil {
+		return *execution.StartTime
+	}
+	var start time.Time
+	for _, point := range metrics {
+		if start.IsZero() || point.Timestamp.Before(start) {
+			start = point.Timestamp
+		}
+	}
+	return start
+}
+
+// alignMetrics groups numeric metric fields into series keyed by "source.type.field",
+// averaging values that fall into the same resolution bucket
+func alignMetrics(metrics []models.MetricPoint, start time.Time, resolution time.Duration, wanted map[string]bool) map[string][]ComparisonPoint {
+	type bucket struct {
+		sum   float64
+		count int
+	}
+	buckets := make(map[string]map[int64]*bucket)
+
+	for _, point := range metrics {
+		index := int64(point.Timestamp.Sub(start) / resolution)
+		if index < 0 {
+			continue
+		}
+
+		for field, raw := range point.Fields {
+			value, ok := numericValue(raw)
+			if !ok {
+				continue
+			}
+
+			key := point.Source + "." + point.Type + "." + field
+			if wanted != nil && !wanted[key] {
+				continue
+			}
+
+			if buckets[key] == nil {
+				buckets[key] = make(map[int64]*bucket)
+			}
+			b := buckets[key][index]
+			if b == nil {
+				b = &bucket{}
+				buckets[key][index] = b
+			}
+			b.sum += value
+			b.count++
+		}
+	}
+
+	series := make(map[string][]ComparisonPoint, len(buckets))
+	for key, byIndex := range buckets {
+		indexes := make([]int64, 0, len(byIndex))
+		for index := range byIndex {
+			indexes = append(indexes, index)
+		}
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+		points := make([]ComparisonPoint, 0, len(indexes))
+		for _, index := range indexes {
+			b := byIndex[index]
+			points = append(points, ComparisonPoint{
+				Offset: (time.Duration(index) * resolution).Seconds(),
+				Value:  b.sum / float64(b.count),
+			})
+		}
+		series[key] = points
+	}
+
+	return series
+}
+
+// summarizePoints computes summary statistics over a series
+func summarizePoints(points []ComparisonPoint) MetricSummary {
+	if len(points) == 0 {
+		return MetricSummary{}
+	}
+
+	values := make([]float64, len(points))
+	var sum float64
+	for i, point := range points {
+		values[i] = point.Value
+		sum += point.Value
+	}
+	sort.Float64s(values)
+
+	mean := sum / float64(len(values))
+	var variance float64
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	variance /= float64(len(values))
+
+	return MetricSummary{
+		Count:  len(values),
+		Min:    values[0],
+		Max:    values[len(values)-1],
+		Mean:   mean,
+		StdDev: math.Sqrt(variance),
+		P50:    values[int(float64(len(values)-1)*0.50)],
+		P95:    values[int(float64(len(values)-1)*0.95)],
+	}
+}
+
+// numericValue converts a metric field value to float64 if it is numeric
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestDiffSystems(t *testing.T) {
+	executions := []ComparedExecution{
+		{ID: "a", System: &models.SystemSnapshot{
+			KernelVersion: "6.5.0",
+			KernelParams:  map[string]string{"vm.swappiness": "60", "vm.dirty_ratio": "20"},
+			IOSchedulers:  map[string]string{"sda": "mq-deadline"},
+			CPUGovernor:   "performance",
+		}},
+		{ID: "b", System: &models.SystemSnapshot{
+			KernelVersion: "6.5.0",
+			KernelParams:  map[string]string{"vm.swappiness": "10", "vm.dirty_ratio": "20"},
+			CPUGovernor:   "performance",
+		}},
+		{ID: "old"}, // recorded before snapshots were taken
+	}
+
+	diff := diffSystems(executions)
+
+	want := map[string]map[string]string{
+		"vm.swappiness":    {"a": "60", "b": "10"},
+		"io_scheduler.sda": {"a": "mq-deadline", "b": ""},
+	}
+	if len(diff) != len(want) {
+		t.Fatalf("diff = %+v, want settings %v", diff, want)
+	}
+	for i, setting := range []string{"io_scheduler.sda", "vm.swappiness"} {
+		if diff[i].Setting != setting {
+			t.Errorf("diff[%d] = %s, want %s", i, diff[i].Setting, setting)
+			continue
+		}
+		for id, value := range want[setting] {
+			if diff[i].Values[id] != value {
+				t.Errorf("%s of %s = %q, want %q", setting, id, diff[i].Values[id], value)
+			}
+		}
+		if _, ok := diff[i].Values["old"]; ok {
+			t.Errorf("%s lists an execution without a snapshot", setting)
+		}
+	}
+
+	if diff := diffSystems(executions[:1]); diff != nil {
+		t.Errorf("diff of a single snapshot = %+v, want none", diff)
+	}
+}
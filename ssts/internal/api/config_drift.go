@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/secrets"
+)
+
+// ConfigResponse is the configuration in effect and how the configuration file
+// has drifted from it since it was loaded
+type ConfigResponse struct {
+	File        string                 `json:"file,omitempty"`        // the configuration file loaded, if any
+	Environment string                 `json:"environment,omitempty"` // the environment loaded from it, if any
+	Config      map[string]interface{} `json:"config"`                // settings in effect, by dotted key, secrets redacted
+	Drifted     bool                   `json:"drifted"`
+	Drift       []config.Drift         `json:"drift"`                // settings whose value in the file differs, secrets redacted
+	FileError   string                 `json:"file_error,omitempty"` // why the file couldn't be read for comparison
+}
+
+// ConfigDriftEvent is broadcast to WebSocket clients, as a config_drift message,
+// when the settings that differ between the configuration file and the
+// configuration in effect change, so UIs can show or clear a warning banner
+type ConfigDriftEvent struct {
+	Drifted bool     `json:"drifted"`
+	File    string   `json:"file"`
+	Keys    []string `json:"keys,omitempty"`
+}
+
+// configDriftState remembers the drift last reported to WebSocket clients
+type configDriftState struct {
+	mu       sync.Mutex
+	reported string // JSON of the drift; empty until drift is first reported
+}
+
+// @Summary Get effective configuration
+// @Description Get the configuration in effect, with secrets redacted, and the settings whose value in the configuration file on disk differs from it, as after the file was edited without restarting the server. Connected WebSocket clients receive a config_drift message whenever those settings change.
+// @Tags system
+// @Produce json
+// @Success 200 {object} ConfigResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/system/config [get]
+func (s *Server) getSystemConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, s.checkConfigDrift())
+}
+
+// checkConfigDrift compares the configuration file with the configuration in
+// effect and tells WebSocket clients when the settings that differ have changed
+// since they were last told
+func (s *Server) checkConfigDrift() ConfigResponse {
+	response := ConfigResponse{
+		File:        s.config.File,
+		Environment: s.config.Environment,
+		Config:      redactSettings(s.config.Settings()),
+		Drift:       []config.Drift{},
+	}
+	if s.config.File == "" {
+		return response
+	}
+
+	file, err := config.ReadFile(s.config.File, s.config.Environment)
+	if err != nil {
+		response.FileError = err.Error()
+		return response
+	}
+	for _, drift := range config.Diff(s.config, file) {
+		if secretSetting(drift.Key) {
+			drift.Running, drift.File = redactedValue(drift.Running), redactedValue(drift.File)
+		}
+		response.Drift = append(response.Drift, drift)
+	}
+	response.Drifted = len(response.Drift) > 0
+
+	event := ConfigDriftEvent{Drifted: response.Drifted, File: s.config.File}
+	for _, drift := range response.Drift {
+		event.Keys = append(event.Keys, drift.Key)
+	}
+	signature, _ := json.Marshal(response.Drift)
+
+	s.configDrift.mu.Lock()
+	changed := string(signature) != s.configDrift.reported && (s.configDrift.reported != "" || response.Drifted)
+	s.configDrift.reported = string(signature)
+	s.configDrift.mu.Unlock()
+	if changed {
+		if event.Drifted {
+			s.logger.Warn("Configuration file differs from the configuration in effect", zap.String("file", event.File), zap.Strings("keys", event.Keys))
+		}
+		s.wsHub.BroadcastConfigDrift(event)
+	}
+	return response
+}
+
+// watchConfigDrift checks the configuration file for drift every interval until
+// ctx is done
+func (s *Server) watchConfigDrift(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkConfigDrift()
+		}
+	}
+}
+
+// secretSetting reports whether a dotted setting key is, or is inside, a secret
+// such as database.password or metrics.remote_write.0.token. HTTP headers, which
+// may carry credentials, count as secrets.
+func secretSetting(key string) bool {
+	for _, part := range strings.Split(key, ".") {
+		if secrets.IsSecretKey(part) || part == "headers" {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedValue replaces a secret setting's value with Redacted, keeping unset
+// ones empty so it shows whether the secret is set
+func redactedValue(value interface{}) interface{} {
+	if value == nil || value == "" {
+		return value
+	}
+	return secrets.Redacted
+}
+
+// redactSettings replaces the values of secret settings with Redacted
+func redactSettings(settings map[string]interface{}) map[string]interface{} {
+	for key, value := range settings {
+		if secretSetting(key) {
+			settings[key] = redactedValue(value)
+		}
+	}
+	return settings
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+)
+
+func TestConfigDrift(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssts.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("server:\n  port: 8080\ndatabase:\n  password: hunter2\n")
+	cfg, err := config.ReadFile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := database.NewMemoryRepository()
+	server := NewServer(cfg, repo, core.NewOrchestrator(cfg, repo, plugins.NewPluginManager(), zap.NewNop()), zap.NewNop())
+	events := func() []ConfigDriftEvent {
+		var events []ConfigDriftEvent
+		for _, payload := range server.wsHub.replay.Get("config_drift", func(string) bool { return true }, time.Time{}, 0) {
+			var message struct{ Data ConfigDriftEvent }
+			json.Unmarshal(payload, &message)
+			events = append(events, message.Data)
+		}
+		return events
+	}
+
+	if rec := server.serve(http.MethodGet, "/api/v1/system/config", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous request: status %d, want 401", rec.Code)
+	}
+
+	response := server.checkConfigDrift()
+	if response.File != path || response.Drifted || len(response.Drift) != 0 {
+		t.Errorf("response = %+v, want the file without drift", response)
+	}
+	if response.Config["server.port"] != 8080 || response.Config["database.password"] != "********" {
+		t.Errorf("port %v, password %v; want 8080 and the password redacted", response.Config["server.port"], response.Config["database.password"])
+	}
+	if got := events(); len(got) != 0 {
+		t.Errorf("events = %+v before any drift", got)
+	}
+
+	write("server:\n  port: 9090\ndatabase:\n  password: hunter3\n")
+	response = server.checkConfigDrift()
+	want := []config.Drift{
+		{Key: "database.password", Running: "********", File: "********"},
+		{Key: "server.port", Running: 8080, File: 9090},
+	}
+	if !response.Drifted || len(response.Drift) != 2 || response.Drift[0] != want[0] || response.Drift[1] != want[1] {
+		t.Errorf("drift = %+v, want %+v", response.Drift, want)
+	}
+	server.checkConfigDrift()
+
+	write("server:\n  port: 8080\ndatabase:\n  password: hunter2\n")
+	server.checkConfigDrift()
+	got := events()
+	if len(got) != 2 || !got[0].Drifted || len(got[0].Keys) != 2 || got[1].Drifted {
+		t.Errorf("events = %+v, want the drift then its resolution, once each", got)
+	}
+
+	os.Remove(path)
+	if response := server.checkConfigDrift(); response.FileError == "" {
+		t.Error("a missing file isn't reported")
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// @Summary Download execution diagnostics
+// @Description Download the diagnostics bundle captured when an execution failed or timed out, a gzipped tar archive of the execution (with its failure class), its plugin config and parameters, its last metric points and safety violations, the kernel log and a goroutine dump. The execution's diagnostics field names the bundle once it has been captured.
+// @Tags executions
+// @Produce application/gzip
+// @Param id path string true "Execution ID"
+// @Success 200 {file} file
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/diagnostics [get]
+func (s *Server) downloadExecutionDiagnostics(c *gin.Context) {
+	execution := s.annotatedExecution(c)
+	if execution == nil {
+		return
+	}
+	if execution.Diagnostics == "" {
+		respondError(c, http.StatusNotFound, "Execution has no diagnostics bundle")
+		return
+	}
+
+	path, err := s.orchestrator.DiagnosticsPath(execution.Diagnostics)
+	if err != nil {
+		s.logger.Error("Invalid diagnostics bundle", zap.String("execution_id", execution.ID), zap.Error(err))
+		respondError(c, http.StatusNotFound, "Execution has no diagnostics bundle")
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		respondError(c, http.StatusNotFound, "Diagnostics bundle no longer exists")
+		return
+	}
+
+	c.Header("Content-Type", "application/gzip")
+	c.FileAttachment(path, "ssts-diagnostics-"+execution.Diagnostics)
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/export"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// downloadFlushInterval is how many points are written between flushes of the response
+const downloadFlushInterval = 1000
+
+// @Summary Download execution metrics
+// @Description Stream every raw metric point of an execution in any registered export format, one row per field. Rows of executions run for a CI build carry its ci_commit, ci_pipeline_url and ci_build_number as tags. The format comes from the format query parameter or the Accept header and defaults to CSV. With window=steady_state only the points of the execution at full intensity are included. The response is chunked and gzip-encoded when the client accepts it.
+// @Tags executions
+// @Produce text/csv
+// @Produce application/json
+// @Produce text/html
+// @Produce application/pdf
+// @Param id path string true "Execution ID"
+// @Param format query string false "csv, json, html or pdf"
+// @Param window query string false "steady_state or last_run, the whole execution" default(last_run)
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 406 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "The execution has no steady state"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/metrics/download [get]
+func (s *Server) downloadExecutionMetrics(c *gin.Context) {
+	id := c.Param("id")
+
+	exporter, ok := s.negotiateExporter(c, "")
+	if !ok {
+		return
+	}
+
+	execution, err := s.orchestrator.GetTestStatus(id)
+	if err != nil {
+		if errors.Is(err, core.ErrExecutionNotFound) {
+			respondError(c, http.StatusNotFound, "Execution not found")
+		} else {
+			s.logger.Error("Failed to get execution", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get execution")
+		}
+		return
+	}
+
+	download := &metricDownload{c: c, format: exporter.Format(), filename: fmt.Sprintf("execution-%s.%s", id, exporter.Extension())}
+	write := ciTagger(execution.CI, download.write)
+
+	// Raw points live in InfluxDB; fall back to the points held in memory if it has
+	// none for this execution or can't be reached before anything was sent
+	timeRange := models.TimeRange{End: time.Now()}
+	if execution.StartTime != nil {
+		timeRange.Start = *execution.StartTime
+	}
+	if execution.EndTime != nil {
+		timeRange.End = execution.EndTime.Add(time.Second)
+	}
+	var fallbackRange models.TimeRange
+	if window := c.Query("window"); window != "" && window != core.WindowLastRun {
+		timeRange, err = s.orchestrator.ExecutionWindow(*execution, window)
+		if err != nil {
+			s.respondWindowError(c, err)
+			return
+		}
+		fallbackRange = timeRange
+	}
+
+	err = s.influxDB.StreamTestMetrics(c.Request.Context(), execution.TestID, timeRange, write)
+	if err != nil && download.started {
+		s.logger.Error("Metric download interrupted", zap.String("execution_id", id), zap.Error(err))
+		download.abort()
+		return
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		s.logger.Warn("Failed to stream metrics from InfluxDB, using in-memory metrics",
+			zap.String("execution_id", id), zap.Error(err))
+	}
+
+	if !download.started {
+		metrics, err := s.orchestrator.GetTestMetrics(id, fallbackRange)
+		if err != nil {
+			s.logger.Error("Failed to get execution metrics", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get execution metrics")
+			return
+		}
+		for _, point := range metrics {
+			if err := write(point); err != nil {
+				s.logger.Error("Metric download interrupted", zap.String("execution_id", id), zap.Error(err))
+				download.abort()
+				return
+			}
+		}
+	}
+
+	if err := download.close(); err != nil {
+		s.logger.Error("Failed to finish metric download", zap.String("execution_id", id), zap.Error(err))
+	}
+}
+
+// ciTagger tags each point written with the execution's CI metadata, so exported
+// rows can be traced back to the build under test
+func ciTagger(ci models.CIMetadata, write func(models.MetricPoint) error) func(models.MetricPoint) error {
+	ciTags := ci.Tags()
+	if len(ciTags) == 0 {
+		return write
+	}
+	return func(point models.MetricPoint) error {
+		// Points may share their tags map, so it is copied rather than modified
+		tags := make(map[string]string, len(point.Tags)+len(ciTags))
+		for name, value := range point.Tags {
+			tags[name] = value
+		}
+		for name, value := range ciTags {
+			tags[name] = value
+		}
+		point.Tags = tags
+		return write(point)
+	}
+}
+
+// metricDownload writes a metric export to the response, sending headers lazily
+// on the first point so that errors before then can still be reported as JSON
+type metricDownload struct {
+	c        *gin.Context
+	format   string
+	filename string
+	started  bool
+	gz       *gzip.Writer
+	writer   export.MetricWriter
+	written  int
+}
+
+func (d *metricDownload) start() error {
+	d.started = true
+
+	header := d.c.Writer.Header()
+	header.Set("Content-Type", export.ContentType(d.format))
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, d.filename))
+
+	var out io.Writer = d.c.Writer
+	if strings.Contains(d.c.GetHeader("Accept-Encoding"), "gzip") {
+		header.Set("Content-Encoding", "gzip")
+		header.Add("Vary", "Accept-Encoding")
+		d.gz = gzip.NewWriter(d.c.Writer)
+		out = d.gz
+	}
+	d.c.Status(http.StatusOK)
+
+	writer, err := export.NewMetricWriter(d.format, out)
+	if err != nil {
+		return err
+	}
+	d.writer = writer
+	return nil
+}
+
+func (d *metricDownload) write(point models.MetricPoint) error {
+	if !d.started {
+		if err := d.start(); err != nil {
+			return err
+		}
+	}
+
+	if err := d.writer.Write(point); err != nil {
+		return err
+	}
+
+	d.written++
+	if d.written%downloadFlushInterval == 0 {
+		d.flush()
+	}
+	return nil
+}
+
+// flush pushes buffered output to the client as a chunk
+func (d *metricDownload) flush() {
+	if flusher, ok := d.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if d.gz != nil {
+		d.gz.Flush()
+	}
+	d.c.Writer.Flush()
+}
+
+func (d *metricDownload) close() error {
+	if !d.started {
+		if err := d.start(); err != nil {
+			return err
+		}
+	}
+
+	if err := d.writer.Close(); err != nil {
+		return err
+	}
+	if d.gz != nil {
+		if err := d.gz.Close(); err != nil {
+			return err
+		}
+	}
+	d.c.Writer.Flush()
+	return nil
+}
+
+// abort ends a download that failed after headers were sent. The body is left
+// truncated so clients can tell it is incomplete.
+func (d *metricDownload) abort() {
+	if d.gz != nil {
+		d.gz.Flush()
+	}
+	d.c.Abort()
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// DeviceEnduranceResponse is a device's cumulative test writes against its endurance budget
+type DeviceEnduranceResponse struct {
+	models.DeviceEndurance
+	BudgetBytes int64   `json:"budget_bytes,omitempty"` // 0 when no budget is configured
+	UsedPercent float64 `json:"used_percent,omitempty"`
+	Warning     bool    `json:"warning"` // past the configured warning share of the budget
+}
+
+// @Summary List device endurance
+// @Description Get the bytes tests have written to each storage device across executions, with the share of each device's configured endurance budget used. Devices with a budget that have not been written to yet are included.
+// @Tags system
+// @Produce json
+// @Success 200 {array} DeviceEnduranceResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/system/endurance [get]
+func (s *Server) listDeviceEndurance(c *gin.Context) {
+	devices, err := s.repo.ListDeviceEndurance()
+	if err != nil {
+		s.logger.Error("Failed to list device endurance", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list device endurance")
+		return
+	}
+
+	budgets, err := core.EnduranceBudgets(s.config.Endurance)
+	if err != nil {
+		s.logger.Warn("Ignoring device endurance budgets", zap.Error(err))
+		budgets = nil
+	}
+
+	response := make([]DeviceEnduranceResponse, 0, len(devices)+len(budgets))
+	seen := make(map[string]bool, len(devices))
+	for _, device := range devices {
+		seen[device.Device] = true
+		response = append(response, DeviceEnduranceResponse{DeviceEndurance: device})
+	}
+	for device := range budgets {
+		if !seen[device] {
+			response = append(response, DeviceEnduranceResponse{DeviceEndurance: models.DeviceEndurance{Device: device}})
+		}
+	}
+	sort.Slice(response, func(i, j int) bool { return response[i].Device < response[j].Device })
+
+	for i := range response {
+		device := &response[i]
+		device.BudgetBytes = budgets[device.Device]
+		device.UsedPercent = core.EnduranceUsed(device.BytesWritten, device.BudgetBytes)
+		device.Warning = device.BudgetBytes > 0 && device.UsedPercent >= s.config.Endurance.WarnPercent
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the body of every failed v1 request. Code is machine-readable
+// and stable, so clients should switch on it rather than on Message, whose
+// wording may change. Details carries structured context where there is any,
+// such as the invalid fields of a validation error.
+type ErrorResponse struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+	Error   string      `json:"error"` // Deprecated: the same as Message, for clients written before codes
+}
+
+// Error codes more specific than the one derived from the HTTP status, for
+// failures clients are expected to handle differently from others with the
+// same status
+const (
+	CodeDraining            = "draining"             // the server is shutting down and not starting tests
+	CodeDurationExceeded    = "duration_exceeded"    // the requested duration is over a configured limit
+	CodePluginDisabled      = "plugin_disabled"      // the test's plugin is registered but disabled
+	CodePreflightFailed     = "preflight_failed"     // the host can't run the test, e.g. for lack of disk space
+	CodeResourceConflict    = "resource_conflict"    // a running test holds a resource this one needs
+	CodePrerequisitesFailed = "prerequisites_failed" // details lists the unmet prerequisites
+	CodeExecutionRunning    = "execution_running"    // the execution must finish first
+	CodeAgentUpgrade        = "agent_upgrade"        // the agent speaks a protocol version the server no longer accepts
+	CodeReviewConflict      = "review_conflict"      // the review can't move to the requested status from where it is
+	CodeNotExtendable       = "not_extendable"       // the execution's run can't be lengthened
+	CodeNotPausable         = "not_pausable"         // the execution's load can't be paused or resumed
+	CodeNotQueued           = "not_queued"           // the execution isn't waiting in the queue
+	CodeNoSteadyState       = "no_steady_state"      // the execution never ran at full intensity, so has no steady_state window
+)
+
+// newErrorResponse returns an error body with the given code
+func newErrorResponse(code, message string, details interface{}) ErrorResponse {
+	return ErrorResponse{Code: code, Message: message, Details: details, Error: message}
+}
+
+// respondError responds with an error whose code is derived from the status
+func respondError(c *gin.Context, status int, message string) {
+	c.JSON(status, newErrorResponse(errorCode(status), message, nil))
+}
+
+// respondErrorCode responds with an error with a specific code and details
+func respondErrorCode(c *gin.Context, status int, code, message string, details interface{}) {
+	c.JSON(status, newErrorResponse(code, message, details))
+}
+
+// abortWithError aborts the request with an error whose code is derived from
+// the status, for middleware
+func abortWithError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, newErrorResponse(errorCode(status), message, nil))
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestErrorResponses(t *testing.T) {
+	server, repo := newTestServer(t)
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t1", Name: "burn", Plugin: "cpu-stress", Duration: time.Second})
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        interface{}
+		wantStatus  int
+		wantCode    string
+		wantDetails bool
+	}{
+		{"missing test", http.MethodGet, "/api/v1/tests/missing", nil, http.StatusNotFound, "not_found", false},
+		{"missing execution", http.MethodGet, "/api/v1/executions/missing", nil, http.StatusNotFound, "not_found", false},
+		{"malformed body", http.MethodPost, "/api/v1/alerts/mutes", "not an object", http.StatusBadRequest, "invalid_request", false},
+		{"invalid fields", http.MethodPost, "/api/v1/tests", models.TestConfiguration{Plugin: "missing"}, http.StatusBadRequest, "invalid_request", true},
+		{"duration over the maximum", http.MethodPost, "/api/v1/tests/t1/run", models.TestParams{Duration: 1000 * time.Hour}, http.StatusBadRequest, "duration_exceeded", false},
+	}
+	for _, tt := range tests {
+		rec := server.serve(tt.method, tt.path, tt.body)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.wantStatus, rec.Body)
+			continue
+		}
+		var body ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if body.Code != tt.wantCode || body.Message == "" || body.Error != body.Message {
+			t.Errorf("%s: body = %+v, want code %q", tt.name, body, tt.wantCode)
+		}
+		if (body.Details != nil) != tt.wantDetails {
+			t.Errorf("%s: details = %v", tt.name, body.Details)
+		}
+	}
+
+	server.orchestrator.BeginDrain()
+	var body ErrorResponse
+	json.Unmarshal(server.serve(http.MethodPost, "/api/v1/tests/t1/run", models.TestParams{}).Body.Bytes(), &body)
+	if body.Code != CodeDraining {
+		t.Errorf("run while draining: code %q, want %q", body.Code, CodeDraining)
+	}
+}
+
+func TestBindingFieldErrors(t *testing.T) {
+	server, repo := newTestServer(t)
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t1", Name: "burn", Plugin: "cpu-stress", Duration: time.Second})
+
+	tests := []struct {
+		name string
+		body interface{}
+		want FieldError
+	}{
+		{"over a maximum", models.TestParams{Intensity: 150}, FieldError{Field: "intensity", Message: "must be at most 100"}},
+		{"nested field", models.TestParams{Placement: &models.Placement{IOClass: "urgent"}}, FieldError{Field: "placement.io_class", Message: "must be one of realtime, best-effort, idle"}},
+		{"wrong type", json.RawMessage(`{"intensity": "high"}`), FieldError{Field: "intensity", Message: "must be an integer"}},
+	}
+	for _, tt := range tests {
+		rec := server.serve(http.MethodPost, "/api/v1/tests/t1/run", tt.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", tt.name, rec.Code, rec.Body)
+			continue
+		}
+		var body struct {
+			Details []FieldError `json:"details"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if len(body.Details) != 1 || body.Details[0] != tt.want {
+			t.Errorf("%s: details = %+v, want %+v", tt.name, body.Details, tt.want)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/export"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Export job statuses
+const (
+	ExportPending   = "pending"
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+)
+
+// ExportJob is an export generated in the background, polled until it completes
+type ExportJob struct {
+	ID          string     `json:"id"`
+	TestID      string     `json:"test_id"`
+	Format      string     `json:"format"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Rows        int        `json:"rows"`            // metric points written so far
+	Bytes       int64      `json:"bytes,omitempty"` // size of the finished export
+	Created     time.Time  `json:"created"`
+	Completed   *time.Time `json:"completed,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"` // set once the export has completed
+
+	projectID string
+	path      string
+}
+
+// exportJobs tracks background exports. Finished jobs and their files are
+// removed once the retention period has passed.
+type exportJobs struct {
+	dir       string
+	retention time.Duration
+	jobs      map[string]*ExportJob
+	mu        sync.RWMutex
+}
+
+// newExportJobs creates the background export tracker
+func newExportJobs(cfg config.ExportConfig) *exportJobs {
+	dir := cfg.Directory
+	if dir == "" {
+		dir = "exports"
+	}
+	return &exportJobs{
+		dir:       dir,
+		retention: cfg.JobRetention,
+		jobs:      make(map[string]*ExportJob),
+	}
+}
+
+// create registers a pending job, pruning expired ones
+func (e *exportJobs) create(testID, projectID string, exporter export.Exporter) (*ExportJob, error) {
+	if err := os.MkdirAll(e.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	job := &ExportJob{
+		ID:        uuid.New().String(),
+		TestID:    testID,
+		Format:    exporter.Format(),
+		Status:    ExportPending,
+		Created:   time.Now(),
+		projectID: projectID,
+	}
+	job.path = filepath.Join(e.dir, fmt.Sprintf("export-%s.%s", job.ID, exporter.Extension()))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.prune()
+	e.jobs[job.ID] = job
+	return job, nil
+}
+
+// get returns a copy of a job
+func (e *exportJobs) get(id string) (ExportJob, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	job, ok := e.jobs[id]
+	if !ok {
+		return ExportJob{}, false
+	}
+	return *job, true
+}
+
+// update changes a job under the lock
+func (e *exportJobs) update(id string, fn func(job *ExportJob)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if job, ok := e.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// prune removes finished jobs older than the retention period. The caller must hold e.mu.
+func (e *exportJobs) prune() {
+	if e.retention <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-e.retention)
+	for id, job := range e.jobs {
+		if job.Completed != nil && job.Completed.Before(cutoff) {
+			os.Remove(job.path)
+			delete(e.jobs, id)
+		}
+	}
+}
+
+// exportFilter returns a function selecting the requested metrics from a point. Each
+// entry names a measurement (the point's type), optionally with a field as type.field;
+// an empty list selects everything.
+func exportFilter(metrics []string) func(point models.MetricPoint) (models.MetricPoint, bool) {
+	if len(metrics) == 0 {
+		return func(point models.MetricPoint) (models.MetricPoint, bool) { return point, true }
+	}
+
+	types := make(map[string]bool)
+	fields := make(map[string]map[string]bool)
+	for _, metric := range metrics {
+		pointType, field, ok := strings.Cut(metric, ".")
+		if !ok {
+			types[pointType] = true
+			continue
+		}
+		if fields[pointType] == nil {
+			fields[pointType] = make(map[string]bool)
+		}
+		fields[pointType][field] = true
+	}
+
+	return func(point models.MetricPoint) (models.MetricPoint, bool) {
+		if types[point.Type] {
+			return point, true
+		}
+		wanted := fields[point.Type]
+		if wanted == nil {
+			return point, false
+		}
+		selected := make(map[string]interface{}, len(wanted))
+		for field, value := range point.Fields {
+			if wanted[field] {
+				selected[field] = value
+			}
+		}
+		point.Fields = selected
+		return point, len(selected) > 0
+	}
+}
+
+// @Summary Export test data
+// @Description Export a test's raw metrics within a time range. The format comes from the request body, the format query parameter, or the Accept header, in that order, and defaults to CSV. Exports spanning longer than the configured threshold, or requested with async=true, are generated in the background: the response is 202 with a job to poll at /api/v1/exports/{id}.
+// @Tags tests
+// @Accept json
+// @Produce text/csv
+// @Produce application/json
+// @Produce text/html
+// @Produce application/pdf
+// @Param id path string true "Test ID"
+// @Param request body models.ExportRequest true "Export request"
+// @Param format query string false "csv, json, html or pdf"
+// @Param async query bool false "Generate the export in the background"
+// @Success 200 {file} file
+// @Success 202 {object} ExportJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 406 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/tests/{id}/export [post]
+func (s *Server) exportTestData(c *gin.Context) {
+	id := c.Param("id")
+
+	var request models.ExportRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+	request.TestID = id
+
+	if request.Aggregation != "" && request.Aggregation != "raw" {
+		respondError(c, http.StatusBadRequest, "Only raw exports are supported")
+		return
+	}
+
+	exporter, ok := s.negotiateExporter(c, request.Format)
+	if !ok {
+		return
+	}
+
+	test, err := s.repo.GetTestConfiguration(id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Test not found")
+		} else {
+			s.logger.Error("Failed to get test", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get test")
+		}
+		return
+	}
+
+	timeRange := request.TimeRange
+	if timeRange.Start.IsZero() {
+		timeRange.Start = test.Created
+	}
+	if timeRange.End.IsZero() {
+		timeRange.End = time.Now()
+	}
+	if !timeRange.End.After(timeRange.Start) {
+		respondError(c, http.StatusBadRequest, "Time range end must be after its start")
+		return
+	}
+
+	filter := exportFilter(request.Metrics)
+	threshold := s.config.Export.AsyncThreshold
+	if c.Query("async") != "true" && (threshold <= 0 || timeRange.End.Sub(timeRange.Start) <= threshold) {
+		s.streamExport(c, test.ID, exporter, timeRange, filter)
+		return
+	}
+
+	job, err := s.exports.create(test.ID, currentProjectID(c), exporter)
+	if err != nil {
+		s.logger.Error("Failed to create export job", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to create export job")
+		return
+	}
+	go s.runExport(apiBase(c), job.ID, job.path, test.ID, exporter, timeRange, filter)
+
+	c.Header("Location", apiBase(c)+"/exports/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// negotiateExporter picks the exporter for a requested format, falling back to the
+// format query parameter and then the Accept header. It responds with an error and
+// returns false if the format isn't supported.
+func (s *Server) negotiateExporter(c *gin.Context, format string) (export.Exporter, bool) {
+	if format == "" {
+		format = c.Query("format")
+	}
+	if format != "" {
+		exporter, err := export.Lookup(format)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("Unsupported format %s, expected one of %s", format, strings.Join(export.Formats(), ", ")))
+			return nil, false
+		}
+		return exporter, true
+	}
+
+	exporter := export.Negotiate(c.GetHeader("Accept"), export.FormatCSV)
+	if exporter == nil {
+		respondError(c, http.StatusNotAcceptable, "No acceptable export format, expected one of "+strings.Join(export.Formats(), ", "))
+		return nil, false
+	}
+	return exporter, true
+}
+
+// streamExport writes an export straight to the response
+func (s *Server) streamExport(c *gin.Context, testID string, exporter export.Exporter, timeRange models.TimeRange, filter func(models.MetricPoint) (models.MetricPoint, bool)) {
+	download := &metricDownload{c: c, format: exporter.Format(), filename: fmt.Sprintf("test-%s.%s", testID, exporter.Extension())}
+
+	err := s.influxDB.StreamTestMetrics(c.Request.Context(), testID, timeRange, func(point models.MetricPoint) error {
+		if point, ok := filter(point); ok {
+			return download.write(point)
+		}
+		return nil
+	})
+	if err != nil {
+		if download.started {
+			s.logger.Error("Export interrupted", zap.String("test_id", testID), zap.Error(err))
+			download.abort()
+		} else {
+			s.logger.Error("Failed to query metrics for export", zap.String("test_id", testID), zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to query metrics")
+		}
+		return
+	}
+
+	if err := download.close(); err != nil {
+		s.logger.Error("Failed to finish export", zap.String("test_id", testID), zap.Error(err))
+	}
+}
+
+// runExport generates a background export into its file, recording progress on the
+// job. Its download link points into base, the API version it was requested from.
+func (s *Server) runExport(base, jobID, path, testID string, exporter export.Exporter, timeRange models.TimeRange, filter func(models.MetricPoint) (models.MetricPoint, bool)) {
+	s.exports.update(jobID, func(job *ExportJob) { job.Status = ExportRunning })
+
+	fail := func(err error) {
+		s.logger.Error("Export job failed", zap.String("job_id", jobID), zap.Error(err))
+		os.Remove(path)
+		now := time.Now()
+		s.exports.update(jobID, func(job *ExportJob) {
+			job.Status = ExportFailed
+			job.Error = err.Error()
+			job.Completed = &now
+		})
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		fail(fmt.Errorf("failed to create export file: %w", err))
+		return
+	}
+	defer file.Close()
+
+	writer, err := exporter.NewWriter(file)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	rows := 0
+	err = s.influxDB.StreamTestMetrics(context.Background(), testID, timeRange, func(point models.MetricPoint) error {
+		point, ok := filter(point)
+		if !ok {
+			return nil
+		}
+		if err := writer.Write(point); err != nil {
+			return err
+		}
+		rows++
+		if rows%downloadFlushInterval == 0 {
+			s.exports.update(jobID, func(job *ExportJob) { job.Rows = rows })
+		}
+		return nil
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	now := time.Now()
+	s.exports.update(jobID, func(job *ExportJob) {
+		job.Status = ExportCompleted
+		job.Rows = rows
+		job.Bytes = size
+		job.Completed = &now
+		job.DownloadURL = base + "/exports/" + jobID + "/download"
+	})
+}
+
+// projectExportJob returns the export job named by the id parameter. It responds
+// with 404 and returns false if there is none in the current project.
+func (s *Server) projectExportJob(c *gin.Context) (ExportJob, bool) {
+	job, ok := s.exports.get(c.Param("id"))
+	if !ok || job.projectID != currentProjectID(c) {
+		respondError(c, http.StatusNotFound, "Export not found")
+		return ExportJob{}, false
+	}
+	return job, true
+}
+
+// @Summary Get export job
+// @Description Poll the status of an export generated in the background
+// @Tags exports
+// @Produce json
+// @Param id path string true "Export job ID"
+// @Success 200 {object} ExportJob
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/exports/{id} [get]
+func (s *Server) getExportJob(c *gin.Context) {
+	job, ok := s.projectExportJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Download export
+// @Description Download the file of a completed background export
+// @Tags exports
+// @Param id path string true "Export job ID"
+// @Success 200 {file} file
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/exports/{id}/download [get]
+func (s *Server) downloadExport(c *gin.Context) {
+	job, ok := s.projectExportJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != ExportCompleted {
+		respondError(c, http.StatusConflict, "Export is "+job.Status)
+		return
+	}
+
+	c.Header("Content-Type", export.ContentType(job.Format))
+	c.FileAttachment(job.path, filepath.Base(job.path))
+}
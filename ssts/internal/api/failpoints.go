@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pranavgopavaram/ssts/internal/faults"
+)
+
+// SetFailpointRequest is the body for enabling a failpoint
+type SetFailpointRequest struct {
+	Spec string `json:"spec" binding:"required"` // e.g. "return(disk full)" or "2*sleep(5s)"
+}
+
+// failpointName returns the failpoint name from the wildcard route parameter,
+// since names such as influxdb/write contain slashes
+func failpointName(c *gin.Context) string {
+	return strings.TrimPrefix(c.Param("name"), "/")
+}
+
+// @Summary List failpoints
+// @Description List active failpoints. Only available in builds with the failpoints tag.
+// @Tags debug
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /api/v1/debug/failpoints [get]
+func (s *Server) listFailpoints(c *gin.Context) {
+	c.JSON(http.StatusOK, faults.List())
+}
+
+// @Summary Set failpoint
+// @Description Make a failpoint fail or delay. Only available in builds with the failpoints tag.
+// @Tags debug
+// @Accept json
+// @Produce json
+// @Param name path string true "Failpoint name, e.g. influxdb/write"
+// @Param failpoint body SetFailpointRequest true "Failpoint spec"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Router /api/v1/debug/failpoints/{name} [put]
+func (s *Server) setFailpoint(c *gin.Context) {
+	var req SetFailpointRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	name := failpointName(c)
+	if err := faults.Enable(name, req.Spec); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, faults.List())
+}
+
+// @Summary Clear failpoint
+// @Description Disable a failpoint. Only available in builds with the failpoints tag.
+// @Tags debug
+// @Param name path string true "Failpoint name"
+// @Success 204
+// @Router /api/v1/debug/failpoints/{name} [delete]
+func (s *Server) clearFailpoint(c *gin.Context) {
+	faults.Disable(failpointName(c))
+	c.Status(http.StatusNoContent)
+}
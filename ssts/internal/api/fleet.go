@@ -0,0 +1,304 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// How long after a host was last seen it counts as stale, and then offline
+const (
+	fleetStaleAfter   = 2 * time.Minute
+	fleetOfflineAfter = 15 * time.Minute
+)
+
+// fleetHistoryLimit is how many of the project's latest executions are looked
+// through for the hosts they ran on and their last results
+const fleetHistoryLimit = 1000
+
+// fleetMaxHealthFields caps the health metrics kept per agent, so an agent
+// pushing ever new field names can't grow the server's memory
+const fleetMaxHealthFields = 64
+
+// agentActivity is what an agent last pushed: the latest value of its host
+// metrics, those without a test, and the tests it pushed metrics for
+type agentActivity struct {
+	seen    time.Time
+	health  models.HostHealth
+	testsAt map[string]time.Time
+}
+
+// fleetActivity tracks the metrics agents push, by agent ID, for the fleet overview
+type fleetActivity struct {
+	mu     sync.Mutex
+	agents map[string]*agentActivity
+}
+
+// record notes points an agent pushed
+func (f *fleetActivity) record(agentID string, points []models.MetricPoint, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.agents == nil {
+		f.agents = make(map[string]*agentActivity)
+	}
+	activity := f.agents[agentID]
+	if activity == nil {
+		activity = &agentActivity{
+			health:  models.HostHealth{Metrics: make(map[string]float64)},
+			testsAt: make(map[string]time.Time),
+		}
+		f.agents[agentID] = activity
+	}
+	activity.seen = now
+
+	for _, point := range points {
+		if point.TestID != "" {
+			activity.testsAt[point.TestID] = now
+			continue
+		}
+		if point.Timestamp.Before(activity.health.Timestamp) {
+			continue
+		}
+		activity.health.Timestamp = point.Timestamp
+		for field, value := range point.Fields {
+			number, ok := fieldFloat(value)
+			if !ok {
+				continue
+			}
+			key := point.Type + "." + field
+			if _, exists := activity.health.Metrics[key]; !exists && len(activity.health.Metrics) >= fleetMaxHealthFields {
+				continue
+			}
+			activity.health.Metrics[key] = number
+		}
+	}
+}
+
+// snapshot returns a copy of an agent's activity, or nil if it pushed nothing
+// since the server started. Tests it stopped pushing metrics for before
+// activeSince are dropped.
+func (f *fleetActivity) snapshot(agentID string, activeSince time.Time) *agentActivity {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	activity := f.agents[agentID]
+	if activity == nil {
+		return nil
+	}
+	for testID, at := range activity.testsAt {
+		if at.Before(activeSince) {
+			delete(activity.testsAt, testID)
+		}
+	}
+
+	copied := &agentActivity{
+		seen:    activity.seen,
+		health:  models.HostHealth{Timestamp: activity.health.Timestamp, Metrics: make(map[string]float64, len(activity.health.Metrics))},
+		testsAt: make(map[string]time.Time, len(activity.testsAt)),
+	}
+	for key, value := range activity.health.Metrics {
+		copied.health.Metrics[key] = value
+	}
+	for testID, at := range activity.testsAt {
+		copied.testsAt[testID] = at
+	}
+	return copied
+}
+
+// fieldFloat converts a numeric metric field value
+func fieldFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// @Summary Get fleet overview
+// @Description Get every host the project knows of with its status, running executions, latest health metrics and the last result of each test run on it. Hosts are the one the server runs on, those of the project's agents, named after the agent, and those recorded in the system snapshot of recent executions. Agent health is the latest value of each field of the metrics it pushed without a test, keyed type.field; push system points with cpu_usage_percent, memory_usage_percent and disk_usage_percent fields to line up with the server's own. Hosts are testing while running executions or while their agent pushes metrics for a test, and stale, then offline, as they go unseen.
+// @Tags system
+// @Produce json
+// @Success 200 {object} models.Fleet
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/fleet [get]
+func (s *Server) getFleet(c *gin.Context) {
+	projectID := currentProjectID(c)
+	now := time.Now()
+	hosts := make(map[string]*models.FleetHost)
+	host := func(name string) *models.FleetHost {
+		if hosts[name] == nil {
+			hosts[name] = &models.FleetHost{Name: name, Running: []models.FleetExecution{}, LastResults: []models.FleetResult{}}
+		}
+		return hosts[name]
+	}
+
+	// The server's own host runs the project's executions
+	hostname, _ := os.Hostname()
+	local := host(hostname)
+	local.Local = true
+	local.LastSeen = &now
+	metrics := s.orchestrator.GetSystemMetrics()
+	local.Health = &models.HostHealth{
+		Timestamp: metrics.Timestamp,
+		Metrics: map[string]float64{
+			"system.cpu_usage_percent":       metrics.CPU.UsagePercent,
+			"system.cpu_temperature_celsius": metrics.CPU.Temperature,
+			"system.memory_usage_percent":    metrics.Memory.UsagePercent,
+			"system.disk_usage_percent":      metrics.Disk.UsagePercent,
+		},
+	}
+	for _, execution := range s.orchestrator.ListExecutions() {
+		if execution.ProjectID != projectID {
+			continue
+		}
+		switch execution.Status {
+		case models.StatusQueued, models.StatusPending, models.StatusRunning:
+			local.Running = append(local.Running, models.FleetExecution{
+				ID:        execution.ID,
+				TestID:    execution.TestID,
+				Status:    execution.Status,
+				StartTime: execution.StartTime,
+				Progress:  execution.Progress,
+			})
+		}
+	}
+	sort.Slice(local.Running, func(i, j int) bool { return local.Running[i].ID < local.Running[j].ID })
+
+	agents, err := s.repo.ListAgentTokens()
+	if err != nil {
+		s.logger.Error("Failed to list agent tokens", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to get fleet")
+		return
+	}
+	for _, agent := range agents {
+		if agent.ProjectID != projectID {
+			continue
+		}
+		entry := host(agent.Name)
+		entry.Agent = &models.FleetAgent{ID: agent.ID, Name: agent.Name, Version: agent.AgentVersion, Platform: agent.Platform}
+		if agent.RevokedAt != nil {
+			entry.Status = models.HostRevoked
+		}
+		entry.LastSeen = latestTime(entry.LastSeen, agent.LastUsed)
+
+		if activity := s.fleet.snapshot(agent.ID, now.Add(-fleetStaleAfter)); activity != nil {
+			entry.LastSeen = latestTime(entry.LastSeen, &activity.seen)
+			if len(activity.health.Metrics) > 0 {
+				health := activity.health
+				entry.Health = &health
+			}
+			for testID := range activity.testsAt {
+				entry.ActiveTests = append(entry.ActiveTests, testID)
+			}
+			sort.Strings(entry.ActiveTests)
+		}
+	}
+
+	// Hosts executions ran on, with the latest result of each test
+	executions, err := s.repo.ListTestExecutionsFiltered(database.ExecutionFilter{
+		ProjectID: projectID,
+		Statuses:  []models.ExecutionStatus{models.StatusCompleted, models.StatusFailed, models.StatusStopped, models.StatusTimedOut},
+		Sort:      []database.SortField{{Field: "start_time", Desc: true}},
+		Limit:     fleetHistoryLimit,
+	})
+	if err != nil {
+		s.logger.Error("Failed to list executions", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to get fleet")
+		return
+	}
+	for _, execution := range executions {
+		if execution.System == nil || execution.System.Hostname == "" {
+			continue
+		}
+		entry := host(execution.System.Hostname)
+		entry.LastSeen = latestTime(entry.LastSeen, execution.EndTime)
+		if hasResult(entry.LastResults, execution.TestID) {
+			continue
+		}
+		result := models.FleetResult{
+			TestID:       execution.TestID,
+			ExecutionID:  execution.ID,
+			Status:       execution.Status,
+			Score:        calculateTestScore(execution),
+			ReviewStatus: execution.ReviewStatus,
+			Finished:     execution.EndTime,
+		}
+		var summary models.ExecutionSummary
+		if len(execution.Summary) > 0 && json.Unmarshal(execution.Summary, &summary) == nil && summary.Grade != nil {
+			passed := summary.Grade.Passed
+			result.GradePassed = &passed
+		}
+		entry.LastResults = append(entry.LastResults, result)
+	}
+
+	fleet := models.Fleet{
+		Hosts:     make([]models.FleetHost, 0, len(hosts)),
+		Counts:    make(map[models.HostStatus]int),
+		Generated: now,
+	}
+	for _, entry := range hosts {
+		if entry.Status == "" {
+			entry.Status = hostStatus(entry, now)
+		}
+		fleet.Counts[entry.Status]++
+		fleet.Hosts = append(fleet.Hosts, *entry)
+	}
+	sort.Slice(fleet.Hosts, func(i, j int) bool {
+		if fleet.Hosts[i].Local != fleet.Hosts[j].Local {
+			return fleet.Hosts[i].Local
+		}
+		return fleet.Hosts[i].Name < fleet.Hosts[j].Name
+	})
+
+	c.JSON(http.StatusOK, fleet)
+}
+
+// hostStatus works out what a host that isn't revoked is doing
+func hostStatus(host *models.FleetHost, now time.Time) models.HostStatus {
+	switch {
+	case len(host.Running) > 0 || len(host.ActiveTests) > 0:
+		return models.HostTesting
+	case host.Local:
+		return models.HostOnline
+	case host.LastSeen == nil || now.Sub(*host.LastSeen) >= fleetOfflineAfter:
+		return models.HostOffline
+	case now.Sub(*host.LastSeen) >= fleetStaleAfter:
+		return models.HostStale
+	}
+	return models.HostOnline
+}
+
+// latestTime returns the later of two optional times
+func latestTime(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
+
+// hasResult reports whether results already hold one of the test
+func hasResult(results []models.FleetResult, testID string) bool {
+	for _, result := range results {
+		if result.TestID == testID {
+			return true
+		}
+	}
+	return false
+}
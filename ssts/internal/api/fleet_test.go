@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestGetFleet(t *testing.T) {
+	server, repo := newTestServer(t)
+	project, _ := repo.GetProjectByName(models.DefaultProjectName)
+	repo.CreateAgentToken(&models.AgentToken{ID: "a1", Name: "rack-1", ProjectID: project.ID, TokenHash: hashAgentToken(testAgentToken), AgentVersion: "1.2.0"})
+	lastUsed := time.Now().Add(-time.Hour)
+	repo.CreateAgentToken(&models.AgentToken{ID: "a2", Name: "rack-2", ProjectID: project.ID, TokenHash: "other", LastUsed: &lastUsed})
+	repo.CreateAgentToken(&models.AgentToken{ID: "a3", Name: "elsewhere", ProjectID: "another", TokenHash: "another"})
+
+	record := func(host, testID string, status models.ExecutionStatus, ended time.Time, grade *models.ProfileGrade) string {
+		summary, _ := json.Marshal(models.ExecutionSummary{Grade: grade})
+		execution := models.TestExecution{
+			TestID:    testID,
+			ProjectID: project.ID,
+			Status:    status,
+			StartTime: &ended,
+			EndTime:   &ended,
+			Summary:   summary,
+			System:    &models.SystemSnapshot{Hostname: host},
+		}
+		repo.CreateTestExecution(&execution)
+		return execution.ID
+	}
+	now := time.Now()
+	record("rack-1", "burn", models.StatusFailed, now.Add(-2*time.Hour), nil)
+	latest := record("rack-1", "burn", models.StatusCompleted, now.Add(-time.Hour), &models.ProfileGrade{Passed: true})
+	record("bench-7", "fio", models.StatusCompleted, now.Add(-time.Minute), nil)
+
+	// rack-1's agent pushes host metrics and metrics of a test it runs
+	test := models.TestConfiguration{ID: "soak", Name: "soak", ProjectID: project.ID, Plugin: "cpu-stress"}
+	repo.CreateTestConfiguration(&test)
+	rec := server.serveAgent(http.MethodPost, "/api/v1/ingest/metrics", IngestMetricsRequest{Points: []models.MetricPoint{
+		{Timestamp: now, Source: "agent", Type: "system", Fields: map[string]interface{}{"cpu_usage_percent": 42.5, "kernel": "6.5"}},
+		{Timestamp: now, TestID: "soak", Source: "agent", Type: "cpu", Fields: map[string]interface{}{"ops": 10.0}},
+	}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ingest: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = server.serve(http.MethodGet, "/api/v1/fleet", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fleet: status %d: %s", rec.Code, rec.Body)
+	}
+	var fleet models.Fleet
+	json.Unmarshal(rec.Body.Bytes(), &fleet)
+
+	hosts := make(map[string]models.FleetHost)
+	for _, host := range fleet.Hosts {
+		hosts[host.Name] = host
+	}
+	hostname, _ := os.Hostname()
+	if len(fleet.Hosts) != 4 || !fleet.Hosts[0].Local || fleet.Hosts[0].Name != hostname {
+		t.Fatalf("hosts = %+v, want the local host first, rack-1, rack-2 and bench-7", fleet.Hosts)
+	}
+	if _, exists := hosts["elsewhere"]; exists {
+		t.Error("another project's agent is listed")
+	}
+	if local := hosts[hostname]; local.Status != models.HostOnline || local.Health == nil {
+		t.Errorf("local host = %+v, want online with its health", local)
+	}
+
+	rack1 := hosts["rack-1"]
+	if rack1.Status != models.HostTesting || rack1.Agent == nil || rack1.Agent.Version != "1.2.0" || len(rack1.ActiveTests) != 1 || rack1.ActiveTests[0] != "soak" {
+		t.Errorf("rack-1 = %+v, want its agent testing soak", rack1)
+	}
+	if rack1.Health == nil || rack1.Health.Metrics["system.cpu_usage_percent"] != 42.5 || len(rack1.Health.Metrics) != 1 {
+		t.Errorf("rack-1 health = %+v, want its pushed CPU usage", rack1.Health)
+	}
+	if len(rack1.LastResults) != 1 {
+		t.Fatalf("rack-1 results = %+v, want only the latest burn", rack1.LastResults)
+	}
+	if result := rack1.LastResults[0]; result.ExecutionID != latest || result.Score != 100 || result.GradePassed == nil || !*result.GradePassed {
+		t.Errorf("rack-1 result = %+v, want the passed completed run", result)
+	}
+
+	if rack2 := hosts["rack-2"]; rack2.Status != models.HostOffline || rack2.Health != nil {
+		t.Errorf("rack-2 = %+v, want offline, unseen for an hour", rack2)
+	}
+	if bench := hosts["bench-7"]; bench.Status != models.HostOnline || bench.Agent != nil || len(bench.LastResults) != 1 {
+		t.Errorf("bench-7 = %+v, want online from its recent execution", bench)
+	}
+	if fleet.Counts[models.HostOnline] != 2 || fleet.Counts[models.HostTesting] != 1 || fleet.Counts[models.HostOffline] != 1 {
+		t.Errorf("counts = %v", fleet.Counts)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+)
+
+// FluxQueryRequest is a custom Flux query. The query reads the metrics bucket
+// through data, already limited to the time range, and its parameters through
+// params, e.g. data |> filter(fn: (r) => r.host == params.host).
+type FluxQueryRequest struct {
+	Query  string                 `json:"query" binding:"required"`
+	Params map[string]interface{} `json:"params"` // strings, numbers and booleans
+	Start  time.Time              `json:"start" binding:"required"`
+	Stop   *time.Time             `json:"stop"` // defaults to now
+}
+
+// FluxQueryResponse is the records a custom Flux query returned
+type FluxQueryResponse struct {
+	Records   []map[string]interface{} `json:"records"`
+	Truncated bool                     `json:"truncated"` // there were more records than the configured maximum
+}
+
+// @Summary Run a Flux query
+// @Description Run a custom Flux query against the metric bucket, for analyses the metric query endpoint can't express. Admin only. The query reads the bucket through data, a stream already limited to the requested time range, and its parameters through the params record; parameters are passed as literals, never as Flux. Queries may not call from, to or buckets, set options, interpolate strings, or import packages other than array, date, dict, experimental/aggregate, interpolate, join, math, regexp, strings and types. Queries run with a read-only InfluxDB token, under a timeout, and return a limited number of records.
+// @Tags metrics
+// @Accept json
+// @Produce json
+// @Param query body FluxQueryRequest true "Query"
+// @Success 200 {object} FluxQueryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 504 {object} ErrorResponse
+// @Router /api/v1/flux/query [post]
+func (s *Server) queryFlux(c *gin.Context) {
+	var request FluxQueryRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	limits := s.config.InfluxDB.Flux
+	stop := time.Now()
+	if request.Stop != nil {
+		stop = *request.Stop
+	}
+	if !stop.After(request.Start) {
+		respondError(c, http.StatusBadRequest, "stop must be after start")
+		return
+	}
+	if stop.Sub(request.Start) > limits.MaxRange {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Time range may span at most %s", limits.MaxRange))
+		return
+	}
+
+	query := database.FluxQuery{
+		Query:      request.Query,
+		Params:     request.Params,
+		Start:      request.Start,
+		Stop:       stop,
+		MaxRecords: limits.MaxRecords,
+	}
+	s.logger.Info("Running custom Flux query",
+		zap.String("user_id", c.GetString(contextUserIDKey)), zap.String("query", request.Query))
+
+	result, err := s.flux.QueryFlux(c.Request.Context(), query)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidFluxQuery):
+			respondError(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, context.DeadlineExceeded):
+			respondError(c, http.StatusGatewayTimeout, fmt.Sprintf("Query ran longer than %s", limits.Timeout))
+		default:
+			s.logger.Error("Failed to run Flux query", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to run query")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, FluxQueryResponse{Records: result.Records, Truncated: result.Truncated})
+}
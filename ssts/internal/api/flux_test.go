@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/database"
+)
+
+// fakeFluxQuerier checks queries as InfluxDB would be sent them and answers
+// with fixed records
+type fakeFluxQuerier struct {
+	query   database.FluxQuery
+	records []map[string]interface{}
+}
+
+func (f *fakeFluxQuerier) QueryFlux(ctx context.Context, query database.FluxQuery) (*database.FluxResult, error) {
+	if _, err := database.BuildFluxQuery("metrics", query); err != nil {
+		return nil, err
+	}
+	f.query = query
+	return &database.FluxResult{Records: f.records}, nil
+}
+
+func TestQueryFlux(t *testing.T) {
+	server, _ := newAuthTestServer(t, func(cfg *config.Config) {
+		cfg.InfluxDB.Flux.Enabled = true
+		cfg.InfluxDB.Flux.MaxRange = 24 * time.Hour
+	})
+	querier := &fakeFluxQuerier{records: []map[string]interface{}{{"_value": 42.0, "host": "rack-1"}}}
+	server.flux = querier
+
+	start := time.Now().Add(-time.Hour)
+	request := FluxQueryRequest{
+		Query:  `data |> filter(fn: (r) => r.host == params.host)`,
+		Params: map[string]interface{}{"host": "rack-1"},
+		Start:  start,
+	}
+	if rec := server.serveAs("bob", http.MethodPost, "/api/v1/flux/query", request); rec.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status %d, want 403", rec.Code)
+	}
+
+	rec := server.serveAs("root", http.MethodPost, "/api/v1/flux/query", request)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("query: status %d: %s", rec.Code, rec.Body)
+	}
+	var response FluxQueryResponse
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if len(response.Records) != 1 || response.Records[0]["host"] != "rack-1" {
+		t.Errorf("records = %+v", response.Records)
+	}
+	if querier.query.Stop.Before(start) || querier.query.MaxRecords != server.config.InfluxDB.Flux.MaxRecords {
+		t.Errorf("query = %+v, want it to stop now with the configured record limit", querier.query)
+	}
+
+	tooLong := request
+	tooLong.Start = start.Add(-48 * time.Hour)
+	if rec := server.serveAs("root", http.MethodPost, "/api/v1/flux/query", tooLong); rec.Code != http.StatusBadRequest {
+		t.Errorf("range over the limit: status %d, want 400", rec.Code)
+	}
+
+	other := request
+	other.Query = `from(bucket: "_monitoring") |> range(start: -1h)`
+	if rec := server.serveAs("root", http.MethodPost, "/api/v1/flux/query", other); rec.Code != http.StatusBadRequest {
+		t.Errorf("query choosing its bucket: status %d, want 400", rec.Code)
+	}
+}
+
+func TestQueryFluxDisabled(t *testing.T) {
+	server, _ := newAuthTestServer(t, nil)
+	rec := server.serveAs("root", http.MethodPost, "/api/v1/flux/query", FluxQueryRequest{Query: "data", Start: time.Now().Add(-time.Hour)})
+	if rec.Code == http.StatusOK {
+		t.Errorf("disabled: status %d, want the route missing", rec.Code)
+	}
+}
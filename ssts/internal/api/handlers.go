@@ -2,28 +2,37 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/core"
 	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
 	"github.com/pranavgopavaram/ssts/pkg/models"
 )
 
 // Additional API handlers
 
 // @Summary Update test configuration
-// @Description Update an existing test configuration
+// @Description Update an existing test configuration. Secrets sent back as redacted by GET, such as passwords in the plugin configuration, keep their stored values.
 // @Tags tests
 // @Accept json
 // @Produce json
 // @Param id path string true "Test ID"
 // @Param test body models.TestConfiguration true "Updated test configuration"
 // @Success 200 {object} models.TestConfiguration
-// @Failure 400 {object} ErrorResponse
+// @Failure 400 {object} ErrorResponse "Details lists the invalid fields"
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/tests/{id} [put]
@@ -31,49 +40,79 @@ func (s *Server) updateTest(c *gin.Context) {
 	id := c.Param("id")
 
 	var test models.TestConfiguration
-	if err := c.ShouldBindJSON(&test); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !bindJSON(c, &test) {
+		return
+	}
+
+	if fieldErrs := s.validateTestConfiguration(&test); len(fieldErrs) > 0 {
+		respondErrorCode(c, http.StatusBadRequest, errorCode(http.StatusBadRequest), "Invalid test configuration", fieldErrs)
 		return
 	}
 
-	// Ensure ID matches
+	// Keep the secrets a client sent back redacted
+	stored, err := s.repo.GetTestConfiguration(id)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		s.logger.Error("Failed to get test", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to update test")
+		return
+	}
+	if stored != nil {
+		restoreTestSecrets(&test, *stored)
+		test.CreatedBy = stored.CreatedBy
+	}
+
+	// Ensure ID and project match
 	test.ID = id
+	test.ProjectID = currentProjectID(c)
 	test.Updated = time.Now()
 
-	repo := database.NewRepository(s.db)
-	if err := repo.UpdateTestConfiguration(&test); err != nil {
-		if err.Error() == "record not found" {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Test not found"})
+	if err := s.repo.UpdateTestConfiguration(&test); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Test not found")
 		} else {
 			s.logger.Error("Failed to update test", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to update test"})
+			respondError(c, http.StatusInternalServerError, "Failed to update test")
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, test)
+	c.JSON(http.StatusOK, redactTest(test))
 }
 
 // @Summary Delete test configuration
-// @Description Delete a test configuration
+// @Description Delete a test configuration. With auth enabled only its creator, a project owner or an admin may.
 // @Tags tests
 // @Accept json
 // @Produce json
 // @Param id path string true "Test ID"
 // @Success 204 "No Content"
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/tests/{id} [delete]
 func (s *Server) deleteTest(c *gin.Context) {
 	id := c.Param("id")
 
-	repo := database.NewRepository(s.db)
-	if err := repo.DeleteTestConfiguration(id); err != nil {
-		if err.Error() == "record not found" {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Test not found"})
+	test, err := s.repo.GetTestConfiguration(id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Test not found")
+		} else {
+			s.logger.Error("Failed to get test", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to delete test")
+		}
+		return
+	}
+	if !s.checkOwner(c, test.CreatedBy) {
+		return
+	}
+
+	if err := s.repo.DeleteTestConfiguration(id); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Test not found")
 		} else {
 			s.logger.Error("Failed to delete test", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to delete test"})
+			respondError(c, http.StatusInternalServerError, "Failed to delete test")
 		}
 		return
 	}
@@ -82,36 +121,38 @@ func (s *Server) deleteTest(c *gin.Context) {
 }
 
 // @Summary Stop test execution
-// @Description Stop a running test
+// @Description Stop a running test. With auth enabled only the user who ran it, a project owner or an admin may.
 // @Tags tests
 // @Accept json
 // @Produce json
 // @Param id path string true "Test ID"
 // @Success 200 {object} map[string]string
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/tests/{id}/stop [post]
 func (s *Server) stopTest(c *gin.Context) {
 	id := c.Param("id")
 
-	// Find running execution for this test
-	executions := s.orchestrator.ListExecutions()
-	var executionID string
-	for _, exec := range executions {
-		if exec.TestID == id && exec.Status == models.StatusRunning {
-			executionID = exec.ID
-			break
+	// Find running or queued execution for this test
+	execution, err := s.orchestrator.GetRunningExecutionForTest(id)
+	if err != nil {
+		if errors.Is(err, core.ErrExecutionNotFound) {
+			respondError(c, http.StatusNotFound, "No running execution found for this test")
+		} else {
+			s.logger.Error("Failed to find running execution", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to find running execution")
 		}
+		return
 	}
-
-	if executionID == "" {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "No running execution found for this test"})
+	if !s.checkOwner(c, execution.StartedBy) {
 		return
 	}
+	executionID := execution.ID
 
 	if err := s.orchestrator.StopTest(executionID); err != nil {
 		s.logger.Error("Failed to stop test", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to stop test"})
+		respondError(c, http.StatusInternalServerError, "Failed to stop test")
 		return
 	}
 
@@ -135,18 +176,14 @@ func (s *Server) getTestStatus(c *gin.Context) {
 	id := c.Param("id")
 
 	// Find the latest execution for this test
-	executions := s.orchestrator.ListExecutions()
-	var latestExecution *models.TestExecution
-	for _, exec := range executions {
-		if exec.TestID == id {
-			if latestExecution == nil || exec.StartTime.After(*latestExecution.StartTime) {
-				latestExecution = &exec
-			}
+	latestExecution, err := s.orchestrator.GetLatestExecutionForTest(id)
+	if err != nil {
+		if errors.Is(err, core.ErrExecutionNotFound) {
+			respondError(c, http.StatusNotFound, "No execution found for this test")
+		} else {
+			s.logger.Error("Failed to find latest execution", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to find latest execution")
 		}
-	}
-
-	if latestExecution == nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "No execution found for this test"})
 		return
 	}
 
@@ -154,11 +191,12 @@ func (s *Server) getTestStatus(c *gin.Context) {
 }
 
 // @Summary Get test results
-// @Description Get aggregated results for a test
+// @Description Get aggregated results for a test's latest completed execution, or with include_partial its latest finished one: a run that failed or was stopped gives a partial result, scored on what ran until then
 // @Tags tests
 // @Accept json
 // @Produce json
 // @Param id path string true "Test ID"
+// @Param include_partial query bool false "Consider executions that failed or were stopped"
 // @Success 200 {object} models.TestResult
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
@@ -166,50 +204,62 @@ func (s *Server) getTestStatus(c *gin.Context) {
 func (s *Server) getTestResults(c *gin.Context) {
 	id := c.Param("id")
 
-	// Find completed executions for this test
-	executions := s.orchestrator.ListExecutions()
-	var completedExecutions []models.TestExecution
-	for _, exec := range executions {
-		if exec.TestID == id && exec.Status == models.StatusCompleted {
-			completedExecutions = append(completedExecutions, exec)
-		}
-	}
-
-	if len(completedExecutions) == 0 {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "No completed executions found for this test"})
-		return
+	// Get the latest completed execution, or finished one if partial results are wanted
+	statuses := []models.ExecutionStatus{models.StatusCompleted}
+	if c.Query("include_partial") == "true" {
+		statuses = append(statuses, models.StatusFailed, models.StatusStopped, models.StatusTimedOut)
 	}
-
-	// Get the latest completed execution
-	latestExecution := completedExecutions[0]
-	for _, exec := range completedExecutions {
-		if exec.StartTime.After(*latestExecution.StartTime) {
-			latestExecution = exec
+	latestExecution, err := s.orchestrator.GetLatestExecutionForTest(id, statuses...)
+	if err != nil {
+		if errors.Is(err, core.ErrExecutionNotFound) {
+			respondError(c, http.StatusNotFound, "No completed executions found for this test")
+		} else {
+			s.logger.Error("Failed to find latest completed execution", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to find latest completed execution")
 		}
+		return
 	}
 
 	// Build test result
 	result := models.TestResult{
-		TestID:   id,
-		Status:   latestExecution.Status,
-		Duration: latestExecution.Duration,
-		Passed:   latestExecution.Status == models.StatusCompleted,
-		Score:    calculateTestScore(latestExecution),
+		TestID:      id,
+		ExecutionID: latestExecution.ID,
+		Status:      latestExecution.Status,
+		Duration:    latestExecution.Duration,
+		Passed:      latestExecution.Status == models.StatusCompleted,
+		Score:       calculateTestScore(*latestExecution),
+	}
+	if latestExecution.ErrorMessage != nil {
+		result.Errors = []string{*latestExecution.ErrorMessage}
+	}
+
+	var summary models.ExecutionSummary
+	if len(latestExecution.Summary) > 0 && json.Unmarshal(latestExecution.Summary, &summary) == nil {
+		result.Energy = summary.Energy
+		if partial := summary.Partial; partial != nil {
+			result.Partial = true
+			result.FailureReason = partial.Reason
+			result.Completion = partial.Completion
+			result.Score = partial.Score
+		}
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
 // @Summary Get test metrics
-// @Description Get metrics for a specific test
+// @Description Get metrics for a specific test, by default over the last hour. window=last_run covers the test's latest execution and window=steady_state that execution at full intensity, without ramp-up, ramp-down and cooldown.
 // @Tags tests
 // @Accept json
 // @Produce json
 // @Param id path string true "Test ID"
 // @Param start query string false "Start time (RFC3339)"
 // @Param end query string false "End time (RFC3339)"
+// @Param window query string false "Named window instead of start and end: last_run or steady_state"
 // @Success 200 {array} models.MetricPoint
 // @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "The test has not been run"
+// @Failure 409 {object} ErrorResponse "The latest execution has no steady state"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/tests/{id}/metrics [get]
 func (s *Server) getTestMetrics(c *gin.Context) {
@@ -233,51 +283,29 @@ func (s *Server) getTestMetrics(c *gin.Context) {
 		}
 	}
 
+	if window := c.Query("window"); window != "" {
+		if !noExplicitRange(c) {
+			return
+		}
+		resolved, _, err := s.orchestrator.TestWindow(id, window)
+		if err != nil {
+			s.respondWindowError(c, err)
+			return
+		}
+		timeRange = resolved
+	}
+
 	// Query metrics from InfluxDB
 	metrics, err := s.influxDB.QueryMetrics(context.Background(), id, "system_cpu", timeRange)
 	if err != nil {
 		s.logger.Error("Failed to query metrics", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to query metrics"})
+		respondError(c, http.StatusInternalServerError, "Failed to query metrics")
 		return
 	}
 
 	c.JSON(http.StatusOK, metrics)
 }
 
-// @Summary Export test data
-// @Description Export test data in various formats
-// @Tags tests
-// @Accept json
-// @Produce json
-// @Param id path string true "Test ID"
-// @Param request body models.ExportRequest true "Export request"
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /api/v1/tests/{id}/export [post]
-func (s *Server) exportTestData(c *gin.Context) {
-	id := c.Param("id")
-
-	var request models.ExportRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
-		return
-	}
-
-	request.TestID = id
-
-	// TODO: Implement data export functionality
-	// This would include:
-	// - Query metrics from InfluxDB
-	// - Generate reports in requested format (JSON, CSV, PDF)
-	// - Return download link or data directly
-
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"message": "Export functionality not yet implemented",
-		"request": request,
-	})
-}
-
 // Execution handlers
 
 // @Summary List test executions
@@ -287,36 +315,145 @@ func (s *Server) exportTestData(c *gin.Context) {
 // @Produce json
 // @Param limit query int false "Limit number of results" default(50)
 // @Param offset query int false "Offset for pagination" default(0)
-// @Param status query string false "Filter by status"
+// @Param status query string false "Filter by status (comma-separated for several)"
+// @Param test_id query string false "Filter by test configuration ID"
+// @Param name query string false "Filter by test name substring"
+// @Param plugin query string false "Filter by plugin"
+// @Param created_by query string false "Filter by test creator"
+// @Param started_by query string false "Filter by the user who ran the test"
+// @Param mine query bool false "Only executions the caller ran"
+// @Param review_status query string false "Filter by review status (comma-separated for several)"
+// @Param started_after query string false "Only executions started at or after this time (RFC3339)"
+// @Param started_before query string false "Only executions started at or before this time (RFC3339)"
+// @Param label query string false "Label selector, e.g. rack=r12,gen!=g4,owner (repeatable; every term must match)"
+// @Param ci_commit query string false "Filter by the commit under test; abbreviated SHAs match"
+// @Param ci_build_number query string false "Filter by the build number under test"
+// @Param search query string false "ID of a saved search whose filters apply underneath the explicit ones"
+// @Param sort query string false "Comma-separated sort fields, prefix with - for descending (status, start_time, end_time, duration, created)"
 // @Success 200 {array} models.TestExecution
+// @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/executions [get]
 func (s *Server) listExecutions(c *gin.Context) {
-	limit := parseIntQuery(c, "limit", 50)
-	offset := parseIntQuery(c, "offset", 0)
-	status := c.Query("status")
-
-	repo := database.NewRepository(s.db)
-	var executions []models.TestExecution
-	var err error
+	query, ok := s.listQuery(c, models.SavedSearchExecutions)
+	if !ok {
+		return
+	}
 
-	if status != "" {
-		executions, err = repo.ListTestExecutionsByStatus(models.ExecutionStatus(status), limit, offset)
-	} else {
-		executions, err = repo.ListTestExecutions(limit, offset)
+	filter, err := executionFilter(query)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.ProjectID = currentProjectID(c)
+	mine, ok := mineFilter(c, query)
+	if !ok {
+		return
+	}
+	if mine != "" {
+		filter.StartedBy = mine
 	}
 
+	executions, err := s.repo.ListTestExecutionsFiltered(filter)
 	if err != nil {
 		s.logger.Error("Failed to list executions", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to list executions"})
+		respondError(c, http.StatusInternalServerError, "Failed to list executions")
 		return
 	}
 
+	setPagination(c, filter.Limit, filter.Offset, len(executions))
 	c.JSON(http.StatusOK, executions)
 }
 
+// @Summary List running executions
+// @Description Get the executions of the project that are queued, pending or running, with their live state. Executions are only listed by GET /executions once they finish.
+// @Tags executions
+// @Produce json
+// @Param mine query bool false "Only executions the caller ran"
+// @Success 200 {array} models.TestExecution
+// @Failure 400 {object} ErrorResponse
+// @Router /api/v1/executions/running [get]
+func (s *Server) listRunningExecutions(c *gin.Context) {
+	projectID := currentProjectID(c)
+	mine, ok := mineFilter(c, c.Request.URL.Query())
+	if !ok {
+		return
+	}
+
+	running := make([]models.TestExecution, 0)
+	for _, execution := range s.orchestrator.ListExecutions() {
+		if execution.ProjectID != projectID || (mine != "" && execution.StartedBy != mine) {
+			continue
+		}
+		switch execution.Status {
+		case models.StatusQueued, models.StatusPending, models.StatusRunning:
+			running = append(running, execution)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].Created.Before(running[j].Created) })
+
+	c.JSON(http.StatusOK, running)
+}
+
+// executionFilter builds the test execution filter from list query parameters
+func executionFilter(query url.Values) (database.ExecutionFilter, error) {
+	sort, err := database.ParseSort(query.Get("sort"), database.ExecutionSortFields)
+	if err != nil {
+		return database.ExecutionFilter{}, err
+	}
+
+	startedAfter, err := parseTimeValue(query, "started_after")
+	if err != nil {
+		return database.ExecutionFilter{}, err
+	}
+	startedBefore, err := parseTimeValue(query, "started_before")
+	if err != nil {
+		return database.ExecutionFilter{}, err
+	}
+
+	labels, err := database.ParseLabelSelector(query["label"])
+	if err != nil {
+		return database.ExecutionFilter{}, err
+	}
+
+	var statuses []models.ExecutionStatus
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			statuses = append(statuses, models.ExecutionStatus(status))
+		}
+	}
+
+	var reviewStatuses []models.ReviewStatus
+	for _, status := range strings.Split(query.Get("review_status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			if !models.ReviewStatus(status).Valid() {
+				return database.ExecutionFilter{}, fmt.Errorf("unknown review status %q", status)
+			}
+			reviewStatuses = append(reviewStatuses, models.ReviewStatus(status))
+		}
+	}
+
+	return database.ExecutionFilter{
+		TestID:           query.Get("test_id"),
+		TestNameContains: query.Get("name"),
+		Plugin:           query.Get("plugin"),
+		CreatedBy:        query.Get("created_by"),
+		StartedBy:        query.Get("started_by"),
+		Statuses:         statuses,
+		ReviewStatuses:   reviewStatuses,
+		StartedAfter:     startedAfter,
+		StartedBefore:    startedBefore,
+		Labels:           labels,
+		CICommit:         query.Get("ci_commit"),
+		CIBuildNumber:    query.Get("ci_build_number"),
+		Sort:             sort,
+		Limit:            parseIntValue(query, "limit", 50),
+		Offset:           parseIntValue(query, "offset", 0),
+	}, nil
+}
+
 // @Summary Get test execution
-// @Description Get a specific test execution by ID
+// @Description Get a specific test execution by ID. Running executions include their progress: percentage, phase, elapsed and estimated remaining time.
 // @Tags executions
 // @Accept json
 // @Produce json
@@ -329,38 +466,63 @@ func (s *Server) getExecution(c *gin.Context) {
 	id := c.Param("id")
 
 	execution, err := s.orchestrator.GetTestStatus(id)
-	if err != nil {
-		if err.Error() == "test execution not found: "+id {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Execution not found"})
-		} else {
-			s.logger.Error("Failed to get execution", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to get execution"})
+	if errors.Is(err, core.ErrExecutionNotFound) {
+		// Finished executions are persisted with their summary after leaving memory
+		execution, err = s.repo.GetTestExecution(id)
+		if err == nil && execution.ProjectID != currentProjectID(c) {
+			respondError(c, http.StatusNotFound, "Execution not found")
+			return
 		}
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Execution not found")
+			return
+		}
+	}
+	if err != nil {
+		s.logger.Error("Failed to get execution", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to get execution")
 		return
 	}
 
+	// Reviews are only recorded in the repository, so finished executions the
+	// orchestrator still holds take theirs from there
+	if finishedStatus(execution.Status) && execution.ReviewStatus == "" {
+		if stored, err := s.repo.GetTestExecution(id); err == nil {
+			execution.ReviewStatus = stored.ReviewStatus
+			execution.Reviewer = stored.Reviewer
+			execution.ReviewNotes = stored.ReviewNotes
+			execution.Reviewed = stored.Reviewed
+		}
+	}
+
 	c.JSON(http.StatusOK, execution)
 }
 
 // @Summary Stop test execution
-// @Description Stop a running test execution
+// @Description Stop a running test execution. With auth enabled only the user who ran it, a project owner or an admin may.
 // @Tags executions
 // @Accept json
 // @Produce json
 // @Param id path string true "Execution ID"
 // @Success 200 {object} map[string]string
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/executions/{id}/stop [post]
 func (s *Server) stopExecution(c *gin.Context) {
 	id := c.Param("id")
 
+	execution, err := s.orchestrator.GetTestStatus(id)
+	if err == nil && !s.checkOwner(c, execution.StartedBy) {
+		return
+	}
+
 	if err := s.orchestrator.StopTest(id); err != nil {
-		if err.Error() == "test execution not found: "+id {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Execution not found"})
+		if errors.Is(err, core.ErrExecutionNotFound) {
+			respondError(c, http.StatusNotFound, "Execution not found")
 		} else {
 			s.logger.Error("Failed to stop execution", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to stop execution"})
+			respondError(c, http.StatusInternalServerError, "Failed to stop execution")
 		}
 		return
 	}
@@ -370,27 +532,183 @@ func (s *Server) stopExecution(c *gin.Context) {
 	})
 }
 
+// ExtendExecutionRequest is the body for extending a running execution
+type ExtendExecutionRequest struct {
+	Duration string `json:"duration" binding:"required"` // run time to add, e.g. "2h"
+}
+
+// @Summary Extend test execution
+// @Description Lengthen the run of a running execution, such as a soak test that is behaving interestingly, without restarting it. The extended duration is held to the configured maximum, the soak maximum for soak runs, and the caller's role maximum. Only executions of plugins whose runs end at their deadline can be extended, and only before their cooldown. With auth enabled only the user who ran it, a project owner or an admin may extend it.
+// @Tags executions
+// @Accept json
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Param request body ExtendExecutionRequest true "Extension"
+// @Success 200 {object} models.TestExecution
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/extend [post]
+func (s *Server) extendExecution(c *gin.Context) {
+	var request ExtendExecutionRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+	extension, err := time.ParseDuration(request.Duration)
+	if err != nil || extension <= 0 {
+		respondError(c, http.StatusBadRequest, "Invalid duration")
+		return
+	}
+
+	id := c.Param("id")
+	execution, err := s.orchestrator.GetTestStatus(id)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Execution not found")
+		return
+	}
+	if !s.checkOwner(c, execution.StartedBy) {
+		return
+	}
+
+	execution, err = s.orchestrator.ExtendTest(id, extension, s.roleMaxDuration(c))
+	switch {
+	case errors.Is(err, core.ErrExecutionNotFound):
+		respondError(c, http.StatusNotFound, "Execution not found")
+	case errors.Is(err, core.ErrNotExtendable):
+		respondErrorCode(c, http.StatusConflict, CodeNotExtendable, err.Error(), nil)
+	case errors.Is(err, core.ErrDurationExceeded):
+		respondErrorCode(c, http.StatusBadRequest, CodeDurationExceeded, err.Error(), nil)
+	case err != nil:
+		s.logger.Error("Failed to extend execution", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to extend execution")
+	default:
+		c.JSON(http.StatusOK, execution)
+	}
+}
+
+// @Summary Pause test execution
+// @Description Hold the load of a running execution without ending it. Its run time, watchdog budget and load profile stand still while paused. Only executions of plugins supporting pause can be paused, and not soak runs or during cooldown. Pausing a paused execution leaves it paused. With auth enabled only the user who ran it, a project owner or an admin may pause it.
+// @Tags executions
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Success 200 {object} models.TestExecution
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/pause [post]
+func (s *Server) pauseExecution(c *gin.Context) {
+	s.setExecutionPaused(c, true)
+}
+
+// @Summary Resume test execution
+// @Description Take up the load of a paused execution again; it runs for the rest of its duration. Resuming an execution that isn't paused leaves it as it is. With auth enabled only the user who ran it, a project owner or an admin may resume it.
+// @Tags executions
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Success 200 {object} models.TestExecution
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/resume [post]
+func (s *Server) resumeExecution(c *gin.Context) {
+	s.setExecutionPaused(c, false)
+}
+
+// setExecutionPaused pauses or resumes the execution named in the path
+func (s *Server) setExecutionPaused(c *gin.Context, paused bool) {
+	id := c.Param("id")
+	execution, err := s.orchestrator.GetTestStatus(id)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Execution not found")
+		return
+	}
+	if !s.checkOwner(c, execution.StartedBy) {
+		return
+	}
+
+	if paused {
+		execution, err = s.orchestrator.PauseTest(id)
+	} else {
+		execution, err = s.orchestrator.UnpauseTest(id)
+	}
+	switch {
+	case errors.Is(err, core.ErrExecutionNotFound):
+		respondError(c, http.StatusNotFound, "Execution not found")
+	case errors.Is(err, core.ErrNotPausable):
+		respondErrorCode(c, http.StatusConflict, CodeNotPausable, err.Error(), nil)
+	case err != nil:
+		s.logger.Error("Failed to pause or resume execution", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to pause or resume execution")
+	default:
+		c.JSON(http.StatusOK, execution)
+	}
+}
+
 // @Summary Get execution metrics
-// @Description Get metrics for a specific execution
+// @Description Get metrics for a specific execution, optionally within a time range given by start and end or by a named window: steady_state leaves out ramp-up, ramp-down and cooldown, and last_run is the whole execution. Recent points are held in memory and older ones read back from InfluxDB. With annotations=true the metrics are returned alongside the execution's annotations.
 // @Tags executions
 // @Accept json
 // @Produce json
 // @Param id path string true "Execution ID"
+// @Param start query string false "Start time (RFC3339)"
+// @Param end query string false "End time (RFC3339)"
+// @Param window query string false "Named window instead of start and end: steady_state or last_run"
+// @Param annotations query bool false "Also return the execution's annotations"
 // @Success 200 {array} models.MetricPoint
+// @Success 200 {object} ExecutionMetricsResponse "With annotations=true"
+// @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "The execution has no steady state"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/executions/{id}/metrics [get]
 func (s *Server) getExecutionMetrics(c *gin.Context) {
 	id := c.Param("id")
 
-	metrics, err := s.orchestrator.GetTestMetrics(id)
+	timeRange, ok := s.executionMetricsRange(c, id)
+	if !ok {
+		return
+	}
+
+	metrics, err := s.orchestrator.GetTestMetrics(id, timeRange)
 	if err != nil {
-		if err.Error() == "test execution not found: "+id {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Execution not found"})
+		if errors.Is(err, core.ErrExecutionNotFound) {
+			respondError(c, http.StatusNotFound, "Execution not found")
 		} else {
 			s.logger.Error("Failed to get execution metrics", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to get execution metrics"})
+			respondError(c, http.StatusInternalServerError, "Failed to get execution metrics")
+		}
+		return
+	}
+
+	if c.Query("annotations") == "true" {
+		annotations, err := s.repo.ListAnnotations(id)
+		if err != nil {
+			s.logger.Error("Failed to list annotations", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to list annotations")
+			return
 		}
+		c.JSON(http.StatusOK, ExecutionMetricsResponse{Metrics: metrics, Annotations: annotations})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+// @Summary Get execution plugin metrics
+// @Description Get the latest metrics reported by the plugin instance running an execution. Each execution has its own instance, so runs of the same plugin don't mix.
+// @Tags executions
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/plugin-metrics [get]
+func (s *Server) getExecutionPluginMetrics(c *gin.Context) {
+	id := c.Param("id")
+
+	metrics, err := s.orchestrator.GetPluginMetrics(id)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Execution not found")
 		return
 	}
 
@@ -436,13 +754,7 @@ func (s *Server) listPlugins(c *gin.Context) {
 	// Convert to response format
 	pluginList := make([]map[string]interface{}, 0, len(plugins))
 	for _, plugin := range plugins {
-		pluginInfo := map[string]interface{}{
-			"name":         plugin.Name(),
-			"version":      plugin.Version(),
-			"description":  plugin.Description(),
-			"safety_limits": plugin.GetSafetyLimits(),
-		}
-		pluginList = append(pluginList, pluginInfo)
+		pluginList = append(pluginList, s.pluginInfo(plugin))
 	}
 
 	c.JSON(http.StatusOK, pluginList)
@@ -462,18 +774,11 @@ func (s *Server) getPlugin(c *gin.Context) {
 
 	plugin, exists := s.orchestrator.GetPluginManager().GetPlugin(name)
 	if !exists {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Plugin not found"})
+		respondError(c, http.StatusNotFound, "Plugin not found")
 		return
 	}
 
-	pluginInfo := map[string]interface{}{
-		"name":         plugin.Name(),
-		"version":      plugin.Version(),
-		"description":  plugin.Description(),
-		"safety_limits": plugin.GetSafetyLimits(),
-	}
-
-	c.JSON(http.StatusOK, pluginInfo)
+	c.JSON(http.StatusOK, s.pluginInfo(plugin))
 }
 
 // @Summary Get plugin configuration schema
@@ -490,7 +795,7 @@ func (s *Server) getPluginSchema(c *gin.Context) {
 
 	plugin, exists := s.orchestrator.GetPluginManager().GetPlugin(name)
 	if !exists {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Plugin not found"})
+		respondError(c, http.StatusNotFound, "Plugin not found")
 		return
 	}
 
@@ -498,8 +803,55 @@ func (s *Server) getPluginSchema(c *gin.Context) {
 	c.Data(http.StatusOK, "application/json", schema)
 }
 
+// PluginSchemaResponse is a plugin's configuration schema, with the form a
+// client can generate from it
+type PluginSchemaResponse struct {
+	Name         string                    `json:"name"`
+	Version      string                    `json:"version"`
+	Description  string                    `json:"description"`
+	Enabled      bool                      `json:"enabled"`
+	Capabilities models.PluginCapabilities `json:"capabilities"`
+	Schema       json.RawMessage           `json:"schema"`
+	Form         *plugins.ConfigForm       `json:"form"` // null if the schema can't be parsed
+}
+
+// @Summary List plugin configuration schemas
+// @Description Get the configuration schema of every plugin in one response, each with a form descriptor grouping and ordering its fields with titles and units, for building configuration forms, and the plugin's capabilities for deciding which run controls to show
+// @Tags plugins
+// @Produce json
+// @Success 200 {array} PluginSchemaResponse
+// @Router /api/v1/plugins/schemas [get]
+func (s *Server) listPluginSchemas(c *gin.Context) {
+	pluginMgr := s.orchestrator.GetPluginManager()
+	registered := pluginMgr.ListPlugins()
+	sort.Slice(registered, func(i, j int) bool { return registered[i].Name() < registered[j].Name() })
+
+	schemas := make([]PluginSchemaResponse, 0, len(registered))
+	for _, plugin := range registered {
+		schema := plugin.ConfigSchema()
+		form, err := plugins.DescribeConfig(schema)
+		if err != nil {
+			s.logger.Warn("Failed to describe plugin schema", zap.String("plugin", plugin.Name()), zap.Error(err))
+		}
+		if !json.Valid(schema) {
+			schema = nil
+		}
+		schemas = append(schemas, PluginSchemaResponse{
+			Name:         plugin.Name(),
+			Version:      plugin.Version(),
+			Description:  plugin.Description(),
+			Enabled:      pluginMgr.IsEnabled(plugin.Name()),
+			Capabilities: plugins.Capabilities(plugin),
+			Schema:       schema,
+			Form:         form,
+		})
+	}
+
+	c.JSON(http.StatusOK, schemas)
+}
+
 // @Summary Validate plugin configuration
-// @Description Validate a plugin configuration against its schema
+// @Description Validate a plugin configuration against its schema. Problems that don't stop the plugin running, such as test files placed on tmpfs, are listed in warnings.
 // @Tags plugins
 // @Accept json
 // @Produce json
@@ -514,13 +866,12 @@ func (s *Server) validatePluginConfig(c *gin.Context) {
 
 	plugin, exists := s.orchestrator.GetPluginManager().GetPlugin(name)
 	if !exists {
-		c.JSON(http.StatusNotFound, ErrorResponse{Error: "Plugin not found"})
+		respondError(c, http.StatusNotFound, "Plugin not found")
 		return
 	}
 
 	var config map[string]interface{}
-	if err := c.ShouldBindJSON(&config); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !bindJSON(c, &config) {
 		return
 	}
 
@@ -533,12 +884,19 @@ func (s *Server) validatePluginConfig(c *gin.Context) {
 		return
 	}
 
+	response := map[string]interface{}{
+		"valid": true,
+	}
+	if warner, ok := plugin.(plugins.ConfigWarner); ok {
+		if warnings := warner.ConfigWarnings(); len(warnings) > 0 {
+			response["warnings"] = warnings
+		}
+	}
+
 	// Clean up after validation
 	plugin.Cleanup()
 
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"valid": true,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
 // System handlers
@@ -552,20 +910,7 @@ func (s *Server) validatePluginConfig(c *gin.Context) {
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/system/metrics [get]
 func (s *Server) getSystemMetrics(c *gin.Context) {
-	// TODO: Get metrics from metrics collector
-	// For now, return placeholder data
-	
-	metrics := models.SystemMetrics{
-		Timestamp: time.Now(),
-		CPU: models.CPUMetrics{
-			UsagePercent: 45.2,
-		},
-		Memory: models.MemoryMetrics{
-			UsagePercent: 62.8,
-		},
-	}
-
-	c.JSON(http.StatusOK, metrics)
+	c.JSON(http.StatusOK, s.orchestrator.GetSystemMetrics())
 }
 
 // @Summary Get system health
@@ -597,6 +942,8 @@ func (s *Server) getSystemInfo(c *gin.Context) {
 			"authentication": s.config.Auth.Enabled,
 			"metrics":        s.config.Metrics.Enabled,
 			"influxdb":       true,
+			"remote_write":   s.config.Metrics.HasOutput(config.MetricsOutputRemoteWrite),
+			"anomalies":      s.config.Metrics.Anomalies.Enabled,
 		},
 	}
 
@@ -606,35 +953,26 @@ func (s *Server) getSystemInfo(c *gin.Context) {
 // User handlers (placeholder - implement when auth is enabled)
 
 func (s *Server) getUserProfile(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, ErrorResponse{Error: "User management not implemented"})
+	respondError(c, http.StatusNotImplemented, "User management not implemented")
 }
 
 func (s *Server) updateUserProfile(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, ErrorResponse{Error: "User management not implemented"})
+	respondError(c, http.StatusNotImplemented, "User management not implemented")
 }
 
 func (s *Server) changePassword(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, ErrorResponse{Error: "User management not implemented"})
-}
-
-// Auth handlers (placeholder)
-
-func (s *Server) login(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, ErrorResponse{Error: "Authentication not implemented"})
-}
-
-func (s *Server) logout(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, ErrorResponse{Error: "Authentication not implemented"})
-}
-
-func (s *Server) refreshToken(c *gin.Context) {
-	c.JSON(http.StatusNotImplemented, ErrorResponse{Error: "Authentication not implemented"})
+	respondError(c, http.StatusNotImplemented, "User management not implemented")
 }
 
 // Helper functions
 
 func parseIntQuery(c *gin.Context, key string, defaultValue int) int {
-	if valueStr := c.Query(key); valueStr != "" {
+	return parseIntValue(c.Request.URL.Query(), key, defaultValue)
+}
+
+// parseIntValue parses an optional integer from query values
+func parseIntValue(values url.Values, key string, defaultValue int) int {
+	if valueStr := values.Get(key); valueStr != "" {
 		if value, err := strconv.Atoi(valueStr); err == nil {
 			return value
 		}
@@ -642,11 +980,29 @@ func parseIntQuery(c *gin.Context, key string, defaultValue int) int {
 	return defaultValue
 }
 
+// parseTimeQuery parses an optional RFC3339 timestamp query parameter
+func parseTimeQuery(c *gin.Context, key string) (*time.Time, error) {
+	return parseTimeValue(c.Request.URL.Query(), key)
+}
+
+// parseTimeValue parses an optional RFC3339 timestamp from query values
+func parseTimeValue(values url.Values, key string) (*time.Time, error) {
+	valueStr := values.Get(key)
+	if valueStr == "" {
+		return nil, nil
+	}
+	value, err := time.Parse(time.RFC3339, valueStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected RFC3339 timestamp", key)
+	}
+	return &value, nil
+}
+
 func calculateTestScore(execution models.TestExecution) float64 {
 	// Simple scoring algorithm - can be enhanced
 	if execution.Status == models.StatusCompleted {
 		return 100.0
-	} else if execution.Status == models.StatusFailed {
+	} else if execution.Status == models.StatusFailed || execution.Status == models.StatusTimedOut {
 		return 0.0
 	}
 	return 50.0
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// newTestServer creates a server on an in-memory repository with the CPU stress plugin
+func newTestServer(t *testing.T) (*Server, *database.MemoryRepository) {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	repo := database.NewMemoryRepository()
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(plugins.NewCPUStressPlugin())
+	orchestrator := core.NewOrchestrator(cfg, repo, pluginMgr, zap.NewNop())
+
+	return NewServer(cfg, repo, orchestrator, zap.NewNop()), repo
+}
+
+func (s *Server) serve(method, path string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCreateAndGetTest(t *testing.T) {
+	server, repo := newTestServer(t)
+
+	rec := server.serve(http.MethodPost, "/api/v1/tests", models.TestConfiguration{
+		Name:     "burn",
+		Plugin:   "cpu-stress",
+		Config:   json.RawMessage(`{"workers": 1, "algorithm": "prime", "intensity": 50}`),
+		Duration: time.Minute,
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create test: status %d: %s", rec.Code, rec.Body)
+	}
+	var created models.TestConfiguration
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := repo.GetTestConfiguration(created.ID)
+	if err != nil {
+		t.Fatalf("created test not stored: %v", err)
+	}
+	project, _ := repo.GetProjectByName(models.DefaultProjectName)
+	if stored.ProjectID != project.ID {
+		t.Errorf("test project = %q, want the default project %q", stored.ProjectID, project.ID)
+	}
+
+	rec = server.serve(http.MethodGet, "/api/v1/tests/"+created.ID, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get test: status %d: %s", rec.Code, rec.Body)
+	}
+	var got models.TestConfiguration
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "burn" {
+		t.Errorf("got test %+v", got)
+	}
+}
+
+func TestCreateTestValidation(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	rec := server.serve(http.MethodPost, "/api/v1/tests", models.TestConfiguration{Name: "burn", Plugin: "missing", Duration: time.Minute})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("create test with an unknown plugin: status %d, want 400", rec.Code)
+	}
+}
+
+func TestGetMissingTest(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	rec := server.serve(http.MethodGet, "/api/v1/tests/missing", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("get missing test: status %d, want 404: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestListExecutionsByTestName(t *testing.T) {
+	server, repo := newTestServer(t)
+	project, _ := repo.GetProjectByName(models.DefaultProjectName)
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t1", Name: "CPU burn", Plugin: "cpu-stress"})
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t2", Name: "Disk soak", Plugin: "cpu-stress"})
+	for _, execution := range []models.TestExecution{{ID: "e1", TestID: "t1"}, {ID: "e2", TestID: "t2"}} {
+		execution.ProjectID = project.ID
+		repo.CreateTestExecution(&execution)
+	}
+
+	for name, want := range map[string]int{"BURN": 1, "": 2, "%": 0} {
+		rec := server.serve(http.MethodGet, "/api/v1/executions?name="+url.QueryEscape(name), nil)
+		var executions []models.TestExecution
+		json.Unmarshal(rec.Body.Bytes(), &executions)
+		if rec.Code != http.StatusOK || len(executions) != want {
+			t.Errorf("name=%s: status %d, %d executions, want %d: %s", name, rec.Code, len(executions), want, rec.Body)
+		}
+	}
+}
+
+func TestListPluginSchemas(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.orchestrator.GetPluginManager().RegisterPlugin(plugins.NewMemoryStressPlugin())
+
+	rec := server.serve(http.MethodGet, "/api/v1/plugins/schemas", nil)
+	var schemas []PluginSchemaResponse
+	json.Unmarshal(rec.Body.Bytes(), &schemas)
+	if rec.Code != http.StatusOK || len(schemas) != 2 || schemas[0].Name != "cpu-stress" || schemas[1].Name != "memory-stress" {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	if cpu := schemas[0].Capabilities; !cpu.Pause || !cpu.Throttle || cpu.RequiresRoot {
+		t.Errorf("cpu-stress capabilities = %+v", cpu)
+	}
+
+	memory := schemas[1]
+	if len(memory.Schema) == 0 || memory.Form == nil || !memory.Enabled {
+		t.Fatalf("memory-stress = %+v, want its schema and form", memory)
+	}
+	var groups []string
+	for _, group := range memory.Form.Groups {
+		groups = append(groups, group.Title)
+	}
+	if strings.Join(groups, ", ") != "General, Verification, Garbage collection" {
+		t.Errorf("groups = %v", groups)
+	}
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/remotewrite"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Health statuses
+const (
+	healthHealthy   = "healthy"
+	healthUnhealthy = "unhealthy"
+	healthDegraded  = "degraded"
+)
+
+// HealthResponse is the result of the deep health check
+type HealthResponse struct {
+	Status       string                      `json:"status"` // healthy, or degraded when a required dependency is unhealthy
+	Timestamp    time.Time                   `json:"timestamp"`
+	Version      string                      `json:"version"`
+	Services     map[string]string           `json:"services"` // status by dependency
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+	MetricSinks  []remotewrite.Health        `json:"metric_sinks,omitempty"`
+	Queue        QueueHealth                 `json:"queue"`
+	Cached       bool                        `json:"cached"` // the dependencies' health was checked at Timestamp, not for this request
+}
+
+// DependencyHealth is the health of one dependency, as last probed
+type DependencyHealth struct {
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"` // the server is degraded without it
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// QueueHealth is the state of the orchestrator's executions
+type QueueHealth struct {
+	Running  int  `json:"running"`
+	Queued   int  `json:"queued"`
+	Draining bool `json:"draining"` // shutting down, not accepting new tests
+}
+
+// healthCache holds the last dependency health check, so probes hitting the
+// health endpoints don't hammer the dependencies
+type healthCache struct {
+	mu      sync.Mutex // held while checking, so concurrent requests share one check
+	report  *HealthResponse
+	expires time.Time
+}
+
+// healthProbe checks one dependency
+type healthProbe struct {
+	name     string
+	required bool
+	check    func(ctx context.Context) error
+}
+
+// @Summary Liveness check
+// @Description Report that the server process is up and serving requests. It checks no dependencies, so it stays cheap enough to probe often.
+// @Tags system
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /livez [get]
+func (s *Server) livenessCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive", "timestamp": time.Now().UTC()})
+}
+
+// @Summary Readiness check
+// @Description Check the server can take work: its required dependencies are healthy and it isn't draining for shutdown. Dependency health is cached for server.health.cache_ttl.
+// @Tags system
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Failure 503 {object} HealthResponse
+// @Router /readyz [get]
+func (s *Server) readinessCheck(c *gin.Context) {
+	health := s.health()
+	if health.Status != healthHealthy || health.Queue.Draining {
+		c.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+	c.JSON(http.StatusOK, health)
+}
+
+// @Summary Health check
+// @Description Get the health of each dependency (the database, InfluxDB, remote-write targets, Redis and the plugins' own checks) with how long it took to answer, and the orchestrator's running and queued executions. Only the database, InfluxDB and remote-write targets are required; the others are reported without degrading the server. Dependency health is cached for server.health.cache_ttl.
+// @Tags system
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Failure 503 {object} HealthResponse
+// @Router /health [get]
+func (s *Server) healthCheck(c *gin.Context) {
+	health := s.health()
+	if health.Status != healthHealthy {
+		c.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+	c.JSON(http.StatusOK, health)
+}
+
+// health returns the dependencies' health, checking them if the cached check
+// has expired, and the current state of the queue
+func (s *Server) health() HealthResponse {
+	s.healthCache.mu.Lock()
+	var health HealthResponse
+	if s.healthCache.report != nil && time.Now().Before(s.healthCache.expires) {
+		health = *s.healthCache.report
+		health.Cached = true
+	} else {
+		health = s.checkDependencies()
+		s.healthCache.report = &health
+		s.healthCache.expires = time.Now().Add(s.config.Server.Health.CacheTTL)
+	}
+	s.healthCache.mu.Unlock()
+
+	health.Queue = s.queueHealth()
+	return health
+}
+
+// checkDependencies probes every dependency at once, each bounded by the
+// configured timeout
+func (s *Server) checkDependencies() HealthResponse {
+	probes := []healthProbe{
+		{name: "database", required: true, check: func(ctx context.Context) error {
+			return withContext(ctx, s.repo.HealthCheck)
+		}},
+		{name: "influxdb", required: true, check: s.influxDB.HealthCheck},
+	}
+	if redis := s.config.Redis; redis.Address != "" {
+		// Nothing uses Redis yet, so it is reported without being required
+		probes = append(probes, healthProbe{name: "redis", check: func(ctx context.Context) error {
+			return pingRedis(ctx, redis)
+		}})
+	}
+	for _, plugin := range s.orchestrator.GetPluginManager().ListPlugins() {
+		plugin := plugin
+		probes = append(probes, healthProbe{name: "plugin:" + plugin.Name(), check: func(ctx context.Context) error {
+			return withContext(ctx, plugin.HealthCheck)
+		}})
+	}
+
+	health := HealthResponse{
+		Status:       healthHealthy,
+		Timestamp:    time.Now().UTC(),
+		Version:      "1.0.0",
+		Services:     make(map[string]string),
+		Dependencies: make(map[string]DependencyHealth),
+	}
+	results := make([]DependencyHealth, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func(i int, probe healthProbe) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), s.config.Server.Health.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := probe.check(ctx)
+			results[i] = DependencyHealth{
+				Status:    healthHealthy,
+				Required:  probe.required,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				results[i].Status = healthUnhealthy
+				results[i].Error = err.Error()
+			}
+		}(i, probe)
+	}
+	wg.Wait()
+	for i, probe := range probes {
+		health.add(probe.name, results[i])
+	}
+
+	// Remote-write targets report the outcome of the requests metrics are shipped in
+	if sinks := s.orchestrator.MetricSinkHealth(); len(sinks) > 0 {
+		for _, sink := range sinks {
+			dependency := DependencyHealth{Status: healthHealthy, Required: true, Error: sink.LastError}
+			if !sink.Healthy {
+				dependency.Status = healthUnhealthy
+			}
+			health.add("remote_write:"+sink.Name, dependency)
+		}
+		health.MetricSinks = sinks
+	}
+	return health
+}
+
+// add records a dependency's health, degrading the server if it is required
+// and unhealthy
+func (h *HealthResponse) add(name string, dependency DependencyHealth) {
+	h.Dependencies[name] = dependency
+	h.Services[name] = dependency.Status
+	if dependency.Required && dependency.Status != healthHealthy {
+		h.Status = healthDegraded
+	}
+}
+
+// queueHealth counts the orchestrator's running and queued executions
+func (s *Server) queueHealth() QueueHealth {
+	queue := QueueHealth{Draining: s.orchestrator.IsDraining()}
+	for _, execution := range s.orchestrator.ListExecutions() {
+		switch execution.Status {
+		case models.StatusRunning:
+			queue.Running++
+		case models.StatusQueued:
+			queue.Queued++
+		}
+	}
+	return queue
+}
+
+// withContext runs a check that takes no context, giving up on it once ctx is
+// done
+func withContext(ctx context.Context, check func() error) error {
+	result := make(chan error, 1)
+	go func() { result <- check() }()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pingRedis checks Redis answers PING, authenticating first if a password is
+// configured. The protocol is spoken directly as nothing else needs a client.
+func pingRedis(ctx context.Context, cfg config.RedisConfig) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", cfg.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	reader := bufio.NewReader(conn)
+	command := func(args ...string) (string, error) {
+		var request strings.Builder
+		fmt.Fprintf(&request, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(&request, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+		if _, err := conn.Write([]byte(request.String())); err != nil {
+			return "", err
+		}
+		reply, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		reply = strings.TrimRight(reply, "\r\n")
+		if strings.HasPrefix(reply, "-") {
+			return "", fmt.Errorf("redis: %s", reply[1:])
+		}
+		return reply, nil
+	}
+
+	if cfg.Password != "" {
+		if _, err := command("AUTH", cfg.Password); err != nil {
+			return err
+		}
+	}
+	reply, err := command("PING")
+	if err != nil {
+		return err
+	}
+	if reply != "+PONG" {
+		return fmt.Errorf("unexpected reply to PING: %q", reply)
+	}
+	return nil
+}
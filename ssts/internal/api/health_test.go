@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers PING on a local port, counting the connections made to it
+func fakeRedis(t *testing.T) (string, *int64) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var connections int64
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt64(&connections, 1)
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if strings.TrimSpace(line) == "PING" {
+						conn.Write([]byte("+PONG\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), &connections
+}
+
+func TestHealthChecksDependencies(t *testing.T) {
+	server, _ := newTestServer(t)
+	address, connections := fakeRedis(t)
+	server.config.Redis.Address = address
+	server.config.Server.Health.CacheTTL = time.Minute
+
+	get := func(path string) (int, HealthResponse) {
+		t.Helper()
+		rec := server.serve(http.MethodGet, path, nil)
+		var health HealthResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+			t.Fatalf("%s: %v: %s", path, err, rec.Body)
+		}
+		return rec.Code, health
+	}
+
+	_, health := get("/health")
+	if health.Cached {
+		t.Error("first check served from the cache")
+	}
+	for _, name := range []string{"database", "redis", "plugin:cpu-stress"} {
+		if dependency := health.Dependencies[name]; dependency.Status != healthHealthy || health.Services[name] != healthHealthy {
+			t.Errorf("%s = %+v, want healthy", name, dependency)
+		}
+	}
+	if !health.Dependencies["database"].Required || health.Dependencies["redis"].Required {
+		t.Errorf("dependencies = %+v, want the database required and Redis not", health.Dependencies)
+	}
+
+	// Later checks reuse the result until it expires
+	code, health := get("/readyz")
+	if !health.Cached || atomic.LoadInt64(connections) != 1 {
+		t.Errorf("readiness check cached = %v after %d Redis connections, want the first check reused", health.Cached, atomic.LoadInt64(connections))
+	}
+	wantReady := http.StatusOK
+	if health.Status != healthHealthy {
+		wantReady = http.StatusServiceUnavailable
+	}
+	if code != wantReady {
+		t.Errorf("readiness status %d for a %s server", code, health.Status)
+	}
+
+	// Draining servers aren't ready, whatever their dependencies
+	server.orchestrator.BeginDrain()
+	if code, health := get("/readyz"); code != http.StatusServiceUnavailable || !health.Queue.Draining {
+		t.Errorf("readiness while draining = %d, queue %+v", code, health.Queue)
+	}
+	if rec := server.serve(http.MethodGet, "/livez", nil); rec.Code != http.StatusOK {
+		t.Errorf("liveness while draining = %d, want the server alive", rec.Code)
+	}
+}
+
+func TestHealthReportsUnreachableRedisWithoutDegrading(t *testing.T) {
+	server, _ := newTestServer(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.config.Redis.Address = listener.Addr().String()
+	listener.Close()
+
+	health := server.health()
+	redis := health.Dependencies["redis"]
+	if redis.Status != healthUnhealthy || redis.Error == "" {
+		t.Errorf("redis = %+v, want unhealthy with the error", redis)
+	}
+	want := healthHealthy
+	for _, dependency := range health.Dependencies {
+		if dependency.Required && dependency.Status != healthHealthy {
+			want = healthDegraded
+		}
+	}
+	if health.Status != want {
+		t.Errorf("status = %s, want %s as only required dependencies degrade the server", health.Status, want)
+	}
+}
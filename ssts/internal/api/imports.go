@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/importer"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// @Summary Import execution
+// @Description Import the result file of an external benchmark tool as a completed execution, so historic benchmarks can be listed, charted and compared with runs SSTS made. fio JSON (--output-format=json), stress-ng YAML (--yaml) and sysbench's text output are read; the format is detected when not given. The run is imported into the test given by test_id, or else into the project's test named by test, which is created, running the SSTS plugin closest to the tool's workload, if it doesn't exist. Runs whose file doesn't record when they happened, such as sysbench's, are taken to have just ended unless start is given.
+// @Tags executions
+// @Accept plain
+// @Produce json
+// @Param format query string false "fio, stress-ng or sysbench; detected when omitted"
+// @Param test_id query string false "Test to import into"
+// @Param test query string false "Name of the test to import into, created if it doesn't exist; defaults to e.g. 'fio results'"
+// @Param start query string false "When the run started, as an RFC3339 timestamp; overrides the file's"
+// @Param results body string true "Result file"
+// @Success 201 {object} models.TestExecution
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/import [post]
+func (s *Server) importExecution(c *gin.Context) {
+	start, err := parseTimeQuery(c, "start")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	run, err := importer.Parse(c.Query("format"), body)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch {
+	case start != nil:
+		run.SetStart(*start)
+	case run.Start.IsZero():
+		run.SetStart(time.Now().Add(-run.Duration))
+	}
+
+	test := s.importTest(c, run)
+	if test == nil {
+		return
+	}
+
+	imported := models.TestExecution{
+		StartTime:    &run.Start,
+		Duration:     run.Duration,
+		Labels:       run.Labels,
+		StartedBy:    c.GetString(contextUserIDKey),
+		ImportedFrom: run.Tool,
+	}
+	execution, err := s.orchestrator.ImportExecution(*test, imported, run.Points)
+	if err != nil {
+		s.logger.Error("Failed to import execution", zap.String("tool", run.Tool), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to import execution")
+		return
+	}
+
+	c.JSON(http.StatusCreated, execution)
+}
+
+// importTest returns the test an imported run is stored under: the one given by
+// test_id, or the project's test named by the test query parameter, created if
+// there is none. It responds with the error and returns nil if there is none
+// to use.
+func (s *Server) importTest(c *gin.Context, run *importer.Run) *models.TestConfiguration {
+	projectID := currentProjectID(c)
+	if id := c.Query("test_id"); id != "" {
+		test, err := s.repo.GetTestConfiguration(id)
+		if errors.Is(err, database.ErrNotFound) || (err == nil && test.ProjectID != projectID) {
+			respondError(c, http.StatusNotFound, "Test not found")
+			return nil
+		}
+		if err != nil {
+			s.logger.Error("Failed to get test", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get test")
+			return nil
+		}
+		return test
+	}
+
+	name := c.DefaultQuery("test", run.Tool+" results")
+	tests, err := s.repo.ListTestConfigurationsFiltered(database.TestFilter{ProjectID: projectID})
+	if err != nil {
+		s.logger.Error("Failed to list tests", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list tests")
+		return nil
+	}
+	for i := range tests {
+		if tests[i].Name == name {
+			return &tests[i]
+		}
+	}
+
+	if run.Plugin == "" {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("No plugin runs a workload like these %s results; import them into an existing test with test_id", run.Tool))
+		return nil
+	}
+	now := time.Now()
+	test := models.TestConfiguration{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Description: "Results imported from " + run.Tool,
+		Plugin:      run.Plugin,
+		Config:      json.RawMessage(`{}`),
+		Duration:    run.Duration,
+		ProjectID:   projectID,
+		CreatedBy:   c.GetString(contextUserIDKey),
+		Created:     now,
+		Updated:     now,
+	}
+	if err := s.repo.CreateTestConfiguration(&test); err != nil {
+		s.logger.Error("Failed to create test", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to create test")
+		return nil
+	}
+	return &test
+}
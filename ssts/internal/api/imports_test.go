@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+const sysbenchCPUOutput = `sysbench 1.0.20 (using system LuaJIT 2.1.0-beta3)
+
+Number of threads: 2
+
+CPU speed:
+    events per second:  2000.50
+
+General statistics:
+    total time:                          10.0003s
+    total number of events:              20005
+
+Latency (ms):
+         min:                                    0.95
+         avg:                                    1.00
+         max:                                    2.10
+         95th percentile:                        1.02
+`
+
+// importRequest posts a result file to be imported
+func (s *Server) importRequest(query, results string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/executions/import"+query, strings.NewReader(results))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	s.engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImportExecution(t *testing.T) {
+	server, repo := newTestServer(t)
+
+	rec := server.importRequest("?start=2023-05-01T12:00:00Z", sysbenchCPUOutput)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("import: status %d: %s", rec.Code, rec.Body)
+	}
+	var imported models.TestExecution
+	if err := json.Unmarshal(rec.Body.Bytes(), &imported); err != nil {
+		t.Fatal(err)
+	}
+	if imported.ImportedFrom != "sysbench" || imported.Status != models.StatusCompleted || imported.Labels["sysbench_test"] != "cpu" {
+		t.Errorf("imported execution = %+v", imported)
+	}
+	if imported.StartTime == nil || imported.StartTime.Format("2006-01-02T15:04:05Z") != "2023-05-01T12:00:00Z" {
+		t.Errorf("imported execution started at %v, want the start given", imported.StartTime)
+	}
+
+	// The run is stored under a test created for the tool's results
+	tests, _ := repo.ListTestConfigurationsFiltered(database.TestFilter{})
+	if len(tests) != 1 || tests[0].Name != "sysbench results" || tests[0].Plugin != "cpu-stress" || tests[0].ID != imported.TestID {
+		t.Fatalf("tests = %+v", tests)
+	}
+	if stored, err := repo.GetTestExecution(imported.ID); err != nil || stored.ImportedFrom != "sysbench" {
+		t.Errorf("imported execution not stored: %+v, %v", stored, err)
+	}
+	if rec := server.serve(http.MethodGet, "/api/v1/executions/"+imported.ID, nil); rec.Code != http.StatusOK {
+		t.Errorf("get imported execution: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// Later imports of the tool's results go to the same test
+	if rec := server.importRequest("?format=sysbench", sysbenchCPUOutput); rec.Code != http.StatusCreated {
+		t.Fatalf("second import: status %d: %s", rec.Code, rec.Body)
+	}
+	if tests, _ := repo.ListTestConfigurationsFiltered(database.TestFilter{}); len(tests) != 1 {
+		t.Errorf("second import created another test: %+v", tests)
+	}
+}
+
+func TestImportExecutionInvalid(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		name    string
+		query   string
+		results string
+		want    int
+	}{
+		{"unrecognized results", "", "hello", http.StatusBadRequest},
+		{"wrong format", "?format=fio", sysbenchCPUOutput, http.StatusBadRequest},
+		{"invalid start", "?start=yesterday", sysbenchCPUOutput, http.StatusBadRequest},
+		{"unknown test", "?test_id=missing", sysbenchCPUOutput, http.StatusNotFound},
+		{"no plugin for the workload", "", strings.Replace(sysbenchCPUOutput, "CPU speed:", "SQL statistics:", 1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := server.importRequest(tt.query, tt.results); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
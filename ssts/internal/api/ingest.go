@@ -0,0 +1,287 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/agentupdate"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/metrics"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// contextAgentKey is set by the agent auth middleware to the authenticated agent token
+const contextAgentKey = "agent"
+
+// Tags set on every ingested point, overriding any the agent sent
+const (
+	ingestAgentIDTag   = "agent_id"
+	ingestAgentNameTag = "agent"
+)
+
+// CreateAgentTokenRequest is the body for creating an agent token
+type CreateAgentTokenRequest struct {
+	Name      string `json:"name" binding:"required"`
+	ProjectID string `json:"project_id"` // defaults to the default project
+}
+
+// CreateAgentTokenResponse returns a new agent token. Token is only ever shown here.
+type CreateAgentTokenResponse struct {
+	models.AgentToken
+	Token string `json:"token"`
+}
+
+// IngestMetricsRequest is a batch of metric points pushed by an agent
+type IngestMetricsRequest struct {
+	Points []models.MetricPoint `json:"points" binding:"required"`
+}
+
+// IngestMetricsResponse reports what happened to each point of a batch
+type IngestMetricsResponse struct {
+	Accepted   int               `json:"accepted"`
+	Duplicates int               `json:"duplicates"` // already ingested within the dedup window, e.g. from a retried batch
+	Rejected   []IngestRejection `json:"rejected,omitempty"`
+}
+
+// IngestRejection is a point that failed validation, by its index in the batch
+type IngestRejection struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// hashAgentToken returns the stored form of an agent token
+func hashAgentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// agentAuthMiddleware authenticates requests with an agent token given as a
+// bearer token, rejecting unknown and revoked tokens
+func (s *Server) agentAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, models.AgentTokenPrefix) {
+			abortWithError(c, http.StatusUnauthorized, "Agent token required")
+			return
+		}
+
+		agent, err := s.repo.GetAgentTokenByHash(hashAgentToken(token))
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				abortWithError(c, http.StatusUnauthorized, "Invalid agent token")
+			} else {
+				s.logger.Error("Failed to get agent token", zap.Error(err))
+				abortWithError(c, http.StatusInternalServerError, "Failed to check agent token")
+			}
+			return
+		}
+		if err := s.repo.TouchAgentToken(agent.ID, time.Now()); err != nil {
+			s.logger.Warn("Failed to record agent token use", zap.String("agent_id", agent.ID), zap.Error(err))
+		}
+
+		c.Set(contextAgentKey, agent)
+		c.Next()
+	}
+}
+
+// @Summary Ingest metrics
+// @Description Push a batch of metric points from a remote agent or sidecar into the metric store and WebSocket stream. Authenticate with an agent token as a bearer token. Points are tagged with the agent's ID and name; points naming a test must name one in the agent's project. Invalid points are rejected individually, and points already ingested within the dedup window are dropped, so failed batches can be retried safely. Agents whose last handshake negotiated a protocol version the server does not accept are refused with 426 until they update.
+// @Tags ingest
+// @Accept json
+// @Produce json
+// @Param points body IngestMetricsRequest true "Metric points"
+// @Success 200 {object} IngestMetricsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 426 {object} ErrorResponse
+// @Router /api/v1/ingest/metrics [post]
+func (s *Server) ingestMetrics(c *gin.Context) {
+	agent := c.MustGet(contextAgentKey).(*models.AgentToken)
+
+	// Agents negotiate their protocol version in a handshake; those that never
+	// did predate it and are accepted unless a handshake is required
+	if agent.ProtocolVersion != 0 && !agentupdate.Compatible(agent.ProtocolVersion) {
+		respondErrorCode(c, http.StatusUpgradeRequired, CodeAgentUpgrade,
+			fmt.Sprintf("Agent protocol version %d is not accepted by this server; update the agent", agent.ProtocolVersion), nil)
+		return
+	}
+	if agent.ProtocolVersion == 0 && s.config.Ingest.RequireHandshake {
+		respondErrorCode(c, http.StatusUpgradeRequired, CodeAgentUpgrade,
+			"Agent must complete a version handshake before pushing metrics", nil)
+		return
+	}
+
+	var request IngestMetricsRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+	if len(request.Points) > s.config.Ingest.MaxBatchSize {
+		respondError(c, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Batch has %d points; at most %d are accepted per request", len(request.Points), s.config.Ingest.MaxBatchSize))
+		return
+	}
+
+	limits := metrics.IngestLimits{
+		MaxClockSkew: s.config.Ingest.MaxClockSkew,
+		MaxAge:       s.config.Ingest.MaxAge,
+	}
+	testInProject := make(map[string]bool)
+	now := time.Now()
+
+	response := IngestMetricsResponse{}
+	accepted := make([]models.MetricPoint, 0, len(request.Points))
+	for i, point := range request.Points {
+		if err := metrics.ValidateIngestedPoint(point, now, limits); err != nil {
+			response.Rejected = append(response.Rejected, IngestRejection{Index: i, Error: err.Error()})
+			continue
+		}
+
+		if point.TestID != "" {
+			inProject, checked := testInProject[point.TestID]
+			if !checked {
+				test, err := s.repo.GetTestConfiguration(point.TestID)
+				if err != nil && !errors.Is(err, database.ErrNotFound) {
+					s.logger.Error("Failed to get test configuration", zap.Error(err))
+					respondError(c, http.StatusInternalServerError, "Failed to check test")
+					return
+				}
+				inProject = err == nil && test.ProjectID == agent.ProjectID
+				testInProject[point.TestID] = inProject
+			}
+			if !inProject {
+				response.Rejected = append(response.Rejected, IngestRejection{Index: i, Error: "test not found: " + point.TestID})
+				continue
+			}
+		}
+
+		tags := make(map[string]string, len(point.Tags)+2)
+		for key, value := range point.Tags {
+			tags[key] = value
+		}
+		tags[ingestAgentIDTag] = agent.ID
+		tags[ingestAgentNameTag] = agent.Name
+		point.Tags = tags
+
+		if s.ingestDedup.Seen(point, now) {
+			response.Duplicates++
+			continue
+		}
+		accepted = append(accepted, point)
+	}
+
+	s.fleet.record(agent.ID, accepted, now)
+	if failed := s.orchestrator.IngestMetrics(accepted); failed > 0 {
+		s.logger.Warn("Failed to write some ingested metrics",
+			zap.String("agent_id", agent.ID), zap.Int("sink_errors", failed))
+	}
+	response.Accepted = len(accepted)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary List agent tokens
+// @Description Get the tokens remote agents use to push metrics, revoked ones included. Token values are never returned.
+// @Tags ingest
+// @Produce json
+// @Success 200 {array} models.AgentToken
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/agents [get]
+func (s *Server) listAgentTokens(c *gin.Context) {
+	tokens, err := s.repo.ListAgentTokens()
+	if err != nil {
+		s.logger.Error("Failed to list agent tokens", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list agent tokens")
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary Create agent token
+// @Description Create a token for a remote agent to push metrics with. Points the agent pushes belong to the given project. The token is only returned by this call.
+// @Tags ingest
+// @Accept json
+// @Produce json
+// @Param token body CreateAgentTokenRequest true "Agent"
+// @Success 201 {object} CreateAgentTokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/agents [post]
+func (s *Server) createAgentToken(c *gin.Context) {
+	var request CreateAgentTokenRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	var project *models.Project
+	var err error
+	if request.ProjectID != "" {
+		project, err = s.repo.GetProject(request.ProjectID)
+	} else {
+		project, err = s.repo.GetProjectByName(models.DefaultProjectName)
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Project not found")
+		} else {
+			s.logger.Error("Failed to resolve project", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to resolve project")
+		}
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		s.logger.Error("Failed to generate agent token", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to generate agent token")
+		return
+	}
+	token := models.AgentTokenPrefix + hex.EncodeToString(secret)
+
+	agent := models.AgentToken{
+		Name:      request.Name,
+		ProjectID: project.ID,
+		TokenHash: hashAgentToken(token),
+		CreatedBy: c.GetString(contextUserIDKey),
+	}
+	if err := s.repo.CreateAgentToken(&agent); err != nil {
+		s.logger.Error("Failed to create agent token", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to create agent token")
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAgentTokenResponse{AgentToken: agent, Token: token})
+}
+
+// @Summary Revoke agent token
+// @Description Revoke an agent token. Metrics the agent already pushed are kept.
+// @Tags ingest
+// @Param id path string true "Agent token ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/agents/{id} [delete]
+func (s *Server) revokeAgentToken(c *gin.Context) {
+	if err := s.repo.RevokeAgentToken(c.Param("id")); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Agent token not found")
+		} else {
+			s.logger.Error("Failed to revoke agent token", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to revoke agent token")
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Bounds on metric queries, so a single request can't make the store scan or
+// return an unbounded number of points
+const (
+	maxQueryWindows   = 10000               // windows an aggregated query may split its range into
+	maxQueryRange     = 31 * 24 * time.Hour // longest time range of a query
+	defaultQueryLimit = 1000                // points per series when no limit is given
+	maxQueryLimit     = 10000               // largest limit of points per series
+)
+
+// MetricQueryResponse is the result of a time-series query
+type MetricQueryResponse struct {
+	Series []models.TimeSeries `json:"series"`
+}
+
+// @Summary Query metrics
+// @Description Query the metric store without writing its query language. Points of a measurement are selected by field and tag, and optionally aggregated over fixed windows; each field and tag set is returned as its own series. Queries are scoped to the project: they must filter on test_id or execution_id, and every test and execution named must belong to the project. Instead of start and end, range names a window of the single execution or test filtered on: last_run is the whole execution, or a test's latest one, and steady_state leaves out its ramp-up, ramp-down and cooldown.
+// @Tags metrics
+// @Produce json
+// @Param measurement query string true "Measurement, e.g. system_cpu"
+// @Param field query []string false "Fields to return, all when omitted" collectionFormat(multi)
+// @Param tag query []string false "Tag filter key=value; repeat a key to match any of its values" collectionFormat(multi)
+// @Param start query string false "Start time (RFC3339), defaults to an hour before end for aggregated queries; required for raw queries"
+// @Param end query string false "End time (RFC3339), defaults to now. The range may span at most 31 days."
+// @Param range query string false "Named range instead of start and end: last_run or steady_state"
+// @Param window query string false "Aggregation window, e.g. 1m; raw points when omitted"
+// @Param fn query string false "Aggregation function: mean (default), min, max, sum, count, first, last or median"
+// @Param limit query int false "Points per series, at most 10000" default(1000)
+// @Success 200 {object} MetricQueryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "The execution has no steady state"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/metrics/query [get]
+func (s *Server) queryMetrics(c *gin.Context) {
+	query, err := metricQuery(c.Request.URL.Query(), time.Now())
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.checkMetricQueryScope(c, query) {
+		return
+	}
+	if name := c.Query("range"); name != "" {
+		timeRange, ok := s.metricQueryRange(c, query, name)
+		if !ok {
+			return
+		}
+		query.Start, query.End = timeRange.Start, timeRange.End
+		if err := checkQueryRange(query); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	series, err := s.timeSeries.QueryTimeSeries(c.Request.Context(), query)
+	if err != nil {
+		s.logger.Error("Failed to query metrics", zap.String("measurement", query.Measurement), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to query metrics")
+		return
+	}
+	if series == nil {
+		series = []models.TimeSeries{}
+	}
+
+	c.JSON(http.StatusOK, MetricQueryResponse{Series: series})
+}
+
+// checkMetricQueryScope verifies a metric query only selects points of tests and
+// executions in the current project, responding with an error and returning
+// false if not. Points carry the test or execution they were collected for as a
+// tag, so a query without one of those tags could read any project's metrics.
+func (s *Server) checkMetricQueryScope(c *gin.Context, query models.TimeSeriesQuery) bool {
+	testIDs, executionIDs := query.Tags["test_id"], query.Tags["execution_id"]
+	if len(testIDs) == 0 && len(executionIDs) == 0 {
+		respondError(c, http.StatusBadRequest, "A test_id or execution_id tag is required")
+		return false
+	}
+
+	projectID := currentProjectID(c)
+	for _, id := range testIDs {
+		test, err := s.repo.GetTestConfiguration(id)
+		if err == nil && test.ProjectID != projectID {
+			err = database.ErrNotFound
+		}
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				respondError(c, http.StatusNotFound, "Test not found: "+id)
+			} else {
+				s.logger.Error("Failed to get test", zap.String("test_id", id), zap.Error(err))
+				respondError(c, http.StatusInternalServerError, "Failed to get test")
+			}
+			return false
+		}
+	}
+	for _, id := range executionIDs {
+		execution, err := s.windowExecution(id)
+		if err != nil || execution.ProjectID != projectID {
+			respondError(c, http.StatusNotFound, "Execution not found: "+id)
+			return false
+		}
+	}
+	return true
+}
+
+// metricQueryRange resolves the named range of a metric query against the one
+// execution or test it filters on, responding with an error and returning false
+// if it can't be resolved
+func (s *Server) metricQueryRange(c *gin.Context, query models.TimeSeriesQuery, name string) (models.TimeRange, bool) {
+	testIDs, executionIDs := query.Tags["test_id"], query.Tags["execution_id"]
+	switch {
+	case len(executionIDs) == 1:
+		execution, err := s.windowExecution(executionIDs[0])
+		if err != nil {
+			respondError(c, http.StatusNotFound, "Execution not found: "+executionIDs[0])
+			return models.TimeRange{}, false
+		}
+		timeRange, err := s.orchestrator.ExecutionWindow(*execution, name)
+		if err != nil {
+			s.respondWindowError(c, err)
+			return models.TimeRange{}, false
+		}
+		return timeRange, true
+
+	case len(executionIDs) == 0 && len(testIDs) == 1:
+		timeRange, _, err := s.orchestrator.TestWindow(testIDs[0], name)
+		if err != nil {
+			s.respondWindowError(c, err)
+			return models.TimeRange{}, false
+		}
+		return timeRange, true
+
+	default:
+		respondError(c, http.StatusBadRequest, "range requires a single execution_id or test_id tag")
+		return models.TimeRange{}, false
+	}
+}
+
+// metricQuery parses and validates the query parameters of a metric query
+func metricQuery(values url.Values, now time.Time) (models.TimeSeriesQuery, error) {
+	query := models.TimeSeriesQuery{
+		Measurement: values.Get("measurement"),
+		Fields:      values["field"],
+		End:         now,
+		Limit:       parseIntValue(values, "limit", defaultQueryLimit),
+	}
+	if query.Measurement == "" {
+		return query, fmt.Errorf("measurement is required")
+	}
+	if query.Limit <= 0 || query.Limit > maxQueryLimit {
+		return query, fmt.Errorf("limit must be between 1 and %d", maxQueryLimit)
+	}
+
+	for _, tag := range values["tag"] {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || key == "" {
+			return query, fmt.Errorf("invalid tag %q, expected key=value", tag)
+		}
+		if query.Tags == nil {
+			query.Tags = make(map[string][]string)
+		}
+		query.Tags[key] = append(query.Tags[key], value)
+	}
+
+	// A named range is resolved once the query's scope is checked
+	named := values.Get("range")
+	if named != "" {
+		if values.Get("start") != "" || values.Get("end") != "" {
+			return query, fmt.Errorf("range can't be combined with start or end")
+		}
+		if named != core.WindowLastRun && named != core.WindowSteadyState {
+			return query, fmt.Errorf("unsupported range %q, want %s or %s", named, core.WindowLastRun, core.WindowSteadyState)
+		}
+	}
+
+	end, err := parseTimeValue(values, "end")
+	if err != nil {
+		return query, err
+	}
+	if end != nil {
+		query.End = *end
+	}
+	query.Start = query.End.Add(-time.Hour)
+	start, err := parseTimeValue(values, "start")
+	if err != nil {
+		return query, err
+	}
+	if start != nil {
+		query.Start = *start
+	}
+
+	window := values.Get("window")
+	if window == "" && start == nil && named == "" {
+		return query, fmt.Errorf("start is required for queries without a window")
+	}
+	if window != "" {
+		query.Window, err = time.ParseDuration(window)
+		if err != nil || query.Window <= 0 {
+			return query, fmt.Errorf("invalid window %q, expected a positive duration such as 1m", window)
+		}
+		query.Function = models.AggregateMean
+	}
+	if named == "" {
+		if err := checkQueryRange(query); err != nil {
+			return query, err
+		}
+	}
+
+	if fn := values.Get("fn"); fn != "" {
+		if query.Window == 0 {
+			return query, fmt.Errorf("fn requires a window")
+		}
+		if !models.ValidAggregateFunction(fn) {
+			return query, fmt.Errorf("unsupported fn %q, want one of %s", fn, strings.Join(models.AggregateFunctions, ", "))
+		}
+		query.Function = fn
+	}
+
+	return query, nil
+}
+
+// checkQueryRange checks the time range of a metric query isn't empty or too
+// long and isn't split into too many windows
+func checkQueryRange(query models.TimeSeriesQuery) error {
+	if !query.Start.Before(query.End) {
+		return fmt.Errorf("start must be before end")
+	}
+	if query.End.Sub(query.Start) > maxQueryRange {
+		return fmt.Errorf("the range from start to end must not exceed %s", maxQueryRange)
+	}
+	if query.Window > 0 && query.End.Sub(query.Start)/query.Window > maxQueryWindows {
+		return fmt.Errorf("window %s splits the range into more than %d windows", query.Window, maxQueryWindows)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// fakeTimeSeries records the last query and answers it with fixed series
+type fakeTimeSeries struct {
+	query  models.TimeSeriesQuery
+	series []models.TimeSeries
+}
+
+func (f *fakeTimeSeries) QueryTimeSeries(ctx context.Context, query models.TimeSeriesQuery) ([]models.TimeSeries, error) {
+	f.query = query
+	return f.series, nil
+}
+
+func TestQueryMetrics(t *testing.T) {
+	server, repo := newTestServer(t)
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t1", Name: "burn", Plugin: "cpu-stress"})
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t2", Name: "soak", Plugin: "cpu-stress"})
+	at := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	store := &fakeTimeSeries{series: []models.TimeSeries{{
+		Measurement: "system_cpu",
+		Field:       "usage",
+		Tags:        map[string]string{"test_id": "t1"},
+		Points:      []models.TimeSeriesPoint{{Time: at, Value: 42.5}},
+	}}}
+	server.timeSeries = store
+
+	rec := server.serve(http.MethodGet, "/api/v1/metrics/query?measurement=system_cpu&field=usage&tag=test_id=t1&tag=test_id=t2&start=2024-01-02T03:00:00Z&end=2024-01-02T04:00:00Z&window=1m&fn=max", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("query: status %d: %s", rec.Code, rec.Body)
+	}
+	var resp MetricQueryResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Series) != 1 || resp.Series[0].Points[0].Value != 42.5 {
+		t.Errorf("series = %+v", resp.Series)
+	}
+
+	query := store.query
+	if query.Measurement != "system_cpu" || len(query.Fields) != 1 || len(query.Tags["test_id"]) != 2 ||
+		query.Window != time.Minute || query.Function != models.AggregateMax || query.End.Sub(query.Start) != time.Hour {
+		t.Errorf("store got query %+v", query)
+	}
+}
+
+func TestQueryMetricsScopedToProject(t *testing.T) {
+	server, repo := newTestServer(t)
+	server.timeSeries = &fakeTimeSeries{}
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t1", Name: "burn", Plugin: "cpu-stress"})
+	other := &models.Project{Name: "other"}
+	if err := repo.CreateProject(other); err != nil {
+		t.Fatal(err)
+	}
+	repo.CreateTestConfiguration(&models.TestConfiguration{ID: "t9", Name: "theirs", Plugin: "cpu-stress", ProjectID: other.ID})
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"own test", "tag=test_id=t1", http.StatusOK},
+		{"no test or execution", "", http.StatusBadRequest},
+		{"other tag only", "tag=host=h1", http.StatusBadRequest},
+		{"other project's test", "tag=test_id=t9", http.StatusNotFound},
+		{"own and other project's test", "tag=test_id=t1&tag=test_id=t9", http.StatusNotFound},
+		{"unknown test", "tag=test_id=nope", http.StatusNotFound},
+		{"unknown execution", "tag=test_id=t1&tag=execution_id=nope", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := server.serve(http.MethodGet, "/api/v1/metrics/query?measurement=system_cpu&window=1m&"+tt.query, nil); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	// The other project's tests can be queried within it
+	if rec := server.serve(http.MethodGet, "/api/v1/metrics/query?measurement=system_cpu&window=1m&tag=test_id=t9&project_id="+other.ID, nil); rec.Code != http.StatusOK {
+		t.Errorf("query in the test's project: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestMetricQueryValidation(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+
+	query, err := metricQuery(url.Values{"measurement": {"system_cpu"}, "window": {"30s"}}, now)
+	if err != nil {
+		t.Fatalf("metricQuery: %v", err)
+	}
+	if query.End != now || query.Start != now.Add(-time.Hour) || query.Function != models.AggregateMean || query.Limit != defaultQueryLimit {
+		t.Errorf("defaults = %+v, want the last hour averaged", query)
+	}
+
+	query, err = metricQuery(url.Values{"measurement": {"m"}, "start": {"2024-01-01T00:00:00Z"}, "limit": {"10000"}}, now)
+	if err != nil {
+		t.Fatalf("raw query with a range: %v", err)
+	}
+	if query.Window != 0 || query.Limit != maxQueryLimit {
+		t.Errorf("raw query = %+v", query)
+	}
+
+	for name, values := range map[string]url.Values{
+		"no measurement":    {"window": {"1m"}},
+		"bad tag":           {"measurement": {"m"}, "tag": {"host"}},
+		"bad window":        {"measurement": {"m"}, "window": {"soon"}},
+		"too many windows":  {"measurement": {"m"}, "window": {"100ms"}},
+		"fn without window": {"measurement": {"m"}, "fn": {"max"}},
+		"unknown fn":        {"measurement": {"m"}, "window": {"1m"}, "fn": {"p99"}},
+		"start after end":   {"measurement": {"m"}, "start": {"2024-01-02T05:00:00Z"}},
+		"raw without start": {"measurement": {"m"}, "end": {"2024-01-02T03:00:00Z"}},
+		"range too long":    {"measurement": {"m"}, "window": {"1h"}, "start": {"2023-11-01T00:00:00Z"}},
+		"negative limit":    {"measurement": {"m"}, "window": {"1m"}, "limit": {"-1"}},
+		"zero limit":        {"measurement": {"m"}, "window": {"1m"}, "limit": {"0"}},
+		"limit too large":   {"measurement": {"m"}, "window": {"1m"}, "limit": {"10001"}},
+		"range and end":     {"measurement": {"m"}, "range": {"last_run"}, "end": {"2024-01-02T03:00:00Z"}},
+		"unknown range":     {"measurement": {"m"}, "range": {"today"}},
+	} {
+		if _, err := metricQuery(values, now); err == nil {
+			t.Errorf("%s: metricQuery accepted %v", name, values)
+		}
+	}
+}
+
+func TestQueryMetricsNamedRange(t *testing.T) {
+	server, repo := newTestServer(t)
+	project, _ := repo.GetProjectByName(models.DefaultProjectName)
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t1", Name: "burn", Plugin: "cpu-stress"})
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t2", Name: "idle", Plugin: "cpu-stress"})
+	store := &fakeTimeSeries{}
+	server.timeSeries = store
+
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Minute)
+	steady := models.TimeRange{Start: start.Add(time.Minute), End: start.Add(8 * time.Minute)}
+	summary, _ := json.Marshal(models.ExecutionSummary{SteadyState: &steady})
+	earlier := start.Add(-time.Hour)
+	previous := models.TestExecution{TestID: "t1", ProjectID: project.ID, Status: models.StatusCompleted, StartTime: &earlier, EndTime: &start}
+	repo.CreateTestExecution(&previous)
+	latest := models.TestExecution{TestID: "t1", ProjectID: project.ID, Status: models.StatusCompleted, StartTime: &start, EndTime: &end, Summary: summary}
+	repo.CreateTestExecution(&latest)
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+		from  time.Time
+		to    time.Time
+	}{
+		{"latest run of a test", "tag=test_id=t1&range=last_run", http.StatusOK, start, end},
+		{"steady state of the latest run", "tag=test_id=t1&range=steady_state", http.StatusOK, steady.Start, steady.End},
+		{"steady state of an execution", "tag=execution_id=" + latest.ID + "&range=steady_state", http.StatusOK, steady.Start, steady.End},
+		{"execution without steady state", "tag=execution_id=" + previous.ID + "&range=steady_state", http.StatusConflict, time.Time{}, time.Time{}},
+		{"test never run", "tag=test_id=t2&range=last_run", http.StatusNotFound, time.Time{}, time.Time{}},
+		{"several tests", "tag=test_id=t1&tag=test_id=t2&range=last_run", http.StatusBadRequest, time.Time{}, time.Time{}},
+		{"range with start", "tag=test_id=t1&range=last_run&start=2024-01-02T03:00:00Z", http.StatusBadRequest, time.Time{}, time.Time{}},
+		{"unknown range", "tag=test_id=t1&range=yesterday", http.StatusBadRequest, time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		rec := server.serve(http.MethodGet, "/api/v1/metrics/query?measurement=system_cpu&window=10s&"+tt.query, nil)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if tt.want == http.StatusOK && (!store.query.Start.Equal(tt.from) || !store.query.End.Equal(tt.to)) {
+			t.Errorf("%s: queried %s to %s, want %s to %s", tt.name, store.query.Start, store.query.End, tt.from, tt.to)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// streamBroadcastInterval is how often streamed plugin metrics are flushed to WebSocket clients
+const streamBroadcastInterval = 250 * time.Millisecond
+
+// maxStreamBatch bounds how many points are held per test between flushes; older points are dropped
+const maxStreamBatch = 1000
+
+// wsMetricSink batches points streamed by plugins and broadcasts them to WebSocket
+// clients a few times a second, so per-operation metrics don't flood the hub. The
+// buffers points are batched in are recycled once broadcast, so a steady stream
+// of points doesn't allocate new batches every flush.
+type wsMetricSink struct {
+	hub     *WebSocketHub
+	mu      sync.Mutex
+	pending map[string][]models.MetricPoint // keyed by test ID
+	spare   map[string][]models.MetricPoint // emptied map pending is swapped with on flush
+	batches sync.Pool                       // broadcast batch buffers, as *[]models.MetricPoint
+}
+
+// newWSMetricSink creates a sink and starts its flush loop
+func newWSMetricSink(hub *WebSocketHub) *wsMetricSink {
+	sink := &wsMetricSink{
+		hub:     hub,
+		pending: make(map[string][]models.MetricPoint),
+	}
+	go sink.run()
+	return sink
+}
+
+// WriteMetricPoint queues a point for the next broadcast
+func (w *wsMetricSink) WriteMetricPoint(point models.MetricPoint) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	batch, ok := w.pending[point.TestID]
+	if !ok {
+		batch = w.newBatch()
+	}
+	batch = append(batch, point)
+	if len(batch) > maxStreamBatch {
+		batch = batch[len(batch)-maxStreamBatch:]
+	}
+	w.pending[point.TestID] = batch
+	return nil
+}
+
+// newBatch returns an empty batch buffer, one recycled from an earlier flush if
+// there is one
+func (w *wsMetricSink) newBatch() []models.MetricPoint {
+	if buffer, ok := w.batches.Get().(*[]models.MetricPoint); ok {
+		return *buffer
+	}
+	return make([]models.MetricPoint, 0, 64)
+}
+
+func (w *wsMetricSink) run() {
+	ticker := time.NewTicker(streamBroadcastInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		w.flush()
+	}
+}
+
+// flush broadcasts the pending batches and recycles their buffers
+func (w *wsMetricSink) flush() {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = w.spare
+	if w.pending == nil {
+		w.pending = make(map[string][]models.MetricPoint, len(pending))
+	}
+	w.spare = nil
+	w.mu.Unlock()
+
+	for testID, points := range pending {
+		// Broadcasting encodes the points, so their buffer is free afterwards
+		w.hub.BroadcastMetrics(testID, points)
+		for i := range points {
+			points[i] = models.MetricPoint{}
+		}
+		buffer := points[:0]
+		w.batches.Put(&buffer)
+		delete(pending, testID)
+	}
+
+	w.mu.Lock()
+	w.spare = pending
+	w.mu.Unlock()
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestWSMetricSinkBatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		written map[string]int // points written per test
+		want    map[string]int // points pending per test
+	}{
+		{"per test", map[string]int{"t1": 3, "t2": 1}, map[string]int{"t1": 3, "t2": 1}},
+		{"capped", map[string]int{"t1": maxStreamBatch + 10}, map[string]int{"t1": maxStreamBatch}},
+	}
+	for _, tt := range tests {
+		sink := &wsMetricSink{pending: make(map[string][]models.MetricPoint)}
+		for testID, n := range tt.written {
+			for i := 0; i < n; i++ {
+				sink.WriteMetricPoint(models.MetricPoint{TestID: testID, Fields: map[string]interface{}{"n": i}})
+			}
+		}
+		for testID, want := range tt.want {
+			batch := sink.pending[testID]
+			if len(batch) != want {
+				t.Errorf("%s: %d points pending for %s, want %d", tt.name, len(batch), testID, want)
+				continue
+			}
+			// The oldest points are the ones dropped
+			if last := batch[len(batch)-1].Fields["n"]; last != tt.written[testID]-1 {
+				t.Errorf("%s: newest pending point is %v", tt.name, last)
+			}
+		}
+	}
+}
+
+func TestWSMetricSinkFlush(t *testing.T) {
+	hub := NewWebSocketHub(config.WebSocketConfig{ReplayBufferSize: 10, ReplayMaxAge: time.Minute})
+	sink := &wsMetricSink{hub: hub, pending: make(map[string][]models.MetricPoint)}
+
+	// Batches are broadcast and their buffers reused across flushes
+	for flush := 1; flush <= 2; flush++ {
+		for i := 0; i < 3; i++ {
+			sink.WriteMetricPoint(models.MetricPoint{TestID: "t1", Fields: map[string]interface{}{"n": i}})
+		}
+		sink.flush()
+		if len(sink.pending) != 0 {
+			t.Errorf("flush %d: %d batches still pending", flush, len(sink.pending))
+		}
+
+		messages := hub.replay.Get("metrics_update", func(string) bool { return true }, time.Time{}, 0)
+		if len(messages) != flush {
+			t.Fatalf("flush %d: %d messages broadcast", flush, len(messages))
+		}
+		var message struct {
+			Data struct {
+				Metrics []models.MetricPoint `json:"metrics"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(messages[flush-1], &message); err != nil || len(message.Data.Metrics) != 3 {
+			t.Errorf("flush %d: broadcast %s, %v", flush, messages[flush-1], err)
+		}
+	}
+}
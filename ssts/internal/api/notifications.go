@@ -0,0 +1,148 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/notify"
+	"github.com/pranavgopavaram/ssts/internal/secrets"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// currentUser loads the caller's user record. It responds with an error and
+// returns nil if there is none.
+func (s *Server) currentUser(c *gin.Context) *models.User {
+	userID := c.GetString(contextUserIDKey)
+	if userID == "" {
+		respondError(c, http.StatusUnauthorized, "Authentication required")
+		return nil
+	}
+
+	user, err := s.repo.GetUser(userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "User not found")
+		} else {
+			s.logger.Error("Failed to get user", zap.String("user_id", userID), zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get user")
+		}
+		return nil
+	}
+	return user
+}
+
+// @Summary Get notification preferences
+// @Description Get which events the caller is notified about and how. Users who have never set preferences get the defaults, which send nothing. Slack and webhook URLs are redacted beyond their host.
+// @Tags users
+// @Produce json
+// @Success 200 {object} models.NotificationPreferences
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/notifications [get]
+func (s *Server) getNotificationPreferences(c *gin.Context) {
+	user := s.currentUser(c)
+	if user == nil {
+		return
+	}
+
+	prefs, err := user.NotificationPreferences()
+	if err != nil {
+		s.logger.Error("Failed to read notification preferences", zap.String("user_id", user.ID), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to read notification preferences")
+		return
+	}
+
+	c.JSON(http.StatusOK, secrets.RedactNotificationPreferences(prefs))
+}
+
+// @Summary Set notification preferences
+// @Description Choose which events the caller is notified about: alerts and finished executions, about their own tests' executions or all of them, of which severities, by email, Slack, webhook, browser or web push. Browser notifications arrive as notification messages on the caller's open WebSocket connections, each of which can narrow them further with a notifications message; web push takes the browser's push subscription, subscribed with the key from GET /users/notifications/web-push. Only notifications about projects the caller is a member of are sent. Slack and webhook URLs must be https and not name a loopback, link-local or private host. A redacted URL, as returned by GET, keeps the stored one. Other preferences are kept.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param preferences body models.NotificationPreferences true "Notification preferences"
+// @Success 200 {object} models.NotificationPreferences
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/notifications [put]
+func (s *Server) updateNotificationPreferences(c *gin.Context) {
+	prefs := models.DefaultNotificationPreferences()
+	if !bindJSON(c, &prefs) {
+		return
+	}
+	if err := prefs.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if fields := secrets.EncryptedPreferenceFields(prefs); len(fields) > 0 {
+		respondError(c, http.StatusBadRequest, strings.Join(fields, ", ")+": must not be an encrypted value")
+		return
+	}
+	for _, channel := range prefs.Channels {
+		if channel == models.ChannelEmail && s.config.Notifications.Email.SMTPHost == "" {
+			respondError(c, http.StatusBadRequest, "Email notifications aren't configured on this server")
+			return
+		}
+		if channel == models.ChannelWebPush && s.config.Notifications.WebPush.VAPIDPrivateKey == "" {
+			respondError(c, http.StatusBadRequest, "Web push notifications aren't configured on this server")
+			return
+		}
+	}
+
+	user := s.currentUser(c)
+	if user == nil {
+		return
+	}
+
+	// Keep the webhook URLs a client sent back redacted
+	if stored, err := user.NotificationPreferences(); err == nil {
+		prefs = secrets.RestoreNotificationPreferences(prefs, stored)
+	}
+	if err := user.SetNotificationPreferences(prefs); err != nil {
+		s.logger.Error("Failed to set notification preferences", zap.String("user_id", user.ID), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to set notification preferences")
+		return
+	}
+	if err := s.repo.UpdateUser(user); err != nil {
+		s.logger.Error("Failed to save notification preferences", zap.String("user_id", user.ID), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to save notification preferences")
+		return
+	}
+
+	c.JSON(http.StatusOK, secrets.RedactNotificationPreferences(prefs))
+}
+
+// WebPushKey is the key browsers subscribe to push notifications with
+type WebPushKey struct {
+	PublicKey string `json:"public_key"` // base64url; pass as applicationServerKey to PushManager.subscribe
+}
+
+// @Summary Get the web push key
+// @Description Get the server's VAPID public key, which browsers subscribe to push notifications with before the subscription is saved in the notification preferences.
+// @Tags users
+// @Produce json
+// @Success 200 {object} WebPushKey
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/users/notifications/web-push [get]
+func (s *Server) getWebPushKey(c *gin.Context) {
+	privateKey := s.config.Notifications.WebPush.VAPIDPrivateKey
+	if privateKey == "" {
+		respondError(c, http.StatusNotFound, "Web push notifications aren't configured on this server")
+		return
+	}
+	publicKey, err := notify.VAPIDPublicKey(privateKey)
+	if err != nil {
+		s.logger.Error("Invalid VAPID key", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Web push is misconfigured")
+		return
+	}
+	c.JSON(http.StatusOK, WebPushKey{PublicKey: publicKey})
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// callAsUser calls a handler directly as a signed-in user
+func callAsUser(handler gin.HandlerFunc, userID, method string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(method, "/api/v1/users/notifications", bytes.NewReader(data))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		c.Set(contextUserIDKey, userID)
+	}
+	handler(c)
+	return rec
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	server, repo := newTestServer(t)
+	user := &models.User{ID: "alice", Username: "alice", Email: "alice@example.com", Preferences: json.RawMessage(`{"theme":"dark"}`)}
+	if err := repo.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+
+	// Defaults until set: nothing is sent
+	rec := callAsUser(server.getNotificationPreferences, "alice", http.MethodGet, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get: status %d: %s", rec.Code, rec.Body)
+	}
+	var prefs models.NotificationPreferences
+	json.Unmarshal(rec.Body.Bytes(), &prefs)
+	if prefs.Enabled || prefs.Scope != models.NotifyScopeOwn {
+		t.Errorf("default preferences = %+v", prefs)
+	}
+
+	rec = callAsUser(server.updateNotificationPreferences, "alice", http.MethodPut, models.NotificationPreferences{
+		Enabled:         true,
+		Scope:           models.NotifyScopeAll,
+		Severities:      []string{"error", "critical"},
+		Channels:        []string{models.ChannelSlack},
+		SlackWebhookURL: "https://hooks.slack.com/services/T000/B000/XXXX",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("put: status %d: %s", rec.Code, rec.Body)
+	}
+
+	stored, _ := repo.GetUser("alice")
+	prefs, err := stored.NotificationPreferences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prefs.Enabled || prefs.Scope != models.NotifyScopeAll || len(prefs.Severities) != 2 {
+		t.Errorf("stored preferences = %+v", prefs)
+	}
+	// Other preferences are kept
+	var all map[string]json.RawMessage
+	json.Unmarshal(stored.Preferences, &all)
+	if string(all["theme"]) != `"dark"` {
+		t.Errorf("preferences = %s, lost the theme", stored.Preferences)
+	}
+}
+
+func TestNotificationPreferencesInvalid(t *testing.T) {
+	server, repo := newTestServer(t)
+	repo.CreateUser(&models.User{ID: "alice", Username: "alice", Email: "alice@example.com"})
+
+	tests := map[string]models.NotificationPreferences{
+		"unknown scope":             {Scope: "team", Channels: []string{models.ChannelWebhook}, WebhookURL: "https://example.com"},
+		"unknown event":             {Scope: models.NotifyScopeOwn, Events: []string{"deploy"}},
+		"unknown severity":          {Scope: models.NotifyScopeOwn, Severities: []string{"fatal"}},
+		"no channels":               {Enabled: true, Scope: models.NotifyScopeOwn, Channels: []string{}},
+		"webhook without url":       {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelWebhook}},
+		"slack not http":            {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelSlack}, SlackWebhookURL: "ftp://example.com"},
+		"webhook over http":         {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelWebhook}, WebhookURL: "http://example.com/hook"},
+		"webhook loopback":          {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelWebhook}, WebhookURL: "https://127.0.0.1:8080/hook"},
+		"webhook localhost":         {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelWebhook}, WebhookURL: "https://LOCALHOST./hook"},
+		"webhook link-local":        {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelWebhook}, WebhookURL: "https://169.254.169.254/latest/meta-data"},
+		"webhook private":           {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelWebhook}, WebhookURL: "https://10.0.0.5/hook"},
+		"slack ipv6 loopback":       {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelSlack}, SlackWebhookURL: "https://[::1]/hook"},
+		"slack mapped private":      {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelSlack}, SlackWebhookURL: "https://[::ffff:192.168.1.1]/hook"},
+		"email without smtp":        {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelEmail}},
+		"push without subscription": {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelWebPush}},
+		"push without vapid key": {Scope: models.NotifyScopeOwn, Channels: []string{models.ChannelWebPush}, WebPush: &models.WebPushSubscription{
+			Endpoint: "https://push.example.com/abc",
+			Keys:     models.WebPushKeys{P256DH: "BNcRdreALRFXTkOOUHK1EtK2wtaz5Ry4YfYCA_0QTpQtUbVlUls0VJXg7A8u-Ts1XbjhazAkj7I99e8QcYP7DkM", Auth: "tBHItJI5svbpez7KI4CCXg"},
+		}},
+	}
+	for name, prefs := range tests {
+		if rec := callAsUser(server.updateNotificationPreferences, "alice", http.MethodPut, prefs); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", name, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := callAsUser(server.getNotificationPreferences, "", http.MethodGet, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous get: status %d, want 401", rec.Code)
+	}
+}
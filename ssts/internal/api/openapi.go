@@ -0,0 +1,26 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// General information of the API's OpenAPI document, generated from the
+// handlers' annotations and the types they name
+//
+// @title SSTS API
+// @version 1.0.0
+// @description Stress testing suite API. Routes are documented under /api/v1; /api/v2 serves the same routes with responses wrapped in an envelope.
+
+//go:generate go run ../openapi/gen -dir . -out openapi.json
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// serveOpenAPISpec serves the API's OpenAPI 3 document, for generating typed
+// clients
+func (s *Server) serveOpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openAPISpec)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/faults"
+	"github.com/pranavgopavaram/ssts/internal/openapi"
+)
+
+func TestOpenAPISpecUpToDate(t *testing.T) {
+	doc, err := openapi.Generate(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := doc.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, openAPISpec) {
+		t.Error("openapi.json is out of date with the handlers' annotations; run go generate ./internal/api")
+	}
+}
+
+func TestOpenAPISpecRoutes(t *testing.T) {
+	server, _ := newAuthTestServer(t, func(cfg *config.Config) {
+		cfg.Ingest.Enabled = true
+		cfg.InfluxDB.Flux.Enabled = true
+		cfg.Server.StatusPage.Enabled = true
+	})
+
+	rec := server.serveAs("", http.MethodGet, "/openapi.json", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get spec: status %d", rec.Code)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != openapi.Version || len(doc.Paths) == 0 {
+		t.Fatalf("spec: openapi %q with %d paths", doc.OpenAPI, len(doc.Paths))
+	}
+
+	// Every documented operation must be served
+	routes := map[string]bool{}
+	for _, route := range server.engine.Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+	param := regexp.MustCompile(`\{(\w+)\}`)
+	for path, operations := range doc.Paths {
+		if strings.HasPrefix(path, apiV1+"/debug/") && !faults.Enabled {
+			continue
+		}
+		for method := range operations {
+			route := param.ReplaceAllString(path, ":$1")
+			if !routes[strings.ToUpper(method)+" "+route] && !routes[strings.ToUpper(method)+" "+param.ReplaceAllString(path, "*$1")] {
+				t.Errorf("%s %s is documented but not served", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// newOwnershipTestServer creates an auth-enabled server where bob and carol are
+// editors of the default project
+func newOwnershipTestServer(t *testing.T) (*Server, *database.MemoryRepository, *models.Project) {
+	t.Helper()
+
+	server, repo := newAuthTestServer(t, func(cfg *config.Config) { cfg.Safety.Preflight.Enabled = false })
+	repo.CreateUser(&models.User{ID: "carol", Username: "carol", Email: "carol@example.com", Role: "user"})
+	project, err := repo.GetProjectByName(models.DefaultProjectName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"bob", "carol"} {
+		if _, err := repo.SetProjectMember(project.ID, user, models.ProjectRoleEditor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return server, repo, project
+}
+
+func TestTestOwnership(t *testing.T) {
+	server, _, _ := newOwnershipTestServer(t)
+
+	rec := server.serveAs("bob", http.MethodPost, "/api/v1/tests", models.TestConfiguration{
+		Name:      "burn",
+		Plugin:    "cpu-stress",
+		Config:    json.RawMessage(`{"workers": 1, "algorithm": "prime", "intensity": 10}`),
+		Duration:  time.Minute,
+		CreatedBy: "carol",
+	})
+	var test models.TestConfiguration
+	json.Unmarshal(rec.Body.Bytes(), &test)
+	if rec.Code != http.StatusCreated || test.CreatedBy != "bob" {
+		t.Fatalf("create: status %d, want the test created by bob: %s", rec.Code, rec.Body)
+	}
+
+	// Updates keep the creator
+	test.CreatedBy = "carol"
+	if rec = server.serveAs("carol", http.MethodPut, "/api/v1/tests/"+test.ID, test); rec.Code != http.StatusOK {
+		t.Fatalf("update: status %d: %s", rec.Code, rec.Body)
+	}
+	if stored, _ := server.repo.GetTestConfiguration(test.ID); stored.CreatedBy != "bob" {
+		t.Errorf("created_by after update = %q, want bob", stored.CreatedBy)
+	}
+
+	for user, want := range map[string]int{"bob": 1, "carol": 0} {
+		rec = server.serveAs(user, http.MethodGet, "/api/v1/tests?mine=true", nil)
+		var tests []models.TestConfiguration
+		json.Unmarshal(rec.Body.Bytes(), &tests)
+		if rec.Code != http.StatusOK || len(tests) != want {
+			t.Errorf("%s: mine=true: status %d, %d tests, want %d", user, rec.Code, len(tests), want)
+		}
+	}
+
+	if rec = server.serveAs("carol", http.MethodDelete, "/api/v1/tests/"+test.ID, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("delete by another editor: status %d, want 403", rec.Code)
+	}
+	if rec = server.serveAs("bob", http.MethodDelete, "/api/v1/tests/"+test.ID, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("delete by the creator: status %d, want 204: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestExecutionOwnership(t *testing.T) {
+	server, repo, project := newOwnershipTestServer(t)
+	createDefaultProjectTest(t, repo, models.TestConfiguration{
+		ID:       "t1",
+		Name:     "burn",
+		Plugin:   "cpu-stress",
+		Config:   json.RawMessage(`{"workers": 1, "algorithm": "prime", "intensity": 10}`),
+		Duration: time.Minute,
+	})
+
+	rec := server.serveAs("bob", http.MethodPost, "/api/v1/tests/t1/run", models.TestParams{})
+	var started TestExecutionResponse
+	json.Unmarshal(rec.Body.Bytes(), &started)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("run: status %d: %s", rec.Code, rec.Body)
+	}
+	defer server.orchestrator.StopTest(started.ExecutionID)
+
+	// Executions can only be stopped once running
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		execution, err := server.orchestrator.GetTestStatus(started.ExecutionID)
+		if err == nil && execution.Status == models.StatusRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("execution not running: %+v, %v", execution, err)
+		}
+	}
+
+	for user, want := range map[string]int{"bob": 1, "carol": 0} {
+		rec = server.serveAs(user, http.MethodGet, "/api/v1/executions/running?mine=true", nil)
+		var running []models.TestExecution
+		json.Unmarshal(rec.Body.Bytes(), &running)
+		if rec.Code != http.StatusOK || len(running) != want {
+			t.Errorf("%s: running mine=true: status %d, %d executions, want %d", user, rec.Code, len(running), want)
+		}
+		if want == 1 && running[0].StartedBy != "bob" {
+			t.Errorf("started_by = %q, want bob", running[0].StartedBy)
+		}
+	}
+
+	if rec = server.serveAs("carol", http.MethodPost, "/api/v1/executions/"+started.ExecutionID+"/extend", ExtendExecutionRequest{Duration: "30s"}); rec.Code != http.StatusForbidden {
+		t.Errorf("extend by another editor: status %d, want 403", rec.Code)
+	}
+	if rec = server.serveAs("bob", http.MethodPost, "/api/v1/executions/"+started.ExecutionID+"/extend", ExtendExecutionRequest{Duration: "soon"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("extend by an invalid duration: status %d, want 400", rec.Code)
+	}
+	rec = server.serveAs("bob", http.MethodPost, "/api/v1/executions/"+started.ExecutionID+"/extend", ExtendExecutionRequest{Duration: "30s"})
+	var extended models.TestExecution
+	json.Unmarshal(rec.Body.Bytes(), &extended)
+	if rec.Code != http.StatusOK || extended.Extended != 30*time.Second {
+		t.Errorf("extend by the starter: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec = server.serveAs("bob", http.MethodPost, "/api/v1/executions/missing/extend", ExtendExecutionRequest{Duration: "30s"}); rec.Code != http.StatusNotFound {
+		t.Errorf("extend a missing execution: status %d, want 404", rec.Code)
+	}
+
+	if rec = server.serveAs("carol", http.MethodPost, "/api/v1/executions/"+started.ExecutionID+"/pause", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("pause by another editor: status %d, want 403", rec.Code)
+	}
+	for _, action := range []string{"pause", "resume"} {
+		rec = server.serveAs("bob", http.MethodPost, "/api/v1/executions/"+started.ExecutionID+"/"+action, nil)
+		var execution models.TestExecution
+		json.Unmarshal(rec.Body.Bytes(), &execution)
+		if rec.Code != http.StatusOK || execution.Progress == nil || execution.Progress.Paused != (action == "pause") {
+			t.Errorf("%s by the starter: status %d: %s", action, rec.Code, rec.Body)
+		}
+	}
+
+	if rec = server.serveAs("carol", http.MethodPost, "/api/v1/executions/"+started.ExecutionID+"/stop", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("stop by another editor: status %d, want 403", rec.Code)
+	}
+	if rec = server.serveAs("carol", http.MethodPost, "/api/v1/tests/t1/stop", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("stop test by another editor: status %d, want 403", rec.Code)
+	}
+	if rec = server.serveAs("root", http.MethodPost, "/api/v1/executions/"+started.ExecutionID+"/stop", nil); rec.Code != http.StatusOK {
+		t.Errorf("stop by an admin: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+
+	// Finished executions are filtered in the repository
+	for _, execution := range []models.TestExecution{{ID: "e1", StartedBy: "bob"}, {ID: "e2", StartedBy: "carol"}} {
+		execution.TestID = "t1"
+		execution.ProjectID = project.ID
+		repo.CreateTestExecution(&execution)
+	}
+	rec = server.serveAs("carol", http.MethodGet, "/api/v1/executions?mine=true", nil)
+	var executions []models.TestExecution
+	json.Unmarshal(rec.Body.Bytes(), &executions)
+	if rec.Code != http.StatusOK || len(executions) != 1 || executions[0].ID != "e2" {
+		t.Errorf("mine=true: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestMineRequiresAuthentication(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	for _, path := range []string{"/api/v1/tests?mine=true", "/api/v1/executions?mine=true", "/api/v1/executions/running?mine=true"} {
+		if rec := server.serve(http.MethodGet, path, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s without auth: status %d, want 400", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// RegisterPluginRequest is the body for registering an external plugin
+type RegisterPluginRequest struct {
+	Name         string               `json:"name" binding:"required"`
+	Version      string               `json:"version"`
+	Description  string               `json:"description"`
+	BinaryPath   string               `json:"binary_path" binding:"required"`
+	Checksum     string               `json:"checksum" binding:"required,len=64,hexadecimal"` // hex SHA-256 of the binary, verified before every run
+	ConfigSchema json.RawMessage      `json:"config_schema"`
+	SafetyLimits *models.SafetyLimits `json:"safety_limits"`
+	Destructive  bool                 `json:"destructive"`   // the plugin may destroy data on the machine
+	RequiresRoot bool                 `json:"requires_root"` // the plugin only runs with root privileges
+}
+
+// requireAdmin restricts a route to admin users. Requests without an identity are
+// refused, so admin routes are unavailable while auth is disabled.
+func (s *Server) requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString(contextUserIDKey)
+		if userID == "" {
+			abortWithError(c, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		admin, err := s.isAdmin(userID)
+		if err != nil {
+			s.logger.Error("Failed to get user", zap.Error(err))
+			abortWithError(c, http.StatusInternalServerError, "Failed to check permissions")
+			return
+		}
+		if !admin {
+			abortWithError(c, http.StatusForbidden, "Admin role required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// pluginInfo builds the API representation of a registered plugin, with the
+// capabilities clients use to decide which controls to offer for it
+func (s *Server) pluginInfo(plugin plugins.StressPlugin) map[string]interface{} {
+	_, external := plugin.(*plugins.ExternalPlugin)
+
+	return map[string]interface{}{
+		"name":          plugin.Name(),
+		"version":       plugin.Version(),
+		"description":   plugin.Description(),
+		"safety_limits": plugin.GetSafetyLimits(),
+		"enabled":       s.orchestrator.GetPluginManager().IsEnabled(plugin.Name()),
+		"external":      external,
+		"capabilities":  plugins.Capabilities(plugin),
+	}
+}
+
+// @Summary Register external plugin
+// @Description Register an executable on the server as a plugin, without a restart. The plugin is persisted and loaded again on startup. External plugins must be enabled in the configuration, and the binary must lie in an allowed plugin directory and match the given SHA-256.
+// @Tags plugins
+// @Accept json
+// @Produce json
+// @Param plugin body RegisterPluginRequest true "Plugin definition"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/plugins [post]
+func (s *Server) registerPlugin(c *gin.Context) {
+	var req RegisterPluginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if len(req.ConfigSchema) > 0 {
+		if _, err := plugins.ValidateConfig(req.ConfigSchema, nil); err != nil {
+			respondError(c, http.StatusBadRequest, "Invalid config schema: "+err.Error())
+			return
+		}
+	}
+
+	definition := models.Plugin{
+		Name:         req.Name,
+		Version:      req.Version,
+		Description:  req.Description,
+		BinaryPath:   req.BinaryPath,
+		Checksum:     req.Checksum,
+		ConfigSchema: req.ConfigSchema,
+		Capabilities: models.PluginCapabilities{
+			Destructive:  req.Destructive,
+			RequiresRoot: req.RequiresRoot,
+		},
+	}
+	if req.SafetyLimits != nil {
+		definition.SafetyLimits = *req.SafetyLimits
+	}
+
+	plugin, err := s.orchestrator.RegisterExternalPlugin(definition)
+	if errors.Is(err, plugins.ErrPluginExists) {
+		respondError(c, http.StatusConflict, "A plugin with this name is already registered")
+		return
+	}
+	if errors.Is(err, plugins.ErrExternalDisabled) || errors.Is(err, plugins.ErrBinaryNotAllowed) {
+		respondError(c, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Warn("Failed to register plugin", zap.String("plugin", req.Name), zap.Error(err))
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, s.pluginInfo(plugin))
+}
+
+// @Summary Remove external plugin
+// @Description Unregister an external plugin. Built-in plugins can only be disabled, and plugins loaded from a watched plugin directory are removed by deleting their manifest. Running executions are not interrupted.
+// @Tags plugins
+// @Param name path string true "Plugin name"
+// @Success 204
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/plugins/{name} [delete]
+func (s *Server) removePlugin(c *gin.Context) {
+	name := c.Param("name")
+
+	err := s.orchestrator.RemovePlugin(name)
+	switch {
+	case errors.Is(err, plugins.ErrPluginNotFound):
+		respondError(c, http.StatusNotFound, "Plugin not found")
+	case errors.Is(err, plugins.ErrPluginBuiltin):
+		respondError(c, http.StatusConflict, "Built-in plugins can't be removed, disable them instead")
+	case errors.Is(err, plugins.ErrPluginWatched):
+		respondError(c, http.StatusConflict, "Plugin is loaded from a watched plugin directory, delete its manifest instead")
+	case err != nil:
+		s.logger.Error("Failed to remove plugin", zap.String("plugin", name), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to remove plugin")
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// @Summary Enable plugin
+// @Description Allow new test runs to use a plugin
+// @Tags plugins
+// @Produce json
+// @Param name path string true "Plugin name"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/plugins/{name}/enable [post]
+func (s *Server) enablePlugin(c *gin.Context) {
+	s.setPluginEnabled(c, true)
+}
+
+// @Summary Disable plugin
+// @Description Refuse new test runs of a plugin. Running executions are not interrupted.
+// @Tags plugins
+// @Produce json
+// @Param name path string true "Plugin name"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/plugins/{name}/disable [post]
+func (s *Server) disablePlugin(c *gin.Context) {
+	s.setPluginEnabled(c, false)
+}
+
+func (s *Server) setPluginEnabled(c *gin.Context, enabled bool) {
+	name := c.Param("name")
+
+	err := s.orchestrator.SetPluginEnabled(name, enabled)
+	if errors.Is(err, plugins.ErrPluginNotFound) {
+		respondError(c, http.StatusNotFound, "Plugin not found")
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to change plugin state", zap.String("plugin", name), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to change plugin state")
+		return
+	}
+
+	plugin, exists := s.orchestrator.GetPluginManager().GetPlugin(name)
+	if !exists {
+		respondError(c, http.StatusNotFound, "Plugin not found")
+		return
+	}
+	c.JSON(http.StatusOK, s.pluginInfo(plugin))
+}
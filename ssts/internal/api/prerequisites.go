@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/database"
+)
+
+// PrerequisitesResponse reports the outcome of checking a test's prerequisites
+type PrerequisitesResponse struct {
+	Met      bool                       `json:"met"`
+	Failures []core.PrerequisiteFailure `json:"failures,omitempty"`
+}
+
+// @Summary Check test prerequisites
+// @Description Check whether a test's prerequisites are met right now, without running it
+// @Tags tests
+// @Produce json
+// @Param id path string true "Test ID"
+// @Success 200 {object} PrerequisitesResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/tests/{id}/prerequisites [get]
+func (s *Server) checkTestPrerequisites(c *gin.Context) {
+	test, err := s.repo.GetTestConfiguration(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Test not found")
+		} else {
+			s.logger.Error("Failed to get test", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get test")
+		}
+		return
+	}
+
+	err = s.orchestrator.CheckPrerequisites(*test)
+	var prerequisiteErr *core.PrerequisiteError
+	if errors.As(err, &prerequisiteErr) {
+		c.JSON(http.StatusOK, PrerequisitesResponse{Failures: prerequisiteErr.Failures})
+		return
+	}
+
+	c.JSON(http.StatusOK, PrerequisitesResponse{Met: true})
+}
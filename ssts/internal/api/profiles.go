@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ImportProfilesRequest is the body for importing hardware profiles. Profiles
+// replace existing ones with the same name.
+type ImportProfilesRequest struct {
+	Profiles []models.HardwareProfile `json:"profiles" binding:"required"`
+}
+
+// @Summary List hardware profiles
+// @Description Get the reference hardware profiles results are graded against
+// @Tags profiles
+// @Produce json
+// @Param plugin query string false "Only profiles for this plugin"
+// @Success 200 {array} models.HardwareProfile
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/profiles [get]
+func (s *Server) listProfiles(c *gin.Context) {
+	profiles, err := s.repo.ListHardwareProfiles(c.Query("plugin"))
+	if err != nil {
+		s.logger.Error("Failed to list hardware profiles", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list hardware profiles")
+		return
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+// @Summary Import hardware profiles
+// @Description Import reference results for hardware, e.g. the IOPS and latency expected of an SSD model. Completed executions of the profile's plugin whose labels include all of its match labels are graded against it. Profiles replace existing ones with the same name.
+// @Tags profiles
+// @Accept json
+// @Produce json
+// @Param profiles body ImportProfilesRequest true "Profiles"
+// @Success 200 {array} models.HardwareProfile
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/profiles [post]
+func (s *Server) importProfiles(c *gin.Context) {
+	var request ImportProfilesRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	names := make(map[string]bool, len(request.Profiles))
+	for i := range request.Profiles {
+		profile := &request.Profiles[i]
+		if err := profile.Validate(); err != nil {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("Profile %d: %v", i, err))
+			return
+		}
+		if names[profile.Name] {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("Profile %s is given more than once", profile.Name))
+			return
+		}
+		names[profile.Name] = true
+	}
+
+	for i := range request.Profiles {
+		profile := &request.Profiles[i]
+		profile.ID = ""
+		profile.CreatedBy = c.GetString(contextUserIDKey)
+		if err := s.repo.SaveHardwareProfile(profile); err != nil {
+			s.logger.Error("Failed to save hardware profile", zap.String("name", profile.Name), zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to save hardware profile "+profile.Name)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, request.Profiles)
+}
+
+// @Summary Get hardware profile
+// @Description Get a reference hardware profile by ID
+// @Tags profiles
+// @Produce json
+// @Param id path string true "Profile ID"
+// @Success 200 {object} models.HardwareProfile
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/profiles/{id} [get]
+func (s *Server) getProfile(c *gin.Context) {
+	profile, err := s.repo.GetHardwareProfile(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Profile not found")
+		} else {
+			s.logger.Error("Failed to get hardware profile", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get hardware profile")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Delete hardware profile
+// @Description Delete a reference hardware profile. Grades already stored with executions are kept.
+// @Tags profiles
+// @Param id path string true "Profile ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/profiles/{id} [delete]
+func (s *Server) deleteProfile(c *gin.Context) {
+	if err := s.repo.DeleteHardwareProfile(c.Param("id")); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Profile not found")
+		} else {
+			s.logger.Error("Failed to delete hardware profile", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to delete hardware profile")
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Grade execution
+// @Description Grade a finished execution against the current hardware profile matching its plugin and labels, e.g. one that finished before the profile was imported. The grade isn't stored.
+// @Tags executions
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Success 200 {object} models.ProfileGrade
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/grade [get]
+func (s *Server) gradeExecution(c *gin.Context) {
+	execution := s.annotatedExecution(c)
+	if execution == nil {
+		return
+	}
+	if len(execution.Summary) == 0 {
+		respondErrorCode(c, http.StatusConflict, CodeExecutionRunning, "Execution has not finished", nil)
+		return
+	}
+
+	grade, err := core.GradeExecution(s.repo, execution, s.config.Profiles.DefaultMarginPercent)
+	if errors.Is(err, core.ErrNoMatchingProfile) {
+		respondError(c, http.StatusNotFound, "No hardware profile matches this execution")
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to grade execution", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to grade execution")
+		return
+	}
+
+	c.JSON(http.StatusOK, grade)
+}
@@ -0,0 +1,522 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ProjectHeader selects the project a request operates on. The project_id query
+// parameter may be used instead; when neither is set the default project is used.
+const ProjectHeader = "X-Project-ID"
+
+// Context keys set by the project middleware and the auth middleware
+const (
+	contextProjectKey = "project"
+	contextUserIDKey  = "user_id"
+)
+
+// SetProjectMemberRequest is the body for granting a user a role in a project
+type SetProjectMemberRequest struct {
+	Role models.ProjectRole `json:"role" binding:"required,oneof=viewer editor owner"`
+}
+
+// projectMiddleware resolves the project for the request and, when the caller is
+// authenticated, checks that they hold a role allowing the request
+func (s *Server) projectMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID := c.GetHeader(ProjectHeader)
+		if projectID == "" {
+			projectID = c.Query("project_id")
+		}
+
+		var project *models.Project
+		var err error
+		if projectID != "" {
+			project, err = s.repo.GetProject(projectID)
+		} else {
+			project, err = s.repo.GetProjectByName(models.DefaultProjectName)
+		}
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				abortWithError(c, http.StatusNotFound, "Project not found")
+			} else {
+				s.logger.Error("Failed to resolve project", zap.Error(err))
+				abortWithError(c, http.StatusInternalServerError, "Failed to resolve project")
+			}
+			return
+		}
+
+		required := models.ProjectRoleEditor
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			required = models.ProjectRoleViewer
+		}
+		if !s.checkProjectRole(c, project.ID, required) {
+			return
+		}
+
+		c.Set(contextProjectKey, project)
+		c.Next()
+	}
+}
+
+// checkProjectRole verifies the caller holds at least the required role in the
+// project, aborting the request if not. Server admins hold every role. Requests
+// without an identity are only allowed while auth is disabled, when the server
+// has a single anonymous user; with auth enabled they are refused.
+func (s *Server) checkProjectRole(c *gin.Context, projectID string, required models.ProjectRole) bool {
+	userID := c.GetString(contextUserIDKey)
+	if userID == "" {
+		if s.config.Auth.Enabled {
+			abortWithError(c, http.StatusUnauthorized, "Authentication required")
+			return false
+		}
+		return true
+	}
+
+	admin, err := s.isAdmin(userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", zap.Error(err))
+		abortWithError(c, http.StatusInternalServerError, "Failed to check project access")
+		return false
+	}
+	if admin {
+		return true
+	}
+
+	member, err := s.repo.GetProjectMember(projectID, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			// Don't reveal the existence of projects the user can't see
+			abortWithError(c, http.StatusNotFound, "Project not found")
+		} else {
+			s.logger.Error("Failed to get project member", zap.Error(err))
+			abortWithError(c, http.StatusInternalServerError, "Failed to check project access")
+		}
+		return false
+	}
+
+	if !member.Role.Allows(required) {
+		abortWithError(c, http.StatusForbidden, "Insufficient project role")
+		return false
+	}
+
+	return true
+}
+
+// checkOwner verifies the caller may act on a test or execution belonging to
+// owner, such as stopping or deleting it, aborting the request if not. That takes
+// being the owner, a server admin or an owner of the current project. Requests
+// without an identity only reach handlers while auth is disabled, and are allowed.
+func (s *Server) checkOwner(c *gin.Context, owner string) bool {
+	userID := c.GetString(contextUserIDKey)
+	if userID == "" || userID == owner {
+		return true
+	}
+
+	admin, err := s.isAdmin(userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", zap.Error(err))
+		abortWithError(c, http.StatusInternalServerError, "Failed to check permissions")
+		return false
+	}
+	if admin {
+		return true
+	}
+
+	member, err := s.repo.GetProjectMember(currentProjectID(c), userID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		s.logger.Error("Failed to get project member", zap.Error(err))
+		abortWithError(c, http.StatusInternalServerError, "Failed to check permissions")
+		return false
+	}
+	if err == nil && member.Role.Allows(models.ProjectRoleOwner) {
+		return true
+	}
+
+	abortWithError(c, http.StatusForbidden, "Only the owner, a project owner or an admin may do this")
+	return false
+}
+
+// mineFilter returns the caller's user ID when the request asks for only their
+// own tests or executions with mine=true, and an empty string otherwise. Without
+// an identity mine=true is rejected, as there is nobody to match.
+func mineFilter(c *gin.Context, query url.Values) (string, bool) {
+	mine, _ := strconv.ParseBool(query.Get("mine"))
+	if !mine {
+		return "", true
+	}
+	userID := c.GetString(contextUserIDKey)
+	if userID == "" {
+		respondError(c, http.StatusBadRequest, "mine=true requires authentication")
+		return "", false
+	}
+	return userID, true
+}
+
+// isAdmin reports whether the user is a server admin. Unknown users aren't.
+func (s *Server) isAdmin(userID string) (bool, error) {
+	user, err := s.repo.GetUser(userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return user.Role == models.UserRoleAdmin, nil
+}
+
+// checkServerAdmin verifies the caller may change server-wide state, aborting the
+// request if not. With auth enabled that takes an admin; without it the single
+// anonymous user may. Routes that must stay unavailable without auth use
+// requireAdmin instead.
+func (s *Server) checkServerAdmin(c *gin.Context) bool {
+	userID := c.GetString(contextUserIDKey)
+	if userID == "" {
+		if s.config.Auth.Enabled {
+			abortWithError(c, http.StatusUnauthorized, "Authentication required")
+			return false
+		}
+		return true
+	}
+
+	admin, err := s.isAdmin(userID)
+	if err != nil {
+		s.logger.Error("Failed to get user", zap.Error(err))
+		abortWithError(c, http.StatusInternalServerError, "Failed to check permissions")
+		return false
+	}
+	if !admin {
+		abortWithError(c, http.StatusForbidden, "Admin role required")
+		return false
+	}
+	return true
+}
+
+// requireServerAdmin restricts a route to callers passing checkServerAdmin
+func (s *Server) requireServerAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.checkServerAdmin(c) {
+			c.Next()
+		}
+	}
+}
+
+// currentProject returns the project resolved by projectMiddleware
+func currentProject(c *gin.Context) *models.Project {
+	if value, ok := c.Get(contextProjectKey); ok {
+		if project, ok := value.(*models.Project); ok {
+			return project
+		}
+	}
+	return nil
+}
+
+// currentProjectID returns the ID of the project resolved by projectMiddleware
+func currentProjectID(c *gin.Context) string {
+	if project := currentProject(c); project != nil {
+		return project.ID
+	}
+	return ""
+}
+
+// requireTestInProject rejects requests for a test that belongs to another project
+func (s *Server) requireTestInProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if id == "" {
+			c.Next()
+			return
+		}
+
+		test, err := s.repo.GetTestConfiguration(id)
+		if err == nil && test.ProjectID != currentProjectID(c) {
+			abortWithError(c, http.StatusNotFound, "Test not found")
+			return
+		}
+
+		// Other errors are left to the handler, which reports them as usual
+		c.Next()
+	}
+}
+
+// requireExecutionInProject rejects requests for an execution that belongs to another project
+func (s *Server) requireExecutionInProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if id == "" {
+			c.Next()
+			return
+		}
+
+		execution, err := s.orchestrator.GetTestStatus(id)
+		if err == nil && execution.ProjectID != currentProjectID(c) {
+			abortWithError(c, http.StatusNotFound, "Execution not found")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// @Summary List projects
+// @Description Get the projects visible to the caller: those they are a member of, or all of them for admins
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Project
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/projects [get]
+func (s *Server) listProjects(c *gin.Context) {
+	userID := c.GetString(contextUserIDKey)
+	all := userID == ""
+	if !all {
+		admin, err := s.isAdmin(userID)
+		if err != nil {
+			s.logger.Error("Failed to get user", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to list projects")
+			return
+		}
+		all = admin
+	}
+
+	var projects []models.Project
+	var err error
+	if all {
+		projects, err = s.repo.ListProjects()
+	} else {
+		projects, err = s.repo.ListProjectsForUser(userID)
+	}
+
+	if err != nil {
+		s.logger.Error("Failed to list projects", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list projects")
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+// @Summary Create project
+// @Description Create a new project. With auth enabled only admins may, and the creator becomes its owner.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param project body models.Project true "Project"
+// @Success 201 {object} models.Project
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/projects [post]
+func (s *Server) createProject(c *gin.Context) {
+	var project models.Project
+	if !bindJSON(c, &project) {
+		return
+	}
+
+	project.ID = ""
+	userID := c.GetString(contextUserIDKey)
+	if userID != "" {
+		project.CreatedBy = userID
+	}
+
+	if err := s.repo.CreateProject(&project); err != nil {
+		s.logger.Error("Failed to create project", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to create project")
+		return
+	}
+
+	if userID != "" {
+		if _, err := s.repo.SetProjectMember(project.ID, userID, models.ProjectRoleOwner); err != nil {
+			s.logger.Error("Failed to add project owner", zap.Error(err))
+		}
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+// @Summary Get project
+// @Description Get a specific project by ID
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path string true "Project ID"
+// @Success 200 {object} models.Project
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/projects/{id} [get]
+func (s *Server) getProject(c *gin.Context) {
+	id := c.Param("id")
+
+	if !s.checkProjectRole(c, id, models.ProjectRoleViewer) {
+		return
+	}
+
+	project, err := s.repo.GetProject(id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Project not found")
+		} else {
+			s.logger.Error("Failed to get project", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get project")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+// @Summary Delete project
+// @Description Delete a project and its memberships. Requires the owner role or admin. The default project cannot be deleted.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path string true "Project ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/projects/{id} [delete]
+func (s *Server) deleteProject(c *gin.Context) {
+	id := c.Param("id")
+
+	if !s.checkProjectRole(c, id, models.ProjectRoleOwner) {
+		return
+	}
+
+	project, err := s.repo.GetProject(id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Project not found")
+		} else {
+			s.logger.Error("Failed to get project", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get project")
+		}
+		return
+	}
+
+	if project.Name == models.DefaultProjectName {
+		respondError(c, http.StatusBadRequest, "The default project cannot be deleted")
+		return
+	}
+
+	if err := s.repo.DeleteProject(id); err != nil {
+		s.logger.Error("Failed to delete project", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to delete project")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary List project members
+// @Description Get the users granted a role in a project
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path string true "Project ID"
+// @Success 200 {array} models.ProjectMember
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/projects/{id}/members [get]
+func (s *Server) listProjectMembers(c *gin.Context) {
+	id := c.Param("id")
+
+	if !s.checkProjectRole(c, id, models.ProjectRoleViewer) {
+		return
+	}
+
+	members, err := s.repo.ListProjectMembers(id)
+	if err != nil {
+		s.logger.Error("Failed to list project members", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list project members")
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// @Summary Set project member
+// @Description Grant a user a role in a project, replacing any role they already hold. Requires the owner role or admin.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path string true "Project ID"
+// @Param user_id path string true "User ID"
+// @Param request body SetProjectMemberRequest true "Role"
+// @Success 200 {object} models.ProjectMember
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/projects/{id}/members/{user_id} [put]
+func (s *Server) setProjectMember(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.Param("user_id")
+
+	if !s.checkProjectRole(c, id, models.ProjectRoleOwner) {
+		return
+	}
+
+	var request SetProjectMemberRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if _, err := s.repo.GetProject(id); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Project not found")
+		} else {
+			s.logger.Error("Failed to get project", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get project")
+		}
+		return
+	}
+
+	member, err := s.repo.SetProjectMember(id, userID, request.Role)
+	if err != nil {
+		s.logger.Error("Failed to set project member", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to set project member")
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// @Summary Remove project member
+// @Description Revoke a user's role in a project. Requires the owner role or admin.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param id path string true "Project ID"
+// @Param user_id path string true "User ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/projects/{id}/members/{user_id} [delete]
+func (s *Server) removeProjectMember(c *gin.Context) {
+	id := c.Param("id")
+	userID := c.Param("user_id")
+
+	if !s.checkProjectRole(c, id, models.ProjectRoleOwner) {
+		return
+	}
+
+	if err := s.repo.RemoveProjectMember(id, userID); err != nil {
+		s.logger.Error("Failed to remove project member", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to remove project member")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
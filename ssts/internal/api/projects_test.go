@@ -0,0 +1,266 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// createProject creates a project in which bob holds role, or none when empty
+func createProject(t *testing.T, repo *database.MemoryRepository, name string, role models.ProjectRole) *models.Project {
+	t.Helper()
+	project := &models.Project{Name: name}
+	if err := repo.CreateProject(project); err != nil {
+		t.Fatal(err)
+	}
+	if role != "" {
+		if _, err := repo.SetProjectMember(project.ID, "bob", role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return project
+}
+
+func TestCheckProjectRoleWithoutIdentity(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		server, _ := newAuthTestServer(t, func(cfg *config.Config) { cfg.Auth.Enabled = enabled })
+
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+		// Only the anonymous user of a server without auth passes
+		if allowed := server.checkProjectRole(c, "p1", models.ProjectRoleViewer); allowed != !enabled {
+			t.Errorf("auth enabled = %v: allowed = %v", enabled, allowed)
+		}
+		if enabled && rec.Code != http.StatusUnauthorized {
+			t.Errorf("auth enabled: status %d, want 401", rec.Code)
+		}
+	}
+}
+
+func TestProjectRoutesRequireOwnerOrAdmin(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		role   models.ProjectRole
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"non-member deletes", "bob", "", http.MethodDelete, "", nil, http.StatusNotFound},
+		{"viewer deletes", "bob", models.ProjectRoleViewer, http.MethodDelete, "", nil, http.StatusForbidden},
+		{"editor deletes", "bob", models.ProjectRoleEditor, http.MethodDelete, "", nil, http.StatusForbidden},
+		{"owner deletes", "bob", models.ProjectRoleOwner, http.MethodDelete, "", nil, http.StatusNoContent},
+		{"admin deletes", "root", "", http.MethodDelete, "", nil, http.StatusNoContent},
+		{"editor adds member", "bob", models.ProjectRoleEditor, http.MethodPut, "/members/carol", SetProjectMemberRequest{Role: models.ProjectRoleOwner}, http.StatusForbidden},
+		{"owner adds member", "bob", models.ProjectRoleOwner, http.MethodPut, "/members/carol", SetProjectMemberRequest{Role: models.ProjectRoleViewer}, http.StatusOK},
+		{"admin adds member", "root", "", http.MethodPut, "/members/carol", SetProjectMemberRequest{Role: models.ProjectRoleViewer}, http.StatusOK},
+		{"editor removes member", "bob", models.ProjectRoleEditor, http.MethodDelete, "/members/bob", nil, http.StatusForbidden},
+		{"owner removes member", "bob", models.ProjectRoleOwner, http.MethodDelete, "/members/bob", nil, http.StatusNoContent},
+		{"viewer reads", "bob", models.ProjectRoleViewer, http.MethodGet, "", nil, http.StatusOK},
+		{"admin reads", "root", "", http.MethodGet, "", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		server, repo := newAuthTestServer(t, nil)
+		project := createProject(t, repo, "lab", tt.role)
+		if rec := server.serveAs(tt.user, tt.method, "/api/v1/projects/"+project.ID+tt.path, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestCreateProject(t *testing.T) {
+	server, _ := newAuthTestServer(t, nil)
+	if rec := server.serveAs("bob", http.MethodPost, "/api/v1/projects", models.Project{Name: "lab"}); rec.Code != http.StatusForbidden {
+		t.Errorf("user: status %d, want 403", rec.Code)
+	}
+	rec := server.serveAs("root", http.MethodPost, "/api/v1/projects", models.Project{Name: "lab"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("admin: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// Admins see every project, users only their own
+	var projects []models.Project
+	json.Unmarshal(server.serveAs("root", http.MethodGet, "/api/v1/projects", nil).Body.Bytes(), &projects)
+	if len(projects) != 2 {
+		t.Errorf("admin lists %d projects, want 2", len(projects))
+	}
+	json.Unmarshal(server.serveAs("bob", http.MethodGet, "/api/v1/projects", nil).Body.Bytes(), &projects)
+	if len(projects) != 0 {
+		t.Errorf("user lists %+v, want none", projects)
+	}
+
+	// Without auth the anonymous user may create projects
+	server, _ = newTestServer(t)
+	if rec := server.serve(http.MethodPost, "/api/v1/projects", models.Project{Name: "lab"}); rec.Code != http.StatusCreated {
+		t.Errorf("auth disabled: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAlertsScopedToProject(t *testing.T) {
+	server, repo := newAuthTestServer(t, nil)
+	lab := createProject(t, repo, "lab", models.ProjectRoleEditor)
+	other := createProject(t, repo, "other", "")
+	now := time.Now()
+	repo.CreateAlert(&models.Alert{ID: "lab-alert", Type: "grade", ProjectID: lab.ID, Timestamp: now})
+	repo.CreateAlert(&models.Alert{ID: "other-alert", Type: "grade", ProjectID: other.ID, Timestamp: now})
+	repo.CreateAlert(&models.Alert{ID: "host-alert", Type: "cpu", Timestamp: now})
+
+	rec := server.serveAs("bob", http.MethodGet, "/api/v1/alerts?project_id="+lab.ID, nil)
+	var alerts []models.Alert
+	json.Unmarshal(rec.Body.Bytes(), &alerts)
+	if len(alerts) != 2 {
+		t.Fatalf("alerts = %s, want the project's and the host-wide one", rec.Body)
+	}
+	for _, alert := range alerts {
+		if alert.ID == "other-alert" {
+			t.Errorf("listed another project's alert")
+		}
+	}
+
+	ack := AcknowledgeAlertRequest{AcknowledgedBy: "bob"}
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"get other project's alert", "bob", http.MethodGet, "/other-alert", nil, http.StatusNotFound},
+		{"acknowledge other project's alert", "bob", http.MethodPost, "/other-alert/acknowledge", ack, http.StatusNotFound},
+		{"acknowledge host-wide alert", "bob", http.MethodPost, "/host-alert/acknowledge", ack, http.StatusForbidden},
+		{"create mute rule", "bob", http.MethodPost, "/mutes", CreateMuteRuleRequest{Type: "cpu"}, http.StatusForbidden},
+		{"get project's alert", "bob", http.MethodGet, "/lab-alert", nil, http.StatusOK},
+		{"acknowledge project's alert", "bob", http.MethodPost, "/lab-alert/acknowledge", ack, http.StatusOK},
+		{"admin acknowledges host-wide alert", "root", http.MethodPost, "/host-alert/acknowledge", ack, http.StatusOK},
+		{"admin creates mute rule", "root", http.MethodPost, "/mutes", CreateMuteRuleRequest{Type: "cpu"}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		if rec := server.serveAs(tt.user, tt.method, "/api/v1/alerts"+tt.path+"?project_id="+lab.ID, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestSavedSearchesScopedToProject(t *testing.T) {
+	server, repo := newTestServer(t)
+	other := createProject(t, repo, "other", "")
+
+	rec := server.serve(http.MethodPost, "/api/v1/searches", CreateSavedSearchRequest{Name: "cpu", Resource: models.SavedSearchTests, Query: "plugin=cpu-stress"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("save: status %d: %s", rec.Code, rec.Body)
+	}
+	var search models.SavedSearch
+	json.Unmarshal(rec.Body.Bytes(), &search)
+
+	var searches []models.SavedSearch
+	json.Unmarshal(server.serve(http.MethodGet, "/api/v1/searches?project_id="+other.ID, nil).Body.Bytes(), &searches)
+	if len(searches) != 0 {
+		t.Errorf("searches in another project = %+v, want none", searches)
+	}
+
+	for _, path := range []string{
+		"/api/v1/searches/" + search.ID + "?project_id=" + other.ID,
+		"/api/v1/tests?search=" + search.ID + "&project_id=" + other.ID,
+	} {
+		if rec := server.serve(http.MethodGet, path, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", path, rec.Code)
+		}
+	}
+	if rec := server.serve(http.MethodGet, "/api/v1/tests?search="+search.ID, nil); rec.Code != http.StatusOK {
+		t.Errorf("running the search in its project: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestWebSocketReplayScopedToProject(t *testing.T) {
+	hub := NewWebSocketHub(config.WebSocketConfig{ReplayBufferSize: 10})
+	hub.BroadcastMetrics("mine", "a")
+	hub.BroadcastMetrics("theirs", "b")
+	hub.broadcastTopic("metrics_update", "", "host")
+
+	client := &WSClient{
+		hub:     hub,
+		queue:   newSendQueue(1),
+		visible: func(testID string) bool { return testID == "mine" },
+	}
+	replayed := func(request map[string]interface{}) int {
+		client.handleReplay(request)
+		var reply struct {
+			Data struct {
+				Messages []json.RawMessage `json:"messages"`
+			} `json:"data"`
+		}
+		json.Unmarshal(nextMessage(client), &reply)
+		return len(reply.Data.Messages)
+	}
+
+	if n := replayed(map[string]interface{}{"topic": "metrics_update"}); n != 2 {
+		t.Errorf("replayed %d messages, want the client's test's and the host-wide one", n)
+	}
+	if n := replayed(map[string]interface{}{"topic": "metrics_update", "test_id": "theirs"}); n != 0 {
+		t.Errorf("replayed %d messages of another project's test", n)
+	}
+
+	client.visible = nil
+	if n := replayed(map[string]interface{}{"topic": "metrics_update"}); n != 1 {
+		t.Errorf("client without a project: replayed %d messages, want only the host-wide one", n)
+	}
+}
+
+func TestWebSocketBroadcastsScopedToProject(t *testing.T) {
+	server, repo := newTestServer(t)
+	mine, theirs := createProject(t, repo, "mine", ""), createProject(t, repo, "theirs", "")
+	if err := repo.CreateTestConfiguration(&models.TestConfiguration{ID: "t1", Name: "burn", Plugin: "cpu-stress", ProjectID: mine.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	httpServer := httptest.NewServer(server.engine)
+	defer httpServer.Close()
+	dial := func(project *models.Project) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/ws?project_id="+project.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	ours, other := dial(mine), dial(theirs)
+	defer ours.Close()
+	defer other.Close()
+	// Wait for the hub to register the clients
+	time.Sleep(50 * time.Millisecond)
+
+	server.wsHub.BroadcastMetrics("t1", []models.MetricPoint{{TestID: "t1"}})
+	server.wsHub.BroadcastAlert("anomaly", "host-wide", "warning")
+
+	// firstType reads the type of the first message sent to a connection
+	firstType := func(conn *websocket.Conn) string {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var message WSMessage
+		json.Unmarshal(bytes.SplitN(data, []byte{'\n'}, 2)[0], &message)
+		return message.Type
+	}
+	if got := firstType(ours); got != "metrics_update" {
+		t.Errorf("the test's project was sent %q first, want its metrics", got)
+	}
+	if got := firstType(other); got != "alert" {
+		t.Errorf("another project was sent %q first, want only the host-wide alert", got)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// prometheusMetrics exposes server counters, and the WebSocket hub's, in the Prometheus text exposition format
+func (s *Server) prometheusMetrics(c *gin.Context) {
+	var b strings.Builder
+
+	throttled := s.throttled.snapshot()
+	b.WriteString("# HELP ssts_http_requests_throttled_total Requests rejected by the API rate limiter.\n")
+	b.WriteString("# TYPE ssts_http_requests_throttled_total counter\n")
+	for _, scope := range []string{"ip", "api_key"} {
+		fmt.Fprintf(&b, "ssts_http_requests_throttled_total{scope=%q} %d\n", scope, throttled[scope])
+	}
+
+	ws := s.wsHub.Stats()
+	for _, metric := range []struct {
+		name, kind, help string
+		value            uint64
+	}{
+		{"ssts_websocket_clients", "gauge", "Connected WebSocket clients.", uint64(ws.Clients)},
+		{"ssts_websocket_queued_messages", "gauge", "Messages waiting in WebSocket clients' send queues.", uint64(ws.QueuedMessages)},
+		{"ssts_websocket_max_queue_depth", "gauge", "Messages queued for the WebSocket client furthest behind.", uint64(ws.MaxQueueDepth)},
+		{"ssts_websocket_broadcasts_total", "counter", "Messages broadcast to WebSocket clients.", ws.Broadcasts},
+		{"ssts_websocket_broadcasts_dropped_total", "counter", "Broadcasts dropped because the WebSocket hub was too far behind.", ws.BroadcastsDropped},
+		{"ssts_websocket_messages_coalesced_total", "counter", "WebSocket messages superseded by a later one before being sent.", ws.Coalesced},
+		{"ssts_websocket_messages_queued_total", "counter", "Messages queued for WebSocket clients.", ws.Queued},
+		{"ssts_websocket_queue_drops_total", "counter", "Messages dropped from full WebSocket send queues.", ws.QueueDrops},
+		{"ssts_websocket_clients_evicted_total", "counter", "WebSocket clients disconnected for falling behind.", ws.Evicted},
+		{"ssts_websocket_messages_written_total", "counter", "Messages written to WebSocket connections.", ws.MessagesWritten},
+		{"ssts_websocket_bytes_written_total", "counter", "Bytes written to WebSocket connections.", ws.BytesWritten},
+	} {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", metric.name, metric.help, metric.name, metric.kind, metric.name, metric.value)
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
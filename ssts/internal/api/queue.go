@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// MoveQueuedRequest is the body for moving a queued execution
+type MoveQueuedRequest struct {
+	Position int `json:"position" binding:"required,min=1"` // 1 to start next
+}
+
+// @Summary Get the run queue
+// @Description Get the runs of the project waiting to start, in the order they start, with their position counting the runs of every project, their priority, why they are waiting and when they are estimated to start; the project's executions holding the machine, with when they are expected to end; and the resources running executions claim, such as devices and memory, with how much of each is in use. Runs start in queue order: one waits for runs ahead of it that claim a resource it claims, and for those ahead waiting for the preflight checks. Estimates assume runs take their duration and cooldown; runs waiting for load from outside SSTS to clear have none. Test runs aren't scheduled on a timer in this version, so there are no scheduled fire times to report.
+// @Tags queue
+// @Produce json
+// @Success 200 {object} models.QueueState
+// @Router /api/v1/queue [get]
+func (s *Server) getQueue(c *gin.Context) {
+	projectID := currentProjectID(c)
+	state := s.orchestrator.GetQueue()
+
+	result := models.QueueState{
+		Queued:    make([]models.QueuedRun, 0, len(state.Queued)),
+		Running:   make([]models.RunningRun, 0, len(state.Running)),
+		Resources: state.Resources,
+	}
+	visible := make(map[string]bool)
+	for _, execution := range s.orchestrator.ListExecutions() {
+		visible[execution.ID] = execution.ProjectID == projectID
+	}
+	for _, run := range state.Queued {
+		if visible[run.ExecutionID] {
+			result.Queued = append(result.Queued, run)
+		}
+	}
+	for _, run := range state.Running {
+		if visible[run.ExecutionID] {
+			result.Running = append(result.Running, run)
+		}
+	}
+	// Resources are shared by every project, but other projects' executions aren't named
+	for i := range result.Resources {
+		holders := make([]string, 0, len(result.Resources[i].Holders))
+		for _, id := range result.Resources[i].Holders {
+			if visible[id] {
+				holders = append(holders, id)
+			}
+		}
+		result.Resources[i].Holders = holders
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// @Summary Move a queued execution
+// @Description Move an execution waiting in the queue to a position, 1 to start next, whatever its priority. Positions past the end move it last. The queue is shared by every project, so this takes a server admin.
+// @Tags queue
+// @Accept json
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Param request body MoveQueuedRequest true "New position"
+// @Success 200 {object} models.QueueState
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/queue/{id}/position [put]
+func (s *Server) moveQueuedExecution(c *gin.Context) {
+	var request MoveQueuedRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if !s.respondQueueError(c, "move", s.orchestrator.MoveQueued(c.Param("id"), request.Position)) {
+		s.getQueue(c)
+	}
+}
+
+// @Summary Cancel a queued execution
+// @Description Cancel an execution waiting in the queue; it ends stopped without having run. With auth enabled only the user who ran it, a project owner or an admin may.
+// @Tags queue
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/queue/{id} [delete]
+func (s *Server) cancelQueuedExecution(c *gin.Context) {
+	id := c.Param("id")
+	execution, err := s.orchestrator.GetTestStatus(id)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "Execution not found")
+		return
+	}
+	if !s.checkOwner(c, execution.StartedBy) {
+		return
+	}
+
+	if !s.respondQueueError(c, "cancel", s.orchestrator.CancelQueued(id)) {
+		c.JSON(http.StatusOK, map[string]string{
+			"message": "Queued execution cancelled",
+		})
+	}
+}
+
+// respondQueueError responds to a failed queue operation, reporting whether err
+// was one
+func (s *Server) respondQueueError(c *gin.Context, operation string, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, core.ErrExecutionNotFound):
+		respondError(c, http.StatusNotFound, "Execution not found")
+	case errors.Is(err, core.ErrNotQueued):
+		respondErrorCode(c, http.StatusConflict, CodeNotQueued, err.Error(), nil)
+	default:
+		s.logger.Error("Failed to "+operation+" queued execution", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to "+operation+" queued execution")
+	}
+	return true
+}
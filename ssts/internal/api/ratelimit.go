@@ -0,0 +1,242 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// idleBucketTTL is how long an unused bucket is kept before being evicted
+const idleBucketTTL = 10 * time.Minute
+
+// apiKeyCacheTTL is how long a valid API key is remembered, sparing a database
+// lookup per request. A key revoked meanwhile keeps its bucket this long.
+const apiKeyCacheTTL = time.Minute
+
+// maxCachedAPIKeys bounds the valid API keys remembered
+const maxCachedAPIKeys = 10000
+
+// tokenBucket is a classic token bucket refilled continuously at rate tokens per second
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter enforces per-key token bucket limits
+type RateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a rate limiter allowing rate requests per second with the given burst
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token for key. When no token is available it returns false and
+// how long the caller should wait before retrying.
+func (r *RateLimiter) Allow(key string) (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.sweep(now)
+
+	bucket, exists := r.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: r.burst, lastSeen: now}
+		r.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(r.burst, bucket.tokens+now.Sub(bucket.lastSeen).Seconds()*r.rate)
+	bucket.lastSeen = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	if r.rate <= 0 {
+		return false, time.Minute
+	}
+	wait := time.Duration((1 - bucket.tokens) / r.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep evicts buckets that have not been used recently so the map does not grow without bound
+func (r *RateLimiter) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < idleBucketTTL {
+		return
+	}
+	for key, bucket := range r.buckets {
+		if now.Sub(bucket.lastSeen) > idleBucketTTL {
+			delete(r.buckets, key)
+		}
+	}
+	r.lastSweep = now
+}
+
+// throttleCounters counts throttled requests by limiter scope for the /metrics endpoint
+type throttleCounters struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (t *throttleCounters) inc(scope string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counts == nil {
+		t.counts = make(map[string]int64)
+	}
+	t.counts[scope]++
+}
+
+func (t *throttleCounters) snapshot() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := make(map[string]int64, len(t.counts))
+	for scope, count := range t.counts {
+		counts[scope] = count
+	}
+	return counts
+}
+
+// rateLimitMiddleware applies per-API-key limits to requests carrying a valid API
+// key and per-IP limits to everything else. API keys are agent tokens; a header
+// with an unknown or revoked key is ignored, so made-up keys can't be used to get
+// fresh buckets. Checking a key not seen lately takes a database lookup, so
+// such requests count against the client's IP first, and made-up keys can't be
+// used to flood the database either.
+func (s *Server) rateLimitMiddleware(cfg config.RateLimitConfig) gin.HandlerFunc {
+	ipLimiter := NewRateLimiter(cfg.RequestsPerSecond, cfg.Burst)
+	keyLimiter := NewRateLimiter(cfg.APIKeyRequestsPerSecond, cfg.APIKeyBurst)
+	keys := &apiKeyCache{agents: make(map[string]cachedAPIKey)}
+
+	exempt := make(map[string]bool, len(cfg.ExemptPaths))
+	for _, path := range cfg.ExemptPaths {
+		exempt[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if exempt[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		scope, limiter, key := "ip", ipLimiter, c.ClientIP()
+		if apiKey := c.GetHeader(cfg.APIKeyHeader); cfg.APIKeyHeader != "" && strings.HasPrefix(apiKey, models.AgentTokenPrefix) {
+			hash := hashAgentToken(apiKey)
+			agentID, known := keys.get(hash)
+			if !known {
+				if !s.takeToken(c, scope, limiter, key) {
+					return
+				}
+				if agentID = s.apiKeyAgent(apiKey); agentID == "" {
+					c.Next()
+					return
+				}
+				keys.put(hash, agentID)
+			}
+			scope, limiter, key = "api_key", keyLimiter, agentID
+		}
+
+		if !s.takeToken(c, scope, limiter, key) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// takeToken takes a token from the limiter's bucket for key, or rejects the
+// request with 429 and returns false when there is none
+func (s *Server) takeToken(c *gin.Context, scope string, limiter *RateLimiter, key string) bool {
+	allowed, retryAfter := limiter.Allow(key)
+	if allowed {
+		return true
+	}
+	s.throttled.inc(scope)
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	abortWithError(c, http.StatusTooManyRequests, "Rate limit exceeded")
+	return false
+}
+
+// apiKeyCache remembers the agents valid API keys belong to, by the keys' hashes
+type apiKeyCache struct {
+	mu     sync.Mutex
+	agents map[string]cachedAPIKey
+}
+
+type cachedAPIKey struct {
+	agentID string
+	expires time.Time
+}
+
+func (a *apiKeyCache) get(hash string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cached, ok := a.agents[hash]
+	if !ok || time.Now().After(cached.expires) {
+		return "", false
+	}
+	return cached.agentID, true
+}
+
+func (a *apiKeyCache) put(hash, agentID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	if len(a.agents) >= maxCachedAPIKeys {
+		for key, cached := range a.agents {
+			if now.After(cached.expires) {
+				delete(a.agents, key)
+			}
+		}
+		if len(a.agents) >= maxCachedAPIKeys {
+			a.agents = make(map[string]cachedAPIKey)
+		}
+	}
+	a.agents[hash] = cachedAPIKey{agentID: agentID, expires: now.Add(apiKeyCacheTTL)}
+}
+
+// apiKeyAgent returns the ID of the agent an API key belongs to, or "" when the key
+// is empty, unknown or revoked
+func (s *Server) apiKeyAgent(apiKey string) string {
+	if !strings.HasPrefix(apiKey, models.AgentTokenPrefix) {
+		return ""
+	}
+
+	agent, err := s.repo.GetAgentTokenByHash(hashAgentToken(apiKey))
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			s.logger.Warn("Failed to check API key", zap.Error(err))
+		}
+		return ""
+	}
+	return agent.ID
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestRateLimiterRefills(t *testing.T) {
+	limiter := NewRateLimiter(100, 1)
+	if allowed, _ := limiter.Allow("a"); !allowed {
+		t.Fatal("first request throttled")
+	}
+	allowed, wait := limiter.Allow("a")
+	if allowed || wait <= 0 || wait > 10*time.Millisecond {
+		t.Fatalf("empty bucket: allowed = %v, wait = %v", allowed, wait)
+	}
+	if allowed, _ := limiter.Allow("b"); !allowed {
+		t.Error("another key shares the bucket")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if allowed, _ := limiter.Allow("a"); !allowed {
+		t.Error("bucket did not refill")
+	}
+}
+
+func TestRateLimitAPIKeys(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.RateLimit = config.RateLimitConfig{
+		Enabled:                 true,
+		RequestsPerSecond:       50,
+		Burst:                   2,
+		APIKeyRequestsPerSecond: 50,
+		APIKeyBurst:             4,
+		APIKeyHeader:            "X-API-Key",
+	}
+	memory := database.NewMemoryRepository()
+	repo := &countingTokenLookups{MemoryRepository: memory}
+	server := NewServer(cfg, repo, core.NewOrchestrator(cfg, memory, plugins.NewPluginManager(), zap.NewNop()), zap.NewNop())
+
+	valid := models.AgentTokenPrefix + "valid"
+	revoked := models.AgentTokenPrefix + "revoked"
+	repo.CreateAgentToken(&models.AgentToken{ID: "a1", Name: "rack-1", TokenHash: hashAgentToken(valid)})
+	repo.CreateAgentToken(&models.AgentToken{ID: "a2", Name: "rack-2", TokenHash: hashAgentToken(revoked)})
+	repo.RevokeAgentToken("a2")
+
+	call := func(apiKey string) int {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rec := httptest.NewRecorder()
+		server.engine.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	// allowed makes n requests and returns how many were let through
+	allowed := func(apiKey string, n int) int {
+		count := 0
+		for i := 0; i < n; i++ {
+			if call(apiKey) != http.StatusTooManyRequests {
+				count++
+			}
+		}
+		return count
+	}
+
+	// A valid key has its own bucket; checking it the first time costs the IP a
+	// request, and it is remembered afterwards
+	if n := allowed(valid, 6); n != 4 {
+		t.Errorf("requests with a valid key allowed = %d, want the key burst of 4", n)
+	}
+	if repo.lookups != 1 {
+		t.Errorf("valid key looked up %d times, want once", repo.lookups)
+	}
+
+	// Unknown and revoked keys, and keys of the wrong form, all draw on the
+	// bucket of the client's IP, and once it is empty they aren't looked up
+	if n := allowed("", 1) + allowed(models.AgentTokenPrefix+"made-up", 1) + allowed(revoked, 1) + allowed("anything", 1); n != 1 {
+		t.Errorf("requests with invalid keys allowed = %d, want what the valid key left of the IP burst of 2", n)
+	}
+	if repo.lookups != 1 {
+		t.Errorf("keys looked up %d times with the IP's bucket empty", repo.lookups)
+	}
+	if server.throttled.snapshot()["ip"] != 3 || server.throttled.snapshot()["api_key"] != 2 {
+		t.Errorf("throttled counts = %v", server.throttled.snapshot())
+	}
+
+	// A client can't get a fresh bucket by claiming another IP
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	rec := httptest.NewRecorder()
+	server.engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("request with a spoofed X-Forwarded-For: status %d, want 429", rec.Code)
+	}
+
+	// Both buckets refill
+	time.Sleep(50 * time.Millisecond)
+	if call("") == http.StatusTooManyRequests {
+		t.Error("IP bucket did not refill")
+	}
+	if call(valid) == http.StatusTooManyRequests {
+		t.Error("API key bucket did not refill")
+	}
+}
+
+func TestRateLimitTrustedProxies(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.RateLimit = config.RateLimitConfig{Enabled: true, RequestsPerSecond: 0.001, Burst: 1}
+	cfg.Server.TrustedProxies = []string{"10.0.0.0/8"}
+	repo := database.NewMemoryRepository()
+	server := NewServer(cfg, repo, core.NewOrchestrator(cfg, repo, plugins.NewPluginManager(), zap.NewNop()), zap.NewNop())
+
+	call := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		server.engine.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Behind the proxy, each client has its own bucket
+	if call("10.0.0.1:1234", "203.0.113.1") == http.StatusTooManyRequests || call("10.0.0.1:1234", "203.0.113.2") == http.StatusTooManyRequests {
+		t.Error("clients behind a trusted proxy share a bucket")
+	}
+	// Elsewhere the header is ignored
+	if call("192.0.2.1:1234", "203.0.113.3") == http.StatusTooManyRequests {
+		t.Error("first request from an untrusted peer throttled")
+	}
+	if call("192.0.2.1:1234", "203.0.113.4") != http.StatusTooManyRequests {
+		t.Error("an untrusted peer got a fresh bucket by claiming another IP")
+	}
+}
+
+// countingTokenLookups counts the agent token lookups made through it
+type countingTokenLookups struct {
+	*database.MemoryRepository
+	lookups int
+}
+
+func (r *countingTokenLookups) GetAgentTokenByHash(hash string) (*models.AgentToken, error) {
+	r.lookups++
+	return r.MemoryRepository.GetAgentTokenByHash(hash)
+}
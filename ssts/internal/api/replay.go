@@ -0,0 +1,96 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// replayEntry is a single broadcast message retained for replay
+type replayEntry struct {
+	timestamp time.Time
+	testID    string
+	payload   []byte
+}
+
+// ReplayBuffer retains recently broadcast messages per topic so that clients
+// reconnecting after a network blip can backfill what they missed
+type ReplayBuffer struct {
+	mu      sync.RWMutex
+	size    int
+	maxAge  time.Duration
+	entries map[string][]replayEntry
+}
+
+// NewReplayBuffer creates a replay buffer holding at most size messages per topic,
+// each retained for at most maxAge (0 = no age limit)
+func NewReplayBuffer(size int, maxAge time.Duration) *ReplayBuffer {
+	return &ReplayBuffer{
+		size:    size,
+		maxAge:  maxAge,
+		entries: make(map[string][]replayEntry),
+	}
+}
+
+// Add records a message under the given topic
+func (r *ReplayBuffer) Add(topic, testID string, payload []byte) {
+	if r.size <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := append(r.entries[topic], replayEntry{
+		timestamp: time.Now(),
+		testID:    testID,
+		payload:   payload,
+	})
+
+	if len(entries) > r.size {
+		entries = entries[len(entries)-r.size:]
+	}
+
+	r.entries[topic] = r.expire(entries)
+}
+
+// Get returns up to limit of the most recent messages for a topic, oldest first.
+// Messages older than since are skipped, and only messages whose test match
+// accepts are returned.
+func (r *ReplayBuffer) Get(topic string, match func(testID string) bool, since time.Time, limit int) [][]byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := r.expire(r.entries[topic])
+
+	result := make([][]byte, 0, len(entries))
+	for _, entry := range entries {
+		if entry.timestamp.Before(since) {
+			continue
+		}
+		if !match(entry.testID) {
+			continue
+		}
+		result = append(result, entry.payload)
+	}
+
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+
+	return result
+}
+
+// expire drops entries older than the maximum age
+func (r *ReplayBuffer) expire(entries []replayEntry) []replayEntry {
+	if r.maxAge <= 0 {
+		return entries
+	}
+
+	cutoff := time.Now().Add(-r.maxAge)
+	for i, entry := range entries {
+		if entry.timestamp.After(cutoff) {
+			return entries[i:]
+		}
+	}
+	return entries[:0]
+}
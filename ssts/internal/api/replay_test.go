@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	all := func(string) bool { return true }
+	fill := func(buffer *ReplayBuffer, n int) {
+		for i := 0; i < n; i++ {
+			testID := "a"
+			if i%2 == 1 {
+				testID = "b"
+			}
+			buffer.Add("metrics", testID, []byte{byte('0' + i)})
+		}
+	}
+
+	tests := []struct {
+		name  string
+		size  int
+		added int
+		match func(string) bool
+		since time.Time
+		limit int
+		want  string
+	}{
+		{"oldest first", 5, 3, all, time.Time{}, 0, "012"},
+		{"only the newest retained", 3, 5, all, time.Time{}, 0, "234"},
+		{"limited to the newest", 5, 5, all, time.Time{}, 2, "34"},
+		{"negative limit is no limit", 5, 3, all, time.Time{}, -1, "012"},
+		{"filtered by test", 5, 5, func(id string) bool { return id == "a" }, time.Time{}, 0, "024"},
+		{"filtered before the limit", 5, 5, func(id string) bool { return id == "b" }, time.Time{}, 1, "3"},
+		{"nothing since the future", 5, 3, all, time.Now().Add(time.Hour), 0, ""},
+		{"disabled", 0, 3, all, time.Time{}, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := NewReplayBuffer(tt.size, 0)
+			fill(buffer, tt.added)
+			got := ""
+			for _, payload := range buffer.Get("metrics", tt.match, tt.since, tt.limit) {
+				got += string(payload)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	buffer := NewReplayBuffer(5, 0)
+	fill(buffer, 2)
+	if got := buffer.Get("alerts", all, time.Time{}, 0); len(got) != 0 {
+		t.Errorf("unknown topic replayed %d messages", len(got))
+	}
+}
+
+func TestReplayBufferExpiry(t *testing.T) {
+	buffer := NewReplayBuffer(10, 30*time.Millisecond)
+	buffer.Add("metrics", "", []byte("old"))
+	time.Sleep(50 * time.Millisecond)
+	buffer.Add("metrics", "", []byte("new"))
+
+	got := buffer.Get("metrics", func(string) bool { return true }, time.Time{}, 0)
+	if len(got) != 1 || string(got[0]) != "new" {
+		t.Errorf("replayed %q, want only the message within the maximum age", got)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := buffer.Get("metrics", func(string) bool { return true }, time.Time{}, 0); len(got) != 0 {
+		t.Errorf("replayed %q after every message expired", got)
+	}
+}
+
+func TestHandleReplayRequests(t *testing.T) {
+	hub := NewWebSocketHub(config.WebSocketConfig{ReplayBufferSize: 10})
+	for i := 0; i < 3; i++ {
+		hub.BroadcastMetrics("t1", i)
+	}
+	client := &WSClient{hub: hub, queue: newSendQueue(1), visible: func(string) bool { return true }}
+
+	tests := []struct {
+		name    string
+		request interface{}
+		want    int // messages replayed, -1 when no reply is sent
+	}{
+		{"not an object", "metrics_update", -1},
+		{"whole topic", map[string]interface{}{"topic": "metrics_update"}, 3},
+		{"limited", map[string]interface{}{"topic": "metrics_update", "limit": float64(2)}, 2},
+		{"other test", map[string]interface{}{"topic": "metrics_update", "test_id": "t2"}, 0},
+		{"since the future", map[string]interface{}{"topic": "metrics_update", "since": time.Now().Add(time.Hour).Format(time.RFC3339)}, 0},
+		{"unparsable since", map[string]interface{}{"topic": "metrics_update", "since": "yesterday"}, 3},
+		{"unknown topic", map[string]interface{}{"topic": "nope"}, 0},
+	}
+	for _, tt := range tests {
+		client.handleReplay(tt.request)
+		got := -1
+		if messages, _, _ := client.queue.take(); len(messages) > 0 {
+			data := messages[0]
+			var reply struct {
+				Type string `json:"type"`
+				Data struct {
+					Messages []json.RawMessage `json:"messages"`
+				} `json:"data"`
+			}
+			json.Unmarshal(data, &reply)
+			if reply.Type != "replay" {
+				t.Errorf("%s: reply type %q", tt.name, reply.Type)
+			}
+			got = len(reply.Data.Messages)
+		}
+		if got != tt.want {
+			t.Errorf("%s: replayed %d messages, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ReviewExecutionRequest moves the review of a finished execution on
+type ReviewExecutionRequest struct {
+	Status models.ReviewStatus `json:"status" binding:"required,oneof=pending in_review passed failed needs_investigation"` // in_review, passed, failed, needs_investigation or pending
+	Notes  string              `json:"notes"`                                                                               // required for failed and needs_investigation
+}
+
+// finishedStatus reports whether an execution with the status has finished
+func finishedStatus(status models.ExecutionStatus) bool {
+	switch status {
+	case models.StatusCompleted, models.StatusFailed, models.StatusStopped, models.StatusTimedOut:
+		return true
+	}
+	return false
+}
+
+// @Summary Review execution
+// @Description Move the human sign-off of a finished execution on, so burn-in results are reviewed before the hardware is deployed. Finished executions start pending. A reviewer claims one with in_review and gives a verdict of passed, failed or needs_investigation; failed and needs_investigation need notes saying why. An execution needing investigation can be claimed again or given a final verdict. Moving back to pending releases a claim, which takes the reviewer holding it, a project owner or an admin, or reopens a passed or failed review, which takes a project owner or an admin. A verdict on an execution another reviewer has claimed also takes a project owner or an admin.
+// @Tags executions
+// @Accept json
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Param review body ReviewExecutionRequest true "Review"
+// @Success 200 {object} models.TestExecution
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/review [put]
+func (s *Server) reviewExecution(c *gin.Context) {
+	var request ReviewExecutionRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+	if len(request.Notes) > models.MaxReviewNotesLength {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Review notes are longer than %d characters", models.MaxReviewNotesLength))
+		return
+	}
+	if (request.Status == models.ReviewFailed || request.Status == models.ReviewNeedsInvestigation) && request.Notes == "" {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Notes are required for %s", request.Status))
+		return
+	}
+
+	// Reviews are read from the repository, which finished executions are
+	// persisted to; the orchestrator's copy doesn't carry them
+	execution, err := s.repo.GetTestExecution(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Execution not found")
+		} else {
+			s.logger.Error("Failed to get execution", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get execution")
+		}
+		return
+	}
+	if execution.ProjectID != currentProjectID(c) {
+		respondError(c, http.StatusNotFound, "Execution not found")
+		return
+	}
+	if !finishedStatus(execution.Status) {
+		respondErrorCode(c, http.StatusConflict, CodeExecutionRunning, "Execution has not finished", nil)
+		return
+	}
+
+	// Executions that finished before reviews were recorded are pending
+	current := execution.ReviewStatus
+	if current == "" {
+		current = models.ReviewPending
+	}
+	if !current.CanTransition(request.Status) {
+		respondErrorCode(c, http.StatusConflict, CodeReviewConflict,
+			fmt.Sprintf("Review can't move from %s to %s", current, request.Status), nil)
+		return
+	}
+
+	userID := c.GetString(contextUserIDKey)
+	switch {
+	case current == models.ReviewPassed || current == models.ReviewFailed:
+		// Reopening a final verdict
+		if !s.checkOwner(c, "") {
+			return
+		}
+	case current == models.ReviewInReview && execution.Reviewer != userID:
+		if !s.checkOwner(c, execution.Reviewer) {
+			return
+		}
+	}
+
+	review := models.ExecutionReview{
+		Status:   request.Status,
+		Reviewer: userID,
+		Notes:    request.Notes,
+		At:       time.Now(),
+	}
+	if err := s.repo.UpdateExecutionReview(execution.ID, execution.ReviewStatus, review); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondErrorCode(c, http.StatusConflict, CodeReviewConflict, "Review was changed by someone else; reload it and try again", nil)
+		} else {
+			s.logger.Error("Failed to update review", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to update review")
+		}
+		return
+	}
+	s.logger.Info("Execution review updated",
+		zap.String("execution_id", execution.ID),
+		zap.String("from", string(current)),
+		zap.String("to", string(request.Status)),
+		zap.String("reviewer", userID))
+
+	execution.ReviewStatus = review.Status
+	execution.Reviewer = review.Reviewer
+	execution.ReviewNotes = review.Notes
+	execution.Reviewed = &review.At
+	c.JSON(http.StatusOK, execution)
+}
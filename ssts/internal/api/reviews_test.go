@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestReviewExecution(t *testing.T) {
+	server, repo, project := newOwnershipTestServer(t)
+
+	finished := models.TestExecution{TestID: "burn-in", ProjectID: project.ID, Status: models.StatusCompleted, ReviewStatus: models.ReviewPending}
+	repo.CreateTestExecution(&finished)
+	running := models.TestExecution{TestID: "burn-in", ProjectID: project.ID, Status: models.StatusRunning}
+	repo.CreateTestExecution(&running)
+
+	steps := []struct {
+		name   string
+		user   string
+		id     string
+		review ReviewExecutionRequest
+		want   int
+		code   string
+	}{
+		{"running execution", "bob", running.ID, ReviewExecutionRequest{Status: models.ReviewInReview}, http.StatusConflict, CodeExecutionRunning},
+		{"unknown status", "bob", finished.ID, ReviewExecutionRequest{Status: "approved"}, http.StatusBadRequest, ""},
+		{"failure without notes", "bob", finished.ID, ReviewExecutionRequest{Status: models.ReviewFailed}, http.StatusBadRequest, ""},
+		{"bob claims it", "bob", finished.ID, ReviewExecutionRequest{Status: models.ReviewInReview}, http.StatusOK, ""},
+		{"claimed twice", "bob", finished.ID, ReviewExecutionRequest{Status: models.ReviewInReview}, http.StatusConflict, CodeReviewConflict},
+		{"carol overrides bob's claim", "carol", finished.ID, ReviewExecutionRequest{Status: models.ReviewPassed}, http.StatusForbidden, ""},
+		{"bob asks for investigation", "bob", finished.ID, ReviewExecutionRequest{Status: models.ReviewNeedsInvestigation, Notes: "ECC errors on DIMM 3"}, http.StatusOK, ""},
+		{"carol passes it", "carol", finished.ID, ReviewExecutionRequest{Status: models.ReviewPassed, Notes: "DIMM 3 reseated, clean rerun"}, http.StatusOK, ""},
+		{"passed is final", "carol", finished.ID, ReviewExecutionRequest{Status: models.ReviewFailed, Notes: "changed my mind"}, http.StatusConflict, CodeReviewConflict},
+		{"reopening takes an owner", "carol", finished.ID, ReviewExecutionRequest{Status: models.ReviewPending}, http.StatusForbidden, ""},
+		{"admin reopens it", "root", finished.ID, ReviewExecutionRequest{Status: models.ReviewPending, Notes: "same batch failed elsewhere"}, http.StatusOK, ""},
+	}
+	for _, step := range steps {
+		rec := server.serveAs(step.user, http.MethodPut, "/api/v1/executions/"+step.id+"/review", step.review)
+		if rec.Code != step.want {
+			t.Fatalf("%s: status %d, want %d: %s", step.name, rec.Code, step.want, rec.Body)
+		}
+		if step.code != "" {
+			var body ErrorResponse
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if body.Code != step.code {
+				t.Errorf("%s: code %q, want %q", step.name, body.Code, step.code)
+			}
+		}
+		if step.name == "carol passes it" {
+			stored, _ := repo.GetTestExecution(finished.ID)
+			if stored.ReviewStatus != models.ReviewPassed || stored.Reviewer != "carol" || stored.ReviewNotes == "" || stored.Reviewed == nil {
+				t.Errorf("stored review = %s by %q, notes %q", stored.ReviewStatus, stored.Reviewer, stored.ReviewNotes)
+			}
+		}
+	}
+
+	rec := server.serveAs("bob", http.MethodGet, "/api/v1/executions?review_status=pending,in_review", nil)
+	var pending []models.TestExecution
+	json.Unmarshal(rec.Body.Bytes(), &pending)
+	if rec.Code != http.StatusOK || len(pending) != 1 || pending[0].ID != finished.ID {
+		t.Errorf("pending reviews: status %d, %d executions", rec.Code, len(pending))
+	}
+	if rec := server.serveAs("bob", http.MethodGet, "/api/v1/executions?review_status=approved", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown review status filter: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// maxRollupExecutions bounds how many executions a rollup reads; the most
+// recent are used
+const maxRollupExecutions = 5000
+
+// @Summary Roll up results by hardware model
+// @Description Group the results of completed executions by hardware model, the CPU model or disk models recorded in their system snapshot or the value of a label such as ssd_model, to spot a bad batch of drives or DIMMs. Each execution's result is one stat of one summary metric. Every group shows the distribution of its results, how far its median is from the fleet's, whether that makes the whole group an outlier, and the executions that are outliers within it (beyond 1.5 interquartile ranges of the quartiles). The execution list filters narrow which executions are included.
+// @Tags executions
+// @Produce json
+// @Param group_by query string true "cpu_model, disk_model or a label key"
+// @Param metric query string true "Summary metric key (source.type.field) or total name"
+// @Param stat query string false "avg, min, max, p95, p99 or total" default(avg)
+// @Param test_id query string false "Filter by test configuration ID"
+// @Param plugin query string false "Filter by plugin"
+// @Param started_after query string false "Only executions started at or after this time (RFC3339)"
+// @Param started_before query string false "Only executions started at or before this time (RFC3339)"
+// @Param label query string false "Label selector, e.g. rack=r12,gen!=g4,owner (repeatable; every term must match)"
+// @Param search query string false "ID of a saved search whose filters apply underneath the explicit ones"
+// @Success 200 {object} models.HardwareRollup
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/rollup [get]
+func (s *Server) rollupExecutions(c *gin.Context) {
+	query, ok := s.listQuery(c, models.SavedSearchExecutions)
+	if !ok {
+		return
+	}
+
+	groupBy, metric, stat := query.Get("group_by"), query.Get("metric"), query.Get("stat")
+	if groupBy == "" || metric == "" {
+		respondError(c, http.StatusBadRequest, "group_by and metric are required")
+		return
+	}
+	switch stat {
+	case "", models.ProfileStatAvg, models.ProfileStatMin, models.ProfileStatMax, models.ProfileStatP95, models.ProfileStatP99, models.ProfileStatTotal:
+	default:
+		respondError(c, http.StatusBadRequest, "stat must be avg, min, max, p95, p99 or total")
+		return
+	}
+
+	filter, err := executionFilter(query)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.ProjectID = currentProjectID(c)
+	filter.Statuses = []models.ExecutionStatus{models.StatusCompleted}
+	filter.Sort = []database.SortField{{Field: "start_time", Desc: true}}
+	filter.Limit = maxRollupExecutions
+	filter.Offset = 0
+
+	executions, err := s.repo.ListTestExecutionsFiltered(filter)
+	if err != nil {
+		s.logger.Error("Failed to list executions", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list executions")
+		return
+	}
+
+	c.JSON(http.StatusOK, core.RollupExecutions(executions, groupBy, metric, stat))
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestRollupExecutions(t *testing.T) {
+	server, repo := newTestServer(t)
+	project, _ := repo.GetProjectByName(models.DefaultProjectName)
+
+	record := func(disk string, iops float64, status models.ExecutionStatus) string {
+		summary, _ := json.Marshal(models.ExecutionSummary{
+			Metrics: map[string]models.MetricStats{"plugin.io.iops": {Count: 10, Avg: iops}},
+		})
+		execution := models.TestExecution{
+			TestID:    "fio",
+			ProjectID: project.ID,
+			Status:    status,
+			Summary:   summary,
+			System:    &models.SystemSnapshot{Hostname: fmt.Sprintf("host-%d", int(iops)), DiskModels: map[string]string{"nvme0n1": disk}},
+		}
+		repo.CreateTestExecution(&execution)
+		return execution.ID
+	}
+
+	for _, iops := range []float64{100, 101, 99, 102, 98, 100, 101} {
+		record("Good SSD", iops, models.StatusCompleted)
+	}
+	slow := record("Good SSD", 60, models.StatusCompleted)
+	for _, iops := range []float64{70, 71, 69} {
+		record("Bad Batch SSD", iops, models.StatusCompleted)
+	}
+	record("Bad Batch SSD", 10, models.StatusFailed)
+
+	rec := server.serve(http.MethodGet, "/api/v1/executions/rollup?group_by=disk_model&metric=plugin.io.iops", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rollup: status %d: %s", rec.Code, rec.Body)
+	}
+	var rollup models.HardwareRollup
+	json.Unmarshal(rec.Body.Bytes(), &rollup)
+
+	if rollup.Executions != 11 || rollup.Fleet.Count != 11 || rollup.Stat != models.ProfileStatAvg {
+		t.Fatalf("rollup = %+v, want the 11 completed executions by their average", rollup)
+	}
+	if len(rollup.Groups) != 2 {
+		t.Fatalf("groups = %+v, want one per disk model", rollup.Groups)
+	}
+	bad, good := rollup.Groups[0], rollup.Groups[1]
+	if bad.Model != "Bad Batch SSD" || !bad.Outlier || bad.Distribution.P50 != 70 || bad.DeviationPercent > -25 {
+		t.Errorf("bad batch = %+v, want it flagged well below the rest of the fleet", bad)
+	}
+	// Hosts are named after their result, and two pairs share one
+	if good.Model != "Good SSD" || good.Outlier || good.Hosts != 6 {
+		t.Errorf("good drives = %+v", good)
+	}
+	if len(good.Outliers) != 1 || good.Outliers[0].ExecutionID != slow {
+		t.Errorf("good drive outliers = %+v, want the one slow drive", good.Outliers)
+	}
+
+	for _, query := range []string{"metric=plugin.io.iops", "group_by=disk_model", "group_by=disk_model&metric=plugin.io.iops&stat=median"} {
+		if rec := server.serve(http.MethodGet, "/api/v1/executions/rollup?"+query, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
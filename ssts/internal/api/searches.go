@@ -0,0 +1,198 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// CreateSavedSearchRequest is the body for saving a search
+type CreateSavedSearchRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Resource string `json:"resource" binding:"required,oneof=tests executions"` // tests or executions
+	Query    string `json:"query"`                                              // list endpoint query string, e.g. plugin=cpu-stress&label=rack=r12
+}
+
+// listQuery returns the query parameters of a list request. When the search parameter
+// names a saved search of the caller's in the current project, its parameters are filled in underneath the
+// explicit ones; label selectors from both apply. It responds with an error and
+// returns false if the saved search can't be used.
+func (s *Server) listQuery(c *gin.Context, resource string) (url.Values, bool) {
+	query := c.Request.URL.Query()
+	id := query.Get("search")
+	if id == "" {
+		return query, true
+	}
+
+	search := s.projectSavedSearch(c, id)
+	if search == nil {
+		return nil, false
+	}
+	if search.Resource != resource {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Saved search lists %s, not %s", search.Resource, resource))
+		return nil, false
+	}
+
+	// Validated when the search was saved
+	saved, _ := url.ParseQuery(search.Query)
+	for key, values := range saved {
+		if key == "label" {
+			query[key] = append(query[key], values...)
+		} else if _, set := query[key]; !set {
+			query[key] = values
+		}
+	}
+	return query, true
+}
+
+// projectSavedSearch returns the caller's saved search with the given ID if it
+// belongs to the current project, responding with an error and returning nil if not
+func (s *Server) projectSavedSearch(c *gin.Context, id string) *models.SavedSearch {
+	search, err := s.repo.GetSavedSearch(id, c.GetString(contextUserIDKey))
+	if err == nil && search.ProjectID != currentProjectID(c) {
+		err = database.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Saved search not found")
+		} else {
+			s.logger.Error("Failed to get saved search", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to get saved search")
+		}
+		return nil
+	}
+	return search
+}
+
+// @Summary List saved searches
+// @Description Get the caller's saved searches in the project
+// @Tags searches
+// @Produce json
+// @Param resource query string false "Only searches listing this resource (tests or executions)"
+// @Success 200 {array} models.SavedSearch
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/searches [get]
+func (s *Server) listSavedSearches(c *gin.Context) {
+	searches, err := s.repo.ListSavedSearches(c.GetString(contextUserIDKey), c.Query("resource"))
+	if err != nil {
+		s.logger.Error("Failed to list saved searches", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list saved searches")
+		return
+	}
+
+	projectID := currentProjectID(c)
+	inProject := make([]models.SavedSearch, 0, len(searches))
+	for _, search := range searches {
+		if search.ProjectID == projectID {
+			inProject = append(inProject, search)
+		}
+	}
+
+	c.JSON(http.StatusOK, inProject)
+}
+
+// @Summary Save search
+// @Description Save a test or execution list query under a name in the project. Run it by passing its ID as the search parameter of the project's list endpoint.
+// @Tags searches
+// @Accept json
+// @Produce json
+// @Param search body CreateSavedSearchRequest true "Saved search"
+// @Success 201 {object} models.SavedSearch
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/searches [post]
+func (s *Server) createSavedSearch(c *gin.Context) {
+	var request CreateSavedSearchRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	query, err := url.ParseQuery(request.Query)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid query: "+err.Error())
+		return
+	}
+	delete(query, "search")
+	delete(query, "project_id")
+
+	switch request.Resource {
+	case models.SavedSearchTests:
+		_, err = testFilter(query)
+	case models.SavedSearchExecutions:
+		_, err = executionFilter(query)
+	default:
+		err = fmt.Errorf("resource must be %s or %s", models.SavedSearchTests, models.SavedSearchExecutions)
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	search := models.SavedSearch{
+		UserID:    c.GetString(contextUserIDKey),
+		ProjectID: currentProjectID(c),
+		Name:      request.Name,
+		Resource:  request.Resource,
+		Query:     query.Encode(),
+	}
+
+	if err := s.repo.CreateSavedSearch(&search); err != nil {
+		s.logger.Error("Failed to save search", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to save search")
+		return
+	}
+
+	c.JSON(http.StatusCreated, search)
+}
+
+// @Summary Get saved search
+// @Description Get one of the caller's saved searches in the project
+// @Tags searches
+// @Produce json
+// @Param id path string true "Saved search ID"
+// @Success 200 {object} models.SavedSearch
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/searches/{id} [get]
+func (s *Server) getSavedSearch(c *gin.Context) {
+	search := s.projectSavedSearch(c, c.Param("id"))
+	if search == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+// @Summary Delete saved search
+// @Description Delete one of the caller's saved searches in the project
+// @Tags searches
+// @Param id path string true "Saved search ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/searches/{id} [delete]
+func (s *Server) deleteSavedSearch(c *gin.Context) {
+	search := s.projectSavedSearch(c, c.Param("id"))
+	if search == nil {
+		return
+	}
+
+	if err := s.repo.DeleteSavedSearch(search.ID, search.UserID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Saved search not found")
+		} else {
+			s.logger.Error("Failed to delete saved search", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to delete saved search")
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestCreateSavedSearch(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		name      string
+		request   CreateSavedSearchRequest
+		want      int
+		wantQuery string
+	}{
+		{"no name", CreateSavedSearchRequest{Resource: models.SavedSearchTests}, http.StatusBadRequest, ""},
+		{"unknown resource", CreateSavedSearchRequest{Name: "x", Resource: "alerts"}, http.StatusBadRequest, ""},
+		{"malformed query", CreateSavedSearchRequest{Name: "x", Resource: models.SavedSearchTests, Query: "plugin=%zz"}, http.StatusBadRequest, ""},
+		{"invalid label", CreateSavedSearchRequest{Name: "x", Resource: models.SavedSearchTests, Query: "label=bad key"}, http.StatusBadRequest, ""},
+		{"unsortable field", CreateSavedSearchRequest{Name: "x", Resource: models.SavedSearchExecutions, Query: "sort=password"}, http.StatusBadRequest, ""},
+		{"invalid time", CreateSavedSearchRequest{Name: "x", Resource: models.SavedSearchExecutions, Query: "started_after=yesterday"}, http.StatusBadRequest, ""},
+		{"empty query", CreateSavedSearchRequest{Name: "all", Resource: models.SavedSearchTests}, http.StatusCreated, ""},
+		{"search and project dropped", CreateSavedSearchRequest{Name: "r12", Resource: models.SavedSearchExecutions, Query: "label=rack%3Dr12&search=s1&project_id=p1&status=failed"}, http.StatusCreated, "label=rack%3Dr12&status=failed"},
+	}
+	for _, tt := range tests {
+		rec := server.serve(http.MethodPost, "/api/v1/searches", tt.request)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if rec.Code != http.StatusCreated {
+			continue
+		}
+		var search models.SavedSearch
+		json.Unmarshal(rec.Body.Bytes(), &search)
+		if search.ID == "" || search.Query != tt.wantQuery {
+			t.Errorf("%s: saved %+v, want query %q", tt.name, search, tt.wantQuery)
+		}
+	}
+
+	var searches []models.SavedSearch
+	json.Unmarshal(server.serve(http.MethodGet, "/api/v1/searches?resource=executions", nil).Body.Bytes(), &searches)
+	if len(searches) != 1 || searches[0].Name != "r12" {
+		t.Errorf("saved execution searches = %+v", searches)
+	}
+}
+
+func TestSavedSearchesArePerUser(t *testing.T) {
+	server, repo := newAuthTestServer(t, nil)
+	lab := createProject(t, repo, "lab", models.ProjectRoleEditor)
+
+	rec := server.serveAs("bob", http.MethodPost, "/api/v1/searches?project_id="+lab.ID, CreateSavedSearchRequest{Name: "mine", Resource: models.SavedSearchTests})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("save: status %d: %s", rec.Code, rec.Body)
+	}
+	var search models.SavedSearch
+	json.Unmarshal(rec.Body.Bytes(), &search)
+
+	tests := []struct {
+		user   string
+		method string
+		want   int
+	}{
+		{"root", http.MethodGet, http.StatusNotFound},
+		{"root", http.MethodDelete, http.StatusNotFound},
+		{"bob", http.MethodGet, http.StatusOK},
+		{"bob", http.MethodDelete, http.StatusNoContent},
+		{"bob", http.MethodGet, http.StatusNotFound},
+		{"bob", http.MethodDelete, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := server.serveAs(tt.user, tt.method, "/api/v1/searches/"+search.ID+"?project_id="+lab.ID, nil); rec.Code != tt.want {
+			t.Errorf("%s %s as %s: status %d, want %d", tt.method, search.ID, tt.user, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestListWithSavedSearch(t *testing.T) {
+	server, repo := newTestServer(t)
+	for _, test := range []models.TestConfiguration{
+		{ID: "a", Name: "a", Plugin: "cpu-stress", Labels: models.Labels{"rack": "r12", "gen": "g4"}},
+		{ID: "b", Name: "b", Plugin: "cpu-stress", Labels: models.Labels{"rack": "r12", "gen": "g5"}},
+		{ID: "c", Name: "c", Plugin: "io-stress", Labels: models.Labels{"rack": "r12", "gen": "g5"}},
+		{ID: "d", Name: "d", Plugin: "cpu-stress", Labels: models.Labels{"rack": "r7"}},
+	} {
+		createDefaultProjectTest(t, repo, test)
+	}
+
+	save := func(resource, query string) string {
+		rec := server.serve(http.MethodPost, "/api/v1/searches", CreateSavedSearchRequest{Name: "s", Resource: resource, Query: query})
+		var search models.SavedSearch
+		json.Unmarshal(rec.Body.Bytes(), &search)
+		return search.ID
+	}
+	r12 := save(models.SavedSearchTests, "label=rack%3Dr12&plugin=cpu-stress")
+	executions := save(models.SavedSearchExecutions, "status=failed")
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+		code  int
+	}{
+		{"saved parameters", "search=" + r12, []string{"a", "b"}, http.StatusOK},
+		{"explicit parameters win", "search=" + r12 + "&plugin=io-stress", []string{"c"}, http.StatusOK},
+		{"label selectors add up", "search=" + r12 + "&label=gen%3Dg5", []string{"b"}, http.StatusOK},
+		{"search of another resource", "search=" + executions, nil, http.StatusBadRequest},
+		{"unknown search", "search=missing", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := server.serve(http.MethodGet, "/api/v1/tests?"+tt.query, nil)
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.code, rec.Body)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var tests []models.TestConfiguration
+		json.Unmarshal(rec.Body.Bytes(), &tests)
+		var names []string
+		for _, test := range tests {
+			names = append(names, test.Name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, names, tt.want)
+		}
+	}
+}
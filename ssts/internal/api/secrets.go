@@ -0,0 +1,49 @@
+package api
+
+import (
+	"github.com/pranavgopavaram/ssts/internal/secrets"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// redactTest returns a test with its secrets redacted, for responses
+func redactTest(test models.TestConfiguration) models.TestConfiguration {
+	test.Config = secrets.RedactConfig(test.Config)
+	test.Probes = secrets.RedactProbes(test.Probes)
+	return test
+}
+
+// redactTests redacts the secrets of tests, for responses
+func redactTests(tests []models.TestConfiguration) []models.TestConfiguration {
+	redacted := make([]models.TestConfiguration, len(tests))
+	for i, test := range tests {
+		redacted[i] = redactTest(test)
+	}
+	return redacted
+}
+
+// restoreTestSecrets keeps the stored secrets of a test updated by a client
+// that sent them back redacted
+func restoreTestSecrets(test *models.TestConfiguration, stored models.TestConfiguration) {
+	test.Config = secrets.RestoreConfig(test.Config, stored.Config)
+	test.Probes = secrets.RestoreProbes(test.Probes, stored.Probes)
+}
+
+// redactUser returns a user with the webhook URLs in their notification
+// preferences redacted, for responses
+func redactUser(user *models.User) *models.User {
+	prefs, err := user.NotificationPreferences()
+	if err != nil {
+		// Unreadable preferences can't be redacted selectively
+		redacted := *user
+		redacted.Preferences = nil
+		return &redacted
+	}
+	if prefs.SlackWebhookURL == "" && prefs.WebhookURL == "" {
+		return user
+	}
+	redacted := *user
+	if err := redacted.SetNotificationPreferences(secrets.RedactNotificationPreferences(prefs)); err != nil {
+		redacted.Preferences = nil
+	}
+	return &redacted
+}
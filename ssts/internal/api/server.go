@@ -2,8 +2,13 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -13,41 +18,94 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"go.uber.org/zap"
 
+	"github.com/pranavgopavaram/ssts/internal/agentupdate"
 	"github.com/pranavgopavaram/ssts/internal/config"
 	"github.com/pranavgopavaram/ssts/internal/core"
 	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/faults"
+	"github.com/pranavgopavaram/ssts/internal/metrics"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/secrets"
 	"github.com/pranavgopavaram/ssts/pkg/models"
 )
 
 // Server represents the HTTP server
 type Server struct {
 	config       *config.Config
-	db           *database.Database
+	repo         database.Repository
 	influxDB     *database.InfluxDB
+	timeSeries   database.TimeSeriesStore
 	orchestrator *core.Orchestrator
 	wsHub        *WebSocketHub
 	logger       *zap.Logger
 	engine       *gin.Engine
+	throttled    throttleCounters
+	exports      *exportJobs
+	ingestDedup  *metrics.Deduplicator
+	fleet        fleetActivity
+	agentUpdates *agentupdate.Store // nil unless agent updates are enabled
+	flux         database.FluxQuerier
+	configDrift  configDriftState
+	healthCache  healthCache
 }
 
 // NewServer creates a new API server
-func NewServer(cfg *config.Config, db *database.Database, orchestrator *core.Orchestrator, logger *zap.Logger) *Server {
+func NewServer(cfg *config.Config, repo database.Repository, orchestrator *core.Orchestrator, logger *zap.Logger) *Server {
 	// Initialize InfluxDB
 	influxDB := database.NewInfluxDB(cfg.InfluxDB)
 
 	// Initialize WebSocket hub
-	wsHub := NewWebSocketHub()
+	wsHub := NewWebSocketHub(cfg.Server.WebSocket)
 	go wsHub.Run()
 
 	server := &Server{
 		config:       cfg,
-		db:           db,
+		repo:         repo,
 		influxDB:     influxDB,
+		timeSeries:   influxDB,
 		orchestrator: orchestrator,
 		wsHub:        wsHub,
 		logger:       logger,
+		exports:      newExportJobs(cfg.Export),
+		ingestDedup:  metrics.NewDeduplicator(cfg.Ingest.DedupWindow),
 	}
 
+	if cfg.InfluxDB.Flux.Enabled {
+		server.flux = database.NewInfluxFluxQuerier(cfg.InfluxDB)
+	}
+
+	if updates := cfg.Ingest.AgentUpdates; updates.Enabled {
+		store, err := agentupdate.NewStore(updates.ReleaseDir, updates.PublicKey)
+		if err != nil {
+			logger.Error("Agent updates disabled", zap.Error(err))
+		} else {
+			server.agentUpdates = store
+		}
+	}
+
+	// Push metrics streamed by plugins to WebSocket clients
+	orchestrator.AddMetricSink(newWSMetricSink(wsHub))
+
+	// Push running executions' progress to WebSocket clients
+	orchestrator.OnProgress(func(execution models.TestExecution) {
+		wsHub.BroadcastProgress(execution.TestID, execution.ID, *execution.Progress)
+	})
+
+	// Alert WebSocket clients to anomalies in running executions' metrics, and
+	// mark them on the executions' charts
+	orchestrator.OnAnomaly(func(anomaly models.Anomaly, annotation *models.Annotation) {
+		wsHub.BroadcastAlert("anomaly", anomaly.Message(), "warning")
+		if annotation != nil {
+			wsHub.BroadcastAnnotation(anomaly.TestID, "created", *annotation)
+		}
+	})
+
+	// Tell WebSocket clients when watched plugin directories change the plugins
+	orchestrator.OnPluginsChanged(wsHub.BroadcastPluginsChanged)
+
+	// Show users the notifications they chose the browser for on their open dashboards
+	orchestrator.AddNotificationChannel(models.ChannelBrowser, wsNotificationChannel{hub: wsHub})
+
 	server.setupRoutes()
 	return server
 }
@@ -62,89 +120,52 @@ func (s *Server) setupRoutes() {
 	}
 
 	s.engine = gin.New()
+	// Only believe X-Forwarded-For from configured proxies, so clients can't pick
+	// the IP their requests are limited and logged under. Validate has checked
+	// the list.
+	if err := s.engine.SetTrustedProxies(s.config.Server.TrustedProxies); err != nil {
+		s.logger.Error("Invalid trusted proxies, trusting none", zap.Error(err))
+		s.engine.SetTrustedProxies(nil)
+	}
 
 	// Middleware
 	s.engine.Use(gin.Recovery())
 	s.engine.Use(s.loggingMiddleware())
 	s.engine.Use(s.corsMiddleware())
+	s.engine.Use(s.envelopeMiddleware())
+	if s.config.Server.RateLimit.Enabled {
+		s.engine.Use(s.rateLimitMiddleware(s.config.Server.RateLimit))
+	}
 
 	// Health check
 	s.engine.GET("/health", s.healthCheck)
+	s.engine.GET("/livez", s.livenessCheck)
+	s.engine.GET("/readyz", s.readinessCheck)
 
-	// API routes
-	api := s.engine.Group("/api/v1")
-	{
-		// Authentication routes (if enabled)
-		if s.config.Auth.Enabled {
-			auth := api.Group("/auth")
-			{
-				auth.POST("/login", s.login)
-				auth.POST("/logout", s.logout)
-				auth.POST("/refresh", s.refreshToken)
-			}
-			// Apply auth middleware to protected routes
-			api.Use(s.authMiddleware())
-		}
-
-		// Test configuration routes
-		tests := api.Group("/tests")
-		{
-			tests.GET("", s.listTests)
-			tests.POST("", s.createTest)
-			tests.GET("/:id", s.getTest)
-			tests.PUT("/:id", s.updateTest)
-			tests.DELETE("/:id", s.deleteTest)
-			tests.POST("/:id/run", s.runTest)
-			tests.POST("/:id/stop", s.stopTest)
-			tests.GET("/:id/status", s.getTestStatus)
-			tests.GET("/:id/results", s.getTestResults)
-			tests.GET("/:id/metrics", s.getTestMetrics)
-			tests.POST("/:id/export", s.exportTestData)
-		}
-
-		// Test execution routes
-		executions := api.Group("/executions")
-		{
-			executions.GET("", s.listExecutions)
-			executions.GET("/:id", s.getExecution)
-			executions.POST("/:id/stop", s.stopExecution)
-			executions.GET("/:id/metrics", s.getExecutionMetrics)
-			executions.GET("/:id/logs", s.getExecutionLogs)
-		}
+	// Prometheus metrics
+	s.engine.GET("/metrics", s.prometheusMetrics)
 
-		// Plugin routes
-		plugins := api.Group("/plugins")
-		{
-			plugins.GET("", s.listPlugins)
-			plugins.GET("/:name", s.getPlugin)
-			plugins.GET("/:name/schema", s.getPluginSchema)
-			plugins.POST("/:name/validate", s.validatePluginConfig)
-		}
+	// Public status page, read-only and served without authentication
+	if s.config.Server.StatusPage.Enabled {
+		s.engine.GET("/status", s.statusPage)
+	}
 
-		// System routes
-		system := api.Group("/system")
-		{
-			system.GET("/metrics", s.getSystemMetrics)
-			system.GET("/health", s.getSystemHealth)
-			system.GET("/info", s.getSystemInfo)
-		}
+	// API routes. v1 stays as it is, announcing its deprecation; v2 serves the
+	// same routes with responses wrapped in an envelope.
+	s.registerAPIRoutes(s.engine.Group(apiV1, s.deprecationMiddleware(s.config.Server.Deprecation)))
+	s.registerAPIRoutes(s.engine.Group(apiV2))
 
-		// User routes (if auth enabled)
-		if s.config.Auth.Enabled {
-			users := api.Group("/users")
-			{
-				users.GET("/profile", s.getUserProfile)
-				users.PUT("/profile", s.updateUserProfile)
-				users.POST("/change-password", s.changePassword)
-			}
-		}
+	// WebSocket endpoint. Clients connect to a project, chosen as for API requests,
+	// and are only replayed messages about its tests.
+	if s.config.Auth.Enabled {
+		s.engine.GET("/ws", s.authMiddleware(), s.projectMiddleware(), s.handleWebSocket)
+	} else {
+		s.engine.GET("/ws", s.projectMiddleware(), s.handleWebSocket)
 	}
 
-	// WebSocket endpoint
-	s.engine.GET("/ws", s.handleWebSocket)
-
-	// Swagger documentation
+	// Swagger documentation, and the OpenAPI 3 document generated from it
 	s.engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	s.engine.GET("/openapi.json", s.serveOpenAPISpec)
 
 	// Static files for web dashboard
 	s.engine.Static("/static", "./web/dist/static")
@@ -161,6 +182,213 @@ func (s *Server) setupRoutes() {
 	})
 }
 
+// registerAPIRoutes registers the API's routes under a version's group
+func (s *Server) registerAPIRoutes(api *gin.RouterGroup) {
+	// Metric ingestion from remote agents, authenticated with agent tokens
+	// rather than user credentials
+	if s.config.Ingest.Enabled {
+		api.POST("/ingest/metrics", s.agentAuthMiddleware(), s.ingestMetrics)
+
+		agent := api.Group("/agent", s.agentAuthMiddleware())
+		{
+			agent.POST("/handshake", s.agentHandshake)
+			agent.GET("/releases/:version/:os/:arch", s.downloadAgentRelease)
+		}
+	}
+
+	// Public status, registered ahead of the auth middleware
+	if s.config.Server.StatusPage.Enabled {
+		api.GET("/status", s.getPublicStatus)
+	}
+
+	// Authentication routes (if enabled)
+	if s.config.Auth.Enabled {
+		auth := api.Group("/auth")
+		{
+			auth.POST("/login", s.login)
+			auth.POST("/logout", s.logout)
+			auth.POST("/refresh", s.refreshToken)
+		}
+		// Apply auth middleware to protected routes
+		api.Use(s.authMiddleware())
+	}
+
+	// Test configuration routes
+	tests := api.Group("/tests", s.projectMiddleware(), s.requireTestInProject())
+	{
+		tests.GET("", s.listTests)
+		tests.POST("", s.createTest)
+		tests.GET("/:id", s.getTest)
+		tests.PUT("/:id", s.updateTest)
+		tests.DELETE("/:id", s.deleteTest)
+		tests.POST("/:id/run", s.runTest)
+		tests.POST("/:id/stop", s.stopTest)
+		tests.GET("/:id/status", s.getTestStatus)
+		tests.GET("/:id/prerequisites", s.checkTestPrerequisites)
+		tests.GET("/:id/results", s.getTestResults)
+		tests.GET("/:id/metrics", s.getTestMetrics)
+		tests.POST("/:id/export", s.exportTestData)
+	}
+
+	// Test execution routes
+	executions := api.Group("/executions", s.projectMiddleware(), s.requireExecutionInProject())
+	{
+		executions.GET("", s.listExecutions)
+		executions.GET("/compare", s.compareExecutions)
+		executions.GET("/rollup", s.rollupExecutions)
+		executions.GET("/running", s.listRunningExecutions)
+		executions.POST("/import", s.importExecution)
+		executions.GET("/:id", s.getExecution)
+		executions.POST("/:id/stop", s.stopExecution)
+		executions.POST("/:id/extend", s.extendExecution)
+		executions.POST("/:id/pause", s.pauseExecution)
+		executions.POST("/:id/resume", s.resumeExecution)
+		executions.GET("/:id/metrics", s.getExecutionMetrics)
+		executions.GET("/:id/plugin-metrics", s.getExecutionPluginMetrics)
+		executions.GET("/:id/charts", s.getExecutionCharts)
+		executions.GET("/:id/metrics/download", s.downloadExecutionMetrics)
+		executions.GET("/:id/logs", s.getExecutionLogs)
+		executions.GET("/:id/diagnostics", s.downloadExecutionDiagnostics)
+		executions.GET("/:id/annotations", s.listAnnotations)
+		executions.GET("/:id/violations", s.listViolations)
+		executions.POST("/:id/annotations", s.createAnnotation)
+		executions.DELETE("/:id/annotations/:annotation_id", s.deleteAnnotation)
+		executions.GET("/:id/grade", s.gradeExecution)
+		executions.PUT("/:id/review", s.reviewExecution)
+	}
+
+	// Run queue routes
+	queue := api.Group("/queue", s.projectMiddleware(), s.requireExecutionInProject())
+	{
+		queue.GET("", s.getQueue)
+		queue.PUT("/:id/position", s.requireServerAdmin(), s.moveQueuedExecution)
+		queue.DELETE("/:id", s.cancelQueuedExecution)
+	}
+
+	// Test catalog import/export routes
+	bundles := api.Group("/bundles", s.projectMiddleware())
+	{
+		bundles.GET("", s.exportBundle)
+		bundles.POST("", s.importBundle)
+	}
+
+	// Background export routes
+	exports := api.Group("/exports", s.projectMiddleware())
+	{
+		exports.GET("/:id", s.getExportJob)
+		exports.GET("/:id/download", s.downloadExport)
+	}
+
+	// Reference hardware profile routes. Profiles are shared by all projects, but
+	// reading them still takes a role in one.
+	profiles := api.Group("/profiles", s.projectMiddleware())
+	{
+		profiles.GET("", s.listProfiles)
+		profiles.POST("", s.requireAdmin(), s.importProfiles)
+		profiles.GET("/:id", s.getProfile)
+		profiles.DELETE("/:id", s.requireAdmin(), s.deleteProfile)
+	}
+
+	// Project routes
+	projects := api.Group("/projects")
+	{
+		projects.GET("", s.listProjects)
+		projects.POST("", s.requireServerAdmin(), s.createProject)
+		projects.GET("/:id", s.getProject)
+		projects.DELETE("/:id", s.deleteProject)
+		projects.GET("/:id/members", s.listProjectMembers)
+		projects.PUT("/:id/members/:user_id", s.setProjectMember)
+		projects.DELETE("/:id/members/:user_id", s.removeProjectMember)
+	}
+
+	// Saved search routes
+	searches := api.Group("/searches", s.projectMiddleware())
+	{
+		searches.GET("", s.listSavedSearches)
+		searches.POST("", s.createSavedSearch)
+		searches.GET("/:id", s.getSavedSearch)
+		searches.DELETE("/:id", s.deleteSavedSearch)
+	}
+
+	// Alert routes
+	alerts := api.Group("/alerts", s.projectMiddleware())
+	{
+		alerts.GET("", s.listAlerts)
+		alerts.GET("/mutes", s.listAlertMuteRules)
+		alerts.POST("/mutes", s.requireServerAdmin(), s.createAlertMuteRule)
+		alerts.DELETE("/mutes/:id", s.requireServerAdmin(), s.deleteAlertMuteRule)
+		alerts.GET("/:id", s.getAlert)
+		alerts.POST("/:id/acknowledge", s.acknowledgeAlert)
+	}
+
+	// Plugin routes
+	plugins := api.Group("/plugins")
+	{
+		plugins.GET("", s.listPlugins)
+		plugins.GET("/schemas", s.listPluginSchemas)
+		plugins.GET("/:name", s.getPlugin)
+		plugins.GET("/:name/schema", s.getPluginSchema)
+		plugins.POST("/:name/validate", s.validatePluginConfig)
+		plugins.POST("", s.requireAdmin(), s.registerPlugin)
+		plugins.DELETE("/:name", s.requireAdmin(), s.removePlugin)
+		plugins.POST("/:name/enable", s.requireAdmin(), s.enablePlugin)
+		plugins.POST("/:name/disable", s.requireAdmin(), s.disablePlugin)
+	}
+
+	// Agent token routes
+	agents := api.Group("/agents", s.requireAdmin())
+	{
+		agents.GET("", s.listAgentTokens)
+		agents.POST("", s.createAgentToken)
+		agents.DELETE("/:id", s.revokeAgentToken)
+	}
+
+	// Time-series query routes
+	api.GET("/metrics/query", s.projectMiddleware(), s.queryMetrics)
+	if s.config.InfluxDB.Flux.Enabled {
+		api.POST("/flux/query", s.requireAdmin(), s.queryFlux)
+	}
+
+	// Fleet overview of the project's hosts
+	api.GET("/fleet", s.projectMiddleware(), s.getFleet)
+
+	// Usage of the project's executions, for chargeback
+	api.GET("/usage", s.projectMiddleware(), s.getUsage)
+
+	// System routes
+	system := api.Group("/system")
+	{
+		system.GET("/metrics", s.getSystemMetrics)
+		system.GET("/health", s.getSystemHealth)
+		system.GET("/info", s.getSystemInfo)
+		system.GET("/endurance", s.listDeviceEndurance)
+		system.GET("/config", s.requireAdmin(), s.getSystemConfig)
+	}
+
+	// Failpoint control, only in builds with the failpoints tag
+	if faults.Enabled {
+		failpoints := api.Group("/debug/failpoints", s.requireAdmin())
+		{
+			failpoints.GET("", s.listFailpoints)
+			failpoints.PUT("/*name", s.setFailpoint)
+			failpoints.DELETE("/*name", s.clearFailpoint)
+		}
+	}
+
+	// User routes (if auth enabled)
+	if s.config.Auth.Enabled {
+		users := api.Group("/users")
+		{
+			users.GET("/profile", s.getUserProfile)
+			users.PUT("/profile", s.updateUserProfile)
+			users.POST("/change-password", s.changePassword)
+			users.GET("/notifications", s.getNotificationPreferences)
+			users.PUT("/notifications", s.updateNotificationPreferences)
+			users.GET("/notifications/web-push", s.getWebPushKey)
+		}
+	}
+}
+
 // Start starts the HTTP server
 func (s *Server) Start(ctx context.Context) error {
 	addr := fmt.Sprintf("%s:%d", s.config.Server.Address, s.config.Server.Port)
@@ -172,6 +400,11 @@ func (s *Server) Start(ctx context.Context) error {
 		WriteTimeout: s.config.Server.WriteTimeout,
 	}
 
+	// Watch for the configuration file drifting from the configuration in effect
+	if interval := s.config.Server.ConfigDriftInterval; interval > 0 && s.config.File != "" {
+		go s.watchConfigDrift(ctx, interval)
+	}
+
 	// Start server in goroutine
 	serverErr := make(chan error, 1)
 	go func() {
@@ -192,13 +425,29 @@ func (s *Server) Start(ctx context.Context) error {
 	case err := <-serverErr:
 		return fmt.Errorf("server error: %w", err)
 	case <-ctx.Done():
+		s.logger.Info("Shutting down, draining test executions")
+
+		// Refuse new runs while the API stays up so clients can watch the drain
+		s.orchestrator.BeginDrain()
+		if stopped := s.orchestrator.Drain(context.Background()); stopped > 0 {
+			s.logger.Warn("Stopped running executions for shutdown", zap.Int("count", stopped))
+		}
+
+		// Hijacked WebSocket connections aren't closed by server.Shutdown
+		s.wsHub.Shutdown()
+
 		s.logger.Info("Shutting down HTTP server")
 
 		// Graceful shutdown with timeout
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		if err := server.Shutdown(shutdownCtx); err != nil {
+		err := server.Shutdown(shutdownCtx)
+
+		// Flush any buffered metric writes
+		s.influxDB.Close()
+
+		if err != nil {
 			s.logger.Error("Server shutdown error", zap.Error(err))
 			return err
 		}
@@ -208,6 +457,20 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 }
 
+// Run starts the server and blocks until SIGINT or SIGTERM, then drains running
+// executions, shuts down the server and cleans up the orchestrator
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := s.Start(ctx)
+
+	if cleanupErr := s.orchestrator.Cleanup(); cleanupErr != nil && err == nil {
+		err = cleanupErr
+	}
+	return err
+}
+
 // Middleware functions
 
 func (s *Server) loggingMiddleware() gin.HandlerFunc {
@@ -219,7 +482,7 @@ func (s *Server) loggingMiddleware() gin.HandlerFunc {
 		c.Next()
 
 		if raw != "" {
-			path = path + "?" + raw
+			path = path + "?" + secrets.RedactQuery(raw)
 		}
 
 		s.logger.Info("HTTP request",
@@ -243,46 +506,6 @@ func (s *Server) corsMiddleware() gin.HandlerFunc {
 	return cors.New(config)
 }
 
-func (s *Server) authMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// TODO: Implement JWT authentication
-		// For now, just pass through
-		c.Next()
-	}
-}
-
-// Health check endpoint
-func (s *Server) healthCheck(c *gin.Context) {
-	health := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC(),
-		"version":   "1.0.0",
-		"services":  make(map[string]string),
-	}
-
-	// Check database health
-	if err := s.db.HealthCheck(); err != nil {
-		health["services"].(map[string]string)["database"] = "unhealthy"
-		health["status"] = "degraded"
-	} else {
-		health["services"].(map[string]string)["database"] = "healthy"
-	}
-
-	// Check InfluxDB health
-	if err := s.influxDB.HealthCheck(context.Background()); err != nil {
-		health["services"].(map[string]string)["influxdb"] = "unhealthy"
-		health["status"] = "degraded"
-	} else {
-		health["services"].(map[string]string)["influxdb"] = "healthy"
-	}
-
-	if health["status"] == "healthy" {
-		c.JSON(http.StatusOK, health)
-	} else {
-		c.JSON(http.StatusServiceUnavailable, health)
-	}
-}
-
 // Test configuration handlers
 
 // @Summary List test configurations
@@ -292,22 +515,82 @@ func (s *Server) healthCheck(c *gin.Context) {
 // @Produce json
 // @Param limit query int false "Limit number of results" default(50)
 // @Param offset query int false "Offset for pagination" default(0)
+// @Param plugin query string false "Filter by plugin"
+// @Param created_by query string false "Filter by creator"
+// @Param mine query bool false "Only tests created by the caller"
+// @Param name query string false "Filter by name substring"
+// @Param created_after query string false "Only tests created at or after this time (RFC3339)"
+// @Param created_before query string false "Only tests created at or before this time (RFC3339)"
+// @Param label query string false "Label selector, e.g. rack=r12,gen!=g4,owner (repeatable; every term must match)"
+// @Param search query string false "ID of a saved search whose filters apply underneath the explicit ones"
+// @Param sort query string false "Comma-separated sort fields, prefix with - for descending (name, plugin, created, updated, duration)"
 // @Success 200 {array} models.TestConfiguration
+// @Failure 400 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/tests [get]
 func (s *Server) listTests(c *gin.Context) {
-	limit := c.DefaultQuery("limit", "50")
-	offset := c.DefaultQuery("offset", "0")
+	query, ok := s.listQuery(c, models.SavedSearchTests)
+	if !ok {
+		return
+	}
 
-	repo := database.NewRepository(s.db)
-	tests, err := repo.ListTestConfigurations(parseInt(limit, 50), parseInt(offset, 0))
+	filter, err := testFilter(query)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.ProjectID = currentProjectID(c)
+	mine, ok := mineFilter(c, query)
+	if !ok {
+		return
+	}
+	if mine != "" {
+		filter.CreatedBy = mine
+	}
+
+	tests, err := s.repo.ListTestConfigurationsFiltered(filter)
 	if err != nil {
 		s.logger.Error("Failed to list tests", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to list tests"})
+		respondError(c, http.StatusInternalServerError, "Failed to list tests")
 		return
 	}
 
-	c.JSON(http.StatusOK, tests)
+	setPagination(c, filter.Limit, filter.Offset, len(tests))
+	c.JSON(http.StatusOK, redactTests(tests))
+}
+
+// testFilter builds the test configuration filter from list query parameters
+func testFilter(query url.Values) (database.TestFilter, error) {
+	sort, err := database.ParseSort(query.Get("sort"), database.TestSortFields)
+	if err != nil {
+		return database.TestFilter{}, err
+	}
+
+	createdAfter, err := parseTimeValue(query, "created_after")
+	if err != nil {
+		return database.TestFilter{}, err
+	}
+	createdBefore, err := parseTimeValue(query, "created_before")
+	if err != nil {
+		return database.TestFilter{}, err
+	}
+
+	labels, err := database.ParseLabelSelector(query["label"])
+	if err != nil {
+		return database.TestFilter{}, err
+	}
+
+	return database.TestFilter{
+		Plugin:        query.Get("plugin"),
+		CreatedBy:     query.Get("created_by"),
+		NameContains:  query.Get("name"),
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+		Labels:        labels,
+		Sort:          sort,
+		Limit:         parseIntValue(query, "limit", 50),
+		Offset:        parseIntValue(query, "offset", 0),
+	}, nil
 }
 
 // @Summary Create test configuration
@@ -317,32 +600,37 @@ func (s *Server) listTests(c *gin.Context) {
 // @Produce json
 // @Param test body models.TestConfiguration true "Test configuration"
 // @Success 201 {object} models.TestConfiguration
-// @Failure 400 {object} ErrorResponse
+// @Failure 400 {object} ErrorResponse "Details lists the invalid fields"
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/tests [post]
 func (s *Server) createTest(c *gin.Context) {
 	var test models.TestConfiguration
-	if err := c.ShouldBindJSON(&test); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !bindJSON(c, &test) {
+		return
+	}
+
+	if fieldErrs := s.validateTestConfiguration(&test); len(fieldErrs) > 0 {
+		respondErrorCode(c, http.StatusBadRequest, errorCode(http.StatusBadRequest), "Invalid test configuration", fieldErrs)
 		return
 	}
 
-	// Set creation time and ID
+	// Set creation time, owning project and creator
+	test.ProjectID = currentProjectID(c)
+	test.CreatedBy = c.GetString(contextUserIDKey)
 	test.Created = time.Now()
 	test.Updated = time.Now()
 
-	repo := database.NewRepository(s.db)
-	if err := repo.CreateTestConfiguration(&test); err != nil {
+	if err := s.repo.CreateTestConfiguration(&test); err != nil {
 		s.logger.Error("Failed to create test", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to create test"})
+		respondError(c, http.StatusInternalServerError, "Failed to create test")
 		return
 	}
 
-	c.JSON(http.StatusCreated, test)
+	c.JSON(http.StatusCreated, redactTest(test))
 }
 
 // @Summary Get test configuration
-// @Description Get a specific test configuration by ID
+// @Description Get a specific test configuration by ID. Secrets, such as passwords and tokens in the plugin configuration and command probes, are redacted.
 // @Tags tests
 // @Accept json
 // @Produce json
@@ -354,23 +642,39 @@ func (s *Server) createTest(c *gin.Context) {
 func (s *Server) getTest(c *gin.Context) {
 	id := c.Param("id")
 
-	repo := database.NewRepository(s.db)
-	test, err := repo.GetTestConfiguration(id)
+	test, err := s.repo.GetTestConfiguration(id)
 	if err != nil {
-		if err.Error() == "record not found" {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Test not found"})
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Test not found")
 		} else {
 			s.logger.Error("Failed to get test", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to get test"})
+			respondError(c, http.StatusInternalServerError, "Failed to get test")
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, test)
+	c.JSON(http.StatusOK, redactTest(*test))
+}
+
+// roleMaxDuration returns the duration cap configured for the authenticated user's
+// role, or 0 when there is none
+func (s *Server) roleMaxDuration(c *gin.Context) time.Duration {
+	limits := s.config.Safety.Watchdog.RoleMaxDuration
+	userID := c.GetString(contextUserIDKey)
+	if len(limits) == 0 || userID == "" {
+		return 0
+	}
+
+	user, err := s.repo.GetUser(userID)
+	if err != nil {
+		s.logger.Warn("Failed to get user for duration limit", zap.String("user_id", userID), zap.Error(err))
+		return 0
+	}
+	return limits[user.Role]
 }
 
 // @Summary Run test
-// @Description Execute a test configuration
+// @Description Execute a test configuration. The run is rejected with 412, listing what failed, if the test's prerequisites aren't met, and with 409, or queued if so configured, while the machine fails the preflight health checks or a running execution holds a resource the run claims, such as the device an I/O test writes to. Set soak to run for up to days, checkpointing progress so a server restart resumes the run. Set pin_cpu_governor and disable_turbo for reproducible numbers on Linux; the CPU frequency settings are restored when the run ends. Set placement to run the workload on a CPU list or NUMA node at a given nice and I/O priority (Linux); the placement applies to the whole server process, so runs asking for a different one while it is held are rejected with 409, and the placement the workload ran with is reported in the summary and metrics. Set load_profile to vary the intensity over time rather than apply constant pressure: step, spike, sine and sawtooth shapes cycle every period between full intensity and amplitude percent below it, and the current level is reported in the execution's progress; plugins that can't vary their load while running, currently all but cpu-stress and grpc-load, reject it with 400. Set ramp_down to lower the intensity over the final part of the run; only cpu-stress ramps down, and other plugins reject it with 400. Set disk_quota_mb to stop an I/O run, removing its test files, once it has written that much, below the server's disk guard quota. Set polite on workstations to give way to people using the machine while anyone is logged in over SSH, on a terminal or in an X session: throttle runs the load at throttle_percent (25 by default) of the intensity until they leave, which only plugins that can vary their load while running accept, and defer queues the run until they have left and pauses it, if its plugin can pause, when they come back. Polite mode is set per run, as runs aren't started on a schedule in this version. X sessions count as x11 even when they come from a remote host, so sessions: ["ssh"] doesn't make way for them. Set priority to order the run in the queue should it have to wait, higher first. Set seed to replay the random decisions (offsets, patterns, read/write choices) of an earlier execution, which records the seed it ran with; one is picked otherwise.
 // @Tags tests
 // @Accept json
 // @Produce json
@@ -379,25 +683,46 @@ func (s *Server) getTest(c *gin.Context) {
 // @Success 202 {object} TestExecutionResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse "Details lists the unmet prerequisites"
 // @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
 // @Router /api/v1/tests/{id}/run [post]
 func (s *Server) runTest(c *gin.Context) {
 	id := c.Param("id")
 
 	var params models.TestParams
-	if err := c.ShouldBindJSON(&params); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+	if !bindJSON(c, &params) {
+		return
+	}
+	if err := params.Labels.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := params.Placement.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := params.CI.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := params.LoadProfile.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := params.Polite.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	repo := database.NewRepository(s.db)
-	test, err := repo.GetTestConfiguration(id)
+	test, err := s.repo.GetTestConfiguration(id)
 	if err != nil {
-		if err.Error() == "record not found" {
-			c.JSON(http.StatusNotFound, ErrorResponse{Error: "Test not found"})
+		if errors.Is(err, database.ErrNotFound) {
+			respondError(c, http.StatusNotFound, "Test not found")
 		} else {
 			s.logger.Error("Failed to get test", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to get test"})
+			respondError(c, http.StatusInternalServerError, "Failed to get test")
 		}
 		return
 	}
@@ -406,12 +731,50 @@ func (s *Server) runTest(c *gin.Context) {
 	if params.Duration == 0 {
 		params.Duration = test.Duration
 	}
+	params.StartedBy = c.GetString(contextUserIDKey)
+
+	// Enforce the caller's role limit; the global maximum is enforced by the orchestrator
+	if limit := s.roleMaxDuration(c); limit > 0 && params.Duration > limit {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("Test duration %s exceeds the maximum of %s for your role", params.Duration, limit))
+		return
+	}
 
 	// Start test execution
 	executionID, err := s.orchestrator.StartTest(*test, params)
+	if errors.Is(err, core.ErrDraining) {
+		respondErrorCode(c, http.StatusServiceUnavailable, CodeDraining, "Server is shutting down, not accepting new test runs", nil)
+		return
+	}
+	if errors.Is(err, core.ErrDurationExceeded) {
+		respondErrorCode(c, http.StatusBadRequest, CodeDurationExceeded, err.Error(), nil)
+		return
+	}
+	if errors.Is(err, core.ErrSoakDisabled) || errors.Is(err, core.ErrFrequencyPinningUnavailable) || errors.Is(err, core.ErrCommandProbesDisabled) ||
+		errors.Is(err, core.ErrPlacementUnavailable) || errors.Is(err, core.ErrLoadShapingUnsupported) || errors.Is(err, core.ErrPoliteUnsupported) ||
+		errors.Is(err, core.ErrRampDownUnsupported) {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, plugins.ErrPluginNotEnabled) {
+		respondErrorCode(c, http.StatusConflict, CodePluginDisabled, "Plugin "+test.Plugin+" is disabled", nil)
+		return
+	}
+	if errors.Is(err, core.ErrPreflightFailed) {
+		respondErrorCode(c, http.StatusConflict, CodePreflightFailed, err.Error(), nil)
+		return
+	}
+	if errors.Is(err, core.ErrResourceConflict) || errors.Is(err, core.ErrPlacementConflict) {
+		respondErrorCode(c, http.StatusConflict, CodeResourceConflict, err.Error(), nil)
+		return
+	}
+	var prerequisiteErr *core.PrerequisiteError
+	if errors.As(err, &prerequisiteErr) {
+		respondErrorCode(c, http.StatusPreconditionFailed, CodePrerequisitesFailed, err.Error(), prerequisiteErr.Failures)
+		return
+	}
 	if err != nil {
 		s.logger.Error("Failed to start test", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "Failed to start test"})
+		respondError(c, http.StatusInternalServerError, "Failed to start test")
 		return
 	}
 
@@ -420,6 +783,10 @@ func (s *Server) runTest(c *gin.Context) {
 		Status:      "started",
 		Message:     "Test execution started successfully",
 	}
+	if execution, err := s.orchestrator.GetTestStatus(executionID); err == nil && execution.Status == models.StatusQueued {
+		response.Status = "queued"
+		response.Message = "Test execution queued until the system passes preflight checks, the resources it claims are free and any interactive users it defers to have left"
+	}
 
 	c.JSON(http.StatusAccepted, response)
 }
@@ -438,10 +805,16 @@ func (s *Server) handleWebSocket(c *gin.Context) {
 		return
 	}
 
+	projectID := currentProjectID(c)
 	client := &WSClient{
-		hub:  s.wsHub,
-		conn: conn,
-		send: make(chan []byte, 256),
+		hub:    s.wsHub,
+		conn:   conn,
+		queue:  s.wsHub.newQueue(),
+		userID: c.GetString(contextUserIDKey),
+		visible: func(testID string) bool {
+			test, err := s.repo.GetTestConfiguration(testID)
+			return err == nil && test.ProjectID == projectID
+		},
 	}
 
 	client.hub.register <- client
@@ -462,10 +835,6 @@ func parseInt(s string, defaultValue int) int {
 
 // Response types
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
 type TestExecutionResponse struct {
 	ExecutionID string `json:"execution_id"`
 	Status      string `json:"status"`
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// statusSummaryWindow is how far back the status page's summary of finished
+// executions reaches
+const statusSummaryWindow = 24 * time.Hour
+
+// PublicStatus is what the public status page shows: the machine's health, the
+// tests running on it and how the last day's executions ended. It leaves out
+// anything beyond test names, such as who ran them and their parameters.
+type PublicStatus struct {
+	Title     string        `json:"title"`
+	Timestamp time.Time     `json:"timestamp"`
+	Health    string        `json:"health"`   // healthy, or degraded when a required dependency is unhealthy
+	Draining  bool          `json:"draining"` // shutting down, not accepting new tests
+	System    StatusSystem  `json:"system"`
+	Running   []StatusRun   `json:"running"`
+	Queued    int           `json:"queued"`
+	LastDay   StatusSummary `json:"last_24h"`
+}
+
+// StatusSystem is the machine's current load
+type StatusSystem struct {
+	CPUPercent         float64 `json:"cpu_percent"`
+	MemoryPercent      float64 `json:"memory_percent"`
+	DiskPercent        float64 `json:"disk_percent"`
+	TemperatureCelsius float64 `json:"temperature_celsius"` // hottest sensor; 0 when none can be read
+}
+
+// StatusRun is a running execution as shown on the status page
+type StatusRun struct {
+	Test      string        `json:"test"`
+	Plugin    string        `json:"plugin"`
+	Started   *time.Time    `json:"started,omitempty"`
+	Percent   float64       `json:"percent"`
+	Phase     string        `json:"phase,omitempty"`
+	Remaining time.Duration `json:"remaining"` // estimated
+}
+
+// StatusSummary counts the executions started in the last day by how they ended
+type StatusSummary struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"` // failed or timed out
+	Stopped   int `json:"stopped"`
+}
+
+// @Summary Get public status
+// @Description Get the summary shown on the public status page: the machine's health and load, the tests running on it and the last 24 hours' executions by outcome. Served without authentication when server.status_page is enabled, for wall displays; it exposes nothing that changes the server.
+// @Tags system
+// @Produce json
+// @Success 200 {object} PublicStatus
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/status [get]
+func (s *Server) getPublicStatus(c *gin.Context) {
+	status, err := s.publicStatus()
+	if err != nil {
+		s.logger.Error("Failed to build public status", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to get status")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// statusPage serves the public status as a page that reloads itself, for a
+// wall display
+func (s *Server) statusPage(c *gin.Context) {
+	status, err := s.publicStatus()
+	if err != nil {
+		s.logger.Error("Failed to build public status", zap.Error(err))
+		c.String(http.StatusInternalServerError, "Status unavailable")
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(renderStatusPage(status, s.config.Server.StatusPage.Refresh)))
+}
+
+// publicStatus gathers the public status across every project
+func (s *Server) publicStatus() (PublicStatus, error) {
+	health := s.health()
+	system := s.orchestrator.GetSystemMetrics()
+	status := PublicStatus{
+		Title:     s.config.Server.StatusPage.Title,
+		Timestamp: time.Now().UTC(),
+		Health:    health.Status,
+		Draining:  health.Queue.Draining,
+		Queued:    health.Queue.Queued,
+		System: StatusSystem{
+			CPUPercent:         system.CPU.UsagePercent,
+			MemoryPercent:      system.Memory.UsagePercent,
+			DiskPercent:        system.Disk.UsagePercent,
+			TemperatureCelsius: system.Thermal.MaxCelsius,
+		},
+		Running: []StatusRun{},
+	}
+
+	for _, execution := range s.orchestrator.ListExecutions() {
+		if execution.Status != models.StatusRunning {
+			continue
+		}
+		run := StatusRun{Test: execution.TestID, Started: execution.StartTime}
+		if test, err := s.repo.GetTestConfiguration(execution.TestID); err == nil {
+			run.Test, run.Plugin = test.Name, test.Plugin
+		}
+		if progress := execution.Progress; progress != nil {
+			run.Percent, run.Phase, run.Remaining = progress.Percent, progress.Phase, progress.Remaining
+		}
+		status.Running = append(status.Running, run)
+	}
+	sort.Slice(status.Running, func(i, j int) bool { return status.Running[i].Test < status.Running[j].Test })
+
+	since := status.Timestamp.Add(-statusSummaryWindow)
+	executions, err := s.repo.ListTestExecutionsFiltered(database.ExecutionFilter{StartedAfter: &since})
+	if err != nil {
+		return status, err
+	}
+	for _, execution := range executions {
+		status.LastDay.Total++
+		switch execution.Status {
+		case models.StatusCompleted:
+			status.LastDay.Completed++
+		case models.StatusFailed, models.StatusTimedOut:
+			status.LastDay.Failed++
+		case models.StatusStopped:
+			status.LastDay.Stopped++
+		}
+	}
+	return status, nil
+}
+
+// renderStatusPage writes the public status as a self-contained HTML page,
+// reloading every refresh
+func renderStatusPage(status PublicStatus, refresh time.Duration) string {
+	var b strings.Builder
+	title := html.EscapeString(status.Title)
+	fmt.Fprintf(&b, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="%d">
+<title>%s</title>
+<style>
+body { font-family: sans-serif; background: #111; color: #eee; margin: 2em; }
+h1 { font-size: 2.4em; margin: 0 0 .5em; }
+h2 { font-size: 1.4em; color: #aaa; margin-top: 1.5em; }
+table { border-collapse: collapse; width: 100%%; font-size: 1.3em; }
+th, td { padding: 6px 12px; text-align: left; border-bottom: 1px solid #333; }
+.num { text-align: right; font-variant-numeric: tabular-nums; }
+.healthy { color: #4caf50; } .degraded, .failed { color: #f44336; } .draining { color: #ff9800; }
+</style>
+</head>
+<body>
+<h1>%s <span class="%s">%s</span></h1>
+`, int(refresh.Seconds()), title, title, html.EscapeString(status.Health), html.EscapeString(status.Health))
+	if status.Draining {
+		b.WriteString(`<p class="draining">Shutting down, not accepting new tests</p>` + "\n")
+	}
+
+	fmt.Fprintf(&b, `<table>
+<tr><th>CPU</th><th>Memory</th><th>Disk</th><th>Temperature</th></tr>
+<tr><td class="num">%.0f%%</td><td class="num">%.0f%%</td><td class="num">%.0f%%</td><td class="num">%.0f °C</td></tr>
+</table>
+`, status.System.CPUPercent, status.System.MemoryPercent, status.System.DiskPercent, status.System.TemperatureCelsius)
+
+	fmt.Fprintf(&b, "<h2>Running (%d queued)</h2>\n", status.Queued)
+	if len(status.Running) == 0 {
+		b.WriteString("<p>No tests running</p>\n")
+	} else {
+		b.WriteString("<table>\n<tr><th>Test</th><th>Plugin</th><th>Phase</th><th class=\"num\">Progress</th><th class=\"num\">Remaining</th></tr>\n")
+		for _, run := range status.Running {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td><td class=\"num\">%.0f%%</td><td class=\"num\">%s</td></tr>\n",
+				html.EscapeString(run.Test), html.EscapeString(run.Plugin), html.EscapeString(run.Phase), run.Percent, run.Remaining.Round(time.Second))
+		}
+		b.WriteString("</table>\n")
+	}
+
+	day := status.LastDay
+	fmt.Fprintf(&b, `<h2>Last 24 hours</h2>
+<table>
+<tr><th class="num">Runs</th><th class="num">Completed</th><th class="num">Failed</th><th class="num">Stopped</th></tr>
+<tr><td class="num">%d</td><td class="num">%d</td><td class="num failed">%d</td><td class="num">%d</td></tr>
+</table>
+<p>Updated %s</p>
+</body>
+</html>
+`, day.Total, day.Completed, day.Failed, day.Stopped, status.Timestamp.Format(time.RFC3339))
+	return b.String()
+}
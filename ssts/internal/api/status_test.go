@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestPublicStatus(t *testing.T) {
+	server, repo := newAuthTestServer(t, func(cfg *config.Config) {
+		cfg.Server.StatusPage.Enabled = true
+		cfg.Server.StatusPage.Title = "Lab <3>"
+	})
+
+	at := func(ago time.Duration) *time.Time {
+		start := time.Now().Add(-ago)
+		return &start
+	}
+	for _, execution := range []models.TestExecution{
+		{TestID: "t1", ProjectID: "a", Status: models.StatusCompleted, StartTime: at(time.Hour)},
+		{TestID: "t1", ProjectID: "b", Status: models.StatusFailed, StartTime: at(2 * time.Hour)},
+		{TestID: "t2", ProjectID: "a", Status: models.StatusTimedOut, StartTime: at(3 * time.Hour)},
+		{TestID: "t2", ProjectID: "a", Status: models.StatusStopped, StartTime: at(4 * time.Hour)},
+		{TestID: "t2", ProjectID: "a", Status: models.StatusCompleted, StartTime: at(48 * time.Hour)},
+	} {
+		execution := execution
+		repo.CreateTestExecution(&execution)
+	}
+
+	rec := server.serveAs("", http.MethodGet, "/api/v1/status", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status without a token: status %d: %s", rec.Code, rec.Body)
+	}
+	var status PublicStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	want := StatusSummary{Total: 4, Completed: 1, Failed: 2, Stopped: 1}
+	if status.LastDay != want {
+		t.Errorf("last 24h = %+v, want %+v", status.LastDay, want)
+	}
+	if status.Health == "" || status.Running == nil {
+		t.Errorf("status = %+v, want health and an empty list of running tests", status)
+	}
+
+	page := server.serveAs("", http.MethodGet, "/status", nil)
+	if page.Code != http.StatusOK || !strings.HasPrefix(page.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status page: status %d, content type %q", page.Code, page.Header().Get("Content-Type"))
+	}
+	body := page.Body.String()
+	for _, want := range []string{"Lab &lt;3&gt;", `content="30"`, "No tests running"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("status page doesn't contain %q:\n%s", want, body)
+		}
+	}
+
+	// Everything else still needs a token
+	for _, request := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/tests"},
+		{http.MethodPost, "/api/v1/tests"},
+		{http.MethodPost, "/api/v1/tests/t1/run"},
+	} {
+		if rec := server.serveAs("", request.method, request.path, nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without a token: status %d, want 401", request.method, request.path, rec.Code)
+		}
+	}
+}
+
+func TestPublicStatusDisabled(t *testing.T) {
+	server, _ := newAuthTestServer(t, nil)
+
+	if rec := server.serveAs("", http.MethodGet, "/api/v1/status", nil); rec.Code == http.StatusOK {
+		t.Errorf("status with the page disabled: status %d, want it not served", rec.Code)
+	}
+}
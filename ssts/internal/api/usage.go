@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// maxUsageExecutions bounds how many executions a usage report reads; the most
+// recent are used
+const maxUsageExecutions = 100000
+
+// usageCSVHeader is the header row of usage reports exported as CSV
+var usageCSVHeader = []string{
+	"period", "project_id", "project_name", "user", "executions",
+	"machine_hours", "watt_hours", "metered_executions", "bytes_written",
+}
+
+// @Summary Usage report
+// @Description Add up the machine hours, watt-hours and bytes written to storage devices of finished executions by project, user and period, for charging shared lab time back. Watt-hours only cover executions whose power was measured. The execution list filters narrow which executions are counted; all_projects reports on every project and takes a server admin. Set format=csv, or accept text/csv, for a CSV file with one row per project, user and period.
+// @Tags executions
+// @Produce json
+// @Produce text/csv
+// @Param period query string false "day, week or month" default(month)
+// @Param all_projects query bool false "Report on every project rather than the current one (server admins)"
+// @Param format query string false "json or csv"
+// @Param started_after query string false "Only executions started at or after this time (RFC3339)"
+// @Param started_before query string false "Only executions started at or before this time (RFC3339)"
+// @Param started_by query string false "Only executions this user ran"
+// @Param test_id query string false "Filter by test configuration ID"
+// @Param plugin query string false "Filter by plugin"
+// @Param label query string false "Label selector, e.g. rack=r12,gen!=g4,owner (repeatable; every term must match)"
+// @Param search query string false "ID of a saved search whose filters apply underneath the explicit ones"
+// @Success 200 {object} models.UsageReport
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/usage [get]
+func (s *Server) getUsage(c *gin.Context) {
+	query, ok := s.listQuery(c, models.SavedSearchExecutions)
+	if !ok {
+		return
+	}
+
+	period := query.Get("period")
+	switch period {
+	case "", models.UsagePeriodDay, models.UsagePeriodWeek, models.UsagePeriodMonth:
+	default:
+		respondError(c, http.StatusBadRequest, "period must be day, week or month")
+		return
+	}
+	format := strings.ToLower(query.Get("format"))
+	if format == "" && strings.Contains(c.GetHeader("Accept"), "text/csv") {
+		format = "csv"
+	}
+	if format != "" && format != "json" && format != "csv" {
+		respondError(c, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+
+	filter, err := executionFilter(query)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.ProjectID = currentProjectID(c)
+	if all, _ := strconv.ParseBool(query.Get("all_projects")); all {
+		if !s.checkServerAdmin(c) {
+			return
+		}
+		filter.ProjectID = ""
+	}
+	filter.Statuses = []models.ExecutionStatus{models.StatusCompleted, models.StatusFailed, models.StatusStopped, models.StatusTimedOut}
+	filter.Sort = []database.SortField{{Field: "start_time", Desc: true}}
+	filter.Limit = maxUsageExecutions
+	filter.Offset = 0
+
+	executions, err := s.repo.ListTestExecutionsFiltered(filter)
+	if err != nil {
+		s.logger.Error("Failed to list executions", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list executions")
+		return
+	}
+
+	projectNames := make(map[string]string)
+	if filter.ProjectID != "" {
+		if project := currentProject(c); project != nil {
+			projectNames[project.ID] = project.Name
+		}
+	} else {
+		projects, err := s.repo.ListProjects()
+		if err != nil {
+			s.logger.Error("Failed to list projects", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "Failed to list projects")
+			return
+		}
+		for _, project := range projects {
+			projectNames[project.ID] = project.Name
+		}
+	}
+
+	report := core.ExecutionUsage(executions, period, projectNames)
+	report.Truncated = len(executions) == maxUsageExecutions
+	if format == "csv" {
+		s.writeUsageCSV(c, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// writeUsageCSV sends a usage report as a CSV file
+func (s *Server) writeUsageCSV(c *gin.Context, report models.UsageReport) {
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="usage-%s.csv"`, report.Generated.Format("20060102")))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(usageCSVHeader)
+	for _, row := range report.Rows {
+		writer.Write([]string{
+			row.Period.Format(time.DateOnly),
+			row.ProjectID,
+			row.ProjectName,
+			row.User,
+			strconv.Itoa(row.Executions),
+			strconv.FormatFloat(row.MachineHours, 'f', 4, 64),
+			strconv.FormatFloat(row.WattHours, 'f', 4, 64),
+			strconv.Itoa(row.MeteredExecutions),
+			strconv.FormatInt(row.BytesWritten, 10),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		s.logger.Error("Failed to write usage report", zap.Error(err))
+	}
+}
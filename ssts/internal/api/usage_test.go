@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestUsageReport(t *testing.T) {
+	server, repo := newTestServer(t)
+	project, _ := repo.GetProjectByName(models.DefaultProjectName)
+	other := &models.Project{Name: "storage"}
+	repo.CreateProject(other)
+
+	record := func(projectID, user string, start time.Time, hours float64, summary *models.ExecutionSummary) {
+		end := start.Add(time.Duration(hours * float64(time.Hour)))
+		execution := models.TestExecution{
+			TestID:    "t",
+			ProjectID: projectID,
+			StartedBy: user,
+			Status:    models.StatusCompleted,
+			StartTime: &start,
+			EndTime:   &end,
+		}
+		if summary != nil {
+			execution.Summary, _ = json.Marshal(summary)
+		}
+		repo.CreateTestExecution(&execution)
+	}
+	march := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	record(project.ID, "alice", march, 2, &models.ExecutionSummary{
+		Energy:       &models.EnergyUsage{WattHours: 300},
+		DeviceWrites: map[string]int64{"nvme0n1": 1000, "sda": 24},
+	})
+	record(project.ID, "alice", march.AddDate(0, 0, 10), 1, nil)
+	record(project.ID, "bob", march, 0.5, nil)
+	record(project.ID, "bob", march.AddDate(0, 1, 0), 4, nil)
+	record(other.ID, "carol", march, 8, nil)
+
+	rec := server.serve(http.MethodGet, "/api/v1/usage", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("usage: status %d: %s", rec.Code, rec.Body)
+	}
+	var report models.UsageReport
+	json.Unmarshal(rec.Body.Bytes(), &report)
+
+	if report.Period != models.UsagePeriodMonth || len(report.Rows) != 3 {
+		t.Fatalf("report = %+v, want monthly rows for alice and bob in March and bob in April", report)
+	}
+	alice := report.Rows[0]
+	if alice.User != "alice" || alice.ProjectName != models.DefaultProjectName || alice.Executions != 2 ||
+		alice.MachineHours != 3 || alice.WattHours != 300 || alice.MeteredExecutions != 1 || alice.BytesWritten != 1024 {
+		t.Errorf("alice = %+v", alice)
+	}
+	if report.Totals.Executions != 4 || report.Totals.MachineHours != 7.5 {
+		t.Errorf("totals = %+v, want the current project's 4 executions over 7.5 hours", report.Totals)
+	}
+
+	// Every project, by week, as CSV
+	rec = server.serve(http.MethodGet, "/api/v1/usage?all_projects=true&period=week&format=csv", nil)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("CSV usage: status %d, content type %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 6 || strings.Join(rows[0], ",") != strings.Join(usageCSVHeader, ",") {
+		t.Fatalf("CSV = %v, want a header and five weekly rows", rows)
+	}
+	if row := rows[1]; row[0] != "2024-03-04" || row[2] != models.DefaultProjectName || row[3] != "alice" || row[5] != "2.0000" {
+		t.Errorf("first row = %v", row)
+	}
+
+	if rec := server.serve(http.MethodGet, "/api/v1/usage?period=year", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown period: status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/secrets"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// FieldError describes a validation failure for a single request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func init() {
+	// Name fields in validation errors as clients send them
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// jsonFieldName returns the name of a struct field in JSON
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// bindJSON decodes a request body and checks it against the binding tags of
+// obj's fields. It responds with an error, listing the invalid fields in its
+// details, and returns false if the body is malformed or invalid.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		errs := make([]FieldError, 0, len(validationErrs))
+		for _, e := range validationErrs {
+			errs = append(errs, bindingFieldError(e))
+		}
+		respondErrorCode(c, http.StatusBadRequest, errorCode(http.StatusBadRequest), "Invalid request body", errs)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		errs := []FieldError{{Field: typeErr.Field, Message: "must be " + jsonTypeName(typeErr.Type)}}
+		respondErrorCode(c, http.StatusBadRequest, errorCode(http.StatusBadRequest), "Invalid request body", errs)
+	default:
+		respondError(c, http.StatusBadRequest, "Invalid request body")
+	}
+	return false
+}
+
+// bindingFieldError describes a field failing one of its binding rules
+func bindingFieldError(e validator.FieldError) FieldError {
+	// The namespace starts with the Go name of the bound type
+	field := e.Namespace()
+	if _, rest, ok := strings.Cut(field, "."); ok {
+		field = rest
+	}
+
+	var message string
+	kind := e.Kind()
+	switch e.Tag() {
+	case "required":
+		message = "is required"
+	case "min", "gte":
+		message = "must be at least " + bindingLimit(kind, e.Param())
+	case "max", "lte":
+		message = "must be at most " + bindingLimit(kind, e.Param())
+	case "gt":
+		message = "must be greater than " + bindingLimit(kind, e.Param())
+	case "lt":
+		message = "must be less than " + bindingLimit(kind, e.Param())
+	case "len":
+		message = "must be exactly " + bindingLimit(kind, e.Param())
+	case "oneof":
+		message = "must be one of " + strings.Join(strings.Fields(e.Param()), ", ")
+	case "hexadecimal":
+		message = "must be hexadecimal"
+	default:
+		message = fmt.Sprintf("fails the %s check", e.Tag())
+	}
+	return FieldError{Field: field, Message: message}
+}
+
+// bindingLimit words the parameter of a size rule, which bounds the length of
+// strings and the count of slices and maps rather than their value
+func bindingLimit(kind reflect.Kind, param string) string {
+	switch kind {
+	case reflect.String:
+		return param + " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return param + " items"
+	}
+	return param
+}
+
+// jsonTypeName names a Go type as the JSON type a client should send
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// validateTestConfiguration checks a test configuration against the registered
+// plugins and the global safety limits, returning one entry per invalid field
+func (s *Server) validateTestConfiguration(test *models.TestConfiguration) []FieldError {
+	var errs []FieldError
+
+	if test.Name == "" {
+		errs = append(errs, FieldError{Field: "name", Message: "is required"})
+	}
+
+	// Plugin must exist and the config must match its schema
+	if test.Plugin == "" {
+		errs = append(errs, FieldError{Field: "plugin", Message: "is required"})
+	} else if plugin, exists := s.orchestrator.GetPluginManager().GetPlugin(test.Plugin); !exists {
+		errs = append(errs, FieldError{Field: "plugin", Message: fmt.Sprintf("unknown plugin %q", test.Plugin)})
+	} else {
+		schemaErrs, err := plugins.ValidateConfig(plugin.ConfigSchema(), test.Config)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "config", Message: err.Error()})
+		}
+		for _, e := range schemaErrs {
+			errs = append(errs, FieldError{Field: "config." + e.Field, Message: e.Message})
+		}
+	}
+
+	// Duration must be within the configured bounds
+	limits := s.config.Safety.GlobalLimits
+	if test.Duration <= 0 {
+		errs = append(errs, FieldError{Field: "duration", Message: "must be greater than zero"})
+	} else if limits.MinTestDuration > 0 && test.Duration < limits.MinTestDuration {
+		errs = append(errs, FieldError{Field: "duration", Message: fmt.Sprintf("must be at least %s", limits.MinTestDuration)})
+	} else if limits.MaxTestDuration > 0 && test.Duration > limits.MaxTestDuration {
+		errs = append(errs, FieldError{Field: "duration", Message: fmt.Sprintf("must be at most %s", limits.MaxTestDuration)})
+	}
+
+	// Safety overrides may tighten but never exceed the global limits
+	errs = append(errs, checkSafetyOverride("safety.max_cpu_percent", test.Safety.MaxCPUPercent, limits.MaxCPUPercent)...)
+	errs = append(errs, checkSafetyOverride("safety.max_memory_percent", test.Safety.MaxMemoryPercent, limits.MaxMemoryPercent)...)
+	errs = append(errs, checkSafetyOverride("safety.max_disk_percent", test.Safety.MaxDiskPercent, limits.MaxDiskPercent)...)
+	if test.Safety.MaxNetworkMbps < 0 {
+		errs = append(errs, FieldError{Field: "safety.max_network_mbps", Message: "must not be negative"})
+	}
+
+	if err := test.Labels.Validate(); err != nil {
+		errs = append(errs, FieldError{Field: "labels", Message: err.Error()})
+	}
+
+	if err := core.ValidatePrerequisites(test.Prerequisites); err != nil {
+		errs = append(errs, FieldError{Field: "prerequisites", Message: err.Error()})
+	}
+
+	if err := test.SLA.Validate(); err != nil {
+		errs = append(errs, FieldError{Field: "sla", Message: err.Error()})
+	}
+
+	if err := core.ValidateProbes(test.Probes, s.config.Probes.AllowCommands); err != nil {
+		errs = append(errs, FieldError{Field: "probes", Message: err.Error()})
+	}
+
+	// Secrets are encrypted by the server; one that arrives looking encrypted
+	// would be stored as it is and fail to decrypt
+	for _, field := range secrets.EncryptedTestFields(*test) {
+		errs = append(errs, FieldError{Field: field, Message: "must not be an encrypted value"})
+	}
+
+	return errs
+}
+
+// checkSafetyOverride validates a per-test percentage limit against its global counterpart.
+// A zero value means "use the default" and is always accepted.
+func checkSafetyOverride(field string, value, globalLimit float64) []FieldError {
+	switch {
+	case value < 0:
+		return []FieldError{{Field: field, Message: "must not be negative"}}
+	case value > 100:
+		return []FieldError{{Field: field, Message: "must be at most 100"}}
+	case globalLimit > 0 && value > globalLimit:
+		return []FieldError{{Field: field, Message: fmt.Sprintf("exceeds global limit of %.1f", globalLimit)}}
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+)
+
+// API versions. v1 is kept stable for existing clients; v2 serves the same
+// routes with every JSON response wrapped in an Envelope.
+const (
+	apiV1 = "/api/v1"
+	apiV2 = "/api/v2"
+)
+
+// contextPaginationKey is the context key list handlers store their Pagination under
+const contextPaginationKey = "pagination"
+
+// Envelope wraps every JSON response of /api/v2. Successful responses carry
+// data and meta; failed ones carry errors and no data.
+type Envelope struct {
+	Data   json.RawMessage `json:"data" swaggertype:"object"`
+	Meta   *EnvelopeMeta   `json:"meta,omitempty"`
+	Errors []APIError      `json:"errors,omitempty"`
+}
+
+// EnvelopeMeta describes a successful response
+type EnvelopeMeta struct {
+	APIVersion string      `json:"api_version"`
+	Pagination *Pagination `json:"pagination,omitempty"` // only for paginated lists
+}
+
+// Pagination describes the page of a list a response holds
+type Pagination struct {
+	Limit      int  `json:"limit"`
+	Offset     int  `json:"offset"`
+	Count      int  `json:"count"`                 // items in this page
+	NextOffset *int `json:"next_offset,omitempty"` // set when the page is full, so more may follow
+}
+
+// APIError is one error of a failed /api/v2 response. Code is the same as the
+// v1 ErrorResponse's, so clients can switch on it without parsing messages.
+type APIError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Field   string          `json:"field,omitempty"`   // the invalid field, for validation errors
+	Details json.RawMessage `json:"details,omitempty"` // anything else the v1 error body carried
+}
+
+// errorCodes maps HTTP statuses to API error codes
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "invalid_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusNotAcceptable:         "not_acceptable",
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnprocessableEntity:   "unprocessable",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusNotImplemented:        "not_implemented",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// errorCode returns the API error code for an HTTP status
+func errorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return "internal_error"
+	}
+	return "request_failed"
+}
+
+// setPagination records the page a list handler responds with, for the v2 envelope
+func setPagination(c *gin.Context, limit, offset, count int) {
+	pagination := &Pagination{Limit: limit, Offset: offset, Count: count}
+	if limit > 0 && count >= limit {
+		next := offset + count
+		pagination.NextOffset = &next
+	}
+	c.Set(contextPaginationKey, pagination)
+}
+
+// apiBase returns the path prefix of the API version a request was made to, for
+// building links back into the same version
+func apiBase(c *gin.Context) string {
+	if strings.HasPrefix(c.Request.URL.Path, apiV2+"/") {
+		return apiV2
+	}
+	return apiV1
+}
+
+// deprecationMiddleware announces that v1 is deprecated: a Deprecation header
+// (RFC 9745), a Sunset header (RFC 8594) when a removal date is configured, and
+// Links to the v2 equivalent and the migration guide
+func (s *Server) deprecationMiddleware(cfg config.DeprecationConfig) gin.HandlerFunc {
+	deprecation := "true"
+	if date, err := time.Parse("2006-01-02", cfg.Date); err == nil {
+		deprecation = fmt.Sprintf("@%d", date.Unix())
+	}
+	var sunset string
+	if date, err := time.Parse("2006-01-02", cfg.Sunset); err == nil {
+		sunset = date.UTC().Format(http.TimeFormat)
+	}
+
+	return func(c *gin.Context) {
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("Deprecation", deprecation)
+		if sunset != "" {
+			header.Set("Sunset", sunset)
+		}
+		successor := apiV2 + strings.TrimPrefix(c.Request.URL.Path, apiV1)
+		header.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+		if cfg.Link != "" {
+			header.Add("Link", fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, cfg.Link))
+		}
+		c.Next()
+	}
+}
+
+// envelopeMiddleware wraps the JSON responses of /api/v2 in an Envelope. Other
+// responses, such as downloads and event streams, are passed through untouched.
+// It is installed on the engine rather than the v2 group so that errors from
+// engine-wide middleware such as rate limiting are wrapped too.
+func (s *Server) envelopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, apiV2+"/") {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		writer := &envelopeWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = writer
+		defer func() { c.Writer = original }()
+
+		c.Next()
+
+		if !writer.decided {
+			// Nothing was written; gin sends the status once the handlers return
+			original.WriteHeader(writer.status)
+			return
+		}
+		if !writer.buffering {
+			return
+		}
+
+		body, err := envelope(c, writer.status, writer.body.Bytes())
+		if err != nil {
+			// The handler wrote invalid JSON; send it as it was rather than lose it
+			body = writer.body.Bytes()
+		}
+		original.WriteHeader(writer.status)
+		original.Write(body)
+	}
+}
+
+// envelope wraps a JSON response body
+func envelope(c *gin.Context, status int, body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		body = []byte("null")
+	} else if !json.Valid(body) {
+		return nil, fmt.Errorf("response body is not valid JSON")
+	}
+
+	if status < 400 {
+		meta := &EnvelopeMeta{APIVersion: "v2"}
+		if pagination, ok := c.Get(contextPaginationKey); ok {
+			meta.Pagination = pagination.(*Pagination)
+		}
+		return json.Marshal(Envelope{Data: body, Meta: meta})
+	}
+
+	return json.Marshal(Envelope{Data: json.RawMessage("null"), Errors: apiErrors(status, body)})
+}
+
+// apiErrors converts a v1 error body, usually an ErrorResponse, to API errors.
+// Invalid fields listed in the details of a validation error become errors of
+// their own.
+func apiErrors(status int, body []byte) []APIError {
+	primary := APIError{Code: errorCode(status), Message: http.StatusText(status)}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return []APIError{primary}
+	}
+
+	if raw, ok := fields["code"]; ok {
+		json.Unmarshal(raw, &primary.Code)
+	}
+	if raw, ok := fields["message"]; ok {
+		json.Unmarshal(raw, &primary.Message)
+	} else if raw, ok := fields["error"]; ok {
+		json.Unmarshal(raw, &primary.Message)
+	}
+	var fieldErrors []FieldError
+	if raw, ok := fields["details"]; ok {
+		if json.Unmarshal(raw, &fieldErrors) != nil || !validFieldErrors(fieldErrors) {
+			fieldErrors = nil
+			primary.Details = raw
+		}
+	}
+	for _, key := range []string{"code", "message", "error", "details"} {
+		delete(fields, key)
+	}
+	if len(fields) > 0 && primary.Details == nil {
+		primary.Details, _ = json.Marshal(fields)
+	}
+
+	errs := []APIError{primary}
+	for _, fieldErr := range fieldErrors {
+		errs = append(errs, APIError{Code: "invalid_field", Message: fieldErr.Message, Field: fieldErr.Field})
+	}
+	return errs
+}
+
+// validFieldErrors reports whether details decoded as field errors really are,
+// rather than some other list whose entries merely share no keys with them
+func validFieldErrors(fieldErrors []FieldError) bool {
+	for _, fieldErr := range fieldErrors {
+		if fieldErr.Field == "" {
+			return false
+		}
+	}
+	return len(fieldErrors) > 0
+}
+
+// envelopeWriter holds back JSON responses so they can be wrapped once the
+// handler is done. Whether a response is JSON is decided when its body is first
+// written, since handlers set the content type after the status.
+type envelopeWriter struct {
+	gin.ResponseWriter
+	status    int
+	decided   bool
+	buffering bool
+	body      bytes.Buffer
+}
+
+// decide picks whether to buffer the response, sending the status straight away if not
+func (w *envelopeWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	header := w.ResponseWriter.Header()
+	w.buffering = strings.HasPrefix(header.Get("Content-Type"), "application/json") && header.Get("Content-Disposition") == ""
+	if !w.buffering {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *envelopeWriter) WriteHeader(code int) {
+	if code > 0 && !w.decided {
+		w.status = code
+	}
+}
+
+func (w *envelopeWriter) WriteHeaderNow() {
+	w.decide()
+	if !w.buffering {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *envelopeWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.buffering {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *envelopeWriter) WriteString(s string) (int, error) {
+	w.decide()
+	if w.buffering {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *envelopeWriter) Status() int {
+	return w.status
+}
+
+func (w *envelopeWriter) Size() int {
+	if w.buffering {
+		return w.body.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *envelopeWriter) Written() bool {
+	return w.decided
+}
+
+// Flush sends what has been written so far, unless it is being held back
+func (w *envelopeWriter) Flush() {
+	if w.decided && !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
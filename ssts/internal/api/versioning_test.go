@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func decodeEnvelope(t *testing.T, body []byte) Envelope {
+	t.Helper()
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("response is not an envelope: %v: %s", err, body)
+	}
+	return envelope
+}
+
+// createDefaultProjectTest stores a test in the default project, where requests
+// without a project header look
+func createDefaultProjectTest(t *testing.T, repo *database.MemoryRepository, test models.TestConfiguration) {
+	t.Helper()
+	project, err := repo.GetProjectByName(models.DefaultProjectName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ProjectID = project.ID
+	if err := repo.CreateTestConfiguration(&test); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestV1Unchanged(t *testing.T) {
+	server, repo := newTestServer(t)
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t1", Name: "burn", Plugin: "cpu-stress"})
+
+	rec := server.serve(http.MethodGet, "/api/v1/tests", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var tests []models.TestConfiguration
+	if err := json.Unmarshal(rec.Body.Bytes(), &tests); err != nil || len(tests) != 1 {
+		t.Fatalf("v1 body = %s, want a bare list", rec.Body)
+	}
+
+	if got := rec.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("Deprecation = %q, want true", got)
+	}
+	if got := rec.Header().Get("Sunset"); got != "" {
+		t.Errorf("Sunset = %q without a sunset date", got)
+	}
+	if got := rec.Header().Get("Link"); got != `</api/v2/tests>; rel="successor-version"` {
+		t.Errorf("Link = %q", got)
+	}
+}
+
+func TestV1DeprecationDates(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.Deprecation = config.DeprecationConfig{Enabled: true, Date: "2026-01-01", Sunset: "2026-12-31", Link: "https://example.com/migrate"}
+	server := NewServer(cfg, database.NewMemoryRepository(), core.NewOrchestrator(cfg, database.NewMemoryRepository(), plugins.NewPluginManager(), zap.NewNop()), zap.NewNop())
+
+	rec := server.serve(http.MethodGet, "/api/v1/projects", nil)
+	if got, want := rec.Header().Get("Deprecation"), "@1767225600"; got != want {
+		t.Errorf("Deprecation = %q, want %q", got, want)
+	}
+	if got, want := rec.Header().Get("Sunset"), "Thu, 31 Dec 2026 00:00:00 GMT"; got != want {
+		t.Errorf("Sunset = %q, want %q", got, want)
+	}
+	links := rec.Header().Values("Link")
+	if len(links) != 2 || links[1] != `<https://example.com/migrate>; rel="deprecation"; type="text/html"` {
+		t.Errorf("Link = %q", links)
+	}
+
+	// v2 isn't deprecated
+	if rec := server.serve(http.MethodGet, "/api/v2/projects", nil); rec.Header().Get("Deprecation") != "" {
+		t.Errorf("v2 has Deprecation %q", rec.Header().Get("Deprecation"))
+	}
+}
+
+func TestV2Envelope(t *testing.T) {
+	server, repo := newTestServer(t)
+	for _, name := range []string{"a", "b", "c"} {
+		createDefaultProjectTest(t, repo, models.TestConfiguration{ID: name, Name: name, Plugin: "cpu-stress", Created: time.Now()})
+	}
+
+	rec := server.serve(http.MethodGet, "/api/v2/tests?limit=2", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	envelope := decodeEnvelope(t, rec.Body.Bytes())
+	var tests []models.TestConfiguration
+	if err := json.Unmarshal(envelope.Data, &tests); err != nil || len(tests) != 2 {
+		t.Fatalf("data = %s, want two tests", envelope.Data)
+	}
+	if envelope.Meta == nil || envelope.Meta.APIVersion != "v2" {
+		t.Fatalf("meta = %+v", envelope.Meta)
+	}
+	page := envelope.Meta.Pagination
+	if page == nil || page.Limit != 2 || page.Offset != 0 || page.Count != 2 || page.NextOffset == nil || *page.NextOffset != 2 {
+		t.Errorf("pagination = %+v", page)
+	}
+
+	// The last page isn't full, so has no next offset
+	envelope = decodeEnvelope(t, server.serve(http.MethodGet, "/api/v2/tests?limit=2&offset=2", nil).Body.Bytes())
+	if page := envelope.Meta.Pagination; page == nil || page.Count != 1 || page.NextOffset != nil {
+		t.Errorf("last page pagination = %+v", page)
+	}
+
+	// Responses that aren't lists have no pagination
+	envelope = decodeEnvelope(t, server.serve(http.MethodGet, "/api/v2/tests/a", nil).Body.Bytes())
+	if envelope.Meta == nil || envelope.Meta.Pagination != nil || !strings.Contains(string(envelope.Data), `"name":"a"`) {
+		t.Errorf("get envelope = %+v, data %s", envelope.Meta, envelope.Data)
+	}
+}
+
+func TestV2Errors(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	rec := server.serve(http.MethodGet, "/api/v2/tests/missing", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", rec.Code)
+	}
+	envelope := decodeEnvelope(t, rec.Body.Bytes())
+	if string(envelope.Data) != "null" || envelope.Meta != nil {
+		t.Errorf("error envelope has data %s, meta %+v", envelope.Data, envelope.Meta)
+	}
+	if len(envelope.Errors) != 1 || envelope.Errors[0].Code != "not_found" || envelope.Errors[0].Message == "" {
+		t.Errorf("errors = %+v", envelope.Errors)
+	}
+
+	// Validation errors list each invalid field
+	rec = server.serve(http.MethodPost, "/api/v2/tests", models.TestConfiguration{Plugin: "missing"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400: %s", rec.Code, rec.Body)
+	}
+	envelope = decodeEnvelope(t, rec.Body.Bytes())
+	if len(envelope.Errors) < 2 || envelope.Errors[0].Code != "invalid_request" {
+		t.Fatalf("errors = %+v", envelope.Errors)
+	}
+	fields := map[string]bool{}
+	for _, err := range envelope.Errors[1:] {
+		if err.Code != "invalid_field" {
+			t.Errorf("field error code = %q", err.Code)
+		}
+		fields[err.Field] = true
+	}
+	if !fields["name"] {
+		t.Errorf("errors = %+v, want one for name", envelope.Errors)
+	}
+}
+
+func TestV2PassesThroughDownloads(t *testing.T) {
+	server, repo := newTestServer(t)
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t1", Name: "burn", Plugin: "cpu-stress", Config: json.RawMessage(`{}`)})
+
+	rec := server.serve(http.MethodGet, "/api/v2/bundles?format=json", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(rec.Body.Bytes(), &bundle); err != nil || len(bundle.Tests) != 1 {
+		t.Errorf("bundle download = %s, want the bundle itself", rec.Body)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantCodes   []string
+		wantMessage string
+		wantDetails string
+	}{
+		{"not JSON", http.StatusBadGateway, "<html>", []string{"internal_error"}, "Bad Gateway", ""},
+		{"legacy body", http.StatusNotFound, `{"error": "Test not found"}`, []string{"not_found"}, "Test not found", ""},
+		{"specific code", http.StatusServiceUnavailable, `{"code": "draining", "message": "Shutting down", "error": "Shutting down"}`, []string{"draining"}, "Shutting down", ""},
+		{"field errors", http.StatusBadRequest, `{"code": "invalid_request", "message": "Invalid", "details": [{"field": "name", "message": "required"}]}`, []string{"invalid_request", "invalid_field"}, "Invalid", ""},
+		{"other details", http.StatusPreconditionFailed, `{"code": "prerequisites_failed", "message": "Unmet", "details": [{"prerequisite": "plugin x", "reason": "missing"}]}`, []string{"prerequisites_failed"}, "Unmet", `[{"prerequisite": "plugin x", "reason": "missing"}]`},
+		{"unknown keys", http.StatusConflict, `{"error": "Busy", "holder": "t1"}`, []string{"conflict"}, "Busy", `{"holder":"t1"}`},
+	}
+	for _, tt := range tests {
+		errs := apiErrors(tt.status, []byte(tt.body))
+		var codes []string
+		for _, err := range errs {
+			codes = append(codes, err.Code)
+		}
+		if strings.Join(codes, ",") != strings.Join(tt.wantCodes, ",") {
+			t.Errorf("%s: codes %q, want %q", tt.name, codes, tt.wantCodes)
+		}
+		if errs[0].Message != tt.wantMessage || string(errs[0].Details) != tt.wantDetails {
+			t.Errorf("%s: first error = %+v", tt.name, errs[0])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// @Summary List execution safety violations
+// @Description Get the safety violations recorded while an execution ran, in time order. Every sample over a limit is kept; samples of one stretch over the limit share an episode_id. Counts by severity are in the execution's summary.
+// @Tags executions
+// @Produce json
+// @Param id path string true "Execution ID"
+// @Param severity query string false "Only violations of this severity" enums(info,warning,error,critical)
+// @Success 200 {array} models.Violation
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/executions/{id}/violations [get]
+func (s *Server) listViolations(c *gin.Context) {
+	execution := s.annotatedExecution(c)
+	if execution == nil {
+		return
+	}
+
+	violations, err := s.repo.ListViolations(execution.ID)
+	if err != nil {
+		s.logger.Error("Failed to list violations", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to list violations")
+		return
+	}
+
+	if severity := c.Query("severity"); severity != "" {
+		filtered := make([]models.Violation, 0, len(violations))
+		for _, violation := range violations {
+			if violation.Severity == severity {
+				filtered = append(filtered, violation)
+			}
+		}
+		violations = filtered
+	}
+	if violations == nil {
+		violations = []models.Violation{}
+	}
+
+	c.JSON(http.StatusOK, violations)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestListViolations(t *testing.T) {
+	server, repo := newTestServer(t)
+	project, _ := repo.GetProjectByName(models.DefaultProjectName)
+
+	start := time.Now().Add(-time.Hour).UTC()
+	end := start.Add(30 * time.Minute)
+	finished := models.TestExecution{TestID: "t1", ProjectID: project.ID, Status: models.StatusCompleted, StartTime: &start, EndTime: &end}
+	repo.CreateTestExecution(&finished)
+	elsewhere := models.TestExecution{TestID: "t2", ProjectID: "other", Status: models.StatusCompleted, StartTime: &start, EndTime: &end}
+	repo.CreateTestExecution(&elsewhere)
+
+	for i, severity := range []string{"critical", "warning", "warning"} {
+		repo.CreateViolation(&models.Violation{
+			ExecutionID: finished.ID,
+			Type:        "cpu_usage",
+			Severity:    severity,
+			Timestamp:   start.Add(time.Duration(3-i) * time.Minute),
+		})
+	}
+	repo.CreateViolation(&models.Violation{ExecutionID: elsewhere.ID, Type: "cpu_usage", Severity: "warning", Timestamp: start})
+
+	list := func(query string) []models.Violation {
+		t.Helper()
+		rec := server.serve(http.MethodGet, "/api/v1/executions/"+finished.ID+"/violations"+query, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", query, rec.Code, rec.Body)
+		}
+		var violations []models.Violation
+		json.Unmarshal(rec.Body.Bytes(), &violations)
+		return violations
+	}
+
+	violations := list("")
+	if len(violations) != 3 || violations[0].Severity != "warning" || violations[2].Severity != "critical" {
+		t.Errorf("violations = %+v, want all three in time order", violations)
+	}
+	if violations := list("?severity=critical"); len(violations) != 1 || violations[0].Severity != "critical" {
+		t.Errorf("critical violations = %+v", violations)
+	}
+	if violations := list("?severity=info"); len(violations) != 0 {
+		t.Errorf("info violations = %+v, want none", violations)
+	}
+
+	if rec := server.serve(http.MethodGet, "/api/v1/executions/"+elsewhere.ID+"/violations", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("execution in another project: status %d, want 404", rec.Code)
+	}
+}
@@ -1,11 +1,18 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/notify"
+	"github.com/pranavgopavaram/ssts/pkg/models"
 )
 
 const (
@@ -20,70 +27,351 @@ const (
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 512
+
+	// Defaults for hubs configured without queue sizes
+	defaultSendQueueSize   = 256
+	defaultBroadcastBuffer = 1024
 )
 
 // WSClient represents a WebSocket client
 type WSClient struct {
-	hub  *WebSocketHub
-	conn *websocket.Conn
-	send chan []byte
+	hub   *WebSocketHub
+	conn  *websocket.Conn
+	queue *sendQueue
+
+	// Reports whether the client may see messages about a test. Messages about no
+	// test are host-wide and visible to every client.
+	visible func(testID string) bool
+
+	// The authenticated user, who is sent their browser notifications; "" while
+	// auth is disabled
+	userID string
+
+	// Narrows the notifications sent to this connection, as the client asked
+	filterMu      sync.Mutex
+	notifications notificationFilter
+}
+
+// notificationFilter narrows the browser notifications a connection is sent,
+// e.g. for a dashboard that only shows failures. The zero value sends all of
+// those the user opted into.
+type notificationFilter struct {
+	Muted      bool     `json:"muted"`
+	Events     []string `json:"events,omitempty"`     // all events when empty
+	Severities []string `json:"severities,omitempty"` // all severities when empty
+}
+
+// wants reports whether the filter lets a notification through
+func (f notificationFilter) wants(n notify.Notification) bool {
+	if f.Muted {
+		return false
+	}
+	return (len(f.Events) == 0 || containsString(f.Events, n.Event)) &&
+		(len(f.Severities) == 0 || containsString(f.Severities, n.Severity))
 }
 
-// WebSocketHub maintains the set of active clients and broadcasts messages to them
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// userMessage is a notification for the connections of one user
+type userMessage struct {
+	userID       string
+	notification notify.Notification
+	data         []byte
+}
+
+// hubMessage is a message for the hub to fan out to its clients
+type hubMessage struct {
+	data   []byte
+	key    string // a later message with the same key supersedes it; "" for none
+	testID string // the test the message is about, sent only to clients that may see it; "" for host-wide
+}
+
+// WebSocketHub maintains the set of active clients and broadcasts messages to them.
+// Each client has a queue of its own the hub pushes messages onto without
+// waiting, and clients that fall behind are dealt with by the slow client policy.
 type WebSocketHub struct {
-	// Registered clients
+	// Registered clients. Only Run changes the map; mu lets Stats read it.
+	mu      sync.RWMutex
 	clients map[*WSClient]bool
 
 	// Inbound messages from the clients
-	broadcast chan []byte
+	broadcast chan hubMessage
+
+	// Notifications for one user's clients
+	direct chan userMessage
 
 	// Register requests from the clients
 	register chan *WSClient
 
 	// Unregister requests from clients
 	unregister chan *WSClient
+
+	// Recently broadcast messages, kept for reconnecting clients
+	replay *ReplayBuffer
+
+	// Shutdown requests; the hub closes every client and acknowledges on the channel
+	shutdown chan chan struct{}
+
+	// Set once the hub has shut down, after which new clients are turned away
+	stopped bool
+
+	// How clients' send queues are sized, and what happens when they fill
+	queueSize        int
+	slowClientPolicy string
+	maxQueueAge      time.Duration
+
+	// Messages with a key held until the next coalescing flush, the latest for
+	// each key, in the order the keys were first held
+	coalesceInterval time.Duration
+	heldMu           sync.Mutex
+	held             map[string]hubMessage
+	heldOrder        []string
+
+	counters hubCounters
+}
+
+// hubCounters count the hub's traffic, updated atomically
+type hubCounters struct {
+	broadcasts        uint64
+	broadcastsDropped uint64
+	coalesced         uint64
+	queued            uint64
+	queueDrops        uint64
+	evicted           uint64
+	messagesWritten   uint64
+	bytesWritten      uint64
+}
+
+// WebSocketStats is a snapshot of the hub's clients and the traffic through it
+// since it started
+type WebSocketStats struct {
+	Clients           int    // connected
+	QueuedMessages    int    // waiting in clients' send queues
+	MaxQueueDepth     int    // of the furthest behind client
+	Broadcasts        uint64 // messages broadcast
+	BroadcastsDropped uint64 // broadcasts dropped because the hub was too far behind
+	Coalesced         uint64 // messages superseded by a later one before being sent
+	Queued            uint64 // messages queued for clients
+	QueueDrops        uint64 // messages dropped from full send queues
+	Evicted           uint64 // clients disconnected for falling behind
+	MessagesWritten   uint64 // to connections
+	BytesWritten      uint64 // to connections
 }
 
 // NewWebSocketHub creates a new WebSocket hub
-func NewWebSocketHub() *WebSocketHub {
+func NewWebSocketHub(cfg config.WebSocketConfig) *WebSocketHub {
+	queueSize := cfg.SendQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultSendQueueSize
+	}
+	broadcastBuffer := cfg.BroadcastBuffer
+	if broadcastBuffer <= 0 {
+		broadcastBuffer = defaultBroadcastBuffer
+	}
+	policy := cfg.SlowClientPolicy
+	if policy == "" {
+		policy = SlowClientDisconnect
+	}
+
 	return &WebSocketHub{
-		broadcast:  make(chan []byte),
-		register:   make(chan *WSClient),
-		unregister: make(chan *WSClient),
-		clients:    make(map[*WSClient]bool),
+		broadcast:        make(chan hubMessage, broadcastBuffer),
+		direct:           make(chan userMessage),
+		register:         make(chan *WSClient),
+		unregister:       make(chan *WSClient),
+		clients:          make(map[*WSClient]bool),
+		replay:           NewReplayBuffer(cfg.ReplayBufferSize, cfg.ReplayMaxAge),
+		shutdown:         make(chan chan struct{}),
+		queueSize:        queueSize,
+		slowClientPolicy: policy,
+		maxQueueAge:      cfg.MaxQueueAge,
+		coalesceInterval: cfg.CoalesceInterval,
+		held:             make(map[string]hubMessage),
 	}
 }
 
+// newQueue creates a send queue for a client of the hub
+func (h *WebSocketHub) newQueue() *sendQueue {
+	return newSendQueue(h.queueSize)
+}
+
 // Run starts the WebSocket hub
 func (h *WebSocketHub) Run() {
+	var flush <-chan time.Time
+	if h.coalesceInterval > 0 {
+		ticker := time.NewTicker(h.coalesceInterval)
+		defer ticker.Stop()
+		flush = ticker.C
+	}
+
 	for {
 		select {
 		case client := <-h.register:
+			if h.stopped {
+				client.queue.close(goingAwayMessage(), true)
+				continue
+			}
+			h.mu.Lock()
 			h.clients[client] = true
-			log.Printf("WebSocket client connected, total clients: %d", len(h.clients))
+			total := len(h.clients)
+			h.mu.Unlock()
+			log.Printf("WebSocket client connected, total clients: %d", total)
 
 		case client := <-h.unregister:
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				log.Printf("WebSocket client disconnected, total clients: %d", len(h.clients))
+			h.mu.Lock()
+			_, ok := h.clients[client]
+			delete(h.clients, client)
+			total := len(h.clients)
+			h.mu.Unlock()
+			if ok {
+				client.queue.close(nil, false)
+				log.Printf("WebSocket client disconnected, total clients: %d", total)
 			}
 
 		case message := <-h.broadcast:
+			h.fanOut(message, nil)
+
+		case <-flush:
+			h.flushHeld()
+
+		case message := <-h.direct:
+			h.fanOut(hubMessage{data: message.data}, func(client *WSClient) bool {
+				return client.userID == message.userID && client.wantsNotification(message.notification)
+			})
+
+		case done := <-h.shutdown:
+			h.mu.Lock()
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+				client.queue.close(goingAwayMessage(), false)
+				delete(h.clients, client)
 			}
+			h.mu.Unlock()
+			h.stopped = true
+			log.Printf("WebSocket hub shut down")
+			close(done)
+		}
+	}
+}
+
+// fanOut queues a message for every client that may see it and it is for, or
+// all of those when isFor is nil, and disconnects those too far behind to keep
+func (h *WebSocketHub) fanOut(message hubMessage, isFor func(*WSClient) bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		if !client.sees(message.testID) || (isFor != nil && !isFor(client)) {
+			continue
+		}
+		switch client.queue.push(message.data, message.key, h.slowClientPolicy, h.maxQueueAge) {
+		case pushQueued:
+			atomic.AddUint64(&h.counters.queued, 1)
+		case pushCoalesced:
+			atomic.AddUint64(&h.counters.coalesced, 1)
+		case pushDroppedOldest:
+			atomic.AddUint64(&h.counters.queued, 1)
+			atomic.AddUint64(&h.counters.queueDrops, 1)
+		case pushDroppedNewest:
+			atomic.AddUint64(&h.counters.queueDrops, 1)
+		case pushFull:
+			client.queue.close(tooSlowMessage(), true)
+			delete(h.clients, client)
+			atomic.AddUint64(&h.counters.evicted, 1)
+			log.Printf("WebSocket client too far behind, disconnecting, total clients: %d", len(h.clients))
+		}
+	}
+}
+
+// flushHeld fans out the messages held for coalescing
+func (h *WebSocketHub) flushHeld() {
+	h.heldMu.Lock()
+	held, order := h.held, h.heldOrder
+	if len(order) == 0 {
+		h.heldMu.Unlock()
+		return
+	}
+	h.held, h.heldOrder = make(map[string]hubMessage, len(held)), nil
+	h.heldMu.Unlock()
+
+	for _, key := range order {
+		h.fanOut(held[key], nil)
+	}
+}
+
+// countWritten counts messages written to a client's connection
+func (h *WebSocketHub) countWritten(messages, bytes int) {
+	atomic.AddUint64(&h.counters.messagesWritten, uint64(messages))
+	atomic.AddUint64(&h.counters.bytesWritten, uint64(bytes))
+}
+
+// Stats returns the hub's clients and traffic
+func (h *WebSocketHub) Stats() WebSocketStats {
+	stats := WebSocketStats{
+		Broadcasts:        atomic.LoadUint64(&h.counters.broadcasts),
+		BroadcastsDropped: atomic.LoadUint64(&h.counters.broadcastsDropped),
+		Coalesced:         atomic.LoadUint64(&h.counters.coalesced),
+		Queued:            atomic.LoadUint64(&h.counters.queued),
+		QueueDrops:        atomic.LoadUint64(&h.counters.queueDrops),
+		Evicted:           atomic.LoadUint64(&h.counters.evicted),
+		MessagesWritten:   atomic.LoadUint64(&h.counters.messagesWritten),
+		BytesWritten:      atomic.LoadUint64(&h.counters.bytesWritten),
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	stats.Clients = len(h.clients)
+	for client := range h.clients {
+		depth := client.queue.len()
+		stats.QueuedMessages += depth
+		if depth > stats.MaxQueueDepth {
+			stats.MaxQueueDepth = depth
 		}
 	}
+	return stats
+}
+
+// Shutdown closes every client connection with a going-away close frame and
+// turns away clients that connect afterwards
+func (h *WebSocketHub) Shutdown() {
+	done := make(chan struct{})
+	h.shutdown <- done
+	<-done
+}
+
+// goingAwayMessage builds the close frame sent to clients when the server shuts down
+func goingAwayMessage() []byte {
+	return websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+}
+
+// tooSlowMessage builds the close frame sent to clients disconnected for falling
+// behind, which can reconnect and backfill what they missed with replay requests
+func tooSlowMessage() []byte {
+	return websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow")
 }
 
 // BroadcastMessage broadcasts a message to all connected clients
 func (h *WebSocketHub) BroadcastMessage(messageType string, data interface{}) {
+	h.broadcastTopic(messageType, "", data)
+}
+
+// broadcastTopic broadcasts a message and records it in the replay buffer under its
+// type, tagged with the test it belongs to (if any)
+func (h *WebSocketHub) broadcastTopic(messageType string, testID string, data interface{}) {
+	h.publish(messageType, testID, "", data)
+}
+
+// publish broadcasts a message as broadcastTopic does. A message with a key is
+// state a later message with the same key supersedes, such as an execution's
+// progress: the hub holds it and sends only the latest each coalescing interval,
+// and replaces it in the queues of clients that haven't been sent it yet. The
+// replay buffer keeps every message.
+func (h *WebSocketHub) publish(messageType string, testID string, key string, data interface{}) {
 	message := WSMessage{
 		Type:      messageType,
 		Timestamp: time.Now(),
@@ -96,16 +384,33 @@ func (h *WebSocketHub) BroadcastMessage(messageType string, data interface{}) {
 		return
 	}
 
+	h.replay.Add(messageType, testID, jsonData)
+	atomic.AddUint64(&h.counters.broadcasts, 1)
+
+	hubMsg := hubMessage{data: jsonData, key: key, testID: testID}
+	if key != "" && h.coalesceInterval > 0 {
+		h.heldMu.Lock()
+		if _, ok := h.held[key]; ok {
+			atomic.AddUint64(&h.counters.coalesced, 1)
+		} else {
+			h.heldOrder = append(h.heldOrder, key)
+		}
+		h.held[key] = hubMsg
+		h.heldMu.Unlock()
+		return
+	}
+
 	select {
-	case h.broadcast <- jsonData:
+	case h.broadcast <- hubMsg:
 	default:
+		atomic.AddUint64(&h.counters.broadcastsDropped, 1)
 		log.Printf("WebSocket broadcast channel full, dropping message")
 	}
 }
 
 // BroadcastTestUpdate broadcasts test execution updates
 func (h *WebSocketHub) BroadcastTestUpdate(testID string, status string, data interface{}) {
-	h.BroadcastMessage("test_update", map[string]interface{}{
+	h.broadcastTopic("test_update", testID, map[string]interface{}{
 		"test_id": testID,
 		"status":  status,
 		"data":    data,
@@ -114,15 +419,73 @@ func (h *WebSocketHub) BroadcastTestUpdate(testID string, status string, data in
 
 // BroadcastMetrics broadcasts real-time metrics
 func (h *WebSocketHub) BroadcastMetrics(testID string, metrics interface{}) {
-	h.BroadcastMessage("metrics_update", map[string]interface{}{
+	h.broadcastTopic("metrics_update", testID, map[string]interface{}{
 		"test_id": testID,
 		"metrics": metrics,
 	})
 }
 
-// BroadcastSystemMetrics broadcasts system-wide metrics
+// BroadcastProgress broadcasts the progress of a running execution of a test.
+// Only the latest progress of an execution is sent each coalescing interval.
+func (h *WebSocketHub) BroadcastProgress(testID string, executionID string, progress models.ExecutionProgress) {
+	h.publish("execution_progress", testID, "execution_progress:"+executionID, map[string]interface{}{
+		"test_id":      testID,
+		"execution_id": executionID,
+		"progress":     progress,
+	})
+}
+
+// BroadcastAnnotation broadcasts an annotation added to, or deleted from, an execution
+// of a test so dashboards can add or remove its chart marker
+func (h *WebSocketHub) BroadcastAnnotation(testID string, action string, annotation models.Annotation) {
+	h.broadcastTopic("annotation", testID, map[string]interface{}{
+		"test_id":    testID,
+		"action":     action,
+		"annotation": annotation,
+	})
+}
+
+// BroadcastPluginsChanged broadcasts the plugins loaded from, updated in or removed
+// from watched plugin directories so UIs can refresh their plugin lists
+func (h *WebSocketHub) BroadcastPluginsChanged(change models.PluginsChange) {
+	h.BroadcastMessage("plugins_changed", change)
+}
+
+// BroadcastConfigDrift broadcasts a change in how the configuration file differs
+// from the configuration in effect
+func (h *WebSocketHub) BroadcastConfigDrift(event ConfigDriftEvent) {
+	h.BroadcastMessage("config_drift", event)
+}
+
+// NotifyUser sends a notification to every connection of a user that hasn't
+// filtered it out. Users without an open connection don't see it.
+func (h *WebSocketHub) NotifyUser(ctx context.Context, userID string, n notify.Notification) error {
+	data, err := json.Marshal(WSMessage{Type: "notification", Timestamp: time.Now(), Data: n})
+	if err != nil {
+		return err
+	}
+	select {
+	case h.direct <- userMessage{userID: userID, notification: n, data: data}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wsNotificationChannel delivers browser notifications over the WebSocket, for
+// dashboards to show as they arrive
+type wsNotificationChannel struct {
+	hub *WebSocketHub
+}
+
+func (w wsNotificationChannel) Send(ctx context.Context, user models.User, prefs models.NotificationPreferences, n notify.Notification) error {
+	return w.hub.NotifyUser(ctx, user.ID, n)
+}
+
+// BroadcastSystemMetrics broadcasts system-wide metrics. Only the latest are sent
+// each coalescing interval.
 func (h *WebSocketHub) BroadcastSystemMetrics(metrics interface{}) {
-	h.BroadcastMessage("system_metrics", metrics)
+	h.publish("system_metrics", "", "system_metrics", metrics)
 }
 
 // BroadcastAlert broadcasts alert messages
@@ -172,27 +535,36 @@ func (c *WSClient) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
+		case <-c.queue.ready:
+			messages, closed, closeMessage := c.queue.take()
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			// Batch messages if available
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+			// Everything queued goes out in one frame, one message per line
+			if len(messages) > 0 {
+				w, err := c.conn.NextWriter(websocket.TextMessage)
+				if err != nil {
+					return
+				}
+				written := 0
+				for i, message := range messages {
+					if i > 0 {
+						w.Write([]byte{'\n'})
+						written++
+					}
+					w.Write(message)
+					written += len(message)
+				}
+				if err := w.Close(); err != nil {
+					return
+				}
+				c.hub.countWritten(len(messages), written)
 			}
 
-			if err := w.Close(); err != nil {
+			if closed {
+				if closeMessage == nil {
+					closeMessage = []byte{}
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
@@ -228,6 +600,14 @@ func (c *WSClient) handleMessage(message []byte) {
 			// TODO: Implement per-test unsubscriptions
 		}
 
+	case "replay":
+		// Send back recent messages for a topic so the client can backfill gaps
+		c.handleReplay(msg.Data)
+
+	case "notifications":
+		// Narrow the notifications sent to this connection
+		c.handleNotificationFilter(msg.Data)
+
 	case "ping":
 		// Respond to ping with pong
 		pongMessage := WSMessage{
@@ -236,11 +616,7 @@ func (c *WSClient) handleMessage(message []byte) {
 			Data:      msg.Data,
 		}
 		if jsonData, err := json.Marshal(pongMessage); err == nil {
-			select {
-			case c.send <- jsonData:
-			default:
-				close(c.send)
-			}
+			c.reply(jsonData, "pong")
 		}
 
 	default:
@@ -248,6 +624,100 @@ func (c *WSClient) handleMessage(message []byte) {
 	}
 }
 
+// handleReplay answers a replay request of the form
+// {"topic": "metrics_update", "test_id": "...", "limit": 100, "since": "RFC3339"}
+func (c *WSClient) handleReplay(data interface{}) {
+	request, ok := data.(map[string]interface{})
+	if !ok {
+		log.Printf("Invalid WebSocket replay request")
+		return
+	}
+
+	topic, _ := request["topic"].(string)
+	testID, _ := request["test_id"].(string)
+
+	limit := 0
+	if l, ok := request["limit"].(float64); ok {
+		limit = int(l)
+	}
+
+	var since time.Time
+	if s, ok := request["since"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			since = t
+		}
+	}
+
+	match := func(entryTestID string) bool {
+		if testID != "" && entryTestID != testID {
+			return false
+		}
+		return c.sees(entryTestID)
+	}
+	history := c.hub.replay.Get(topic, match, since, limit)
+	messages := make([]json.RawMessage, 0, len(history))
+	for _, payload := range history {
+		messages = append(messages, json.RawMessage(payload))
+	}
+
+	replayMessage := WSMessage{
+		Type:      "replay",
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"topic":    topic,
+			"test_id":  testID,
+			"messages": messages,
+		},
+	}
+	if jsonData, err := json.Marshal(replayMessage); err == nil {
+		c.reply(jsonData, "replay")
+	}
+}
+
+// handleNotificationFilter sets the connection's notification filter from a
+// request of the form {"muted": false, "events": ["execution_failed"],
+// "severities": ["error", "critical"]}, and echoes the filter back
+func (c *WSClient) handleNotificationFilter(data interface{}) {
+	raw, err := json.Marshal(data)
+	var filter notificationFilter
+	if err == nil {
+		err = json.Unmarshal(raw, &filter)
+	}
+	if err != nil {
+		log.Printf("Invalid WebSocket notification filter: %v", err)
+		return
+	}
+
+	c.filterMu.Lock()
+	c.notifications = filter
+	c.filterMu.Unlock()
+
+	if jsonData, err := json.Marshal(WSMessage{Type: "notifications", Timestamp: time.Now(), Data: filter}); err == nil {
+		c.reply(jsonData, "notification filter")
+	}
+}
+
+// reply queues an answer to a request from the client. If the client is behind
+// the answer is dropped rather than the broadcasts queued ahead of it.
+func (c *WSClient) reply(data []byte, what string) {
+	if c.queue.push(data, "", SlowClientDropNewest, 0) == pushDroppedNewest {
+		log.Printf("WebSocket client send queue full, dropping %s", what)
+	}
+}
+
+// sees reports whether the client may be sent a message about a test. Messages
+// about no test are host-wide and every client sees them.
+func (c *WSClient) sees(testID string) bool {
+	return testID == "" || (c.visible != nil && c.visible(testID))
+}
+
+// wantsNotification reports whether the connection's filter lets a notification through
+func (c *WSClient) wantsNotification(n notify.Notification) bool {
+	c.filterMu.Lock()
+	defer c.filterMu.Unlock()
+	return c.notifications.wants(n)
+}
+
 // WSMessage represents a WebSocket message
 type WSMessage struct {
 	Type      string      `json:"type"`
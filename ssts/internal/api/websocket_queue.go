@@ -0,0 +1,141 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// What the hub does with a client whose send queue is full
+const (
+	SlowClientDisconnect = "disconnect"  // close the connection; the client reconnects and backfills with replay
+	SlowClientDropOldest = "drop_oldest" // drop the oldest queued message to make room
+	SlowClientDropNewest = "drop_newest" // drop the message that doesn't fit
+)
+
+// pushResult is what became of a message pushed onto a send queue
+type pushResult int
+
+const (
+	pushQueued        pushResult = iota
+	pushCoalesced                // replaced a queued message with the same key
+	pushDroppedOldest            // queued after dropping the oldest message
+	pushDroppedNewest            // dropped, the queue being full
+	pushFull                     // not queued: the client has fallen too far behind to keep
+	pushClosed                   // not queued: the queue is closed
+)
+
+// queuedMessage is a message waiting to be written to a client
+type queuedMessage struct {
+	data   []byte
+	key    string // a later message with the same key replaces it; "" for none
+	queued time.Time
+}
+
+// sendQueue holds the messages waiting to be written to one client. The hub
+// pushes onto it without ever blocking, and the client's write pump takes
+// everything queued whenever ready is signalled, so one slow connection
+// doesn't hold up the others.
+type sendQueue struct {
+	mu       sync.Mutex
+	messages []queuedMessage
+	size     int
+	ready    chan struct{} // signalled when messages are queued or the queue is closed
+
+	closed       bool
+	closeMessage []byte // close frame written once the queue is drained
+}
+
+// newSendQueue creates a queue holding up to size messages
+func newSendQueue(size int) *sendQueue {
+	return &sendQueue{size: size, ready: make(chan struct{}, 1)}
+}
+
+// push queues a message. A message with a key replaces a queued one with the
+// same key where it stands, so a client that is behind gets only the latest.
+// When the queue is full, or its oldest message has waited longer than maxAge,
+// the policy says whether to drop a message or give up on the client.
+func (q *sendQueue) push(data []byte, key string, policy string, maxAge time.Duration) pushResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return pushClosed
+	}
+	if key != "" {
+		for i := range q.messages {
+			if q.messages[i].key == key {
+				q.messages[i].data = data
+				return pushCoalesced
+			}
+		}
+	}
+
+	now := time.Now()
+	if maxAge > 0 && len(q.messages) > 0 && now.Sub(q.messages[0].queued) > maxAge {
+		return pushFull
+	}
+
+	result := pushQueued
+	if len(q.messages) >= q.size {
+		switch policy {
+		case SlowClientDropOldest:
+			q.messages[0] = queuedMessage{}
+			q.messages = q.messages[1:]
+			result = pushDroppedOldest
+		case SlowClientDropNewest:
+			return pushDroppedNewest
+		default:
+			return pushFull
+		}
+	}
+	q.messages = append(q.messages, queuedMessage{data: data, key: key, queued: now})
+	q.signal()
+	return result
+}
+
+// take removes and returns every queued message, and whether the queue is
+// closed along with the close frame to write after them
+func (q *sendQueue) take() (messages [][]byte, closed bool, closeMessage []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	messages = make([][]byte, len(q.messages))
+	for i, message := range q.messages {
+		messages[i] = message.data
+	}
+	q.messages = nil
+	return messages, q.closed, q.closeMessage
+}
+
+// close stops the queue taking messages, with the close frame the client is
+// sent once the messages still queued are written, or straight away if discard
+// is set. Closing a closed queue does nothing.
+func (q *sendQueue) close(closeMessage []byte, discard bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.closeMessage = closeMessage
+	if discard {
+		q.messages = nil
+	}
+	q.signal()
+}
+
+// len returns the number of messages queued
+func (q *sendQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// signal wakes the write pump, if it isn't already due to wake
+func (q *sendQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendQueuePolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   pushResult
+		queued string
+	}{
+		{SlowClientDisconnect, pushFull, "ab"},
+		{SlowClientDropOldest, pushDroppedOldest, "bc"},
+		{SlowClientDropNewest, pushDroppedNewest, "ab"},
+	}
+	for _, tt := range tests {
+		queue := newSendQueue(2)
+		queue.push([]byte("a"), "", tt.policy, 0)
+		queue.push([]byte("b"), "", tt.policy, 0)
+		if got := queue.push([]byte("c"), "", tt.policy, 0); got != tt.want {
+			t.Errorf("%s: pushing onto a full queue = %d, want %d", tt.policy, got, tt.want)
+		}
+		messages, _, _ := queue.take()
+		if got := string(append(messages[0], messages[1]...)); got != tt.queued {
+			t.Errorf("%s: queued %q, want %q", tt.policy, got, tt.queued)
+		}
+	}
+}
+
+func TestSendQueueCoalescesByKey(t *testing.T) {
+	queue := newSendQueue(3)
+	queue.push([]byte("progress 1"), "e1", SlowClientDisconnect, 0)
+	queue.push([]byte("alert"), "", SlowClientDisconnect, 0)
+	if got := queue.push([]byte("progress 2"), "e1", SlowClientDisconnect, 0); got != pushCoalesced {
+		t.Errorf("pushing newer progress = %d, want coalesced", got)
+	}
+	messages, _, _ := queue.take()
+	if len(messages) != 2 || string(messages[0]) != "progress 2" || string(messages[1]) != "alert" {
+		t.Errorf("queued %q, want the latest progress where the first stood", messages)
+	}
+
+	// Once sent, progress is queued again
+	if got := queue.push([]byte("progress 3"), "e1", SlowClientDisconnect, 0); got != pushQueued {
+		t.Errorf("pushing progress after the queue was taken = %d, want queued", got)
+	}
+}
+
+func TestSendQueueMaxAge(t *testing.T) {
+	queue := newSendQueue(10)
+	queue.push([]byte("a"), "", SlowClientDropOldest, 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	if got := queue.push([]byte("b"), "", SlowClientDropOldest, 20*time.Millisecond); got != pushFull {
+		t.Errorf("pushing behind a stale message = %d, want full", got)
+	}
+}
+
+func TestSendQueueClose(t *testing.T) {
+	queue := newSendQueue(10)
+	queue.push([]byte("a"), "", SlowClientDisconnect, 0)
+	queue.close(goingAwayMessage(), false)
+	queue.close(nil, true)
+	if got := queue.push([]byte("b"), "", SlowClientDisconnect, 0); got != pushClosed {
+		t.Errorf("pushing onto a closed queue = %d, want closed", got)
+	}
+
+	select {
+	case <-queue.ready:
+	default:
+		t.Fatal("closing the queue didn't wake the writer")
+	}
+	messages, closed, closeMessage := queue.take()
+	if len(messages) != 1 || !closed || string(closeMessage) != string(goingAwayMessage()) {
+		t.Errorf("took %q, closed %v with %q, want the queued message then going away", messages, closed, closeMessage)
+	}
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/notify"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestWebSocketShutdownSendsGoingAway(t *testing.T) {
+	server, _ := newTestServer(t)
+	httpServer := httptest.NewServer(server.engine)
+	defer httpServer.Close()
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/ws"
+
+	// closeCode reads from a connection until it is closed and returns the close code
+	closeCode := func(conn *websocket.Conn) int {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					return closeErr.Code
+				}
+				t.Fatalf("read: %v", err)
+			}
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Wait for the hub to register the client
+	time.Sleep(50 * time.Millisecond)
+
+	server.wsHub.Shutdown()
+	if code := closeCode(conn); code != websocket.CloseGoingAway {
+		t.Errorf("connected client closed with %d, want going away", code)
+	}
+
+	// Clients connecting after the shutdown are turned away the same way
+	late, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Close()
+	if code := closeCode(late); code != websocket.CloseGoingAway {
+		t.Errorf("late client closed with %d, want going away", code)
+	}
+}
+
+func TestRunTestWhileDraining(t *testing.T) {
+	server, repo := newTestServer(t)
+	createDefaultProjectTest(t, repo, models.TestConfiguration{ID: "t1", Name: "burn", Plugin: "cpu-stress", Duration: time.Second})
+
+	server.orchestrator.BeginDrain()
+	rec := server.serve(http.MethodPost, "/api/v1/tests/t1/run", models.TestParams{})
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("run while draining: status %d, want 503: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestBrowserNotificationsReachTheUsersConnections(t *testing.T) {
+	hub := NewWebSocketHub(config.WebSocketConfig{ReplayBufferSize: 10})
+	go hub.Run()
+	defer hub.Shutdown()
+
+	connect := func(userID string) *WSClient {
+		client := &WSClient{hub: hub, queue: newSendQueue(4), userID: userID}
+		hub.register <- client
+		return client
+	}
+	dashboard, failuresOnly, other := connect("alice"), connect("alice"), connect("bob")
+
+	// One of alice's dashboards only wants failures
+	failuresOnly.handleMessage([]byte(`{"type": "notifications", "data": {"events": ["execution_failed"]}}`))
+	var echo WSMessage
+	if json.Unmarshal(nextMessage(failuresOnly), &echo); echo.Type != "notifications" {
+		t.Errorf("filter acknowledged with %+v", echo)
+	}
+
+	channel := wsNotificationChannel{hub: hub}
+	completed := notify.Notification{Event: models.NotifyExecutionCompleted, Severity: "info", Title: "done"}
+	if err := channel.Send(context.Background(), models.User{ID: "alice"}, models.NotificationPreferences{}, completed); err != nil {
+		t.Fatal(err)
+	}
+	failed := notify.Notification{Event: models.NotifyExecutionFailed, Severity: "error", Title: "failed"}
+	channel.Send(context.Background(), models.User{ID: "alice"}, models.NotificationPreferences{}, failed)
+	// The hub handles requests in order, so this one has seen both notifications
+	hub.register <- &WSClient{hub: hub, queue: newSendQueue(1)}
+
+	titles := func(client *WSClient) []string {
+		var got []string
+		messages, _, _ := client.queue.take()
+		for _, data := range messages {
+			var message struct {
+				Type string              `json:"type"`
+				Data notify.Notification `json:"data"`
+			}
+			json.Unmarshal(data, &message)
+			if message.Type == "notification" {
+				got = append(got, message.Data.Title)
+			}
+		}
+		return got
+	}
+	if got := titles(dashboard); len(got) != 2 {
+		t.Errorf("dashboard got %v, want both notifications", got)
+	}
+	if got := titles(failuresOnly); len(got) != 1 || got[0] != "failed" {
+		t.Errorf("failures-only dashboard got %v, want only the failure", got)
+	}
+	if got := titles(other); len(got) != 0 {
+		t.Errorf("another user's dashboard got %v", got)
+	}
+}
+
+func TestWebSocketHubCoalescesAndEvictsSlowClients(t *testing.T) {
+	hub := NewWebSocketHub(config.WebSocketConfig{SendQueueSize: 2, CoalesceInterval: time.Minute})
+	everything := func(string) bool { return true }
+	fast := &WSClient{hub: hub, queue: newSendQueue(10), visible: everything}
+	slow := &WSClient{hub: hub, queue: hub.newQueue(), visible: everything}
+	hub.clients[fast], hub.clients[slow] = true, true
+
+	// Progress is held until the next flush, which sends each execution's latest
+	for percent := 10.0; percent <= 30; percent += 10 {
+		hub.BroadcastProgress("t1", "e1", models.ExecutionProgress{Percent: percent})
+	}
+	hub.BroadcastProgress("t1", "e2", models.ExecutionProgress{Percent: 50})
+	if len(hub.broadcast) != 0 {
+		t.Fatal("progress broadcast without waiting for the coalescing interval")
+	}
+	hub.flushHeld()
+
+	percents := func(client *WSClient) []float64 {
+		var got []float64
+		messages, _, _ := client.queue.take()
+		for _, data := range messages {
+			var message struct {
+				Data struct {
+					Progress models.ExecutionProgress `json:"progress"`
+				} `json:"data"`
+			}
+			json.Unmarshal(data, &message)
+			got = append(got, message.Data.Progress.Percent)
+		}
+		return got
+	}
+	if got := percents(fast); len(got) != 2 || got[0] != 30 || got[1] != 50 {
+		t.Errorf("fast client sent progress %v, want the latest of each execution", got)
+	}
+
+	// The slow client's queue is full, but newer progress replaces what it holds
+	hub.BroadcastProgress("t1", "e1", models.ExecutionProgress{Percent: 40})
+	hub.flushHeld()
+	if _, ok := hub.clients[slow]; !ok || slow.queue.len() != 2 {
+		t.Fatalf("slow client disconnected over progress it hadn't been sent yet")
+	}
+
+	// Anything else doesn't fit, so it is disconnected
+	hub.BroadcastAlert("anomaly", "spike", "warning")
+	hub.fanOut(<-hub.broadcast, nil)
+	if _, ok := hub.clients[slow]; ok {
+		t.Fatal("slow client kept with a full queue")
+	}
+	messages, closed, closeMessage := slow.queue.take()
+	if len(messages) != 0 || !closed || string(closeMessage) != string(tooSlowMessage()) {
+		t.Errorf("slow client left with %d messages, closed %v with %q", len(messages), closed, closeMessage)
+	}
+	if got := percents(fast); len(got) != 2 || got[0] != 40 {
+		t.Errorf("fast client sent %v after the slow client fell behind", got)
+	}
+
+	stats := hub.Stats()
+	want := WebSocketStats{Clients: 1, Broadcasts: 6, Coalesced: 3, Queued: 6, Evicted: 1}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestWebSocketStatsExported(t *testing.T) {
+	server, _ := newTestServer(t)
+	httpServer := httptest.NewServer(server.engine)
+	defer httpServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Wait for the hub to register the client
+	time.Sleep(50 * time.Millisecond)
+
+	server.wsHub.BroadcastAlert("anomaly", "spike", "warning")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The writer counts the message once it is written
+	time.Sleep(10 * time.Millisecond)
+
+	body := server.serve(http.MethodGet, "/metrics", nil).Body.String()
+	for _, want := range []string{
+		"ssts_websocket_clients 1\n",
+		"ssts_websocket_broadcasts_total 1\n",
+		"ssts_websocket_messages_written_total 1\n",
+		fmt.Sprintf("ssts_websocket_bytes_written_total %d\n", len(data)),
+		"# TYPE ssts_websocket_clients_evicted_total counter\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+// nextMessage takes the oldest message queued for a client, or nil if there is none
+func nextMessage(client *WSClient) []byte {
+	client.queue.mu.Lock()
+	defer client.queue.mu.Unlock()
+	if len(client.queue.messages) == 0 {
+		return nil
+	}
+	data := client.queue.messages[0].data
+	client.queue.messages = client.queue.messages[1:]
+	return data
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/core"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// executionMetricsRange reads the time range of a request for an execution's
+// metrics: the named window query parameter, or start and end. It responds with
+// an error and returns false if they are invalid or the window can't be resolved.
+func (s *Server) executionMetricsRange(c *gin.Context, executionID string) (models.TimeRange, bool) {
+	if window := c.Query("window"); window != "" {
+		if !noExplicitRange(c) {
+			return models.TimeRange{}, false
+		}
+		execution, err := s.windowExecution(executionID)
+		if err != nil {
+			respondError(c, http.StatusNotFound, "Execution not found")
+			return models.TimeRange{}, false
+		}
+		timeRange, err := s.orchestrator.ExecutionWindow(*execution, window)
+		if err != nil {
+			s.respondWindowError(c, err)
+			return models.TimeRange{}, false
+		}
+		return timeRange, true
+	}
+
+	var timeRange models.TimeRange
+	start, err := parseTimeQuery(c, "start")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return timeRange, false
+	}
+	if start != nil {
+		timeRange.Start = *start
+	}
+	end, err := parseTimeQuery(c, "end")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return timeRange, false
+	}
+	if end != nil {
+		timeRange.End = *end
+	}
+	return timeRange, true
+}
+
+// windowExecution returns an execution whose window is asked for, from the
+// orchestrator while it holds it or else from the repository, where finished
+// executions are kept with the summary recording their steady state
+func (s *Server) windowExecution(id string) (*models.TestExecution, error) {
+	if execution, err := s.orchestrator.GetTestStatus(id); err == nil {
+		return execution, nil
+	}
+	return s.repo.GetTestExecution(id)
+}
+
+// noExplicitRange responds with an error and returns false if a request naming
+// a window also gives start or end
+func noExplicitRange(c *gin.Context) bool {
+	if c.Query("start") != "" || c.Query("end") != "" {
+		respondError(c, http.StatusBadRequest, "A named window can't be combined with start or end")
+		return false
+	}
+	return true
+}
+
+// respondWindowError responds to a named window that couldn't be resolved
+func (s *Server) respondWindowError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, core.ErrUnknownWindow):
+		respondError(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, core.ErrNoRuns):
+		respondError(c, http.StatusNotFound, "The test has not been run")
+	case errors.Is(err, core.ErrNoSteadyState):
+		respondErrorCode(c, http.StatusConflict, CodeNoSteadyState, "The execution hasn't reached steady state", nil)
+	default:
+		s.logger.Error("Failed to resolve metric window", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "Failed to resolve metric window")
+	}
+}
@@ -1,22 +1,46 @@
 package config
 
 import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"encoding/base64"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	InfluxDB InfluxDBConfig `mapstructure:"influxdb"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	Log      LogConfig      `mapstructure:"log"`
-	Safety   SafetyConfig   `mapstructure:"safety"`
-	Auth     AuthConfig     `mapstructure:"auth"`
-	Metrics  MetricsConfig  `mapstructure:"metrics"`
+	Server        ServerConfig        `mapstructure:"server"`
+	Database      DatabaseConfig      `mapstructure:"database"`
+	InfluxDB      InfluxDBConfig      `mapstructure:"influxdb"`
+	Redis         RedisConfig         `mapstructure:"redis"`
+	Log           LogConfig           `mapstructure:"log"`
+	Safety        SafetyConfig        `mapstructure:"safety"`
+	Auth          AuthConfig          `mapstructure:"auth"`
+	Metrics       MetricsConfig       `mapstructure:"metrics"`
+	Profiles      ProfilesConfig      `mapstructure:"profiles"`
+	Export        ExportConfig        `mapstructure:"export"`
+	Diagnostics   DiagnosticsConfig   `mapstructure:"diagnostics"`
+	Endurance     EnduranceConfig     `mapstructure:"endurance"`
+	Ingest        IngestConfig        `mapstructure:"ingest"`
+	Publish       PublishConfig       `mapstructure:"publish"`
+	Notifications NotificationsConfig `mapstructure:"notifications"`
+	Plugins       PluginsConfig       `mapstructure:"plugins"`
+	Probes        ProbesConfig        `mapstructure:"probes"`
+	Encryption    EncryptionConfig    `mapstructure:"encryption"`
+	Resources     ResourcesConfig     `mapstructure:"resources"`
+
+	// Environment is the environment the configuration was loaded for, if any
+	Environment string `mapstructure:"-"`
+	// File is the configuration file read, if any
+	File string `mapstructure:"-"`
 }
 
 // ServerConfig contains HTTP server configuration
@@ -27,6 +51,14 @@ type ServerConfig struct {
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
 	TLS          TLSConfig     `mapstructure:"tls"`
 	CORS         CORSConfig    `mapstructure:"cors"`
+	WebSocket    WebSocketConfig `mapstructure:"websocket"`
+	RateLimit    RateLimitConfig `mapstructure:"rate_limit"`
+	Shutdown     ShutdownConfig  `mapstructure:"shutdown"`
+	Deprecation  DeprecationConfig `mapstructure:"deprecation"`
+	Health       HealthConfig      `mapstructure:"health"`
+	StatusPage   StatusPageConfig  `mapstructure:"status_page"`
+	ConfigDriftInterval time.Duration `mapstructure:"config_drift_interval"` // how often the config file is compared with the configuration in effect; 0 disables it
+	TrustedProxies []string `mapstructure:"trusted_proxies"` // addresses or CIDRs of reverse proxies whose X-Forwarded-For gives the client's IP; none by default
 }
 
 // TLSConfig contains TLS configuration
@@ -43,6 +75,58 @@ type CORSConfig struct {
 	AllowHeaders []string `mapstructure:"allow_headers"`
 }
 
+// WebSocketConfig contains WebSocket hub configuration
+type WebSocketConfig struct {
+	ReplayBufferSize int           `mapstructure:"replay_buffer_size"`
+	ReplayMaxAge     time.Duration `mapstructure:"replay_max_age"`
+	ProgressInterval time.Duration `mapstructure:"progress_interval"` // how often running executions' progress is broadcast; 0 disables it
+	SendQueueSize    int           `mapstructure:"send_queue_size"`    // messages queued per client waiting to be written
+	SlowClientPolicy string        `mapstructure:"slow_client_policy"` // when a client's queue is full: disconnect, drop_oldest or drop_newest
+	MaxQueueAge      time.Duration `mapstructure:"max_queue_age"`      // disconnect clients with a message queued this long; 0 for no limit
+	CoalesceInterval time.Duration `mapstructure:"coalesce_interval"`  // progress and system metrics are sent at most this often, latest only; 0 sends every one
+	BroadcastBuffer  int           `mapstructure:"broadcast_buffer"`   // messages waiting for the hub to fan them out
+}
+
+// RateLimitConfig contains API rate limiting configuration
+type RateLimitConfig struct {
+	Enabled                 bool     `mapstructure:"enabled"`
+	RequestsPerSecond       float64  `mapstructure:"requests_per_second"` // per client IP
+	Burst                   int      `mapstructure:"burst"`
+	APIKeyRequestsPerSecond float64  `mapstructure:"api_key_requests_per_second"` // per API key
+	APIKeyBurst             int      `mapstructure:"api_key_burst"`
+	APIKeyHeader            string   `mapstructure:"api_key_header"`
+	ExemptPaths             []string `mapstructure:"exempt_paths"`
+}
+
+// ShutdownConfig controls how running executions are drained when the server stops
+type ShutdownConfig struct {
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"` // how long to wait for running executions to finish
+	StopRunning  bool          `mapstructure:"stop_running"`  // stop running executions immediately instead of waiting
+	StopTimeout  time.Duration `mapstructure:"stop_timeout"`  // how long stopped executions get to clean up
+}
+
+// DeprecationConfig controls the headers announcing that /api/v1 is deprecated
+// in favour of /api/v2. Dates are YYYY-MM-DD.
+type DeprecationConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Date    string `mapstructure:"date"`   // when v1 was deprecated; the header is just "true" when empty
+	Sunset  string `mapstructure:"sunset"` // when v1 will be removed; no Sunset header when empty
+	Link    string `mapstructure:"link"`   // migration guide, sent as a Link with rel="deprecation"
+}
+
+// HealthConfig controls the dependency checks behind /health and /readyz
+type HealthConfig struct {
+	CacheTTL time.Duration `mapstructure:"cache_ttl"` // how long a check is reused; 0 checks on every request
+	Timeout  time.Duration `mapstructure:"timeout"`   // how long each dependency gets to answer
+}
+
+// StatusPageConfig contains the public status page configuration
+type StatusPageConfig struct {
+	Enabled bool          `mapstructure:"enabled"` // serve /status and /api/v1/status without authentication
+	Title   string        `mapstructure:"title"`   // heading of the page
+	Refresh time.Duration `mapstructure:"refresh"` // how often the page reloads itself
+}
+
 // DatabaseConfig contains database configuration
 type DatabaseConfig struct {
 	Type     string `mapstructure:"type"`
@@ -56,10 +140,22 @@ type DatabaseConfig struct {
 
 // InfluxDBConfig contains InfluxDB configuration
 type InfluxDBConfig struct {
-	URL    string `mapstructure:"url"`
-	Token  string `mapstructure:"token"`
-	Org    string `mapstructure:"org"`
-	Bucket string `mapstructure:"bucket"`
+	URL    string     `mapstructure:"url"`
+	Token  string     `mapstructure:"token"`
+	Org    string     `mapstructure:"org"`
+	Bucket string     `mapstructure:"bucket"`
+	Flux   FluxConfig `mapstructure:"flux"`
+}
+
+// FluxConfig controls the admin endpoint running custom Flux queries against
+// the bucket. Queries run with ReadToken rather than Token, which should be an
+// InfluxDB token allowed only to read the bucket.
+type FluxConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	ReadToken  string        `mapstructure:"read_token"`
+	MaxRange   time.Duration `mapstructure:"max_range"`   // longest time range a query may read
+	Timeout    time.Duration `mapstructure:"timeout"`     // how long a query may run
+	MaxRecords int           `mapstructure:"max_records"` // records returned before the result is truncated
 }
 
 // RedisConfig contains Redis configuration
@@ -81,6 +177,14 @@ type SafetyConfig struct {
 	GlobalLimits    GlobalLimits    `mapstructure:"global_limits"`
 	Monitoring      MonitoringConfig `mapstructure:"monitoring"`
 	RampUp          RampUpConfig    `mapstructure:"ramp_up"`
+	RampDown        RampDownConfig  `mapstructure:"ramp_down"`
+	Watchdog        WatchdogConfig  `mapstructure:"watchdog"`
+	Soak            SoakConfig      `mapstructure:"soak"`
+	Preflight       PreflightConfig `mapstructure:"preflight"`
+	Conflicts       ConflictsConfig `mapstructure:"conflicts"`
+	KernelLog       KernelLogConfig `mapstructure:"kernel_log"`
+	DiskGuard       DiskGuardConfig `mapstructure:"disk_guard"`
+	CustomChecks    []CustomCheckConfig `mapstructure:"custom_checks"`
 	EmergencyStop   bool           `mapstructure:"emergency_stop"`
 }
 
@@ -90,6 +194,8 @@ type GlobalLimits struct {
 	MaxMemoryPercent          float64 `mapstructure:"max_memory_percent"`
 	MaxDiskPercent            float64 `mapstructure:"max_disk_percent"`
 	EmergencyStopThreshold    float64 `mapstructure:"emergency_stop_threshold"`
+	MinTestDuration           time.Duration `mapstructure:"min_test_duration"`
+	MaxTestDuration           time.Duration `mapstructure:"max_test_duration"`
 }
 
 // MonitoringConfig contains monitoring configuration
@@ -97,6 +203,8 @@ type MonitoringConfig struct {
 	CheckInterval    time.Duration `mapstructure:"check_interval"`
 	AlertThreshold   float64       `mapstructure:"alert_threshold"`
 	AutoStopEnabled  bool          `mapstructure:"auto_stop_enabled"`
+	EpisodeGap       time.Duration `mapstructure:"episode_gap"`      // a violation episode closes once its limit hasn't been exceeded for this long
+	ReAlertInterval  time.Duration `mapstructure:"realert_interval"` // how often an ongoing episode is alerted again; 0 alerts only when it opens, escalates and closes
 }
 
 // RampUpConfig contains ramp-up configuration
@@ -106,6 +214,92 @@ type RampUpConfig struct {
 	Steps    int           `mapstructure:"steps"`
 }
 
+// RampDownConfig contains ramp-down and post-test cooldown configuration
+type RampDownConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Duration time.Duration `mapstructure:"duration"`
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+// WatchdogConfig contains the execution watchdog configuration. The watchdog stops
+// executions that outlive their wall-clock budget (duration plus cooldown plus
+// grace), whether or not the plugin honours its context.
+type WatchdogConfig struct {
+	Enabled         bool                     `mapstructure:"enabled"`
+	Grace           time.Duration            `mapstructure:"grace"`             // slack over the planned run time before the watchdog fires
+	KillTimeout     time.Duration            `mapstructure:"kill_timeout"`      // time a cancelled execution gets to return before it is abandoned
+	RoleMaxDuration map[string]time.Duration `mapstructure:"role_max_duration"` // per user role caps, below global_limits.max_test_duration
+}
+
+// SoakConfig contains soak mode configuration. Soak executions may run for days;
+// their progress and a rolling metric summary are checkpointed to the database so
+// a server restart can resume them instead of losing them.
+type SoakConfig struct {
+	Enabled            bool          `mapstructure:"enabled"`
+	MaxDuration        time.Duration `mapstructure:"max_duration"`        // replaces global_limits.max_test_duration for soak runs
+	CheckpointInterval time.Duration `mapstructure:"checkpoint_interval"` // how often progress is saved
+	ResumeOnStartup    bool          `mapstructure:"resume_on_startup"`   // continue interrupted soak runs for their remaining duration
+}
+
+// PreflightConfig contains the health checks run before a test starts, so stress
+// isn't added to a machine that is already loaded. A run failing them is rejected
+// with the reasons, or queued until the machine recovers.
+type PreflightConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	MaxCPUPercent    float64       `mapstructure:"max_cpu_percent"` // current usage must be at or below these; 0 skips a check
+	MaxMemoryPercent float64       `mapstructure:"max_memory_percent"`
+	MaxDiskPercent   float64       `mapstructure:"max_disk_percent"`
+	MaxTemperature   float64       `mapstructure:"max_temperature"` // degrees Celsius
+	Queue            bool          `mapstructure:"queue"`           // hold failing runs until the checks pass instead of rejecting them
+	QueueTimeout     time.Duration `mapstructure:"queue_timeout"`   // fail a queued run that hasn't started after this long
+	RetryInterval    time.Duration `mapstructure:"retry_interval"`  // how often queued runs are re-checked
+}
+
+// ConflictsConfig controls detection of concurrent runs claiming the same
+// resources, such as two I/O tests on one device. Queued runs are retried and
+// time out as configured for the preflight checks.
+type ConflictsConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Queue   bool `mapstructure:"queue"` // hold conflicting runs until the resources are free instead of rejecting them
+}
+
+// KernelLogConfig controls tailing the kernel log while tests run. Machine check,
+// I/O, OOM kill and thermal events it reports are recorded as violations of the
+// running executions. Reading it usually needs root; without access, runs go on
+// unwatched.
+type KernelLogConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// DiskGuardConfig bounds what a run's test files may take from disk. A run that
+// has written its quota, or has left a file system it writes to with less free
+// than the floor, has its test files removed and is stopped, with a critical
+// violation recorded, rather than filling the disk.
+type DiskGuardConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	QuotaMB        int64         `mapstructure:"quota_mb"`         // bytes a run may write, counting overwrites; 0 for no quota. Runs may ask for less.
+	MinFreePercent float64       `mapstructure:"min_free_percent"` // free space floor of the file systems written to
+	MinFreeMB      int64         `mapstructure:"min_free_mb"`      // and in absolute terms; 0 skips either check
+	CheckInterval  time.Duration `mapstructure:"check_interval"`
+}
+
+// CustomCheckConfig is a safety check of the operator's, evaluated with the
+// built-in limits every safety check. A command check exits 0 when all is well,
+// 1 on a violation and 2 on a critical one, as Nagios checks do; a metric check
+// is violated while its expression holds; a webhook check answers a GET with
+// {"status": "ok", "violation" or "critical", "message": ...}. Critical
+// violations stop the run when emergency_stop is on.
+type CustomCheckConfig struct {
+	Name       string            `mapstructure:"name"`       // violations are of type custom_<name>
+	Type       string            `mapstructure:"type"`       // command, metric or webhook
+	Command    []string          `mapstructure:"command"`    // program and arguments of a command check
+	Expression string            `mapstructure:"expression"` // of a metric check, e.g. "temperature_celsius > 80 && cpu_percent < 20"
+	URL        string            `mapstructure:"url"`        // of a webhook check
+	Headers    map[string]string `mapstructure:"headers"`    // sent to a webhook check
+	Timeout    time.Duration     `mapstructure:"timeout"`    // of command and webhook checks; 5s if unset
+	Critical   bool              `mapstructure:"critical"`   // every violation is critical
+}
+
 // AuthConfig contains authentication configuration
 type AuthConfig struct {
 	Enabled       bool          `mapstructure:"enabled"`
@@ -120,7 +314,60 @@ type MetricsConfig struct {
 	CollectionInterval time.Duration `mapstructure:"collection_interval"`
 	BatchSize         int           `mapstructure:"batch_size"`
 	FlushInterval     time.Duration `mapstructure:"flush_interval"`
+	BufferSize        int           `mapstructure:"buffer_size"` // metric points each execution keeps in memory
 	Retention         RetentionConfig `mapstructure:"retention"`
+	Outputs           []string            `mapstructure:"outputs"`      // where collected metrics are shipped: influxdb and/or remote_write
+	RemoteWrite       []RemoteWriteConfig `mapstructure:"remote_write"` // targets of the remote_write output
+	Anomalies         AnomaliesConfig     `mapstructure:"anomalies"`
+}
+
+// Metric outputs
+const (
+	MetricsOutputInfluxDB    = "influxdb"
+	MetricsOutputRemoteWrite = "remote_write"
+)
+
+// HasOutput reports whether metrics are shipped to the output. Without any
+// outputs configured they go to InfluxDB.
+func (m MetricsConfig) HasOutput(output string) bool {
+	if len(m.Outputs) == 0 {
+		return output == MetricsOutputInfluxDB
+	}
+	for _, configured := range m.Outputs {
+		if configured == output {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteWriteConfig is a Prometheus remote-write endpoint system and plugin
+// metrics are shipped to, such as Thanos Receive, Mimir or VictoriaMetrics.
+// Samples are sent in batches of metrics.batch_size every metrics.flush_interval.
+type RemoteWriteConfig struct {
+	Name           string            `mapstructure:"name"`            // identifies the target in health checks; defaults to its URL's host
+	URL            string            `mapstructure:"url"`             // e.g. http://mimir:8080/api/v1/push
+	Timeout        time.Duration     `mapstructure:"timeout"`         // per request; 10s if unset
+	QueueSize      int               `mapstructure:"queue_size"`      // samples held while the target is down, the oldest dropped beyond it; 100000 if unset
+	Username       string            `mapstructure:"username"`        // basic auth
+	Password       string            `mapstructure:"password"`        // basic auth
+	Token          string            `mapstructure:"token"`           // bearer token
+	TokenFile      string            `mapstructure:"token_file"`      // read before every request, for tokens refreshed by another process
+	Headers        map[string]string `mapstructure:"headers"`         // e.g. X-Scope-OrgID for multi-tenant Mimir
+	ExternalLabels map[string]string `mapstructure:"external_labels"` // added to every series, e.g. cluster
+}
+
+// AnomaliesConfig controls the detection of anomalies in running executions'
+// live metrics: throughput metrics that drop, or latency metrics that spike, by
+// more than Threshold standard deviations from their exponentially weighted
+// moving average
+type AnomaliesConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	Threshold        float64       `mapstructure:"threshold"`          // z-score a value must exceed
+	Alpha            float64       `mapstructure:"alpha"`              // weight of each new value in the moving average, between 0 and 1
+	Warmup           int           `mapstructure:"warmup"`             // values of a metric seen before it is checked
+	MinChangePercent float64       `mapstructure:"min_change_percent"` // smallest change from the average that is flagged
+	Cooldown         time.Duration `mapstructure:"cooldown"`           // between anomalies flagged for the same metric
 }
 
 // RetentionConfig contains data retention configuration
@@ -131,6 +378,197 @@ type RetentionConfig struct {
 	Archive        time.Duration `mapstructure:"archive"`
 }
 
+// ProfilesConfig controls how results are graded against reference hardware profiles
+type ProfilesConfig struct {
+	Enabled              bool    `mapstructure:"enabled"`
+	DefaultMarginPercent float64 `mapstructure:"default_margin_percent"` // for profiles that don't set their own margin
+	AlertOnFailure       bool    `mapstructure:"alert_on_failure"`       // raise an alert when hardware underperforms its profile
+}
+
+// ExportConfig controls metric exports
+type ExportConfig struct {
+	Directory      string        `mapstructure:"directory"`       // where exports generated in the background are written
+	AsyncThreshold time.Duration `mapstructure:"async_threshold"` // exports spanning longer than this are generated in the background
+	JobRetention   time.Duration `mapstructure:"job_retention"`   // how long finished export jobs and their files are kept
+}
+
+// DiagnosticsConfig controls the bundles captured when an execution fails: its
+// last metric points, safety violations and plugin config, the kernel log and a
+// goroutine dump, written to Directory as <execution id>.tar.gz
+type DiagnosticsConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	Directory      string `mapstructure:"directory"`
+	MetricPoints   int    `mapstructure:"metric_points"`    // most recent points included
+	KernelLogLines int    `mapstructure:"kernel_log_lines"` // most recent kernel log lines included
+}
+
+// EnduranceConfig controls tracking of the cumulative bytes tests write to each
+// storage device, and the warnings raised as devices near their rated endurance
+type EnduranceConfig struct {
+	Enabled     bool                    `mapstructure:"enabled"`
+	WarnPercent float64                 `mapstructure:"warn_percent"` // share of a device's budget at which to warn
+	Devices     []DeviceEnduranceBudget `mapstructure:"devices"`
+}
+
+// DeviceEnduranceBudget is how much tests may write to a device over its life
+type DeviceEnduranceBudget struct {
+	Device string `mapstructure:"device"` // serial number, or device name such as /dev/nvme0n1p1
+	TBW    string `mapstructure:"tbw"`    // e.g. 600TB
+}
+
+// IngestConfig controls the endpoint remote agents push metric points to
+type IngestConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	MaxBatchSize int           `mapstructure:"max_batch_size"` // points accepted per request
+	MaxClockSkew time.Duration `mapstructure:"max_clock_skew"` // how far in the future a point's timestamp may be
+	MaxAge       time.Duration `mapstructure:"max_age"`        // how old a point's timestamp may be
+	DedupWindow  time.Duration `mapstructure:"dedup_window"`   // how long pushed points are remembered to drop retried duplicates
+
+	// RequireHandshake refuses points from agents that haven't negotiated their
+	// protocol version. Agents speaking a version the server no longer supports
+	// are refused either way.
+	RequireHandshake bool               `mapstructure:"require_handshake"`
+	AgentUpdates     AgentUpdatesConfig `mapstructure:"agent_updates"`
+}
+
+// AgentUpdatesConfig controls the channel agents update themselves through. The
+// server serves the newest release in ReleaseDir to agents running an older
+// version while they are idle. Releases are signed offline with the release
+// key; the server only serves those verifying against PublicKey.
+type AgentUpdatesConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	ReleaseDir string `mapstructure:"release_dir"` // <release_dir>/<version>/ssts-agent-<os>-<arch> with a .sig beside each
+	PublicKey  string `mapstructure:"public_key"`  // base64 Ed25519 public key of the release signing key
+}
+
+// PublishConfig controls publishing each finished execution's result to external
+// systems, so fleets of machines can aggregate their results centrally
+type PublishConfig struct {
+	Enabled bool               `mapstructure:"enabled"`
+	Timeout time.Duration      `mapstructure:"timeout"` // per attempt
+	Retries int                `mapstructure:"retries"` // further attempts after a failed one
+	Sinks   []ResultSinkConfig `mapstructure:"sinks"`
+}
+
+// ResultSinkConfig is one system results are published to. Settings the sink's
+// type doesn't use are ignored.
+type ResultSinkConfig struct {
+	Name      string            `mapstructure:"name"`       // identifies the sink in logs; defaults to its type
+	Type      string            `mapstructure:"type"`       // elasticsearch, kafka, bigquery or webhook
+	URL       string            `mapstructure:"url"`        // Elasticsearch node, Kafka REST proxy or webhook endpoint; overrides the BigQuery API endpoint
+	Index     string            `mapstructure:"index"`      // elasticsearch
+	Topic     string            `mapstructure:"topic"`      // kafka
+	Project   string            `mapstructure:"project"`    // bigquery
+	Dataset   string            `mapstructure:"dataset"`    // bigquery
+	Table     string            `mapstructure:"table"`      // bigquery
+	Username  string            `mapstructure:"username"`   // basic auth
+	Password  string            `mapstructure:"password"`   // basic auth
+	Token     string            `mapstructure:"token"`      // bearer token; an API key for elasticsearch
+	TokenFile string            `mapstructure:"token_file"` // read before every publish, for tokens refreshed by another process
+	Headers   map[string]string `mapstructure:"headers"`
+}
+
+// NotificationsConfig controls notifying users about alerts and finished
+// executions, as each user's preferences ask
+type NotificationsConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Timeout time.Duration `mapstructure:"timeout"` // per notification sent
+	Email   EmailConfig   `mapstructure:"email"`
+	WebPush WebPushConfig `mapstructure:"web_push"`
+}
+
+// WebPushConfig is the VAPID key push notifications are signed with, which
+// browsers subscribe with; web push notifications are unavailable without one
+type WebPushConfig struct {
+	VAPIDPrivateKey string `mapstructure:"vapid_private_key"` // P-256 private key, base64url; the public key is derived from it
+	Subject         string `mapstructure:"subject"`           // mailto: or https: contact push services may reach the operator at
+}
+
+// EmailConfig is the SMTP server notifications are emailed through; email
+// notifications are unavailable without a host
+type EmailConfig struct {
+	SMTPHost string `mapstructure:"smtp_host"`
+	SMTPPort int    `mapstructure:"smtp_port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+// PluginsConfig controls plugins beyond the built-in ones
+type PluginsConfig struct {
+	External ExternalPluginsConfig `mapstructure:"external"`
+	Sandbox  PluginSandboxConfig   `mapstructure:"sandbox"`
+}
+
+// ExternalPluginsConfig controls registering executables on the server as
+// plugins. It is off by default, since a registered plugin runs with the
+// server's privileges.
+type ExternalPluginsConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	AllowedDirs   []string      `mapstructure:"allowed_dirs"`   // absolute directories registered executables must live in
+	Watch         bool          `mapstructure:"watch"`          // load plugins from manifests in the allowed directories as they change
+	WatchDebounce time.Duration `mapstructure:"watch_debounce"` // how long changes must settle before the directories are rescanned
+}
+
+// PluginSandboxConfig runs external plugin processes with fewer privileges than
+// the server, which keeps its own. Built-in plugins run inside the server and
+// aren't affected. Linux only.
+type PluginSandboxConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	User    string `mapstructure:"user"`    // name or uid plugin processes run as
+	Group   string `mapstructure:"group"`   // name or gid, the user's primary group by default
+	Mode    string `mapstructure:"mode"`    // setuid, or userns to also run them as root of a user namespace owned by the user
+	Seccomp bool   `mapstructure:"seccomp"` // deny system calls such as mount, ptrace and module loading
+}
+
+// ProbesConfig controls victim probes, which measure services sharing the
+// machine, such as a web server or database, while a test loads it
+type ProbesConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	BaselineSamples int  `mapstructure:"baseline_samples"` // taken from each probe before the load starts
+	AllowCommands   bool `mapstructure:"allow_commands"`   // command probes run with the server's privileges, so are off by default
+}
+
+// ResourcesConfig controls what CPU and memory usage are measured against. In a
+// container, or any cgroup with limits, the safety monitor, preflight checks and
+// metrics collector report usage as a percentage of the cgroup's CPU quota and
+// memory limit rather than of the host's totals.
+type ResourcesConfig struct {
+	Detect   string  `mapstructure:"detect"`    // auto to use the cgroup's limits, host to ignore them
+	CPUs     float64 `mapstructure:"cpus"`      // overrides the CPUs usage is measured against; 0 detects them
+	MemoryMB int64   `mapstructure:"memory_mb"` // overrides the memory usage is measured against; 0 detects it
+}
+
+// EncryptionConfig controls envelope encryption of secrets stored in the
+// database, such as notification webhook URLs and passwords in plugin
+// configurations. Each secret is encrypted with its own data key, which is
+// encrypted with the master key read from KeySource.
+type EncryptionConfig struct {
+	Enabled   bool           `mapstructure:"enabled"`
+	KeySource string         `mapstructure:"key_source"` // env, file, vault or kms
+	KeyEnv    string         `mapstructure:"key_env"`    // env: variable holding the base64 master key
+	KeyFile   string         `mapstructure:"key_file"`   // file: holding the base64 master key
+	Vault     VaultKeyConfig `mapstructure:"vault"`
+	KMS       KMSKeyConfig   `mapstructure:"kms"`
+}
+
+// VaultKeyConfig reads the master key from a Vault KV version 2 secret, with
+// the token in the VAULT_TOKEN environment variable
+type VaultKeyConfig struct {
+	Address string `mapstructure:"address"` // defaults to VAULT_ADDR
+	Path    string `mapstructure:"path"`    // API path of the secret, e.g. secret/data/ssts
+	Field   string `mapstructure:"field"`   // holding the base64 master key
+}
+
+// KMSKeyConfig decrypts the master key with AWS KMS, using the credentials in
+// the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// environment variables
+type KMSKeyConfig struct {
+	Region         string `mapstructure:"region"`
+	Endpoint       string `mapstructure:"endpoint"`        // overrides the region's KMS endpoint
+	CiphertextFile string `mapstructure:"ciphertext_file"` // the master key encrypted by KMS, base64 encoded
+}
+
 // DefaultConfig returns default configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -147,6 +585,41 @@ func DefaultConfig() *Config {
 				AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 				AllowHeaders: []string{"*"},
 			},
+			WebSocket: WebSocketConfig{
+				ReplayBufferSize: 500,
+				ReplayMaxAge:     10 * time.Minute,
+				ProgressInterval: 2 * time.Second,
+				SendQueueSize:    256,
+				SlowClientPolicy: "disconnect",
+				CoalesceInterval: 500 * time.Millisecond,
+				BroadcastBuffer:  1024,
+			},
+			RateLimit: RateLimitConfig{
+				Enabled:                 true,
+				RequestsPerSecond:       20,
+				Burst:                   40,
+				APIKeyRequestsPerSecond: 50,
+				APIKeyBurst:             100,
+				APIKeyHeader:            "X-API-Key",
+				ExemptPaths:             []string{"/health", "/metrics", "/ws"},
+			},
+			Shutdown: ShutdownConfig{
+				DrainTimeout: 5 * time.Minute,
+				StopRunning:  false,
+				StopTimeout:  30 * time.Second,
+			},
+			Deprecation: DeprecationConfig{
+				Enabled: true,
+			},
+			Health: HealthConfig{
+				CacheTTL: 5 * time.Second,
+				Timeout:  2 * time.Second,
+			},
+			StatusPage: StatusPageConfig{
+				Title:   "SSTS",
+				Refresh: 30 * time.Second,
+			},
+			ConfigDriftInterval: time.Minute,
 		},
 		Database: DatabaseConfig{
 			Type:     "sqlite",
@@ -157,6 +630,11 @@ func DefaultConfig() *Config {
 			URL:    "http://localhost:8086",
 			Org:    "ssts",
 			Bucket: "metrics",
+			Flux: FluxConfig{
+				MaxRange:   7 * 24 * time.Hour,
+				Timeout:    30 * time.Second,
+				MaxRecords: 10000,
+			},
 		},
 		Redis: RedisConfig{
 			Address: "localhost:6379",
@@ -173,17 +651,60 @@ func DefaultConfig() *Config {
 				MaxMemoryPercent:          70.0,
 				MaxDiskPercent:            90.0,
 				EmergencyStopThreshold:    95.0,
+				MinTestDuration:           1 * time.Second,
+				MaxTestDuration:           24 * time.Hour,
 			},
 			Monitoring: MonitoringConfig{
 				CheckInterval:   1 * time.Second,
 				AlertThreshold:  85.0,
 				AutoStopEnabled: true,
+				EpisodeGap:      5 * time.Second,
+				ReAlertInterval: 5 * time.Minute,
 			},
 			RampUp: RampUpConfig{
 				Enabled:  true,
 				Duration: 30 * time.Second,
 				Steps:    10,
 			},
+			RampDown: RampDownConfig{
+				Enabled:  false,
+				Duration: 30 * time.Second,
+				Cooldown: 60 * time.Second,
+			},
+			Watchdog: WatchdogConfig{
+				Enabled:     true,
+				Grace:       2 * time.Minute,
+				KillTimeout: 30 * time.Second,
+			},
+			Soak: SoakConfig{
+				Enabled:            true,
+				MaxDuration:        7 * 24 * time.Hour,
+				CheckpointInterval: 5 * time.Minute,
+				ResumeOnStartup:    true,
+			},
+			Preflight: PreflightConfig{
+				Enabled:          true,
+				MaxCPUPercent:    50.0,
+				MaxMemoryPercent: 80.0,
+				MaxDiskPercent:   90.0,
+				MaxTemperature:   80.0,
+				Queue:            false,
+				QueueTimeout:     30 * time.Minute,
+				RetryInterval:    15 * time.Second,
+			},
+			Conflicts: ConflictsConfig{
+				Enabled: true,
+				Queue:   true,
+			},
+			KernelLog: KernelLogConfig{
+				Enabled: true,
+			},
+			DiskGuard: DiskGuardConfig{
+				Enabled:        true,
+				MinFreePercent: 5,
+				MinFreeMB:      1024,
+				CheckInterval:  time.Second,
+			},
 			EmergencyStop: true,
 		},
 		Auth: AuthConfig{
@@ -196,6 +717,16 @@ func DefaultConfig() *Config {
 			CollectionInterval: 1 * time.Second,
 			BatchSize:          1000,
 			FlushInterval:      5 * time.Second,
+			BufferSize:         10000,
+			Outputs:            []string{MetricsOutputInfluxDB},
+			Anomalies: AnomaliesConfig{
+				Enabled:          true,
+				Threshold:        4,
+				Alpha:            0.1,
+				Warmup:           30,
+				MinChangePercent: 25,
+				Cooldown:         time.Minute,
+			},
 			Retention: RetentionConfig{
 				RealTime:   24 * time.Hour,
 				HourlyAggr: 30 * 24 * time.Hour,
@@ -203,33 +734,145 @@ func DefaultConfig() *Config {
 				Archive:    5 * 365 * 24 * time.Hour,
 			},
 		},
+		Profiles: ProfilesConfig{
+			Enabled:              true,
+			DefaultMarginPercent: 10,
+			AlertOnFailure:       true,
+		},
+		Export: ExportConfig{
+			Directory:      "exports",
+			AsyncThreshold: 6 * time.Hour,
+			JobRetention:   24 * time.Hour,
+		},
+		Endurance: EnduranceConfig{
+			Enabled:     true,
+			WarnPercent: 80,
+		},
+		Diagnostics: DiagnosticsConfig{
+			Enabled:        true,
+			Directory:      "diagnostics",
+			MetricPoints:   500,
+			KernelLogLines: 200,
+		},
+		Ingest: IngestConfig{
+			Enabled:      true,
+			MaxBatchSize: 5000,
+			MaxClockSkew: 5 * time.Minute,
+			MaxAge:       24 * time.Hour,
+			DedupWindow:  10 * time.Minute,
+			AgentUpdates: AgentUpdatesConfig{
+				ReleaseDir: "/var/lib/ssts/agent-releases",
+			},
+		},
+		Publish: PublishConfig{
+			Enabled: true,
+			Timeout: 10 * time.Second,
+			Retries: 3,
+		},
+		Notifications: NotificationsConfig{
+			Enabled: true,
+			Timeout: 10 * time.Second,
+			Email: EmailConfig{
+				SMTPPort: 587,
+			},
+		},
+		Plugins: PluginsConfig{
+			External: ExternalPluginsConfig{
+				Enabled:       false,
+				WatchDebounce: 2 * time.Second,
+			},
+			Sandbox: PluginSandboxConfig{
+				Enabled: false,
+				Mode:    "setuid",
+				Seccomp: true,
+			},
+		},
+		Probes: ProbesConfig{
+			Enabled:         true,
+			BaselineSamples: 3,
+		},
+		Resources: ResourcesConfig{
+			Detect: "auto",
+		},
+		Encryption: EncryptionConfig{
+			Enabled:   false,
+			KeySource: "env",
+			KeyEnv:    "SSTS_MASTER_KEY",
+			Vault: VaultKeyConfig{
+				Path:  "secret/data/ssts",
+				Field: "key",
+			},
+		},
 	}
 }
 
-// Load loads configuration from file and environment variables
+// Load loads configuration from file and environment variables, applying the
+// environment named by $SSTS_ENVIRONMENT, if any
 func Load() (*Config, error) {
+	return LoadEnvironment("")
+}
+
+// LoadEnvironment loads configuration from file and environment variables, with
+// the named environment's settings layered over the file's top-level settings. An empty name loads the
+// environment named by $SSTS_ENVIRONMENT, if any, so a --environment flag can be
+// passed straight through.
+func LoadEnvironment(name string) (*Config, error) {
+	if name == "" {
+		name = os.Getenv(EnvironmentVar)
+	}
+
+	cfg, err := load(viper.GetViper(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// ReadFile reads the configuration in a file for an environment, as LoadEnvironment
+// would, without validating it or touching the configuration loaded, so the file
+// can be compared with the configuration in effect
+func ReadFile(path, environment string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	return load(v, environment)
+}
+
+// load reads v's configuration file, if it has one, over the defaults and layers
+// the named environment's settings over it
+func load(v *viper.Viper, name string) (*Config, error) {
 	cfg := DefaultConfig()
 
 	// Set defaults
-	setDefaults()
+	setDefaults(v)
 
 	// Load from file if exists
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
+	} else if file, err := filepath.Abs(v.ConfigFileUsed()); err == nil {
+		cfg.File = file
 	}
 
-	// Unmarshal to struct
-	if err := viper.Unmarshal(cfg); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	if name != "" {
+		if err := applyEnvironment(v, name); err != nil {
+			return nil, err
+		}
 	}
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+	// Unmarshal to struct. Lists replace the defaults rather than overwriting
+	// their first elements.
+	zeroFields := func(c *mapstructure.DecoderConfig) { c.ZeroFields = true }
+	if err := v.Unmarshal(cfg, zeroFields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
-
+	cfg.Environment = strings.ToLower(name)
 	return cfg, nil
 }
 
@@ -238,6 +881,148 @@ func (c *Config) Validate() error {
 	if c.Server.Port < 1 || c.Server.Port > 65535 {
 		return fmt.Errorf("invalid server port: %d", c.Server.Port)
 	}
+	if c.Server.ConfigDriftInterval < 0 {
+		return fmt.Errorf("server config_drift_interval must not be negative")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid server trusted proxy %q: must be an IP address or CIDR", proxy)
+		}
+	}
+	if ws := c.Server.WebSocket; ws.SendQueueSize < 0 || ws.BroadcastBuffer < 0 || ws.MaxQueueAge < 0 || ws.CoalesceInterval < 0 {
+		return fmt.Errorf("server websocket queue sizes, max_queue_age and coalesce_interval must not be negative")
+	}
+	switch c.Server.WebSocket.SlowClientPolicy {
+	case "", "disconnect", "drop_oldest", "drop_newest":
+	default:
+		return fmt.Errorf("invalid server websocket slow_client_policy %q: must be disconnect, drop_oldest or drop_newest", c.Server.WebSocket.SlowClientPolicy)
+	}
+	if c.Server.Health.CacheTTL < 0 {
+		return fmt.Errorf("server health cache_ttl must not be negative")
+	}
+	if c.Server.Health.Timeout <= 0 {
+		return fmt.Errorf("server health timeout must be positive")
+	}
+	if c.Server.StatusPage.Enabled && c.Server.StatusPage.Refresh < time.Second {
+		return fmt.Errorf("server status_page refresh must be at least 1s")
+	}
+	if push := c.Notifications.WebPush; push.VAPIDPrivateKey != "" {
+		key, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(push.VAPIDPrivateKey, "="))
+		if err == nil {
+			_, err = ecdh.P256().NewPrivateKey(key)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid notifications web_push vapid_private_key: expected a base64url P-256 private key")
+		}
+		if !strings.HasPrefix(push.Subject, "mailto:") && !strings.HasPrefix(push.Subject, "https://") {
+			return fmt.Errorf("notifications web_push subject must be a mailto: or https: URL")
+		}
+	}
+
+	for name, date := range map[string]string{"deprecation date": c.Server.Deprecation.Date, "sunset date": c.Server.Deprecation.Sunset} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			return fmt.Errorf("invalid API %s %q: expected YYYY-MM-DD", name, date)
+		}
+	}
+
+	if c.Auth.Enabled && c.Auth.JWTSecret == "" {
+		return fmt.Errorf("auth is enabled but no JWT secret is set")
+	}
+
+	if c.Plugins.External.Enabled && len(c.Plugins.External.AllowedDirs) == 0 {
+		return fmt.Errorf("external plugins are enabled but no allowed plugin directories are set")
+	}
+	for _, dir := range c.Plugins.External.AllowedDirs {
+		if !filepath.IsAbs(dir) {
+			return fmt.Errorf("allowed plugin directory must be absolute: %q", dir)
+		}
+	}
+	if sandbox := c.Plugins.Sandbox; sandbox.Enabled {
+		if sandbox.User == "" {
+			return fmt.Errorf("the plugin sandbox is enabled but no user is set")
+		}
+		if sandbox.Mode != "setuid" && sandbox.Mode != "userns" {
+			return fmt.Errorf("invalid plugin sandbox mode %q: must be setuid or userns", sandbox.Mode)
+		}
+	}
+	if c.Plugins.External.Watch {
+		if !c.Plugins.External.Enabled {
+			return fmt.Errorf("watching plugin directories requires external plugins to be enabled")
+		}
+		if c.Plugins.External.WatchDebounce <= 0 {
+			return fmt.Errorf("plugin directory watch debounce must be positive")
+		}
+	}
+
+	if flux := c.InfluxDB.Flux; flux.Enabled {
+		if flux.ReadToken == "" {
+			return fmt.Errorf("custom Flux queries are enabled but no read token is set")
+		}
+		if flux.ReadToken == c.InfluxDB.Token {
+			return fmt.Errorf("the Flux read token must not be the InfluxDB write token")
+		}
+		if flux.MaxRange <= 0 || flux.MaxRecords <= 0 {
+			return fmt.Errorf("custom Flux query max_range and max_records must be positive")
+		}
+	}
+
+	for _, output := range c.Metrics.Outputs {
+		if output != MetricsOutputInfluxDB && output != MetricsOutputRemoteWrite {
+			return fmt.Errorf("invalid metrics output %q: must be influxdb or remote_write", output)
+		}
+	}
+	if c.Metrics.HasOutput(MetricsOutputRemoteWrite) {
+		if len(c.Metrics.RemoteWrite) == 0 {
+			return fmt.Errorf("the remote_write metrics output is selected but no remote_write targets are set")
+		}
+		names := make(map[string]bool)
+		for i, target := range c.Metrics.RemoteWrite {
+			if target.URL == "" {
+				return fmt.Errorf("remote_write target %d has no url", i+1)
+			}
+			if target.Name != "" && names[target.Name] {
+				return fmt.Errorf("duplicate remote_write target name %q", target.Name)
+			}
+			names[target.Name] = true
+		}
+	}
+	if anomalies := c.Metrics.Anomalies; anomalies.Enabled {
+		if anomalies.Threshold <= 0 {
+			return fmt.Errorf("metrics anomalies threshold must be positive")
+		}
+		if anomalies.Alpha <= 0 || anomalies.Alpha > 1 {
+			return fmt.Errorf("metrics anomalies alpha must be greater than 0 and at most 1")
+		}
+		if anomalies.Warmup < 2 || anomalies.MinChangePercent < 0 || anomalies.Cooldown < 0 {
+			return fmt.Errorf("metrics anomalies warmup must be at least 2, and min_change_percent and cooldown not negative")
+		}
+	}
+
+	if updates := c.Ingest.AgentUpdates; updates.Enabled {
+		if !filepath.IsAbs(updates.ReleaseDir) {
+			return fmt.Errorf("agent release directory must be absolute: %q", updates.ReleaseDir)
+		}
+		if key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(updates.PublicKey)); err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("agent release public key must be a base64 Ed25519 public key")
+		}
+	}
+
+	if c.Encryption.Enabled {
+		switch c.Encryption.KeySource {
+		case "env", "file", "vault", "kms":
+		default:
+			return fmt.Errorf("invalid encryption key source %q: expected env, file, vault or kms", c.Encryption.KeySource)
+		}
+	}
+
+	switch c.Resources.Detect {
+	case "", "auto", "host":
+	default:
+		return fmt.Errorf("invalid resources detect %q: expected auto or host", c.Resources.Detect)
+	}
+	if c.Resources.CPUs < 0 || c.Resources.MemoryMB < 0 {
+		return fmt.Errorf("resources cpus and memory_mb must not be negative")
+	}
 
 	if c.Safety.GlobalLimits.MaxCPUPercent < 1 || c.Safety.GlobalLimits.MaxCPUPercent > 100 {
 		return fmt.Errorf("invalid max CPU percentage: %f", c.Safety.GlobalLimits.MaxCPUPercent)
@@ -247,64 +1032,231 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid max memory percentage: %f", c.Safety.GlobalLimits.MaxMemoryPercent)
 	}
 
+	guard := c.Safety.DiskGuard
+	if guard.QuotaMB < 0 || guard.MinFreeMB < 0 {
+		return fmt.Errorf("safety disk_guard quota_mb and min_free_mb must not be negative")
+	}
+	if guard.MinFreePercent < 0 || guard.MinFreePercent >= 100 {
+		return fmt.Errorf("invalid safety disk_guard min_free_percent: %f", guard.MinFreePercent)
+	}
+	if guard.Enabled && guard.CheckInterval <= 0 {
+		return fmt.Errorf("safety disk_guard check_interval must be positive")
+	}
+
+	checks := make(map[string]bool)
+	for i, check := range c.Safety.CustomChecks {
+		if check.Name == "" {
+			return fmt.Errorf("custom safety check %d has no name", i+1)
+		}
+		if checks[check.Name] {
+			return fmt.Errorf("duplicate custom safety check name %q", check.Name)
+		}
+		checks[check.Name] = true
+		switch {
+		case check.Type != "command" && check.Type != "metric" && check.Type != "webhook":
+			return fmt.Errorf("invalid custom safety check %s type %q: must be command, metric or webhook", check.Name, check.Type)
+		case check.Type == "command" && len(check.Command) == 0:
+			return fmt.Errorf("custom safety check %s has no command", check.Name)
+		case check.Type == "metric" && check.Expression == "":
+			return fmt.Errorf("custom safety check %s has no expression", check.Name)
+		case check.Type == "webhook" && check.URL == "":
+			return fmt.Errorf("custom safety check %s has no url", check.Name)
+		case check.Timeout < 0:
+			return fmt.Errorf("custom safety check %s timeout must not be negative", check.Name)
+		}
+	}
+
 	return nil
 }
 
 // setDefaults sets default values for viper
-func setDefaults() {
+func setDefaults(v *viper.Viper) {
 	// Server defaults
-	viper.SetDefault("server.address", "0.0.0.0")
-	viper.SetDefault("server.port", 8080)
-	viper.SetDefault("server.read_timeout", "30s")
-	viper.SetDefault("server.write_timeout", "30s")
+	v.SetDefault("server.address", "0.0.0.0")
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.read_timeout", "30s")
+	v.SetDefault("server.write_timeout", "30s")
+	v.SetDefault("server.config_drift_interval", "1m")
+	v.SetDefault("server.trusted_proxies", []string{})
+	v.SetDefault("server.websocket.replay_buffer_size", 500)
+	v.SetDefault("server.websocket.replay_max_age", "10m")
+	v.SetDefault("server.websocket.progress_interval", "2s")
+	v.SetDefault("server.websocket.send_queue_size", 256)
+	v.SetDefault("server.websocket.slow_client_policy", "disconnect")
+	v.SetDefault("server.websocket.max_queue_age", "0s")
+	v.SetDefault("server.websocket.coalesce_interval", "500ms")
+	v.SetDefault("server.websocket.broadcast_buffer", 1024)
+	v.SetDefault("server.rate_limit.enabled", true)
+	v.SetDefault("server.rate_limit.requests_per_second", 20)
+	v.SetDefault("server.rate_limit.burst", 40)
+	v.SetDefault("server.rate_limit.api_key_requests_per_second", 50)
+	v.SetDefault("server.rate_limit.api_key_burst", 100)
+	v.SetDefault("server.rate_limit.api_key_header", "X-API-Key")
+	v.SetDefault("server.rate_limit.exempt_paths", []string{"/health", "/metrics", "/ws"})
+	v.SetDefault("server.shutdown.drain_timeout", "5m")
+	v.SetDefault("server.shutdown.stop_running", false)
+	v.SetDefault("server.shutdown.stop_timeout", "30s")
+	v.SetDefault("server.deprecation.enabled", true)
+	v.SetDefault("server.health.cache_ttl", "5s")
+	v.SetDefault("server.health.timeout", "2s")
+	v.SetDefault("server.status_page.enabled", false)
+	v.SetDefault("server.status_page.title", "SSTS")
+	v.SetDefault("server.status_page.refresh", "30s")
 
 	// Database defaults
-	viper.SetDefault("database.type", "sqlite")
-	viper.SetDefault("database.database", "./ssts.db")
-	viper.SetDefault("database.ssl_mode", "disable")
+	v.SetDefault("database.type", "sqlite")
+	v.SetDefault("database.database", "./ssts.db")
+	v.SetDefault("database.ssl_mode", "disable")
 
 	// InfluxDB defaults
-	viper.SetDefault("influxdb.url", "http://localhost:8086")
-	viper.SetDefault("influxdb.org", "ssts")
-	viper.SetDefault("influxdb.bucket", "metrics")
+	v.SetDefault("influxdb.url", "http://localhost:8086")
+	v.SetDefault("influxdb.org", "ssts")
+	v.SetDefault("influxdb.bucket", "metrics")
+	v.SetDefault("influxdb.flux.enabled", false)
+	v.SetDefault("influxdb.flux.max_range", "168h")
+	v.SetDefault("influxdb.flux.timeout", "30s")
+	v.SetDefault("influxdb.flux.max_records", 10000)
 
 	// Redis defaults
-	viper.SetDefault("redis.address", "localhost:6379")
-	viper.SetDefault("redis.db", 0)
+	v.SetDefault("redis.address", "localhost:6379")
+	v.SetDefault("redis.db", 0)
 
 	// Logging defaults
-	viper.SetDefault("log.level", "info")
-	viper.SetDefault("log.format", "json")
-	viper.SetDefault("log.output", "stdout")
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.format", "json")
+	v.SetDefault("log.output", "stdout")
 
 	// Safety defaults
-	viper.SetDefault("safety.global_limits.max_cpu_percent", 80.0)
-	viper.SetDefault("safety.global_limits.max_memory_percent", 70.0)
-	viper.SetDefault("safety.global_limits.max_disk_percent", 90.0)
-	viper.SetDefault("safety.global_limits.emergency_stop_threshold", 95.0)
+	v.SetDefault("safety.global_limits.max_cpu_percent", 80.0)
+	v.SetDefault("safety.global_limits.max_memory_percent", 70.0)
+	v.SetDefault("safety.global_limits.max_disk_percent", 90.0)
+	v.SetDefault("safety.global_limits.emergency_stop_threshold", 95.0)
+	v.SetDefault("safety.global_limits.min_test_duration", "1s")
+	v.SetDefault("safety.global_limits.max_test_duration", "24h")
 
-	viper.SetDefault("safety.monitoring.check_interval", "1s")
-	viper.SetDefault("safety.monitoring.alert_threshold", 85.0)
-	viper.SetDefault("safety.monitoring.auto_stop_enabled", true)
+	v.SetDefault("safety.monitoring.check_interval", "1s")
+	v.SetDefault("safety.monitoring.alert_threshold", 85.0)
+	v.SetDefault("safety.monitoring.auto_stop_enabled", true)
+	v.SetDefault("safety.monitoring.episode_gap", "5s")
+	v.SetDefault("safety.monitoring.realert_interval", "5m")
 
-	viper.SetDefault("safety.ramp_up.enabled", true)
-	viper.SetDefault("safety.ramp_up.duration", "30s")
-	viper.SetDefault("safety.ramp_up.steps", 10)
-	viper.SetDefault("safety.emergency_stop", true)
+	v.SetDefault("safety.ramp_up.enabled", true)
+	v.SetDefault("safety.ramp_up.duration", "30s")
+	v.SetDefault("safety.ramp_up.steps", 10)
+	v.SetDefault("safety.ramp_down.enabled", false)
+	v.SetDefault("safety.ramp_down.duration", "30s")
+	v.SetDefault("safety.ramp_down.cooldown", "60s")
+	v.SetDefault("safety.watchdog.enabled", true)
+	v.SetDefault("safety.watchdog.grace", "2m")
+	v.SetDefault("safety.watchdog.kill_timeout", "30s")
+	v.SetDefault("safety.soak.enabled", true)
+	v.SetDefault("safety.soak.max_duration", "168h")
+	v.SetDefault("safety.soak.checkpoint_interval", "5m")
+	v.SetDefault("safety.soak.resume_on_startup", true)
+	v.SetDefault("safety.preflight.enabled", true)
+	v.SetDefault("safety.preflight.max_cpu_percent", 50.0)
+	v.SetDefault("safety.preflight.max_memory_percent", 80.0)
+	v.SetDefault("safety.preflight.max_disk_percent", 90.0)
+	v.SetDefault("safety.preflight.max_temperature", 80.0)
+	v.SetDefault("safety.preflight.queue", false)
+	v.SetDefault("safety.preflight.queue_timeout", "30m")
+	v.SetDefault("safety.preflight.retry_interval", "15s")
+	v.SetDefault("safety.conflicts.enabled", true)
+	v.SetDefault("safety.conflicts.queue", true)
+	v.SetDefault("safety.kernel_log.enabled", true)
+	v.SetDefault("safety.disk_guard.enabled", true)
+	v.SetDefault("safety.disk_guard.quota_mb", 0)
+	v.SetDefault("safety.disk_guard.min_free_percent", 5.0)
+	v.SetDefault("safety.disk_guard.min_free_mb", 1024)
+	v.SetDefault("safety.disk_guard.check_interval", "1s")
+	v.SetDefault("safety.emergency_stop", true)
 
 	// Auth defaults
-	viper.SetDefault("auth.enabled", false)
-	viper.SetDefault("auth.token_expiry", "24h")
-	viper.SetDefault("auth.refresh_expiry", "168h")
+	v.SetDefault("auth.enabled", false)
+	v.SetDefault("auth.token_expiry", "24h")
+	v.SetDefault("auth.refresh_expiry", "168h")
 
 	// Metrics defaults
-	viper.SetDefault("metrics.enabled", true)
-	viper.SetDefault("metrics.collection_interval", "1s")
-	viper.SetDefault("metrics.batch_size", 1000)
-	viper.SetDefault("metrics.flush_interval", "5s")
-
-	viper.SetDefault("metrics.retention.realtime", "24h")
-	viper.SetDefault("metrics.retention.hourly_aggregates", "720h")
-	viper.SetDefault("metrics.retention.daily_aggregates", "8760h")
-	viper.SetDefault("metrics.retention.archive", "43800h")
+	v.SetDefault("metrics.enabled", true)
+	v.SetDefault("metrics.collection_interval", "1s")
+	v.SetDefault("metrics.batch_size", 1000)
+	v.SetDefault("metrics.flush_interval", "5s")
+	v.SetDefault("metrics.buffer_size", 10000)
+	v.SetDefault("metrics.outputs", []string{MetricsOutputInfluxDB})
+	v.SetDefault("metrics.anomalies.enabled", true)
+	v.SetDefault("metrics.anomalies.threshold", 4)
+	v.SetDefault("metrics.anomalies.alpha", 0.1)
+	v.SetDefault("metrics.anomalies.warmup", 30)
+	v.SetDefault("metrics.anomalies.min_change_percent", 25)
+	v.SetDefault("metrics.anomalies.cooldown", "1m")
+
+	v.SetDefault("metrics.retention.realtime", "24h")
+	v.SetDefault("metrics.retention.hourly_aggregates", "720h")
+	v.SetDefault("metrics.retention.daily_aggregates", "8760h")
+	v.SetDefault("metrics.retention.archive", "43800h")
+
+	// Hardware profile defaults
+	v.SetDefault("profiles.enabled", true)
+	v.SetDefault("profiles.default_margin_percent", 10)
+	v.SetDefault("profiles.alert_on_failure", true)
+
+	// Export defaults
+	v.SetDefault("export.directory", "exports")
+	v.SetDefault("export.async_threshold", "6h")
+	v.SetDefault("export.job_retention", "24h")
+
+	// Diagnostics defaults
+	v.SetDefault("diagnostics.enabled", true)
+	v.SetDefault("diagnostics.directory", "diagnostics")
+	v.SetDefault("diagnostics.metric_points", 500)
+	v.SetDefault("diagnostics.kernel_log_lines", 200)
+
+	// Device endurance defaults
+	v.SetDefault("endurance.enabled", true)
+	v.SetDefault("endurance.warn_percent", 80)
+
+	// Metric ingestion defaults
+	v.SetDefault("ingest.enabled", true)
+	v.SetDefault("ingest.max_batch_size", 5000)
+	v.SetDefault("ingest.max_clock_skew", "5m")
+	v.SetDefault("ingest.max_age", "24h")
+	v.SetDefault("ingest.dedup_window", "10m")
+	v.SetDefault("ingest.require_handshake", false)
+	v.SetDefault("ingest.agent_updates.enabled", false)
+	v.SetDefault("ingest.agent_updates.release_dir", "/var/lib/ssts/agent-releases")
+
+	// Result publishing defaults
+	v.SetDefault("publish.enabled", true)
+	v.SetDefault("publish.timeout", "10s")
+	v.SetDefault("publish.retries", 3)
+
+	// Notification defaults
+	v.SetDefault("notifications.enabled", true)
+	v.SetDefault("notifications.timeout", "10s")
+	v.SetDefault("notifications.email.smtp_port", 587)
+
+	// Plugin defaults
+	v.SetDefault("plugins.external.enabled", false)
+	v.SetDefault("plugins.external.watch", false)
+	v.SetDefault("plugins.external.watch_debounce", "2s")
+	v.SetDefault("plugins.sandbox.enabled", false)
+	v.SetDefault("plugins.sandbox.mode", "setuid")
+	v.SetDefault("plugins.sandbox.seccomp", true)
+
+	// Victim probe defaults
+	v.SetDefault("probes.enabled", true)
+	v.SetDefault("probes.baseline_samples", 3)
+	v.SetDefault("probes.allow_commands", false)
+
+	// Resource limit defaults
+	v.SetDefault("resources.detect", "auto")
+	v.SetDefault("resources.cpus", 0)
+	v.SetDefault("resources.memory_mb", 0)
+
+	// Encryption defaults
+	v.SetDefault("encryption.enabled", false)
+	v.SetDefault("encryption.key_source", "env")
+	v.SetDefault("encryption.key_env", "SSTS_MASTER_KEY")
+	v.SetDefault("encryption.vault.path", "secret/data/ssts")
+	v.SetDefault("encryption.vault.field", "key")
 }
\ No newline at end of file
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Drift is a setting whose value in effect differs from the one in the
+// configuration file. A setting missing on one side is nil there.
+type Drift struct {
+	Key     string      `json:"key"`
+	Running interface{} `json:"running"`
+	File    interface{} `json:"file"`
+}
+
+// Settings flattens the configuration into its settings, keyed by their dotted
+// paths as written in the configuration file, e.g. server.port. Durations are
+// written as in the file, e.g. 30s; lists of settings blocks and maps are keyed
+// by index and key, e.g. metrics.remote_write.0.url.
+func (c *Config) Settings() map[string]interface{} {
+	settings := make(map[string]interface{})
+	flattenSetting(settings, "", reflect.ValueOf(*c))
+	return settings
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func flattenSetting(settings map[string]interface{}, key string, value reflect.Value) {
+	join := func(name string) string {
+		if key == "" {
+			return name
+		}
+		return key + "." + name
+	}
+
+	if value.Type() == durationType {
+		settings[key] = time.Duration(value.Int()).String()
+		return
+	}
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			settings[key] = nil
+			return
+		}
+		flattenSetting(settings, key, value.Elem())
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			flattenSetting(settings, join(name), value.Field(i))
+		}
+	case reflect.Map:
+		keys := value.MapKeys()
+		if len(keys) == 0 {
+			settings[key] = map[string]interface{}{}
+			return
+		}
+		for _, mapKey := range keys {
+			flattenSetting(settings, join(fmt.Sprint(mapKey.Interface())), value.MapIndex(mapKey))
+		}
+	case reflect.Slice, reflect.Array:
+		elem := value.Type().Elem()
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct || elem.Kind() == reflect.Map {
+			for i := 0; i < value.Len(); i++ {
+				flattenSetting(settings, join(strconv.Itoa(i)), value.Index(i))
+			}
+			if value.Len() == 0 {
+				settings[key] = []interface{}{}
+			}
+			return
+		}
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = value.Index(i).Interface()
+		}
+		settings[key] = items
+	default:
+		settings[key] = value.Interface()
+	}
+}
+
+// Diff lists the settings whose values in the running configuration differ
+// from those in the file's, ordered by key
+func Diff(running, file *Config) []Drift {
+	runningSettings := running.Settings()
+	fileSettings := file.Settings()
+
+	var drift []Drift
+	for key, value := range runningSettings {
+		if fileValue, ok := fileSettings[key]; !ok || !reflect.DeepEqual(value, fileValue) {
+			drift = append(drift, Drift{Key: key, Running: value, File: fileValue})
+		}
+	}
+	for key, value := range fileSettings {
+		if _, ok := runningSettings[key]; !ok {
+			drift = append(drift, Drift{Key: key, File: value})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Key < drift[j].Key })
+	return drift
+}
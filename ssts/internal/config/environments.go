@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvironmentVar selects the environment to load when none is given explicitly
+const EnvironmentVar = "SSTS_ENVIRONMENT"
+
+// environmentsKey holds the named environments in the config file. Each is a
+// partial configuration layered over the top-level settings, or over another
+// environment named by its inherits key, so one file can configure every class
+// of machine the same build is deployed to:
+//
+//	log:
+//	  level: info
+//	environments:
+//	  dev:
+//	    log:
+//	      level: debug
+//	  burn-in-rig:
+//	    inherits: dev
+//	    safety:
+//	      global_limits:
+//	        max_cpu_percent: 100
+//
+// Maps are merged key by key; any other value, including a list, replaces the
+// inherited one.
+const environmentsKey = "environments"
+
+// inheritsKey names the environment an environment is layered over
+const inheritsKey = "inherits"
+
+// applyEnvironment merges the settings of an environment, and of the
+// environments it inherits from, into v, the outermost ancestor first
+func applyEnvironment(v *viper.Viper, name string) error {
+	environments := v.GetStringMap(environmentsKey)
+
+	// Viper lowercases keys, so environment names match case-insensitively
+	var chain []map[string]interface{}
+	seen := make(map[string]bool)
+	for current := strings.ToLower(name); current != ""; {
+		if seen[current] {
+			return fmt.Errorf("environment %q inherits from itself", current)
+		}
+		seen[current] = true
+
+		settings, ok := environments[current].(map[string]interface{})
+		if !ok {
+			if _, exists := environments[current]; exists {
+				return fmt.Errorf("environment %q is not a map of settings", current)
+			}
+			return fmt.Errorf("unknown environment %q (defined: %s)", current, environmentNames(environments))
+		}
+		chain = append(chain, settings)
+
+		parent, _ := settings[inheritsKey].(string)
+		current = strings.ToLower(parent)
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		settings := make(map[string]interface{}, len(chain[i]))
+		for key, value := range chain[i] {
+			if key != inheritsKey {
+				settings[key] = value
+			}
+		}
+		if err := v.MergeConfigMap(settings); err != nil {
+			return fmt.Errorf("failed to apply environment %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// environmentNames lists the defined environments for error messages
+func environmentNames(environments map[string]interface{}) string {
+	if len(environments) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(environments))
+	for name := range environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
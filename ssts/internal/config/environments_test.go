@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const environmentsFile = `
+log:
+  level: info
+  format: json
+server:
+  port: 8080
+  rate_limit:
+    exempt_paths: ["/health", "/metrics"]
+environments:
+  dev:
+    log:
+      level: debug
+  staging:
+    server:
+      port: 9090
+  Burn-In-Rig:
+    inherits: dev
+    server:
+      rate_limit:
+        exempt_paths: ["/health"]
+    safety:
+      global_limits:
+        max_test_duration: 168h
+  loop-a:
+    inherits: loop-b
+  loop-b:
+    inherits: loop-a
+  broken: 42
+`
+
+func TestLoadEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssts.yaml")
+	if err := os.WriteFile(path, []byte(environmentsFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		environment string
+		envVar      string
+		wantLevel   string
+		wantPort    int
+		wantExempt  []string
+		wantMaxTest time.Duration
+		wantErr     string
+	}{
+		{"top-level settings", "", "", "info", 8080, []string{"/health", "/metrics"}, 24 * time.Hour, ""},
+		{"environment", "staging", "", "info", 9090, []string{"/health", "/metrics"}, 24 * time.Hour, ""},
+		{"from the environment variable", "", "dev", "debug", 8080, []string{"/health", "/metrics"}, 24 * time.Hour, ""},
+		{"name over the environment variable", "staging", "dev", "info", 9090, []string{"/health", "/metrics"}, 24 * time.Hour, ""},
+		{"inherited", "burn-in-rig", "", "debug", 8080, []string{"/health"}, 168 * time.Hour, ""},
+		{"unknown", "prod", "", "", 0, nil, 0, `unknown environment "prod" (defined: broken, burn-in-rig, dev, loop-a, loop-b, staging)`},
+		{"inheritance loop", "loop-a", "", "", 0, nil, 0, `environment "loop-a" inherits from itself`},
+		{"not a map", "broken", "", "", 0, nil, 0, `environment "broken" is not a map of settings`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+			viper.SetConfigFile(path)
+			t.Setenv(EnvironmentVar, tt.envVar)
+
+			cfg, err := LoadEnvironment(tt.environment)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Log.Level != tt.wantLevel || cfg.Log.Format != "json" || cfg.Server.Port != tt.wantPort {
+				t.Errorf("log level %q, format %q, port %d; want %q, json, %d", cfg.Log.Level, cfg.Log.Format, cfg.Server.Port, tt.wantLevel, tt.wantPort)
+			}
+			if !reflect.DeepEqual(cfg.Server.RateLimit.ExemptPaths, tt.wantExempt) {
+				t.Errorf("exempt paths = %q, want %q", cfg.Server.RateLimit.ExemptPaths, tt.wantExempt)
+			}
+			if cfg.Safety.GlobalLimits.MaxTestDuration != tt.wantMaxTest {
+				t.Errorf("max test duration = %s, want %s", cfg.Safety.GlobalLimits.MaxTestDuration, tt.wantMaxTest)
+			}
+			if want := tt.environment; want == "" && cfg.Environment != tt.envVar || want != "" && cfg.Environment != want {
+				t.Errorf("environment = %q", cfg.Environment)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// persistentAlertManager stores safety alerts in the database and applies mute
+// rules before forwarding them to the next alert manager
+type persistentAlertManager struct {
+	next   safety.AlertManager
+	repo   database.Repository
+	logger *logrus.Logger
+}
+
+// newPersistentAlertManager wraps an alert manager with database persistence
+func newPersistentAlertManager(next safety.AlertManager, repo database.Repository, logger *logrus.Logger) *persistentAlertManager {
+	return &persistentAlertManager{
+		next:   next,
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// SendAlert persists the alert and forwards it unless a mute rule matches
+func (p *persistentAlertManager) SendAlert(alert safety.Alert) error {
+	if alert.ID == "" {
+		alert.ID = uuid.New().String()
+	}
+
+	if p.repo == nil {
+		return p.next.SendAlert(alert)
+	}
+
+	record := &models.Alert{
+		ID:        alert.ID,
+		Type:      alert.Type,
+		Severity:  string(alert.Severity),
+		Message:   alert.Message,
+		DedupKey:  alert.DedupKey,
+		Resolved:  alert.Resolved,
+		Timestamp: alert.Timestamp,
+	}
+
+	if len(alert.Metadata) > 0 {
+		if metadata, err := json.Marshal(alert.Metadata); err == nil {
+			record.Metadata = metadata
+		}
+	}
+
+	// Alerts about an execution belong to its project; the rest are host-wide
+	if executionID, ok := alert.Metadata["execution_id"].(string); ok && executionID != "" {
+		if execution, err := p.repo.GetTestExecution(executionID); err == nil {
+			record.ProjectID = execution.ProjectID
+		} else {
+			p.logger.WithError(err).WithField("execution_id", executionID).Warn("Failed to get project of alert")
+		}
+	}
+
+	rules, err := p.repo.ListActiveAlertMuteRules()
+	if err != nil {
+		p.logger.WithError(err).Warn("Failed to load alert mute rules")
+	}
+	for i := range rules {
+		if rules[i].Matches(record.Type, record.Severity, record.Timestamp) {
+			record.Muted = true
+			record.MuteRuleID = &rules[i].ID
+			break
+		}
+	}
+
+	if err := p.repo.CreateAlert(record); err != nil {
+		p.logger.WithError(err).Error("Failed to persist alert")
+	}
+
+	if record.Muted {
+		return nil
+	}
+
+	return p.next.SendAlert(alert)
+}
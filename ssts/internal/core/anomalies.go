@@ -0,0 +1,286 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// AnomalySettings configure the detection of anomalies in running executions'
+// live metrics. Each throughput and latency metric keeps an exponentially
+// weighted moving average and variance; a value more than Threshold standard
+// deviations below the average of a throughput metric, or above that of a
+// latency metric, is an anomaly.
+type AnomalySettings struct {
+	Enabled          bool
+	Threshold        float64       // z-score a value must exceed
+	Alpha            float64       // weight of each new value in the moving average
+	Warmup           int           // values of a metric seen before it is checked
+	MinChangePercent float64       // smallest change from the average that is flagged
+	Cooldown         time.Duration // between anomalies flagged for the same metric
+}
+
+// anomalySeries is the moving average and variance of one metric of an execution
+type anomalySeries struct {
+	mean     float64
+	variance float64
+	count    int
+	flagged  time.Time // when the last anomaly of the metric was flagged
+}
+
+// SetAnomalies configures anomaly detection
+func (to *TestOrchestrator) SetAnomalies(settings AnomalySettings) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.anomalySettings = settings
+}
+
+// OnAnomaly registers a callback invoked for each anomaly found in a running
+// execution's metrics
+func (to *TestOrchestrator) OnAnomaly(fn func(models.Anomaly)) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.onAnomaly = append(to.onAnomaly, fn)
+}
+
+// anomalyKind tells whether a metric field is watched for drops, as throughput
+// is, or for spikes, as latency is
+func anomalyKind(field string) (models.AnomalyKind, bool) {
+	field = strings.ToLower(field)
+	if isCounterField(field) {
+		return "", false
+	}
+	if strings.Contains(field, "latency") {
+		return models.AnomalyLatencySpike, true
+	}
+	for _, marker := range []string{"per_sec", "iops", "throughput", "bandwidth", "ops_per", "mbps"} {
+		if strings.Contains(field, marker) {
+			return models.AnomalyThroughputDrop, true
+		}
+	}
+	return "", false
+}
+
+// anomalySeriesKey identifies a metric of an execution: its summary key and its
+// tags, so that e.g. read and write latencies are tracked apart
+func anomalySeriesKey(metric string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		if key != "execution_id" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(metric)
+	for _, key := range keys {
+		b.WriteString("," + key + "=" + tags[key])
+	}
+	return b.String()
+}
+
+// observeAnomalies checks a point of a running execution against the moving
+// averages of its throughput and latency metrics, then folds it into them, and
+// reports the anomalies found to the OnAnomaly callbacks
+func (to *TestOrchestrator) observeAnomalies(execution *TestExecution, point models.MetricPoint) {
+	to.mu.RLock()
+	settings := to.anomalySettings
+	callbacks := to.onAnomaly
+	to.mu.RUnlock()
+	if !settings.Enabled {
+		return
+	}
+
+	timestamp := point.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	var found []models.Anomaly
+	execution.mu.Lock()
+	if execution.Status != models.StatusRunning || execution.cooldownStart != nil {
+		execution.mu.Unlock()
+		return
+	}
+	for field, raw := range point.Fields {
+		kind, watched := anomalyKind(field)
+		if !watched {
+			continue
+		}
+		value, ok := metricValue(raw)
+		if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		metric := point.Source + "." + point.Type + "." + field
+		key := anomalySeriesKey(metric, point.Tags)
+		if execution.anomalies == nil {
+			execution.anomalies = make(map[string]*anomalySeries)
+		}
+		series := execution.anomalies[key]
+		if series == nil {
+			series = &anomalySeries{}
+			execution.anomalies[key] = series
+		}
+
+		if anomaly, ok := series.check(kind, value, timestamp, settings); ok {
+			anomaly.ExecutionID = execution.ID
+			anomaly.TestID = execution.Config.ID
+			anomaly.Metric = metric
+			anomaly.Tags = point.Tags
+			found = append(found, anomaly)
+		}
+		series.add(value, settings.Alpha)
+	}
+	execution.mu.Unlock()
+
+	for _, anomaly := range found {
+		for _, fn := range callbacks {
+			fn(anomaly)
+		}
+	}
+}
+
+// check reports whether a value of the series is an anomaly: after the warmup,
+// outside the cooldown of the last anomaly, more than the threshold of standard
+// deviations and the minimum change away from the average, in the direction
+// that is bad for the metric
+func (s *anomalySeries) check(kind models.AnomalyKind, value float64, at time.Time, settings AnomalySettings) (models.Anomaly, bool) {
+	if s.count < settings.Warmup || s.variance <= 0 {
+		return models.Anomaly{}, false
+	}
+	if !s.flagged.IsZero() && at.Sub(s.flagged) < settings.Cooldown {
+		return models.Anomaly{}, false
+	}
+
+	stdDev := math.Sqrt(s.variance)
+	z := (value - s.mean) / stdDev
+	switch kind {
+	case models.AnomalyThroughputDrop:
+		if z > -settings.Threshold {
+			return models.Anomaly{}, false
+		}
+	case models.AnomalyLatencySpike:
+		if z < settings.Threshold {
+			return models.Anomaly{}, false
+		}
+	}
+	if s.mean != 0 && math.Abs(value-s.mean)/math.Abs(s.mean)*100 < settings.MinChangePercent {
+		return models.Anomaly{}, false
+	}
+
+	s.flagged = at
+	return models.Anomaly{
+		Kind:      kind,
+		Value:     value,
+		Expected:  s.mean,
+		StdDev:    stdDev,
+		ZScore:    z,
+		Timestamp: at,
+	}, true
+}
+
+// add folds a value into the series' exponentially weighted moving average and
+// variance. The first value starts the average.
+func (s *anomalySeries) add(value, alpha float64) {
+	s.count++
+	if s.count == 1 {
+		s.mean = value
+		return
+	}
+	diff := value - s.mean
+	s.mean += alpha * diff
+	s.variance = (1 - alpha) * (s.variance + alpha*diff*diff)
+}
+
+// anomalySink checks the points plugins stream while executions run for
+// anomalies. It is added to the metrics collector's sinks.
+type anomalySink struct {
+	orchestrator *TestOrchestrator
+}
+
+// WriteMetricPoint checks a streamed point against the execution it came from
+func (s anomalySink) WriteMetricPoint(point models.MetricPoint) error {
+	executionID := point.Tags["execution_id"]
+	if executionID == "" {
+		return nil
+	}
+
+	s.orchestrator.mu.RLock()
+	execution, exists := s.orchestrator.executions[executionID]
+	s.orchestrator.mu.RUnlock()
+	if exists {
+		s.orchestrator.observeAnomalies(execution, point)
+	}
+	return nil
+}
+
+// OnAnomaly registers a callback invoked for each anomaly found in a running
+// execution's metrics, with the annotation marking it on the execution's
+// timeline, nil if it couldn't be recorded
+func (o *Orchestrator) OnAnomaly(fn func(models.Anomaly, *models.Annotation)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.onAnomaly = append(o.onAnomaly, fn)
+}
+
+// recordAnomaly marks an anomaly on its execution's timeline with an annotation,
+// raises an alert for it and passes both to the OnAnomaly callbacks
+func (o *Orchestrator) recordAnomaly(anomaly models.Anomaly, alertManager safety.AlertManager) {
+	o.logger.Warn("Anomaly in execution metrics",
+		zap.String("execution_id", anomaly.ExecutionID),
+		zap.String("metric", anomaly.Metric),
+		zap.String("kind", string(anomaly.Kind)),
+		zap.Float64("value", anomaly.Value),
+		zap.Float64("expected", anomaly.Expected),
+		zap.Float64("z_score", anomaly.ZScore))
+
+	var annotation *models.Annotation
+	if o.repo != nil {
+		annotation = &models.Annotation{
+			ExecutionID: anomaly.ExecutionID,
+			Timestamp:   anomaly.Timestamp,
+			Text:        "Anomaly: " + anomaly.Message(),
+			CreatedBy:   models.AnnotationByAnomalyDetector,
+		}
+		if err := o.repo.CreateAnnotation(annotation); err != nil {
+			o.logger.Warn("Failed to record anomaly annotation", zap.String("execution_id", anomaly.ExecutionID), zap.Error(err))
+			annotation = nil
+		}
+	}
+
+	alert := safety.Alert{
+		Type:      "anomaly",
+		Message:   fmt.Sprintf("Execution %s: %s", anomaly.ExecutionID, anomaly.Message()),
+		Severity:  safety.SeverityWarning,
+		Timestamp: anomaly.Timestamp,
+		DedupKey:  "anomaly:" + anomaly.ExecutionID + ":" + anomalySeriesKey(anomaly.Metric, anomaly.Tags),
+		Metadata: map[string]interface{}{
+			"execution_id": anomaly.ExecutionID,
+			"test_id":      anomaly.TestID,
+			"kind":         anomaly.Kind,
+			"metric":       anomaly.Metric,
+			"value":        anomaly.Value,
+			"expected":     anomaly.Expected,
+			"z_score":      anomaly.ZScore,
+		},
+	}
+	if err := alertManager.SendAlert(alert); err != nil {
+		o.logger.Warn("Failed to send anomaly alert", zap.Error(err))
+	}
+
+	o.mu.RLock()
+	callbacks := o.onAnomaly
+	o.mu.RUnlock()
+	for _, fn := range callbacks {
+		fn(anomaly, annotation)
+	}
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestObserveAnomaliesFlagsDropsAndSpikes(t *testing.T) {
+	orchestrator := &TestOrchestrator{}
+	orchestrator.SetAnomalies(AnomalySettings{Enabled: true, Threshold: 4, Alpha: 0.1, Warmup: 30, MinChangePercent: 25, Cooldown: time.Minute})
+	var found []models.Anomaly
+	orchestrator.OnAnomaly(func(anomaly models.Anomaly) { found = append(found, anomaly) })
+	execution := &TestExecution{ID: "e1", Config: models.TestConfiguration{ID: "t1"}, Status: models.StatusRunning}
+
+	start := time.Now()
+	observe := func(second int, operation string, fields map[string]interface{}) {
+		orchestrator.observeAnomalies(execution, models.MetricPoint{
+			Timestamp: start.Add(time.Duration(second) * time.Second),
+			Source:    "plugin",
+			Type:      "io",
+			Tags:      map[string]string{"operation": operation, "execution_id": "e1"},
+			Fields:    fields,
+		})
+	}
+	for i := 0; i < 60; i++ {
+		noise := float64(i%5) - 2
+		observe(i, "read", map[string]interface{}{"iops": 1000 + 10*noise, "latency_ms": 2 + 0.05*noise, "total_ops": 1000 * i})
+		observe(i, "write", map[string]interface{}{"latency_ms": 20 + noise})
+	}
+	if len(found) != 0 {
+		t.Fatalf("found %+v in steady metrics", found)
+	}
+
+	// Write latency at the level of read latency, and a small dip in IOPS, are fine
+	observe(60, "write", map[string]interface{}{"latency_ms": 19.0})
+	observe(60, "read", map[string]interface{}{"iops": 985.0})
+	if len(found) != 0 {
+		t.Fatalf("found %+v in expected values", found)
+	}
+
+	observe(61, "read", map[string]interface{}{"iops": 300.0, "latency_ms": 9.0})
+	if len(found) != 2 {
+		t.Fatalf("found %+v, want an IOPS drop and a latency spike", found)
+	}
+	for _, anomaly := range found {
+		switch anomaly.Metric {
+		case "plugin.io.iops":
+			if anomaly.Kind != models.AnomalyThroughputDrop || anomaly.ZScore > -4 || anomaly.Expected < 990 {
+				t.Errorf("iops anomaly = %+v", anomaly)
+			}
+		case "plugin.io.latency_ms":
+			if anomaly.Kind != models.AnomalyLatencySpike || anomaly.Tags["operation"] != "read" || anomaly.Value != 9 {
+				t.Errorf("latency anomaly = %+v", anomaly)
+			}
+		default:
+			t.Errorf("unexpected anomaly %+v", anomaly)
+		}
+		if anomaly.ExecutionID != "e1" || anomaly.TestID != "t1" {
+			t.Errorf("anomaly %+v isn't attributed to the execution", anomaly)
+		}
+	}
+
+	// The same metric isn't flagged again within the cooldown
+	observe(70, "read", map[string]interface{}{"iops": 100.0})
+	if len(found) != 2 {
+		t.Errorf("flagged %+v within the cooldown", found[2:])
+	}
+}
+
+func TestRecordAnomalyAnnotatesAndAlerts(t *testing.T) {
+	repo := database.NewMemoryRepository()
+	orchestrator := &Orchestrator{repo: repo, logger: zap.NewNop()}
+	var notified *models.Annotation
+	orchestrator.OnAnomaly(func(anomaly models.Anomaly, annotation *models.Annotation) { notified = annotation })
+	alerts := &recordedAlerts{}
+
+	anomaly := models.Anomaly{
+		ExecutionID: "e1", TestID: "t1", Kind: models.AnomalyLatencySpike, Metric: "plugin.io.latency_ms",
+		Value: 9, Expected: 2, StdDev: 0.1, ZScore: 70, Timestamp: time.Now(),
+	}
+	orchestrator.recordAnomaly(anomaly, alerts)
+
+	annotations, err := repo.ListAnnotations("e1")
+	if err != nil || len(annotations) != 1 {
+		t.Fatalf("annotations = %+v, %v", annotations, err)
+	}
+	if annotations[0].CreatedBy != models.AnnotationByAnomalyDetector || annotations[0].Text != "Anomaly: plugin.io.latency_ms spiked to 9 (expected 2, z=70.0)" {
+		t.Errorf("annotation = %+v", annotations[0])
+	}
+	if notified == nil || notified.ID != annotations[0].ID {
+		t.Errorf("callback got annotation %+v, want %+v", notified, annotations[0])
+	}
+	sent := alerts.sent()
+	if len(sent) != 1 || sent[0].Type != "anomaly" || sent[0].Metadata["execution_id"] != "e1" {
+		t.Errorf("alerts = %+v, want an anomaly alert for the execution", sent)
+	}
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrResourceConflict is returned by StartTest when a run claims resources that
+// running executions hold
+var ErrResourceConflict = errors.New("resource conflict")
+
+// ConflictError names the resource a run can't have and the execution holding it
+type ConflictError struct {
+	Resource    string
+	ExecutionID string // the execution holding the resource
+	Reason      string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s is held by execution %s: %s", ErrResourceConflict, e.Resource, e.ExecutionID, e.Reason)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrResourceConflict
+}
+
+// ConflictSettings configure how runs claiming resources held by running
+// executions are handled. Queued runs are retried and time out as configured
+// for the preflight checks.
+type ConflictSettings struct {
+	Enabled bool
+	Queue   bool // queue conflicting runs until the resources are free instead of rejecting them
+}
+
+// SetConflicts configures resource conflict detection
+func (to *TestOrchestrator) SetConflicts(settings ConflictSettings) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.conflictSettings = settings
+}
+
+// resourceClaims returns the resources a run of the test claims, or nil if its
+// plugin doesn't claim any
+func resourceClaims(config models.TestConfiguration, plugin plugins.StressPlugin) ([]plugins.ResourceClaim, error) {
+	claimer, ok := plugin.(plugins.ResourceClaimer)
+	if !ok {
+		return nil, nil
+	}
+
+	var pluginConfig interface{}
+	if len(config.Config) > 0 {
+		if err := json.Unmarshal(config.Config, &pluginConfig); err != nil {
+			return nil, fmt.Errorf("invalid %s config: %w", config.Plugin, err)
+		}
+	}
+	claims, err := claimer.ResourceClaims(pluginConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine resources claimed by %s: %w", config.Plugin, err)
+	}
+	return claims, nil
+}
+
+// acquireClaimsLocked marks the execution as holding its claims, unless they
+// conflict with those of an execution already holding its own. The caller must
+// hold to.mu.
+func (to *TestOrchestrator) acquireClaimsLocked(execution *TestExecution) error {
+	if len(execution.claims) == 0 {
+		return nil
+	}
+
+	held := make(map[string][]plugins.ResourceClaim)
+	for id, other := range to.executions {
+		if other.claimsHeld {
+			held[id] = other.claims
+		}
+	}
+	if conflict := findConflict(execution.claims, held); conflict != nil {
+		return conflict
+	}
+
+	execution.claimsHeld = true
+	return nil
+}
+
+// acquireClaims is acquireClaimsLocked for callers not holding to.mu
+func (to *TestOrchestrator) acquireClaims(execution *TestExecution) error {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	return to.acquireClaimsLocked(execution)
+}
+
+// releaseClaims frees the resources an execution held for other runs
+func (to *TestOrchestrator) releaseClaims(execution *TestExecution) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	execution.claimsHeld = false
+}
+
+// findConflict returns the first of the claims that can't be granted alongside
+// those held by other executions, keyed by execution ID. Exclusive claims conflict
+// with any other on the same resource; claims on a resource with a capacity
+// conflict when their amounts together exceed it.
+func findConflict(claims []plugins.ResourceClaim, held map[string][]plugins.ResourceClaim) *ConflictError {
+	for _, claim := range claims {
+		total := claim.Amount
+		holder := ""
+		for id, heldClaims := range held {
+			for _, other := range heldClaims {
+				if other.Resource != claim.Resource {
+					continue
+				}
+				if claim.Exclusive || other.Exclusive {
+					return &ConflictError{Resource: claim.Resource, ExecutionID: id, Reason: "claimed exclusively"}
+				}
+				total += other.Amount
+				holder = id
+			}
+		}
+		if holder != "" && claim.Capacity > 0 && total > claim.Capacity {
+			return &ConflictError{
+				Resource:    claim.Resource,
+				ExecutionID: holder,
+				Reason:      fmt.Sprintf("together the runs would use %.0f of %.0f", total, claim.Capacity),
+			}
+		}
+	}
+	return nil
+}
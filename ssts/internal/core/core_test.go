@@ -1,13 +1,30 @@
 package core
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/sirupsen/logrus"
 	"go.uber.org/zap"
 
 	"github.com/pranavgopavaram/ssts/internal/config"
 	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/metrics"
 	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
 )
 
 func TestOrchestratorCreation(t *testing.T) {
@@ -27,7 +44,7 @@ func TestOrchestratorCreation(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 
 	// Create database (nil for test)
-	var db *database.Database
+	var db database.Repository
 
 	// Create plugin manager
 	pluginMgr := plugins.NewPluginManager()
@@ -47,3 +64,1735 @@ func TestOrchestratorCreation(t *testing.T) {
 		t.Error("Expected orchestrator logger to match input logger")
 	}
 }
+
+// runawayPlugin ignores its context and only returns once released
+type runawayPlugin struct {
+	release chan struct{}
+}
+
+func (p runawayPlugin) Name() string                         { return "runaway" }
+func (p runawayPlugin) Version() string                      { return "1.0.0" }
+func (p runawayPlugin) Description() string                  { return "ignores cancellation" }
+func (p runawayPlugin) ConfigSchema() []byte                 { return []byte(`{"type": "object"}`) }
+func (p runawayPlugin) Initialize(config interface{}) error  { return nil }
+func (p runawayPlugin) Cleanup() error                       { return nil }
+func (p runawayPlugin) GetMetrics() map[string]interface{}   { return nil }
+func (p runawayPlugin) GetSafetyLimits() models.SafetyLimits { return models.DefaultSafetyLimits() }
+func (p runawayPlugin) HealthCheck() error                   { return nil }
+func (p runawayPlugin) Execute(context.Context, models.TestParams) error {
+	<-p.release
+	return nil
+}
+
+func TestWatchdogTimesOutRunawayExecution(t *testing.T) {
+	cfg := &config.Config{
+		InfluxDB: config.InfluxDBConfig{URL: "http://localhost:8086", Token: "test-token", Org: "test-org", Bucket: "test-bucket"},
+		Safety: config.SafetyConfig{
+			GlobalLimits: config.GlobalLimits{MaxTestDuration: time.Minute},
+			Watchdog:     config.WatchdogConfig{Enabled: true, Grace: 50 * time.Millisecond, KillTimeout: 50 * time.Millisecond},
+		},
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(runawayPlugin{release: release})
+	orchestrator := NewOrchestrator(cfg, nil, pluginMgr, zap.NewNop())
+
+	_, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "runaway"}, models.TestParams{Duration: time.Hour})
+	if !errors.Is(err, ErrDurationExceeded) {
+		t.Fatalf("StartTest above the maximum duration: error = %v, want ErrDurationExceeded", err)
+	}
+
+	id, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "runaway"}, models.TestParams{Duration: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		execution, err := orchestrator.GetTestStatus(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if execution.Status == models.StatusTimedOut {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("runaway execution was not marked timed out")
+}
+
+// idlePlugin runs until its context is done
+type idlePlugin struct{}
+
+func (idlePlugin) Name() string                         { return "idle" }
+func (idlePlugin) Version() string                      { return "1.0.0" }
+func (idlePlugin) Description() string                  { return "waits for cancellation" }
+func (idlePlugin) ConfigSchema() []byte                 { return []byte(`{"type": "object"}`) }
+func (idlePlugin) Initialize(config interface{}) error  { return nil }
+func (idlePlugin) Cleanup() error                       { return nil }
+func (idlePlugin) GetMetrics() map[string]interface{}   { return nil }
+func (idlePlugin) GetSafetyLimits() models.SafetyLimits { return models.DefaultSafetyLimits() }
+func (idlePlugin) HealthCheck() error                   { return nil }
+func (idlePlugin) Execute(ctx context.Context, params models.TestParams) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// stubbornPlugin ignores cancellation until released
+type stubbornPlugin struct {
+	idlePlugin
+	release chan struct{}
+}
+
+func (stubbornPlugin) Name() string { return "stubborn" }
+func (p stubbornPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	<-p.release
+	return nil
+}
+
+// seedPlugin reports the seed each run is given
+type seedPlugin struct {
+	idlePlugin
+	seeds chan int64
+}
+
+func (seedPlugin) Name() string { return "seed" }
+func (p seedPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	p.seeds <- params.Seed
+	return nil
+}
+
+func TestExecutionsRecordTheirSeed(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	seeds := make(chan int64, 2)
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(seedPlugin{seeds: seeds})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	test := models.TestConfiguration{ID: "test", Plugin: "seed"}
+
+	picked, err := orchestrator.StartTest(test, models.TestParams{Duration: time.Second})
+	if err != nil {
+		t.Fatalf("StartTest: %v", err)
+	}
+	execution, _ := orchestrator.GetTestStatus(picked)
+	if seed := <-seeds; seed == 0 || execution.Seed != seed {
+		t.Fatalf("plugin ran with seed %d, execution records %d, want the same picked seed", seed, execution.Seed)
+	}
+
+	replayed, err := orchestrator.StartTest(test, models.TestParams{Duration: time.Second, Seed: execution.Seed})
+	if err != nil {
+		t.Fatalf("StartTest: %v", err)
+	}
+	replay, _ := orchestrator.GetTestStatus(replayed)
+	if seed := <-seeds; seed != execution.Seed || replay.Seed != execution.Seed {
+		t.Errorf("replay ran with seed %d and records %d, want %d", seed, replay.Seed, execution.Seed)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	tests := []struct {
+		name        string
+		plugin      string
+		duration    time.Duration
+		wait        time.Duration // how long Drain waits for executions to finish on their own
+		stopRunning bool
+		wantStopped int
+		wantStatus  models.ExecutionStatus
+	}{
+		{"finishes within the drain timeout", "idle", 20 * time.Millisecond, 5 * time.Second, false, 0, models.StatusCompleted},
+		{"stopped once the drain timeout passes", "idle", time.Minute, 20 * time.Millisecond, false, 1, models.StatusStopped},
+		{"stopped straight away", "idle", time.Minute, time.Minute, true, 1, models.StatusStopped},
+		{"ignores being stopped", "stubborn", time.Minute, 0, true, 1, models.StatusRunning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+			release := make(chan struct{})
+			defer close(release)
+
+			pluginMgr := plugins.NewPluginManager()
+			pluginMgr.RegisterPlugin(idlePlugin{})
+			pluginMgr.RegisterPlugin(stubbornPlugin{release: release})
+			orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+
+			id, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: tt.plugin}, models.TestParams{Duration: tt.duration})
+			if err != nil {
+				t.Fatalf("StartTest: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.wait)
+			defer cancel()
+			start := time.Now()
+			if stopped := orchestrator.Drain(ctx, tt.stopRunning, 50*time.Millisecond); stopped != tt.wantStopped {
+				t.Errorf("Drain stopped %d executions, want %d", stopped, tt.wantStopped)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Drain took %s", elapsed)
+			}
+			if execution, _ := orchestrator.GetTestStatus(id); execution.Status != tt.wantStatus {
+				t.Errorf("status after draining = %s, want %s", execution.Status, tt.wantStatus)
+			}
+
+			if !orchestrator.IsDraining() {
+				t.Error("orchestrator isn't draining")
+			}
+			if _, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "idle"}, models.TestParams{Duration: time.Second}); !errors.Is(err, ErrDraining) {
+				t.Errorf("StartTest while draining: error = %v, want ErrDraining", err)
+			}
+			if stopped := orchestrator.Drain(ctx, true, time.Millisecond); tt.plugin == "idle" && stopped != 0 {
+				t.Errorf("second Drain stopped %d executions", stopped)
+			}
+		})
+	}
+}
+
+func TestSoakExecutionCheckpointsAndResumes(t *testing.T) {
+	cfg := &config.Config{
+		InfluxDB: config.InfluxDBConfig{URL: "http://localhost:8086", Token: "test-token", Org: "test-org", Bucket: "test-bucket"},
+		Safety: config.SafetyConfig{
+			Soak: config.SoakConfig{Enabled: true, MaxDuration: time.Minute, CheckpointInterval: 20 * time.Millisecond},
+		},
+	}
+
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(idlePlugin{})
+	orchestrator := NewOrchestrator(cfg, nil, pluginMgr, zap.NewNop())
+
+	checkpoints := make(chan models.TestExecution, 100)
+	completed := make(chan models.TestExecution, 2)
+	orchestrator.testOrchestrator.OnCheckpoint(func(execution models.TestExecution) {
+		select {
+		case checkpoints <- execution:
+		default:
+		}
+	})
+	orchestrator.testOrchestrator.OnExecutionComplete(func(execution models.TestExecution) {
+		completed <- execution
+	})
+
+	test := models.TestConfiguration{ID: "test", Plugin: "idle"}
+	if _, err := orchestrator.StartTest(test, models.TestParams{Duration: time.Hour, Soak: true}); !errors.Is(err, ErrDurationExceeded) {
+		t.Fatalf("soak run above the soak maximum: error = %v, want ErrDurationExceeded", err)
+	}
+
+	params := models.TestParams{Duration: 150 * time.Millisecond, Soak: true}
+	id, err := orchestrator.StartTest(test, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var final models.TestExecution
+	select {
+	case final = <-completed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("soak execution did not complete")
+	}
+	if final.Status != models.StatusCompleted || !final.Soak || final.Elapsed < params.Duration {
+		t.Errorf("final state: status %s, soak %v, elapsed %s", final.Status, final.Soak, final.Elapsed)
+	}
+
+	first := <-checkpoints
+	if len(checkpoints) == 0 {
+		t.Error("expected periodic checkpoints after the first")
+	}
+	if first.ID != id || first.Status != models.StatusRunning || first.CheckpointedAt == nil || len(first.Params) == 0 || len(first.Summary) == 0 {
+		t.Fatalf("incomplete checkpoint: %+v", first)
+	}
+
+	// Resume from the first checkpoint as if the server had restarted right after it
+	if err := orchestrator.testOrchestrator.ResumeTest(test, first); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case final = <-completed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("resumed soak execution did not complete")
+	}
+	if final.ID != id || final.Resumes != 1 || final.Status != models.StatusCompleted {
+		t.Errorf("resumed execution: id %s, resumes %d, status %s", final.ID, final.Resumes, final.Status)
+	}
+	if final.Elapsed < params.Duration {
+		t.Errorf("resumed execution elapsed %s, want at least %s", final.Elapsed, params.Duration)
+	}
+
+	finished := first
+	finished.Elapsed = params.Duration
+	if err := orchestrator.testOrchestrator.ResumeTest(test, finished); !errors.Is(err, ErrSoakFinished) {
+		t.Errorf("resuming a finished soak run: error = %v, want ErrSoakFinished", err)
+	}
+}
+
+// loadedSystem reports a fixed CPU usage and otherwise idle resources
+type loadedSystem struct {
+	mu  sync.Mutex
+	cpu float64
+}
+
+func (s *loadedSystem) setCPU(cpu float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cpu = cpu
+}
+
+func (s *loadedSystem) GetCPUUsage() (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cpu, nil
+}
+
+func (s *loadedSystem) GetMemoryUsage() (float64, error)       { return 10, nil }
+func (s *loadedSystem) GetDiskUsage() (float64, error)         { return 10, nil }
+func (s *loadedSystem) GetNetworkUsage() (float64, error)      { return 0, nil }
+func (s *loadedSystem) GetSystemTemperature() (float64, error) { return 40, nil }
+
+func TestPreflightRejectsOrQueuesRunsOnLoadedSystem(t *testing.T) {
+	system := &loadedSystem{cpu: 90}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(system, safety.NewAlertManager(logger), safety.Config{}, logger)
+
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(idlePlugin{})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	settings := PreflightSettings{
+		Enabled:       true,
+		Thresholds:    safety.PreflightThresholds{MaxCPUPercent: 50},
+		RetryInterval: 10 * time.Millisecond,
+	}
+	orchestrator.SetPreflight(settings)
+
+	test := models.TestConfiguration{ID: "test", Plugin: "idle"}
+	params := models.TestParams{Duration: 20 * time.Millisecond}
+
+	_, err := orchestrator.StartTest(test, params)
+	var preflightErr *PreflightError
+	if !errors.As(err, &preflightErr) || !errors.Is(err, ErrPreflightFailed) {
+		t.Fatalf("StartTest on a loaded system: error = %v, want a PreflightError", err)
+	}
+	if len(preflightErr.Reasons) != 1 || !strings.Contains(preflightErr.Reasons[0], "CPU usage 90.0%") {
+		t.Errorf("preflight reasons = %q", preflightErr.Reasons)
+	}
+
+	settings.Queue = true
+	orchestrator.SetPreflight(settings)
+	id, err := orchestrator.StartTest(test, params)
+	if err != nil {
+		t.Fatalf("StartTest with queueing: %v", err)
+	}
+	if execution, _ := orchestrator.GetTestStatus(id); execution.Status != models.StatusQueued {
+		t.Fatalf("status on a loaded system = %s, want queued", execution.Status)
+	}
+
+	system.setCPU(5)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		execution, _ := orchestrator.GetTestStatus(id)
+		if execution.Status == models.StatusCompleted {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("queued execution did not run once the system passed preflight checks")
+}
+
+func TestPreflightRejectsOrQueuesIOTestsWithoutSpace(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(plugins.NewIOStressPlugin())
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	settings := PreflightSettings{Enabled: true, RetryInterval: 10 * time.Millisecond}
+	orchestrator.SetPreflight(settings)
+
+	// No disk has room for 600TB of test files
+	config, _ := json.Marshal(map[string]interface{}{"temp_dir": t.TempDir(), "file_size": "600TB", "workers": 1})
+	test := models.TestConfiguration{ID: "test", Plugin: "io-stress", Config: config}
+	params := models.TestParams{Duration: time.Second}
+
+	_, err := orchestrator.StartTest(test, params)
+	var preflightErr *PreflightError
+	if !errors.As(err, &preflightErr) {
+		t.Fatalf("StartTest without space: error = %v, want a PreflightError", err)
+	}
+	if len(preflightErr.Reasons) != 1 || !strings.HasPrefix(preflightErr.Reasons[0], "io-stress: insufficient space") {
+		t.Errorf("preflight reasons = %q", preflightErr.Reasons)
+	}
+
+	// A queued run keeps waiting for space and fails with the reason once the queue times out
+	settings.Queue = true
+	settings.QueueTimeout = 50 * time.Millisecond
+	orchestrator.SetPreflight(settings)
+	id, err := orchestrator.StartTest(test, params)
+	if err != nil {
+		t.Fatalf("StartTest with queueing: %v", err)
+	}
+	if execution, _ := orchestrator.GetTestStatus(id); execution.Status != models.StatusQueued {
+		t.Fatalf("status without space = %s, want queued", execution.Status)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		execution, _ := orchestrator.GetTestStatus(id)
+		if execution.Status == models.StatusFailed {
+			if execution.ErrorMessage == nil || !strings.Contains(*execution.ErrorMessage, "insufficient space") {
+				t.Errorf("error = %v, want the preflight reason", execution.ErrorMessage)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("queued execution did not fail once the queue timed out")
+}
+
+// recordedAlerts keeps the alerts sent to it
+type recordedAlerts struct {
+	mu     sync.Mutex
+	alerts []safety.Alert
+}
+
+func (r *recordedAlerts) SendAlert(alert safety.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func (r *recordedAlerts) sent() []safety.Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]safety.Alert(nil), r.alerts...)
+}
+
+func TestSafetyViolationEpisodes(t *testing.T) {
+	system := &loadedSystem{cpu: 70}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	alerts := &recordedAlerts{}
+	monitor := safety.NewMonitor(system, alerts, safety.Config{EpisodeGap: 50 * time.Millisecond}, logger)
+	limits := models.SafetyLimits{MaxCPUPercent: 50, MaxMemoryPercent: 100, MaxDiskPercent: 100, MaxNetworkMbps: 1000}
+
+	var first *safety.Violation
+	for i := 0; i < 3; i++ {
+		violation := monitor.CheckSafetyLimits(limits)
+		if violation == nil {
+			t.Fatal("CPU over the limit was not reported as a violation")
+		}
+		if i == 0 {
+			first = violation
+		}
+		if violation.NewEpisode != (i == 0) || violation.EpisodeID != first.EpisodeID {
+			t.Fatalf("sample %d: new episode = %v, episode = %q, want one episode %q", i, violation.NewEpisode, violation.EpisodeID, first.EpisodeID)
+		}
+	}
+	if sent := alerts.sent(); len(sent) != 1 || sent[0].Severity != safety.SeverityWarning || sent[0].DedupKey != "cpu>50" {
+		t.Fatalf("alerts while the episode is ongoing = %+v, want a single warning", sent)
+	}
+
+	system.setCPU(90)
+	monitor.CheckSafetyLimits(limits)
+	if sent := alerts.sent(); len(sent) != 2 || sent[1].Severity != safety.SeverityError {
+		t.Fatalf("alerts after escalating = %+v, want an error alert", sent)
+	}
+
+	system.setCPU(10)
+	time.Sleep(60 * time.Millisecond)
+	if violation := monitor.CheckSafetyLimits(limits); violation != nil {
+		t.Fatalf("violation %+v below the limit", violation)
+	}
+	sent := alerts.sent()
+	if len(sent) != 3 || !sent[2].Resolved || sent[2].DedupKey != "cpu>50" {
+		t.Fatalf("alerts after the limit cleared = %+v, want a resolution", sent)
+	}
+
+	episodes := monitor.GetEpisodes()
+	if len(episodes) != 1 {
+		t.Fatalf("episodes = %+v, want one", episodes)
+	}
+	if episode := episodes[0]; episode.End == nil || episode.Samples != 4 || episode.Peak != 90 || episode.Severity != safety.SeverityError {
+		t.Errorf("closed episode = %+v", episode)
+	}
+}
+
+func TestCustomSafetyChecks(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"status": "violation", "message": "chiller offline", "value": 31, "limit": 25}`))
+	}))
+	defer webhook.Close()
+
+	newCheck := func(definition safety.CustomCheckDefinition) *safety.CustomCheck {
+		check, err := safety.NewCustomCheck(definition)
+		if err != nil {
+			t.Fatalf("NewCustomCheck(%s): %v", definition.Name, err)
+		}
+		return check
+	}
+	system := &loadedSystem{cpu: 70}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	alerts := &recordedAlerts{}
+	monitor := safety.NewMonitor(system, alerts, safety.Config{CheckInterval: time.Hour}, logger)
+	monitor.SetCustomChecks([]*safety.CustomCheck{
+		newCheck(safety.CustomCheckDefinition{Name: "busy", Kind: safety.CustomCheckMetric, Expression: "cpu_percent > 60 && memory_percent < 50 || disk_percent > 99"}),
+		newCheck(safety.CustomCheckDefinition{Name: "facility", Kind: safety.CustomCheckWebhook, URL: webhook.URL, Headers: map[string]string{"X-Token": "secret"}}),
+		newCheck(safety.CustomCheckDefinition{Name: "psu", Kind: safety.CustomCheckCommand, Command: []string{"sh", "-c", "echo PSU 2 failed; exit 2"}}),
+	})
+	limits := models.SafetyLimits{MaxCPUPercent: 100, MaxMemoryPercent: 100, MaxDiskPercent: 100, MaxNetworkMbps: 1000}
+
+	// the metric check is evaluated right away, the others once they've run
+	violation := monitor.CheckSafetyLimits(limits)
+	if violation == nil || violation.Type != "custom_busy" || violation.Severity != safety.SeverityError || violation.CurrentValue != 70 || violation.Limit != 60 {
+		t.Fatalf("first violation = %+v, want the busy metric check's", violation)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for violation.Type != "custom_psu" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		violation = monitor.CheckSafetyLimits(limits)
+	}
+	if !violation.Critical || violation.Severity != safety.SeverityCritical || violation.Message != "Custom check psu: PSU 2 failed" {
+		t.Fatalf("command check violation = %+v, want a critical one", violation)
+	}
+
+	types := make(map[string]safety.Severity)
+	for _, alert := range alerts.sent() {
+		types[alert.Type] = alert.Severity
+	}
+	if types["custom_facility"] != safety.SeverityError || types["custom_busy"] != safety.SeverityError || types["custom_psu"] != safety.SeverityCritical {
+		t.Errorf("alerted = %v, want every check's violation", types)
+	}
+
+	system.setCPU(10)
+	monitor.SetCustomChecks([]*safety.CustomCheck{
+		newCheck(safety.CustomCheckDefinition{Name: "broken", Kind: safety.CustomCheckWebhook, URL: webhook.URL}),
+	})
+	monitor.CheckSafetyLimits(limits)
+	deadline = time.Now().Add(5 * time.Second)
+	for violation = monitor.CheckSafetyLimits(limits); violation == nil && time.Now().Before(deadline); violation = monitor.CheckSafetyLimits(limits) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if violation == nil || violation.Severity != safety.SeverityWarning || violation.Critical {
+		t.Errorf("failing check violation = %+v, want a warning", violation)
+	}
+}
+
+func TestCustomSafetyCheckDefinitions(t *testing.T) {
+	tests := []struct {
+		definition safety.CustomCheckDefinition
+		want       string
+	}{
+		{safety.CustomCheckDefinition{Name: "a", Kind: safety.CustomCheckMetric, Expression: "load > 3"}, "unknown metric"},
+		{safety.CustomCheckDefinition{Name: "a", Kind: safety.CustomCheckMetric, Expression: "cpu_percent >> 3"}, "invalid comparison"},
+		{safety.CustomCheckDefinition{Name: "a", Kind: safety.CustomCheckCommand}, "has no command"},
+		{safety.CustomCheckDefinition{Name: "a", Kind: safety.CustomCheckWebhook, URL: "ftp://x"}, "http or https"},
+		{safety.CustomCheckDefinition{Name: "a", Kind: "script"}, "unknown type"},
+	}
+	for _, tt := range tests {
+		if _, err := safety.NewCustomCheck(tt.definition); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("NewCustomCheck(%+v) = %v, want an error containing %q", tt.definition, err, tt.want)
+		}
+	}
+}
+
+func TestDedupAlertManager(t *testing.T) {
+	alerts := &recordedAlerts{}
+	dedup := safety.NewDedupAlertManager(alerts, time.Minute)
+	now := time.Now()
+
+	send := func(severity safety.Severity, resolved bool, at time.Duration) {
+		dedup.SendAlert(safety.Alert{Type: "cpu", DedupKey: "cpu>50", Severity: severity, Resolved: resolved, Timestamp: now.Add(at)})
+	}
+	send(safety.SeverityWarning, false, 0)
+	send(safety.SeverityWarning, false, time.Second)           // repeat within the window
+	send(safety.SeverityError, false, 2*time.Second)           // escalation
+	send(safety.SeverityWarning, false, 3*time.Second)         // lower severity within the window
+	send(safety.SeverityError, false, 2*time.Minute)           // window elapsed
+	send(safety.SeverityInfo, true, 2*time.Minute+time.Second) // resolution
+	send(safety.SeverityWarning, false, 2*time.Minute+2*time.Second)
+	dedup.SendAlert(safety.Alert{Type: "grade", Severity: safety.SeverityWarning})
+	dedup.SendAlert(safety.Alert{Type: "grade", Severity: safety.SeverityWarning})
+
+	if sent := alerts.sent(); len(sent) != 7 {
+		t.Fatalf("forwarded %d alerts, want 7: %+v", len(sent), sent)
+	}
+}
+
+func TestPersistedAlertsBelongToTheExecutionsProject(t *testing.T) {
+	repo := database.NewMemoryRepository()
+	repo.CreateTestExecution(&models.TestExecution{ID: "e1", TestID: "t1", ProjectID: "p1"})
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	manager := newPersistentAlertManager(&recordedAlerts{}, repo, logger)
+
+	manager.SendAlert(safety.Alert{ID: "a1", Type: "device_endurance", Metadata: map[string]interface{}{"execution_id": "e1"}})
+	manager.SendAlert(safety.Alert{ID: "a2", Type: "cpu"})
+	manager.SendAlert(safety.Alert{ID: "a3", Type: "grade", Metadata: map[string]interface{}{"execution_id": "gone"}})
+
+	for id, want := range map[string]string{"a1": "p1", "a2": "", "a3": ""} {
+		alert, err := repo.GetAlert(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if alert.ProjectID != want {
+			t.Errorf("alert %s: project %q, want %q", id, alert.ProjectID, want)
+		}
+	}
+
+	other, _ := repo.ListAlerts(database.AlertFilter{ProjectID: "p2"})
+	if len(other) != 2 || other[0].ProjectID != "" || other[1].ProjectID != "" {
+		t.Errorf("alerts visible to another project = %+v, want only host-wide ones", other)
+	}
+}
+
+func TestPersistedAlertsHonourMuteRules(t *testing.T) {
+	repo := database.NewMemoryRepository()
+	expired := time.Now().Add(-time.Minute)
+	repo.CreateAlertMuteRule(&models.AlertMuteRule{ID: "cpu", Type: "cpu"})
+	repo.CreateAlertMuteRule(&models.AlertMuteRule{ID: "old", Severity: "critical", ExpiresAt: &expired})
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	forwarded := &recordedAlerts{}
+	manager := newPersistentAlertManager(forwarded, repo, logger)
+
+	// Only the cpu alert is muted: the critical rule has expired
+	sent := []safety.Alert{
+		{Type: "cpu", Severity: safety.SeverityWarning, Timestamp: time.Now()},
+		{Type: "memory", Severity: safety.SeverityCritical, Timestamp: time.Now()},
+		{Type: "memory", Severity: safety.SeverityWarning, Timestamp: time.Now()},
+	}
+	for _, alert := range sent {
+		if err := manager.SendAlert(alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alerts, _ := repo.ListAlerts(database.AlertFilter{IncludeMuted: true})
+	if len(alerts) != len(sent) {
+		t.Fatalf("persisted %d alerts, want %d", len(alerts), len(sent))
+	}
+	for _, alert := range alerts {
+		want := alert.Type == "cpu"
+		if alert.Muted != want || (alert.MuteRuleID != nil) != want {
+			t.Errorf("alert %s/%s: muted = %v by %v", alert.Type, alert.Severity, alert.Muted, alert.MuteRuleID)
+		}
+		if alert.ID == "" {
+			t.Error("persisted alert has no ID")
+		}
+	}
+	if n := len(forwarded.sent()); n != 2 {
+		t.Errorf("forwarded %d alerts, want the 2 unmuted ones", n)
+	}
+}
+
+// devicePlugin is an idle plugin claiming the device named in its config
+type devicePlugin struct{ idlePlugin }
+
+func (devicePlugin) Name() string { return "device" }
+func (devicePlugin) ResourceClaims(config interface{}) ([]plugins.ResourceClaim, error) {
+	device, _ := config.(map[string]interface{})["device"].(string)
+	return []plugins.ResourceClaim{{Resource: plugins.ResourceDevicePrefix + device, Exclusive: true}}, nil
+}
+
+func TestResourceConflictsRejectOrQueueRuns(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(devicePlugin{})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	orchestrator.SetPreflight(PreflightSettings{RetryInterval: 10 * time.Millisecond})
+	orchestrator.SetConflicts(ConflictSettings{Enabled: true})
+
+	sda := models.TestConfiguration{ID: "sda", Plugin: "device", Config: json.RawMessage(`{"device":"sda"}`)}
+	sdb := models.TestConfiguration{ID: "sdb", Plugin: "device", Config: json.RawMessage(`{"device":"sdb"}`)}
+	params := models.TestParams{Duration: 100 * time.Millisecond}
+
+	first, err := orchestrator.StartTest(sda, params)
+	if err != nil {
+		t.Fatalf("StartTest: %v", err)
+	}
+	if _, err := orchestrator.StartTest(sdb, params); err != nil {
+		t.Fatalf("StartTest on another device: %v", err)
+	}
+
+	_, err = orchestrator.StartTest(sda, params)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrResourceConflict) {
+		t.Fatalf("StartTest on a claimed device: error = %v, want a ConflictError", err)
+	}
+	if conflict.Resource != "device:sda" || conflict.ExecutionID != first {
+		t.Errorf("conflict = %+v", conflict)
+	}
+
+	orchestrator.SetConflicts(ConflictSettings{Enabled: true, Queue: true})
+	second, err := orchestrator.StartTest(sda, params)
+	if err != nil {
+		t.Fatalf("StartTest with queueing: %v", err)
+	}
+	if execution, _ := orchestrator.GetTestStatus(second); execution.Status != models.StatusQueued {
+		t.Fatalf("status of a conflicting run = %s, want queued", execution.Status)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		firstRun, _ := orchestrator.GetTestStatus(first)
+		secondRun, _ := orchestrator.GetTestStatus(second)
+		if secondRun.Status == models.StatusCompleted {
+			if firstRun.EndTime == nil || secondRun.EndTime.Sub(*firstRun.EndTime) < params.Duration {
+				t.Error("queued run overlapped the run holding its device")
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("queued run did not start once the device was free")
+}
+
+func TestQueueOrdersQueuedRuns(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(devicePlugin{})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	orchestrator.SetPreflight(PreflightSettings{RetryInterval: 10 * time.Millisecond})
+	orchestrator.SetConflicts(ConflictSettings{Enabled: true, Queue: true})
+
+	sda := models.TestConfiguration{ID: "sda", Plugin: "device", Config: json.RawMessage(`{"device":"sda"}`)}
+	sdb := models.TestConfiguration{ID: "sdb", Plugin: "device", Config: json.RawMessage(`{"device":"sdb"}`)}
+	start := func(test models.TestConfiguration, priority int) string {
+		t.Helper()
+		id, err := orchestrator.StartTest(test, models.TestParams{Duration: 200 * time.Millisecond, Priority: priority})
+		if err != nil {
+			t.Fatalf("StartTest: %v", err)
+		}
+		return id
+	}
+
+	holder := start(sda, 0)
+	low, later, urgent := start(sda, 0), start(sda, 0), start(sda, 5)
+	order := func() []string {
+		var ids []string
+		for _, run := range orchestrator.GetQueue().Queued {
+			ids = append(ids, run.ExecutionID)
+		}
+		return ids
+	}
+	start(sdb, 0)
+	if got, want := order(), []string{urgent, low, later}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("queue = %v, want the urgent run first, then the others in the order queued", got)
+	}
+
+	state := orchestrator.GetQueue()
+	if len(state.Resources) != 2 || state.Resources[0].Resource != "device:sda" || state.Resources[0].Holders[0] != holder {
+		t.Errorf("resources = %+v, want sda held by %s", state.Resources, holder)
+	}
+	first, second := state.Queued[0].EstimatedStart, state.Queued[1].EstimatedStart
+	if first == nil || second == nil || second.Sub(*first) < 200*time.Millisecond {
+		t.Errorf("estimated starts %v and %v, want the second a run's duration after the first", first, second)
+	}
+
+	if err := orchestrator.MoveQueued(later, 1); err != nil {
+		t.Fatalf("MoveQueued: %v", err)
+	}
+	if err := orchestrator.CancelQueued(low); err != nil {
+		t.Fatalf("CancelQueued: %v", err)
+	}
+	if got, want := order(), []string{later, urgent}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("queue after moving and cancelling = %v, want %v", got, want)
+	}
+	if err := orchestrator.CancelQueued(holder); !errors.Is(err, ErrNotQueued) {
+		t.Errorf("CancelQueued of a running execution: error = %v, want ErrNotQueued", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		moved, _ := orchestrator.GetTestStatus(later)
+		last, _ := orchestrator.GetTestStatus(urgent)
+		if last.Status == models.StatusCompleted {
+			if moved.EndTime == nil || last.EndTime.Sub(*moved.EndTime) < 200*time.Millisecond {
+				t.Error("the run moved to the front didn't run first")
+			}
+			if cancelled, _ := orchestrator.GetTestStatus(low); cancelled.Status != models.StatusStopped {
+				t.Errorf("cancelled run status = %s, want stopped", cancelled.Status)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("queued runs did not run")
+}
+
+func TestFindConflict(t *testing.T) {
+	const gb = 1 << 30
+	memory := func(amount float64) plugins.ResourceClaim {
+		return plugins.ResourceClaim{Resource: plugins.ResourceMemory, Amount: amount * gb, Capacity: 16 * gb}
+	}
+	held := map[string][]plugins.ResourceClaim{
+		"a": {memory(6)},
+		"b": {memory(4), {Resource: "device:sda", Exclusive: true}},
+	}
+
+	if conflict := findConflict([]plugins.ResourceClaim{memory(6)}, held); conflict != nil {
+		t.Errorf("16GB of 16GB conflicted: %v", conflict)
+	}
+	if conflict := findConflict([]plugins.ResourceClaim{memory(7)}, held); conflict == nil || conflict.Resource != plugins.ResourceMemory {
+		t.Errorf("17GB of 16GB: conflict = %v", conflict)
+	}
+	if conflict := findConflict([]plugins.ResourceClaim{{Resource: "device:sda", Exclusive: true}}, held); conflict == nil || conflict.ExecutionID != "b" {
+		t.Errorf("claimed device: conflict = %v", conflict)
+	}
+	if conflict := findConflict([]plugins.ResourceClaim{memory(20)}, nil); conflict != nil {
+		t.Errorf("a lone claim over capacity conflicted: %v", conflict)
+	}
+}
+
+func TestHardwareProfileMatchingAndGrading(t *testing.T) {
+	margin := 5.0
+	profiles := []models.HardwareProfile{
+		{ID: "any-nvme", Name: "any-nvme", Plugin: "io-stress", Match: models.Labels{"disk": "nvme"}},
+		{
+			ID:     "980-pro",
+			Name:   "980-pro",
+			Plugin: "io-stress",
+			Match:  models.Labels{"disk": "nvme", "ssd_model": "980-pro"},
+			Expectations: models.ProfileExpectations{
+				{Metric: "plugin.io.iops", Expected: 1000},
+				{Metric: "plugin.io.latency_ms", Stat: models.ProfileStatP95, Expected: 2, LowerIsBetter: true},
+				{Metric: "bytes_written", Stat: models.ProfileStatTotal, Expected: 1 << 30},
+			},
+			MarginPercent: &margin,
+		},
+		{ID: "cpu", Name: "cpu", Plugin: "cpu-stress", Match: models.Labels{"ssd_model": "980-pro"}},
+	}
+
+	profile := MatchProfile(profiles, "io-stress", models.Labels{"disk": "nvme", "ssd_model": "980-pro", "rack": "r12"})
+	if profile == nil || profile.ID != "980-pro" {
+		t.Fatalf("Expected the most specific profile to match, got %+v", profile)
+	}
+	if profile := MatchProfile(profiles, "io-stress", models.Labels{"disk": "nvme"}); profile == nil || profile.ID != "any-nvme" {
+		t.Fatalf("Expected the generic profile to match, got %+v", profile)
+	}
+	if profile := MatchProfile(profiles, "io-stress", models.Labels{"disk": "sata"}); profile != nil {
+		t.Fatalf("Expected no profile to match, got %s", profile.ID)
+	}
+
+	summary := models.ExecutionSummary{
+		Metrics: map[string]models.MetricStats{
+			"plugin.io.iops":       {Count: 10, Avg: 960},
+			"plugin.io.latency_ms": {Count: 10, P95: 2.05},
+		},
+		Totals: map[string]float64{"bytes_written": 1 << 30},
+	}
+	grade := GradeSummary(summary, *profile, 10)
+	if !grade.Passed || grade.MarginPercent != 5 || len(grade.Checks) != 3 {
+		t.Fatalf("Expected results within the profile's margin to pass, got %+v", grade)
+	}
+	if grade.Checks[0].DeviationPercent != -4 {
+		t.Errorf("Expected iops deviation -4%%, got %v", grade.Checks[0].DeviationPercent)
+	}
+	if grade.Checks[1].Stat != models.ProfileStatP95 || grade.Checks[1].DeviationPercent >= 0 {
+		t.Errorf("Expected higher latency to deviate negatively, got %+v", grade.Checks[1])
+	}
+
+	summary.Metrics["plugin.io.iops"] = models.MetricStats{Count: 10, Avg: 900}
+	delete(summary.Totals, "bytes_written")
+	grade = GradeSummary(summary, *profile, 10)
+	if grade.Passed {
+		t.Fatal("Expected underperforming results to fail")
+	}
+	if grade.Checks[0].Passed || !grade.Checks[1].Passed {
+		t.Errorf("Expected only iops to fail, got %+v", grade.Checks[:2])
+	}
+	if !grade.Checks[2].Missing || grade.Checks[2].Passed {
+		t.Errorf("Expected the missing total to fail, got %+v", grade.Checks[2])
+	}
+}
+
+// memoryMetricStore keeps written points, returning them one field at a time like InfluxDB
+type memoryMetricStore struct {
+	mu     sync.Mutex
+	points []models.MetricPoint
+}
+
+func (s *memoryMetricStore) WriteMetricPoint(point models.MetricPoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.points = append(s.points, point)
+	return nil
+}
+
+func (s *memoryMetricStore) StreamTestMetrics(ctx context.Context, testID string, timeRange models.TimeRange, fn func(models.MetricPoint) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, point := range s.points {
+		if point.TestID != testID || point.Timestamp.Before(timeRange.Start) || !point.Timestamp.Before(timeRange.End) {
+			continue
+		}
+		for field, value := range point.Fields {
+			tags := make(map[string]string, len(point.Tags))
+			for key, tag := range point.Tags {
+				tags[key] = tag
+			}
+			split := point
+			split.Tags = tags
+			split.Fields = map[string]interface{}{field: value}
+			if err := fn(split); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestMetricBufferOffloadsOlderPoints(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	store := &memoryMetricStore{}
+	to := NewTestOrchestrator(plugins.NewPluginManager(), nil, nil, logger)
+	to.SetMetricBuffer(MetricBufferSettings{Size: 4, Store: store})
+
+	start := time.Now().Add(-time.Minute)
+	execution := &TestExecution{
+		ID:        "execution",
+		Config:    models.TestConfiguration{ID: "test"},
+		StartTime: start,
+		metrics:   to.newMetricBuffer(),
+	}
+	to.executions[execution.ID] = execution
+
+	for i := 0; i < 10; i++ {
+		err := to.AddMetric(execution.ID, models.MetricPoint{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			TestID:    "test",
+			Source:    "plugin",
+			Type:      "io",
+			Fields:    map[string]interface{}{"iops": float64(i), "latency_ms": float64(10 * i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if execution.metrics.count > 4 {
+		t.Fatalf("Expected at most 4 points in memory, got %d", execution.metrics.count)
+	}
+	if len(store.points) != 6 {
+		t.Fatalf("Expected 6 points offloaded, got %d", len(store.points))
+	}
+
+	metrics, err := to.GetTestMetrics(execution.ID, models.TimeRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 10 {
+		t.Fatalf("Expected all 10 points from memory and the store, got %d", len(metrics))
+	}
+	for i, point := range metrics {
+		if point.Fields["iops"] != float64(i) || point.Fields["latency_ms"] != float64(10*i) {
+			t.Fatalf("Point %d: got fields %v", i, point.Fields)
+		}
+	}
+
+	metrics, err = to.GetTestMetrics(execution.ID, models.TimeRange{Start: start.Add(4 * time.Second), End: start.Add(7 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 4 || metrics[0].Fields["iops"] != 4.0 || metrics[3].Fields["iops"] != 7.0 {
+		t.Fatalf("Expected points 4 to 7 across memory and the store, got %v", metrics)
+	}
+
+	execution.mu.Lock()
+	summary := execution.summarizeMetrics(time.Minute)
+	execution.mu.Unlock()
+	stats := summary.Metrics["plugin.io.iops"]
+	if summary.Samples != 10 || stats.Count != 10 || stats.Min != 0 || stats.Max != 9 || stats.Avg != 4.5 {
+		t.Fatalf("Expected the summary to cover offloaded points, got %d samples and %+v", summary.Samples, stats)
+	}
+}
+
+func TestPrerequisitesReportEveryFailure(t *testing.T) {
+	pluginManager := plugins.NewPluginManager()
+	if err := pluginManager.RegisterPlugin(idlePlugin{}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	failedGrade, _ := json.Marshal(models.ExecutionSummary{Grade: &models.ProfileGrade{Passed: false}})
+	history := map[string][]models.TestExecution{
+		"burn-in":  {{ID: "e1", Status: models.StatusCompleted, EndTime: &now}},
+		"ssd-perf": {{ID: "e2", Status: models.StatusCompleted, EndTime: &now, Summary: failedGrade}},
+	}
+	var windows []*time.Time
+	env := prerequisiteEnv{
+		now:           now,
+		pluginManager: pluginManager,
+		executions: func(testID string, since *time.Time) ([]models.TestExecution, error) {
+			windows = append(windows, since)
+			return history[testID], nil
+		},
+		freeSpace: func(path string) (uint64, error) { return 10 << 30, nil },
+		kernelParam: func(name string) (string, error) {
+			if name == "vm.swappiness" {
+				return "10\n", nil
+			}
+			return "4096\t65536  6291456\n", nil
+		},
+	}
+
+	met := models.Prerequisites{
+		Tests:        []models.TestPrerequisite{{TestID: "burn-in", Within: 24 * time.Hour}},
+		Plugins:      []models.PluginPrerequisite{{Name: "idle", MinVersion: "0.9.12"}},
+		MinFreeDisk:  []models.DiskPrerequisite{{Path: "/data", MinFree: "5GB"}},
+		KernelParams: []models.KernelParamPrerequisite{{Name: "vm.swappiness", Value: "10"}, {Name: "net.ipv4.tcp_rmem", Value: "4096 65536 6291456"}},
+	}
+	if err := env.check(met); err != nil {
+		t.Fatalf("check() = %v, want nil", err)
+	}
+	if len(windows) != 1 || windows[0] == nil || !windows[0].Equal(now.Add(-24*time.Hour)) {
+		t.Errorf("executions looked up since %v, want 24h before now", windows)
+	}
+
+	unmet := models.Prerequisites{
+		Tests:        []models.TestPrerequisite{{TestID: "ssd-perf"}, {TestID: "never-run"}},
+		Plugins:      []models.PluginPrerequisite{{Name: "idle", MinVersion: "v1.10"}, {Name: "gpu-stress"}},
+		MinFreeDisk:  []models.DiskPrerequisite{{Path: "/data", MinFree: "1TB"}},
+		KernelParams: []models.KernelParamPrerequisite{{Name: "vm.swappiness", Value: "1"}},
+	}
+	err := env.check(unmet)
+	if !errors.Is(err, ErrPrerequisitesFailed) {
+		t.Fatalf("check() = %v, want ErrPrerequisitesFailed", err)
+	}
+	var prerequisiteErr *PrerequisiteError
+	if !errors.As(err, &prerequisiteErr) {
+		t.Fatalf("check() = %T, want *PrerequisiteError", err)
+	}
+	want := []string{
+		"test ssd-perf passed",
+		"test never-run passed",
+		"plugin idle >= v1.10",
+		"plugin gpu-stress",
+		"1TB free on /data",
+		"vm.swappiness = 1",
+	}
+	if len(prerequisiteErr.Failures) != len(want) {
+		t.Fatalf("got failures %+v, want %v", prerequisiteErr.Failures, want)
+	}
+	for i, failure := range prerequisiteErr.Failures {
+		if failure.Prerequisite != want[i] || failure.Reason == "" {
+			t.Errorf("failure %d = %+v, want %s with a reason", i, failure, want[i])
+		}
+	}
+}
+
+// budgetPlugin reports a fixed share of a write budget done
+type budgetPlugin struct {
+	idlePlugin
+	percent float64
+}
+
+func (p budgetPlugin) Progress() (float64, string) { return p.percent, "" }
+
+func TestExecutionProgress(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	params := models.TestParams{Duration: 10 * time.Minute, RampDown: 2 * time.Minute, Cooldown: time.Minute}
+	execution := &TestExecution{Status: models.StatusRunning, plugin: idlePlugin{}, params: params, runStart: start}
+
+	got := execution.progress(start.Add(5 * time.Minute))
+	if got == nil || got.Percent != 50 || got.Phase != models.PhaseSteady || got.Elapsed != 5*time.Minute || got.Remaining != 6*time.Minute {
+		t.Errorf("halfway: %+v, want 50%% steady, 5m elapsed, 6m remaining", got)
+	}
+	if got := execution.progress(start.Add(9 * time.Minute)); got.Phase != models.PhaseRampDown {
+		t.Errorf("in the ramp-down window: phase %s, want %s", got.Phase, models.PhaseRampDown)
+	}
+
+	cooldown := start.Add(10 * time.Minute)
+	execution.cooldownStart = &cooldown
+	got = execution.progress(cooldown.Add(20 * time.Second))
+	if got.Percent != 100 || got.Phase != models.PhaseCooldown || got.Remaining != 40*time.Second {
+		t.Errorf("cooling down: %+v, want 100%% cooldown with 40s remaining", got)
+	}
+
+	// A plugin ending on a write budget may finish long before its duration
+	execution = &TestExecution{Status: models.StatusRunning, plugin: budgetPlugin{percent: 80}, params: params, runStart: start}
+	got = execution.progress(start.Add(4 * time.Minute))
+	if got.Percent != 80 || got.Remaining != 2*time.Minute {
+		t.Errorf("reported by the plugin: %+v, want 80%% with 1m of writes and 1m cooldown remaining", got)
+	}
+
+	execution.Status = models.StatusCompleted
+	if got := execution.progress(start.Add(4 * time.Minute)); got != nil {
+		t.Errorf("finished execution: progress %+v, want nil", got)
+	}
+}
+
+func TestProgressReportedWhileRunning(t *testing.T) {
+	cfg := &config.Config{
+		InfluxDB: config.InfluxDBConfig{URL: "http://localhost:8086", Token: "test-token", Org: "test-org", Bucket: "test-bucket"},
+		Server:   config.ServerConfig{WebSocket: config.WebSocketConfig{ProgressInterval: 10 * time.Millisecond}},
+	}
+
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(idlePlugin{})
+	orchestrator := NewOrchestrator(cfg, nil, pluginMgr, zap.NewNop())
+
+	reports := make(chan models.TestExecution, 100)
+	orchestrator.OnProgress(func(execution models.TestExecution) {
+		select {
+		case reports <- execution:
+		default:
+		}
+	})
+
+	id, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "idle"}, models.TestParams{Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orchestrator.StopTest(id)
+
+	select {
+	case report := <-reports:
+		if report.ID != id || report.Progress == nil || report.Progress.Phase != models.PhaseSteady || report.Progress.Remaining <= 0 {
+			t.Errorf("progress report: %+v", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no progress reported")
+	}
+
+	execution, err := orchestrator.GetTestStatus(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if execution.Progress == nil || execution.Progress.Percent >= 100 {
+		t.Errorf("status of running execution: progress %+v", execution.Progress)
+	}
+}
+
+// phasedPlugin reports whichever phase the test sets
+type phasedPlugin struct {
+	idlePlugin
+	mu    *sync.Mutex
+	phase *string
+}
+
+func (p phasedPlugin) Progress() (float64, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return 0, *p.phase
+}
+
+func TestSteadyStateWindow(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	params := models.TestParams{Duration: 10 * time.Minute, RampDown: 2 * time.Minute}
+	execution := &TestExecution{plugin: idlePlugin{}, params: params, runStart: start}
+
+	// Without phases from the plugin, steady state ends where ramp-down begins
+	if got := execution.steadyState(start.Add(5 * time.Minute)); got == nil || !got.Start.Equal(start) || !got.End.Equal(start.Add(5*time.Minute)) {
+		t.Errorf("halfway: steady state %+v, want from the start until now", got)
+	}
+	if got := execution.steadyState(start.Add(11 * time.Minute)); got == nil || !got.End.Equal(start.Add(8*time.Minute)) {
+		t.Errorf("finished: steady state %+v, want it to end at the ramp-down window", got)
+	}
+
+	previous := phasePollInterval
+	phasePollInterval = 5 * time.Millisecond
+	defer func() { phasePollInterval = previous }()
+
+	var mu sync.Mutex
+	phase := models.PhaseRampUp
+	setPhase := func(next string) {
+		mu.Lock()
+		phase = next
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+	}
+	execution = &TestExecution{plugin: phasedPlugin{mu: &mu, phase: &phase}, params: models.TestParams{Duration: time.Minute}, runStart: time.Now()}
+	stop := watchPhases(execution)
+
+	setPhase(models.PhaseRampUp)
+	execution.mu.RLock()
+	rampingUp := execution.steadyState(time.Now())
+	execution.mu.RUnlock()
+	if rampingUp != nil {
+		t.Errorf("ramping up: steady state %+v, want none", rampingUp)
+	}
+
+	steadyAfter := time.Now()
+	setPhase(models.PhaseSteady)
+	rampDownBefore := time.Now()
+	setPhase(models.PhaseRampDown)
+	stop()
+
+	got := execution.steadyState(time.Now())
+	if got == nil || got.Start.Before(steadyAfter) || got.End.Before(rampDownBefore) || got.End.After(time.Now()) {
+		t.Errorf("steady state %+v, want it between the reported ramp-up and ramp-down", got)
+	}
+}
+
+func TestExecutionWindow(t *testing.T) {
+	cfg := &config.Config{InfluxDB: config.InfluxDBConfig{URL: "http://localhost:8086", Token: "test-token", Org: "test-org", Bucket: "test-bucket"}}
+	orchestrator := NewOrchestrator(cfg, nil, plugins.NewPluginManager(), zap.NewNop())
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Minute)
+	steady := models.TimeRange{Start: start.Add(time.Minute), End: start.Add(8 * time.Minute)}
+	summary, _ := json.Marshal(models.ExecutionSummary{SteadyState: &steady})
+	execution := models.TestExecution{ID: "exec", StartTime: &start, EndTime: &end, Summary: summary}
+
+	if got, err := orchestrator.ExecutionWindow(execution, WindowLastRun); err != nil || !got.Start.Equal(start) || !got.End.Equal(end) {
+		t.Errorf("last_run = %+v, %v, want the whole execution", got, err)
+	}
+	if got, err := orchestrator.ExecutionWindow(execution, WindowSteadyState); err != nil || got != steady {
+		t.Errorf("steady_state = %+v, %v, want %+v from the summary", got, err, steady)
+	}
+
+	execution.Summary = nil
+	if _, err := orchestrator.ExecutionWindow(execution, WindowSteadyState); !errors.Is(err, ErrNoSteadyState) {
+		t.Errorf("steady_state without a summary: %v, want ErrNoSteadyState", err)
+	}
+	if _, err := orchestrator.ExecutionWindow(execution, "yesterday"); !errors.Is(err, ErrUnknownWindow) {
+		t.Errorf("unknown window: %v, want ErrUnknownWindow", err)
+	}
+}
+
+// shapedPlugin records the load levels it is set to
+type shapedPlugin struct {
+	idlePlugin
+	levels chan int
+}
+
+func (p shapedPlugin) SetLoadLevel(percent int) {
+	select {
+	case p.levels <- percent:
+	default:
+	}
+}
+
+func TestLoadProfileShapesRun(t *testing.T) {
+	profile := &models.LoadProfile{Shape: models.LoadShapeSpike, Period: time.Hour, Amplitude: 70, Spike: time.Minute}
+	if err := checkLoadProfile(idlePlugin{}, profile); !errors.Is(err, ErrLoadShapingUnsupported) {
+		t.Errorf("profile for a plugin that can't shape its load: %v, want ErrLoadShapingUnsupported", err)
+	}
+	if err := checkLoadProfile(idlePlugin{}, &models.LoadProfile{Shape: models.LoadShapeConstant}); err != nil {
+		t.Errorf("constant profile: %v", err)
+	}
+
+	previous := loadShapeInterval
+	loadShapeInterval = 5 * time.Millisecond
+	defer func() { loadShapeInterval = previous }()
+
+	// Two minutes in, the spike is over and the load is at its low level
+	plugin := shapedPlugin{levels: make(chan int, 100)}
+	execution := &TestExecution{
+		Status:   models.StatusRunning,
+		plugin:   plugin,
+		params:   models.TestParams{Duration: time.Hour, LoadProfile: profile},
+		runStart: time.Now().Add(-2 * time.Minute),
+	}
+	stop := shapeLoad(execution)
+	defer stop()
+
+	select {
+	case level := <-plugin.levels:
+		if level != 30 {
+			t.Errorf("load level %d, want 30 between spikes", level)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("load level never set")
+	}
+
+	execution.mu.RLock()
+	progress := execution.progress(time.Now())
+	execution.mu.RUnlock()
+	if progress == nil || progress.LoadLevel != 30 {
+		t.Errorf("progress %+v, want the current load level reported", progress)
+	}
+}
+
+// rampingPlugin reports the params of its runs, and whether it ramps down
+type rampingPlugin struct {
+	idlePlugin
+	name   string
+	ramps  bool
+	params chan models.TestParams
+}
+
+func (p rampingPlugin) Name() string    { return p.name }
+func (p rampingPlugin) RampsDown() bool { return p.ramps }
+func (p rampingPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	p.params <- params
+	return nil
+}
+
+func TestRampDownNeedsSupport(t *testing.T) {
+	if err := checkRampDown(idlePlugin{}, time.Minute); !errors.Is(err, ErrRampDownUnsupported) {
+		t.Errorf("ramp-down for a plugin that can't ramp down: %v, want ErrRampDownUnsupported", err)
+	}
+	if err := checkRampDown(rampingPlugin{ramps: false}, time.Minute); !errors.Is(err, ErrRampDownUnsupported) {
+		t.Errorf("ramp-down for a plugin saying it doesn't ramp down: %v, want ErrRampDownUnsupported", err)
+	}
+	if err := checkRampDown(rampingPlugin{ramps: true}, time.Minute); err != nil {
+		t.Errorf("ramp-down for a plugin that ramps down: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	settings := safety.Config{RampDownEnabled: true, RampDownDuration: 30 * time.Second, PostTestCooldown: time.Minute}
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), settings, logger)
+	params := make(chan models.TestParams, 1)
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(rampingPlugin{name: "steady", params: params})
+	pluginMgr.RegisterPlugin(rampingPlugin{name: "ramping", ramps: true, params: params})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+
+	asked := models.TestParams{Duration: time.Minute, RampDown: 10 * time.Second}
+	if _, err := orchestrator.StartTest(models.TestConfiguration{ID: "steady", Plugin: "steady"}, asked); !errors.Is(err, ErrRampDownUnsupported) {
+		t.Errorf("StartTest asking a steady run to ramp down: %v, want ErrRampDownUnsupported", err)
+	}
+
+	// The configured ramp-down window only applies to plugins that ramp down; the cooldown to all
+	for plugin, want := range map[string]time.Duration{"steady": 0, "ramping": 30 * time.Second} {
+		id, err := orchestrator.StartTest(models.TestConfiguration{ID: plugin, Plugin: plugin}, models.TestParams{Duration: time.Minute})
+		if err != nil {
+			t.Fatalf("StartTest %s: %v", plugin, err)
+		}
+		got := <-params
+		orchestrator.StopTest(id)
+		if got.RampDown != want || got.Cooldown != time.Minute {
+			t.Errorf("%s run: ramp-down %s and cooldown %s, want %s and the configured 1m", plugin, got.RampDown, got.Cooldown, want)
+		}
+	}
+}
+
+func TestRunCooldown(t *testing.T) {
+	previous := cooldownInterval
+	cooldownInterval = 5 * time.Millisecond
+	defer func() { cooldownInterval = previous }()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	orchestrator := NewTestOrchestrator(plugins.NewPluginManager(), nil, metrics.NewCollector(zap.NewNop()), logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	execution := &TestExecution{ID: "execution", Config: models.TestConfiguration{ID: "test"}, Context: ctx, metrics: orchestrator.newMetricBuffer()}
+
+	// System metrics are sampled, tagged as cooldown, until the period ends
+	start := time.Now()
+	orchestrator.runCooldown(execution, 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("cooldown took %s, want 100ms", elapsed)
+	}
+	if execution.cooldownStart == nil || execution.cooldownStart.Before(start) {
+		t.Errorf("cooldown start %v, want it recorded when the cooldown began", execution.cooldownStart)
+	}
+	points := execution.metrics.all()
+	if len(points) < 2 {
+		t.Fatalf("%d points sampled during the cooldown, want several", len(points))
+	}
+	for _, point := range points {
+		if point.Tags["phase"] != "cooldown" || point.TestID != "test" || point.Type != "system" {
+			t.Errorf("cooldown point %+v, want a system point of the test tagged phase=cooldown", point)
+		}
+		if _, ok := point.Fields["cpu_usage_percent"]; !ok {
+			t.Errorf("cooldown point fields %v, want the CPU usage", point.Fields)
+		}
+	}
+
+	// Stopping the execution ends the cooldown early
+	cancel()
+	start = time.Now()
+	orchestrator.runCooldown(execution, time.Hour)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cooldown of a stopped execution took %s, want it to end straight away", elapsed)
+	}
+}
+
+// diskFillingPlugin writes a megabyte a millisecond until its files are reclaimed
+type diskFillingPlugin struct {
+	idlePlugin
+	written   *int64
+	reclaimed chan struct{}
+}
+
+func (diskFillingPlugin) Name() string { return "disk-filling" }
+func (p diskFillingPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			atomic.AddInt64(p.written, 1<<20)
+		}
+	}
+}
+func (p diskFillingPlugin) DiskWritten() (int64, []string) {
+	return atomic.LoadInt64(p.written), []string{"/data"}
+}
+func (p diskFillingPlugin) ReclaimDisk() (int64, error) {
+	close(p.reclaimed)
+	return atomic.LoadInt64(p.written), nil
+}
+
+func TestDiskGuardStopsRunAtQuota(t *testing.T) {
+	previous := diskUsage
+	diskUsage = func(path string) (*disk.UsageStat, error) {
+		return &disk.UsageStat{Path: path, Total: 100 << 30, Free: 50 << 30}, nil
+	}
+	defer func() { diskUsage = previous }()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	plugin := diskFillingPlugin{written: new(int64), reclaimed: make(chan struct{})}
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(plugin)
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	orchestrator.SetDiskGuard(DiskGuardSettings{Enabled: true, Quota: 1 << 30, MinFreePercent: 5, Interval: 10 * time.Millisecond})
+	violations := make(chan models.Violation, 10)
+	orchestrator.OnViolation(func(violation models.Violation) { violations <- violation })
+	completed := make(chan models.TestExecution, 1)
+	orchestrator.OnExecutionComplete(func(execution models.TestExecution) { completed <- execution })
+
+	// The run asks for less than the server's quota
+	id, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "disk-filling"}, models.TestParams{Duration: time.Minute, DiskQuotaMB: 20})
+	if err != nil {
+		t.Fatalf("StartTest: %v", err)
+	}
+	select {
+	case <-plugin.reclaimed:
+	case <-time.After(5 * time.Second):
+		orchestrator.StopTest(id)
+		t.Fatal("test files weren't reclaimed at the quota")
+	}
+
+	execution, _ := orchestrator.GetTestStatus(id)
+	if execution.Status != models.StatusFailed || execution.FailureClass != models.FailureSafety ||
+		execution.ErrorMessage == nil || !strings.Contains(*execution.ErrorMessage, "disk quota") {
+		t.Errorf("execution %s (%s), want failed for the disk quota", execution.Status, execution.FailureClass)
+	}
+	if written := atomic.LoadInt64(plugin.written); written > 1<<30 {
+		t.Errorf("run wrote %d MB before it was stopped, want about its own quota of 20 MB", written>>20)
+	}
+
+	// The violation is passed on for persisting and counted by severity
+	select {
+	case violation := <-violations:
+		if violation.ExecutionID != id || violation.Severity != string(safety.SeverityCritical) || !violation.Critical {
+			t.Errorf("violation = %+v, want the critical quota violation of %s", violation, id)
+		}
+	default:
+		t.Error("no violation passed on")
+	}
+	var summary models.ExecutionSummary
+	select {
+	case finished := <-completed:
+		json.Unmarshal(finished.Summary, &summary)
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution did not complete")
+	}
+	if summary.ViolationsBySeverity["critical"] != 1 || summary.CriticalViolations != 1 {
+		t.Errorf("summary violations = %d critical, by severity %v", summary.CriticalViolations, summary.ViolationsBySeverity)
+	}
+}
+
+func TestCheckDiskFreeSpaceFloor(t *testing.T) {
+	previous := diskUsage
+	diskUsage = func(path string) (*disk.UsageStat, error) {
+		return &disk.UsageStat{Path: path, Total: 100 << 30, Free: 3 << 30}, nil
+	}
+	defer func() { diskUsage = previous }()
+
+	plugin := diskFillingPlugin{written: new(int64)}
+	if violation := checkDisk(plugin, DiskGuardSettings{MinFreePercent: 5}, 0); violation == nil || violation.Type != ViolationDiskSpace {
+		t.Errorf("3%% free under a 5%% floor: violation %+v, want %s", violation, ViolationDiskSpace)
+	}
+	if violation := checkDisk(plugin, DiskGuardSettings{MinFreePercent: 2, MinFreeBytes: 1 << 30}, 0); violation != nil {
+		t.Errorf("3%% free over the floors: violation %+v", violation)
+	}
+	if violation := checkDisk(plugin, DiskGuardSettings{MinFreePercent: 2, MinFreeBytes: 4 << 30}, 0); violation == nil {
+		t.Error("3GB free under a 4GB floor: no violation")
+	}
+	if quota := diskQuota(DiskGuardSettings{Quota: 10 << 20}, 50); quota != 10<<20 {
+		t.Errorf("run quota above the server's: %d, want the server's", quota)
+	}
+}
+
+func TestSystemSnapshot(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("proc/sys/kernel/osrelease", "6.5.0-14-generic\n")
+	write("proc/sys/vm/swappiness", "10\n")
+	write("proc/sys/vm/overcommit_memory", "2\n")
+	write("proc/1/cgroup", "0::/kubepods/besteffort/pod1/"+strings.Repeat("ab", 32)+"\n")
+	write("sys/block/sda/queue/scheduler", "mq-deadline kyber [bfq] none\n")
+	write("sys/block/nvme0n1/queue/scheduler", "[none] mq-deadline\n")
+	write("sys/block/nvme0n1/device/model", "Samsung SSD 980 PRO 1TB                 \n")
+	write("proc/cpuinfo", "processor\t: 0\nmodel name\t: AMD EPYC 7763 64-Core Processor\n\nprocessor\t: 1\nmodel name\t: AMD EPYC 7763 64-Core Processor\n")
+	write("sys/devices/system/cpu/cpu0/cpufreq/scaling_governor", "performance\n")
+	write("sys/devices/system/cpu/cpu1/cpufreq/scaling_governor", "powersave\n")
+	write("sys/devices/system/cpu/cpu2/cpufreq/scaling_governor", "performance\n")
+	write("sys/kernel/mm/transparent_hugepage/enabled", "always [madvise] never\n")
+
+	defer func(proc, sys, root string) { procSys, sysFS, procRoot = proc, sys, root }(procSys, sysFS, procRoot)
+	procSys, sysFS, procRoot = filepath.Join(root, "proc/sys"), filepath.Join(root, "sys"), filepath.Join(root, "proc")
+
+	snapshot := captureSystemSnapshot()
+
+	if snapshot.KernelVersion != "6.5.0-14-generic" {
+		t.Errorf("kernel version = %q", snapshot.KernelVersion)
+	}
+	if snapshot.KernelParams["vm.swappiness"] != "10" || snapshot.KernelParams["vm.overcommit_memory"] != "2" {
+		t.Errorf("kernel params = %v", snapshot.KernelParams)
+	}
+	if _, ok := snapshot.KernelParams["vm.dirty_ratio"]; ok {
+		t.Error("unreadable kernel param recorded")
+	}
+	if snapshot.IOSchedulers["sda"] != "bfq" || snapshot.IOSchedulers["nvme0n1"] != "none" {
+		t.Errorf("I/O schedulers = %v", snapshot.IOSchedulers)
+	}
+	if snapshot.CPUModel != "AMD EPYC 7763 64-Core Processor" {
+		t.Errorf("CPU model = %q", snapshot.CPUModel)
+	}
+	if len(snapshot.DiskModels) != 1 || snapshot.DiskModels["nvme0n1"] != "Samsung SSD 980 PRO 1TB" {
+		t.Errorf("disk models = %v", snapshot.DiskModels)
+	}
+	if snapshot.CPUGovernor != "performance,powersave" {
+		t.Errorf("CPU governor = %q, want performance,powersave", snapshot.CPUGovernor)
+	}
+	if snapshot.TransparentHugePages != "madvise" {
+		t.Errorf("transparent hugepages = %q, want madvise", snapshot.TransparentHugePages)
+	}
+	if snapshot.Container == nil || snapshot.Container.Runtime != "kubernetes" || snapshot.Container.ID != strings.Repeat("ab", 32) {
+		t.Errorf("container = %+v", snapshot.Container)
+	}
+}
+
+func TestCPUFrequencyPinning(t *testing.T) {
+	root := t.TempDir()
+	defer func(sys string) { sysFS = sys }(sysFS)
+	sysFS = root
+
+	governors := []string{
+		filepath.Join(root, "devices/system/cpu/cpu0/cpufreq/scaling_governor"),
+		filepath.Join(root, "devices/system/cpu/cpu1/cpufreq/scaling_governor"),
+	}
+	for _, path := range governors {
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte("powersave\n"), 0o644)
+	}
+	read := func(path string) string {
+		data, _ := os.ReadFile(path)
+		return strings.TrimSpace(string(data))
+	}
+
+	params := models.TestParams{PinCPUGovernor: true}
+	if err := checkFrequencyPinning(params); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFrequencyPinning(models.TestParams{DisableTurbo: true}); !errors.Is(err, ErrFrequencyPinningUnavailable) {
+		t.Errorf("disabling turbo without a turbo control: error = %v, want ErrFrequencyPinningUnavailable", err)
+	}
+
+	pins := newCPUFrequencyPins()
+	pins.marker = filepath.Join(root, "marker.json")
+
+	// Concurrent runs share the pinned governor; the last to finish restores it
+	first, err := pins.pin(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := pins.pin(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range governors {
+		if got := read(path); got != "performance" {
+			t.Errorf("%s = %q while pinned, want performance", path, got)
+		}
+	}
+	first()
+	if got := read(governors[0]); got != "performance" {
+		t.Errorf("governor restored while another run still pins it: %q", got)
+	}
+	second()
+	for _, path := range governors {
+		if got := read(path); got != "powersave" {
+			t.Errorf("%s = %q after the runs finished, want powersave", path, got)
+		}
+	}
+	if _, err := os.Stat(pins.marker); !os.IsNotExist(err) {
+		t.Errorf("restore marker left behind: %v", err)
+	}
+
+	// A process that died while the governor was pinned is cleaned up after
+	if _, err := pins.pin(params); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(pins.marker)
+	var marker cpuFrequencyMarker
+	json.Unmarshal(data, &marker)
+	marker.PID = 1 << 30 // no such process
+	data, _ = json.Marshal(marker)
+	os.WriteFile(pins.marker, data, 0o600)
+
+	restarted := newCPUFrequencyPins()
+	restarted.marker = pins.marker
+	restored, err := restarted.recoverStale()
+	if err != nil || restored != len(governors) {
+		t.Fatalf("recovering a dead process's pins: restored %d, error %v", restored, err)
+	}
+	if got := read(governors[1]); got != "powersave" {
+		t.Errorf("governor after recovery = %q, want powersave", got)
+	}
+
+	// Markers naming anything but the frequency controls restore nothing
+	victim := filepath.Join(root, "etc/passwd")
+	os.MkdirAll(filepath.Dir(victim), 0o755)
+	os.WriteFile(victim, []byte("root:x:0:0\n"), 0o644)
+	planted := map[string]map[string]string{
+		"outside sysfs":      {victim: "owned"},
+		"traversal":          {filepath.Join(root, "devices/system/cpu/cpu0/cpufreq/../../../../etc/passwd"): "owned"},
+		"other cpu file":     {filepath.Join(root, "devices/system/cpu/cpu0/online"): "0"},
+		"bad governor value": {governors[0]: "performance\nroot"},
+		"bad turbo value":    {filepath.Join(root, "devices/system/cpu/intel_pstate/no_turbo"): "2"},
+		"mixed":              {governors[0]: "performance", victim: "owned"},
+	}
+	for name, original := range planted {
+		data, _ := json.Marshal(cpuFrequencyMarker{PID: 1 << 30, Original: original})
+		os.WriteFile(pins.marker, data, 0o600)
+		if restored, err := restarted.recoverStale(); err == nil || restored != 0 {
+			t.Errorf("%s: restored %d, error %v; want the marker rejected", name, restored, err)
+		}
+		if got := read(victim); got != "root:x:0:0" {
+			t.Fatalf("%s: recovery wrote outside the frequency controls: %q", name, got)
+		}
+		if got := read(governors[0]); got != "powersave" {
+			t.Errorf("%s: governor = %q, want an invalid marker to restore nothing", name, got)
+		}
+	}
+
+	// Nor is a marker that is a link to another file
+	os.Remove(pins.marker)
+	os.Symlink(victim, pins.marker)
+	if _, err := restarted.recoverStale(); err == nil {
+		t.Error("recovering through a symlinked marker succeeded")
+	}
+}
+
+func TestNotFoundErrors(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	orchestrator := NewTestOrchestrator(plugins.NewPluginManager(), monitor, metrics.NewCollector(zap.NewNop()), logger)
+
+	_, statusErr := orchestrator.GetTestStatus("missing")
+	_, metricsErr := orchestrator.GetTestMetrics("missing", models.TimeRange{})
+	_, startErr := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "missing"}, models.TestParams{Duration: time.Second})
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"status", statusErr, ErrExecutionNotFound},
+		{"metrics", metricsErr, ErrExecutionNotFound},
+		{"stop", orchestrator.StopTest("missing"), ErrExecutionNotFound},
+		{"emergency stop", orchestrator.EmergencyStop("missing", "test"), ErrExecutionNotFound},
+		{"unknown plugin", startErr, plugins.ErrPluginNotFound},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+}
+
+func TestExecutionsByTest(t *testing.T) {
+	cfg := &config.Config{
+		InfluxDB: config.InfluxDBConfig{URL: "http://localhost:8086", Token: "test-token", Org: "test-org", Bucket: "test-bucket"},
+	}
+	repo := database.NewMemoryRepository()
+	now := time.Now()
+	for _, execution := range []models.TestExecution{
+		{ID: "completed", TestID: "test", Status: models.StatusCompleted, Created: now.Add(-2 * time.Hour)},
+		{ID: "failed", TestID: "test", Status: models.StatusFailed, Created: now.Add(-time.Hour)},
+		{ID: "other", TestID: "other", Status: models.StatusCompleted, Created: now},
+	} {
+		if err := repo.CreateTestExecution(&execution); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(idlePlugin{})
+	orchestrator := NewOrchestrator(cfg, repo, pluginMgr, zap.NewNop())
+	id, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "idle"}, models.TestParams{Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orchestrator.StopTest(id)
+
+	latestID := func(execution *models.TestExecution, err error) string {
+		if err != nil {
+			return err.Error()
+		}
+		return execution.ID
+	}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"running", latestID(orchestrator.GetRunningExecutionForTest("test")), id},
+		{"latest", latestID(orchestrator.GetLatestExecutionForTest("test")), id},
+		{"latest stored", latestID(orchestrator.GetLatestExecutionForTest("test", models.StatusCompleted, models.StatusFailed)), "failed"},
+		{"latest completed", latestID(orchestrator.GetLatestExecutionForTest("test", models.StatusCompleted)), "completed"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if _, err := orchestrator.GetRunningExecutionForTest("other"); !errors.Is(err, ErrExecutionNotFound) {
+		t.Errorf("running execution of a finished test: error = %v, want ErrExecutionNotFound", err)
+	}
+	if _, err := orchestrator.GetLatestExecutionForTest("missing"); !errors.Is(err, ErrExecutionNotFound) {
+		t.Errorf("latest execution of a test never run: error = %v, want ErrExecutionNotFound", err)
+	}
+
+	executions, err := orchestrator.ListExecutionsByTest("test", 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != 2 || executions[0].ID != "failed" || executions[1].ID != "completed" {
+		t.Errorf("second page = %+v, want the stored executions newest first", executions)
+	}
+	if executions, _ := orchestrator.ListExecutionsByTest("test", 0, 0); len(executions) != 3 {
+		t.Errorf("got %d executions of the test, want 3", len(executions))
+	}
+}
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pranavgopavaram/ssts/internal/statefile"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrFrequencyPinningUnavailable is returned by StartTest when a run asks to pin
+// the CPU governor or disable turbo on a machine without the sysfs controls for it
+var ErrFrequencyPinningUnavailable = errors.New("CPU frequency pinning is not available")
+
+// Settings pinned while a test runs
+const (
+	pinGovernor = "governor" // every CPU's frequency governor set to performance
+	pinNoTurbo  = "no_turbo" // turbo boost disabled
+)
+
+// cpuFrequencyPins sets the CPU governor to performance and disables turbo while
+// runs that ask for it are executing. Concurrent runs share the pinned settings;
+// the original values are restored when the last of them finishes. The originals
+// are also recorded in a marker file, so a later process restores them if this
+// one dies while they are pinned.
+type cpuFrequencyPins struct {
+	mu     sync.Mutex
+	marker string
+	pins   map[string]*frequencyPin
+}
+
+// frequencyPin is one pinned setting and the values it replaced
+type frequencyPin struct {
+	users    int
+	original map[string]string // sysfs file -> value before pinning
+}
+
+// restorableFiles are the sysfs files, relative to sysFS, a restore marker may name,
+// each with the values it may hold. Markers are only written by the server, but
+// are checked anyway since recovering from one writes as root.
+var restorableFiles = []struct {
+	path  *regexp.Regexp
+	value *regexp.Regexp
+}{
+	{regexp.MustCompile(`^devices/system/cpu/cpu[0-9]+/cpufreq/scaling_governor$`), regexp.MustCompile(`^[a-z_]{1,32}$`)},
+	{regexp.MustCompile(`^devices/system/cpu/intel_pstate/no_turbo$`), regexp.MustCompile(`^[01]$`)},
+	{regexp.MustCompile(`^devices/system/cpu/cpufreq/boost$`), regexp.MustCompile(`^[01]$`)},
+}
+
+// cpuFrequencyMarker is written while settings are pinned
+type cpuFrequencyMarker struct {
+	PID      int               `json:"pid"`
+	Original map[string]string `json:"original"`
+}
+
+func newCPUFrequencyPins() *cpuFrequencyPins {
+	return &cpuFrequencyPins{
+		marker: statefile.Path("cpufreq.json"),
+		pins:   make(map[string]*frequencyPin),
+	}
+}
+
+// frequencySettings returns the settings a run asks to pin
+func frequencySettings(params models.TestParams) []string {
+	var settings []string
+	if params.PinCPUGovernor {
+		settings = append(settings, pinGovernor)
+	}
+	if params.DisableTurbo {
+		settings = append(settings, pinNoTurbo)
+	}
+	return settings
+}
+
+// pinTargets returns the sysfs files to write to pin a setting and the value each gets
+func pinTargets(setting string) (map[string]string, error) {
+	targets := make(map[string]string)
+	switch setting {
+	case pinGovernor:
+		paths, _ := filepath.Glob(filepath.Join(sysFS, "devices/system/cpu/cpu[0-9]*/cpufreq/scaling_governor"))
+		for _, path := range paths {
+			targets[path] = "performance"
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("%w: no cpufreq governors found", ErrFrequencyPinningUnavailable)
+		}
+	case pinNoTurbo:
+		// intel_pstate exposes no_turbo; acpi-cpufreq and amd-pstate expose boost
+		if path := filepath.Join(sysFS, "devices/system/cpu/intel_pstate/no_turbo"); fileExists(path) {
+			targets[path] = "1"
+		} else if path := filepath.Join(sysFS, "devices/system/cpu/cpufreq/boost"); fileExists(path) {
+			targets[path] = "0"
+		} else {
+			return nil, fmt.Errorf("%w: no turbo control found", ErrFrequencyPinningUnavailable)
+		}
+	}
+	return targets, nil
+}
+
+// checkFrequencyPinning verifies the controls for the settings a run asks to pin exist
+func checkFrequencyPinning(params models.TestParams) error {
+	for _, setting := range frequencySettings(params) {
+		if _, err := pinTargets(setting); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pin applies the settings a run asks for and returns a function restoring them
+// once no other run needs them. The returned function is never nil, and is safe
+// to call after pin failed: whatever was applied is restored.
+func (p *cpuFrequencyPins) pin(params models.TestParams) (func(), error) {
+	var held []string
+	release := func() {
+		for _, setting := range held {
+			p.release(setting)
+		}
+	}
+
+	for _, setting := range frequencySettings(params) {
+		if err := p.acquire(setting); err != nil {
+			return release, err
+		}
+		held = append(held, setting)
+	}
+	return release, nil
+}
+
+// acquire pins a setting, or joins the runs already holding it
+func (p *cpuFrequencyPins) acquire(setting string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pin := p.pins[setting]; pin != nil {
+		pin.users++
+		return nil
+	}
+
+	targets, err := pinTargets(setting)
+	if err != nil {
+		return err
+	}
+
+	original := make(map[string]string, len(targets))
+	for path := range targets {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		original[path] = strings.TrimSpace(string(data))
+	}
+
+	// Record the originals before changing anything, so there is no moment at
+	// which a crash would leave the settings changed without a way back
+	p.pins[setting] = &frequencyPin{users: 1, original: original}
+	if err := p.writeMarker(); err != nil {
+		delete(p.pins, setting)
+		return fmt.Errorf("failed to write restore marker: %w", err)
+	}
+
+	for path, value := range targets {
+		if err := writeSysfs(path, value); err != nil {
+			restoreSysfs(original)
+			delete(p.pins, setting)
+			p.writeMarker()
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// release drops a run's hold on a setting, restoring it when no run holds it any more
+func (p *cpuFrequencyPins) release(setting string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pin := p.pins[setting]
+	if pin == nil {
+		return
+	}
+	if pin.users--; pin.users > 0 {
+		return
+	}
+
+	// Keep the marker listing files that couldn't be restored, so a later process retries
+	if failed := restoreSysfs(pin.original); len(failed) > 0 {
+		pin.original = failed
+	} else {
+		delete(p.pins, setting)
+	}
+	p.writeMarker()
+}
+
+// writeMarker records the original values of every pinned setting, or removes
+// the marker when nothing is pinned
+func (p *cpuFrequencyPins) writeMarker() error {
+	marker := cpuFrequencyMarker{PID: os.Getpid(), Original: make(map[string]string)}
+	for _, pin := range p.pins {
+		for path, value := range pin.original {
+			marker.Original[path] = value
+		}
+	}
+	if len(marker.Original) == 0 {
+		return statefile.Remove(p.marker)
+	}
+
+	data, err := json.Marshal(marker)
+	if err != nil {
+		return err
+	}
+	return statefile.Write(p.marker, data)
+}
+
+// recoverStale restores settings recorded by a process that exited while they were
+// pinned. It returns the number of files restored. A marker naming anything but
+// the CPU frequency controls is rejected without restoring any of it.
+func (p *cpuFrequencyPins) recoverStale() (int, error) {
+	data, err := statefile.Read(p.marker)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var marker cpuFrequencyMarker
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return 0, fmt.Errorf("invalid restore marker %s: %w", p.marker, err)
+	}
+	if marker.PID == os.Getpid() || statefile.ProcessAlive(marker.PID) {
+		return 0, nil
+	}
+	for path, value := range marker.Original {
+		if !restorable(path, value) {
+			return 0, fmt.Errorf("invalid restore marker %s: %s is not a CPU frequency control", p.marker, path)
+		}
+	}
+
+	if failed := restoreSysfs(marker.Original); len(failed) > 0 {
+		return len(marker.Original) - len(failed), fmt.Errorf("failed to restore %d CPU frequency setting(s)", len(failed))
+	}
+	return len(marker.Original), statefile.Remove(p.marker)
+}
+
+// restorable reports whether a restore marker may set path to value
+func restorable(path, value string) bool {
+	if path != filepath.Clean(path) {
+		return false
+	}
+	rel, err := filepath.Rel(sysFS, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, file := range restorableFiles {
+		if file.path.MatchString(rel) {
+			return file.value.MatchString(value)
+		}
+	}
+	return false
+}
+
+// restoreSysfs writes back original values, returning those that couldn't be written
+func restoreSysfs(original map[string]string) map[string]string {
+	failed := make(map[string]string)
+	for path, value := range original {
+		if err := writeSysfs(path, value); err != nil {
+			failed[path] = value
+		}
+	}
+	return failed
+}
+
+// writeSysfs writes a value to an existing sysfs file, never creating one
+func writeSysfs(path, value string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(value); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,218 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/internal/secrets"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// maxRecordedViolations bounds the safety violations an execution keeps for its
+// diagnostics bundle; a limit exceeded for hours is checked every second
+const maxRecordedViolations = 100
+
+// kernelLogTimeout bounds how long reading the kernel log may hold up completion
+const kernelLogTimeout = 5 * time.Second
+
+// DiagnosticsSettings configure the bundles captured when an execution fails
+type DiagnosticsSettings struct {
+	Enabled        bool
+	Directory      string
+	MetricPoints   int // most recent points included
+	KernelLogLines int // most recent kernel log lines included
+}
+
+// SetDiagnostics configures failure diagnostics
+func (to *TestOrchestrator) SetDiagnostics(settings DiagnosticsSettings) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.diagnostics = settings
+}
+
+// DiagnosticsPath returns the path of a diagnostics bundle by its file name, as
+// recorded in an execution
+func (to *TestOrchestrator) DiagnosticsPath(name string) (string, error) {
+	to.mu.RLock()
+	dir := to.diagnostics.Directory
+	to.mu.RUnlock()
+
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid diagnostics bundle name %q", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// recordViolation keeps a violation for the execution's diagnostics bundle,
+// dropping the oldest once maxRecordedViolations are kept. The caller must hold
+// execution.mu.
+func (e *TestExecution) recordViolation(violation safety.Violation) {
+	if len(e.violations) == maxRecordedViolations {
+		copy(e.violations, e.violations[1:])
+		e.violations = e.violations[:len(e.violations)-1]
+	}
+	e.violations = append(e.violations, violation)
+}
+
+// fail marks the execution failed with a message and the class of failure. The
+// caller must hold execution.mu.
+func (e *TestExecution) fail(class models.FailureClass, message string) {
+	e.Status = models.StatusFailed
+	e.ErrorMessage = &message
+	e.failureClass = class
+}
+
+// kernelLog returns the last lines of the kernel log. It is a variable so tests
+// can replace it.
+var kernelLog = func(ctx context.Context, lines int) (string, error) {
+	output, err := exec.CommandContext(ctx, "dmesg").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read kernel log: %w", err)
+	}
+	all := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n") + "\n", nil
+}
+
+// diagnosticsBundle is what a bundle holds besides the kernel log and goroutine dump
+type diagnosticsBundle struct {
+	execution  models.TestExecution
+	plugin     string
+	config     json.RawMessage
+	params     json.RawMessage
+	metrics    []models.MetricPoint
+	violations []safety.Violation
+}
+
+// captureDiagnostics writes a diagnostics bundle for a failed or timed out
+// execution, returning its file name, or "" when none was written. Anyone who
+// can see the execution can download the bundle, so the secrets in its
+// configuration and parameters are redacted as in API responses.
+func (to *TestOrchestrator) captureDiagnostics(execution *TestExecution, result models.TestExecution) string {
+	to.mu.RLock()
+	settings := to.diagnostics
+	to.mu.RUnlock()
+
+	if !settings.Enabled || (result.Status != models.StatusFailed && result.Status != models.StatusTimedOut) {
+		return ""
+	}
+
+	execution.mu.RLock()
+	params, err := json.Marshal(execution.params)
+	bundle := diagnosticsBundle{
+		execution:  result,
+		plugin:     execution.Config.Plugin,
+		config:     secrets.RedactConfig(execution.Config.Config),
+		params:     secrets.RedactConfig(params),
+		metrics:    execution.metrics.all(),
+		violations: append([]safety.Violation(nil), execution.violations...),
+	}
+	execution.mu.RUnlock()
+	if err != nil {
+		bundle.params = nil
+	}
+	bundle.execution.Params = secrets.RedactConfig(bundle.execution.Params)
+	if n := settings.MetricPoints; n >= 0 && len(bundle.metrics) > n {
+		bundle.metrics = bundle.metrics[len(bundle.metrics)-n:]
+	}
+
+	logger := to.logger.WithField("execution_id", execution.ID)
+	name := execution.ID + ".tar.gz"
+	if err := writeDiagnostics(filepath.Join(settings.Directory, name), bundle, settings.KernelLogLines, logger); err != nil {
+		logger.WithError(err).Error("Failed to write diagnostics bundle")
+		return ""
+	}
+	logger.WithField("bundle", name).Info("Captured diagnostics for failed execution")
+	return name
+}
+
+// writeDiagnostics writes a bundle as a gzipped tar archive, replacing the file
+// only once it is complete
+func writeDiagnostics(path string, bundle diagnosticsBundle, kernelLogLines int, logger *logrus.Entry) error {
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"execution.json", bundle.execution},
+		{"config.json", map[string]interface{}{"plugin": bundle.plugin, "config": bundle.config, "params": bundle.params}},
+		{"metrics.json", bundle.metrics},
+		{"violations.json", bundle.violations},
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create diagnostics directory: %w", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".diagnostics-*")
+	if err != nil {
+		return fmt.Errorf("failed to create diagnostics bundle: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	archive := tar.NewWriter(gz)
+	now := time.Now()
+	add := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0o640, Size: int64(len(data)), ModTime: now}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := archive.Write(data)
+		return err
+	}
+
+	for _, f := range files {
+		data, err := json.MarshalIndent(f.value, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", f.name, err)
+		}
+		if err := add(f.name, data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+
+	// The kernel log often can't be read without privileges; the bundle is
+	// still worth having without it
+	ctx, cancel := context.WithTimeout(context.Background(), kernelLogTimeout)
+	dmesg, err := kernelLog(ctx, kernelLogLines)
+	cancel()
+	if err != nil {
+		logger.WithError(err).Warn("Diagnostics bundle captured without the kernel log")
+		dmesg = err.Error() + "\n"
+	}
+	if err := add("dmesg.txt", []byte(dmesg)); err != nil {
+		return fmt.Errorf("failed to write dmesg.txt: %w", err)
+	}
+
+	var goroutines bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&goroutines, 2)
+	if err := add("goroutines.txt", goroutines.Bytes()); err != nil {
+		return fmt.Errorf("failed to write goroutines.txt: %w", err)
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write diagnostics bundle: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write diagnostics bundle: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write diagnostics bundle: %w", err)
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/metrics"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/internal/secrets"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// failingPlugin fails as soon as it runs
+type failingPlugin struct{ idlePlugin }
+
+func (failingPlugin) Name() string { return "failing" }
+func (failingPlugin) Execute(context.Context, models.TestParams) error {
+	return errors.New("device on fire")
+}
+
+// readBundle returns the contents of a diagnostics bundle's files by name
+func readBundle(t *testing.T, path string) map[string]string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("bundle not written: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(archive)
+		files[header.Name] = string(data)
+	}
+}
+
+func TestFailedExecutionCapturesDiagnostics(t *testing.T) {
+	defer func(previous func(context.Context, int) (string, error)) { kernelLog = previous }(kernelLog)
+	kernelLog = func(ctx context.Context, lines int) (string, error) {
+		return "nvme0: I/O timeout\n", nil
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(failingPlugin{})
+	pluginMgr.RegisterPlugin(idlePlugin{})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	dir := t.TempDir()
+	orchestrator.SetDiagnostics(DiagnosticsSettings{Enabled: true, Directory: dir, MetricPoints: 10, KernelLogLines: 50})
+
+	completed := make(chan models.TestExecution, 2)
+	orchestrator.OnExecutionComplete(func(execution models.TestExecution) { completed <- execution })
+
+	wait := func() models.TestExecution {
+		t.Helper()
+		select {
+		case execution := <-completed:
+			return execution
+		case <-time.After(5 * time.Second):
+			t.Fatal("execution did not complete")
+			return models.TestExecution{}
+		}
+	}
+
+	test := models.TestConfiguration{ID: "test", Plugin: "failing", Config: json.RawMessage(`{"target": {"url": "https://db", "password": "hunter2"}}`)}
+	params := models.TestParams{Duration: time.Second, CustomParams: map[string]interface{}{"api_token": "tok-123"}}
+	if _, err := orchestrator.StartTest(test, params); err != nil {
+		t.Fatalf("StartTest: %v", err)
+	}
+	execution := wait()
+	if execution.Status != models.StatusFailed || execution.FailureClass != models.FailurePlugin {
+		t.Fatalf("execution = %s, class %q, want failed by the plugin", execution.Status, execution.FailureClass)
+	}
+	if execution.Diagnostics != execution.ID+".tar.gz" {
+		t.Fatalf("diagnostics = %q, want the bundle named after the execution", execution.Diagnostics)
+	}
+
+	path, err := orchestrator.DiagnosticsPath(execution.Diagnostics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readBundle(t, path)
+	for _, name := range []string{"execution.json", "config.json", "metrics.json", "violations.json", "goroutines.txt"} {
+		if files[name] == "" {
+			t.Errorf("bundle is missing %s", name)
+		}
+	}
+	// Anyone who can see the execution can download the bundle, so it holds no secrets
+	for _, secret := range []string{"hunter2", "tok-123"} {
+		if strings.Contains(files["config.json"], secret) || strings.Contains(files["execution.json"], secret) {
+			t.Errorf("bundle holds the secret %q:\n%s", secret, files["config.json"])
+		}
+	}
+	if !strings.Contains(files["config.json"], "https://db") || !strings.Contains(files["config.json"], secrets.Redacted) {
+		t.Errorf("config.json = %s, want the configuration with its secrets redacted", files["config.json"])
+	}
+	if files["dmesg.txt"] != "nvme0: I/O timeout\n" {
+		t.Errorf("dmesg.txt = %q", files["dmesg.txt"])
+	}
+	if status, _ := orchestrator.GetTestStatus(execution.ID); status.Diagnostics != execution.Diagnostics {
+		t.Errorf("tracked execution diagnostics = %q, want the bundle linked", status.Diagnostics)
+	}
+
+	// Executions that succeed get none
+	if _, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "idle"}, models.TestParams{Duration: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("StartTest: %v", err)
+	}
+	if execution := wait(); execution.Status != models.StatusCompleted || execution.Diagnostics != "" || execution.FailureClass != "" {
+		t.Errorf("completed execution = %s, diagnostics %q, class %q", execution.Status, execution.Diagnostics, execution.FailureClass)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("diagnostics directory holds %d files, want the one bundle", len(entries))
+	}
+
+	if _, err := orchestrator.DiagnosticsPath(filepath.Join("..", execution.Diagnostics)); err == nil {
+		t.Error("DiagnosticsPath accepted a path outside the directory")
+	}
+}
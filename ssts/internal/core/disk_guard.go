@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/sirupsen/logrus"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+)
+
+// Violation types recorded when the disk guard stops a run
+const (
+	ViolationDiskQuota = "disk_quota" // the run wrote its quota
+	ViolationDiskSpace = "disk_space" // a file system it writes to fell below the free space floor
+)
+
+// DiskGuardSettings bound what runs of plugins writing test files may take from
+// disk
+type DiskGuardSettings struct {
+	Enabled        bool
+	Quota          int64   // bytes a run may write, overwrites included; 0 for none
+	MinFreePercent float64 // free space floor of the file systems written to; 0 skips it
+	MinFreeBytes   int64   // and in absolute terms; 0 skips it
+	Interval       time.Duration
+}
+
+// SetDiskGuard configures the disk guard
+func (to *TestOrchestrator) SetDiskGuard(settings DiskGuardSettings) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.diskGuardSettings = settings
+}
+
+// diskUsage reports the capacity of the file system holding a path. It is a
+// variable so tests can substitute one.
+var diskUsage = disk.Usage
+
+// diskQuota returns the quota of a run: the server's, lowered to the run's own
+func diskQuota(settings DiskGuardSettings, runQuotaMB int64) int64 {
+	quota := settings.Quota
+	if run := runQuotaMB * 1024 * 1024; run > 0 && (quota == 0 || run < quota) {
+		quota = run
+	}
+	return quota
+}
+
+// guardDisk checks what a run's test files take from disk until ctx is done.
+// Once the run has written its quota, or a file system it writes to falls
+// below the free space floor, its test files are removed and it is stopped,
+// with a critical violation recorded.
+func (to *TestOrchestrator) guardDisk(ctx context.Context, execution *TestExecution) {
+	to.mu.RLock()
+	settings := to.diskGuardSettings
+	to.mu.RUnlock()
+	writer, ok := execution.plugin.(plugins.DiskWriter)
+	if !settings.Enabled || settings.Interval <= 0 || !ok {
+		return
+	}
+
+	execution.mu.RLock()
+	quota := diskQuota(settings, execution.params.DiskQuotaMB)
+	execution.mu.RUnlock()
+
+	ticker := time.NewTicker(settings.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		violation := checkDisk(writer, settings, quota)
+		if violation == nil {
+			continue
+		}
+
+		freed, err := writer.ReclaimDisk()
+		logger := to.logger.WithFields(logrus.Fields{
+			"execution_id": execution.ID,
+			"violation":    violation.Type,
+			"value":        violation.CurrentValue,
+			"limit":        violation.Limit,
+			"freed_bytes":  freed,
+		})
+		if err != nil {
+			logger = logger.WithError(err)
+		}
+		logger.Error("Disk guard removed the test files of a run")
+
+		to.noteViolation(execution, *violation)
+
+		to.EmergencyStop(execution.ID, violation.Message)
+		return
+	}
+}
+
+// checkDisk returns the violation of a run that has written its quota or left
+// a file system below the free space floor, or nil if it has done neither
+func checkDisk(writer plugins.DiskWriter, settings DiskGuardSettings, quota int64) *safety.Violation {
+	written, paths := writer.DiskWritten()
+	now := time.Now()
+	if quota > 0 && written >= quota {
+		return &safety.Violation{
+			Type:         ViolationDiskQuota,
+			CurrentValue: float64(written),
+			Limit:        float64(quota),
+			Severity:     safety.SeverityCritical,
+			Message:      fmt.Sprintf("Run wrote %d MB, reaching its disk quota of %d MB", written/(1024*1024), quota/(1024*1024)),
+			Timestamp:    now,
+			Critical:     true,
+		}
+	}
+
+	for _, path := range paths {
+		usage, err := diskUsage(path)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+		floor := uint64(float64(usage.Total) * settings.MinFreePercent / 100)
+		if minimum := uint64(settings.MinFreeBytes); minimum > floor {
+			floor = minimum
+		}
+		if floor > 0 && usage.Free < floor {
+			return &safety.Violation{
+				Type:         ViolationDiskSpace,
+				CurrentValue: float64(usage.Free),
+				Limit:        float64(floor),
+				Severity:     safety.SeverityCritical,
+				Message:      fmt.Sprintf("%s has %d MB free, below the floor of %d MB", path, usage.Free/(1024*1024), floor/(1024*1024)),
+				Timestamp:    now,
+				Critical:     true,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// EnduranceBudgets parses the configured device endurance budgets into bytes, keyed by device
+func EnduranceBudgets(cfg config.EnduranceConfig) (map[string]int64, error) {
+	budgets := make(map[string]int64, len(cfg.Devices))
+	for _, device := range cfg.Devices {
+		bytes, err := plugins.ParseSize(device.TBW)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tbw for device %s: %w", device.Device, err)
+		}
+		if bytes <= 0 {
+			return nil, fmt.Errorf("invalid tbw for device %s: must be positive", device.Device)
+		}
+		budgets[device.Device] = bytes
+	}
+	return budgets, nil
+}
+
+// EnduranceUsed returns the percentage of budget written, or 0 without a budget
+func EnduranceUsed(written, budget int64) float64 {
+	if budget <= 0 {
+		return 0
+	}
+	return float64(written) / float64(budget) * 100
+}
+
+// trackDeviceWrites adds the bytes a finished execution wrote to each device to
+// the device's cumulative total, alerting for devices past the warning share of
+// their endurance budget. Stopped and failed executions count too; their writes
+// still wore the device.
+func (o *Orchestrator) trackDeviceWrites(execution *models.TestExecution, alertManager safety.AlertManager) {
+	if !o.config.Endurance.Enabled || len(execution.Summary) == 0 {
+		return
+	}
+
+	var summary models.ExecutionSummary
+	if err := json.Unmarshal(execution.Summary, &summary); err != nil || len(summary.DeviceWrites) == 0 {
+		return
+	}
+
+	budgets, err := EnduranceBudgets(o.config.Endurance)
+	if err != nil {
+		o.logger.Warn("Ignoring device endurance budgets", zap.Error(err))
+		budgets = nil
+	}
+
+	devices := make([]string, 0, len(summary.DeviceWrites))
+	for device := range summary.DeviceWrites {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+
+	for _, device := range devices {
+		written := summary.DeviceWrites[device]
+		if written <= 0 {
+			continue
+		}
+		endurance, err := o.repo.AddDeviceWrites(device, written, execution.ID)
+		if err != nil {
+			o.logger.Error("Failed to record device writes", zap.String("device", device), zap.Error(err))
+			continue
+		}
+
+		budget := budgets[device]
+		used := EnduranceUsed(endurance.BytesWritten, budget)
+		if budget == 0 || used < o.config.Endurance.WarnPercent {
+			continue
+		}
+
+		severity := safety.SeverityWarning
+		if used >= 100 {
+			severity = safety.SeverityCritical
+		}
+		alert := safety.Alert{
+			Type:      "device_endurance",
+			Message:   fmt.Sprintf("Tests have written %.1f%% of the endurance budget of device %s", used, device),
+			Severity:  severity,
+			Timestamp: time.Now(),
+			Metadata: map[string]interface{}{
+				"execution_id":  execution.ID,
+				"device":        device,
+				"bytes_written": endurance.BytesWritten,
+				"budget_bytes":  budget,
+			},
+		}
+		if err := alertManager.SendAlert(alert); err != nil {
+			o.logger.Warn("Failed to send device endurance alert", zap.Error(err))
+		}
+	}
+}
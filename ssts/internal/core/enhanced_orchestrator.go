@@ -3,8 +3,11 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -13,8 +16,12 @@ import (
 
 	"github.com/pranavgopavaram/ssts/internal/config"
 	"github.com/pranavgopavaram/ssts/internal/database"
+	logging "github.com/pranavgopavaram/ssts/internal/logger"
 	"github.com/pranavgopavaram/ssts/internal/metrics"
+	"github.com/pranavgopavaram/ssts/internal/notify"
 	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/publish"
+	"github.com/pranavgopavaram/ssts/internal/remotewrite"
 	"github.com/pranavgopavaram/ssts/internal/safety"
 	"github.com/pranavgopavaram/ssts/pkg/models"
 )
@@ -22,32 +29,61 @@ import (
 // Orchestrator manages the overall test execution and coordination
 type Orchestrator struct {
 	config           *config.Config
-	db               *database.Database
+	repo             database.Repository
 	influxDB         *database.InfluxDB
 	pluginManager    *plugins.PluginManager
 	safetyMonitor    *safety.Monitor
 	metricsCollector *metrics.Collector
 	testOrchestrator *TestOrchestrator
+	publisher        *publish.Publisher    // nil when results aren't published
+	remoteWriters    []*remotewrite.Writer // remote-write targets metrics are shipped to
+	notifier         *notify.Dispatcher    // nil when users aren't notified
+	pluginWatcher    *pluginWatcher        // nil when plugin directories aren't watched
+	sandbox          *plugins.Sandbox      // confines external plugin processes; nil when they aren't sandboxed
+	sandboxErr       error                 // why the configured sandbox couldn't be set up
+	onAnomaly        []func(models.Anomaly, *models.Annotation)
+	mu               sync.RWMutex // guards onAnomaly
 	logger           *zap.Logger
 }
 
 // NewOrchestrator creates a new orchestrator
-func NewOrchestrator(cfg *config.Config, db *database.Database, pluginMgr *plugins.PluginManager, logger *zap.Logger) *Orchestrator {
+func NewOrchestrator(cfg *config.Config, repo database.Repository, pluginMgr *plugins.PluginManager, logger *zap.Logger) *Orchestrator {
 	// Initialize InfluxDB
 	influxDB := database.NewInfluxDB(cfg.InfluxDB)
 
 	// Create logrus logger from zap logger
 	logrusLogger := logrus.New()
+	logrusLogger.AddHook(logging.RedactHook{})
+
+	// Measure usage against what the server is allowed, such as its container's cgroup limits
+	limits := metrics.DetectResourceLimits(cfg.Resources.Detect, cfg.Resources.CPUs, uint64(cfg.Resources.MemoryMB)*1024*1024)
+	logger.Info("Resource limits detected",
+		zap.String("source", limits.Source),
+		zap.Int("host_cpus", limits.HostCPUs),
+		zap.Float64("cpus", limits.CPUs),
+		zap.Uint64("memory_bytes", limits.MemoryBytes))
 
 	// Initialize system monitor
 	systemMonitor := safety.NewSystemMonitor()
+	systemMonitor.SetResourceLimits(limits)
+
+	// Notify users about alerts and finished executions as their preferences ask
+	var notifier *notify.Dispatcher
+	var logAlerts safety.AlertManager = safety.NewAlertManager(logrusLogger)
+	if cfg.Notifications.Enabled && repo != nil {
+		notifier = notify.NewDispatcher(cfg.Notifications, repo, logger)
+		logAlerts = &notifyingAlertManager{next: logAlerts, dispatcher: notifier}
+	}
 
-	// Initialize alert manager
-	alertManager := safety.NewAlertManager(logrusLogger)
+	// Initialize alert manager, persisting alerts so they can be reviewed and acknowledged
+	alertManager := safety.NewDedupAlertManager(
+		newPersistentAlertManager(logAlerts, repo, logrusLogger),
+		cfg.Safety.Monitoring.ReAlertInterval,
+	)
 
 	// Convert safety config to safety.Config
 	safetyConfig := safety.Config{
-		CheckInterval:       1 * time.Second,
+		CheckInterval:       cfg.Safety.Monitoring.CheckInterval,
 		AlertThreshold:      85.0,
 		EmergencyThreshold:  95.0,
 		AutoStopEnabled:     true,
@@ -56,27 +92,289 @@ func NewOrchestrator(cfg *config.Config, db *database.Database, pluginMgr *plugi
 		RampUpSteps:         10,
 		CooldownPeriod:      60 * time.Second,
 		MaxViolationsPerMin: 5,
+		RampDownEnabled:     cfg.Safety.RampDown.Enabled,
+		RampDownDuration:    cfg.Safety.RampDown.Duration,
+		PostTestCooldown:    cfg.Safety.RampDown.Cooldown,
+		EpisodeGap:          cfg.Safety.Monitoring.EpisodeGap,
+		ReAlertInterval:     cfg.Safety.Monitoring.ReAlertInterval,
 	}
 
 	// Initialize safety monitor with correct arguments
 	safetyMonitor := safety.NewMonitor(systemMonitor, alertManager, safetyConfig, logrusLogger)
+	var customChecks []*safety.CustomCheck
+	for _, definition := range cfg.Safety.CustomChecks {
+		check, err := safety.NewCustomCheck(safety.CustomCheckDefinition{
+			Name:       definition.Name,
+			Kind:       definition.Type,
+			Command:    definition.Command,
+			Expression: definition.Expression,
+			URL:        definition.URL,
+			Headers:    definition.Headers,
+			Timeout:    definition.Timeout,
+			Critical:   definition.Critical,
+		})
+		if err != nil {
+			logger.Error("Failed to configure custom safety check", zap.String("name", definition.Name), zap.Error(err))
+			continue
+		}
+		customChecks = append(customChecks, check)
+	}
+	safetyMonitor.SetCustomChecks(customChecks)
 
 	// Initialize metrics collector with correct arguments
 	metricsCollector := metrics.NewCollector(logger)
+	metricsCollector.SetResourceLimits(limits)
+	if cfg.Metrics.HasOutput(config.MetricsOutputInfluxDB) {
+		metricsCollector.AddSink(influxDB)
+	}
+
+	// Ship system and plugin metrics to Prometheus remote-write targets
+	var remoteWriters []*remotewrite.Writer
+	if cfg.Metrics.HasOutput(config.MetricsOutputRemoteWrite) {
+		for _, target := range cfg.Metrics.RemoteWrite {
+			writer, err := remotewrite.NewWriter(target, cfg.Metrics.BatchSize, cfg.Metrics.FlushInterval, logger)
+			if err != nil {
+				logger.Error("Failed to configure remote write target", zap.String("url", target.URL), zap.Error(err))
+				continue
+			}
+			metricsCollector.AddSystemSink(writer)
+			remoteWriters = append(remoteWriters, writer)
+			logger.Info("Shipping metrics with remote write", zap.String("target", writer.Name()))
+		}
+	}
+
+	// Sample system metrics in the background so the API can serve them; Cleanup stops it
+	if err := metricsCollector.Start(context.Background()); err != nil {
+		logger.Error("Failed to start metrics collector", zap.Error(err))
+	}
 
 	// Initialize test orchestrator with correct arguments
 	testOrchestrator := NewTestOrchestrator(pluginMgr, safetyMonitor, metricsCollector, logrusLogger)
 
-	return &Orchestrator{
+	// Stop executions that outlive their wall-clock budget, even if the plugin ignores cancellation
+	testOrchestrator.SetWatchdog(WatchdogSettings{
+		Enabled:     cfg.Safety.Watchdog.Enabled,
+		MaxDuration: cfg.Safety.GlobalLimits.MaxTestDuration,
+		Grace:       cfg.Safety.Watchdog.Grace,
+		KillTimeout: cfg.Safety.Watchdog.KillTimeout,
+	})
+
+	// Soak runs checkpoint their progress so they survive a restart
+	testOrchestrator.SetSoak(SoakSettings{
+		Enabled:            cfg.Safety.Soak.Enabled,
+		MaxDuration:        cfg.Safety.Soak.MaxDuration,
+		CheckpointInterval: cfg.Safety.Soak.CheckpointInterval,
+		Resume:             cfg.Safety.Soak.ResumeOnStartup,
+	})
+
+	// Check the machine isn't already loaded before starting a test
+	testOrchestrator.SetPreflight(PreflightSettings{
+		Enabled: cfg.Safety.Preflight.Enabled,
+		Thresholds: safety.PreflightThresholds{
+			MaxCPUPercent:    cfg.Safety.Preflight.MaxCPUPercent,
+			MaxMemoryPercent: cfg.Safety.Preflight.MaxMemoryPercent,
+			MaxDiskPercent:   cfg.Safety.Preflight.MaxDiskPercent,
+			MaxTemperature:   cfg.Safety.Preflight.MaxTemperature,
+		},
+		Queue:         cfg.Safety.Preflight.Queue,
+		QueueTimeout:  cfg.Safety.Preflight.QueueTimeout,
+		RetryInterval: cfg.Safety.Preflight.RetryInterval,
+	})
+
+	// Don't run executions that would corrupt or starve each other side by side
+	testOrchestrator.SetConflicts(ConflictSettings{
+		Enabled: cfg.Safety.Conflicts.Enabled,
+		Queue:   cfg.Safety.Conflicts.Queue,
+	})
+
+	// Measure co-located services while tests load the machine
+	testOrchestrator.SetProbes(ProbeSettings{
+		Enabled:         cfg.Probes.Enabled,
+		BaselineSamples: cfg.Probes.BaselineSamples,
+		AllowCommands:   cfg.Probes.AllowCommands,
+	})
+
+	// Flag hardware errors the kernel reports while tests run
+	testOrchestrator.SetKernelLog(KernelLogSettings{Enabled: cfg.Safety.KernelLog.Enabled})
+
+	// Keep runs' test files from filling the disk
+	diskGuard := cfg.Safety.DiskGuard
+	testOrchestrator.SetDiskGuard(DiskGuardSettings{
+		Enabled:        diskGuard.Enabled,
+		Quota:          diskGuard.QuotaMB * 1024 * 1024,
+		MinFreePercent: diskGuard.MinFreePercent,
+		MinFreeBytes:   diskGuard.MinFreeMB * 1024 * 1024,
+		Interval:       diskGuard.CheckInterval,
+	})
+
+	// Capture diagnostics bundles for executions that fail
+	testOrchestrator.SetDiagnostics(DiagnosticsSettings{
+		Enabled:        cfg.Diagnostics.Enabled,
+		Directory:      cfg.Diagnostics.Directory,
+		MetricPoints:   cfg.Diagnostics.MetricPoints,
+		KernelLogLines: cfg.Diagnostics.KernelLogLines,
+	})
+
+	// Watch running executions' throughput and latency metrics for anomalies
+	anomalies := cfg.Metrics.Anomalies
+	testOrchestrator.SetAnomalies(AnomalySettings{
+		Enabled:          anomalies.Enabled,
+		Threshold:        anomalies.Threshold,
+		Alpha:            anomalies.Alpha,
+		Warmup:           anomalies.Warmup,
+		MinChangePercent: anomalies.MinChangePercent,
+		Cooldown:         anomalies.Cooldown,
+	})
+	metricsCollector.AddSink(anomalySink{orchestrator: testOrchestrator})
+
+	// Report running executions' progress for the API to broadcast
+	testOrchestrator.SetProgressInterval(cfg.Server.WebSocket.ProgressInterval)
+
+	orchestrator := &Orchestrator{
 		config:           cfg,
-		db:               db,
+		repo:             repo,
 		influxDB:         influxDB,
 		pluginManager:    pluginMgr,
 		safetyMonitor:    safetyMonitor,
 		metricsCollector: metricsCollector,
+		remoteWriters:    remoteWriters,
 		testOrchestrator: testOrchestrator,
+		notifier:         notifier,
 		logger:           logger,
 	}
+
+	// Keep only recent metric points in memory, offloading older ones to InfluxDB
+	testOrchestrator.SetMetricBuffer(MetricBufferSettings{
+		Size:  cfg.Metrics.BufferSize,
+		Store: influxDB,
+	})
+
+	// Send finished executions' results to external systems
+	if cfg.Publish.Enabled && len(cfg.Publish.Sinks) > 0 {
+		publisher, err := publish.NewPublisher(cfg.Publish, logger)
+		if err != nil {
+			logger.Error("Failed to configure result publishing", zap.Error(err))
+		} else {
+			orchestrator.publisher = publisher
+			logger.Info("Publishing execution results", zap.Strings("sinks", publisher.Sinks()))
+		}
+	}
+
+	// Persist executions with their summaries once they finish, so list views and
+	// comparisons can use them without re-querying InfluxDB. Results are graded
+	// against the matching hardware profile first, and the bytes they wrote are
+	// added to each device's endurance total, and the execution awaits review. The
+	// final result is then published, and users who asked are notified.
+	testOrchestrator.OnExecutionComplete(func(execution models.TestExecution) {
+		if repo != nil {
+			execution.ReviewStatus = models.ReviewPending
+			orchestrator.gradeFinishedExecution(&execution, alertManager)
+			orchestrator.trackDeviceWrites(&execution, alertManager)
+			if err := repo.UpdateTestExecution(&execution); err != nil {
+				logger.Error("Failed to persist execution", zap.String("execution_id", execution.ID), zap.Error(err))
+			}
+		}
+		orchestrator.publishResult(execution)
+		orchestrator.notifyExecution(execution)
+	})
+	testOrchestrator.OnAnomaly(func(anomaly models.Anomaly) {
+		orchestrator.recordAnomaly(anomaly, alertManager)
+	})
+	if repo != nil {
+		testOrchestrator.OnCheckpoint(func(execution models.TestExecution) {
+			if err := repo.UpdateTestExecution(&execution); err != nil {
+				logger.Error("Failed to checkpoint execution", zap.String("execution_id", execution.ID), zap.Error(err))
+			}
+		})
+		// Keep every violation, where the safety monitor only holds the last hour's
+		testOrchestrator.OnViolation(func(violation models.Violation) {
+			if err := repo.CreateViolation(&violation); err != nil {
+				logger.Warn("Failed to persist safety violation", zap.String("execution_id", violation.ExecutionID), zap.Error(err))
+			}
+		})
+	}
+
+	// Run external plugins as the sandbox user; if that can't be set up they
+	// don't run at all
+	if sandbox := cfg.Plugins.Sandbox; sandbox.Enabled {
+		orchestrator.sandbox, orchestrator.sandboxErr = plugins.ResolveSandbox(sandbox.User, sandbox.Group, sandbox.Mode, sandbox.Seccomp)
+		if orchestrator.sandboxErr != nil {
+			logger.Error("External plugins won't run: failed to set up the plugin sandbox", zap.Error(orchestrator.sandboxErr))
+		}
+	}
+
+	// Load external plugins and persisted enabled/disabled state
+	if err := orchestrator.SyncPlugins(); err != nil {
+		logger.Error("Failed to sync plugins", zap.Error(err))
+	}
+
+	// Load plugins from manifests in the plugin directories, and follow changes to them
+	if external := cfg.Plugins.External; external.Enabled && external.Watch {
+		watcher := newPluginWatcher(orchestrator, external.AllowedDirs, external.WatchDebounce)
+		watcher.scan()
+		if err := watcher.start(); err != nil {
+			logger.Error("Failed to watch plugin directories", zap.Error(err))
+		} else {
+			orchestrator.pluginWatcher = watcher
+		}
+	}
+
+	// Pick up soak runs the previous server process didn't finish
+	if repo != nil {
+		orchestrator.resumeSoakExecutions()
+	}
+
+	return orchestrator
+}
+
+// resumeSoakExecutions resumes soak executions interrupted by a restart from their
+// last checkpoint. Those that can't be resumed are closed with the progress their
+// last checkpoint recorded rather than left running forever.
+func (o *Orchestrator) resumeSoakExecutions() {
+	executions, err := o.repo.ListUnfinishedSoakExecutions()
+	if err != nil {
+		o.logger.Error("Failed to list interrupted soak executions", zap.Error(err))
+		return
+	}
+
+	for _, execution := range executions {
+		err := errors.New("resuming soak executions is disabled")
+		if o.config.Safety.Soak.Enabled && o.config.Safety.Soak.ResumeOnStartup {
+			var test *models.TestConfiguration
+			test, err = o.repo.GetTestConfiguration(execution.TestID)
+			if err == nil {
+				err = o.testOrchestrator.ResumeTest(*test, execution)
+			}
+			if err == nil {
+				o.logger.Info("Resumed soak execution", zap.String("execution_id", execution.ID))
+				continue
+			}
+		}
+
+		if errors.Is(err, ErrSoakFinished) {
+			execution.Status = models.StatusCompleted
+		} else {
+			execution.Status = models.StatusFailed
+			message := "Interrupted by a server restart and not resumed: " + err.Error()
+			execution.ErrorMessage = &message
+		}
+		execution.ReviewStatus = models.ReviewPending
+		end := time.Now()
+		if execution.CheckpointedAt != nil {
+			end = *execution.CheckpointedAt
+		}
+		execution.EndTime = &end
+		if execution.StartTime != nil {
+			execution.Duration = end.Sub(*execution.StartTime)
+		}
+		if err := o.repo.UpdateTestExecution(&execution); err != nil {
+			o.logger.Error("Failed to close interrupted soak execution", zap.String("execution_id", execution.ID), zap.Error(err))
+			continue
+		}
+		o.logger.Warn("Closed interrupted soak execution",
+			zap.String("execution_id", execution.ID),
+			zap.String("status", string(execution.Status)))
+	}
 }
 
 // ExecuteTestFromFile executes a test from a configuration file
@@ -127,8 +425,11 @@ func (o *Orchestrator) ExecuteTestFromFile(ctx context.Context, configPath strin
 		}
 	}
 
+	// Apply the configured cooldown so the wait below accounts for it
+	o.testOrchestrator.applyRampDownDefaults(&params, false)
+
 	// Start test execution
-	executionID, err := o.testOrchestrator.StartTest(testConfig, params)
+	executionID, err := o.StartTest(testConfig, params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start test: %w", err)
 	}
@@ -140,24 +441,32 @@ func (o *Orchestrator) ExecuteTestFromFile(ctx context.Context, configPath strin
 	)
 
 	// Wait for test completion
-	return o.waitForTestCompletion(ctx, executionID, params.Duration)
+	return o.waitForTestCompletion(ctx, executionID, params.Duration+params.Cooldown)
 }
 
-// waitForTestCompletion waits for a test to complete and returns the result
+// waitForTestCompletion waits for a test to complete and returns the result. A
+// test still running at the deadline, pushed back by as much as the run was
+// extended, is stopped, and the partial result of what ran until then is
+// returned with the error.
 func (o *Orchestrator) waitForTestCompletion(ctx context.Context, executionID string, maxDuration time.Duration) (*models.TestResult, error) {
-	// Create a timeout context
-	timeoutCtx, cancel := context.WithTimeout(ctx, maxDuration+30*time.Second)
-	defer cancel()
+	deadline := time.Now().Add(maxDuration + 30*time.Second)
+
+	// timeout emergency stops the test, giving it a moment to record what it collected
+	timeout := func() (*models.TestResult, error) {
+		o.testOrchestrator.EmergencyStop(executionID, "Test execution timeout")
+		if execution := o.awaitSummary(executionID, partialResultWait); execution != nil {
+			return o.testResult(execution), fmt.Errorf("test execution timeout")
+		}
+		return nil, fmt.Errorf("test execution timeout")
+	}
 
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-timeoutCtx.Done():
-			// Emergency stop the test
-			o.testOrchestrator.EmergencyStop(executionID, "Test execution timeout")
-			return nil, fmt.Errorf("test execution timeout")
+		case <-ctx.Done():
+			return timeout()
 
 		case <-ticker.C:
 			execution, err := o.testOrchestrator.GetTestStatus(executionID)
@@ -165,56 +474,115 @@ func (o *Orchestrator) waitForTestCompletion(ctx context.Context, executionID st
 				return nil, fmt.Errorf("failed to get test status: %w", err)
 			}
 
-			// Check if test is complete
-			if execution.Status == models.StatusCompleted ||
-				execution.Status == models.StatusFailed ||
-				execution.Status == models.StatusStopped {
-
-				// Get test metrics
-				metrics, err := o.testOrchestrator.GetTestMetrics(executionID)
-				if err != nil {
-					o.logger.Warn("Failed to get test metrics", zap.Error(err))
-					metrics = []models.MetricPoint{}
-				}
-
-				// Calculate test score and determine if passed
-				score := o.calculateTestScore(execution, metrics)
-				passed := execution.Status == models.StatusCompleted && score >= 70.0
-
-				result := &models.TestResult{
-					TestID:   execution.TestID,
-					Status:   execution.Status,
-					Duration: execution.Duration,
-					Metrics:  metrics,
-					Score:    score,
-					Passed:   passed,
-				}
-
-				if execution.ErrorMessage != nil {
-					result.Errors = []string{*execution.ErrorMessage}
-				}
+			// Check if test is complete, with its summary computed
+			if finished(execution) {
+				result := o.testResult(execution)
 
 				o.logger.Info("Test execution completed",
 					zap.String("execution_id", executionID),
 					zap.String("status", string(execution.Status)),
-					zap.Float64("score", score),
-					zap.Bool("passed", passed),
+					zap.Float64("score", result.Score),
+					zap.Bool("passed", result.Passed),
 				)
 
 				return result, nil
 			}
+			if time.Now().After(deadline.Add(execution.Extended)) {
+				return timeout()
+			}
+		}
+	}
+}
+
+// partialResultWait is how long a test stopped at its deadline is given to
+// summarize the metrics it collected
+const partialResultWait = 10 * time.Second
+
+// finished reports whether an execution has ended and its summary been computed
+func finished(execution *models.TestExecution) bool {
+	switch execution.Status {
+	case models.StatusCompleted, models.StatusFailed, models.StatusStopped, models.StatusTimedOut:
+		return len(execution.Summary) > 0
+	}
+	return false
+}
+
+// awaitSummary waits up to timeout for an execution to finish, returning nil if
+// it doesn't
+func (o *Orchestrator) awaitSummary(executionID string, timeout time.Duration) *models.TestExecution {
+	deadline := time.Now().Add(timeout)
+	for {
+		execution, err := o.testOrchestrator.GetTestStatus(executionID)
+		if err != nil {
+			return nil
+		}
+		if finished(execution) {
+			return execution
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// testResult scores a finished execution and aggregates its result. Executions of
+// tests with an SLA pass when they complete and meet it, whatever their score.
+// Runs that failed or were stopped get a partial result: their score is scaled
+// by how much of the planned run time they covered.
+func (o *Orchestrator) testResult(execution *models.TestExecution) *models.TestResult {
+	// Get test metrics
+	metrics, err := o.testOrchestrator.GetTestMetrics(execution.ID, models.TimeRange{})
+	if err != nil {
+		o.logger.Warn("Failed to get test metrics", zap.Error(err))
+		metrics = []models.MetricPoint{}
+	}
+
+	// Calculate test score and determine if passed
+	score := calculateTestScore(execution.Status, metrics)
+	passed := execution.Status == models.StatusCompleted && score >= 70.0
+
+	result := &models.TestResult{
+		TestID:      execution.TestID,
+		ExecutionID: execution.ID,
+		Status:      execution.Status,
+		Duration:    execution.Duration,
+		Metrics:     metrics,
+		Score:       score,
+		Passed:      passed,
+	}
+
+	if execution.ErrorMessage != nil {
+		result.Errors = []string{*execution.ErrorMessage}
+	}
+
+	var summary models.ExecutionSummary
+	if len(execution.Summary) > 0 && json.Unmarshal(execution.Summary, &summary) == nil {
+		result.Energy = summary.Energy
+		// A test's SLA decides whether its runs pass, in place of the score
+		if summary.SLA != nil {
+			result.Passed = execution.Status == models.StatusCompleted && summary.SLA.Passed
+			result.Errors = append(result.Errors, summary.SLA.Failures()...)
+		}
+		if partial := summary.Partial; partial != nil {
+			result.Partial = true
+			result.FailureReason = partial.Reason
+			result.Completion = partial.Completion
+			result.Score = score * partial.Completion
 		}
 	}
+
+	return result
 }
 
-// calculateTestScore calculates a test score based on execution and metrics
-func (o *Orchestrator) calculateTestScore(execution *models.TestExecution, metrics []models.MetricPoint) float64 {
+// calculateTestScore calculates a test score based on how an execution finished and its metrics
+func calculateTestScore(status models.ExecutionStatus, metrics []models.MetricPoint) float64 {
 	baseScore := 100.0
 
 	// Deduct points for failures
-	if execution.Status == models.StatusFailed {
+	if status == models.StatusFailed || status == models.StatusTimedOut {
 		baseScore -= 50.0
-	} else if execution.Status == models.StatusStopped {
+	} else if status == models.StatusStopped {
 		baseScore -= 25.0
 	}
 
@@ -237,8 +605,11 @@ func (o *Orchestrator) calculateTestScore(execution *models.TestExecution, metri
 	return baseScore * performanceScore
 }
 
-// StartTest starts a new test execution
+// StartTest starts a new test execution, provided the test's prerequisites are met
 func (o *Orchestrator) StartTest(config models.TestConfiguration, params models.TestParams) (string, error) {
+	if err := o.CheckPrerequisites(config); err != nil {
+		return "", err
+	}
 	return o.testOrchestrator.StartTest(config, params)
 }
 
@@ -247,6 +618,85 @@ func (o *Orchestrator) StopTest(executionID string) error {
 	return o.testOrchestrator.StopTest(executionID)
 }
 
+// GetQueue returns the queued runs and the executions holding the machine
+func (o *Orchestrator) GetQueue() models.QueueState {
+	return o.testOrchestrator.GetQueue()
+}
+
+// MoveQueued moves a queued execution to a position in the queue
+func (o *Orchestrator) MoveQueued(executionID string, position int) error {
+	return o.testOrchestrator.MoveQueued(executionID, position)
+}
+
+// CancelQueued cancels an execution waiting in the queue
+func (o *Orchestrator) CancelQueued(executionID string) error {
+	return o.testOrchestrator.CancelQueued(executionID)
+}
+
+// ExtendTest lengthens a running execution's run by d, up to limit if positive
+// as well as the configured maximum
+func (o *Orchestrator) ExtendTest(executionID string, d, limit time.Duration) (*models.TestExecution, error) {
+	return o.testOrchestrator.ExtendTest(executionID, d, limit)
+}
+
+// PauseTest holds the load of a running execution without ending it
+func (o *Orchestrator) PauseTest(executionID string) (*models.TestExecution, error) {
+	return o.testOrchestrator.PauseTest(executionID)
+}
+
+// UnpauseTest takes up the load of a paused execution again
+func (o *Orchestrator) UnpauseTest(executionID string) (*models.TestExecution, error) {
+	return o.testOrchestrator.UnpauseTest(executionID)
+}
+
+// OnProgress registers a function called with every running execution, including
+// its progress, at the configured progress interval
+func (o *Orchestrator) OnProgress(fn func(models.TestExecution)) {
+	o.testOrchestrator.OnProgress(fn)
+}
+
+// AddMetricSink registers a sink for metrics streamed by plugins during executions
+func (o *Orchestrator) AddMetricSink(sink metrics.MetricSink) {
+	o.metricsCollector.AddSink(sink)
+}
+
+// MetricSinkHealth reports how shipping metrics to each remote-write target is going
+func (o *Orchestrator) MetricSinkHealth() []remotewrite.Health {
+	health := make([]remotewrite.Health, 0, len(o.remoteWriters))
+	for _, writer := range o.remoteWriters {
+		health = append(health, writer.Health())
+	}
+	return health
+}
+
+// IngestMetrics feeds points collected outside this server, such as by remote
+// agents, into the same sinks as plugin metrics. It returns the number of sink
+// writes that failed.
+func (o *Orchestrator) IngestMetrics(points []models.MetricPoint) int {
+	return o.metricsCollector.WritePoints(points)
+}
+
+// BeginDrain stops the orchestrator from accepting new tests
+func (o *Orchestrator) BeginDrain() {
+	o.testOrchestrator.BeginDrain()
+}
+
+// IsDraining reports whether the orchestrator is draining for shutdown
+func (o *Orchestrator) IsDraining() bool {
+	return o.testOrchestrator.IsDraining()
+}
+
+// Drain waits for or stops running executions according to the shutdown config,
+// returning the number of executions that had to be stopped
+func (o *Orchestrator) Drain(ctx context.Context) int {
+	shutdown := o.config.Server.Shutdown
+
+	drainCtx, cancel := context.WithTimeout(ctx, shutdown.DrainTimeout)
+	defer cancel()
+
+	return o.testOrchestrator.Drain(drainCtx, shutdown.StopRunning, shutdown.StopTimeout)
+}
+
 // GetTestStatus returns the status of a test execution
 func (o *Orchestrator) GetTestStatus(executionID string) (*models.TestExecution, error) {
 	return o.testOrchestrator.GetTestStatus(executionID)
@@ -257,9 +707,95 @@ func (o *Orchestrator) ListExecutions() []models.TestExecution {
 	return o.testOrchestrator.ListExecutions()
 }
 
-// GetTestMetrics returns metrics for a test execution
-func (o *Orchestrator) GetTestMetrics(executionID string) ([]models.MetricPoint, error) {
-	return o.testOrchestrator.GetTestMetrics(executionID)
+// ListExecutionsByTest returns a page of the executions of a test, newest first,
+// from those the orchestrator is tracking and those stored in the repository.
+// A limit of 0 returns them all.
+func (o *Orchestrator) ListExecutionsByTest(testID string, limit, offset int) ([]models.TestExecution, error) {
+	executions := o.testOrchestrator.ListExecutionsByTest(testID)
+	if o.repo != nil {
+		// The newest limit+offset stored executions are enough to fill the page
+		storedLimit := 0
+		if limit > 0 {
+			storedLimit = limit + offset
+		}
+		stored, err := o.repo.ListExecutionsByTest(testID, storedLimit, 0)
+		if err != nil {
+			return nil, err
+		}
+		tracked := make(map[string]bool, len(executions))
+		for _, execution := range executions {
+			tracked[execution.ID] = true
+		}
+		for _, execution := range stored {
+			if !tracked[execution.ID] {
+				executions = append(executions, execution)
+			}
+		}
+		sort.SliceStable(executions, func(i, j int) bool { return executions[i].Created.After(executions[j].Created) })
+	}
+
+	if offset >= len(executions) {
+		return []models.TestExecution{}, nil
+	}
+	executions = executions[offset:]
+	if limit > 0 && limit < len(executions) {
+		executions = executions[:limit]
+	}
+	return executions, nil
+}
+
+// GetLatestExecutionForTest returns the newest execution of a test with one of the
+// statuses, or with any status when none are given, whether the orchestrator is
+// still tracking it or it was only stored. It returns ErrExecutionNotFound if
+// there is none.
+func (o *Orchestrator) GetLatestExecutionForTest(testID string, statuses ...models.ExecutionStatus) (*models.TestExecution, error) {
+	latest, err := o.testOrchestrator.GetLatestExecutionForTest(testID, statuses...)
+	if err != nil && !errors.Is(err, ErrExecutionNotFound) {
+		return nil, err
+	}
+	if o.repo == nil {
+		return latest, err
+	}
+
+	stored, storedErr := o.repo.GetLatestExecutionForTest(testID, statuses...)
+	switch {
+	case errors.Is(storedErr, database.ErrNotFound):
+		return latest, err
+	case storedErr != nil:
+		return nil, storedErr
+	case latest == nil || stored.Created.After(latest.Created):
+		return stored, nil
+	}
+	return latest, nil
+}
+
+// GetRunningExecutionForTest returns the newest queued, pending or running
+// execution of a test. Only executions the orchestrator is tracking can be
+// running, so the repository isn't consulted.
+func (o *Orchestrator) GetRunningExecutionForTest(testID string) (*models.TestExecution, error) {
+	return o.testOrchestrator.GetRunningExecutionForTest(testID)
+}
+
+// GetTestMetrics returns metrics for a test execution within the time range; a zero
+// start or end leaves that side open
+func (o *Orchestrator) GetTestMetrics(executionID string, timeRange models.TimeRange) ([]models.MetricPoint, error) {
+	return o.testOrchestrator.GetTestMetrics(executionID, timeRange)
+}
+
+// GetPluginMetrics returns the metrics reported by the plugin instance running an execution
+func (o *Orchestrator) GetPluginMetrics(executionID string) (map[string]interface{}, error) {
+	return o.testOrchestrator.GetPluginMetrics(executionID)
+}
+
+// DiagnosticsPath returns the path of a failed execution's diagnostics bundle
+// by the file name recorded in the execution
+func (o *Orchestrator) DiagnosticsPath(name string) (string, error) {
+	return o.testOrchestrator.DiagnosticsPath(name)
+}
+
+// GetSystemMetrics returns the most recent system metrics sample
+func (o *Orchestrator) GetSystemMetrics() models.SystemMetrics {
+	return o.metricsCollector.CollectSystemMetrics()
 }
 
 // GetPluginManager returns the plugin manager
@@ -276,7 +812,7 @@ func (o *Orchestrator) GetSystemHealth() map[string]interface{} {
 	}
 
 	// Check database health
-	if err := o.db.HealthCheck(); err != nil {
+	if err := o.repo.HealthCheck(); err != nil {
 		health["components"].(map[string]interface{})["database"] = map[string]interface{}{
 			"status": "unhealthy",
 			"error":  err.Error(),
@@ -324,19 +860,41 @@ func (o *Orchestrator) GetSystemHealth() map[string]interface{} {
 func (o *Orchestrator) Cleanup() error {
 	o.logger.Info("Starting orchestrator cleanup")
 
+	// Stop following plugin directories
+	if o.pluginWatcher != nil {
+		o.pluginWatcher.stop()
+	}
+
 	// Cleanup metrics collector
 	if o.metricsCollector != nil {
 		o.metricsCollector.Stop()
 	}
 
+	// Send metrics still queued for remote-write targets
+	for _, writer := range o.remoteWriters {
+		if !writer.Close(o.config.Metrics.FlushInterval) {
+			o.logger.Warn("Stopped before all metrics were shipped", zap.String("target", writer.Name()))
+		}
+	}
+
+	// Give results still being published a chance to be sent
+	if o.publisher != nil && !o.publisher.Close(o.config.Publish.Timeout) {
+		o.logger.Warn("Stopped before all execution results were published")
+	}
+
+	// Give notifications still being sent a chance to be delivered
+	if o.notifier != nil && !o.notifier.Close(o.config.Notifications.Timeout) {
+		o.logger.Warn("Stopped before all notifications were sent")
+	}
+
 	// Close InfluxDB
 	if o.influxDB != nil {
 		o.influxDB.Close()
 	}
 
 	// Close database
-	if o.db != nil {
-		if err := o.db.Close(); err != nil {
+	if o.repo != nil {
+		if err := o.repo.Close(); err != nil {
 			o.logger.Error("Failed to close database", zap.Error(err))
 			return err
 		}
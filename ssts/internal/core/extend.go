@@ -0,0 +1,185 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrNotExtendable is returned by ExtendTest for executions whose run can't be
+// lengthened: those not running their workload, and those of plugins that end
+// their runs by their own clock
+var ErrNotExtendable = errors.New("execution can't be extended")
+
+// runDeadline is the context a plugin runs under: it ends when its parent does,
+// or with context.DeadlineExceeded once its deadline passes, and its deadline
+// can be pushed back until then
+type runDeadline struct {
+	context.Context
+	done     chan struct{}
+	mu       sync.Mutex
+	deadline time.Time
+	pausedAt time.Time // set while paused, when the deadline's clock stands still
+	err      error
+	timer    *time.Timer
+}
+
+// newRunDeadline returns a run context that ends after d, and the function that
+// releases it
+func newRunDeadline(parent context.Context, d time.Duration) (*runDeadline, context.CancelFunc) {
+	ctx := &runDeadline{
+		Context:  parent,
+		done:     make(chan struct{}),
+		deadline: time.Now().Add(d),
+	}
+	ctx.mu.Lock()
+	ctx.timer = time.AfterFunc(d, ctx.expire)
+	ctx.mu.Unlock()
+
+	go func() {
+		select {
+		case <-parent.Done():
+			ctx.end(parent.Err())
+		case <-ctx.done:
+		}
+	}()
+	return ctx, func() { ctx.end(context.Canceled) }
+}
+
+// Deadline returns the current deadline
+func (ctx *runDeadline) Deadline() (time.Time, bool) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.deadline, true
+}
+
+func (ctx *runDeadline) Done() <-chan struct{} {
+	return ctx.done
+}
+
+func (ctx *runDeadline) Err() error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.err
+}
+
+// extend pushes the deadline back by d. It fails once the context has ended.
+func (ctx *runDeadline) extend(d time.Duration) bool {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.err != nil {
+		return false
+	}
+	ctx.deadline = ctx.deadline.Add(d)
+	ctx.timer.Reset(time.Until(ctx.deadline))
+	return true
+}
+
+// expire ends the context if its deadline has passed, and otherwise waits for
+// the deadline it was pushed back to. Paused contexts wait to be resumed.
+func (ctx *runDeadline) expire() {
+	ctx.mu.Lock()
+	if !ctx.pausedAt.IsZero() {
+		ctx.mu.Unlock()
+		return
+	}
+	if remaining := time.Until(ctx.deadline); remaining > 0 {
+		ctx.timer.Reset(remaining)
+		ctx.mu.Unlock()
+		return
+	}
+	ctx.mu.Unlock()
+	ctx.end(context.DeadlineExceeded)
+}
+
+func (ctx *runDeadline) end(err error) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.err != nil {
+		return
+	}
+	ctx.err = err
+	ctx.timer.Stop()
+	close(ctx.done)
+}
+
+// ExtendTest lengthens the run of a running execution by d: its plugin runs that
+// much longer and the watchdog's budget grows with it. The extended duration is
+// held to the same maximum as a new run's, the soak maximum for soak runs, and
+// to limit if that is positive, e.g. the caller's role maximum. Soak runs are
+// checkpointed right away so the extension survives a restart. It returns the
+// execution as extended.
+func (to *TestOrchestrator) ExtendTest(executionID string, d, limit time.Duration) (*models.TestExecution, error) {
+	if d <= 0 {
+		return nil, fmt.Errorf("extension must be positive, got %s", d)
+	}
+
+	to.mu.RLock()
+	execution, exists := to.executions[executionID]
+	to.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
+	}
+
+	execution.mu.RLock()
+	soakRun := execution.soak != nil
+	execution.mu.RUnlock()
+	maxDuration, err := to.maxDuration(soakRun)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && (maxDuration == 0 || limit < maxDuration) {
+		maxDuration = limit
+	}
+
+	execution.mu.Lock()
+	if execution.Status != models.StatusRunning || execution.run == nil || execution.cooldownStart != nil {
+		execution.mu.Unlock()
+		return nil, fmt.Errorf("%w: its workload isn't running", ErrNotExtendable)
+	}
+	if follower, ok := execution.plugin.(plugins.DeadlineFollower); !ok || !follower.FollowsDeadline() {
+		execution.mu.Unlock()
+		return nil, fmt.Errorf("%w: plugin %s ends its runs by its own clock", ErrNotExtendable, execution.Config.Plugin)
+	}
+
+	// Soak runs resumed after a restart are held to the total requested
+	duration := execution.params.Duration + d
+	if execution.soak != nil {
+		duration = execution.soak.params.Duration + d
+	}
+	if err := durationWithin(duration, maxDuration); err != nil {
+		execution.mu.Unlock()
+		return nil, err
+	}
+
+	if !execution.run.extend(d) {
+		execution.mu.Unlock()
+		return nil, fmt.Errorf("%w: its workload has finished", ErrNotExtendable)
+	}
+	execution.params.Duration += d
+	if execution.soak != nil {
+		execution.soak.params.Duration += d
+	}
+	execution.extended += d
+
+	to.logger.WithFields(logrus.Fields{
+		"execution_id": execution.ID,
+		"extension":    d,
+		"duration":     duration,
+	}).Info("Extended test execution")
+
+	result := execution.toModel()
+	execution.mu.Unlock()
+
+	if soakRun {
+		to.checkpoint(execution)
+	}
+	return &result, nil
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/metrics"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// followingPlugin runs until its context's deadline
+type followingPlugin struct{ idlePlugin }
+
+func (followingPlugin) Name() string          { return "following" }
+func (followingPlugin) FollowsDeadline() bool { return true }
+
+func TestExtendTest(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(idlePlugin{})
+	pluginMgr.RegisterPlugin(followingPlugin{})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	orchestrator.SetWatchdog(WatchdogSettings{Enabled: true, MaxDuration: 2 * time.Second, Grace: 100 * time.Millisecond, KillTimeout: time.Second})
+
+	completed := make(chan models.TestExecution, 2)
+	orchestrator.OnExecutionComplete(func(execution models.TestExecution) { completed <- execution })
+	start := func(plugin string) string {
+		t.Helper()
+		id, err := orchestrator.StartTest(models.TestConfiguration{ID: "test-" + plugin, Plugin: plugin}, models.TestParams{Duration: 300 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("StartTest: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		return id
+	}
+
+	id := start("following")
+	if _, err := orchestrator.ExtendTest(id, 5*time.Second, 0); !errors.Is(err, ErrDurationExceeded) {
+		t.Errorf("extending past the maximum: %v", err)
+	}
+	if _, err := orchestrator.ExtendTest(id, time.Second, 500*time.Millisecond); !errors.Is(err, ErrDurationExceeded) {
+		t.Errorf("extending past the caller's limit: %v", err)
+	}
+	extended, err := orchestrator.ExtendTest(id, 400*time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("ExtendTest: %v", err)
+	}
+	if extended.Extended != 400*time.Millisecond || extended.Progress == nil || extended.Progress.Remaining < 500*time.Millisecond {
+		t.Errorf("extended = %+v, progress %+v; want 400ms more to run", extended, extended.Progress)
+	}
+
+	// The run outlasts both its original duration and watchdog budget
+	started := time.Now()
+	var execution models.TestExecution
+	select {
+	case execution = <-completed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution did not complete")
+	}
+	if ran := time.Since(started); ran < 450*time.Millisecond {
+		t.Errorf("execution ended %s after being extended, want its extended duration", ran)
+	}
+	if execution.Status != models.StatusCompleted || execution.Extended != 400*time.Millisecond {
+		t.Errorf("execution = %s, extended %s; want completed and extended", execution.Status, execution.Extended)
+	}
+	if _, err := orchestrator.ExtendTest(id, time.Second, 0); !errors.Is(err, ErrNotExtendable) {
+		t.Errorf("extending a finished execution: %v", err)
+	}
+
+	// Plugins that time their runs themselves can't be extended
+	id = start("idle")
+	if _, err := orchestrator.ExtendTest(id, time.Second, 0); !errors.Is(err, ErrNotExtendable) {
+		t.Errorf("extending an idle execution: %v", err)
+	}
+	<-completed
+}
@@ -0,0 +1,69 @@
+//go:build failpoints
+
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/faults"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func newFailpointOrchestrator(t *testing.T) *Orchestrator {
+	t.Helper()
+	t.Cleanup(faults.Reset)
+
+	cfg := &config.Config{
+		InfluxDB: config.InfluxDBConfig{URL: "http://localhost:8086", Token: "test-token", Org: "test-org", Bucket: "test-bucket"},
+	}
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(idlePlugin{})
+
+	return NewOrchestrator(cfg, nil, pluginMgr, zap.NewNop())
+}
+
+func TestStartTestFailpoint(t *testing.T) {
+	o := newFailpointOrchestrator(t)
+	if err := faults.Enable(faults.OrchestratorStart, "return(rejected)"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := o.StartTest(models.TestConfiguration{ID: "test", Plugin: "idle"}, models.TestParams{Duration: time.Second})
+	if !errors.Is(err, faults.ErrInjected) {
+		t.Fatalf("StartTest error = %v, want ErrInjected", err)
+	}
+}
+
+func TestExecuteFailpointFailsExecution(t *testing.T) {
+	o := newFailpointOrchestrator(t)
+	if err := faults.Enable(faults.OrchestratorExecute, "return(plugin crashed)"); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := o.StartTest(models.TestConfiguration{ID: "test", Plugin: "idle"}, models.TestParams{Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		execution, err := o.GetTestStatus(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if execution.Status == models.StatusFailed {
+			return
+		}
+		if execution.Status != models.StatusRunning && execution.Status != models.StatusPending {
+			t.Fatalf("execution status = %s, want failed", execution.Status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("execution did not fail after the injected fault")
+}
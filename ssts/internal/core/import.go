@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ImportExecution stores a run made outside SSTS, such as by an external
+// benchmark tool, as a completed execution of the test. run gives its start
+// time, duration, labels, who imported it and the tool it came from. Its points
+// are buffered like those of runs the orchestrator made, so the run can be
+// queried and compared with them, and its summary is computed from them,
+// including the test's SLA. Completion handlers aren't called, as the run
+// didn't just finish. It returns the execution.
+func (to *TestOrchestrator) ImportExecution(config models.TestConfiguration, run models.TestExecution, points []models.MetricPoint) (models.TestExecution, error) {
+	if run.StartTime == nil || run.StartTime.IsZero() {
+		return models.TestExecution{}, fmt.Errorf("imported run has no start time")
+	}
+
+	// Imported executions have nothing left to run or wait for
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	close(done)
+
+	end := run.StartTime.Add(run.Duration)
+	execution := &TestExecution{
+		ID:           uuid.New().String(),
+		Config:       config,
+		Status:       models.StatusCompleted,
+		StartTime:    *run.StartTime,
+		EndTime:      &end,
+		Context:      ctx,
+		Cancel:       cancel,
+		Labels:       config.Labels.Merge(run.Labels),
+		StartedBy:    run.StartedBy,
+		metrics:      to.newMetricBuffer(),
+		done:         done,
+		completed:    true,
+		importedFrom: run.ImportedFrom,
+	}
+
+	for _, point := range points {
+		to.recordMetric(execution, importedPoint(point, config.ID, execution.ID))
+	}
+
+	execution.mu.Lock()
+	summary := execution.summarizeMetrics(run.Duration)
+	if !config.SLA.Empty() {
+		sla := EvaluateSLA(summary, config.SLA)
+		summary.SLA = &sla
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		execution.mu.Unlock()
+		return models.TestExecution{}, fmt.Errorf("failed to encode execution summary: %w", err)
+	}
+	execution.Summary = data
+	result := execution.toModel()
+	execution.mu.Unlock()
+
+	to.mu.Lock()
+	to.executions[execution.ID] = execution
+	if to.byTest[config.ID] == nil {
+		to.byTest[config.ID] = make(map[string]*TestExecution)
+	}
+	to.byTest[config.ID][execution.ID] = execution
+	to.mu.Unlock()
+
+	to.logger.WithFields(logrus.Fields{
+		"execution_id": execution.ID,
+		"test_id":      config.ID,
+		"tool":         run.ImportedFrom,
+		"points":       len(points),
+	}).Info("Imported test execution")
+
+	return result, nil
+}
+
+// ImportExecution stores a run made outside SSTS as a completed execution of
+// the test, persists it, and writes its points to InfluxDB so they can be
+// queried like those of other runs
+func (o *Orchestrator) ImportExecution(config models.TestConfiguration, run models.TestExecution, points []models.MetricPoint) (*models.TestExecution, error) {
+	execution, err := o.testOrchestrator.ImportExecution(config, run, points)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.repo != nil {
+		if err := o.repo.CreateTestExecution(&execution); err != nil {
+			return nil, fmt.Errorf("failed to store imported execution: %w", err)
+		}
+	}
+	if o.influxDB != nil {
+		for _, point := range points {
+			if err := o.influxDB.WriteMetricPoint(importedPoint(point, config.ID, execution.ID)); err != nil {
+				o.logger.Warn("Failed to write imported metric point", zap.String("execution_id", execution.ID), zap.Error(err))
+				break
+			}
+		}
+	}
+	return &execution, nil
+}
+
+// importedPoint attributes a point of an imported run to its test and execution
+func importedPoint(point models.MetricPoint, testID, executionID string) models.MetricPoint {
+	point.TestID = testID
+	tags := make(map[string]string, len(point.Tags)+1)
+	for key, value := range point.Tags {
+		tags[key] = value
+	}
+	tags["execution_id"] = executionID
+	point.Tags = tags
+	return point
+}
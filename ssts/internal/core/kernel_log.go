@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pranavgopavaram/ssts/internal/safety"
+)
+
+// KernelLogSettings configure watching the kernel log while executions run
+type KernelLogSettings struct {
+	Enabled bool
+}
+
+// SetKernelLog configures kernel log watching
+func (to *TestOrchestrator) SetKernelLog(settings KernelLogSettings) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.kernelLogSettings = settings
+}
+
+// openKernelLog opens the kernel log at its end. It is a variable so tests can
+// substitute one.
+var openKernelLog = safety.OpenKernelLog
+
+// watchKernelLog tails the kernel log until ctx is done, recording the machine
+// check, I/O, OOM kill and thermal events it reports as violations of the
+// execution. They don't stop the run: surfacing them is what a burn-in is for.
+func (to *TestOrchestrator) watchKernelLog(ctx context.Context, execution *TestExecution) {
+	to.mu.RLock()
+	settings := to.kernelLogSettings
+	to.mu.RUnlock()
+	if !settings.Enabled {
+		return
+	}
+
+	logger := to.logger.WithField("execution_id", execution.ID)
+	kmsg, err := openKernelLog()
+	if err != nil {
+		logger.WithError(err).Warn("Kernel log can't be read, kernel errors won't be flagged")
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		kmsg.Close()
+	}()
+
+	err = safety.WatchKernelLog(kmsg, func(violation safety.Violation) {
+		kind := strings.TrimPrefix(violation.Type, safety.KernelViolationPrefix)
+		to.noteViolation(execution, violation)
+		execution.mu.Lock()
+		if execution.kernelEvents == nil {
+			execution.kernelEvents = make(map[string]int)
+		}
+		execution.kernelEvents[kind]++
+		execution.mu.Unlock()
+
+		logger.WithFields(logrus.Fields{
+			"event":   kind,
+			"message": violation.Message,
+		}).Warn("Kernel reported an error during the test")
+	})
+	if err != nil && ctx.Err() == nil {
+		logger.WithError(err).Warn("Stopped watching the kernel log")
+	}
+}
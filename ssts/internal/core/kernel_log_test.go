@@ -0,0 +1,78 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/metrics"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestKernelLogEventsAreExecutionViolations(t *testing.T) {
+	kmsg, write := io.Pipe()
+	defer func(previous func() (io.ReadCloser, error)) { openKernelLog = previous }(openKernelLog)
+	openKernelLog = func() (io.ReadCloser, error) { return kmsg, nil }
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(idlePlugin{})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	orchestrator.SetKernelLog(KernelLogSettings{Enabled: true})
+
+	completed := make(chan models.TestExecution, 1)
+	orchestrator.OnExecutionComplete(func(execution models.TestExecution) { completed <- execution })
+	if _, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "idle"}, models.TestParams{Duration: 500 * time.Millisecond}); err != nil {
+		t.Fatalf("StartTest: %v", err)
+	}
+
+	// Writes block until the execution reads them
+	for _, record := range []string{
+		"2,1001,5000000,-;mce: [Hardware Error]: Machine check events logged",
+		" SUBSYSTEM=machinecheck",
+		"3,1002,5000100,-;blk_update_request: I/O error, dev sda, sector 2048 op 0x1:(WRITE) flags 0x0",
+		"6,1003,5000200,-;usb 1-1: new high-speed USB device number 2 using xhci_hcd",
+		"3,1004,5000300,-;Out of memory: Killed process 4242 (stress-ng) total-vm:1048576kB",
+		"4,1005,5000400,-;CPU3: Core temperature above threshold, cpu clock throttled (total events = 1)",
+		"4,1006,5000500,-;EDAC MC0: 1 CE memory read error on CPU_SrcID#0_Ha#0_Chan#1_DIMM#0",
+	} {
+		if _, err := io.WriteString(write, record+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var execution models.TestExecution
+	select {
+	case execution = <-completed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution did not complete")
+	}
+	var summary models.ExecutionSummary
+	if err := json.Unmarshal(execution.Summary, &summary); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{safety.KernelEventMCE: 2, safety.KernelEventIOError: 1, safety.KernelEventOOMKill: 1, safety.KernelEventThermal: 1}
+	if len(summary.KernelEvents) != len(want) {
+		t.Errorf("kernel events = %v, want %v", summary.KernelEvents, want)
+	}
+	for kind, count := range want {
+		if summary.KernelEvents[kind] != count {
+			t.Errorf("%s events = %d, want %d", kind, summary.KernelEvents[kind], count)
+		}
+	}
+	if summary.Violations != 5 || summary.CriticalViolations != 2 {
+		t.Errorf("violations = %d, %d critical; want every event, machine checks critical", summary.Violations, summary.CriticalViolations)
+	}
+	if execution.Status != models.StatusCompleted {
+		t.Errorf("status = %s, want the run to go on despite kernel errors", execution.Status)
+	}
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrLoadShapingUnsupported is returned by StartTest for a run asking for a load
+// profile with a plugin whose load can't be varied while it runs
+var ErrLoadShapingUnsupported = errors.New("plugin doesn't support load profiles")
+
+// ErrRampDownUnsupported is returned by StartTest for a run asking for a
+// ramp-down window with a plugin that can't lower its load as the run ends
+var ErrRampDownUnsupported = errors.New("plugin doesn't support ramp-down")
+
+// loadShapeInterval is how often the level of a run's load profile is applied
+var loadShapeInterval = 250 * time.Millisecond
+
+// checkLoadProfile verifies the plugin can follow the run's load profile
+func checkLoadProfile(plugin plugins.StressPlugin, profile *models.LoadProfile) error {
+	if profile.Empty() {
+		return nil
+	}
+	if _, ok := plugin.(plugins.LoadShaper); !ok {
+		return fmt.Errorf("%w: %s", ErrLoadShapingUnsupported, plugin.Name())
+	}
+	return nil
+}
+
+// checkRampDown verifies the plugin can lower its load over the run's ramp-down window
+func checkRampDown(plugin plugins.StressPlugin, rampDown time.Duration) error {
+	if rampDown <= 0 || rampsDown(plugin) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrRampDownUnsupported, plugin.Name())
+}
+
+// rampsDown reports whether the plugin lowers its load over a run's ramp-down window
+func rampsDown(plugin plugins.StressPlugin) bool {
+	rampDowner, ok := plugin.(plugins.RampDowner)
+	return ok && rampDowner.RampsDown()
+}
+
+// shapeLoad sets the plugin's load level from the run's load profile and polite
+// mode as the run goes on. The returned function stops shaping.
+func shapeLoad(execution *TestExecution) func() {
+	params := execution.params
+	shaper, ok := execution.plugin.(plugins.LoadShaper)
+	if (params.LoadProfile.Empty() && !params.Polite.Throttles()) || !ok {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(loadShapeInterval)
+		defer ticker.Stop()
+		for {
+			// Applied every time, as plugins reset their level when their run begins
+			execution.mu.RLock()
+			level := execution.loadLevel(time.Now())
+			execution.mu.RUnlock()
+			shaper.SetLoadLevel(level)
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// loadLevel returns the percentage of the run's intensity the workload runs at:
+// the level of its load profile, measured from the start of the workload and
+// leaving out time paused, cut to the polite mode throttle while interactive
+// users are present. The caller must hold execution.mu.
+func (execution *TestExecution) loadLevel(now time.Time) int {
+	params := execution.params
+	level := params.LoadProfile.Level(now.Sub(execution.runStart) - execution.pausedTime(now))
+	if execution.yielding && params.Polite.Throttles() {
+		level = level * params.Polite.Throttle() / 100
+	}
+	return level
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// defaultMetricBufferSize is how many metric points an execution keeps in memory when unset
+const defaultMetricBufferSize = 10000
+
+// metricStoreTimeout bounds reading offloaded points back from the metric store
+const metricStoreTimeout = 10 * time.Second
+
+// offloadedTag marks points the orchestrator moved from memory to the metric store,
+// so they can be told apart from those the metrics collector writes
+const offloadedTag = "offloaded"
+
+// MetricStore persists metric points evicted from an execution's in-memory buffer
+// and reads them back. *database.InfluxDB implements it.
+type MetricStore interface {
+	WriteMetricPoint(point models.MetricPoint) error
+	StreamTestMetrics(ctx context.Context, testID string, timeRange models.TimeRange, fn func(models.MetricPoint) error) error
+}
+
+// MetricBufferSettings bound the metric points an execution holds in memory
+type MetricBufferSettings struct {
+	Size  int         // points kept in memory per execution
+	Store MetricStore // receives older points once the buffer is full; nil drops them
+}
+
+// SetMetricBuffer configures the in-memory metric buffer of executions started afterwards
+func (to *TestOrchestrator) SetMetricBuffer(settings MetricBufferSettings) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.metricBuffer = settings
+}
+
+// metricBuffer is a ring buffer holding an execution's most recent metric points
+type metricBuffer struct {
+	points []models.MetricPoint
+	head   int // index of the oldest point
+	count  int
+}
+
+// newMetricBuffer returns an empty buffer holding up to size points
+func (to *TestOrchestrator) newMetricBuffer() *metricBuffer {
+	to.mu.RLock()
+	size := to.metricBuffer.Size
+	to.mu.RUnlock()
+	if size <= 0 {
+		size = defaultMetricBufferSize
+	}
+	return &metricBuffer{points: make([]models.MetricPoint, size)}
+}
+
+// push appends a point. When the buffer is full, the oldest half is evicted to make
+// room and returned in time order, so evictions are summarized and offloaded in
+// batches rather than a point at a time.
+func (b *metricBuffer) push(point models.MetricPoint) []models.MetricPoint {
+	var evicted []models.MetricPoint
+	if b.count == len(b.points) {
+		n := (b.count + 1) / 2
+		evicted = make([]models.MetricPoint, 0, n)
+		for i := 0; i < n; i++ {
+			index := (b.head + i) % len(b.points)
+			evicted = append(evicted, b.points[index])
+			b.points[index] = models.MetricPoint{}
+		}
+		b.head = (b.head + n) % len(b.points)
+		b.count -= n
+	}
+
+	b.points[(b.head+b.count)%len(b.points)] = point
+	b.count++
+	return evicted
+}
+
+// all returns a copy of the buffered points in time order
+func (b *metricBuffer) all() []models.MetricPoint {
+	points := make([]models.MetricPoint, 0, b.count)
+	for i := 0; i < b.count; i++ {
+		points = append(points, b.points[(b.head+i)%len(b.points)])
+	}
+	return points
+}
+
+// between returns a copy of the buffered points within the time range; a zero
+// start or end leaves that side open
+func (b *metricBuffer) between(timeRange models.TimeRange) []models.MetricPoint {
+	points := make([]models.MetricPoint, 0)
+	for i := 0; i < b.count; i++ {
+		point := b.points[(b.head+i)%len(b.points)]
+		if inTimeRange(point.Timestamp, timeRange) {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// reset empties the buffer
+func (b *metricBuffer) reset() {
+	for i := range b.points {
+		b.points[i] = models.MetricPoint{}
+	}
+	b.head = 0
+	b.count = 0
+}
+
+// inTimeRange reports whether t falls within the range; a zero start or end leaves that side open
+func inTimeRange(t time.Time, timeRange models.TimeRange) bool {
+	if !timeRange.Start.IsZero() && t.Before(timeRange.Start) {
+		return false
+	}
+	if !timeRange.End.IsZero() && t.After(timeRange.End) {
+		return false
+	}
+	return true
+}
+
+// recordMetric buffers a point for the execution. Points evicted from a full buffer
+// are folded into the execution's offloaded summary, so the final summary still
+// covers them, and written to the metric store.
+func (to *TestOrchestrator) recordMetric(execution *TestExecution, metric models.MetricPoint) {
+	execution.mu.Lock()
+	evicted := execution.metrics.push(metric)
+	if len(evicted) > 0 {
+		offloaded := mergeSummaries(execution.offloaded, SummarizeExecution(evicted, 0, 0, 0))
+		execution.offloaded = &offloaded
+		execution.offloadedUntil = evicted[len(evicted)-1].Timestamp
+	}
+	execution.mu.Unlock()
+
+	to.offload(execution, evicted)
+	to.observeAnomalies(execution, metric)
+}
+
+// offload writes points no longer held in the execution's buffer to the metric
+// store, if there is one
+func (to *TestOrchestrator) offload(execution *TestExecution, points []models.MetricPoint) {
+	if len(points) == 0 {
+		return
+	}
+
+	to.mu.RLock()
+	store := to.metricBuffer.Store
+	to.mu.RUnlock()
+	if store == nil {
+		return
+	}
+
+	for _, point := range points {
+		tags := make(map[string]string, len(point.Tags)+2)
+		for key, value := range point.Tags {
+			tags[key] = value
+		}
+		tags["execution_id"] = execution.ID
+		tags[offloadedTag] = "true"
+		point.Tags = tags
+
+		if err := store.WriteMetricPoint(point); err != nil {
+			to.logger.WithError(err).WithField("execution_id", execution.ID).Warn("Failed to offload metric point")
+		}
+	}
+}
+
+// summarizeMetrics summarizes every metric point recorded for the execution since
+// the last soak checkpoint, in memory or offloaded. The caller must hold execution.mu.
+func (execution *TestExecution) summarizeMetrics(duration time.Duration) models.ExecutionSummary {
+	summary := SummarizeExecution(execution.metrics.all(), duration, execution.Violations, execution.Critical)
+	summary.ViolationsBySeverity = execution.violationCounts()
+	return mergeSummaries(execution.offloaded, summary)
+}
+
+// storedMetrics reads the points of an execution that were offloaded to the store
+// within the time range. The store returns one point per field, so points written
+// together are regrouped.
+func storedMetrics(store MetricStore, testID, executionID string, timeRange models.TimeRange) ([]models.MetricPoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), metricStoreTimeout)
+	defer cancel()
+
+	grouped := make(map[string]*models.MetricPoint)
+	var points []*models.MetricPoint
+	err := store.StreamTestMetrics(ctx, testID, timeRange, func(point models.MetricPoint) error {
+		if point.Tags["execution_id"] != executionID || point.Tags[offloadedTag] != "true" {
+			return nil
+		}
+		delete(point.Tags, offloadedTag)
+
+		key := pointKey(point)
+		if existing, ok := grouped[key]; ok {
+			for field, value := range point.Fields {
+				existing.Fields[field] = value
+			}
+			return nil
+		}
+		grouped[key] = &point
+		points = append(points, &point)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.MetricPoint, 0, len(points))
+	for _, point := range points {
+		result = append(result, *point)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result, nil
+}
+
+// pointKey identifies the point a stored field value was written with
+func pointKey(point models.MetricPoint) string {
+	tags := make([]string, 0, len(point.Tags))
+	for key, value := range point.Tags {
+		tags = append(tags, key+"="+value)
+	}
+	sort.Strings(tags)
+	return fmt.Sprintf("%d|%s|%s|%s", point.Timestamp.UnixNano(), point.Source, point.Type, strings.Join(tags, ","))
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/notify"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// notifyingAlertManager notifies users who asked about alerts before forwarding
+// them to the next alert manager. It sits after mute rules and deduplication,
+// so users aren't notified about alerts nobody else sees either.
+type notifyingAlertManager struct {
+	next       safety.AlertManager
+	dispatcher *notify.Dispatcher
+}
+
+// SendAlert notifies users about the alert and forwards it
+func (n *notifyingAlertManager) SendAlert(alert safety.Alert) error {
+	// A resolved condition isn't worth a notification of its own
+	if !alert.Resolved {
+		notification := notify.Notification{
+			Event:     models.NotifyAlert,
+			Severity:  string(alert.Severity),
+			Title:     fmt.Sprintf("%s alert: %s", alert.Severity, alert.Type),
+			Message:   alert.Message,
+			Timestamp: alert.Timestamp,
+			Metadata:  alert.Metadata,
+		}
+		notification.ExecutionID, _ = alert.Metadata["execution_id"].(string)
+		notification.TestID, _ = alert.Metadata["test_id"].(string)
+		n.dispatcher.Notify(notification)
+	}
+
+	return n.next.SendAlert(alert)
+}
+
+// AddNotificationChannel makes a channel users can choose in their notification
+// preferences available, if users are notified at all
+func (o *Orchestrator) AddNotificationChannel(name string, channel notify.Channel) {
+	if o.notifier != nil {
+		o.notifier.AddChannel(name, channel)
+	}
+}
+
+// notifyExecution notifies users about a finished execution. Stopped executions
+// were stopped by someone, so nobody is told about them.
+func (o *Orchestrator) notifyExecution(execution models.TestExecution) {
+	if o.notifier == nil {
+		return
+	}
+
+	notification := notify.Notification{
+		ExecutionID: execution.ID,
+		TestID:      execution.TestID,
+		Timestamp:   time.Now(),
+	}
+	if execution.EndTime != nil {
+		notification.Timestamp = *execution.EndTime
+	}
+
+	name := execution.TestID
+	if o.repo != nil {
+		if test, err := o.repo.GetTestConfiguration(execution.TestID); err == nil {
+			name = test.Name
+		}
+	}
+
+	switch execution.Status {
+	case models.StatusCompleted:
+		notification.Event = models.NotifyExecutionCompleted
+		notification.Severity = string(safety.SeverityInfo)
+		notification.Title = fmt.Sprintf("Execution of %s completed", name)
+		notification.Message = fmt.Sprintf("Execution %s of %s completed in %s.", execution.ID, name, execution.Duration.Round(time.Second))
+	case models.StatusFailed, models.StatusTimedOut:
+		notification.Event = models.NotifyExecutionFailed
+		notification.Severity = string(safety.SeverityError)
+		notification.Title = fmt.Sprintf("Execution of %s %s", name, execution.Status)
+		notification.Message = fmt.Sprintf("Execution %s of %s %s.", execution.ID, name, execution.Status)
+		if execution.ErrorMessage != nil && *execution.ErrorMessage != "" {
+			notification.Message += " " + *execution.ErrorMessage
+		}
+	default:
+		return
+	}
+
+	o.notifier.Notify(notification)
+}
@@ -3,11 +3,16 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/pranavgopavaram/ssts/internal/faults"
 	"github.com/pranavgopavaram/ssts/internal/plugins"
 	"github.com/pranavgopavaram/ssts/internal/safety"
 	"github.com/pranavgopavaram/ssts/pkg/models"
@@ -20,30 +25,105 @@ type TestOrchestrator struct {
 	safetyMonitor   *safety.Monitor
 	metricsCollector MetricsCollector
 	executions      map[string]*TestExecution
+	byTest          map[string]map[string]*TestExecution // executions by test ID
+	queue           []*TestExecution                     // queued executions in the order they start
+	draining        bool
+	watchdog        WatchdogSettings
+	soak            SoakSettings
+	preflightSettings PreflightSettings
+	conflictSettings ConflictSettings
+	probeSettings   ProbeSettings
+	onComplete      []func(models.TestExecution)
+	onCheckpoint    []func(models.TestExecution)
+	metricBuffer    MetricBufferSettings
+	progressInterval time.Duration
+	onProgress      []func(models.TestExecution)
+	frequencyPins   *cpuFrequencyPins
+	placement       processPlacement
+	diagnostics     DiagnosticsSettings
+	anomalySettings AnomalySettings
+	kernelLogSettings KernelLogSettings
+	diskGuardSettings DiskGuardSettings
+	onAnomaly       []func(models.Anomaly)
+	onViolation     []func(models.Violation)
 	mu              sync.RWMutex
 	logger          *logrus.Logger
 }
 
+// ErrDraining is returned by StartTest once the orchestrator has begun draining for shutdown
+var ErrDraining = errors.New("orchestrator is draining, not accepting new tests")
+
+// ErrExecutionNotFound is returned, wrapped with the ID, for an execution the orchestrator isn't tracking
+var ErrExecutionNotFound = errors.New("test execution not found")
+
+// cooldownInterval is how often system metrics are sampled during a run's cooldown
+var cooldownInterval = time.Second
+
 // TestExecution represents an active test execution
+
 type TestExecution struct {
-	ID           string
-	Config       models.TestConfiguration
-	Status       models.ExecutionStatus
-	StartTime    time.Time
-	EndTime      *time.Time
-	Context      context.Context
-	Cancel       context.CancelFunc
-	Metrics      []models.MetricPoint
-	ErrorMessage *string
-	mu           sync.RWMutex
+	ID             string
+	Config         models.TestConfiguration
+	Status         models.ExecutionStatus
+	StartTime      time.Time
+	EndTime        *time.Time
+	Context        context.Context
+	Cancel         context.CancelFunc
+	ErrorMessage   *string
+	Summary        json.RawMessage                // set once the execution has finished
+	Violations     int                            // safety limit violations seen while running
+	Critical       int                            // of which critical
+	Energy         *models.EnergyUsage            // energy consumed while running, if it could be measured
+	Labels         models.Labels                  // the test's labels plus those given at run time
+	StartedBy      string                         // user who ran the test, if known
+	System         *models.SystemSnapshot         // kernel and OS settings captured as it started
+	Seed           int64                          // of the plugin's random decisions
+	CI             models.CIMetadata              // build the run was started for
+	done           chan struct{}                  // closed once executeTest has returned
+	timedOut       *string                        // set by the watchdog once the wall-clock budget is spent
+	abandoned      bool                           // the watchdog gave up waiting for executeTest to return
+	completed      bool                           // completion handlers have run
+	plugin         plugins.StressPlugin           // instance running this execution, not shared with others
+	metrics        *metricBuffer                  // most recent metric points
+	offloaded      *models.ExecutionSummary       // summary of the points evicted from metrics
+	offloadedUntil time.Time                      // time of the newest evicted point
+	soak           *soakState                     // set for soak runs
+	suspended      bool                           // a soak run stopped for shutdown, to be resumed after the restart
+	params         models.TestParams              // what the plugin is running with, set once it starts
+	runStart       time.Time                      // when the plugin started; StartTime includes time queued
+	cooldownStart  *time.Time                     // set once the workload has finished and cooldown began
+	claims         []plugins.ResourceClaim        // resources the plugin claims for the run
+	claimsHeld     bool                           // claims are granted; guarded by TestOrchestrator.mu
+	probes         map[string]models.ProbeSummary // victim probe results, set once the plugin has run
+	violations     []safety.Violation             // most recent safety violations, for diagnostics
+	bySeverity     map[string]int                 // safety violations seen while running, by severity
+	failureClass   models.FailureClass            // where a failed execution went wrong
+	diagnostics    string                         // file name of the diagnostics bundle, once captured
+	run            *runDeadline                   // the plugin's run context, set once it starts
+	extended       time.Duration                  // run time added by ExtendTest
+	pausedAt       *time.Time                     // set while the workload is paused
+	paused         time.Duration                  // time the workload spent paused before the current pause
+	yielding       bool                           // polite mode is throttling or pausing the load for interactive users
+	anomalies      map[string]*anomalySeries      // moving averages of watched metrics, by series key
+	importedFrom   string                         // tool the run was imported from, for runs made outside SSTS
+	kernelEvents   map[string]int                 // events the kernel log reported while running, by kind
+	placement      *models.PlacementReport        // placement the workload ran with, if it asked for one
+	priority       int                            // orders the execution in the queue
+	queue          *queueEntry                    // set while queued; guarded by TestOrchestrator.mu
+	steadyFrom     *time.Time                     // when a plugin reporting its phase reached steady state
+	steadyUntil    *time.Time                     // when it left it for ramp-down
+	rampingUp      bool                           // it reported ramp-up and hasn't reached steady state yet
+	mu             sync.RWMutex
 }
 
 // MetricsCollector interface for collecting metrics
 type MetricsCollector interface {
 	CollectSystemMetrics() models.SystemMetrics
 	CollectPluginMetrics(pluginName string, plugin plugins.StressPlugin) map[string]interface{}
-	StartCollection(ctx context.Context, testID string)
-	StopCollection(testID string)
+	StreamPluginMetrics(testID, executionID, pluginName string, stream <-chan models.MetricPoint)
+	StartCollection(ctx context.Context, testID, executionID string)
+	StopCollection(executionID string)
+	EnergyUsage(executionID string) *models.EnergyUsage
 }
 
 // NewTestOrchestrator creates a new test orchestrator
@@ -53,105 +133,415 @@ func NewTestOrchestrator(
 	metricsCollector MetricsCollector,
 	logger *logrus.Logger,
 ) *TestOrchestrator {
-	return &TestOrchestrator{
+	to := &TestOrchestrator{
 		pluginManager:    pluginManager,
 		safetyMonitor:    safetyMonitor,
 		metricsCollector: metricsCollector,
 		executions:       make(map[string]*TestExecution),
+		byTest:           make(map[string]map[string]*TestExecution),
+		frequencyPins:    newCPUFrequencyPins(),
 		logger:           logger,
 	}
+
+	// Restore CPU frequency settings left pinned by a previous process that died mid-test
+	if restored, err := to.frequencyPins.recoverStale(); err != nil {
+		logger.WithError(err).Error("Failed to restore CPU frequency settings left pinned by a previous process")
+	} else if restored > 0 {
+		logger.WithField("settings", restored).Warn("Restored CPU frequency settings left pinned by a previous process")
+	}
+
+	return to
 }
 
 // StartTest starts a new test execution
 func (to *TestOrchestrator) StartTest(config models.TestConfiguration, params models.TestParams) (string, error) {
-	// Validate plugin exists
-	plugin, exists := to.pluginManager.GetPlugin(config.Plugin)
-	if !exists {
-		return "", fmt.Errorf("plugin not found: %s", config.Plugin)
+	if err := faults.Inject(context.Background(), faults.OrchestratorStart); err != nil {
+		return "", err
+	}
+
+	// Validate plugin exists and get an instance of it for this execution alone
+	plugin, err := to.pluginManager.NewInstance(config.Plugin)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", plugins.ErrPluginNotFound, config.Plugin)
+	}
+	if !to.pluginManager.IsEnabled(config.Plugin) {
+		return "", fmt.Errorf("%w: %s", plugins.ErrPluginNotEnabled, config.Plugin)
+	}
+
+	if err := checkRampDown(plugin, params.RampDown); err != nil {
+		return "", err
+	}
+
+	// Fill in configured ramp-down and cooldown if the caller didn't specify them
+	to.applyRampDownDefaults(&params, rampsDown(plugin))
+
+	// Pick the seed of the plugin's random decisions, recorded so the run can be replayed
+	if params.Seed == 0 {
+		params.Seed = newSeed()
+	}
+
+	if err := to.checkDuration(params); err != nil {
+		return "", err
+	}
+
+	if err := checkFrequencyPinning(params); err != nil {
+		return "", err
+	}
+
+	if err := to.placement.check(params.Placement); err != nil {
+		return "", err
+	}
+
+	if err := checkLoadProfile(plugin, params.LoadProfile); err != nil {
+		return "", err
+	}
+
+	if err := checkPolite(plugin, params.Polite); err != nil {
+		return "", err
+	}
+
+	if err := to.checkProbes(config.Probes); err != nil {
+		return "", err
+	}
+
+	if to.IsDraining() {
+		return "", ErrDraining
+	}
+
+	if plugins.Capabilities(plugin).Destructive {
+		to.logger.WithField("plugin", config.Plugin).Warn("Starting a test with a plugin that may destroy data on this machine")
+	}
+
+	// Don't add stress to a machine that is already loaded; queue the run if configured to
+	status := models.StatusPending
+	if err := to.preflight(config, plugin); err != nil {
+		to.mu.RLock()
+		queue := to.preflightSettings.Queue
+		to.mu.RUnlock()
+		if !queue {
+			return "", err
+		}
+		status = models.StatusQueued
+		to.logger.WithError(err).WithField("plugin", config.Plugin).Warn("Test execution queued until preflight checks pass")
+	}
+
+	// Polite runs deferring to interactive users queue until they have left
+	if status != models.StatusQueued {
+		if err := to.politeBlocker(params.Polite); err != nil {
+			status = models.StatusQueued
+			to.logger.WithError(err).WithField("plugin", config.Plugin).Info("Test execution queued until interactive users leave")
+		}
 	}
 
 	// Create execution ID
 	executionID := uuid.New().String()
 
-	// Create execution context
-	ctx, cancel := context.WithTimeout(context.Background(), params.Duration)
+	// Create execution context; the plugin run itself is bounded by params.Duration
+	// in executeTest so that the post-test cooldown can outlive it
+	ctx, cancel := context.WithCancel(context.Background())
 
 	// Create test execution
 	execution := &TestExecution{
 		ID:        executionID,
 		Config:    config,
-		Status:    models.StatusPending,
+		Status:    status,
 		StartTime: time.Now(),
 		Context:   ctx,
 		Cancel:    cancel,
-		Metrics:   make([]models.MetricPoint, 0),
+		Labels:    config.Labels.Merge(params.Labels),
+		StartedBy: params.StartedBy,
+		Seed:      params.Seed,
+		CI:        ciMetadata(params.CI),
+		priority:  params.Priority,
+		metrics:   to.newMetricBuffer(),
+		done:      make(chan struct{}),
+		plugin:    plugin,
+	}
+	if params.Soak {
+		execution.soak = newSoakState(params, execution.StartTime)
 	}
 
-	// Store execution
-	to.mu.Lock()
-	to.executions[executionID] = execution
-	to.mu.Unlock()
-
-	// Start test in goroutine
-	go to.executeTest(execution, plugin, params)
+	if err := to.launch(execution, plugin, params); err != nil {
+		cancel()
+		return "", err
+	}
 
 	to.logger.WithFields(logrus.Fields{
 		"execution_id": executionID,
 		"plugin":       config.Plugin,
 		"duration":     params.Duration,
+		"soak":         params.Soak,
+		"seed":         params.Seed,
 	}).Info("Test execution started")
 
 	return executionID, nil
 }
 
+// ciMetadata returns the CI metadata given with a run, if any
+func ciMetadata(ci *models.CIMetadata) models.CIMetadata {
+	if ci == nil {
+		return models.CIMetadata{}
+	}
+	return *ci
+}
+
+// newSeed picks the seed of a run that wasn't given one. Seeds are positive, as
+// zero means none was given.
+func newSeed() int64 {
+	return rand.Int63n(math.MaxInt64-1) + 1
+}
+
+// launch stores an execution and starts running it with its watchdog. A run
+// claiming resources that running executions hold is rejected, or queued until
+// they are free if configured to. A run that would start before queued runs ahead
+// of it that need the machine or its resources queues behind them.
+func (to *TestOrchestrator) launch(execution *TestExecution, plugin plugins.StressPlugin, params models.TestParams) error {
+	to.mu.RLock()
+	conflicts := to.conflictSettings
+	to.mu.RUnlock()
+
+	if conflicts.Enabled {
+		claims, err := resourceClaims(execution.Config, plugin)
+		if err != nil {
+			return err
+		}
+		execution.claims = claims
+	}
+
+	// Store execution, re-checking in case a drain began since the caller checked.
+	// Claims are checked under the same lock, so two runs can't both be granted them.
+	to.mu.Lock()
+	if to.draining {
+		to.mu.Unlock()
+		return ErrDraining
+	}
+	if execution.Status != models.StatusQueued {
+		if err := to.queueBlockerLocked(execution); err != nil {
+			execution.Status = models.StatusQueued
+			to.logger.WithError(err).WithField("plugin", execution.Config.Plugin).Info("Test execution queued behind earlier runs")
+		} else if err := to.acquireClaimsLocked(execution); err != nil {
+			if !conflicts.Queue {
+				to.mu.Unlock()
+				return err
+			}
+			execution.Status = models.StatusQueued
+			to.logger.WithError(err).WithField("plugin", execution.Config.Plugin).Warn("Test execution queued until the resources it claims are free")
+		}
+	}
+	to.executions[execution.ID] = execution
+	if to.byTest[execution.Config.ID] == nil {
+		to.byTest[execution.Config.ID] = make(map[string]*TestExecution)
+	}
+	to.byTest[execution.Config.ID][execution.ID] = execution
+	if execution.Status == models.StatusQueued {
+		to.enqueueLocked(execution, params)
+	}
+	to.mu.Unlock()
+
+	// Start test in goroutine; a queued one first waits for the preflight checks to
+	// pass and its claims to be granted
+	if execution.Status == models.StatusQueued {
+		go to.runWhenReady(execution, plugin, params)
+		return nil
+	}
+	go to.executeTest(execution, plugin, params)
+	go to.watch(execution, params)
+	return nil
+}
+
 // executeTest executes a test
 func (to *TestOrchestrator) executeTest(execution *TestExecution, plugin plugins.StressPlugin, params models.TestParams) {
+	defer close(execution.done)
+	defer to.releaseClaims(execution)
+	defer to.complete(execution)
 	defer func() {
 		if r := recover(); r != nil {
 			to.handleTestPanic(execution, r)
 		}
 	}()
 
+	// Pin CPU frequency if asked to, restoring it however the run ends. This comes
+	// before the snapshot so that it records the pinned settings.
+	releaseFrequency, pinErr := to.frequencyPins.pin(params)
+	defer releaseFrequency()
+
+	// Place the workload on the CPUs and at the priority asked for
+	releasePlacement, placement, placeErr := to.placement.acquire(params.Placement)
+	defer func() {
+		if err := releasePlacement(); err != nil {
+			to.logger.WithError(err).WithField("execution_id", execution.ID).Error("Failed to restore CPU affinity and priorities")
+		}
+	}()
+
+	// Record the settings the machine runs with, so results can be compared fairly
+	system := captureSystemSnapshot()
+
 	// Update status to running
 	execution.mu.Lock()
 	execution.Status = models.StatusRunning
+	execution.System = system
+	execution.params = params
+	execution.runStart = time.Now()
 	execution.mu.Unlock()
+	defer to.startProgressReports(execution)()
+	defer watchPhases(execution)()
+	defer shapeLoad(execution)()
+	defer to.bePolite(execution)()
+
+	// Checkpoint soak runs until they finish, before the final state is recorded
+	if execution.soak != nil {
+		defer to.startCheckpoints(execution)()
+	}
 
 	// Start safety monitoring
 	safetyCtx, safetyCancel := context.WithCancel(execution.Context)
 	defer safetyCancel()
 
 	go to.monitorSafety(safetyCtx, execution, plugin.GetSafetyLimits())
+	go to.watchKernelLog(safetyCtx, execution)
+	go to.guardDisk(safetyCtx, execution)
 
 	// Start metrics collection
-	to.metricsCollector.StartCollection(execution.Context, execution.ID)
-	defer to.metricsCollector.StopCollection(execution.ID)
+	to.metricsCollector.StartCollection(execution.Context, execution.Config.ID, execution.ID)
+	defer func() {
+		to.metricsCollector.StopCollection(execution.ID)
+		energy := to.metricsCollector.EnergyUsage(execution.ID)
+		execution.mu.Lock()
+		execution.Energy = energy
+		execution.mu.Unlock()
+	}()
+
+	if pinErr != nil {
+		to.finishTestWithError(execution, models.FailureSetup, fmt.Errorf("failed to pin CPU frequency: %w", pinErr))
+		return
+	}
+	if placeErr != nil {
+		to.finishTestWithError(execution, models.FailureSetup, fmt.Errorf("failed to apply placement: %w", placeErr))
+		return
+	}
+	if placement != nil {
+		execution.mu.Lock()
+		execution.placement = placement
+		execution.mu.Unlock()
+		to.recordMetric(execution, placementPoint(execution, placement))
+		if !placement.Verified {
+			to.logger.WithField("execution_id", execution.ID).Warn("Not every thread runs with the placement asked for")
+		}
+	}
 
 	// Parse plugin configuration
 	var pluginConfig interface{}
 	if len(execution.Config.Config) > 0 {
 		if err := json.Unmarshal(execution.Config.Config, &pluginConfig); err != nil {
-			to.finishTestWithError(execution, fmt.Errorf("failed to parse plugin config: %w", err))
+			to.finishTestWithError(execution, models.FailureSetup, fmt.Errorf("failed to parse plugin config: %w", err))
 			return
 		}
 	}
 
+	// Measure services sharing the machine before and while it is loaded
+	stopProbes := to.startProbes(execution)
+
 	// Execute the test
-	err := to.pluginManager.ExecutePlugin(execution.Context, execution.Config.Plugin, pluginConfig, params)
-	
-	if err != nil {
+	runCtx, runCancel := newRunDeadline(execution.Context, params.Duration)
+	execution.mu.Lock()
+	execution.run = runCtx
+	execution.mu.Unlock()
+	if streamer, ok := plugin.(plugins.MetricsStreamer); ok {
+		to.metricsCollector.StreamPluginMetrics(execution.Config.ID, execution.ID, plugin.Name(), streamer.MetricsStream(runCtx))
+	}
+	err := faults.Inject(runCtx, faults.OrchestratorExecute)
+	if err == nil {
+		err = to.pluginManager.RunPlugin(runCtx, plugin, pluginConfig, params)
+	}
+	runCancel()
+	if warner, ok := plugin.(plugins.ConfigWarner); ok {
+		for _, warning := range warner.ConfigWarnings() {
+			to.logger.WithField("execution_id", execution.ID).WithField("plugin", plugin.Name()).Warn("Plugin configuration: " + warning)
+		}
+	}
+	probes := stopProbes()
+	execution.mu.Lock()
+	execution.probes = probes
+	execution.mu.Unlock()
+
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
 		if execution.Context.Err() == context.Canceled {
 			to.finishTestWithStatus(execution, models.StatusStopped)
 		} else {
-			to.finishTestWithError(execution, err)
+			to.finishTestWithError(execution, models.FailurePlugin, err)
 		}
 		return
 	}
 
+	// Keep collecting metrics to capture recovery behaviour
+	if params.Cooldown > 0 {
+		to.runCooldown(execution, params.Cooldown)
+	}
+
 	// Test completed successfully
 	to.finishTestWithStatus(execution, models.StatusCompleted)
 }
 
+// applyRampDownDefaults fills in the configured cooldown period, and the
+// ramp-down window for plugins that ramp down
+func (to *TestOrchestrator) applyRampDownDefaults(params *models.TestParams, rampsDown bool) {
+	rampDown, cooldown := to.safetyMonitor.GetRampDownSettings()
+	if params.RampDown == 0 && rampsDown {
+		params.RampDown = rampDown
+	}
+	if params.Cooldown == 0 {
+		params.Cooldown = cooldown
+	}
+}
+
+// runCooldown samples system metrics after the plugin has finished until the
+// cooldown period elapses or the execution is stopped
+func (to *TestOrchestrator) runCooldown(execution *TestExecution, cooldown time.Duration) {
+	to.logger.WithFields(logrus.Fields{
+		"execution_id": execution.ID,
+		"cooldown":     cooldown,
+	}).Info("Test workload finished, collecting cooldown metrics")
+
+	start := time.Now()
+	execution.mu.Lock()
+	execution.cooldownStart = &start
+	execution.mu.Unlock()
+
+	ticker := time.NewTicker(cooldownInterval)
+	defer ticker.Stop()
+
+	timer := time.NewTimer(cooldown)
+	defer timer.Stop()
+
+	tags := map[string]string{"phase": "cooldown"}
+	for {
+		select {
+		case <-execution.Context.Done():
+			return
+		case <-timer.C:
+			return
+		case <-ticker.C:
+			system := to.metricsCollector.CollectSystemMetrics()
+			metric := models.MetricPoint{
+				Timestamp: time.Now(),
+				TestID:    execution.Config.ID,
+				Source:    "orchestrator",
+				Type:      "system",
+				Tags:      tags,
+				Fields: models.CooldownFields{
+					CPUUsagePercent:    system.CPU.UsagePercent,
+					TemperatureCelsius: system.CPU.Temperature,
+					MemoryUsagePercent: system.Memory.UsagePercent,
+					MemoryUsedBytes:    system.Memory.UsedBytes,
+					DiskUsagePercent:   system.Disk.UsagePercent,
+				}.Fields(),
+			}
+
+			to.recordMetric(execution, metric)
+		}
+	}
+}
+
 // monitorSafety monitors system safety during test execution
 func (to *TestOrchestrator) monitorSafety(ctx context.Context, execution *TestExecution, safetyLimits models.SafetyLimits) {
 	ticker := time.NewTicker(1 * time.Second)
@@ -163,12 +553,18 @@ func (to *TestOrchestrator) monitorSafety(ctx context.Context, execution *TestEx
 			return
 		case <-ticker.C:
 			if violation := to.safetyMonitor.CheckSafetyLimits(safetyLimits); violation != nil {
-				to.logger.WithFields(logrus.Fields{
-					"execution_id": execution.ID,
-					"violation":    violation.Type,
-					"value":        violation.CurrentValue,
-					"limit":        violation.Limit,
-				}).Warn("Safety limit violation detected")
+				to.noteViolation(execution, *violation)
+
+				// Log once per episode rather than every tick the limit stays exceeded
+				if violation.NewEpisode {
+					to.logger.WithFields(logrus.Fields{
+						"execution_id": execution.ID,
+						"violation":    violation.Type,
+						"value":        violation.CurrentValue,
+						"limit":        violation.Limit,
+						"episode_id":   violation.EpisodeID,
+					}).Warn("Safety limit violation detected")
+				}
 
 				// Emergency stop if critical
 				if violation.Critical {
@@ -182,16 +578,20 @@ func (to *TestOrchestrator) monitorSafety(ctx context.Context, execution *TestEx
 
 // StopTest stops a running test
 func (to *TestOrchestrator) StopTest(executionID string) error {
+	if err := faults.Inject(context.Background(), faults.OrchestratorStop); err != nil {
+		return err
+	}
+
 	to.mu.RLock()
 	execution, exists := to.executions[executionID]
 	to.mu.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("test execution not found: %s", executionID)
+		return fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
 	}
 
 	execution.mu.Lock()
-	if execution.Status != models.StatusRunning {
+	if execution.Status != models.StatusRunning && execution.Status != models.StatusQueued {
 		execution.mu.Unlock()
 		return fmt.Errorf("test is not running: %s", execution.Status)
 	}
@@ -204,6 +604,170 @@ func (to *TestOrchestrator) StopTest(executionID string) error {
 	return nil
 }
 
+// OnExecutionComplete registers a function called with the final state of every
+// execution, including its summary, once it has finished
+func (to *TestOrchestrator) OnExecutionComplete(fn func(models.TestExecution)) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.onComplete = append(to.onComplete, fn)
+}
+
+// complete computes the execution summary and notifies completion handlers. A
+// suspended soak run isn't complete; its last state is checkpointed instead.
+func (to *TestOrchestrator) complete(execution *TestExecution) {
+	execution.mu.Lock()
+	if execution.suspended && !execution.abandoned {
+		execution.mu.Unlock()
+		to.checkpoint(execution)
+		return
+	}
+	if execution.completed {
+		execution.mu.Unlock()
+		return
+	}
+	execution.completed = true
+	end := time.Now()
+	if execution.EndTime != nil {
+		end = *execution.EndTime
+	}
+	var summary models.ExecutionSummary
+	if execution.soak != nil {
+		summary = execution.soak.summary(execution, end)
+	} else {
+		summary = execution.summarizeMetrics(end.Sub(execution.StartTime))
+		summary.Energy = energySummary(execution.Energy, summary.Totals)
+	}
+	if reporter, ok := execution.plugin.(plugins.DeviceWriteReporter); ok {
+		summary.DeviceWrites = reporter.DeviceBytesWritten()
+	}
+	summary.Probes = execution.probes
+	summary.KernelEvents = execution.kernelEvents
+	summary.Placement = execution.placement
+	summary.SteadyState = execution.steadyState(end)
+	if !execution.Config.SLA.Empty() {
+		sla := EvaluateSLA(summary, execution.Config.SLA)
+		summary.SLA = &sla
+	}
+	if execution.Status != models.StatusCompleted {
+		summary.Partial = execution.partialRun(summary, end)
+	}
+	if data, err := json.Marshal(summary); err == nil {
+		execution.Summary = data
+	} else {
+		to.logger.WithError(err).WithField("execution_id", execution.ID).Error("Failed to encode execution summary")
+	}
+	result := execution.toModel()
+	execution.mu.Unlock()
+
+	// Capture what's needed to troubleshoot a failure before it is persisted
+	if bundle := to.captureDiagnostics(execution, result); bundle != "" {
+		execution.mu.Lock()
+		execution.diagnostics = bundle
+		execution.mu.Unlock()
+		result.Diagnostics = bundle
+	}
+
+	to.mu.RLock()
+	handlers := to.onComplete
+	to.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(result)
+	}
+}
+
+// BeginDrain stops the orchestrator from accepting new tests. Executions already
+// running are unaffected; use Drain to wait for or stop them.
+func (to *TestOrchestrator) BeginDrain() {
+	to.mu.Lock()
+	to.draining = true
+	to.mu.Unlock()
+
+	to.logger.Info("Test orchestrator draining, new tests will be rejected")
+}
+
+// IsDraining reports whether the orchestrator has begun draining
+func (to *TestOrchestrator) IsDraining() bool {
+	to.mu.RLock()
+	defer to.mu.RUnlock()
+	return to.draining
+}
+
+// Drain waits for unfinished executions to return. Unless stopRunning is set it
+// first waits until they finish on their own or ctx is done; anything still
+// running is then cancelled, which runs plugin cleanup, and given stopTimeout
+// to exit. It returns the number of executions that had to be stopped.
+func (to *TestOrchestrator) Drain(ctx context.Context, stopRunning bool, stopTimeout time.Duration) int {
+	to.BeginDrain()
+
+	to.mu.RLock()
+	pending := make([]*TestExecution, 0, len(to.executions))
+	for _, execution := range to.executions {
+		select {
+		case <-execution.done:
+		default:
+			execution.mu.RLock()
+			abandoned := execution.abandoned
+			execution.mu.RUnlock()
+			if !abandoned {
+				pending = append(pending, execution)
+			}
+		}
+	}
+	to.mu.RUnlock()
+
+	if len(pending) == 0 {
+		return 0
+	}
+
+	to.logger.WithFields(logrus.Fields{
+		"executions":   len(pending),
+		"stop_running": stopRunning,
+	}).Info("Draining test executions")
+
+	if !stopRunning {
+		remaining := pending[:0]
+		for _, execution := range pending {
+			select {
+			case <-execution.done:
+			case <-ctx.Done():
+				remaining = append(remaining, execution)
+			}
+		}
+		pending = remaining
+	}
+
+	if len(pending) == 0 {
+		return 0
+	}
+
+	to.mu.RLock()
+	resume := to.soak.Resume
+	to.mu.RUnlock()
+
+	for _, execution := range pending {
+		if resume && execution.soak != nil {
+			to.suspend(execution)
+			continue
+		}
+		to.logger.WithField("execution_id", execution.ID).Warn("Stopping test execution for shutdown")
+		execution.Cancel()
+	}
+
+	timer := time.NewTimer(stopTimeout)
+	defer timer.Stop()
+	for _, execution := range pending {
+		select {
+		case <-execution.done:
+		case <-timer.C:
+			to.logger.WithField("execution_id", execution.ID).Error("Test execution did not stop before shutdown timeout")
+			return len(pending)
+		}
+	}
+
+	return len(pending)
+}
+
 // EmergencyStop performs an emergency stop of a test
 func (to *TestOrchestrator) EmergencyStop(executionID string, reason string) error {
 	to.mu.RLock()
@@ -211,7 +775,7 @@ func (to *TestOrchestrator) EmergencyStop(executionID string, reason string) err
 	to.mu.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("test execution not found: %s", executionID)
+		return fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
 	}
 
 	// Cancel the test immediately
@@ -219,8 +783,7 @@ func (to *TestOrchestrator) EmergencyStop(executionID string, reason string) err
 
 	// Update status and error message
 	execution.mu.Lock()
-	execution.Status = models.StatusFailed
-	execution.ErrorMessage = &reason
+	execution.fail(models.FailureSafety, reason)
 	now := time.Now()
 	execution.EndTime = &now
 	execution.mu.Unlock()
@@ -240,20 +803,38 @@ func (to *TestOrchestrator) GetTestStatus(executionID string) (*models.TestExecu
 	to.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("test execution not found: %s", executionID)
+		return nil, fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
 	}
 
 	execution.mu.RLock()
 	defer execution.mu.RUnlock()
 
 	// Convert to model
-	result := &models.TestExecution{
+	result := execution.toModel()
+	return &result, nil
+}
+
+// toModel converts the execution to its model form. The caller must hold execution.mu.
+func (execution *TestExecution) toModel() models.TestExecution {
+	result := models.TestExecution{
 		ID:           execution.ID,
 		TestID:       execution.Config.ID,
+		ProjectID:    execution.Config.ProjectID,
+		StartedBy:    execution.StartedBy,
 		Status:       execution.Status,
 		StartTime:    &execution.StartTime,
 		EndTime:      execution.EndTime,
 		ErrorMessage: execution.ErrorMessage,
+		FailureClass: execution.failureClass,
+		Diagnostics:  execution.diagnostics,
+		Summary:      execution.Summary,
+		Labels:       execution.Labels,
+		System:       execution.System,
+		Seed:         execution.Seed,
+		CI:           execution.CI,
+		Created:      execution.StartTime,
+		Extended:     execution.extended,
+		ImportedFrom: execution.importedFrom,
 	}
 
 	if execution.EndTime != nil {
@@ -261,7 +842,21 @@ func (to *TestOrchestrator) GetTestStatus(executionID string) (*models.TestExecu
 		result.Duration = duration
 	}
 
-	return result, nil
+	if execution.soak != nil {
+		result.Soak = true
+		result.Params, _ = json.Marshal(execution.soak.params)
+		end := time.Now()
+		if execution.EndTime != nil {
+			end = *execution.EndTime
+		}
+		result.Elapsed = execution.soak.elapsed(end)
+		result.CheckpointedAt = execution.soak.checkpointedAt
+		result.Resumes = execution.soak.resumes
+	}
+
+	result.Progress = execution.progress(time.Now())
+
+	return result
 }
 
 // ListExecutions returns all test executions
@@ -272,46 +867,122 @@ func (to *TestOrchestrator) ListExecutions() []models.TestExecution {
 	executions := make([]models.TestExecution, 0, len(to.executions))
 	for _, execution := range to.executions {
 		execution.mu.RLock()
-		
-		modelExec := models.TestExecution{
-			ID:           execution.ID,
-			TestID:       execution.Config.ID,
-			Status:       execution.Status,
-			StartTime:    &execution.StartTime,
-			EndTime:      execution.EndTime,
-			ErrorMessage: execution.ErrorMessage,
-		}
+		executions = append(executions, execution.toModel())
+		execution.mu.RUnlock()
+	}
 
-		if execution.EndTime != nil {
-			duration := execution.EndTime.Sub(execution.StartTime)
-			modelExec.Duration = duration
-		}
+	return executions
+}
+
+// ListExecutionsByTest returns the executions of a test, newest first
+func (to *TestOrchestrator) ListExecutionsByTest(testID string) []models.TestExecution {
+	to.mu.RLock()
+	defer to.mu.RUnlock()
 
-		executions = append(executions, modelExec)
+	executions := make([]models.TestExecution, 0, len(to.byTest[testID]))
+	for _, execution := range to.byTest[testID] {
+		execution.mu.RLock()
+		executions = append(executions, execution.toModel())
 		execution.mu.RUnlock()
 	}
+	sort.Slice(executions, func(i, j int) bool { return executions[i].Created.After(executions[j].Created) })
 
 	return executions
 }
 
-// GetTestMetrics returns metrics for a test execution
-func (to *TestOrchestrator) GetTestMetrics(executionID string) ([]models.MetricPoint, error) {
+// GetLatestExecutionForTest returns the most recently started execution of a test
+// with one of the statuses, or with any status when none are given
+func (to *TestOrchestrator) GetLatestExecutionForTest(testID string, statuses ...models.ExecutionStatus) (*models.TestExecution, error) {
+	to.mu.RLock()
+	defer to.mu.RUnlock()
+
+	var latest *TestExecution
+	var latestStart time.Time
+	for _, execution := range to.byTest[testID] {
+		execution.mu.RLock()
+		matches := len(statuses) == 0 || hasStatus(statuses, execution.Status)
+		start := execution.StartTime
+		execution.mu.RUnlock()
+		if matches && (latest == nil || start.After(latestStart)) {
+			latest, latestStart = execution, start
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("%w: no execution of test %s", ErrExecutionNotFound, testID)
+	}
+
+	latest.mu.RLock()
+	defer latest.mu.RUnlock()
+	result := latest.toModel()
+	return &result, nil
+}
+
+// GetRunningExecutionForTest returns the most recently started queued, pending
+// or running execution of a test
+func (to *TestOrchestrator) GetRunningExecutionForTest(testID string) (*models.TestExecution, error) {
+	return to.GetLatestExecutionForTest(testID, models.StatusQueued, models.StatusPending, models.StatusRunning)
+}
+
+// hasStatus reports whether status is one of statuses
+func hasStatus(statuses []models.ExecutionStatus, status models.ExecutionStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTestMetrics returns the metrics of a test execution within the time range; a
+// zero start or end leaves that side open. Recent points come from memory and older
+// ones, evicted from the execution's buffer, are read back from the metric store.
+func (to *TestOrchestrator) GetTestMetrics(executionID string, timeRange models.TimeRange) ([]models.MetricPoint, error) {
 	to.mu.RLock()
 	execution, exists := to.executions[executionID]
+	store := to.metricBuffer.Store
 	to.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("test execution not found: %s", executionID)
+		return nil, fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
 	}
 
 	execution.mu.RLock()
-	defer execution.mu.RUnlock()
+	recent := execution.metrics.between(timeRange)
+	offloadedUntil := execution.offloadedUntil
+	start := execution.StartTime
+	execution.mu.RUnlock()
 
-	// Return copy of metrics
-	metrics := make([]models.MetricPoint, len(execution.Metrics))
-	copy(metrics, execution.Metrics)
-	
-	return metrics, nil
+	if store == nil || offloadedUntil.IsZero() || (!timeRange.Start.IsZero() && timeRange.Start.After(offloadedUntil)) {
+		return recent, nil
+	}
+
+	storedRange := models.TimeRange{Start: timeRange.Start, End: offloadedUntil.Add(time.Nanosecond)}
+	if storedRange.Start.IsZero() {
+		storedRange.Start = start
+	}
+	if !timeRange.End.IsZero() && timeRange.End.Before(offloadedUntil) {
+		storedRange.End = timeRange.End.Add(time.Nanosecond)
+	}
+
+	stored, err := storedMetrics(store, execution.Config.ID, executionID, storedRange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offloaded metrics: %w", err)
+	}
+
+	return append(stored, recent...), nil
+}
+
+// GetPluginMetrics returns the metrics reported by the plugin instance running an execution
+func (to *TestOrchestrator) GetPluginMetrics(executionID string) (map[string]interface{}, error) {
+	to.mu.RLock()
+	execution, exists := to.executions[executionID]
+	to.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
+	}
+
+	return execution.plugin.GetMetrics(), nil
 }
 
 // CleanupCompletedTests removes completed test executions older than specified duration
@@ -326,12 +997,17 @@ func (to *TestOrchestrator) CleanupCompletedTests(maxAge time.Duration) int {
 		execution.mu.RLock()
 		shouldClean := execution.Status != models.StatusRunning && 
 			execution.Status != models.StatusPending &&
+			execution.Status != models.StatusQueued &&
 			execution.EndTime != nil &&
 			execution.EndTime.Before(cutoff)
 		execution.mu.RUnlock()
 
 		if shouldClean {
 			delete(to.executions, id)
+			delete(to.byTest[execution.Config.ID], id)
+			if len(to.byTest[execution.Config.ID]) == 0 {
+				delete(to.byTest, execution.Config.ID)
+			}
 			cleaned++
 		}
 	}
@@ -340,14 +1016,17 @@ func (to *TestOrchestrator) CleanupCompletedTests(maxAge time.Duration) int {
 	return cleaned
 }
 
-// finishTestWithError finishes a test with an error
-func (to *TestOrchestrator) finishTestWithError(execution *TestExecution, err error) {
+// finishTestWithError finishes a test with an error and the class of failure
+func (to *TestOrchestrator) finishTestWithError(execution *TestExecution, class models.FailureClass, err error) {
 	execution.mu.Lock()
-	execution.Status = models.StatusFailed
-	errorMsg := err.Error()
-	execution.ErrorMessage = &errorMsg
+	if execution.abandoned || execution.suspended {
+		execution.mu.Unlock()
+		return
+	}
+	execution.fail(class, err.Error())
 	now := time.Now()
 	execution.EndTime = &now
+	execution.applyTimeout()
 	execution.mu.Unlock()
 
 	to.logger.WithFields(logrus.Fields{
@@ -356,12 +1035,21 @@ func (to *TestOrchestrator) finishTestWithError(execution *TestExecution, err er
 	}).Error("Test execution failed")
 }
 
-// finishTestWithStatus finishes a test with a specific status
+// finishTestWithStatus finishes a test with a specific status. A failure recorded
+// while the run was stopping, such as an emergency stop, is kept.
 func (to *TestOrchestrator) finishTestWithStatus(execution *TestExecution, status models.ExecutionStatus) {
 	execution.mu.Lock()
-	execution.Status = status
+	if execution.abandoned || execution.suspended {
+		execution.mu.Unlock()
+		return
+	}
+	if execution.Status != models.StatusFailed {
+		execution.Status = status
+	}
 	now := time.Now()
 	execution.EndTime = &now
+	execution.applyTimeout()
+	status = execution.Status
 	execution.mu.Unlock()
 
 	to.logger.WithFields(logrus.Fields{
@@ -371,15 +1059,29 @@ func (to *TestOrchestrator) finishTestWithStatus(execution *TestExecution, statu
 	}).Info("Test execution finished")
 }
 
+// applyTimeout marks an execution the watchdog cancelled as timed out, whatever
+// status it finished with. The caller must hold execution.mu.
+func (e *TestExecution) applyTimeout() {
+	if e.timedOut != nil {
+		e.Status = models.StatusTimedOut
+		e.ErrorMessage = e.timedOut
+		e.failureClass = models.FailureTimeout
+	}
+}
+
 // handleTestPanic handles panics during test execution
 func (to *TestOrchestrator) handleTestPanic(execution *TestExecution, r interface{}) {
 	errorMsg := fmt.Sprintf("Test panicked: %v", r)
 	
 	execution.mu.Lock()
-	execution.Status = models.StatusFailed
-	execution.ErrorMessage = &errorMsg
+	if execution.abandoned || execution.suspended {
+		execution.mu.Unlock()
+		return
+	}
+	execution.fail(models.FailurePanic, errorMsg)
 	now := time.Now()
 	execution.EndTime = &now
+	execution.applyTimeout()
 	execution.mu.Unlock()
 
 	to.logger.WithFields(logrus.Fields{
@@ -395,12 +1097,10 @@ func (to *TestOrchestrator) AddMetric(executionID string, metric models.MetricPo
 	to.mu.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("test execution not found: %s", executionID)
+		return fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
 	}
 
-	execution.mu.Lock()
-	execution.Metrics = append(execution.Metrics, metric)
-	execution.mu.Unlock()
+	to.recordMetric(execution, metric)
 
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// partialRun records how far an execution that didn't complete got: why it
+// ended, how much of its planned run time it covered, and its score on the
+// metrics collected until then, scaled by that coverage. Soak runs count the run
+// time their summary covers across resumes. The caller must hold execution.mu.
+func (execution *TestExecution) partialRun(summary models.ExecutionSummary, end time.Time) *models.PartialRun {
+	partial := &models.PartialRun{
+		Status:       execution.Status,
+		FailureClass: execution.failureClass,
+		Reason:       "stopped before finishing",
+	}
+	if execution.ErrorMessage != nil {
+		partial.Reason = *execution.ErrorMessage
+	}
+
+	var elapsed time.Duration
+	switch {
+	case execution.soak != nil:
+		elapsed = time.Duration(summary.DurationSeconds * float64(time.Second))
+	case !execution.runStart.IsZero():
+		elapsed = end.Sub(execution.runStart)
+	}
+	if planned := execution.params.Duration; planned > 0 && elapsed > 0 {
+		partial.Completion = float64(elapsed) / float64(planned)
+		if partial.Completion > 1 {
+			partial.Completion = 1
+		}
+	}
+
+	partial.Score = calculateTestScore(execution.Status, execution.metrics.all()) * partial.Completion
+	return partial
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/metrics"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestUnfinishedExecutionsKeepPartialResults(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(idlePlugin{})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	enhanced := &Orchestrator{testOrchestrator: orchestrator, logger: zap.NewNop()}
+
+	completed := make(chan models.TestExecution, 2)
+	orchestrator.OnExecutionComplete(func(execution models.TestExecution) { completed <- execution })
+
+	// run starts an execution meant to last 10s and ends it after 200ms
+	run := func(end func(id string)) (models.TestExecution, models.PartialRun) {
+		t.Helper()
+		id, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "idle"}, models.TestParams{Duration: 10 * time.Second})
+		if err != nil {
+			t.Fatalf("StartTest: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		orchestrator.AddMetric(id, models.MetricPoint{Timestamp: time.Now(), Source: "plugin", Type: "io", Fields: map[string]interface{}{"iops": 500.0}})
+		time.Sleep(100 * time.Millisecond)
+		end(id)
+
+		var execution models.TestExecution
+		select {
+		case execution = <-completed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("execution did not complete")
+		}
+		var summary models.ExecutionSummary
+		if err := json.Unmarshal(execution.Summary, &summary); err != nil || summary.Partial == nil {
+			t.Fatalf("summary = %s, want a partial result", execution.Summary)
+		}
+		if stats := summary.Metrics["plugin.io.iops"]; stats.Count != 1 || stats.Avg != 500 {
+			t.Errorf("iops = %+v, want the metrics collected before the run ended", stats)
+		}
+		partial := *summary.Partial
+		if partial.Completion <= 0 || partial.Completion > 0.1 {
+			t.Errorf("completion = %v, want the fraction of 10s run", partial.Completion)
+		}
+		return execution, partial
+	}
+
+	execution, partial := run(func(id string) { orchestrator.StopTest(id) })
+	if partial.Status != models.StatusStopped || partial.Reason != "stopped before finishing" || partial.Score <= 0 {
+		t.Errorf("partial = %+v, want a scored stopped run", partial)
+	}
+	result := enhanced.testResult(&execution)
+	if !result.Partial || result.Passed || result.ExecutionID != execution.ID || result.Completion != partial.Completion {
+		t.Errorf("result = %+v, want a partial result of the execution", result)
+	}
+
+	// A failure recorded while the run stops isn't overwritten
+	execution, partial = run(func(id string) { orchestrator.EmergencyStop(id, "Critical safety violation: cpu") })
+	if execution.Status != models.StatusFailed || execution.FailureClass != models.FailureSafety {
+		t.Errorf("execution = %s, class %q, want failed for safety", execution.Status, execution.FailureClass)
+	}
+	if partial.Reason != "Critical safety violation: cpu" || partial.FailureClass != models.FailureSafety {
+		t.Errorf("partial = %+v, want the failure reason", partial)
+	}
+	if result := enhanced.testResult(&execution); result.FailureReason != partial.Reason {
+		t.Errorf("failure reason = %q, want %q", result.FailureReason, partial.Reason)
+	}
+}
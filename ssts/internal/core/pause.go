@@ -0,0 +1,115 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrNotPausable is returned by PauseTest and UnpauseTest for executions whose
+// load can't be held: those not running their workload, soak runs, and those of
+// plugins that can't pause
+var ErrNotPausable = errors.New("execution can't be paused")
+
+// pause stops the deadline's clock until resume. It fails once the context has
+// ended.
+func (ctx *runDeadline) pause() bool {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.err != nil {
+		return false
+	}
+	if ctx.pausedAt.IsZero() {
+		ctx.pausedAt = time.Now()
+		ctx.timer.Stop()
+	}
+	return true
+}
+
+// resume starts the deadline's clock again, pushing the deadline back by the
+// time it was paused
+func (ctx *runDeadline) resume() {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.err != nil || ctx.pausedAt.IsZero() {
+		return
+	}
+	ctx.deadline = ctx.deadline.Add(time.Since(ctx.pausedAt))
+	ctx.pausedAt = time.Time{}
+	ctx.timer.Reset(time.Until(ctx.deadline))
+}
+
+// pausedTime returns how long the execution's workload has been paused,
+// including a pause still going on. The caller must hold execution.mu.
+func (execution *TestExecution) pausedTime(now time.Time) time.Duration {
+	paused := execution.paused
+	if execution.pausedAt != nil {
+		paused += now.Sub(*execution.pausedAt)
+	}
+	return paused
+}
+
+// PauseTest holds the load of a running execution without ending it. The run's
+// deadline, the watchdog's budget and the load profile stand still while it is
+// paused, so the workload still runs for its whole duration once resumed.
+// Pausing a paused execution leaves it as it is. It returns the execution.
+func (to *TestOrchestrator) PauseTest(executionID string) (*models.TestExecution, error) {
+	return to.setPaused(executionID, true)
+}
+
+// UnpauseTest takes up the load of a paused execution again. Resuming an
+// execution that isn't paused leaves it as it is. It returns the execution.
+func (to *TestOrchestrator) UnpauseTest(executionID string) (*models.TestExecution, error) {
+	return to.setPaused(executionID, false)
+}
+
+func (to *TestOrchestrator) setPaused(executionID string, paused bool) (*models.TestExecution, error) {
+	to.mu.RLock()
+	execution, exists := to.executions[executionID]
+	to.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
+	}
+
+	execution.mu.Lock()
+	defer execution.mu.Unlock()
+	if execution.Status != models.StatusRunning || execution.run == nil || execution.cooldownStart != nil {
+		return nil, fmt.Errorf("%w: its workload isn't running", ErrNotPausable)
+	}
+	if execution.soak != nil {
+		return nil, fmt.Errorf("%w: soak runs are checkpointed by their run time", ErrNotPausable)
+	}
+	pauser, ok := execution.plugin.(plugins.Pauser)
+	if !ok {
+		return nil, fmt.Errorf("%w: plugin %s doesn't support pausing", ErrNotPausable, execution.Config.Plugin)
+	}
+
+	now := time.Now()
+	switch {
+	case paused && execution.pausedAt == nil:
+		if !execution.run.pause() {
+			return nil, fmt.Errorf("%w: its workload has finished", ErrNotPausable)
+		}
+		pauser.SetPaused(true)
+		execution.pausedAt = &now
+		to.logger.WithField("execution_id", execution.ID).Info("Paused test execution")
+	case !paused && execution.pausedAt != nil:
+		pausedFor := now.Sub(*execution.pausedAt)
+		pauser.SetPaused(false)
+		execution.run.resume()
+		execution.paused += pausedFor
+		execution.pausedAt = nil
+		to.logger.WithFields(logrus.Fields{
+			"execution_id": execution.ID,
+			"paused_for":   pausedFor,
+		}).Info("Resumed test execution")
+	}
+
+	result := execution.toModel()
+	return &result, nil
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/metrics"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// pausingPlugin runs until its context's deadline and records whether it is paused
+type pausingPlugin struct {
+	followingPlugin
+	paused *int32
+}
+
+func (pausingPlugin) Name() string { return "pausing" }
+func (p pausingPlugin) SetPaused(paused bool) {
+	var value int32
+	if paused {
+		value = 1
+	}
+	atomic.StoreInt32(p.paused, value)
+}
+
+// rootPlugin declares that it requires root
+type rootPlugin struct{ idlePlugin }
+
+func (rootPlugin) Name() string { return "root" }
+func (rootPlugin) DeclaredCapabilities() models.PluginCapabilities {
+	return models.PluginCapabilities{RequiresRoot: true}
+}
+
+func TestPauseTest(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	paused := new(int32)
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(idlePlugin{})
+	pluginMgr.RegisterPlugin(pausingPlugin{paused: paused})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	orchestrator.SetWatchdog(WatchdogSettings{Enabled: true, MaxDuration: 2 * time.Second, Grace: 100 * time.Millisecond, KillTimeout: time.Second})
+
+	completed := make(chan models.TestExecution, 2)
+	orchestrator.OnExecutionComplete(func(execution models.TestExecution) { completed <- execution })
+	start := func(plugin string) string {
+		t.Helper()
+		id, err := orchestrator.StartTest(models.TestConfiguration{ID: "test-" + plugin, Plugin: plugin}, models.TestParams{Duration: 300 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("StartTest: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		return id
+	}
+
+	id := start("pausing")
+	execution, err := orchestrator.PauseTest(id)
+	if err != nil {
+		t.Fatalf("PauseTest: %v", err)
+	}
+	if atomic.LoadInt32(paused) != 1 || execution.Progress == nil || !execution.Progress.Paused {
+		t.Fatalf("after pausing, plugin paused = %d, progress %+v", atomic.LoadInt32(paused), execution.Progress)
+	}
+	if _, err := orchestrator.PauseTest(id); err != nil {
+		t.Errorf("pausing a paused execution: %v", err)
+	}
+
+	// The pause outlasts the run's duration and watchdog budget
+	time.Sleep(600 * time.Millisecond)
+	select {
+	case execution := <-completed:
+		t.Fatalf("paused execution ended: %s", execution.Status)
+	default:
+	}
+	execution, err = orchestrator.UnpauseTest(id)
+	if err != nil {
+		t.Fatalf("UnpauseTest: %v", err)
+	}
+	if atomic.LoadInt32(paused) != 0 || execution.Progress == nil || execution.Progress.Paused ||
+		execution.Progress.Elapsed > 250*time.Millisecond {
+		t.Errorf("after resuming, plugin paused = %d, progress %+v; want paused time left out", atomic.LoadInt32(paused), execution.Progress)
+	}
+
+	resumed := time.Now()
+	select {
+	case execution := <-completed:
+		if execution.Status != models.StatusCompleted {
+			t.Errorf("execution = %s, want completed", execution.Status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution did not complete")
+	}
+	if ran := time.Since(resumed); ran < 150*time.Millisecond {
+		t.Errorf("execution ended %s after resuming, want the rest of its duration", ran)
+	}
+	if _, err := orchestrator.PauseTest(id); !errors.Is(err, ErrNotPausable) {
+		t.Errorf("pausing a finished execution: %v", err)
+	}
+
+	// Plugins that can't hold their load can't be paused
+	id = start("idle")
+	if _, err := orchestrator.PauseTest(id); !errors.Is(err, ErrNotPausable) {
+		t.Errorf("pausing an idle execution: %v", err)
+	}
+	<-completed
+}
+
+func TestPreflightRequiresRoot(t *testing.T) {
+	defer func(saved func() int) { euid = saved }(euid)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(rootPlugin{})
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	orchestrator.SetPreflight(PreflightSettings{Enabled: true})
+
+	config := models.TestConfiguration{ID: "test", Plugin: "root"}
+	euid = func() int { return 1000 }
+	err := orchestrator.preflight(config, rootPlugin{})
+	if !errors.Is(err, ErrPreflightFailed) || !strings.Contains(err.Error(), "requires root") {
+		t.Errorf("preflight as uid 1000 = %v, want root required", err)
+	}
+
+	for _, uid := range []int{0, -1} {
+		euid = func() int { return uid }
+		if err := orchestrator.preflight(config, rootPlugin{}); err != nil {
+			t.Errorf("preflight as uid %d = %v", uid, err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrPlacementUnavailable is returned by StartTest when a run asks for CPUs, a
+// NUMA node or priorities this machine can't give it
+var ErrPlacementUnavailable = errors.New("placement is not available")
+
+// ErrPlacementConflict is returned by StartTest for a run asking for another
+// placement than the one running tests hold
+var ErrPlacementConflict = errors.New("a running test holds a different placement")
+
+// resolvedPlacement is a placement with its CPUs worked out for this machine
+type resolvedPlacement struct {
+	request models.Placement
+	cpus    []int // nil leaves the affinity unchanged
+}
+
+// String describes the placement, and tells placements apart
+func (p resolvedPlacement) String() string {
+	var parts []string
+	if p.cpus != nil {
+		parts = append(parts, "cpus "+models.FormatCPUList(p.cpus))
+	}
+	if p.request.Nice != 0 {
+		parts = append(parts, fmt.Sprintf("nice %d", p.request.Nice))
+	}
+	if p.request.IOClass != "" {
+		parts = append(parts, fmt.Sprintf("io %s/%d", p.request.IOClass, p.request.IOPriority))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// resolvePlacement checks the machine can give a run its placement, working
+// out the CPUs it names
+func resolvePlacement(placement models.Placement) (resolvedPlacement, error) {
+	resolved := resolvedPlacement{request: placement}
+	if err := placement.Validate(); err != nil {
+		return resolved, err
+	}
+	if !placementSupported {
+		return resolved, fmt.Errorf("%w: placement is only available on Linux", ErrPlacementUnavailable)
+	}
+	if placement.CPUs == "" && placement.NUMANode == nil {
+		return resolved, nil
+	}
+
+	online, err := readCPUList(filepath.Join(sysFS, "devices/system/cpu/online"))
+	if err != nil {
+		return resolved, fmt.Errorf("%w: can't read the online CPUs: %v", ErrPlacementUnavailable, err)
+	}
+	cpus := online
+	if placement.CPUs != "" {
+		requested, _ := models.ParseCPUList(placement.CPUs)
+		if missing := subtractCPUs(requested, online); len(missing) > 0 {
+			return resolved, fmt.Errorf("%w: CPUs %s aren't online", ErrPlacementUnavailable, models.FormatCPUList(missing))
+		}
+		cpus = requested
+	}
+	if node := placement.NUMANode; node != nil {
+		nodeCPUs, err := readCPUList(filepath.Join(sysFS, fmt.Sprintf("devices/system/node/node%d/cpulist", *node)))
+		if err != nil {
+			return resolved, fmt.Errorf("%w: no NUMA node %d", ErrPlacementUnavailable, *node)
+		}
+		cpus = subtractCPUs(cpus, subtractCPUs(cpus, nodeCPUs))
+		if len(cpus) == 0 {
+			return resolved, fmt.Errorf("%w: NUMA node %d has none of CPUs %s", ErrPlacementUnavailable, *node, placement.CPUs)
+		}
+	}
+	resolved.cpus = cpus
+	return resolved, nil
+}
+
+// readCPUList reads a CPU list file from sysfs
+func readCPUList(path string) ([]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return models.ParseCPUList(string(data))
+}
+
+// subtractCPUs returns the CPUs of a that aren't in b, in a's order
+func subtractCPUs(a, b []int) []int {
+	in := make(map[int]bool, len(b))
+	for _, cpu := range b {
+		in[cpu] = true
+	}
+	var rest []int
+	for _, cpu := range a {
+		if !in[cpu] {
+			rest = append(rest, cpu)
+		}
+	}
+	return rest
+}
+
+// processPlacement applies runs' placements to the server process. In-process
+// plugins run on goroutines the Go scheduler moves between all of the process's
+// threads, so the placement is set on every thread, and external plugins
+// inherit it when they are started. Runs asking for the same placement share
+// it; the original settings are restored when the last of them finishes. While
+// one is applied, runs asking for another are rejected, and runs asking for
+// none run with it too.
+type processPlacement struct {
+	mu      sync.Mutex
+	current string // description of the applied placement
+	users   int
+	report  *models.PlacementReport
+	restore func() error
+}
+
+// check rejects a run whose placement can't be applied, or conflicts with the
+// one running tests hold
+func (p *processPlacement) check(placement *models.Placement) error {
+	if placement.Empty() {
+		return nil
+	}
+	resolved, err := resolvePlacement(*placement)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.users > 0 && p.current != resolved.String() {
+		return fmt.Errorf("%w: %s", ErrPlacementConflict, p.current)
+	}
+	return nil
+}
+
+// acquire applies a run's placement, or joins the runs already holding it, and
+// returns what the threads were found running with. The returned function
+// releases the run's hold, restoring the original settings if it was the last;
+// it is never nil.
+func (p *processPlacement) acquire(placement *models.Placement) (func() error, *models.PlacementReport, error) {
+	noop := func() error { return nil }
+	if placement.Empty() {
+		return noop, nil, nil
+	}
+	resolved, err := resolvePlacement(*placement)
+	if err != nil {
+		return noop, nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.users > 0 {
+		if p.current != resolved.String() {
+			return noop, nil, fmt.Errorf("%w: %s", ErrPlacementConflict, p.current)
+		}
+	} else {
+		report, restore, err := placeThreads(resolved)
+		if err != nil {
+			return noop, nil, fmt.Errorf("%w: %v", ErrPlacementUnavailable, err)
+		}
+		p.current, p.report, p.restore = resolved.String(), report, restore
+	}
+	p.users++
+
+	var once sync.Once
+	release := func() error {
+		var err error
+		once.Do(func() { err = p.release() })
+		return err
+	}
+	return release, p.report, nil
+}
+
+// release drops a run's hold, restoring the original settings once no run holds
+// the placement
+func (p *processPlacement) release() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.users--; p.users > 0 {
+		return nil
+	}
+	restore := p.restore
+	p.current, p.report, p.restore = "", nil, nil
+	return restore()
+}
+
+// placementPoint records the placement a run's workload got
+func placementPoint(execution *TestExecution, report *models.PlacementReport) models.MetricPoint {
+	cpus, _ := models.ParseCPUList(report.CPUs)
+	fields := map[string]interface{}{
+		"cpus":        report.CPUs,
+		"cpu_count":   len(cpus),
+		"nice":        report.Nice,
+		"io_class":    report.IOClass,
+		"io_priority": report.IOPriority,
+		"verified":    report.Verified,
+	}
+	if report.NUMANode != nil {
+		fields["numa_node"] = *report.NUMANode
+	}
+	return models.MetricPoint{
+		Timestamp: time.Now(),
+		TestID:    execution.Config.ID,
+		Source:    "orchestrator",
+		Type:      "placement",
+		Tags:      map[string]string{},
+		Fields:    fields,
+	}
+}
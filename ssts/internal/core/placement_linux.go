@@ -0,0 +1,194 @@
+//go:build linux
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Placement relies on Linux thread affinity and I/O priorities
+const placementSupported = true
+
+// ioprio_set and ioprio_get arguments
+const (
+	ioprioWhoProcess = 1 // a thread, by its ID
+	ioprioClassShift = 13
+	ioprioDataMask   = 1<<ioprioClassShift - 1
+)
+
+// ioClasses are the kernel's I/O scheduling classes by name
+var ioClasses = map[string]int{
+	models.IOClassRealtime:   1,
+	models.IOClassBestEffort: 2,
+	models.IOClassIdle:       3,
+}
+
+// threadSettings are the placement settings of one thread
+type threadSettings struct {
+	affinity unix.CPUSet
+	nice     int
+	ioprio   int
+}
+
+// readThread reads a thread's settings
+func readThread(tid int) (threadSettings, error) {
+	var settings threadSettings
+	if err := unix.SchedGetaffinity(tid, &settings.affinity); err != nil {
+		return settings, err
+	}
+	// The system call returns 20 - nice, keeping it positive
+	priority, err := unix.Getpriority(unix.PRIO_PROCESS, tid)
+	if err != nil {
+		return settings, err
+	}
+	settings.nice = 20 - priority
+	ioprio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(tid), 0)
+	if errno != 0 {
+		return settings, errno
+	}
+	settings.ioprio = int(ioprio)
+	return settings, nil
+}
+
+// write applies the settings to a thread
+func (s threadSettings) write(tid int) error {
+	if err := unix.SchedSetaffinity(tid, &s.affinity); err != nil {
+		return fmt.Errorf("failed to set CPU affinity: %w", err)
+	}
+	if err := unix.Setpriority(unix.PRIO_PROCESS, tid, s.nice); err != nil {
+		return fmt.Errorf("failed to set nice: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(s.ioprio)); errno != 0 {
+		return fmt.Errorf("failed to set I/O priority: %w", errno)
+	}
+	return nil
+}
+
+// threadIDs lists the threads of the process
+func threadIDs() ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(procRoot, "self/task"))
+	if err != nil {
+		return nil, err
+	}
+	tids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if tid, err := strconv.Atoi(entry.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids, nil
+}
+
+// setThreads applies settings to every thread of the process, going over them
+// again until no new ones appear as the runtime may start threads meanwhile.
+// Threads started afterwards inherit them from the thread starting them.
+func setThreads(settings threadSettings) error {
+	done := make(map[int]bool)
+	for pass := 0; pass < 5; pass++ {
+		tids, err := threadIDs()
+		if err != nil {
+			return err
+		}
+		added := false
+		for _, tid := range tids {
+			if done[tid] {
+				continue
+			}
+			added = true
+			if err := settings.write(tid); err != nil && !errors.Is(err, unix.ESRCH) {
+				return fmt.Errorf("thread %d: %w", tid, err)
+			}
+			done[tid] = true
+		}
+		if !added {
+			return nil
+		}
+	}
+	return nil
+}
+
+// placeThreads applies a placement to every thread of the process and reads it
+// back from each. It returns what the main thread runs with, verified if every
+// thread runs with the placement, and a function restoring the settings the
+// process had.
+func placeThreads(placement resolvedPlacement) (*models.PlacementReport, func() error, error) {
+	original, err := readThread(unix.Getpid())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the process's settings: %w", err)
+	}
+
+	target := original
+	if placement.cpus != nil {
+		target.affinity.Zero()
+		for _, cpu := range placement.cpus {
+			target.affinity.Set(cpu)
+		}
+	}
+	if placement.request.Nice != 0 {
+		target.nice = placement.request.Nice
+	}
+	if class := placement.request.IOClass; class != "" {
+		target.ioprio = ioClasses[class] << ioprioClassShift
+		if class != models.IOClassIdle {
+			target.ioprio |= placement.request.IOPriority
+		}
+	}
+
+	restore := func() error { return setThreads(original) }
+	if err := setThreads(target); err != nil {
+		restore()
+		return nil, nil, err
+	}
+
+	tids, err := threadIDs()
+	if err != nil {
+		restore()
+		return nil, nil, err
+	}
+	verified := true
+	for _, tid := range tids {
+		settings, err := readThread(tid)
+		if err != nil {
+			if !errors.Is(err, unix.ESRCH) {
+				verified = false
+			}
+			continue
+		}
+		if settings != target {
+			verified = false
+		}
+	}
+
+	actual, err := readThread(unix.Getpid())
+	if err != nil {
+		restore()
+		return nil, nil, err
+	}
+	var cpus []int
+	for cpu := 0; cpu < models.MaxCPUs; cpu++ {
+		if actual.affinity.IsSet(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+	report := &models.PlacementReport{
+		CPUs:     models.FormatCPUList(cpus),
+		NUMANode: placement.request.NUMANode,
+		Nice:     actual.nice,
+		Verified: verified,
+	}
+	for name, class := range ioClasses {
+		if actual.ioprio>>ioprioClassShift == class {
+			report.IOClass = name
+			report.IOPriority = actual.ioprio & ioprioDataMask
+		}
+	}
+	return report, restore, nil
+}
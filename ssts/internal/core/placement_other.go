@@ -0,0 +1,12 @@
+//go:build !linux
+
+package core
+
+import "github.com/pranavgopavaram/ssts/pkg/models"
+
+// Placement relies on Linux thread affinity and I/O priorities
+const placementSupported = false
+
+func placeThreads(resolvedPlacement) (*models.PlacementReport, func() error, error) {
+	return nil, nil, ErrPlacementUnavailable
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestResolvePlacement(t *testing.T) {
+	if !placementSupported {
+		t.Skip("placement is only available on Linux")
+	}
+	root := t.TempDir()
+	defer func(sys string) { sysFS = sys }(sysFS)
+	sysFS = root
+	write := func(path, content string) {
+		path = filepath.Join(root, path)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(content), 0o644)
+	}
+	write("devices/system/cpu/online", "0-5,7\n")
+	write("devices/system/node/node0/cpulist", "0-3\n")
+	write("devices/system/node/node1/cpulist", "4-7\n")
+
+	node := func(n int) *int { return &n }
+	tests := []struct {
+		placement models.Placement
+		want      []int
+		wantErr   bool
+	}{
+		{models.Placement{Nice: 5}, nil, false},
+		{models.Placement{CPUs: "2-4"}, []int{2, 3, 4}, false},
+		{models.Placement{NUMANode: node(1)}, []int{4, 5, 7}, false},
+		{models.Placement{CPUs: "2-5", NUMANode: node(0)}, []int{2, 3}, false},
+		{models.Placement{CPUs: "5-6"}, nil, true},                    // CPU 6 is offline
+		{models.Placement{NUMANode: node(2)}, nil, true},              // no such node
+		{models.Placement{CPUs: "0-1", NUMANode: node(1)}, nil, true}, // node has none of the CPUs
+	}
+	for _, tt := range tests {
+		resolved, err := resolvePlacement(tt.placement)
+		if tt.wantErr {
+			if !errors.Is(err, ErrPlacementUnavailable) {
+				t.Errorf("resolvePlacement(%+v) = %v, want ErrPlacementUnavailable", tt.placement, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(resolved.cpus, tt.want) {
+			t.Errorf("resolvePlacement(%+v) = %v, %v, want %v", tt.placement, resolved.cpus, err, tt.want)
+		}
+	}
+}
+
+func TestProcessPlacementSharedAndRestored(t *testing.T) {
+	if !placementSupported {
+		t.Skip("placement is only available on Linux")
+	}
+	var placement processPlacement
+	// Any process may lower its own I/O priority and raise it back within the
+	// best-effort class, so this needs no privileges
+	lowered := &models.Placement{IOClass: models.IOClassBestEffort, IOPriority: 7}
+
+	release, report, err := placement.acquire(lowered)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if report.IOClass != models.IOClassBestEffort || report.IOPriority != 7 || !report.Verified {
+		t.Errorf("report = %+v, want best-effort 7 on every thread", report)
+	}
+
+	// Runs asking for the same placement share it; others are turned away
+	other := &models.Placement{IOClass: models.IOClassBestEffort, IOPriority: 6}
+	if err := placement.check(other); !errors.Is(err, ErrPlacementConflict) {
+		t.Errorf("check of another placement = %v, want ErrPlacementConflict", err)
+	}
+	if err := placement.check(lowered); err != nil {
+		t.Errorf("check of the same placement = %v", err)
+	}
+	releaseShared, _, err := placement.acquire(lowered)
+	if err != nil {
+		t.Fatalf("acquire of the same placement: %v", err)
+	}
+
+	if err := release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	release() // releasing twice drops one hold
+	if placement.users != 1 {
+		t.Errorf("%d runs hold the placement, want 1", placement.users)
+	}
+	if err := releaseShared(); err != nil {
+		t.Fatalf("release of the last hold: %v", err)
+	}
+	if err := placement.check(other); err != nil {
+		t.Errorf("check after the last release = %v, want the placement free", err)
+	}
+}
@@ -0,0 +1,183 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// SyncPlugins reconciles the plugin manager with the plugins table: built-in plugins
+// get a row so their enabled state can be persisted, external plugins stored in the
+// table are registered, and every plugin's enabled flag is applied. External plugins
+// whose binary is missing or fails its checksum are logged and skipped.
+func (o *Orchestrator) SyncPlugins() error {
+	if o.repo == nil {
+		return nil
+	}
+
+	rows, err := o.repo.ListAllPlugins()
+	if err != nil {
+		return fmt.Errorf("failed to list plugins: %w", err)
+	}
+
+	stored := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		stored[row.Name] = true
+
+		if _, exists := o.pluginManager.GetPlugin(row.Name); !exists {
+			if row.BinaryPath == "" {
+				continue
+			}
+			plugin, err := o.newExternalPlugin(row)
+			if err != nil {
+				o.logger.Error("Failed to load external plugin", zap.String("plugin", row.Name), zap.Error(err))
+				continue
+			}
+			o.pluginManager.RegisterFactory(plugin.Factory())
+		}
+
+		o.pluginManager.SetEnabled(row.Name, row.Enabled)
+	}
+
+	for _, plugin := range o.pluginManager.ListPlugins() {
+		if stored[plugin.Name()] {
+			continue
+		}
+		row := pluginRow(plugin)
+		if err := o.repo.CreatePlugin(&row); err != nil {
+			o.logger.Error("Failed to store plugin", zap.String("plugin", plugin.Name()), zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// RegisterExternalPlugin registers an executable as a plugin without a restart and
+// persists it so it is loaded again on startup
+func (o *Orchestrator) RegisterExternalPlugin(definition models.Plugin) (*plugins.ExternalPlugin, error) {
+	if _, exists := o.pluginManager.GetPlugin(definition.Name); exists {
+		return nil, fmt.Errorf("%w: %s", plugins.ErrPluginExists, definition.Name)
+	}
+
+	plugin, err := o.newExternalPlugin(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.repo != nil {
+		row := pluginRow(plugin)
+		row.BinaryPath = definition.BinaryPath
+		row.Checksum = definition.Checksum
+		if err := o.repo.CreatePlugin(&row); err != nil {
+			return nil, fmt.Errorf("failed to store plugin: %w", err)
+		}
+	}
+
+	o.pluginManager.RegisterFactory(plugin.Factory())
+	o.logger.Info("Registered external plugin", zap.String("plugin", definition.Name), zap.String("binary", definition.BinaryPath))
+	return plugin, nil
+}
+
+// newExternalPlugin creates an external plugin if the configuration allows external
+// plugins and its binary lies in an allowed plugin directory, confined by the
+// plugin sandbox if one is configured
+func (o *Orchestrator) newExternalPlugin(definition models.Plugin) (*plugins.ExternalPlugin, error) {
+	external := o.config.Plugins.External
+	if !external.Enabled {
+		return nil, plugins.ErrExternalDisabled
+	}
+	if err := plugins.CheckBinaryAllowed(definition.BinaryPath, external.AllowedDirs); err != nil {
+		return nil, err
+	}
+	// Refuse to run plugins unconfined when the sandbox was asked for but can't be set up
+	if o.sandboxErr != nil {
+		return nil, fmt.Errorf("plugin sandbox unavailable: %w", o.sandboxErr)
+	}
+
+	plugin, err := plugins.NewExternalPlugin(definition)
+	if err != nil {
+		return nil, err
+	}
+	plugin.SetSandbox(o.sandbox)
+	return plugin, nil
+}
+
+// RemovePlugin unregisters an external plugin and deletes it from the plugins table.
+// Running executions of the plugin are not interrupted. Plugins loaded from a
+// watched plugin directory are removed by deleting their manifest instead.
+func (o *Orchestrator) RemovePlugin(name string) error {
+	plugin, exists := o.pluginManager.GetPlugin(name)
+	if !exists {
+		return plugins.ErrPluginNotFound
+	}
+	if _, external := plugin.(*plugins.ExternalPlugin); !external {
+		return plugins.ErrPluginBuiltin
+	}
+	if o.pluginWatcher != nil && o.pluginWatcher.manages(name) {
+		return plugins.ErrPluginWatched
+	}
+
+	if o.repo != nil {
+		if err := o.repo.DeletePlugin(name); err != nil {
+			return fmt.Errorf("failed to delete plugin: %w", err)
+		}
+	}
+
+	o.logger.Info("Removed external plugin", zap.String("plugin", name))
+	return o.pluginManager.UnregisterPlugin(name)
+}
+
+// SetPluginEnabled enables or disables a plugin and persists the change. Disabling a
+// plugin doesn't stop executions already running; it only refuses new ones.
+func (o *Orchestrator) SetPluginEnabled(name string, enabled bool) error {
+	plugin, exists := o.pluginManager.GetPlugin(name)
+	if !exists {
+		return plugins.ErrPluginNotFound
+	}
+
+	if o.repo != nil {
+		row, err := o.repo.GetPlugin(name)
+		if err != nil {
+			if !errors.Is(err, database.ErrNotFound) {
+				return fmt.Errorf("failed to get plugin: %w", err)
+			}
+			created := pluginRow(plugin)
+			if err := o.repo.CreatePlugin(&created); err != nil {
+				return fmt.Errorf("failed to store plugin: %w", err)
+			}
+			row = &created
+		}
+
+		// Saved separately from the create, since gorm would replace a false
+		// Enabled with the column default on insert
+		row.Enabled = enabled
+		if err := o.repo.UpdatePlugin(row); err != nil {
+			return fmt.Errorf("failed to update plugin: %w", err)
+		}
+	}
+
+	o.logger.Info("Changed plugin state", zap.String("plugin", name), zap.Bool("enabled", enabled))
+	return o.pluginManager.SetEnabled(name, enabled)
+}
+
+// pluginRow builds the plugins table row describing a registered plugin
+func pluginRow(plugin plugins.StressPlugin) models.Plugin {
+	row := models.Plugin{
+		Name:         plugin.Name(),
+		Version:      plugin.Version(),
+		Description:  plugin.Description(),
+		SafetyLimits: plugin.GetSafetyLimits(),
+		Enabled:      true,
+		Capabilities: plugins.Capabilities(plugin),
+	}
+	if schema := plugin.ConfigSchema(); json.Valid(schema) {
+		row.ConfigSchema = schema
+	}
+	return row
+}
@@ -0,0 +1,307 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// pluginManifestPattern matches the manifests describing plugins in a watched directory
+const pluginManifestPattern = "*.plugin.json"
+
+// pluginManifest describes an external plugin kept in a watched plugin directory
+type pluginManifest struct {
+	Name         string              `json:"name"`
+	Version      string              `json:"version"`
+	Description  string              `json:"description"`
+	Binary       string              `json:"binary"`   // relative to the manifest's directory, or absolute
+	Checksum     string              `json:"checksum"` // SHA-256 of the binary
+	ConfigSchema json.RawMessage     `json:"config_schema"`
+	SafetyLimits models.SafetyLimits `json:"safety_limits"`
+	Destructive  bool                `json:"destructive"`   // may destroy data on the machine
+	RequiresRoot bool                `json:"requires_root"` // only runs with root privileges
+}
+
+// readPluginManifest reads a manifest into the plugin definition it describes
+func readPluginManifest(path string) (models.Plugin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return models.Plugin{}, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest pluginManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return models.Plugin{}, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Binary == "" {
+		return models.Plugin{}, fmt.Errorf("manifest has no binary")
+	}
+
+	binary := manifest.Binary
+	if !filepath.IsAbs(binary) {
+		binary = filepath.Join(filepath.Dir(path), binary)
+	}
+	return models.Plugin{
+		Name:         manifest.Name,
+		Version:      manifest.Version,
+		Description:  manifest.Description,
+		ConfigSchema: manifest.ConfigSchema,
+		SafetyLimits: manifest.SafetyLimits,
+		BinaryPath:   binary,
+		Checksum:     manifest.Checksum,
+		Enabled:      true,
+		Capabilities: models.PluginCapabilities{
+			Destructive:  manifest.Destructive,
+			RequiresRoot: manifest.RequiresRoot,
+		},
+	}, nil
+}
+
+// pluginWatcher keeps the plugins described by manifests in the allowed plugin
+// directories registered, rescanning the directories once changes to them settle
+type pluginWatcher struct {
+	orchestrator *Orchestrator
+	dirs         []string
+	debounce     time.Duration
+	watcher      *fsnotify.Watcher
+	done         chan struct{}
+
+	mu        sync.Mutex
+	loaded    map[string]models.Plugin // by manifest path
+	listeners []func(models.PluginsChange)
+}
+
+// newPluginWatcher creates a watcher for the directories; call scan to load the
+// plugins already there and start to follow changes
+func newPluginWatcher(orchestrator *Orchestrator, dirs []string, debounce time.Duration) *pluginWatcher {
+	return &pluginWatcher{
+		orchestrator: orchestrator,
+		dirs:         dirs,
+		debounce:     debounce,
+		done:         make(chan struct{}),
+		loaded:       make(map[string]models.Plugin),
+	}
+}
+
+// start watches the directories, and the directories of loaded plugins' binaries,
+// and rescans them whenever changes settle
+func (w *pluginWatcher) start() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	for _, dir := range w.dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+	w.watcher = watcher
+	w.watchBinaries()
+
+	go w.run()
+	return nil
+}
+
+// stop stops following changes
+func (w *pluginWatcher) stop() {
+	if w.watcher == nil {
+		return
+	}
+	close(w.done)
+	w.watcher.Close()
+}
+
+// run rescans the directories once no change has been seen for the debounce
+// interval, so a manifest and binary copied in one after the other load together
+func (w *pluginWatcher) run() {
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case _, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			timer.Reset(w.debounce)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			// Events may have been dropped, so rescan to be sure
+			w.orchestrator.logger.Warn("Plugin directory watch error", zap.Error(err))
+			timer.Reset(w.debounce)
+		case <-timer.C:
+			change := w.scan()
+			w.watchBinaries()
+			if change.Empty() && len(change.Errors) == 0 {
+				continue
+			}
+			w.mu.Lock()
+			listeners := w.listeners
+			w.mu.Unlock()
+			for _, fn := range listeners {
+				fn(change)
+			}
+		}
+	}
+}
+
+// watchBinaries also watches the directories holding loaded plugins' binaries,
+// so a binary replaced outside its manifest's directory is checked again
+func (w *pluginWatcher) watchBinaries() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, definition := range w.loaded {
+		// Adding a directory already watched does nothing
+		if err := w.watcher.Add(filepath.Dir(definition.BinaryPath)); err != nil {
+			w.orchestrator.logger.Warn("Failed to watch plugin binary directory",
+				zap.String("plugin", definition.Name), zap.Error(err))
+		}
+	}
+}
+
+// onChange registers a function called with every change a rescan makes
+func (w *pluginWatcher) onChange(fn func(models.PluginsChange)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// manages reports whether the named plugin was loaded from a manifest
+func (w *pluginWatcher) manages(name string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, definition := range w.loaded {
+		if definition.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// scan reconciles the registered plugins with the manifests in the directories.
+// New manifests are registered, changed ones re-registered, and plugins whose
+// manifest was deleted are unregistered. Every plugin's binary is checked against
+// its checksum again, and one that no longer matches, or whose manifest became
+// invalid, is unregistered until it is fixed. Plugins registered some other way
+// are never touched; a manifest naming one fails to load.
+func (w *pluginWatcher) scan() models.PluginsChange {
+	o := w.orchestrator
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var manifests []string
+	for _, dir := range w.dirs {
+		matches, err := filepath.Glob(filepath.Join(dir, pluginManifestPattern))
+		if err != nil {
+			continue
+		}
+		manifests = append(manifests, matches...)
+	}
+	sort.Strings(manifests)
+
+	change := models.PluginsChange{}
+	fail := func(manifest string, err error) {
+		if change.Errors == nil {
+			change.Errors = make(map[string]string)
+		}
+		change.Errors[manifest] = err.Error()
+		o.logger.Error("Failed to load plugin manifest", zap.String("manifest", manifest), zap.Error(err))
+	}
+	unload := func(manifest string) {
+		previous := w.loaded[manifest]
+		delete(w.loaded, manifest)
+		if err := o.pluginManager.UnregisterPlugin(previous.Name); err == nil {
+			change.Removed = append(change.Removed, previous.Name)
+			o.logger.Info("Unregistered watched plugin", zap.String("plugin", previous.Name), zap.String("manifest", manifest))
+		}
+	}
+
+	seen := make(map[string]bool, len(manifests))
+	for _, manifest := range manifests {
+		seen[manifest] = true
+		previous, loaded := w.loaded[manifest]
+
+		definition, err := readPluginManifest(manifest)
+		if err == nil && loaded && definition.Name != previous.Name {
+			// Renamed: the old name goes away and the new one is added
+			unload(manifest)
+			loaded = false
+		}
+		if err == nil && !loaded {
+			if _, exists := o.pluginManager.GetPlugin(definition.Name); exists {
+				err = fmt.Errorf("%w: %s", plugins.ErrPluginExists, definition.Name)
+			}
+		}
+		var plugin *plugins.ExternalPlugin
+		if err == nil {
+			plugin, err = o.newExternalPlugin(definition)
+		}
+		if err != nil {
+			if loaded {
+				unload(manifest)
+			}
+			fail(manifest, err)
+			continue
+		}
+
+		switch {
+		case !loaded:
+			o.pluginManager.RegisterFactory(plugin.Factory())
+			o.applyStoredEnabled(definition.Name)
+			change.Added = append(change.Added, definition.Name)
+			o.logger.Info("Registered watched plugin", zap.String("plugin", definition.Name), zap.String("manifest", manifest))
+		case !reflect.DeepEqual(definition, previous):
+			// Re-registering keeps the plugin's enabled state
+			o.pluginManager.RegisterFactory(plugin.Factory())
+			change.Updated = append(change.Updated, definition.Name)
+			o.logger.Info("Re-registered watched plugin", zap.String("plugin", definition.Name), zap.String("manifest", manifest))
+		}
+		w.loaded[manifest] = definition
+	}
+
+	for manifest := range w.loaded {
+		if !seen[manifest] {
+			unload(manifest)
+		}
+	}
+	sort.Strings(change.Removed)
+	return change
+}
+
+// applyStoredEnabled disables a plugin loaded from a manifest if it was disabled
+// before, since manifests don't record whether a plugin is enabled
+func (o *Orchestrator) applyStoredEnabled(name string) {
+	if o.repo == nil {
+		return
+	}
+	row, err := o.repo.GetPlugin(name)
+	if err != nil {
+		return
+	}
+	o.pluginManager.SetEnabled(name, row.Enabled)
+}
+
+// OnPluginsChanged registers a function called whenever watching the plugin
+// directories registers, re-registers or unregisters plugins, or a manifest fails
+// to load. It does nothing unless plugin directories are watched.
+func (o *Orchestrator) OnPluginsChanged(fn func(models.PluginsChange)) {
+	if o.pluginWatcher != nil {
+		o.pluginWatcher.onChange(fn)
+	}
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestPluginWatcher(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Plugins.External = config.ExternalPluginsConfig{Enabled: true, AllowedDirs: []string{dir}, Watch: true, WatchDebounce: 50 * time.Millisecond}
+	orchestrator := &Orchestrator{config: cfg, pluginManager: plugins.NewPluginManager(), logger: zap.NewNop()}
+	orchestrator.pluginManager.RegisterPlugin(plugins.NewCPUStressPlugin())
+
+	writeBinary := func(name, script string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256([]byte(script))
+		return hex.EncodeToString(sum[:])
+	}
+	writeManifest := func(file string, manifest pluginManifest) {
+		data, _ := json.Marshal(manifest)
+		if err := os.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	watcher := newPluginWatcher(orchestrator, []string{dir}, cfg.Plugins.External.WatchDebounce)
+	orchestrator.pluginWatcher = watcher
+	checksum := writeBinary("disk-soak", "#!/bin/sh\nexit 0\n")
+	writeManifest("disk-soak.plugin.json", pluginManifest{Name: "disk_soak", Version: "1.0.0", Binary: "disk-soak", Checksum: checksum})
+	writeManifest("clash.plugin.json", pluginManifest{Name: "cpu-stress", Binary: "disk-soak", Checksum: checksum})
+
+	change := watcher.scan()
+	if !reflect.DeepEqual(change.Added, []string{"disk_soak"}) || len(change.Errors) != 1 {
+		t.Fatalf("first scan = %+v, want disk_soak added and the built-in's name refused", change)
+	}
+	if change := watcher.scan(); !change.Empty() {
+		t.Errorf("rescan without changes = %+v", change)
+	}
+	if err := orchestrator.RemovePlugin("disk_soak"); err != plugins.ErrPluginWatched {
+		t.Errorf("removing a watched plugin: %v, want ErrPluginWatched", err)
+	}
+
+	// A binary replaced without its manifest fails its checksum
+	writeBinary("disk-soak", "#!/bin/sh\nexit 1\n")
+	if change := watcher.scan(); !reflect.DeepEqual(change.Removed, []string{"disk_soak"}) {
+		t.Errorf("scan after the binary changed = %+v, want disk_soak removed", change)
+	}
+
+	// Updating the manifest to match brings it back
+	checksum = writeBinary("disk-soak", "#!/bin/sh\nexit 1\n")
+	writeManifest("disk-soak.plugin.json", pluginManifest{Name: "disk_soak", Version: "1.1.0", Binary: "disk-soak", Checksum: checksum})
+	if change := watcher.scan(); !reflect.DeepEqual(change.Added, []string{"disk_soak"}) {
+		t.Errorf("scan after the manifest was fixed = %+v, want disk_soak added", change)
+	}
+	if plugin, _ := orchestrator.pluginManager.GetPlugin("disk_soak"); plugin == nil || plugin.Version() != "1.1.0" {
+		t.Errorf("disk_soak = %v, want version 1.1.0", plugin)
+	}
+
+	// Followed changes are reported to listeners once they settle
+	changes := make(chan models.PluginsChange, 4)
+	watcher.onChange(func(change models.PluginsChange) { changes <- change })
+	if err := watcher.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.stop()
+	os.Remove(filepath.Join(dir, "clash.plugin.json"))
+	os.Remove(filepath.Join(dir, "disk-soak.plugin.json"))
+
+	select {
+	case change := <-changes:
+		if !reflect.DeepEqual(change.Removed, []string{"disk_soak"}) || len(change.Errors) != 0 {
+			t.Errorf("change = %+v, want disk_soak removed", change)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported after the manifest was deleted")
+	}
+	if _, exists := orchestrator.pluginManager.GetPlugin("disk_soak"); exists {
+		t.Error("disk_soak is still registered")
+	}
+}
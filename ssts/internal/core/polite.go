@@ -0,0 +1,202 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/host"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrPoliteUnsupported is returned by StartTest for a polite run asking to be
+// throttled with a plugin whose load can't be varied while it runs
+var ErrPoliteUnsupported = errors.New("plugin can't be throttled for interactive users")
+
+// interactiveSessions lists the users logged in to the machine. It is a variable
+// so tests can log users in and out.
+var interactiveSessions = loggedInSessions
+
+// politeInterval is how often polite runs look for interactive users
+var politeInterval = 10 * time.Second
+
+// InteractiveUsersError lists the interactive sessions a polite run defers to
+type InteractiveUsersError struct {
+	Sessions []models.InteractiveSession
+}
+
+func (e *InteractiveUsersError) Error() string {
+	users := make([]string, len(e.Sessions))
+	for i, session := range e.Sessions {
+		users[i] = session.String()
+	}
+	return "deferring to interactive users: " + strings.Join(users, ", ")
+}
+
+// loggedInSessions reads the machine's login records
+func loggedInSessions() ([]models.InteractiveSession, error) {
+	users, err := host.Users()
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]models.InteractiveSession, 0, len(users))
+	for _, user := range users {
+		sessions = append(sessions, models.InteractiveSession{
+			User:     user.User,
+			Kind:     sessionKind(user.Terminal, user.Host),
+			Terminal: user.Terminal,
+			Host:     user.Host,
+			Since:    time.Unix(int64(user.Started), 0),
+		})
+	}
+	return sessions, nil
+}
+
+// sessionKind tells a login record's kind of session from its terminal and the
+// host it came from: X sessions are on a display such as :0, whether or not they
+// come from a remote host, and SSH sessions come from a remote host
+func sessionKind(terminal, host string) string {
+	switch {
+	case strings.HasPrefix(terminal, ":") || strings.HasPrefix(host, ":"):
+		return models.SessionX11
+	case host != "":
+		return models.SessionSSH
+	default:
+		return models.SessionTerminal
+	}
+}
+
+// checkPolite verifies the plugin can be throttled if the run's polite policy
+// asks for it
+func checkPolite(plugin plugins.StressPlugin, policy *models.PolitePolicy) error {
+	if !policy.Throttles() {
+		return nil
+	}
+	if _, ok := plugin.(plugins.LoadShaper); !ok {
+		return fmt.Errorf("%w: %s", ErrPoliteUnsupported, plugin.Name())
+	}
+	return nil
+}
+
+// presentFor returns the interactive sessions a polite policy makes way for.
+// Where login records can't be read nobody counts as present.
+func (to *TestOrchestrator) presentFor(policy *models.PolitePolicy) []models.InteractiveSession {
+	sessions, err := interactiveSessions()
+	if err != nil {
+		to.logger.WithError(err).Debug("Failed to list interactive sessions for polite mode")
+		return nil
+	}
+	var present []models.InteractiveSession
+	for _, session := range sessions {
+		if policy.Counts(session) {
+			present = append(present, session)
+		}
+	}
+	return present
+}
+
+// politeBlocker returns an *InteractiveUsersError while a run deferring to
+// interactive users has any to wait for
+func (to *TestOrchestrator) politeBlocker(policy *models.PolitePolicy) error {
+	if !policy.Defers() {
+		return nil
+	}
+	if present := to.presentFor(policy); len(present) > 0 {
+		return &InteractiveUsersError{Sessions: present}
+	}
+	return nil
+}
+
+// notePoliteWait records that a queued execution is deferring to interactive users
+func (to *TestOrchestrator) notePoliteWait(execution *TestExecution, err error) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	if execution.queue != nil {
+		execution.queue.reason = err.Error()
+		execution.queue.preflight = false
+		execution.queue.interactive = true
+	}
+}
+
+// bePolite makes a running execution give way to interactive users as its polite
+// policy says: a throttled run's load level is cut while they are present, and a
+// deferring run is paused until they leave, if its plugin can pause. A run
+// resumed by hand while they are present isn't paused again until they have
+// left and come back. The returned function stops watching.
+func (to *TestOrchestrator) bePolite(execution *TestExecution) func() {
+	policy := execution.params.Polite
+	if policy == nil {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(politeInterval)
+		defer ticker.Stop()
+
+		logger := to.logger.WithField("execution_id", execution.ID)
+		var present, paused, warned bool
+		for {
+			sessions := to.presentFor(policy)
+			arrived := len(sessions) > 0 && !present
+			left := len(sessions) == 0 && present
+			present = len(sessions) > 0
+
+			if arrived {
+				logger.WithField("users", (&InteractiveUsersError{Sessions: sessions}).Error()).Info("Interactive users present, polite run giving way")
+			}
+			if left {
+				logger.Info("Interactive users left, polite run taking up its load again")
+				warned = false
+			}
+
+			if policy.Throttles() {
+				execution.mu.Lock()
+				execution.yielding = present
+				execution.mu.Unlock()
+			} else {
+				switch {
+				case arrived || (present && !paused && !warned):
+					if _, err := to.setPaused(execution.ID, true); err != nil {
+						if !warned {
+							logger.WithError(err).Warn("Polite run can't pause for interactive users, running on")
+							warned = true
+						}
+						break
+					}
+					paused = true
+				case paused && !present:
+					if _, err := to.setPaused(execution.ID, false); err != nil {
+						logger.WithError(err).Warn("Failed to resume polite run after interactive users left")
+					}
+					paused = false
+				case paused:
+					// Resumed by hand while they are still here
+					execution.mu.RLock()
+					paused = execution.pausedAt != nil
+					execution.mu.RUnlock()
+					warned = !paused
+				}
+				execution.mu.Lock()
+				execution.yielding = paused
+				execution.mu.Unlock()
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
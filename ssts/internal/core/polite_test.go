@@ -0,0 +1,207 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/metrics"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// loginRecords stands in for the machine's login records
+type loginRecords struct {
+	mu       sync.Mutex
+	sessions []models.InteractiveSession
+}
+
+func (r *loginRecords) set(sessions ...models.InteractiveSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions = sessions
+}
+
+func (r *loginRecords) list() ([]models.InteractiveSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.InteractiveSession(nil), r.sessions...), nil
+}
+
+func usePoliteStubs(t *testing.T) *loginRecords {
+	t.Helper()
+	savedSessions, savedInterval, savedShape := interactiveSessions, politeInterval, loadShapeInterval
+	t.Cleanup(func() {
+		interactiveSessions, politeInterval, loadShapeInterval = savedSessions, savedInterval, savedShape
+	})
+
+	records := &loginRecords{}
+	interactiveSessions = records.list
+	politeInterval = 10 * time.Millisecond
+	loadShapeInterval = 5 * time.Millisecond
+	return records
+}
+
+func newPoliteOrchestrator(pluginMgr *plugins.PluginManager) *TestOrchestrator {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	return NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+}
+
+func TestPoliteRunDefersToInteractiveUsers(t *testing.T) {
+	records := usePoliteStubs(t)
+	ci := models.InteractiveSession{User: "ci", Kind: models.SessionSSH, Host: "10.0.0.9"}
+	alice := models.InteractiveSession{User: "alice", Kind: models.SessionX11, Terminal: ":0"}
+	records.set(ci, alice)
+
+	paused := new(int32)
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(pausingPlugin{paused: paused})
+	orchestrator := newPoliteOrchestrator(pluginMgr)
+	orchestrator.SetPreflight(PreflightSettings{RetryInterval: 10 * time.Millisecond})
+	completed := make(chan models.TestExecution, 1)
+	orchestrator.OnExecutionComplete(func(execution models.TestExecution) { completed <- execution })
+
+	policy := &models.PolitePolicy{Action: models.PoliteDefer, IgnoreUsers: []string{"ci"}}
+	id, err := orchestrator.StartTest(models.TestConfiguration{ID: "test", Plugin: "pausing"},
+		models.TestParams{Duration: 300 * time.Millisecond, Polite: policy})
+	if err != nil {
+		t.Fatalf("StartTest: %v", err)
+	}
+
+	var queue models.QueueState
+	waitFor(t, "the run to be checked for interactive users", func() bool {
+		queue = orchestrator.GetQueue()
+		return len(queue.Queued) == 1 && queue.Queued[0].Reason != ""
+	})
+	if queue.Queued[0].EstimatedStart != nil ||
+		!strings.Contains(queue.Queued[0].Reason, "alice (x11)") || strings.Contains(queue.Queued[0].Reason, "ci") {
+		t.Fatalf("queue = %+v, want the run deferring to alice alone with no estimate", queue.Queued)
+	}
+
+	// Only the ignored user is left, so the run starts
+	records.set(ci)
+	waitFor(t, "the run to start", func() bool {
+		execution, err := orchestrator.GetTestStatus(id)
+		return err == nil && execution.Status == models.StatusRunning
+	})
+
+	// Alice comes back and the run pauses until she leaves again
+	records.set(ci, alice)
+	waitFor(t, "the run to pause", func() bool {
+		execution, err := orchestrator.GetTestStatus(id)
+		return err == nil && execution.Progress != nil && execution.Progress.Paused && execution.Progress.Yielding
+	})
+	if atomic.LoadInt32(paused) != 1 {
+		t.Error("the plugin wasn't paused")
+	}
+	time.Sleep(400 * time.Millisecond)
+	select {
+	case execution := <-completed:
+		t.Fatalf("execution ended while paused: %s", execution.Status)
+	default:
+	}
+
+	records.set()
+	waitFor(t, "the run to resume", func() bool { return atomic.LoadInt32(paused) == 0 })
+	select {
+	case execution := <-completed:
+		if execution.Status != models.StatusCompleted {
+			t.Errorf("execution = %s, want completed", execution.Status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution did not complete")
+	}
+}
+
+func TestPoliteRunThrottlesForInteractiveUsers(t *testing.T) {
+	records := usePoliteStubs(t)
+	policy := &models.PolitePolicy{ThrottlePercent: 40, Sessions: []string{models.SessionSSH}}
+	if err := checkPolite(idlePlugin{}, policy); !errors.Is(err, ErrPoliteUnsupported) {
+		t.Errorf("throttling a plugin that can't shape its load: %v, want ErrPoliteUnsupported", err)
+	}
+	if err := checkPolite(idlePlugin{}, &models.PolitePolicy{Action: models.PoliteDefer}); err != nil {
+		t.Errorf("deferring a plugin that can't shape its load: %v", err)
+	}
+
+	orchestrator := newPoliteOrchestrator(plugins.NewPluginManager())
+	plugin := shapedPlugin{levels: make(chan int, 100)}
+	execution := &TestExecution{
+		Status:   models.StatusRunning,
+		plugin:   plugin,
+		params:   models.TestParams{Duration: time.Hour, Polite: policy},
+		runStart: time.Now(),
+	}
+	defer shapeLoad(execution)()
+	defer orchestrator.bePolite(execution)()
+
+	// A local terminal login doesn't count for this policy
+	records.set(models.InteractiveSession{User: "bob", Kind: models.SessionTerminal})
+	time.Sleep(50 * time.Millisecond)
+	if level := currentLevel(plugin.levels); level != 100 {
+		t.Errorf("load level %d with only a terminal login, want 100", level)
+	}
+
+	records.set(models.InteractiveSession{User: "alice", Kind: models.SessionSSH, Host: "10.0.0.5"})
+	waitFor(t, "the load to be throttled", func() bool { return currentLevel(plugin.levels) == 40 })
+	execution.mu.RLock()
+	progress := execution.progress(time.Now())
+	execution.mu.RUnlock()
+	if progress == nil || progress.LoadLevel != 40 || !progress.Yielding {
+		t.Errorf("progress %+v, want yielding at load level 40", progress)
+	}
+
+	records.set()
+	waitFor(t, "the load to be restored", func() bool { return currentLevel(plugin.levels) == 100 })
+}
+
+func TestSessionKind(t *testing.T) {
+	for _, tt := range []struct {
+		terminal, host, want string
+	}{
+		{"pts/0", "10.0.0.5", models.SessionSSH},
+		{"tty1", "", models.SessionTerminal},
+		{":0", "", models.SessionX11},
+		{"pts/1", ":0", models.SessionX11},
+		{":0", "10.0.0.5", models.SessionX11},
+	} {
+		if got := sessionKind(tt.terminal, tt.host); got != tt.want {
+			t.Errorf("sessionKind(%q, %q) = %s, want %s", tt.terminal, tt.host, got, tt.want)
+		}
+	}
+}
+
+// currentLevel discards the levels a shapedPlugin was set to so far and returns
+// the next one, or -1 if it isn't set again
+func currentLevel(levels chan int) int {
+	for len(levels) > 0 {
+		<-levels
+	}
+	select {
+	case level := <-levels:
+		return level
+	case <-time.After(time.Second):
+		return -1
+	}
+}
+
+// waitFor polls a condition for up to five seconds
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
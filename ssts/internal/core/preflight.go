@@ -0,0 +1,159 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrPreflightFailed is returned by StartTest when the machine fails the preflight checks
+var ErrPreflightFailed = errors.New("preflight checks failed")
+
+// euid returns the user ID the server runs as, for plugins requiring root
+var euid = os.Geteuid
+
+// PreflightError lists the reasons the machine failed the preflight checks
+type PreflightError struct {
+	Reasons []string
+}
+
+func (e *PreflightError) Error() string {
+	return ErrPreflightFailed.Error() + ": " + strings.Join(e.Reasons, "; ")
+}
+
+func (e *PreflightError) Unwrap() error {
+	return ErrPreflightFailed
+}
+
+// PreflightSettings configure the health checks run before a test starts
+type PreflightSettings struct {
+	Enabled       bool
+	Thresholds    safety.PreflightThresholds
+	Queue         bool          // queue runs failing the checks instead of rejecting them
+	QueueTimeout  time.Duration // fail a queued run after this long; 0 for no limit
+	RetryInterval time.Duration // how often queued runs are re-checked
+}
+
+// SetPreflight configures the checks run before a test starts
+func (to *TestOrchestrator) SetPreflight(settings PreflightSettings) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.preflightSettings = settings
+}
+
+// preflight checks the machine can take a test: it isn't already loaded or cooling
+// down, the server has root if the plugin requires it, and the machine meets the
+// plugin's own requirements. It returns a *PreflightError with
+// every failed check, or nil if all passed or the checks are disabled.
+func (to *TestOrchestrator) preflight(config models.TestConfiguration, plugin plugins.StressPlugin) error {
+	to.mu.RLock()
+	settings := to.preflightSettings
+	to.mu.RUnlock()
+
+	if !settings.Enabled {
+		return nil
+	}
+
+	reasons := to.safetyMonitor.Preflight(settings.Thresholds)
+
+	if plugins.Capabilities(plugin).RequiresRoot {
+		// Not known on platforms without user IDs
+		if uid := euid(); uid > 0 {
+			reasons = append(reasons, fmt.Sprintf("%s: requires root, running as uid %d", config.Plugin, uid))
+		}
+	}
+
+	if preflighter, ok := plugin.(plugins.Preflighter); ok {
+		var pluginConfig interface{}
+		var err error
+		if len(config.Config) > 0 {
+			err = json.Unmarshal(config.Config, &pluginConfig)
+		}
+		if err == nil {
+			err = preflighter.Preflight(pluginConfig)
+		}
+		if err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %v", config.Plugin, err))
+		}
+	}
+
+	if len(reasons) > 0 {
+		return &PreflightError{Reasons: reasons}
+	}
+	return nil
+}
+
+// runWhenReady holds a queued execution until it passes the preflight checks and
+// the resources it claims are free, then runs it. It gives up when the execution
+// is stopped or the queue timeout passes.
+func (to *TestOrchestrator) runWhenReady(execution *TestExecution, plugin plugins.StressPlugin, params models.TestParams) {
+	to.mu.RLock()
+	settings := to.preflightSettings
+	to.mu.RUnlock()
+
+	retry := settings.RetryInterval
+	if retry <= 0 {
+		retry = 15 * time.Second
+	}
+	ticker := time.NewTicker(retry)
+	defer ticker.Stop()
+
+	var expired <-chan time.Time
+	if settings.QueueTimeout > 0 {
+		timer := time.NewTimer(settings.QueueTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	defer to.leaveQueue(execution)
+	ready := func() error {
+		if err := to.politeBlocker(params.Polite); err != nil {
+			to.notePoliteWait(execution, err)
+			return err
+		}
+		if err := to.preflight(execution.Config, plugin); err != nil {
+			to.notePreflightWait(execution, err)
+			return err
+		}
+		return to.startQueued(execution)
+	}
+
+	err := ready()
+	for err != nil {
+		select {
+		case <-execution.Context.Done():
+			to.finishTestWithStatus(execution, models.StatusStopped)
+			to.complete(execution)
+			close(execution.done)
+			return
+		case <-expired:
+			to.finishTestWithError(execution, models.FailureSetup, fmt.Errorf("not started within %s: %w", settings.QueueTimeout, err))
+			to.complete(execution)
+			close(execution.done)
+			return
+		case <-ticker.C:
+			err = ready()
+		}
+	}
+
+	execution.mu.Lock()
+	execution.Status = models.StatusPending
+	if execution.soak != nil {
+		// Time spent queued isn't run time
+		now := time.Now()
+		execution.soak.segmentStart = now
+		execution.soak.foldedAt = now
+	}
+	execution.mu.Unlock()
+
+	to.logger.WithField("execution_id", execution.ID).Info("Queued test execution is ready, starting")
+	go to.watch(execution, params)
+	to.executeTest(execution, plugin, params)
+}
@@ -0,0 +1,233 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrPrerequisitesFailed is returned by StartTest when a test's prerequisites aren't met
+var ErrPrerequisitesFailed = errors.New("prerequisites not met")
+
+// PrerequisiteFailure is a prerequisite that isn't met and why
+type PrerequisiteFailure struct {
+	Prerequisite string `json:"prerequisite"` // e.g. plugin io-stress >= 1.2.0
+	Reason       string `json:"reason"`
+}
+
+// PrerequisiteError lists the prerequisites a test failed
+type PrerequisiteError struct {
+	Failures []PrerequisiteFailure
+}
+
+func (e *PrerequisiteError) Error() string {
+	reasons := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		reasons[i] = failure.Prerequisite + ": " + failure.Reason
+	}
+	return ErrPrerequisitesFailed.Error() + ": " + strings.Join(reasons, "; ")
+}
+
+func (e *PrerequisiteError) Unwrap() error {
+	return ErrPrerequisitesFailed
+}
+
+// ValidatePrerequisites checks prerequisites are well formed, including sizes
+func ValidatePrerequisites(prerequisites models.Prerequisites) error {
+	if err := prerequisites.Validate(); err != nil {
+		return err
+	}
+	for i, requirement := range prerequisites.MinFreeDisk {
+		if _, err := plugins.ParseSize(requirement.MinFree); err != nil {
+			return fmt.Errorf("min_free_disk[%d]: invalid min_free: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// prerequisiteEnv is what prerequisites are checked against. The lookups are
+// fields so tests can check prerequisites without a database or real machine.
+type prerequisiteEnv struct {
+	now           time.Time
+	pluginManager *plugins.PluginManager
+	executions    func(testID string, since *time.Time) ([]models.TestExecution, error)
+	freeSpace     func(path string) (uint64, error)
+	kernelParam   func(name string) (string, error)
+}
+
+// procSys is where kernel parameters are read from
+var procSys = "/proc/sys"
+
+// CheckPrerequisites checks a test's prerequisites against this machine and the
+// execution history, returning a *PrerequisiteError listing every one not met
+func (o *Orchestrator) CheckPrerequisites(config models.TestConfiguration) error {
+	if config.Prerequisites.Empty() {
+		return nil
+	}
+
+	env := prerequisiteEnv{
+		now:           time.Now(),
+		pluginManager: o.pluginManager,
+		executions: func(testID string, since *time.Time) ([]models.TestExecution, error) {
+			if o.repo == nil {
+				return nil, errors.New("execution history is not available")
+			}
+			return o.repo.ListCompletedExecutions(testID, since)
+		},
+		freeSpace: func(path string) (uint64, error) {
+			usage, err := disk.Usage(path)
+			if err != nil {
+				return 0, err
+			}
+			return usage.Free, nil
+		},
+		kernelParam: readKernelParam,
+	}
+	return env.check(config.Prerequisites)
+}
+
+// check evaluates every prerequisite, so the caller learns all that failed at once
+func (env prerequisiteEnv) check(prerequisites models.Prerequisites) error {
+	var failures []PrerequisiteFailure
+	fail := func(prerequisite, format string, args ...interface{}) {
+		failures = append(failures, PrerequisiteFailure{Prerequisite: prerequisite, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	for _, requirement := range prerequisites.Tests {
+		name := "test " + requirement.TestID + " passed"
+		var since *time.Time
+		if requirement.Within > 0 {
+			name += " within " + requirement.Within.String()
+			start := env.now.Add(-requirement.Within)
+			since = &start
+		}
+		executions, err := env.executions(requirement.TestID, since)
+		if err != nil {
+			fail(name, "failed to look up executions: %v", err)
+			continue
+		}
+		if !anyPassed(executions) {
+			fail(name, "no passing execution found")
+		}
+	}
+
+	for _, requirement := range prerequisites.Plugins {
+		name := "plugin " + requirement.Name
+		if requirement.MinVersion != "" {
+			name += " >= " + requirement.MinVersion
+		}
+		plugin, ok := env.pluginManager.GetPlugin(requirement.Name)
+		switch {
+		case !ok:
+			fail(name, "not registered")
+		case !env.pluginManager.IsEnabled(requirement.Name):
+			fail(name, "disabled")
+		case requirement.MinVersion != "" && compareVersions(plugin.Version(), requirement.MinVersion) < 0:
+			fail(name, "version %s is installed", plugin.Version())
+		}
+	}
+
+	for _, requirement := range prerequisites.MinFreeDisk {
+		name := requirement.MinFree + " free on " + requirement.Path
+		required, err := plugins.ParseSize(requirement.MinFree)
+		if err != nil {
+			fail(name, "invalid size: %v", err)
+			continue
+		}
+		free, err := env.freeSpace(requirement.Path)
+		if err != nil {
+			fail(name, "failed to read free space: %v", err)
+			continue
+		}
+		if free < uint64(required) {
+			fail(name, "only %d MB free", free/(1024*1024))
+		}
+	}
+
+	for _, requirement := range prerequisites.KernelParams {
+		name := requirement.Name + " = " + requirement.Value
+		value, err := env.kernelParam(requirement.Name)
+		if err != nil {
+			fail(name, "failed to read: %v", err)
+			continue
+		}
+		if strings.Join(strings.Fields(value), " ") != strings.Join(strings.Fields(requirement.Value), " ") {
+			fail(name, "value is %s", strings.TrimSpace(value))
+		}
+	}
+
+	if len(failures) > 0 {
+		return &PrerequisiteError{Failures: failures}
+	}
+	return nil
+}
+
+// anyPassed reports whether any of the completed executions passed: finished
+// without failing the hardware profile it was graded against or its test's SLA
+func anyPassed(executions []models.TestExecution) bool {
+	for _, execution := range executions {
+		if execution.Status != models.StatusCompleted {
+			continue
+		}
+		var summary models.ExecutionSummary
+		if len(execution.Summary) > 0 && json.Unmarshal(execution.Summary, &summary) == nil &&
+			((summary.Grade != nil && !summary.Grade.Passed) || (summary.SLA != nil && !summary.SLA.Passed)) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// readKernelParam reads a sysctl such as vm.swappiness from /proc/sys
+func readKernelParam(name string) (string, error) {
+	if name == "" || strings.Contains(name, "..") {
+		return "", fmt.Errorf("invalid kernel parameter %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(procSys, strings.ReplaceAll(name, ".", "/")))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// compareVersions compares dotted versions such as 1.10.2 and v1.9, returning
+// -1, 0 or 1. Missing components count as 0; anything after a component's
+// leading digits, such as -rc1, is ignored.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, y := versionComponent(as, i), versionComponent(bs, i)
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionComponent(components []string, i int) int {
+	if i >= len(components) {
+		return 0
+	}
+	component := components[i]
+	end := 0
+	for end < len(component) && component[end] >= '0' && component[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(component[:end])
+	return n
+}
@@ -0,0 +1,275 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrCommandProbesDisabled is returned by StartTest for a test with command
+// probes when they aren't allowed
+var ErrCommandProbesDisabled = errors.New("command probes are disabled")
+
+// ProbeSettings configure victim probes, which measure services sharing the
+// machine before and while a test loads it
+type ProbeSettings struct {
+	Enabled         bool
+	BaselineSamples int  // taken from each probe before the plugin starts
+	AllowCommands   bool // command probes run with the server's privileges
+}
+
+// SetProbes configures victim probes
+func (to *TestOrchestrator) SetProbes(settings ProbeSettings) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.probeSettings = settings
+}
+
+// ValidateProbes checks a test's victim probes are well formed and, for
+// command probes, allowed
+func ValidateProbes(probes models.VictimProbes, allowCommands bool) error {
+	if err := probes.Validate(); err != nil {
+		return err
+	}
+	for _, probe := range probes {
+		if probe.Type == models.ProbeCommand && !allowCommands {
+			return fmt.Errorf("%w: probe %s", ErrCommandProbesDisabled, probe.Name)
+		}
+	}
+	return nil
+}
+
+// checkProbes rejects a test whose probes can't run
+func (to *TestOrchestrator) checkProbes(probes models.VictimProbes) error {
+	to.mu.RLock()
+	settings := to.probeSettings
+	to.mu.RUnlock()
+	if !settings.Enabled {
+		return nil
+	}
+	return ValidateProbes(probes, settings.AllowCommands)
+}
+
+// probeSample is one measurement of a victim probe
+type probeSample struct {
+	latency float64 // milliseconds
+	ok      bool
+	cpu     float64 // machine CPU usage when it was taken
+}
+
+// startProbes samples an execution's victim probes for a baseline, then keeps
+// sampling them every interval until the returned function is called, which
+// returns their summaries. Each sample under load is also recorded as a metric
+// point so it can be charted against the load.
+func (to *TestOrchestrator) startProbes(execution *TestExecution) func() map[string]models.ProbeSummary {
+	to.mu.RLock()
+	settings := to.probeSettings
+	to.mu.RUnlock()
+	probes := execution.Config.Probes
+	if !settings.Enabled || len(probes) == 0 {
+		return func() map[string]models.ProbeSummary { return nil }
+	}
+
+	baseline := make([][]probeSample, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func(i int, probe models.VictimProbe) {
+			defer wg.Done()
+			for n := 0; n < settings.BaselineSamples && execution.Context.Err() == nil; n++ {
+				baseline[i] = append(baseline[i], to.sampleProbe(execution.Context, probe))
+			}
+		}(i, probe)
+	}
+	wg.Wait()
+
+	ctx, cancel := context.WithCancel(execution.Context)
+	loaded := make([][]probeSample, len(probes))
+	for i, probe := range probes {
+		wg.Add(1)
+		go func(i int, probe models.VictimProbe) {
+			defer wg.Done()
+			interval := probe.Interval
+			if interval <= 0 {
+				interval = models.DefaultProbeInterval
+			}
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tags := map[string]string{"execution_id": execution.ID, "probe_type": probe.Type}
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				sample := to.sampleProbe(ctx, probe)
+				if ctx.Err() != nil {
+					// Cut short by the load ending, so not a measurement under load
+					return
+				}
+				loaded[i] = append(loaded[i], sample)
+				to.recordMetric(execution, probePoint(execution, probe, tags, sample))
+			}
+		}(i, probe)
+	}
+
+	return func() map[string]models.ProbeSummary {
+		cancel()
+		wg.Wait()
+		summaries := make(map[string]models.ProbeSummary, len(probes))
+		for i, probe := range probes {
+			summaries[probe.Name] = summarizeProbe(probe.Type, baseline[i], loaded[i])
+		}
+		return summaries
+	}
+}
+
+// sampleProbe measures a probe once, along with the CPU usage at the time
+func (to *TestOrchestrator) sampleProbe(ctx context.Context, probe models.VictimProbe) probeSample {
+	latency, err := measureProbe(ctx, probe)
+	return probeSample{
+		latency: float64(latency) / float64(time.Millisecond),
+		ok:      err == nil,
+		cpu:     to.metricsCollector.CollectSystemMetrics().CPU.UsagePercent,
+	}
+}
+
+// measureProbe returns how long a probe took to answer, or why it didn't
+func measureProbe(ctx context.Context, probe models.VictimProbe) (time.Duration, error) {
+	timeout := probe.Timeout
+	if timeout <= 0 {
+		timeout = models.DefaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	switch probe.Type {
+	case models.ProbeHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, probe.Target, nil)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return 0, fmt.Errorf("%s: %s", probe.Target, resp.Status)
+		}
+	case models.ProbeTCP:
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", probe.Target)
+		if err != nil {
+			return 0, err
+		}
+		conn.Close()
+	case models.ProbeCommand:
+		if err := exec.CommandContext(ctx, "sh", "-c", probe.Target).Run(); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("unknown probe type %q", probe.Type)
+	}
+	return time.Since(start), nil
+}
+
+// probePoint is the metric point recording a probe sample, tagged with tags,
+// which the probe's points share
+func probePoint(execution *TestExecution, probe models.VictimProbe, tags map[string]string, sample probeSample) models.MetricPoint {
+	return models.MetricPoint{
+		Timestamp: time.Now(),
+		TestID:    execution.Config.ID,
+		Source:    "probe",
+		Type:      probe.Name,
+		Tags:      tags,
+		Fields:    models.ProbeFields{OK: sample.ok, LatencyMs: sample.latency}.Fields(),
+	}
+}
+
+// summarizeProbe compares a probe's latency under load with its baseline
+func summarizeProbe(probeType string, baseline, loaded []probeSample) models.ProbeSummary {
+	summary := models.ProbeSummary{
+		Type:      probeType,
+		Baseline:  probeLatency(baseline),
+		UnderLoad: probeLatency(loaded),
+	}
+	if summary.Baseline.AvgMs > 0 && summary.UnderLoad.Samples > summary.UnderLoad.Failures {
+		summary.Slowdown = summary.UnderLoad.AvgMs / summary.Baseline.AvgMs
+	}
+
+	var latencies, cpu []float64
+	for _, sample := range loaded {
+		if sample.ok {
+			latencies = append(latencies, sample.latency)
+			cpu = append(cpu, sample.cpu)
+		}
+	}
+	summary.CPUCorrelation = correlation(latencies, cpu)
+	return summary
+}
+
+// probeLatency summarizes probe samples
+func probeLatency(samples []probeSample) models.ProbeLatency {
+	latency := models.ProbeLatency{Samples: len(samples)}
+	var succeeded []float64
+	for _, sample := range samples {
+		if sample.ok {
+			succeeded = append(succeeded, sample.latency)
+		} else {
+			latency.Failures++
+		}
+	}
+	if len(succeeded) == 0 {
+		return latency
+	}
+
+	sort.Float64s(succeeded)
+	var sum float64
+	for _, value := range succeeded {
+		sum += value
+	}
+	latency.AvgMs = sum / float64(len(succeeded))
+	latency.P95Ms = succeeded[int(float64(len(succeeded)-1)*0.95)]
+	latency.MaxMs = succeeded[len(succeeded)-1]
+	return latency
+}
+
+// correlation returns the Pearson correlation coefficient of two series, or nil
+// when there are fewer than three pairs or either series is constant
+func correlation(xs, ys []float64) *float64 {
+	n := len(xs)
+	if n < 3 || n != len(ys) {
+		return nil
+	}
+
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/float64(n), sumY/float64(n)
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return nil
+	}
+	r := cov / math.Sqrt(varX*varY)
+	return &r
+}
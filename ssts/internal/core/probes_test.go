@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/metrics"
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestValidateProbes(t *testing.T) {
+	web := models.VictimProbe{Name: "web", Type: models.ProbeHTTP, Target: "http://localhost/health"}
+	command := models.VictimProbe{Name: "db", Type: models.ProbeCommand, Target: "psql -c 'select 1'"}
+
+	tests := []struct {
+		name          string
+		probes        models.VictimProbes
+		allowCommands bool
+		wantErr       bool
+	}{
+		{"none", nil, false, false},
+		{"http", models.VictimProbes{web}, false, false},
+		{"commands allowed", models.VictimProbes{web, command}, true, false},
+		{"commands disabled", models.VictimProbes{command}, false, true},
+		{"unnamed", models.VictimProbes{{Type: models.ProbeTCP, Target: "localhost:5432"}}, false, true},
+		{"name used twice", models.VictimProbes{web, web}, false, true},
+		{"unknown type", models.VictimProbes{{Name: "ping", Type: "icmp", Target: "localhost"}}, false, true},
+		{"no target", models.VictimProbes{{Name: "db", Type: models.ProbeTCP}}, false, true},
+		{"negative interval", models.VictimProbes{{Name: "db", Type: models.ProbeTCP, Target: "localhost:5432", Interval: -time.Second}}, false, true},
+	}
+	for _, tt := range tests {
+		err := ValidateProbes(tt.probes, tt.allowCommands)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if err := ValidateProbes(models.VictimProbes{command}, false); !errors.Is(err, ErrCommandProbesDisabled) {
+		t.Errorf("command probe: error = %v, want ErrCommandProbesDisabled", err)
+	}
+}
+
+func TestMeasureProbe(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().String()
+	listener.Close()
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	tests := []struct {
+		name    string
+		probe   models.VictimProbe
+		wantErr bool
+	}{
+		{"http", models.VictimProbe{Type: models.ProbeHTTP, Target: healthy.URL}, false},
+		{"http server error", models.VictimProbe{Type: models.ProbeHTTP, Target: failing.URL}, true},
+		{"http timeout", models.VictimProbe{Type: models.ProbeHTTP, Target: slow.URL, Timeout: 20 * time.Millisecond}, true},
+		{"tcp", models.VictimProbe{Type: models.ProbeTCP, Target: listener.Addr().String()}, false},
+		{"tcp refused", models.VictimProbe{Type: models.ProbeTCP, Target: closed}, true},
+		{"command", models.VictimProbe{Type: models.ProbeCommand, Target: "exit 0"}, false},
+		{"command failing", models.VictimProbe{Type: models.ProbeCommand, Target: "exit 3"}, true},
+		{"unknown type", models.VictimProbe{Type: "icmp", Target: "localhost"}, true},
+	}
+	for _, tt := range tests {
+		latency, err := measureProbe(context.Background(), tt.probe)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil && latency <= 0 {
+			t.Errorf("%s: latency = %s", tt.name, latency)
+		}
+	}
+}
+
+func TestSummarizeProbe(t *testing.T) {
+	baseline := []probeSample{{latency: 10, ok: true}, {latency: 20, ok: true}, {ok: false}}
+	loaded := []probeSample{{latency: 20, ok: true, cpu: 20}, {latency: 40, ok: true, cpu: 40}, {latency: 60, ok: true, cpu: 60}, {ok: false, cpu: 90}}
+
+	summary := summarizeProbe(models.ProbeHTTP, baseline, loaded)
+	if summary.Baseline != (models.ProbeLatency{Samples: 3, Failures: 1, AvgMs: 15, P95Ms: 10, MaxMs: 20}) {
+		t.Errorf("baseline = %+v", summary.Baseline)
+	}
+	if summary.UnderLoad != (models.ProbeLatency{Samples: 4, Failures: 1, AvgMs: 40, P95Ms: 40, MaxMs: 60}) {
+		t.Errorf("under load = %+v", summary.UnderLoad)
+	}
+	if math.Abs(summary.Slowdown-40.0/15) > 1e-9 {
+		t.Errorf("slowdown = %v, want %v", summary.Slowdown, 40.0/15)
+	}
+	if summary.CPUCorrelation == nil || math.Abs(*summary.CPUCorrelation-1) > 1e-9 {
+		t.Errorf("CPU correlation = %v, want 1", summary.CPUCorrelation)
+	}
+
+	// Nothing to compare against when every baseline sample failed
+	if summary := summarizeProbe(models.ProbeTCP, []probeSample{{ok: false}}, loaded); summary.Slowdown != 0 {
+		t.Errorf("slowdown without a baseline = %v", summary.Slowdown)
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	tests := []struct {
+		name   string
+		xs, ys []float64
+		want   *float64
+	}{
+		{"too few pairs", []float64{1, 2}, []float64{1, 2}, nil},
+		{"mismatched lengths", []float64{1, 2, 3}, []float64{1, 2}, nil},
+		{"constant series", []float64{1, 2, 3}, []float64{5, 5, 5}, nil},
+		{"positive", []float64{1, 2, 3}, []float64{2, 4, 6}, ptr(1.0)},
+		{"negative", []float64{1, 2, 3}, []float64{3, 2, 1}, ptr(-1.0)},
+		{"none", []float64{1, 2, 3, 4}, []float64{1, -1, -1, 1}, ptr(0.0)},
+	}
+	for _, tt := range tests {
+		got := correlation(tt.xs, tt.ys)
+		if (got == nil) != (tt.want == nil) || (got != nil && math.Abs(*got-*tt.want) > 1e-9) {
+			t.Errorf("%s: correlation = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProbesSampledDuringExecution(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	pluginMgr := plugins.NewPluginManager()
+	pluginMgr.RegisterPlugin(idlePlugin{})
+	monitor := safety.NewMonitor(&loadedSystem{}, safety.NewAlertManager(logger), safety.Config{}, logger)
+	orchestrator := NewTestOrchestrator(pluginMgr, monitor, metrics.NewCollector(zap.NewNop()), logger)
+	orchestrator.SetProbes(ProbeSettings{Enabled: true, BaselineSamples: 2})
+
+	// Command probes are turned away unless allowed
+	command := models.TestConfiguration{ID: "test", Plugin: "idle", Probes: models.VictimProbes{{Name: "db", Type: models.ProbeCommand, Target: "true"}}}
+	if _, err := orchestrator.StartTest(command, models.TestParams{Duration: time.Second}); !errors.Is(err, ErrCommandProbesDisabled) {
+		t.Fatalf("StartTest with a command probe: error = %v, want ErrCommandProbesDisabled", err)
+	}
+
+	done := make(chan models.TestExecution, 1)
+	orchestrator.OnExecutionComplete(func(execution models.TestExecution) { done <- execution })
+	test := models.TestConfiguration{ID: "test", Plugin: "idle", Probes: models.VictimProbes{
+		{Name: "web", Type: models.ProbeHTTP, Target: server.URL, Interval: 20 * time.Millisecond},
+	}}
+	id, err := orchestrator.StartTest(test, models.TestParams{Duration: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var execution models.TestExecution
+	select {
+	case execution = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution didn't finish")
+	}
+	var summary models.ExecutionSummary
+	if err := json.Unmarshal(execution.Summary, &summary); err != nil {
+		t.Fatal(err)
+	}
+	web, ok := summary.Probes["web"]
+	if !ok || web.Type != models.ProbeHTTP || web.Baseline.Samples != 2 || web.UnderLoad.Samples == 0 || web.UnderLoad.Failures != 0 {
+		t.Errorf("probe summary = %+v", summary.Probes)
+	}
+	if stats := summary.Metrics["probe.web.ok"]; stats.Count != web.UnderLoad.Samples || stats.Avg != 1 {
+		t.Errorf("probe metric points = %+v, want one per sample under load", stats)
+	}
+
+	metrics, _ := orchestrator.GetTestMetrics(id, models.TimeRange{})
+	for _, point := range metrics {
+		if point.Source == "probe" && point.Tags["execution_id"] != id {
+			t.Errorf("probe point tags = %v", point.Tags)
+		}
+	}
+}
+
+func ptr(f float64) *float64 { return &f }
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrNoMatchingProfile is returned by GradeExecution when no hardware profile matches the execution
+var ErrNoMatchingProfile = errors.New("no matching hardware profile")
+
+// MatchProfile returns the profile for plugin whose match labels are all among
+// labels, preferring the most specific one. It returns nil if none matches.
+func MatchProfile(profiles []models.HardwareProfile, plugin string, labels models.Labels) *models.HardwareProfile {
+	var best *models.HardwareProfile
+	for i := range profiles {
+		profile := &profiles[i]
+		if profile.Plugin != plugin || !matchesLabels(profile.Match, labels) {
+			continue
+		}
+		if best == nil || len(profile.Match) > len(best.Match) ||
+			(len(profile.Match) == len(best.Match) && profile.Name < best.Name) {
+			best = profile
+		}
+	}
+	return best
+}
+
+// matchesLabels reports whether labels has every key=value of match
+func matchesLabels(match, labels models.Labels) bool {
+	for key, value := range match {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// GradeSummary compares an execution's summary with each of the profile's
+// expectations. A check fails when the result is worse than expected by more than
+// the profile's margin, or defaultMargin if it sets none, or isn't in the summary.
+func GradeSummary(summary models.ExecutionSummary, profile models.HardwareProfile, defaultMargin float64) models.ProfileGrade {
+	margin := defaultMargin
+	if profile.MarginPercent != nil {
+		margin = *profile.MarginPercent
+	}
+
+	grade := models.ProfileGrade{
+		ProfileID:     profile.ID,
+		ProfileName:   profile.Name,
+		MarginPercent: margin,
+		Passed:        true,
+		Checks:        make([]models.ProfileCheck, 0, len(profile.Expectations)),
+	}
+
+	for _, expectation := range profile.Expectations {
+		check := models.ProfileCheck{
+			Metric:   expectation.Metric,
+			Stat:     expectation.Stat,
+			Expected: expectation.Expected,
+		}
+		if check.Stat == "" {
+			check.Stat = models.ProfileStatAvg
+		}
+
+		actual, ok := summaryStat(summary, expectation.Metric, check.Stat)
+		if !ok {
+			check.Missing = true
+		} else {
+			check.Actual = actual
+			check.DeviationPercent = (actual - expectation.Expected) / expectation.Expected * 100
+			if expectation.LowerIsBetter {
+				check.DeviationPercent = -check.DeviationPercent
+			}
+			check.Passed = check.DeviationPercent >= -margin
+		}
+
+		if !check.Passed {
+			grade.Passed = false
+		}
+		grade.Checks = append(grade.Checks, check)
+	}
+
+	return grade
+}
+
+// summaryStat looks up one figure of a summary
+func summaryStat(summary models.ExecutionSummary, metric, stat string) (float64, bool) {
+	if stat == models.ProfileStatTotal {
+		value, ok := summary.Totals[metric]
+		return value, ok
+	}
+
+	stats, ok := summary.Metrics[metric]
+	if !ok || stats.Count == 0 {
+		return 0, false
+	}
+	switch stat {
+	case models.ProfileStatMin:
+		return stats.Min, true
+	case models.ProfileStatMax:
+		return stats.Max, true
+	case models.ProfileStatP95:
+		return stats.P95, true
+	case models.ProfileStatP99:
+		return stats.P99, true
+	default:
+		return stats.Avg, true
+	}
+}
+
+// GradeExecution grades a finished execution against the hardware profile matching
+// its test's plugin and its labels. It returns ErrNoMatchingProfile if there is none.
+func GradeExecution(repo database.Repository, execution *models.TestExecution, defaultMargin float64) (*models.ProfileGrade, error) {
+	if len(execution.Summary) == 0 {
+		return nil, errors.New("execution has no summary")
+	}
+	var summary models.ExecutionSummary
+	if err := json.Unmarshal(execution.Summary, &summary); err != nil {
+		return nil, fmt.Errorf("failed to read summary: %w", err)
+	}
+
+	test, err := repo.GetTestConfiguration(execution.TestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get test: %w", err)
+	}
+	profiles, err := repo.ListHardwareProfiles(test.Plugin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hardware profiles: %w", err)
+	}
+
+	profile := MatchProfile(profiles, test.Plugin, execution.Labels)
+	if profile == nil {
+		return nil, ErrNoMatchingProfile
+	}
+
+	grade := GradeSummary(summary, *profile, defaultMargin)
+	return &grade, nil
+}
+
+// gradeFinishedExecution stores the grade of a completed execution in its summary,
+// raising an alert if the hardware underperforms its profile. Failed and stopped
+// runs aren't graded since their results don't reflect the hardware.
+func (o *Orchestrator) gradeFinishedExecution(execution *models.TestExecution, alertManager safety.AlertManager) {
+	if !o.config.Profiles.Enabled || execution.Status != models.StatusCompleted || len(execution.Summary) == 0 {
+		return
+	}
+
+	grade, err := GradeExecution(o.repo, execution, o.config.Profiles.DefaultMarginPercent)
+	if err != nil {
+		if !errors.Is(err, ErrNoMatchingProfile) {
+			o.logger.Warn("Failed to grade execution", zap.String("execution_id", execution.ID), zap.Error(err))
+		}
+		return
+	}
+
+	var summary models.ExecutionSummary
+	if err := json.Unmarshal(execution.Summary, &summary); err != nil {
+		return
+	}
+	summary.Grade = grade
+	if data, err := json.Marshal(summary); err == nil {
+		execution.Summary = data
+	}
+
+	if grade.Passed || !o.config.Profiles.AlertOnFailure {
+		return
+	}
+
+	var failed []string
+	for _, check := range grade.Checks {
+		if !check.Passed {
+			failed = append(failed, check.Stat+" "+check.Metric)
+		}
+	}
+	alert := safety.Alert{
+		Type:      "hardware_underperformance",
+		Message:   fmt.Sprintf("Execution %s underperformed hardware profile %s by more than %.0f%%", execution.ID, grade.ProfileName, grade.MarginPercent),
+		Severity:  safety.SeverityWarning,
+		Timestamp: time.Now(),
+		Metadata: map[string]interface{}{
+			"execution_id": execution.ID,
+			"test_id":      execution.TestID,
+			"profile_id":   grade.ProfileID,
+			"failed":       failed,
+		},
+	}
+	if err := alertManager.SendAlert(alert); err != nil {
+		o.logger.Warn("Failed to send hardware profile alert", zap.Error(err))
+	}
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// SetProgressInterval sets how often progress handlers are called for each
+// running execution; 0 disables progress reports
+func (to *TestOrchestrator) SetProgressInterval(interval time.Duration) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.progressInterval = interval
+}
+
+// OnProgress registers a function called with the state of every running
+// execution, including its progress, at each progress interval
+func (to *TestOrchestrator) OnProgress(fn func(models.TestExecution)) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.onProgress = append(to.onProgress, fn)
+}
+
+// startProgressReports reports an execution's progress at every progress
+// interval. The returned function stops reporting.
+func (to *TestOrchestrator) startProgressReports(execution *TestExecution) func() {
+	to.mu.RLock()
+	interval := to.progressInterval
+	to.mu.RUnlock()
+	if interval <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			execution.mu.RLock()
+			result := execution.toModel()
+			execution.mu.RUnlock()
+			if result.Progress == nil {
+				continue
+			}
+
+			to.mu.RLock()
+			handlers := to.onProgress
+			to.mu.RUnlock()
+			for _, handler := range handlers {
+				handler(result)
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// progress returns how far a running execution has got, or nil when it isn't
+// running. Percent and phase come from the plugin when it reports them and are
+// otherwise estimated from the elapsed share of the duration. The caller must
+// hold execution.mu.
+func (execution *TestExecution) progress(now time.Time) *models.ExecutionProgress {
+	if execution.Status != models.StatusRunning || execution.runStart.IsZero() {
+		return nil
+	}
+
+	params := execution.params
+	duration := params.Duration
+	elapsed := now.Sub(execution.runStart) - execution.pausedTime(now)
+	if execution.soak != nil {
+		// A resumed soak run only runs the plugin for what was left of the duration
+		duration = execution.soak.params.Duration
+		elapsed = execution.soak.elapsed(now)
+	}
+
+	if execution.cooldownStart != nil {
+		return &models.ExecutionProgress{
+			Percent:   100,
+			Phase:     models.PhaseCooldown,
+			Elapsed:   elapsed,
+			Remaining: nonNegative(params.Cooldown - now.Sub(*execution.cooldownStart)),
+		}
+	}
+
+	remaining := nonNegative(duration - elapsed)
+	progress := &models.ExecutionProgress{
+		Percent:  100,
+		Phase:    models.PhaseSteady,
+		Elapsed:  elapsed,
+		Paused:   execution.pausedAt != nil,
+		Yielding: execution.yielding,
+	}
+	if duration > 0 {
+		progress.Percent = percentOf(elapsed, duration)
+	}
+	if params.RampDown > 0 && remaining <= params.RampDown {
+		progress.Phase = models.PhaseRampDown
+	}
+	if !params.LoadProfile.Empty() || params.Polite.Throttles() {
+		progress.LoadLevel = execution.loadLevel(now)
+	}
+
+	if reporter, ok := execution.plugin.(plugins.ProgressReporter); ok {
+		percent, phase := reporter.Progress()
+		if phase != "" {
+			progress.Phase = phase
+		}
+		// The plugin only knows about the current segment of a resumed soak run
+		if percent >= 0 && execution.soak == nil {
+			if percent > 100 {
+				percent = 100
+			}
+			progress.Percent = percent
+			if percent > 0 {
+				// Plugins ending on something other than time, such as a write
+				// budget, may finish well before the duration is up
+				estimate := time.Duration(float64(elapsed) * (100 - percent) / percent)
+				if estimate < remaining {
+					remaining = estimate
+				}
+			}
+		}
+	}
+
+	progress.Remaining = remaining + params.Cooldown
+	return progress
+}
+
+// percentOf returns part as a percentage of whole, capped at 100
+func percentOf(part, whole time.Duration) float64 {
+	percent := float64(part) / float64(whole) * 100
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/internal/publish"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// publishResult sends a finished execution's result and summary to the
+// configured sinks. Suspended soak runs aren't finished, so never get here.
+func (o *Orchestrator) publishResult(execution models.TestExecution) {
+	if o.publisher == nil {
+		return
+	}
+
+	result := publish.Result{
+		ExecutionID: execution.ID,
+		TestID:      execution.TestID,
+		ProjectID:   execution.ProjectID,
+		Labels:      execution.Labels,
+		StartTime:   execution.StartTime,
+		EndTime:     execution.EndTime,
+		Timestamp:   time.Now(),
+		Result:      *o.testResult(&execution),
+		System:      execution.System,
+	}
+	// Raw metric points can run to millions for long runs; the summary carries their statistics
+	result.Result.Metrics = nil
+	if execution.EndTime != nil {
+		result.Timestamp = *execution.EndTime
+	}
+
+	var summary models.ExecutionSummary
+	if len(execution.Summary) > 0 && json.Unmarshal(execution.Summary, &summary) == nil {
+		result.Summary = &summary
+	}
+
+	if !execution.CI.Empty() {
+		ci := execution.CI
+		result.CI = &ci
+	}
+
+	if execution.System != nil {
+		result.Hostname = execution.System.Hostname
+	} else {
+		result.Hostname, _ = os.Hostname()
+	}
+
+	if o.repo != nil {
+		if test, err := o.repo.GetTestConfiguration(execution.TestID); err == nil {
+			result.TestName = test.Name
+			result.Plugin = test.Plugin
+		} else if !errors.Is(err, database.ErrNotFound) {
+			o.logger.Warn("Failed to load test for published result", zap.String("test_id", execution.TestID), zap.Error(err))
+		}
+	}
+
+	o.publisher.Publish(result)
+}
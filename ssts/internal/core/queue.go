@@ -0,0 +1,348 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrNotQueued is returned, wrapped with the ID, for queue operations on an
+// execution that isn't waiting in the queue
+var ErrNotQueued = errors.New("execution is not queued")
+
+// queueEntry is what the queue knows of a queued execution. Entries are guarded
+// by TestOrchestrator.mu.
+type queueEntry struct {
+	since       time.Time
+	duration    time.Duration // of the run and its cooldown, for estimating when runs behind it start
+	reason      string        // why it last failed to start
+	preflight   bool          // it last waited for the preflight checks
+	interactive bool          // it last waited for interactive users to leave
+}
+
+// QueueBlockedError names the queued execution a run waits behind
+type QueueBlockedError struct {
+	ExecutionID string
+}
+
+func (e *QueueBlockedError) Error() string {
+	return fmt.Sprintf("waiting behind queued execution %s", e.ExecutionID)
+}
+
+// enqueueLocked places a queued execution behind the runs of its priority or
+// higher. The caller must hold to.mu.
+func (to *TestOrchestrator) enqueueLocked(execution *TestExecution, params models.TestParams) {
+	execution.queue = &queueEntry{since: time.Now(), duration: params.Duration + params.Cooldown}
+	position := len(to.queue)
+	for i, queued := range to.queue {
+		if queued.priority < execution.priority {
+			position = i
+			break
+		}
+	}
+	to.queue = append(to.queue, nil)
+	copy(to.queue[position+1:], to.queue[position:])
+	to.queue[position] = execution
+}
+
+// dequeueLocked removes an execution from the queue, if it is in it. The caller
+// must hold to.mu.
+func (to *TestOrchestrator) dequeueLocked(execution *TestExecution) {
+	for i, queued := range to.queue {
+		if queued == execution {
+			to.queue = append(to.queue[:i], to.queue[i+1:]...)
+			break
+		}
+	}
+	execution.queue = nil
+}
+
+// leaveQueue is dequeueLocked for callers not holding to.mu
+func (to *TestOrchestrator) leaveQueue(execution *TestExecution) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.dequeueLocked(execution)
+}
+
+// aheadLocked returns the queued runs that start before the execution: those
+// before it in the queue, or for a run not yet queued, those it would queue
+// behind. The caller must hold to.mu.
+func (to *TestOrchestrator) aheadLocked(execution *TestExecution) []*TestExecution {
+	if execution.queue != nil {
+		for i, queued := range to.queue {
+			if queued == execution {
+				return to.queue[:i]
+			}
+		}
+	}
+	var ahead []*TestExecution
+	for _, queued := range to.queue {
+		if queued.priority >= execution.priority {
+			ahead = append(ahead, queued)
+		}
+	}
+	return ahead
+}
+
+// waitsBehind reports whether a run has to wait for a queued run ahead of it:
+// one waiting for the preflight checks gets the machine first, and one claiming
+// a resource the run claims gets the resource first
+func waitsBehind(execution, ahead *TestExecution) bool {
+	return ahead.queue.preflight || sharesResource(execution.claims, ahead.claims)
+}
+
+// sharesResource reports whether two sets of claims name a resource in common
+func sharesResource(a, b []plugins.ResourceClaim) bool {
+	for _, claim := range a {
+		for _, other := range b {
+			if claim.Resource == other.Resource {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// queueBlockerLocked returns a *QueueBlockedError if a queued run ahead of the
+// execution has to start first. The caller must hold to.mu.
+func (to *TestOrchestrator) queueBlockerLocked(execution *TestExecution) error {
+	for _, ahead := range to.aheadLocked(execution) {
+		if waitsBehind(execution, ahead) {
+			return &QueueBlockedError{ExecutionID: ahead.ID}
+		}
+	}
+	return nil
+}
+
+// startQueued takes a queued execution whose preflight checks passed out of the
+// queue, granting its claims, unless a run ahead of it has to start first or its
+// claims conflict with those held
+func (to *TestOrchestrator) startQueued(execution *TestExecution) error {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	err := to.queueBlockerLocked(execution)
+	if err == nil {
+		err = to.acquireClaimsLocked(execution)
+	}
+	if err != nil {
+		if execution.queue != nil {
+			execution.queue.reason = err.Error()
+			execution.queue.preflight = false
+			execution.queue.interactive = false
+		}
+		return err
+	}
+	to.dequeueLocked(execution)
+	return nil
+}
+
+// notePreflightWait records that a queued execution failed the preflight checks
+func (to *TestOrchestrator) notePreflightWait(execution *TestExecution, err error) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	if execution.queue != nil {
+		execution.queue.reason = err.Error()
+		execution.queue.preflight = true
+		execution.queue.interactive = false
+	}
+}
+
+// MoveQueued moves a queued execution to a position in the queue, 1 being the
+// next to start, whatever its priority. Positions past the end move it last.
+func (to *TestOrchestrator) MoveQueued(executionID string, position int) error {
+	if position < 1 {
+		return fmt.Errorf("position must be at least 1, got %d", position)
+	}
+
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	execution, exists := to.executions[executionID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
+	}
+	if execution.queue == nil {
+		return fmt.Errorf("%w: %s", ErrNotQueued, executionID)
+	}
+
+	for i, queued := range to.queue {
+		if queued == execution {
+			to.queue = append(to.queue[:i], to.queue[i+1:]...)
+			break
+		}
+	}
+	if position > len(to.queue)+1 {
+		position = len(to.queue) + 1
+	}
+	to.queue = append(to.queue, nil)
+	copy(to.queue[position:], to.queue[position-1:])
+	to.queue[position-1] = execution
+
+	to.logger.WithField("execution_id", executionID).WithField("position", position).Info("Queued test execution moved")
+	return nil
+}
+
+// CancelQueued cancels an execution waiting in the queue, which ends stopped
+// without having run
+func (to *TestOrchestrator) CancelQueued(executionID string) error {
+	to.mu.Lock()
+	execution, exists := to.executions[executionID]
+	if !exists {
+		to.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
+	}
+	if execution.queue == nil {
+		to.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrNotQueued, executionID)
+	}
+	to.dequeueLocked(execution)
+	to.mu.Unlock()
+
+	execution.Cancel()
+	to.logger.WithField("execution_id", executionID).Info("Queued test execution cancelled")
+	return nil
+}
+
+// GetQueue returns the queued runs in the order they start, the executions
+// holding the machine, and the resources they claim. A queued run is estimated
+// to start once the running executions and queued runs ahead it waits for end;
+// runs waiting for the preflight checks wait for every running execution, and
+// have no estimate while none is running, as the load is then from elsewhere.
+// Runs deferring to interactive users have no estimate either.
+func (to *TestOrchestrator) GetQueue() models.QueueState {
+	to.mu.RLock()
+	defer to.mu.RUnlock()
+
+	now := time.Now()
+	state := models.QueueState{
+		Queued:    make([]models.QueuedRun, 0, len(to.queue)),
+		Running:   make([]models.RunningRun, 0),
+		Resources: make([]models.ResourceUsage, 0),
+	}
+
+	// Executions out of the queue that haven't finished hold the machine
+	var running []*TestExecution
+	ends := make(map[*TestExecution]time.Time)
+	resources := make(map[string]*models.ResourceUsage)
+	for _, execution := range to.executions {
+		execution.mu.RLock()
+		status := execution.Status
+		run := models.RunningRun{
+			ExecutionID: execution.ID,
+			TestID:      execution.Config.ID,
+			TestName:    execution.Config.Name,
+			Plugin:      execution.Config.Plugin,
+			StartedBy:   execution.StartedBy,
+			Status:      status,
+			Claims:      claimNames(execution.claims),
+		}
+		end := now
+		if !execution.runStart.IsZero() {
+			end = execution.runStart.Add(execution.params.Duration + execution.params.Cooldown)
+			if end.Before(now) {
+				end = now
+			}
+			run.ExpectedEnd = &end
+		}
+		execution.mu.RUnlock()
+
+		if execution.queue != nil || (status != models.StatusPending && status != models.StatusRunning) {
+			continue
+		}
+		running = append(running, execution)
+		ends[execution] = end
+		state.Running = append(state.Running, run)
+
+		if !execution.claimsHeld {
+			continue
+		}
+		for _, claim := range execution.claims {
+			usage := resources[claim.Resource]
+			if usage == nil {
+				usage = &models.ResourceUsage{Resource: claim.Resource, Capacity: claim.Capacity}
+				resources[claim.Resource] = usage
+			}
+			usage.Exclusive = usage.Exclusive || claim.Exclusive
+			usage.Used += claim.Amount
+			usage.Holders = append(usage.Holders, execution.ID)
+		}
+	}
+	sort.Slice(state.Running, func(i, j int) bool { return state.Running[i].ExecutionID < state.Running[j].ExecutionID })
+	for _, usage := range resources {
+		sort.Strings(usage.Holders)
+		state.Resources = append(state.Resources, *usage)
+	}
+	sort.Slice(state.Resources, func(i, j int) bool { return state.Resources[i].Resource < state.Resources[j].Resource })
+
+	estimates := make([]*time.Time, len(to.queue))
+	for i, execution := range to.queue {
+		entry := execution.queue
+		estimate := now
+		known := true
+
+		if entry.interactive {
+			known = false
+		}
+		if entry.preflight {
+			known = len(running) > 0
+			for _, run := range running {
+				if ends[run].After(estimate) {
+					estimate = ends[run]
+				}
+			}
+		}
+		for _, run := range running {
+			if sharesResource(execution.claims, run.claims) && ends[run].After(estimate) {
+				estimate = ends[run]
+			}
+		}
+		for j, ahead := range to.queue[:i] {
+			if !waitsBehind(execution, ahead) {
+				continue
+			}
+			if estimates[j] == nil {
+				known = false
+				break
+			}
+			if end := estimates[j].Add(ahead.queue.duration); end.After(estimate) {
+				estimate = end
+			}
+		}
+		if known {
+			estimates[i] = &estimate
+		}
+
+		state.Queued = append(state.Queued, models.QueuedRun{
+			ExecutionID:    execution.ID,
+			TestID:         execution.Config.ID,
+			TestName:       execution.Config.Name,
+			Plugin:         execution.Config.Plugin,
+			StartedBy:      execution.StartedBy,
+			Position:       i + 1,
+			Priority:       execution.priority,
+			QueuedAt:       entry.since,
+			Reason:         entry.reason,
+			EstimatedStart: estimates[i],
+			Claims:         claimNames(execution.claims),
+		})
+	}
+
+	return state
+}
+
+// claimNames describes claims for the queue's state
+func claimNames(claims []plugins.ResourceClaim) []string {
+	if len(claims) == 0 {
+		return nil
+	}
+	names := make([]string, len(claims))
+	for i, claim := range claims {
+		names[i] = claim.String()
+	}
+	return names
+}
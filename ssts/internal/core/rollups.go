@@ -0,0 +1,200 @@
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// minOutlierSamples is how many results a group needs before any of them is
+// called an outlier; quartiles of fewer say little
+const minOutlierSamples = 4
+
+// rollupResult is one execution's result in a rollup
+type rollupResult struct {
+	executionID string
+	hostname    string
+	model       string // "" when the execution has nothing to group by
+	value       float64
+}
+
+// RollupExecutions groups the results of finished executions by hardware model:
+// the CPU model or disk models of their system snapshot, or the value of a label.
+// Each execution's result is one stat of one summary metric, as in hardware profiles.
+func RollupExecutions(executions []models.TestExecution, groupBy, metric, stat string) models.HardwareRollup {
+	if stat == "" {
+		stat = models.ProfileStatAvg
+	}
+	rollup := models.HardwareRollup{
+		GroupBy:    groupBy,
+		Metric:     metric,
+		Stat:       stat,
+		Groups:     make([]models.HardwareGroupStat, 0),
+		Executions: len(executions),
+	}
+
+	var fleet []rollupResult
+	groups := make(map[string][]rollupResult)
+	for i := range executions {
+		execution := &executions[i]
+		var summary models.ExecutionSummary
+		if len(execution.Summary) == 0 || json.Unmarshal(execution.Summary, &summary) != nil {
+			rollup.Unscored++
+			continue
+		}
+		value, ok := summaryStat(summary, metric, stat)
+		if !ok {
+			rollup.Unscored++
+			continue
+		}
+
+		result := rollupResult{executionID: execution.ID, model: hardwareModel(execution, groupBy), value: value}
+		if execution.System != nil {
+			result.hostname = execution.System.Hostname
+		}
+		fleet = append(fleet, result)
+		if result.model == "" {
+			rollup.Ungrouped++
+			continue
+		}
+		groups[result.model] = append(groups[result.model], result)
+	}
+
+	rollup.Fleet = distribution(resultValues(fleet))
+	for model, results := range groups {
+		hosts := make(map[string]bool)
+		for _, result := range results {
+			if result.hostname != "" {
+				hosts[result.hostname] = true
+			}
+		}
+
+		group := models.HardwareGroupStat{
+			Model:        model,
+			Hosts:        len(hosts),
+			Distribution: distribution(resultValues(results)),
+		}
+
+		// The group is compared with the rest of the fleet, so a large bad
+		// batch doesn't drag the reference toward itself
+		var others []rollupResult
+		for _, result := range fleet {
+			if result.model != model {
+				others = append(others, result)
+			}
+		}
+		rest := distribution(resultValues(others))
+		if rest.P50 != 0 {
+			group.DeviationPercent = (group.Distribution.P50 - rest.P50) / math.Abs(rest.P50) * 100
+		}
+		if rest.Count >= minOutlierSamples {
+			low, high := outlierFences(rest)
+			group.Outlier = group.Distribution.P50 < low || group.Distribution.P50 > high
+		}
+
+		if group.Distribution.Count >= minOutlierSamples {
+			low, high := outlierFences(group.Distribution)
+			for _, result := range results {
+				if result.value < low || result.value > high {
+					group.Outliers = append(group.Outliers, models.RollupOutlier{
+						ExecutionID: result.executionID,
+						Hostname:    result.hostname,
+						Value:       result.value,
+					})
+				}
+			}
+			sort.Slice(group.Outliers, func(i, j int) bool { return group.Outliers[i].Value < group.Outliers[j].Value })
+		}
+		rollup.Groups = append(rollup.Groups, group)
+	}
+	sort.Slice(rollup.Groups, func(i, j int) bool { return rollup.Groups[i].Model < rollup.Groups[j].Model })
+
+	return rollup
+}
+
+// hardwareModel returns the value an execution is grouped by, or "" if it has none
+func hardwareModel(execution *models.TestExecution, groupBy string) string {
+	switch groupBy {
+	case models.RollupByCPUModel:
+		if execution.System == nil {
+			return ""
+		}
+		return execution.System.CPUModel
+	case models.RollupByDiskModel:
+		if execution.System == nil {
+			return ""
+		}
+		seen := make(map[string]bool)
+		var disks []string
+		for _, model := range execution.System.DiskModels {
+			if !seen[model] {
+				seen[model] = true
+				disks = append(disks, model)
+			}
+		}
+		sort.Strings(disks)
+		return strings.Join(disks, " + ")
+	default:
+		return execution.Labels[groupBy]
+	}
+}
+
+// resultValues returns the values of results
+func resultValues(results []rollupResult) []float64 {
+	values := make([]float64, len(results))
+	for i, result := range results {
+		values[i] = result.value
+	}
+	return values
+}
+
+// distribution summarizes values, interpolating between them for quartiles
+func distribution(values []float64) models.ScoreDistribution {
+	if len(values) == 0 {
+		return models.ScoreDistribution{}
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, value := range sorted {
+		sum += value
+	}
+	mean := sum / float64(len(sorted))
+	var variance float64
+	for _, value := range sorted {
+		variance += (value - mean) * (value - mean)
+	}
+	variance /= float64(len(sorted))
+
+	return models.ScoreDistribution{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		P25:    quantile(sorted, 0.25),
+		P50:    quantile(sorted, 0.50),
+		P75:    quantile(sorted, 0.75),
+		Max:    sorted[len(sorted)-1],
+		Mean:   mean,
+		StdDev: math.Sqrt(variance),
+	}
+}
+
+// quantile returns the q-th quantile of sorted values, interpolating linearly
+func quantile(sorted []float64, q float64) float64 {
+	position := q * float64(len(sorted)-1)
+	lower := int(position)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*(position-float64(lower))
+}
+
+// outlierFences returns the bounds beyond which a result is an outlier: 1.5
+// interquartile ranges below the first quartile and above the third
+func outlierFences(d models.ScoreDistribution) (float64, float64) {
+	iqr := d.P75 - d.P25
+	return d.P25 - 1.5*iqr, d.P75 + 1.5*iqr
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// EvaluateSLA checks an execution's summary against each limit the SLA sets. A
+// check fails when the result is beyond its limit or its metric wasn't reported.
+func EvaluateSLA(summary models.ExecutionSummary, sla models.SLA) models.SLAResult {
+	result := models.SLAResult{Passed: true, Checks: []models.SLACheck{}}
+	add := func(check models.SLACheck) {
+		if !check.Passed {
+			result.Passed = false
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	if sla.MaxP99Latency > 0 {
+		check := models.SLACheck{SLA: models.SLAMaxP99Latency, Metric: sla.LatencyMetric, Limit: sla.MaxP99Latency}
+		check.Actual, check.Missing = slaStat(summary, sla.LatencyMetric, models.ProfileStatP99)
+		check.Passed = !check.Missing && check.Actual <= check.Limit
+		add(check)
+	}
+
+	if sla.MinThroughput > 0 {
+		check := models.SLACheck{SLA: models.SLAMinThroughput, Metric: sla.ThroughputMetric, Limit: sla.MinThroughput}
+		check.Actual, check.Missing = slaStat(summary, sla.ThroughputMetric, models.ProfileStatAvg)
+		check.Passed = !check.Missing && check.Actual >= check.Limit
+		add(check)
+	}
+
+	if sla.MaxErrorRatePercent != nil {
+		check := models.SLACheck{SLA: models.SLAMaxErrorRate, Metric: sla.ErrorsMetric, Limit: *sla.MaxErrorRatePercent}
+		failed, failedMissing := slaCounter(summary, sla.ErrorsMetric)
+		operations, operationsMissing := slaCounter(summary, sla.OperationsMetric)
+		switch {
+		case operationsMissing:
+			check.Metric, check.Missing = sla.OperationsMetric, true
+		case failedMissing:
+			check.Missing = true
+		case operations > 0:
+			check.Actual = failed / operations * 100
+		case failed > 0:
+			check.Actual = 100
+		}
+		check.Passed = !check.Missing && check.Actual <= check.Limit
+		add(check)
+	}
+
+	if sla.ZeroCriticalViolations {
+		add(models.SLACheck{
+			SLA:    models.SLAZeroCriticalViolations,
+			Actual: float64(summary.CriticalViolations),
+			Passed: summary.CriticalViolations == 0,
+		})
+	}
+
+	return result
+}
+
+// slaStat looks up one figure of a summary metric, reporting whether it's missing
+func slaStat(summary models.ExecutionSummary, metric, stat string) (float64, bool) {
+	value, ok := summaryStat(summary, metric, stat)
+	return value, !ok
+}
+
+// slaCounter looks up the final value of a counter: its total if it's named like
+// one, or else the highest value it reported
+func slaCounter(summary models.ExecutionSummary, metric string) (float64, bool) {
+	if value, ok := summary.Totals[metric]; ok {
+		return value, false
+	}
+	return slaStat(summary, metric, models.ProfileStatMax)
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestEvaluateSLA(t *testing.T) {
+	summary := models.ExecutionSummary{
+		Metrics: map[string]models.MetricStats{
+			"plugin.io.latency_ms": {Count: 100, Avg: 2, Max: 30, P95: 8, P99: 12},
+			"plugin.io.iops":       {Count: 100, Avg: 950},
+			"plugin.io.errors":     {Count: 100, Max: 5},
+		},
+		Totals:             map[string]float64{"plugin.io.total_ops": 1000},
+		CriticalViolations: 1,
+	}
+	rate := func(percent float64) *float64 { return &percent }
+
+	tests := []struct {
+		name   string
+		sla    models.SLA
+		passed []bool // of each check, in order
+	}{
+		{"latency met", models.SLA{LatencyMetric: "plugin.io.latency_ms", MaxP99Latency: 15}, []bool{true}},
+		{"latency exceeded", models.SLA{LatencyMetric: "plugin.io.latency_ms", MaxP99Latency: 10}, []bool{false}},
+		{"throughput", models.SLA{ThroughputMetric: "plugin.io.iops", MinThroughput: 1000}, []bool{false}},
+		{"missing metric", models.SLA{ThroughputMetric: "plugin.cpu.ops", MinThroughput: 1}, []bool{false}},
+		// 5 failed of 1000 operations is 0.5%
+		{"error rate met", models.SLA{ErrorsMetric: "plugin.io.errors", OperationsMetric: "plugin.io.total_ops", MaxErrorRatePercent: rate(1)}, []bool{true}},
+		{"no errors allowed", models.SLA{ErrorsMetric: "plugin.io.errors", OperationsMetric: "plugin.io.total_ops", MaxErrorRatePercent: rate(0)}, []bool{false}},
+		{"critical violations", models.SLA{ZeroCriticalViolations: true}, []bool{false}},
+		{"all", models.SLA{
+			LatencyMetric: "plugin.io.latency_ms", MaxP99Latency: 15,
+			ThroughputMetric: "plugin.io.iops", MinThroughput: 900,
+			ErrorsMetric: "plugin.io.errors", OperationsMetric: "plugin.io.total_ops", MaxErrorRatePercent: rate(1),
+		}, []bool{true, true, true}},
+	}
+	for _, tt := range tests {
+		result := EvaluateSLA(summary, tt.sla)
+		var passed []bool
+		allPassed := true
+		for _, check := range result.Checks {
+			passed = append(passed, check.Passed)
+			allPassed = allPassed && check.Passed
+		}
+		if !reflect.DeepEqual(passed, tt.passed) || result.Passed != allPassed {
+			t.Errorf("%s: result = %+v, want checks passed %v", tt.name, result, tt.passed)
+		}
+		if failures := result.Failures(); len(failures) != len(tt.passed)-countTrue(tt.passed) {
+			t.Errorf("%s: failures = %q", tt.name, failures)
+		}
+	}
+}
+
+func countTrue(values []bool) int {
+	count := 0
+	for _, value := range values {
+		if value {
+			count++
+		}
+	}
+	return count
+}
+
+func TestTestResultAppliesSLA(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	orchestrator := &Orchestrator{testOrchestrator: NewTestOrchestrator(plugins.NewPluginManager(), nil, nil, logger), logger: zap.NewNop()}
+
+	sla := EvaluateSLA(models.ExecutionSummary{Metrics: map[string]models.MetricStats{
+		"plugin.io.latency_ms": {Count: 10, P99: 12},
+	}}, models.SLA{LatencyMetric: "plugin.io.latency_ms", MaxP99Latency: 10, ZeroCriticalViolations: true})
+	summary, _ := json.Marshal(models.ExecutionSummary{SLA: &sla})
+
+	result := orchestrator.testResult(&models.TestExecution{ID: "e1", TestID: "t1", Status: models.StatusCompleted, Summary: summary})
+	if result.Passed {
+		t.Error("execution missing its p99 latency SLA passed")
+	}
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "max_p99_latency: plugin.io.latency_ms 12 exceeds 10") {
+		t.Errorf("errors = %q, want the failed latency SLA", result.Errors)
+	}
+
+	sla = EvaluateSLA(models.ExecutionSummary{}, models.SLA{ZeroCriticalViolations: true})
+	summary, _ = json.Marshal(models.ExecutionSummary{SLA: &sla})
+	result = orchestrator.testResult(&models.TestExecution{ID: "e2", TestID: "t1", Status: models.StatusCompleted, Summary: summary})
+	if !result.Passed || len(result.Errors) != 0 {
+		t.Errorf("result = %+v, want a run meeting its SLA to pass despite having no metrics", result)
+	}
+}
@@ -0,0 +1,255 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrSoakDisabled is returned by StartTest for a soak run when soak mode is disabled
+var ErrSoakDisabled = errors.New("soak mode is disabled")
+
+// ErrSoakFinished is returned by ResumeTest when the checkpoint already covers the whole duration
+var ErrSoakFinished = errors.New("soak execution has no remaining duration")
+
+// SoakSettings configure soak executions
+type SoakSettings struct {
+	Enabled            bool
+	MaxDuration        time.Duration // longest accepted soak duration; 0 for no limit
+	CheckpointInterval time.Duration // how often progress is checkpointed
+	Resume             bool          // suspend soak runs on drain so they can be resumed after the restart
+}
+
+// soakState tracks a soak execution's progress across checkpoints and restarts.
+// Metrics are folded into base at every checkpoint and the points offloaded to the
+// metric store, so the summary doesn't count them twice.
+type soakState struct {
+	params         models.TestParams        // as requested, persisted so the run can be resumed
+	base           *models.ExecutionSummary // metrics folded in at earlier checkpoints
+	foldedAt       time.Time                // end of the period base covers
+	segmentStart   time.Time                // when this server started running the execution
+	priorRun       time.Duration            // run time before this server picked the execution up
+	priorTotals    map[string]float64       // counter totals from before the last resume
+	priorEnergy    *models.EnergyUsage      // energy consumed before the last resume
+	checkpointedAt *time.Time
+	resumes        int
+}
+
+// newSoakState starts tracking a soak run that begins running at start
+func newSoakState(params models.TestParams, start time.Time) *soakState {
+	return &soakState{params: params, foldedAt: start, segmentStart: start}
+}
+
+// SetSoak configures soak executions
+func (to *TestOrchestrator) SetSoak(settings SoakSettings) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.soak = settings
+}
+
+// OnCheckpoint registers a function called with the state of a soak execution at
+// every checkpoint, while it is still running
+func (to *TestOrchestrator) OnCheckpoint(fn func(models.TestExecution)) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.onCheckpoint = append(to.onCheckpoint, fn)
+}
+
+// summary computes the summary of the whole run so far, including earlier
+// checkpoints and runs before a restart. The caller must hold execution.mu.
+func (s *soakState) summary(execution *TestExecution, end time.Time) models.ExecutionSummary {
+	segment := execution.summarizeMetrics(end.Sub(s.foldedAt))
+	summary := mergeSummaries(s.base, segment)
+	for key, total := range s.priorTotals {
+		summary.Totals[key] += total
+	}
+	summary.Energy = energySummary(mergeEnergy(s.priorEnergy, execution.Energy), summary.Totals)
+	return summary
+}
+
+// fold moves the execution's metric points into the base summary, returning the
+// points removed from its buffer. The caller must hold execution.mu.
+func (s *soakState) fold(execution *TestExecution, now time.Time) []models.MetricPoint {
+	segment := execution.summarizeMetrics(now.Sub(s.foldedAt))
+	base := mergeSummaries(s.base, segment)
+	s.base = &base
+	s.foldedAt = now
+	points := execution.metrics.all()
+	execution.metrics.reset()
+	execution.offloaded = nil
+	if len(points) > 0 {
+		execution.offloadedUntil = points[len(points)-1].Timestamp
+	}
+	return points
+}
+
+// elapsed returns the run time of the execution up to end
+func (s *soakState) elapsed(end time.Time) time.Duration {
+	return s.priorRun + end.Sub(s.segmentStart)
+}
+
+// startCheckpoints checkpoints a soak execution now and then at every checkpoint
+// interval. The returned function stops checkpointing and waits for a checkpoint
+// in progress to finish.
+func (to *TestOrchestrator) startCheckpoints(execution *TestExecution) func() {
+	to.mu.RLock()
+	interval := to.soak.CheckpointInterval
+	to.mu.RUnlock()
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	to.checkpoint(execution)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				to.checkpoint(execution)
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+// checkpoint folds a running soak execution's metrics into its summary and hands
+// its state to the checkpoint handlers
+func (to *TestOrchestrator) checkpoint(execution *TestExecution) {
+	execution.mu.Lock()
+	if execution.soak == nil || execution.completed || execution.abandoned {
+		execution.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	folded := execution.soak.fold(execution, now)
+	execution.soak.checkpointedAt = &now
+	if data, err := json.Marshal(execution.soak.summary(execution, now)); err == nil {
+		execution.Summary = data
+	} else {
+		to.logger.WithError(err).WithField("execution_id", execution.ID).Error("Failed to encode checkpoint summary")
+	}
+	result := execution.toModel()
+	execution.mu.Unlock()
+
+	to.offload(execution, folded)
+
+	to.mu.RLock()
+	handlers := to.onCheckpoint
+	to.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(result)
+	}
+}
+
+// suspend stops a soak execution for shutdown without finishing it: its final
+// state is checkpointed as still running, so it is resumed after the restart
+func (to *TestOrchestrator) suspend(execution *TestExecution) {
+	execution.mu.Lock()
+	execution.suspended = true
+	execution.mu.Unlock()
+
+	to.logger.WithField("execution_id", execution.ID).Info("Suspending soak execution for shutdown")
+	execution.Cancel()
+}
+
+// ResumeTest continues a soak execution interrupted by a restart from its last
+// checkpoint, under the same execution ID, for the part of its duration that
+// hadn't run yet. Time between the last checkpoint and the restart is lost.
+func (to *TestOrchestrator) ResumeTest(config models.TestConfiguration, checkpoint models.TestExecution) error {
+	var params models.TestParams
+	if err := json.Unmarshal(checkpoint.Params, &params); err != nil {
+		return fmt.Errorf("invalid checkpoint parameters: %w", err)
+	}
+	remaining := params.Duration - checkpoint.Elapsed
+	if remaining <= 0 {
+		return ErrSoakFinished
+	}
+
+	plugin, err := to.pluginManager.NewInstance(config.Plugin)
+	if err != nil {
+		return fmt.Errorf("%w: %s", plugins.ErrPluginNotFound, config.Plugin)
+	}
+	if !to.pluginManager.IsEnabled(config.Plugin) {
+		return fmt.Errorf("plugin %s is disabled", config.Plugin)
+	}
+
+	var summary models.ExecutionSummary
+	if len(checkpoint.Summary) > 0 {
+		if err := json.Unmarshal(checkpoint.Summary, &summary); err != nil {
+			return fmt.Errorf("invalid checkpoint summary: %w", err)
+		}
+	}
+
+	now := time.Now()
+	state := newSoakState(params, now)
+	state.priorRun = checkpoint.Elapsed
+	state.priorTotals = summary.Totals
+	state.priorEnergy = summary.Energy
+	state.resumes = checkpoint.Resumes + 1
+	summary.Totals = nil
+	summary.Energy = nil
+	state.base = &summary
+
+	start := now
+	if checkpoint.StartTime != nil {
+		start = *checkpoint.StartTime
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	execution := &TestExecution{
+		ID:         checkpoint.ID,
+		Config:     config,
+		Status:     models.StatusPending,
+		StartTime:  start,
+		Context:    ctx,
+		Cancel:     cancel,
+		Violations: summary.Violations,
+		Critical:   summary.CriticalViolations,
+		bySeverity: summary.ViolationsBySeverity,
+		Labels:     checkpoint.Labels,
+		StartedBy:  checkpoint.StartedBy,
+		Seed:       params.Seed,
+		CI:         ciMetadata(params.CI),
+		priority:   params.Priority,
+		done:       make(chan struct{}),
+		plugin:     plugin,
+		soak:       state,
+		metrics:    to.newMetricBuffer(),
+	}
+
+	runParams := params
+	runParams.Duration = remaining
+	if err := to.launch(execution, plugin, runParams); err != nil {
+		cancel()
+		return err
+	}
+
+	to.logger.WithFields(logrus.Fields{
+		"execution_id": execution.ID,
+		"elapsed":      checkpoint.Elapsed,
+		"remaining":    remaining,
+		"resumes":      state.resumes,
+	}).Info("Resumed soak execution")
+	return nil
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// SummarizeExecution computes summary statistics over an execution's metric points.
+// Every numeric field is summarized under "source.type.field"; fields that look like
+// cumulative counters (total_*, *_total, *_count) also report their final value.
+func SummarizeExecution(metrics []models.MetricPoint, duration time.Duration, violations, criticalViolations int) models.ExecutionSummary {
+	summary := models.ExecutionSummary{
+		DurationSeconds:    duration.Seconds(),
+		Samples:            len(metrics),
+		Metrics:            make(map[string]models.MetricStats),
+		Totals:             make(map[string]float64),
+		Violations:         violations,
+		CriticalViolations: criticalViolations,
+	}
+
+	values := make(map[string][]float64)
+	for _, point := range metrics {
+		for field, raw := range point.Fields {
+			value, ok := metricValue(raw)
+			if !ok {
+				continue
+			}
+
+			key := point.Source + "." + point.Type + "." + field
+			values[key] = append(values[key], value)
+
+			if isCounterField(field) && value > summary.Totals[key] {
+				summary.Totals[key] = value
+			}
+		}
+	}
+
+	for key, series := range values {
+		sort.Float64s(series)
+
+		var sum float64
+		for _, value := range series {
+			sum += value
+		}
+
+		summary.Metrics[key] = models.MetricStats{
+			Count: len(series),
+			Min:   series[0],
+			Max:   series[len(series)-1],
+			Avg:   sum / float64(len(series)),
+			P95:   series[int(float64(len(series)-1)*0.95)],
+			P99:   series[int(float64(len(series)-1)*0.99)],
+		}
+	}
+
+	return summary
+}
+
+// mergeSummaries combines the summary of an earlier part of an execution with that
+// of the part following it. Percentiles can't be combined exactly, so P95 is the
+// higher of the two, as is P99; totals keep the higher value, as counters only grow.
+func mergeSummaries(earlier *models.ExecutionSummary, later models.ExecutionSummary) models.ExecutionSummary {
+	if later.Metrics == nil {
+		later.Metrics = make(map[string]models.MetricStats)
+	}
+	if later.Totals == nil {
+		later.Totals = make(map[string]float64)
+	}
+	if earlier == nil {
+		return later
+	}
+
+	merged := later
+	merged.DurationSeconds = earlier.DurationSeconds + later.DurationSeconds
+	merged.Samples = earlier.Samples + later.Samples
+	merged.Metrics = make(map[string]models.MetricStats, len(later.Metrics))
+	for key, stats := range later.Metrics {
+		merged.Metrics[key] = stats
+	}
+	for key, before := range earlier.Metrics {
+		after, ok := merged.Metrics[key]
+		if !ok {
+			merged.Metrics[key] = before
+			continue
+		}
+		count := before.Count + after.Count
+		merged.Metrics[key] = models.MetricStats{
+			Count: count,
+			Min:   math.Min(before.Min, after.Min),
+			Max:   math.Max(before.Max, after.Max),
+			Avg:   (before.Avg*float64(before.Count) + after.Avg*float64(after.Count)) / float64(count),
+			P95:   math.Max(before.P95, after.P95),
+			P99:   math.Max(before.P99, after.P99),
+		}
+	}
+	merged.Totals = make(map[string]float64, len(later.Totals))
+	for key, total := range later.Totals {
+		merged.Totals[key] = total
+	}
+	for key, total := range earlier.Totals {
+		if total > merged.Totals[key] {
+			merged.Totals[key] = total
+		}
+	}
+	return merged
+}
+
+// mergeEnergy adds up the energy consumed over two consecutive parts of an execution
+func mergeEnergy(earlier, later *models.EnergyUsage) *models.EnergyUsage {
+	if earlier == nil {
+		return later
+	}
+	if later == nil {
+		return earlier
+	}
+
+	merged := models.EnergyUsage{
+		Source:    later.Source,
+		Joules:    earlier.Joules + later.Joules,
+		WattHours: earlier.WattHours + later.WattHours,
+		PeakWatts: math.Max(earlier.PeakWatts, later.PeakWatts),
+		Domains:   make(map[string]float64),
+	}
+	var seconds float64
+	for _, part := range []*models.EnergyUsage{earlier, later} {
+		if part.AvgWatts > 0 {
+			seconds += part.Joules / part.AvgWatts
+		}
+		for domain, joules := range part.Domains {
+			merged.Domains[domain] += joules
+		}
+	}
+	if seconds > 0 {
+		merged.AvgWatts = merged.Joules / seconds
+	}
+	return &merged
+}
+
+// energySummary adds performance-per-watt figures to an execution's energy usage:
+// each cumulative total (operations, bytes, ...) divided by the joules consumed
+func energySummary(energy *models.EnergyUsage, totals map[string]float64) *models.EnergyUsage {
+	if energy == nil {
+		return nil
+	}
+
+	summary := *energy
+	if summary.Joules > 0 && len(totals) > 0 {
+		summary.WorkPerJoule = make(map[string]float64, len(totals))
+		for key, total := range totals {
+			summary.WorkPerJoule[key] = total / summary.Joules
+		}
+	}
+	return &summary
+}
+
+// isCounterField reports whether a field name denotes a cumulative counter
+func isCounterField(field string) bool {
+	return strings.HasPrefix(field, "total_") || strings.HasSuffix(field, "_total") || strings.HasSuffix(field, "_count")
+}
+
+// metricValue converts a numeric metric field value to float64
+func metricValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
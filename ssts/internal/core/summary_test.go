@@ -0,0 +1,184 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestSummarizeExecution(t *testing.T) {
+	point := func(fields map[string]interface{}) models.MetricPoint {
+		return models.MetricPoint{Source: "cpu-stress", Type: "load", Fields: fields}
+	}
+
+	tests := []struct {
+		name        string
+		metrics     []models.MetricPoint
+		wantMetrics map[string]models.MetricStats
+		wantTotals  map[string]float64
+	}{
+		{"no metrics", nil, map[string]models.MetricStats{}, map[string]float64{}},
+		{
+			"single sample",
+			[]models.MetricPoint{point(map[string]interface{}{"usage": 40.0})},
+			map[string]models.MetricStats{"cpu-stress.load.usage": {Count: 1, Min: 40, Max: 40, Avg: 40, P95: 40, P99: 40}},
+			map[string]float64{},
+		},
+		{
+			"mixed numeric types",
+			[]models.MetricPoint{
+				point(map[string]interface{}{"usage": 10}),
+				point(map[string]interface{}{"usage": int64(30)}),
+				point(map[string]interface{}{"usage": float32(20)}),
+			},
+			map[string]models.MetricStats{"cpu-stress.load.usage": {Count: 3, Min: 10, Max: 30, Avg: 20, P95: 20, P99: 20}},
+			map[string]float64{},
+		},
+		{
+			"non-numeric fields skipped",
+			[]models.MetricPoint{point(map[string]interface{}{"governor": "performance", "throttled": true, "usage": uint64(5)})},
+			map[string]models.MetricStats{"cpu-stress.load.usage": {Count: 1, Min: 5, Max: 5, Avg: 5, P95: 5, P99: 5}},
+			map[string]float64{},
+		},
+		{
+			"counters report their final value",
+			[]models.MetricPoint{
+				point(map[string]interface{}{"total_ops": 100, "errors_total": 1, "retry_count": 0}),
+				point(map[string]interface{}{"total_ops": 250, "errors_total": 3, "retry_count": 2}),
+			},
+			map[string]models.MetricStats{
+				"cpu-stress.load.total_ops":    {Count: 2, Min: 100, Max: 250, Avg: 175, P95: 100, P99: 100},
+				"cpu-stress.load.errors_total": {Count: 2, Min: 1, Max: 3, Avg: 2, P95: 1, P99: 1},
+				"cpu-stress.load.retry_count":  {Count: 2, Min: 0, Max: 2, Avg: 1, P95: 0, P99: 0},
+			},
+			map[string]float64{"cpu-stress.load.total_ops": 250, "cpu-stress.load.errors_total": 3, "cpu-stress.load.retry_count": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := SummarizeExecution(tt.metrics, 90*time.Second, 2, 1)
+			if summary.DurationSeconds != 90 || summary.Samples != len(tt.metrics) || summary.Violations != 2 || summary.CriticalViolations != 1 {
+				t.Errorf("summary = %+v", summary)
+			}
+			if !reflect.DeepEqual(summary.Metrics, tt.wantMetrics) {
+				t.Errorf("metrics = %+v, want %+v", summary.Metrics, tt.wantMetrics)
+			}
+			if !reflect.DeepEqual(summary.Totals, tt.wantTotals) {
+				t.Errorf("totals = %+v, want %+v", summary.Totals, tt.wantTotals)
+			}
+		})
+	}
+
+	// P95 of 100 samples 1..100 is the 95th smallest, and P99 the 99th
+	var metrics []models.MetricPoint
+	for i := 100; i >= 1; i-- {
+		metrics = append(metrics, point(map[string]interface{}{"usage": float64(i)}))
+	}
+	if stats := SummarizeExecution(metrics, time.Minute, 0, 0).Metrics["cpu-stress.load.usage"]; stats.P95 != 95 || stats.P99 != 99 || stats.Avg != 50.5 {
+		t.Errorf("stats of 1..100 = %+v", stats)
+	}
+}
+
+func TestMergeSummaries(t *testing.T) {
+	earlier := &models.ExecutionSummary{
+		DurationSeconds: 60,
+		Samples:         2,
+		Metrics:         map[string]models.MetricStats{"a": {Count: 2, Min: 1, Max: 3, Avg: 2, P95: 3}, "gone": {Count: 1, Min: 5, Max: 5, Avg: 5, P95: 5}},
+		Totals:          map[string]float64{"ops": 100, "gone": 7},
+	}
+	later := models.ExecutionSummary{
+		DurationSeconds: 30,
+		Samples:         1,
+		Metrics:         map[string]models.MetricStats{"a": {Count: 1, Min: 0, Max: 8, Avg: 8, P95: 8}, "new": {Count: 1, Min: 1, Max: 1, Avg: 1, P95: 1}},
+		Totals:          map[string]float64{"ops": 40, "new": 1},
+		Violations:      1,
+	}
+
+	tests := []struct {
+		name    string
+		earlier *models.ExecutionSummary
+		later   models.ExecutionSummary
+		want    models.ExecutionSummary
+	}{
+		{"nothing earlier", nil, later, later},
+		{"nothing later", nil, models.ExecutionSummary{}, models.ExecutionSummary{Metrics: map[string]models.MetricStats{}, Totals: map[string]float64{}}},
+		{"combined", earlier, later, models.ExecutionSummary{
+			DurationSeconds: 90,
+			Samples:         3,
+			Metrics: map[string]models.MetricStats{
+				"a":    {Count: 3, Min: 0, Max: 8, Avg: 4, P95: 8},
+				"gone": {Count: 1, Min: 5, Max: 5, Avg: 5, P95: 5},
+				"new":  {Count: 1, Min: 1, Max: 1, Avg: 1, P95: 1},
+			},
+			// Counters only grow, so a lower later value means the earlier one stands
+			Totals:     map[string]float64{"ops": 100, "gone": 7, "new": 1},
+			Violations: 1,
+		}},
+	}
+	for _, tt := range tests {
+		if got := mergeSummaries(tt.earlier, tt.later); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+	if earlier.Metrics["a"].Count != 2 || later.Totals["ops"] != 40 {
+		t.Error("merging modified its inputs")
+	}
+}
+
+func TestMergeEnergy(t *testing.T) {
+	first := &models.EnergyUsage{Source: "rapl", Joules: 1000, WattHours: 1000.0 / 3600, AvgWatts: 100, PeakWatts: 150, Domains: map[string]float64{"package-0": 800, "dram": 200}}
+	second := &models.EnergyUsage{Source: "rapl", Joules: 600, WattHours: 600.0 / 3600, AvgWatts: 60, PeakWatts: 90, Domains: map[string]float64{"package-0": 600}}
+
+	tests := []struct {
+		name           string
+		earlier, later *models.EnergyUsage
+		want           *models.EnergyUsage
+	}{
+		{"neither", nil, nil, nil},
+		{"earlier only", first, nil, first},
+		{"later only", nil, second, second},
+		{"both", first, second, &models.EnergyUsage{
+			Source:    "rapl",
+			Joules:    1600,
+			WattHours: 1600.0 / 3600,
+			AvgWatts:  80, // 1600 J over 10 s + 10 s
+			PeakWatts: 150,
+			Domains:   map[string]float64{"package-0": 1400, "dram": 200},
+		}},
+		{"no average power", &models.EnergyUsage{Joules: 10}, &models.EnergyUsage{Joules: 5}, &models.EnergyUsage{Joules: 15, Domains: map[string]float64{}}},
+	}
+	for _, tt := range tests {
+		if got := mergeEnergy(tt.earlier, tt.later); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEnergySummary(t *testing.T) {
+	totals := map[string]float64{"io-stress.io.total_bytes_written": 1 << 20}
+
+	tests := []struct {
+		name   string
+		energy *models.EnergyUsage
+		totals map[string]float64
+		want   map[string]float64
+	}{
+		{"work per joule", &models.EnergyUsage{Joules: 512}, totals, map[string]float64{"io-stress.io.total_bytes_written": 2048}},
+		{"no energy used", &models.EnergyUsage{Joules: 0}, totals, nil},
+		{"no totals", &models.EnergyUsage{Joules: 512}, nil, nil},
+	}
+	for _, tt := range tests {
+		got := energySummary(tt.energy, tt.totals)
+		if !reflect.DeepEqual(got.WorkPerJoule, tt.want) {
+			t.Errorf("%s: work per joule = %v, want %v", tt.name, got.WorkPerJoule, tt.want)
+		}
+		if tt.energy.WorkPerJoule != nil {
+			t.Errorf("%s: modified the energy usage it was given", tt.name)
+		}
+	}
+	if energySummary(nil, totals) != nil {
+		t.Error("summary of unmeasured energy isn't nil")
+	}
+}
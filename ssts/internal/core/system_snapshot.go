@@ -0,0 +1,196 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/host"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// snapshotKernelParams are the sysctls recorded with every execution: those that
+// change how the machine behaves under memory, I/O and scheduler pressure
+var snapshotKernelParams = []string{
+	"vm.swappiness",
+	"vm.overcommit_memory",
+	"vm.dirty_ratio",
+	"vm.dirty_background_ratio",
+	"vm.min_free_kbytes",
+	"vm.nr_hugepages",
+	"kernel.numa_balancing",
+	"kernel.sched_autogroup_enabled",
+}
+
+// sysFS is where device and CPU settings are read from; procRoot is where
+// process information is read from
+var (
+	sysFS    = "/sys"
+	procRoot = "/proc"
+)
+
+// containerIDPattern matches the container ID in a cgroup path
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// captureSystemSnapshot records the kernel and OS settings of this machine.
+// Settings that can't be read, e.g. on systems without cpufreq, are left out.
+func captureSystemSnapshot() *models.SystemSnapshot {
+	snapshot := &models.SystemSnapshot{
+		KernelParams: make(map[string]string),
+		Captured:     time.Now(),
+	}
+
+	snapshot.Hostname, _ = os.Hostname()
+	if platform, _, version, err := host.PlatformInformation(); err == nil {
+		snapshot.OS = strings.TrimSpace(platform + " " + version)
+	}
+	if version, err := readKernelParam("kernel.osrelease"); err == nil {
+		snapshot.KernelVersion = strings.TrimSpace(version)
+	}
+	snapshot.Arch, _ = host.KernelArch()
+	snapshot.CPUModel = readCPUModel()
+
+	for _, name := range snapshotKernelParams {
+		if value, err := readKernelParam(name); err == nil {
+			snapshot.KernelParams[name] = strings.Join(strings.Fields(value), " ")
+		}
+	}
+
+	snapshot.IOSchedulers = readIOSchedulers()
+	snapshot.DiskModels = readDiskModels()
+	snapshot.CPUGovernor = readCPUGovernor()
+	if data, err := os.ReadFile(filepath.Join(sysFS, "kernel/mm/transparent_hugepage/enabled")); err == nil {
+		snapshot.TransparentHugePages = selectedOption(string(data))
+	}
+	snapshot.Container = detectContainer()
+
+	return snapshot
+}
+
+// readIOSchedulers returns the active I/O scheduler of each block device that has one
+func readIOSchedulers() map[string]string {
+	paths, _ := filepath.Glob(filepath.Join(sysFS, "block/*/queue/scheduler"))
+	schedulers := make(map[string]string, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if scheduler := selectedOption(string(data)); scheduler != "" {
+			device := filepath.Base(filepath.Dir(filepath.Dir(path)))
+			schedulers[device] = scheduler
+		}
+	}
+	if len(schedulers) == 0 {
+		return nil
+	}
+	return schedulers
+}
+
+// readDiskModels returns the model of each block device that reports one.
+// Virtual devices such as loop and dm devices have none and are left out.
+func readDiskModels() map[string]string {
+	paths, _ := filepath.Glob(filepath.Join(sysFS, "block/*/device/model"))
+	disks := make(map[string]string, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if model := strings.Join(strings.Fields(string(data)), " "); model != "" {
+			device := filepath.Base(filepath.Dir(filepath.Dir(path)))
+			disks[device] = model
+		}
+	}
+	if len(disks) == 0 {
+		return nil
+	}
+	return disks
+}
+
+// readCPUModel returns the CPU model name from /proc/cpuinfo, or the distinct
+// models comma-separated when sockets differ
+func readCPUModel() string {
+	data, err := os.ReadFile(filepath.Join(procRoot, "cpuinfo"))
+	if err != nil {
+		return ""
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(key) != "model name" {
+			continue
+		}
+		if name := strings.Join(strings.Fields(value), " "); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// readCPUGovernor returns the CPUs' frequency governor, or the distinct governors
+// comma-separated when they differ
+func readCPUGovernor() string {
+	paths, _ := filepath.Glob(filepath.Join(sysFS, "devices/system/cpu/cpu[0-9]*/cpufreq/scaling_governor"))
+	seen := make(map[string]bool)
+	var governors []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if governor := strings.TrimSpace(string(data)); governor != "" && !seen[governor] {
+			seen[governor] = true
+			governors = append(governors, governor)
+		}
+	}
+	sort.Strings(governors)
+	return strings.Join(governors, ",")
+}
+
+// selectedOption returns the bracketed choice of a sysfs setting such as
+// "mq-deadline [none]", or the whole value if nothing is bracketed
+func selectedOption(value string) string {
+	value = strings.TrimSpace(value)
+	if start := strings.Index(value, "["); start >= 0 {
+		if end := strings.Index(value[start:], "]"); end > 0 {
+			return value[start+1 : start+end]
+		}
+	}
+	return value
+}
+
+// detectContainer identifies the container runtime the server runs under from
+// the marker files runtimes create and the cgroup of init, or returns nil
+func detectContainer() *models.ContainerInfo {
+	cgroup, _ := os.ReadFile(filepath.Join(procRoot, "1/cgroup"))
+	info := &models.ContainerInfo{ID: containerIDPattern.FindString(string(cgroup))}
+
+	switch {
+	case os.Getenv("KUBERNETES_SERVICE_HOST") != "" || strings.Contains(string(cgroup), "kubepods"):
+		info.Runtime = "kubernetes"
+	case fileExists("/.dockerenv") || strings.Contains(string(cgroup), "docker"):
+		info.Runtime = "docker"
+	case fileExists("/run/.containerenv") || strings.Contains(string(cgroup), "libpod"):
+		info.Runtime = "podman"
+	case strings.Contains(string(cgroup), "containerd"):
+		info.Runtime = "containerd"
+	case strings.Contains(string(cgroup), "/lxc"):
+		info.Runtime = "lxc"
+	default:
+		return nil
+	}
+	return info
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ExecutionUsage adds up the usage of finished executions by project, user and
+// the period they started in. Machine time is the time from start to end; energy
+// and device writes come from the execution summaries, where they were measured.
+// Project names are filled in from projectNames, keyed by ID.
+func ExecutionUsage(executions []models.TestExecution, period string, projectNames map[string]string) models.UsageReport {
+	if period == "" {
+		period = models.UsagePeriodMonth
+	}
+	report := models.UsageReport{
+		Period:    period,
+		Rows:      make([]models.UsageRow, 0),
+		Generated: time.Now().UTC(),
+	}
+
+	type rowKey struct {
+		period    time.Time
+		projectID string
+		user      string
+	}
+	rows := make(map[rowKey]*models.UsageRow)
+	for _, execution := range executions {
+		if execution.StartTime == nil {
+			continue
+		}
+		usage := executionUsage(execution)
+		key := rowKey{usagePeriodStart(*execution.StartTime, period), execution.ProjectID, execution.StartedBy}
+		row := rows[key]
+		if row == nil {
+			row = &models.UsageRow{
+				Period:      key.period,
+				ProjectID:   key.projectID,
+				ProjectName: projectNames[key.projectID],
+				User:        key.user,
+			}
+			rows[key] = row
+		}
+		row.Add(usage)
+		report.Totals.Add(usage)
+	}
+
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if !a.Period.Equal(b.Period) {
+			return a.Period.Before(b.Period)
+		}
+		if a.ProjectName+a.ProjectID != b.ProjectName+b.ProjectID {
+			return a.ProjectName+a.ProjectID < b.ProjectName+b.ProjectID
+		}
+		return a.User < b.User
+	})
+	return report
+}
+
+// executionUsage is the usage of one execution
+func executionUsage(execution models.TestExecution) models.UsageTotals {
+	usage := models.UsageTotals{Executions: 1}
+	elapsed := execution.Duration
+	if execution.EndTime != nil {
+		elapsed = execution.EndTime.Sub(*execution.StartTime)
+	}
+	if elapsed > 0 {
+		usage.MachineHours = elapsed.Hours()
+	}
+
+	var summary models.ExecutionSummary
+	if len(execution.Summary) == 0 || json.Unmarshal(execution.Summary, &summary) != nil {
+		return usage
+	}
+	if summary.Energy != nil {
+		usage.WattHours = summary.Energy.WattHours
+		usage.MeteredExecutions = 1
+	}
+	for _, bytes := range summary.DeviceWrites {
+		usage.BytesWritten += bytes
+	}
+	return usage
+}
+
+// usagePeriodStart returns the start of the period a time falls in, in UTC
+func usagePeriodStart(t time.Time, period string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case models.UsagePeriodDay:
+		return day
+	case models.UsagePeriodWeek:
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
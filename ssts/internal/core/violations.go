@@ -0,0 +1,62 @@
+package core
+
+import (
+	"github.com/pranavgopavaram/ssts/internal/safety"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// OnViolation registers a callback invoked with each safety violation seen
+// while an execution runs, linked to the execution
+func (to *TestOrchestrator) OnViolation(fn func(models.Violation)) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.onViolation = append(to.onViolation, fn)
+}
+
+// noteViolation counts a safety violation against the execution, by severity as
+// well, keeps it for the diagnostics bundle and passes it to the OnViolation
+// callbacks. The caller must not hold execution.mu.
+func (to *TestOrchestrator) noteViolation(execution *TestExecution, violation safety.Violation) {
+	execution.mu.Lock()
+	execution.Violations++
+	if violation.Critical {
+		execution.Critical++
+	}
+	if execution.bySeverity == nil {
+		execution.bySeverity = make(map[string]int)
+	}
+	execution.bySeverity[string(violation.Severity)]++
+	execution.recordViolation(violation)
+	execution.mu.Unlock()
+
+	to.mu.RLock()
+	callbacks := to.onViolation
+	to.mu.RUnlock()
+	record := models.Violation{
+		ExecutionID:  execution.ID,
+		Type:         violation.Type,
+		Severity:     string(violation.Severity),
+		CurrentValue: violation.CurrentValue,
+		Limit:        violation.Limit,
+		Message:      violation.Message,
+		Critical:     violation.Critical,
+		EpisodeID:    violation.EpisodeID,
+		Timestamp:    violation.Timestamp,
+	}
+	for _, fn := range callbacks {
+		fn(record)
+	}
+}
+
+// violationCounts copies the execution's violation counts by severity, or
+// returns nil if there were none. The caller must hold execution.mu.
+func (execution *TestExecution) violationCounts() map[string]int {
+	if len(execution.bySeverity) == 0 {
+		return nil
+	}
+	counts := make(map[string]int, len(execution.bySeverity))
+	for severity, count := range execution.bySeverity {
+		counts[severity] = count
+	}
+	return counts
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ErrDurationExceeded is returned by StartTest when the requested duration is above the maximum
+var ErrDurationExceeded = errors.New("test duration exceeds the maximum allowed")
+
+// WatchdogSettings bound how long an execution may run
+type WatchdogSettings struct {
+	Enabled     bool
+	MaxDuration time.Duration // longest accepted test duration; 0 for no limit
+	Grace       time.Duration // slack over duration + cooldown before the watchdog fires
+	KillTimeout time.Duration // time a cancelled execution gets to return before it is abandoned
+}
+
+// SetWatchdog configures the maximum duration and the execution watchdog
+func (to *TestOrchestrator) SetWatchdog(settings WatchdogSettings) {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	to.watchdog = settings
+}
+
+// checkDuration rejects durations above the configured maximum. Soak runs are
+// bounded by the soak maximum instead and rejected if soak mode is disabled.
+func (to *TestOrchestrator) checkDuration(params models.TestParams) error {
+	maxDuration, err := to.maxDuration(params.Soak)
+	if err != nil {
+		return err
+	}
+	return durationWithin(params.Duration, maxDuration)
+}
+
+// maxDuration returns the longest duration accepted for a run, 0 for no limit
+func (to *TestOrchestrator) maxDuration(soakRun bool) (time.Duration, error) {
+	to.mu.RLock()
+	maxDuration := to.watchdog.MaxDuration
+	soak := to.soak
+	to.mu.RUnlock()
+
+	if soakRun {
+		if !soak.Enabled {
+			return 0, ErrSoakDisabled
+		}
+		maxDuration = soak.MaxDuration
+	}
+	return maxDuration, nil
+}
+
+// durationWithin rejects a duration above maxDuration, unless that is 0
+func durationWithin(duration, maxDuration time.Duration) error {
+	if maxDuration > 0 && duration > maxDuration {
+		return fmt.Errorf("%w: %s > %s", ErrDurationExceeded, duration, maxDuration)
+	}
+	return nil
+}
+
+// watch enforces an execution's wall-clock budget independently of the plugin. Once
+// the budget is spent the execution is cancelled; if it still hasn't returned after
+// the kill timeout, typically because the plugin ignores its context, it is marked
+// timed out and abandoned so it no longer counts as running.
+func (to *TestOrchestrator) watch(execution *TestExecution, params models.TestParams) {
+	to.mu.RLock()
+	settings := to.watchdog
+	to.mu.RUnlock()
+
+	if !settings.Enabled {
+		return
+	}
+	start := time.Now()
+	budget := params.Duration + params.Cooldown + settings.Grace
+
+	timer := time.NewTimer(budget)
+	defer timer.Stop()
+	for {
+		select {
+		case <-execution.done:
+			return
+		case <-timer.C:
+		}
+
+		// Extending or pausing the run extends the budget
+		execution.mu.RLock()
+		extended := execution.extended + execution.pausedTime(time.Now())
+		execution.mu.RUnlock()
+		if remaining := budget + extended - time.Since(start); remaining > 0 {
+			timer.Reset(remaining)
+			continue
+		}
+		budget += extended
+		break
+	}
+
+	message := fmt.Sprintf("Execution exceeded its wall-clock budget of %s", budget)
+	execution.mu.Lock()
+	execution.timedOut = &message
+	execution.mu.Unlock()
+
+	to.logger.WithFields(logrus.Fields{
+		"execution_id": execution.ID,
+		"budget":       budget,
+	}).Warn("Execution exceeded its wall-clock budget, stopping it")
+	execution.Cancel()
+
+	kill := time.NewTimer(settings.KillTimeout)
+	defer kill.Stop()
+	select {
+	case <-execution.done:
+		return
+	case <-kill.C:
+	}
+
+	// The plugin goroutine can't be killed; record the timeout and stop tracking it
+	execution.mu.Lock()
+	execution.abandoned = true
+	execution.Status = models.StatusTimedOut
+	execution.ErrorMessage = &message
+	execution.failureClass = models.FailureTimeout
+	now := time.Now()
+	execution.EndTime = &now
+	execution.mu.Unlock()
+
+	to.logger.WithFields(logrus.Fields{
+		"execution_id": execution.ID,
+		"plugin":       execution.Config.Plugin,
+		"kill_timeout": settings.KillTimeout,
+	}).Error("Execution did not stop after cancellation, abandoning it as timed out")
+
+	to.complete(execution)
+}
@@ -0,0 +1,183 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/plugins"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Named time windows of metric queries, resolved here so clients don't have to
+// work out timestamps themselves
+const (
+	WindowLastRun     = "last_run"     // the latest execution of a test, from start to end
+	WindowSteadyState = "steady_state" // an execution at full intensity, without ramp-up, ramp-down and cooldown
+)
+
+// Errors resolving a named window
+var (
+	ErrUnknownWindow = errors.New("unknown window")
+	ErrNoSteadyState = errors.New("execution has no steady state")
+	ErrNoRuns        = errors.New("test has not been run")
+)
+
+// phasePollInterval is how often the phase reported by a plugin is sampled to
+// find where steady state starts and ends
+var phasePollInterval = time.Second
+
+// watchPhases records when an execution whose plugin reports its phase reaches
+// steady state and when it leaves it for ramp-down. Plugins that don't report
+// their phase are steady from the start until the ramp-down window. The
+// returned function stops watching.
+func watchPhases(execution *TestExecution) func() {
+	reporter, ok := execution.plugin.(plugins.ProgressReporter)
+	if !ok {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(phasePollInterval)
+		defer ticker.Stop()
+		for {
+			_, phase := reporter.Progress()
+			now := time.Now()
+
+			execution.mu.Lock()
+			switch phase {
+			case "":
+			case models.PhaseRampUp:
+				if execution.steadyFrom == nil {
+					execution.rampingUp = true
+				}
+			case models.PhaseRampDown, models.PhaseCooldown:
+				if execution.steadyUntil == nil {
+					execution.steadyUntil = &now
+				}
+			default:
+				if execution.steadyFrom == nil {
+					start := execution.runStart
+					if execution.rampingUp {
+						start = now
+					}
+					execution.steadyFrom = &start
+					execution.rampingUp = false
+				}
+			}
+			execution.mu.Unlock()
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// steadyState returns the time range of the execution's steady state up to now,
+// or nil if the workload hasn't reached it. Without phases reported by the
+// plugin, it runs from the start of the workload to the ramp-down window or the
+// end of the workload. The caller must hold execution.mu.
+func (execution *TestExecution) steadyState(now time.Time) *models.TimeRange {
+	if execution.runStart.IsZero() || (execution.rampingUp && execution.steadyFrom == nil) {
+		return nil
+	}
+
+	start := execution.runStart
+	if execution.steadyFrom != nil {
+		start = *execution.steadyFrom
+	}
+	end := now
+	if execution.cooldownStart != nil && execution.cooldownStart.Before(end) {
+		end = *execution.cooldownStart
+	}
+	if rampDown := execution.params.RampDown; rampDown > 0 {
+		if planned := execution.runStart.Add(execution.params.Duration - rampDown); planned.Before(end) {
+			end = planned
+		}
+	}
+	if execution.steadyUntil != nil && execution.steadyUntil.Before(end) {
+		end = *execution.steadyUntil
+	}
+
+	if !start.Before(end) {
+		return nil
+	}
+	return &models.TimeRange{Start: start, End: end}
+}
+
+// ExecutionWindow resolves a named window of an execution's metrics. Running
+// executions' steady state runs up to now, or to where it ended.
+func (o *Orchestrator) ExecutionWindow(execution models.TestExecution, window string) (models.TimeRange, error) {
+	switch window {
+	case WindowLastRun:
+		timeRange := models.TimeRange{End: time.Now()}
+		if execution.StartTime != nil {
+			timeRange.Start = *execution.StartTime
+		}
+		if execution.EndTime != nil {
+			timeRange.End = *execution.EndTime
+		}
+		return timeRange, nil
+
+	case WindowSteadyState:
+		var summary models.ExecutionSummary
+		if len(execution.Summary) > 0 && json.Unmarshal(execution.Summary, &summary) == nil && summary.SteadyState != nil {
+			return *summary.SteadyState, nil
+		}
+		if steady := o.testOrchestrator.liveSteadyState(execution.ID); steady != nil {
+			return *steady, nil
+		}
+		return models.TimeRange{}, fmt.Errorf("%w: %s", ErrNoSteadyState, execution.ID)
+
+	default:
+		return models.TimeRange{}, fmt.Errorf("%w %q, want %s or %s", ErrUnknownWindow, window, WindowLastRun, WindowSteadyState)
+	}
+}
+
+// TestWindow resolves a named window of a test's metrics against its latest
+// execution, which it returns with the range
+func (o *Orchestrator) TestWindow(testID, window string) (models.TimeRange, *models.TestExecution, error) {
+	if window != WindowLastRun && window != WindowSteadyState {
+		return models.TimeRange{}, nil, fmt.Errorf("%w %q, want %s or %s", ErrUnknownWindow, window, WindowLastRun, WindowSteadyState)
+	}
+
+	executions, err := o.ListExecutionsByTest(testID, 1, 0)
+	if err != nil {
+		return models.TimeRange{}, nil, err
+	}
+	if len(executions) == 0 {
+		return models.TimeRange{}, nil, fmt.Errorf("%w: %s", ErrNoRuns, testID)
+	}
+	latest := executions[0]
+	timeRange, err := o.ExecutionWindow(latest, window)
+	return timeRange, &latest, err
+}
+
+// liveSteadyState returns the steady state so far of an execution still running
+func (to *TestOrchestrator) liveSteadyState(executionID string) *models.TimeRange {
+	to.mu.RLock()
+	execution, exists := to.executions[executionID]
+	to.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	execution.mu.RLock()
+	defer execution.mu.RUnlock()
+	if execution.Status != models.StatusRunning {
+		return nil
+	}
+	return execution.steadyState(time.Now())
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -66,6 +67,10 @@ func Initialize(cfg config.DatabaseConfig) (*Database, error) {
 		sqlDB.SetConnMaxLifetime(time.Hour)
 	}
 
+	if err := registerFailpoints(db); err != nil {
+		return nil, fmt.Errorf("failed to register failpoints: %w", err)
+	}
+
 	// Auto-migrate schemas
 	if err := runMigrations(db); err != nil {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
@@ -86,8 +91,18 @@ func runMigrations(db *gorm.DB) error {
 	models := []interface{}{
 		&models.User{},
 		&models.Plugin{},
+		&models.Project{},
+		&models.ProjectMember{},
 		&models.TestConfiguration{},
 		&models.TestExecution{},
+		&models.Alert{},
+		&models.AlertMuteRule{},
+		&models.SavedSearch{},
+		&models.Annotation{},
+		&models.Violation{},
+		&models.HardwareProfile{},
+		&models.DeviceEndurance{},
+		&models.AgentToken{},
 	}
 
 	for _, model := range models {
@@ -101,6 +116,32 @@ func runMigrations(db *gorm.DB) error {
 		return fmt.Errorf("failed to create indexes: %w", err)
 	}
 
+	// Make sure unscoped data has a project to belong to
+	if err := ensureDefaultProject(db); err != nil {
+		return fmt.Errorf("failed to create default project: %w", err)
+	}
+
+	return nil
+}
+
+// ensureDefaultProject creates the default project and assigns any tests and
+// executions created before projects existed to it
+func ensureDefaultProject(db *gorm.DB) error {
+	var project models.Project
+	if err := db.Where("name = ?", models.DefaultProjectName).
+		Attrs(models.Project{Description: "Default project"}).
+		FirstOrCreate(&project).Error; err != nil {
+		return err
+	}
+
+	for _, model := range []interface{}{&models.TestConfiguration{}, &models.TestExecution{}} {
+		if err := db.Model(model).
+			Where("project_id IS NULL OR project_id = ''").
+			Update("project_id", project.ID).Error; err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -111,9 +152,22 @@ func createIndexes(db *gorm.DB) error {
 		"CREATE INDEX IF NOT EXISTS idx_test_executions_start_time ON test_executions(start_time)",
 		"CREATE INDEX IF NOT EXISTS idx_test_configurations_plugin ON test_configurations(plugin)",
 		"CREATE INDEX IF NOT EXISTS idx_test_executions_test_id ON test_executions(test_id)",
+		// Serve the per-test lookups of the latest and running executions
+		"CREATE INDEX IF NOT EXISTS idx_test_executions_test_created ON test_executions(test_id, created DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_test_executions_test_status ON test_executions(test_id, status, created DESC)",
 		"CREATE INDEX IF NOT EXISTS idx_users_username ON users(username)",
 		"CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)",
 		"CREATE INDEX IF NOT EXISTS idx_plugins_name ON plugins(name)",
+		"CREATE INDEX IF NOT EXISTS idx_alerts_timestamp ON alerts(timestamp)",
+		"CREATE INDEX IF NOT EXISTS idx_alerts_type ON alerts(type)",
+	}
+
+	// Label filters on PostgreSQL use jsonb containment, which a GIN index serves
+	if db.Dialector.Name() == "postgres" {
+		indexes = append(indexes,
+			"CREATE INDEX IF NOT EXISTS idx_test_configurations_labels ON test_configurations USING GIN (labels)",
+			"CREATE INDEX IF NOT EXISTS idx_test_executions_labels ON test_executions USING GIN (labels)",
+		)
 	}
 
 	for _, index := range indexes {
@@ -148,22 +202,41 @@ func (db *Database) HealthCheck() error {
 	return sqlDB.PingContext(ctx)
 }
 
-// Repository provides data access methods
-type Repository struct {
+// SQLRepository is the Repository backed by the SQL database
+type SQLRepository struct {
 	db *Database
 }
 
-// NewRepository creates a new repository
-func NewRepository(db *Database) *Repository {
-	return &Repository{db: db}
+// NewSQLRepository creates a repository on the database
+func NewSQLRepository(db *Database) *SQLRepository {
+	return &SQLRepository{db: db}
+}
+
+// HealthCheck checks the database can be reached
+func (r *SQLRepository) HealthCheck() error {
+	return r.db.HealthCheck()
+}
+
+// Close closes the database connection
+func (r *SQLRepository) Close() error {
+	return r.db.Close()
 }
 
 // Users repository methods
-func (r *Repository) CreateUser(user *models.User) error {
+func (r *SQLRepository) CreateUser(user *models.User) error {
 	return r.db.Create(user).Error
 }
 
-func (r *Repository) GetUserByUsername(username string) (*models.User, error) {
+func (r *SQLRepository) GetUser(id string) (*models.User, error) {
+	var user models.User
+	err := r.db.Where("id = ?", id).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *SQLRepository) GetUserByUsername(username string) (*models.User, error) {
 	var user models.User
 	err := r.db.Where("username = ?", username).First(&user).Error
 	if err != nil {
@@ -172,7 +245,7 @@ func (r *Repository) GetUserByUsername(username string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *Repository) GetUserByEmail(email string) (*models.User, error) {
+func (r *SQLRepository) GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
 	err := r.db.Where("email = ?", email).First(&user).Error
 	if err != nil {
@@ -181,16 +254,22 @@ func (r *Repository) GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *Repository) UpdateUser(user *models.User) error {
+func (r *SQLRepository) UpdateUser(user *models.User) error {
 	return r.db.Save(user).Error
 }
 
+func (r *SQLRepository) ListUsers() ([]models.User, error) {
+	var users []models.User
+	err := r.db.Order("username").Find(&users).Error
+	return users, err
+}
+
 // Test configurations repository methods
-func (r *Repository) CreateTestConfiguration(config *models.TestConfiguration) error {
+func (r *SQLRepository) CreateTestConfiguration(config *models.TestConfiguration) error {
 	return r.db.Create(config).Error
 }
 
-func (r *Repository) GetTestConfiguration(id string) (*models.TestConfiguration, error) {
+func (r *SQLRepository) GetTestConfiguration(id string) (*models.TestConfiguration, error) {
 	var config models.TestConfiguration
 	err := r.db.Where("id = ?", id).First(&config).Error
 	if err != nil {
@@ -199,26 +278,43 @@ func (r *Repository) GetTestConfiguration(id string) (*models.TestConfiguration,
 	return &config, nil
 }
 
-func (r *Repository) ListTestConfigurations(limit, offset int) ([]models.TestConfiguration, error) {
+func (r *SQLRepository) ListTestConfigurations(limit, offset int) ([]models.TestConfiguration, error) {
 	var configs []models.TestConfiguration
 	err := r.db.Limit(limit).Offset(offset).Order("created DESC").Find(&configs).Error
 	return configs, err
 }
 
-func (r *Repository) UpdateTestConfiguration(config *models.TestConfiguration) error {
+// ListTestConfigurationsFiltered lists test configurations matching the filter
+func (r *SQLRepository) ListTestConfigurationsFiltered(filter TestFilter) ([]models.TestConfiguration, error) {
+	var configs []models.TestConfiguration
+	query := NewQueryBuilder(r.db.Model(&models.TestConfiguration{}), TestSortFields).
+		Equals("project_id", filter.ProjectID).
+		Equals("plugin", filter.Plugin).
+		Equals("created_by", filter.CreatedBy).
+		Contains("name", filter.NameContains).
+		Between("created", filter.CreatedAfter, filter.CreatedBefore).
+		Labels("labels", filter.Labels).
+		OrderBy(filter.Sort, "created DESC").
+		Paginate(filter.Limit, filter.Offset).
+		Query()
+	err := query.Find(&configs).Error
+	return configs, err
+}
+
+func (r *SQLRepository) UpdateTestConfiguration(config *models.TestConfiguration) error {
 	return r.db.Save(config).Error
 }
 
-func (r *Repository) DeleteTestConfiguration(id string) error {
+func (r *SQLRepository) DeleteTestConfiguration(id string) error {
 	return r.db.Where("id = ?", id).Delete(&models.TestConfiguration{}).Error
 }
 
 // Test executions repository methods
-func (r *Repository) CreateTestExecution(execution *models.TestExecution) error {
+func (r *SQLRepository) CreateTestExecution(execution *models.TestExecution) error {
 	return r.db.Create(execution).Error
 }
 
-func (r *Repository) GetTestExecution(id string) (*models.TestExecution, error) {
+func (r *SQLRepository) GetTestExecution(id string) (*models.TestExecution, error) {
 	var execution models.TestExecution
 	err := r.db.Where("id = ?", id).First(&execution).Error
 	if err != nil {
@@ -227,32 +323,146 @@ func (r *Repository) GetTestExecution(id string) (*models.TestExecution, error)
 	return &execution, nil
 }
 
-func (r *Repository) ListTestExecutions(limit, offset int) ([]models.TestExecution, error) {
+func (r *SQLRepository) ListTestExecutions(limit, offset int) ([]models.TestExecution, error) {
 	var executions []models.TestExecution
 	err := r.db.Limit(limit).Offset(offset).Order("created DESC").Find(&executions).Error
 	return executions, err
 }
 
-func (r *Repository) ListTestExecutionsByStatus(status models.ExecutionStatus, limit, offset int) ([]models.TestExecution, error) {
+func (r *SQLRepository) ListTestExecutionsByStatus(status models.ExecutionStatus, limit, offset int) ([]models.TestExecution, error) {
 	var executions []models.TestExecution
 	err := r.db.Where("status = ?", status).Limit(limit).Offset(offset).Order("created DESC").Find(&executions).Error
 	return executions, err
 }
 
-func (r *Repository) UpdateTestExecution(execution *models.TestExecution) error {
+// ListTestExecutionsFiltered lists test executions matching the filter. Test name,
+// plugin and creator filters are resolved through the owning test configuration.
+func (r *SQLRepository) ListTestExecutionsFiltered(filter ExecutionFilter) ([]models.TestExecution, error) {
+	var executions []models.TestExecution
+
+	statuses := make([]string, 0, len(filter.Statuses))
+	for _, status := range filter.Statuses {
+		statuses = append(statuses, string(status))
+	}
+	reviewStatuses := make([]string, 0, len(filter.ReviewStatuses))
+	for _, status := range filter.ReviewStatuses {
+		reviewStatuses = append(reviewStatuses, string(status))
+	}
+
+	builder := NewQueryBuilder(r.db.Model(&models.TestExecution{}), ExecutionSortFields).
+		Equals("project_id", filter.ProjectID).
+		Equals("test_id", filter.TestID).
+		Equals("started_by", filter.StartedBy).
+		In("status", statuses).
+		In("review_status", reviewStatuses).
+		Between("start_time", filter.StartedAfter, filter.StartedBefore).
+		Labels("labels", filter.Labels).
+		HasPrefix("ci_commit", filter.CICommit).
+		Equals("ci_build_number", filter.CIBuildNumber)
+
+	if filter.TestNameContains != "" || filter.Plugin != "" || filter.CreatedBy != "" {
+		tests := NewQueryBuilder(r.db.Model(&models.TestConfiguration{}).Select("id"), TestSortFields).
+			Contains("name", filter.TestNameContains).
+			Equals("plugin", filter.Plugin).
+			Equals("created_by", filter.CreatedBy).
+			Query()
+		builder = builder.Subquery("test_id", tests)
+	}
+
+	err := builder.
+		OrderBy(filter.Sort, "created DESC").
+		Paginate(filter.Limit, filter.Offset).
+		Query().
+		Find(&executions).Error
+	return executions, err
+}
+
+// ListCompletedExecutions lists the completed executions of a test, most recently
+// finished first, optionally only those finished since a time
+func (r *SQLRepository) ListCompletedExecutions(testID string, since *time.Time) ([]models.TestExecution, error) {
+	var executions []models.TestExecution
+	query := r.db.Where("test_id = ? AND status = ?", testID, models.StatusCompleted)
+	if since != nil {
+		query = query.Where("end_time >= ?", *since)
+	}
+	err := query.Order("end_time DESC").Find(&executions).Error
+	return executions, err
+}
+
+// ListExecutionsByTest lists the executions of a test, newest first
+func (r *SQLRepository) ListExecutionsByTest(testID string, limit, offset int) ([]models.TestExecution, error) {
+	return r.ListTestExecutionsFiltered(ExecutionFilter{TestID: testID, Limit: limit, Offset: offset})
+}
+
+// GetLatestExecutionForTest returns the newest execution of a test with one of
+// the statuses, or with any status when none are given
+func (r *SQLRepository) GetLatestExecutionForTest(testID string, statuses ...models.ExecutionStatus) (*models.TestExecution, error) {
+	var execution models.TestExecution
+	query := r.db.Where("test_id = ?", testID)
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	if err := query.Order("created DESC").First(&execution).Error; err != nil {
+		return nil, err
+	}
+	return &execution, nil
+}
+
+// GetRunningExecutionForTest returns the newest queued, pending or running
+// execution of a test
+func (r *SQLRepository) GetRunningExecutionForTest(testID string) (*models.TestExecution, error) {
+	return r.GetLatestExecutionForTest(testID, activeStatuses...)
+}
+
+// ListUnfinishedSoakExecutions lists soak executions last recorded as queued, pending
+// or running, i.e. interrupted by a restart
+func (r *SQLRepository) ListUnfinishedSoakExecutions() ([]models.TestExecution, error) {
+	var executions []models.TestExecution
+	err := r.db.Where("soak = ? AND status IN ?", true, []models.ExecutionStatus{models.StatusQueued, models.StatusPending, models.StatusRunning}).
+		Order("start_time").Find(&executions).Error
+	return executions, err
+}
+
+func (r *SQLRepository) UpdateTestExecution(execution *models.TestExecution) error {
 	return r.db.Save(execution).Error
 }
 
-func (r *Repository) DeleteTestExecution(id string) error {
-	return r.db.Where("id = ?", id).Delete(&models.TestExecution{}).Error
+// UpdateExecutionReview moves the review of an execution on from the given status,
+// returning ErrNotFound if the execution doesn't exist or its review has moved on
+func (r *SQLRepository) UpdateExecutionReview(id string, from models.ReviewStatus, review models.ExecutionReview) error {
+	result := r.db.Model(&models.TestExecution{}).
+		Where("id = ? AND review_status = ?", id, from).
+		Updates(map[string]interface{}{
+			"review_status": review.Status,
+			"reviewer":      review.Reviewer,
+			"review_notes":  review.Notes,
+			"reviewed":      review.At,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteTestExecution deletes an execution and the safety violations recorded for it
+func (r *SQLRepository) DeleteTestExecution(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("execution_id = ?", id).Delete(&models.Violation{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.TestExecution{}).Error
+	})
 }
 
 // Plugin repository methods
-func (r *Repository) CreatePlugin(plugin *models.Plugin) error {
+func (r *SQLRepository) CreatePlugin(plugin *models.Plugin) error {
 	return r.db.Create(plugin).Error
 }
 
-func (r *Repository) GetPlugin(name string) (*models.Plugin, error) {
+func (r *SQLRepository) GetPlugin(name string) (*models.Plugin, error) {
 	var plugin models.Plugin
 	err := r.db.Where("name = ?", name).First(&plugin).Error
 	if err != nil {
@@ -261,16 +471,376 @@ func (r *Repository) GetPlugin(name string) (*models.Plugin, error) {
 	return &plugin, nil
 }
 
-func (r *Repository) ListPlugins() ([]models.Plugin, error) {
+func (r *SQLRepository) ListPlugins() ([]models.Plugin, error) {
 	var plugins []models.Plugin
 	err := r.db.Where("enabled = ?", true).Order("name").Find(&plugins).Error
 	return plugins, err
 }
 
-func (r *Repository) UpdatePlugin(plugin *models.Plugin) error {
+// ListAllPlugins returns every plugin, including disabled ones
+func (r *SQLRepository) ListAllPlugins() ([]models.Plugin, error) {
+	var plugins []models.Plugin
+	err := r.db.Order("name").Find(&plugins).Error
+	return plugins, err
+}
+
+func (r *SQLRepository) UpdatePlugin(plugin *models.Plugin) error {
 	return r.db.Save(plugin).Error
 }
 
-func (r *Repository) DeletePlugin(name string) error {
+func (r *SQLRepository) DeletePlugin(name string) error {
 	return r.db.Where("name = ?", name).Delete(&models.Plugin{}).Error
-}
\ No newline at end of file
+}
+// Alert repository methods
+func (r *SQLRepository) CreateAlert(alert *models.Alert) error {
+	return r.db.Create(alert).Error
+}
+
+func (r *SQLRepository) GetAlert(id string) (*models.Alert, error) {
+	var alert models.Alert
+	err := r.db.Where("id = ?", id).First(&alert).Error
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// ListAlerts lists alerts matching the filter, newest first by default
+func (r *SQLRepository) ListAlerts(filter AlertFilter) ([]models.Alert, error) {
+	var alerts []models.Alert
+	builder := NewQueryBuilder(r.db.Model(&models.Alert{}), AlertSortFields).
+		EqualsOrEmpty("project_id", filter.ProjectID).
+		Equals("type", filter.Type).
+		In("severity", filter.Severities).
+		Between("timestamp", filter.Since, filter.Until).
+		Bool("acknowledged", filter.Acknowledged)
+
+	if !filter.IncludeMuted {
+		notMuted := false
+		builder = builder.Bool("muted", &notMuted)
+	}
+
+	err := builder.
+		OrderBy(filter.Sort, "timestamp DESC").
+		Paginate(filter.Limit, filter.Offset).
+		Query().
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// AcknowledgeAlert marks an alert as acknowledged by the given operator
+func (r *SQLRepository) AcknowledgeAlert(id, by string) (*models.Alert, error) {
+	alert, err := r.GetAlert(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	alert.Acknowledged = true
+	alert.AcknowledgedBy = &by
+	alert.AcknowledgedAt = &now
+
+	if err := r.db.Save(alert).Error; err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// Alert mute rule repository methods
+func (r *SQLRepository) CreateAlertMuteRule(rule *models.AlertMuteRule) error {
+	return r.db.Create(rule).Error
+}
+
+// ListActiveAlertMuteRules returns mute rules that have not expired
+func (r *SQLRepository) ListActiveAlertMuteRules() ([]models.AlertMuteRule, error) {
+	var rules []models.AlertMuteRule
+	err := r.db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).Order("created DESC").Find(&rules).Error
+	return rules, err
+}
+
+func (r *SQLRepository) DeleteAlertMuteRule(id string) error {
+	return r.db.Where("id = ?", id).Delete(&models.AlertMuteRule{}).Error
+}
+
+// Project repository methods
+func (r *SQLRepository) CreateProject(project *models.Project) error {
+	return r.db.Create(project).Error
+}
+
+func (r *SQLRepository) GetProject(id string) (*models.Project, error) {
+	var project models.Project
+	err := r.db.Where("id = ?", id).First(&project).Error
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (r *SQLRepository) GetProjectByName(name string) (*models.Project, error) {
+	var project models.Project
+	err := r.db.Where("name = ?", name).First(&project).Error
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (r *SQLRepository) ListProjects() ([]models.Project, error) {
+	var projects []models.Project
+	err := r.db.Order("name").Find(&projects).Error
+	return projects, err
+}
+
+// ListProjectsForUser lists the projects a user is a member of
+func (r *SQLRepository) ListProjectsForUser(userID string) ([]models.Project, error) {
+	var projects []models.Project
+	members := r.db.Model(&models.ProjectMember{}).Select("project_id").Where("user_id = ?", userID)
+	err := r.db.Where("id IN (?)", members).Order("name").Find(&projects).Error
+	return projects, err
+}
+
+// DeleteProject deletes a project and its memberships. Tests and executions are kept.
+func (r *SQLRepository) DeleteProject(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", id).Delete(&models.ProjectMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.Project{}).Error
+	})
+}
+
+// Project member repository methods
+func (r *SQLRepository) GetProjectMember(projectID, userID string) (*models.ProjectMember, error) {
+	var member models.ProjectMember
+	err := r.db.Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *SQLRepository) ListProjectMembers(projectID string) ([]models.ProjectMember, error) {
+	var members []models.ProjectMember
+	err := r.db.Where("project_id = ?", projectID).Order("created").Find(&members).Error
+	return members, err
+}
+
+// SetProjectMember grants a user a role in a project, replacing any existing role
+func (r *SQLRepository) SetProjectMember(projectID, userID string, role models.ProjectRole) (*models.ProjectMember, error) {
+	var member models.ProjectMember
+	err := r.db.Where("project_id = ? AND user_id = ?", projectID, userID).
+		Assign(models.ProjectMember{Role: role}).
+		FirstOrCreate(&member, models.ProjectMember{ProjectID: projectID, UserID: userID}).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *SQLRepository) RemoveProjectMember(projectID, userID string) error {
+	return r.db.Where("project_id = ? AND user_id = ?", projectID, userID).Delete(&models.ProjectMember{}).Error
+}
+
+// Saved search repository methods
+func (r *SQLRepository) CreateSavedSearch(search *models.SavedSearch) error {
+	return r.db.Create(search).Error
+}
+
+// GetSavedSearch returns a user's saved search
+func (r *SQLRepository) GetSavedSearch(id, userID string) (*models.SavedSearch, error) {
+	var search models.SavedSearch
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&search).Error
+	if err != nil {
+		return nil, err
+	}
+	return &search, nil
+}
+
+// ListSavedSearches lists a user's saved searches, optionally only those for one resource
+func (r *SQLRepository) ListSavedSearches(userID, resource string) ([]models.SavedSearch, error) {
+	var searches []models.SavedSearch
+	err := NewQueryBuilder(r.db.Model(&models.SavedSearch{}).Where("user_id = ?", userID), nil).
+		Equals("resource", resource).
+		Query().
+		Order("name").
+		Find(&searches).Error
+	return searches, err
+}
+
+// DeleteSavedSearch deletes a user's saved search, returning gorm.ErrRecordNotFound if there is none
+func (r *SQLRepository) DeleteSavedSearch(id, userID string) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.SavedSearch{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CreateAnnotation stores an annotation
+func (r *SQLRepository) CreateAnnotation(annotation *models.Annotation) error {
+	return r.db.Create(annotation).Error
+}
+
+// ListAnnotations lists an execution's annotations in time order
+func (r *SQLRepository) ListAnnotations(executionID string) ([]models.Annotation, error) {
+	var annotations []models.Annotation
+	err := r.db.Where("execution_id = ?", executionID).Order("timestamp").Find(&annotations).Error
+	return annotations, err
+}
+
+// CreateViolation stores a safety violation
+func (r *SQLRepository) CreateViolation(violation *models.Violation) error {
+	return r.db.Create(violation).Error
+}
+
+// ListViolations lists the safety violations recorded for an execution in time order
+func (r *SQLRepository) ListViolations(executionID string) ([]models.Violation, error) {
+	var violations []models.Violation
+	err := r.db.Where("execution_id = ?", executionID).Order("timestamp").Find(&violations).Error
+	return violations, err
+}
+
+// DeleteAnnotation deletes an execution's annotation, returning gorm.ErrRecordNotFound if there is none
+func (r *SQLRepository) DeleteAnnotation(executionID, id string) error {
+	result := r.db.Where("id = ? AND execution_id = ?", id, executionID).Delete(&models.Annotation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SaveHardwareProfile creates a hardware profile, or replaces the one with the same name
+func (r *SQLRepository) SaveHardwareProfile(profile *models.HardwareProfile) error {
+	var existing models.HardwareProfile
+	err := r.db.Where("name = ?", profile.Name).First(&existing).Error
+	if err == nil {
+		profile.ID = existing.ID
+		profile.Created = existing.Created
+		return r.db.Save(profile).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return r.db.Create(profile).Error
+}
+
+// GetHardwareProfile gets a hardware profile by ID
+func (r *SQLRepository) GetHardwareProfile(id string) (*models.HardwareProfile, error) {
+	var profile models.HardwareProfile
+	err := r.db.Where("id = ?", id).First(&profile).Error
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// ListHardwareProfiles lists hardware profiles by name, optionally only those for one plugin
+func (r *SQLRepository) ListHardwareProfiles(plugin string) ([]models.HardwareProfile, error) {
+	var profiles []models.HardwareProfile
+	err := NewQueryBuilder(r.db.Model(&models.HardwareProfile{}), nil).
+		Equals("plugin", plugin).
+		Query().
+		Order("name").
+		Find(&profiles).Error
+	return profiles, err
+}
+
+// DeleteHardwareProfile deletes a hardware profile, returning gorm.ErrRecordNotFound if there is none
+func (r *SQLRepository) DeleteHardwareProfile(id string) error {
+	result := r.db.Where("id = ?", id).Delete(&models.HardwareProfile{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AddDeviceWrites adds an execution's writes to a device's cumulative total and
+// returns the updated record, creating it for devices not seen before
+func (r *SQLRepository) AddDeviceWrites(device string, bytes int64, executionID string) (*models.DeviceEndurance, error) {
+	var endurance models.DeviceEndurance
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("device = ?", device).FirstOrCreate(&endurance, models.DeviceEndurance{Device: device}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&endurance).Updates(map[string]interface{}{
+			"bytes_written":     gorm.Expr("bytes_written + ?", bytes),
+			"executions":        gorm.Expr("executions + 1"),
+			"last_execution_id": executionID,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", endurance.ID).First(&endurance).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &endurance, nil
+}
+
+// ListDeviceEndurance lists the tracked devices by name
+func (r *SQLRepository) ListDeviceEndurance() ([]models.DeviceEndurance, error) {
+	var devices []models.DeviceEndurance
+	err := r.db.Order("device").Find(&devices).Error
+	return devices, err
+}
+
+// CreateAgentToken creates an agent token
+func (r *SQLRepository) CreateAgentToken(token *models.AgentToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetAgentTokenByHash gets the unrevoked agent token with the given hash
+func (r *SQLRepository) GetAgentTokenByHash(hash string) (*models.AgentToken, error) {
+	var token models.AgentToken
+	err := r.db.Where("token_hash = ? AND revoked_at IS NULL", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListAgentTokens lists agent tokens by name, revoked ones included
+func (r *SQLRepository) ListAgentTokens() ([]models.AgentToken, error) {
+	var tokens []models.AgentToken
+	err := r.db.Order("name").Find(&tokens).Error
+	return tokens, err
+}
+
+// TouchAgentToken records when an agent token was last used
+func (r *SQLRepository) TouchAgentToken(id string, used time.Time) error {
+	return r.db.Model(&models.AgentToken{}).Where("id = ?", id).Update("last_used", used).Error
+}
+
+// RecordAgentHandshake records the version an agent reported in a handshake
+func (r *SQLRepository) RecordAgentHandshake(id string, handshake models.AgentHandshake) error {
+	return r.db.Model(&models.AgentToken{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"agent_version":    handshake.Version,
+		"protocol_version": handshake.ProtocolVersion,
+		"platform":         handshake.Platform,
+		"last_handshake":   handshake.At,
+	}).Error
+}
+
+// RevokeAgentToken revokes an agent token, returning gorm.ErrRecordNotFound if
+// there is no unrevoked token with the ID
+func (r *SQLRepository) RevokeAgentToken(id string) error {
+	result := r.db.Model(&models.AgentToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
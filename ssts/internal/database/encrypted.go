@@ -0,0 +1,156 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/pranavgopavaram/ssts/internal/secrets"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// EncryptedRepository encrypts the secrets in users and test configurations
+// before the repository it wraps stores them, and decrypts them as they are
+// read, so the rest of the server only sees plaintext. Records stored before
+// encryption was enabled are read as they are and encrypted when next saved.
+// A test whose secrets can't be decrypted fails to be read on its own, but is
+// listed with those secrets redacted so it doesn't hide the others.
+//
+// When encryption is configured, wrap the repository the server and
+// orchestrator use with the keyring secrets.Load returns.
+type EncryptedRepository struct {
+	Repository
+	keys *secrets.Keyring
+}
+
+var _ Repository = (*EncryptedRepository)(nil)
+
+// NewEncryptedRepository wraps a repository to encrypt secrets with a keyring
+func NewEncryptedRepository(repo Repository, keys *secrets.Keyring) *EncryptedRepository {
+	return &EncryptedRepository{Repository: repo, keys: keys}
+}
+
+// Users
+
+func (r *EncryptedRepository) CreateUser(user *models.User) error {
+	preferences := user.Preferences
+	if err := r.keys.EncryptUser(user); err != nil {
+		return fmt.Errorf("failed to encrypt user secrets: %w", err)
+	}
+	err := r.Repository.CreateUser(user)
+	user.Preferences = preferences
+	return err
+}
+
+func (r *EncryptedRepository) GetUser(id string) (*models.User, error) {
+	return r.decryptUser(r.Repository.GetUser(id))
+}
+
+func (r *EncryptedRepository) GetUserByUsername(username string) (*models.User, error) {
+	return r.decryptUser(r.Repository.GetUserByUsername(username))
+}
+
+func (r *EncryptedRepository) GetUserByEmail(email string) (*models.User, error) {
+	return r.decryptUser(r.Repository.GetUserByEmail(email))
+}
+
+func (r *EncryptedRepository) UpdateUser(user *models.User) error {
+	preferences := user.Preferences
+	if err := r.keys.EncryptUser(user); err != nil {
+		return fmt.Errorf("failed to encrypt user secrets: %w", err)
+	}
+	err := r.Repository.UpdateUser(user)
+	user.Preferences = preferences
+	return err
+}
+
+func (r *EncryptedRepository) ListUsers() ([]models.User, error) {
+	users, err := r.Repository.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if err := r.keys.DecryptUser(&users[i]); err != nil {
+			return nil, fmt.Errorf("failed to decrypt secrets of user %s: %w", users[i].ID, err)
+		}
+	}
+	return users, nil
+}
+
+func (r *EncryptedRepository) decryptUser(user *models.User, err error) (*models.User, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := r.keys.DecryptUser(user); err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets of user %s: %w", user.ID, err)
+	}
+	return user, nil
+}
+
+// Test configurations
+
+func (r *EncryptedRepository) CreateTestConfiguration(config *models.TestConfiguration) error {
+	plain := *config
+	if err := r.keys.EncryptTest(config); err != nil {
+		return fmt.Errorf("failed to encrypt test secrets: %w", err)
+	}
+	err := r.Repository.CreateTestConfiguration(config)
+	config.Config, config.Probes = plain.Config, plain.Probes
+	return err
+}
+
+func (r *EncryptedRepository) GetTestConfiguration(id string) (*models.TestConfiguration, error) {
+	config, err := r.Repository.GetTestConfiguration(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.keys.DecryptTest(config); err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets of test %s: %w", id, err)
+	}
+	return config, nil
+}
+
+func (r *EncryptedRepository) ListTestConfigurations(limit, offset int) ([]models.TestConfiguration, error) {
+	return r.decryptTests(r.Repository.ListTestConfigurations(limit, offset))
+}
+
+func (r *EncryptedRepository) ListTestConfigurationsFiltered(filter TestFilter) ([]models.TestConfiguration, error) {
+	return r.decryptTests(r.Repository.ListTestConfigurationsFiltered(filter))
+}
+
+func (r *EncryptedRepository) UpdateTestConfiguration(config *models.TestConfiguration) error {
+	plain := *config
+	if err := r.keys.EncryptTest(config); err != nil {
+		return fmt.Errorf("failed to encrypt test secrets: %w", err)
+	}
+	err := r.Repository.UpdateTestConfiguration(config)
+	config.Config, config.Probes = plain.Config, plain.Probes
+	return err
+}
+
+func (r *EncryptedRepository) decryptTests(tests []models.TestConfiguration, err error) ([]models.TestConfiguration, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range tests {
+		if err := r.keys.DecryptTest(&tests[i]); err != nil {
+			redactUndecryptable(&tests[i])
+		}
+	}
+	return tests, nil
+}
+
+// redactUndecryptable replaces the secrets of a test that failed to decrypt with
+// secrets.Redacted, so it can be listed without them
+func redactUndecryptable(test *models.TestConfiguration) {
+	test.Config = secrets.RedactConfig(test.Config)
+	if test.Probes == nil {
+		return
+	}
+	probes := make(models.VictimProbes, len(test.Probes))
+	for i, probe := range test.Probes {
+		if secrets.IsEncrypted(probe.Target) {
+			probe.Target = secrets.Redacted
+		}
+		probes[i] = probe
+	}
+	test.Probes = probes
+}
@@ -0,0 +1,11 @@
+package database
+
+import "gorm.io/gorm"
+
+// Errors returned, possibly wrapped, by both repositories. They are gorm's own
+// errors so that the SQL repository can pass gorm's results straight through;
+// callers should test for them with errors.Is rather than gorm or the message.
+var (
+	ErrNotFound  = gorm.ErrRecordNotFound
+	ErrDuplicate = gorm.ErrDuplicatedKey
+)
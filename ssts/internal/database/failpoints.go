@@ -0,0 +1,35 @@
+package database
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/pranavgopavaram/ssts/internal/faults"
+)
+
+// registerFailpoints hooks the database failpoints into every GORM operation, so
+// repository error paths can be tested without a failing database
+func registerFailpoints(db *gorm.DB) error {
+	if !faults.Enabled {
+		return nil
+	}
+
+	inject := func(name string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if err := faults.Inject(tx.Statement.Context, name); err != nil {
+				tx.AddError(err)
+			}
+		}
+	}
+
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("faults:create", inject(faults.DatabaseCreate)); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("faults:query", inject(faults.DatabaseQuery)); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("faults:update", inject(faults.DatabaseUpdate)); err != nil {
+		return err
+	}
+	return callbacks.Delete().Before("gorm:delete").Register("faults:delete", inject(faults.DatabaseDelete))
+}
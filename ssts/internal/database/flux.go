@@ -0,0 +1,364 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	influxhttp "github.com/influxdata/influxdb-client-go/v2/api/http"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/faults"
+)
+
+// Custom Flux queries don't choose their data source. They are given a stream
+// of the metrics bucket already limited to the query's time range, and the
+// query's parameters as a record:
+//
+//	data = from(bucket: "metrics") |> range(start: ..., stop: ...)
+//	params = {host: "rack-1", threshold: 90}
+//
+// so a query such as
+//
+//	data
+//		|> filter(fn: (r) => r._measurement == "system_cpu" and r.host == params.host)
+//		|> filter(fn: (r) => r._value > params.threshold)
+//
+// can't read other buckets or time, nor have its parameters interpreted as
+// Flux. Queries calling from, to or buckets, importing packages outside
+// fluxAllowedImports, setting options or interpolating strings are refused.
+
+// ErrInvalidFluxQuery is returned for custom queries that are refused, or that
+// InfluxDB fails to compile
+var ErrInvalidFluxQuery = errors.New("invalid Flux query")
+
+// FluxQuery is a custom Flux query over the metrics bucket
+type FluxQuery struct {
+	Query      string
+	Params     map[string]interface{}
+	Start      time.Time
+	Stop       time.Time
+	MaxRecords int // records returned before the result is truncated
+}
+
+// FluxResult is the records a custom Flux query returned
+type FluxResult struct {
+	Records   []map[string]interface{}
+	Truncated bool // the query returned more than MaxRecords records
+}
+
+// FluxQuerier runs custom Flux queries
+type FluxQuerier interface {
+	QueryFlux(ctx context.Context, query FluxQuery) (*FluxResult, error)
+}
+
+// fluxAllowedImports are the packages custom queries may import: those
+// transforming data, and none reading or writing anything outside the query
+var fluxAllowedImports = map[string]bool{
+	"array":                  true,
+	"date":                   true,
+	"dict":                   true,
+	"experimental/aggregate": true,
+	"interpolate":            true,
+	"join":                   true,
+	"math":                   true,
+	"regexp":                 true,
+	"strings":                true,
+	"types":                  true,
+}
+
+// fluxForbiddenIdentifiers read or write buckets other than through data. They
+// are refused wherever they appear, so they can't be called under another name.
+var fluxForbiddenIdentifiers = map[string]bool{
+	"from":    true,
+	"to":      true,
+	"buckets": true,
+	"wideTo":  true,
+	"option":  true,
+}
+
+// Names the query's preamble defines
+const (
+	fluxDataIdentifier   = "data"
+	fluxParamsIdentifier = "params"
+)
+
+var fluxIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// fluxToken is a word or string literal of a Flux query
+type fluxToken struct {
+	text   string
+	str    bool // a string literal, text being its contents
+	member bool // a word following a dot, such as strings.title's title
+	end    int  // offset after the token
+}
+
+// tokenizeFlux splits a Flux query into words and string literals, dropping
+// comments and punctuation
+func tokenizeFlux(query string) ([]fluxToken, error) {
+	var tokens []fluxToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '/' && strings.HasPrefix(query[i:], "//"):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '/' && fluxRegexAllowed(query[:i]):
+			// A regular expression literal; skipped so quotes in it don't
+			// start strings
+			for i++; ; i++ {
+				if i >= len(query) || query[i] == '\n' {
+					return nil, fmt.Errorf("unterminated regular expression")
+				}
+				if query[i] == '\\' {
+					i++
+					continue
+				}
+				if query[i] == '/' {
+					i++
+					break
+				}
+			}
+		case c == '"':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(query) {
+					return nil, fmt.Errorf("unterminated string")
+				}
+				if query[i] == '"' {
+					i++
+					break
+				}
+				if strings.HasPrefix(query[i:], "${") {
+					return nil, fmt.Errorf("string interpolation is not allowed")
+				}
+				if query[i] == '\\' && i+1 < len(query) {
+					b.WriteByte(query[i+1])
+					i += 2
+					continue
+				}
+				b.WriteByte(query[i])
+				i++
+			}
+			tokens = append(tokens, fluxToken{text: b.String(), str: true, end: i})
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			start := i
+			for i < len(query) && (query[i] == '_' || query[i] >= 'A' && query[i] <= 'Z' || query[i] >= 'a' && query[i] <= 'z' || query[i] >= '0' && query[i] <= '9') {
+				i++
+			}
+			member := strings.HasSuffix(strings.TrimRight(query[:start], " \t\r\n"), ".")
+			tokens = append(tokens, fluxToken{text: query[start:i], member: member, end: i})
+		default:
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// fluxKeywords are the words a regular expression literal may follow
+var fluxKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "exists": true, "return": true, "if": true, "then": true, "else": true,
+}
+
+// fluxRegexAllowed reports whether a slash after the text starts a regular
+// expression rather than dividing, which it does after an operator, opening
+// bracket or keyword but not after an operand
+func fluxRegexAllowed(before string) bool {
+	before = strings.TrimRight(before, " \t\r\n")
+	if before == "" {
+		return true
+	}
+	word := before[strings.LastIndexFunc(before, func(r rune) bool {
+		return !(r == '_' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})+1:]
+	if word != "" {
+		return fluxKeywords[word]
+	}
+	c := before[len(before)-1]
+	return !(c == ')' || c == ']' || c == '}' || c == '"')
+}
+
+// CheckFluxQuery verifies a custom Flux query only reads data, returning the
+// offset its import statements end at, where the preamble is inserted
+func CheckFluxQuery(query string) (int, error) {
+	tokens, err := tokenizeFlux(query)
+	if err != nil {
+		return 0, err
+	}
+
+	importsEnd := 0
+	imports := true
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.str || token.member {
+			continue
+		}
+
+		if token.text == "import" {
+			if !imports {
+				return 0, fmt.Errorf("imports must come before the query")
+			}
+			// import ["alias"] "path"
+			if i+1 < len(tokens) && !tokens[i+1].str {
+				i++
+			}
+			if i+1 >= len(tokens) || !tokens[i+1].str {
+				return 0, fmt.Errorf("invalid import")
+			}
+			i++
+			if !fluxAllowedImports[tokens[i].text] {
+				return 0, fmt.Errorf("package %q can't be imported", tokens[i].text)
+			}
+			importsEnd = tokens[i].end
+			continue
+		}
+		imports = false
+
+		if fluxForbiddenIdentifiers[token.text] {
+			return 0, fmt.Errorf("%s is not allowed in custom queries, which read from %s", token.text, fluxDataIdentifier)
+		}
+	}
+	return importsEnd, nil
+}
+
+// fluxParams renders query parameters as a Flux record of literals
+func fluxParams(params map[string]interface{}) (string, error) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if !fluxIdentifier.MatchString(name) {
+			return "", fmt.Errorf("invalid parameter name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]string, len(names))
+	for i, name := range names {
+		var literal string
+		switch value := params[name].(type) {
+		case string:
+			literal = fluxString(value)
+		case bool:
+			literal = strconv.FormatBool(value)
+		case float64:
+			// JSON numbers; whole ones are integers in Flux
+			if math.IsInf(value, 0) || math.IsNaN(value) {
+				return "", fmt.Errorf("invalid parameter %s", name)
+			}
+			if value == math.Trunc(value) && math.Abs(value) < 1<<53 {
+				literal = strconv.FormatInt(int64(value), 10)
+			} else {
+				literal = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+		case int:
+			literal = strconv.Itoa(value)
+		case int64:
+			literal = strconv.FormatInt(value, 10)
+		default:
+			return "", fmt.Errorf("parameter %s must be a string, number or boolean", name)
+		}
+		fields[i] = name + ": " + literal
+	}
+	return "{" + strings.Join(fields, ", ") + "}", nil
+}
+
+// BuildFluxQuery checks a custom query and returns it with the preamble
+// defining data over the bucket and params
+func BuildFluxQuery(bucket string, query FluxQuery) (string, error) {
+	importsEnd, err := CheckFluxQuery(query.Query)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidFluxQuery, err)
+	}
+	params, err := fluxParams(query.Params)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidFluxQuery, err)
+	}
+
+	var b strings.Builder
+	b.WriteString(query.Query[:importsEnd])
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "%s = from(bucket: %s)\n", fluxDataIdentifier, fluxString(bucket))
+	fmt.Fprintf(&b, "\t|> range(start: %s, stop: %s)\n", query.Start.UTC().Format(time.RFC3339Nano), query.Stop.UTC().Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "%s = %s\n", fluxParamsIdentifier, params)
+	b.WriteString(query.Query[importsEnd:])
+	return b.String(), nil
+}
+
+// InfluxFluxQuerier runs custom Flux queries with its own token, which should
+// only be allowed to read the metrics bucket
+type InfluxFluxQuerier struct {
+	client   influxdb2.Client
+	queryAPI api.QueryAPI
+	bucket   string
+	timeout  time.Duration
+}
+
+// NewInfluxFluxQuerier creates a querier for the configured bucket,
+// authenticating with the Flux read token
+func NewInfluxFluxQuerier(cfg config.InfluxDBConfig) *InfluxFluxQuerier {
+	client := influxdb2.NewClient(cfg.URL, cfg.Flux.ReadToken)
+	return &InfluxFluxQuerier{
+		client:   client,
+		queryAPI: client.QueryAPI(cfg.Org),
+		bucket:   cfg.Bucket,
+		timeout:  cfg.Flux.Timeout,
+	}
+}
+
+// QueryFlux runs a custom query, stopping once it has returned MaxRecords records
+func (q *InfluxFluxQuerier) QueryFlux(ctx context.Context, query FluxQuery) (*FluxResult, error) {
+	if err := faults.Inject(ctx, faults.InfluxDBQuery); err != nil {
+		return nil, err
+	}
+
+	flux, err := BuildFluxQuery(q.bucket, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.timeout)
+		defer cancel()
+	}
+
+	result, err := q.queryAPI.Query(ctx, flux)
+	if err != nil {
+		var httpErr *influxhttp.Error
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusBadRequest {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFluxQuery, httpErr.Message)
+		}
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer result.Close()
+
+	response := &FluxResult{Records: []map[string]interface{}{}}
+	for result.Next() {
+		if query.MaxRecords > 0 && len(response.Records) == query.MaxRecords {
+			response.Truncated = true
+			break
+		}
+		response.Records = append(response.Records, result.Record().Values())
+	}
+
+	if result.Err() != nil {
+		return nil, fmt.Errorf("query result error: %w", result.Err())
+	}
+	return response, nil
+}
+
+// Close closes the querier's client
+func (q *InfluxFluxQuerier) Close() {
+	q.client.Close()
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBuildFluxQuery(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	query := FluxQuery{
+		Query: `import "strings"
+data |> filter(fn: (r) => r.host == params.host and r._value > params.threshold)`,
+		Params: map[string]interface{}{"host": `rack-1" or true //`, "threshold": 90.0, "ratio": 0.5, "raw": false},
+		Start:  start,
+		Stop:   start.Add(time.Hour),
+	}
+
+	want := `import "strings"
+data = from(bucket: "metrics")
+	|> range(start: 2024-01-02T03:00:00Z, stop: 2024-01-02T04:00:00Z)
+params = {host: "rack-1\" or true //", ratio: 0.5, raw: false, threshold: 90}
+
+data |> filter(fn: (r) => r.host == params.host and r._value > params.threshold)`
+	got, err := BuildFluxQuery("metrics", query)
+	if err != nil {
+		t.Fatalf("BuildFluxQuery: %v", err)
+	}
+	if got != want {
+		t.Errorf("BuildFluxQuery =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCheckFluxQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		ok    bool
+	}{
+		{"data", `data |> mean()`, true},
+		{"member named like a forbidden function", `import "array"
+array.from(rows: [{v: 1}])`, true},
+		{"aliased import", `import s "strings"
+data |> map(fn: (r) => ({r with host: s.toUpper(v: r.host)}))`, true},
+		{"forbidden word in a string", `data |> filter(fn: (r) => r.direction == "to")`, true},
+		{"forbidden word in a comment", `// from the last day
+data`, true},
+		{"division", `data |> map(fn: (r) => ({r with _value: r._value / 2.0 / 3.0}))`, true},
+		{"regex with a quote", `data |> filter(fn: (r) => r.host =~ /"rack/)`, true},
+
+		{"from", `from(bucket: "_monitoring") |> range(start: -1h)`, false},
+		{"from by another name", `f = from
+f(bucket: "other")`, false},
+		{"to", `data |> to(bucket: "metrics")`, false},
+		{"buckets", `buckets()`, false},
+		{"option", `option now = () => 2020-01-01T00:00:00Z
+data`, false},
+		{"disallowed import", `import "sql"
+sql.from(driverName: "postgres", dataSourceName: "", query: "")`, false},
+		{"disallowed import with an alias", `import h "http"
+data`, false},
+		{"import after the query", `data
+import "strings"`, false},
+		{"interpolation", `data |> filter(fn: (r) => r.host == "${r.tag}")`, false},
+		{"regex hiding a call", `data |> filter(fn: (r) => r.host =~ /"/ or exists from(bucket: "x") or r.x =~ /"/)`, false},
+		{"regex after a keyword hiding a call", `f = () => {
+return /"/ + from(bucket: "x") + /"/
+}`, false},
+		{"unterminated string", `data |> filter(fn: (r) => r.host == "rack)`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CheckFluxQuery(tt.query)
+			if tt.ok && err != nil {
+				t.Errorf("CheckFluxQuery() = %v, want the query allowed", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("CheckFluxQuery() = nil, want the query refused")
+			}
+		})
+	}
+}
+
+func TestBuildFluxQueryRefusesInvalidParams(t *testing.T) {
+	for name, params := range map[string]map[string]interface{}{
+		"name":   {"host) //": "x"},
+		"nested": {"hosts": []interface{}{"a", "b"}},
+	} {
+		_, err := BuildFluxQuery("metrics", FluxQuery{Query: "data", Params: params})
+		if !errors.Is(err, ErrInvalidFluxQuery) {
+			t.Errorf("%s: BuildFluxQuery() = %v, want ErrInvalidFluxQuery", name, err)
+		}
+	}
+}
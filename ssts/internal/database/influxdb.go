@@ -9,6 +9,7 @@ import (
 	"github.com/influxdata/influxdb-client-go/v2/api"
 
 	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/faults"
 	"github.com/pranavgopavaram/ssts/pkg/models"
 )
 
@@ -46,6 +47,10 @@ func NewInfluxDB(cfg config.InfluxDBConfig) *InfluxDB {
 
 // WriteMetricPoint writes a metric point to InfluxDB
 func (idb *InfluxDB) WriteMetricPoint(point models.MetricPoint) error {
+	if err := faults.Inject(context.Background(), faults.InfluxDBWrite); err != nil {
+		return err
+	}
+
 	p := influxdb2.NewPointWithMeasurement(point.Type).
 		SetTime(point.Timestamp)
 
@@ -69,6 +74,10 @@ func (idb *InfluxDB) WriteMetricPoint(point models.MetricPoint) error {
 
 // WriteSystemMetrics writes system metrics to InfluxDB
 func (idb *InfluxDB) WriteSystemMetrics(testID string, metrics models.SystemMetrics) error {
+	if err := faults.Inject(context.Background(), faults.InfluxDBWrite); err != nil {
+		return err
+	}
+
 	timestamp := metrics.Timestamp
 
 	// CPU metrics
@@ -133,11 +142,68 @@ func (idb *InfluxDB) WriteSystemMetrics(testID string, metrics models.SystemMetr
 	idb.writeAPI.WritePoint(diskPoint)
 	idb.writeAPI.WritePoint(networkPoint)
 
+	// Per-device points share the aggregate measurements, distinguished by tag
+	for _, device := range metrics.Disk.Devices {
+		idb.writeAPI.WritePoint(influxdb2.NewPointWithMeasurement("system_io").
+			SetTime(timestamp).
+			AddTag("test_id", testID).
+			AddTag("host_id", "localhost").
+			AddTag("device_name", device.Device).
+			AddTag("mountpoint", device.Mountpoint).
+			AddField("read_bytes_per_sec", device.ReadBytesPerSec).
+			AddField("write_bytes_per_sec", device.WriteBytesPerSec).
+			AddField("read_ops_per_sec", device.ReadOpsPerSec).
+			AddField("write_ops_per_sec", device.WriteOpsPerSec).
+			AddField("latency_ms", device.LatencyMs).
+			AddField("total_bytes", device.TotalBytes).
+			AddField("used_bytes", device.UsedBytes).
+			AddField("usage_percent", device.UsagePercent))
+	}
+
+	for _, nic := range metrics.Network.Interfaces {
+		idb.writeAPI.WritePoint(influxdb2.NewPointWithMeasurement("system_network").
+			SetTime(timestamp).
+			AddTag("test_id", testID).
+			AddTag("host_id", "localhost").
+			AddTag("interface_name", nic.Name).
+			AddField("rx_bytes_per_sec", nic.RxBytesPerSec).
+			AddField("tx_bytes_per_sec", nic.TxBytesPerSec).
+			AddField("rx_packets_per_sec", nic.RxPacketsPerSec).
+			AddField("tx_packets_per_sec", nic.TxPacketsPerSec).
+			AddField("rx_errors", nic.RxErrors).
+			AddField("tx_errors", nic.TxErrors))
+	}
+
+	// One point per temperature sensor and fan
+	for _, sensor := range metrics.Thermal.Temperatures {
+		idb.writeAPI.WritePoint(influxdb2.NewPointWithMeasurement("system_temperature").
+			SetTime(timestamp).
+			AddTag("test_id", testID).
+			AddTag("host_id", "localhost").
+			AddTag("sensor", sensor.Name).
+			AddField("celsius", sensor.Celsius).
+			AddField("high_celsius", sensor.HighCelsius).
+			AddField("critical_celsius", sensor.CriticalCelsius))
+	}
+
+	for _, fan := range metrics.Thermal.Fans {
+		idb.writeAPI.WritePoint(influxdb2.NewPointWithMeasurement("system_fan").
+			SetTime(timestamp).
+			AddTag("test_id", testID).
+			AddTag("host_id", "localhost").
+			AddTag("sensor", fan.Name).
+			AddField("rpm", fan.RPM))
+	}
+
 	return nil
 }
 
 // WriteCustomMetrics writes plugin-specific metrics to InfluxDB
 func (idb *InfluxDB) WriteCustomMetrics(testID, pluginName string, metrics map[string]interface{}) error {
+	if err := faults.Inject(context.Background(), faults.InfluxDBWrite); err != nil {
+		return err
+	}
+
 	point := influxdb2.NewPointWithMeasurement("custom_metrics").
 		SetTime(time.Now()).
 		AddTag("test_id", testID).
@@ -153,6 +219,10 @@ func (idb *InfluxDB) WriteCustomMetrics(testID, pluginName string, metrics map[s
 
 // QueryMetrics queries metrics from InfluxDB
 func (idb *InfluxDB) QueryMetrics(ctx context.Context, testID string, measurement string, timeRange models.TimeRange) ([]models.MetricPoint, error) {
+	if err := faults.Inject(ctx, faults.InfluxDBQuery); err != nil {
+		return nil, err
+	}
+
 	query := fmt.Sprintf(`
 		from(bucket: "%s")
 		|> range(start: %s, stop: %s)
@@ -203,8 +273,67 @@ func (idb *InfluxDB) QueryMetrics(ctx context.Context, testID string, measuremen
 	return metrics, nil
 }
 
+// StreamTestMetrics calls fn for every raw field value written for a test within the
+// time range, across all measurements, without buffering the result. Iteration stops
+// at the first error returned by fn.
+func (idb *InfluxDB) StreamTestMetrics(ctx context.Context, testID string, timeRange models.TimeRange, fn func(models.MetricPoint) error) error {
+	if err := faults.Inject(ctx, faults.InfluxDBQuery); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r.test_id == "%s")
+	`, idb.bucket, timeRange.Start.Format(time.RFC3339Nano), timeRange.End.Format(time.RFC3339Nano), testID)
+
+	result, err := idb.queryAPI.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer result.Close()
+
+	for result.Next() {
+		record := result.Record()
+
+		source, _ := record.ValueByKey("source").(string)
+		metric := models.MetricPoint{
+			Timestamp: record.Time(),
+			TestID:    testID,
+			Source:    source,
+			Type:      record.Measurement(),
+			Tags:      make(map[string]string),
+			Fields:    map[string]interface{}{record.Field(): record.Value()},
+		}
+
+		for k, v := range record.Values() {
+			switch k {
+			case "_time", "_value", "_field", "_measurement", "_start", "_stop", "result", "table", "test_id", "source":
+				continue
+			}
+			if str, ok := v.(string); ok {
+				metric.Tags[k] = str
+			}
+		}
+
+		if err := fn(metric); err != nil {
+			return err
+		}
+	}
+
+	if result.Err() != nil {
+		return fmt.Errorf("query result error: %w", result.Err())
+	}
+
+	return nil
+}
+
 // QuerySystemMetrics queries system metrics for a specific time range
 func (idb *InfluxDB) QuerySystemMetrics(ctx context.Context, testID string, timeRange models.TimeRange) ([]models.SystemMetrics, error) {
+	if err := faults.Inject(ctx, faults.InfluxDBQuery); err != nil {
+		return nil, err
+	}
+
 	query := fmt.Sprintf(`
 		import "join"
 		
@@ -263,6 +392,10 @@ func (idb *InfluxDB) QuerySystemMetrics(ctx context.Context, testID string, time
 
 // QueryLatestMetrics queries the latest metrics for a test
 func (idb *InfluxDB) QueryLatestMetrics(ctx context.Context, testID string, measurement string, limit int) ([]models.MetricPoint, error) {
+	if err := faults.Inject(ctx, faults.InfluxDBQuery); err != nil {
+		return nil, err
+	}
+
 	query := fmt.Sprintf(`
 		from(bucket: "%s")
 		|> range(start: -1h)
@@ -330,6 +463,10 @@ func (idb *InfluxDB) Close() {
 
 // HealthCheck performs a health check on InfluxDB
 func (idb *InfluxDB) HealthCheck(ctx context.Context) error {
+	if err := faults.Inject(ctx, faults.InfluxDBHealth); err != nil {
+		return err
+	}
+
 	health, err := idb.client.Health(ctx)
 	if err != nil {
 		return fmt.Errorf("InfluxDB health check failed: %w", err)
@@ -0,0 +1,1040 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// MemoryRepository is a Repository kept in memory, so handlers and the
+// orchestrator can be tested without a database. Records are copied in and out,
+// so callers can't change stored records without going through the repository.
+// It mirrors the SQL repository's ordering, filtering and not-found errors, and
+// returns gorm.ErrDuplicatedKey where the schema has a unique index.
+type MemoryRepository struct {
+	mu            sync.RWMutex
+	users         map[string]models.User
+	tests         map[string]models.TestConfiguration
+	executions    map[string]models.TestExecution
+	plugins       map[string]models.Plugin // by name
+	alerts        map[string]models.Alert
+	muteRules     map[string]models.AlertMuteRule
+	projects      map[string]models.Project
+	members       map[string]models.ProjectMember
+	savedSearches map[string]models.SavedSearch
+	annotations   map[string]models.Annotation
+	violations    map[string]models.Violation
+	profiles      map[string]models.HardwareProfile
+	deviceWrites  map[string]models.DeviceEndurance // by device
+	agentTokens   map[string]models.AgentToken
+	now           func() time.Time
+}
+
+// NewMemoryRepository creates an empty in-memory repository with the default
+// project, as a migrated database has
+func NewMemoryRepository() *MemoryRepository {
+	r := &MemoryRepository{
+		users:         make(map[string]models.User),
+		tests:         make(map[string]models.TestConfiguration),
+		executions:    make(map[string]models.TestExecution),
+		plugins:       make(map[string]models.Plugin),
+		alerts:        make(map[string]models.Alert),
+		muteRules:     make(map[string]models.AlertMuteRule),
+		projects:      make(map[string]models.Project),
+		members:       make(map[string]models.ProjectMember),
+		savedSearches: make(map[string]models.SavedSearch),
+		annotations:   make(map[string]models.Annotation),
+		violations:    make(map[string]models.Violation),
+		profiles:      make(map[string]models.HardwareProfile),
+		deviceWrites:  make(map[string]models.DeviceEndurance),
+		agentTokens:   make(map[string]models.AgentToken),
+		now:           func() time.Time { return time.Now().UTC() },
+	}
+	r.CreateProject(&models.Project{Name: models.DefaultProjectName, Description: "Default project"})
+	return r
+}
+
+// HealthCheck always succeeds
+func (r *MemoryRepository) HealthCheck() error {
+	return nil
+}
+
+// Close does nothing; the records stay readable
+func (r *MemoryRepository) Close() error {
+	return nil
+}
+
+// Users repository methods
+func (r *MemoryRepository) CreateUser(user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.users {
+		if existing.Username == user.Username || existing.Email == user.Email {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	user.BeforeCreate()
+	if user.Created.IsZero() {
+		user.Created = r.now()
+	}
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *MemoryRepository) GetUser(id string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return found(r.users, id)
+}
+
+func (r *MemoryRepository) GetUserByUsername(username string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return first(r.users, func(user models.User) bool { return user.Username == username })
+}
+
+func (r *MemoryRepository) GetUserByEmail(email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return first(r.users, func(user models.User) bool { return user.Email == email })
+}
+
+func (r *MemoryRepository) UpdateUser(user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.BeforeCreate()
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *MemoryRepository) ListUsers() ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := where(r.users, func(models.User) bool { return true })
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+// Test configurations repository methods
+func (r *MemoryRepository) CreateTestConfiguration(config *models.TestConfiguration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tests[config.ID]; exists && config.ID != "" {
+		return gorm.ErrDuplicatedKey
+	}
+	config.BeforeCreate()
+	now := r.now()
+	if config.Created.IsZero() {
+		config.Created = now
+	}
+	config.Updated = now
+	r.tests[config.ID] = *config
+	return nil
+}
+
+func (r *MemoryRepository) GetTestConfiguration(id string) (*models.TestConfiguration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return found(r.tests, id)
+}
+
+func (r *MemoryRepository) ListTestConfigurations(limit, offset int) ([]models.TestConfiguration, error) {
+	return r.ListTestConfigurationsFiltered(TestFilter{Limit: limit, Offset: offset})
+}
+
+// ListTestConfigurationsFiltered lists test configurations matching the filter
+func (r *MemoryRepository) ListTestConfigurationsFiltered(filter TestFilter) ([]models.TestConfiguration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	nameContains := strings.ToLower(filter.NameContains)
+	configs := where(r.tests, func(config models.TestConfiguration) bool {
+		return equals(config.ProjectID, filter.ProjectID) &&
+			equals(config.Plugin, filter.Plugin) &&
+			equals(config.CreatedBy, filter.CreatedBy) &&
+			strings.Contains(strings.ToLower(config.Name), nameContains) &&
+			between(config.Created, filter.CreatedAfter, filter.CreatedBefore) &&
+			labelsMatch(config.Labels, filter.Labels)
+	})
+	sortBy(configs, filter.Sort, SortField{Field: "created", Desc: true}, testSortValue)
+	return paginate(configs, filter.Limit, filter.Offset), nil
+}
+
+func (r *MemoryRepository) UpdateTestConfiguration(config *models.TestConfiguration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	config.BeforeCreate()
+	config.Updated = r.now()
+	r.tests[config.ID] = *config
+	return nil
+}
+
+func (r *MemoryRepository) DeleteTestConfiguration(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tests, id)
+	return nil
+}
+
+// Test executions repository methods
+func (r *MemoryRepository) CreateTestExecution(execution *models.TestExecution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.executions[execution.ID]; exists && execution.ID != "" {
+		return gorm.ErrDuplicatedKey
+	}
+	execution.BeforeCreate()
+	if execution.Created.IsZero() {
+		execution.Created = r.now()
+	}
+	r.executions[execution.ID] = stored(*execution)
+	return nil
+}
+
+func (r *MemoryRepository) GetTestExecution(id string) (*models.TestExecution, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return found(r.executions, id)
+}
+
+func (r *MemoryRepository) ListTestExecutions(limit, offset int) ([]models.TestExecution, error) {
+	return r.ListTestExecutionsFiltered(ExecutionFilter{Limit: limit, Offset: offset})
+}
+
+func (r *MemoryRepository) ListTestExecutionsByStatus(status models.ExecutionStatus, limit, offset int) ([]models.TestExecution, error) {
+	return r.ListTestExecutionsFiltered(ExecutionFilter{Statuses: []models.ExecutionStatus{status}, Limit: limit, Offset: offset})
+}
+
+// ListTestExecutionsFiltered lists test executions matching the filter. Plugin and
+// creator filters are resolved through the owning test configuration.
+func (r *MemoryRepository) ListTestExecutionsFiltered(filter ExecutionFilter) ([]models.TestExecution, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	nameContains := strings.ToLower(filter.TestNameContains)
+	executions := where(r.executions, func(execution models.TestExecution) bool {
+		if !equals(execution.ProjectID, filter.ProjectID) ||
+			!equals(execution.TestID, filter.TestID) ||
+			!equals(execution.StartedBy, filter.StartedBy) ||
+			!betweenPtr(execution.StartTime, filter.StartedAfter, filter.StartedBefore) ||
+			!labelsMatch(execution.Labels, filter.Labels) ||
+			!strings.HasPrefix(strings.ToLower(execution.CI.Commit), strings.ToLower(filter.CICommit)) ||
+			!equals(execution.CI.BuildNumber, filter.CIBuildNumber) {
+			return false
+		}
+		if len(filter.Statuses) > 0 && !containsStatus(filter.Statuses, execution.Status) {
+			return false
+		}
+		if len(filter.ReviewStatuses) > 0 && !containsReviewStatus(filter.ReviewStatuses, execution.ReviewStatus) {
+			return false
+		}
+		if nameContains != "" || filter.Plugin != "" || filter.CreatedBy != "" {
+			test, ok := r.tests[execution.TestID]
+			return ok && strings.Contains(strings.ToLower(test.Name), nameContains) &&
+				equals(test.Plugin, filter.Plugin) && equals(test.CreatedBy, filter.CreatedBy)
+		}
+		return true
+	})
+	sortBy(executions, filter.Sort, SortField{Field: "created", Desc: true}, executionSortValue)
+	return paginate(executions, filter.Limit, filter.Offset), nil
+}
+
+// ListCompletedExecutions lists the completed executions of a test, most recently
+// finished first, optionally only those finished since a time
+func (r *MemoryRepository) ListCompletedExecutions(testID string, since *time.Time) ([]models.TestExecution, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	executions := where(r.executions, func(execution models.TestExecution) bool {
+		return execution.TestID == testID && execution.Status == models.StatusCompleted &&
+			(since == nil || (execution.EndTime != nil && !execution.EndTime.Before(*since)))
+	})
+	sortBy(executions, nil, SortField{Field: "end_time", Desc: true}, executionSortValue)
+	return executions, nil
+}
+
+// ListExecutionsByTest lists the executions of a test, newest first
+func (r *MemoryRepository) ListExecutionsByTest(testID string, limit, offset int) ([]models.TestExecution, error) {
+	return r.ListTestExecutionsFiltered(ExecutionFilter{TestID: testID, Limit: limit, Offset: offset})
+}
+
+// GetLatestExecutionForTest returns the newest execution of a test with one of
+// the statuses, or with any status when none are given
+func (r *MemoryRepository) GetLatestExecutionForTest(testID string, statuses ...models.ExecutionStatus) (*models.TestExecution, error) {
+	executions, err := r.ListTestExecutionsFiltered(ExecutionFilter{TestID: testID, Statuses: statuses, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(executions) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &executions[0], nil
+}
+
+// GetRunningExecutionForTest returns the newest queued, pending or running
+// execution of a test
+func (r *MemoryRepository) GetRunningExecutionForTest(testID string) (*models.TestExecution, error) {
+	return r.GetLatestExecutionForTest(testID, activeStatuses...)
+}
+
+// ListUnfinishedSoakExecutions lists soak executions last recorded as queued, pending
+// or running, i.e. interrupted by a restart
+func (r *MemoryRepository) ListUnfinishedSoakExecutions() ([]models.TestExecution, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	unfinished := []models.ExecutionStatus{models.StatusQueued, models.StatusPending, models.StatusRunning}
+	executions := where(r.executions, func(execution models.TestExecution) bool {
+		return execution.Soak && containsStatus(unfinished, execution.Status)
+	})
+	sortBy(executions, nil, SortField{Field: "start_time"}, executionSortValue)
+	return executions, nil
+}
+
+func (r *MemoryRepository) UpdateTestExecution(execution *models.TestExecution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	execution.BeforeCreate()
+	r.executions[execution.ID] = stored(*execution)
+	return nil
+}
+
+// UpdateExecutionReview moves the review of an execution on from the given status,
+// returning ErrNotFound if the execution doesn't exist or its review has moved on
+func (r *MemoryRepository) UpdateExecutionReview(id string, from models.ReviewStatus, review models.ExecutionReview) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	execution, ok := r.executions[id]
+	if !ok || execution.ReviewStatus != from {
+		return gorm.ErrRecordNotFound
+	}
+	execution.ReviewStatus = review.Status
+	execution.Reviewer = review.Reviewer
+	execution.ReviewNotes = review.Notes
+	execution.Reviewed = &review.At
+	r.executions[id] = execution
+	return nil
+}
+
+func (r *MemoryRepository) DeleteTestExecution(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.executions, id)
+	for violationID, violation := range r.violations {
+		if violation.ExecutionID == id {
+			delete(r.violations, violationID)
+		}
+	}
+	return nil
+}
+
+// Plugin repository methods
+func (r *MemoryRepository) CreatePlugin(plugin *models.Plugin) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.plugins[plugin.Name]; exists {
+		return gorm.ErrDuplicatedKey
+	}
+	plugin.BeforeCreate()
+	if plugin.InstalledAt.IsZero() {
+		plugin.InstalledAt = r.now()
+	}
+	r.plugins[plugin.Name] = *plugin
+	return nil
+}
+
+func (r *MemoryRepository) GetPlugin(name string) (*models.Plugin, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return found(r.plugins, name)
+}
+
+func (r *MemoryRepository) ListPlugins() ([]models.Plugin, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	plugins := where(r.plugins, func(plugin models.Plugin) bool { return plugin.Enabled })
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, nil
+}
+
+// ListAllPlugins returns every plugin, including disabled ones
+func (r *MemoryRepository) ListAllPlugins() ([]models.Plugin, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	plugins := where(r.plugins, func(models.Plugin) bool { return true })
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, nil
+}
+
+func (r *MemoryRepository) UpdatePlugin(plugin *models.Plugin) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	plugin.BeforeCreate()
+	r.plugins[plugin.Name] = *plugin
+	return nil
+}
+
+func (r *MemoryRepository) DeletePlugin(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.plugins, name)
+	return nil
+}
+
+// Alert repository methods
+func (r *MemoryRepository) CreateAlert(alert *models.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.alerts[alert.ID]; exists && alert.ID != "" {
+		return gorm.ErrDuplicatedKey
+	}
+	alert.BeforeCreate()
+	if alert.Created.IsZero() {
+		alert.Created = r.now()
+	}
+	r.alerts[alert.ID] = *alert
+	return nil
+}
+
+func (r *MemoryRepository) GetAlert(id string) (*models.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return found(r.alerts, id)
+}
+
+// ListAlerts lists alerts matching the filter, newest first by default
+func (r *MemoryRepository) ListAlerts(filter AlertFilter) ([]models.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	alerts := where(r.alerts, func(alert models.Alert) bool {
+		return (alert.ProjectID == "" || equals(alert.ProjectID, filter.ProjectID)) &&
+			equals(alert.Type, filter.Type) &&
+			(len(filter.Severities) == 0 || containsString(filter.Severities, alert.Severity)) &&
+			between(alert.Timestamp, filter.Since, filter.Until) &&
+			(filter.Acknowledged == nil || alert.Acknowledged == *filter.Acknowledged) &&
+			(filter.IncludeMuted || !alert.Muted)
+	})
+	sortBy(alerts, filter.Sort, SortField{Field: "timestamp", Desc: true}, alertSortValue)
+	return paginate(alerts, filter.Limit, filter.Offset), nil
+}
+
+// AcknowledgeAlert marks an alert as acknowledged by the given operator
+func (r *MemoryRepository) AcknowledgeAlert(id, by string) (*models.Alert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	alert, ok := r.alerts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	now := r.now()
+	alert.Acknowledged = true
+	alert.AcknowledgedBy = &by
+	alert.AcknowledgedAt = &now
+	r.alerts[id] = alert
+	return &alert, nil
+}
+
+// Alert mute rule repository methods
+func (r *MemoryRepository) CreateAlertMuteRule(rule *models.AlertMuteRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rule.BeforeCreate()
+	if rule.Created.IsZero() {
+		rule.Created = r.now()
+	}
+	r.muteRules[rule.ID] = *rule
+	return nil
+}
+
+// ListActiveAlertMuteRules returns mute rules that have not expired
+func (r *MemoryRepository) ListActiveAlertMuteRules() ([]models.AlertMuteRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := r.now()
+	rules := where(r.muteRules, func(rule models.AlertMuteRule) bool {
+		return rule.ExpiresAt == nil || rule.ExpiresAt.After(now)
+	})
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Created.After(rules[j].Created) })
+	return rules, nil
+}
+
+func (r *MemoryRepository) DeleteAlertMuteRule(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.muteRules, id)
+	return nil
+}
+
+// Project repository methods
+func (r *MemoryRepository) CreateProject(project *models.Project) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.projects {
+		if existing.Name == project.Name {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	project.BeforeCreate()
+	if project.Created.IsZero() {
+		project.Created = r.now()
+	}
+	r.projects[project.ID] = *project
+	return nil
+}
+
+func (r *MemoryRepository) GetProject(id string) (*models.Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return found(r.projects, id)
+}
+
+func (r *MemoryRepository) GetProjectByName(name string) (*models.Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return first(r.projects, func(project models.Project) bool { return project.Name == name })
+}
+
+func (r *MemoryRepository) ListProjects() ([]models.Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	projects := where(r.projects, func(models.Project) bool { return true })
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+// ListProjectsForUser lists the projects a user is a member of
+func (r *MemoryRepository) ListProjectsForUser(userID string) ([]models.Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	member := make(map[string]bool)
+	for _, m := range r.members {
+		if m.UserID == userID {
+			member[m.ProjectID] = true
+		}
+	}
+	projects := where(r.projects, func(project models.Project) bool { return member[project.ID] })
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+// DeleteProject deletes a project and its memberships. Tests and executions are kept.
+func (r *MemoryRepository) DeleteProject(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for memberID, member := range r.members {
+		if member.ProjectID == id {
+			delete(r.members, memberID)
+		}
+	}
+	delete(r.projects, id)
+	return nil
+}
+
+// Project member repository methods
+func (r *MemoryRepository) GetProjectMember(projectID, userID string) (*models.ProjectMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return first(r.members, func(member models.ProjectMember) bool {
+		return member.ProjectID == projectID && member.UserID == userID
+	})
+}
+
+func (r *MemoryRepository) ListProjectMembers(projectID string) ([]models.ProjectMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	members := where(r.members, func(member models.ProjectMember) bool { return member.ProjectID == projectID })
+	sort.Slice(members, func(i, j int) bool { return members[i].Created.Before(members[j].Created) })
+	return members, nil
+}
+
+// SetProjectMember grants a user a role in a project, replacing any existing role
+func (r *MemoryRepository) SetProjectMember(projectID, userID string, role models.ProjectRole) (*models.ProjectMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, member := range r.members {
+		if member.ProjectID == projectID && member.UserID == userID {
+			member.Role = role
+			r.members[id] = member
+			return &member, nil
+		}
+	}
+	member := models.ProjectMember{ProjectID: projectID, UserID: userID, Role: role, Created: r.now()}
+	member.BeforeCreate()
+	r.members[member.ID] = member
+	return &member, nil
+}
+
+func (r *MemoryRepository) RemoveProjectMember(projectID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, member := range r.members {
+		if member.ProjectID == projectID && member.UserID == userID {
+			delete(r.members, id)
+		}
+	}
+	return nil
+}
+
+// Saved search repository methods
+func (r *MemoryRepository) CreateSavedSearch(search *models.SavedSearch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	search.BeforeCreate()
+	if search.Created.IsZero() {
+		search.Created = r.now()
+	}
+	r.savedSearches[search.ID] = *search
+	return nil
+}
+
+// GetSavedSearch returns a user's saved search
+func (r *MemoryRepository) GetSavedSearch(id, userID string) (*models.SavedSearch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	search, ok := r.savedSearches[id]
+	if !ok || search.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &search, nil
+}
+
+// ListSavedSearches lists a user's saved searches, optionally only those for one resource
+func (r *MemoryRepository) ListSavedSearches(userID, resource string) ([]models.SavedSearch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	searches := where(r.savedSearches, func(search models.SavedSearch) bool {
+		return search.UserID == userID && equals(search.Resource, resource)
+	})
+	sort.Slice(searches, func(i, j int) bool { return searches[i].Name < searches[j].Name })
+	return searches, nil
+}
+
+// DeleteSavedSearch deletes a user's saved search, returning gorm.ErrRecordNotFound if there is none
+func (r *MemoryRepository) DeleteSavedSearch(id, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	search, ok := r.savedSearches[id]
+	if !ok || search.UserID != userID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.savedSearches, id)
+	return nil
+}
+
+// CreateAnnotation stores an annotation
+func (r *MemoryRepository) CreateAnnotation(annotation *models.Annotation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	annotation.BeforeCreate()
+	if annotation.Created.IsZero() {
+		annotation.Created = r.now()
+	}
+	r.annotations[annotation.ID] = *annotation
+	return nil
+}
+
+// ListAnnotations lists an execution's annotations in time order
+func (r *MemoryRepository) ListAnnotations(executionID string) ([]models.Annotation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	annotations := where(r.annotations, func(annotation models.Annotation) bool { return annotation.ExecutionID == executionID })
+	sort.Slice(annotations, func(i, j int) bool { return annotations[i].Timestamp.Before(annotations[j].Timestamp) })
+	return annotations, nil
+}
+
+// CreateViolation stores a safety violation
+func (r *MemoryRepository) CreateViolation(violation *models.Violation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	violation.BeforeCreate()
+	r.violations[violation.ID] = *violation
+	return nil
+}
+
+// ListViolations lists the safety violations recorded for an execution in time order
+func (r *MemoryRepository) ListViolations(executionID string) ([]models.Violation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	violations := where(r.violations, func(violation models.Violation) bool { return violation.ExecutionID == executionID })
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Timestamp.Before(violations[j].Timestamp) })
+	return violations, nil
+}
+
+// DeleteAnnotation deletes an execution's annotation, returning gorm.ErrRecordNotFound if there is none
+func (r *MemoryRepository) DeleteAnnotation(executionID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	annotation, ok := r.annotations[id]
+	if !ok || annotation.ExecutionID != executionID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.annotations, id)
+	return nil
+}
+
+// SaveHardwareProfile creates a hardware profile, or replaces the one with the same name
+func (r *MemoryRepository) SaveHardwareProfile(profile *models.HardwareProfile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	profile.Created = now
+	for _, existing := range r.profiles {
+		if existing.Name == profile.Name {
+			profile.ID = existing.ID
+			profile.Created = existing.Created
+		}
+	}
+	profile.BeforeCreate()
+	profile.Updated = now
+	r.profiles[profile.ID] = *profile
+	return nil
+}
+
+// GetHardwareProfile gets a hardware profile by ID
+func (r *MemoryRepository) GetHardwareProfile(id string) (*models.HardwareProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return found(r.profiles, id)
+}
+
+// ListHardwareProfiles lists hardware profiles by name, optionally only those for one plugin
+func (r *MemoryRepository) ListHardwareProfiles(plugin string) ([]models.HardwareProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	profiles := where(r.profiles, func(profile models.HardwareProfile) bool { return equals(profile.Plugin, plugin) })
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// DeleteHardwareProfile deletes a hardware profile, returning gorm.ErrRecordNotFound if there is none
+func (r *MemoryRepository) DeleteHardwareProfile(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.profiles[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.profiles, id)
+	return nil
+}
+
+// AddDeviceWrites adds an execution's writes to a device's cumulative total and
+// returns the updated record, creating it for devices not seen before
+func (r *MemoryRepository) AddDeviceWrites(device string, bytes int64, executionID string) (*models.DeviceEndurance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	endurance, ok := r.deviceWrites[device]
+	now := r.now()
+	if !ok {
+		endurance = models.DeviceEndurance{Device: device, Created: now}
+		endurance.BeforeCreate()
+	}
+	endurance.BytesWritten += bytes
+	endurance.Executions++
+	endurance.LastExecutionID = executionID
+	endurance.Updated = now
+	r.deviceWrites[device] = endurance
+	return &endurance, nil
+}
+
+// ListDeviceEndurance lists the tracked devices by name
+func (r *MemoryRepository) ListDeviceEndurance() ([]models.DeviceEndurance, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	devices := where(r.deviceWrites, func(models.DeviceEndurance) bool { return true })
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Device < devices[j].Device })
+	return devices, nil
+}
+
+// CreateAgentToken creates an agent token
+func (r *MemoryRepository) CreateAgentToken(token *models.AgentToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.agentTokens {
+		if existing.Name == token.Name || existing.TokenHash == token.TokenHash {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	token.BeforeCreate()
+	if token.Created.IsZero() {
+		token.Created = r.now()
+	}
+	r.agentTokens[token.ID] = *token
+	return nil
+}
+
+// GetAgentTokenByHash gets the unrevoked agent token with the given hash
+func (r *MemoryRepository) GetAgentTokenByHash(hash string) (*models.AgentToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return first(r.agentTokens, func(token models.AgentToken) bool {
+		return token.TokenHash == hash && token.RevokedAt == nil
+	})
+}
+
+// ListAgentTokens lists agent tokens by name, revoked ones included
+func (r *MemoryRepository) ListAgentTokens() ([]models.AgentToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tokens := where(r.agentTokens, func(models.AgentToken) bool { return true })
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Name < tokens[j].Name })
+	return tokens, nil
+}
+
+// TouchAgentToken records when an agent token was last used
+func (r *MemoryRepository) TouchAgentToken(id string, used time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.agentTokens[id]; ok {
+		token.LastUsed = &used
+		r.agentTokens[id] = token
+	}
+	return nil
+}
+
+// RecordAgentHandshake records the version an agent reported in a handshake
+func (r *MemoryRepository) RecordAgentHandshake(id string, handshake models.AgentHandshake) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.agentTokens[id]; ok {
+		token.AgentVersion = handshake.Version
+		token.ProtocolVersion = handshake.ProtocolVersion
+		token.Platform = handshake.Platform
+		token.LastHandshake = &handshake.At
+		r.agentTokens[id] = token
+	}
+	return nil
+}
+
+// RevokeAgentToken revokes an agent token, returning gorm.ErrRecordNotFound if
+// there is no unrevoked token with the ID
+func (r *MemoryRepository) RevokeAgentToken(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.agentTokens[id]
+	if !ok || token.RevokedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+	now := r.now()
+	token.RevokedAt = &now
+	r.agentTokens[id] = token
+	return nil
+}
+
+// stored drops what a TestExecution doesn't persist
+func stored(execution models.TestExecution) models.TestExecution {
+	execution.Progress = nil
+	return execution
+}
+
+// found returns a copy of the record with the key, or gorm.ErrRecordNotFound
+func found[T any](records map[string]T, key string) (*T, error) {
+	record, ok := records[key]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &record, nil
+}
+
+// first returns a copy of a record matching the condition, or gorm.ErrRecordNotFound
+func first[T any](records map[string]T, match func(T) bool) (*T, error) {
+	for _, record := range records {
+		if match(record) {
+			return &record, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// where returns copies of the records matching the condition, in no particular order
+func where[T any](records map[string]T, match func(T) bool) []T {
+	matched := make([]T, 0, len(records))
+	for _, record := range records {
+		if match(record) {
+			matched = append(matched, record)
+		}
+	}
+	return matched
+}
+
+// sortBy sorts records by the sort fields, or by the default field when none are
+// given. value returns a record's string, time, duration or status for a field.
+func sortBy[T any](records []T, fields []SortField, fallback SortField, value func(T, string) interface{}) {
+	if len(fields) == 0 {
+		fields = []SortField{fallback}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, field := range fields {
+			c := compare(value(records[i], field.Field), value(records[j], field.Field))
+			if c == 0 {
+				continue
+			}
+			if field.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// compare orders two values of the same type; nil times sort first, as NULL
+// does in ascending SQL order
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Duration:
+		b := b.(time.Duration)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+		return 0
+	case time.Time:
+		b := b.(time.Time)
+		if a.Before(b) {
+			return -1
+		} else if a.After(b) {
+			return 1
+		}
+		return 0
+	case *time.Time:
+		b := b.(*time.Time)
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		case b == nil:
+			return 1
+		}
+		return compare(*a, *b)
+	}
+	return 0
+}
+
+func testSortValue(config models.TestConfiguration, field string) interface{} {
+	switch field {
+	case "name":
+		return config.Name
+	case "plugin":
+		return config.Plugin
+	case "updated":
+		return config.Updated
+	case "duration":
+		return config.Duration
+	}
+	return config.Created
+}
+
+func executionSortValue(execution models.TestExecution, field string) interface{} {
+	switch field {
+	case "status":
+		return string(execution.Status)
+	case "start_time":
+		return execution.StartTime
+	case "end_time":
+		return execution.EndTime
+	case "duration":
+		return execution.Duration
+	}
+	return execution.Created
+}
+
+func alertSortValue(alert models.Alert, field string) interface{} {
+	switch field {
+	case "type":
+		return alert.Type
+	case "severity":
+		return alert.Severity
+	}
+	return alert.Timestamp
+}
+
+// paginate applies limit and offset
+func paginate[T any](records []T, limit, offset int) []T {
+	if offset > 0 {
+		if offset >= len(records) {
+			return records[:0]
+		}
+		records = records[offset:]
+	}
+	if limit > 0 && limit < len(records) {
+		records = records[:limit]
+	}
+	return records
+}
+
+// equals matches like QueryBuilder.Equals: an empty value matches anything
+func equals(field, value string) bool {
+	return value == "" || field == value
+}
+
+func between(t time.Time, after, before *time.Time) bool {
+	return (after == nil || !t.Before(*after)) && (before == nil || !t.After(*before))
+}
+
+// betweenPtr is between for nullable columns, which never match a bound
+func betweenPtr(t *time.Time, after, before *time.Time) bool {
+	if t == nil {
+		return after == nil && before == nil
+	}
+	return between(*t, after, before)
+}
+
+// labelsMatch matches like QueryBuilder.Labels
+func labelsMatch(labels models.Labels, matches []LabelMatch) bool {
+	for _, match := range matches {
+		value, ok := labels[match.Key]
+		switch match.Op {
+		case "=":
+			if !ok || value != match.Value {
+				return false
+			}
+		case "!=":
+			if ok && value == match.Value {
+				return false
+			}
+		default:
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsStatus(statuses []models.ExecutionStatus, status models.ExecutionStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func containsReviewStatus(statuses []models.ReviewStatus, status models.ReviewStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
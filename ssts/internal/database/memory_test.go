@@ -0,0 +1,202 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestMemoryRepositoryTests(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	project, err := repo.GetProjectByName(models.DefaultProjectName)
+	if err != nil {
+		t.Fatalf("default project: %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, test := range []models.TestConfiguration{
+		{Name: "CPU burn", Plugin: "cpu_stress", ProjectID: project.ID, Labels: models.Labels{"env": "lab"}},
+		{Name: "Disk soak", Plugin: "io_stress", ProjectID: project.ID, Labels: models.Labels{"env": "prod"}},
+		{Name: "cpu quick", Plugin: "cpu_stress", ProjectID: "other"},
+	} {
+		test.Created = base.Add(time.Duration(i) * time.Hour)
+		if err := repo.CreateTestConfiguration(&test); err != nil {
+			t.Fatal(err)
+		}
+		if test.ID == "" {
+			t.Fatal("created test has no ID")
+		}
+	}
+
+	names := func(tests []models.TestConfiguration) []string {
+		var names []string
+		for _, test := range tests {
+			names = append(names, test.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name   string
+		filter TestFilter
+		want   []string
+	}{
+		{"newest first by default", TestFilter{}, []string{"cpu quick", "Disk soak", "CPU burn"}},
+		{"project", TestFilter{ProjectID: project.ID}, []string{"Disk soak", "CPU burn"}},
+		{"name contains, any case", TestFilter{NameContains: "CPU"}, []string{"cpu quick", "CPU burn"}},
+		{"label equals", TestFilter{Labels: []LabelMatch{{Key: "env", Op: "=", Value: "lab"}}}, []string{"CPU burn"}},
+		{"label not equals", TestFilter{Labels: []LabelMatch{{Key: "env", Op: "!=", Value: "lab"}}}, []string{"cpu quick", "Disk soak"}},
+		{"label exists", TestFilter{Labels: []LabelMatch{{Key: "env", Op: "exists"}}}, []string{"Disk soak", "CPU burn"}},
+		{"sorted by name", TestFilter{Sort: []SortField{{Field: "name"}}}, []string{"CPU burn", "Disk soak", "cpu quick"}},
+		{"paginated", TestFilter{Limit: 1, Offset: 1}, []string{"Disk soak"}},
+		{"offset past the end", TestFilter{Offset: 5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.ListTestConfigurationsFiltered(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g := names(got); !equalStrings(g, tt.want) {
+				t.Errorf("got %v, want %v", g, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRepositoryErrors(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	if _, err := repo.GetTestConfiguration("missing"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetTestConfiguration of a missing test: error = %v, want gorm.ErrRecordNotFound", err)
+	}
+	if err := repo.CreateProject(&models.Project{Name: models.DefaultProjectName}); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Errorf("CreateProject with a taken name: error = %v, want gorm.ErrDuplicatedKey", err)
+	}
+	if err := repo.RevokeAgentToken("missing"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("RevokeAgentToken of a missing token: error = %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func TestMemoryRepositoryCopies(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	test := models.TestConfiguration{Name: "original", Plugin: "cpu_stress"}
+	if err := repo.CreateTestConfiguration(&test); err != nil {
+		t.Fatal(err)
+	}
+	test.Name = "changed"
+
+	stored, err := repo.GetTestConfiguration(test.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored.Plugin = "io_stress"
+
+	again, err := repo.GetTestConfiguration(test.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Name != "original" || again.Plugin != "cpu_stress" {
+		t.Errorf("stored test changed without an update: %+v", again)
+	}
+}
+
+func TestMemoryRepositoryExecutions(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	test := models.TestConfiguration{Name: "soak", Plugin: "io_stress", CreatedBy: "alice"}
+	if err := repo.CreateTestConfiguration(&test); err != nil {
+		t.Fatal(err)
+	}
+
+	finished := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, execution := range []models.TestExecution{
+		{TestID: test.ID, Status: models.StatusCompleted, EndTime: &finished},
+		{TestID: test.ID, Status: models.StatusRunning, Soak: true},
+		{TestID: "other", Status: models.StatusFailed},
+	} {
+		if err := repo.CreateTestExecution(&execution); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byPlugin, err := repo.ListTestExecutionsFiltered(ExecutionFilter{Plugin: "io_stress", CreatedBy: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byPlugin) != 2 {
+		t.Errorf("executions of io_stress tests by alice: got %d, want 2", len(byPlugin))
+	}
+	for name, want := range map[string]int{"SOAK": 2, "oa": 2, "burn": 0} {
+		byName, err := repo.ListTestExecutionsFiltered(ExecutionFilter{TestNameContains: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(byName) != want {
+			t.Errorf("executions of tests named like %q: got %d, want %d", name, len(byName), want)
+		}
+	}
+
+	since := finished.Add(-time.Hour)
+	completed, err := repo.ListCompletedExecutions(test.ID, &since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 1 {
+		t.Errorf("completed executions since %s: got %d, want 1", since, len(completed))
+	}
+
+	unfinished, err := repo.ListUnfinishedSoakExecutions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unfinished) != 1 || unfinished[0].Status != models.StatusRunning {
+		t.Errorf("unfinished soak executions: %+v", unfinished)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryRepositoryViolations(t *testing.T) {
+	repo := NewMemoryRepository()
+	start := time.Now()
+	for _, violation := range []models.Violation{
+		{ExecutionID: "e1", Type: "memory_usage", Severity: "critical", Timestamp: start.Add(time.Second)},
+		{ExecutionID: "e1", Type: "cpu_usage", Severity: "warning", Timestamp: start},
+		{ExecutionID: "e2", Type: "cpu_usage", Severity: "warning", Timestamp: start},
+	} {
+		if err := repo.CreateViolation(&violation); err != nil || violation.ID == "" {
+			t.Fatalf("CreateViolation: %v, id %q", err, violation.ID)
+		}
+	}
+
+	violations, _ := repo.ListViolations("e1")
+	if len(violations) != 2 || violations[0].Type != "cpu_usage" || violations[1].Type != "memory_usage" {
+		t.Errorf("violations of e1 = %+v, want both in time order", violations)
+	}
+
+	// Deleting an execution deletes its violations
+	repo.DeleteTestExecution("e1")
+	if violations, _ := repo.ListViolations("e1"); len(violations) != 0 {
+		t.Errorf("violations of a deleted execution = %+v", violations)
+	}
+	if violations, _ := repo.ListViolations("e2"); len(violations) != 1 {
+		t.Errorf("violations of e2 = %+v, want one", violations)
+	}
+}
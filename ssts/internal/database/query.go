@@ -0,0 +1,298 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// SortField describes a single ORDER BY term
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a sort expression such as "-created,name" into sort fields.
+// A leading "-" sorts descending. Only fields present in allowed are accepted.
+func ParseSort(expr string, allowed map[string]string) ([]SortField, error) {
+	var fields []SortField
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			field = SortField{Field: strings.TrimPrefix(part, "-"), Desc: true}
+		} else if strings.HasPrefix(part, "+") {
+			field.Field = strings.TrimPrefix(part, "+")
+		}
+
+		if _, ok := allowed[field.Field]; !ok {
+			return nil, fmt.Errorf("cannot sort by %q", field.Field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// LabelMatch is one term of a label selector
+type LabelMatch struct {
+	Key   string
+	Value string
+	Op    string // "=", "!=" or "exists"
+}
+
+// ParseLabelSelector parses label selectors such as "rack=r12,gen!=g4,owner" into
+// their terms; every expression must match. A bare key matches resources that have
+// the label at all.
+func ParseLabelSelector(exprs []string) ([]LabelMatch, error) {
+	var matches []LabelMatch
+	for _, expr := range exprs {
+		for _, term := range strings.Split(expr, ",") {
+			term = strings.TrimSpace(term)
+			if term == "" {
+				continue
+			}
+
+			match := LabelMatch{Key: term, Op: "exists"}
+			if key, value, ok := strings.Cut(term, "!="); ok {
+				match = LabelMatch{Key: key, Value: value, Op: "!="}
+			} else if key, value, ok := strings.Cut(term, "="); ok {
+				match = LabelMatch{Key: key, Value: value, Op: "="}
+			}
+			match.Key = strings.TrimSpace(match.Key)
+			match.Value = strings.TrimSpace(match.Value)
+
+			if !models.ValidLabelKey(match.Key) {
+				return nil, fmt.Errorf("invalid label key %q", match.Key)
+			}
+			matches = append(matches, match)
+		}
+	}
+	return matches, nil
+}
+
+// TestSortFields maps the sortable test configuration fields to their columns
+var TestSortFields = map[string]string{
+	"name":     "name",
+	"plugin":   "plugin",
+	"created":  "created",
+	"updated":  "updated",
+	"duration": "duration",
+}
+
+// ExecutionSortFields maps the sortable test execution fields to their columns
+var ExecutionSortFields = map[string]string{
+	"status":     "status",
+	"start_time": "start_time",
+	"end_time":   "end_time",
+	"duration":   "duration",
+	"created":    "created",
+}
+
+// AlertSortFields maps the sortable alert fields to their columns
+var AlertSortFields = map[string]string{
+	"timestamp": "timestamp",
+	"type":      "type",
+	"severity":  "severity",
+}
+
+// AlertFilter describes filtering, sorting and pagination for alerts
+type AlertFilter struct {
+	ProjectID    string // only alerts of this project and host-wide alerts
+	Type         string
+	Severities   []string
+	Acknowledged *bool
+	IncludeMuted bool
+	Since        *time.Time
+	Until        *time.Time
+	Sort         []SortField
+	Limit        int
+	Offset       int
+}
+
+// TestFilter describes filtering, sorting and pagination for test configurations
+type TestFilter struct {
+	ProjectID     string
+	Plugin        string
+	CreatedBy     string
+	NameContains  string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Labels        []LabelMatch
+	Sort          []SortField
+	Limit         int
+	Offset        int
+}
+
+// ExecutionFilter describes filtering, sorting and pagination for test executions
+type ExecutionFilter struct {
+	ProjectID        string
+	TestID           string
+	TestNameContains string // case-insensitive substring of the test's name
+	Plugin           string
+	CreatedBy        string // creator of the execution's test
+	StartedBy        string
+	Statuses         []models.ExecutionStatus
+	ReviewStatuses   []models.ReviewStatus
+	StartedAfter     *time.Time
+	StartedBefore    *time.Time
+	Labels           []LabelMatch
+	CICommit         string // case-insensitive prefix of the commit, so abbreviated SHAs match
+	CIBuildNumber    string
+	Sort             []SortField
+	Limit            int
+	Offset           int
+}
+
+// QueryBuilder composes a GORM query from whitelisted columns and bound parameters,
+// so user-supplied filter values never end up in the SQL text
+type QueryBuilder struct {
+	query   *gorm.DB
+	columns map[string]string
+}
+
+// NewQueryBuilder creates a query builder over the given base query. Only the
+// columns in the columns map may be referenced by sort terms.
+func NewQueryBuilder(query *gorm.DB, columns map[string]string) *QueryBuilder {
+	return &QueryBuilder{query: query, columns: columns}
+}
+
+// Equals adds "column = value" when value is non-empty
+func (b *QueryBuilder) Equals(column, value string) *QueryBuilder {
+	if value != "" {
+		b.query = b.query.Where(column+" = ?", value)
+	}
+	return b
+}
+
+// EqualsOrEmpty adds "column = value" when value is non-empty, also matching rows
+// where the column is empty or NULL, i.e. rows that aren't scoped to any value
+func (b *QueryBuilder) EqualsOrEmpty(column, value string) *QueryBuilder {
+	if value != "" {
+		b.query = b.query.Where("("+column+" = ? OR "+column+" = '' OR "+column+" IS NULL)", value)
+	}
+	return b
+}
+
+// In adds "column IN (values)" when values is non-empty
+func (b *QueryBuilder) In(column string, values []string) *QueryBuilder {
+	if len(values) > 0 {
+		b.query = b.query.Where(column+" IN ?", values)
+	}
+	return b
+}
+
+// Contains adds a case-insensitive substring match with LIKE wildcards escaped
+func (b *QueryBuilder) Contains(column, value string) *QueryBuilder {
+	if value != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(value))
+		b.query = b.query.Where("LOWER("+column+") LIKE ? ESCAPE '\\'", "%"+escaped+"%")
+	}
+	return b
+}
+
+// HasPrefix adds a case-insensitive prefix match with LIKE wildcards escaped
+func (b *QueryBuilder) HasPrefix(column, value string) *QueryBuilder {
+	if value != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(value))
+		b.query = b.query.Where("LOWER("+column+") LIKE ? ESCAPE '\\'", escaped+"%")
+	}
+	return b
+}
+
+// Between adds lower and upper time bounds on a column when they are set
+func (b *QueryBuilder) Between(column string, after, before *time.Time) *QueryBuilder {
+	if after != nil {
+		b.query = b.query.Where(column+" >= ?", *after)
+	}
+	if before != nil {
+		b.query = b.query.Where(column+" <= ?", *before)
+	}
+	return b
+}
+
+// Bool adds "column = value" when value is set
+func (b *QueryBuilder) Bool(column string, value *bool) *QueryBuilder {
+	if value != nil {
+		b.query = b.query.Where(column+" = ?", *value)
+	}
+	return b
+}
+
+// Labels adds a condition per label selector term on a JSON labels column. Keys are
+// validated by ParseLabelSelector, so they can be spliced into JSON paths safely.
+func (b *QueryBuilder) Labels(column string, matches []LabelMatch) *QueryBuilder {
+	postgres := b.query.Dialector.Name() == "postgres"
+	for _, match := range matches {
+		// The label's value, or NULL when it isn't set
+		value := "json_extract(" + column + `, '$."` + match.Key + `"')`
+		if postgres {
+			value = column + " ->> '" + match.Key + "'"
+		}
+
+		switch match.Op {
+		case "=":
+			if postgres {
+				containment, _ := json.Marshal(map[string]string{match.Key: match.Value})
+				b.query = b.query.Where(column+" @> ?", string(containment))
+			} else {
+				b.query = b.query.Where(value+" = ?", match.Value)
+			}
+		case "!=":
+			b.query = b.query.Where("("+value+" IS NULL OR "+value+" <> ?)", match.Value)
+		default:
+			b.query = b.query.Where(value + " IS NOT NULL")
+		}
+	}
+	return b
+}
+
+// Subquery adds "column IN (subquery)"
+func (b *QueryBuilder) Subquery(column string, subquery *gorm.DB) *QueryBuilder {
+	b.query = b.query.Where(column+" IN (?)", subquery)
+	return b
+}
+
+// OrderBy applies the sort fields, falling back to defaultOrder when none are given
+func (b *QueryBuilder) OrderBy(fields []SortField, defaultOrder string) *QueryBuilder {
+	if len(fields) == 0 {
+		b.query = b.query.Order(defaultOrder)
+		return b
+	}
+
+	for _, field := range fields {
+		column, ok := b.columns[field.Field]
+		if !ok {
+			continue
+		}
+		if field.Desc {
+			column += " DESC"
+		}
+		b.query = b.query.Order(column)
+	}
+	return b
+}
+
+// Paginate applies limit and offset
+func (b *QueryBuilder) Paginate(limit, offset int) *QueryBuilder {
+	if limit > 0 {
+		b.query = b.query.Limit(limit)
+	}
+	if offset > 0 {
+		b.query = b.query.Offset(offset)
+	}
+	return b
+}
+
+// Query returns the composed query
+func (b *QueryBuilder) Query() *gorm.DB {
+	return b.query
+}
@@ -0,0 +1,405 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// openSQLite opens a SQLite database in a temporary directory with tables for
+// tests and executions. SQLite has no gen_random_uuid(), so the column default
+// is dropped and rows are created with explicit IDs.
+func openSQLite(t *testing.T) *Database {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ssts.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, model := range []interface{}{&models.TestConfiguration{}, &models.TestExecution{}} {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DefaultValue == "gen_random_uuid()" {
+				field.DefaultValue, field.HasDefaultValue = "", false
+			}
+		}
+		if err := db.AutoMigrate(model); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return &Database{DB: db}
+}
+
+// queryNames runs the composed query on test configurations and returns their sorted names
+func queryNames(t *testing.T, builder *QueryBuilder) []string {
+	t.Helper()
+	var tests []models.TestConfiguration
+	if err := builder.Query().Find(&tests).Error; err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, test := range tests {
+		names = append(names, test.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestQueryBuilder(t *testing.T) {
+	db := openSQLite(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"100% CPU", "cpu_burn", "cpuXburn", `disk\soak`, "memory"} {
+		test := models.TestConfiguration{ID: string(rune('a' + i)), Name: name, Plugin: "cpu-stress", Created: base.Add(time.Duration(i) * time.Hour)}
+		if err := db.Create(&test).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	newBuilder := func() *QueryBuilder {
+		return NewQueryBuilder(db.Model(&models.TestConfiguration{}), TestSortFields)
+	}
+
+	after, before := base.Add(time.Hour), base.Add(3*time.Hour)
+	tests := []struct {
+		name    string
+		builder *QueryBuilder
+		want    []string
+	}{
+		{"contains any case", newBuilder().Contains("name", "cpu"), []string{"100% CPU", "cpuXburn", "cpu_burn"}},
+		{"contains escapes %", newBuilder().Contains("name", "%"), []string{"100% CPU"}},
+		{"contains escapes _", newBuilder().Contains("name", "u_b"), []string{"cpu_burn"}},
+		{"contains escapes backslash", newBuilder().Contains("name", `k\s`), []string{`disk\soak`}},
+		{"contains empty", newBuilder().Contains("name", ""), []string{"100% CPU", "cpuXburn", "cpu_burn", "disk\\soak", "memory"}},
+		{"in", newBuilder().In("name", []string{"memory", "cpu_burn"}), []string{"cpu_burn", "memory"}},
+		{"in nothing", newBuilder().In("name", nil), []string{"100% CPU", "cpuXburn", "cpu_burn", "disk\\soak", "memory"}},
+		{"between", newBuilder().Between("created", &after, &before), []string{"cpuXburn", "cpu_burn", "disk\\soak"}},
+		{"after only", newBuilder().Between("created", &before, nil), []string{"disk\\soak", "memory"}},
+		{"between nothing", newBuilder().Between("created", nil, nil), []string{"100% CPU", "cpuXburn", "cpu_burn", "disk\\soak", "memory"}},
+		{"equals", newBuilder().Equals("name", "memory"), []string{"memory"}},
+		{"equals or empty", newBuilder().EqualsOrEmpty("plugin", "io-stress"), []string{}},
+	}
+	for _, tt := range tests {
+		if got := queryNames(t, tt.builder); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueryBuilderOrderAndPaginate(t *testing.T) {
+	db := openSQLite(t)
+	for i, name := range []string{"c", "a", "b"} {
+		if err := db.Create(&models.TestConfiguration{ID: name, Name: name, Plugin: "cpu-stress", Created: time.Unix(int64(i), 0)}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	ordered := func(builder *QueryBuilder) []string {
+		var tests []models.TestConfiguration
+		if err := builder.Query().Find(&tests).Error; err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, test := range tests {
+			names = append(names, test.Name)
+		}
+		return names
+	}
+	newBuilder := func() *QueryBuilder {
+		return NewQueryBuilder(db.Model(&models.TestConfiguration{}), TestSortFields)
+	}
+
+	// Sort fields outside the whitelist are rejected when parsed, and never reach the SQL
+	for _, expr := range []string{"password", "name;DROP TABLE test_configurations", "-created,id"} {
+		if fields, err := ParseSort(expr, TestSortFields); err == nil {
+			t.Errorf("ParseSort(%q) = %+v, want an error", expr, fields)
+		}
+	}
+	injected := []SortField{{Field: "name; DROP TABLE test_configurations"}, {Field: "name", Desc: true}}
+	if got := ordered(newBuilder().OrderBy(injected, "created")); !reflect.DeepEqual(got, []string{"c", "b", "a"}) {
+		t.Errorf("order with an unknown field = %q, want it ignored", got)
+	}
+	if got := ordered(newBuilder().OrderBy(nil, "created DESC")); !reflect.DeepEqual(got, []string{"b", "a", "c"}) {
+		t.Errorf("default order = %q", got)
+	}
+
+	tests := []struct {
+		limit, offset int
+		want          []string
+	}{
+		{0, 0, []string{"a", "b", "c"}},
+		{2, 0, []string{"a", "b"}},
+		{2, 2, []string{"c"}},
+		{1, 5, []string{}},
+		{-1, -1, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		if got := ordered(newBuilder().OrderBy([]SortField{{Field: "name"}}, "").Paginate(tt.limit, tt.offset)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Paginate(%d, %d) = %q, want %q", tt.limit, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestSQLRepositoryExecutionFilter(t *testing.T) {
+	repo := NewSQLRepository(openSQLite(t))
+	for _, test := range []models.TestConfiguration{
+		{ID: "t1", Name: "CPU burn", Plugin: "cpu-stress"},
+		{ID: "t2", Name: "Disk 100%", Plugin: "io-stress"},
+	} {
+		if err := repo.CreateTestConfiguration(&test); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, execution := range []models.TestExecution{{ID: "e1", TestID: "t1"}, {ID: "e2", TestID: "t2"}, {ID: "e3", TestID: "t1"}} {
+		if err := repo.CreateTestExecution(&execution); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter ExecutionFilter
+		want   []string
+	}{
+		{ExecutionFilter{TestNameContains: "cpu"}, []string{"e1", "e3"}},
+		{ExecutionFilter{TestNameContains: "%"}, []string{"e2"}},
+		{ExecutionFilter{TestNameContains: "cpu", Plugin: "io-stress"}, []string{}},
+		{ExecutionFilter{}, []string{"e1", "e2", "e3"}},
+	}
+	for _, tt := range tests {
+		executions, err := repo.ListTestExecutionsFiltered(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, execution := range executions {
+			ids = append(ids, execution.ID)
+		}
+		sort.Strings(ids)
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("%+v: got %q, want %q", tt.filter, ids, tt.want)
+		}
+	}
+}
+
+func TestRepositoryExecutionsByTest(t *testing.T) {
+	now := time.Now()
+	repos := map[string]Repository{"sql": NewSQLRepository(openSQLite(t)), "memory": NewMemoryRepository()}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			for _, execution := range []models.TestExecution{
+				{ID: "e1", TestID: "t1", Status: models.StatusCompleted, Created: now.Add(-3 * time.Hour)},
+				{ID: "e2", TestID: "t1", Status: models.StatusFailed, Created: now.Add(-2 * time.Hour)},
+				{ID: "e3", TestID: "t1", Status: models.StatusRunning, Created: now.Add(-time.Hour)},
+				{ID: "e4", TestID: "t2", Status: models.StatusCompleted, Created: now},
+			} {
+				if err := repo.CreateTestExecution(&execution); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			latestID := func(execution *models.TestExecution, err error) string {
+				if errors.Is(err, ErrNotFound) {
+					return "not found"
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				return execution.ID
+			}
+			tests := []struct {
+				name string
+				got  string
+				want string
+			}{
+				{"latest", latestID(repo.GetLatestExecutionForTest("t1")), "e3"},
+				{"latest completed", latestID(repo.GetLatestExecutionForTest("t1", models.StatusCompleted)), "e1"},
+				{"latest finished", latestID(repo.GetLatestExecutionForTest("t1", models.StatusCompleted, models.StatusFailed)), "e2"},
+				{"running", latestID(repo.GetRunningExecutionForTest("t1")), "e3"},
+				{"none running", latestID(repo.GetRunningExecutionForTest("t2")), "not found"},
+				{"never run", latestID(repo.GetLatestExecutionForTest("t3")), "not found"},
+			}
+			for _, tt := range tests {
+				if tt.got != tt.want {
+					t.Errorf("%s: got %s, want %s", tt.name, tt.got, tt.want)
+				}
+			}
+
+			executions, err := repo.ListExecutionsByTest("t1", 2, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, execution := range executions {
+				ids = append(ids, execution.ID)
+			}
+			if want := []string{"e2", "e1"}; !reflect.DeepEqual(ids, want) {
+				t.Errorf("ListExecutionsByTest = %q, want %q", ids, want)
+			}
+		})
+	}
+}
+
+func TestRepositoryExecutionReview(t *testing.T) {
+	repos := map[string]Repository{"sql": NewSQLRepository(openSQLite(t)), "memory": NewMemoryRepository()}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			execution := models.TestExecution{ID: "e1", TestID: "t1", Status: models.StatusCompleted, ReviewStatus: models.ReviewPending}
+			if err := repo.CreateTestExecution(&execution); err != nil {
+				t.Fatal(err)
+			}
+
+			review := models.ExecutionReview{Status: models.ReviewInReview, Reviewer: "bob", At: time.Now()}
+			if err := repo.UpdateExecutionReview("e1", models.ReviewPending, review); err != nil {
+				t.Fatalf("UpdateExecutionReview: %v", err)
+			}
+			// A second reviewer working from the same pending review lost the race
+			review.Reviewer = "carol"
+			if err := repo.UpdateExecutionReview("e1", models.ReviewPending, review); !errors.Is(err, ErrNotFound) {
+				t.Errorf("stale UpdateExecutionReview = %v, want ErrNotFound", err)
+			}
+
+			stored, err := repo.GetTestExecution("e1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.ReviewStatus != models.ReviewInReview || stored.Reviewer != "bob" || stored.Reviewed == nil {
+				t.Errorf("review = %s by %q at %v, want in review by bob", stored.ReviewStatus, stored.Reviewer, stored.Reviewed)
+			}
+
+			executions, err := repo.ListTestExecutionsFiltered(ExecutionFilter{ReviewStatuses: []models.ReviewStatus{models.ReviewInReview}})
+			if err != nil || len(executions) != 1 {
+				t.Errorf("executions in review = %d, %v, want 1", len(executions), err)
+			}
+		})
+	}
+}
+
+func TestRepositoryExecutionsByCI(t *testing.T) {
+	repos := map[string]Repository{"sql": NewSQLRepository(openSQLite(t)), "memory": NewMemoryRepository()}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			for _, execution := range []models.TestExecution{
+				{ID: "e1", TestID: "t1", CI: models.CIMetadata{Commit: "9f2c41d0ab", BuildNumber: "412", PipelineURL: "https://ci.example.com/jobs/412"}},
+				{ID: "e2", TestID: "t1", CI: models.CIMetadata{Commit: "9F2C7700EE", BuildNumber: "413"}},
+				{ID: "e3", TestID: "t1"},
+			} {
+				if err := repo.CreateTestExecution(&execution); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tests := []struct {
+				filter ExecutionFilter
+				want   []string
+			}{
+				{ExecutionFilter{CICommit: "9f2c"}, []string{"e1", "e2"}},
+				{ExecutionFilter{CICommit: "9f2c41"}, []string{"e1"}},
+				{ExecutionFilter{CICommit: "2c41"}, []string{}},
+				{ExecutionFilter{CICommit: "9f2c", CIBuildNumber: "413"}, []string{"e2"}},
+			}
+			for _, tt := range tests {
+				executions, err := repo.ListTestExecutionsFiltered(tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids := []string{}
+				for _, execution := range executions {
+					ids = append(ids, execution.ID)
+				}
+				sort.Strings(ids)
+				if !reflect.DeepEqual(ids, tt.want) {
+					t.Errorf("%+v: got %q, want %q", tt.filter, ids, tt.want)
+				}
+			}
+
+			stored, err := repo.GetTestExecution("e1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.CI.PipelineURL != "https://ci.example.com/jobs/412" {
+				t.Errorf("stored CI metadata = %+v", stored.CI)
+			}
+		})
+	}
+}
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		exprs   []string
+		want    []LabelMatch
+		wantErr bool
+	}{
+		{nil, nil, false},
+		{[]string{"rack=r12"}, []LabelMatch{{Key: "rack", Value: "r12", Op: "="}}, false},
+		{[]string{" rack = r12 , gen!=g4,owner", "env=lab"}, []LabelMatch{
+			{Key: "rack", Value: "r12", Op: "="},
+			{Key: "gen", Value: "g4", Op: "!="},
+			{Key: "owner", Op: "exists"},
+			{Key: "env", Value: "lab", Op: "="},
+		}, false},
+		{[]string{"rack="}, []LabelMatch{{Key: "rack", Op: "="}}, false},
+		{[]string{",,"}, nil, false},
+		{[]string{"=r12"}, nil, true},
+		{[]string{`rack"]=x`}, nil, true},
+		{[]string{"rack id=x"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLabelSelector(tt.exprs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLabelSelector(%q) error = %v, want error %v", tt.exprs, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseLabelSelector(%q) = %+v, want %+v", tt.exprs, got, tt.want)
+		}
+	}
+}
+
+func TestQueryBuilderLabels(t *testing.T) {
+	db := openSQLite(t)
+	for _, test := range []models.TestConfiguration{
+		{ID: "a", Name: "a", Plugin: "cpu-stress", Labels: models.Labels{"rack": "r12", "gen": "g4"}},
+		{ID: "b", Name: "b", Plugin: "cpu-stress", Labels: models.Labels{"rack": "r12", "gen": "g5"}},
+		{ID: "c", Name: "c", Plugin: "cpu-stress", Labels: models.Labels{"rack": "r7"}},
+		{ID: "d", Name: "d", Plugin: "cpu-stress"},
+	} {
+		if err := db.Create(&test).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{"rack=r12", []string{"a", "b"}},
+		{"rack=r12,gen!=g4", []string{"b"}},
+		{"gen!=g4", []string{"b", "c", "d"}},
+		{"gen", []string{"a", "b"}},
+		{"rack=r99", []string{}},
+		{"", []string{"a", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		matches, err := ParseLabelSelector([]string{tt.selector})
+		if err != nil {
+			t.Fatal(err)
+		}
+		builder := NewQueryBuilder(db.Model(&models.TestConfiguration{}), TestSortFields).Labels("labels", matches)
+		if got := queryNames(t, builder); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.selector, got, tt.want)
+		}
+	}
+}
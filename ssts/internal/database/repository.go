@@ -0,0 +1,119 @@
+package database
+
+import (
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Repository is the store for everything the server keeps outside the metric
+// store. SQLRepository implements it on the SQL database and MemoryRepository in
+// memory, for tests. Lookups of a single record return ErrNotFound when there
+// is none, whichever implementation is used.
+type Repository interface {
+	// Users
+	CreateUser(user *models.User) error
+	GetUser(id string) (*models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+	UpdateUser(user *models.User) error
+	ListUsers() ([]models.User, error)
+
+	// Test configurations
+	CreateTestConfiguration(config *models.TestConfiguration) error
+	GetTestConfiguration(id string) (*models.TestConfiguration, error)
+	ListTestConfigurations(limit, offset int) ([]models.TestConfiguration, error)
+	ListTestConfigurationsFiltered(filter TestFilter) ([]models.TestConfiguration, error)
+	UpdateTestConfiguration(config *models.TestConfiguration) error
+	DeleteTestConfiguration(id string) error
+
+	// Test executions
+	CreateTestExecution(execution *models.TestExecution) error
+	GetTestExecution(id string) (*models.TestExecution, error)
+	ListTestExecutions(limit, offset int) ([]models.TestExecution, error)
+	ListTestExecutionsByStatus(status models.ExecutionStatus, limit, offset int) ([]models.TestExecution, error)
+	ListTestExecutionsFiltered(filter ExecutionFilter) ([]models.TestExecution, error)
+	ListCompletedExecutions(testID string, since *time.Time) ([]models.TestExecution, error)
+	ListExecutionsByTest(testID string, limit, offset int) ([]models.TestExecution, error)
+	GetLatestExecutionForTest(testID string, statuses ...models.ExecutionStatus) (*models.TestExecution, error)
+	GetRunningExecutionForTest(testID string) (*models.TestExecution, error)
+	ListUnfinishedSoakExecutions() ([]models.TestExecution, error)
+	UpdateTestExecution(execution *models.TestExecution) error
+	UpdateExecutionReview(id string, from models.ReviewStatus, review models.ExecutionReview) error
+	DeleteTestExecution(id string) error
+
+	// Plugins
+	CreatePlugin(plugin *models.Plugin) error
+	GetPlugin(name string) (*models.Plugin, error)
+	ListPlugins() ([]models.Plugin, error)
+	ListAllPlugins() ([]models.Plugin, error)
+	UpdatePlugin(plugin *models.Plugin) error
+	DeletePlugin(name string) error
+
+	// Alerts and mute rules
+	CreateAlert(alert *models.Alert) error
+	GetAlert(id string) (*models.Alert, error)
+	ListAlerts(filter AlertFilter) ([]models.Alert, error)
+	AcknowledgeAlert(id, by string) (*models.Alert, error)
+	CreateAlertMuteRule(rule *models.AlertMuteRule) error
+	ListActiveAlertMuteRules() ([]models.AlertMuteRule, error)
+	DeleteAlertMuteRule(id string) error
+
+	// Projects and their members
+	CreateProject(project *models.Project) error
+	GetProject(id string) (*models.Project, error)
+	GetProjectByName(name string) (*models.Project, error)
+	ListProjects() ([]models.Project, error)
+	ListProjectsForUser(userID string) ([]models.Project, error)
+	DeleteProject(id string) error
+	GetProjectMember(projectID, userID string) (*models.ProjectMember, error)
+	ListProjectMembers(projectID string) ([]models.ProjectMember, error)
+	SetProjectMember(projectID, userID string, role models.ProjectRole) (*models.ProjectMember, error)
+	RemoveProjectMember(projectID, userID string) error
+
+	// Saved searches
+	CreateSavedSearch(search *models.SavedSearch) error
+	GetSavedSearch(id, userID string) (*models.SavedSearch, error)
+	ListSavedSearches(userID, resource string) ([]models.SavedSearch, error)
+	DeleteSavedSearch(id, userID string) error
+
+	// Annotations
+	CreateAnnotation(annotation *models.Annotation) error
+	ListAnnotations(executionID string) ([]models.Annotation, error)
+	DeleteAnnotation(executionID, id string) error
+
+	// Safety violations
+	CreateViolation(violation *models.Violation) error
+	ListViolations(executionID string) ([]models.Violation, error)
+
+	// Hardware profiles
+	SaveHardwareProfile(profile *models.HardwareProfile) error
+	GetHardwareProfile(id string) (*models.HardwareProfile, error)
+	ListHardwareProfiles(plugin string) ([]models.HardwareProfile, error)
+	DeleteHardwareProfile(id string) error
+
+	// Device endurance
+	AddDeviceWrites(device string, bytes int64, executionID string) (*models.DeviceEndurance, error)
+	ListDeviceEndurance() ([]models.DeviceEndurance, error)
+
+	// Agent tokens
+	CreateAgentToken(token *models.AgentToken) error
+	GetAgentTokenByHash(hash string) (*models.AgentToken, error)
+	ListAgentTokens() ([]models.AgentToken, error)
+	TouchAgentToken(id string, used time.Time) error
+	RecordAgentHandshake(id string, handshake models.AgentHandshake) error
+	RevokeAgentToken(id string) error
+
+	// HealthCheck reports whether the store can be reached
+	HealthCheck() error
+	// Close releases the store's connections
+	Close() error
+}
+
+// activeStatuses are the statuses of an execution that hasn't finished
+var activeStatuses = []models.ExecutionStatus{models.StatusQueued, models.StatusPending, models.StatusRunning}
+
+var (
+	_ Repository = (*SQLRepository)(nil)
+	_ Repository = (*MemoryRepository)(nil)
+)
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/faults"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// TimeSeriesStore answers time-series queries. Callers build queries with
+// models.TimeSeriesQuery instead of the store's own query language, so the
+// metric backend can be replaced without changing them.
+type TimeSeriesStore interface {
+	QueryTimeSeries(ctx context.Context, query models.TimeSeriesQuery) ([]models.TimeSeries, error)
+}
+
+// fluxSkippedColumns are the columns of a Flux record that aren't series tags
+var fluxSkippedColumns = map[string]bool{
+	"_time": true, "_value": true, "_field": true, "_measurement": true,
+	"_start": true, "_stop": true, "result": true, "table": true,
+}
+
+// QueryTimeSeries runs a query translated to Flux and returns one series per
+// measurement, field and tag set, in the order InfluxDB returns them
+func (idb *InfluxDB) QueryTimeSeries(ctx context.Context, query models.TimeSeriesQuery) ([]models.TimeSeries, error) {
+	if err := faults.Inject(ctx, faults.InfluxDBQuery); err != nil {
+		return nil, err
+	}
+
+	result, err := idb.queryAPI.Query(ctx, fluxQuery(idb.bucket, query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer result.Close()
+
+	var series []models.TimeSeries
+	index := make(map[string]int)
+	for result.Next() {
+		record := result.Record()
+
+		tags := make(map[string]string)
+		for k, v := range record.Values() {
+			if fluxSkippedColumns[k] {
+				continue
+			}
+			if str, ok := v.(string); ok {
+				tags[k] = str
+			}
+		}
+
+		key := seriesKey(record.Measurement(), record.Field(), tags)
+		i, ok := index[key]
+		if !ok {
+			i = len(series)
+			index[key] = i
+			series = append(series, models.TimeSeries{
+				Measurement: record.Measurement(),
+				Field:       record.Field(),
+				Tags:        tags,
+			})
+		}
+		series[i].Points = append(series[i].Points, models.TimeSeriesPoint{Time: record.Time(), Value: record.Value()})
+	}
+
+	if result.Err() != nil {
+		return nil, fmt.Errorf("query result error: %w", result.Err())
+	}
+
+	return series, nil
+}
+
+// fluxQuery translates a query to Flux. Every value from the query is quoted,
+// so none of it is interpreted as Flux.
+func fluxQuery(bucket string, query models.TimeSeriesQuery) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "from(bucket: %s)\n", fluxString(bucket))
+	fmt.Fprintf(&b, "\t|> range(start: %s, stop: %s)\n", query.Start.UTC().Format(time.RFC3339Nano), query.End.UTC().Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "\t|> filter(fn: (r) => r._measurement == %s)\n", fluxString(query.Measurement))
+
+	if len(query.Fields) > 0 {
+		fmt.Fprintf(&b, "\t|> filter(fn: (r) => %s)\n", fluxAnyOf("r._field", query.Fields))
+	}
+
+	keys := make([]string, 0, len(query.Tags))
+	for key := range query.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if len(query.Tags[key]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\t|> filter(fn: (r) => %s)\n", fluxAnyOf("r["+fluxString(key)+"]", query.Tags[key]))
+	}
+
+	if query.Window > 0 {
+		function := query.Function
+		if function == "" {
+			function = models.AggregateMean
+		}
+		fmt.Fprintf(&b, "\t|> aggregateWindow(every: %s, fn: %s, createEmpty: false)\n", fluxDuration(query.Window), function)
+	}
+	if query.Limit > 0 {
+		fmt.Fprintf(&b, "\t|> limit(n: %d)\n", query.Limit)
+	}
+
+	return b.String()
+}
+
+// fluxAnyOf matches a column against any of the values
+func fluxAnyOf(column string, values []string) string {
+	terms := make([]string, len(values))
+	for i, value := range values {
+		terms[i] = column + " == " + fluxString(value)
+	}
+	return strings.Join(terms, " or ")
+}
+
+// fluxString quotes a Flux string literal, escaping interpolation as well as quotes
+func fluxString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "${", `\${`)
+	return `"` + s + `"`
+}
+
+// fluxDuration formats a duration as a Flux duration literal, which has no fractions
+func fluxDuration(d time.Duration) string {
+	switch {
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	case d%time.Millisecond == 0:
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	default:
+		return fmt.Sprintf("%dns", d)
+	}
+}
+
+// seriesKey identifies a series by its measurement, field and tags
+func seriesKey(measurement, field string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(measurement + "\x00" + field)
+	for _, key := range keys {
+		b.WriteString("\x00" + key + "=" + tags[key])
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestFluxQuery(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	query := models.TimeSeriesQuery{
+		Measurement: "system_cpu",
+		Fields:      []string{"usage", "frequency"},
+		Tags:        map[string][]string{"test_id": {"t1", "t2"}, "host": {"node-7"}},
+		Start:       start,
+		End:         start.Add(time.Hour),
+		Window:      90 * time.Second,
+		Function:    models.AggregateMax,
+		Limit:       100,
+	}
+
+	want := `from(bucket: "metrics")
+	|> range(start: 2024-01-02T03:00:00Z, stop: 2024-01-02T04:00:00Z)
+	|> filter(fn: (r) => r._measurement == "system_cpu")
+	|> filter(fn: (r) => r._field == "usage" or r._field == "frequency")
+	|> filter(fn: (r) => r["host"] == "node-7")
+	|> filter(fn: (r) => r["test_id"] == "t1" or r["test_id"] == "t2")
+	|> aggregateWindow(every: 90s, fn: max, createEmpty: false)
+	|> limit(n: 100)
+`
+	if got := fluxQuery("metrics", query); got != want {
+		t.Errorf("fluxQuery =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFluxQueryQuotesValues(t *testing.T) {
+	query := models.TimeSeriesQuery{
+		Measurement: `cpu") |> drop(columns: ["x"]) //`,
+		Tags:        map[string][]string{"host": {`${secrets.get(key: "k")}`}},
+		Start:       time.Unix(0, 0),
+		End:         time.Unix(60, 0),
+		Window:      1500 * time.Millisecond,
+	}
+
+	flux := fluxQuery("metrics", query)
+	for _, want := range []string{
+		`r._measurement == "cpu\") |> drop(columns: [\"x\"]) //"`,
+		`r["host"] == "\${secrets.get(key: \"k\")}"`,
+		`aggregateWindow(every: 1500ms, fn: mean, createEmpty: false)`,
+	} {
+		if !strings.Contains(flux, want) {
+			t.Errorf("query doesn't contain %s:\n%s", want, flux)
+		}
+	}
+}
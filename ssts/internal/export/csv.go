@@ -0,0 +1,76 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+var csvHeader = []string{"timestamp", "test_id", "source", "type", "field", "value", "value_text", "tags"}
+
+// CSVWriter writes metric points as CSV
+type CSVWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+// NewCSVWriter creates a CSV metric writer
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes one row per field of the point
+func (c *CSVWriter) Write(point models.MetricPoint) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	for _, r := range pointRows(point) {
+		value := ""
+		if !math.IsNaN(r.value) {
+			value = strconv.FormatFloat(r.value, 'g', -1, 64)
+		}
+
+		record := []string{
+			r.timestamp.UTC().Format(time.RFC3339Nano),
+			r.testID,
+			r.source,
+			r.pointType,
+			r.field,
+			value,
+			r.valueText,
+			r.tags,
+		}
+		if err := c.w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return c.w.Error()
+}
+
+// Flush flushes buffered rows to the underlying writer
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Close writes the header if no rows were written and flushes
+func (c *CSVWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.Flush()
+}
+
+func (c *CSVWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write(csvHeader)
+}
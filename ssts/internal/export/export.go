@@ -0,0 +1,129 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Built-in export formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatHTML = "html"
+	FormatPDF  = "pdf"
+)
+
+// MetricWriter writes metric points as rows in "long" format: one row per field,
+// with columns timestamp, test_id, source, type, field, value, value_text, tags
+type MetricWriter interface {
+	Write(point models.MetricPoint) error
+	// Close flushes buffered rows and writes any trailer; it does not close the underlying writer
+	Close() error
+}
+
+// NewMetricWriter creates a writer for the given format
+func NewMetricWriter(format string, w io.Writer) (MetricWriter, error) {
+	exporter, err := Lookup(format)
+	if err != nil {
+		return nil, err
+	}
+	return exporter.NewWriter(w)
+}
+
+// ContentType returns the MIME type for an export format
+func ContentType(format string) string {
+	exporter, err := Lookup(format)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return exporter.ContentType()
+}
+
+// row is a single field of a metric point
+type row struct {
+	timestamp time.Time
+	testID    string
+	source    string
+	pointType string
+	field     string
+	value     float64 // NaN when the field is not numeric
+	valueText string  // set only when the field is not numeric
+	tags      string  // JSON object
+}
+
+// pointRows flattens a metric point into rows, ordered by field name
+func pointRows(point models.MetricPoint) []row {
+	tags := "{}"
+	if len(point.Tags) > 0 {
+		if data, err := json.Marshal(point.Tags); err == nil {
+			tags = string(data)
+		}
+	}
+
+	fields := make([]string, 0, len(point.Fields))
+	for field := range point.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	rows := make([]row, 0, len(fields))
+	for _, field := range fields {
+		r := row{
+			timestamp: point.Timestamp,
+			testID:    point.TestID,
+			source:    point.Source,
+			pointType: point.Type,
+			field:     field,
+			tags:      tags,
+		}
+
+		if value, ok := toFloat(point.Fields[field]); ok {
+			r.value = value
+		} else {
+			r.value = math.NaN()
+			r.valueText = fmt.Sprint(point.Fields[field])
+		}
+
+		rows = append(rows, r)
+	}
+
+	return rows
+}
+
+// toFloat converts numeric field values to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func testPoint(i int) models.MetricPoint {
+	return models.MetricPoint{
+		Timestamp: time.Unix(1700000000+int64(i), 0).UTC(),
+		TestID:    "test-1",
+		Source:    "cpu-stress",
+		Type:      "plugin",
+		Tags:      map[string]string{"worker": "0"},
+		Fields:    map[string]interface{}{"ops": int64(i), "state": "running"},
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSVWriter(&buf)
+	if err := w.Write(testPoint(1)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"timestamp,test_id,source,type,field,value,value_text,tags",
+		`2023-11-14T22:13:21Z,test-1,cpu-stress,plugin,ops,1,,"{""worker"":""0""}"`,
+		`2023-11-14T22:13:21Z,test-1,cpu-stress,plugin,state,,running,"{""worker"":""0""}"`,
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Errorf("unexpected CSV:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestRegistryNegotiation(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatJSON, FormatHTML, FormatPDF} {
+		if _, err := Lookup(format); err != nil {
+			t.Errorf("Lookup(%s): %v", format, err)
+		}
+	}
+	if _, err := Lookup("xlsx"); err == nil {
+		t.Error("expected an error for an unregistered format")
+	}
+
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", FormatCSV},
+		{"*/*", FormatCSV},
+		{"application/pdf", FormatPDF},
+		{"text/html;q=0.5, application/json", FormatJSON},
+		{"application/xml, text/*;q=0.8", FormatCSV},
+		{"application/*", FormatJSON},
+	}
+	for _, tc := range cases {
+		exporter := Negotiate(tc.accept, FormatCSV)
+		if exporter == nil || exporter.Format() != tc.want {
+			t.Errorf("Negotiate(%q) = %v, want %s", tc.accept, exporter, tc.want)
+		}
+	}
+	if exporter := Negotiate("application/xml", FormatCSV); exporter != nil {
+		t.Errorf("expected no exporter for an unsupported type, got %s", exporter.Format())
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewJSONWriter(&buf)
+	if err := w.Write(testPoint(1)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var rows []jsonRow
+	if err := json.Unmarshal(buf.Bytes(), &rows); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if len(rows) != 2 || rows[0].Field != "ops" || rows[0].Value == nil || *rows[0].Value != 1 {
+		t.Fatalf("unexpected rows: %+v", rows)
+	}
+	if rows[1].Value != nil || rows[1].ValueText != "running" || rows[1].Tags["worker"] != "0" {
+		t.Errorf("unexpected text row: %+v", rows[1])
+	}
+
+	buf.Reset()
+	w = NewJSONWriter(&buf)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("expected an empty array, got %q", buf.String())
+	}
+}
+
+func TestHTMLWriterEscapes(t *testing.T) {
+	point := testPoint(1)
+	point.Fields = map[string]interface{}{"state": "<script>"}
+
+	var buf bytes.Buffer
+	w := NewHTMLWriter(&buf)
+	if err := w.Write(point); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "<script>") || !strings.Contains(out, "&lt;script&gt;") {
+		t.Errorf("field values were not escaped:\n%s", out)
+	}
+	if !strings.Contains(out, "<p>1 rows</p>") || !strings.HasSuffix(out, "</html>\n") {
+		t.Errorf("missing footer:\n%s", out)
+	}
+}
+
+func TestPDFWriterLayout(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewPDFWriter(&buf)
+	for i := 0; i < 100; i++ {
+		if err := w.Write(testPoint(i)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data := buf.String()
+	if !strings.HasPrefix(data, "%PDF-1.4\n") || !strings.HasSuffix(data, "%%EOF\n") {
+		t.Fatal("missing PDF header or trailer")
+	}
+
+	// 200 rows at 54 per page
+	if len(w.pages) != 4 {
+		t.Errorf("expected 4 pages, got %d", len(w.pages))
+	}
+
+	// Every cross-reference entry must point at its object
+	for object := 1; object < w.next; object++ {
+		offset := w.offsets[object]
+		if !strings.HasPrefix(data[offset:], strconv.Itoa(object)+" 0 obj\n") {
+			t.Errorf("object %d not at offset %d", object, offset)
+		}
+	}
+	startxref := strings.LastIndex(data, "startxref\n")
+	offset, err := strconv.Atoi(strings.Fields(data[startxref+len("startxref\n"):])[0])
+	if err != nil || !strings.HasPrefix(data[offset:], "xref\n") {
+		t.Errorf("startxref does not point at the cross-reference table")
+	}
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+const htmlHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Metric export</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: left; }
+th { background: #eee; position: sticky; top: 0; }
+td.value { text-align: right; font-variant-numeric: tabular-nums; }
+</style>
+</head>
+<body>
+<h1>Metric export</h1>
+<table>
+<thead><tr><th>timestamp</th><th>test_id</th><th>source</th><th>type</th><th>field</th><th>value</th><th>value_text</th><th>tags</th></tr></thead>
+<tbody>
+`
+
+// HTMLWriter writes metric points as an HTML report with one table row per field
+type HTMLWriter struct {
+	w       *bufio.Writer
+	started bool
+	rows    int
+}
+
+// NewHTMLWriter creates an HTML metric writer
+func NewHTMLWriter(w io.Writer) *HTMLWriter {
+	return &HTMLWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes one table row per field of the point
+func (h *HTMLWriter) Write(point models.MetricPoint) error {
+	if err := h.writeHeader(); err != nil {
+		return err
+	}
+
+	for _, r := range pointRows(point) {
+		value := ""
+		if !math.IsNaN(r.value) {
+			value = strconv.FormatFloat(r.value, 'g', -1, 64)
+		}
+
+		cells := []string{
+			"<tr><td>", html.EscapeString(r.timestamp.UTC().Format(time.RFC3339Nano)),
+			"</td><td>", html.EscapeString(r.testID),
+			"</td><td>", html.EscapeString(r.source),
+			"</td><td>", html.EscapeString(r.pointType),
+			"</td><td>", html.EscapeString(r.field),
+			"</td><td class=\"value\">", value,
+			"</td><td>", html.EscapeString(r.valueText),
+			"</td><td>", html.EscapeString(r.tags),
+			"</td></tr>\n",
+		}
+		for _, cell := range cells {
+			if _, err := h.w.WriteString(cell); err != nil {
+				return err
+			}
+		}
+		h.rows++
+	}
+
+	return nil
+}
+
+// Flush flushes buffered rows to the underlying writer
+func (h *HTMLWriter) Flush() error {
+	return h.w.Flush()
+}
+
+// Close ends the table with a row count and flushes
+func (h *HTMLWriter) Close() error {
+	if err := h.writeHeader(); err != nil {
+		return err
+	}
+	footer := "</tbody>\n</table>\n<p>" + strconv.Itoa(h.rows) + " rows</p>\n</body>\n</html>\n"
+	if _, err := h.w.WriteString(footer); err != nil {
+		return err
+	}
+	return h.w.Flush()
+}
+
+func (h *HTMLWriter) writeHeader() error {
+	if h.started {
+		return nil
+	}
+	h.started = true
+	_, err := h.w.WriteString(htmlHeader)
+	return err
+}
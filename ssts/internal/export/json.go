@@ -0,0 +1,94 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// jsonRow is a row as written by JSONWriter
+type jsonRow struct {
+	Timestamp string            `json:"timestamp"`
+	TestID    string            `json:"test_id"`
+	Source    string            `json:"source"`
+	Type      string            `json:"type"`
+	Field     string            `json:"field"`
+	Value     *float64          `json:"value"` // null when the field is not numeric
+	ValueText string            `json:"value_text,omitempty"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// JSONWriter writes metric points as a JSON array of rows, streaming each as it is written
+type JSONWriter struct {
+	w       *bufio.Writer
+	started bool
+}
+
+// NewJSONWriter creates a JSON metric writer
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes one array element per field of the point
+func (j *JSONWriter) Write(point models.MetricPoint) error {
+	tags := point.Tags
+	if tags == nil {
+		tags = map[string]string{}
+	}
+
+	for _, r := range pointRows(point) {
+		element := jsonRow{
+			Timestamp: r.timestamp.UTC().Format(time.RFC3339Nano),
+			TestID:    r.testID,
+			Source:    r.source,
+			Type:      r.pointType,
+			Field:     r.field,
+			ValueText: r.valueText,
+			Tags:      tags,
+		}
+		if !math.IsNaN(r.value) {
+			value := r.value
+			element.Value = &value
+		}
+
+		data, err := json.Marshal(element)
+		if err != nil {
+			return err
+		}
+
+		separator := ",\n"
+		if !j.started {
+			separator = "[\n"
+			j.started = true
+		}
+		if _, err := j.w.WriteString(separator); err != nil {
+			return err
+		}
+		if _, err := j.w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flush flushes buffered rows to the underlying writer
+func (j *JSONWriter) Flush() error {
+	return j.w.Flush()
+}
+
+// Close ends the array and flushes
+func (j *JSONWriter) Close() error {
+	end := "\n]\n"
+	if !j.started {
+		end = "[]\n"
+	}
+	if _, err := j.w.WriteString(end); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
@@ -0,0 +1,227 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// PDF page layout: A4 landscape in points, set in 7pt Courier so columns line up
+const (
+	pdfPageWidth    = 842
+	pdfPageHeight   = 595
+	pdfMargin       = 36
+	pdfFontSize     = 7
+	pdfLineHeight   = 9
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+	pdfLineChars    = 180
+)
+
+// Objects written before the pages; pages and their content streams follow
+const (
+	pdfCatalogObject = 1
+	pdfPagesObject   = 2
+	pdfFontObject    = 3
+	pdfFirstObject   = 4
+)
+
+// pdfColumns are the widths of the report columns; tags take the rest of the line
+var pdfColumns = []struct {
+	name  string
+	width int
+}{
+	{"timestamp", 31},
+	{"source", 16},
+	{"type", 12},
+	{"field", 28},
+	{"value", 20},
+}
+
+// PDFWriter writes metric points as a paginated PDF report with one line per
+// field. Each page is written out as soon as it is full, so memory use doesn't
+// grow with the size of the export.
+type PDFWriter struct {
+	w       *countingWriter
+	offsets map[int]int64 // byte offset of each object, for the cross-reference table
+	pages   []int         // page object numbers
+	next    int           // next free object number
+	lines   []string      // lines of the page being filled
+	rows    int
+	started bool
+}
+
+// NewPDFWriter creates a PDF metric writer
+func NewPDFWriter(w io.Writer) *PDFWriter {
+	return &PDFWriter{
+		w:       &countingWriter{w: w},
+		offsets: make(map[int]int64),
+		next:    pdfFirstObject,
+	}
+}
+
+// Write adds one line per field of the point, writing out the page when it is full
+func (p *PDFWriter) Write(point models.MetricPoint) error {
+	if err := p.writeHeader(); err != nil {
+		return err
+	}
+
+	for _, r := range pointRows(point) {
+		value := r.valueText
+		if !math.IsNaN(r.value) {
+			value = strconv.FormatFloat(r.value, 'g', -1, 64)
+		}
+
+		cells := []string{r.timestamp.UTC().Format(time.RFC3339Nano), r.source, r.pointType, r.field, value}
+		p.lines = append(p.lines, pdfLine(cells, r.tags))
+		p.rows++
+
+		if len(p.lines) == pdfLinesPerPage-4 { // room for the headings and the closing row count
+			if err := p.writePage(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close writes the last page, a row count, and the document trailer
+func (p *PDFWriter) Close() error {
+	if err := p.writeHeader(); err != nil {
+		return err
+	}
+	p.lines = append(p.lines, "", fmt.Sprintf("%d rows", p.rows))
+	if err := p.writePage(); err != nil {
+		return err
+	}
+
+	if err := p.writeObject(pdfFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>"); err != nil {
+		return err
+	}
+
+	kids := make([]string, len(p.pages))
+	for i, page := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	pages := fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages))
+	if err := p.writeObject(pdfPagesObject, pages); err != nil {
+		return err
+	}
+	if err := p.writeObject(pdfCatalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObject)); err != nil {
+		return err
+	}
+
+	xref := p.w.n
+	var trailer bytes.Buffer
+	fmt.Fprintf(&trailer, "xref\n0 %d\n0000000000 65535 f \n", p.next)
+	for object := 1; object < p.next; object++ {
+		fmt.Fprintf(&trailer, "%010d 00000 n \n", p.offsets[object])
+	}
+	fmt.Fprintf(&trailer, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", p.next, pdfCatalogObject, xref)
+	_, err := p.w.Write(trailer.Bytes())
+	return err
+}
+
+func (p *PDFWriter) writeHeader() error {
+	if p.started {
+		return nil
+	}
+	p.started = true
+	_, err := p.w.Write([]byte("%PDF-1.4\n"))
+	return err
+}
+
+// writePage writes the buffered lines, under the column headings, as a page
+func (p *PDFWriter) writePage() error {
+	headings := make([]string, len(pdfColumns))
+	for i, column := range pdfColumns {
+		headings[i] = column.name
+	}
+	lines := append([]string{pdfLine(headings, "tags"), ""}, p.lines...)
+	p.lines = p.lines[:0]
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin-pdfFontSize)
+	for _, line := range lines {
+		fmt.Fprintf(&content, "(%s) '\n", pdfEscape(line))
+	}
+	content.WriteString("ET\n")
+
+	contentObject := p.next
+	pageObject := p.next + 1
+	p.next += 2
+
+	stream := fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String())
+	if err := p.writeObject(contentObject, stream); err != nil {
+		return err
+	}
+	page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesObject, pdfPageWidth, pdfPageHeight, pdfFontObject, contentObject)
+	if err := p.writeObject(pageObject, page); err != nil {
+		return err
+	}
+	p.pages = append(p.pages, pageObject)
+	return nil
+}
+
+func (p *PDFWriter) writeObject(number int, body string) error {
+	p.offsets[number] = p.w.n
+	_, err := fmt.Fprintf(p.w, "%d 0 obj\n%s\nendobj\n", number, body)
+	return err
+}
+
+// pdfLine lays out cells in the report columns, followed by the tags, cut to the line width
+func pdfLine(cells []string, tags string) string {
+	var line strings.Builder
+	for i, column := range pdfColumns {
+		cell := cells[i]
+		if len(cell) >= column.width {
+			cell = cell[:column.width-2] + "~"
+		}
+		line.WriteString(cell)
+		line.WriteString(strings.Repeat(" ", column.width-len(cell)))
+	}
+	line.WriteString(tags)
+
+	text := line.String()
+	if len(text) > pdfLineChars {
+		text = text[:pdfLineChars-1] + "~"
+	}
+	return text
+}
+
+// pdfEscape escapes a string for a PDF literal, replacing characters outside
+// printable ASCII since the standard fonts can't show them
+func pdfEscape(text string) string {
+	var escaped strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			escaped.WriteByte('\\')
+			escaped.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			escaped.WriteByte('?')
+		default:
+			escaped.WriteRune(r)
+		}
+	}
+	return escaped.String()
+}
+
+// countingWriter tracks how many bytes have been written, for object offsets
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	n, err := c.w.Write(data)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,142 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Exporter is an export format. Formats are looked up by name or content type in
+// the registry, so a new one only needs to be registered to be usable everywhere.
+type Exporter interface {
+	Format() string      // name used in format parameters, e.g. csv
+	ContentType() string // MIME type of the output
+	Extension() string   // file name extension, without the dot
+	NewWriter(w io.Writer) (MetricWriter, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Exporter)
+)
+
+// Register adds an exporter to the registry, replacing any with the same format
+func Register(exporter Exporter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[exporter.Format()] = exporter
+}
+
+// Lookup returns the exporter for a format
+func Lookup(format string) (Exporter, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	exporter, ok := registry[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+	return exporter, nil
+}
+
+// Formats lists the registered formats in name order
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return sortedFormats()
+}
+
+// Negotiate picks the exporter best matching an HTTP Accept header, honouring
+// q-values and wildcards. It returns nil if no registered format is acceptable,
+// and the fallback format's exporter if the header is empty or accepts anything.
+func Negotiate(accept, fallback string) Exporter {
+	if strings.TrimSpace(accept) == "" {
+		exporter, _ := Lookup(fallback)
+		return exporter
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var best Exporter
+	bestQ := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+
+		var match Exporter
+		switch {
+		case mediaType == "*/*":
+			match = registry[fallback]
+		case strings.HasSuffix(mediaType, "/*"):
+			prefix := strings.TrimSuffix(mediaType, "*")
+			if exporter, ok := registry[fallback]; ok && strings.HasPrefix(exporter.ContentType(), prefix) {
+				match = exporter
+			} else {
+				for _, format := range sortedFormats() {
+					if strings.HasPrefix(registry[format].ContentType(), prefix) {
+						match = registry[format]
+						break
+					}
+				}
+			}
+		default:
+			for _, format := range sortedFormats() {
+				if registry[format].ContentType() == mediaType {
+					match = registry[format]
+					break
+				}
+			}
+		}
+
+		if match != nil {
+			best, bestQ = match, q
+		}
+	}
+	return best
+}
+
+// sortedFormats lists the registered formats in name order. The caller must hold registryMu.
+func sortedFormats() []string {
+	formats := make([]string, 0, len(registry))
+	for format := range registry {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// writerExporter is an exporter backed by a writer constructor
+type writerExporter struct {
+	format      string
+	contentType string
+	newWriter   func(w io.Writer) MetricWriter
+}
+
+func (e writerExporter) Format() string      { return e.format }
+func (e writerExporter) ContentType() string { return e.contentType }
+func (e writerExporter) Extension() string   { return e.format }
+func (e writerExporter) NewWriter(w io.Writer) (MetricWriter, error) {
+	return e.newWriter(w), nil
+}
+
+func init() {
+	Register(writerExporter{FormatCSV, "text/csv", func(w io.Writer) MetricWriter { return NewCSVWriter(w) }})
+	Register(writerExporter{FormatJSON, "application/json", func(w io.Writer) MetricWriter { return NewJSONWriter(w) }})
+	Register(writerExporter{FormatHTML, "text/html", func(w io.Writer) MetricWriter { return NewHTMLWriter(w) }})
+	Register(writerExporter{FormatPDF, "application/pdf", func(w io.Writer) MetricWriter { return NewPDFWriter(w) }})
+}
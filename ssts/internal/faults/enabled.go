@@ -0,0 +1,6 @@
+//go:build failpoints
+
+package faults
+
+// Enabled reports whether failpoints are compiled in
+const Enabled = true
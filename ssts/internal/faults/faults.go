@@ -0,0 +1,251 @@
+// Package faults provides failpoints: named hooks in the orchestrator, repository and
+// InfluxDB code paths that can be made to fail or stall on demand, so error handling
+// such as write failures and timeouts can be exercised by integration tests.
+//
+// Failpoints are compiled in only with the "failpoints" build tag:
+//
+//	go test -tags failpoints ./...
+//
+// Without it Inject is a no-op the compiler removes. With it, failpoints are set
+// programmatically with Enable, or at startup from the SSTS_FAILPOINTS environment
+// variable as semicolon-separated name=spec pairs, e.g.
+//
+//	SSTS_FAILPOINTS='influxdb/write=return(disk full);database/query=2*sleep(5s)'
+//
+// A spec is an action with an optional count prefix. The count limits how many times
+// the action fires, after which the failpoint is disabled again:
+//
+//	return(msg)      fail with an error wrapping ErrInjected
+//	sleep(duration)  delay, returning early with the context's error if it is done
+//	panic(msg)       panic
+//	off              do nothing
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Failpoints placed in the codebase
+const (
+	OrchestratorStart   = "orchestrator/start"   // TestOrchestrator.StartTest
+	OrchestratorExecute = "orchestrator/execute" // before a plugin is executed
+	OrchestratorStop    = "orchestrator/stop"    // TestOrchestrator.StopTest
+
+	DatabaseCreate = "database/create" // every GORM create
+	DatabaseQuery  = "database/query"  // every GORM query
+	DatabaseUpdate = "database/update" // every GORM update or save
+	DatabaseDelete = "database/delete" // every GORM delete
+
+	InfluxDBWrite  = "influxdb/write"  // metric writes
+	InfluxDBQuery  = "influxdb/query"  // metric queries
+	InfluxDBHealth = "influxdb/health" // health checks
+)
+
+// EnvVar is the environment variable failpoints are read from at startup
+const EnvVar = "SSTS_FAILPOINTS"
+
+// ErrInjected is wrapped by every error returned from a failpoint
+var ErrInjected = errors.New("injected fault")
+
+// Spec is a parsed failpoint action
+type Spec struct {
+	Action string        // return, sleep, panic or off
+	Arg    string        // message for return and panic
+	Delay  time.Duration // delay for sleep
+	Count  int           // times the action fires; 0 for unlimited
+}
+
+// ParseSpec parses a failpoint spec such as "return(boom)" or "3*sleep(100ms)"
+func ParseSpec(spec string) (Spec, error) {
+	var s Spec
+	spec = strings.TrimSpace(spec)
+
+	if i := strings.Index(spec, "*"); i >= 0 && i < strings.Index(spec+"(", "(") {
+		count, err := strconv.Atoi(spec[:i])
+		if err != nil || count <= 0 {
+			return Spec{}, fmt.Errorf("invalid failpoint count in %q", spec)
+		}
+		s.Count = count
+		spec = spec[i+1:]
+	}
+
+	s.Action = spec
+	if i := strings.Index(spec, "("); i >= 0 {
+		if !strings.HasSuffix(spec, ")") {
+			return Spec{}, fmt.Errorf("unterminated argument in failpoint spec %q", spec)
+		}
+		s.Action = spec[:i]
+		s.Arg = spec[i+1 : len(spec)-1]
+	}
+
+	switch s.Action {
+	case "return", "panic", "off":
+	case "sleep":
+		delay, err := time.ParseDuration(s.Arg)
+		if err != nil {
+			return Spec{}, fmt.Errorf("invalid failpoint delay %q: %w", s.Arg, err)
+		}
+		s.Delay = delay
+	default:
+		return Spec{}, fmt.Errorf("unknown failpoint action %q", s.Action)
+	}
+
+	return s, nil
+}
+
+// String formats the spec in the syntax ParseSpec accepts
+func (s Spec) String() string {
+	prefix := ""
+	if s.Count > 0 {
+		prefix = strconv.Itoa(s.Count) + "*"
+	}
+	switch s.Action {
+	case "sleep":
+		return prefix + "sleep(" + s.Delay.String() + ")"
+	case "off":
+		return prefix + "off"
+	default:
+		return prefix + s.Action + "(" + s.Arg + ")"
+	}
+}
+
+// registry holds the active failpoints
+type registry struct {
+	mu     sync.Mutex
+	points map[string]*Spec
+}
+
+var active = &registry{points: make(map[string]*Spec)}
+
+func init() {
+	if !Enabled {
+		return
+	}
+	if err := EnableFromString(os.Getenv(EnvVar)); err != nil {
+		panic(fmt.Sprintf("faults: %s: %v", EnvVar, err))
+	}
+}
+
+// Inject evaluates the named failpoint. It returns nil unless the failpoint is
+// enabled and its action is return, or it is sleep and ctx is done first.
+func Inject(ctx context.Context, name string) error {
+	if !Enabled {
+		return nil
+	}
+	return active.eval(ctx, name)
+}
+
+// Enable sets a failpoint from a spec string. It returns an error if failpoints
+// aren't compiled in, so tests relying on them fail loudly instead of passing.
+func Enable(name, spec string) error {
+	if !Enabled {
+		return fmt.Errorf("failpoints not compiled in, build with -tags failpoints")
+	}
+	s, err := ParseSpec(spec)
+	if err != nil {
+		return err
+	}
+	active.set(name, s)
+	return nil
+}
+
+// EnableFromString enables failpoints from semicolon-separated name=spec pairs
+func EnableFromString(specs string) error {
+	for _, pair := range strings.Split(specs, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid failpoint %q, expected name=spec", pair)
+		}
+		if err := Enable(strings.TrimSpace(name), spec); err != nil {
+			return fmt.Errorf("failpoint %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Disable removes a failpoint
+func Disable(name string) {
+	active.mu.Lock()
+	defer active.mu.Unlock()
+	delete(active.points, name)
+}
+
+// Reset removes all failpoints
+func Reset() {
+	active.mu.Lock()
+	defer active.mu.Unlock()
+	active.points = make(map[string]*Spec)
+}
+
+// List returns the active failpoints and their remaining specs
+func List() map[string]string {
+	active.mu.Lock()
+	defer active.mu.Unlock()
+
+	list := make(map[string]string, len(active.points))
+	for name, spec := range active.points {
+		list[name] = spec.String()
+	}
+	return list
+}
+
+func (r *registry) set(name string, spec Spec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.points[name] = &spec
+}
+
+// take returns the action to run for a failpoint, consuming one use of a counted spec
+func (r *registry) take(name string) (Spec, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	spec, ok := r.points[name]
+	if !ok {
+		return Spec{}, false
+	}
+	taken := *spec
+	if spec.Count > 0 {
+		spec.Count--
+		if spec.Count == 0 {
+			delete(r.points, name)
+		}
+	}
+	return taken, true
+}
+
+func (r *registry) eval(ctx context.Context, name string) error {
+	spec, ok := r.take(name)
+	if !ok {
+		return nil
+	}
+
+	switch spec.Action {
+	case "return":
+		return fmt.Errorf("%w: %s: %s", ErrInjected, name, spec.Arg)
+	case "panic":
+		panic(fmt.Sprintf("failpoint %s: %s", name, spec.Arg))
+	case "sleep":
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		timer := time.NewTimer(spec.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
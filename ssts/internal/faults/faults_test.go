@@ -0,0 +1,63 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Spec
+	}{
+		{"return(disk full)", Spec{Action: "return", Arg: "disk full"}},
+		{"3*return(a*b)", Spec{Action: "return", Arg: "a*b", Count: 3}},
+		{"sleep(250ms)", Spec{Action: "sleep", Arg: "250ms", Delay: 250 * time.Millisecond}},
+		{"panic(boom)", Spec{Action: "panic", Arg: "boom"}},
+		{"off", Spec{Action: "off"}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSpec(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"explode", "sleep(soon)", "0*return(x)", "return(x"} {
+		if _, err := ParseSpec(spec); err == nil {
+			t.Errorf("ParseSpec(%q) expected an error", spec)
+		}
+	}
+}
+
+func TestRegistryEval(t *testing.T) {
+	r := &registry{points: make(map[string]*Spec)}
+
+	spec, _ := ParseSpec("2*return(write failed)")
+	r.set("influxdb/write", spec)
+
+	for i := 0; i < 2; i++ {
+		if err := r.eval(context.Background(), "influxdb/write"); !errors.Is(err, ErrInjected) {
+			t.Fatalf("eval #%d = %v, want ErrInjected", i+1, err)
+		}
+	}
+	if err := r.eval(context.Background(), "influxdb/write"); err != nil {
+		t.Errorf("failpoint should be exhausted after its count, got %v", err)
+	}
+
+	spec, _ = ParseSpec("sleep(1h)")
+	r.set("database/query", spec)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.eval(ctx, "database/query"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("sleep should end with the context, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// fioResults is the part of fio's JSON output imported
+type fioResults struct {
+	Version   string   `json:"fio version"`
+	Timestamp int64    `json:"timestamp"` // when fio finished, in Unix seconds
+	Jobs      []fioJob `json:"jobs"`
+}
+
+type fioJob struct {
+	Name    string  `json:"jobname"`
+	Error   int     `json:"error"`
+	Runtime int64   `json:"job_runtime"` // milliseconds
+	Read    fioIO   `json:"read"`
+	Write   fioIO   `json:"write"`
+	Trim    fioIO   `json:"trim"`
+	UsrCPU  float64 `json:"usr_cpu"`
+	SysCPU  float64 `json:"sys_cpu"`
+}
+
+type fioIO struct {
+	IOBytes   int64      `json:"io_bytes"`
+	Bandwidth float64    `json:"bw_bytes"`
+	IOPS      float64    `json:"iops"`
+	TotalIOs  int64      `json:"total_ios"`
+	Runtime   int64      `json:"runtime"` // milliseconds
+	Latency   fioLatency `json:"lat_ns"`
+	Clat      fioLatency `json:"clat_ns"`
+}
+
+type fioLatency struct {
+	Min         float64            `json:"min"`
+	Max         float64            `json:"max"`
+	Mean        float64            `json:"mean"`
+	Percentiles map[string]float64 `json:"percentile"` // keyed like "95.000000"
+}
+
+// parseFio reads fio's JSON output. Each job's reads, writes and trims are a
+// point of type io, tagged with the job and operation, with latencies in
+// milliseconds. fio reports percentiles of completion latency only.
+func parseFio(data []byte) (*Run, error) {
+	var results fioResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+	if len(results.Jobs) == 0 {
+		return nil, fmt.Errorf("no jobs")
+	}
+
+	run := &Run{Version: strings.TrimPrefix(results.Version, "fio-"), Plugin: "io-stress"}
+	var jobs []string
+	for _, job := range results.Jobs {
+		if runtime := time.Duration(job.Runtime) * time.Millisecond; runtime > run.Duration {
+			run.Duration = runtime
+		}
+		jobs = append(jobs, job.Name)
+	}
+	if results.Timestamp > 0 {
+		run.Start = time.Unix(results.Timestamp, 0).Add(-run.Duration)
+	}
+	run.Labels = models.Labels{"fio_jobs": listLabel(jobs)}
+
+	for _, job := range results.Jobs {
+		for _, io := range []struct {
+			operation string
+			stats     fioIO
+		}{{"read", job.Read}, {"write", job.Write}, {"trim", job.Trim}} {
+			if io.stats.TotalIOs == 0 {
+				continue
+			}
+			run.point("fio", "io", map[string]string{"job": job.Name, "operation": io.operation}, map[string]interface{}{
+				"iops":            io.stats.IOPS,
+				"bytes_per_sec":   io.stats.Bandwidth,
+				"avg_latency_ms":  io.stats.Latency.Mean / 1e6,
+				"min_latency_ms":  io.stats.Latency.Min / 1e6,
+				"max_latency_ms":  io.stats.Latency.Max / 1e6,
+				"p95_latency_ms":  percentile(io.stats.Clat.Percentiles, 95) / 1e6,
+				"p99_latency_ms":  percentile(io.stats.Clat.Percentiles, 99) / 1e6,
+				"total_ops":       io.stats.TotalIOs,
+				"total_bytes":     io.stats.IOBytes,
+				"runtime_seconds": float64(io.stats.Runtime) / 1000,
+				"job_error":       job.Error,
+				"usr_cpu_percent": job.UsrCPU,
+				"sys_cpu_percent": job.SysCPU,
+			})
+		}
+	}
+	return run, nil
+}
+
+// percentile looks up a percentile fio reported, keyed by its value with six
+// decimals, returning 0 if it wasn't
+func percentile(percentiles map[string]float64, p float64) float64 {
+	return percentiles[fmt.Sprintf("%f", p)]
+}
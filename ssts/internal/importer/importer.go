@@ -0,0 +1,117 @@
+// Package importer reads the result files of external benchmark tools, so runs
+// made outside SSTS can be stored as executions and compared with its own.
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Formats of the result files Parse reads
+const (
+	FormatFio      = "fio"       // fio --output-format=json
+	FormatStressNG = "stress-ng" // stress-ng --yaml
+	FormatSysbench = "sysbench"  // sysbench's standard text output
+)
+
+// Formats lists the formats Parse reads
+var Formats = []string{FormatFio, FormatStressNG, FormatSysbench}
+
+// Run is a benchmark run read from a result file. Its points carry the tool's
+// aggregate results, timestamped at the end of the run; none of the formats hold
+// samples taken while it ran.
+type Run struct {
+	Tool     string
+	Version  string    // of the tool, when the file records it
+	Plugin   string    // SSTS plugin running the same kind of workload; empty if none does
+	Start    time.Time // zero when the file doesn't record when the run happened
+	Duration time.Duration
+	Labels   models.Labels // what was run, e.g. the fio jobs or stress-ng stressors
+	Points   []models.MetricPoint
+}
+
+// Parse reads a result file in the format given, or in the format Detect finds
+// when that is empty
+func Parse(format string, data []byte) (*Run, error) {
+	if format == "" {
+		format = Detect(data)
+		if format == "" {
+			return nil, fmt.Errorf("unrecognized result file; the format must be one of %s", strings.Join(Formats, ", "))
+		}
+	}
+
+	var run *Run
+	var err error
+	switch format {
+	case FormatFio:
+		run, err = parseFio(data)
+	case FormatStressNG:
+		run, err = parseStressNG(data)
+	case FormatSysbench:
+		run, err = parseSysbench(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q; must be one of %s", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s results: %w", format, err)
+	}
+	if len(run.Points) == 0 {
+		return nil, fmt.Errorf("invalid %s results: no results found", format)
+	}
+	run.Tool = format
+	return run, nil
+}
+
+// Detect returns the format of a result file, or an empty string if it isn't
+// one Parse reads
+func Detect(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")) && bytes.Contains(trimmed, []byte(`"fio version"`)):
+		return FormatFio
+	case bytes.Contains(trimmed, []byte("stress-ng-version:")) || (bytes.Contains(trimmed, []byte("metrics:")) && bytes.Contains(trimmed, []byte("bogo-ops:"))):
+		return FormatStressNG
+	case bytes.HasPrefix(trimmed, []byte("sysbench ")) || bytes.Contains(trimmed, []byte("General statistics:")):
+		return FormatSysbench
+	}
+	return ""
+}
+
+// SetStart moves the run, and its points, to start at the time given. It is for
+// runs whose file doesn't record when they happened.
+func (r *Run) SetStart(start time.Time) {
+	// Shift by each point's offset into the run, as the distance from a zero
+	// start overflows a time.Duration
+	for i := range r.Points {
+		r.Points[i].Timestamp = start.Add(r.Points[i].Timestamp.Sub(r.Start))
+	}
+	r.Start = start
+}
+
+// listLabel joins the sorted values into a label value, cut to the longest a
+// label may be
+func listLabel(values []string) string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	value := strings.Join(sorted, ",")
+	if len(value) > models.MaxLabelValueLength {
+		value = value[:models.MaxLabelValueLength]
+	}
+	return value
+}
+
+// point is a result of the run, timestamped at its end
+func (r *Run) point(source, pointType string, tags map[string]string, fields map[string]interface{}) {
+	r.Points = append(r.Points, models.MetricPoint{
+		Timestamp: r.Start.Add(r.Duration),
+		Source:    source,
+		Type:      pointType,
+		Tags:      tags,
+		Fields:    fields,
+	})
+}
@@ -0,0 +1,171 @@
+package importer
+
+import (
+	"testing"
+	"time"
+)
+
+const fioOutput = `{
+  "fio version" : "fio-3.28",
+  "timestamp" : 1700000060,
+  "jobs" : [
+    {
+      "jobname" : "randrw",
+      "error" : 0,
+      "job_runtime" : 60000,
+      "usr_cpu" : 2.5,
+      "sys_cpu" : 10.1,
+      "read" : {
+        "io_bytes" : 419430400,
+        "bw_bytes" : 6990506,
+        "iops" : 1706.7,
+        "runtime" : 60000,
+        "total_ios" : 102400,
+        "clat_ns" : {"percentile" : {"95.000000" : 1531904, "99.000000" : 2899968}},
+        "lat_ns" : {"min" : 85000, "max" : 9800000, "mean" : 580000.5}
+      },
+      "write" : {
+        "io_bytes" : 0, "bw_bytes" : 0, "iops" : 0, "runtime" : 0, "total_ios" : 0,
+        "clat_ns" : {}, "lat_ns" : {}
+      },
+      "trim" : {"total_ios" : 0}
+    }
+  ]
+}`
+
+const stressNGOutput = `---
+system-info:
+      stress-ng-version: 0.14.06
+      run-by: root
+      epoch-secs: 1700000000
+      hostname: bench-01
+metrics:
+    - stressor: cpu
+      bogo-ops: 54321
+      bogo-ops-per-second-usr-sys-time: 905.35
+      bogo-ops-per-second-real-time: 905.02
+      wall-clock-time: 60.02
+      user-time: 59.97
+      system-time: 0.01
+    - stressor: vm
+      bogo-ops: 1200
+      bogo-ops-per-second-real-time: 20
+      wall-clock-time: 60.01
+times:
+      run-time: 60.03
+`
+
+const sysbenchOutput = `sysbench 1.0.20 (using system LuaJIT 2.1.0-beta3)
+
+Running the test with following options:
+Number of threads: 4
+Initializing random number generator from current time
+
+
+Prime numbers limit: 10000
+
+Initializing worker threads...
+
+Threads started!
+
+CPU speed:
+    events per second:  4001.52
+
+General statistics:
+    total time:                          10.0005s
+    total number of events:              40022
+
+Latency (ms):
+         min:                                    0.95
+         avg:                                    1.00
+         max:                                    3.02
+         95th percentile:                        1.01
+         sum:                                39981.28
+
+Threads fairness:
+    events (avg/stddev):           10005.5000/2.12
+    execution time (avg/stddev):   9.9953/0.00
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		data     string
+		tool     string
+		plugin   string
+		start    time.Time
+		duration time.Duration
+		points   int
+		field    string
+		want     float64
+	}{
+		{"fio", FormatFio, fioOutput, FormatFio, "io-stress", time.Unix(1700000000, 0), time.Minute, 1, "p99_latency_ms", 2.899968},
+		{"fio detected", "", fioOutput, FormatFio, "io-stress", time.Unix(1700000000, 0), time.Minute, 1, "avg_latency_ms", 0.5800005},
+		{"stress-ng", "", stressNGOutput, FormatStressNG, "cpu-stress", time.Unix(1700000000, 0), 60030 * time.Millisecond, 2, "total_bogo_ops", 54321},
+		{"sysbench", "", sysbenchOutput, FormatSysbench, "cpu-stress", time.Time{}, 10000500 * time.Microsecond, 1, "events_per_sec", 4001.52},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run, err := Parse(tt.format, []byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if run.Tool != tt.tool || run.Plugin != tt.plugin || !run.Start.Equal(tt.start) || run.Duration != tt.duration {
+				t.Errorf("run = %s %s from %s for %s", run.Tool, run.Plugin, run.Start, run.Duration)
+			}
+			if len(run.Points) != tt.points {
+				t.Fatalf("points = %+v", run.Points)
+			}
+			point := run.Points[0]
+			if got := point.Fields[tt.field]; got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.field, got, tt.want)
+			}
+			if point.Source != tt.tool || !point.Timestamp.Equal(run.Start.Add(run.Duration)) {
+				t.Errorf("point %s %s at %s, want the tool's at the end of the run", point.Source, point.Type, point.Timestamp)
+			}
+		})
+	}
+}
+
+func TestParseLabelsAndTags(t *testing.T) {
+	fio, _ := Parse(FormatFio, []byte(fioOutput))
+	if fio.Labels["fio_jobs"] != "randrw" || fio.Points[0].Tags["operation"] != "read" || fio.Version != "3.28" {
+		t.Errorf("fio run = %+v", fio)
+	}
+	stressNG, _ := Parse(FormatStressNG, []byte(stressNGOutput))
+	if stressNG.Labels["stress_ng_stressors"] != "cpu,vm" || stressNG.Points[1].Tags["stressor"] != "vm" {
+		t.Errorf("stress-ng run = %+v", stressNG)
+	}
+	sysbench, _ := Parse(FormatSysbench, []byte(sysbenchOutput))
+	if sysbench.Points[0].Tags["test"] != "cpu" || sysbench.Points[0].Fields["p95_latency_ms"] != 1.01 {
+		t.Errorf("sysbench point = %+v", sysbench.Points[0])
+	}
+
+	// Files without a time are moved to when the caller says they ran
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	sysbench.SetStart(start)
+	if !sysbench.Points[0].Timestamp.Equal(start.Add(sysbench.Duration)) {
+		t.Errorf("point at %s after moving the run to %s", sysbench.Points[0].Timestamp, start)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"unknown format", "iperf", fioOutput},
+		{"undetected", "", "hello"},
+		{"malformed fio", FormatFio, "{"},
+		{"fio without jobs", FormatFio, `{"fio version": "fio-3.28", "jobs": []}`},
+		{"stress-ng without metrics", FormatStressNG, "system-info:\n  stress-ng-version: 0.14.06\n"},
+		{"sysbench without statistics", FormatSysbench, "sysbench 1.0.20\n"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.format, []byte(tt.data)); err == nil {
+			t.Errorf("%s: parsed", tt.name)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// stressNGResults is the part of stress-ng's YAML output imported
+type stressNGResults struct {
+	SystemInfo struct {
+		Version string `yaml:"stress-ng-version"`
+		Epoch   int64  `yaml:"epoch-secs"` // when the run started
+	} `yaml:"system-info"`
+	Metrics []map[string]interface{} `yaml:"metrics"`
+	Times   struct {
+		RunTime float64 `yaml:"run-time"` // seconds
+	} `yaml:"times"`
+}
+
+// stressNGPlugins maps stress-ng stressors to the SSTS plugin stressing the same
+// resource; stressors of the cpu family map by prefix
+var stressNGPlugins = map[string]string{
+	"cpu":       "cpu-stress",
+	"matrix":    "cpu-stress",
+	"vm":        "memory-stress",
+	"malloc":    "memory-stress",
+	"memcpy":    "memory-stress",
+	"stream":    "memory-stress",
+	"bigheap":   "memory-stress",
+	"hdd":       "io-stress",
+	"iomix":     "io-stress",
+	"aio":       "io-stress",
+	"io":        "io-stress",
+	"sched":     "scheduler-stress",
+	"switch":    "scheduler-stress",
+	"yield":     "scheduler-stress",
+	"getrandom": "entropy-stress",
+	"urandom":   "entropy-stress",
+}
+
+// parseStressNG reads stress-ng's YAML output. Each stressor is a point of type
+// stressor, tagged with its name, holding every number stress-ng reported for it
+// with dashes in names turned to underscores; bogo-ops becomes total_bogo_ops.
+func parseStressNG(data []byte) (*Run, error) {
+	var results stressNGResults
+	if err := yaml.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+	if len(results.Metrics) == 0 {
+		return nil, fmt.Errorf("no stressor metrics")
+	}
+
+	run := &Run{Version: results.SystemInfo.Version}
+	if results.SystemInfo.Epoch > 0 {
+		run.Start = time.Unix(results.SystemInfo.Epoch, 0)
+	}
+	run.Duration = time.Duration(results.Times.RunTime * float64(time.Second))
+
+	var stressors []string
+	for _, metrics := range results.Metrics {
+		name, _ := metrics["stressor"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("stressor metrics without a stressor name")
+		}
+		stressors = append(stressors, name)
+		if wall, ok := number(metrics["wall-clock-time"]); ok && run.Duration == 0 {
+			run.Duration = time.Duration(wall * float64(time.Second))
+		}
+		if run.Plugin == "" {
+			run.Plugin = stressNGPlugin(name)
+		}
+	}
+
+	for _, metrics := range results.Metrics {
+		fields := make(map[string]interface{}, len(metrics))
+		for key, value := range metrics {
+			if value, ok := number(value); ok {
+				if key == "bogo-ops" {
+					key = "total_bogo_ops"
+				}
+				fields[strings.ReplaceAll(key, "-", "_")] = value
+			}
+		}
+		run.point("stress-ng", "stressor", map[string]string{"stressor": metrics["stressor"].(string)}, fields)
+	}
+
+	run.Labels = models.Labels{"stress_ng_stressors": listLabel(stressors)}
+	return run, nil
+}
+
+// stressNGPlugin returns the plugin stressing what a stressor does, if any
+func stressNGPlugin(stressor string) string {
+	if plugin, ok := stressNGPlugins[stressor]; ok {
+		return plugin
+	}
+	if strings.HasPrefix(stressor, "cpu") {
+		return "cpu-stress"
+	}
+	return ""
+}
+
+// number converts a number decoded from YAML to float64
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// sysbenchField reads one or two fields from a line of sysbench's output
+type sysbenchField struct {
+	pattern *regexp.Regexp
+	fields  []string // named after each submatch, in order
+}
+
+// sysbenchFields are the results read from sysbench's output, whatever the test
+var sysbenchFields = []sysbenchField{
+	{regexp.MustCompile(`^Number of threads:\s*([\d.]+)`), []string{"threads"}},
+	{regexp.MustCompile(`^events per second:\s*([\d.]+)`), []string{"events_per_sec"}},
+	{regexp.MustCompile(`^total time:\s*([\d.]+)s`), []string{"total_time_seconds"}},
+	{regexp.MustCompile(`^total number of events:\s*([\d.]+)`), []string{"total_events"}},
+	{regexp.MustCompile(`^min:\s*([\d.]+)`), []string{"min_latency_ms"}},
+	{regexp.MustCompile(`^avg:\s*([\d.]+)`), []string{"avg_latency_ms"}},
+	{regexp.MustCompile(`^max:\s*([\d.]+)`), []string{"max_latency_ms"}},
+	{regexp.MustCompile(`^95th percentile:\s*([\d.]+)`), []string{"p95_latency_ms"}},
+	{regexp.MustCompile(`^Total operations:\s*([\d.]+)\s*\(([\d.]+) per second\)`), []string{"total_ops", "ops_per_sec"}},
+	{regexp.MustCompile(`^([\d.]+) MiB transferred \(([\d.]+) MiB/sec\)`), []string{"total_mib", "mib_per_sec"}},
+	{regexp.MustCompile(`^reads/s:\s*([\d.]+)`), []string{"reads_per_sec"}},
+	{regexp.MustCompile(`^writes/s:\s*([\d.]+)`), []string{"writes_per_sec"}},
+	{regexp.MustCompile(`^fsyncs/s:\s*([\d.]+)`), []string{"fsyncs_per_sec"}},
+	{regexp.MustCompile(`^read, MiB/s:\s*([\d.]+)`), []string{"read_mib_per_sec"}},
+	{regexp.MustCompile(`^written, MiB/s:\s*([\d.]+)`), []string{"written_mib_per_sec"}},
+	{regexp.MustCompile(`^transactions:\s*([\d.]+)\s*\(([\d.]+) per sec\.\)`), []string{"total_transactions", "transactions_per_sec"}},
+	{regexp.MustCompile(`^queries:\s*([\d.]+)\s*\(([\d.]+) per sec\.\)`), []string{"total_queries", "queries_per_sec"}},
+}
+
+var sysbenchVersion = regexp.MustCompile(`^sysbench (\S+)`)
+
+// sysbenchTests recognizes sysbench's tests by a heading only their output has,
+// and maps them to the SSTS plugin running the same kind of workload
+var sysbenchTests = []struct {
+	heading string
+	test    string
+	plugin  string
+}{
+	{"CPU speed:", "cpu", "cpu-stress"},
+	{"MiB transferred", "memory", "memory-stress"},
+	{"File operations:", "fileio", "io-stress"},
+	{"SQL statistics:", "oltp", ""},
+}
+
+// parseSysbench reads sysbench's text output as a single point of type
+// sysbench, tagged with the test when it can be told from the output. sysbench
+// doesn't record when it ran, so the run starts at the zero time.
+func parseSysbench(data []byte) (*Run, error) {
+	run := &Run{}
+	test := ""
+	fields := make(map[string]interface{})
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := sysbenchVersion.FindStringSubmatch(line); match != nil && run.Version == "" {
+			run.Version = match[1]
+		}
+		for _, t := range sysbenchTests {
+			if test == "" && strings.Contains(line, t.heading) {
+				test, run.Plugin = t.test, t.plugin
+			}
+		}
+		for _, field := range sysbenchFields {
+			match := field.pattern.FindStringSubmatch(line)
+			for i := 1; i < len(match); i++ {
+				value, err := strconv.ParseFloat(match[i], 64)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", field.fields[i-1], err)
+				}
+				fields[field.fields[i-1]] = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	seconds, ok := fields["total_time_seconds"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("no general statistics")
+	}
+	run.Duration = time.Duration(seconds * float64(time.Second))
+
+	tags := map[string]string{}
+	if test != "" {
+		tags["test"] = test
+		run.Labels = models.Labels{"sysbench_test": test}
+	}
+	run.point("sysbench", "sysbench", tags, fields)
+	return run, nil
+}
@@ -3,10 +3,12 @@ package logger
 import (
 	"os"
 
+	"github.com/sirupsen/logrus"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
 	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/secrets"
 )
 
 // New creates a new logger based on configuration
@@ -49,8 +51,68 @@ func New(cfg config.LogConfig) *zap.Logger {
 		writer = zapcore.AddSync(os.Stdout)
 	}
 
-	core := zapcore.NewCore(encoder, writer, level)
+	core := RedactSecrets(zapcore.NewCore(encoder, writer, level))
 	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
 
 	return logger
-}
\ No newline at end of file
+}
+
+// RedactSecrets wraps a core to redact the values of fields whose names mark
+// them as secrets, such as password or api_key
+func RedactSecrets(core zapcore.Core) zapcore.Core {
+	return redactingCore{core}
+}
+
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	var redacted []zapcore.Field
+	for i, field := range fields {
+		if !secrets.IsSecretKey(field.Key) {
+			continue
+		}
+		if redacted == nil {
+			redacted = append([]zapcore.Field(nil), fields...)
+		}
+		redacted[i] = zap.String(field.Key, secrets.Redacted)
+	}
+	if redacted == nil {
+		return fields
+	}
+	return redacted
+}
+
+// RedactHook redacts logrus fields whose names mark them as secrets
+type RedactHook struct{}
+
+// Levels returns every level, since any entry may carry a secret
+func (RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire redacts the entry's secret fields
+func (RedactHook) Fire(entry *logrus.Entry) error {
+	for key := range entry.Data {
+		if secrets.IsSecretKey(key) {
+			entry.Data[key] = secrets.Redacted
+		}
+	}
+	return nil
+}
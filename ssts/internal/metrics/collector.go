@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"context"
+	"math"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -28,32 +30,76 @@ type SystemMetrics struct {
 		Usage     float64 `json:"usage"`
 	} `json:"memory"`
 	Disk struct {
-		Total uint64  `json:"total"`
-		Used  uint64  `json:"used"`
-		Free  uint64  `json:"free"`
-		Usage float64 `json:"usage"`
+		Total   uint64                     `json:"total"`
+		Used    uint64                     `json:"used"`
+		Free    uint64                     `json:"free"`
+		Usage   float64                    `json:"usage"`
+		Devices []models.DiskDeviceMetrics `json:"devices"`
 	} `json:"disk"`
 	Network struct {
-		BytesSent uint64 `json:"bytes_sent"`
-		BytesRecv uint64 `json:"bytes_recv"`
+		BytesSent  uint64                           `json:"bytes_sent"`
+		BytesRecv  uint64                           `json:"bytes_recv"`
+		Interfaces []models.NetworkInterfaceMetrics `json:"interfaces"`
 	} `json:"network"`
+	Thermal models.ThermalMetrics `json:"thermal"`
+	Limits  ResourceLimits        `json:"limits"` // what CPU and memory usage are percentages of
 }
 
+// The system's per-device counters, variables so tests can stand samples in for them
+var (
+	diskPartitions = disk.Partitions
+	diskUsage      = disk.Usage
+	diskIOCounters = disk.IOCounters
+	netIOCounters  = net.IOCounters
+)
+
 type Collector struct {
 	mu           sync.RWMutex
 	logger       *zap.Logger
 	metrics      SystemMetrics
 	isCollecting bool
 	stopChan     chan struct{}
+
+	// Counters from the previous sample, used to turn cumulative totals into rates
+	lastSample time.Time
+	lastDiskIO map[string]disk.IOCountersStat
+	lastNetIO  map[string]net.IOCountersStat
+
+	sinks       []MetricSink
+	systemSinks []MetricSink // also receive every system metrics sample
+
+	limits   ResourceLimits
+	cpuMeter *CPUMeter
+
+	energy  map[string]*energySampler     // keyed by execution ID
+	sensors map[string]context.CancelFunc // stops sensor sampling, keyed by execution ID
+}
+
+// MetricSink receives metric points streamed by plugins. A point's tags map may
+// be shared with other points, so sinks must not modify it.
+type MetricSink interface {
+	WriteMetricPoint(point models.MetricPoint) error
 }
 
 func NewCollector(logger *zap.Logger) *Collector {
+	limits := ResourceLimits{Source: LimitsSourceHost, HostCPUs: hostCPUs()}
 	return &Collector{
 		logger:   logger,
 		stopChan: make(chan struct{}),
+		limits:   limits,
+		cpuMeter: NewCPUMeter(limits),
 	}
 }
 
+// SetResourceLimits sets what CPU and memory usage are measured against, such
+// as a container's cgroup limits rather than the host's totals
+func (c *Collector) SetResourceLimits(limits ResourceLimits) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limits = limits
+	c.cpuMeter = NewCPUMeter(limits)
+}
+
 func (c *Collector) Start(ctx context.Context) error {
 	c.mu.Lock()
 	if c.isCollecting {
@@ -105,20 +151,37 @@ func (c *Collector) collectSystemMetrics() {
 	var metrics SystemMetrics
 	metrics.Timestamp = time.Now()
 
-	// CPU metrics
+	c.mu.RLock()
+	limits, cpuMeter := c.limits, c.cpuMeter
+	c.mu.RUnlock()
+	metrics.Limits = limits
+
+	// CPU metrics, relative to the CPUs allowed
 	if cpuPercents, err := cpu.Percent(time.Second, false); err == nil && len(cpuPercents) > 0 {
-		metrics.CPU.Usage = cpuPercents[0]
+		metrics.CPU.Usage = cpuMeter.Percent(cpuPercents[0])
 	}
 	if cpuCounts, err := cpu.Counts(true); err == nil {
 		metrics.CPU.Cores = cpuCounts
+		if limits.CPUs > 0 && int(math.Ceil(limits.CPUs)) < cpuCounts {
+			metrics.CPU.Cores = int(math.Ceil(limits.CPUs))
+		}
 	}
 
-	// Memory metrics
+	// Memory metrics, relative to the memory allowed
 	if memStat, err := mem.VirtualMemory(); err == nil {
 		metrics.Memory.Total = memStat.Total
 		metrics.Memory.Used = memStat.Used
 		metrics.Memory.Available = memStat.Available
 		metrics.Memory.Usage = memStat.UsedPercent
+		if limits.MemoryBytes > 0 {
+			total, used := limits.Memory(memStat.Total, memStat.Total-memStat.Available)
+			metrics.Memory.Total, metrics.Memory.Used = total, used
+			metrics.Memory.Available = 0
+			if used < total {
+				metrics.Memory.Available = total - used
+			}
+			metrics.Memory.Usage = float64(used) / float64(total) * 100
+		}
 	}
 
 	// Disk metrics
@@ -135,9 +198,162 @@ func (c *Collector) collectSystemMetrics() {
 		metrics.Network.BytesRecv = netStats[0].BytesRecv
 	}
 
+	// Temperature sensors and fans
+	metrics.Thermal = ReadThermal()
+
 	c.mu.Lock()
+	// Per-device rates are computed against the previous sample
+	elapsed := metrics.Timestamp.Sub(c.lastSample).Seconds()
+	if c.lastSample.IsZero() {
+		elapsed = 0
+	}
+	metrics.Disk.Devices = c.collectDiskDevices(elapsed)
+	metrics.Network.Interfaces = c.collectNetworkInterfaces(elapsed)
+	c.lastSample = metrics.Timestamp
+
 	c.metrics = metrics
+	sinks := c.systemSinks
 	c.mu.Unlock()
+
+	if len(sinks) > 0 {
+		point := systemPoint(metrics)
+		for _, sink := range sinks {
+			sink.WriteMetricPoint(point)
+		}
+	}
+}
+
+// systemPoint is the metric point system sinks receive for a sample
+func systemPoint(metrics SystemMetrics) models.MetricPoint {
+	return models.MetricPoint{
+		Timestamp: metrics.Timestamp,
+		Source:    "collector",
+		Type:      "system",
+		Tags:      map[string]string{},
+		Fields: models.SystemFields{
+			CPUUsagePercent:      metrics.CPU.Usage,
+			CPUCores:             metrics.CPU.Cores,
+			MemoryUsagePercent:   metrics.Memory.Usage,
+			MemoryUsedBytes:      metrics.Memory.Used,
+			MemoryAvailableBytes: metrics.Memory.Available,
+			DiskUsagePercent:     metrics.Disk.Usage,
+			DiskUsedBytes:        metrics.Disk.Used,
+			NetworkSentBytes:     metrics.Network.BytesSent,
+			NetworkRecvBytes:     metrics.Network.BytesRecv,
+		}.Fields(),
+	}
+}
+
+// collectDiskDevices samples every mounted filesystem. Rates are left at zero
+// when there is no previous sample to compare against.
+func (c *Collector) collectDiskDevices(elapsed float64) []models.DiskDeviceMetrics {
+	partitions, err := diskPartitions(false)
+	if err != nil {
+		c.logger.Debug("Failed to list disk partitions", zap.Error(err))
+		return nil
+	}
+
+	counters, err := diskIOCounters()
+	if err != nil {
+		c.logger.Debug("Failed to read disk I/O counters", zap.Error(err))
+		counters = nil
+	}
+
+	devices := make([]models.DiskDeviceMetrics, 0, len(partitions))
+	seen := make(map[string]bool, len(partitions))
+	for _, partition := range partitions {
+		// Bind mounts and multiple mounts of one device would otherwise be reported twice
+		if seen[partition.Device] {
+			continue
+		}
+		seen[partition.Device] = true
+
+		usage, err := diskUsage(partition.Mountpoint)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+
+		device := models.DiskDeviceMetrics{
+			Device:       partition.Device,
+			Mountpoint:   partition.Mountpoint,
+			Fstype:       partition.Fstype,
+			TotalBytes:   int64(usage.Total),
+			UsedBytes:    int64(usage.Used),
+			UsagePercent: usage.UsedPercent,
+		}
+
+		name := filepath.Base(partition.Device)
+		if current, ok := counters[name]; ok {
+			if previous, ok := c.lastDiskIO[name]; ok && elapsed > 0 {
+				device.ReadBytesPerSec = rate(current.ReadBytes, previous.ReadBytes, elapsed)
+				device.WriteBytesPerSec = rate(current.WriteBytes, previous.WriteBytes, elapsed)
+				device.ReadOpsPerSec = rate(current.ReadCount, previous.ReadCount, elapsed)
+				device.WriteOpsPerSec = rate(current.WriteCount, previous.WriteCount, elapsed)
+
+				ops := delta(current.ReadCount, previous.ReadCount) + delta(current.WriteCount, previous.WriteCount)
+				if ops > 0 {
+					ioTime := delta(current.ReadTime, previous.ReadTime) + delta(current.WriteTime, previous.WriteTime)
+					device.LatencyMs = float64(ioTime) / float64(ops)
+				}
+			}
+		}
+
+		devices = append(devices, device)
+	}
+
+	c.lastDiskIO = counters
+	return devices
+}
+
+// collectNetworkInterfaces samples every network interface except loopback
+func (c *Collector) collectNetworkInterfaces(elapsed float64) []models.NetworkInterfaceMetrics {
+	counters, err := netIOCounters(true)
+	if err != nil {
+		c.logger.Debug("Failed to read network I/O counters", zap.Error(err))
+		return nil
+	}
+
+	current := make(map[string]net.IOCountersStat, len(counters))
+	interfaces := make([]models.NetworkInterfaceMetrics, 0, len(counters))
+	for _, counter := range counters {
+		current[counter.Name] = counter
+		if counter.Name == "lo" || counter.Name == "lo0" {
+			continue
+		}
+
+		nic := models.NetworkInterfaceMetrics{Name: counter.Name}
+		if previous, ok := c.lastNetIO[counter.Name]; ok && elapsed > 0 {
+			nic.RxBytesPerSec = rate(counter.BytesRecv, previous.BytesRecv, elapsed)
+			nic.TxBytesPerSec = rate(counter.BytesSent, previous.BytesSent, elapsed)
+			nic.RxPacketsPerSec = rate(counter.PacketsRecv, previous.PacketsRecv, elapsed)
+			nic.TxPacketsPerSec = rate(counter.PacketsSent, previous.PacketsSent, elapsed)
+			nic.RxErrors = int64(delta(counter.Errin, previous.Errin))
+			nic.TxErrors = int64(delta(counter.Errout, previous.Errout))
+		}
+
+		interfaces = append(interfaces, nic)
+	}
+
+	c.lastNetIO = current
+	return interfaces
+}
+
+// delta returns the increase of a cumulative counter, treating a reset or wrap as no change
+func delta(current, previous uint64) uint64 {
+	if current < previous {
+		return 0
+	}
+	return current - previous
+}
+
+// rate converts the increase of a cumulative counter into a per-second rate,
+// capped so a counter jumping by more than fits in an int64 doesn't overflow
+func rate(current, previous uint64, elapsed float64) int64 {
+	perSec := float64(delta(current, previous)) / elapsed
+	if perSec >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(perSec)
 }
 
 // CollectSystemMetrics returns current system metrics in the format expected by MetricsCollector interface
@@ -145,10 +361,43 @@ func (c *Collector) CollectSystemMetrics() models.SystemMetrics {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	diskMetrics := models.DiskMetrics{
+		UsagePercent: c.metrics.Disk.Usage,
+		Devices:      c.metrics.Disk.Devices,
+	}
+	var latencySum float64
+	var latencyCount int
+	for _, device := range c.metrics.Disk.Devices {
+		diskMetrics.ReadBytesPerSec += device.ReadBytesPerSec
+		diskMetrics.WriteBytesPerSec += device.WriteBytesPerSec
+		diskMetrics.ReadOpsPerSec += device.ReadOpsPerSec
+		diskMetrics.WriteOpsPerSec += device.WriteOpsPerSec
+		if device.LatencyMs > 0 {
+			latencySum += device.LatencyMs
+			latencyCount++
+		}
+	}
+	if latencyCount > 0 {
+		diskMetrics.LatencyMs = latencySum / float64(latencyCount)
+	}
+
+	networkMetrics := models.NetworkMetrics{
+		Interfaces: c.metrics.Network.Interfaces,
+	}
+	for _, nic := range c.metrics.Network.Interfaces {
+		networkMetrics.RxBytesPerSec += nic.RxBytesPerSec
+		networkMetrics.TxBytesPerSec += nic.TxBytesPerSec
+		networkMetrics.RxPacketsPerSec += nic.RxPacketsPerSec
+		networkMetrics.TxPacketsPerSec += nic.TxPacketsPerSec
+		networkMetrics.RxErrors += nic.RxErrors
+		networkMetrics.TxErrors += nic.TxErrors
+	}
+
 	return models.SystemMetrics{
 		Timestamp: c.metrics.Timestamp,
 		CPU: models.CPUMetrics{
 			UsagePercent: c.metrics.CPU.Usage,
+			Temperature:  c.metrics.Thermal.MaxCelsius,
 			// Set other fields to 0 for now - could be enhanced later
 		},
 		Memory: models.MemoryMetrics{
@@ -157,14 +406,9 @@ func (c *Collector) CollectSystemMetrics() models.SystemMetrics {
 			AvailableBytes: int64(c.metrics.Memory.Available),
 			UsagePercent:   c.metrics.Memory.Usage,
 		},
-		Disk: models.DiskMetrics{
-			UsagePercent: c.metrics.Disk.Usage,
-			// Other disk metrics would need to be collected separately
-		},
-		Network: models.NetworkMetrics{
-			RxBytesPerSec: int64(c.metrics.Network.BytesRecv),
-			TxBytesPerSec: int64(c.metrics.Network.BytesSent),
-		},
+		Disk:    diskMetrics,
+		Network: networkMetrics,
+		Thermal: c.metrics.Thermal,
 	}
 }
 
@@ -183,14 +427,140 @@ func (c *Collector) CollectPluginMetrics(pluginName string, plugin plugins.Stres
 	return metrics
 }
 
-// StartCollection starts metrics collection for a test
-func (c *Collector) StartCollection(ctx context.Context, testID string) {
-	c.logger.Info("Starting metrics collection", zap.String("test_id", testID))
-	// Additional collection logic could be added here for test-specific metrics
+// AddSink registers a sink that receives every point streamed by plugins
+func (c *Collector) AddSink(sink MetricSink) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sinks = append(c.sinks, sink)
+}
+
+// AddSystemSink registers a sink that, besides every point streamed by plugins,
+// receives each sample of the system metrics sampled in the background
+func (c *Collector) AddSystemSink(sink MetricSink) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sinks = append(c.sinks, sink)
+	c.systemSinks = append(c.systemSinks, sink)
+}
+
+// StreamPluginMetrics forwards points from a plugin's metrics stream to every sink
+// until the stream is closed. Points are tagged with the test, execution and plugin
+// they came from, so concurrent executions of a plugin can be told apart. Plugins
+// may publish points sharing a tags map; it is tagged with the execution before
+// its first point is forwarded and left alone afterwards.
+func (c *Collector) StreamPluginMetrics(testID, executionID, pluginName string, stream <-chan models.MetricPoint) {
+	go func() {
+		var forwarded, failed int64
+		for point := range stream {
+			point.TestID = testID
+			if point.Tags == nil {
+				point.Tags = map[string]string{"execution_id": executionID}
+			} else if point.Tags["execution_id"] != executionID {
+				point.Tags["execution_id"] = executionID
+			}
+			if point.Source == "" {
+				point.Source = pluginName
+			}
+			if point.Timestamp.IsZero() {
+				point.Timestamp = time.Now()
+			}
+
+			failed += int64(c.forward(point))
+			forwarded++
+		}
+
+		c.logger.Debug("Plugin metrics stream closed",
+			zap.String("test_id", testID),
+			zap.String("execution_id", executionID),
+			zap.String("plugin", pluginName),
+			zap.Int64("forwarded", forwarded),
+			zap.Int64("sink_errors", failed))
+	}()
+}
+
+// WritePoints forwards points that were collected elsewhere, such as by remote
+// agents, to every sink. It returns the number of sink writes that failed.
+func (c *Collector) WritePoints(points []models.MetricPoint) int {
+	failed := 0
+	for _, point := range points {
+		failed += c.forward(point)
+	}
+	return failed
+}
+
+// forward writes a point to every sink, returning how many writes failed
+func (c *Collector) forward(point models.MetricPoint) int {
+	c.mu.RLock()
+	sinks := c.sinks
+	c.mu.RUnlock()
+
+	failed := 0
+	for _, sink := range sinks {
+		if err := sink.WriteMetricPoint(point); err != nil {
+			failed++
+		}
+	}
+	return failed
 }
 
-// StopCollection stops metrics collection for a test
-func (c *Collector) StopCollection(testID string) {
-	c.logger.Info("Stopping metrics collection", zap.String("test_id", testID))
-	// Additional cleanup logic could be added here
+// StartCollection starts metrics collection for a test execution: temperature and
+// fan sensors, and power draw when RAPL energy counters are available
+func (c *Collector) StartCollection(ctx context.Context, testID, executionID string) {
+	c.logger.Info("Starting metrics collection", zap.String("test_id", testID), zap.String("execution_id", executionID))
+
+	sensorCtx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	if c.sensors == nil {
+		c.sensors = make(map[string]context.CancelFunc)
+	}
+	c.sensors[executionID] = cancel
+	c.mu.Unlock()
+	go c.sampleSensors(sensorCtx, testID, executionID)
+
+	meter, err := NewPowerMeter()
+	if err != nil {
+		c.logger.Debug("Power metrics unavailable", zap.Error(err))
+		return
+	}
+
+	sampler := newEnergySampler(ctx, c, meter, testID, executionID)
+	c.mu.Lock()
+	if c.energy == nil {
+		c.energy = make(map[string]*energySampler)
+	}
+	c.energy[executionID] = sampler
+	c.mu.Unlock()
+}
+
+// StopCollection stops metrics collection for a test execution
+func (c *Collector) StopCollection(executionID string) {
+	c.logger.Info("Stopping metrics collection", zap.String("execution_id", executionID))
+
+	c.mu.Lock()
+	sampler := c.energy[executionID]
+	stopSensors := c.sensors[executionID]
+	delete(c.sensors, executionID)
+	c.mu.Unlock()
+	if stopSensors != nil {
+		stopSensors()
+	}
+	if sampler != nil {
+		sampler.stop()
+	}
+}
+
+// EnergyUsage returns the energy consumed while an execution ran, once its collection
+// has been stopped, or nil if power couldn't be measured. The result is only kept
+// until it has been retrieved.
+func (c *Collector) EnergyUsage(executionID string) *models.EnergyUsage {
+	c.mu.Lock()
+	sampler := c.energy[executionID]
+	delete(c.energy, executionID)
+	c.mu.Unlock()
+
+	if sampler == nil {
+		return nil
+	}
+	sampler.stop()
+	return sampler.usage()
 }
@@ -0,0 +1,311 @@
+package metrics
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/net"
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// recordingSink keeps the points written to it, or fails every write
+type recordingSink struct {
+	mu     sync.Mutex
+	points []models.MetricPoint
+	fail   bool
+}
+
+func (s *recordingSink) WriteMetricPoint(point models.MetricPoint) error {
+	if s.fail {
+		return errors.New("sink unavailable")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.points = append(s.points, point)
+	return nil
+}
+
+func (s *recordingSink) written() []models.MetricPoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.MetricPoint(nil), s.points...)
+}
+
+func TestStreamPluginMetrics(t *testing.T) {
+	collector := NewCollector(zap.NewNop())
+	sink := &recordingSink{}
+	collector.AddSink(&recordingSink{fail: true})
+	collector.AddSink(sink)
+
+	sent := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		in   models.MetricPoint
+		want models.MetricPoint
+	}{
+		{
+			"filled in",
+			models.MetricPoint{Type: "latency", Fields: map[string]interface{}{"us": 12}},
+			models.MetricPoint{TestID: "t1", Source: "io-stress", Type: "latency", Tags: map[string]string{"execution_id": "e1"}, Fields: map[string]interface{}{"us": 12}},
+		},
+		{
+			"source, tags and timestamp kept",
+			models.MetricPoint{TestID: "other", Source: "fio", Type: "latency", Timestamp: sent, Tags: map[string]string{"op": "read"}},
+			models.MetricPoint{TestID: "t1", Source: "fio", Type: "latency", Timestamp: sent, Tags: map[string]string{"op": "read", "execution_id": "e1"}},
+		},
+	}
+
+	stream := make(chan models.MetricPoint, len(tests))
+	for _, tt := range tests {
+		stream <- tt.in
+	}
+	close(stream)
+	collector.StreamPluginMetrics("t1", "e1", "io-stress", stream)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.written()) < len(tests) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	written := sink.written()
+	if len(written) != len(tests) {
+		t.Fatalf("a failing sink kept points from the others: %d written, want %d", len(written), len(tests))
+	}
+	for i, tt := range tests {
+		got := written[i]
+		if tt.want.Timestamp.IsZero() {
+			if got.Timestamp.IsZero() {
+				t.Errorf("%s: no timestamp", tt.name)
+			}
+			got.Timestamp = time.Time{}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStreamPluginMetricsSharedTags(t *testing.T) {
+	collector := NewCollector(zap.NewNop())
+	sink := &recordingSink{}
+	collector.AddSink(sink)
+
+	// Plugins may publish points sharing one tags map, which sinks read concurrently
+	tags := map[string]string{"operation": "read"}
+	stream := make(chan models.MetricPoint, 3)
+	for i := 0; i < cap(stream); i++ {
+		stream <- models.MetricPoint{Type: "latency", Tags: tags, Fields: map[string]interface{}{"us": i}}
+	}
+	close(stream)
+	collector.StreamPluginMetrics("t1", "e1", "io-stress", stream)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sink.written()) < cap(stream) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	for _, point := range sink.written() {
+		if point.Tags["execution_id"] != "e1" || point.Tags["operation"] != "read" {
+			t.Errorf("tags = %v", point.Tags)
+		}
+	}
+	if len(sink.written()) != cap(stream) {
+		t.Errorf("%d points written, want %d", len(sink.written()), cap(stream))
+	}
+}
+
+func TestWritePointsCountsFailures(t *testing.T) {
+	collector := NewCollector(zap.NewNop())
+	if failed := collector.WritePoints([]models.MetricPoint{{Type: "cpu"}}); failed != 0 {
+		t.Errorf("without sinks: %d failures", failed)
+	}
+
+	collector.AddSink(&recordingSink{fail: true})
+	collector.AddSink(&recordingSink{})
+	if failed := collector.WritePoints([]models.MetricPoint{{Type: "cpu"}, {Type: "memory"}}); failed != 2 {
+		t.Errorf("%d failures, want one per point for the failing sink", failed)
+	}
+}
+
+func TestDiskDeviceRates(t *testing.T) {
+	defer func(partitions func(bool) ([]disk.PartitionStat, error)) { diskPartitions = partitions }(diskPartitions)
+	defer func(usage func(string) (*disk.UsageStat, error)) { diskUsage = usage }(diskUsage)
+	defer func(counters func(...string) (map[string]disk.IOCountersStat, error)) { diskIOCounters = counters }(diskIOCounters)
+	diskUsage = func(path string) (*disk.UsageStat, error) {
+		return &disk.UsageStat{Path: path, Total: 100, Used: 25, UsedPercent: 25}, nil
+	}
+
+	sda := disk.IOCountersStat{Name: "sda", ReadBytes: 1000, WriteBytes: 2000, ReadCount: 10, WriteCount: 20, ReadTime: 30, WriteTime: 60}
+	tests := []struct {
+		name        string
+		devices     [2][]string // mounted in each sample
+		first, next map[string]disk.IOCountersStat
+		want        map[string]models.DiskDeviceMetrics // rates and latency by device
+	}{
+		{
+			name:    "deltas over the interval",
+			devices: [2][]string{{"sda"}, {"sda"}},
+			first:   map[string]disk.IOCountersStat{"sda": sda},
+			next:    map[string]disk.IOCountersStat{"sda": {ReadBytes: 5000, WriteBytes: 10000, ReadCount: 30, WriteCount: 40, ReadTime: 90, WriteTime: 120}},
+			want:    map[string]models.DiskDeviceMetrics{"sda": {ReadBytesPerSec: 2000, WriteBytesPerSec: 4000, ReadOpsPerSec: 10, WriteOpsPerSec: 10, LatencyMs: 3}},
+		},
+		{
+			name:    "idle device",
+			devices: [2][]string{{"sda"}, {"sda"}},
+			first:   map[string]disk.IOCountersStat{"sda": sda},
+			next:    map[string]disk.IOCountersStat{"sda": sda},
+			want:    map[string]models.DiskDeviceMetrics{"sda": {}},
+		},
+		{
+			name:    "device appearing has no rates until its next sample",
+			devices: [2][]string{{"sda"}, {"sda", "sdb"}},
+			first:   map[string]disk.IOCountersStat{"sda": sda},
+			next:    map[string]disk.IOCountersStat{"sda": sda, "sdb": {ReadBytes: 1 << 30, ReadCount: 1000}},
+			want:    map[string]models.DiskDeviceMetrics{"sda": {}, "sdb": {}},
+		},
+		{
+			name:    "device disappearing is no longer reported",
+			devices: [2][]string{{"sda", "sdb"}, {"sda"}},
+			first:   map[string]disk.IOCountersStat{"sda": sda, "sdb": {ReadBytes: 1 << 30}},
+			next:    map[string]disk.IOCountersStat{"sda": sda},
+			want:    map[string]models.DiskDeviceMetrics{"sda": {}},
+		},
+		{
+			name:    "counters reset",
+			devices: [2][]string{{"sda"}, {"sda"}},
+			first:   map[string]disk.IOCountersStat{"sda": sda},
+			next:    map[string]disk.IOCountersStat{"sda": {ReadBytes: 10, WriteBytes: 2100, ReadCount: 1, WriteCount: 22, ReadTime: 1, WriteTime: 64}},
+			want:    map[string]models.DiskDeviceMetrics{"sda": {WriteBytesPerSec: 50, WriteOpsPerSec: 1, LatencyMs: 2}},
+		},
+		{
+			name:    "counters wrapping",
+			devices: [2][]string{{"sda"}, {"sda"}},
+			first:   map[string]disk.IOCountersStat{"sda": {ReadBytes: math.MaxUint64 - 10, ReadCount: math.MaxUint32}},
+			next:    map[string]disk.IOCountersStat{"sda": {ReadBytes: 10, ReadCount: 5}},
+			want:    map[string]models.DiskDeviceMetrics{"sda": {}},
+		},
+		{
+			name:    "jump beyond an int64",
+			devices: [2][]string{{"sda"}, {"sda"}},
+			first:   map[string]disk.IOCountersStat{"sda": {}},
+			next:    map[string]disk.IOCountersStat{"sda": {ReadBytes: math.MaxUint64}},
+			want:    map[string]models.DiskDeviceMetrics{"sda": {ReadBytesPerSec: math.MaxInt64}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := NewCollector(zap.NewNop())
+			var devices []models.DiskDeviceMetrics
+			for sample, counters := range []map[string]disk.IOCountersStat{tt.first, tt.next} {
+				mounted := tt.devices[sample]
+				diskPartitions = func(bool) ([]disk.PartitionStat, error) {
+					partitions := make([]disk.PartitionStat, len(mounted))
+					for i, name := range mounted {
+						partitions[i] = disk.PartitionStat{Device: "/dev/" + name, Mountpoint: "/mnt/" + name, Fstype: "ext4"}
+					}
+					return partitions, nil
+				}
+				diskIOCounters = func(...string) (map[string]disk.IOCountersStat, error) { return counters, nil }
+				// The first sample has nothing to compare against; the next is two seconds later
+				devices = collector.collectDiskDevices(float64(sample * 2))
+			}
+
+			if len(devices) != len(tt.want) {
+				t.Fatalf("got %d devices %+v, want %d", len(devices), devices, len(tt.want))
+			}
+			for _, device := range devices {
+				want, ok := tt.want[device.Device[len("/dev/"):]]
+				if !ok {
+					t.Errorf("unexpected device %s", device.Device)
+					continue
+				}
+				if device.ReadBytesPerSec < 0 || device.WriteBytesPerSec < 0 || device.ReadOpsPerSec < 0 || device.WriteOpsPerSec < 0 || device.LatencyMs < 0 {
+					t.Errorf("%s: negative rate in %+v", device.Device, device)
+				}
+				got := models.DiskDeviceMetrics{
+					ReadBytesPerSec:  device.ReadBytesPerSec,
+					WriteBytesPerSec: device.WriteBytesPerSec,
+					ReadOpsPerSec:    device.ReadOpsPerSec,
+					WriteOpsPerSec:   device.WriteOpsPerSec,
+					LatencyMs:        device.LatencyMs,
+				}
+				if got != want {
+					t.Errorf("%s: rates %+v, want %+v", device.Device, got, want)
+				}
+				if device.TotalBytes != 100 || device.UsagePercent != 25 {
+					t.Errorf("%s: usage %+v, want the file system's", device.Device, device)
+				}
+			}
+		})
+	}
+}
+
+func TestNetworkInterfaceRates(t *testing.T) {
+	defer func(counters func(bool) ([]net.IOCountersStat, error)) { netIOCounters = counters }(netIOCounters)
+
+	eth0 := net.IOCountersStat{Name: "eth0", BytesRecv: 1000, BytesSent: 4000, PacketsRecv: 10, PacketsSent: 40, Errin: 1, Errout: 2}
+	lo := net.IOCountersStat{Name: "lo", BytesRecv: 1000, BytesSent: 1000}
+	tests := []struct {
+		name        string
+		first, next []net.IOCountersStat
+		want        map[string]models.NetworkInterfaceMetrics
+	}{
+		{
+			name:  "deltas over the interval, loopback left out",
+			first: []net.IOCountersStat{lo, eth0},
+			next:  []net.IOCountersStat{{Name: "lo", BytesRecv: 9000}, {Name: "eth0", BytesRecv: 3000, BytesSent: 12000, PacketsRecv: 14, PacketsSent: 60, Errin: 4, Errout: 2}},
+			want:  map[string]models.NetworkInterfaceMetrics{"eth0": {Name: "eth0", RxBytesPerSec: 1000, TxBytesPerSec: 4000, RxPacketsPerSec: 2, TxPacketsPerSec: 10, RxErrors: 3}},
+		},
+		{
+			name:  "interface appearing has no rates until its next sample",
+			first: []net.IOCountersStat{eth0},
+			next:  []net.IOCountersStat{eth0, {Name: "wg0", BytesRecv: 1 << 30, Errin: 7}},
+			want:  map[string]models.NetworkInterfaceMetrics{"eth0": {Name: "eth0"}, "wg0": {Name: "wg0"}},
+		},
+		{
+			name:  "interface disappearing is no longer reported",
+			first: []net.IOCountersStat{eth0, {Name: "wg0", BytesRecv: 1 << 30}},
+			next:  []net.IOCountersStat{eth0},
+			want:  map[string]models.NetworkInterfaceMetrics{"eth0": {Name: "eth0"}},
+		},
+		{
+			name:  "counters reset when the interface is recreated",
+			first: []net.IOCountersStat{eth0},
+			next:  []net.IOCountersStat{{Name: "eth0", BytesRecv: 100, BytesSent: 4200, PacketsRecv: 1, PacketsSent: 42}},
+			want:  map[string]models.NetworkInterfaceMetrics{"eth0": {Name: "eth0", TxBytesPerSec: 100, TxPacketsPerSec: 1}},
+		},
+		{
+			name:  "32-bit counters wrapping",
+			first: []net.IOCountersStat{{Name: "eth0", BytesRecv: math.MaxUint32 - 100, PacketsRecv: math.MaxUint32, Errin: math.MaxUint32}},
+			next:  []net.IOCountersStat{{Name: "eth0", BytesRecv: 100, PacketsRecv: 3, Errin: 0}},
+			want:  map[string]models.NetworkInterfaceMetrics{"eth0": {Name: "eth0"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := NewCollector(zap.NewNop())
+			var interfaces []models.NetworkInterfaceMetrics
+			for sample, counters := range [][]net.IOCountersStat{tt.first, tt.next} {
+				netIOCounters = func(bool) ([]net.IOCountersStat, error) { return counters, nil }
+				interfaces = collector.collectNetworkInterfaces(float64(sample * 2))
+			}
+
+			if len(interfaces) != len(tt.want) {
+				t.Fatalf("got %d interfaces %+v, want %d", len(interfaces), interfaces, len(tt.want))
+			}
+			for _, nic := range interfaces {
+				if nic.RxBytesPerSec < 0 || nic.TxBytesPerSec < 0 || nic.RxPacketsPerSec < 0 || nic.TxPacketsPerSec < 0 || nic.RxErrors < 0 || nic.TxErrors < 0 {
+					t.Errorf("%s: negative rate in %+v", nic.Name, nic)
+				}
+				if want, ok := tt.want[nic.Name]; !ok || nic != want {
+					t.Errorf("%s: %+v, want %+v", nic.Name, nic, want)
+				}
+			}
+		})
+	}
+}
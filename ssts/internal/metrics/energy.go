@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// powerSampleInterval is how often power draw is sampled during an execution
+const powerSampleInterval = time.Second
+
+// energySampler samples power draw for one execution, writing every sample to the
+// collector's sinks and accumulating the energy consumed
+type energySampler struct {
+	collector   *Collector
+	meter       *PowerMeter
+	testID      string
+	executionID string
+	started     time.Time
+	cancel      context.CancelFunc
+	done        chan struct{}
+	stopOnce    sync.Once
+
+	// Only touched by run until done is closed
+	peakWatts  float64
+	ended      time.Time
+	tags       map[string]string
+	domainTags map[string]map[string]string // keyed by domain
+	points     []models.MetricPoint         // reused by every sample
+}
+
+func newEnergySampler(ctx context.Context, collector *Collector, meter *PowerMeter, testID, executionID string) *energySampler {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &energySampler{
+		collector:   collector,
+		meter:       meter,
+		testID:      testID,
+		executionID: executionID,
+		started:     time.Now(),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		tags:        map[string]string{"execution_id": executionID},
+		domainTags:  make(map[string]map[string]string),
+	}
+	go s.run(ctx)
+	return s
+}
+
+func (s *energySampler) run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(powerSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Take a last sample so energy used since the previous tick is counted
+			s.sample()
+			s.ended = time.Now()
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+func (s *energySampler) sample() {
+	power := s.meter.Sample()
+	totalWatts := power.PackageWatts + power.DRAMWatts
+	if totalWatts > s.peakWatts {
+		s.peakWatts = totalWatts
+	}
+
+	now := time.Now()
+	points := append(s.points[:0], models.MetricPoint{
+		Timestamp: now,
+		TestID:    s.testID,
+		Source:    s.meter.Source(),
+		Type:      "power",
+		Tags:      s.tags,
+		Fields: models.PowerFields{
+			PackageWatts: power.PackageWatts,
+			CoreWatts:    power.CoreWatts,
+			DRAMWatts:    power.DRAMWatts,
+			TotalWatts:   totalWatts,
+			EnergyJoules: s.meter.TotalJoules(),
+		}.Fields(),
+	})
+	for _, domain := range power.Domains {
+		tags, ok := s.domainTags[domain.Name]
+		if !ok {
+			tags = map[string]string{"execution_id": s.executionID, "domain": domain.Name}
+			s.domainTags[domain.Name] = tags
+		}
+		points = append(points, models.MetricPoint{
+			Timestamp: now,
+			TestID:    s.testID,
+			Source:    s.meter.Source(),
+			Type:      "power_domain",
+			Tags:      tags,
+			Fields:    models.PowerDomainFields{Watts: domain.Watts, Joules: domain.Joules}.Fields(),
+		})
+	}
+	s.points = points
+
+	s.collector.mu.RLock()
+	sinks := s.collector.sinks
+	s.collector.mu.RUnlock()
+
+	for _, sink := range sinks {
+		for _, point := range points {
+			sink.WriteMetricPoint(point)
+		}
+	}
+}
+
+// stop ends sampling and waits for the final sample
+func (s *energySampler) stop() {
+	s.stopOnce.Do(func() {
+		s.cancel()
+		<-s.done
+	})
+}
+
+// usage returns the energy consumed between start and stop; call after stop
+func (s *energySampler) usage() *models.EnergyUsage {
+	joules := s.meter.TotalJoules()
+	usage := &models.EnergyUsage{
+		Source:    s.meter.Source(),
+		Joules:    joules,
+		WattHours: joules / 3600,
+		PeakWatts: s.peakWatts,
+		Domains:   s.meter.DomainJoules(),
+	}
+	if elapsed := s.ended.Sub(s.started).Seconds(); elapsed > 0 {
+		usage.AvgWatts = joules / elapsed
+	}
+	return usage
+}
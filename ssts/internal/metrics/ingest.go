@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// IngestLimits bounds the timestamps accepted for points pushed by remote agents
+type IngestLimits struct {
+	MaxClockSkew time.Duration // how far in the future a point may be, for agents with fast clocks
+	MaxAge       time.Duration // how old a point may be; 0 accepts any age
+}
+
+// ValidateIngestedPoint checks a point pushed by a remote agent is complete and
+// only has fields the metric stores can hold: numbers, strings and booleans
+func ValidateIngestedPoint(point models.MetricPoint, now time.Time, limits IngestLimits) error {
+	switch {
+	case point.Timestamp.IsZero():
+		return errors.New("timestamp is required")
+	case point.Timestamp.After(now.Add(limits.MaxClockSkew)):
+		return fmt.Errorf("timestamp %s is in the future", point.Timestamp.Format(time.RFC3339))
+	case limits.MaxAge > 0 && point.Timestamp.Before(now.Add(-limits.MaxAge)):
+		return fmt.Errorf("timestamp %s is older than %s", point.Timestamp.Format(time.RFC3339), limits.MaxAge)
+	case point.Source == "":
+		return errors.New("source is required")
+	case point.Type == "":
+		return errors.New("type is required")
+	case len(point.Fields) == 0:
+		return errors.New("at least one field is required")
+	}
+
+	for name, value := range point.Fields {
+		if name == "" {
+			return errors.New("field names must not be empty")
+		}
+		switch value.(type) {
+		case float64, float32, int, int64, int32, uint64, uint32, bool, string:
+		default:
+			return fmt.Errorf("field %s: unsupported value %v", name, value)
+		}
+	}
+	for key := range point.Tags {
+		if key == "" {
+			return errors.New("tag names must not be empty")
+		}
+	}
+
+	return nil
+}
+
+// Deduplicator remembers the points ingested within a window, so batches that
+// agents retry after a timeout aren't written twice
+type Deduplicator struct {
+	window    time.Duration
+	mu        sync.Mutex
+	seen      map[string]time.Time // point key to when it was first seen
+	lastSweep time.Time
+}
+
+// NewDeduplicator creates a deduplicator remembering points for window
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	return &Deduplicator{
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// Seen reports whether the point was already seen within the window, recording it if not
+func (d *Deduplicator) Seen(point models.MetricPoint, now time.Time) bool {
+	key := pointKey(point)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.lastSweep) >= d.window/10 {
+		for k, first := range d.seen {
+			if now.Sub(first) >= d.window {
+				delete(d.seen, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	if first, ok := d.seen[key]; ok && now.Sub(first) < d.window {
+		return true
+	}
+	d.seen[key] = now
+	return false
+}
+
+// pointKey identifies a point by its content. json.Marshal sorts map keys, so
+// the same point always encodes the same way.
+func pointKey(point models.MetricPoint) string {
+	point.Timestamp = point.Timestamp.UTC()
+	data, _ := json.Marshal(point)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
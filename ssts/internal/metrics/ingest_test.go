@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestValidateIngestedPoint(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	limits := IngestLimits{MaxClockSkew: time.Minute, MaxAge: time.Hour}
+	valid := models.MetricPoint{
+		Timestamp: now.Add(-time.Minute),
+		Source:    "node-exporter",
+		Type:      "cpu",
+		Tags:      map[string]string{"host": "db1"},
+		Fields:    map[string]interface{}{"usage": 42.5, "throttled": false, "governor": "performance"},
+	}
+	if err := ValidateIngestedPoint(valid, now, limits); err != nil {
+		t.Fatalf("valid point rejected: %v", err)
+	}
+
+	cases := map[string]func(p *models.MetricPoint){
+		"no timestamp": func(p *models.MetricPoint) { p.Timestamp = time.Time{} },
+		"future":       func(p *models.MetricPoint) { p.Timestamp = now.Add(2 * time.Minute) },
+		"too old":      func(p *models.MetricPoint) { p.Timestamp = now.Add(-2 * time.Hour) },
+		"no source":    func(p *models.MetricPoint) { p.Source = "" },
+		"no type":      func(p *models.MetricPoint) { p.Type = "" },
+		"no fields":    func(p *models.MetricPoint) { p.Fields = nil },
+		"nested field": func(p *models.MetricPoint) { p.Fields = map[string]interface{}{"cores": []interface{}{1.0}} },
+		"null field":   func(p *models.MetricPoint) { p.Fields = map[string]interface{}{"usage": nil} },
+		"empty tag":    func(p *models.MetricPoint) { p.Tags = map[string]string{"": "x"} },
+	}
+	for name, mutate := range cases {
+		point := valid
+		mutate(&point)
+		if err := ValidateIngestedPoint(point, now, limits); err == nil {
+			t.Errorf("%s: point accepted", name)
+		}
+	}
+}
+
+func TestDeduplicator(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	d := NewDeduplicator(10 * time.Minute)
+	point := models.MetricPoint{
+		Timestamp: now,
+		Source:    "agent",
+		Type:      "cpu",
+		Tags:      map[string]string{"agent_id": "a1", "host": "db1"},
+		Fields:    map[string]interface{}{"usage": 42.5},
+	}
+
+	if d.Seen(point, now) {
+		t.Fatal("first point reported as a duplicate")
+	}
+
+	retried := point
+	retried.Timestamp = now.In(time.FixedZone("EST", -5*3600))
+	retried.Tags = map[string]string{"host": "db1", "agent_id": "a1"}
+	if !d.Seen(retried, now.Add(time.Minute)) {
+		t.Error("retried point not reported as a duplicate")
+	}
+
+	other := point
+	other.Tags = map[string]string{"agent_id": "a2", "host": "db1"}
+	if d.Seen(other, now.Add(time.Minute)) {
+		t.Error("point from another agent reported as a duplicate")
+	}
+
+	if d.Seen(point, now.Add(11*time.Minute)) {
+		t.Error("point reported as a duplicate after the window")
+	}
+}
@@ -0,0 +1,294 @@
+package metrics
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// Ways of detecting resource limits
+const (
+	LimitsAuto = "auto" // the cgroup's limits, where it has any
+	LimitsHost = "host" // the host's totals, ignoring cgroup limits
+)
+
+// Where resource limits came from
+const (
+	LimitsSourceHost     = "host"
+	LimitsSourceCgroupV1 = "cgroup_v1"
+	LimitsSourceCgroupV2 = "cgroup_v2"
+	LimitsSourceConfig   = "config"
+)
+
+// cgroupFS is where cgroup hierarchies are mounted; selfCgroup lists the
+// cgroups of this process
+var (
+	cgroupFS   = "/sys/fs/cgroup"
+	selfCgroup = "/proc/self/cgroup"
+)
+
+// ResourceLimits are what CPU and memory usage is measured against: the host's
+// totals, or inside a container the CPU quota and memory limit of its cgroup
+type ResourceLimits struct {
+	Source      string  `json:"source"`                 // host, cgroup_v1, cgroup_v2 or config
+	HostCPUs    int     `json:"host_cpus"`              // logical CPUs of the host
+	CPUs        float64 `json:"cpus,omitempty"`         // CPU quota in CPUs; 0 for all of the host's
+	MemoryBytes uint64  `json:"memory_bytes,omitempty"` // memory limit; 0 for the host's total
+
+	cpuUsage  string // files usage is accounted in, when limits are the cgroup's
+	memoryDir string
+	v2        bool
+}
+
+// DetectResourceLimits works out what usage is measured against. With detect
+// set to auto, the cgroup's cpu.max and memory.max (cfs_quota_us and
+// limit_in_bytes under cgroup v1) are used where they are below the host's
+// totals. Nonzero cpus and memoryBytes override what is detected.
+func DetectResourceLimits(detect string, cpus float64, memoryBytes uint64) ResourceLimits {
+	limits := ResourceLimits{Source: LimitsSourceHost, HostCPUs: hostCPUs()}
+	if detect != LimitsHost {
+		limits.detectCgroup()
+	}
+
+	if cpus > 0 || memoryBytes > 0 {
+		limits.Source = LimitsSourceConfig
+		if cpus > 0 {
+			limits.CPUs = cpus
+		}
+		if memoryBytes > 0 {
+			limits.MemoryBytes = memoryBytes
+		}
+	}
+	return limits
+}
+
+// hostCPUs counts the host's logical CPUs
+func hostCPUs() int {
+	if count, err := cpu.Counts(true); err == nil && count > 0 {
+		return count
+	}
+	return runtime.NumCPU()
+}
+
+// detectCgroup reads the limits of this process's cgroup
+func (l *ResourceLimits) detectCgroup() {
+	data, err := os.ReadFile(selfCgroup)
+	if err != nil {
+		return
+	}
+
+	// Lines are hierarchy-ID:controllers:path; cgroup v2 has the single line 0::path
+	paths := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "0" && fields[1] == "" {
+			paths[""] = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			paths[controller] = fields[2]
+		}
+	}
+
+	var cpus float64
+	var memory uint64
+	if path, ok := paths[""]; ok && fileExists(filepath.Join(cgroupFS, "cgroup.controllers")) {
+		l.v2 = true
+		cpuDir := cgroupDir(cgroupFS, path, "cpu.max")
+		l.cpuUsage = filepath.Join(cpuDir, "cpu.stat")
+		l.memoryDir = cgroupDir(cgroupFS, path, "memory.max")
+		cpus = readCPUMax(filepath.Join(cpuDir, "cpu.max"))
+		memory = readLimit(filepath.Join(l.memoryDir, "memory.max"))
+	} else {
+		cpuDir := cgroupDir(filepath.Join(cgroupFS, "cpu"), paths["cpu"], "cpu.cfs_quota_us")
+		l.cpuUsage = filepath.Join(cgroupDir(filepath.Join(cgroupFS, "cpuacct"), paths["cpuacct"], "cpuacct.usage"), "cpuacct.usage")
+		l.memoryDir = cgroupDir(filepath.Join(cgroupFS, "memory"), paths["memory"], "memory.limit_in_bytes")
+		quota := readInt(filepath.Join(cpuDir, "cpu.cfs_quota_us"))
+		period := readInt(filepath.Join(cpuDir, "cpu.cfs_period_us"))
+		if quota > 0 && period > 0 {
+			cpus = float64(quota) / float64(period)
+		}
+		memory = readLimit(filepath.Join(l.memoryDir, "memory.limit_in_bytes"))
+	}
+
+	// Limits at or above the host's totals don't limit anything; v1 reports
+	// an unlimited memory limit as a huge number
+	if cpus >= float64(l.HostCPUs) {
+		cpus = 0
+	}
+	if vm, err := mem.VirtualMemory(); err == nil && memory >= vm.Total {
+		memory = 0
+	}
+	if cpus == 0 && memory == 0 {
+		l.cpuUsage, l.memoryDir = "", ""
+		return
+	}
+
+	l.CPUs, l.MemoryBytes = cpus, memory
+	l.Source = LimitsSourceCgroupV1
+	if l.v2 {
+		l.Source = LimitsSourceCgroupV2
+	}
+}
+
+// cgroupDir returns the directory of a cgroup holding the file. Inside a cgroup
+// namespace the cgroup is mounted at the root, whatever path is listed.
+func cgroupDir(mount, path, file string) string {
+	if dir := filepath.Join(mount, path); fileExists(filepath.Join(dir, file)) {
+		return dir
+	}
+	return mount
+}
+
+// readCPUMax reads cgroup v2's cpu.max, "max 100000" or "<quota> <period>", in CPUs
+func readCPUMax(path string) float64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0
+	}
+	quota, err1 := strconv.ParseFloat(fields[0], 64)
+	period, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || quota <= 0 || period <= 0 {
+		return 0
+	}
+	return quota / period
+}
+
+// readLimit reads a byte limit, 0 for "max"
+func readLimit(path string) uint64 {
+	if value := readInt(path); value > 0 {
+		return uint64(value)
+	}
+	return 0
+}
+
+// readInt reads a file holding a single integer, 0 if it doesn't
+func readInt(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// readStat reads a field of a cgroup stat file such as cpu.stat or memory.stat
+func readStat(path, field string) (uint64, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == field {
+			value, err := strconv.ParseUint(fields[1], 10, 64)
+			return value, err == nil
+		}
+	}
+	return 0, false
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// cpuTime reads the CPU time the cgroup has used, if limits are the cgroup's
+func (l ResourceLimits) cpuTime() (time.Duration, bool) {
+	if l.cpuUsage == "" {
+		return 0, false
+	}
+	if l.v2 {
+		usec, ok := readStat(l.cpuUsage, "usage_usec")
+		return time.Duration(usec) * time.Microsecond, ok
+	}
+	nsec := readInt(l.cpuUsage)
+	return time.Duration(nsec), nsec > 0
+}
+
+// Memory returns the memory usage is measured against and how much of it is
+// used, given the host's. Within a cgroup, used is the cgroup's usage less
+// inactive page cache the kernel reclaims before hitting the limit, as docker
+// stats reports it.
+func (l ResourceLimits) Memory(hostTotal, hostUsed uint64) (total, used uint64) {
+	if l.MemoryBytes == 0 {
+		return hostTotal, hostUsed
+	}
+	total, used = l.MemoryBytes, hostUsed
+
+	if l.memoryDir != "" {
+		current, statFile, inactive := "memory.current", "memory.stat", "inactive_file"
+		if !l.v2 {
+			current, inactive = "memory.usage_in_bytes", "total_inactive_file"
+		}
+		if usage := readInt(filepath.Join(l.memoryDir, current)); usage > 0 {
+			used = uint64(usage)
+			if cache, ok := readStat(filepath.Join(l.memoryDir, statFile), inactive); ok && cache < used {
+				used -= cache
+			}
+		}
+	}
+	return total, used
+}
+
+// CPUMeter turns CPU usage into a percentage of the CPUs allowed. Each caller
+// sampling usage on its own schedule needs a meter of its own.
+type CPUMeter struct {
+	limits ResourceLimits
+
+	mu     sync.Mutex
+	last   time.Duration // cgroup CPU time at the previous reading
+	lastAt time.Time
+}
+
+// NewCPUMeter creates a meter of usage against limits
+func NewCPUMeter(limits ResourceLimits) *CPUMeter {
+	return &CPUMeter{limits: limits}
+}
+
+// Percent returns CPU usage as a percentage of the CPUs allowed, given the host's
+// usage as a percentage of all its CPUs. Within a cgroup it is the cgroup's CPU
+// time since the previous reading; otherwise, and on the first reading, the
+// host's usage rescaled to the quota.
+func (m *CPUMeter) Percent(hostPercent float64) float64 {
+	if m.limits.CPUs <= 0 {
+		return hostPercent
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	percent := hostPercent * float64(m.limits.HostCPUs) / m.limits.CPUs
+	if used, ok := m.limits.cpuTime(); ok {
+		if !m.lastAt.IsZero() && used >= m.last && now.After(m.lastAt) {
+			percent = float64(used-m.last) / float64(now.Sub(m.lastAt)) / m.limits.CPUs * 100
+		}
+		m.last, m.lastAt = used, now
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	return percent
+}
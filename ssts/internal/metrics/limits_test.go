@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeCgroup points cgroup reads at files under a temporary root
+func fakeCgroup(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	previousFS, previousSelf := cgroupFS, selfCgroup
+	cgroupFS, selfCgroup = filepath.Join(root, "sys/fs/cgroup"), filepath.Join(root, "proc/self/cgroup")
+	t.Cleanup(func() { cgroupFS, selfCgroup = previousFS, previousSelf })
+
+	for file, content := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestDetectResourceLimitsCgroupV2(t *testing.T) {
+	root := fakeCgroup(t, map[string]string{
+		"proc/self/cgroup":                        "0::/docker/abc",
+		"sys/fs/cgroup/cgroup.controllers":        "cpu memory",
+		"sys/fs/cgroup/docker/abc/cpu.max":        "50000 100000",
+		"sys/fs/cgroup/docker/abc/cpu.stat":       "usage_usec 1000000\nuser_usec 800000",
+		"sys/fs/cgroup/docker/abc/memory.max":     "67108864",
+		"sys/fs/cgroup/docker/abc/memory.current": "50331648",
+		"sys/fs/cgroup/docker/abc/memory.stat":    "anon 33554432\ninactive_file 16777216",
+	})
+
+	limits := DetectResourceLimits(LimitsAuto, 0, 0)
+	if limits.Source != LimitsSourceCgroupV2 || limits.CPUs != 0.5 || limits.MemoryBytes != 64<<20 {
+		t.Fatalf("limits = %+v, want half a CPU and 64MiB from cgroup v2", limits)
+	}
+
+	// Inactive page cache is reclaimed before the limit is hit, so it isn't used
+	total, used := limits.Memory(16<<30, 8<<30)
+	if total != 64<<20 || used != 32<<20 {
+		t.Errorf("memory = %d of %d, want 32MiB of 64MiB", used, total)
+	}
+
+	// 25ms of CPU time in 100ms is half of a half-CPU quota
+	meter := NewCPUMeter(limits)
+	meter.Percent(0)
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(root, "sys/fs/cgroup/docker/abc/cpu.stat"), []byte("usage_usec 1025000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if percent := meter.Percent(0); percent <= 20 || percent > 50 {
+		t.Errorf("CPU = %.1f%%, want about 50%% of the quota for 25ms of CPU time in 100ms", percent)
+	}
+
+	if host := DetectResourceLimits(LimitsHost, 0, 0); host.Source != LimitsSourceHost || host.CPUs != 0 || host.MemoryBytes != 0 {
+		t.Errorf("limits ignoring the cgroup = %+v, want the host's", host)
+	}
+}
+
+func TestDetectResourceLimitsCgroupV1(t *testing.T) {
+	fakeCgroup(t, map[string]string{
+		"proc/self/cgroup":                           "4:memory:/\n3:cpu,cpuacct:/\n1:name=systemd:/",
+		"sys/fs/cgroup/cpu/cpu.cfs_quota_us":         "25000",
+		"sys/fs/cgroup/cpu/cpu.cfs_period_us":        "100000",
+		"sys/fs/cgroup/cpuacct/cpuacct.usage":        "5000000000",
+		"sys/fs/cgroup/memory/memory.limit_in_bytes": "9223372036854771712",
+		"sys/fs/cgroup/memory/memory.usage_in_bytes": "1048576",
+	})
+
+	limits := DetectResourceLimits(LimitsAuto, 0, 0)
+	if limits.Source != LimitsSourceCgroupV1 || limits.CPUs != 0.25 {
+		t.Fatalf("limits = %+v, want a quarter CPU from cgroup v1", limits)
+	}
+	if limits.MemoryBytes != 0 {
+		t.Errorf("memory limit = %d, want none for v1's unlimited value", limits.MemoryBytes)
+	}
+	if used, ok := limits.cpuTime(); !ok || used != 5*time.Second {
+		t.Errorf("CPU time = %v, want 5s from cpuacct.usage", used)
+	}
+}
+
+func TestResourceLimitsOverride(t *testing.T) {
+	fakeCgroup(t, map[string]string{"proc/self/cgroup": "0::/"})
+
+	limits := DetectResourceLimits(LimitsAuto, 0, 0)
+	if limits.Source != LimitsSourceHost || limits.CPUs != 0 {
+		t.Fatalf("limits without a cgroup = %+v, want the host's", limits)
+	}
+	if percent := NewCPUMeter(limits).Percent(42); percent != 42 {
+		t.Errorf("CPU = %v%%, want the host's usage unchanged", percent)
+	}
+
+	limits = DetectResourceLimits(LimitsAuto, 0.5, 1<<30)
+	if limits.Source != LimitsSourceConfig || limits.CPUs != 0.5 || limits.MemoryBytes != 1<<30 {
+		t.Fatalf("overridden limits = %+v", limits)
+	}
+	// Without cgroup accounting, host usage is rescaled to the limits
+	limits.HostCPUs = 4
+	if percent := NewCPUMeter(limits).Percent(10); percent != 80 {
+		t.Errorf("CPU = %v%%, want 10%% of 4 CPUs as 80%% of half a CPU", percent)
+	}
+	if total, used := limits.Memory(16<<30, 512<<20); total != 1<<30 || used != 512<<20 {
+		t.Errorf("memory = %d of %d, want the host's use of the 1GiB override", used, total)
+	}
+}
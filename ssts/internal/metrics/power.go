@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Energy counter locations; variables so tests can point them at a fake sysfs
+var (
+	powercapRoot = "/sys/class/powercap"
+	hwmonRoot    = "/sys/class/hwmon"
+)
+
+// Power sources reported in EnergyUsage.Source
+const (
+	PowerSourceRAPL      = "intel-rapl" // powercap RAPL, also used by AMD Zen on Linux 5.8+
+	PowerSourceAMDEnergy = "amd_energy" // amd_energy hwmon driver on older kernels
+)
+
+// powerDomain is one energy counter
+type powerDomain struct {
+	name     string // e.g. package-0, package-0/core, package-0/dram
+	kind     string // package, core, uncore, dram or psys
+	path     string // file holding the cumulative energy in microjoules
+	maxRange uint64 // counter wraps after this many microjoules; 0 if it doesn't wrap
+}
+
+// PowerMeter turns cumulative RAPL energy counters into power draw and energy consumed
+type PowerMeter struct {
+	source   string
+	domains  []powerDomain
+	last     []uint64
+	lastTime time.Time
+	joules   []float64
+}
+
+// NewPowerMeter discovers the readable energy counters. It returns an error when
+// none are available, e.g. in VMs, on unsupported CPUs, or when not running as
+// root (energy_uj is root-only on current kernels).
+func NewPowerMeter() (*PowerMeter, error) {
+	source := PowerSourceRAPL
+	domains, err := raplDomains()
+	if len(domains) == 0 {
+		source = PowerSourceAMDEnergy
+		domains = amdEnergyDomains()
+	}
+	if len(domains) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no RAPL energy counters found")
+	}
+
+	m := &PowerMeter{
+		source:  source,
+		domains: domains,
+		last:    make([]uint64, len(domains)),
+		joules:  make([]float64, len(domains)),
+	}
+	for i, domain := range domains {
+		value, err := readUint(domain.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s energy: %w", domain.name, err)
+		}
+		m.last[i] = value
+	}
+	m.lastTime = time.Now()
+
+	return m, nil
+}
+
+// Source returns where the counters come from
+func (m *PowerMeter) Source() string {
+	return m.source
+}
+
+// Sample reads the counters and returns the average power since the previous
+// sample, along with the energy consumed since the meter was created
+func (m *PowerMeter) Sample() models.PowerMetrics {
+	now := time.Now()
+	elapsed := now.Sub(m.lastTime).Seconds()
+	m.lastTime = now
+
+	var power models.PowerMetrics
+	for i, domain := range m.domains {
+		value, err := readUint(domain.path)
+		if err != nil {
+			continue
+		}
+
+		consumed := float64(energyDelta(value, m.last[i], domain.maxRange)) / 1e6
+		m.last[i] = value
+		m.joules[i] += consumed
+
+		var watts float64
+		if elapsed > 0 {
+			watts = consumed / elapsed
+		}
+
+		switch domain.kind {
+		case "package":
+			power.PackageWatts += watts
+		case "core":
+			power.CoreWatts += watts
+		case "dram":
+			power.DRAMWatts += watts
+		}
+		power.Domains = append(power.Domains, models.PowerDomainMetrics{
+			Name:   domain.name,
+			Watts:  watts,
+			Joules: m.joules[i],
+		})
+	}
+
+	return power
+}
+
+// TotalJoules returns the package plus DRAM energy consumed since the meter was
+// created; core and uncore are part of the package and psys overlaps everything
+func (m *PowerMeter) TotalJoules() float64 {
+	var total float64
+	for i, domain := range m.domains {
+		if domain.kind == "package" || domain.kind == "dram" {
+			total += m.joules[i]
+		}
+	}
+	return total
+}
+
+// DomainJoules returns the energy consumed per domain since the meter was created
+func (m *PowerMeter) DomainJoules() map[string]float64 {
+	joules := make(map[string]float64, len(m.domains))
+	for i, domain := range m.domains {
+		joules[domain.name] = m.joules[i]
+	}
+	return joules
+}
+
+// energyDelta returns the increase of an energy counter, accounting for wraparound
+func energyDelta(current, previous, maxRange uint64) uint64 {
+	if current >= previous {
+		return current - previous
+	}
+	if maxRange > previous {
+		return maxRange - previous + current
+	}
+	return 0
+}
+
+// raplDomains lists powercap RAPL zones and their subzones, e.g.
+// intel-rapl:0 (package-0) and intel-rapl:0:0 (core)
+func raplDomains() ([]powerDomain, error) {
+	zones, _ := filepath.Glob(filepath.Join(powercapRoot, "intel-rapl:*"))
+	sort.Strings(zones)
+
+	var domains []powerDomain
+	var readErr error
+	parents := make(map[string]string)
+	for _, zone := range zones {
+		energyPath := filepath.Join(zone, "energy_uj")
+		if _, err := readUint(energyPath); err != nil {
+			// Typically EACCES: energy_uj is only readable by root
+			readErr = fmt.Errorf("failed to read RAPL energy counters: %w", err)
+			continue
+		}
+
+		name := readString(filepath.Join(zone, "name"))
+		kind := raplKind(name)
+		id := strings.TrimPrefix(filepath.Base(zone), "intel-rapl:")
+
+		// Subzones are named after their package, e.g. package-0/core
+		if parent, _, ok := strings.Cut(id, ":"); ok {
+			if parentName, ok := parents[parent]; ok {
+				name = parentName + "/" + name
+			}
+		} else {
+			parents[id] = name
+		}
+
+		maxRange, _ := readUint(filepath.Join(zone, "max_energy_range_uj"))
+		domains = append(domains, powerDomain{name: name, kind: kind, path: energyPath, maxRange: maxRange})
+	}
+
+	return domains, readErr
+}
+
+// raplKind classifies a powercap zone name: package-N zones are packages, and
+// subzones (core, uncore, dram) and psys are named after their kind
+func raplKind(name string) string {
+	if strings.HasPrefix(name, "package") {
+		return "package"
+	}
+	return name
+}
+
+// amdEnergyDomains lists the counters of the amd_energy hwmon driver. Its socket
+// counters map to packages and its per-core counters are summed into the core domain.
+func amdEnergyDomains() []powerDomain {
+	hwmons, _ := filepath.Glob(filepath.Join(hwmonRoot, "hwmon*"))
+	sort.Strings(hwmons)
+
+	var domains []powerDomain
+	for _, hwmon := range hwmons {
+		if readString(filepath.Join(hwmon, "name")) != "amd_energy" {
+			continue
+		}
+
+		labels, _ := filepath.Glob(filepath.Join(hwmon, "energy*_label"))
+		sort.Strings(labels)
+		for _, labelPath := range labels {
+			inputPath := strings.TrimSuffix(labelPath, "_label") + "_input"
+			if _, err := readUint(inputPath); err != nil {
+				continue
+			}
+
+			label := readString(labelPath)
+			switch {
+			case strings.HasPrefix(label, "Esocket"):
+				socket := strings.TrimPrefix(label, "Esocket")
+				domains = append(domains, powerDomain{name: "package-" + socket, kind: "package", path: inputPath})
+			case strings.HasPrefix(label, "Ecore"):
+				core := strings.TrimLeft(strings.TrimPrefix(label, "Ecore"), "0")
+				if core == "" {
+					core = "0"
+				}
+				domains = append(domains, powerDomain{name: "core-" + core, kind: "core", path: inputPath})
+			}
+		}
+	}
+
+	return domains
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func readString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// writeZone creates a fake powercap zone
+func writeZone(t *testing.T, root, zone, name string, energy, maxRange uint64) string {
+	t.Helper()
+	dir := filepath.Join(root, zone)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"name":                name,
+		"energy_uj":           strconv.FormatUint(energy, 10),
+		"max_energy_range_uj": strconv.FormatUint(maxRange, 10),
+	}
+	for file, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "energy_uj")
+}
+
+func TestPowerMeterRAPL(t *testing.T) {
+	root := t.TempDir()
+	previousRoot := powercapRoot
+	powercapRoot = root
+	defer func() { powercapRoot = previousRoot }()
+
+	const maxRange = 262143328850
+	pkg := writeZone(t, root, "intel-rapl:0", "package-0", maxRange-1_000_000, maxRange)
+	core := writeZone(t, root, "intel-rapl:0:0", "core", 5_000_000, maxRange)
+	dram := writeZone(t, root, "intel-rapl:0:1", "dram", 1_000_000, maxRange)
+
+	meter, err := NewPowerMeter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meter.Source() != PowerSourceRAPL {
+		t.Errorf("source = %q, want %q", meter.Source(), PowerSourceRAPL)
+	}
+
+	// The package counter wraps: 1J before the wrap plus 2J after it
+	os.WriteFile(pkg, []byte("2000000\n"), 0644)
+	os.WriteFile(core, []byte("7000000\n"), 0644)
+	os.WriteFile(dram, []byte("1500000\n"), 0644)
+
+	power := meter.Sample()
+	if len(power.Domains) != 3 {
+		t.Fatalf("got %d domains, want 3", len(power.Domains))
+	}
+	if power.Domains[1].Name != "package-0/core" {
+		t.Errorf("subzone name = %q, want package-0/core", power.Domains[1].Name)
+	}
+	if power.PackageWatts <= 0 || power.CoreWatts <= 0 || power.DRAMWatts <= 0 {
+		t.Errorf("expected positive power, got %+v", power)
+	}
+
+	joules := meter.DomainJoules()
+	if joules["package-0"] != 3 || joules["package-0/core"] != 2 || joules["package-0/dram"] != 0.5 {
+		t.Errorf("unexpected domain energy %v", joules)
+	}
+	// Core is part of the package, so only package and DRAM count towards the total
+	if total := meter.TotalJoules(); total != 3.5 {
+		t.Errorf("total = %vJ, want 3.5J", total)
+	}
+}
+
+func TestPowerMeterUnavailable(t *testing.T) {
+	previousPowercap, previousHwmon := powercapRoot, hwmonRoot
+	powercapRoot, hwmonRoot = t.TempDir(), t.TempDir()
+	defer func() { powercapRoot, hwmonRoot = previousPowercap, previousHwmon }()
+
+	if _, err := NewPowerMeter(); err == nil {
+		t.Error("expected an error without energy counters")
+	}
+}
@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/host"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// sensorSampleInterval is how often temperature and fan sensors are sampled during an execution
+const sensorSampleInterval = 2 * time.Second
+
+// ErrNoTemperatureSensors is returned by MaxTemperature when no sensor can be read
+var ErrNoTemperatureSensors = errors.New("no temperature sensors found")
+
+// sensorsTemperatures reads the temperature sensors; a variable so tests can fake it
+var sensorsTemperatures = host.SensorsTemperatures
+
+// ReadThermal reads every temperature sensor and fan the machine exposes. Either
+// list is empty where the hardware or platform provides none.
+func ReadThermal() models.ThermalMetrics {
+	var thermal models.ThermalMetrics
+
+	// Sensors that fail to read are reported as warnings alongside the ones that worked
+	temperatures, _ := sensorsTemperatures()
+	for _, sensor := range temperatures {
+		if sensor.Temperature <= 0 {
+			continue
+		}
+		thermal.Temperatures = append(thermal.Temperatures, models.TemperatureSensor{
+			Name:            sensor.SensorKey,
+			Celsius:         sensor.Temperature,
+			HighCelsius:     sensor.High,
+			CriticalCelsius: sensor.Critical,
+		})
+		if sensor.Temperature > thermal.MaxCelsius {
+			thermal.MaxCelsius = sensor.Temperature
+		}
+	}
+
+	thermal.Fans = readFans()
+	return thermal
+}
+
+// MaxTemperature returns the reading of the hottest temperature sensor
+func MaxTemperature() (float64, error) {
+	thermal := ReadThermal()
+	if len(thermal.Temperatures) == 0 {
+		return 0, ErrNoTemperatureSensors
+	}
+	return thermal.MaxCelsius, nil
+}
+
+// readFans reads the fan speeds reported by hwmon drivers, named after the driver
+// and the fan's label (or its input number when it has none)
+func readFans() []models.FanSensor {
+	hwmons, _ := filepath.Glob(filepath.Join(hwmonRoot, "hwmon*"))
+	sort.Strings(hwmons)
+
+	var fans []models.FanSensor
+	for _, hwmon := range hwmons {
+		driver := readString(filepath.Join(hwmon, "name"))
+		if driver == "" {
+			driver = filepath.Base(hwmon)
+		}
+
+		inputs, _ := filepath.Glob(filepath.Join(hwmon, "fan*_input"))
+		sort.Strings(inputs)
+		for _, inputPath := range inputs {
+			rpm, err := readUint(inputPath)
+			if err != nil {
+				continue
+			}
+
+			fan := strings.TrimSuffix(filepath.Base(inputPath), "_input")
+			if label := readString(strings.TrimSuffix(inputPath, "_input") + "_label"); label != "" {
+				fan = label
+			}
+			fans = append(fans, models.FanSensor{Name: driver + "/" + fan, RPM: int64(rpm)})
+		}
+	}
+
+	return fans
+}
+
+// sampleSensors writes a point per temperature sensor and fan to the collector's
+// sinks every sensorSampleInterval until ctx is done. Each sensor's tags map and
+// the slice points are gathered in are reused from one sample to the next.
+func (c *Collector) sampleSensors(ctx context.Context, testID, executionID string) {
+	ticker := time.NewTicker(sensorSampleInterval)
+	defer ticker.Stop()
+
+	temperatureTags := make(map[string]map[string]string)
+	fanTags := make(map[string]map[string]string)
+	sensorTags := func(cache map[string]map[string]string, sensor string) map[string]string {
+		tags, ok := cache[sensor]
+		if !ok {
+			tags = map[string]string{"execution_id": executionID, "sensor": sensor}
+			cache[sensor] = tags
+		}
+		return tags
+	}
+
+	var points []models.MetricPoint
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		thermal := ReadThermal()
+		now := time.Now()
+		points = points[:0]
+		for _, sensor := range thermal.Temperatures {
+			points = append(points, models.MetricPoint{
+				Timestamp: now,
+				TestID:    testID,
+				Source:    "sensors",
+				Type:      "temperature",
+				Tags:      sensorTags(temperatureTags, sensor.Name),
+				Fields: models.TemperatureFields{
+					Celsius:         sensor.Celsius,
+					HighCelsius:     sensor.HighCelsius,
+					CriticalCelsius: sensor.CriticalCelsius,
+				}.Fields(),
+			})
+		}
+		for _, fan := range thermal.Fans {
+			points = append(points, models.MetricPoint{
+				Timestamp: now,
+				TestID:    testID,
+				Source:    "sensors",
+				Type:      "fan",
+				Tags:      sensorTags(fanTags, fan.Name),
+				Fields:    models.FanFields{RPM: fan.RPM}.Fields(),
+			})
+		}
+		if len(points) == 0 {
+			// Nothing to report on this machine
+			return
+		}
+
+		c.mu.RLock()
+		sinks := c.sinks
+		c.mu.RUnlock()
+
+		for _, sink := range sinks {
+			for _, point := range points {
+				sink.WriteMetricPoint(point)
+			}
+		}
+	}
+}
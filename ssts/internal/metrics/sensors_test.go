@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+func TestReadThermal(t *testing.T) {
+	root := t.TempDir()
+	previousRoot, previousTemperatures := hwmonRoot, sensorsTemperatures
+	hwmonRoot = root
+	defer func() { hwmonRoot, sensorsTemperatures = previousRoot, previousTemperatures }()
+
+	sensorsTemperatures = func() ([]host.TemperatureStat, error) {
+		return []host.TemperatureStat{
+			{SensorKey: "coretemp_package_id_0", Temperature: 71.5, High: 84, Critical: 100},
+			{SensorKey: "nvme_composite", Temperature: 42},
+			{SensorKey: "acpitz", Temperature: 0}, // unreadable sensors report 0
+		}, errors.New("partial read")
+	}
+
+	files := map[string]string{
+		"hwmon0/name":        "nct6775",
+		"hwmon0/fan1_input":  "1200",
+		"hwmon0/fan2_input":  "850",
+		"hwmon0/fan2_label":  "CPU_FAN",
+		"hwmon1/name":        "coretemp",
+		"hwmon1/temp1_input": "71500",
+	}
+	for file, content := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	thermal := ReadThermal()
+	if len(thermal.Temperatures) != 2 {
+		t.Fatalf("got %d temperature sensors, want 2", len(thermal.Temperatures))
+	}
+	if thermal.MaxCelsius != 71.5 {
+		t.Errorf("max = %v, want 71.5", thermal.MaxCelsius)
+	}
+	if sensor := thermal.Temperatures[0]; sensor.HighCelsius != 84 || sensor.CriticalCelsius != 100 {
+		t.Errorf("thresholds = %v/%v, want 84/100", sensor.HighCelsius, sensor.CriticalCelsius)
+	}
+
+	if len(thermal.Fans) != 2 {
+		t.Fatalf("got %d fans, want 2", len(thermal.Fans))
+	}
+	if fan := thermal.Fans[0]; fan.Name != "nct6775/fan1" || fan.RPM != 1200 {
+		t.Errorf("fan 0 = %+v, want nct6775/fan1 at 1200 RPM", fan)
+	}
+	if fan := thermal.Fans[1]; fan.Name != "nct6775/CPU_FAN" || fan.RPM != 850 {
+		t.Errorf("fan 1 = %+v, want nct6775/CPU_FAN at 850 RPM", fan)
+	}
+
+	sensorsTemperatures = func() ([]host.TemperatureStat, error) { return nil, nil }
+	if _, err := MaxTemperature(); !errors.Is(err, ErrNoTemperatureSensors) {
+		t.Errorf("MaxTemperature without sensors: error = %v, want ErrNoTemperatureSensors", err)
+	}
+}
@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// sendMail sends an email; tests replace it to capture messages
+var sendMail = smtp.SendMail
+
+// emailChannel emails notifications to the user's address through an SMTP server
+type emailChannel struct {
+	cfg config.EmailConfig
+}
+
+func newEmailChannel(cfg config.EmailConfig) *emailChannel {
+	if cfg.SMTPPort == 0 {
+		cfg.SMTPPort = 587
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.Username
+	}
+	return &emailChannel{cfg: cfg}
+}
+
+// Send emails the notification. net/smtp can't be cancelled, so the context
+// only stops sends that haven't started.
+func (e *emailChannel) Send(ctx context.Context, user models.User, prefs models.NotificationPreferences, n Notification) error {
+	if user.Email == "" {
+		return fmt.Errorf("user has no email address")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.SMTPHost)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", user.Email)
+	fmt.Fprintf(&msg, "Subject: [SSTS] %s\r\n", headerValue(n.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Timestamp.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(text(n))
+
+	addr := net.JoinHostPort(e.cfg.SMTPHost, strconv.Itoa(e.cfg.SMTPPort))
+	return sendMail(addr, auth, e.cfg.From, []string{user.Email}, msg.Bytes())
+}
+
+// slackChannel posts notifications to the user's Slack incoming webhook
+type slackChannel struct {
+	client *http.Client
+}
+
+func (s *slackChannel) Send(ctx context.Context, user models.User, prefs models.NotificationPreferences, n Notification) error {
+	return post(ctx, s.client, prefs.SlackWebhookURL, map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", n.Title, n.Message),
+	})
+}
+
+// webhookChannel posts notifications as JSON to the user's URL
+type webhookChannel struct {
+	client *http.Client
+}
+
+func (w *webhookChannel) Send(ctx context.Context, user models.User, prefs models.NotificationPreferences, n Notification) error {
+	return post(ctx, w.client, prefs.WebhookURL, n)
+}
+
+// targetAllowed reports whether notifications may be sent to an address; tests
+// replace it to reach servers on the loopback interface
+var targetAllowed = models.PublicIP
+
+// newHTTPClient creates the client Slack and webhook notifications are posted
+// with. URLs are checked when preferences are saved, but a name may resolve to
+// anything by the time a notification is sent, so the address actually dialled
+// is checked too. Redirects aren't followed, since they could lead anywhere.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !targetAllowed(ip) {
+				return fmt.Errorf("refusing to send notifications to %s", host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// post sends a JSON body, failing on non-2xx statuses
+func post(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	if !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("refusing to send notifications to %q: not an https URL", url)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("POST %s: %s: %s", url, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// text is the plain-text body of a notification
+func text(n Notification) string {
+	var b strings.Builder
+	b.WriteString(n.Message + "\r\n\r\n")
+	fmt.Fprintf(&b, "Severity: %s\r\n", n.Severity)
+	if n.ExecutionID != "" {
+		fmt.Fprintf(&b, "Execution: %s\r\n", n.ExecutionID)
+	}
+	if n.TestID != "" {
+		fmt.Fprintf(&b, "Test: %s\r\n", n.TestID)
+	}
+	fmt.Fprintf(&b, "Time: %s\r\n", n.Timestamp.Format(time.RFC3339))
+	return b.String()
+}
+
+// headerValue keeps a value on one header line
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
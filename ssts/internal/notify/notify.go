@@ -0,0 +1,240 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Notification is an event users may be notified about
+type Notification struct {
+	Event       string                 `json:"event"`    // one of models.NotificationEvents
+	Severity    string                 `json:"severity"` // one of models.NotificationSeverities
+	Title       string                 `json:"title"`
+	Message     string                 `json:"message"`
+	ExecutionID string                 `json:"execution_id,omitempty"`
+	TestID      string                 `json:"test_id,omitempty"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Channel delivers notifications to a user
+type Channel interface {
+	Send(ctx context.Context, user models.User, prefs models.NotificationPreferences, n Notification) error
+}
+
+// Dispatcher sends each notification to the users whose preferences ask for it,
+// over the channels they chose, in the background. A channel failing doesn't
+// stop the others being sent to.
+type Dispatcher struct {
+	repo     database.Repository
+	mu       sync.RWMutex // guards channels
+	channels map[string]Channel
+	timeout  time.Duration
+	logger   *zap.Logger
+	wg       sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher. Email is only available when an SMTP host
+// is configured, web push when a VAPID key is, and browser notifications once
+// the API server adds its channel.
+func NewDispatcher(cfg config.NotificationsConfig, repo database.Repository, logger *zap.Logger) *Dispatcher {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	client := newHTTPClient(timeout)
+	channels := map[string]Channel{
+		models.ChannelSlack:   &slackChannel{client: client},
+		models.ChannelWebhook: &webhookChannel{client: client},
+	}
+	if cfg.Email.SMTPHost != "" {
+		channels[models.ChannelEmail] = newEmailChannel(cfg.Email)
+	}
+	if cfg.WebPush.VAPIDPrivateKey != "" {
+		if push, err := newWebPushChannel(cfg.WebPush, client); err != nil {
+			logger.Error("Web push notifications unavailable", zap.Error(err))
+		} else {
+			channels[models.ChannelWebPush] = push
+		}
+	}
+
+	return &Dispatcher{
+		repo:     repo,
+		channels: channels,
+		timeout:  timeout,
+		logger:   logger,
+	}
+}
+
+// AddChannel makes a channel available under a name, replacing any channel
+// by that name
+func (d *Dispatcher) AddChannel(name string, channel Channel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.channels[name] = channel
+}
+
+// Available reports whether notifications can be sent over a channel
+func (d *Dispatcher) Available(channel string) bool {
+	_, ok := d.channel(channel)
+	return ok
+}
+
+// channel returns the channel by a name
+func (d *Dispatcher) channel(name string) (Channel, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	channel, ok := d.channels[name]
+	return channel, ok
+}
+
+// Notify sends a notification to every user who wants it without waiting for it
+// to be delivered
+func (d *Dispatcher) Notify(n Notification) {
+	if n.Timestamp.IsZero() {
+		n.Timestamp = time.Now()
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.dispatch(n)
+	}()
+}
+
+// dispatch sends a notification to each recipient over each of their channels
+func (d *Dispatcher) dispatch(n Notification) {
+	users, err := d.repo.ListUsers()
+	if err != nil {
+		d.logger.Error("Failed to list users to notify", zap.String("event", n.Event), zap.Error(err))
+		return
+	}
+
+	about := d.subject(n)
+	for _, user := range users {
+		prefs, err := user.NotificationPreferences()
+		if err != nil {
+			d.logger.Warn("Skipping user with invalid notification preferences", zap.String("user_id", user.ID), zap.Error(err))
+			continue
+		}
+		if !prefs.Wants(n.Event, n.Severity) {
+			continue
+		}
+		// Alerts about the whole system have no owner, so only reach users notified about everything
+		if prefs.Scope != models.NotifyScopeAll && (about.owner == "" || about.owner != user.ID) {
+			continue
+		}
+		// Notifications about an execution only reach users who may see its project
+		if about.scoped && !d.canSee(user, about.projectID) {
+			continue
+		}
+
+		for _, name := range prefs.Channels {
+			channel, ok := d.channel(name)
+			if !ok {
+				d.logger.Debug("Notification channel unavailable", zap.String("channel", name), zap.String("user_id", user.ID))
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+			err := channel.Send(ctx, user, prefs, n)
+			cancel()
+			if err != nil {
+				d.logger.Error("Failed to send notification",
+					zap.String("channel", name),
+					zap.String("user_id", user.ID),
+					zap.String("event", n.Event),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+// subject is what a notification is about
+type subject struct {
+	scoped    bool   // about an execution or test rather than the whole system
+	owner     string // ID of the user who created the test, "" if unknown
+	projectID string // project of the execution or test, "" if unknown
+}
+
+// subject finds the test and project a notification is about. A notification
+// naming an execution or test that can't be found is still scoped, with no
+// project, so it only reaches admins.
+func (d *Dispatcher) subject(n Notification) subject {
+	about := subject{scoped: n.ExecutionID != "" || n.TestID != ""}
+
+	testID := n.TestID
+	if n.ExecutionID != "" {
+		execution, err := d.repo.GetTestExecution(n.ExecutionID)
+		if err != nil {
+			if !errors.Is(err, database.ErrNotFound) {
+				d.logger.Warn("Failed to load execution to notify about", zap.String("execution_id", n.ExecutionID), zap.Error(err))
+			}
+			return about
+		}
+		about.projectID = execution.ProjectID
+		if testID == "" {
+			testID = execution.TestID
+		}
+	}
+	if testID == "" {
+		return about
+	}
+
+	test, err := d.repo.GetTestConfiguration(testID)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			d.logger.Warn("Failed to load test to notify about", zap.String("test_id", testID), zap.Error(err))
+		}
+		return about
+	}
+	about.owner = test.CreatedBy
+	if about.projectID == "" {
+		about.projectID = test.ProjectID
+	}
+	return about
+}
+
+// canSee reports whether a user may see a project: admins see every project,
+// other users those they are a member of
+func (d *Dispatcher) canSee(user models.User, projectID string) bool {
+	if user.Role == models.UserRoleAdmin {
+		return true
+	}
+	if projectID == "" {
+		return false
+	}
+
+	_, err := d.repo.GetProjectMember(projectID, user.ID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		d.logger.Warn("Failed to check project membership", zap.String("project_id", projectID), zap.String("user_id", user.ID), zap.Error(err))
+	}
+	return err == nil
+}
+
+// Close waits for notifications being sent to be delivered, up to the timeout.
+// It reports whether they all were.
+func (d *Dispatcher) Close(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
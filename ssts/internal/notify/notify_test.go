@@ -0,0 +1,376 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/internal/database"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// received records what fake channels were sent
+type received struct {
+	mu     sync.Mutex
+	slack  []string // message texts
+	hooks  []Notification
+	users  []string // user query parameter of webhook URLs
+	emails []string // recipients
+}
+
+func (r *received) snapshot() (slack []string, hooks []Notification, emails []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append(slack, r.slack...), append(hooks, r.hooks...), append(emails, r.emails...)
+}
+
+func (r *received) hookUsers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.users...)
+}
+
+// fakeReceivers serves Slack and webhook URLs and captures sent emails. The
+// server is on the loopback interface, so sending to it is allowed for the test.
+func fakeReceivers(t *testing.T) (*httptest.Server, *received) {
+	r := &received{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		switch req.URL.Path {
+		case "/slack":
+			var body map[string]string
+			json.Unmarshal(data, &body)
+			r.slack = append(r.slack, body["text"])
+		case "/hook":
+			var n Notification
+			json.Unmarshal(data, &n)
+			r.hooks = append(r.hooks, n)
+			r.users = append(r.users, req.URL.Query().Get("user"))
+		case "/redirect":
+			http.Redirect(w, req, "/hook", http.StatusFound)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	original := sendMail
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if addr != "smtp.example.com:587" {
+			t.Errorf("email sent to %s", addr)
+		}
+		if !strings.Contains(string(msg), "Subject: [SSTS] ") {
+			t.Errorf("email has no subject: %s", msg)
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.emails = append(r.emails, to...)
+		return nil
+	}
+	t.Cleanup(func() { sendMail = original })
+
+	allowed := targetAllowed
+	targetAllowed = func(net.IP) bool { return true }
+	t.Cleanup(func() { targetAllowed = allowed })
+
+	return server, r
+}
+
+// trust makes the dispatcher trust the test server's certificate
+func trust(d *Dispatcher, server *httptest.Server) {
+	client := d.channels[models.ChannelWebhook].(*webhookChannel).client
+	client.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+}
+
+func addUser(t *testing.T, repo *database.MemoryRepository, id string, prefs *models.NotificationPreferences) {
+	user := &models.User{ID: id, Username: id, Email: id + "@example.com"}
+	if prefs != nil {
+		if err := user.SetNotificationPreferences(*prefs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDispatcherHonorsPreferences(t *testing.T) {
+	server, got := fakeReceivers(t)
+	repo := database.NewMemoryRepository()
+
+	// alice owns the test and wants everything about her executions by email and Slack
+	addUser(t, repo, "alice", &models.NotificationPreferences{
+		Enabled:         true,
+		Scope:           models.NotifyScopeOwn,
+		Channels:        []string{models.ChannelEmail, models.ChannelSlack},
+		SlackWebhookURL: server.URL + "/slack",
+	})
+	// bob wants critical alerts about anything by webhook
+	addUser(t, repo, "bob", &models.NotificationPreferences{
+		Enabled:    true,
+		Scope:      models.NotifyScopeAll,
+		Events:     []string{models.NotifyAlert},
+		Severities: []string{"critical"},
+		Channels:   []string{models.ChannelWebhook},
+		WebhookURL: server.URL + "/hook",
+	})
+	// carol has never set preferences, so gets nothing
+	addUser(t, repo, "carol", nil)
+
+	if err := repo.CreateTestConfiguration(&models.TestConfiguration{ID: "test-1", Name: "cpu", CreatedBy: "alice", ProjectID: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateTestExecution(&models.TestExecution{ID: "exec-1", TestID: "test-1", ProjectID: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+	repo.SetProjectMember("p1", "alice", models.ProjectRoleOwner)
+
+	cfg := config.NotificationsConfig{Timeout: time.Second, Email: config.EmailConfig{SMTPHost: "smtp.example.com", SMTPPort: 587}}
+	d := NewDispatcher(cfg, repo, zap.NewNop())
+	trust(d, server)
+
+	d.Notify(Notification{Event: models.NotifyExecutionCompleted, Severity: "info", Title: "Execution completed", ExecutionID: "exec-1"})
+	d.Notify(Notification{Event: models.NotifyAlert, Severity: "critical", Title: "CPU critical", Message: "CPU at 99%"})
+	d.Notify(Notification{Event: models.NotifyAlert, Severity: "warning", Title: "CPU high", ExecutionID: "exec-1", TestID: "test-1"})
+	if !d.Close(5 * time.Second) {
+		t.Fatal("notifications weren't delivered")
+	}
+
+	slack, hooks, emails := got.snapshot()
+
+	// alice gets her execution's completion and its warning, but not the system-wide alert
+	if len(emails) != 2 || emails[0] != "alice@example.com" || emails[1] != "alice@example.com" {
+		t.Errorf("emails = %v, want two to alice", emails)
+	}
+	if len(slack) != 2 {
+		t.Errorf("slack messages = %v, want 2", slack)
+	}
+	// bob gets only the critical alert
+	if len(hooks) != 1 || hooks[0].Title != "CPU critical" || hooks[0].Severity != "critical" {
+		t.Errorf("webhook notifications = %+v, want the critical alert", hooks)
+	}
+}
+
+func TestDispatcherWithoutSMTPHost(t *testing.T) {
+	server, got := fakeReceivers(t)
+	repo := database.NewMemoryRepository()
+	addUser(t, repo, "alice", &models.NotificationPreferences{
+		Enabled:    true,
+		Scope:      models.NotifyScopeAll,
+		Channels:   []string{models.ChannelEmail, models.ChannelWebhook},
+		WebhookURL: server.URL + "/hook",
+	})
+
+	d := NewDispatcher(config.NotificationsConfig{}, repo, zap.NewNop())
+	trust(d, server)
+	if d.Available(models.ChannelEmail) {
+		t.Error("email available without an SMTP host")
+	}
+
+	d.Notify(Notification{Event: models.NotifyAlert, Severity: "error", Title: "Disk full"})
+	if !d.Close(5 * time.Second) {
+		t.Fatal("notifications weren't delivered")
+	}
+
+	// The webhook is still sent to when email is unavailable
+	_, hooks, emails := got.snapshot()
+	if len(emails) != 0 {
+		t.Errorf("emails = %v, want none", emails)
+	}
+	if len(hooks) != 1 {
+		t.Errorf("webhook notifications = %+v, want 1", hooks)
+	}
+}
+
+func TestDispatcherOnlyNotifiesProjectMembers(t *testing.T) {
+	server, got := fakeReceivers(t)
+	repo := database.NewMemoryRepository()
+	for _, id := range []string{"member", "outsider", "admin"} {
+		addUser(t, repo, id, &models.NotificationPreferences{
+			Enabled:    true,
+			Scope:      models.NotifyScopeAll,
+			Channels:   []string{models.ChannelWebhook},
+			WebhookURL: server.URL + "/hook?user=" + id,
+		})
+	}
+	admin, _ := repo.GetUser("admin")
+	admin.Role = models.UserRoleAdmin
+	repo.UpdateUser(admin)
+	repo.SetProjectMember("p1", "member", models.ProjectRoleViewer)
+	repo.CreateTestConfiguration(&models.TestConfiguration{ID: "test-1", Name: "cpu", ProjectID: "p1"})
+	repo.CreateTestExecution(&models.TestExecution{ID: "exec-1", TestID: "test-1", ProjectID: "p1"})
+
+	tests := []struct {
+		name         string
+		notification Notification
+		want         []string
+	}{
+		{"execution", Notification{Event: models.NotifyExecutionFailed, Severity: "error", ExecutionID: "exec-1"}, []string{"admin", "member"}},
+		{"test", Notification{Event: models.NotifyAlert, Severity: "warning", TestID: "test-1"}, []string{"admin", "member"}},
+		{"unknown execution", Notification{Event: models.NotifyAlert, Severity: "warning", ExecutionID: "gone"}, []string{"admin"}},
+		{"whole system", Notification{Event: models.NotifyAlert, Severity: "critical"}, []string{"admin", "member", "outsider"}},
+	}
+	for _, tt := range tests {
+		d := NewDispatcher(config.NotificationsConfig{Timeout: time.Second}, repo, zap.NewNop())
+		trust(d, server)
+		before := len(got.hookUsers())
+		d.Notify(tt.notification)
+		if !d.Close(5 * time.Second) {
+			t.Fatal("notifications weren't delivered")
+		}
+
+		users := got.hookUsers()[before:]
+		sort.Strings(users)
+		if strings.Join(users, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: notified %v, want %v", tt.name, users, tt.want)
+		}
+	}
+}
+
+func TestDispatcherRefusesUnsafeTargets(t *testing.T) {
+	server, got := fakeReceivers(t)
+	repo := database.NewMemoryRepository()
+	targets := map[string]string{
+		"plain http": strings.Replace(server.URL, "https://", "http://", 1) + "/hook",
+		"redirect":   server.URL + "/redirect",
+	}
+	for name, url := range targets {
+		addUser(t, repo, name, &models.NotificationPreferences{
+			Enabled:    true,
+			Scope:      models.NotifyScopeAll,
+			Channels:   []string{models.ChannelWebhook},
+			WebhookURL: url,
+		})
+	}
+
+	d := NewDispatcher(config.NotificationsConfig{Timeout: time.Second}, repo, zap.NewNop())
+	trust(d, server)
+	d.Notify(Notification{Event: models.NotifyAlert, Severity: "critical"})
+	d.Close(5 * time.Second)
+	if _, hooks, _ := got.snapshot(); len(hooks) != 0 {
+		t.Errorf("webhook notifications = %+v, want none", hooks)
+	}
+
+	// Names resolving to the server's own or private networks are refused when
+	// dialling, whatever the preferences say
+	targetAllowed = models.PublicIP
+	d = NewDispatcher(config.NotificationsConfig{Timeout: time.Second}, repo, zap.NewNop())
+	trust(d, server)
+	err := d.channels[models.ChannelWebhook].Send(context.Background(), models.User{}, models.NotificationPreferences{WebhookURL: server.URL + "/hook"}, Notification{})
+	if err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("send to the loopback interface: error = %v", err)
+	}
+	if _, hooks, _ := got.snapshot(); len(hooks) != 0 {
+		t.Errorf("webhook notifications = %+v, want none", hooks)
+	}
+}
+
+func TestWebPushIsEncryptedForTheSubscription(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		body    []byte
+		headers http.Header
+	)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ = io.ReadAll(req.Body)
+		headers = req.Header
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	// The browser's side of the subscription
+	browserKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	authSecret := []byte("0123456789abcdef")
+	subscription := &models.WebPushSubscription{Endpoint: server.URL + "/push/abc"}
+	subscription.Keys.P256DH = base64.RawURLEncoding.EncodeToString(browserKey.PublicKey().Bytes())
+	subscription.Keys.Auth = base64.RawURLEncoding.EncodeToString(authSecret)
+
+	vapidKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	channel, err := newWebPushChannel(config.WebPushConfig{
+		VAPIDPrivateKey: base64.RawURLEncoding.EncodeToString(vapidKey.Bytes()),
+		Subject:         "mailto:ops@example.com",
+	}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := Notification{Event: models.NotifyExecutionFailed, Severity: "error", Title: "Execution of fio failed"}
+	if err := channel.Send(context.Background(), models.User{ID: "u1"}, models.NotificationPreferences{WebPush: subscription}, sent); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	if headers.Get("Content-Encoding") != "aes128gcm" || headers.Get("Urgency") != "high" || headers.Get("TTL") == "" {
+		t.Errorf("headers = %v", headers)
+	}
+
+	// The VAPID token is signed by the configured key, for the push service's origin
+	var token, key string
+	fmt.Sscanf(strings.ReplaceAll(headers.Get("Authorization"), ",", ""), "vapid t=%s k=%s", &token, &key)
+	if key != base64.RawURLEncoding.EncodeToString(vapidKey.PublicKey().Bytes()) {
+		t.Errorf("VAPID key = %q", key)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token = %q", token)
+	}
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if !strings.Contains(string(claims), `"aud":"`+server.URL+`"`) {
+		t.Errorf("claims = %s, want the push service's origin as audience", claims)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	public := vapidKey.PublicKey().Bytes()
+	verifier := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(public[1:33]), Y: new(big.Int).SetBytes(public[33:])}
+	if len(signature) != 64 || !ecdsa.Verify(verifier, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		t.Error("VAPID token signature doesn't verify")
+	}
+
+	// Only the browser can decrypt the notification
+	salt, serverKey := body[:16], body[21:86]
+	serverPublic, err := ecdh.P256().NewPublicKey(serverKey)
+	if err != nil {
+		t.Fatalf("message header: %v", err)
+	}
+	shared, _ := browserKey.ECDH(serverPublic)
+	keyInfo := append(append([]byte("WebPush: info\x00"), browserKey.PublicKey().Bytes()...), serverKey...)
+	ikm := hkdf(authSecret, shared, keyInfo, 32)
+	block, _ := aes.NewCipher(hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16))
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12), body[86:], nil)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	var got Notification
+	if err := json.Unmarshal(bytes.TrimSuffix(plaintext, []byte{2}), &got); err != nil || got.Title != sent.Title {
+		t.Errorf("decrypted %q, want the notification", plaintext)
+	}
+}
@@ -0,0 +1,236 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// webPushTTL is how long push services hold a notification for a browser
+// that is offline
+const webPushTTL = 24 * time.Hour
+
+// webPushRecordSize is the record size of encrypted push messages; a
+// notification fits in one record
+const webPushRecordSize = 4096
+
+// webPushChannel sends notifications to the user's browser push subscription
+// (RFC 8030), encrypted for the browser (RFC 8291) and signed with the server's
+// VAPID key (RFC 8292)
+type webPushChannel struct {
+	client  *http.Client
+	key     *ecdsa.PrivateKey
+	public  []byte // uncompressed public key
+	subject string
+}
+
+// newWebPushChannel creates the channel from the configured VAPID key
+func newWebPushChannel(cfg config.WebPushConfig, client *http.Client) (*webPushChannel, error) {
+	key, public, err := parseVAPIDKey(cfg.VAPIDPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &webPushChannel{client: client, key: key, public: public, subject: cfg.Subject}, nil
+}
+
+// VAPIDPublicKey returns the base64url public key of a VAPID private key, which
+// browsers pass to PushManager.subscribe as applicationServerKey
+func VAPIDPublicKey(privateKey string) (string, error) {
+	_, public, err := parseVAPIDKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(public), nil
+}
+
+// parseVAPIDKey reads a base64url P-256 private key
+func parseVAPIDKey(privateKey string) (*ecdsa.PrivateKey, []byte, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(privateKey, "="))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	public := key.PublicKey().Bytes()
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}, public, nil
+}
+
+func (w *webPushChannel) Send(ctx context.Context, user models.User, prefs models.NotificationPreferences, n Notification) error {
+	subscription := prefs.WebPush
+	if subscription == nil {
+		return fmt.Errorf("user has no push subscription")
+	}
+	if !strings.HasPrefix(subscription.Endpoint, "https://") {
+		return fmt.Errorf("refusing to send notifications to %q: not an https URL", subscription.Endpoint)
+	}
+
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+	body, err := encryptWebPush(payload, subscription)
+	if err != nil {
+		return err
+	}
+	authorization, err := w.vapidAuthorization(subscription.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", fmt.Sprint(int(webPushTTL.Seconds())))
+	req.Header.Set("Authorization", authorization)
+	if n.Severity == "error" || n.Severity == "critical" {
+		req.Header.Set("Urgency", "high")
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return fmt.Errorf("push subscription has expired; the browser must subscribe again")
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("push service: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// vapidAuthorization signs a JWT naming the push service's origin, proving the
+// notification comes from the server browsers subscribed with
+func (w *webPushChannel) vapidAuthorization(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": parsed.Scheme + "://" + parsed.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": w.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, w.key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign VAPID token: %w", err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, base64.RawURLEncoding.EncodeToString(w.public)), nil
+}
+
+// encryptWebPush encrypts a payload for a subscription as a single aes128gcm
+// record (RFC 8188), keyed by an ephemeral ECDH exchange with the browser's
+// key and its authentication secret (RFC 8291)
+func encryptWebPush(payload []byte, subscription *models.WebPushSubscription) ([]byte, error) {
+	browserKey, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(subscription.Keys.P256DH, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription key: %w", err)
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(subscription.Keys.Auth, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription secret: %w", err)
+	}
+	browserPublic, err := ecdh.P256().NewPublicKey(browserKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push subscription key: %w", err)
+	}
+
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(browserPublic)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := ephemeral.PublicKey().Bytes()
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), browserKey...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm := hkdf(authSecret, shared, keyInfo, 32)
+	contentKey := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// The 0x02 delimiter marks the last (and only) record
+	plaintext := append(append([]byte{}, payload...), 2)
+	if len(plaintext)+gcm.Overhead() > webPushRecordSize {
+		return nil, fmt.Errorf("notification too large for a push message")
+	}
+
+	header := make([]byte, 0, 21+len(serverPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(serverPublic)))
+	header = append(header, serverPublic...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// hkdf derives length bytes (at most 32) from a secret with HKDF-SHA-256
+func hkdf(salt, secret, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:length]
+}
@@ -0,0 +1,29 @@
+// Command gen writes the OpenAPI document of a package's handlers, for
+// go:generate
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pranavgopavaram/ssts/internal/openapi"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "package holding the annotated handlers")
+	out := flag.String("out", "openapi.json", "file to write the document to")
+	flag.Parse()
+
+	doc, err := openapi.Generate(*dir)
+	if err == nil {
+		var data []byte
+		if data, err = doc.JSON(); err == nil {
+			err = os.WriteFile(*out, data, 0o644)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "openapi:", err)
+		os.Exit(1)
+	}
+}
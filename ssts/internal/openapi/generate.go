@@ -0,0 +1,334 @@
+package openapi
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types of the short names annotations use in @Accept and @Produce
+var mediaTypes = map[string]string{
+	"json":         "application/json",
+	"plain":        "text/plain",
+	"html":         "text/html",
+	"yaml":         "application/yaml",
+	"octet-stream": "application/octet-stream",
+}
+
+var (
+	// @Param name in type required "description" attributes
+	paramPattern = regexp.MustCompile(`^(\S+)\s+(\w+)\s+(\S+)\s+(true|false)\s*(?:"([^"]*)")?\s*(.*)$`)
+	// @Success code {kind} type "description", where only the code is required
+	responsePattern = regexp.MustCompile(`^(\d{3})\s*(?:\{(\w+)\}\s*(\S+))?\s*(?:"([^"]*)")?\s*$`)
+	// @Router path [method]
+	routerPattern = regexp.MustCompile(`^(\S+)\s+\[(\w+)\]$`)
+	// default(value) and enums(a,b) attributes of a parameter
+	attributePattern = regexp.MustCompile(`(\w+)\(([^)]*)\)`)
+)
+
+// Generate builds the document for the handlers in the package in dir. The
+// API's title, version and description come from the @title, @version and
+// @description annotations in the package's comments.
+func Generate(dir string) (*Document, error) {
+	l, err := newLoader(dir)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := l.loadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas: l.schemas,
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearerAuth": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "An access token from /api/v1/auth/login, required when the server has auth enabled",
+				},
+			},
+		},
+		// Requests are authenticated only when the server has auth enabled
+		Security: []map[string][]string{{"bearerAuth": {}}, {}},
+	}
+
+	for _, file := range pkg.files {
+		for _, group := range file.syntax.Comments {
+			for _, line := range annotationLines(group) {
+				key, value := splitAnnotation(line)
+				switch key {
+				case "@title":
+					doc.Info.Title = value
+				case "@version":
+					doc.Info.Version = value
+				case "@description":
+					doc.Info.Description = value
+				}
+			}
+		}
+
+		for _, decl := range file.syntax.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Doc == nil {
+				continue
+			}
+			path, method, operation, err := l.operation(fn, file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", fn.Name.Name, err)
+			}
+			if operation == nil {
+				continue
+			}
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = map[string]*Operation{}
+			}
+			if existing := doc.Paths[path][method]; existing != nil {
+				return nil, fmt.Errorf("%s: %s %s is already documented by %s", fn.Name.Name, strings.ToUpper(method), path, existing.OperationID)
+			}
+			doc.Paths[path][method] = operation
+		}
+	}
+
+	if doc.Info.Title == "" || doc.Info.Version == "" {
+		return nil, fmt.Errorf("no @title and @version annotations in %s", dir)
+	}
+	return doc, nil
+}
+
+// operation reads the annotations of a handler, returning no operation if it
+// has no @Router
+func (l *loader) operation(fn *ast.FuncDecl, file *goFile) (path, method string, op *Operation, err error) {
+	op = &Operation{OperationID: fn.Name.Name, Responses: map[string]*Response{}}
+	var accepts, produces []string
+	type bodyParam struct {
+		description string
+		required    bool
+		schema      *Schema
+	}
+	var body *bodyParam
+	type response struct {
+		code, description string
+		schema            *Schema
+		failure           bool
+	}
+	var responses []response
+
+	for _, line := range annotationLines(fn.Doc) {
+		key, value := splitAnnotation(line)
+		switch key {
+		case "@Summary":
+			op.Summary = value
+		case "@Description":
+			op.Description = value
+		case "@Tags":
+			for _, tag := range strings.Split(value, ",") {
+				op.Tags = append(op.Tags, strings.TrimSpace(tag))
+			}
+		case "@Accept":
+			accepts = append(accepts, mediaTypeList(value)...)
+		case "@Produce":
+			produces = append(produces, mediaTypeList(value)...)
+		case "@Param":
+			match := paramPattern.FindStringSubmatch(value)
+			if match == nil {
+				return "", "", nil, fmt.Errorf("malformed @Param %q", value)
+			}
+			name, in, typ, required, description := match[1], match[2], match[3], match[4] == "true", match[5]
+			schema, err := l.annotationSchema(typ, file)
+			if err != nil {
+				return "", "", nil, fmt.Errorf("@Param %s: %w", name, err)
+			}
+			if in == "body" {
+				body = &bodyParam{description: description, required: required, schema: schema}
+				continue
+			}
+			for _, attribute := range attributePattern.FindAllStringSubmatch(match[6], -1) {
+				switch attribute[1] {
+				case "default":
+					schema.Default = typedValue(schema, attribute[2])
+				case "enums":
+					for _, value := range strings.Split(attribute[2], ",") {
+						schema.Enum = append(schema.Enum, typedValue(schema, strings.TrimSpace(value)))
+					}
+				}
+			}
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:        name,
+				In:          in,
+				Description: description,
+				Required:    required || in == "path",
+				Schema:      schema,
+			})
+		case "@Success", "@Failure":
+			match := responsePattern.FindStringSubmatch(value)
+			if match == nil {
+				return "", "", nil, fmt.Errorf("malformed %s %q", key, value)
+			}
+			r := response{code: match[1], description: match[4], failure: key == "@Failure"}
+			switch kind, typ := match[2], match[3]; kind {
+			case "":
+			case "file":
+				r.schema = &Schema{Type: "string", Format: "binary"}
+			case "object", "string", "array":
+				schema, err := l.annotationSchema(typ, file)
+				if err != nil {
+					return "", "", nil, fmt.Errorf("%s %s: %w", key, r.code, err)
+				}
+				if kind == "array" {
+					schema = &Schema{Type: "array", Items: schema}
+				}
+				r.schema = schema
+			default:
+				return "", "", nil, fmt.Errorf("%s %s: unknown kind {%s}", key, r.code, kind)
+			}
+			responses = append(responses, r)
+		case "@Router":
+			match := routerPattern.FindStringSubmatch(value)
+			if match == nil {
+				return "", "", nil, fmt.Errorf("malformed @Router %q", value)
+			}
+			path, method = match[1], strings.ToLower(match[2])
+		}
+	}
+	if path == "" {
+		return "", "", nil, nil
+	}
+
+	if len(accepts) == 0 {
+		accepts = []string{"application/json"}
+	}
+	if len(produces) == 0 {
+		produces = []string{"application/json"}
+	}
+	if body != nil {
+		op.RequestBody = &RequestBody{
+			Description: body.description,
+			Required:    body.required,
+			Content:     contentOf(accepts, body.schema),
+		}
+	}
+	for _, r := range responses {
+		description := r.description
+		if description == "" {
+			code, _ := strconv.Atoi(r.code)
+			description = http.StatusText(code)
+		}
+		if existing := op.Responses[r.code]; existing != nil {
+			// Several annotations for the same status describe different causes
+			if r.description != "" && existing.Description != description {
+				existing.Description += "; " + description
+			}
+			continue
+		}
+		response := &Response{Description: description}
+		if r.schema != nil {
+			if r.failure {
+				// Failures are reported as JSON, whatever a success is served as
+				response.Content = contentOf([]string{"application/json"}, r.schema)
+			} else {
+				response.Content = contentOf(produces, r.schema)
+			}
+		}
+		op.Responses[r.code] = response
+	}
+	sort.SliceStable(op.Parameters, func(i, j int) bool {
+		return parameterOrder(op.Parameters[i].In) < parameterOrder(op.Parameters[j].In)
+	})
+	return path, method, op, nil
+}
+
+// annotationSchema returns the schema of a type named in an annotation, which
+// is resolved in the handler's file
+func (l *loader) annotationSchema(typ string, file *goFile) (*Schema, error) {
+	expr, err := parser.ParseExpr(typ)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type %q", typ)
+	}
+	return l.schemaOf(expr, file)
+}
+
+// contentOf describes a body served as each of the media types: as the schema
+// in JSON, and as an opaque document in the others
+func contentOf(mediaTypes []string, schema *Schema) map[string]*MediaType {
+	content := make(map[string]*MediaType, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		if mediaType == "application/json" || schema.Format == "binary" {
+			content[mediaType] = &MediaType{Schema: schema}
+		} else {
+			content[mediaType] = &MediaType{Schema: &Schema{Type: "string"}}
+		}
+	}
+	return content
+}
+
+// mediaTypeList reads the media types listed in an @Accept or @Produce
+func mediaTypeList(value string) []string {
+	var list []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if mediaType, ok := mediaTypes[name]; ok {
+			name = mediaType
+		}
+		if name != "" {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// parameterOrder orders parameters as they appear in a request
+func parameterOrder(in string) int {
+	switch in {
+	case "path":
+		return 0
+	case "query":
+		return 1
+	}
+	return 2
+}
+
+// typedValue converts an annotation's value to the type of the schema
+func typedValue(schema *Schema, value string) interface{} {
+	switch schema.Type {
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// annotationLines returns the lines of a comment that are annotations
+func annotationLines(group *ast.CommentGroup) []string {
+	var lines []string
+	for _, line := range strings.Split(group.Text(), "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "@") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitAnnotation splits an annotation into its key and value
+func splitAnnotation(line string) (key, value string) {
+	key, value, _ = strings.Cut(line, " ")
+	return key, strings.TrimSpace(value)
+}
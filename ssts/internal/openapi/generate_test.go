@@ -0,0 +1,85 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	doc, err := Generate("testdata/widgets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Info.Title != "Widgets API" || doc.Info.Version != "2.0.0" || len(doc.Paths) != 2 {
+		t.Fatalf("doc = %+v", doc)
+	}
+
+	create := doc.Paths["/widgets"]["post"]
+	if create == nil || create.OperationID != "createWidget" || !reflect.DeepEqual(create.Tags, []string{"widgets"}) {
+		t.Fatalf("create = %+v", create)
+	}
+	if len(create.Parameters) != 1 || create.Parameters[0].Schema.Type != "boolean" || create.Parameters[0].Schema.Default != false {
+		t.Errorf("create parameters = %+v", create.Parameters)
+	}
+	if body := create.RequestBody; body == nil || !body.Required || body.Content["application/json"].Schema.Ref != "#/components/schemas/widgets.Widget" {
+		t.Errorf("create body = %+v", body)
+	}
+	if got := create.Responses["400"].Description; got != "Invalid widget; Duplicate name" {
+		t.Errorf("400 description = %q", got)
+	}
+	if got := create.Responses["201"].Description; got != "Created" {
+		t.Errorf("201 description = %q", got)
+	}
+
+	download := doc.Paths["/widgets/{id}/download"]["get"]
+	if schema := download.Responses["200"].Content["text/csv"].Schema; schema.Format != "binary" {
+		t.Errorf("download schema = %+v", schema)
+	}
+	if _, ok := download.Responses["404"].Content["application/json"]; !ok {
+		t.Errorf("404 isn't JSON: %+v", download.Responses["404"])
+	}
+
+	widget := doc.Components.Schemas["widgets.Widget"]
+	if widget == nil {
+		t.Fatalf("no widget schema in %v", doc.Components.Schemas)
+	}
+	if !reflect.DeepEqual(widget.Required, []string{"name"}) {
+		t.Errorf("required = %v", widget.Required)
+	}
+	props := widget.Properties
+	if _, ok := props["secret"]; ok {
+		t.Error("unexported field documented")
+	}
+	if props["id"] == nil || props["created"].Format != "date-time" {
+		t.Errorf("embedded fields = %+v, %+v", props["id"], props["created"])
+	}
+	if name := props["name"]; *name.MaxLength != 64 {
+		t.Errorf("name = %+v", name)
+	}
+	if size := props["size"]; *size.Minimum != 1 || *size.Maximum != 10 || size.Description != "in inches" {
+		t.Errorf("size = %+v", size)
+	}
+	if color := props["color"]; color.Type != "string" || !reflect.DeepEqual(color.Enum, []interface{}{"red", "blue"}) {
+		t.Errorf("color = %+v", color)
+	}
+	if parts := props["parts"]; parts.Type != "array" || parts.Items.Ref != "#/components/schemas/widgets.Widget" {
+		t.Errorf("parts = %+v", parts)
+	}
+	if warmup := props["warmup"]; warmup.Type != "integer" || warmup.Format != "int64" {
+		t.Errorf("warmup = %+v", warmup)
+	}
+}
+
+func TestGenerateUnknownType(t *testing.T) {
+	l, err := newLoader("testdata/widgets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := l.loadDir("testdata/widgets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.annotationSchema("Gadget", pkg.files[0]); err == nil {
+		t.Error("annotation naming an unknown type accepted")
+	}
+}
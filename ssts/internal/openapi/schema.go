@@ -0,0 +1,455 @@
+package openapi
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// loader parses the module's packages as the types annotations name lead to
+// them, and builds the schemas of those types
+type loader struct {
+	modulePath string
+	moduleRoot string
+	fset       *token.FileSet
+	packages   map[string]*goPackage // by import path
+	schemas    map[string]*Schema    // component schemas of struct types, by package and type name
+}
+
+type goPackage struct {
+	name  string
+	files []*goFile
+	types map[string]*typeDecl
+}
+
+type goFile struct {
+	pkg     *goPackage
+	syntax  *ast.File
+	imports map[string]string // import paths by the name the file refers to them with
+}
+
+type typeDecl struct {
+	name string
+	spec *ast.TypeSpec
+	file *goFile
+}
+
+// newLoader returns a loader for the module containing dir
+func newLoader(dir string) (*loader, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if module, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+					return &loader{
+						modulePath: strings.Trim(strings.TrimSpace(module), `"`),
+						moduleRoot: root,
+						fset:       token.NewFileSet(),
+						packages:   map[string]*goPackage{},
+						schemas:    map[string]*Schema{},
+					}, nil
+				}
+			}
+			return nil, fmt.Errorf("no module path in %s", filepath.Join(root, "go.mod"))
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return nil, fmt.Errorf("%s is not in a module", dir)
+		}
+		root = parent
+	}
+}
+
+// loadDir parses the package in dir, which must be in the loader's module
+func (l *loader) loadDir(dir string) (*goPackage, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(l.moduleRoot, abs)
+	if err != nil {
+		return nil, err
+	}
+	return l.load(path.Join(l.modulePath, filepath.ToSlash(rel)))
+}
+
+// load parses the module's package with the import path, with the files the
+// default build would include
+func (l *loader) load(importPath string) (*goPackage, error) {
+	if pkg, ok := l.packages[importPath]; ok {
+		return pkg, nil
+	}
+	dir := filepath.Join(l.moduleRoot, filepath.FromSlash(strings.TrimPrefix(importPath, l.modulePath)))
+	buildPkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", importPath, err)
+	}
+
+	pkg := &goPackage{name: buildPkg.Name, types: map[string]*typeDecl{}}
+	l.packages[importPath] = pkg
+	for _, name := range buildPkg.GoFiles {
+		syntax, err := parser.ParseFile(l.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		file := &goFile{pkg: pkg, syntax: syntax, imports: map[string]string{}}
+		for _, spec := range syntax.Imports {
+			importPath, _ := strconv.Unquote(spec.Path.Value)
+			name := importName(importPath)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			file.imports[name] = importPath
+		}
+		for _, decl := range syntax.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.TypeSpec)
+				pkg.types[spec.Name.Name] = &typeDecl{name: spec.Name.Name, spec: spec, file: file}
+			}
+		}
+		pkg.files = append(pkg.files, file)
+	}
+	return pkg, nil
+}
+
+// lookup finds the declaration of a type named in a file, returning nil for
+// types outside the module
+func (l *loader) lookup(expr ast.Expr, file *goFile) (*typeDecl, error) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if decl, ok := file.pkg.types[e.Name]; ok {
+			return decl, nil
+		}
+		return nil, fmt.Errorf("unknown type %s", e.Name)
+	case *ast.SelectorExpr:
+		x, ok := e.X.(*ast.Ident)
+		if !ok {
+			return nil, fmt.Errorf("unsupported type %s", exprString(expr))
+		}
+		importPath, ok := file.imports[x.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown package %s", x.Name)
+		}
+		if importPath != l.modulePath && !strings.HasPrefix(importPath, l.modulePath+"/") {
+			return nil, nil
+		}
+		pkg, err := l.load(importPath)
+		if err != nil {
+			return nil, err
+		}
+		if decl, ok := pkg.types[e.Sel.Name]; ok {
+			return decl, nil
+		}
+		return nil, fmt.Errorf("unknown type %s", exprString(expr))
+	}
+	return nil, fmt.Errorf("unsupported type %s", exprString(expr))
+}
+
+// schemaOf returns the schema of a type expression in a file. Struct types
+// are referred to as components; other named types are written out in place.
+func (l *loader) schemaOf(expr ast.Expr, file *goFile) (*Schema, error) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if schema := basicSchema(e.Name); schema != nil {
+			return schema, nil
+		}
+	case *ast.SelectorExpr:
+		if schema := externalSchema(exprString(e), file); schema != nil {
+			return schema, nil
+		}
+	case *ast.StarExpr:
+		return l.schemaOf(e.X, file)
+	case *ast.ArrayType:
+		if ident, ok := e.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			return &Schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := l.schemaOf(e.Elt, file)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case *ast.MapType:
+		values, err := l.schemaOf(e.Value, file)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case *ast.InterfaceType:
+		return &Schema{}, nil
+	case *ast.StructType:
+		return l.structSchema(e, file)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", exprString(expr))
+	}
+
+	decl, err := l.lookup(expr, file)
+	if err != nil {
+		return nil, err
+	}
+	if decl == nil {
+		// Outside the module, and not one the generator knows
+		return &Schema{}, nil
+	}
+	return l.namedSchema(decl)
+}
+
+// namedSchema returns the schema of a declared type
+func (l *loader) namedSchema(decl *typeDecl) (*Schema, error) {
+	st, ok := decl.spec.Type.(*ast.StructType)
+	if !ok {
+		return l.schemaOf(decl.spec.Type, decl.file)
+	}
+
+	name := decl.file.pkg.name + "." + decl.name
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := l.schemas[name]; ok {
+		return ref, nil
+	}
+	// Recorded before the fields are, for types that refer to themselves
+	l.schemas[name] = &Schema{}
+	schema, err := l.structSchema(st, decl.file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	l.schemas[name] = schema
+	return ref, nil
+}
+
+// structSchema returns the schema of a struct's JSON encoding, with the
+// constraints of its fields' binding tags
+func (l *loader) structSchema(st *ast.StructType, file *goFile) (*Schema, error) {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, field := range st.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			value, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(value)
+		}
+		jsonName, _, _ := strings.Cut(tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+
+		names := field.Names
+		if len(names) == 0 {
+			embedded := field.Type
+			if star, ok := embedded.(*ast.StarExpr); ok {
+				embedded = star.X
+			}
+			if jsonName == "" {
+				// The embedded struct's fields are promoted into this one
+				decl, err := l.lookup(embedded, file)
+				if err != nil {
+					return nil, err
+				}
+				if decl == nil {
+					continue
+				}
+				embeddedStruct, ok := decl.spec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				promoted, err := l.structSchema(embeddedStruct, decl.file)
+				if err != nil {
+					return nil, err
+				}
+				for name, property := range promoted.Properties {
+					if _, ok := schema.Properties[name]; !ok {
+						schema.Properties[name] = property
+					}
+				}
+				schema.Required = append(schema.Required, promoted.Required...)
+				continue
+			}
+			names = []*ast.Ident{ast.NewIdent(exprString(embedded))}
+		}
+
+		for _, ident := range names {
+			if !ident.IsExported() && len(field.Names) > 0 {
+				continue
+			}
+			name := jsonName
+			if name == "" {
+				name = ident.Name
+			}
+			property, err := l.schemaOf(field.Type, file)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", ident.Name, err)
+			}
+			required := applyBinding(property, tag.Get("binding"))
+			if property.Ref != "" && (property.Description != "" || fieldDescription(field) != "") {
+				// Siblings of a reference are ignored, so describe it around one
+				property = &Schema{AllOf: []*Schema{property}}
+			}
+			if description := fieldDescription(field); description != "" {
+				if property.Description != "" {
+					description += " (" + property.Description + ")"
+				}
+				property.Description = description
+			}
+			schema.Properties[name] = property
+			if required {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+	return schema, nil
+}
+
+// applyBinding adds the constraints of a binding tag to a field's schema,
+// reporting whether the field is required
+func applyBinding(schema *Schema, binding string) (required bool) {
+	if binding == "" || schema.Ref != "" {
+		return strings.Contains(binding, "required")
+	}
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "min", "gte", "max", "lte", "gt", "lt", "len":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			setLimit(schema, name, limit)
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, typedValue(schema, value))
+			}
+		case "hexadecimal":
+			schema.Pattern = "^[0-9a-fA-F]+$"
+		}
+	}
+	return required
+}
+
+// setLimit sets a bound of a binding rule, which limits the value of numbers,
+// the length of strings and the count of arrays
+func setLimit(schema *Schema, rule string, limit float64) {
+	n := int(limit)
+	switch schema.Type {
+	case "integer", "number":
+		switch rule {
+		case "min", "gte", "len":
+			schema.Minimum = &limit
+		case "gt":
+			schema.Minimum, schema.ExclusiveMinimum = &limit, true
+		}
+		switch rule {
+		case "max", "lte", "len":
+			schema.Maximum = &limit
+		case "lt":
+			schema.Maximum, schema.ExclusiveMaximum = &limit, true
+		}
+	case "string":
+		if rule == "min" || rule == "gte" || rule == "len" {
+			schema.MinLength = &n
+		}
+		if rule == "max" || rule == "lte" || rule == "len" {
+			schema.MaxLength = &n
+		}
+	case "array":
+		if rule == "min" || rule == "gte" || rule == "len" {
+			schema.MinItems = &n
+		}
+		if rule == "max" || rule == "lte" || rule == "len" {
+			schema.MaxItems = &n
+		}
+	}
+}
+
+// fieldDescription returns the comment on a struct field
+func fieldDescription(field *ast.Field) string {
+	group := field.Comment
+	if group == nil {
+		group = field.Doc
+	}
+	if group == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(group.Text()), " ")
+}
+
+// basicSchema returns the schema of a predeclared type, or nil if the name
+// isn't one
+func basicSchema(name string) *Schema {
+	switch name {
+	case "string", "error":
+		return &Schema{Type: "string"}
+	case "bool", "boolean":
+		return &Schema{Type: "boolean"}
+	case "int", "int8", "int16", "uint", "uint8", "uint16", "integer":
+		return &Schema{Type: "integer"}
+	case "int32", "uint32", "rune":
+		return &Schema{Type: "integer", Format: "int32"}
+	case "int64", "uint64":
+		return &Schema{Type: "integer", Format: "int64"}
+	case "float32":
+		return &Schema{Type: "number", Format: "float"}
+	case "float64", "number":
+		return &Schema{Type: "number", Format: "double"}
+	case "any":
+		return &Schema{}
+	}
+	return nil
+}
+
+// externalSchema returns the schema of a type from outside the module that
+// encodes to JSON as something other than its fields, or nil if it isn't one
+func externalSchema(name string, file *goFile) *Schema {
+	pkg, typ, _ := strings.Cut(name, ".")
+	switch file.imports[pkg] + "." + typ {
+	case "time.Time":
+		return &Schema{Type: "string", Format: "date-time"}
+	case "time.Duration":
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case "encoding/json.RawMessage":
+		return &Schema{}
+	}
+	return nil
+}
+
+// importName returns the name a package is referred to by when imported
+// without one, assuming it is the last element of its path that isn't a major
+// version
+func importName(importPath string) string {
+	name := path.Base(importPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(importPath))
+	}
+	return strings.TrimPrefix(name, "go-")
+}
+
+// exprString writes a type expression as Go source
+func exprString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	case *ast.StarExpr:
+		return "*" + exprString(e.X)
+	case *ast.ArrayType:
+		return "[]" + exprString(e.Elt)
+	case *ast.MapType:
+		return "map[" + exprString(e.Key) + "]" + exprString(e.Value)
+	}
+	return fmt.Sprintf("%T", expr)
+}
@@ -0,0 +1,56 @@
+package widgets
+
+import "time"
+
+// @title Widgets API
+// @version 2.0.0
+
+// Widget is what the API serves
+type Widget struct {
+	Base
+	Name   string        `json:"name" binding:"required,max=64"`
+	Size   int           `json:"size" binding:"gte=1,lte=10"` // in inches
+	Color  Color         `json:"color" binding:"omitempty,oneof=red blue"`
+	Parts  []*Widget     `json:"parts,omitempty"`
+	Warmup time.Duration `json:"warmup"`
+	secret string
+}
+
+// Base holds the fields every resource has
+type Base struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+// Color is a widget's color
+type Color string
+
+// ErrorResponse is a failure
+type ErrorResponse struct {
+	Message string `json:"message"`
+}
+
+type handler struct{}
+
+// @Summary Create widget
+// @Tags widgets
+// @Accept json
+// @Produce json
+// @Param dry_run query bool false "Only validate" default(false)
+// @Param widget body Widget true "Widget"
+// @Success 201 {object} Widget
+// @Failure 400 {object} ErrorResponse "Invalid widget"
+// @Failure 400 {object} ErrorResponse "Duplicate name"
+// @Router /widgets [post]
+func (h *handler) createWidget() {}
+
+// @Summary Download widget
+// @Produce text/csv
+// @Param id path string true "Widget ID"
+// @Success 200 {file} file
+// @Failure 404 {object} ErrorResponse
+// @Router /widgets/{id}/download [get]
+func (h *handler) downloadWidget() {}
+
+// helper has no annotations
+func helper() {}
@@ -0,0 +1,33 @@
+package plugins
+
+import "github.com/pranavgopavaram/ssts/pkg/models"
+
+// Pauser is implemented by plugins whose load can be held while running without
+// ending the run. SetPaused stops generating load when paused is true and takes
+// it up again where it left off when it is false.
+type Pauser interface {
+	SetPaused(paused bool)
+}
+
+// CapabilityDeclarer is implemented by plugins with effects or requirements that
+// don't follow from the interfaces they implement. Only the Destructive and
+// RequiresRoot flags of what DeclaredCapabilities returns are used.
+type CapabilityDeclarer interface {
+	DeclaredCapabilities() models.PluginCapabilities
+}
+
+// Capabilities returns what the plugin supports and requires: pausing, throttling
+// and progress reports from the interfaces it implements, and the rest from what
+// it declares
+func Capabilities(plugin StressPlugin) models.PluginCapabilities {
+	var capabilities models.PluginCapabilities
+	if declarer, ok := plugin.(CapabilityDeclarer); ok {
+		declared := declarer.DeclaredCapabilities()
+		capabilities.Destructive = declared.Destructive
+		capabilities.RequiresRoot = declared.RequiresRoot
+	}
+	_, capabilities.Pause = plugin.(Pauser)
+	_, capabilities.Throttle = plugin.(LoadShaper)
+	_, capabilities.Progress = plugin.(ProgressReporter)
+	return capabilities
+}
@@ -0,0 +1,109 @@
+package plugins
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Default working-set sizes in KB, chosen to land in L1, L2, L3 and DRAM respectively
+var defaultWorkingSetsKB = []int{32, 256, 8192, 65536}
+
+// cacheLineSize is the stride used by the cache-thrashing kernel
+const cacheLineSize = 64
+
+// bandwidthBuffer holds the STREAM arrays for a single working set
+type bandwidthBuffer struct {
+	sizeKB int
+	a      []float64
+	b      []float64
+	c      []float64
+	bytes  *int64 // bytes moved, shared across workers for this working set
+}
+
+// newBandwidthBuffers allocates one set of STREAM arrays per working set. The
+// three arrays together occupy the configured working-set size.
+func newBandwidthBuffers(workingSetsKB []int, counters map[int]*int64) []*bandwidthBuffer {
+	buffers := make([]*bandwidthBuffer, 0, len(workingSetsKB))
+	for _, sizeKB := range workingSetsKB {
+		n := sizeKB * 1024 / 8 / 3
+		if n < 1 {
+			n = 1
+		}
+		buf := &bandwidthBuffer{
+			sizeKB: sizeKB,
+			a:      make([]float64, n),
+			b:      make([]float64, n),
+			c:      make([]float64, n),
+			bytes:  counters[sizeKB],
+		}
+		for i := range buf.a {
+			buf.a[i] = 1.0
+			buf.b[i] = 2.0
+		}
+		buffers = append(buffers, buf)
+	}
+	return buffers
+}
+
+// runStream runs the selected STREAM kernel(s) over the buffer and records bytes moved
+func (buf *bandwidthBuffer) runStream(kernel string) {
+	const scalar = 3.0
+	n := int64(len(buf.a))
+	var moved int64
+
+	if kernel == "copy" || kernel == "all" {
+		copy(buf.c, buf.a)
+		moved += 2 * 8 * n
+	}
+	if kernel == "scale" || kernel == "all" {
+		for i := range buf.b {
+			buf.b[i] = scalar * buf.c[i]
+		}
+		moved += 2 * 8 * n
+	}
+	if kernel == "add" || kernel == "all" {
+		for i := range buf.c {
+			buf.c[i] = buf.a[i] + buf.b[i]
+		}
+		moved += 3 * 8 * n
+	}
+	if kernel == "triad" || kernel == "all" {
+		for i := range buf.a {
+			buf.a[i] = buf.b[i] + scalar*buf.c[i]
+		}
+		moved += 3 * 8 * n
+	}
+
+	atomic.AddInt64(buf.bytes, moved)
+}
+
+// runCacheThrash touches one value per cache line across the whole working set,
+// defeating spatial locality so every access misses the faster cache tiers
+func (buf *bandwidthBuffer) runCacheThrash() {
+	stride := cacheLineSize / 8
+	var moved int64
+
+	for _, arr := range [][]float64{buf.a, buf.b, buf.c} {
+		for offset := 0; offset < stride; offset++ {
+			for i := offset; i < len(arr); i += stride {
+				arr[i]++
+			}
+		}
+		// Each touch reads and writes a full cache line
+		moved += 2 * int64(len(arr)) * 8
+	}
+
+	atomic.AddInt64(buf.bytes, moved)
+}
+
+// workingSetLabel formats a working-set size for metric keys
+func workingSetLabel(sizeKB int) string {
+	switch {
+	case sizeKB >= 1024*1024 && sizeKB%(1024*1024) == 0:
+		return fmt.Sprintf("%dGB", sizeKB/(1024*1024))
+	case sizeKB >= 1024 && sizeKB%1024 == 0:
+		return fmt.Sprintf("%dMB", sizeKB/1024)
+	default:
+		return fmt.Sprintf("%dKB", sizeKB)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"math"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pranavgopavaram/ssts/pkg/models"
@@ -15,9 +16,14 @@ import (
 // CPUStressConfig defines the configuration for CPU stress testing
 type CPUStressConfig struct {
 	Workers   int    `json:"workers"`                      // Number of worker goroutines (0 = number of CPUs)
-	Algorithm string `json:"algorithm"`                    // prime, fibonacci, matrix, pi
+	Algorithm string `json:"algorithm"`                    // prime, fibonacci, matrix, pi, stream, cache
 	Intensity int    `json:"intensity"`                    // 1-100 scale
 	RampUp    bool   `json:"ramp_up" default:"true"`      // Gradual intensity increase
+	WorkingSetKB []int  `json:"working_set_kb"` // Working-set sizes for stream/cache algorithms
+	StreamKernel string `json:"stream_kernel"`  // copy, scale, add, triad, all
+	// ContinueOnError keeps the test running after a calculation returns a wrong
+	// result, counting errors, instead of failing it
+	ContinueOnError bool `json:"continue_on_error"`
 }
 
 // CPUStressPlugin implements CPU stress testing
@@ -28,6 +34,17 @@ type CPUStressPlugin struct {
 	stopChan        chan bool
 	currentWorkers  int
 	operationsCount int64
+	intensity       int64          // target intensity workers run at, raised step by step during ramp-up
+	intensityScale  int64          // percentage of target intensity, lowered during ramp-down
+	loadLevel       int64          // percentage of target intensity set by the run's load profile
+	paused          int32          // 1 while the run is paused; workers idle
+	bandwidthBytes  map[int]*int64 // bytes moved per working set (KB)
+	phase           string      // ramp-up, steady or ramp-down, for Progress
+	calculation     calculation // the compute algorithm's work and golden value
+	verifiedOps     int64       // calculations checked against their golden value
+	calcErrors      int64
+	errorDetails    []CalculationError
+	failed          chan error // receives the first calculation error unless ContinueOnError
 }
 
 // CPUMetrics tracks CPU stress test metrics
@@ -37,6 +54,57 @@ type CPUMetrics struct {
 	ThermalThrottling   bool    `json:"thermal_throttle"`
 	CoreUtilization     []float64 `json:"core_usage"`
 	WorkerCount         int     `json:"worker_count"`
+	BandwidthGBps       map[string]float64 `json:"bandwidth_gbps"`
+}
+
+// maxReportedCalculationErrors caps the calculation errors kept for reporting;
+// errors beyond it are still counted
+const maxReportedCalculationErrors = 20
+
+// CalculationError describes a calculation that didn't return its golden value
+type CalculationError struct {
+	Algorithm string    `json:"algorithm"`
+	Expected  float64   `json:"expected"`
+	Actual    float64   `json:"actual"`
+	Time      time.Time `json:"time"`
+}
+
+// idlePeriod is how long a worker with nothing to do waits before checking its
+// intensity again
+const idlePeriod = 100 * time.Millisecond
+
+// Work done by each compute algorithm per operation
+const (
+	primeLimit     = 10000
+	fibonacciN     = 35
+	matrixSize     = 100
+	piSeriesTerms  = 1000000
+	primesBelow10k = 1229    // primes up to primeLimit
+	fibonacci35    = 9227465 // the fibonacciN-th Fibonacci number
+)
+
+// calculation is a compute algorithm whose result is checked after every run,
+// so wrong answers under load, such as from a marginal CPU, are caught rather
+// than silently burning cycles
+type calculation struct {
+	run       func() float64
+	expected  float64
+	tolerance float64 // largest correct deviation from expected
+}
+
+// correct reports whether a result is within tolerance of the golden value
+func (c calculation) correct(result float64) bool {
+	return math.Abs(result-c.expected) <= c.tolerance
+}
+
+// calculations are the compute algorithms by name. Prime counting, Fibonacci and
+// the matrix checksum are exact; the pi series' truncation error is bounded by
+// its first omitted term.
+var calculations = map[string]calculation{
+	"prime":     {run: func() float64 { return float64(calculatePrimes(primeLimit)) }, expected: primesBelow10k},
+	"fibonacci": {run: func() float64 { return float64(calculateFibonacci(fibonacciN)) }, expected: fibonacci35},
+	"matrix":    {run: func() float64 { return matrixMultiplication(matrixSize) }, expected: float64(matrixChecksum(matrixSize))},
+	"pi":        {run: func() float64 { return calculatePi(piSeriesTerms) }, expected: math.Pi, tolerance: 4.0/(2*piSeriesTerms+1) + 1e-9},
 }
 
 // NewCPUStressPlugin creates a new CPU stress plugin
@@ -59,7 +127,7 @@ func (c *CPUStressPlugin) Version() string {
 
 // Description returns the plugin description
 func (c *CPUStressPlugin) Description() string {
-	return "CPU stress testing plugin with multiple algorithms"
+	return "CPU stress testing plugin with multiple algorithms, including memory bandwidth and cache stress"
 }
 
 // ConfigSchema returns the JSON schema for configuration
@@ -76,12 +144,13 @@ func (c *CPUStressPlugin) ConfigSchema() []byte {
 			},
 			"algorithm": {
 				"type": "string",
-				"enum": ["prime", "fibonacci", "matrix", "pi"],
+				"enum": ["prime", "fibonacci", "matrix", "pi", "stream", "cache"],
 				"default": "prime",
 				"description": "CPU stress algorithm to use"
 			},
 			"intensity": {
 				"type": "integer",
+				"x-unit": "%",
 				"minimum": 1,
 				"maximum": 100,
 				"default": 70,
@@ -91,6 +160,30 @@ func (c *CPUStressPlugin) ConfigSchema() []byte {
 				"type": "boolean",
 				"default": true,
 				"description": "Enable gradual intensity ramp-up"
+			},
+			"working_set_kb": {
+				"type": "array",
+				"x-group": "Bandwidth",
+				"items": {
+					"type": "integer",
+					"minimum": 1,
+					"maximum": 4194304
+				},
+				"default": [32, 256, 8192, 65536],
+				"description": "Working-set sizes in KB for the stream and cache algorithms (allocated per worker)"
+			},
+			"stream_kernel": {
+				"type": "string",
+				"x-group": "Bandwidth",
+				"enum": ["copy", "scale", "add", "triad", "all"],
+				"default": "all",
+				"description": "STREAM kernel used by the stream algorithm"
+			},
+			"continue_on_error": {
+				"type": "boolean",
+				"x-group": "Verification",
+				"default": false,
+				"description": "Keep running after a calculation returns a wrong result, counting errors, instead of failing the test. Results of the prime, fibonacci, matrix and pi algorithms are checked against golden values."
 			}
 		},
 		"required": ["algorithm"]
@@ -119,6 +212,24 @@ func (c *CPUStressPlugin) Initialize(config interface{}) error {
 	if c.config.Algorithm == "" {
 		c.config.Algorithm = "prime"
 	}
+	if len(c.config.WorkingSetKB) == 0 {
+		c.config.WorkingSetKB = defaultWorkingSetsKB
+	}
+	if c.config.StreamKernel == "" {
+		c.config.StreamKernel = "all"
+	}
+	if !c.isBandwidthAlgorithm() {
+		calc, ok := calculations[c.config.Algorithm]
+		if !ok {
+			calc = calculations["prime"]
+		}
+		c.calculation = calc
+	}
+
+	c.bandwidthBytes = make(map[int]*int64, len(c.config.WorkingSetKB))
+	for _, sizeKB := range c.config.WorkingSetKB {
+		c.bandwidthBytes[sizeKB] = new(int64)
+	}
 
 	c.currentWorkers = c.config.Workers
 	c.metrics.WorkerCount = c.currentWorkers
@@ -130,78 +241,218 @@ func (c *CPUStressPlugin) Initialize(config interface{}) error {
 func (c *CPUStressPlugin) Execute(ctx context.Context, params models.TestParams) error {
 	c.mu.Lock()
 	c.operationsCount = 0
+	c.calcErrors = 0
+	c.errorDetails = nil
+	c.verifiedOps = 0
+	c.failed = make(chan error, 1)
+	c.phase = models.PhaseSteady
+	if c.config.RampUp {
+		c.phase = models.PhaseRampUp
+	}
 	c.mu.Unlock()
+	atomic.StoreInt64(&c.intensityScale, 100)
+	atomic.StoreInt64(&c.loadLevel, 100)
+	intensity := c.config.Intensity
+	if c.config.RampUp {
+		intensity = c.config.Intensity / rampUpSteps
+	}
+	atomic.StoreInt64(&c.intensity, int64(intensity))
 
+	// The workers, and everything else the run started, stop once it returns
+	workCtx, stop := context.WithCancel(ctx)
 	var wg sync.WaitGroup
-	
+	defer wg.Wait()
+	defer stop()
+
 	// Start metrics collection
-	go c.collectMetrics(ctx)
+	go c.collectMetrics(workCtx)
+
+	// Ramp down at the end of the run if requested
+	if params.RampDown > 0 {
+		go c.rampDown(workCtx, params)
+	}
+
+	c.startWorkers(workCtx, &wg)
 
 	// Ramp up if enabled
 	if c.config.RampUp {
-		return c.executeWithRampUp(ctx, params, &wg)
+		return c.executeWithRampUp(ctx, params)
 	}
 
-	return c.executeFullIntensity(ctx, params, &wg)
+	return c.waitRun(ctx, params.Duration)
+}
+
+// RampsDown reports that the intensity is lowered over the run's ramp-down window
+func (c *CPUStressPlugin) RampsDown() bool {
+	return true
 }
 
-// executeWithRampUp gradually increases intensity
-func (c *CPUStressPlugin) executeWithRampUp(ctx context.Context, params models.TestParams, wg *sync.WaitGroup) error {
+// FollowsDeadline reports that the run, and its ramp-down, follow the context's
+// deadline as it is pushed back
+func (c *CPUStressPlugin) FollowsDeadline() bool {
+	return true
+}
+
+// rampUpSteps is how many steps ramp-up raises the intensity in
+const rampUpSteps = 10
+
+// executeWithRampUp gradually raises the intensity the workers run at, starting
+// from the first step's
+func (c *CPUStressPlugin) executeWithRampUp(ctx context.Context, params models.TestParams) error {
 	rampUpDuration := time.Duration(float64(params.Duration) * 0.1) // 10% of total duration
 	if rampUpDuration < 10*time.Second {
 		rampUpDuration = 10 * time.Second
 	}
 
-	steps := 10
-	stepDuration := rampUpDuration / time.Duration(steps)
-	
-	for step := 1; step <= steps; step++ {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+	stepDuration := rampUpDuration / rampUpSteps
+	for step := 1; step <= rampUpSteps; step++ {
+		atomic.StoreInt64(&c.intensity, int64(c.config.Intensity*step/rampUpSteps))
+		if err := c.wait(ctx, stepDuration); err != nil {
+			return err
 		}
-
-		intensity := (c.config.Intensity * step) / steps
-		c.startWorkers(ctx, intensity, wg)
-		
-		time.Sleep(stepDuration)
 	}
 
-	// Run at full intensity for remaining time
+	// Run at full intensity for remaining time, unless ramp-down has already begun
+	c.setPhase(models.PhaseRampUp, models.PhaseSteady)
 	remainingDuration := params.Duration - rampUpDuration
-	time.Sleep(remainingDuration)
-
-	return nil
+	return c.waitRun(ctx, remainingDuration)
 }
 
-// executeFullIntensity runs at full intensity immediately
-func (c *CPUStressPlugin) executeFullIntensity(ctx context.Context, params models.TestParams, wg *sync.WaitGroup) error {
-	c.startWorkers(ctx, c.config.Intensity, wg)
-	
+// wait waits for d, returning early with an error if ctx is done or a
+// calculation returned a wrong result
+func (c *CPUStressPlugin) wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-time.After(params.Duration):
+	case err := <-c.failed:
+		return err
+	case <-timer.C:
 		return nil
 	}
 }
 
-// startWorkers starts the CPU stress workers
-func (c *CPUStressPlugin) startWorkers(ctx context.Context, intensity int, wg *sync.WaitGroup) {
+// waitRun waits for the rest of the run: d, then for as long as the context's
+// deadline has been pushed back past it
+func (c *CPUStressPlugin) waitRun(ctx context.Context, d time.Duration) error {
+	for {
+		if err := c.wait(ctx, d); err != nil {
+			return err
+		}
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return nil
+		}
+		if d = time.Until(deadline); d <= 0 {
+			return nil
+		}
+	}
+}
+
+// rampDown gradually lowers intensity over the final params.RampDown of the run,
+// which ends at the context's deadline if it has one
+func (c *CPUStressPlugin) rampDown(ctx context.Context, params models.TestParams) {
+	rampDownDuration := params.RampDown
+	if rampDownDuration > params.Duration {
+		rampDownDuration = params.Duration
+	}
+
+	// Wait until the ramp-down window begins, later if the deadline is pushed back
+	end := time.Now().Add(params.Duration)
+	for {
+		if deadline, ok := ctx.Deadline(); ok {
+			end = deadline
+		}
+		wait := time.Until(end.Add(-rampDownDuration))
+		if wait <= 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+
+	c.setPhase("", models.PhaseRampDown)
+
+	steps := 10
+	stepDuration := rampDownDuration / time.Duration(steps)
+
+	for step := steps - 1; step >= 0; step-- {
+		atomic.StoreInt64(&c.intensityScale, int64(step*100/steps))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(stepDuration):
+		}
+	}
+}
+
+// setPhase moves to phase if the current phase is from, or whatever it is when from is empty
+func (c *CPUStressPlugin) setPhase(from, phase string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if from == "" || c.phase == from {
+		c.phase = phase
+	}
+}
+
+// Progress reports the current phase; the percentage is left to the orchestrator
+// as the run is bounded by its duration alone
+func (c *CPUStressPlugin) Progress() (float64, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return -1, c.phase
+}
+
+// startWorkers starts the fixed pool of workers the run uses throughout. Each
+// has its own bandwidth buffers, bounding memory to workers x working sets.
+func (c *CPUStressPlugin) startWorkers(ctx context.Context, wg *sync.WaitGroup) {
 	for i := 0; i < c.currentWorkers; i++ {
+		var buffers []*bandwidthBuffer
+		if c.isBandwidthAlgorithm() {
+			buffers = newBandwidthBuffers(c.config.WorkingSetKB, c.bandwidthBytes)
+		}
 		wg.Add(1)
-		go c.worker(ctx, intensity, wg)
+		go c.worker(ctx, buffers, wg)
 	}
 }
 
-// worker performs CPU intensive operations
-func (c *CPUStressPlugin) worker(ctx context.Context, intensity int, wg *sync.WaitGroup) {
-	defer wg.Done()
+// SetLoadLevel scales the intensity workers run at to the level of the run's
+// load profile
+func (c *CPUStressPlugin) SetLoadLevel(percent int) {
+	atomic.StoreInt64(&c.loadLevel, int64(percent))
+}
+
+// SetPaused idles the workers while paused; they keep their pool and buffers
+// and take up the load again once resumed
+func (c *CPUStressPlugin) SetPaused(paused bool) {
+	var value int32
+	if paused {
+		value = 1
+	}
+	atomic.StoreInt32(&c.paused, value)
+}
+
+// effectiveIntensity is the percentage of the time workers are busy: the target
+// intensity, scaled by the load profile and down during ramp-down, or none while
+// paused
+func (c *CPUStressPlugin) effectiveIntensity() int64 {
+	if atomic.LoadInt32(&c.paused) != 0 {
+		return 0
+	}
+	return atomic.LoadInt64(&c.intensity) * atomic.LoadInt64(&c.loadLevel) / 100 * atomic.LoadInt64(&c.intensityScale) / 100
+}
 
-	// Calculate work/sleep ratio based on intensity
-	workTime := time.Duration(intensity) * time.Millisecond
-	sleepTime := time.Duration(100-intensity) * time.Millisecond
+// worker performs CPU intensive operations until ctx is done. Its duty cycle
+// follows the effective intensity: after each operation it rests for as long
+// as keeps its busy share of the time at the intensity, however long
+// operations take.
+func (c *CPUStressPlugin) worker(ctx context.Context, buffers []*bandwidthBuffer, wg *sync.WaitGroup) {
+	defer wg.Done()
 
 	for {
 		select {
@@ -212,41 +463,101 @@ func (c *CPUStressPlugin) worker(ctx context.Context, intensity int, wg *sync.Wa
 		default:
 		}
 
-		// Perform CPU intensive work
+		intensity := c.effectiveIntensity()
+		if intensity <= 0 {
+			c.rest(ctx, idlePeriod)
+			continue
+		}
+
 		start := time.Now()
-		c.performWork()
-		workDuration := time.Since(start)
+		c.performWork(buffers)
+		busy := time.Since(start)
 
-		// Increment operations counter
 		c.mu.Lock()
 		c.operationsCount++
 		c.mu.Unlock()
 
-		// Sleep if needed to maintain intensity
-		if workDuration < workTime && sleepTime > 0 {
-			time.Sleep(sleepTime)
+		if intensity < 100 {
+			c.rest(ctx, busy*time.Duration(100-intensity)/time.Duration(intensity))
+		}
+	}
+}
+
+// rest pauses a worker for d, or until the run stops
+func (c *CPUStressPlugin) rest(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-c.stopChan:
+	case <-timer.C:
+	}
+}
+
+// performWork executes the configured algorithm, checking the result of a
+// compute algorithm against its golden value
+func (c *CPUStressPlugin) performWork(buffers []*bandwidthBuffer) {
+	if c.isBandwidthAlgorithm() {
+		c.performBandwidthWork(buffers)
+		return
+	}
+	c.checkResult(c.calculation.run())
+}
+
+// checkResult counts a verified calculation and records it if the result is
+// wrong, failing the test unless ContinueOnError is set
+func (c *CPUStressPlugin) checkResult(result float64) {
+	c.mu.Lock()
+	c.verifiedOps++
+	if c.calculation.correct(result) {
+		c.mu.Unlock()
+		return
+	}
+
+	calcErr := CalculationError{Algorithm: c.config.Algorithm, Expected: c.calculation.expected, Actual: result, Time: time.Now()}
+	c.calcErrors++
+	if len(c.errorDetails) < maxReportedCalculationErrors {
+		c.errorDetails = append(c.errorDetails, calcErr)
+	}
+	c.mu.Unlock()
+
+	if !c.config.ContinueOnError {
+		select {
+		case c.failed <- fmt.Errorf("%w: calculation error detected: %s returned %v, expected %v",
+			ErrPluginExecution, calcErr.Algorithm, calcErr.Actual, calcErr.Expected):
+		default:
 		}
 	}
 }
 
-// performWork executes the configured algorithm
-func (c *CPUStressPlugin) performWork() {
-	switch c.config.Algorithm {
-	case "prime":
-		c.calculatePrimes(10000)
-	case "fibonacci":
-		c.calculateFibonacci(35)
-	case "matrix":
-		c.matrixMultiplication(100)
-	case "pi":
-		c.calculatePi(1000000)
-	default:
-		c.calculatePrimes(10000)
+// accuracy returns the percentage of verified calculations that were correct,
+// and whether any were verified. Callers must hold c.mu.
+func (c *CPUStressPlugin) accuracy() (float64, bool) {
+	if c.verifiedOps == 0 {
+		return 0, false
 	}
+	return 100 * float64(c.verifiedOps-c.calcErrors) / float64(c.verifiedOps), true
 }
 
-// calculatePrimes finds prime numbers up to n
-func (c *CPUStressPlugin) calculatePrimes(n int) {
+// isBandwidthAlgorithm reports whether the configured algorithm stresses memory bandwidth
+func (c *CPUStressPlugin) isBandwidthAlgorithm() bool {
+	return c.config.Algorithm == "stream" || c.config.Algorithm == "cache"
+}
+
+// performBandwidthWork runs one pass of the bandwidth algorithm over a worker's working sets
+func (c *CPUStressPlugin) performBandwidthWork(buffers []*bandwidthBuffer) {
+	for _, buf := range buffers {
+		if c.config.Algorithm == "cache" {
+			buf.runCacheThrash()
+		} else {
+			buf.runStream(c.config.StreamKernel)
+		}
+	}
+}
+
+// calculatePrimes counts the prime numbers up to n
+func calculatePrimes(n int) int {
+	count := 0
 	for i := 2; i <= n; i++ {
 		isPrime := true
 		for j := 2; j*j <= i; j++ {
@@ -255,20 +566,24 @@ func (c *CPUStressPlugin) calculatePrimes(n int) {
 				break
 			}
 		}
-		_ = isPrime
+		if isPrime {
+			count++
+		}
 	}
+	return count
 }
 
 // calculateFibonacci calculates fibonacci number (recursive)
-func (c *CPUStressPlugin) calculateFibonacci(n int) int {
+func calculateFibonacci(n int) int {
 	if n <= 1 {
 		return n
 	}
-	return c.calculateFibonacci(n-1) + c.calculateFibonacci(n-2)
+	return calculateFibonacci(n-1) + calculateFibonacci(n-2)
 }
 
-// matrixMultiplication performs matrix multiplication
-func (c *CPUStressPlugin) matrixMultiplication(size int) {
+// matrixMultiplication multiplies two matrices and returns the sum of the
+// product's elements
+func matrixMultiplication(size int) float64 {
 	a := make([][]float64, size)
 	b := make([][]float64, size)
 	result := make([][]float64, size)
@@ -285,26 +600,40 @@ func (c *CPUStressPlugin) matrixMultiplication(size int) {
 	}
 
 	// Multiply matrices
+	var checksum float64
 	for i := 0; i < size; i++ {
 		for j := 0; j < size; j++ {
 			for k := 0; k < size; k++ {
 				result[i][j] += a[i][k] * b[k][j]
 			}
+			checksum += result[i][j]
 		}
 	}
+	return checksum
 }
 
-// calculatePi calculates pi using Monte Carlo method
-func (c *CPUStressPlugin) calculatePi(iterations int) float64 {
-	inside := 0
-	for i := 0; i < iterations; i++ {
-		x := float64(i%1000) / 1000.0
-		y := float64((i*7)%1000) / 1000.0
-		if math.Sqrt(x*x+y*y) <= 1.0 {
-			inside++
-		}
+// matrixChecksum is the sum of the elements of the product matrixMultiplication
+// computes, in closed form. With a[i][k] = i+k and b[k][j] = k*j, element
+// (i, j) is j*(i*S1 + S2) where S1 and S2 are the sums of k and k squared, so
+// the elements sum to S1*(S1*S1 + size*S2). Every intermediate is an integer
+// well within float64's exact range, so the product must match it exactly.
+func matrixChecksum(size int) int64 {
+	n := int64(size)
+	s1 := n * (n - 1) / 2
+	s2 := (n - 1) * n * (2*n - 1) / 6
+	return s1 * (s1*s1 + n*s2)
+}
+
+// calculatePi approximates pi with the first terms of the Leibniz series
+// 4*(1 - 1/3 + 1/5 - ...), whose error is less than the first omitted term
+func calculatePi(terms int) float64 {
+	var sum float64
+	sign := 1.0
+	for i := 0; i < terms; i++ {
+		sum += sign / float64(2*i+1)
+		sign = -sign
 	}
-	return 4.0 * float64(inside) / float64(iterations)
+	return 4 * sum
 }
 
 // collectMetrics collects performance metrics
@@ -313,16 +642,34 @@ func (c *CPUStressPlugin) collectMetrics(ctx context.Context) {
 	defer ticker.Stop()
 
 	var lastOpsCount int64
+	lastBytes := make(map[int]int64, len(c.bandwidthBytes))
+	lastTick := time.Now()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case now := <-ticker.C:
+			elapsed := now.Sub(lastTick).Seconds()
+			lastTick = now
+
 			c.mu.Lock()
 			currentOps := c.operationsCount
 			c.metrics.OperationsPerSecond = currentOps - lastOpsCount
 			lastOpsCount = currentOps
+			if accuracy, ok := c.accuracy(); ok {
+				c.metrics.CalculationAccuracy = accuracy
+			}
+
+			if c.isBandwidthAlgorithm() && elapsed > 0 {
+				bandwidth := make(map[string]float64, len(c.bandwidthBytes))
+				for sizeKB, counter := range c.bandwidthBytes {
+					current := atomic.LoadInt64(counter)
+					bandwidth[workingSetLabel(sizeKB)] = float64(current-lastBytes[sizeKB]) / elapsed / 1e9
+					lastBytes[sizeKB] = current
+				}
+				c.metrics.BandwidthGBps = bandwidth
+			}
 			c.mu.Unlock()
 		}
 	}
@@ -339,14 +686,29 @@ func (c *CPUStressPlugin) GetMetrics() map[string]interface{} {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	return map[string]interface{}{
-		"ops_per_sec":        c.metrics.OperationsPerSecond,
-		"accuracy_percent":   c.metrics.CalculationAccuracy,
-		"thermal_throttle":   c.metrics.ThermalThrottling,
-		"core_usage":         c.metrics.CoreUtilization,
-		"worker_count":       c.metrics.WorkerCount,
-		"total_operations":   c.operationsCount,
+	metrics := map[string]interface{}{
+		"ops_per_sec":      c.metrics.OperationsPerSecond,
+		"thermal_throttle": c.metrics.ThermalThrottling,
+		"core_usage":       c.metrics.CoreUtilization,
+		"worker_count":     c.metrics.WorkerCount,
+		"intensity":        c.effectiveIntensity(),
+		"total_operations": c.operationsCount,
+		"bandwidth_gbps":   c.metrics.BandwidthGBps,
+	}
+	// Bandwidth algorithms have no golden value to check
+	if !c.isBandwidthAlgorithm() {
+		accuracy, ok := c.accuracy()
+		if !ok {
+			accuracy = 100 // nothing checked yet, so nothing found wrong
+		}
+		details := make([]CalculationError, len(c.errorDetails))
+		copy(details, c.errorDetails)
+		metrics["accuracy_percent"] = accuracy
+		metrics["verified_operations"] = c.verifiedOps
+		metrics["calculation_errors"] = c.calcErrors
+		metrics["calculation_error_details"] = details
 	}
+	return metrics
 }
 
 // GetSafetyLimits returns safety limits for CPU testing
@@ -362,7 +724,7 @@ func (c *CPUStressPlugin) GetSafetyLimits() models.SafetyLimits {
 // HealthCheck performs a health check
 func (c *CPUStressPlugin) HealthCheck() error {
 	// Perform a quick calculation to verify CPU functionality
-	result := c.calculateFibonacci(10)
+	result := calculateFibonacci(10)
 	if result != 55 {
 		return fmt.Errorf("CPU health check failed: expected 55, got %d", result)
 	}
@@ -0,0 +1,215 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestCalculationsMatchGoldenValues(t *testing.T) {
+	for name, calc := range calculations {
+		t.Run(name, func(t *testing.T) {
+			if result := calc.run(); !calc.correct(result) {
+				t.Errorf("result = %v, want %v ± %v", result, calc.expected, calc.tolerance)
+			}
+		})
+	}
+
+	if got := matrixChecksum(3); got != 72 {
+		t.Errorf("matrixChecksum(3) = %d, want 72", got)
+	}
+	if got := matrixMultiplication(3); got != 72 {
+		t.Errorf("matrixMultiplication(3) = %v, want 72", got)
+	}
+}
+
+func TestCalculationCorrect(t *testing.T) {
+	tests := []struct {
+		name   string
+		calc   calculation
+		result float64
+		want   bool
+	}{
+		{"exact match", calculations["prime"], primesBelow10k, true},
+		{"exact off by one", calculations["prime"], primesBelow10k + 1, false},
+		{"within the error bound", calculations["pi"], 3.1415916535897743, true},
+		{"outside the error bound", calculations["pi"], 3.1415, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.calc.correct(tt.result); got != tt.want {
+				t.Errorf("correct(%v) = %v, want %v", tt.result, got, tt.want)
+			}
+		})
+	}
+}
+
+// runCPUStress runs a short prime test with the given calculation, returning
+// the plugin and Execute's error
+func runCPUStress(t *testing.T, config map[string]interface{}, run func() float64) (*CPUStressPlugin, error) {
+	t.Helper()
+
+	plugin := NewCPUStressPlugin()
+	config["algorithm"] = "prime"
+	config["workers"] = 2
+	config["intensity"] = 100
+	if err := plugin.Initialize(config); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if run != nil {
+		plugin.calculation.run = run
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	return plugin, plugin.Execute(ctx, models.TestParams{Duration: 300 * time.Millisecond})
+}
+
+func TestCPUStressReportsAccuracy(t *testing.T) {
+	plugin, err := runCPUStress(t, map[string]interface{}{}, nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	metrics := plugin.GetMetrics()
+	if verified, _ := metrics["verified_operations"].(int64); verified == 0 {
+		t.Fatalf("metrics = %+v, want verified operations", metrics)
+	}
+	if metrics["accuracy_percent"] != 100.0 || metrics["calculation_errors"] != int64(0) {
+		t.Errorf("metrics = %+v, want 100%% accuracy", metrics)
+	}
+}
+
+func TestCPUStressFailsOnWrongResult(t *testing.T) {
+	wrong := func() float64 { return primesBelow10k - 1 }
+
+	start := time.Now()
+	plugin, err := runCPUStress(t, map[string]interface{}{}, wrong)
+	if !errors.Is(err, ErrPluginExecution) {
+		t.Fatalf("Execute() = %v, want ErrPluginExecution", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Errorf("Execute took %v, want it to stop at the first error", elapsed)
+	}
+	details, _ := plugin.GetMetrics()["calculation_error_details"].([]CalculationError)
+	if len(details) == 0 || details[0].Actual != primesBelow10k-1 || details[0].Expected != primesBelow10k {
+		t.Errorf("error details = %+v", details)
+	}
+
+	plugin, err = runCPUStress(t, map[string]interface{}{"continue_on_error": true}, wrong)
+	if err != nil {
+		t.Fatalf("Execute with continue_on_error: %v", err)
+	}
+	metrics := plugin.GetMetrics()
+	if errs, _ := metrics["calculation_errors"].(int64); errs == 0 || metrics["accuracy_percent"] != 0.0 {
+		t.Errorf("metrics = %+v, want every calculation counted as an error", metrics)
+	}
+	if details, _ := metrics["calculation_error_details"].([]CalculationError); len(details) > maxReportedCalculationErrors {
+		t.Errorf("%d error details reported, want at most %d", len(details), maxReportedCalculationErrors)
+	}
+}
+
+func TestCPUStressRampUpKeepsOneWorkerPool(t *testing.T) {
+	const workers = 8
+	plugin := NewCPUStressPlugin()
+	config := map[string]interface{}{"algorithm": "prime", "workers": workers, "intensity": 70, "ramp_up": true}
+	if err := plugin.Initialize(config); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	baseline := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- plugin.Execute(ctx, models.TestParams{Duration: time.Minute}) }()
+
+	// Ramp-up steps every second; the second step raises the intensity without
+	// starting more workers
+	time.Sleep(1200 * time.Millisecond)
+	if intensity := plugin.GetMetrics()["intensity"]; intensity != int64(14) {
+		t.Errorf("intensity in the second ramp-up step = %v, want 14", intensity)
+	}
+	if running := runtime.NumGoroutine() - baseline; running > workers+3 {
+		t.Errorf("%d goroutines running, want the %d workers and the run's own", running, workers)
+	}
+
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Execute() = %v, want the deadline exceeded", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if leaked := runtime.NumGoroutine() - baseline; leaked > 0 {
+		t.Errorf("%d goroutines left running after Execute returned", leaked)
+	}
+}
+
+func TestCPUStressPauseIdlesWorkers(t *testing.T) {
+	plugin := NewCPUStressPlugin()
+	atomic.StoreInt64(&plugin.intensity, 80)
+	atomic.StoreInt64(&plugin.intensityScale, 100)
+	plugin.SetLoadLevel(50)
+
+	plugin.SetPaused(true)
+	if got := plugin.effectiveIntensity(); got != 0 {
+		t.Errorf("intensity while paused = %d, want 0", got)
+	}
+	plugin.SetPaused(false)
+	if got := plugin.effectiveIntensity(); got != 40 {
+		t.Errorf("intensity once resumed = %d, want 40", got)
+	}
+}
+
+func TestCPUStressRampDownLowersIntensity(t *testing.T) {
+	plugin := NewCPUStressPlugin()
+	atomic.StoreInt64(&plugin.intensity, 80)
+	atomic.StoreInt64(&plugin.intensityScale, 100)
+	atomic.StoreInt64(&plugin.loadLevel, 100)
+	if !plugin.RampsDown() {
+		t.Fatal("cpu-stress doesn't report ramping down")
+	}
+
+	// The ramp-down window is the last half of a one second run
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		plugin.rampDown(ctx, models.TestParams{Duration: time.Second, RampDown: 500 * time.Millisecond})
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	if got := plugin.effectiveIntensity(); got != 80 {
+		t.Errorf("intensity before the ramp-down window = %d, want 80", got)
+	}
+	if _, phase := plugin.Progress(); phase == models.PhaseRampDown {
+		t.Errorf("phase before the ramp-down window = %s", phase)
+	}
+
+	// Through the window the intensity only falls, a tenth at a time, to nothing
+	last := int64(100)
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-time.After(5 * time.Millisecond):
+		}
+		scale := atomic.LoadInt64(&plugin.intensityScale)
+		if scale > last || scale%10 != 0 {
+			t.Fatalf("intensity scale went from %d%% to %d%%, want it to fall in steps of 10%%", last, scale)
+		}
+		last = scale
+	}
+	if last != 0 || plugin.effectiveIntensity() != 0 {
+		t.Errorf("intensity at the end of the run = %d (%d%%), want 0", plugin.effectiveIntensity(), last)
+	}
+	if _, phase := plugin.Progress(); phase != models.PhaseRampDown {
+		t.Errorf("phase at the end of the run = %s, want %s", phase, models.PhaseRampDown)
+	}
+}
@@ -0,0 +1,12 @@
+package plugins
+
+// DiskWriter is implemented by plugins that write test files to disk, so the
+// orchestrator can hold their runs to a disk quota and free-space floor.
+// DiskWritten returns the bytes the run has written so far, overwrites
+// included, and the directories its files are on. ReclaimDisk removes the
+// run's test files while it is still going, returning the bytes freed; the run
+// is stopped straight after.
+type DiskWriter interface {
+	DiskWritten() (bytes int64, paths []string)
+	ReclaimDisk() (int64, error)
+}
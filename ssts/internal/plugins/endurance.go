@@ -0,0 +1,70 @@
+package plugins
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// DeviceWriteReporter is implemented by plugins that write to storage devices, so
+// the wear each execution puts on a device can be tracked across executions.
+// DeviceBytesWritten is called once the execution has finished and returns the
+// bytes written per device, keyed as described by deviceForPath.
+type DeviceWriteReporter interface {
+	DeviceBytesWritten() map[string]int64
+}
+
+// diskPartitions lists the mounted file systems
+var diskPartitions = disk.Partitions
+
+// deviceForPath identifies the device a path lives on, preferring its serial
+// number so the count follows the drive if it is moved or renamed. It falls back
+// to the device name, then to the path itself when the mount can't be found.
+func deviceForPath(path string) string {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		absolute = path
+	}
+
+	mount, ok := mountForPath(absolute, false)
+	if !ok || mount.Device == "" {
+		return absolute
+	}
+
+	if serial, err := disk.SerialNumber(mount.Device); err == nil && serial != "" {
+		return serial
+	}
+	return mount.Device
+}
+
+// mountForPath returns the file system an absolute path lives on: the mount with
+// the longest mount point holding it. Pseudo file systems such as tmpfs are only
+// considered when all is set.
+func mountForPath(path string, all bool) (disk.PartitionStat, bool) {
+	partitions, err := diskPartitions(all)
+	if err != nil {
+		return disk.PartitionStat{}, false
+	}
+
+	var mount disk.PartitionStat
+	found := false
+	for _, partition := range partitions {
+		if !withinMount(path, partition.Mountpoint) {
+			continue
+		}
+		if !found || len(partition.Mountpoint) > len(mount.Mountpoint) {
+			mount = partition
+			found = true
+		}
+	}
+	return mount, found
+}
+
+// withinMount reports whether path is the mount point or below it
+func withinMount(path, mountpoint string) bool {
+	if mountpoint == "/" || path == mountpoint {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(mountpoint, "/")+"/")
+}
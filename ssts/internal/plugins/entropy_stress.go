@@ -0,0 +1,442 @@
+package plugins
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// EntropyStressConfig defines configuration for entropy and RNG stress testing
+type EntropyStressConfig struct {
+	Workers  int      `json:"workers"`   // Number of worker goroutines (0 = number of CPUs), spread across the sources
+	Sources  []string `json:"sources"`   // urandom, getrandom, keygen
+	ReadSize int      `json:"read_size"` // Bytes requested per read from urandom and getrandom
+	KeyType  string   `json:"key_type"`  // ed25519, ecdsa-p256, ecdsa-p384, rsa-2048
+}
+
+// EntropyStressPlugin drains the kernel's random number generator from many
+// workers at once, through reads of /dev/urandom, getrandom(2) calls and key
+// generation, to validate RNG throughput and latency, e.g. on crypto appliances
+type EntropyStressPlugin struct {
+	config   EntropyStressConfig
+	metrics  *EntropyMetrics
+	mu       sync.RWMutex
+	stopChan chan bool
+	sources  map[string]*entropySource // fixed by Initialize, so read without the lock
+	failed   chan error                // receives the first error a worker hits
+}
+
+// EntropyMetrics tracks entropy stress test metrics, by source
+type EntropyMetrics struct {
+	ThroughputMBps  map[string]float64 `json:"throughput_mb_per_sec"`
+	OpsPerSec       map[string]int64   `json:"ops_per_sec"`
+	LatencyP50Us    map[string]float64 `json:"latency_p50_us"`
+	LatencyP99Us    map[string]float64 `json:"latency_p99_us"`
+	LatencyMaxUs    map[string]float64 `json:"latency_max_us"`
+	EntropyAvail    int                `json:"entropy_avail_bits"`     // the kernel's entropy estimate, Linux only
+	EntropyAvailMin int                `json:"entropy_avail_min_bits"` // the lowest estimate seen during the run
+	ActiveWorkers   int                `json:"active_workers"`
+}
+
+// entropySource accumulates the work done through one source
+type entropySource struct {
+	ops       int64
+	bytes     int64
+	latencies []time.Duration // sampled latencies since the last collection, guarded by the plugin's mu
+}
+
+// Entropy sources
+const (
+	EntropySourceURandom   = "urandom"
+	EntropySourceGetrandom = "getrandom"
+	EntropySourceKeygen    = "keygen"
+)
+
+// entropySources lists the sources in the order workers are assigned to them
+var entropySources = []string{EntropySourceURandom, EntropySourceGetrandom, EntropySourceKeygen}
+
+// NewEntropyStressPlugin creates a new entropy stress plugin
+func NewEntropyStressPlugin() *EntropyStressPlugin {
+	return &EntropyStressPlugin{
+		metrics:  &EntropyMetrics{},
+		stopChan: make(chan bool),
+	}
+}
+
+// Name returns the plugin name
+func (e *EntropyStressPlugin) Name() string {
+	return "entropy-stress"
+}
+
+// Version returns the plugin version
+func (e *EntropyStressPlugin) Version() string {
+	return "1.0.0"
+}
+
+// Description returns the plugin description
+func (e *EntropyStressPlugin) Description() string {
+	return "Entropy and RNG stress testing plugin for /dev/urandom, getrandom and key generation"
+}
+
+// ConfigSchema returns the JSON schema for configuration
+func (e *EntropyStressPlugin) ConfigSchema() []byte {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"workers": {
+				"type": "integer",
+				"minimum": 0,
+				"maximum": 1024,
+				"default": 0,
+				"description": "Number of workers, spread evenly across the sources (0 = number of CPUs)"
+			},
+			"sources": {
+				"type": "array",
+				"items": {"type": "string", "enum": ["urandom", "getrandom", "keygen"]},
+				"default": ["urandom", "getrandom", "keygen"],
+				"description": "urandom reads /dev/urandom through a file of each worker's own; getrandom calls getrandom(2) directly (Linux only); keygen generates key pairs from crypto/rand"
+			},
+			"read_size": {
+				"type": "integer",
+				"x-unit": "bytes",
+				"minimum": 1,
+				"maximum": 1048576,
+				"default": 4096,
+				"description": "Bytes requested per urandom read or getrandom call"
+			},
+			"key_type": {
+				"type": "string",
+				"enum": ["ed25519", "ecdsa-p256", "ecdsa-p384", "rsa-2048"],
+				"default": "ecdsa-p256",
+				"description": "Key pairs generated by the keygen source"
+			}
+		}
+	}`
+	return []byte(schema)
+}
+
+// Initialize initializes the plugin with configuration
+func (e *EntropyStressPlugin) Initialize(config interface{}) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := json.Unmarshal(configBytes, &e.config); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	// Set defaults
+	if e.config.Workers <= 0 {
+		e.config.Workers = runtime.NumCPU()
+	}
+	if len(e.config.Sources) == 0 {
+		e.config.Sources = entropySources
+		if !getrandomSupported {
+			e.config.Sources = []string{EntropySourceURandom, EntropySourceKeygen}
+		}
+	}
+	if e.config.ReadSize <= 0 {
+		e.config.ReadSize = 4096
+	}
+	if e.config.KeyType == "" {
+		e.config.KeyType = "ecdsa-p256"
+	}
+
+	e.sources = make(map[string]*entropySource, len(e.config.Sources))
+	for _, source := range e.config.Sources {
+		switch source {
+		case EntropySourceURandom, EntropySourceKeygen:
+		case EntropySourceGetrandom:
+			if !getrandomSupported {
+				return fmt.Errorf("the getrandom source is only available on Linux")
+			}
+		default:
+			return fmt.Errorf("unsupported source: %s", source)
+		}
+		e.sources[source] = &entropySource{}
+	}
+
+	switch e.config.KeyType {
+	case "ed25519", "ecdsa-p256", "ecdsa-p384", "rsa-2048":
+	default:
+		return fmt.Errorf("unsupported key type: %s", e.config.KeyType)
+	}
+
+	// Every source gets at least one worker
+	if e.config.Workers < len(e.sources) {
+		e.config.Workers = len(e.sources)
+	}
+
+	return nil
+}
+
+// Execute runs the entropy stress test
+func (e *EntropyStressPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	e.mu.Lock()
+	for _, source := range e.sources {
+		atomic.StoreInt64(&source.ops, 0)
+		atomic.StoreInt64(&source.bytes, 0)
+		source.latencies = source.latencies[:0]
+	}
+	e.metrics = &EntropyMetrics{ActiveWorkers: e.config.Workers}
+	e.failed = make(chan error, 1)
+	e.mu.Unlock()
+
+	go e.collectMetrics(ctx)
+
+	// Stop the other workers once one fails
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < e.config.Workers; i++ {
+		wg.Add(1)
+		go e.worker(workerCtx, &wg, e.config.Sources[i%len(e.config.Sources)])
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		<-done
+		return ctx.Err()
+	case err := <-e.failed:
+		cancel()
+		<-done
+		return err
+	case <-done:
+		return nil
+	}
+}
+
+// FollowsDeadline reports that entropy workers run until the context ends
+func (e *EntropyStressPlugin) FollowsDeadline() bool {
+	return true
+}
+
+// worker draws random data from one source until the test stops
+func (e *EntropyStressPlugin) worker(ctx context.Context, wg *sync.WaitGroup, source string) {
+	defer wg.Done()
+
+	// Each urandom worker reads through a file of its own, so reads run in
+	// parallel in the kernel rather than queueing on one descriptor
+	var urandom *os.File
+	if source == EntropySourceURandom {
+		file, err := os.Open("/dev/urandom")
+		if err != nil {
+			e.fail(fmt.Errorf("%w: failed to open /dev/urandom: %v", ErrPluginExecution, err))
+			return
+		}
+		defer file.Close()
+		urandom = file
+	}
+
+	stats := e.sources[source]
+	buf := make([]byte, e.config.ReadSize)
+	for count := 1; ; count++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.stopChan:
+			return
+		default:
+		}
+
+		start := time.Now()
+		n, err := e.draw(source, urandom, buf)
+		latency := time.Since(start)
+		if err != nil {
+			e.fail(fmt.Errorf("%w: %s: %v", ErrPluginExecution, source, err))
+			return
+		}
+
+		atomic.AddInt64(&stats.ops, 1)
+		atomic.AddInt64(&stats.bytes, int64(n))
+
+		// Key generation is slow enough to time every key
+		if source != EntropySourceKeygen && count%latencySampleRate != 0 {
+			continue
+		}
+		e.mu.Lock()
+		if len(stats.latencies) < maxLatencySamples {
+			stats.latencies = append(stats.latencies, latency)
+		}
+		e.mu.Unlock()
+	}
+}
+
+// draw performs one operation on a source, returning the random bytes it read
+func (e *EntropyStressPlugin) draw(source string, urandom *os.File, buf []byte) (int, error) {
+	switch source {
+	case EntropySourceURandom:
+		return io.ReadFull(urandom, buf)
+	case EntropySourceGetrandom:
+		return getrandom(buf)
+	default:
+		return 0, generateKey(e.config.KeyType)
+	}
+}
+
+// generateKey generates a key pair of the given type from crypto/rand
+func generateKey(keyType string) error {
+	var err error
+	switch keyType {
+	case "ed25519":
+		_, _, err = ed25519.GenerateKey(rand.Reader)
+	case "ecdsa-p384":
+		_, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "rsa-2048":
+		_, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		_, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	return err
+}
+
+// fail reports a worker's error, keeping only the first
+func (e *EntropyStressPlugin) fail(err error) {
+	select {
+	case e.failed <- err:
+	default:
+	}
+}
+
+// collectMetrics collects performance metrics
+func (e *EntropyStressPlugin) collectMetrics(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	lastOps := make(map[string]int64, len(e.sources))
+	lastBytes := make(map[string]int64, len(e.sources))
+	minAvail := -1
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			avail, availErr := entropyAvail()
+
+			e.mu.Lock()
+			metrics := &EntropyMetrics{
+				ThroughputMBps:  make(map[string]float64, len(e.sources)),
+				OpsPerSec:       make(map[string]int64, len(e.sources)),
+				LatencyP50Us:    make(map[string]float64, len(e.sources)),
+				LatencyP99Us:    make(map[string]float64, len(e.sources)),
+				LatencyMaxUs:    make(map[string]float64, len(e.sources)),
+				EntropyAvail:    e.metrics.EntropyAvail,
+				EntropyAvailMin: e.metrics.EntropyAvailMin,
+				ActiveWorkers:   e.metrics.ActiveWorkers,
+			}
+			if availErr == nil {
+				if minAvail < 0 || avail < minAvail {
+					minAvail = avail
+				}
+				metrics.EntropyAvail, metrics.EntropyAvailMin = avail, minAvail
+			}
+			for name, source := range e.sources {
+				ops, bytes := atomic.LoadInt64(&source.ops), atomic.LoadInt64(&source.bytes)
+				metrics.OpsPerSec[name] = ops - lastOps[name]
+				if name != EntropySourceKeygen {
+					metrics.ThroughputMBps[name] = float64(bytes-lastBytes[name]) / (1024 * 1024)
+				}
+				lastOps[name], lastBytes[name] = ops, bytes
+
+				samples := source.latencies
+				source.latencies = make([]time.Duration, 0, len(samples))
+				if len(samples) > 0 {
+					sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+					metrics.LatencyP50Us[name] = percentileMicros(samples, 0.50)
+					metrics.LatencyP99Us[name] = percentileMicros(samples, 0.99)
+					metrics.LatencyMaxUs[name] = float64(samples[len(samples)-1]) / float64(time.Microsecond)
+				}
+			}
+			e.metrics = metrics
+			e.mu.Unlock()
+		}
+	}
+}
+
+// Cleanup cleans up resources
+func (e *EntropyStressPlugin) Cleanup() error {
+	close(e.stopChan)
+	return nil
+}
+
+// GetMetrics returns current metrics
+func (e *EntropyStressPlugin) GetMetrics() map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var totalBytes, keys int64
+	for name, source := range e.sources {
+		if name == EntropySourceKeygen {
+			keys = atomic.LoadInt64(&source.ops)
+		} else {
+			totalBytes += atomic.LoadInt64(&source.bytes)
+		}
+	}
+
+	metrics := map[string]interface{}{
+		"throughput_mb_per_sec": e.metrics.ThroughputMBps,
+		"ops_per_sec":           e.metrics.OpsPerSec,
+		"latency_p50_us":        e.metrics.LatencyP50Us,
+		"latency_p99_us":        e.metrics.LatencyP99Us,
+		"latency_max_us":        e.metrics.LatencyMaxUs,
+		"active_workers":        e.metrics.ActiveWorkers,
+		"total_bytes":           totalBytes,
+		"total_keys":            keys,
+	}
+	if entropyAvailSupported {
+		metrics["entropy_avail_bits"] = e.metrics.EntropyAvail
+		metrics["entropy_avail_min_bits"] = e.metrics.EntropyAvailMin
+	}
+	return metrics
+}
+
+// GetSafetyLimits returns safety limits for entropy testing
+func (e *EntropyStressPlugin) GetSafetyLimits() models.SafetyLimits {
+	return models.SafetyLimits{
+		MaxCPUPercent:    90.0,
+		MaxMemoryPercent: 20.0, // Read buffers and generated keys are small
+		MaxDiskPercent:   50.0,
+		MaxNetworkMbps:   10.0,
+	}
+}
+
+// HealthCheck performs a health check
+func (e *EntropyStressPlugin) HealthCheck() error {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("entropy health check failed: crypto/rand: %w", err)
+	}
+
+	file, err := os.Open("/dev/urandom")
+	if err != nil {
+		return fmt.Errorf("entropy health check failed: %w", err)
+	}
+	defer file.Close()
+	if _, err := io.ReadFull(file, buf); err != nil {
+		return fmt.Errorf("entropy health check failed: /dev/urandom: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build linux
+
+package plugins
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// getrandomSupported reports whether the getrandom source can be used
+const getrandomSupported = true
+
+// entropyAvailSupported reports whether the kernel reports its entropy estimate
+const entropyAvailSupported = true
+
+// getrandom fills buf from getrandom(2), retrying reads cut short by a signal or
+// the kernel's per-call limit
+func getrandom(buf []byte) (int, error) {
+	filled := 0
+	for filled < len(buf) {
+		n, err := unix.Getrandom(buf[filled:], 0)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return filled, err
+		}
+		filled += n
+	}
+	return filled, nil
+}
+
+// entropyAvail returns the kernel's estimate of the bits in its entropy pool
+func entropyAvail() (int, error) {
+	data, err := os.ReadFile("/proc/sys/kernel/random/entropy_avail")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package plugins
+
+import "errors"
+
+// getrandom(2) and the kernel's entropy estimate are only available on Linux
+const (
+	getrandomSupported    = false
+	entropyAvailSupported = false
+)
+
+var errNotLinux = errors.New("only available on Linux")
+
+func getrandom(buf []byte) (int, error) {
+	return 0, errNotLinux
+}
+
+func entropyAvail() (int, error) {
+	return 0, errNotLinux
+}
@@ -0,0 +1,102 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestEntropyStressInitialize(t *testing.T) {
+	defaultSources := []string{"urandom", "getrandom", "keygen"}
+	if !getrandomSupported {
+		defaultSources = []string{"urandom", "keygen"}
+	}
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    EntropyStressConfig
+		wantErr bool
+	}{
+		{"defaults", map[string]interface{}{}, EntropyStressConfig{Workers: max(runtime.NumCPU(), len(defaultSources)), Sources: defaultSources, ReadSize: 4096, KeyType: "ecdsa-p256"}, false},
+		{"a worker per source at least", map[string]interface{}{"workers": 1, "sources": []string{"urandom", "keygen"}, "key_type": "ed25519"}, EntropyStressConfig{Workers: 2, Sources: []string{"urandom", "keygen"}, ReadSize: 4096, KeyType: "ed25519"}, false},
+		{"unknown source", map[string]interface{}{"sources": []string{"rdrand"}}, EntropyStressConfig{}, true},
+		{"unknown key type", map[string]interface{}{"key_type": "dsa-1024"}, EntropyStressConfig{}, true},
+		{"read size of the wrong type", map[string]interface{}{"read_size": "4k"}, EntropyStressConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := NewEntropyStressPlugin()
+			err := plugin.Initialize(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Initialize accepted %v", tt.config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+			if !reflect.DeepEqual(plugin.config, tt.want) {
+				t.Errorf("config = %+v, want %+v", plugin.config, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntropyStressSources(t *testing.T) {
+	plugin := NewEntropyStressPlugin()
+	if err := plugin.Initialize(map[string]interface{}{"workers": 6, "read_size": 512, "key_type": "ed25519"}); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	if err := plugin.Execute(ctx, models.TestParams{Duration: 1500 * time.Millisecond}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Execute returned %v, want the context's error", err)
+	}
+
+	metrics := plugin.GetMetrics()
+	if bytes := metrics["total_bytes"].(int64); bytes == 0 || bytes%512 != 0 {
+		t.Errorf("total bytes = %d, want whole reads of 512 bytes", bytes)
+	}
+	if keys := metrics["total_keys"].(int64); keys == 0 {
+		t.Error("no keys were generated")
+	}
+	ops := metrics["ops_per_sec"].(map[string]int64)
+	for _, source := range plugin.config.Sources {
+		if ops[source] == 0 {
+			t.Errorf("%s: no operations in the last second, metrics = %+v", source, metrics)
+		}
+	}
+	if latency := metrics["latency_p50_us"].(map[string]float64); latency["keygen"] <= 0 {
+		t.Errorf("keygen latency = %v", latency)
+	}
+	if _, ok := metrics["entropy_avail_bits"]; ok != entropyAvailSupported {
+		t.Errorf("entropy_avail_bits reported = %v, want %v", ok, entropyAvailSupported)
+	}
+}
+
+func TestEntropyStressStopsOnCleanup(t *testing.T) {
+	plugin := NewEntropyStressPlugin()
+	plugin.Initialize(map[string]interface{}{"workers": 2, "sources": []string{"urandom"}})
+
+	done := make(chan error, 1)
+	go func() { done <- plugin.Execute(context.Background(), models.TestParams{Duration: time.Minute}) }()
+	time.Sleep(50 * time.Millisecond)
+	plugin.Cleanup()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Execute returned %v after Cleanup, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Execute didn't return after Cleanup")
+	}
+}
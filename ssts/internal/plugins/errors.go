@@ -8,4 +8,9 @@ var (
 	ErrInvalidConfig      = errors.New("invalid plugin configuration")
 	ErrSafetyLimitReached = errors.New("safety limit reached")
 	ErrPluginExecution    = errors.New("plugin execution failed")
+	ErrPluginExists       = errors.New("plugin already registered")
+	ErrPluginBuiltin      = errors.New("built-in plugins can't be removed")
+	ErrExternalDisabled   = errors.New("external plugins are disabled")
+	ErrBinaryNotAllowed   = errors.New("plugin binary is outside the allowed plugin directories")
+	ErrPluginWatched      = errors.New("plugin is loaded from a watched plugin directory")
 )
\ No newline at end of file
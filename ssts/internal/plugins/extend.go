@@ -0,0 +1,9 @@
+package plugins
+
+// DeadlineFollower is implemented by plugins whose runs end when their context's
+// deadline passes, however far it has been pushed back, rather than after
+// params.Duration by their own clock. Only executions of plugins that follow
+// their deadline can be extended while running.
+type DeadlineFollower interface {
+	FollowsDeadline() bool
+}
@@ -0,0 +1,347 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// externalStopGrace is how long an external plugin gets to exit after SIGTERM before it is killed
+const externalStopGrace = 10 * time.Second
+
+// externalStderrLimit bounds how much of an external plugin's stderr is kept for error messages
+const externalStderrLimit = 4096
+
+// ExternalPlugin runs a stress test implemented by an executable registered at runtime.
+//
+// The executable receives the plugin configuration as JSON on stdin and the test
+// parameters through SSTS_DURATION_SECONDS, SSTS_INTENSITY, SSTS_CONCURRENCY,
+// SSTS_SEED and SSTS_PARAMS (JSON). It should run until the duration elapses or it
+// receives SIGTERM, and should derive its random decisions from the seed so the
+// run can be replayed.
+// Each line it writes to stdout that is a JSON object is merged into the plugin's
+// metrics; other output is ignored. A non-zero exit status fails the test.
+type ExternalPlugin struct {
+	definition models.Plugin
+	sandbox    *Sandbox // nil to run with the server's privileges
+	config     json.RawMessage
+	metrics    map[string]interface{}
+	mu         sync.RWMutex
+}
+
+// NewExternalPlugin creates a plugin backed by the executable in definition.BinaryPath
+func NewExternalPlugin(definition models.Plugin) (*ExternalPlugin, error) {
+	if definition.Name == "" {
+		return nil, fmt.Errorf("external plugin name is required")
+	}
+	if !filepath.IsAbs(definition.BinaryPath) {
+		return nil, fmt.Errorf("external plugin binary path must be absolute: %q", definition.BinaryPath)
+	}
+	if definition.Checksum == "" {
+		return nil, fmt.Errorf("external plugin checksum is required")
+	}
+	if definition.Version == "" {
+		definition.Version = "0.0.0"
+	}
+
+	plugin := &ExternalPlugin{
+		definition: definition,
+		metrics:    make(map[string]interface{}),
+	}
+	if err := plugin.HealthCheck(); err != nil {
+		return nil, err
+	}
+	return plugin, nil
+}
+
+// Factory returns a factory creating fresh instances of the plugin, so concurrent
+// executions don't share configuration or metrics. The binary isn't checked here,
+// since Execute verifies it before every run.
+func (e *ExternalPlugin) Factory() PluginFactory {
+	definition := e.definition
+	sandbox := e.sandbox
+	return func() StressPlugin {
+		return &ExternalPlugin{
+			definition: definition,
+			sandbox:    sandbox,
+			metrics:    make(map[string]interface{}),
+		}
+	}
+}
+
+// SetSandbox confines the plugin's processes, including those of instances made
+// by a factory obtained afterwards
+func (e *ExternalPlugin) SetSandbox(sandbox *Sandbox) {
+	e.sandbox = sandbox
+}
+
+// Name returns the plugin name
+func (e *ExternalPlugin) Name() string {
+	return e.definition.Name
+}
+
+// Version returns the plugin version
+func (e *ExternalPlugin) Version() string {
+	return e.definition.Version
+}
+
+// Description returns the plugin description
+func (e *ExternalPlugin) Description() string {
+	return e.definition.Description
+}
+
+// DeclaredCapabilities returns the capabilities supplied at registration
+func (e *ExternalPlugin) DeclaredCapabilities() models.PluginCapabilities {
+	return e.definition.Capabilities
+}
+
+// ConfigSchema returns the JSON schema supplied at registration, or one accepting any object
+func (e *ExternalPlugin) ConfigSchema() []byte {
+	if len(e.definition.ConfigSchema) > 0 {
+		return e.definition.ConfigSchema
+	}
+	return []byte(`{"type": "object"}`)
+}
+
+// BinaryPath returns the path of the executable implementing the plugin
+func (e *ExternalPlugin) BinaryPath() string {
+	return e.definition.BinaryPath
+}
+
+// Initialize stores the configuration passed to the executable
+func (e *ExternalPlugin) Initialize(config interface{}) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if string(configBytes) == "null" {
+		configBytes = []byte("{}")
+	}
+
+	e.mu.Lock()
+	e.config = configBytes
+	e.metrics = make(map[string]interface{})
+	e.mu.Unlock()
+
+	return nil
+}
+
+// Execute runs the executable until it exits or ctx is done
+func (e *ExternalPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	// Re-verify right before running, so a binary swapped after registration never runs
+	if err := e.HealthCheck(); err != nil {
+		return err
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal params: %w", err)
+	}
+
+	e.mu.RLock()
+	config := e.config
+	e.mu.RUnlock()
+
+	cmd := exec.CommandContext(ctx, e.definition.BinaryPath)
+	cmd.Stdin = bytes.NewReader(config)
+	env := os.Environ()
+	if e.sandbox != nil {
+		env = e.sandbox.environment()
+	}
+	cmd.Env = append(env,
+		"SSTS_PLUGIN="+e.definition.Name,
+		"SSTS_DURATION_SECONDS="+strconv.FormatFloat(params.Duration.Seconds(), 'f', -1, 64),
+		"SSTS_INTENSITY="+strconv.Itoa(params.Intensity),
+		"SSTS_CONCURRENCY="+strconv.Itoa(params.Concurrency),
+		"SSTS_SEED="+strconv.FormatInt(params.Seed, 10),
+		"SSTS_PARAMS="+string(paramsJSON),
+	)
+	// Ask the plugin, and any helper processes it spawned, to stop gracefully when
+	// the test ends or is stopped, so they don't outlive the test
+	configureProcessGroup(cmd)
+	cmd.WaitDelay = externalStopGrace
+	if e.sandbox != nil {
+		if err := e.sandbox.apply(cmd); err != nil {
+			return fmt.Errorf("failed to sandbox external plugin %s: %w", e.definition.Name, err)
+		}
+	}
+
+	stderr := &limitedBuffer{limit: externalStderrLimit}
+	cmd.Stderr = stderr
+
+	// Read stdout through a pipe rather than cmd.StdoutPipe so that WaitDelay also
+	// bounds the wait when a child of the plugin keeps the output open
+	stdout, stdoutWriter := io.Pipe()
+	cmd.Stdout = stdoutWriter
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.readMetrics(stdout)
+	}()
+
+	if err := cmd.Start(); err != nil {
+		stdoutWriter.Close()
+		<-done
+		return fmt.Errorf("failed to start external plugin %s: %w", e.definition.Name, err)
+	}
+
+	err = cmd.Wait()
+	// Make sure nothing the plugin left behind keeps running
+	killProcessGroup(cmd)
+	stdoutWriter.Close()
+	<-done
+
+	if ctx.Err() != nil {
+		// Stopped by the orchestrator; the exit status reflects the signal, not a failure
+		return ctx.Err()
+	}
+	if err != nil {
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			return fmt.Errorf("%w: %s: %v: %s", ErrPluginExecution, e.definition.Name, err, output)
+		}
+		return fmt.Errorf("%w: %s: %v", ErrPluginExecution, e.definition.Name, err)
+	}
+
+	return nil
+}
+
+// readMetrics merges JSON objects written by the executable into the plugin's metrics
+func (e *ExternalPlugin) readMetrics(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil {
+			continue
+		}
+
+		e.mu.Lock()
+		for key, value := range fields {
+			e.metrics[key] = value
+		}
+		e.mu.Unlock()
+	}
+
+	// Keep draining so the executable never blocks on a full pipe
+	io.Copy(io.Discard, r)
+}
+
+// Cleanup has nothing to release; the process has exited once Execute returns
+func (e *ExternalPlugin) Cleanup() error {
+	return nil
+}
+
+// GetMetrics returns the latest metrics reported by the executable
+func (e *ExternalPlugin) GetMetrics() map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	metrics := make(map[string]interface{}, len(e.metrics))
+	for key, value := range e.metrics {
+		metrics[key] = value
+	}
+	return metrics
+}
+
+// GetSafetyLimits returns the limits supplied at registration, or the defaults
+func (e *ExternalPlugin) GetSafetyLimits() models.SafetyLimits {
+	if e.definition.SafetyLimits == (models.SafetyLimits{}) {
+		return models.DefaultSafetyLimits()
+	}
+	return e.definition.SafetyLimits
+}
+
+// HealthCheck verifies the executable exists, is executable and matches its checksum
+func (e *ExternalPlugin) HealthCheck() error {
+	info, err := os.Stat(e.definition.BinaryPath)
+	if err != nil {
+		return fmt.Errorf("external plugin binary unavailable: %w", err)
+	}
+	if info.IsDir() || info.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("external plugin binary is not executable: %s", e.definition.BinaryPath)
+	}
+
+	checksum, err := fileSHA256(e.definition.BinaryPath)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(checksum, strings.TrimPrefix(e.definition.Checksum, "sha256:")) {
+		return fmt.Errorf("external plugin binary checksum mismatch for %s", e.definition.BinaryPath)
+	}
+	return nil
+}
+
+// CheckBinaryAllowed verifies that path, with symlinks resolved, lies inside one
+// of dirs, so only executables an operator placed there can be registered
+func CheckBinaryAllowed(path string, dirs []string) error {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("external plugin binary unavailable: %w", err)
+	}
+
+	for _, dir := range dirs {
+		root, err := filepath.EvalSymlinks(dir)
+		if err != nil || !filepath.IsAbs(root) {
+			continue
+		}
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrBinaryNotAllowed, path)
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 of a file
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// limitedBuffer keeps the last limit bytes written to it
+type limitedBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
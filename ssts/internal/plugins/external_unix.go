@@ -0,0 +1,22 @@
+//go:build !windows
+
+package plugins
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configureProcessGroup runs cmd in its own process group and sends the whole
+// group SIGTERM when cmd's context is done
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+}
+
+// killProcessGroup kills whatever is left of cmd's process group
+func killProcessGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
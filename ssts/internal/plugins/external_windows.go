@@ -0,0 +1,16 @@
+//go:build windows
+
+package plugins
+
+import "os/exec"
+
+// configureProcessGroup kills cmd when its context is done. Windows has no
+// process groups to signal, so helper processes the plugin spawned may outlive it.
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return cmd.Process.Kill()
+	}
+}
+
+// killProcessGroup has nothing to do; the plugin has exited once Wait returns
+func killProcessGroup(cmd *exec.Cmd) {}
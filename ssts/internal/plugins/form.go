@@ -0,0 +1,263 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Plugin configuration schemas may carry hints for forms generated from them,
+// besides the JSON schema keywords they are validated with:
+//
+//	"access_delay": {
+//		"type": "integer",
+//		"title": "Access delay",
+//		"x-group": "Access",
+//		"x-unit": "ms",
+//		"x-order": 3
+//	}
+//
+// Every hint is optional. Fields without a title get one from their name,
+// fields without a unit get one from a unit suffix of their name (delay_ms,
+// working_set_kb), fields without a group go in DefaultFormGroup, and fields
+// are ordered by x-order, a field without one taking its position in the
+// schema as its order, and fields of equal order as declared.
+
+// DefaultFormGroup holds the fields of a form without an x-group
+const DefaultFormGroup = "General"
+
+// ConfigForm describes a plugin configuration as a form: its fields in groups,
+// the groups and the fields in each in the order they should be shown
+type ConfigForm struct {
+	Groups []FormGroup `json:"groups"`
+}
+
+// FormGroup is a titled group of form fields
+type FormGroup struct {
+	Title  string      `json:"title"`
+	Fields []FormField `json:"fields"`
+}
+
+// FormField describes a configuration option as a form field
+type FormField struct {
+	Name        string          `json:"name"`
+	Title       string          `json:"title"`
+	Type        string          `json:"type"`
+	Description string          `json:"description,omitempty"`
+	Default     json.RawMessage `json:"default,omitempty"`
+	Enum        []interface{}   `json:"enum,omitempty"`
+	Minimum     *float64        `json:"minimum,omitempty"`
+	Maximum     *float64        `json:"maximum,omitempty"`
+	Unit        string          `json:"unit,omitempty"`
+	Required    bool            `json:"required,omitempty"`
+	Items       *FormField      `json:"items,omitempty"`  // element of an array
+	Fields      []FormField     `json:"fields,omitempty"` // properties of an object
+}
+
+// formProperty is a schema property with its form hints
+type formProperty struct {
+	Type        string          `json:"type"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Default     json.RawMessage `json:"default"`
+	Enum        []interface{}   `json:"enum"`
+	Minimum     *float64        `json:"minimum"`
+	Maximum     *float64        `json:"maximum"`
+	Items       json.RawMessage `json:"items"`
+	Properties  json.RawMessage `json:"properties"`
+	Required    []string        `json:"required"`
+	Group       string          `json:"x-group"`
+	Unit        string          `json:"x-unit"`
+	Order       *int            `json:"x-order"`
+}
+
+// DescribeConfig builds the form for a plugin configuration schema. A plugin
+// without a schema has a form without fields.
+func DescribeConfig(schema []byte) (*ConfigForm, error) {
+	form := &ConfigForm{Groups: []FormGroup{}}
+	if len(bytes.TrimSpace(schema)) == 0 {
+		return form, nil
+	}
+
+	var root formProperty
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin schema: %w", err)
+	}
+	properties, err := describeProperties(root.Properties, root.Required)
+	if err != nil {
+		return nil, err
+	}
+
+	// Groups are shown in the order of their first field
+	groups := make(map[string]int)
+	for _, property := range properties {
+		index, ok := groups[property.group]
+		if !ok {
+			index = len(form.Groups)
+			groups[property.group] = index
+			form.Groups = append(form.Groups, FormGroup{Title: property.group})
+		}
+		form.Groups[index].Fields = append(form.Groups[index].Fields, property.field)
+	}
+	return form, nil
+}
+
+// describedProperty is a form field with the group it belongs in
+type describedProperty struct {
+	field FormField
+	group string
+	order int
+}
+
+// describeProperties describes the properties of an object schema in form order
+func describeProperties(raw json.RawMessage, required []string) ([]describedProperty, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	names, err := objectKeys(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema properties: %w", err)
+	}
+	var schemas map[string]formProperty
+	if err := json.Unmarshal(raw, &schemas); err != nil {
+		return nil, fmt.Errorf("failed to parse schema properties: %w", err)
+	}
+
+	isRequired := make(map[string]bool, len(required))
+	for _, name := range required {
+		isRequired[name] = true
+	}
+
+	properties := make([]describedProperty, 0, len(names))
+	for i, name := range names {
+		property := schemas[name]
+		field, err := describeField(name, property)
+		if err != nil {
+			return nil, err
+		}
+		field.Required = isRequired[name]
+
+		group := property.Group
+		if group == "" {
+			group = DefaultFormGroup
+		}
+		order := i
+		if property.Order != nil {
+			order = *property.Order
+		}
+		properties = append(properties, describedProperty{field: field, group: group, order: order})
+	}
+
+	sort.SliceStable(properties, func(i, j int) bool { return properties[i].order < properties[j].order })
+	return properties, nil
+}
+
+// describeField describes a single schema property
+func describeField(name string, property formProperty) (FormField, error) {
+	title, unit := titleAndUnit(name)
+	if property.Title != "" {
+		title = property.Title
+	}
+	if property.Unit != "" {
+		unit = property.Unit
+	}
+
+	field := FormField{
+		Name:        name,
+		Title:       title,
+		Type:        property.Type,
+		Description: property.Description,
+		Default:     property.Default,
+		Enum:        property.Enum,
+		Minimum:     property.Minimum,
+		Maximum:     property.Maximum,
+		Unit:        unit,
+	}
+
+	if len(property.Items) > 0 {
+		var items formProperty
+		if err := json.Unmarshal(property.Items, &items); err != nil {
+			return FormField{}, fmt.Errorf("failed to parse items of %s: %w", name, err)
+		}
+		itemField, err := describeField(name, items)
+		if err != nil {
+			return FormField{}, err
+		}
+		itemField.Name = ""
+		field.Items = &itemField
+	}
+
+	properties, err := describeProperties(property.Properties, property.Required)
+	if err != nil {
+		return FormField{}, err
+	}
+	for _, property := range properties {
+		field.Fields = append(field.Fields, property.field)
+	}
+	return field, nil
+}
+
+// objectKeys returns the keys of a JSON object in the order they are written
+func objectKeys(raw json.RawMessage) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected an object")
+	}
+
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, token.(string))
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// unitSuffixes are the units a field name may end in, e.g. delay_ms
+var unitSuffixes = []struct{ suffix, unit string }{
+	{"_percent", "%"},
+	{"_mbps", "Mbps"},
+	{"_ms", "ms"},
+	{"_us", "µs"},
+	{"_kb", "KB"},
+	{"_mb", "MB"},
+	{"_gb", "GB"},
+}
+
+// acronyms are written in capitals in titles made from field names
+var acronyms = map[string]string{
+	"cpu": "CPU", "gc": "GC", "gogc": "GOGC", "io": "I/O", "tcp": "TCP", "udp": "UDP",
+	"tbw": "TBW", "dns": "DNS", "url": "URL", "id": "ID", "mtu": "MTU",
+}
+
+// titleAndUnit makes a title for a field from its name, taking a unit suffix
+// off as the field's unit: working_set_kb is titled "Working set" in KB
+func titleAndUnit(name string) (string, string) {
+	var unit string
+	for _, u := range unitSuffixes {
+		if strings.HasSuffix(name, u.suffix) && len(name) > len(u.suffix) {
+			name = strings.TrimSuffix(name, u.suffix)
+			unit = u.unit
+			break
+		}
+	}
+
+	words := strings.Fields(strings.NewReplacer("_", " ", "-", " ").Replace(name))
+	for i, word := range words {
+		if acronym, ok := acronyms[word]; ok {
+			words[i] = acronym
+		} else if i == 0 {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " "), unit
+}
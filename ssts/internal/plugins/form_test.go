@@ -0,0 +1,121 @@
+package plugins
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDescribeConfig(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"required": ["target"],
+		"properties": {
+			"target": {"type": "string", "description": "What to stress"},
+			"seed": {"type": "integer", "x-group": "Verification", "default": 0},
+			"delay_ms": {"type": "number", "minimum": 0, "default": 1.5},
+			"udp_rate_mbps": {"type": "number", "title": "Send rate", "x-unit": "Mbit/s"},
+			"verify": {"type": "boolean", "x-group": "Verification", "x-order": 0},
+			"targets": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"properties": {
+						"path": {"type": "string"},
+						"weight": {"type": "integer", "default": 1}
+					}
+				}
+			}
+		}
+	}`
+
+	form, err := DescribeConfig([]byte(schema))
+	if err != nil {
+		t.Fatalf("DescribeConfig: %v", err)
+	}
+
+	var groups [][]string
+	for _, group := range form.Groups {
+		names := []string{group.Title}
+		for _, field := range group.Fields {
+			names = append(names, field.Name)
+		}
+		groups = append(groups, names)
+	}
+	want := [][]string{
+		{DefaultFormGroup, "target", "delay_ms", "udp_rate_mbps", "targets"},
+		{"Verification", "verify", "seed"},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("groups = %v, want %v", groups, want)
+	}
+
+	fields := form.Groups[0].Fields
+	if target := fields[0]; target.Title != "Target" || !target.Required || target.Description != "What to stress" {
+		t.Errorf("target = %+v", target)
+	}
+	if delay := fields[1]; delay.Title != "Delay" || delay.Unit != "ms" || string(delay.Default) != "1.5" || delay.Minimum == nil {
+		t.Errorf("delay_ms = %+v", delay)
+	}
+	if rate := fields[2]; rate.Title != "Send rate" || rate.Unit != "Mbit/s" {
+		t.Errorf("udp_rate_mbps = %+v, want the hints to override the name", rate)
+	}
+	items := fields[3].Items
+	if items == nil || items.Type != "object" || len(items.Fields) != 2 || items.Fields[1].Title != "Weight" {
+		t.Errorf("targets items = %+v", items)
+	}
+	if seed := form.Groups[1].Fields[1]; string(seed.Default) != "0" {
+		t.Errorf("seed default = %s, want 0 kept", seed.Default)
+	}
+}
+
+func TestDescribeConfigWithoutSchema(t *testing.T) {
+	for _, schema := range []string{"", `{"type": "object"}`} {
+		form, err := DescribeConfig([]byte(schema))
+		if err != nil || len(form.Groups) != 0 {
+			t.Errorf("DescribeConfig(%q) = %+v, %v, want an empty form", schema, form, err)
+		}
+	}
+	if _, err := DescribeConfig([]byte("{")); err == nil {
+		t.Error("DescribeConfig of invalid JSON succeeded")
+	}
+}
+
+func TestTitleAndUnit(t *testing.T) {
+	tests := []struct{ name, title, unit string }{
+		{"workers", "Workers", ""},
+		{"working_set_kb", "Working set", "KB"},
+		{"udp_packet_size", "UDP packet size", ""},
+		{"headroom_percent", "Headroom", "%"},
+		{"ms", "Ms", ""},
+	}
+	for _, tt := range tests {
+		if title, unit := titleAndUnit(tt.name); title != tt.title || unit != tt.unit {
+			t.Errorf("titleAndUnit(%q) = %q, %q, want %q, %q", tt.name, title, unit, tt.title, tt.unit)
+		}
+	}
+}
+
+func TestBuiltinSchemasDescribeForms(t *testing.T) {
+	for _, plugin := range []StressPlugin{
+		NewCPUStressPlugin(), NewMemoryStressPlugin(), NewIOStressPlugin(), NewNetworkBandwidthPlugin(),
+		NewNetworkNetemPlugin(), NewSchedulerStressPlugin(), NewEntropyStressPlugin(), NewObservePlugin(), NewGRPCLoadPlugin(),
+	} {
+		form, err := DescribeConfig(plugin.ConfigSchema())
+		if err != nil {
+			t.Errorf("%s: %v", plugin.Name(), err)
+			continue
+		}
+		var schema struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		}
+		json.Unmarshal(plugin.ConfigSchema(), &schema)
+		fields := 0
+		for _, group := range form.Groups {
+			fields += len(group.Fields)
+		}
+		if fields != len(schema.Properties) {
+			t.Errorf("%s: form has %d fields, schema %d properties", plugin.Name(), fields, len(schema.Properties))
+		}
+	}
+}
@@ -0,0 +1,930 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// grpcMaxMessage bounds the size of a response message, gRPC's default
+const grpcMaxMessage = 4 << 20
+
+// grpcPaceTick is the shortest interval at which the pacer releases calls
+const grpcPaceTick = 5 * time.Millisecond
+
+// grpcDialTimeout bounds the preflight connection to the target
+const grpcDialTimeout = 5 * time.Second
+
+// gRPC status codes the plugin tells apart itself
+const (
+	grpcOK               = 0
+	grpcUnknown          = 2
+	grpcDeadlineExceeded = 4
+	grpcPermissionDenied = 7
+	grpcUnimplemented    = 12
+	grpcInternal         = 13
+	grpcUnavailable      = 14
+	grpcUnauthenticated  = 16
+)
+
+// grpcCodeNames are the names of the gRPC status codes, by code
+var grpcCodeNames = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND",
+	"ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION",
+	"ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// errGRPCFraming is returned for responses that aren't made of gRPC messages
+var errGRPCFraming = errors.New("malformed gRPC response")
+
+// GRPCLoadConfig defines configuration for gRPC service load
+type GRPCLoadConfig struct {
+	Target             string            `json:"target"`               // host:port of the service
+	TLS                bool              `json:"tls"`                  // Connect over TLS rather than plaintext HTTP/2
+	InsecureSkipVerify bool              `json:"insecure_skip_verify"` // Accept any server certificate
+	DescriptorSet      string            `json:"descriptor_set"`       // Base64 FileDescriptorSet describing the service
+	DescriptorSetFile  string            `json:"descriptor_set_file"`  // Path of a FileDescriptorSet on the agent
+	Calls              []GRPCCall        `json:"calls"`                // RPCs the load is made of
+	RPS                float64           `json:"rps"`                  // Calls started per second; 0 runs as fast as the workers allow
+	Concurrency        int               `json:"concurrency"`          // Calls in flight at once
+	Connections        int               `json:"connections"`          // HTTP/2 connections the calls are spread over
+	Timeout            string            `json:"timeout"`              // Deadline of each call
+	Metadata           map[string]string `json:"metadata"`             // Headers sent with every call
+}
+
+// GRPCCall is one of the RPCs making up the load
+type GRPCCall struct {
+	Method   string          `json:"method"`   // package.Service/Method
+	Request  json.RawMessage `json:"request"`  // Request message in protobuf's JSON mapping
+	Weight   int             `json:"weight"`   // Share of the calls relative to the other methods
+	Messages int             `json:"messages"` // Request messages sent per call by client and bidirectional streaming RPCs
+}
+
+// GRPCLoadPlugin calls the methods of a gRPC service at a set rate and concurrency.
+// The service is described by a protobuf descriptor set, so any service can be
+// loaded without generated code; calls are made over HTTP/2 as gRPC clients do.
+type GRPCLoadPlugin struct {
+	config    GRPCLoadConfig
+	methods   []*grpcMethod
+	weights   int
+	timeout   time.Duration
+	header    http.Header
+	baseURL   string
+	metrics   *GRPCLoadMetrics
+	mu        sync.RWMutex
+	stream    *metricStream
+	calls     int64
+	errors    int64
+	skipped   int64
+	inFlight  int64
+	loadLevel int64           // percent of the configured rate set by the run's load profile
+	latencies []time.Duration // latencies of calls since the last collection
+}
+
+// GRPCLoadMetrics tracks the calls of all methods over the last second
+type GRPCLoadMetrics struct {
+	CallsPerSec  int64   `json:"calls_per_sec"`
+	ErrorsPerSec int64   `json:"errors_per_sec"`
+	LatencyP50Ms float64 `json:"latency_p50_ms"`
+	LatencyP90Ms float64 `json:"latency_p90_ms"`
+	LatencyP99Ms float64 `json:"latency_p99_ms"`
+	LatencyMaxMs float64 `json:"latency_max_ms"`
+}
+
+// GRPCMethodResult is the outcome of the calls to one method. Latency percentiles
+// cover the calls that succeeded.
+type GRPCMethodResult struct {
+	Method    string           `json:"method"`
+	Calls     int64            `json:"calls"`
+	Errors    int64            `json:"errors"`
+	Messages  int64            `json:"messages"` // response messages received
+	P50Ms     float64          `json:"p50_ms"`
+	P90Ms     float64          `json:"p90_ms"`
+	P99Ms     float64          `json:"p99_ms"`
+	MaxMs     float64          `json:"max_ms"`
+	Codes     map[string]int64 `json:"codes"` // calls by gRPC status code
+	LastError string           `json:"last_error,omitempty"`
+}
+
+// grpcMethod is a call resolved against the descriptor set
+type grpcMethod struct {
+	name   string // package.Service/Method
+	path   string
+	output protoreflect.MessageDescriptor
+	body   []byte // framed request messages
+	weight int
+
+	mu        sync.Mutex
+	calls     int64
+	errors    int64
+	messages  int64
+	codes     map[string]int64
+	samples   []time.Duration // uniform sample of the latencies of successful calls
+	seen      int64           // successful calls offered to the sample
+	max       time.Duration
+	lastError string
+}
+
+// NewGRPCLoadPlugin creates a new gRPC load plugin
+func NewGRPCLoadPlugin() *GRPCLoadPlugin {
+	return &GRPCLoadPlugin{
+		metrics: &GRPCLoadMetrics{},
+	}
+}
+
+// Name returns the plugin name
+func (g *GRPCLoadPlugin) Name() string {
+	return "grpc-load"
+}
+
+// Version returns the plugin version
+func (g *GRPCLoadPlugin) Version() string {
+	return "1.0.0"
+}
+
+// Description returns the plugin description
+func (g *GRPCLoadPlugin) Description() string {
+	return "gRPC load plugin calling unary and streaming methods of a service described by a descriptor set, reporting latency percentiles and status codes per method"
+}
+
+// ConfigSchema returns the JSON schema for configuration
+func (g *GRPCLoadPlugin) ConfigSchema() []byte {
+	schema := `{
+		"type": "object",
+		"required": ["target", "calls"],
+		"properties": {
+			"target": {
+				"type": "string",
+				"description": "host:port of the gRPC service"
+			},
+			"tls": {
+				"type": "boolean",
+				"x-group": "Connection",
+				"default": false,
+				"description": "Connect over TLS; otherwise HTTP/2 is spoken in plaintext (h2c)"
+			},
+			"insecure_skip_verify": {
+				"type": "boolean",
+				"x-group": "Connection",
+				"default": false,
+				"description": "Accept any server certificate"
+			},
+			"descriptor_set": {
+				"type": "string",
+				"x-group": "Service",
+				"description": "Base64 FileDescriptorSet of the service, as written by protoc --include_imports --descriptor_set_out"
+			},
+			"descriptor_set_file": {
+				"type": "string",
+				"x-group": "Service",
+				"description": "Path of the FileDescriptorSet on the agent, instead of descriptor_set"
+			},
+			"calls": {
+				"type": "array",
+				"x-group": "Service",
+				"description": "Methods to call, picked at random by weight",
+				"items": {
+					"type": "object",
+					"required": ["method"],
+					"properties": {
+						"method": {
+							"type": "string",
+							"description": "Method as package.Service/Method"
+						},
+						"request": {
+							"type": "object",
+							"description": "Request message in protobuf's JSON mapping; empty sends the default message"
+						},
+						"weight": {
+							"type": "integer",
+							"minimum": 1,
+							"default": 1,
+							"description": "Share of the calls relative to the other methods"
+						},
+						"messages": {
+							"type": "integer",
+							"minimum": 1,
+							"maximum": 10000,
+							"default": 1,
+							"description": "Request messages sent per call by client and bidirectional streaming methods"
+						}
+					}
+				}
+			},
+			"rps": {
+				"type": "number",
+				"title": "RPS",
+				"x-group": "Load",
+				"minimum": 0,
+				"default": 0,
+				"description": "Calls started per second across all workers; 0 calls as fast as the workers allow"
+			},
+			"concurrency": {
+				"type": "integer",
+				"x-group": "Load",
+				"minimum": 1,
+				"maximum": 10000,
+				"default": 10,
+				"description": "Calls in flight at once; calls due while every worker is busy are skipped"
+			},
+			"connections": {
+				"type": "integer",
+				"x-group": "Load",
+				"minimum": 1,
+				"maximum": 1000,
+				"default": 1,
+				"description": "HTTP/2 connections the calls are spread over"
+			},
+			"timeout": {
+				"type": "string",
+				"x-group": "Load",
+				"default": "10s",
+				"description": "Deadline of each call"
+			},
+			"metadata": {
+				"type": "object",
+				"x-group": "Connection",
+				"description": "Metadata sent with every call, such as authorization"
+			}
+		}
+	}`
+	return []byte(schema)
+}
+
+// Initialize initializes the plugin with configuration
+func (g *GRPCLoadPlugin) Initialize(config interface{}) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	g.config = GRPCLoadConfig{
+		Concurrency: 10,
+		Connections: 1,
+		Timeout:     "10s",
+	}
+	if err := json.Unmarshal(configBytes, &g.config); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	if _, _, err := net.SplitHostPort(g.config.Target); err != nil {
+		return fmt.Errorf("invalid target %q, want host:port: %w", g.config.Target, err)
+	}
+	if g.config.RPS < 0 {
+		return fmt.Errorf("rps must not be negative")
+	}
+	if g.config.Concurrency < 1 || g.config.Concurrency > 10000 {
+		return fmt.Errorf("concurrency must be between 1 and 10000")
+	}
+	if g.config.Connections < 1 || g.config.Connections > 1000 {
+		return fmt.Errorf("connections must be between 1 and 1000")
+	}
+	if g.timeout, err = time.ParseDuration(g.config.Timeout); err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+	if g.timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+
+	g.header = http.Header{
+		"Content-Type": {"application/grpc+proto"},
+		"Te":           {"trailers"},
+		"User-Agent":   {"ssts-grpc-load/" + g.Version()},
+		"Grpc-Timeout": {grpcTimeout(g.timeout)},
+	}
+	for name, value := range g.config.Metadata {
+		key := strings.ToLower(name)
+		if key == "" || strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") ||
+			key == "content-type" || key == "te" || key == "user-agent" {
+			return fmt.Errorf("metadata %q is reserved", name)
+		}
+		g.header.Set(key, value)
+	}
+
+	scheme := "http"
+	if g.config.TLS {
+		scheme = "https"
+	}
+	g.baseURL = scheme + "://" + g.config.Target
+
+	files, err := g.loadDescriptorSet()
+	if err != nil {
+		return err
+	}
+	if len(g.config.Calls) == 0 {
+		return fmt.Errorf("at least one call is required")
+	}
+	g.methods = nil
+	g.weights = 0
+	for _, call := range g.config.Calls {
+		method, err := resolveGRPCCall(files, call)
+		if err != nil {
+			return fmt.Errorf("call %s: %w", call.Method, err)
+		}
+		g.methods = append(g.methods, method)
+		g.weights += method.weight
+	}
+
+	return nil
+}
+
+// loadDescriptorSet reads the descriptor set the configuration names
+func (g *GRPCLoadPlugin) loadDescriptorSet() (*protoregistry.Files, error) {
+	var data []byte
+	var err error
+	switch {
+	case g.config.DescriptorSet != "" && g.config.DescriptorSetFile != "":
+		return nil, fmt.Errorf("descriptor_set and descriptor_set_file are exclusive")
+	case g.config.DescriptorSet != "":
+		if data, err = base64.StdEncoding.DecodeString(g.config.DescriptorSet); err != nil {
+			return nil, fmt.Errorf("invalid descriptor_set: %w", err)
+		}
+	case g.config.DescriptorSetFile != "":
+		if data, err = os.ReadFile(g.config.DescriptorSetFile); err != nil {
+			return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("descriptor_set or descriptor_set_file is required")
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set (was it built with --include_imports?): %w", err)
+	}
+	return files, nil
+}
+
+// resolveGRPCCall finds a call's method in the descriptor set and encodes its
+// request messages
+func resolveGRPCCall(files *protoregistry.Files, call GRPCCall) (*grpcMethod, error) {
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(call.Method, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("method must be package.Service/Method")
+	}
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("no service %s in the descriptor set", serviceName)
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", serviceName)
+	}
+	md := service.Methods().ByName(protoreflect.Name(methodName))
+	if md == nil {
+		return nil, fmt.Errorf("service %s has no method %s", serviceName, methodName)
+	}
+
+	if call.Weight < 0 {
+		return nil, fmt.Errorf("weight must not be negative")
+	}
+	if call.Weight == 0 {
+		call.Weight = 1
+	}
+	if call.Messages < 0 || call.Messages > 10000 {
+		return nil, fmt.Errorf("messages must be between 1 and 10000")
+	}
+	if call.Messages == 0 || !md.IsStreamingClient() {
+		call.Messages = 1
+	}
+
+	request := dynamicpb.NewMessage(md.Input())
+	if len(call.Request) > 0 && string(call.Request) != "null" {
+		options := protojson.UnmarshalOptions{Resolver: dynamicpb.NewTypes(files)}
+		if err := options.Unmarshal(call.Request, request); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+	}
+	payload, err := proto.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	var body []byte
+	for i := 0; i < call.Messages; i++ {
+		body = appendGRPCMessage(body, payload)
+	}
+
+	name := string(service.FullName()) + "/" + string(md.Name())
+	return &grpcMethod{
+		name:   name,
+		path:   "/" + name,
+		output: md.Output(),
+		body:   body,
+		weight: call.Weight,
+		codes:  make(map[string]int64),
+	}, nil
+}
+
+// Preflight checks the configuration and that the target accepts connections
+func (g *GRPCLoadPlugin) Preflight(config interface{}) error {
+	if err := g.Initialize(config); err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", g.config.Target, grpcDialTimeout)
+	if err != nil {
+		return fmt.Errorf("target %s is unreachable: %w", g.config.Target, err)
+	}
+	return conn.Close()
+}
+
+// Execute calls the service until the test ends
+func (g *GRPCLoadPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	g.mu.Lock()
+	g.metrics = &GRPCLoadMetrics{}
+	g.latencies = nil
+	g.mu.Unlock()
+	atomic.StoreInt64(&g.calls, 0)
+	atomic.StoreInt64(&g.errors, 0)
+	atomic.StoreInt64(&g.skipped, 0)
+	atomic.StoreInt64(&g.loadLevel, 100)
+	for _, method := range g.methods {
+		method.reset()
+	}
+
+	transports := make([]*http2.Transport, g.config.Connections)
+	for i := range transports {
+		transports[i] = g.newTransport()
+	}
+	defer func() {
+		for _, transport := range transports {
+			transport.CloseIdleConnections()
+		}
+	}()
+
+	go g.collectMetrics(ctx)
+
+	var tokens chan struct{}
+	if g.config.RPS > 0 {
+		tokens = make(chan struct{}, g.config.Concurrency)
+		go g.pace(ctx, tokens)
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < g.config.Concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			transport := transports[worker%len(transports)]
+			rng := seededRand(params.Seed, worker)
+			for {
+				if tokens != nil {
+					select {
+					case <-tokens:
+					case <-ctx.Done():
+						return
+					}
+				} else if ctx.Err() != nil {
+					return
+				}
+				g.invoke(ctx, transport, g.pick(rng))
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// FollowsDeadline reports that the workers call the service until the context ends
+func (g *GRPCLoadPlugin) FollowsDeadline() bool {
+	return true
+}
+
+// newTransport creates an HTTP/2 transport, which keeps its own connection
+func (g *GRPCLoadPlugin) newTransport() *http2.Transport {
+	if g.config.TLS {
+		return &http2.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: g.config.InsecureSkipVerify},
+		}
+	}
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// pace releases calls to the workers at the configured rate, scaled by the load
+// level, counting the calls skipped because every worker was busy and the
+// backlog full
+func (g *GRPCLoadPlugin) pace(ctx context.Context, tokens chan<- struct{}) {
+	tick := time.Duration(float64(time.Second) / g.config.RPS)
+	if tick < grpcPaceTick {
+		tick = grpcPaceTick
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	last := time.Now()
+	var due float64 // calls owed, carrying fractions over to the next tick
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			due += now.Sub(last).Seconds() * g.config.RPS * float64(atomic.LoadInt64(&g.loadLevel)) / 100
+			last = now
+			for ; due >= 1; due-- {
+				select {
+				case tokens <- struct{}{}:
+				default:
+					atomic.AddInt64(&g.skipped, 1)
+				}
+			}
+		}
+	}
+}
+
+// SetLoadLevel scales the call rate of paced runs to the level of the run's
+// load profile; unpaced runs call as fast as the workers can regardless
+func (g *GRPCLoadPlugin) SetLoadLevel(percent int) {
+	atomic.StoreInt64(&g.loadLevel, int64(percent))
+}
+
+// pick chooses a method at random by weight
+func (g *GRPCLoadPlugin) pick(rng *rand.Rand) *grpcMethod {
+	n := rng.Intn(g.weights)
+	for _, method := range g.methods {
+		if n < method.weight {
+			return method
+		}
+		n -= method.weight
+	}
+	return g.methods[len(g.methods)-1]
+}
+
+// invoke makes one call and records its outcome
+func (g *GRPCLoadPlugin) invoke(ctx context.Context, transport http.RoundTripper, method *grpcMethod) {
+	atomic.AddInt64(&g.inFlight, 1)
+	start := time.Now()
+	code, messages, message := g.call(ctx, transport, method)
+	latency := time.Since(start)
+	atomic.AddInt64(&g.inFlight, -1)
+
+	// Calls cut short by the end of the test say nothing about the service
+	if code != grpcOK && ctx.Err() != nil {
+		return
+	}
+
+	atomic.AddInt64(&g.calls, 1)
+	if code != grpcOK {
+		atomic.AddInt64(&g.errors, 1)
+	}
+	method.record(code, latency, messages, message)
+
+	g.mu.Lock()
+	if code == grpcOK && len(g.latencies) < maxLatencySamples {
+		g.latencies = append(g.latencies, latency)
+	}
+	stream := g.stream
+	g.mu.Unlock()
+
+	stream.publish("grpc_call", stream.tags("method", method.name, "code", grpcCodeName(code)), models.GRPCCallFields{
+		LatencyUs: float64(latency.Nanoseconds()) / 1000.0,
+		Messages:  messages,
+	}.Fields())
+}
+
+// call makes one call, returning its status code, the number of response
+// messages and the status message
+func (g *GRPCLoadPlugin) call(ctx context.Context, transport http.RoundTripper, method *grpcMethod) (int, int, string) {
+	callCtx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(callCtx, http.MethodPost, g.baseURL+method.path, bytes.NewReader(method.body))
+	if err != nil {
+		return grpcInternal, 0, err.Error()
+	}
+	req.Header = g.header.Clone()
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return grpcTransportCode(callCtx, err), 0, err.Error()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return grpcHTTPStatusCode(resp.StatusCode), 0, resp.Status
+	}
+	// A call failing before any message is answered with the status in the headers
+	if resp.Header.Get("Grpc-Status") != "" {
+		code, message := grpcStatus(resp.Header)
+		return code, 0, message
+	}
+
+	messages := 0
+	for {
+		payload, err := readGRPCMessage(resp.Body)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return grpcTransportCode(callCtx, err), messages, err.Error()
+		}
+		if err := proto.Unmarshal(payload, dynamicpb.NewMessage(method.output)); err != nil {
+			return grpcInternal, messages, fmt.Sprintf("failed to decode response: %v", err)
+		}
+		messages++
+	}
+
+	code, message := grpcStatus(resp.Trailer)
+	return code, messages, message
+}
+
+// grpcStatus reads the status of a call from its trailers
+func grpcStatus(trailer http.Header) (int, string) {
+	status := trailer.Get("Grpc-Status")
+	if status == "" {
+		return grpcInternal, "response has no grpc-status"
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return grpcUnknown, fmt.Sprintf("invalid grpc-status %q", status)
+	}
+	message, err := url.PathUnescape(trailer.Get("Grpc-Message"))
+	if err != nil {
+		message = trailer.Get("Grpc-Message")
+	}
+	return code, message
+}
+
+// grpcTransportCode is the status of a call that failed below gRPC
+func grpcTransportCode(ctx context.Context, err error) int {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return grpcDeadlineExceeded
+	case errors.Is(err, errGRPCFraming):
+		return grpcInternal
+	default:
+		return grpcUnavailable
+	}
+}
+
+// grpcHTTPStatusCode maps the HTTP status of a response that isn't a gRPC
+// response, such as one from a proxy, as gRPC clients do
+func grpcHTTPStatusCode(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return grpcInternal
+	case http.StatusUnauthorized:
+		return grpcUnauthenticated
+	case http.StatusForbidden:
+		return grpcPermissionDenied
+	case http.StatusNotFound:
+		return grpcUnimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return grpcUnavailable
+	default:
+		return grpcUnknown
+	}
+}
+
+// grpcCodeName names a gRPC status code
+func grpcCodeName(code int) string {
+	if code >= 0 && code < len(grpcCodeNames) {
+		return grpcCodeNames[code]
+	}
+	return fmt.Sprintf("CODE_%d", code)
+}
+
+// grpcTimeout formats a deadline as the grpc-timeout header, which takes at
+// most eight digits
+func grpcTimeout(timeout time.Duration) string {
+	if ms := timeout.Milliseconds(); ms < 1e8 {
+		return fmt.Sprintf("%dm", ms)
+	}
+	return fmt.Sprintf("%dS", int64(timeout.Seconds()))
+}
+
+// appendGRPCMessage appends a message with gRPC's length prefix
+func appendGRPCMessage(dst, payload []byte) []byte {
+	dst = append(dst, 0) // not compressed
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	return append(dst, payload...)
+}
+
+// readGRPCMessage reads a length-prefixed message, returning io.EOF once the
+// stream ends between messages
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%w: truncated message", errGRPCFraming)
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("%w: compressed messages are not supported", errGRPCFraming)
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > grpcMaxMessage {
+		return nil, fmt.Errorf("%w: message of %d bytes exceeds %d", errGRPCFraming, length, grpcMaxMessage)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%w: truncated message", errGRPCFraming)
+		}
+		return nil, err
+	}
+	return payload, nil
+}
+
+// reset clears the method's results before a run
+func (m *grpcMethod) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls, m.errors, m.messages, m.seen, m.max = 0, 0, 0, 0, 0
+	m.codes = make(map[string]int64)
+	m.samples = nil
+	m.lastError = ""
+}
+
+// record counts a call, keeping a uniform sample of the latencies of the
+// successful ones
+func (m *grpcMethod) record(code int, latency time.Duration, messages int, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+	m.messages += int64(messages)
+	m.codes[grpcCodeName(code)]++
+	if code != grpcOK {
+		m.errors++
+		m.lastError = grpcCodeName(code) + ": " + message
+		return
+	}
+
+	if latency > m.max {
+		m.max = latency
+	}
+	m.seen++
+	if len(m.samples) < maxLatencySamples {
+		m.samples = append(m.samples, latency)
+	} else if i := rand.Int63n(m.seen); i < maxLatencySamples {
+		m.samples[i] = latency
+	}
+}
+
+// result summarizes the method's calls so far
+func (m *grpcMethod) result() GRPCMethodResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := GRPCMethodResult{
+		Method:    m.name,
+		Calls:     m.calls,
+		Errors:    m.errors,
+		Messages:  m.messages,
+		MaxMs:     float64(m.max) / float64(time.Millisecond),
+		Codes:     make(map[string]int64, len(m.codes)),
+		LastError: m.lastError,
+	}
+	for code, count := range m.codes {
+		result.Codes[code] = count
+	}
+	if len(m.samples) > 0 {
+		sorted := append([]time.Duration(nil), m.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		result.P50Ms = percentileMicros(sorted, 0.50) / 1000
+		result.P90Ms = percentileMicros(sorted, 0.90) / 1000
+		result.P99Ms = percentileMicros(sorted, 0.99) / 1000
+	}
+	return result
+}
+
+// collectMetrics computes the call rate and latencies of the last second
+func (g *GRPCLoadPlugin) collectMetrics(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	var lastCalls, lastErrors int64
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			calls := atomic.LoadInt64(&g.calls)
+			errs := atomic.LoadInt64(&g.errors)
+
+			g.mu.Lock()
+			samples := g.latencies
+			g.latencies = make([]time.Duration, 0, len(samples))
+
+			g.metrics.CallsPerSec = calls - lastCalls
+			g.metrics.ErrorsPerSec = errs - lastErrors
+			if len(samples) > 0 {
+				sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+				g.metrics.LatencyP50Ms = percentileMicros(samples, 0.50) / 1000
+				g.metrics.LatencyP90Ms = percentileMicros(samples, 0.90) / 1000
+				g.metrics.LatencyP99Ms = percentileMicros(samples, 0.99) / 1000
+				g.metrics.LatencyMaxMs = float64(samples[len(samples)-1]) / float64(time.Millisecond)
+			}
+			g.mu.Unlock()
+
+			lastCalls, lastErrors = calls, errs
+		}
+	}
+}
+
+// Results returns the outcome of the calls to each method, in the order of the calls
+func (g *GRPCLoadPlugin) Results() []GRPCMethodResult {
+	results := make([]GRPCMethodResult, 0, len(g.methods))
+	for _, method := range g.methods {
+		results = append(results, method.result())
+	}
+	return results
+}
+
+// Cleanup has nothing to release; connections are closed when Execute returns
+func (g *GRPCLoadPlugin) Cleanup() error {
+	return nil
+}
+
+// GetMetrics returns current metrics, with the results of each method
+func (g *GRPCLoadPlugin) GetMetrics() map[string]interface{} {
+	methods := make(map[string]GRPCMethodResult, len(g.methods))
+	for _, result := range g.Results() {
+		methods[result.Method] = result
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return map[string]interface{}{
+		"calls_per_sec":  g.metrics.CallsPerSec,
+		"errors_per_sec": g.metrics.ErrorsPerSec,
+		"latency_p50_ms": g.metrics.LatencyP50Ms,
+		"latency_p90_ms": g.metrics.LatencyP90Ms,
+		"latency_p99_ms": g.metrics.LatencyP99Ms,
+		"latency_max_ms": g.metrics.LatencyMaxMs,
+		"total_calls":    atomic.LoadInt64(&g.calls),
+		"total_errors":   atomic.LoadInt64(&g.errors),
+		"skipped_calls":  atomic.LoadInt64(&g.skipped),
+		"in_flight":      atomic.LoadInt64(&g.inFlight),
+		"stream_dropped": g.stream.droppedCount(),
+		"methods":        methods,
+	}
+}
+
+// MetricsStream streams the latency and status of every call
+func (g *GRPCLoadPlugin) MetricsStream(ctx context.Context) <-chan models.MetricPoint {
+	stream := newMetricStream(ctx, defaultStreamBuffer)
+
+	g.mu.Lock()
+	g.stream = stream
+	g.mu.Unlock()
+
+	return stream.ch
+}
+
+// GetSafetyLimits returns safety limits for gRPC load, which mostly loads the
+// target rather than this machine
+func (g *GRPCLoadPlugin) GetSafetyLimits() models.SafetyLimits {
+	return models.SafetyLimits{
+		MaxCPUPercent:    90.0,
+		MaxMemoryPercent: 50.0,
+		MaxDiskPercent:   50.0,
+		MaxNetworkMbps:   10000.0,
+	}
+}
+
+// HealthCheck always passes; the plugin only needs sockets
+func (g *GRPCLoadPlugin) HealthCheck() error {
+	return nil
+}
@@ -0,0 +1,275 @@
+package plugins
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// echoDescriptorSet describes an echo service with unary, server streaming and
+// client streaming methods
+func echoDescriptorSet(t *testing.T) *descriptorpb.FileDescriptorSet {
+	t.Helper()
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     kind.Enum(),
+		}
+	}
+	method := func(name string, clientStreaming, serverStreaming bool) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".echo.EchoRequest"),
+			OutputType:      proto.String(".echo.EchoReply"),
+			ClientStreaming: proto.Bool(clientStreaming),
+			ServerStreaming: proto.Bool(serverStreaming),
+		}
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("echo.proto"),
+		Package: proto.String("echo"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("EchoRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("text", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+			}},
+			{Name: proto.String("EchoReply"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("text", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("Say", false, false),
+				method("Repeat", false, true),
+				method("Collect", true, false),
+				method("Fail", false, false),
+			},
+		}},
+	}}}
+}
+
+// newEchoServer serves the echo service over plaintext HTTP/2. Repeat answers
+// with count replies, Collect with the number of requests it got, and Fail with
+// NOT_FOUND.
+func newEchoServer(t *testing.T, set *descriptorpb.FileDescriptorSet) *httptest.Server {
+	t.Helper()
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		t.Fatalf("NewFiles: %v", err)
+	}
+	request, _ := files.FindDescriptorByName("echo.EchoRequest")
+	reply, _ := files.FindDescriptorByName("echo.EchoReply")
+	requestDesc := request.(protoreflect.MessageDescriptor)
+	replyDesc := reply.(protoreflect.MessageDescriptor)
+
+	writeReply := func(w http.ResponseWriter, text string) {
+		message := dynamicpb.NewMessage(replyDesc)
+		message.Set(replyDesc.Fields().ByName("text"), protoreflect.ValueOfString(text))
+		payload, _ := proto.Marshal(message)
+		w.Write(appendGRPCMessage(nil, payload))
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		if r.URL.Path == "/echo.Echo/Fail" {
+			io.Copy(io.Discard, r.Body)
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "no%20such%20thing")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		var requests []*dynamicpb.Message
+		for {
+			payload, err := readGRPCMessage(r.Body)
+			if err != nil {
+				break
+			}
+			message := dynamicpb.NewMessage(requestDesc)
+			proto.Unmarshal(payload, message)
+			requests = append(requests, message)
+		}
+		w.WriteHeader(http.StatusOK)
+
+		text := requests[0].Get(requestDesc.Fields().ByName("text")).String()
+		switch r.URL.Path {
+		case "/echo.Echo/Say":
+			writeReply(w, text)
+		case "/echo.Echo/Repeat":
+			for i := int64(0); i < requests[0].Get(requestDesc.Fields().ByName("count")).Int(); i++ {
+				writeReply(w, text)
+			}
+		case "/echo.Echo/Collect":
+			writeReply(w, fmt.Sprint(len(requests)))
+		}
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "0")
+	})
+
+	server := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// echoConfig is a configuration loading the echo server with the calls given
+func echoConfig(t *testing.T, server *httptest.Server, set *descriptorpb.FileDescriptorSet, calls []map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return map[string]interface{}{
+		"target":         strings.TrimPrefix(server.URL, "http://"),
+		"descriptor_set": base64.StdEncoding.EncodeToString(data),
+		"calls":          calls,
+		"metadata":       map[string]string{"authorization": "Bearer token"},
+	}
+}
+
+// runGRPCLoad loads the service for a while and returns each method's results by name
+func runGRPCLoad(t *testing.T, config map[string]interface{}, duration time.Duration) (*GRPCLoadPlugin, map[string]GRPCMethodResult) {
+	t.Helper()
+	plugin := NewGRPCLoadPlugin()
+	if err := plugin.Initialize(config); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	plugin.Execute(ctx, models.TestParams{Duration: duration})
+
+	results := make(map[string]GRPCMethodResult)
+	for _, result := range plugin.Results() {
+		results[result.Method] = result
+	}
+	return plugin, results
+}
+
+func TestGRPCLoadCallsUnaryAndStreamingMethods(t *testing.T) {
+	set := echoDescriptorSet(t)
+	server := newEchoServer(t, set)
+	config := echoConfig(t, server, set, []map[string]interface{}{
+		{"method": "echo.Echo/Say", "request": map[string]interface{}{"text": "hello"}, "weight": 2},
+		{"method": "echo.Echo/Repeat", "request": map[string]interface{}{"text": "hi", "count": 3}},
+		{"method": "/echo.Echo/Collect", "request": map[string]interface{}{"text": "x"}, "messages": 4},
+		{"method": "echo.Echo/Fail"},
+	})
+	config["concurrency"] = 4
+
+	_, results := runGRPCLoad(t, config, 500*time.Millisecond)
+
+	for _, name := range []string{"echo.Echo/Say", "echo.Echo/Repeat", "echo.Echo/Collect"} {
+		result := results[name]
+		if result.Calls == 0 || result.Errors != 0 || result.Codes["OK"] != result.Calls {
+			t.Errorf("%s = %+v, want only successful calls", name, result)
+		}
+		if result.P50Ms <= 0 || result.P99Ms < result.P50Ms || result.MaxMs < result.P99Ms {
+			t.Errorf("%s latencies = %v/%v/%v ms", name, result.P50Ms, result.P99Ms, result.MaxMs)
+		}
+	}
+	if repeat := results["echo.Echo/Repeat"]; repeat.Messages != 3*repeat.Calls {
+		t.Errorf("Repeat got %d messages in %d calls, want 3 per call", repeat.Messages, repeat.Calls)
+	}
+
+	fail := results["echo.Echo/Fail"]
+	if fail.Calls == 0 || fail.Errors != fail.Calls || fail.Codes["NOT_FOUND"] != fail.Calls {
+		t.Errorf("Fail = %+v, want every call NOT_FOUND", fail)
+	}
+	if fail.LastError != "NOT_FOUND: no such thing" {
+		t.Errorf("Fail last error = %q", fail.LastError)
+	}
+}
+
+func TestGRPCLoadPacesCalls(t *testing.T) {
+	set := echoDescriptorSet(t)
+	server := newEchoServer(t, set)
+	config := echoConfig(t, server, set, []map[string]interface{}{{"method": "echo.Echo/Say"}})
+	config["rps"] = 100
+
+	_, results := runGRPCLoad(t, config, 500*time.Millisecond)
+
+	// 500ms at 100 calls a second is about 50 calls
+	if calls := results["echo.Echo/Say"].Calls; calls < 30 || calls > 55 {
+		t.Errorf("made %d calls, want about 50", calls)
+	}
+}
+
+func TestGRPCLoadReportsUnauthenticatedCalls(t *testing.T) {
+	set := echoDescriptorSet(t)
+	server := newEchoServer(t, set)
+	config := echoConfig(t, server, set, []map[string]interface{}{{"method": "echo.Echo/Say"}})
+	delete(config, "metadata")
+
+	plugin, results := runGRPCLoad(t, config, 200*time.Millisecond)
+
+	if say := results["echo.Echo/Say"]; say.Calls == 0 || say.Codes["UNAUTHENTICATED"] != say.Calls {
+		t.Errorf("Say = %+v, want every call UNAUTHENTICATED", say)
+	}
+	if metrics := plugin.GetMetrics(); metrics["total_errors"] != metrics["total_calls"] {
+		t.Errorf("errors = %v, calls = %v", metrics["total_errors"], metrics["total_calls"])
+	}
+}
+
+func TestGRPCLoadRejectsInvalidConfigs(t *testing.T) {
+	set := echoDescriptorSet(t)
+	server := newEchoServer(t, set)
+
+	tests := []struct {
+		name   string
+		change func(map[string]interface{})
+		want   string
+	}{
+		{"unknown service", func(c map[string]interface{}) {
+			c["calls"] = []map[string]interface{}{{"method": "echo.Other/Say"}}
+		}, "no service echo.Other"},
+		{"unknown method", func(c map[string]interface{}) {
+			c["calls"] = []map[string]interface{}{{"method": "echo.Echo/Shout"}}
+		}, "has no method Shout"},
+		{"invalid request", func(c map[string]interface{}) {
+			c["calls"] = []map[string]interface{}{{"method": "echo.Echo/Say", "request": map[string]interface{}{"volume": 11}}}
+		}, "invalid request"},
+		{"no descriptor set", func(c map[string]interface{}) {
+			delete(c, "descriptor_set")
+		}, "descriptor_set or descriptor_set_file is required"},
+		{"reserved metadata", func(c map[string]interface{}) {
+			c["metadata"] = map[string]string{"grpc-timeout": "1S"}
+		}, "is reserved"},
+		{"no calls", func(c map[string]interface{}) {
+			c["calls"] = []map[string]interface{}{}
+		}, "at least one call"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := echoConfig(t, server, set, []map[string]interface{}{{"method": "echo.Echo/Say"}})
+			tt.change(config)
+			err := NewGRPCLoadPlugin().Initialize(config)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Initialize() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package plugins
 
 import (
 	"context"
+	"sync"
 
 	"github.com/pranavgopavaram/ssts/pkg/models"
 )
@@ -31,32 +32,77 @@ type StressPlugin interface {
 	HealthCheck() error
 }
 
+// PluginFactory creates a fresh plugin instance
+type PluginFactory func() StressPlugin
+
 // PluginManager manages the loading and execution of plugins
 type PluginManager struct {
-	plugins map[string]StressPlugin
+	mu        sync.RWMutex
+	plugins   map[string]StressPlugin
+	factories map[string]PluginFactory
+	disabled  map[string]bool
 }
 
 // NewPluginManager creates a new plugin manager
 func NewPluginManager() *PluginManager {
 	return &PluginManager{
-		plugins: make(map[string]StressPlugin),
+		plugins:   make(map[string]StressPlugin),
+		factories: make(map[string]PluginFactory),
+		disabled:  make(map[string]bool),
 	}
 }
 
-// RegisterPlugin registers a plugin with the manager
+// RegisterPlugin registers a plugin instance with the manager. The same instance
+// runs every execution, so it must cope with concurrent executions itself; plugins
+// holding per-run state should be registered with RegisterFactory instead.
 func (pm *PluginManager) RegisterPlugin(plugin StressPlugin) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
 	pm.plugins[plugin.Name()] = plugin
+	delete(pm.factories, plugin.Name())
+	return nil
+}
+
+// RegisterFactory registers a plugin by its factory, so that every execution gets
+// its own instance with its own configuration, stop channel and metrics. One
+// instance is created up front to describe the plugin in GetPlugin and ListPlugins.
+func (pm *PluginManager) RegisterFactory(factory PluginFactory) error {
+	prototype := factory()
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.plugins[prototype.Name()] = prototype
+	pm.factories[prototype.Name()] = factory
+	return nil
+}
+
+// UnregisterPlugin removes a plugin from the manager. Executions already running
+// keep their reference to the plugin and finish normally.
+func (pm *PluginManager) UnregisterPlugin(name string) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if _, exists := pm.plugins[name]; !exists {
+		return ErrPluginNotFound
+	}
+	delete(pm.plugins, name)
+	delete(pm.factories, name)
+	delete(pm.disabled, name)
 	return nil
 }
 
-// GetPlugin retrieves a plugin by name
+// GetPlugin retrieves a plugin by name. For plugins registered with RegisterFactory
+// this is the instance describing the plugin; use NewInstance to run it.
 func (pm *PluginManager) GetPlugin(name string) (StressPlugin, bool) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
 	plugin, exists := pm.plugins[name]
 	return plugin, exists
 }
 
 // ListPlugins returns all registered plugins
 func (pm *PluginManager) ListPlugins() []StressPlugin {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
 	plugins := make([]StressPlugin, 0, len(pm.plugins))
 	for _, plugin := range pm.plugins {
 		plugins = append(plugins, plugin)
@@ -64,13 +110,61 @@ func (pm *PluginManager) ListPlugins() []StressPlugin {
 	return plugins
 }
 
-// ExecutePlugin executes a plugin with given parameters
-func (pm *PluginManager) ExecutePlugin(ctx context.Context, name string, config interface{}, params models.TestParams) error {
-	plugin, exists := pm.GetPlugin(name)
-	if !exists {
+// SetEnabled enables or disables a registered plugin. Disabled plugins stay
+// registered and listed but can't be executed.
+func (pm *PluginManager) SetEnabled(name string, enabled bool) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if _, exists := pm.plugins[name]; !exists {
 		return ErrPluginNotFound
 	}
+	if enabled {
+		delete(pm.disabled, name)
+	} else {
+		pm.disabled[name] = true
+	}
+	return nil
+}
+
+// IsEnabled reports whether a plugin may be executed; plugins are enabled by default
+func (pm *PluginManager) IsEnabled(name string) bool {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return !pm.disabled[name]
+}
+
+// NewInstance returns the plugin instance to run an execution with: a fresh one from
+// the factory, or the shared instance of a plugin registered with RegisterPlugin
+func (pm *PluginManager) NewInstance(name string) (StressPlugin, error) {
+	pm.mu.RLock()
+	plugin, exists := pm.plugins[name]
+	factory := pm.factories[name]
+	pm.mu.RUnlock()
+
+	if !exists {
+		return nil, ErrPluginNotFound
+	}
+	if factory != nil {
+		return factory(), nil
+	}
+	return plugin, nil
+}
+
+// ExecutePlugin executes a plugin with given parameters on a new instance
+func (pm *PluginManager) ExecutePlugin(ctx context.Context, name string, config interface{}, params models.TestParams) error {
+	plugin, err := pm.NewInstance(name)
+	if err != nil {
+		return err
+	}
+	if !pm.IsEnabled(name) {
+		return ErrPluginNotEnabled
+	}
+
+	return pm.RunPlugin(ctx, plugin, config, params)
+}
 
+// RunPlugin initializes and executes a plugin instance, cleaning it up afterwards
+func (pm *PluginManager) RunPlugin(ctx context.Context, plugin StressPlugin, config interface{}, params models.TestParams) error {
 	if err := plugin.Initialize(config); err != nil {
 		return err
 	}
@@ -78,4 +172,4 @@ func (pm *PluginManager) ExecutePlugin(ctx context.Context, name string, config
 	defer plugin.Cleanup()
 
 	return plugin.Execute(ctx, params)
-}
\ No newline at end of file
+}
@@ -0,0 +1,114 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// labelPlugin remembers the label it was initialized with and reports it as a metric
+type labelPlugin struct {
+	label string
+}
+
+func (p *labelPlugin) Name() string         { return "label" }
+func (p *labelPlugin) Version() string      { return "1.0.0" }
+func (p *labelPlugin) Description() string  { return "test plugin" }
+func (p *labelPlugin) ConfigSchema() []byte { return []byte(`{"type": "object"}`) }
+func (p *labelPlugin) Cleanup() error       { return nil }
+func (p *labelPlugin) HealthCheck() error   { return nil }
+
+func (p *labelPlugin) GetSafetyLimits() models.SafetyLimits { return models.SafetyLimits{} }
+
+func (p *labelPlugin) Initialize(config interface{}) error {
+	p.label = config.(string)
+	return nil
+}
+
+func (p *labelPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (p *labelPlugin) GetMetrics() map[string]interface{} {
+	return map[string]interface{}{"label": p.label}
+}
+
+func TestRegisterFactoryGivesExecutionsTheirOwnInstance(t *testing.T) {
+	pm := NewPluginManager()
+	pm.RegisterFactory(func() StressPlugin { return &labelPlugin{} })
+
+	if _, exists := pm.GetPlugin("label"); !exists {
+		t.Fatal("factory plugin not listed by GetPlugin")
+	}
+
+	first, err := pm.NewInstance("label")
+	if err != nil {
+		t.Fatalf("NewInstance: %v", err)
+	}
+	second, err := pm.NewInstance("label")
+	if err != nil {
+		t.Fatalf("NewInstance: %v", err)
+	}
+	if first == second {
+		t.Fatal("NewInstance returned the same instance twice")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for plugin, label := range map[StressPlugin]string{first: "a", second: "b"} {
+		wg.Add(1)
+		go func(plugin StressPlugin, label string) {
+			defer wg.Done()
+			pm.RunPlugin(ctx, plugin, label, models.TestParams{})
+		}(plugin, label)
+	}
+	cancel()
+	wg.Wait()
+
+	if got := first.GetMetrics()["label"]; got != "a" {
+		t.Errorf("first instance label = %v, want a", got)
+	}
+	if got := second.GetMetrics()["label"]; got != "b" {
+		t.Errorf("second instance label = %v, want b", got)
+	}
+}
+
+func TestNewInstanceOfSingletonAndMissingPlugin(t *testing.T) {
+	pm := NewPluginManager()
+	plugin := &labelPlugin{}
+	pm.RegisterPlugin(plugin)
+
+	instance, err := pm.NewInstance("label")
+	if err != nil {
+		t.Fatalf("NewInstance: %v", err)
+	}
+	if instance != plugin {
+		t.Error("NewInstance of a plugin registered with RegisterPlugin should return that instance")
+	}
+
+	if _, err := pm.NewInstance("missing"); !errors.Is(err, ErrPluginNotFound) {
+		t.Errorf("NewInstance(missing) = %v, want ErrPluginNotFound", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		plugin StressPlugin
+		want   models.PluginCapabilities
+	}{
+		{NewCPUStressPlugin(), models.PluginCapabilities{Pause: true, Throttle: true, Progress: true}},
+		{NewNetworkNetemPlugin(), models.PluginCapabilities{RequiresRoot: true}},
+		{NewIOStressPlugin(), models.PluginCapabilities{Destructive: true, Progress: true}},
+		{&ExternalPlugin{definition: models.Plugin{Capabilities: models.PluginCapabilities{Pause: true, Destructive: true}}},
+			models.PluginCapabilities{Destructive: true}},
+	}
+	for _, tt := range tests {
+		if got := Capabilities(tt.plugin); got != tt.want {
+			t.Errorf("Capabilities(%T) = %+v, want %+v", tt.plugin, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package plugins
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Phases of the NVMe workflow, as reported in its results
+const (
+	NVMePhasePurge         = "purge"
+	NVMePhaseFreshOutOfBox = "fresh-out-of-box"
+	NVMePhaseFill          = "sequential-fill"
+	NVMePhasePrecondition  = "precondition"
+	NVMePhaseDone          = "done"
+)
+
+// nvmeFillBlockSize is the size of the writes that fill regions sequentially
+const nvmeFillBlockSize = 128 * 1024
+
+// deviceDiscard discards (TRIMs) a range of a block device. It is a variable so
+// tests can stand a regular file in for a device.
+var deviceDiscard = discardDeviceRange
+
+// NVMePerformance is the performance of the configured random workload over one
+// or more measurement rounds
+type NVMePerformance struct {
+	IOPS                 float64 `json:"iops"`
+	BandwidthBytesPerSec float64 `json:"bandwidth_bytes_per_sec"`
+	AvgLatencyUs         float64 `json:"avg_latency_us"`
+}
+
+// NVMeResult is what the NVMe workflow has measured so far. Fresh-out-of-box
+// performance is that of trimmed flash; steady state is the average over the
+// window of preconditioning rounds that met the steady state criteria, and is
+// only reported once they have been met.
+type NVMeResult struct {
+	Phase              string            `json:"phase"`
+	FreshOutOfBox      *NVMePerformance  `json:"fresh_out_of_box,omitempty"`
+	SteadyState        *NVMePerformance  `json:"steady_state,omitempty"`
+	SteadyStateReached bool              `json:"steady_state_reached"`
+	Rounds             []NVMePerformance `json:"rounds"`      // random preconditioning rounds, in order
+	FillPasses         int               `json:"fill_passes"` // sequential fills completed
+	Trims              int               `json:"trims"`       // times every region was discarded
+	TrimError          string            `json:"trim_error,omitempty"`
+}
+
+// validateNVMe checks the NVMe workflow settings and fills in their defaults
+func (i *IOStressPlugin) validateNVMe() error {
+	if !i.config.NVMe {
+		return nil
+	}
+	if !i.config.RawDevice {
+		return fmt.Errorf("nvme mode tests block devices: set raw_device and name them as targets")
+	}
+	if i.config.Operations == "read" {
+		return fmt.Errorf("nvme mode preconditions drives by writing to them: operations must be write or mixed")
+	}
+	if i.tbwBytes > 0 {
+		return fmt.Errorf("nvme mode ends when the drive reaches steady state and can't be combined with tbw")
+	}
+	if i.config.Engine != IOEngineSync {
+		return fmt.Errorf("nvme mode uses the sync engine")
+	}
+
+	if i.config.PreconditionPasses <= 0 {
+		i.config.PreconditionPasses = 2
+	}
+	if i.config.RoundDuration == "" {
+		i.config.RoundDuration = "1m"
+	}
+	roundDuration, err := time.ParseDuration(i.config.RoundDuration)
+	if err != nil {
+		return fmt.Errorf("invalid round_duration: %w", err)
+	}
+	if roundDuration <= 0 {
+		return fmt.Errorf("round_duration must be positive")
+	}
+	i.roundDuration = roundDuration
+	if i.config.SteadyStateRounds <= 0 {
+		i.config.SteadyStateRounds = 5
+	}
+	if i.config.SteadyStateRounds < 2 {
+		return fmt.Errorf("steady_state_rounds must be at least 2")
+	}
+	if i.config.SteadyStateTolerance <= 0 {
+		i.config.SteadyStateTolerance = 20
+	}
+	if i.config.MaxRounds <= 0 {
+		i.config.MaxRounds = 25
+	}
+	if i.config.MaxRounds < i.config.SteadyStateRounds {
+		return fmt.Errorf("max_rounds (%d) must be at least steady_state_rounds (%d)", i.config.MaxRounds, i.config.SteadyStateRounds)
+	}
+	if i.config.Trim == nil {
+		trim := true
+		i.config.Trim = &trim
+	}
+
+	// Every round measures random I/O
+	i.config.Sequential = false
+	return nil
+}
+
+// runNVMeWorkflow measures the workers' regions the way SSD performance is
+// specified: discard them, measure a fresh-out-of-box round, discard again, fill
+// them sequentially, then run random rounds until their IOPS reach steady state
+// or max_rounds is used up
+func (i *IOStressPlugin) runNVMeWorkflow(ctx context.Context) error {
+	i.mu.RLock()
+	rngs := make([]*mathrand.Rand, len(i.testFiles))
+	for worker := range rngs {
+		rngs[worker] = seededRand(i.seed, worker)
+	}
+	i.mu.RUnlock()
+
+	i.setNVMePhase(NVMePhasePurge)
+	i.trimRegions()
+
+	i.setNVMePhase(NVMePhaseFreshOutOfBox)
+	fob, err := i.nvmeRound(ctx, rngs)
+	if err != nil {
+		return err
+	}
+	i.mu.Lock()
+	i.nvme.FreshOutOfBox = &fob
+	i.mu.Unlock()
+
+	// Start preconditioning from trimmed flash, so what the fresh-out-of-box round
+	// wrote doesn't count towards it
+	i.setNVMePhase(NVMePhasePurge)
+	i.trimRegions()
+
+	i.setNVMePhase(NVMePhaseFill)
+	for pass := 0; pass < i.config.PreconditionPasses; pass++ {
+		if err := i.sequentialFill(ctx); err != nil {
+			return err
+		}
+		i.mu.Lock()
+		i.nvme.FillPasses++
+		i.mu.Unlock()
+	}
+
+	i.setNVMePhase(NVMePhasePrecondition)
+	window := i.config.SteadyStateRounds
+	var iops []float64
+	for round := 0; round < i.config.MaxRounds; round++ {
+		performance, err := i.nvmeRound(ctx, rngs)
+		if err != nil {
+			return err
+		}
+		iops = append(iops, performance.IOPS)
+
+		i.mu.Lock()
+		i.nvme.Rounds = append(i.nvme.Rounds, performance)
+		if steadyState(iops, window, i.config.SteadyStateTolerance) {
+			average := averagePerformance(i.nvme.Rounds[len(i.nvme.Rounds)-window:])
+			i.nvme.SteadyState = &average
+			i.nvme.SteadyStateReached = true
+		}
+		reached := i.nvme.SteadyStateReached
+		i.mu.Unlock()
+		if reached {
+			break
+		}
+	}
+
+	i.setNVMePhase(NVMePhaseDone)
+	return nil
+}
+
+// setNVMePhase records the phase the NVMe workflow has reached
+func (i *IOStressPlugin) setNVMePhase(phase string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.nvme.Phase = phase
+}
+
+// trimRegions discards every worker's region. Once a discard fails, as it does on
+// drives without TRIM support, the error is recorded and the rest of the run
+// goes without.
+func (i *IOStressPlugin) trimRegions() {
+	if !*i.config.Trim {
+		return
+	}
+	i.mu.RLock()
+	testFiles := append([]ioTestFile(nil), i.testFiles...)
+	failed := i.nvme.TrimError != ""
+	i.mu.RUnlock()
+	if failed {
+		return
+	}
+
+	for _, testFile := range testFiles {
+		if err := deviceDiscard(testFile.path, testFile.offset, i.fileSizeBytes); err != nil {
+			i.mu.Lock()
+			i.nvme.TrimError = fmt.Sprintf("%s: %v", testFile.path, err)
+			i.mu.Unlock()
+			return
+		}
+	}
+	i.mu.Lock()
+	i.nvme.Trims++
+	i.mu.Unlock()
+}
+
+// nvmeRound runs the configured random workload on every region for one round
+// and measures its performance
+func (i *IOStressPlugin) nvmeRound(ctx context.Context, rngs []*mathrand.Rand) (NVMePerformance, error) {
+	roundCtx, cancel := context.WithTimeout(ctx, i.roundDuration)
+	defer cancel()
+
+	i.mu.RLock()
+	testFiles := append([]ioTestFile(nil), i.testFiles...)
+	i.mu.RUnlock()
+
+	var mu sync.Mutex
+	var ops, failures int64
+	var latency time.Duration
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for worker, testFile := range testFiles {
+		wg.Add(1)
+		go func(testFile ioTestFile, rng *mathrand.Rand) {
+			defer wg.Done()
+			for roundCtx.Err() == nil {
+				opStart := time.Now()
+				operation, err := i.performIOOperation(testFile, rng)
+				opLatency := time.Since(opStart)
+
+				i.mu.Lock()
+				if err != nil {
+					i.metrics.ErrorCount++
+				} else {
+					i.metrics.AvgLatencyMs = float64(opLatency.Nanoseconds()) / 1000000.0
+				}
+				stream := i.stream
+				i.mu.Unlock()
+
+				mu.Lock()
+				if err != nil {
+					failures++
+				} else {
+					ops++
+					latency += opLatency
+				}
+				mu.Unlock()
+
+				if err != nil {
+					// Don't spin on a device that keeps failing
+					time.Sleep(1 * time.Millisecond)
+					continue
+				}
+				stream.publish("io_operation", stream.tags("operation", operation, "target", testFile.target), models.IOOperationFields{
+					LatencyUs: float64(opLatency.Nanoseconds()) / 1000.0,
+					Bytes:     i.blockSizeBytes,
+				}.Fields())
+			}
+		}(testFile, rngs[worker])
+	}
+	wg.Wait()
+	elapsed := time.Since(start).Seconds()
+
+	if err := ctx.Err(); err != nil {
+		return NVMePerformance{}, err
+	}
+	if ops == 0 && failures > 0 {
+		return NVMePerformance{}, fmt.Errorf("nvme round failed: all %d operations failed", failures)
+	}
+
+	performance := NVMePerformance{}
+	if elapsed > 0 {
+		performance.IOPS = float64(ops) / elapsed
+		performance.BandwidthBytesPerSec = float64(ops*i.blockSizeBytes) / elapsed
+	}
+	if ops > 0 {
+		performance.AvgLatencyUs = float64(latency.Nanoseconds()) / float64(ops) / 1000.0
+	}
+	return performance, nil
+}
+
+// sequentialFill writes every worker's region once from start to end
+func (i *IOStressPlugin) sequentialFill(ctx context.Context) error {
+	i.mu.RLock()
+	testFiles := append([]ioTestFile(nil), i.testFiles...)
+	i.mu.RUnlock()
+
+	errs := make(chan error, len(testFiles))
+	var wg sync.WaitGroup
+	for _, testFile := range testFiles {
+		wg.Add(1)
+		go func(testFile ioTestFile) {
+			defer wg.Done()
+			errs <- i.fillRegion(ctx, testFile)
+		}(testFile)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// fillRegion writes a region sequentially with random data
+func (i *IOStressPlugin) fillRegion(ctx context.Context, testFile ioTestFile) error {
+	file, err := os.OpenFile(testFile.path, rawDeviceOpenFlags(os.O_WRONLY, i.config.Direct), 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buffer := alignedBuffer(nvmeFillBlockSize, i.deviceAlignment(testFile.target))
+	if _, err := rand.Read(buffer); err != nil {
+		return err
+	}
+
+	for written := int64(0); written < i.fileSizeBytes; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		chunk := buffer
+		if remaining := i.fileSizeBytes - written; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		n, err := file.WriteAt(chunk, testFile.offset+written)
+		if err != nil {
+			return fmt.Errorf("sequential fill of %s failed: %w", testFile.path, err)
+		}
+		written += int64(n)
+
+		i.mu.Lock()
+		i.countIO(testFile.target, "write", int64(n))
+		i.mu.Unlock()
+	}
+
+	if i.config.Fsync {
+		return file.Sync()
+	}
+	return nil
+}
+
+// steadyState reports whether the last window values are in steady state as SSD
+// performance is specified: they range over no more than tolerance percent of
+// their average, and their best-fit line moves by no more than half that across
+// the window
+func steadyState(values []float64, window int, tolerance float64) bool {
+	if window < 2 || len(values) < window {
+		return false
+	}
+	values = values[len(values)-window:]
+
+	var sum, minimum, maximum float64
+	minimum, maximum = values[0], values[0]
+	for _, value := range values {
+		sum += value
+		minimum = math.Min(minimum, value)
+		maximum = math.Max(maximum, value)
+	}
+	average := sum / float64(window)
+	if average <= 0 {
+		return false
+	}
+	if maximum-minimum > average*tolerance/100 {
+		return false
+	}
+
+	// Least squares slope over x = 0..window-1
+	meanX := float64(window-1) / 2
+	var covariance, variance float64
+	for x, value := range values {
+		dx := float64(x) - meanX
+		covariance += dx * (value - average)
+		variance += dx * dx
+	}
+	slope := covariance / variance
+	return math.Abs(slope)*float64(window-1) <= average*tolerance/200
+}
+
+// averagePerformance averages the performance of measurement rounds
+func averagePerformance(rounds []NVMePerformance) NVMePerformance {
+	var average NVMePerformance
+	if len(rounds) == 0 {
+		return average
+	}
+	for _, round := range rounds {
+		average.IOPS += round.IOPS
+		average.BandwidthBytesPerSec += round.BandwidthBytesPerSec
+		average.AvgLatencyUs += round.AvgLatencyUs
+	}
+	n := float64(len(rounds))
+	average.IOPS /= n
+	average.BandwidthBytesPerSec /= n
+	average.AvgLatencyUs /= n
+	return average
+}
+
+// nvmeMetrics adds the NVMe workflow's results to the metrics, along with its
+// fresh-out-of-box and steady-state figures as numbers of their own. The caller
+// must hold i.mu.
+func (i *IOStressPlugin) nvmeMetrics(metrics map[string]interface{}) {
+	result := *i.nvme
+	result.Rounds = append([]NVMePerformance{}, i.nvme.Rounds...)
+	metrics["nvme"] = result
+
+	if fob := result.FreshOutOfBox; fob != nil {
+		metrics["fob_iops"] = fob.IOPS
+		metrics["fob_bandwidth_bytes_per_sec"] = fob.BandwidthBytesPerSec
+		metrics["fob_avg_latency_us"] = fob.AvgLatencyUs
+	}
+	if steady := result.SteadyState; steady != nil {
+		metrics["steady_state_iops"] = steady.IOPS
+		metrics["steady_state_bandwidth_bytes_per_sec"] = steady.BandwidthBytesPerSec
+		metrics["steady_state_avg_latency_us"] = steady.AvgLatencyUs
+	}
+}
@@ -0,0 +1,388 @@
+package plugins
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// rawSMARTInterval is how often the SMART log of a device tested directly is read
+const rawSMARTInterval = 30 * time.Second
+
+// RawDeviceInfo describes a block device tested directly, as the kernel reports it
+type RawDeviceInfo struct {
+	Path              string `json:"path"`
+	SizeBytes         int64  `json:"size_bytes"`
+	LogicalBlockSize  int64  `json:"logical_block_size"`
+	PhysicalBlockSize int64  `json:"physical_block_size"`
+	Model             string `json:"model,omitempty"`
+	Serial            string `json:"serial,omitempty"`
+	Rotational        bool   `json:"rotational"`
+}
+
+// RawDeviceStats are a device's own counters: those the kernel keeps for the
+// block device and, for NVMe drives, the drive's SMART log
+type RawDeviceStats struct {
+	RawDeviceInfo
+	ReadsCompleted  uint64    `json:"reads_completed"`
+	SectorsRead     uint64    `json:"sectors_read"`
+	WritesCompleted uint64    `json:"writes_completed"`
+	SectorsWritten  uint64    `json:"sectors_written"`
+	InFlight        uint64    `json:"in_flight"`
+	BusyMs          uint64    `json:"busy_ms"` // time the device had I/O in flight
+	SMART           *SMARTLog `json:"smart,omitempty"`
+}
+
+// SMARTLog holds the health figures of an NVMe drive's SMART / health log page
+type SMARTLog struct {
+	CriticalWarning  uint8   `json:"critical_warning"`
+	TemperatureC     float64 `json:"temperature_c"`
+	AvailableSpare   uint8   `json:"available_spare_percent"`
+	PercentageUsed   uint8   `json:"percentage_used"`
+	DataUnitsRead    uint64  `json:"data_units_read"`    // in thousands of 512-byte units
+	DataUnitsWritten uint64  `json:"data_units_written"` // in thousands of 512-byte units
+	PowerOnHours     uint64  `json:"power_on_hours"`
+	UnsafeShutdowns  uint64  `json:"unsafe_shutdowns"`
+	MediaErrors      uint64  `json:"media_errors"`
+}
+
+// blockDeviceProbe reads a block device's size, block sizes and identity. It is a
+// variable so tests can stand a regular file in for a device.
+var blockDeviceProbe = probeBlockDevice
+
+// validateRawDevices checks the targets of a raw device run: each must be a block
+// device not in use, sized and aligned for the blocks and
+// regions the workers use, and, unless the run only reads, listed in
+// confirm_destroy since writing to it destroys its data
+func (i *IOStressPlugin) validateRawDevices(defaultTargets bool) error {
+	if defaultTargets {
+		return fmt.Errorf("raw_device needs targets naming the block devices to test")
+	}
+	if i.blockSizeBytes <= 0 || i.blockSizeBytes > i.fileSizeBytes {
+		return fmt.Errorf("block_size must be positive and not larger than file_size")
+	}
+
+	confirmed := make(map[string]bool, len(i.config.ConfirmDestroy))
+	for _, device := range i.config.ConfirmDestroy {
+		confirmed[resolveDevicePath(device)] = true
+	}
+
+	i.rawDevices = make(map[string]RawDeviceInfo, len(i.config.Targets))
+	for _, target := range i.config.Targets {
+		info, err := blockDeviceProbe(target.Path)
+		if err != nil {
+			return fmt.Errorf("raw device %s: %w", target.Path, err)
+		}
+		use, err := deviceInUse(target.Path)
+		if err != nil {
+			return fmt.Errorf("raw device %s: can't tell whether it is in use: %w", target.Path, err)
+		}
+		if use != "" {
+			return fmt.Errorf("raw device %s %s; only devices not in use can be tested directly", target.Path, use)
+		}
+		if i.config.Operations != "read" && !confirmed[resolveDevicePath(target.Path)] {
+			return fmt.Errorf("writing to %s destroys the data on it: list it in confirm_destroy to go ahead", target.Path)
+		}
+
+		align := info.LogicalBlockSize
+		if i.config.Direct && align < directIOAlignment {
+			align = directIOAlignment
+		}
+		if align > 0 && (i.blockSizeBytes%align != 0 || i.fileSizeBytes%align != 0) {
+			return fmt.Errorf("raw device %s needs block_size and file_size to be multiples of %d bytes", target.Path, align)
+		}
+		i.rawDevices[target.Path] = info
+	}
+	return nil
+}
+
+// checkDeviceCapacity verifies each device holds a file_size region for each of
+// its workers
+func (i *IOStressPlugin) checkDeviceCapacity(workers []int) error {
+	for idx, target := range i.config.Targets {
+		info := i.rawDevices[target.Path]
+		if required := int64(workers[idx]) * i.fileSizeBytes; required > info.SizeBytes {
+			return fmt.Errorf("raw device %s is too small: %d workers need %d MB but it holds %d MB",
+				target.Path, workers[idx], required/(1024*1024), info.SizeBytes/(1024*1024))
+		}
+	}
+	return nil
+}
+
+// assignDeviceRegions gives each worker its own file_size region of its target
+// device, in place of a test file
+func (i *IOStressPlugin) assignDeviceRegions(workers []int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for idx, target := range i.config.Targets {
+		for n := 0; n < workers[idx]; n++ {
+			i.testFiles = append(i.testFiles, ioTestFile{
+				path:   target.Path,
+				target: target.Path,
+				offset: int64(n) * i.fileSizeBytes,
+				raw:    true,
+			})
+		}
+	}
+}
+
+// deviceKey identifies the device a target is on, for claims and write totals
+func (i *IOStressPlugin) deviceKey(path string) string {
+	if !i.config.RawDevice {
+		return deviceForPath(path)
+	}
+	if info, ok := i.rawDevices[path]; ok && info.Serial != "" {
+		return info.Serial
+	}
+	return resolveDevicePath(path)
+}
+
+// ioOffset picks where in a test file or device region the next operation goes.
+// Offsets on raw devices are whole blocks, as direct I/O on them needs.
+func (i *IOStressPlugin) ioOffset(testFile ioTestFile, rng *rand.Rand) int64 {
+	if i.config.Sequential {
+		return testFile.offset
+	}
+	if testFile.raw {
+		blocks := i.fileSizeBytes / i.blockSizeBytes
+		return testFile.offset + rng.Int63n(blocks)*i.blockSizeBytes
+	}
+	var offset int64
+	if maxOffset := i.fileSizeBytes - i.blockSizeBytes; maxOffset > 0 {
+		offset = rng.Int63n(maxOffset)
+	}
+	return offset
+}
+
+// ioBuffer returns a buffer for one operation. Those for raw devices start on a
+// block boundary, as direct I/O on them needs.
+func (i *IOStressPlugin) ioBuffer(testFile ioTestFile) []byte {
+	if !testFile.raw {
+		return make([]byte, i.blockSizeBytes)
+	}
+	return alignedBuffer(int(i.blockSizeBytes), i.deviceAlignment(testFile.target))
+}
+
+// deviceAlignment is the boundary buffers for a raw device start on: its logical
+// block size, and at least what direct I/O needs
+func (i *IOStressPlugin) deviceAlignment(target string) int {
+	align := i.rawDevices[target].LogicalBlockSize
+	if align < directIOAlignment {
+		align = directIOAlignment
+	}
+	return int(align)
+}
+
+// alignedBuffer returns a buffer of size bytes starting on a multiple of align
+func alignedBuffer(size, align int) []byte {
+	buf := make([]byte, size+align)
+	shift := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) % uintptr(align)); rem != 0 {
+		shift = align - rem
+	}
+	return buf[shift : shift+size]
+}
+
+// sampleRawDevices refreshes the counters of the devices tested directly, and
+// their SMART logs when withSMART is set
+func (i *IOStressPlugin) sampleRawDevices(withSMART bool) {
+	i.mu.RLock()
+	devices := make(map[string]RawDeviceInfo, len(i.rawDevices))
+	for path, info := range i.rawDevices {
+		devices[path] = info
+	}
+	i.mu.RUnlock()
+
+	for path, info := range devices {
+		stats := &RawDeviceStats{RawDeviceInfo: info}
+		if counters, err := os.ReadFile(filepath.Join(blockSysfsDir(path), "stat")); err == nil {
+			parseBlockStat(string(counters), stats)
+		}
+
+		i.mu.Lock()
+		if previous := i.deviceStats[path]; previous != nil {
+			stats.SMART = previous.SMART
+		}
+		i.mu.Unlock()
+		if withSMART {
+			if page, err := readNVMeSMART(path); err == nil {
+				log := parseNVMeSMART(page)
+				stats.SMART = &log
+			}
+		}
+
+		i.mu.Lock()
+		i.deviceStats[path] = stats
+		i.mu.Unlock()
+	}
+}
+
+// Where the kernel lists block devices and the active swap areas. They are
+// variables so tests can stand fixtures in for them.
+var (
+	blockSysfsRoot = "/sys/class/block"
+	procSwaps      = "/proc/swaps"
+)
+
+// deviceInUse says how a device, or one of its partitions, is in use: with a file
+// system mounted, held by another device such as an LVM volume group, an md array
+// or a device mapper target, or as active swap. It is empty if the device isn't in
+// use, and an error is returned if that can't be told.
+func deviceInUse(device string) (string, error) {
+	device = resolveDevicePath(device)
+	if mount, err := mountedFrom(device); err != nil || mount != "" {
+		return mount, err
+	}
+	if holder, err := heldBy(device); err != nil || holder != "" {
+		return holder, err
+	}
+	return swappedOn(device)
+}
+
+// mountedFrom says where a file system on a device, or one of its partitions, is
+// mounted
+func mountedFrom(device string) (string, error) {
+	partitions, err := diskPartitions(true)
+	if err != nil {
+		return "", fmt.Errorf("reading the mounted file systems: %w", err)
+	}
+	for _, mount := range partitions {
+		source := resolveDevicePath(mount.Device)
+		if source == device || isPartitionOf(source, device) {
+			return fmt.Sprintf("has a file system mounted at %s", mount.Mountpoint), nil
+		}
+	}
+	return "", nil
+}
+
+// heldBy says which device holds a device, or one of its partitions, from the
+// holders directories in sysfs
+func heldBy(device string) (string, error) {
+	dir := blockSysfsDir(device)
+	dirs := []string{dir}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("reading %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(dir, entry.Name(), "partition")); err == nil {
+			dirs = append(dirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	for _, dir := range dirs {
+		holders, err := os.ReadDir(filepath.Join(dir, "holders"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading the holders of %s: %w", filepath.Base(dir), err)
+		}
+		if len(holders) > 0 {
+			return fmt.Sprintf("is held by %s", holders[0].Name()), nil
+		}
+	}
+	return "", nil
+}
+
+// swappedOn says whether a device, or one of its partitions, is active swap
+func swappedOn(device string) (string, error) {
+	swaps, err := os.ReadFile(procSwaps)
+	if err != nil {
+		return "", fmt.Errorf("reading the active swap areas: %w", err)
+	}
+	for _, line := range strings.Split(string(swaps), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		source := resolveDevicePath(fields[0])
+		if source == device || isPartitionOf(source, device) {
+			return fmt.Sprintf("has swap active on %s", fields[0]), nil
+		}
+	}
+	return "", nil
+}
+
+// isPartitionOf reports whether a device path names a partition of a disk, such
+// as /dev/sda1 of /dev/sda or /dev/nvme0n1p2 of /dev/nvme0n1. Partitions of
+// disks whose names end in a digit are separated from them by a p.
+func isPartitionOf(partition, disk string) bool {
+	suffix := strings.TrimPrefix(partition, disk)
+	if suffix == partition || suffix == "" || disk == "" {
+		return false
+	}
+	if last := disk[len(disk)-1]; last >= '0' && last <= '9' {
+		if !strings.HasPrefix(suffix, "p") {
+			return false
+		}
+		suffix = suffix[1:]
+	}
+	_, err := strconv.Atoi(suffix)
+	return err == nil
+}
+
+// resolveDevicePath follows links such as /dev/disk/by-id/... to the device node
+func resolveDevicePath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
+
+// blockSysfsDir is the sysfs directory of a block device
+func blockSysfsDir(device string) string {
+	return filepath.Join(blockSysfsRoot, filepath.Base(resolveDevicePath(device)))
+}
+
+// parseBlockStat reads the counters of a block device's sysfs stat file: reads,
+// merges, sectors and ticks for reads, then the same for writes, then the I/O in
+// flight and time busy
+func parseBlockStat(stat string, stats *RawDeviceStats) {
+	fields := strings.Fields(stat)
+	value := func(idx int) uint64 {
+		if idx >= len(fields) {
+			return 0
+		}
+		n, _ := strconv.ParseUint(fields[idx], 10, 64)
+		return n
+	}
+	stats.ReadsCompleted = value(0)
+	stats.SectorsRead = value(2)
+	stats.WritesCompleted = value(4)
+	stats.SectorsWritten = value(6)
+	stats.InFlight = value(8)
+	stats.BusyMs = value(9)
+}
+
+// nvmeSMARTLogSize is the size of the NVMe SMART / health log page
+const nvmeSMARTLogSize = 512
+
+// parseNVMeSMART decodes an NVMe SMART / health log page. Its 128-bit counters
+// are read as their low 64 bits.
+func parseNVMeSMART(page []byte) SMARTLog {
+	if len(page) < nvmeSMARTLogSize {
+		return SMARTLog{}
+	}
+	counter := func(offset int) uint64 { return binary.LittleEndian.Uint64(page[offset:]) }
+	log := SMARTLog{
+		CriticalWarning:  page[0],
+		AvailableSpare:   page[3],
+		PercentageUsed:   page[5],
+		DataUnitsRead:    counter(32),
+		DataUnitsWritten: counter(48),
+		PowerOnHours:     counter(128),
+		UnsafeShutdowns:  counter(144),
+		MediaErrors:      counter(160),
+	}
+	if kelvin := binary.LittleEndian.Uint16(page[1:]); kelvin > 0 {
+		log.TemperatureC = float64(kelvin) - 273.15
+	}
+	return log
+}
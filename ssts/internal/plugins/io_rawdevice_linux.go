@@ -0,0 +1,151 @@
+//go:build linux
+
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unsafe"
+
+	"github.com/shirou/gopsutil/v3/disk"
+	"golang.org/x/sys/unix"
+)
+
+// NVMe admin command ABI from linux/nvme_ioctl.h
+const (
+	nvmeIoctlAdminCmd   = 0xC0484E41 // _IOWR('N', 0x41, struct nvme_admin_cmd)
+	nvmeAdminGetLogPage = 0x02
+	nvmeLogSMART        = 0x02
+	nvmeNSIDAll         = 0xFFFFFFFF
+)
+
+// nvmeAdminCmd is struct nvme_admin_cmd
+type nvmeAdminCmd struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMs   uint32
+	result      uint32
+}
+
+// probeBlockDevice reads a block device's size and block sizes with ioctls, and
+// its model and serial number from sysfs
+func probeBlockDevice(path string) (RawDeviceInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return RawDeviceInfo{}, err
+	}
+	if stat.Mode()&os.ModeDevice == 0 || stat.Mode()&os.ModeCharDevice != 0 {
+		return RawDeviceInfo{}, fmt.Errorf("not a block device")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return RawDeviceInfo{}, err
+	}
+	defer file.Close()
+	fd := int(file.Fd())
+
+	info := RawDeviceInfo{Path: path}
+	var size uint64
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.BLKGETSIZE64, uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return RawDeviceInfo{}, fmt.Errorf("failed to read device size: %w", errno)
+	}
+	info.SizeBytes = int64(size)
+	logical, err := unix.IoctlGetInt(fd, unix.BLKSSZGET)
+	if err != nil {
+		return RawDeviceInfo{}, fmt.Errorf("failed to read logical block size: %w", err)
+	}
+	info.LogicalBlockSize = int64(logical)
+	if physical, err := unix.IoctlGetInt(fd, unix.BLKPBSZGET); err == nil {
+		info.PhysicalBlockSize = int64(physical)
+	}
+
+	sysfs := blockSysfsDir(path)
+	info.Model = readSysfsString(filepath.Join(sysfs, "device", "model"))
+	info.Serial = readSysfsString(filepath.Join(sysfs, "device", "serial"))
+	if info.Serial == "" {
+		if serial, err := disk.SerialNumber(resolveDevicePath(path)); err == nil {
+			info.Serial = serial
+		}
+	}
+	info.Rotational = readSysfsString(filepath.Join(sysfs, "queue", "rotational")) == "1"
+	return info, nil
+}
+
+// readSysfsString reads a sysfs attribute, empty if it can't be read
+func readSysfsString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readNVMeSMART reads the SMART / health log page of an NVMe drive with an admin
+// command, which needs CAP_SYS_ADMIN
+func readNVMeSMART(path string) ([]byte, error) {
+	if !strings.HasPrefix(filepath.Base(resolveDevicePath(path)), "nvme") {
+		return nil, fmt.Errorf("SMART is only read from NVMe drives")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	page := make([]byte, nvmeSMARTLogSize)
+	cmd := nvmeAdminCmd{
+		opcode:  nvmeAdminGetLogPage,
+		nsid:    nvmeNSIDAll,
+		addr:    uint64(uintptr(unsafe.Pointer(&page[0]))),
+		dataLen: nvmeSMARTLogSize,
+		cdw10:   nvmeLogSMART | (nvmeSMARTLogSize/4-1)<<16,
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(&cmd))); errno != 0 {
+		return nil, fmt.Errorf("failed to read SMART log: %w", errno)
+	}
+	runtime.KeepAlive(page)
+	return page, nil
+}
+
+// rawDeviceOpenFlags adds O_DIRECT to the flags a raw device is opened with
+// when direct I/O is asked for
+func rawDeviceOpenFlags(flags int, direct bool) int {
+	if direct {
+		flags |= unix.O_DIRECT
+	}
+	return flags
+}
+
+// discardDeviceRange discards (TRIMs) length bytes of a block device from offset,
+// telling the drive the range holds no data
+func discardDeviceRange(path string, offset, length int64) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	span := [2]uint64{uint64(offset), uint64(length)}
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), unix.BLKDISCARD, uintptr(unsafe.Pointer(&span[0]))); errno != 0 {
+		return fmt.Errorf("discard failed: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package plugins
+
+import "errors"
+
+var errNoRawDevices = errors.New("raw device mode is only available on Linux")
+
+func probeBlockDevice(path string) (RawDeviceInfo, error) {
+	return RawDeviceInfo{}, errNoRawDevices
+}
+
+func readNVMeSMART(path string) ([]byte, error) {
+	return nil, errNoRawDevices
+}
+
+func rawDeviceOpenFlags(flags int, direct bool) int {
+	return flags
+}
+
+func discardDeviceRange(path string, offset, length int64) error {
+	return errNoRawDevices
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	mathrand "math/rand"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -13,6 +14,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/shirou/gopsutil/v3/disk"
+
 	"github.com/pranavgopavaram/ssts/pkg/models"
 )
 
@@ -27,6 +30,37 @@ type IOStressConfig struct {
 	TempDir       string `json:"temp_dir"`       // Directory for test files
 	Sequential    bool   `json:"sequential"`     // Sequential vs random I/O
 	ReadWriteRatio float64 `json:"read_write_ratio"` // For mixed operations (0.0-1.0)
+	Targets        []IOTarget `json:"targets"`        // Directories to spread test files across (default: temp_dir)
+	HeadroomPercent float64   `json:"headroom_percent"` // Free space to leave untouched on each target
+	TBW             string    `json:"tbw"`              // Bytes to write before stopping (e.g. 500GB); the duration becomes an upper bound
+	TempDirPolicy   string    `json:"temp_dir_policy"`  // warn, refuse or auto when a target is on tmpfs or a nearly full root file system
+	Engine          string    `json:"engine"`           // sync or io_uring
+	QueueDepth      int       `json:"queue_depth"`      // io_uring operations in flight per worker
+	Polling         string    `json:"polling"`          // io_uring polling: none, sqpoll or iopoll
+	RawDevice       bool       `json:"raw_device"`       // targets are block devices tested directly, without a file system
+	ConfirmDestroy  []string   `json:"confirm_destroy"`  // block devices whose data raw device writes may destroy
+	NVMe            bool       `json:"nvme"`             // SSD workflow: purge, fresh-out-of-box round, preconditioning to steady state
+	PreconditionPasses   int     `json:"precondition_passes"`            // sequential fills of the regions before random preconditioning
+	RoundDuration        string  `json:"round_duration"`                 // length of each NVMe measurement round (e.g. 1m)
+	SteadyStateRounds    int     `json:"steady_state_rounds"`            // rounds in the steady state window
+	SteadyStateTolerance float64 `json:"steady_state_tolerance_percent"` // how far IOPS may vary across the window, as a percentage of its average
+	MaxRounds            int     `json:"max_rounds"`                     // preconditioning rounds before giving up on steady state
+	Trim                 *bool   `json:"trim"`                           // discard the regions between phases (default true)
+}
+
+// IOTarget is a directory (typically a mount point) that receives a weighted share of the workers
+type IOTarget struct {
+	Path   string `json:"path"`
+	Weight int    `json:"weight"`
+}
+
+// ioTestFile is a test file and the target it lives on. In raw device mode it is
+// instead a region of the target device starting at offset.
+type ioTestFile struct {
+	path   string
+	target string
+	offset int64
+	raw    bool
 }
 
 // IOStressPlugin implements I/O stress testing
@@ -34,10 +68,27 @@ type IOStressPlugin struct {
 	config      IOStressConfig
 	metrics     *IOMetrics
 	mu          sync.RWMutex
-	testFiles   []string
+	testFiles   []ioTestFile
 	stopChan    chan bool
 	fileSizeBytes int64
 	blockSizeBytes int64
+	stream      *metricStream
+	tbwBytes    int64             // write budget, 0 for duration-only runs
+	written     int64             // bytes written to the targets, test file creation included
+	devices     map[string]string // target path to the device it lives on
+	deviceWrites map[string]int64 // bytes written per device
+	budgetDone  chan struct{}     // closed once the write budget is spent
+	budgetOnce  sync.Once
+	warnings    []string // problems with the targets found by Initialize
+	latencyOps        int64         // io_uring operations completed since metrics were last collected
+	submitLatency     time.Duration // summed over latencyOps
+	completionLatency time.Duration // summed over latencyOps
+	workerErr         error         // first error that stopped a worker
+	rawDevices        map[string]RawDeviceInfo   // target devices of a raw device run
+	deviceStats       map[string]*RawDeviceStats // their counters, as last sampled
+	seed              int64                      // of the run's random offsets and read/write choices
+	roundDuration     time.Duration              // of NVMe measurement rounds
+	nvme              *NVMeResult                // the NVMe workflow's results so far
 }
 
 // IOMetrics tracks I/O stress test metrics
@@ -51,13 +102,28 @@ type IOMetrics struct {
 	TotalBytesRead   int64   `json:"total_bytes_read"`
 	TotalBytesWritten int64  `json:"total_bytes_written"`
 	ErrorCount       int64   `json:"error_count"`
+	AvgSubmitLatencyUs     float64 `json:"avg_submit_latency_us"`     // io_uring only
+	AvgCompletionLatencyUs float64 `json:"avg_completion_latency_us"` // io_uring only
+	Targets          map[string]*IOTargetMetrics `json:"targets"`
+}
+
+// IOTargetMetrics tracks throughput for a single target
+type IOTargetMetrics struct {
+	ReadBytesPerSec   int64 `json:"read_bytes_per_sec"`
+	WriteBytesPerSec  int64 `json:"write_bytes_per_sec"`
+	TotalBytesRead    int64 `json:"total_bytes_read"`
+	TotalBytesWritten int64 `json:"total_bytes_written"`
+	Workers           int   `json:"workers"`
 }
 
+// diskUsage reports the capacity of the file system holding a path
+var diskUsage = disk.Usage
+
 // NewIOStressPlugin creates a new I/O stress plugin
 func NewIOStressPlugin() *IOStressPlugin {
 	return &IOStressPlugin{
 		metrics:   &IOMetrics{},
-		testFiles: make([]string, 0),
+		testFiles: make([]ioTestFile, 0),
 		stopChan:  make(chan bool),
 	}
 }
@@ -77,6 +143,12 @@ func (i *IOStressPlugin) Description() string {
 	return "I/O stress testing plugin for disk and file system performance"
 }
 
+// DeclaredCapabilities declares that the plugin may destroy data, as writes to
+// raw block devices overwrite whatever is on them
+func (i *IOStressPlugin) DeclaredCapabilities() models.PluginCapabilities {
+	return models.PluginCapabilities{Destructive: true}
+}
+
 // ConfigSchema returns the JSON schema for configuration
 func (i *IOStressPlugin) ConfigSchema() []byte {
 	schema := `{
@@ -107,19 +179,29 @@ func (i *IOStressPlugin) ConfigSchema() []byte {
 			},
 			"fsync": {
 				"type": "boolean",
+				"title": "Synchronous writes",
 				"default": false,
 				"description": "Force synchronous writes"
 			},
 			"direct": {
 				"type": "boolean",
+				"title": "Direct I/O",
 				"default": false,
 				"description": "Use direct I/O (unbuffered)"
 			},
 			"temp_dir": {
 				"type": "string",
+				"title": "Temporary directory",
 				"default": "/tmp",
 				"description": "Directory for temporary test files"
 			},
+			"temp_dir_policy": {
+				"type": "string",
+				"title": "Unsuitable temp directory",
+				"enum": ["warn", "refuse", "auto"],
+				"default": "warn",
+				"description": "What to do when a target is on tmpfs, which would stress RAM rather than disk, or on a root file system left with little free space: warn, refuse to run, or (auto) move temp_dir to the largest suitable data mount"
+			},
 			"sequential": {
 				"type": "boolean",
 				"default": true,
@@ -131,6 +213,114 @@ func (i *IOStressPlugin) ConfigSchema() []byte {
 				"maximum": 1.0,
 				"default": 0.5,
 				"description": "Ratio of reads to writes for mixed operations"
+			},
+			"targets": {
+				"type": "array",
+				"x-group": "Targets",
+				"description": "Directories or mount points to spread test files across, each with a worker weight (default: temp_dir)"
+			},
+			"headroom_percent": {
+				"type": "number",
+				"x-group": "Targets",
+				"minimum": 0.0,
+				"maximum": 90.0,
+				"default": 10.0,
+				"description": "Percentage of each target's capacity that must remain free after test files are created"
+			},
+			"engine": {
+				"type": "string",
+				"title": "I/O engine",
+				"x-group": "Engine",
+				"enum": ["sync", "io_uring"],
+				"default": "sync",
+				"description": "sync runs one blocking operation at a time per worker; io_uring (Linux 5.6+) keeps queue_depth operations in flight per worker to reach realistic NVMe queue depths"
+			},
+			"queue_depth": {
+				"type": "integer",
+				"x-group": "Engine",
+				"minimum": 1,
+				"maximum": 1024,
+				"default": 32,
+				"description": "Operations each io_uring worker keeps in flight"
+			},
+			"polling": {
+				"type": "string",
+				"x-group": "Engine",
+				"enum": ["none", "sqpoll", "iopoll"],
+				"default": "none",
+				"description": "io_uring polling: sqpoll has a kernel thread pick up submissions, iopoll polls the device for completions and needs direct I/O"
+			},
+			"raw_device": {
+				"type": "boolean",
+				"title": "Raw device mode",
+				"x-group": "Raw device",
+				"default": false,
+				"description": "Test the block devices named as targets (e.g. /dev/nvme0n1) directly, without a file system; each worker gets its own file_size region of its device. Devices in use (with mounted file systems, held by LVM, md or device mapper, or used as swap) are refused."
+			},
+			"confirm_destroy": {
+				"type": "array",
+				"title": "Confirm data loss",
+				"x-group": "Raw device",
+				"items": {"type": "string"},
+				"description": "Raw device mode writes destroy the data on a device: list every target device to confirm it may be overwritten. Read-only runs don't need this."
+			},
+			"tbw": {
+				"type": "string",
+				"title": "Total bytes written",
+				"x-group": "Endurance",
+				"description": "Endurance mode: total bytes to write (e.g. 500GB, 2TB) before the test completes; the duration becomes an upper bound"
+			},
+			"nvme": {
+				"type": "boolean",
+				"title": "NVMe test mode",
+				"x-group": "NVMe",
+				"default": false,
+				"description": "Measure raw devices the way SSD vendors specify them: discard the regions, measure a fresh-out-of-box round, discard again, fill the regions sequentially, then run random rounds until performance reaches steady state. Fresh-out-of-box and steady-state results are reported separately. Needs raw_device and writes; the test ends when the workflow does."
+			},
+			"precondition_passes": {
+				"type": "integer",
+				"x-group": "NVMe",
+				"minimum": 1,
+				"maximum": 10,
+				"default": 2,
+				"description": "Sequential fills of each region before the random preconditioning rounds"
+			},
+			"round_duration": {
+				"type": "string",
+				"x-group": "NVMe",
+				"default": "1m",
+				"description": "Length of each measurement round (e.g. 30s, 1m)"
+			},
+			"steady_state_rounds": {
+				"type": "integer",
+				"x-group": "NVMe",
+				"minimum": 2,
+				"maximum": 20,
+				"default": 5,
+				"description": "Consecutive rounds whose IOPS must agree for the drive to be in steady state"
+			},
+			"steady_state_tolerance_percent": {
+				"type": "number",
+				"x-group": "NVMe",
+				"minimum": 1.0,
+				"maximum": 100.0,
+				"default": 20.0,
+				"description": "Steady state allows IOPS across the window to range over this percentage of their average, and their best-fit line to move by half of it"
+			},
+			"max_rounds": {
+				"type": "integer",
+				"x-group": "NVMe",
+				"minimum": 2,
+				"maximum": 100,
+				"default": 25,
+				"description": "Random preconditioning rounds to run before giving up on reaching steady state"
+			},
+			"trim": {
+				"type": "boolean",
+				"title": "TRIM between phases",
+				"x-group": "NVMe",
+				"default": true,
+				"description": "Discard (TRIM) the regions before the fresh-out-of-box round and before preconditioning; drives that don't support it are measured without"
 			}
 		}
 	}`
@@ -167,6 +357,25 @@ func (i *IOStressPlugin) Initialize(config interface{}) error {
 	if i.config.ReadWriteRatio <= 0 {
 		i.config.ReadWriteRatio = 0.5
 	}
+	if i.config.HeadroomPercent <= 0 {
+		i.config.HeadroomPercent = 10
+	}
+	switch i.config.TempDirPolicy {
+	case "":
+		i.config.TempDirPolicy = TempDirPolicyWarn
+	case TempDirPolicyWarn, TempDirPolicyRefuse, TempDirPolicyAuto:
+	default:
+		return fmt.Errorf("invalid temp_dir_policy %q: must be warn, refuse or auto", i.config.TempDirPolicy)
+	}
+	defaultTargets := len(i.config.Targets) == 0
+	if defaultTargets {
+		i.config.Targets = []IOTarget{{Path: i.config.TempDir, Weight: 1}}
+	}
+	for idx := range i.config.Targets {
+		if i.config.Targets[idx].Weight <= 0 {
+			i.config.Targets[idx].Weight = 1
+		}
+	}
 
 	// Parse sizes
 	i.fileSizeBytes, err = i.parseSize(i.config.FileSize)
@@ -179,20 +388,151 @@ func (i *IOStressPlugin) Initialize(config interface{}) error {
 		return fmt.Errorf("invalid block_size: %w", err)
 	}
 
-	// Validate temp directory
-	if _, err := os.Stat(i.config.TempDir); os.IsNotExist(err) {
-		return fmt.Errorf("temp directory does not exist: %s", i.config.TempDir)
+	i.tbwBytes = 0
+	if i.config.TBW != "" {
+		i.tbwBytes, err = i.parseSize(i.config.TBW)
+		if err != nil {
+			return fmt.Errorf("invalid tbw: %w", err)
+		}
+		if i.tbwBytes <= 0 {
+			return fmt.Errorf("tbw must be positive")
+		}
+		if i.config.Operations == "read" {
+			return fmt.Errorf("tbw requires write or mixed operations")
+		}
+	}
+
+	if err := i.validateEngine(); err != nil {
+		return err
+	}
+
+	if i.config.Workers < len(i.config.Targets) {
+		return fmt.Errorf("workers (%d) must be at least the number of targets (%d)", i.config.Workers, len(i.config.Targets))
+	}
+
+	if err := i.validateNVMe(); err != nil {
+		return err
+	}
+
+	// Raw devices have no file system for test files or temp directory checks
+	if i.config.RawDevice {
+		return i.validateRawDevices(defaultTargets)
+	}
+
+	// Validate target directories
+	for _, target := range i.config.Targets {
+		if _, err := os.Stat(target.Path); os.IsNotExist(err) {
+			return fmt.Errorf("target directory does not exist: %s", target.Path)
+		}
+	}
+
+	return i.applyTempDirPolicy(defaultTargets)
+}
+
+// workersPerTarget splits the workers across targets in proportion to their weights,
+// giving every target at least one worker
+func (i *IOStressPlugin) workersPerTarget() []int {
+	targets := i.config.Targets
+	counts := make([]int, len(targets))
+
+	totalWeight := 0
+	for _, target := range targets {
+		totalWeight += target.Weight
+	}
+
+	assigned := 0
+	for idx, target := range targets {
+		counts[idx] = i.config.Workers * target.Weight / totalWeight
+		if counts[idx] < 1 {
+			counts[idx] = 1
+		}
+		assigned += counts[idx]
+	}
+
+	// Hand out (or take back) the rounding remainder in target order
+	for idx := 0; assigned != i.config.Workers; idx = (idx + 1) % len(counts) {
+		if assigned < i.config.Workers {
+			counts[idx]++
+			assigned++
+		} else if counts[idx] > 1 {
+			counts[idx]--
+			assigned--
+		}
+	}
+
+	return counts
+}
+
+// checkCapacity verifies each target has room for its test files while keeping the
+// configured headroom free, so a test can never fill the file system it runs on
+func (i *IOStressPlugin) checkCapacity(workers []int) error {
+	if i.config.RawDevice {
+		return i.checkDeviceCapacity(workers)
+	}
+	for idx, target := range i.config.Targets {
+		usage, err := diskUsage(target.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read capacity of %s: %w", target.Path, err)
+		}
+
+		required := uint64(workers[idx]) * uint64(i.fileSizeBytes)
+		headroom := uint64(float64(usage.Total) * i.config.HeadroomPercent / 100)
+
+		var available uint64
+		if usage.Free > headroom {
+			available = usage.Free - headroom
+		}
+
+		if required > available {
+			return fmt.Errorf("insufficient space on %s: test files need %d MB but only %d MB is available after keeping %.0f%% free",
+				target.Path, required/(1024*1024), available/(1024*1024), i.config.HeadroomPercent)
+		}
 	}
 
 	return nil
 }
 
+// Preflight verifies the targets have room for the test files before the test starts
+func (i *IOStressPlugin) Preflight(config interface{}) error {
+	if err := i.Initialize(config); err != nil {
+		return err
+	}
+	return i.checkCapacity(i.workersPerTarget())
+}
+
+// ResourceClaims claims the devices of the targets, since two I/O tests sharing a
+// device would skew each other's throughput and together could fill it
+func (i *IOStressPlugin) ResourceClaims(config interface{}) ([]ResourceClaim, error) {
+	if err := i.Initialize(config); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var claims []ResourceClaim
+	for _, target := range i.config.Targets {
+		claim := ResourceClaim{Resource: ResourceDevicePrefix + i.deviceKey(target.Path), Exclusive: true}
+		if !seen[claim.Resource] {
+			seen[claim.Resource] = true
+			claims = append(claims, claim)
+		}
+	}
+	return claims, nil
+}
+
 // parseSize parses size strings like "1GB", "64KB"
 func (i *IOStressPlugin) parseSize(size string) (int64, error) {
+	return ParseSize(size)
+}
+
+// ParseSize parses size strings like "2TB", "1GB", "64KB" into bytes
+func ParseSize(size string) (int64, error) {
 	size = strings.TrimSpace(strings.ToUpper(size))
 	
 	var multiplier int64 = 1
-	if strings.HasSuffix(size, "GB") {
+	if strings.HasSuffix(size, "TB") {
+		multiplier = 1024 * 1024 * 1024 * 1024
+		size = strings.TrimSuffix(size, "TB")
+	} else if strings.HasSuffix(size, "GB") {
 		multiplier = 1024 * 1024 * 1024
 		size = strings.TrimSuffix(size, "GB")
 	} else if strings.HasSuffix(size, "MB") {
@@ -215,24 +555,74 @@ func (i *IOStressPlugin) parseSize(size string) (int64, error) {
 
 // Execute runs the I/O stress test
 func (i *IOStressPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	workers := i.workersPerTarget()
+
 	// Reset metrics
 	i.mu.Lock()
-	i.metrics = &IOMetrics{}
+	i.metrics = &IOMetrics{Targets: make(map[string]*IOTargetMetrics, len(i.config.Targets))}
+	for idx, target := range i.config.Targets {
+		i.metrics.Targets[target.Path] = &IOTargetMetrics{Workers: workers[idx]}
+	}
+	i.written = 0
+	i.devices = make(map[string]string, len(i.config.Targets))
+	for _, target := range i.config.Targets {
+		i.devices[target.Path] = i.deviceKey(target.Path)
+	}
+	i.deviceStats = make(map[string]*RawDeviceStats, len(i.rawDevices))
+	i.deviceWrites = make(map[string]int64)
+	i.latencyOps, i.submitLatency, i.completionLatency = 0, 0, 0
+	i.workerErr = nil
+	i.budgetDone = make(chan struct{})
+	i.budgetOnce = sync.Once{}
+	i.seed = params.Seed
+	i.nvme = nil
+	if i.config.NVMe {
+		i.nvme = &NVMeResult{Phase: NVMePhasePurge}
+	}
 	i.mu.Unlock()
 
-	// Create test files
-	if err := i.createTestFiles(ctx); err != nil {
+	// Make sure the test files fit before writing anything
+	if err := i.checkCapacity(workers); err != nil {
+		return err
+	}
+
+	// Create test files, or on raw devices give each worker a region of its device
+	if i.config.RawDevice {
+		i.assignDeviceRegions(workers)
+		i.sampleRawDevices(true)
+	} else if err := i.createTestFiles(ctx, workers); err != nil {
 		return fmt.Errorf("failed to create test files: %w", err)
 	}
+	if i.budgetSpent() {
+		return nil
+	}
 
 	// Start metrics collection
 	go i.collectMetrics(ctx)
 
+	// The NVMe workflow runs its own phases and ends the run when they are done
+	if i.config.NVMe {
+		return i.runNVMeWorkflow(ctx)
+	}
+
 	// Start I/O workers
 	var wg sync.WaitGroup
 	for workerID := 0; workerID < i.config.Workers; workerID++ {
 		wg.Add(1)
-		go i.ioWorker(ctx, &wg, workerID)
+		if i.config.Engine == IOEngineIOURing {
+			go func(workerID int) {
+				defer wg.Done()
+				if err := i.ioURingWorker(ctx, workerID); err != nil {
+					i.mu.Lock()
+					if i.workerErr == nil {
+						i.workerErr = err
+					}
+					i.mu.Unlock()
+				}
+			}(workerID)
+		} else {
+			go i.ioWorker(ctx, &wg, workerID)
+		}
 	}
 
 	// Wait for completion or context cancellation
@@ -246,36 +636,163 @@ func (i *IOStressPlugin) Execute(ctx context.Context, params models.TestParams)
 	case <-ctx.Done():
 		return ctx.Err()
 	case <-done:
-		return nil
+		i.mu.RLock()
+		defer i.mu.RUnlock()
+		return i.workerErr
 	}
 }
 
-// createTestFiles creates the test files for I/O operations
-func (i *IOStressPlugin) createTestFiles(ctx context.Context) error {
-	for workerID := 0; workerID < i.config.Workers; workerID++ {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+// FollowsDeadline reports that I/O workers run until the context ends
+func (i *IOStressPlugin) FollowsDeadline() bool {
+	return true
+}
+
+// recordWrite counts bytes written to a target against its device and the write
+// budget, ending the run once the budget is spent. The caller must hold i.mu.
+func (i *IOStressPlugin) recordWrite(target string, n int64) {
+	i.written += n
+	if device, ok := i.devices[target]; ok {
+		i.deviceWrites[device] += n
+	}
+	if i.tbwBytes > 0 && i.written >= i.tbwBytes {
+		i.budgetOnce.Do(func() { close(i.budgetDone) })
+	}
+}
+
+// countIO adds a completed read or write of n bytes on a target to the metrics.
+// The caller must hold i.mu.
+func (i *IOStressPlugin) countIO(target string, operation string, n int64) {
+	targetMetrics := i.metrics.Targets[target]
+	if operation == "read" {
+		i.metrics.TotalBytesRead += n
+		i.metrics.ReadOpsPerSec++
+		if targetMetrics != nil {
+			targetMetrics.TotalBytesRead += n
 		}
+		return
+	}
+	i.metrics.TotalBytesWritten += n
+	i.metrics.WriteOpsPerSec++
+	if targetMetrics != nil {
+		targetMetrics.TotalBytesWritten += n
+	}
+	i.recordWrite(target, n)
+}
 
-		filename := filepath.Join(i.config.TempDir, fmt.Sprintf("ssts_io_test_%d_%d.dat", 
-			time.Now().Unix(), workerID))
+// budgetSpent reports whether an endurance run has written its budget
+func (i *IOStressPlugin) budgetSpent() bool {
+	select {
+	case <-i.budgetDone:
+		return true
+	default:
+		return false
+	}
+}
+
+// DeviceBytesWritten returns the bytes the last run wrote to each device
+func (i *IOStressPlugin) DeviceBytesWritten() map[string]int64 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	writes := make(map[string]int64, len(i.deviceWrites))
+	for device, n := range i.deviceWrites {
+		writes[device] = n
+	}
+	return writes
+}
+
+// DiskWritten returns the bytes the run has written and the targets its test
+// files are on. Raw device runs write no files, so have no paths to watch.
+func (i *IOStressPlugin) DiskWritten() (int64, []string) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if i.config.RawDevice {
+		return i.written, nil
+	}
+	paths := make([]string, 0, len(i.config.Targets))
+	for _, target := range i.config.Targets {
+		paths = append(paths, target.Path)
+	}
+	return i.written, paths
+}
 
-		if err := i.createTestFile(filename); err != nil {
-			return fmt.Errorf("failed to create test file %s: %w", filename, err)
+// ReclaimDisk removes the run's test files, returning the bytes freed. Workers
+// still going fail to open their files until the run is stopped.
+func (i *IOStressPlugin) ReclaimDisk() (int64, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var freed int64
+	var firstErr error
+	kept := i.testFiles[:0]
+	for _, testFile := range i.testFiles {
+		if testFile.raw {
+			kept = append(kept, testFile)
+			continue
+		}
+		info, statErr := os.Stat(testFile.path)
+		if err := os.Remove(testFile.path); err != nil && !os.IsNotExist(err) {
+			kept = append(kept, testFile)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if statErr == nil {
+			freed += info.Size()
 		}
+	}
+	i.testFiles = kept
+	return freed, firstErr
+}
 
-		i.mu.Lock()
-		i.testFiles = append(i.testFiles, filename)
-		i.mu.Unlock()
+// Progress reports how much of the write budget an endurance run has written;
+// duration-only runs leave progress to the orchestrator
+func (i *IOStressPlugin) Progress() (float64, string) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if i.tbwBytes <= 0 {
+		return -1, ""
+	}
+	return float64(i.written) / float64(i.tbwBytes) * 100, ""
+}
+
+// createTestFiles creates one test file per worker on each target
+func (i *IOStressPlugin) createTestFiles(ctx context.Context, workers []int) error {
+	workerID := 0
+	for idx, target := range i.config.Targets {
+		for n := 0; n < workers[idx]; n++ {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			if i.budgetSpent() {
+				return nil
+			}
+
+			filename := filepath.Join(target.Path, fmt.Sprintf("ssts_io_test_%d_%d.dat",
+				time.Now().Unix(), workerID))
+
+			if err := i.createTestFile(filename, target.Path); err != nil {
+				return fmt.Errorf("failed to create test file %s: %w", filename, err)
+			}
+
+			i.mu.Lock()
+			i.testFiles = append(i.testFiles, ioTestFile{path: filename, target: target.Path})
+			i.mu.Unlock()
+
+			workerID++
+		}
 	}
 
 	return nil
 }
 
 // createTestFile creates a single test file with random data
-func (i *IOStressPlugin) createTestFile(filename string) error {
+func (i *IOStressPlugin) createTestFile(filename, target string) error {
 	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
 	if i.config.Direct {
 		// Note: O_DIRECT is not available on all platforms
@@ -315,6 +832,10 @@ func (i *IOStressPlugin) createTestFile(filename string) error {
 
 		bytesWritten += int64(n)
 
+		i.mu.Lock()
+		i.recordWrite(target, int64(n))
+		i.mu.Unlock()
+
 		if i.config.Fsync {
 			if err := file.Sync(); err != nil {
 				return err
@@ -334,7 +855,8 @@ func (i *IOStressPlugin) ioWorker(ctx context.Context, wg *sync.WaitGroup, worke
 		i.mu.RUnlock()
 		return
 	}
-	filename := i.testFiles[workerID]
+	testFile := i.testFiles[workerID]
+	rng := seededRand(i.seed, workerID)
 	i.mu.RUnlock()
 
 	for {
@@ -343,11 +865,13 @@ func (i *IOStressPlugin) ioWorker(ctx context.Context, wg *sync.WaitGroup, worke
 			return
 		case <-i.stopChan:
 			return
+		case <-i.budgetDone:
+			return
 		default:
 		}
 
 		start := time.Now()
-		err := i.performIOOperation(filename)
+		operation, err := i.performIOOperation(testFile, rng)
 		latency := time.Since(start)
 
 		i.mu.Lock()
@@ -356,19 +880,27 @@ func (i *IOStressPlugin) ioWorker(ctx context.Context, wg *sync.WaitGroup, worke
 		} else {
 			i.metrics.AvgLatencyMs = float64(latency.Nanoseconds()) / 1000000.0
 		}
+		stream := i.stream
 		i.mu.Unlock()
 
+		if err == nil {
+			stream.publish("io_operation", stream.tags("operation", operation, "target", testFile.target), models.IOOperationFields{
+				LatencyUs: float64(latency.Nanoseconds()) / 1000.0,
+				Bytes:     i.blockSizeBytes,
+			}.Fields())
+		}
+
 		// Small delay to prevent overwhelming the system
 		time.Sleep(1 * time.Millisecond)
 	}
 }
 
-// performIOOperation performs a single I/O operation
-func (i *IOStressPlugin) performIOOperation(filename string) error {
+// performIOOperation performs a single I/O operation, returning which operation it was
+func (i *IOStressPlugin) performIOOperation(testFile ioTestFile, rng *mathrand.Rand) (string, error) {
 	operation := i.config.Operations
 	if operation == "mixed" {
 		// Decide based on read/write ratio
-		if float64(time.Now().UnixNano()%1000)/1000.0 < i.config.ReadWriteRatio {
+		if rng.Float64() < i.config.ReadWriteRatio {
 			operation = "read"
 		} else {
 			operation = "write"
@@ -377,33 +909,30 @@ func (i *IOStressPlugin) performIOOperation(filename string) error {
 
 	switch operation {
 	case "read":
-		return i.performRead(filename)
+		return operation, i.performRead(testFile, rng)
 	case "write":
-		return i.performWrite(filename)
+		return operation, i.performWrite(testFile, rng)
 	default:
-		return fmt.Errorf("unknown operation: %s", operation)
+		return operation, fmt.Errorf("unknown operation: %s", operation)
 	}
 }
 
 // performRead performs a read operation
-func (i *IOStressPlugin) performRead(filename string) error {
-	file, err := os.Open(filename)
+func (i *IOStressPlugin) performRead(testFile ioTestFile, rng *mathrand.Rand) error {
+	flags := os.O_RDONLY
+	if testFile.raw {
+		flags = rawDeviceOpenFlags(flags, i.config.Direct)
+	}
+	file, err := os.OpenFile(testFile.path, flags, 0)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	buffer := make([]byte, i.blockSizeBytes)
-	
+	buffer := i.ioBuffer(testFile)
+
 	// Determine read position
-	var offset int64
-	if !i.config.Sequential {
-		// Random position
-		maxOffset := i.fileSizeBytes - i.blockSizeBytes
-		if maxOffset > 0 {
-			offset = int64(time.Now().UnixNano()) % maxOffset
-		}
-	}
+	offset := i.ioOffset(testFile, rng)
 
 	if _, err := file.Seek(offset, 0); err != nil {
 		return err
@@ -416,40 +945,35 @@ func (i *IOStressPlugin) performRead(filename string) error {
 
 	// Update metrics
 	i.mu.Lock()
-	i.metrics.TotalBytesRead += int64(n)
-	i.metrics.ReadOpsPerSec++
+	i.countIO(testFile.target, "read", int64(n))
 	i.mu.Unlock()
 
 	return nil
 }
 
 // performWrite performs a write operation
-func (i *IOStressPlugin) performWrite(filename string) error {
+func (i *IOStressPlugin) performWrite(testFile ioTestFile, rng *mathrand.Rand) error {
 	flags := os.O_WRONLY
 	if i.config.Direct {
 		flags |= os.O_SYNC
 	}
+	if testFile.raw {
+		flags = rawDeviceOpenFlags(flags, i.config.Direct)
+	}
 
-	file, err := os.OpenFile(filename, flags, 0644)
+	file, err := os.OpenFile(testFile.path, flags, 0644)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	buffer := make([]byte, i.blockSizeBytes)
+	buffer := i.ioBuffer(testFile)
 	if _, err := rand.Read(buffer); err != nil {
 		return err
 	}
 
 	// Determine write position
-	var offset int64
-	if !i.config.Sequential {
-		// Random position
-		maxOffset := i.fileSizeBytes - i.blockSizeBytes
-		if maxOffset > 0 {
-			offset = int64(time.Now().UnixNano()) % maxOffset
-		}
-	}
+	offset := i.ioOffset(testFile, rng)
 
 	if _, err := file.Seek(offset, 0); err != nil {
 		return err
@@ -468,8 +992,7 @@ func (i *IOStressPlugin) performWrite(filename string) error {
 
 	// Update metrics
 	i.mu.Lock()
-	i.metrics.TotalBytesWritten += int64(n)
-	i.metrics.WriteOpsPerSec++
+	i.countIO(testFile.target, "write", int64(n))
 	i.mu.Unlock()
 
 	return nil
@@ -482,12 +1005,24 @@ func (i *IOStressPlugin) collectMetrics(ctx context.Context) {
 
 	var lastBytesRead, lastBytesWritten int64
 	var lastReadOps, lastWriteOps int64
+	lastTargetRead := make(map[string]int64)
+	lastTargetWritten := make(map[string]int64)
+	lastSMART := time.Now()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			// Raw devices' own counters are sampled alongside, their SMART logs less often
+			if i.config.RawDevice {
+				withSMART := time.Since(lastSMART) >= rawSMARTInterval
+				if withSMART {
+					lastSMART = time.Now()
+				}
+				i.sampleRawDevices(withSMART)
+			}
+
 			i.mu.Lock()
 			
 			// Calculate per-second rates
@@ -504,6 +1039,20 @@ func (i *IOStressPlugin) collectMetrics(ctx context.Context) {
 			lastBytesWritten = currentBytesWritten
 			lastReadOps = currentReadOps
 			lastWriteOps = currentWriteOps
+
+			for path, target := range i.metrics.Targets {
+				target.ReadBytesPerSec = target.TotalBytesRead - lastTargetRead[path]
+				target.WriteBytesPerSec = target.TotalBytesWritten - lastTargetWritten[path]
+				lastTargetRead[path] = target.TotalBytesRead
+				lastTargetWritten[path] = target.TotalBytesWritten
+			}
+
+			// io_uring latencies are averaged over the last interval
+			if i.latencyOps > 0 {
+				i.metrics.AvgSubmitLatencyUs = float64(i.submitLatency.Nanoseconds()) / float64(i.latencyOps) / 1000.0
+				i.metrics.AvgCompletionLatencyUs = float64(i.completionLatency.Nanoseconds()) / float64(i.latencyOps) / 1000.0
+				i.latencyOps, i.submitLatency, i.completionLatency = 0, 0, 0
+			}
 			
 			i.mu.Unlock()
 		}
@@ -516,10 +1065,13 @@ func (i *IOStressPlugin) Cleanup() error {
 
 	// Remove test files
 	i.mu.Lock()
-	for _, filename := range i.testFiles {
-		if err := os.Remove(filename); err != nil {
+	for _, testFile := range i.testFiles {
+		if testFile.raw {
+			continue
+		}
+		if err := os.Remove(testFile.path); err != nil {
 			// Log error but don't fail cleanup
-			fmt.Printf("Warning: failed to remove test file %s: %v\n", filename, err)
+			fmt.Printf("Warning: failed to remove test file %s: %v\n", testFile.path, err)
 		}
 	}
 	i.testFiles = i.testFiles[:0]
@@ -533,7 +1085,13 @@ func (i *IOStressPlugin) GetMetrics() map[string]interface{} {
 	i.mu.RLock()
 	defer i.mu.RUnlock()
 
-	return map[string]interface{}{
+	targets := make(map[string]IOTargetMetrics, len(i.metrics.Targets))
+	for path, target := range i.metrics.Targets {
+		targets[path] = *target
+	}
+
+	metrics := map[string]interface{}{
+		"targets":             targets,
 		"read_bytes_per_sec":  i.metrics.ReadBytesPerSec,
 		"write_bytes_per_sec": i.metrics.WriteBytesPerSec,
 		"read_ops_per_sec":    i.metrics.ReadOpsPerSec,
@@ -543,7 +1101,41 @@ func (i *IOStressPlugin) GetMetrics() map[string]interface{} {
 		"total_bytes_read":    i.metrics.TotalBytesRead,
 		"total_bytes_written": i.metrics.TotalBytesWritten,
 		"error_count":         i.metrics.ErrorCount,
+		"stream_dropped":      i.stream.droppedCount(),
+	}
+	metrics["device_bytes_written"] = i.written
+	metrics["engine"] = i.config.Engine
+	if i.config.Engine == IOEngineIOURing {
+		metrics["queue_depth"] = i.config.QueueDepth
+		metrics["avg_submit_latency_us"] = i.metrics.AvgSubmitLatencyUs
+		metrics["avg_completion_latency_us"] = i.metrics.AvgCompletionLatencyUs
+	}
+	if i.config.RawDevice {
+		devices := make(map[string]RawDeviceStats, len(i.deviceStats))
+		for path, stats := range i.deviceStats {
+			devices[path] = *stats
+		}
+		metrics["raw_devices"] = devices
+	}
+	if i.tbwBytes > 0 {
+		metrics["tbw_budget_bytes"] = i.tbwBytes
+		metrics["tbw_progress_percent"] = float64(i.written) / float64(i.tbwBytes) * 100
+	}
+	if i.nvme != nil {
+		i.nvmeMetrics(metrics)
 	}
+	return metrics
+}
+
+// MetricsStream streams the latency of every successful I/O operation
+func (i *IOStressPlugin) MetricsStream(ctx context.Context) <-chan models.MetricPoint {
+	stream := newMetricStream(ctx, defaultStreamBuffer)
+
+	i.mu.Lock()
+	i.stream = stream
+	i.mu.Unlock()
+
+	return stream.ch
 }
 
 // GetSafetyLimits returns safety limits for I/O testing
@@ -0,0 +1,718 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"512":   512,
+		"64KB":  64 << 10,
+		"1mb":   1 << 20,
+		"2GB":   2 << 30,
+		"600TB": 600 << 40,
+	}
+	for input, want := range cases {
+		got, err := ParseSize(input)
+		if err != nil {
+			t.Fatalf("ParseSize(%q): %v", input, err)
+		}
+		if got != want {
+			t.Errorf("ParseSize(%q) = %d, want %d", input, got, want)
+		}
+	}
+	if _, err := ParseSize("lots"); err == nil {
+		t.Error("ParseSize accepted an invalid size")
+	}
+}
+
+func TestIOStressStopsAtWriteBudget(t *testing.T) {
+	plugin := NewIOStressPlugin()
+	err := plugin.Initialize(map[string]interface{}{
+		"temp_dir":         t.TempDir(),
+		"file_size":        "64KB",
+		"block_size":       "4KB",
+		"operations":       "write",
+		"workers":          2,
+		"headroom_percent": 1,
+		"tbw":              "1MB",
+	})
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer plugin.Cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := plugin.Execute(ctx, models.TestParams{Duration: 30 * time.Second}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	metrics := plugin.GetMetrics()
+	written := metrics["device_bytes_written"].(int64)
+	if written < 1<<20 {
+		t.Errorf("stopped after %d bytes, before the 1MB budget", written)
+	}
+	if progress := metrics["tbw_progress_percent"].(float64); progress < 100 {
+		t.Errorf("tbw_progress_percent = %.1f, want at least 100", progress)
+	}
+
+	var total int64
+	for _, n := range plugin.DeviceBytesWritten() {
+		total += n
+	}
+	if total != written {
+		t.Errorf("device writes add up to %d, want %d", total, written)
+	}
+}
+
+func TestIOStressReclaimsDisk(t *testing.T) {
+	dir := t.TempDir()
+	plugin := NewIOStressPlugin()
+	err := plugin.Initialize(map[string]interface{}{
+		"temp_dir":         dir,
+		"file_size":        "64KB",
+		"block_size":       "4KB",
+		"operations":       "write",
+		"workers":          2,
+		"headroom_percent": 1,
+	})
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer plugin.Cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- plugin.Execute(ctx, models.TestParams{Duration: time.Minute}) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Wait for the workers to write to both test files, once they are created
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		written, paths := plugin.DiskWritten()
+		if written > 128<<10 {
+			if len(paths) != 1 || paths[0] != dir {
+				t.Errorf("paths = %v, want the temp dir", paths)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d bytes written", written)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	freed, err := plugin.ReclaimDisk()
+	if err != nil || freed != 128<<10 {
+		t.Errorf("ReclaimDisk = %d, %v, want both 64KB files freed", freed, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "ssts_io_test_*")); len(files) != 0 {
+		t.Errorf("test files left after reclaiming: %v", files)
+	}
+}
+
+func TestIOStressRejectsReadOnlyWriteBudget(t *testing.T) {
+	err := NewIOStressPlugin().Initialize(map[string]interface{}{
+		"temp_dir":   t.TempDir(),
+		"operations": "read",
+		"tbw":        "1GB",
+	})
+	if err == nil {
+		t.Fatal("Initialize accepted a write budget for a read-only test")
+	}
+}
+
+func TestIOStressPreflight(t *testing.T) {
+	defer func(usage func(string) (*disk.UsageStat, error)) { diskUsage = usage }(diskUsage)
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		free    uint64
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{"room to spare", 3 << 30, map[string]interface{}{"file_size": "1GB", "workers": 2}, ""},
+		{"files don't fit", 1 << 30, map[string]interface{}{"file_size": "1GB", "workers": 2}, "insufficient space"},
+		{"files only fit into the headroom", 3 << 30, map[string]interface{}{"file_size": "1GB", "workers": 2, "headroom_percent": 50}, "insufficient space"},
+		{"invalid config", 10 << 30, map[string]interface{}{"file_size": "lots"}, "invalid file_size"},
+		{"missing target", 10 << 30, map[string]interface{}{"targets": []map[string]interface{}{{"path": dir + "/missing"}}}, "does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diskUsage = func(path string) (*disk.UsageStat, error) {
+				return &disk.UsageStat{Path: path, Total: 4 << 30, Free: tt.free}, nil
+			}
+			tt.config["temp_dir"] = dir
+			err := NewIOStressPlugin().Preflight(tt.config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Preflight: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Preflight error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	diskUsage = func(path string) (*disk.UsageStat, error) { return nil, errors.New("statfs failed") }
+	if err := NewIOStressPlugin().Preflight(map[string]interface{}{"temp_dir": dir}); err == nil {
+		t.Error("Preflight passed although the capacity couldn't be read")
+	}
+}
+
+func TestIOStressWorkersPerTarget(t *testing.T) {
+	tests := []struct {
+		workers int
+		weights []int
+		want    []int
+	}{
+		{4, []int{1}, []int{4}},
+		{4, []int{1, 1}, []int{2, 2}},
+		{5, []int{1, 1}, []int{3, 2}},
+		{6, []int{2, 1}, []int{4, 2}},
+		{4, []int{1, 1, 1}, []int{2, 1, 1}},
+		{3, []int{100, 1, 1}, []int{1, 1, 1}}, // every target gets a worker
+		{4, []int{10, 1, 1}, []int{2, 1, 1}},  // even when its share rounds to nothing
+		{10, []int{3, 3, 4}, []int{3, 3, 4}},
+	}
+	for _, tt := range tests {
+		plugin := NewIOStressPlugin()
+		plugin.config.Workers = tt.workers
+		for _, weight := range tt.weights {
+			plugin.config.Targets = append(plugin.config.Targets, IOTarget{Path: "/", Weight: weight})
+		}
+		got := plugin.workersPerTarget()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d workers over weights %v = %v, want %v", tt.workers, tt.weights, got, tt.want)
+		}
+	}
+}
+
+func TestIOStressTargetConfig(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    []IOTarget
+		wantErr string
+	}{
+		{"temp_dir by default", map[string]interface{}{"temp_dir": a}, []IOTarget{{Path: a, Weight: 1}}, ""},
+		{"weights default to 1", map[string]interface{}{"targets": []IOTarget{{Path: a}, {Path: b, Weight: 3}}}, []IOTarget{{Path: a, Weight: 1}, {Path: b, Weight: 3}}, ""},
+		{"missing target", map[string]interface{}{"targets": []IOTarget{{Path: a}, {Path: b + "/missing"}}}, nil, "does not exist"},
+		{"fewer workers than targets", map[string]interface{}{"workers": 1, "targets": []IOTarget{{Path: a}, {Path: b}}}, nil, "at least the number of targets"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := NewIOStressPlugin()
+			err := plugin.Initialize(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Initialize error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+			if !reflect.DeepEqual(plugin.config.Targets, tt.want) {
+				t.Errorf("targets = %+v, want %+v", plugin.config.Targets, tt.want)
+			}
+			if plugin.config.HeadroomPercent != 10 {
+				t.Errorf("headroom = %v%%, want the default of 10%%", plugin.config.HeadroomPercent)
+			}
+		})
+	}
+}
+
+func TestIOStressTempDirPolicy(t *testing.T) {
+	defer func(usage func(string) (*disk.UsageStat, error)) { diskUsage = usage }(diskUsage)
+	defer func(partitions func(bool) ([]disk.PartitionStat, error)) { diskPartitions = partitions }(diskPartitions)
+
+	root := t.TempDir()
+	shm, data, archive := filepath.Join(root, "shm"), filepath.Join(root, "data"), filepath.Join(root, "archive")
+	for _, dir := range []string{shm, data, archive} {
+		os.Mkdir(dir, 0o755)
+	}
+	diskPartitions = func(all bool) ([]disk.PartitionStat, error) {
+		partitions := []disk.PartitionStat{
+			{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4", Opts: []string{"rw"}},
+			{Device: "/dev/sdb1", Mountpoint: data, Fstype: "xfs", Opts: []string{"rw"}},
+			{Device: "/dev/sdc1", Mountpoint: archive, Fstype: "ext4", Opts: []string{"ro"}},
+		}
+		if all {
+			partitions = append(partitions, disk.PartitionStat{Device: "tmpfs", Mountpoint: shm, Fstype: "tmpfs", Opts: []string{"rw"}})
+		}
+		return partitions, nil
+	}
+	// The root file system has 10GB of 100GB free; the data mounts have plenty
+	diskUsage = func(path string) (*disk.UsageStat, error) {
+		if withinMount(path, data) || withinMount(path, archive) {
+			return &disk.UsageStat{Path: path, Total: 1 << 40, Free: 1 << 39}, nil
+		}
+		return &disk.UsageStat{Path: path, Total: 100 << 30, Free: 10 << 30}, nil
+	}
+
+	tests := []struct {
+		name       string
+		config     map[string]interface{}
+		wantTarget string
+		wantWarn   string
+		wantErr    string
+	}{
+		{"tmpfs warns by default", map[string]interface{}{"temp_dir": shm}, shm, "stress memory rather than disk", ""},
+		{"tmpfs refused", map[string]interface{}{"temp_dir": shm, "temp_dir_policy": "refuse"}, "", "", "on tmpfs"},
+		{"tmpfs replaced by the largest writable data mount", map[string]interface{}{"temp_dir": shm, "temp_dir_policy": "auto"}, data, "using " + data, ""},
+		{"nearly full root refused", map[string]interface{}{"temp_dir": root, "temp_dir_policy": "refuse"}, "", "", "root file system"},
+		{"data mount", map[string]interface{}{"temp_dir": data, "temp_dir_policy": "refuse"}, data, "", ""},
+		{"explicit targets aren't replaced", map[string]interface{}{"targets": []IOTarget{{Path: shm}}, "temp_dir_policy": "auto"}, "", "", "on tmpfs"},
+		{"unknown policy", map[string]interface{}{"temp_dir": data, "temp_dir_policy": "ignore"}, "", "", "invalid temp_dir_policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["file_size"] = "1GB"
+			plugin := NewIOStressPlugin()
+			err := plugin.Initialize(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Initialize error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+			if target := plugin.config.Targets[0].Path; len(plugin.config.Targets) != 1 || target != tt.wantTarget {
+				t.Errorf("targets = %+v, want %s", plugin.config.Targets, tt.wantTarget)
+			}
+			warnings := strings.Join(plugin.ConfigWarnings(), "; ")
+			if (tt.wantWarn == "") != (warnings == "") || !strings.Contains(warnings, tt.wantWarn) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.wantWarn)
+			}
+		})
+	}
+
+	// With no data mount to move to, auto refuses
+	diskPartitions = func(all bool) ([]disk.PartitionStat, error) {
+		return []disk.PartitionStat{{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"}}, nil
+	}
+	if err := NewIOStressPlugin().Initialize(map[string]interface{}{"temp_dir": root, "temp_dir_policy": "auto"}); !errors.Is(err, errNoDataMount) {
+		t.Errorf("Initialize error = %v, want errNoDataMount", err)
+	}
+}
+
+func TestIOStressReportsEachTarget(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	plugin := NewIOStressPlugin()
+	err := plugin.Initialize(map[string]interface{}{
+		"targets":          []IOTarget{{Path: a, Weight: 3}, {Path: b, Weight: 1}},
+		"file_size":        "64KB",
+		"block_size":       "4KB",
+		"operations":       "write",
+		"workers":          4,
+		"headroom_percent": 1,
+		"tbw":              "1MB",
+	})
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer plugin.Cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := plugin.Execute(ctx, models.TestParams{Duration: 30 * time.Second}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	targets := plugin.GetMetrics()["targets"].(map[string]IOTargetMetrics)
+	for path, workers := range map[string]int{a: 3, b: 1} {
+		target, ok := targets[path]
+		if !ok {
+			t.Errorf("no metrics for target %s", path)
+			continue
+		}
+		if target.Workers != workers {
+			t.Errorf("target %s: %d workers, want %d", path, target.Workers, workers)
+		}
+		if target.TotalBytesWritten == 0 {
+			t.Errorf("target %s: nothing written", path)
+		}
+	}
+}
+
+func TestIOStressIOURing(t *testing.T) {
+	dir := t.TempDir()
+	base := func(overrides map[string]interface{}) map[string]interface{} {
+		config := map[string]interface{}{
+			"temp_dir":         dir,
+			"file_size":        "256KB",
+			"block_size":       "4KB",
+			"operations":       "mixed",
+			"workers":          2,
+			"headroom_percent": 1,
+			"engine":           IOEngineIOURing,
+		}
+		for key, value := range overrides {
+			config[key] = value
+		}
+		return config
+	}
+
+	for name, overrides := range map[string]map[string]interface{}{
+		"unknown engine":           {"engine": "aio"},
+		"polling without io_uring": {"engine": IOEngineSync, "polling": IOPollingSQ},
+		"iopoll without direct":    {"polling": IOPollingIO},
+		"queue too deep":           {"queue_depth": 4096},
+		"unaligned direct blocks":  {"direct": true, "block_size": "1000"},
+	} {
+		if err := NewIOStressPlugin().Initialize(base(overrides)); err == nil {
+			t.Errorf("%s: Initialize accepted it", name)
+		}
+	}
+
+	plugin := NewIOStressPlugin()
+	err := plugin.Initialize(base(map[string]interface{}{"queue_depth": 8, "tbw": "2MB"}))
+	if err != nil && strings.Contains(err.Error(), "io_uring is unavailable") {
+		t.Skipf("kernel refuses io_uring: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	defer plugin.Cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stream := plugin.MetricsStream(ctx)
+	if err := plugin.Execute(ctx, models.TestParams{Duration: 30 * time.Second}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	metrics := plugin.GetMetrics()
+	if metrics["engine"] != IOEngineIOURing || metrics["queue_depth"] != 8 {
+		t.Errorf("engine = %v at depth %v", metrics["engine"], metrics["queue_depth"])
+	}
+	if metrics["error_count"].(int64) != 0 || metrics["total_bytes_read"].(int64) == 0 || metrics["device_bytes_written"].(int64) < 2<<20 {
+		t.Errorf("metrics = %v, want reads and the 2MB write budget without errors", metrics)
+	}
+
+	point := <-stream
+	if _, ok := point.Fields["submit_latency_us"]; !ok {
+		t.Errorf("streamed point %v has no submit latency", point.Fields)
+	}
+	if _, ok := point.Fields["completion_latency_us"]; !ok {
+		t.Errorf("streamed point %v has no completion latency", point.Fields)
+	}
+}
+
+func TestIOStressRawDeviceConfig(t *testing.T) {
+	defer func(probe func(string) (RawDeviceInfo, error)) { blockDeviceProbe = probe }(blockDeviceProbe)
+	defer func(partitions func(bool) ([]disk.PartitionStat, error)) { diskPartitions = partitions }(diskPartitions)
+	blockDeviceProbe = func(path string) (RawDeviceInfo, error) {
+		if !strings.HasPrefix(path, "/dev/") {
+			return RawDeviceInfo{}, errors.New("not a block device")
+		}
+		return RawDeviceInfo{Path: path, SizeBytes: 1 << 30, LogicalBlockSize: 4096}, nil
+	}
+	defer func(root, swaps string) { blockSysfsRoot, procSwaps = root, swaps }(blockSysfsRoot, procSwaps)
+	diskPartitions = func(all bool) ([]disk.PartitionStat, error) {
+		return []disk.PartitionStat{{Device: "/dev/sda2", Mountpoint: "/"}}, nil
+	}
+
+	// sdb is an LVM physical volume, a partition of sdc an md array member and
+	// one of sdd active swap
+	blockSysfsRoot = t.TempDir()
+	for _, dir := range []string{"nvme0n1/holders", "sdb/holders/dm-0", "sdc/sdc1/holders/md0", "sdd/sdd2/holders"} {
+		os.MkdirAll(filepath.Join(blockSysfsRoot, dir), 0o755)
+	}
+	os.WriteFile(filepath.Join(blockSysfsRoot, "sdc/sdc1/partition"), []byte("1\n"), 0o644)
+	os.WriteFile(filepath.Join(blockSysfsRoot, "sdd/sdd2/partition"), []byte("2\n"), 0o644)
+	procSwaps = filepath.Join(t.TempDir(), "swaps")
+	os.WriteFile(procSwaps, []byte("Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n/dev/sdd2\tpartition\t8388604\t0\t-2\n"), 0o644)
+
+	base := func(overrides map[string]interface{}) map[string]interface{} {
+		config := map[string]interface{}{
+			"raw_device":      true,
+			"targets":         []map[string]interface{}{{"path": "/dev/nvme0n1"}},
+			"file_size":       "256MB",
+			"block_size":      "64KB",
+			"operations":      "write",
+			"workers":         2,
+			"confirm_destroy": []string{"/dev/nvme0n1"},
+		}
+		for key, value := range overrides {
+			config[key] = value
+		}
+		return config
+	}
+
+	tests := []struct {
+		name      string
+		overrides map[string]interface{}
+		wantErr   string
+	}{
+		{"confirmed writes", nil, ""},
+		{"reads need no confirmation", map[string]interface{}{"operations": "read", "confirm_destroy": nil}, ""},
+		{"unconfirmed writes", map[string]interface{}{"confirm_destroy": []string{"/dev/nvme1n1"}}, "confirm_destroy"},
+		{"no targets", map[string]interface{}{"targets": nil}, "needs targets"},
+		{"not a block device", map[string]interface{}{"targets": []map[string]interface{}{{"path": t.TempDir()}}}, "not a block device"},
+		{"mounted partition", map[string]interface{}{"targets": []map[string]interface{}{{"path": "/dev/sda"}}, "confirm_destroy": []string{"/dev/sda"}}, "mounted at /"},
+		{"volume group member", map[string]interface{}{"targets": []map[string]interface{}{{"path": "/dev/sdb"}}, "confirm_destroy": []string{"/dev/sdb"}}, "held by dm-0"},
+		{"md array member partition", map[string]interface{}{"targets": []map[string]interface{}{{"path": "/dev/sdc"}}, "confirm_destroy": []string{"/dev/sdc"}}, "held by md0"},
+		{"swap partition", map[string]interface{}{"targets": []map[string]interface{}{{"path": "/dev/sdd"}}, "confirm_destroy": []string{"/dev/sdd"}}, "swap active on /dev/sdd2"},
+		{"unaligned blocks", map[string]interface{}{"block_size": "6KB"}, "multiples of 4096"},
+		{"regions don't fit", map[string]interface{}{"file_size": "1GB"}, "too small"},
+	}
+	for _, tt := range tests {
+		err := NewIOStressPlugin().Preflight(base(tt.overrides))
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: Preflight: %v", tt.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Preflight error = %v, want one containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// When mounts or swaps can't be read, the device is refused rather than assumed free
+	diskPartitions = func(all bool) ([]disk.PartitionStat, error) { return nil, errors.New("no procfs") }
+	if err := NewIOStressPlugin().Preflight(base(nil)); err == nil || !strings.Contains(err.Error(), "can't tell whether it is in use") {
+		t.Errorf("Preflight with unreadable mounts: %v", err)
+	}
+	diskPartitions = func(all bool) ([]disk.PartitionStat, error) { return nil, nil }
+	procSwaps = filepath.Join(t.TempDir(), "missing")
+	if err := NewIOStressPlugin().Preflight(base(nil)); err == nil || !strings.Contains(err.Error(), "can't tell whether it is in use") {
+		t.Errorf("Preflight with unreadable swaps: %v", err)
+	}
+}
+
+func TestIOStressRawDeviceRun(t *testing.T) {
+	defer func(probe func(string) (RawDeviceInfo, error)) { blockDeviceProbe = probe }(blockDeviceProbe)
+	defer func(partitions func(bool) ([]disk.PartitionStat, error)) { diskPartitions = partitions }(diskPartitions)
+	diskPartitions = func(all bool) ([]disk.PartitionStat, error) { return nil, nil }
+
+	// A regular file stands in for the device; only the first 512KB may be written
+	device := filepath.Join(t.TempDir(), "disk")
+	if err := os.WriteFile(device, make([]byte, 1<<20), 0644); err != nil {
+		t.Fatal(err)
+	}
+	blockDeviceProbe = func(path string) (RawDeviceInfo, error) {
+		return RawDeviceInfo{Path: path, SizeBytes: 1 << 20, LogicalBlockSize: 512, Serial: "S4EWNX0R123456"}, nil
+	}
+
+	plugin := NewIOStressPlugin()
+	err := plugin.Initialize(map[string]interface{}{
+		"raw_device":      true,
+		"targets":         []map[string]interface{}{{"path": device}},
+		"confirm_destroy": []string{device},
+		"file_size":       "256KB",
+		"block_size":      "4KB",
+		"operations":      "mixed",
+		"sequential":      false,
+		"workers":         2,
+		"tbw":             "256KB",
+	})
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := plugin.Execute(ctx, models.TestParams{Duration: 30 * time.Second}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if err := plugin.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(device)
+	if err != nil {
+		t.Fatalf("the device was removed: %v", err)
+	}
+	if len(data) != 1<<20 {
+		t.Fatalf("device is %d bytes, want it left at 1MB", len(data))
+	}
+	if !reflect.DeepEqual(data[512<<10:], make([]byte, 512<<10)) {
+		t.Error("writes went beyond the workers' regions")
+	}
+	if reflect.DeepEqual(data[:512<<10], make([]byte, 512<<10)) {
+		t.Error("nothing was written to the workers' regions")
+	}
+
+	if writes := plugin.DeviceBytesWritten(); writes["S4EWNX0R123456"] < 256<<10 {
+		t.Errorf("device writes = %v, want the budget counted against the device serial", writes)
+	}
+	devices, ok := plugin.GetMetrics()["raw_devices"].(map[string]RawDeviceStats)
+	if !ok || devices[device].SizeBytes != 1<<20 {
+		t.Errorf("raw_devices = %v, want the device's stats", devices)
+	}
+}
+
+func TestParseNVMeSMART(t *testing.T) {
+	page := make([]byte, nvmeSMARTLogSize)
+	page[0] = 0x02
+	page[1], page[2] = 0x3c, 0x01 // 316 K
+	page[3] = 100
+	page[5] = 7
+	page[48] = 0xe8
+	page[49] = 0x03 // 1000 data units written
+	page[128] = 42
+	page[160] = 3
+
+	log := parseNVMeSMART(page)
+	kelvin := 316.0
+	want := SMARTLog{CriticalWarning: 2, TemperatureC: kelvin - 273.15, AvailableSpare: 100, PercentageUsed: 7, DataUnitsWritten: 1000, PowerOnHours: 42, MediaErrors: 3}
+	if log != want {
+		t.Errorf("parseNVMeSMART = %+v, want %+v", log, want)
+	}
+
+	for partition, want := range map[string]bool{
+		"/dev/sda1":      true,
+		"/dev/nvme0n1p2": true,
+		"/dev/sdaa1":     false,
+		"/dev/sda":       false,
+		"/dev/nvme0n10":  false,
+	} {
+		disk := "/dev/sda"
+		if strings.HasPrefix(partition, "/dev/nvme") {
+			disk = "/dev/nvme0n1"
+		}
+		if got := isPartitionOf(partition, disk); got != want {
+			t.Errorf("isPartitionOf(%s, %s) = %v, want %v", partition, disk, got, want)
+		}
+	}
+}
+
+func TestIOStressNVMeWorkflow(t *testing.T) {
+	defer func(probe func(string) (RawDeviceInfo, error)) { blockDeviceProbe = probe }(blockDeviceProbe)
+	defer func(partitions func(bool) ([]disk.PartitionStat, error)) { diskPartitions = partitions }(diskPartitions)
+	defer func(discard func(string, int64, int64) error) { deviceDiscard = discard }(deviceDiscard)
+	diskPartitions = func(all bool) ([]disk.PartitionStat, error) { return nil, nil }
+
+	device := filepath.Join(t.TempDir(), "disk")
+	if err := os.WriteFile(device, make([]byte, 1<<20), 0644); err != nil {
+		t.Fatal(err)
+	}
+	blockDeviceProbe = func(path string) (RawDeviceInfo, error) {
+		return RawDeviceInfo{Path: path, SizeBytes: 1 << 20, LogicalBlockSize: 512, Serial: "S4EWNX0R123456"}, nil
+	}
+	// Discarding zeroes the range, so the test can tell the fill that followed
+	// wrote all of it
+	var discards int
+	deviceDiscard = func(path string, offset, length int64) error {
+		discards++
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = file.WriteAt(make([]byte, length), offset)
+		return err
+	}
+
+	plugin := NewIOStressPlugin()
+	err := plugin.Initialize(map[string]interface{}{
+		"raw_device":                     true,
+		"targets":                        []map[string]interface{}{{"path": device}},
+		"confirm_destroy":                []string{device},
+		"file_size":                      "256KB",
+		"block_size":                     "4KB",
+		"operations":                     "write",
+		"workers":                        2,
+		"nvme":                           true,
+		"precondition_passes":            1,
+		"round_duration":                 "20ms",
+		"steady_state_rounds":            2,
+		"steady_state_tolerance_percent": 100,
+		"max_rounds":                     50,
+	})
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := plugin.Execute(ctx, models.TestParams{Duration: 30 * time.Second}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("the workflow ran until the deadline instead of ending at steady state")
+	}
+
+	metrics := plugin.GetMetrics()
+	result, ok := metrics["nvme"].(NVMeResult)
+	if !ok {
+		t.Fatalf("nvme metrics = %v, want the workflow's results", metrics["nvme"])
+	}
+	if result.Phase != NVMePhaseDone || result.Trims != 2 || result.FillPasses != 1 || result.TrimError != "" {
+		t.Errorf("result = %+v, want it done after 2 trims and 1 fill", result)
+	}
+	if discards != 4 {
+		t.Errorf("discards = %d, want each of the 2 regions discarded twice", discards)
+	}
+	if result.FreshOutOfBox == nil || result.FreshOutOfBox.IOPS <= 0 || metrics["fob_iops"] != result.FreshOutOfBox.IOPS {
+		t.Errorf("fresh-out-of-box = %+v, want it measured and reported", result.FreshOutOfBox)
+	}
+	if !result.SteadyStateReached || result.SteadyState == nil || len(result.Rounds) < 2 {
+		t.Fatalf("result = %+v, want steady state reached", result)
+	}
+	if want := averagePerformance(result.Rounds[len(result.Rounds)-2:]); *result.SteadyState != want || metrics["steady_state_iops"] != want.IOPS {
+		t.Errorf("steady state = %+v, want the average of the last 2 rounds %+v", *result.SteadyState, want)
+	}
+
+	data, err := os.ReadFile(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero := make([]byte, 512)
+	for offset := 0; offset < 512<<10; offset += 512 {
+		if reflect.DeepEqual(data[offset:offset+512], zero) {
+			t.Fatalf("block at %d was left zeroed, want the fill to have written every region", offset)
+		}
+	}
+	if !reflect.DeepEqual(data[512<<10:], make([]byte, 512<<10)) {
+		t.Error("writes went beyond the workers' regions")
+	}
+}
+
+func TestIOStressNVMeConfig(t *testing.T) {
+	plugin := NewIOStressPlugin()
+	err := plugin.Initialize(map[string]interface{}{"temp_dir": t.TempDir(), "nvme": true})
+	if err == nil || !strings.Contains(err.Error(), "raw_device") {
+		t.Errorf("Initialize without raw_device = %v, want an error asking for it", err)
+	}
+}
+
+func TestSteadyState(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   bool
+	}{
+		{"too few rounds", []float64{100, 100}, false},
+		{"flat", []float64{500, 100, 101, 99}, true},
+		{"wide range", []float64{100, 130, 100}, false},
+		{"trending", []float64{100, 108, 116}, false},
+		{"settled after a drop", []float64{900, 600, 300, 105, 100, 102}, true},
+	}
+	for _, tt := range tests {
+		if got := steadyState(tt.values, 3, 20); got != tt.want {
+			t.Errorf("%s: steadyState(%v) = %v, want %v", tt.name, tt.values, got, tt.want)
+		}
+	}
+}
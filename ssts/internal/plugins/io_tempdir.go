@@ -0,0 +1,149 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// What the I/O stress plugin does when a target would not stress a disk, or would
+// leave the root file system short of space
+const (
+	TempDirPolicyWarn   = "warn"   // run anyway, reporting the problem
+	TempDirPolicyRefuse = "refuse" // fail to initialize
+	TempDirPolicyAuto   = "auto"   // move temp_dir to the largest suitable data mount
+)
+
+// minRootFreePercent is how much of the root file system must stay free once
+// the test files are created; below it the machine risks failing for want of space
+const minRootFreePercent = 20
+
+// memoryFilesystems are the file system types kept in RAM, where test files would
+// stress memory instead of a disk
+var memoryFilesystems = map[string]bool{"tmpfs": true, "ramfs": true}
+
+// errNoDataMount is returned when auto-selection finds no suitable mount
+var errNoDataMount = errors.New("no writable data mount besides the root file system has room for the test files")
+
+// targetProblems lists the targets that are on a RAM-backed file system, or on
+// the root file system with too little space to spare. Targets whose mount or
+// capacity can't be read are left to the capacity check.
+func (i *IOStressPlugin) targetProblems(workers []int) []string {
+	var problems []string
+	for idx, target := range i.config.Targets {
+		absolute, err := filepath.Abs(target.Path)
+		if err != nil {
+			continue
+		}
+		mount, ok := mountForPath(absolute, true)
+		if !ok {
+			continue
+		}
+
+		if memoryFilesystems[mount.Fstype] {
+			problems = append(problems, fmt.Sprintf("%s is on %s, so the test would stress memory rather than disk", target.Path, mount.Fstype))
+			continue
+		}
+		if mount.Mountpoint != "/" {
+			continue
+		}
+
+		usage, err := diskUsage(absolute)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+		required := uint64(workers[idx]) * uint64(i.fileSizeBytes)
+		var remaining uint64
+		if usage.Free > required {
+			remaining = usage.Free - required
+		}
+		if float64(remaining) < float64(usage.Total)*minRootFreePercent/100 {
+			problems = append(problems, fmt.Sprintf("%s is on the root file system, which would have %d MB free once the test files are created, under %d%%",
+				target.Path, remaining/(1024*1024), minRootFreePercent))
+		}
+	}
+	return problems
+}
+
+// selectDataMount returns the mount point of the physical, writable file system
+// besides root with the most free space, provided it can hold the test files
+// while keeping the configured headroom free
+func (i *IOStressPlugin) selectDataMount() (string, error) {
+	partitions, err := diskPartitions(false)
+	if err != nil {
+		return "", fmt.Errorf("failed to list mounts: %w", err)
+	}
+
+	required := uint64(i.config.Workers) * uint64(i.fileSizeBytes)
+	var best string
+	var bestFree uint64
+	for _, partition := range partitions {
+		if partition.Mountpoint == "/" || withinMount(partition.Mountpoint, "/boot") ||
+			memoryFilesystems[partition.Fstype] || readOnlyMount(partition.Opts) {
+			continue
+		}
+		usage, err := diskUsage(partition.Mountpoint)
+		if err != nil {
+			continue
+		}
+		headroom := uint64(float64(usage.Total) * i.config.HeadroomPercent / 100)
+		if usage.Free < required+headroom {
+			continue
+		}
+		if usage.Free > bestFree || (usage.Free == bestFree && partition.Mountpoint < best) {
+			best, bestFree = partition.Mountpoint, usage.Free
+		}
+	}
+
+	if best == "" {
+		return "", errNoDataMount
+	}
+	return best, nil
+}
+
+// readOnlyMount reports whether mount options include ro
+func readOnlyMount(opts []string) bool {
+	for _, opt := range opts {
+		if opt == "ro" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTempDirPolicy checks the targets and handles any problem as the policy
+// says. Auto-selection only replaces temp_dir; targets given explicitly are
+// refused instead, since they were chosen deliberately.
+func (i *IOStressPlugin) applyTempDirPolicy(defaultTargets bool) error {
+	i.warnings = nil
+	problems := i.targetProblems(i.workersPerTarget())
+	if len(problems) == 0 {
+		return nil
+	}
+
+	switch i.config.TempDirPolicy {
+	case TempDirPolicyRefuse:
+		return fmt.Errorf("unsuitable target: %s", strings.Join(problems, "; "))
+	case TempDirPolicyAuto:
+		if !defaultTargets {
+			return fmt.Errorf("unsuitable target: %s", strings.Join(problems, "; "))
+		}
+		mount, err := i.selectDataMount()
+		if err != nil {
+			return fmt.Errorf("unsuitable temp_dir: %s; %w", strings.Join(problems, "; "), err)
+		}
+		i.warnings = append(problems, fmt.Sprintf("using %s instead of %s", mount, i.config.TempDir))
+		i.config.TempDir = mount
+		i.config.Targets = []IOTarget{{Path: mount, Weight: 1}}
+	default:
+		i.warnings = problems
+	}
+	return nil
+}
+
+// ConfigWarnings returns the problems found with the targets by the last
+// Initialize that didn't stop it
+func (i *IOStressPlugin) ConfigWarnings() []string {
+	return i.warnings
+}
@@ -0,0 +1,241 @@
+package plugins
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// I/O engines of the I/O stress plugin
+const (
+	IOEngineSync    = "sync"     // one blocking operation at a time per worker
+	IOEngineIOURing = "io_uring" // queue_depth operations in flight per worker, Linux only
+)
+
+// Polling modes of the io_uring engine
+const (
+	IOPollingNone = "none"
+	IOPollingSQ   = "sqpoll" // a kernel thread picks up submissions, saving a system call per batch
+	IOPollingIO   = "iopoll" // completions are polled from the device rather than interrupt driven; needs direct I/O
+)
+
+// Queue depth limits of the io_uring engine
+const (
+	defaultIOQueueDepth = 32
+	maxIOQueueDepth     = 1024
+)
+
+// directIOAlignment is the alignment O_DIRECT needs of block sizes and offsets
+const directIOAlignment = 4096
+
+// validateEngine checks the engine settings and fills in their defaults
+func (i *IOStressPlugin) validateEngine() error {
+	switch i.config.Engine {
+	case "":
+		i.config.Engine = IOEngineSync
+	case IOEngineSync, IOEngineIOURing:
+	default:
+		return fmt.Errorf("invalid engine %q: must be sync or io_uring", i.config.Engine)
+	}
+	switch i.config.Polling {
+	case "":
+		i.config.Polling = IOPollingNone
+	case IOPollingNone, IOPollingSQ, IOPollingIO:
+	default:
+		return fmt.Errorf("invalid polling %q: must be none, sqpoll or iopoll", i.config.Polling)
+	}
+
+	if i.config.Engine != IOEngineIOURing {
+		if i.config.Polling != IOPollingNone {
+			return fmt.Errorf("polling requires the io_uring engine")
+		}
+		return nil
+	}
+	if !ioURingSupported {
+		return fmt.Errorf("the io_uring engine is only available on Linux")
+	}
+	if i.config.QueueDepth == 0 {
+		i.config.QueueDepth = defaultIOQueueDepth
+	}
+	if i.config.QueueDepth < 1 || i.config.QueueDepth > maxIOQueueDepth {
+		return fmt.Errorf("queue_depth must be between 1 and %d", maxIOQueueDepth)
+	}
+	if i.config.Polling == IOPollingIO && !i.config.Direct {
+		return fmt.Errorf("iopoll polling requires direct I/O")
+	}
+	if i.config.Direct && (i.blockSizeBytes%directIOAlignment != 0 || i.fileSizeBytes%directIOAlignment != 0) {
+		return fmt.Errorf("direct I/O with io_uring needs block_size and file_size to be multiples of %d bytes", directIOAlignment)
+	}
+	if i.blockSizeBytes > i.fileSizeBytes {
+		return fmt.Errorf("block_size must not be larger than file_size")
+	}
+
+	// Fail now rather than in every worker if the kernel refuses io_uring, as
+	// kernels before 5.6, seccomp profiles and kernel.io_uring_disabled do
+	ring, err := newIOURing(1, i.config.Polling)
+	if err != nil {
+		return fmt.Errorf("io_uring is unavailable: %w", err)
+	}
+	ring.close()
+	return nil
+}
+
+// ioURingSlot is an operation in flight on a ring, identified by its buffer
+type ioURingSlot struct {
+	operation string
+	submitted time.Time
+	submitLat time.Duration
+}
+
+// ioURingWorker keeps queue_depth operations in flight on the worker's test file,
+// refilling the queue as they complete, and records how long submitting and
+// completing them took separately
+func (i *IOStressPlugin) ioURingWorker(ctx context.Context, workerID int) error {
+	i.mu.RLock()
+	if workerID >= len(i.testFiles) {
+		i.mu.RUnlock()
+		return nil
+	}
+	testFile := i.testFiles[workerID]
+	stream := i.stream
+	seed := i.seed
+	i.mu.RUnlock()
+
+	depth := i.config.QueueDepth
+	ring, err := newIOURing(depth, i.config.Polling)
+	if err != nil {
+		return err
+	}
+	defer ring.close()
+
+	buffers, region, err := allocIOBuffers(depth, int(i.blockSizeBytes))
+	if err != nil {
+		return err
+	}
+	defer freeIOBuffers(region)
+	// Written buffers are filled once; generating random data per operation
+	// would bottleneck deep queues on the CPU
+	if _, err := rand.Read(region); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(testFile.path, ioURingOpenFlags(i.config.Direct, i.config.Fsync), 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fd := int(file.Fd())
+
+	rng := seededRand(seed, workerID)
+	blocks := i.fileSizeBytes / i.blockSizeBytes
+	var cursor int64
+
+	slots := make([]ioURingSlot, depth)
+	free := make([]int, 0, depth)
+	for slot := depth - 1; slot >= 0; slot-- {
+		free = append(free, slot)
+	}
+	batch := make([]int, 0, depth)
+	inflight := 0
+	stopping := false
+
+	complete := func(userData uint64, res int32) {
+		slot := int(userData)
+		completionLat := time.Since(slots[slot].submitted)
+		submitLat := slots[slot].submitLat
+		operation := slots[slot].operation
+		free = append(free, slot)
+		inflight--
+
+		i.mu.Lock()
+		if res < 0 {
+			i.metrics.ErrorCount++
+			i.mu.Unlock()
+			return
+		}
+		i.countIO(testFile.target, operation, int64(res))
+		i.metrics.AvgLatencyMs = float64((submitLat + completionLat).Nanoseconds()) / 1000000.0
+		i.latencyOps++
+		i.submitLatency += submitLat
+		i.completionLatency += completionLat
+		i.mu.Unlock()
+
+		stream.publish("io_operation", stream.tags("operation", operation, "target", testFile.target, "engine", IOEngineIOURing), models.IOOperationFields{
+			LatencyUs:           float64((submitLat + completionLat).Nanoseconds()) / 1000.0,
+			SubmitLatencyUs:     float64(submitLat.Nanoseconds()) / 1000.0,
+			CompletionLatencyUs: float64(completionLat.Nanoseconds()) / 1000.0,
+			Bytes:               int64(res),
+			URing:               true,
+		}.Fields())
+	}
+
+	for {
+		if !stopping {
+			select {
+			case <-ctx.Done():
+				stopping = true
+			case <-i.stopChan:
+				stopping = true
+			case <-i.budgetDone:
+				stopping = true
+			default:
+			}
+		}
+		// Operations in flight are drained before the ring and buffers go away
+		if stopping && inflight == 0 && len(batch) == 0 {
+			return nil
+		}
+
+		for !stopping && len(free) > 0 {
+			slot := free[len(free)-1]
+			operation := i.config.Operations
+			if operation == "mixed" {
+				operation = "write"
+				if rng.Float64() < i.config.ReadWriteRatio {
+					operation = "read"
+				}
+			}
+			// Offsets are within the worker's region on raw devices
+			offset := testFile.offset
+			if i.config.Sequential {
+				offset += cursor * i.blockSizeBytes
+				cursor = (cursor + 1) % blocks
+			} else {
+				offset += rng.Int63n(blocks) * i.blockSizeBytes
+			}
+			if !ring.prepare(uint64(slot), operation == "write", fd, buffers[slot], offset) {
+				break
+			}
+			free = free[:len(free)-1]
+			slots[slot].operation = operation
+			batch = append(batch, slot)
+		}
+
+		if len(batch) > 0 {
+			start := time.Now()
+			submitted, err := ring.submit()
+			submitLat := time.Since(start)
+			if err != nil {
+				return fmt.Errorf("io_uring submit failed: %w", err)
+			}
+			now := time.Now()
+			for _, slot := range batch[:submitted] {
+				slots[slot].submitted = now
+				slots[slot].submitLat = submitLat
+			}
+			inflight += submitted
+			batch = append(batch[:0], batch[submitted:]...)
+		}
+
+		if inflight > 0 && ring.reap(complete) == 0 {
+			if err := ring.wait(); err != nil {
+				return fmt.Errorf("io_uring wait failed: %w", err)
+			}
+			ring.reap(complete)
+		}
+	}
+}
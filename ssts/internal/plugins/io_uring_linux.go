@@ -0,0 +1,305 @@
+//go:build linux
+
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioURingSupported reports whether the io_uring engine can be used
+const ioURingSupported = true
+
+// io_uring ABI constants from linux/io_uring.h
+const (
+	ioringSetupIOPoll = 1 << 0
+	ioringSetupSQPoll = 1 << 1
+
+	ioringFeatSingleMmap = 1 << 0
+
+	ioringEnterGetEvents = 1 << 0
+	ioringEnterSQWakeup  = 1 << 1
+
+	ioringSQNeedWakeup = 1 << 0
+
+	ioringOffSQRing = 0
+	ioringOffCQRing = 0x8000000
+	ioringOffSQEs   = 0x10000000
+
+	ioringOpRead  = 22
+	ioringOpWrite = 23
+)
+
+// sqPollIdleMs is how long the kernel's submission polling thread spins without
+// work before it sleeps and has to be woken
+const sqPollIdleMs = 100
+
+type ioURingSQOffsets struct {
+	Head, Tail, RingMask, RingEntries, Flags, Dropped, Array, Resv1 uint32
+	UserAddr                                                        uint64
+}
+
+type ioURingCQOffsets struct {
+	Head, Tail, RingMask, RingEntries, Overflow, CQEs, Flags, Resv1 uint32
+	UserAddr                                                        uint64
+}
+
+type ioURingParams struct {
+	SQEntries, CQEntries, Flags, SQThreadCPU, SQThreadIdle, Features, WQFd uint32
+	Resv                                                                   [3]uint32
+	SQOff                                                                  ioURingSQOffsets
+	CQOff                                                                  ioURingCQOffsets
+}
+
+type ioURingSQE struct {
+	Opcode      uint8
+	Flags       uint8
+	IOPrio      uint16
+	Fd          int32
+	Off         uint64
+	Addr        uint64
+	Len         uint32
+	RWFlags     uint32
+	UserData    uint64
+	BufIndex    uint16
+	Personality uint16
+	SpliceFdIn  int32
+	Addr3       uint64
+	Pad         uint64
+}
+
+type ioURingCQE struct {
+	UserData uint64
+	Res      int32
+	Flags    uint32
+}
+
+// ioURing is a single-threaded io_uring instance: one goroutine prepares,
+// submits and reaps its operations
+type ioURing struct {
+	fd      int
+	sqPoll  bool
+	pending uint32 // prepared but not yet submitted
+
+	sqRing, cqRing, sqeMem []byte
+	sqHead, sqTail         *uint32
+	sqMask, sqFlags        *uint32
+	sqArray                []uint32
+	sqes                   []ioURingSQE
+	cqHead, cqTail, cqMask *uint32
+	cqes                   []ioURingCQE
+}
+
+// newIOURing sets up a ring with room for entries operations in flight. Polling
+// is none, sqpoll (a kernel thread picks up submissions) or iopoll (completions
+// are polled from the device, which takes O_DIRECT).
+func newIOURing(entries int, polling string) (*ioURing, error) {
+	var params ioURingParams
+	switch polling {
+	case IOPollingSQ:
+		params.Flags = ioringSetupSQPoll
+		params.SQThreadIdle = sqPollIdleMs
+	case IOPollingIO:
+		params.Flags = ioringSetupIOPoll
+	}
+
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&params)), 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("io_uring_setup: %w", errno)
+	}
+	ring := &ioURing{
+		fd:     int(fd),
+		sqPoll: params.Flags&ioringSetupSQPoll != 0,
+	}
+	if err := ring.mmap(&params); err != nil {
+		ring.close()
+		return nil, err
+	}
+	return ring, nil
+}
+
+// mmap maps the submission and completion rings and the submission entries the
+// kernel shares with the ring
+func (r *ioURing) mmap(params *ioURingParams) error {
+	sqSize := int(params.SQOff.Array + params.SQEntries*4)
+	cqSize := int(params.CQOff.CQEs + params.CQEntries*uint32(unsafe.Sizeof(ioURingCQE{})))
+	single := params.Features&ioringFeatSingleMmap != 0
+	if single && cqSize > sqSize {
+		sqSize = cqSize
+	}
+
+	var err error
+	r.sqRing, err = unix.Mmap(r.fd, ioringOffSQRing, sqSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("failed to map io_uring submission ring: %w", err)
+	}
+	if single {
+		r.cqRing = r.sqRing
+	} else {
+		r.cqRing, err = unix.Mmap(r.fd, ioringOffCQRing, cqSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+		if err != nil {
+			return fmt.Errorf("failed to map io_uring completion ring: %w", err)
+		}
+	}
+	sqeSize := int(params.SQEntries) * int(unsafe.Sizeof(ioURingSQE{}))
+	r.sqeMem, err = unix.Mmap(r.fd, ioringOffSQEs, sqeSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("failed to map io_uring submission entries: %w", err)
+	}
+
+	sq, cq := params.SQOff, params.CQOff
+	r.sqHead = (*uint32)(unsafe.Pointer(&r.sqRing[sq.Head]))
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[sq.Tail]))
+	r.sqMask = (*uint32)(unsafe.Pointer(&r.sqRing[sq.RingMask]))
+	r.sqFlags = (*uint32)(unsafe.Pointer(&r.sqRing[sq.Flags]))
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&r.sqRing[sq.Array])), params.SQEntries)
+	r.sqes = unsafe.Slice((*ioURingSQE)(unsafe.Pointer(&r.sqeMem[0])), params.SQEntries)
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[cq.Head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[cq.Tail]))
+	r.cqMask = (*uint32)(unsafe.Pointer(&r.cqRing[cq.RingMask]))
+	r.cqes = unsafe.Slice((*ioURingCQE)(unsafe.Pointer(&r.cqRing[cq.CQEs])), params.CQEntries)
+	return nil
+}
+
+// prepare queues a read or write of buf at offset in the file, to be sent to the
+// kernel by the next submit. It returns false if the submission ring is full.
+func (r *ioURing) prepare(userData uint64, write bool, fd int, buf []byte, offset int64) bool {
+	tail := atomic.LoadUint32(r.sqTail)
+	if tail-atomic.LoadUint32(r.sqHead) >= uint32(len(r.sqes)) {
+		return false
+	}
+
+	opcode := uint8(ioringOpRead)
+	if write {
+		opcode = ioringOpWrite
+	}
+	index := tail & *r.sqMask
+	r.sqes[index] = ioURingSQE{
+		Opcode:   opcode,
+		Fd:       int32(fd),
+		Off:      uint64(offset),
+		Addr:     uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		Len:      uint32(len(buf)),
+		UserData: userData,
+	}
+	r.sqArray[index] = index
+	atomic.StoreUint32(r.sqTail, tail+1)
+	r.pending++
+	return true
+}
+
+// submit sends the prepared operations to the kernel, returning how many were
+// taken. With submission polling the kernel thread takes them itself, and only
+// needs waking once it has gone idle.
+func (r *ioURing) submit() (int, error) {
+	if r.pending == 0 {
+		return 0, nil
+	}
+	if r.sqPoll {
+		if atomic.LoadUint32(r.sqFlags)&ioringSQNeedWakeup != 0 {
+			if _, err := r.enter(0, 0, ioringEnterSQWakeup); err != nil {
+				return 0, err
+			}
+		}
+		submitted := int(r.pending)
+		r.pending = 0
+		return submitted, nil
+	}
+
+	submitted, err := r.enter(r.pending, 0, 0)
+	if err == unix.EAGAIN || err == unix.EBUSY {
+		// Out of resources until completions are reaped; the rest stay queued
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	r.pending -= uint32(submitted)
+	return submitted, nil
+}
+
+// wait blocks until at least one operation has completed
+func (r *ioURing) wait() error {
+	_, err := r.enter(0, 1, ioringEnterGetEvents)
+	return err
+}
+
+// reap calls fn with every completion waiting in the ring and returns how many
+// there were. Polled completions only appear once the ring has been entered.
+func (r *ioURing) reap(fn func(userData uint64, res int32)) int {
+	head := atomic.LoadUint32(r.cqHead)
+	tail := atomic.LoadUint32(r.cqTail)
+	reaped := 0
+	for ; head != tail; head++ {
+		cqe := r.cqes[head&*r.cqMask]
+		fn(cqe.UserData, cqe.Res)
+		reaped++
+	}
+	atomic.StoreUint32(r.cqHead, head)
+	return reaped
+}
+
+// enter calls io_uring_enter, retrying calls interrupted by a signal
+func (r *ioURing) enter(toSubmit, minComplete uint32, flags uintptr) (int, error) {
+	for {
+		n, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), uintptr(toSubmit), uintptr(minComplete), flags, 0, 0)
+		if errno == unix.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return 0, errno
+		}
+		return int(n), nil
+	}
+}
+
+// close unmaps the rings and closes the ring. The kernel keeps the pages of
+// operations still in flight pinned until they complete.
+func (r *ioURing) close() error {
+	if r.sqeMem != nil {
+		unix.Munmap(r.sqeMem)
+	}
+	if r.cqRing != nil && &r.cqRing[0] != &r.sqRing[0] {
+		unix.Munmap(r.cqRing)
+	}
+	if r.sqRing != nil {
+		unix.Munmap(r.sqRing)
+	}
+	return unix.Close(r.fd)
+}
+
+// ioURingOpenFlags returns the flags test files are opened with for the engine:
+// O_DIRECT for direct I/O, and O_DSYNC in place of an fsync after every write
+func ioURingOpenFlags(direct, fsync bool) int {
+	flags := os.O_RDWR
+	if direct {
+		flags |= unix.O_DIRECT
+	}
+	if fsync {
+		flags |= unix.O_DSYNC
+	}
+	return flags
+}
+
+// allocIOBuffers allocates count buffers of size bytes outside the Go heap, page
+// aligned as O_DIRECT needs. It also returns the region to free them with.
+func allocIOBuffers(count, size int) ([][]byte, []byte, error) {
+	region, err := unix.Mmap(-1, 0, count*size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to allocate I/O buffers: %w", err)
+	}
+	buffers := make([][]byte, count)
+	for idx := range buffers {
+		buffers[idx] = region[idx*size : (idx+1)*size : (idx+1)*size]
+	}
+	return buffers, region, nil
+}
+
+// freeIOBuffers releases buffers from allocIOBuffers
+func freeIOBuffers(region []byte) {
+	unix.Munmap(region)
+}
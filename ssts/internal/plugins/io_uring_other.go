@@ -0,0 +1,46 @@
+//go:build !linux
+
+package plugins
+
+import "errors"
+
+// io_uring is only available on Linux
+const ioURingSupported = false
+
+var errNoIOURing = errors.New("io_uring is only available on Linux")
+
+type ioURing struct{}
+
+func newIOURing(entries int, polling string) (*ioURing, error) {
+	return nil, errNoIOURing
+}
+
+func (r *ioURing) prepare(userData uint64, write bool, fd int, buf []byte, offset int64) bool {
+	return false
+}
+
+func (r *ioURing) submit() (int, error) {
+	return 0, errNoIOURing
+}
+
+func (r *ioURing) wait() error {
+	return errNoIOURing
+}
+
+func (r *ioURing) reap(fn func(userData uint64, res int32)) int {
+	return 0
+}
+
+func (r *ioURing) close() error {
+	return nil
+}
+
+func ioURingOpenFlags(direct, fsync bool) int {
+	return 0
+}
+
+func allocIOBuffers(count, size int) ([][]byte, []byte, error) {
+	return nil, nil, errNoIOURing
+}
+
+func freeIOBuffers(region []byte) {}
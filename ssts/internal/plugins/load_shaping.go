@@ -0,0 +1,9 @@
+package plugins
+
+// LoadShaper is implemented by plugins whose load can be raised and lowered
+// while running. The orchestrator calls SetLoadLevel with the percentage of the
+// run's intensity its load profile gives as the run goes on; only runs of
+// plugins that implement it may ask for a load profile.
+type LoadShaper interface {
+	SetLoadLevel(percent int)
+}
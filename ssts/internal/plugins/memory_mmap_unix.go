@@ -0,0 +1,16 @@
+//go:build !windows
+
+package plugins
+
+import "syscall"
+
+// mmapChunk maps anonymous private memory outside the Go heap, which the garbage
+// collector neither scans nor counts towards GOGC or GOMEMLIMIT
+func mmapChunk(size int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+// munmapChunk releases memory mapped by mmapChunk
+func munmapChunk(chunk []byte) error {
+	return syscall.Munmap(chunk)
+}
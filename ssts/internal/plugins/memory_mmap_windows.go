@@ -0,0 +1,12 @@
+//go:build windows
+
+package plugins
+
+import "fmt"
+
+// mmapChunk is unavailable; anonymous mmap is only used on Unix
+func mmapChunk(size int) ([]byte, error) {
+	return nil, fmt.Errorf("the mmap allocator is not available on Windows")
+}
+
+func munmapChunk(chunk []byte) error { return nil }
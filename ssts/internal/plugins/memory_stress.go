@@ -6,11 +6,16 @@ import (
 	"fmt"
 	"math/rand"
 	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/shirou/gopsutil/v3/mem"
+
 	"github.com/pranavgopavaram/ssts/pkg/models"
 )
 
@@ -22,8 +27,27 @@ type MemoryStressConfig struct {
 	Workers      int    `json:"workers"`       // Number of worker threads
 	ChunkSize    string `json:"chunk_size"`    // Size of individual allocations
 	AccessDelay  int    `json:"access_delay"`  // Delay between accesses in ms
+
+	// Verify turns the test into a data-integrity burn-in: patterns are written to
+	// every chunk and read back, and any mismatch fails the test
+	Verify         bool     `json:"verify"`
+	VerifyPatterns []string `json:"verify_patterns"` // walking_ones, walking_zeros, random
+	Seed           int64    `json:"seed"`            // seed of the random pattern; 0 picks one
+
+	// GOGC and MemoryLimit tune the garbage collector for the run. Both are
+	// process-wide, so they apply to the whole server while the test runs, and
+	// the previous settings are restored when it ends.
+	GOGC        string `json:"gogc"`         // GC target percentage, or off; empty leaves it unchanged
+	MemoryLimit string `json:"memory_limit"` // soft heap limit, e.g. 2GB; empty leaves it unchanged
+	Allocator   string `json:"allocator"`    // heap, or mmap to allocate chunks outside the Go heap
 }
 
+// Memory allocators
+const (
+	MemoryAllocatorHeap = "heap"
+	MemoryAllocatorMmap = "mmap"
+)
+
 // MemoryStressPlugin implements memory stress testing
 type MemoryStressPlugin struct {
 	config       MemoryStressConfig
@@ -33,6 +57,19 @@ type MemoryStressPlugin struct {
 	stopChan     chan bool
 	allocSizeMB  int64
 	chunkSizeMB  int64
+	verify       *memoryVerifier
+	gcPercent    *int  // GOGC for the run, -1 for off; nil leaves it unchanged
+	memoryLimit  int64 // GOMEMLIMIT for the run in bytes; 0 leaves it unchanged
+	gcStart      gcSnapshot
+	seed         int64 // of the run's random pattern and accesses
+}
+
+// gcSnapshot records the collector's counters at the start of a run, so the
+// metrics cover only the collections made during it
+type gcSnapshot struct {
+	time       time.Time
+	numGC      int64
+	pauseTotal time.Duration
 }
 
 // MemoryMetrics tracks memory stress test metrics
@@ -43,6 +80,14 @@ type MemoryMetrics struct {
 	CacheHitRatio  float64 `json:"cache_hit_ratio"`
 	AllocatedMB    int64   `json:"allocated_mb"`
 	AccessCount    int64   `json:"access_count"`
+
+	GCCount        int64   `json:"gc_count"`
+	GCPauseP50     float64 `json:"gc_pause_p50_us"`
+	GCPauseP90     float64 `json:"gc_pause_p90_us"`
+	GCPauseP99     float64 `json:"gc_pause_p99_us"`
+	GCPauseMax     float64 `json:"gc_pause_max_us"`
+	GCPauseTotalMs float64 `json:"gc_pause_total_ms"`
+	HeapMB         float64 `json:"heap_mb"`
 }
 
 // NewMemoryStressPlugin creates a new memory stress plugin
@@ -105,10 +150,53 @@ func (m *MemoryStressPlugin) ConfigSchema() []byte {
 			},
 			"access_delay": {
 				"type": "integer",
+				"x-unit": "ms",
 				"minimum": 0,
 				"maximum": 1000,
 				"default": 10,
 				"description": "Delay between memory accesses in milliseconds"
+			},
+			"verify": {
+				"type": "boolean",
+				"x-group": "Verification",
+				"default": false,
+				"description": "Write known patterns and read them back to detect bit errors, failing the test on corruption"
+			},
+			"verify_patterns": {
+				"type": "array",
+				"x-group": "Verification",
+				"items": {
+					"type": "string",
+					"enum": ["walking_ones", "walking_zeros", "random"]
+				},
+				"default": ["walking_ones", "walking_zeros", "random"],
+				"description": "Patterns written in each verification pass"
+			},
+			"seed": {
+				"type": "integer",
+				"x-group": "Verification",
+				"default": 0,
+				"description": "Seed for the random verification pattern, reported in the metrics so a failure can be reproduced; 0 picks one"
+			},
+			"gogc": {
+				"type": "string",
+				"title": "GOGC",
+				"x-group": "Garbage collection",
+				"default": "",
+				"description": "Garbage collector target percentage for the run (GOGC), or off to disable collection; empty leaves it unchanged. Applies to the whole server while the test runs"
+			},
+			"memory_limit": {
+				"type": "string",
+				"x-group": "Garbage collection",
+				"default": "",
+				"description": "Soft memory limit for the run (GOMEMLIMIT, e.g. 2GB); empty leaves it unchanged. Applies to the whole server while the test runs"
+			},
+			"allocator": {
+				"type": "string",
+				"x-group": "Garbage collection",
+				"enum": ["heap", "mmap"],
+				"default": "heap",
+				"description": "heap allocates chunks on the Go heap; mmap maps them outside it, so the garbage collector neither scans them nor counts them towards gogc and memory_limit"
 			}
 		}
 	}`
@@ -154,6 +242,49 @@ func (m *MemoryStressPlugin) Initialize(config interface{}) error {
 		return fmt.Errorf("invalid chunk_size: %w", err)
 	}
 
+	if m.config.Allocator == "" {
+		m.config.Allocator = MemoryAllocatorHeap
+	}
+	switch m.config.Allocator {
+	case MemoryAllocatorHeap, MemoryAllocatorMmap:
+	default:
+		return fmt.Errorf("unsupported allocator: %s", m.config.Allocator)
+	}
+
+	m.gcPercent = nil
+	switch gogc := strings.ToLower(strings.TrimSpace(m.config.GOGC)); gogc {
+	case "":
+	case "off":
+		off := -1
+		m.gcPercent = &off
+	default:
+		percent, err := strconv.Atoi(gogc)
+		if err != nil || percent < 0 {
+			return fmt.Errorf("invalid gogc %q: expected a percentage or off", m.config.GOGC)
+		}
+		m.gcPercent = &percent
+	}
+
+	m.memoryLimit = 0
+	if m.config.MemoryLimit != "" {
+		limitMB, err := m.parseMemorySize(m.config.MemoryLimit)
+		if err != nil {
+			return fmt.Errorf("invalid memory_limit: %w", err)
+		}
+		if limitMB <= 0 {
+			return fmt.Errorf("invalid memory_limit: must be positive")
+		}
+		m.memoryLimit = limitMB * 1024 * 1024
+	}
+
+	m.verify = nil
+	if m.config.Verify {
+		m.verify, err = newMemoryVerifier(m.config.VerifyPatterns, m.config.Seed)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -179,12 +310,43 @@ func (m *MemoryStressPlugin) parseMemorySize(size string) (int64, error) {
 	return value * multiplier, nil
 }
 
+// ResourceClaims claims the memory the test allocates out of the machine's RAM, so
+// concurrent memory tests can't together push the machine into swap or the OOM killer
+func (m *MemoryStressPlugin) ResourceClaims(config interface{}) ([]ResourceClaim, error) {
+	if err := m.Initialize(config); err != nil {
+		return nil, err
+	}
+
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read total memory: %w", err)
+	}
+	return []ResourceClaim{{
+		Resource: ResourceMemory,
+		Amount:   float64(m.allocSizeMB * 1024 * 1024),
+		Capacity: float64(vm.Total),
+	}}, nil
+}
+
 // Execute runs the memory stress test
 func (m *MemoryStressPlugin) Execute(ctx context.Context, params models.TestParams) error {
 	m.mu.Lock()
 	m.metrics.AccessCount = 0
 	m.metrics.AllocatedMB = 0
+	m.gcStart = readGCSnapshot()
+	m.seed = params.Seed
+	if m.config.Seed != 0 {
+		m.seed = m.config.Seed
+	}
 	m.mu.Unlock()
+	if m.verify != nil && m.config.Seed == 0 && params.Seed != 0 {
+		m.verify.mu.Lock()
+		m.verify.seed = params.Seed
+		m.verify.mu.Unlock()
+	}
+
+	defer m.tuneGC()()
+	defer m.collectGCMetrics()
 
 	// Start metrics collection
 	go m.collectMetrics(ctx)
@@ -200,6 +362,13 @@ func (m *MemoryStressPlugin) Execute(ctx context.Context, params models.TestPara
 		return fmt.Errorf("memory allocation failed: %w", err)
 	}
 
+	if m.verify != nil {
+		m.mu.RLock()
+		chunks := m.allocations
+		m.mu.RUnlock()
+		return m.verify.run(ctx, chunks, m.config.Workers)
+	}
+
 	// Start memory access workers
 	var wg sync.WaitGroup
 	for i := 0; i < m.config.Workers; i++ {
@@ -214,17 +383,56 @@ func (m *MemoryStressPlugin) Execute(ctx context.Context, params models.TestPara
 		close(done)
 	}()
 
+	// Workers are waited for even when cancelled, so Cleanup can't unmap
+	// chunks they are still accessing
 	select {
 	case <-ctx.Done():
+		<-done
 		return ctx.Err()
 	case <-done:
 		return nil
 	}
 }
 
+// FollowsDeadline reports that memory workers run until the context ends
+func (m *MemoryStressPlugin) FollowsDeadline() bool {
+	return true
+}
+
+// tuneGC applies the configured GOGC and memory limit, returning a function
+// restoring the previous settings
+func (m *MemoryStressPlugin) tuneGC() func() {
+	var previousPercent int
+	var previousLimit int64
+	if m.gcPercent != nil {
+		previousPercent = debug.SetGCPercent(*m.gcPercent)
+	}
+	if m.memoryLimit > 0 {
+		previousLimit = debug.SetMemoryLimit(m.memoryLimit)
+	}
+
+	return func() {
+		if m.gcPercent != nil {
+			debug.SetGCPercent(previousPercent)
+		}
+		if m.memoryLimit > 0 {
+			debug.SetMemoryLimit(previousLimit)
+		}
+	}
+}
+
+// allocateChunk allocates one chunk with the configured allocator
+func (m *MemoryStressPlugin) allocateChunk(size int) ([]byte, error) {
+	if m.config.Allocator == MemoryAllocatorMmap {
+		return mmapChunk(size)
+	}
+	return make([]byte, size), nil
+}
+
 // allocateMemory allocates memory chunks based on the configured pattern
 func (m *MemoryStressPlugin) allocateMemory(ctx context.Context, numChunks int) error {
 	chunkBytes := m.chunkSizeMB * 1024 * 1024
+	rng := seededRand(m.seed, 0)
 	
 	for i := 0; i < numChunks; i++ {
 		select {
@@ -234,14 +442,17 @@ func (m *MemoryStressPlugin) allocateMemory(ctx context.Context, numChunks int)
 		}
 
 		// Allocate chunk
-		chunk := make([]byte, chunkBytes)
+		chunk, err := m.allocateChunk(int(chunkBytes))
+		if err != nil {
+			return err
+		}
 		
 		// Initialize based on pattern
 		switch m.config.Pattern {
 		case "sequential":
 			m.initializeSequential(chunk)
 		case "random":
-			m.initializeRandom(chunk)
+			m.initializeRandom(chunk, rng)
 		case "fragmented":
 			m.initializeFragmented(chunk, i)
 		}
@@ -251,11 +462,6 @@ func (m *MemoryStressPlugin) allocateMemory(ctx context.Context, numChunks int)
 		m.metrics.AllocatedMB += m.chunkSizeMB
 		m.mu.Unlock()
 
-		// Force garbage collection periodically
-		if i%10 == 0 {
-			runtime.GC()
-		}
-
 		// Small delay to prevent overwhelming the system
 		time.Sleep(10 * time.Millisecond)
 	}
@@ -271,8 +477,8 @@ func (m *MemoryStressPlugin) initializeSequential(chunk []byte) {
 }
 
 // initializeRandom initializes memory with random pattern
-func (m *MemoryStressPlugin) initializeRandom(chunk []byte) {
-	rand.Read(chunk)
+func (m *MemoryStressPlugin) initializeRandom(chunk []byte, rng *rand.Rand) {
+	rng.Read(chunk)
 }
 
 // initializeFragmented initializes memory with fragmented pattern
@@ -298,6 +504,7 @@ func (m *MemoryStressPlugin) memoryAccessWorker(ctx context.Context, wg *sync.Wa
 	defer wg.Done()
 
 	accessDelay := time.Duration(m.config.AccessDelay) * time.Millisecond
+	rng := seededRand(m.seed, workerID+1)
 
 	for {
 		select {
@@ -318,10 +525,10 @@ func (m *MemoryStressPlugin) memoryAccessWorker(ctx context.Context, wg *sync.Wa
 		}
 
 		// Select random allocation
-		allocIndex := rand.Intn(numAllocations)
+		allocIndex := rng.Intn(numAllocations)
 		
 		start := time.Now()
-		m.performMemoryAccess(allocIndex)
+		m.performMemoryAccess(allocIndex, rng)
 		latency := time.Since(start)
 
 		// Update metrics
@@ -337,7 +544,7 @@ func (m *MemoryStressPlugin) memoryAccessWorker(ctx context.Context, wg *sync.Wa
 }
 
 // performMemoryAccess performs the configured type of memory access
-func (m *MemoryStressPlugin) performMemoryAccess(allocIndex int) {
+func (m *MemoryStressPlugin) performMemoryAccess(allocIndex int, rng *rand.Rand) {
 	m.mu.RLock()
 	if allocIndex >= len(m.allocations) {
 		m.mu.RUnlock()
@@ -347,7 +554,7 @@ func (m *MemoryStressPlugin) performMemoryAccess(allocIndex int) {
 	m.mu.RUnlock()
 
 	// Random offset within chunk
-	offset := rand.Intn(len(chunk) - 1024)
+	offset := rng.Intn(len(chunk) - 1024)
 	if offset < 0 {
 		offset = 0
 	}
@@ -356,12 +563,12 @@ func (m *MemoryStressPlugin) performMemoryAccess(allocIndex int) {
 	case "read":
 		m.performRead(chunk, offset)
 	case "write":
-		m.performWrite(chunk, offset)
+		m.performWrite(chunk, offset, rng)
 	case "readwrite":
-		if rand.Intn(2) == 0 {
+		if rng.Intn(2) == 0 {
 			m.performRead(chunk, offset)
 		} else {
-			m.performWrite(chunk, offset)
+			m.performWrite(chunk, offset, rng)
 		}
 	}
 }
@@ -377,9 +584,9 @@ func (m *MemoryStressPlugin) performRead(chunk []byte, offset int) {
 }
 
 // performWrite performs memory write operations
-func (m *MemoryStressPlugin) performWrite(chunk []byte, offset int) {
+func (m *MemoryStressPlugin) performWrite(chunk []byte, offset int, rng *rand.Rand) {
 	// Write 1KB of data
-	value := byte(rand.Intn(256))
+	value := byte(rng.Intn(256))
 	for i := offset; i < offset+1024 && i < len(chunk); i++ {
 		chunk[i] = value
 	}
@@ -405,19 +612,76 @@ func (m *MemoryStressPlugin) collectMetrics(ctx context.Context) {
 			lastAllocatedMB = currentAlloc
 			
 			m.mu.Unlock()
+
+			m.collectGCMetrics()
 		}
 	}
 }
 
+// readGCSnapshot reads the collector's counters
+func readGCSnapshot() gcSnapshot {
+	var stats debug.GCStats
+	debug.ReadGCStats(&stats)
+	return gcSnapshot{time: time.Now(), numGC: stats.NumGC, pauseTotal: stats.PauseTotal}
+}
+
+// collectGCMetrics records the count and pause distribution of the collections
+// made since the run started. The runtime keeps only the most recent 256
+// pauses, so on long runs the distribution covers those.
+func (m *MemoryStressPlugin) collectGCMetrics() {
+	var stats debug.GCStats
+	debug.ReadGCStats(&stats)
+
+	m.mu.RLock()
+	start := m.gcStart
+	m.mu.RUnlock()
+
+	pauses := make([]time.Duration, 0, len(stats.Pause))
+	for i, end := range stats.PauseEnd {
+		if i < len(stats.Pause) && !end.Before(start.time) {
+			pauses = append(pauses, stats.Pause[i])
+		}
+	}
+	sort.Slice(pauses, func(i, j int) bool { return pauses[i] < pauses[j] })
+
+	heap := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(heap)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metrics.GCCount = stats.NumGC - start.numGC
+	m.metrics.GCPauseTotalMs = float64(stats.PauseTotal-start.pauseTotal) / float64(time.Millisecond)
+	if heap[0].Value.Kind() == metrics.KindUint64 {
+		m.metrics.HeapMB = float64(heap[0].Value.Uint64()) / (1024 * 1024)
+	}
+	if len(pauses) > 0 {
+		m.metrics.GCPauseP50 = percentileMicros(pauses, 0.50)
+		m.metrics.GCPauseP90 = percentileMicros(pauses, 0.90)
+		m.metrics.GCPauseP99 = percentileMicros(pauses, 0.99)
+		m.metrics.GCPauseMax = percentileMicros(pauses, 1)
+	}
+}
+
 // Cleanup cleans up allocated memory and resources
 func (m *MemoryStressPlugin) Cleanup() error {
 	close(m.stopChan)
 	
 	m.mu.Lock()
 	// Clear allocations to allow garbage collection
-	m.allocations = m.allocations[:0]
+	allocations := m.allocations
+	m.allocations = nil
 	m.mu.Unlock()
-	
+
+	if m.config.Allocator == MemoryAllocatorMmap {
+		var firstErr error
+		for _, chunk := range allocations {
+			if err := munmapChunk(chunk); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to unmap memory: %w", err)
+			}
+		}
+		return firstErr
+	}
+
 	// Force garbage collection
 	runtime.GC()
 	
@@ -429,7 +693,7 @@ func (m *MemoryStressPlugin) GetMetrics() map[string]interface{} {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	return map[string]interface{}{
+	metrics := map[string]interface{}{
 		"alloc_rate_mb_per_sec": m.metrics.AllocationRate,
 		"access_latency_ns":     m.metrics.AccessLatency,
 		"page_faults_per_sec":   m.metrics.PageFaults,
@@ -437,7 +701,21 @@ func (m *MemoryStressPlugin) GetMetrics() map[string]interface{} {
 		"allocated_mb":          m.metrics.AllocatedMB,
 		"access_count":          m.metrics.AccessCount,
 		"num_allocations":       len(m.allocations),
+		"allocator":             m.config.Allocator,
+		"gc_count":              m.metrics.GCCount,
+		"gc_pause_p50_us":       m.metrics.GCPauseP50,
+		"gc_pause_p90_us":       m.metrics.GCPauseP90,
+		"gc_pause_p99_us":       m.metrics.GCPauseP99,
+		"gc_pause_max_us":       m.metrics.GCPauseMax,
+		"gc_pause_total_ms":     m.metrics.GCPauseTotalMs,
+		"heap_mb":               m.metrics.HeapMB,
+	}
+	if m.verify != nil {
+		for name, value := range m.verify.metrics() {
+			metrics[name] = value
+		}
 	}
+	return metrics
 }
 
 // GetSafetyLimits returns safety limits for memory testing
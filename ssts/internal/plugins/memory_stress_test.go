@@ -0,0 +1,127 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestMemoryStressInitializeGCSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		percent *int
+		limit   int64
+		wantErr bool
+	}{
+		{name: "unchanged by default", config: map[string]interface{}{}},
+		{name: "percentage", config: map[string]interface{}{"gogc": "50"}, percent: intPtr(50)},
+		{name: "off", config: map[string]interface{}{"gogc": "OFF"}, percent: intPtr(-1)},
+		{name: "memory limit", config: map[string]interface{}{"memory_limit": "2GB"}, limit: 2 << 30},
+		{name: "negative gogc", config: map[string]interface{}{"gogc": "-5"}, wantErr: true},
+		{name: "invalid gogc", config: map[string]interface{}{"gogc": "lots"}, wantErr: true},
+		{name: "invalid memory limit", config: map[string]interface{}{"memory_limit": "2TB"}, wantErr: true},
+		{name: "unknown allocator", config: map[string]interface{}{"allocator": "arena"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := NewMemoryStressPlugin()
+			err := plugin.Initialize(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Initialize() = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+			if (plugin.gcPercent == nil) != (tt.percent == nil) || (tt.percent != nil && *plugin.gcPercent != *tt.percent) {
+				t.Errorf("gcPercent = %v, want %v", plugin.gcPercent, tt.percent)
+			}
+			if plugin.memoryLimit != tt.limit {
+				t.Errorf("memoryLimit = %d, want %d", plugin.memoryLimit, tt.limit)
+			}
+			if plugin.config.Allocator != MemoryAllocatorHeap {
+				t.Errorf("allocator = %q, want heap by default", plugin.config.Allocator)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+// runMemoryStress runs a small memory test, forcing a collection while it runs
+func runMemoryStress(t *testing.T, config map[string]interface{}) *MemoryStressPlugin {
+	t.Helper()
+
+	plugin := NewMemoryStressPlugin()
+	config["alloc_size"] = "4MB"
+	config["chunk_size"] = "1MB"
+	config["workers"] = 1
+	if err := plugin.Initialize(config); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		runtime.GC()
+	}()
+	if err := plugin.Execute(ctx, models.TestParams{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Execute() = %v, want the deadline", err)
+	}
+	return plugin
+}
+
+func TestMemoryStressRestoresGCSettings(t *testing.T) {
+	previousPercent := debug.SetGCPercent(100)
+	previousLimit := debug.SetMemoryLimit(-1)
+	defer debug.SetGCPercent(previousPercent)
+	defer debug.SetMemoryLimit(previousLimit)
+
+	plugin := runMemoryStress(t, map[string]interface{}{"gogc": "off", "memory_limit": "512MB"})
+	defer plugin.Cleanup()
+
+	if percent := debug.SetGCPercent(100); percent != 100 {
+		t.Errorf("GOGC after the run = %d, want 100 restored", percent)
+	}
+	if limit := debug.SetMemoryLimit(-1); limit != previousLimit {
+		t.Errorf("memory limit after the run = %d, want %d restored", limit, previousLimit)
+	}
+}
+
+func TestMemoryStressReportsGCPauses(t *testing.T) {
+	for _, allocator := range []string{MemoryAllocatorHeap, MemoryAllocatorMmap} {
+		t.Run(allocator, func(t *testing.T) {
+			if allocator == MemoryAllocatorMmap && runtime.GOOS == "windows" {
+				t.Skip("the mmap allocator is not available on Windows")
+			}
+			plugin := runMemoryStress(t, map[string]interface{}{"allocator": allocator})
+
+			metrics := plugin.GetMetrics()
+			if metrics["allocated_mb"] != int64(4) || metrics["allocator"] != allocator {
+				t.Errorf("metrics = %+v, want 4MB allocated with %s", metrics, allocator)
+			}
+			if count, _ := metrics["gc_count"].(int64); count == 0 {
+				t.Errorf("metrics = %+v, want the forced collection counted", metrics)
+			}
+			if p50, max := metrics["gc_pause_p50_us"].(float64), metrics["gc_pause_max_us"].(float64); p50 <= 0 || max < p50 {
+				t.Errorf("gc pauses p50 = %v, max = %v", p50, max)
+			}
+
+			if err := plugin.Cleanup(); err != nil {
+				t.Fatalf("Cleanup: %v", err)
+			}
+			if n := plugin.GetMetrics()["num_allocations"]; n != 0 {
+				t.Errorf("num_allocations after Cleanup = %v, want 0", n)
+			}
+		})
+	}
+}
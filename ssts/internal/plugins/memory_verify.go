@@ -0,0 +1,208 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Verification patterns
+const (
+	PatternWalkingOnes  = "walking_ones"  // a single set bit per byte, shifted every pass
+	PatternWalkingZeros = "walking_zeros" // a single cleared bit per byte, shifted every pass
+	PatternRandom       = "random"        // pseudo-random bytes derived from the seed
+)
+
+// maxReportedCorruptions caps the corruptions kept for reporting; bit errors
+// beyond it are still counted
+const maxReportedCorruptions = 100
+
+// MemoryCorruption describes a byte that didn't read back as written
+type MemoryCorruption struct {
+	Pattern     string `json:"pattern"`
+	Pass        int64  `json:"pass"`
+	Chunk       int    `json:"chunk"`
+	Offset      int    `json:"offset"`
+	Address     string `json:"address"`
+	Expected    byte   `json:"expected"`
+	Actual      byte   `json:"actual"`
+	FlippedBits int    `json:"flipped_bits"`
+}
+
+// memoryVerifier writes known patterns to memory and reads them back, like memtest
+type memoryVerifier struct {
+	patterns []string
+	seed     int64
+
+	mu            sync.Mutex
+	passes        int64
+	verifiedBytes int64
+	bitErrors     int64
+	corruptions   []MemoryCorruption
+}
+
+func newMemoryVerifier(patterns []string, seed int64) (*memoryVerifier, error) {
+	if len(patterns) == 0 {
+		patterns = []string{PatternWalkingOnes, PatternWalkingZeros, PatternRandom}
+	}
+	for _, pattern := range patterns {
+		switch pattern {
+		case PatternWalkingOnes, PatternWalkingZeros, PatternRandom:
+		default:
+			return nil, fmt.Errorf("%w: unknown verify pattern %q", ErrInvalidConfig, pattern)
+		}
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &memoryVerifier{patterns: patterns, seed: seed}, nil
+}
+
+// run repeats verification passes over the chunks until ctx is done, splitting the
+// chunks between workers. Every pass writes each pattern to all chunks before
+// reading it back, so data has to survive a full sweep. It returns an error wrapping
+// ErrPluginExecution as soon as a pattern reads back wrong.
+func (v *memoryVerifier) run(ctx context.Context, chunks [][]byte, workers int) error {
+	if workers > len(chunks) {
+		workers = len(chunks)
+	}
+	if workers <= 0 {
+		return fmt.Errorf("%w: no memory allocated to verify", ErrPluginExecution)
+	}
+
+	for pass := int64(0); ; pass++ {
+		for _, pattern := range v.patterns {
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := w; i < len(chunks) && ctx.Err() == nil; i += workers {
+						v.fill(pattern, pass, i, chunks[i])
+					}
+					for i := w; i < len(chunks) && ctx.Err() == nil; i += workers {
+						v.check(pattern, pass, i, chunks[i])
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			if err := v.err(); err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+
+		v.mu.Lock()
+		v.passes++
+		v.mu.Unlock()
+	}
+}
+
+// fill writes a pattern to a chunk
+func (v *memoryVerifier) fill(pattern string, pass int64, chunkIndex int, chunk []byte) {
+	next := v.generator(pattern, pass, chunkIndex)
+	for i := range chunk {
+		chunk[i] = next(i)
+	}
+}
+
+// check reads a chunk back, records any mismatches and returns the number of flipped bits
+func (v *memoryVerifier) check(pattern string, pass int64, chunkIndex int, chunk []byte) int64 {
+	next := v.generator(pattern, pass, chunkIndex)
+
+	var flipped int64
+	for i := range chunk {
+		expected := next(i)
+		actual := chunk[i]
+		if actual == expected {
+			continue
+		}
+
+		count := bits.OnesCount8(actual ^ expected)
+		flipped += int64(count)
+
+		v.mu.Lock()
+		if len(v.corruptions) < maxReportedCorruptions {
+			v.corruptions = append(v.corruptions, MemoryCorruption{
+				Pattern:     pattern,
+				Pass:        pass,
+				Chunk:       chunkIndex,
+				Offset:      i,
+				Address:     fmt.Sprintf("%p", &chunk[i]),
+				Expected:    expected,
+				Actual:      actual,
+				FlippedBits: count,
+			})
+		}
+		v.mu.Unlock()
+	}
+
+	v.mu.Lock()
+	v.verifiedBytes += int64(len(chunk))
+	v.bitErrors += flipped
+	v.mu.Unlock()
+
+	return flipped
+}
+
+// generator returns the expected byte at each offset of a chunk. It must be called
+// with increasing offsets, since the random pattern is a stream.
+func (v *memoryVerifier) generator(pattern string, pass int64, chunkIndex int) func(offset int) byte {
+	switch pattern {
+	case PatternWalkingOnes:
+		return func(offset int) byte {
+			return 1 << ((int64(offset) + pass) % 8)
+		}
+	case PatternWalkingZeros:
+		return func(offset int) byte {
+			return ^byte(1 << ((int64(offset) + pass) % 8))
+		}
+	default:
+		// Each chunk and pass gets its own stream, reproducible from the seed
+		r := rand.New(rand.NewSource(v.seed ^ pass<<32 ^ int64(chunkIndex)))
+		var word uint64
+		return func(offset int) byte {
+			if offset%8 == 0 {
+				word = r.Uint64()
+			}
+			return byte(word >> (8 * (offset % 8)))
+		}
+	}
+}
+
+// err describes the corruption found so far, or returns nil if there is none
+func (v *memoryVerifier) err() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.bitErrors == 0 {
+		return nil
+	}
+	first := v.corruptions[0]
+	return fmt.Errorf("%w: memory corruption detected: %d bit errors, first at chunk %d offset %#x (address %s) with %s pattern: expected %#02x, read %#02x (seed %d)",
+		ErrPluginExecution, v.bitErrors, first.Chunk, first.Offset, first.Address, first.Pattern, first.Expected, first.Actual, v.seed)
+}
+
+// metrics returns the verification metrics
+func (v *memoryVerifier) metrics() map[string]interface{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	corruptions := make([]MemoryCorruption, len(v.corruptions))
+	copy(corruptions, v.corruptions)
+
+	return map[string]interface{}{
+		"verify_passes":  v.passes,
+		"verified_bytes": v.verifiedBytes,
+		"bit_errors":     v.bitErrors,
+		"verify_seed":    v.seed,
+		"corruptions":    corruptions,
+	}
+}
@@ -0,0 +1,77 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryVerifierPatternsReadBack(t *testing.T) {
+	v, err := newMemoryVerifier(nil, 42)
+	if err != nil {
+		t.Fatalf("newMemoryVerifier: %v", err)
+	}
+
+	chunk := make([]byte, 4099)
+	for _, pattern := range v.patterns {
+		for pass := int64(0); pass < 3; pass++ {
+			v.fill(pattern, pass, 1, chunk)
+			if flipped := v.check(pattern, pass, 1, chunk); flipped != 0 {
+				t.Fatalf("%s pass %d: %d bit errors on intact memory", pattern, pass, flipped)
+			}
+		}
+	}
+	if err := v.err(); err != nil {
+		t.Fatalf("err() = %v, want nil", err)
+	}
+}
+
+func TestMemoryVerifierDetectsCorruption(t *testing.T) {
+	v, err := newMemoryVerifier([]string{PatternRandom}, 7)
+	if err != nil {
+		t.Fatalf("newMemoryVerifier: %v", err)
+	}
+
+	chunk := make([]byte, 1024)
+	v.fill(PatternRandom, 0, 3, chunk)
+	chunk[100] ^= 0x05
+
+	if flipped := v.check(PatternRandom, 0, 3, chunk); flipped != 2 {
+		t.Fatalf("check() = %d flipped bits, want 2", flipped)
+	}
+	if len(v.corruptions) != 1 {
+		t.Fatalf("got %d corruptions, want 1", len(v.corruptions))
+	}
+	c := v.corruptions[0]
+	if c.Chunk != 3 || c.Offset != 100 || c.Expected^c.Actual != 0x05 || c.FlippedBits != 2 {
+		t.Errorf("unexpected corruption record %+v", c)
+	}
+	if err := v.err(); !errors.Is(err, ErrPluginExecution) {
+		t.Errorf("err() = %v, want ErrPluginExecution", err)
+	}
+}
+
+func TestMemoryVerifierRunStopsWithContext(t *testing.T) {
+	v, err := newMemoryVerifier(nil, 1)
+	if err != nil {
+		t.Fatalf("newMemoryVerifier: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	chunks := [][]byte{make([]byte, 1<<16), make([]byte, 1<<16)}
+	if err := v.run(ctx, chunks, 4); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("run() = %v, want context.DeadlineExceeded", err)
+	}
+	if v.verifiedBytes == 0 {
+		t.Error("run() verified no memory")
+	}
+}
+
+func TestMemoryVerifierRejectsUnknownPattern(t *testing.T) {
+	if _, err := newMemoryVerifier([]string{"checkerboard"}, 0); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("newMemoryVerifier() = %v, want ErrInvalidConfig", err)
+	}
+}
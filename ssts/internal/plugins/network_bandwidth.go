@@ -0,0 +1,971 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Bandwidth measurement roles and directions. Directions are seen from the
+// client: upload sends from the client to the server.
+const (
+	BandwidthRoleClient = "client"
+	BandwidthRoleServer = "server"
+
+	BandwidthUpload   = "upload"
+	BandwidthDownload = "download"
+	BandwidthBoth     = "both"
+)
+
+// bandwidthDefaultListen is where the server role listens unless configured, iperf3's port
+const bandwidthDefaultListen = ":5201"
+
+// bandwidthReportTimeout bounds the exchange of reports after the data phase,
+// and how long the server waits for a UDP download client's first datagram
+const bandwidthReportTimeout = 5 * time.Second
+
+// bandwidthUDPDrain is how long a UDP receiver keeps reading after the sender has
+// finished, for datagrams still in flight
+const bandwidthUDPDrain = 250 * time.Millisecond
+
+// Limits on what a client may ask the server for
+const (
+	maxBandwidthBlock    = 16 << 20
+	maxBandwidthDuration = 7 * 24 * time.Hour
+	udpHeaderSize        = 16 // sequence number and send time
+)
+
+// NetworkBandwidthConfig defines configuration for network bandwidth measurement
+type NetworkBandwidthConfig struct {
+	Role          string  `json:"role"`            // client (default) or server
+	Peer          string  `json:"peer"`            // Server to measure against as host:port; empty measures over loopback
+	Listen        string  `json:"listen"`          // Address the server role listens on
+	Protocol      string  `json:"protocol"`        // tcp or udp
+	Direction     string  `json:"direction"`       // upload, download or both
+	Streams       int     `json:"streams"`         // Parallel streams per direction
+	BlockSize     string  `json:"block_size"`      // TCP write size, e.g. 128KB
+	UDPRateMbps   float64 `json:"udp_rate_mbps"`   // Target send rate of each UDP stream
+	UDPPacketSize int     `json:"udp_packet_size"` // UDP payload size in bytes
+}
+
+// NetworkBandwidthPlugin measures throughput between a client and a server run by
+// this plugin, like iperf. One execution with the server role listens on an agent
+// while another with the client role measures against it from a second agent;
+// without a peer the client measures over loopback against a server of its own.
+type NetworkBandwidthPlugin struct {
+	config    NetworkBandwidthConfig
+	blockSize int
+	metrics   *BandwidthMetrics
+	mu        sync.RWMutex
+	streams   []*bandwidthStream
+	results   []BandwidthStreamResult
+	server    *bandwidthServer
+}
+
+// BandwidthMetrics tracks the combined throughput of all streams
+type BandwidthMetrics struct {
+	UploadMbps    float64 `json:"upload_mbps"`
+	DownloadMbps  float64 `json:"download_mbps"`
+	UploadBytes   int64   `json:"upload_bytes"`
+	DownloadBytes int64   `json:"download_bytes"`
+	Retransmits   int64   `json:"retransmits"`
+	JitterMs      float64 `json:"jitter_ms"`
+	LossPercent   float64 `json:"loss_percent"`
+	ActiveStreams int     `json:"active_streams"`
+}
+
+// BandwidthStreamResult is the outcome of one stream in one direction. Bytes and
+// timing are measured by the receiver; retransmits are counted by the sender.
+type BandwidthStreamResult struct {
+	Stream      int     `json:"stream"`
+	Direction   string  `json:"direction"`
+	Bytes       int64   `json:"bytes"`
+	Seconds     float64 `json:"seconds"`
+	Mbps        float64 `json:"mbps"`
+	Retransmits int64   `json:"retransmits,omitempty"` // TCP, Linux only
+	Packets     int64   `json:"packets,omitempty"`     // UDP datagrams received
+	Lost        int64   `json:"lost,omitempty"`
+	LossPercent float64 `json:"loss_percent,omitempty"`
+	OutOfOrder  int64   `json:"out_of_order,omitempty"`
+	JitterMs    float64 `json:"jitter_ms,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// bandwidthStream holds the live byte count of a running stream
+type bandwidthStream struct {
+	id        int
+	direction string
+	bytes     int64
+}
+
+// bandwidthRequest opens a stream; the client sends it as the first line
+type bandwidthRequest struct {
+	Protocol   string        `json:"protocol"`
+	Direction  string        `json:"direction"`
+	Duration   time.Duration `json:"duration"`
+	BlockSize  int           `json:"block_size,omitempty"`
+	RateMbps   float64       `json:"rate_mbps,omitempty"`
+	PacketSize int           `json:"packet_size,omitempty"`
+}
+
+// bandwidthReady answers a request, with the port to use for UDP streams
+type bandwidthReady struct {
+	UDPPort int    `json:"udp_port,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// bandwidthReport is exchanged once a stream's data has been sent: the sender
+// reports what it sent, the receiver what arrived
+type bandwidthReport struct {
+	Bytes       int64   `json:"bytes"`
+	Seconds     float64 `json:"seconds"`
+	Retransmits int64   `json:"retransmits,omitempty"`
+	Packets     int64   `json:"packets,omitempty"`
+	Lost        int64   `json:"lost,omitempty"`
+	OutOfOrder  int64   `json:"out_of_order,omitempty"`
+	JitterMs    float64 `json:"jitter_ms,omitempty"`
+}
+
+// NewNetworkBandwidthPlugin creates a new network bandwidth plugin
+func NewNetworkBandwidthPlugin() *NetworkBandwidthPlugin {
+	return &NetworkBandwidthPlugin{
+		metrics: &BandwidthMetrics{},
+	}
+}
+
+// Name returns the plugin name
+func (b *NetworkBandwidthPlugin) Name() string {
+	return "network-bandwidth"
+}
+
+// Version returns the plugin version
+func (b *NetworkBandwidthPlugin) Version() string {
+	return "1.0.0"
+}
+
+// Description returns the plugin description
+func (b *NetworkBandwidthPlugin) Description() string {
+	return "Network bandwidth plugin measuring TCP/UDP throughput, retransmits and jitter between two agents or over loopback"
+}
+
+// ConfigSchema returns the JSON schema for configuration
+func (b *NetworkBandwidthPlugin) ConfigSchema() []byte {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"role": {
+				"type": "string",
+				"enum": ["client", "server"],
+				"default": "client",
+				"description": "server listens for clients for the test duration; client measures against a server"
+			},
+			"peer": {
+				"type": "string",
+				"description": "host:port of the agent running the server role; empty measures over loopback"
+			},
+			"listen": {
+				"type": "string",
+				"default": ":5201",
+				"description": "Address the server role listens on"
+			},
+			"protocol": {
+				"type": "string",
+				"enum": ["tcp", "udp"],
+				"default": "tcp",
+				"description": "tcp measures throughput and retransmits; udp sends at a fixed rate and measures loss and jitter"
+			},
+			"direction": {
+				"type": "string",
+				"enum": ["upload", "download", "both"],
+				"default": "both",
+				"description": "upload sends from the client to the server; both runs the two directions at once"
+			},
+			"streams": {
+				"type": "integer",
+				"minimum": 1,
+				"maximum": 128,
+				"default": 1,
+				"description": "Parallel streams in each direction"
+			},
+			"block_size": {
+				"type": "string",
+				"default": "128KB",
+				"description": "Size of each TCP write"
+			},
+			"udp_rate_mbps": {
+				"type": "number",
+				"x-group": "UDP",
+				"minimum": 0.001,
+				"default": 100,
+				"description": "Target send rate of each UDP stream"
+			},
+			"udp_packet_size": {
+				"type": "integer",
+				"x-unit": "bytes",
+				"x-group": "UDP",
+				"minimum": 16,
+				"maximum": 65000,
+				"default": 1400,
+				"description": "UDP payload size; keep it under the path MTU to avoid fragmentation"
+			}
+		}
+	}`
+	return []byte(schema)
+}
+
+// Initialize initializes the plugin with configuration
+func (b *NetworkBandwidthPlugin) Initialize(config interface{}) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	b.config = NetworkBandwidthConfig{
+		Role:          BandwidthRoleClient,
+		Listen:        bandwidthDefaultListen,
+		Protocol:      "tcp",
+		Direction:     BandwidthBoth,
+		Streams:       1,
+		BlockSize:     "128KB",
+		UDPRateMbps:   100,
+		UDPPacketSize: 1400,
+	}
+	if err := json.Unmarshal(configBytes, &b.config); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	switch b.config.Role {
+	case BandwidthRoleClient:
+		if b.config.Peer != "" {
+			if _, _, err := net.SplitHostPort(b.config.Peer); err != nil {
+				return fmt.Errorf("invalid peer %q, want host:port: %w", b.config.Peer, err)
+			}
+		}
+	case BandwidthRoleServer:
+		if _, _, err := net.SplitHostPort(b.config.Listen); err != nil {
+			return fmt.Errorf("invalid listen address %q: %w", b.config.Listen, err)
+		}
+	default:
+		return fmt.Errorf("unsupported role: %s", b.config.Role)
+	}
+
+	if b.config.Protocol != "tcp" && b.config.Protocol != "udp" {
+		return fmt.Errorf("unsupported protocol: %s", b.config.Protocol)
+	}
+	switch b.config.Direction {
+	case BandwidthUpload, BandwidthDownload, BandwidthBoth:
+	default:
+		return fmt.Errorf("unsupported direction: %s", b.config.Direction)
+	}
+	if b.config.Streams < 1 || b.config.Streams > 128 {
+		return fmt.Errorf("streams must be between 1 and 128")
+	}
+
+	blockSize, err := ParseSize(b.config.BlockSize)
+	if err != nil {
+		return fmt.Errorf("invalid block_size: %w", err)
+	}
+	if blockSize < 1024 || blockSize > maxBandwidthBlock {
+		return fmt.Errorf("block_size must be between 1KB and 16MB")
+	}
+	b.blockSize = int(blockSize)
+
+	if b.config.UDPRateMbps <= 0 {
+		return fmt.Errorf("udp_rate_mbps must be positive")
+	}
+	if b.config.UDPPacketSize < udpHeaderSize || b.config.UDPPacketSize > 65000 {
+		return fmt.Errorf("udp_packet_size must be between %d and 65000", udpHeaderSize)
+	}
+
+	return nil
+}
+
+// Execute runs the server until the test ends, or measures every stream against
+// the peer for the test duration
+func (b *NetworkBandwidthPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	if b.config.Role == BandwidthRoleServer {
+		listener, err := net.Listen("tcp", b.config.Listen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", b.config.Listen, err)
+		}
+		server := &bandwidthServer{}
+		b.mu.Lock()
+		b.server = server
+		b.mu.Unlock()
+		return server.serve(ctx, listener)
+	}
+
+	peer := b.config.Peer
+	if peer == "" {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return fmt.Errorf("failed to start loopback server: %w", err)
+		}
+		serverCtx, stopServer := context.WithCancel(context.Background())
+		defer stopServer()
+		go (&bandwidthServer{}).serve(serverCtx, listener)
+		peer = listener.Addr().String()
+	}
+
+	duration := params.Duration
+	if deadline, ok := ctx.Deadline(); ok && (duration <= 0 || time.Until(deadline) < duration) {
+		duration = time.Until(deadline)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("test duration is required")
+	}
+
+	directions := []string{b.config.Direction}
+	if b.config.Direction == BandwidthBoth {
+		directions = []string{BandwidthUpload, BandwidthDownload}
+	}
+	var streams []*bandwidthStream
+	for id := 1; id <= b.config.Streams; id++ {
+		for _, direction := range directions {
+			streams = append(streams, &bandwidthStream{id: id, direction: direction})
+		}
+	}
+
+	b.mu.Lock()
+	b.streams = streams
+	b.results = nil
+	b.metrics = &BandwidthMetrics{ActiveStreams: len(streams)}
+	b.mu.Unlock()
+
+	collectCtx, stopCollecting := context.WithCancel(ctx)
+	go b.collectMetrics(collectCtx)
+
+	results := make([]BandwidthStreamResult, len(streams))
+	errs := make([]error, len(streams))
+	var wg sync.WaitGroup
+	for i, stream := range streams {
+		wg.Add(1)
+		go func(i int, stream *bandwidthStream) {
+			defer wg.Done()
+			request := bandwidthRequest{
+				Protocol:   b.config.Protocol,
+				Direction:  stream.direction,
+				Duration:   duration,
+				BlockSize:  b.blockSize,
+				RateMbps:   b.config.UDPRateMbps,
+				PacketSize: b.config.UDPPacketSize,
+			}
+			report, err := runBandwidthStream(ctx, peer, request, &stream.bytes)
+			results[i] = newBandwidthStreamResult(stream, report, err)
+			errs[i] = err
+		}(i, stream)
+	}
+	wg.Wait()
+	stopCollecting()
+
+	b.mu.Lock()
+	b.results = results
+	b.metrics = summarizeBandwidth(results)
+	b.mu.Unlock()
+
+	if ctx.Err() == context.Canceled {
+		return ctx.Err()
+	}
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("stream %d %s: %w", streams[i].id, streams[i].direction, err)
+		}
+	}
+	return nil
+}
+
+// collectMetrics updates the live throughput of the running streams once a second
+func (b *NetworkBandwidthPlugin) collectMetrics(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	var lastUpload, lastDownload int64
+	lastSample := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			b.mu.Lock()
+			var upload, download int64
+			for _, stream := range b.streams {
+				if stream.direction == BandwidthUpload {
+					upload += atomic.LoadInt64(&stream.bytes)
+				} else {
+					download += atomic.LoadInt64(&stream.bytes)
+				}
+			}
+			if elapsed := now.Sub(lastSample).Seconds(); elapsed > 0 {
+				b.metrics.UploadMbps = megabits(upload-lastUpload) / elapsed
+				b.metrics.DownloadMbps = megabits(download-lastDownload) / elapsed
+			}
+			b.metrics.UploadBytes = upload
+			b.metrics.DownloadBytes = download
+			b.mu.Unlock()
+
+			lastUpload, lastDownload = upload, download
+			lastSample = now
+		}
+	}
+}
+
+// Results returns the outcome of each stream of the last run, once it has finished
+func (b *NetworkBandwidthPlugin) Results() []BandwidthStreamResult {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]BandwidthStreamResult(nil), b.results...)
+}
+
+// Cleanup has nothing to release; connections are closed when Execute returns
+func (b *NetworkBandwidthPlugin) Cleanup() error {
+	return nil
+}
+
+// GetMetrics returns current metrics, with each stream's results once the run has finished
+func (b *NetworkBandwidthPlugin) GetMetrics() map[string]interface{} {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	metrics := map[string]interface{}{
+		"role":           b.config.Role,
+		"protocol":       b.config.Protocol,
+		"upload_mbps":    b.metrics.UploadMbps,
+		"download_mbps":  b.metrics.DownloadMbps,
+		"upload_bytes":   b.metrics.UploadBytes,
+		"download_bytes": b.metrics.DownloadBytes,
+		"retransmits":    b.metrics.Retransmits,
+		"jitter_ms":      b.metrics.JitterMs,
+		"loss_percent":   b.metrics.LossPercent,
+		"active_streams": b.metrics.ActiveStreams,
+	}
+	if b.server != nil {
+		metrics["connections"] = atomic.LoadInt64(&b.server.connections)
+	}
+
+	for _, result := range b.results {
+		prefix := fmt.Sprintf("stream_%d_%s_", result.Stream, result.Direction)
+		metrics[prefix+"mbps"] = result.Mbps
+		if b.config.Protocol == "tcp" {
+			metrics[prefix+"retransmits"] = result.Retransmits
+		} else {
+			metrics[prefix+"jitter_ms"] = result.JitterMs
+			metrics[prefix+"loss_percent"] = result.LossPercent
+		}
+	}
+
+	return metrics
+}
+
+// GetSafetyLimits returns safety limits for bandwidth measurement, which is
+// meant to saturate the link
+func (b *NetworkBandwidthPlugin) GetSafetyLimits() models.SafetyLimits {
+	return models.SafetyLimits{
+		MaxCPUPercent:    90.0,
+		MaxMemoryPercent: 90.0,
+		MaxDiskPercent:   90.0,
+		MaxNetworkMbps:   100000.0,
+	}
+}
+
+// HealthCheck always passes; the plugin only needs sockets
+func (b *NetworkBandwidthPlugin) HealthCheck() error {
+	return nil
+}
+
+// newBandwidthStreamResult combines a stream's report with its identity
+func newBandwidthStreamResult(stream *bandwidthStream, report bandwidthReport, err error) BandwidthStreamResult {
+	result := BandwidthStreamResult{
+		Stream:      stream.id,
+		Direction:   stream.direction,
+		Bytes:       report.Bytes,
+		Seconds:     report.Seconds,
+		Retransmits: report.Retransmits,
+		Packets:     report.Packets,
+		Lost:        report.Lost,
+		OutOfOrder:  report.OutOfOrder,
+		JitterMs:    report.JitterMs,
+	}
+	if report.Seconds > 0 {
+		result.Mbps = megabits(report.Bytes) / report.Seconds
+	}
+	if sent := report.Packets + report.Lost; sent > 0 {
+		result.LossPercent = float64(report.Lost) / float64(sent) * 100
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// summarizeBandwidth adds up the streams' results into the plugin's metrics
+func summarizeBandwidth(results []BandwidthStreamResult) *BandwidthMetrics {
+	metrics := &BandwidthMetrics{}
+	var packets, lost int64
+	var jitter float64
+	var udpStreams int
+
+	for _, result := range results {
+		if result.Direction == BandwidthUpload {
+			metrics.UploadMbps += result.Mbps
+			metrics.UploadBytes += result.Bytes
+		} else {
+			metrics.DownloadMbps += result.Mbps
+			metrics.DownloadBytes += result.Bytes
+		}
+		metrics.Retransmits += result.Retransmits
+		if result.Packets+result.Lost > 0 {
+			packets += result.Packets
+			lost += result.Lost
+			jitter += result.JitterMs
+			udpStreams++
+		}
+	}
+
+	if udpStreams > 0 {
+		metrics.JitterMs = jitter / float64(udpStreams)
+		metrics.LossPercent = float64(lost) / float64(packets+lost) * 100
+	}
+	return metrics
+}
+
+// runBandwidthStream opens a stream to the server and measures it
+func runBandwidthStream(ctx context.Context, peer string, request bandwidthRequest, counter *int64) (bandwidthReport, error) {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", peer)
+	if err != nil {
+		return bandwidthReport{}, err
+	}
+	defer conn.Close()
+
+	// A stopped test ends right away rather than waiting out the data phase
+	stop := context.AfterFunc(ctx, func() {
+		if ctx.Err() == context.Canceled {
+			conn.Close()
+		}
+	})
+	defer stop()
+
+	conn.SetDeadline(time.Now().Add(request.Duration + bandwidthReportTimeout))
+	reader := bufio.NewReader(conn)
+	if err := writeJSONLine(conn, request); err != nil {
+		return bandwidthReport{}, err
+	}
+	var ready bandwidthReady
+	if err := readJSONLine(reader, &ready); err != nil {
+		return bandwidthReport{}, fmt.Errorf("no answer from server: %w", err)
+	}
+	if ready.Error != "" {
+		return bandwidthReport{}, fmt.Errorf("server refused stream: %s", ready.Error)
+	}
+	start := time.Now()
+
+	if request.Protocol == "tcp" {
+		if request.Direction == BandwidthUpload {
+			return sendTCP(ctx, conn, reader, request, counter)
+		}
+		return receiveTCP(conn, reader, start, counter)
+	}
+
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	udpConn, err := net.Dial("udp", net.JoinHostPort(host, strconv.Itoa(ready.UDPPort)))
+	if err != nil {
+		return bandwidthReport{}, err
+	}
+	defer udpConn.Close()
+
+	if request.Direction == BandwidthUpload {
+		sent, err := sendUDP(ctx, udpConn.Write, request, counter)
+		if err != nil {
+			return bandwidthReport{}, err
+		}
+		return exchangeReports(conn, reader, sent, false)
+	}
+
+	receiver := newUDPReceiver(counter)
+	go receiver.receive(udpConn)
+	go receiver.hello(udpConn)
+	return receiver.finish(conn, reader, udpConn)
+}
+
+// bandwidthServer answers the streams clients open
+type bandwidthServer struct {
+	connections int64
+	wg          sync.WaitGroup
+}
+
+// serve accepts streams until ctx is done, then waits for the open ones to finish
+func (s *bandwidthServer) serve(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		atomic.AddInt64(&s.connections, 1)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// handle runs one stream for a client
+func (s *bandwidthServer) handle(ctx context.Context, conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(bandwidthReportTimeout))
+	reader := bufio.NewReader(conn)
+
+	var request bandwidthRequest
+	if err := readJSONLine(reader, &request); err != nil {
+		return err
+	}
+	if err := request.validate(); err != nil {
+		return writeJSONLine(conn, bandwidthReady{Error: err.Error()})
+	}
+	conn.SetDeadline(time.Now().Add(request.Duration + 2*bandwidthReportTimeout))
+
+	if request.Protocol == "tcp" {
+		if err := writeJSONLine(conn, bandwidthReady{}); err != nil {
+			return err
+		}
+		// The client's upload is the server's download
+		if request.Direction == BandwidthUpload {
+			_, err := receiveTCP(conn, reader, time.Now(), nil)
+			return err
+		}
+		_, err := sendTCP(ctx, conn, reader, request, nil)
+		return err
+	}
+
+	// Receive datagrams on the address the client reached, so replies come from it
+	local := conn.LocalAddr().(*net.TCPAddr)
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: local.IP})
+	if err != nil {
+		return writeJSONLine(conn, bandwidthReady{Error: err.Error()})
+	}
+	defer udpConn.Close()
+	if err := writeJSONLine(conn, bandwidthReady{UDPPort: udpConn.LocalAddr().(*net.UDPAddr).Port}); err != nil {
+		return err
+	}
+
+	if request.Direction == BandwidthUpload {
+		receiver := newUDPReceiver(nil)
+		go receiver.receive(udpConn)
+		_, err := receiver.finish(conn, reader, udpConn)
+		return err
+	}
+
+	// Send to wherever the client's hello came from
+	udpConn.SetReadDeadline(time.Now().Add(bandwidthReportTimeout))
+	_, client, err := udpConn.ReadFromUDP(make([]byte, 64))
+	if err != nil {
+		return fmt.Errorf("no hello from UDP client: %w", err)
+	}
+	write := func(packet []byte) (int, error) { return udpConn.WriteToUDP(packet, client) }
+	sent, err := sendUDP(ctx, write, request, nil)
+	if err != nil {
+		return err
+	}
+	_, err = exchangeReports(conn, reader, sent, false)
+	return err
+}
+
+// validate checks a request from a client
+func (r bandwidthRequest) validate() error {
+	if r.Protocol != "tcp" && r.Protocol != "udp" {
+		return fmt.Errorf("unsupported protocol: %s", r.Protocol)
+	}
+	if r.Direction != BandwidthUpload && r.Direction != BandwidthDownload {
+		return fmt.Errorf("unsupported direction: %s", r.Direction)
+	}
+	if r.Duration <= 0 || r.Duration > maxBandwidthDuration {
+		return fmt.Errorf("duration must be positive and at most %s", maxBandwidthDuration)
+	}
+	if r.Protocol == "tcp" && (r.BlockSize <= 0 || r.BlockSize > maxBandwidthBlock) {
+		return fmt.Errorf("block size must be positive and at most %d bytes", maxBandwidthBlock)
+	}
+	if r.Protocol == "udp" && (r.RateMbps <= 0 || r.PacketSize < udpHeaderSize || r.PacketSize > 65000) {
+		return fmt.Errorf("invalid UDP rate or packet size")
+	}
+	return nil
+}
+
+// sendTCP writes length-prefixed blocks until the duration is up or the test is
+// stopped, then a zero length and the sender's report. The result is the
+// receiver's report with the sender's retransmits.
+func sendTCP(ctx context.Context, conn net.Conn, reader *bufio.Reader, request bandwidthRequest, counter *int64) (bandwidthReport, error) {
+	frame := make([]byte, 4+request.BlockSize)
+	binary.BigEndian.PutUint32(frame, uint32(request.BlockSize))
+
+	deadline := time.Now().Add(request.Duration)
+	for ctx.Err() == nil && time.Now().Before(deadline) {
+		if _, err := conn.Write(frame); err != nil {
+			return bandwidthReport{}, fmt.Errorf("failed to send data: %w", err)
+		}
+		addBytes(counter, int64(request.BlockSize))
+	}
+
+	conn.SetDeadline(time.Now().Add(bandwidthReportTimeout))
+	if _, err := conn.Write(make([]byte, 4)); err != nil {
+		return bandwidthReport{}, fmt.Errorf("failed to end data: %w", err)
+	}
+	sent := bandwidthReport{Retransmits: tcpRetransmits(conn)}
+	return exchangeReports(conn, reader, sent, false)
+}
+
+// receiveTCP reads blocks up to the zero length ending them, then exchanges reports
+func receiveTCP(conn net.Conn, reader *bufio.Reader, start time.Time, counter *int64) (bandwidthReport, error) {
+	var received bandwidthReport
+	var header [4]byte
+	for {
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return received, fmt.Errorf("failed to receive data: %w", err)
+		}
+		size := binary.BigEndian.Uint32(header[:])
+		if size == 0 {
+			break
+		}
+		if size > maxBandwidthBlock {
+			return received, fmt.Errorf("block of %d bytes exceeds the maximum", size)
+		}
+		if _, err := reader.Discard(int(size)); err != nil {
+			return received, fmt.Errorf("failed to receive data: %w", err)
+		}
+		received.Bytes += int64(size)
+		addBytes(counter, int64(size))
+	}
+	received.Seconds = time.Since(start).Seconds()
+
+	conn.SetDeadline(time.Now().Add(bandwidthReportTimeout))
+	return exchangeReports(conn, reader, received, true)
+}
+
+// exchangeReports sends this side's report and reads the other side's; the
+// sender goes first. It returns the receiver's report, with the sender's
+// retransmit count.
+func exchangeReports(conn net.Conn, reader *bufio.Reader, own bandwidthReport, receiver bool) (bandwidthReport, error) {
+	var other bandwidthReport
+	if receiver {
+		if err := readJSONLine(reader, &other); err != nil {
+			return own, fmt.Errorf("no report from sender: %w", err)
+		}
+		own.Retransmits = other.Retransmits
+		return own, writeJSONLine(conn, own)
+	}
+
+	if err := writeJSONLine(conn, own); err != nil {
+		return bandwidthReport{}, err
+	}
+	if err := readJSONLine(reader, &other); err != nil {
+		return bandwidthReport{}, fmt.Errorf("no report from receiver: %w", err)
+	}
+	other.Retransmits = own.Retransmits
+	return other, nil
+}
+
+// sendUDP sends datagrams at the requested rate until the duration is up or the
+// test is stopped. Each starts with its sequence number and send time.
+func sendUDP(ctx context.Context, write func([]byte) (int, error), request bandwidthRequest, counter *int64) (bandwidthReport, error) {
+	packet := make([]byte, request.PacketSize)
+	packetsPerSec := request.RateMbps * 1e6 / 8 / float64(request.PacketSize)
+
+	start := time.Now()
+	var sent int64
+	for ctx.Err() == nil {
+		elapsed := time.Since(start)
+		if elapsed >= request.Duration {
+			break
+		}
+
+		due := int64(elapsed.Seconds()*packetsPerSec) + 1 - sent
+		if due <= 0 {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if due > 1024 {
+			due = 1024
+		}
+		for ; due > 0; due-- {
+			binary.BigEndian.PutUint64(packet, uint64(sent))
+			binary.BigEndian.PutUint64(packet[8:], uint64(time.Now().UnixNano()))
+			if _, err := write(packet); err != nil {
+				return bandwidthReport{}, fmt.Errorf("failed to send datagram: %w", err)
+			}
+			sent++
+			addBytes(counter, int64(request.PacketSize))
+		}
+	}
+
+	return bandwidthReport{
+		Bytes:   sent * int64(request.PacketSize),
+		Seconds: time.Since(start).Seconds(),
+		Packets: sent,
+	}, nil
+}
+
+// udpReceiver counts the datagrams of a UDP stream, tracking reordering and
+// the interarrival jitter as RFC 3550 defines it
+type udpReceiver struct {
+	counter    *int64
+	mu         sync.Mutex
+	packets    int64
+	bytes      int64
+	outOfOrder int64
+	nextSeq    uint64
+	transit    int64   // previous datagram's transit time, ns
+	jitter     float64 // ns
+	done       chan struct{}
+}
+
+func newUDPReceiver(counter *int64) *udpReceiver {
+	return &udpReceiver{counter: counter, done: make(chan struct{})}
+}
+
+// receive reads datagrams until the connection's read deadline or close
+func (u *udpReceiver) receive(conn net.Conn) {
+	defer close(u.done)
+
+	buf := make([]byte, 65536)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		if n < udpHeaderSize {
+			continue // a hello
+		}
+		u.record(buf[:n], time.Now())
+	}
+}
+
+// record accounts for one datagram
+func (u *udpReceiver) record(packet []byte, now time.Time) {
+	seq := binary.BigEndian.Uint64(packet)
+	transit := now.UnixNano() - int64(binary.BigEndian.Uint64(packet[8:]))
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	// Clock offsets between hosts cancel out in the difference of transit times
+	if u.packets > 0 {
+		d := transit - u.transit
+		if d < 0 {
+			d = -d
+		}
+		u.jitter += (float64(d) - u.jitter) / 16
+	}
+	u.transit = transit
+
+	if seq < u.nextSeq {
+		u.outOfOrder++
+	} else {
+		u.nextSeq = seq + 1
+	}
+	u.packets++
+	u.bytes += int64(len(packet))
+	addBytes(u.counter, int64(len(packet)))
+}
+
+// started reports whether any datagram has arrived
+func (u *udpReceiver) started() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.packets > 0
+}
+
+// hello tells the server where to send a download to, repeating in case the
+// first datagrams are lost, until data arrives
+func (u *udpReceiver) hello(conn net.Conn) {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(bandwidthReportTimeout)
+
+	for !u.started() {
+		conn.Write([]byte{0})
+		select {
+		case <-u.done:
+			return
+		case <-timeout:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// finish waits for the sender's report, reads the datagrams still in flight and
+// answers with what arrived
+func (u *udpReceiver) finish(conn net.Conn, reader *bufio.Reader, udpConn net.Conn) (bandwidthReport, error) {
+	var sent bandwidthReport
+	if err := readJSONLine(reader, &sent); err != nil {
+		udpConn.Close()
+		return bandwidthReport{}, fmt.Errorf("no report from sender: %w", err)
+	}
+	udpConn.SetReadDeadline(time.Now().Add(bandwidthUDPDrain))
+	<-u.done
+
+	u.mu.Lock()
+	received := bandwidthReport{
+		Bytes:      u.bytes,
+		Seconds:    sent.Seconds,
+		Packets:    u.packets,
+		OutOfOrder: u.outOfOrder,
+		JitterMs:   u.jitter / float64(time.Millisecond),
+	}
+	u.mu.Unlock()
+	if lost := sent.Packets - received.Packets; lost > 0 {
+		received.Lost = lost
+	}
+
+	conn.SetDeadline(time.Now().Add(bandwidthReportTimeout))
+	return received, writeJSONLine(conn, received)
+}
+
+// writeJSONLine sends a control message
+func writeJSONLine(w io.Writer, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// readJSONLine reads a control message
+func readJSONLine(reader *bufio.Reader, value interface{}) error {
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(line, value)
+}
+
+// addBytes adds to a live byte count, if there is one
+func addBytes(counter *int64, n int64) {
+	if counter != nil {
+		atomic.AddInt64(counter, n)
+	}
+}
+
+// megabits converts bytes to megabits
+func megabits(bytes int64) float64 {
+	return float64(bytes) * 8 / 1e6
+}
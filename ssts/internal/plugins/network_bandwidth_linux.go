@@ -0,0 +1,30 @@
+//go:build linux
+
+package plugins
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcpRetransmits returns how many segments the kernel has retransmitted on a connection
+func tcpRetransmits(conn net.Conn) int64 {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return 0
+	}
+	raw, err := tcpConn.SyscallConn()
+	if err != nil {
+		return 0
+	}
+
+	var info *unix.TCPInfo
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		info, sockErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil || sockErr != nil {
+		return 0
+	}
+	return int64(info.Total_retrans)
+}
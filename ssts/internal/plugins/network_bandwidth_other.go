@@ -0,0 +1,10 @@
+//go:build !linux
+
+package plugins
+
+import "net"
+
+// tcpRetransmits is only available from the kernel on Linux
+func tcpRetransmits(conn net.Conn) int64 {
+	return 0
+}
@@ -0,0 +1,112 @@
+package plugins
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// runBandwidth measures for a short while and returns the stream results
+func runBandwidth(t *testing.T, config map[string]interface{}) []BandwidthStreamResult {
+	t.Helper()
+	plugin := NewNetworkBandwidthPlugin()
+	if err := plugin.Initialize(config); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := plugin.Execute(ctx, models.TestParams{Duration: 300 * time.Millisecond}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	return plugin.Results()
+}
+
+func TestNetworkBandwidthTCPLoopback(t *testing.T) {
+	results := runBandwidth(t, map[string]interface{}{"protocol": "tcp", "streams": 2, "block_size": "64KB"})
+
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want upload and download for 2 streams: %+v", len(results), results)
+	}
+	for _, result := range results {
+		if result.Error != "" || result.Bytes == 0 || result.Mbps <= 0 {
+			t.Errorf("stream %d %s = %+v", result.Stream, result.Direction, result)
+		}
+	}
+}
+
+func TestNetworkBandwidthUDPLoopback(t *testing.T) {
+	results := runBandwidth(t, map[string]interface{}{"protocol": "udp", "udp_rate_mbps": 10, "udp_packet_size": 1000})
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want upload and download: %+v", len(results), results)
+	}
+	for _, result := range results {
+		if result.Error != "" || result.Packets == 0 {
+			t.Errorf("%s = %+v", result.Direction, result)
+		}
+		// 300ms at 10 Mbps is about 375 datagrams of 1000 bytes
+		if sent := result.Packets + result.Lost; sent < 200 || sent > 500 {
+			t.Errorf("%s sent %d datagrams, want about 375", result.Direction, sent)
+		}
+	}
+}
+
+func TestNetworkBandwidthServerRole(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := NewNetworkBandwidthPlugin()
+	if err := server.Initialize(map[string]interface{}{"role": "server", "listen": address}); err != nil {
+		t.Fatalf("Initialize server: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- server.Execute(ctx, models.TestParams{Duration: time.Minute}) }()
+
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if conn, err := net.Dial("tcp", address); err == nil {
+			conn.Close()
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("server didn't start listening")
+		}
+	}
+
+	results := runBandwidth(t, map[string]interface{}{"peer": address, "direction": "upload"})
+	if len(results) != 1 || results[0].Direction != BandwidthUpload || results[0].Bytes == 0 {
+		t.Errorf("results = %+v", results)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("server Execute: %v", err)
+	}
+	if connections := server.GetMetrics()["connections"].(int64); connections < 2 {
+		t.Errorf("server saw %d connections, want the probe and the stream", connections)
+	}
+}
+
+func TestNetworkBandwidthRejectsInvalidConfig(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"protocol": "sctp"},
+		{"direction": "sideways"},
+		{"streams": 500},
+		{"peer": "no-port"},
+		{"role": "relay"},
+		{"block_size": "100"},
+		{"protocol": "udp", "udp_packet_size": 8},
+	} {
+		if err := NewNetworkBandwidthPlugin().Initialize(config); err == nil {
+			t.Errorf("Initialize accepted %v", config)
+		}
+	}
+}
@@ -0,0 +1,614 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/statefile"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// netemHandle is the qdisc handle the plugin installs, so rollback only ever removes
+// a qdisc this plugin created and never one configured by someone else
+const netemHandle = "5e7:"
+
+// netemWatchdogGrace is how long past the test duration the watchdog waits before
+// removing the qdisc on its own, in case this process dies without cleaning up
+const netemWatchdogGrace = 60 * time.Second
+
+// netemDefaultQdiscs are root qdiscs the kernel installs by default; deleting our root
+// qdisc restores one of these, so replacing them loses no configuration
+var netemDefaultQdiscs = map[string]bool{
+	"noqueue":    true,
+	"pfifo_fast": true,
+	"fq_codel":   true,
+	"mq":         true,
+	"fq":         true,
+	"pfifo":      true,
+}
+
+// netemStatsPattern parses the counters line of `tc -s qdisc show`
+var netemStatsPattern = regexp.MustCompile(`Sent (\d+) bytes (\d+) pkt \(dropped (\d+), overlimits (\d+) requeues (\d+)\)`)
+
+// interfaceNamePattern matches the interface names Linux allows, except that it
+// also rejects a leading dash so a name can never be taken for a tc option
+var interfaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.:@][A-Za-z0-9_.:@-]{0,14}$`)
+
+// tcCommand runs tc with the given arguments, returning its combined output
+var tcCommand = func(args ...string) ([]byte, error) {
+	return exec.Command("tc", args...).CombinedOutput()
+}
+
+// NetworkNetemConfig defines configuration for network degradation
+type NetworkNetemConfig struct {
+	Interface        string  `json:"interface"`         // Interface to degrade; defaults to the default-route interface
+	DelayMs          float64 `json:"delay_ms"`          // Added egress latency
+	JitterMs         float64 `json:"jitter_ms"`         // Random variation of the added latency
+	Correlation      float64 `json:"correlation"`       // Percent correlation between successive delays
+	Distribution     string  `json:"distribution"`      // uniform (default), normal, pareto, paretonormal
+	LossPercent      float64 `json:"loss_percent"`      // Random packet loss
+	ReorderPercent   float64 `json:"reorder_percent"`   // Packets sent immediately, ahead of delayed ones
+	DuplicatePercent float64 `json:"duplicate_percent"` // Duplicated packets
+}
+
+// NetworkNetemPlugin injects latency, jitter, loss and reordering with tc/netem
+type NetworkNetemPlugin struct {
+	config   NetworkNetemConfig
+	metrics  *NetemMetrics
+	mu       sync.RWMutex
+	applied  bool
+	token    string
+	watchdog *exec.Cmd
+}
+
+// NetemMetrics tracks the traffic passing through the netem qdisc
+type NetemMetrics struct {
+	Interface      string  `json:"interface"`
+	Rule           string  `json:"rule"`
+	Applied        bool    `json:"applied"`
+	BytesSent      int64   `json:"bytes_sent"`
+	PacketsSent    int64   `json:"packets_sent"`
+	PacketsDropped int64   `json:"packets_dropped"`
+	PacketsPerSec  int64   `json:"packets_per_sec"`
+	DropPercent    float64 `json:"drop_percent"`
+}
+
+// netemMarker is written while the qdisc is installed so a later run can roll back
+// after a crash. The watchdog also checks the token before removing the qdisc.
+type netemMarker struct {
+	Interface string    `json:"interface"`
+	PID       int       `json:"pid"`
+	Token     string    `json:"token"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// NewNetworkNetemPlugin creates a new network degradation plugin
+func NewNetworkNetemPlugin() *NetworkNetemPlugin {
+	return &NetworkNetemPlugin{
+		metrics: &NetemMetrics{},
+	}
+}
+
+// Name returns the plugin name
+func (n *NetworkNetemPlugin) Name() string {
+	return "network-netem"
+}
+
+// Version returns the plugin version
+func (n *NetworkNetemPlugin) Version() string {
+	return "1.0.0"
+}
+
+// Description returns the plugin description
+func (n *NetworkNetemPlugin) Description() string {
+	return "Network degradation plugin injecting latency, jitter, loss and reordering with tc/netem"
+}
+
+// DeclaredCapabilities declares that the plugin needs root to change qdiscs
+func (n *NetworkNetemPlugin) DeclaredCapabilities() models.PluginCapabilities {
+	return models.PluginCapabilities{RequiresRoot: true}
+}
+
+// ConfigSchema returns the JSON schema for configuration
+func (n *NetworkNetemPlugin) ConfigSchema() []byte {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"interface": {
+				"type": "string",
+				"description": "Network interface to degrade (egress only); defaults to the interface of the default route"
+			},
+			"delay_ms": {
+				"type": "number",
+				"minimum": 0,
+				"maximum": 60000,
+				"default": 100,
+				"description": "Latency added to every outgoing packet"
+			},
+			"jitter_ms": {
+				"type": "number",
+				"minimum": 0,
+				"maximum": 60000,
+				"default": 0,
+				"description": "Random variation of the added latency"
+			},
+			"correlation": {
+				"type": "number",
+				"x-unit": "%",
+				"minimum": 0,
+				"maximum": 100,
+				"default": 0,
+				"description": "Percent correlation between successive delays"
+			},
+			"distribution": {
+				"type": "string",
+				"enum": ["uniform", "normal", "pareto", "paretonormal"],
+				"default": "uniform",
+				"description": "Distribution of the jitter"
+			},
+			"loss_percent": {
+				"type": "number",
+				"minimum": 0,
+				"maximum": 100,
+				"default": 0,
+				"description": "Percent of packets dropped at random"
+			},
+			"reorder_percent": {
+				"type": "number",
+				"minimum": 0,
+				"maximum": 100,
+				"default": 0,
+				"description": "Percent of packets sent immediately, overtaking delayed ones (requires delay_ms)"
+			},
+			"duplicate_percent": {
+				"type": "number",
+				"minimum": 0,
+				"maximum": 100,
+				"default": 0,
+				"description": "Percent of packets duplicated"
+			}
+		}
+	}`
+	return []byte(schema)
+}
+
+// Initialize initializes the plugin with configuration
+func (n *NetworkNetemPlugin) Initialize(config interface{}) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	n.config = NetworkNetemConfig{DelayMs: 100}
+	if err := json.Unmarshal(configBytes, &n.config); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	if n.config.Interface == "" {
+		iface, err := defaultRouteInterface()
+		if err != nil {
+			return fmt.Errorf("no interface configured and default route lookup failed: %w", err)
+		}
+		n.config.Interface = iface
+	}
+	if err := checkInterface(n.config.Interface); err != nil {
+		return err
+	}
+
+	for name, value := range map[string]float64{
+		"correlation":       n.config.Correlation,
+		"loss_percent":      n.config.LossPercent,
+		"reorder_percent":   n.config.ReorderPercent,
+		"duplicate_percent": n.config.DuplicatePercent,
+	} {
+		if value < 0 || value > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", name)
+		}
+	}
+	if n.config.DelayMs < 0 || n.config.JitterMs < 0 {
+		return fmt.Errorf("delay_ms and jitter_ms must not be negative")
+	}
+	if n.config.ReorderPercent > 0 && n.config.DelayMs == 0 {
+		return fmt.Errorf("reorder_percent requires delay_ms")
+	}
+
+	switch n.config.Distribution {
+	case "", "uniform":
+		n.config.Distribution = "uniform"
+	case "normal", "pareto", "paretonormal":
+		if n.config.JitterMs == 0 {
+			return fmt.Errorf("distribution %s requires jitter_ms", n.config.Distribution)
+		}
+	default:
+		return fmt.Errorf("unsupported distribution: %s", n.config.Distribution)
+	}
+
+	// Roll back anything left behind by a previous process that died mid-test
+	recoverStaleNetem()
+
+	return nil
+}
+
+// Execute installs the netem qdisc for the test duration and removes it afterwards
+func (n *NetworkNetemPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	if err := n.apply(params.Duration); err != nil {
+		return err
+	}
+	// Cleanup also rolls back, but don't leave the network degraded until then
+	defer n.rollback()
+
+	go n.collectMetrics(ctx)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// netemArgs builds the netem options for tc
+func (n *NetworkNetemPlugin) netemArgs() []string {
+	args := []string{"netem"}
+
+	if n.config.DelayMs > 0 || n.config.JitterMs > 0 {
+		args = append(args, "delay", formatMs(n.config.DelayMs))
+		if n.config.JitterMs > 0 {
+			args = append(args, formatMs(n.config.JitterMs))
+			if n.config.Correlation > 0 {
+				args = append(args, formatPercent(n.config.Correlation))
+			}
+			if n.config.Distribution != "uniform" {
+				args = append(args, "distribution", n.config.Distribution)
+			}
+		}
+	}
+	if n.config.LossPercent > 0 {
+		args = append(args, "loss", formatPercent(n.config.LossPercent))
+	}
+	if n.config.ReorderPercent > 0 {
+		args = append(args, "reorder", formatPercent(n.config.ReorderPercent))
+	}
+	if n.config.DuplicatePercent > 0 {
+		args = append(args, "duplicate", formatPercent(n.config.DuplicatePercent))
+	}
+
+	return args
+}
+
+// apply installs the qdisc. The crash marker and watchdog are set up first so that
+// there is no window in which the qdisc exists without a way to roll it back.
+func (n *NetworkNetemPlugin) apply(duration time.Duration) error {
+	iface := n.config.Interface
+
+	kind, handle, err := rootQdisc(iface)
+	if err != nil {
+		return err
+	}
+	if kind == "netem" {
+		return fmt.Errorf("interface %s already has a netem qdisc (handle %s); another test may be running", iface, handle)
+	}
+	if !netemDefaultQdiscs[kind] {
+		return fmt.Errorf("interface %s has a custom %s root qdisc that would be lost; remove it first", iface, kind)
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	marker := netemMarker{Interface: iface, PID: os.Getpid(), Token: token, AppliedAt: time.Now()}
+	if err := writeNetemMarker(marker); err != nil {
+		return fmt.Errorf("failed to write rollback marker: %w", err)
+	}
+
+	watchdog, err := startNetemWatchdog(iface, token, duration+netemWatchdogGrace)
+	if err != nil {
+		statefile.Remove(netemMarkerPath(iface))
+		return fmt.Errorf("failed to start rollback watchdog: %w", err)
+	}
+
+	args := append([]string{"qdisc", "add", "dev", iface, "root", "handle", netemHandle}, n.netemArgs()...)
+	if output, err := tcCommand(args...); err != nil {
+		stopNetemWatchdog(watchdog)
+		statefile.Remove(netemMarkerPath(iface))
+		return fmt.Errorf("tc %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+
+	n.mu.Lock()
+	n.applied = true
+	n.token = token
+	n.watchdog = watchdog
+	n.metrics = &NetemMetrics{
+		Interface: iface,
+		Rule:      strings.Join(n.netemArgs(), " "),
+		Applied:   true,
+	}
+	n.mu.Unlock()
+
+	return nil
+}
+
+// rollback removes the qdisc if this plugin installed it. It is safe to call repeatedly.
+func (n *NetworkNetemPlugin) rollback() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.applied {
+		return nil
+	}
+
+	err := deleteNetemQdisc(n.config.Interface)
+	if err != nil {
+		// Leave the marker and watchdog in place so the qdisc is still removed later
+		return err
+	}
+
+	stopNetemWatchdog(n.watchdog)
+	statefile.Remove(netemMarkerPath(n.config.Interface))
+
+	n.applied = false
+	n.watchdog = nil
+	n.metrics.Applied = false
+	return nil
+}
+
+// collectMetrics samples the qdisc counters once a second
+func (n *NetworkNetemPlugin) collectMetrics(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	var lastPackets int64
+	lastSample := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			bytes, packets, dropped, err := netemStats(n.config.Interface)
+			if err != nil {
+				continue
+			}
+
+			n.mu.Lock()
+			n.metrics.BytesSent = bytes
+			n.metrics.PacketsSent = packets
+			n.metrics.PacketsDropped = dropped
+			if elapsed := now.Sub(lastSample).Seconds(); elapsed > 0 {
+				n.metrics.PacketsPerSec = int64(float64(packets-lastPackets) / elapsed)
+			}
+			if total := packets + dropped; total > 0 {
+				n.metrics.DropPercent = float64(dropped) / float64(total) * 100
+			}
+			n.mu.Unlock()
+
+			lastPackets = packets
+			lastSample = now
+		}
+	}
+}
+
+// Cleanup removes the qdisc if it is still installed
+func (n *NetworkNetemPlugin) Cleanup() error {
+	if err := n.rollback(); err != nil {
+		return fmt.Errorf("failed to remove netem qdisc from %s, the watchdog will retry: %w", n.config.Interface, err)
+	}
+	return nil
+}
+
+// GetMetrics returns current metrics
+func (n *NetworkNetemPlugin) GetMetrics() map[string]interface{} {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return map[string]interface{}{
+		"interface":       n.metrics.Interface,
+		"rule":            n.metrics.Rule,
+		"applied":         n.metrics.Applied,
+		"bytes_sent":      n.metrics.BytesSent,
+		"packets_sent":    n.metrics.PacketsSent,
+		"packets_dropped": n.metrics.PacketsDropped,
+		"packets_per_sec": n.metrics.PacketsPerSec,
+		"drop_percent":    n.metrics.DropPercent,
+	}
+}
+
+// GetSafetyLimits returns safety limits for network degradation
+func (n *NetworkNetemPlugin) GetSafetyLimits() models.SafetyLimits {
+	return models.SafetyLimits{
+		MaxCPUPercent:    90.0,
+		MaxMemoryPercent: 90.0,
+		MaxDiskPercent:   90.0,
+		MaxNetworkMbps:   1000.0,
+	}
+}
+
+// HealthCheck verifies tc is available and the process may change qdiscs
+func (n *NetworkNetemPlugin) HealthCheck() error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("netem is only available on Linux")
+	}
+	if _, err := exec.LookPath("tc"); err != nil {
+		return fmt.Errorf("tc not found, install iproute2: %w", err)
+	}
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("changing qdiscs requires root or CAP_NET_ADMIN")
+	}
+
+	if stale := staleNetemMarkers(); len(stale) > 0 {
+		return fmt.Errorf("netem rollback pending for %d interface(s) left by an exited process", len(stale))
+	}
+	return nil
+}
+
+// rootQdisc returns the kind and handle of an interface's root qdisc
+func rootQdisc(iface string) (string, string, error) {
+	output, err := tcCommand("qdisc", "show", "dev", iface, "root")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read qdisc for %s: %w: %s", iface, err, strings.TrimSpace(string(output)))
+	}
+
+	// e.g. "qdisc fq_codel 0: root refcnt 2 limit 10240p ..."
+	fields := strings.Fields(string(output))
+	if len(fields) < 3 || fields[0] != "qdisc" {
+		return "", "", fmt.Errorf("unexpected tc output for %s: %q", iface, strings.TrimSpace(string(output)))
+	}
+	return fields[1], fields[2], nil
+}
+
+// deleteNetemQdisc removes our root qdisc, restoring the kernel default. Passing the
+// handle makes the kernel refuse to delete a root qdisc someone else installed.
+func deleteNetemQdisc(iface string) error {
+	kind, handle, err := rootQdisc(iface)
+	if err != nil {
+		if _, lookupErr := net.InterfaceByName(iface); lookupErr != nil {
+			// The interface is gone, and the qdisc with it
+			return nil
+		}
+		return err
+	}
+	if kind != "netem" || handle != netemHandle {
+		return nil
+	}
+
+	output, err := tcCommand("qdisc", "del", "dev", iface, "root", "handle", netemHandle)
+	if err != nil {
+		return fmt.Errorf("tc qdisc del: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// netemStats reads the byte, packet and drop counters of our qdisc
+func netemStats(iface string) (int64, int64, int64, error) {
+	output, err := tcCommand("-s", "qdisc", "show", "dev", iface, "root")
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	match := netemStatsPattern.FindStringSubmatch(string(output))
+	if match == nil {
+		return 0, 0, 0, fmt.Errorf("qdisc statistics not found")
+	}
+
+	bytes, _ := strconv.ParseInt(match[1], 10, 64)
+	packets, _ := strconv.ParseInt(match[2], 10, 64)
+	dropped, _ := strconv.ParseInt(match[3], 10, 64)
+	return bytes, packets, dropped, nil
+}
+
+// checkInterface verifies iface names an existing network interface
+func checkInterface(iface string) error {
+	if !interfaceNamePattern.MatchString(iface) {
+		return fmt.Errorf("invalid interface name %q", iface)
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return fmt.Errorf("failed to list interfaces: %w", err)
+	}
+	for _, candidate := range interfaces {
+		if candidate.Name == iface {
+			return nil
+		}
+	}
+	return fmt.Errorf("interface %s not found", iface)
+}
+
+// netemMarkerPath is where the crash marker for an interface is kept. Callers
+// pass only interface names checked against interfaceNamePattern.
+func netemMarkerPath(iface string) string {
+	return statefile.Path("netem-" + iface + ".json")
+}
+
+func writeNetemMarker(marker netemMarker) error {
+	data, err := json.Marshal(marker)
+	if err != nil {
+		return err
+	}
+	return statefile.Write(netemMarkerPath(marker.Interface), data)
+}
+
+// staleNetemMarkers returns the markers, keyed by path, whose owning process has exited
+func staleNetemMarkers() map[string]netemMarker {
+	matches, err := filepath.Glob(statefile.Path("netem-*.json"))
+	if err != nil {
+		return nil
+	}
+
+	stale := make(map[string]netemMarker)
+	for _, path := range matches {
+		data, err := statefile.Read(path)
+		if err != nil {
+			continue
+		}
+
+		// Only trust a marker for the interface its name says it is for
+		var marker netemMarker
+		if err := json.Unmarshal(data, &marker); err != nil || !interfaceNamePattern.MatchString(marker.Interface) || netemMarkerPath(marker.Interface) != path {
+			continue
+		}
+
+		if marker.PID == os.Getpid() || statefile.ProcessAlive(marker.PID) {
+			continue
+		}
+		stale[path] = marker
+	}
+	return stale
+}
+
+// recoverStaleNetem rolls back qdiscs recorded by markers whose owning process has exited
+func recoverStaleNetem() {
+	for path, marker := range staleNetemMarkers() {
+		if err := deleteNetemQdisc(marker.Interface); err == nil {
+			statefile.Remove(path)
+		}
+	}
+}
+
+// defaultRouteInterface returns the interface used by the IPv4 default route
+func defaultRouteInterface() (string, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 1 && fields[1] == "00000000" {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no default route")
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func formatMs(ms float64) string {
+	return strconv.FormatFloat(ms, 'f', -1, 64) + "ms"
+}
+
+func formatPercent(percent float64) string {
+	return strconv.FormatFloat(percent, 'f', -1, 64) + "%"
+}
@@ -0,0 +1,238 @@
+//go:build !windows
+
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/statefile"
+)
+
+// fakeTC stands in for tc, tracking the root qdisc of every interface
+type fakeTC struct {
+	qdisc      string // root qdisc kind and handle, e.g. "fq_codel 0:"
+	failDelete bool
+	calls      [][]string
+}
+
+func (f *fakeTC) run(args ...string) ([]byte, error) {
+	f.calls = append(f.calls, args)
+	switch {
+	case args[0] == "-s":
+		return []byte("qdisc " + f.qdisc + " root refcnt 2\n Sent 1500 bytes 10 pkt (dropped 2, overlimits 0 requeues 0)\n"), nil
+	case args[1] == "show":
+		return []byte("qdisc " + f.qdisc + " root refcnt 2 limit 1000\n"), nil
+	case args[1] == "add":
+		f.qdisc = "netem " + netemHandle
+	case args[1] == "del":
+		if f.failDelete {
+			return []byte("RTNETLINK answers: Device or resource busy"), errors.New("exit status 2")
+		}
+		f.qdisc = "fq_codel 0:"
+	}
+	return nil, nil
+}
+
+// deletes returns the qdisc deletions tc was asked for
+func (f *fakeTC) deletes() [][]string {
+	var deletes [][]string
+	for _, call := range f.calls {
+		if len(call) > 1 && call[1] == "del" {
+			deletes = append(deletes, call)
+		}
+	}
+	return deletes
+}
+
+// useFakeTC replaces tc and the state directory for the duration of a test
+func useFakeTC(t *testing.T) *fakeTC {
+	t.Helper()
+	fake := &fakeTC{qdisc: "fq_codel 0:"}
+
+	run, dir := tcCommand, statefile.Dir
+	t.Cleanup(func() { tcCommand, statefile.Dir = run, dir })
+	tcCommand = fake.run
+	statefile.Dir = t.TempDir()
+	return fake
+}
+
+// testInterface returns the name of an interface that exists on this machine
+func testInterface(t *testing.T) string {
+	t.Helper()
+	interfaces, err := net.Interfaces()
+	if err != nil || len(interfaces) == 0 {
+		t.Skip("no network interfaces")
+	}
+	return interfaces[0].Name
+}
+
+func TestNetworkNetemRejectsInvalidConfig(t *testing.T) {
+	useFakeTC(t)
+	iface := testInterface(t)
+
+	tests := map[string]map[string]interface{}{
+		"unknown interface":       {"interface": "nosuchif0"},
+		"path in interface":       {"interface": "../../etc/x"},
+		"option as interface":     {"interface": "-force"},
+		"shell in interface":      {"interface": "lo;reboot"},
+		"interface too long":      {"interface": strings.Repeat("e", 16)},
+		"negative delay":          {"interface": iface, "delay_ms": -1},
+		"loss over 100":           {"interface": iface, "loss_percent": 101},
+		"reorder without delay":   {"interface": iface, "delay_ms": 0, "reorder_percent": 10},
+		"distribution no jitter":  {"interface": iface, "distribution": "normal"},
+		"unknown distribution":    {"interface": iface, "jitter_ms": 5, "distribution": "zipf"},
+		"negative duplicate rate": {"interface": iface, "duplicate_percent": -5},
+	}
+	for name, config := range tests {
+		if err := NewNetworkNetemPlugin().Initialize(config); err == nil {
+			t.Errorf("%s: Initialize accepted %v", name, config)
+		}
+	}
+
+	if err := NewNetworkNetemPlugin().Initialize(map[string]interface{}{"interface": iface, "jitter_ms": 5, "distribution": "pareto"}); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+}
+
+func TestNetworkNetemArgs(t *testing.T) {
+	tests := []struct {
+		config NetworkNetemConfig
+		want   string
+	}{
+		{NetworkNetemConfig{DelayMs: 100, Distribution: "uniform"}, "netem delay 100ms"},
+		{NetworkNetemConfig{DelayMs: 50, JitterMs: 10, Correlation: 25, Distribution: "normal"}, "netem delay 50ms 10ms 25% distribution normal"},
+		{NetworkNetemConfig{LossPercent: 0.5, DuplicatePercent: 1, Distribution: "uniform"}, "netem loss 0.5% duplicate 1%"},
+		{NetworkNetemConfig{DelayMs: 20, ReorderPercent: 30, Distribution: "uniform"}, "netem delay 20ms reorder 30%"},
+	}
+	for _, tt := range tests {
+		plugin := &NetworkNetemPlugin{config: tt.config}
+		if got := strings.Join(plugin.netemArgs(), " "); got != tt.want {
+			t.Errorf("netemArgs(%+v) = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestNetworkNetemWatchdogCommand(t *testing.T) {
+	defer func(dir string) { statefile.Dir = dir }(statefile.Dir)
+	statefile.Dir = "/run/ssts"
+
+	cmd := netemWatchdogCommand("eth0", "abc123", 90*time.Second)
+	want := []string{"sh", "-c", netemWatchdogScript, "ssts-netem-watchdog", "90", "abc123", "/run/ssts/netem-eth0.json", "eth0", netemHandle}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("watchdog args = %q, want %q", cmd.Args, want)
+	}
+	// The script never has values spliced into it
+	for _, value := range []string{"eth0", "abc123", "90"} {
+		if strings.Contains(netemWatchdogScript, value) {
+			t.Errorf("watchdog script contains %q", value)
+		}
+	}
+	if cmd.SysProcAttr == nil || !cmd.SysProcAttr.Setsid {
+		t.Error("watchdog doesn't run in its own session")
+	}
+}
+
+func TestNetworkNetemApplyAndCleanup(t *testing.T) {
+	fake := useFakeTC(t)
+	iface := testInterface(t)
+
+	plugin := NewNetworkNetemPlugin()
+	if err := plugin.Initialize(map[string]interface{}{"interface": iface, "delay_ms": 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugin.apply(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(netemMarkerPath(iface)); err != nil {
+		t.Errorf("no rollback marker while applied: %v", err)
+	}
+	if !plugin.GetMetrics()["applied"].(bool) {
+		t.Error("metrics don't report the qdisc as applied")
+	}
+
+	// A second run on the same interface is refused rather than stacking qdiscs
+	other := NewNetworkNetemPlugin()
+	other.Initialize(map[string]interface{}{"interface": iface})
+	if err := other.apply(time.Minute); err == nil {
+		t.Error("applying twice to the same interface succeeded")
+	}
+
+	// A failed removal keeps the marker so the qdisc is removed later
+	fake.failDelete = true
+	if err := plugin.Cleanup(); err == nil {
+		t.Error("Cleanup reported success although tc failed")
+	}
+	if _, err := os.Stat(netemMarkerPath(iface)); err != nil {
+		t.Errorf("marker removed although the qdisc is still installed: %v", err)
+	}
+
+	fake.failDelete = false
+	if err := plugin.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(netemMarkerPath(iface)); !os.IsNotExist(err) {
+		t.Errorf("marker left after cleanup: %v", err)
+	}
+	if err := plugin.Cleanup(); err != nil {
+		t.Errorf("second Cleanup: %v", err)
+	}
+	if n := len(fake.deletes()); n != 2 {
+		t.Errorf("tc deleted the qdisc %d times, want 2", n)
+	}
+}
+
+func TestNetworkNetemRefusesCustomQdisc(t *testing.T) {
+	fake := useFakeTC(t)
+	fake.qdisc = "htb 1:"
+	iface := testInterface(t)
+
+	plugin := NewNetworkNetemPlugin()
+	plugin.Initialize(map[string]interface{}{"interface": iface})
+	if err := plugin.apply(time.Minute); err == nil {
+		t.Fatal("apply replaced a custom root qdisc")
+	}
+	if _, err := os.Stat(netemMarkerPath(iface)); !os.IsNotExist(err) {
+		t.Errorf("marker written although nothing was applied: %v", err)
+	}
+}
+
+func TestNetworkNetemRecoversStaleMarkers(t *testing.T) {
+	fake := useFakeTC(t)
+	fake.qdisc = "netem " + netemHandle
+
+	write := func(path string, marker netemMarker) {
+		data, _ := json.Marshal(marker)
+		if err := statefile.Write(path, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dead := 1 << 30
+	write(netemMarkerPath("eth9"), netemMarker{Interface: "eth9", PID: dead, Token: "t"})
+	write(netemMarkerPath("eth8"), netemMarker{Interface: "eth8", PID: os.Getppid(), Token: "t"})
+	// Markers naming another interface than their file, or an invalid one, are ignored
+	write(netemMarkerPath("eth7"), netemMarker{Interface: "eth6", PID: dead, Token: "t"})
+	write(netemMarkerPath("bad"), netemMarker{Interface: "-dev", PID: dead, Token: "t"})
+
+	if stale := staleNetemMarkers(); len(stale) != 1 {
+		t.Fatalf("stale markers = %+v, want only eth9's", stale)
+	}
+
+	recoverStaleNetem()
+	deletes := fake.deletes()
+	if len(deletes) != 1 || deletes[0][3] != "eth9" {
+		t.Errorf("tc deletions = %q, want one for eth9", deletes)
+	}
+	if _, err := os.Stat(netemMarkerPath("eth9")); !os.IsNotExist(err) {
+		t.Errorf("recovered marker left behind: %v", err)
+	}
+	if _, err := os.Stat(netemMarkerPath("eth8")); err != nil {
+		t.Errorf("marker of a live process removed: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package plugins
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// netemWatchdogScript removes the qdisc after a delay if the marker still carries
+// the token. It is fixed text; the values it acts on are passed as arguments:
+// $1 delay in seconds, $2 token, $3 marker path, $4 interface, $5 qdisc handle.
+const netemWatchdogScript = `sleep "$1"; if grep -qF -- "$2" "$3" 2>/dev/null; then tc qdisc del dev "$4" root handle "$5"; rm -f -- "$3"; fi`
+
+// netemWatchdogCommand builds the watchdog for an interface
+func netemWatchdogCommand(iface, token string, after time.Duration) *exec.Cmd {
+	cmd := exec.Command("sh", "-c", netemWatchdogScript, "ssts-netem-watchdog",
+		strconv.Itoa(int(after.Seconds())), token, netemMarkerPath(iface), iface, netemHandle)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return cmd
+}
+
+// startNetemWatchdog starts a detached shell that removes the qdisc after the
+// deadline if the marker still carries our token. It runs in its own session so it
+// outlives this process if we are killed.
+func startNetemWatchdog(iface, token string, after time.Duration) (*exec.Cmd, error) {
+	cmd := netemWatchdogCommand(iface, token, after)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// Reap the watchdog whenever it exits so it doesn't linger as a zombie
+	go cmd.Wait()
+
+	return cmd, nil
+}
+
+func stopNetemWatchdog(cmd *exec.Cmd) {
+	if cmd != nil && cmd.Process != nil {
+		// Kill the whole session so the pending sleep goes too
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
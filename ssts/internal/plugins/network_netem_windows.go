@@ -0,0 +1,16 @@
+//go:build windows
+
+package plugins
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// startNetemWatchdog is unavailable; netem only exists on Linux
+func startNetemWatchdog(iface, token string, after time.Duration) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("netem is only available on Linux")
+}
+
+func stopNetemWatchdog(cmd *exec.Cmd) {}
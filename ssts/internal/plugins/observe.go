@@ -0,0 +1,215 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// ObserveConfig defines configuration for observing the system
+type ObserveConfig struct {
+	SampleInterval string `json:"sample_interval"` // How often the system is sampled, e.g. 1s
+}
+
+// ObservePlugin generates no load. It records what the system does on its own for
+// the test duration, giving an idle baseline that stress results can be
+// normalized against, e.g. to subtract background CPU usage.
+type ObservePlugin struct {
+	config   ObserveConfig
+	interval time.Duration
+	mu       sync.RWMutex
+	cpu      baselineStat
+	memory   baselineStat
+	load1    baselineStat
+	samples  int64
+}
+
+// baselineStat accumulates the samples of one measurement
+type baselineStat struct {
+	current float64
+	sum     float64
+	min     float64
+	max     float64
+	count   int64
+}
+
+// add records a sample
+func (s *baselineStat) add(value float64) {
+	if s.count == 0 || value < s.min {
+		s.min = value
+	}
+	if s.count == 0 || value > s.max {
+		s.max = value
+	}
+	s.current = value
+	s.sum += value
+	s.count++
+}
+
+// mean returns the average of the samples, or 0 without any
+func (s *baselineStat) mean() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+// NewObservePlugin creates a new observe plugin
+func NewObservePlugin() *ObservePlugin {
+	return &ObservePlugin{}
+}
+
+// Name returns the plugin name
+func (o *ObservePlugin) Name() string {
+	return "observe"
+}
+
+// Version returns the plugin version
+func (o *ObservePlugin) Version() string {
+	return "1.0.0"
+}
+
+// Description returns the plugin description
+func (o *ObservePlugin) Description() string {
+	return "Idle plugin generating no load, recording baseline system metrics to normalize stress results against"
+}
+
+// ConfigSchema returns the JSON schema for configuration
+func (o *ObservePlugin) ConfigSchema() []byte {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"sample_interval": {
+				"type": "string",
+				"default": "1s",
+				"description": "How often CPU, memory and load are sampled (at least 100ms)"
+			}
+		}
+	}`
+	return []byte(schema)
+}
+
+// Initialize initializes the plugin with configuration
+func (o *ObservePlugin) Initialize(config interface{}) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	o.config = ObserveConfig{SampleInterval: "1s"}
+	if err := json.Unmarshal(configBytes, &o.config); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	interval, err := time.ParseDuration(o.config.SampleInterval)
+	if err != nil {
+		return fmt.Errorf("invalid sample_interval: %w", err)
+	}
+	if interval < 100*time.Millisecond {
+		return fmt.Errorf("sample_interval must be at least 100ms")
+	}
+	o.interval = interval
+
+	return nil
+}
+
+// Execute samples the system until the test ends, without generating any load
+func (o *ObservePlugin) Execute(ctx context.Context, params models.TestParams) error {
+	o.mu.Lock()
+	o.cpu, o.memory, o.load1 = baselineStat{}, baselineStat{}, baselineStat{}
+	o.samples = 0
+	o.mu.Unlock()
+
+	// Prime the CPU counters so the first sample covers one interval
+	cpu.Percent(0, false)
+
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			o.sample()
+		}
+	}
+}
+
+// FollowsDeadline reports that sampling runs until the context ends
+func (o *ObservePlugin) FollowsDeadline() bool {
+	return true
+}
+
+// sample records the CPU usage since the last sample and current memory and load
+func (o *ObservePlugin) sample() {
+	cpuPercents, cpuErr := cpu.Percent(0, false)
+	memStat, memErr := mem.VirtualMemory()
+	loadStat, loadErr := load.Avg()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if cpuErr == nil && len(cpuPercents) > 0 {
+		o.cpu.add(cpuPercents[0])
+	}
+	if memErr == nil {
+		o.memory.add(memStat.UsedPercent)
+	}
+	if loadErr == nil {
+		o.load1.add(loadStat.Load1)
+	}
+	o.samples++
+}
+
+// Cleanup has nothing to release
+func (o *ObservePlugin) Cleanup() error {
+	return nil
+}
+
+// GetMetrics returns the latest sample and the baseline statistics so far
+func (o *ObservePlugin) GetMetrics() map[string]interface{} {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	metrics := map[string]interface{}{"samples": o.samples}
+	for name, stat := range map[string]baselineStat{
+		"cpu_percent":    o.cpu,
+		"memory_percent": o.memory,
+		"load1":          o.load1,
+	} {
+		metrics[name] = stat.current
+		metrics[name+"_mean"] = stat.mean()
+		metrics[name+"_min"] = stat.min
+		metrics[name+"_max"] = stat.max
+	}
+	return metrics
+}
+
+// GetSafetyLimits returns safety limits for observing. The plugin adds no load,
+// so whatever the system is doing is the baseline being measured and shouldn't
+// stop the run.
+func (o *ObservePlugin) GetSafetyLimits() models.SafetyLimits {
+	return models.SafetyLimits{
+		MaxCPUPercent:    100.0,
+		MaxMemoryPercent: 100.0,
+		MaxDiskPercent:   100.0,
+		MaxNetworkMbps:   100000.0,
+	}
+}
+
+// HealthCheck verifies the system can be sampled
+func (o *ObservePlugin) HealthCheck() error {
+	if _, err := mem.VirtualMemory(); err != nil {
+		return fmt.Errorf("failed to read memory usage: %w", err)
+	}
+	return nil
+}
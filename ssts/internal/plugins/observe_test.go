@@ -0,0 +1,35 @@
+package plugins
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestObserveRecordsBaseline(t *testing.T) {
+	plugin := NewObservePlugin()
+	if err := plugin.Initialize(map[string]interface{}{"sample_interval": "100ms"}); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 550*time.Millisecond)
+	defer cancel()
+	plugin.Execute(ctx, models.TestParams{Duration: 550 * time.Millisecond})
+
+	metrics := plugin.GetMetrics()
+	if samples := metrics["samples"].(int64); samples < 3 {
+		t.Fatalf("took %d samples in 550ms at 100ms intervals", samples)
+	}
+	mean, min, max := metrics["memory_percent_mean"].(float64), metrics["memory_percent_min"].(float64), metrics["memory_percent_max"].(float64)
+	if mean <= 0 || min > mean || mean > max {
+		t.Errorf("memory baseline min %.1f, mean %.1f, max %.1f", min, mean, max)
+	}
+}
+
+func TestObserveRejectsShortInterval(t *testing.T) {
+	if err := NewObservePlugin().Initialize(map[string]interface{}{"sample_interval": "1ms"}); err == nil {
+		t.Error("Initialize accepted a 1ms sample interval")
+	}
+}
@@ -0,0 +1,16 @@
+package plugins
+
+// Preflighter is implemented by plugins with requirements the machine must meet
+// before they run, such as free space for test files. Preflight is called with the
+// test's plugin configuration before the execution starts and must not generate
+// any load; it returns an error describing the first unmet requirement.
+type Preflighter interface {
+	Preflight(config interface{}) error
+}
+
+// ConfigWarner is implemented by plugins that can run with a configuration but
+// find something wrong with it, such as test files placed on tmpfs. ConfigWarnings
+// returns the problems found by the last call to Initialize.
+type ConfigWarner interface {
+	ConfigWarnings() []string
+}
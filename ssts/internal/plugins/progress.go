@@ -0,0 +1,11 @@
+package plugins
+
+// ProgressReporter is implemented by plugins that know how far their run has got
+// better than the elapsed share of its duration, e.g. because it ends on a write
+// budget or ramps its load up and down. Progress returns the percentage of the
+// workload done and the current phase, one of the models.Phase constants. A
+// negative percent or empty phase leaves the orchestrator to estimate that part
+// from the elapsed time.
+type ProgressReporter interface {
+	Progress() (percent float64, phase string)
+}
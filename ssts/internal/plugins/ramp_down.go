@@ -0,0 +1,8 @@
+package plugins
+
+// RampDowner is implemented by plugins that lower their load over the final
+// params.RampDown of a run. Only runs of plugins that ramp down may ask for a
+// ramp-down window.
+type RampDowner interface {
+	RampsDown() bool
+}
@@ -0,0 +1,47 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Resources plugins claim
+const (
+	ResourceMemory       = "memory"  // bytes of RAM
+	ResourceDevicePrefix = "device:" // a storage device, followed by its key as given by deviceForPath
+)
+
+// ResourceClaim is a resource an execution uses while it runs. A claim either
+// needs the resource to itself, or uses an amount of a resource with a fixed
+// capacity that concurrent executions share.
+type ResourceClaim struct {
+	Resource  string  `json:"resource"`           // e.g. memory, device:S4EWNX0R123456
+	Exclusive bool    `json:"exclusive"`          // no other execution may claim the resource at the same time
+	Amount    float64 `json:"amount,omitempty"`   // how much of the resource's capacity is used
+	Capacity  float64 `json:"capacity,omitempty"` // what the amounts of concurrent claims may add up to; 0 for no limit
+}
+
+func (c ResourceClaim) String() string {
+	if c.Exclusive {
+		return c.Resource + " (exclusive)"
+	}
+	return fmt.Sprintf("%s (%.0f of %.0f)", c.Resource, c.Amount, c.Capacity)
+}
+
+// ResourceClaimer is implemented by plugins whose executions would corrupt or
+// starve each other if they ran at the same time, such as two I/O tests on one
+// device. ResourceClaims is called with the test's plugin configuration before
+// the execution starts and must not generate any load.
+type ResourceClaimer interface {
+	ResourceClaims(config interface{}) ([]ResourceClaim, error)
+}
+
+// DeviceClaim is an exclusive claim on the device a path lives on
+func DeviceClaim(path string) ResourceClaim {
+	return ResourceClaim{Resource: ResourceDevicePrefix + deviceForPath(path), Exclusive: true}
+}
+
+// IsDeviceClaim reports whether a claim is on a storage device
+func IsDeviceClaim(claim ResourceClaim) bool {
+	return strings.HasPrefix(claim.Resource, ResourceDevicePrefix)
+}
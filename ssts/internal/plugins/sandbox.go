@@ -0,0 +1,107 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// Sandbox modes
+const (
+	SandboxModeSetuid = "setuid" // switch to the sandbox user
+	SandboxModeUserns = "userns" // run as root of a new user namespace mapped to the sandbox user
+)
+
+// errSandboxUnsupported is returned where the sandbox can't be used
+var errSandboxUnsupported = errors.New("the plugin sandbox is only available on Linux")
+
+// sandboxEnvPassed are the variables of the server's environment sandboxed
+// plugins are given. The rest are withheld, since they may hold credentials such
+// as the master key or database passwords.
+var sandboxEnvPassed = []string{"PATH", "TMPDIR", "LANG", "LC_ALL", "TZ"}
+
+// sandboxDefaultPath is the PATH of sandboxed plugins when the server has none
+const sandboxDefaultPath = "/usr/local/bin:/usr/bin:/bin"
+
+// Sandbox confines external plugin processes: they run as an unprivileged user,
+// optionally inside a user namespace, and optionally under a seccomp filter
+// denying system calls a stress test has no business making. They don't inherit
+// the server's environment.
+type Sandbox struct {
+	UID           uint32
+	GID           uint32
+	Home          string // the sandbox user's home directory
+	UserNamespace bool
+	Seccomp       bool
+}
+
+// ResolveSandbox looks up the user and group plugin processes run as. An empty
+// group means the user's primary group.
+func ResolveSandbox(userName, groupName, mode string, seccomp bool) (*Sandbox, error) {
+	if !sandboxSupported {
+		return nil, errSandboxUnsupported
+	}
+	if mode != SandboxModeSetuid && mode != SandboxModeUserns {
+		return nil, fmt.Errorf("invalid sandbox mode %q", mode)
+	}
+	if seccomp && !seccompSupported {
+		return nil, fmt.Errorf("seccomp filtering isn't available on this architecture")
+	}
+
+	account, err := user.Lookup(userName)
+	if err != nil {
+		account, err = user.LookupId(userName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unknown sandbox user %q: %w", userName, err)
+	}
+	uid, err := strconv.ParseUint(account.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox user %q has no numeric uid", userName)
+	}
+
+	gidString := account.Gid
+	if groupName != "" {
+		group, err := user.LookupGroup(groupName)
+		if err != nil {
+			group, err = user.LookupGroupId(groupName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unknown sandbox group %q: %w", groupName, err)
+		}
+		gidString = group.Gid
+	}
+	gid, err := strconv.ParseUint(gidString, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox group %q has no numeric gid", gidString)
+	}
+
+	return &Sandbox{
+		UID:           uint32(uid),
+		GID:           uint32(gid),
+		Home:          account.HomeDir,
+		UserNamespace: mode == SandboxModeUserns,
+		Seccomp:       seccomp,
+	}, nil
+}
+
+// environment returns the environment a sandboxed plugin starts from: the
+// variables in sandboxEnvPassed and the sandbox user's HOME, to which the run's
+// SSTS_* parameters are added
+func (s *Sandbox) environment() []string {
+	home := s.Home
+	if home == "" {
+		home = "/"
+	}
+	env := []string{"HOME=" + home}
+	for _, name := range sandboxEnvPassed {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		} else if name == "PATH" {
+			env = append(env, "PATH="+sandboxDefaultPath)
+		}
+	}
+	return env
+}
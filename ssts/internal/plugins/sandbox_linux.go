@@ -0,0 +1,161 @@
+//go:build linux
+
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The sandbox relies on Linux credentials, user namespaces and seccomp
+const (
+	sandboxSupported = true
+	seccompSupported = seccompArch != 0
+)
+
+// sandboxExecEnv marks a process started to install the seccomp filter on
+// itself and then exec the plugin named by its first argument. It is removed
+// before the plugin runs.
+const sandboxExecEnv = "SSTS_SANDBOX_EXEC"
+
+// Seccomp filter return actions from linux/seccomp.h
+const (
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+)
+
+// seccompX32Bit is set in the numbers of x32 system calls, which would otherwise
+// get past a filter written for x86-64 numbers
+const seccompX32Bit = 0x40000000
+
+// seccompDenied are the system calls the filter fails with EPERM: changing mounts,
+// namespaces, the kernel, the clock or other processes, and kernel interfaces
+// with a history of privilege escalations
+var seccompDenied = []uintptr{
+	unix.SYS_MOUNT, unix.SYS_UMOUNT2, unix.SYS_PIVOT_ROOT, unix.SYS_CHROOT,
+	unix.SYS_FSOPEN, unix.SYS_FSCONFIG, unix.SYS_FSMOUNT, unix.SYS_FSPICK, unix.SYS_MOVE_MOUNT, unix.SYS_OPEN_TREE,
+	unix.SYS_UNSHARE, unix.SYS_SETNS,
+	unix.SYS_INIT_MODULE, unix.SYS_FINIT_MODULE, unix.SYS_DELETE_MODULE, unix.SYS_KEXEC_LOAD,
+	unix.SYS_REBOOT, unix.SYS_SWAPON, unix.SYS_SWAPOFF, unix.SYS_ACCT, unix.SYS_QUOTACTL,
+	unix.SYS_SETHOSTNAME, unix.SYS_SETDOMAINNAME, unix.SYS_VHANGUP, unix.SYS_SYSLOG,
+	unix.SYS_SETTIMEOFDAY, unix.SYS_CLOCK_SETTIME, unix.SYS_CLOCK_ADJTIME, unix.SYS_ADJTIMEX,
+	unix.SYS_PTRACE, unix.SYS_PROCESS_VM_READV, unix.SYS_PROCESS_VM_WRITEV, unix.SYS_KCMP,
+	unix.SYS_BPF, unix.SYS_PERF_EVENT_OPEN, unix.SYS_USERFAULTFD, unix.SYS_LOOKUP_DCOOKIE,
+	unix.SYS_KEYCTL, unix.SYS_ADD_KEY, unix.SYS_REQUEST_KEY,
+	unix.SYS_OPEN_BY_HANDLE_AT, unix.SYS_NAME_TO_HANDLE_AT,
+}
+
+func init() {
+	if os.Getenv(sandboxExecEnv) != "" {
+		sandboxExec()
+	}
+}
+
+// apply confines the plugin process cmd will start. With seccomp, cmd runs the
+// server's own executable, which installs the filter and then execs the plugin,
+// since Go can't install one between fork and exec.
+func (s *Sandbox) apply(cmd *exec.Cmd) error {
+	attr := cmd.SysProcAttr
+	if attr == nil {
+		attr = &syscall.SysProcAttr{}
+		cmd.SysProcAttr = attr
+	}
+
+	if s.UserNamespace {
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: int(s.UID), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: int(s.GID), Size: 1}}
+	} else {
+		// No supplementary groups, so none of the server's carry over
+		attr.Credential = &syscall.Credential{Uid: s.UID, Gid: s.GID, Groups: []uint32{}}
+	}
+
+	if s.Seccomp {
+		self, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find the sandbox launcher: %w", err)
+		}
+		cmd.Args = append([]string{self, cmd.Path}, cmd.Args[1:]...)
+		cmd.Path = self
+		// The launcher is the server's executable, but it runs with no more of
+		// the server's environment than the plugin is given
+		if cmd.Env == nil {
+			cmd.Env = s.environment()
+		}
+		cmd.Env = append(cmd.Env, sandboxExecEnv+"=1")
+	}
+	return nil
+}
+
+// sandboxExec installs the seccomp filter on this process and replaces it with
+// the plugin. It never returns.
+func sandboxExec() {
+	// Filters and no_new_privs apply to the calling thread, which exec keeps
+	runtime.LockOSThread()
+	os.Unsetenv(sandboxExecEnv)
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "ssts sandbox: no plugin to run")
+		os.Exit(126)
+	}
+	if err := installSeccomp(); err != nil {
+		fmt.Fprintf(os.Stderr, "ssts sandbox: %v\n", err)
+		os.Exit(126)
+	}
+	err := syscall.Exec(os.Args[1], os.Args[1:], os.Environ())
+	fmt.Fprintf(os.Stderr, "ssts sandbox: failed to run %s: %v\n", os.Args[1], err)
+	os.Exit(127)
+}
+
+// installSeccomp sets no_new_privs, so the plugin can't regain privileges through
+// setuid binaries, and installs the filter denying seccompDenied
+func installSeccomp() error {
+	if !seccompSupported {
+		return fmt.Errorf("seccomp filtering isn't available on this architecture")
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+
+	filter := seccompFilter()
+	program := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&program)), 0, 0); err != nil {
+		return fmt.Errorf("failed to install seccomp filter: %w", err)
+	}
+	return nil
+}
+
+// seccompFilter builds the BPF program: system calls from another architecture
+// kill the process, and those in seccompDenied, or x32 ones, fail with EPERM
+func seccompFilter() []unix.SockFilter {
+	const (
+		archOffset = 4 // of struct seccomp_data
+		nrOffset   = 0
+	)
+	deny := uint8(len(seccompDenied) + 1) // jumps from the x32 check to the deny return
+	filter := []unix.SockFilter{
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: archOffset},
+		{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, Jt: 1, K: seccompArch},
+		{Code: unix.BPF_RET | unix.BPF_K, K: seccompRetKillProcess},
+		{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: nrOffset},
+		{Code: unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K, Jt: deny, K: seccompX32Bit},
+	}
+	for idx, nr := range seccompDenied {
+		filter = append(filter, unix.SockFilter{
+			Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K,
+			Jt:   deny - uint8(idx) - 1,
+			K:    uint32(nr),
+		})
+	}
+	return append(filter,
+		unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: seccompRetAllow},
+		unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: seccompRetErrno | uint32(unix.EPERM)},
+	)
+}
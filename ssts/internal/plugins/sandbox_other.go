@@ -0,0 +1,15 @@
+//go:build !linux
+
+package plugins
+
+import "os/exec"
+
+// The sandbox relies on Linux credentials, user namespaces and seccomp
+const (
+	sandboxSupported = false
+	seccompSupported = false
+)
+
+func (s *Sandbox) apply(cmd *exec.Cmd) error {
+	return errSandboxUnsupported
+}
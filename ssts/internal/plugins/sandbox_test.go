@@ -0,0 +1,66 @@
+//go:build linux
+
+package plugins
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestSandboxedExternalPlugin(t *testing.T) {
+	if _, err := ResolveSandbox("no-such-user-ssts", "", SandboxModeSetuid, true); err == nil {
+		t.Error("ResolveSandbox accepted an unknown user")
+	}
+	if _, err := ResolveSandbox(strconv.Itoa(os.Getuid()), "", "chroot", true); err == nil {
+		t.Error("ResolveSandbox accepted an unknown mode")
+	}
+	sandbox, err := ResolveSandbox(strconv.Itoa(os.Getuid()), strconv.Itoa(os.Getgid()), SandboxModeSetuid, true)
+	if err != nil {
+		t.Skipf("no sandbox user: %v", err)
+	}
+
+	// The plugin fails unless the launcher installed the filter before running it
+	script := []byte(`#!/bin/sh
+grep -q '^NoNewPrivs:[[:space:]]*1' /proc/self/status || exit 3
+grep -q '^Seccomp:[[:space:]]*2' /proc/self/status || exit 4
+[ -z "$SSTS_SANDBOX_EXEC" ] || exit 5
+[ -z "$SSTS_MASTER_KEY" ] || exit 6
+[ -n "$SSTS_PARAMS" ] && [ -n "$PATH" ] || exit 7
+echo "{\"uid\": $(id -u)}"
+`)
+	binary := filepath.Join(t.TempDir(), "sandboxed")
+	if err := os.WriteFile(binary, script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(script)
+
+	plugin, err := NewExternalPlugin(models.Plugin{Name: "sandboxed", BinaryPath: binary, Checksum: hex.EncodeToString(sum[:])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugin.SetSandbox(sandbox)
+	instance := plugin.Factory()()
+	if err := instance.Initialize(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server's secrets stay out of the plugin's environment
+	t.Setenv("SSTS_MASTER_KEY", "c2VjcmV0")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := instance.Execute(ctx, models.TestParams{Duration: time.Second}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if uid := instance.GetMetrics()["uid"]; uid != float64(sandbox.UID) {
+		t.Errorf("plugin ran as uid %v, want %d", uid, sandbox.UID)
+	}
+}
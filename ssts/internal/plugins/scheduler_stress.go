@@ -0,0 +1,513 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// SchedulerStressConfig defines configuration for scheduler stress testing
+type SchedulerStressConfig struct {
+	Tasks       int    `json:"tasks"`        // Number of goroutines/threads taking part
+	Mode        string `json:"mode"`         // goroutine, thread
+	WakePattern string `json:"wake_pattern"` // pingpong, ring, broadcast, random
+	Yield       bool   `json:"yield"`        // Call runtime.Gosched after each wake
+}
+
+// SchedulerStressPlugin generates context-switch load by having many tasks wake each other
+type SchedulerStressPlugin struct {
+	config    SchedulerStressConfig
+	metrics   *SchedulerMetrics
+	mu        sync.RWMutex
+	stopChan  chan bool
+	wakeups   int64
+	latencies []time.Duration // sampled wake latencies since the last collection
+	seed      int64           // of the random pattern's choice of peers
+}
+
+// SchedulerMetrics tracks scheduler stress test metrics
+type SchedulerMetrics struct {
+	ContextSwitchesPerSec int64   `json:"context_switches_per_sec"`
+	WakeupsPerSec         int64   `json:"wakeups_per_sec"`
+	LatencyP50Us          float64 `json:"latency_p50_us"`
+	LatencyP90Us          float64 `json:"latency_p90_us"`
+	LatencyP99Us          float64 `json:"latency_p99_us"`
+	LatencyMaxUs          float64 `json:"latency_max_us"`
+	ActiveTasks           int     `json:"active_tasks"`
+}
+
+// latencySampleRate records one in every latencySampleRate wakeups per task
+const latencySampleRate = 64
+
+// maxLatencySamples bounds the samples kept between metric collections
+const maxLatencySamples = 100000
+
+// wakeMessage carries the send time so the receiver can measure wake latency
+type wakeMessage struct {
+	sent time.Time
+}
+
+// NewSchedulerStressPlugin creates a new scheduler stress plugin
+func NewSchedulerStressPlugin() *SchedulerStressPlugin {
+	return &SchedulerStressPlugin{
+		metrics:  &SchedulerMetrics{},
+		stopChan: make(chan bool),
+	}
+}
+
+// Name returns the plugin name
+func (s *SchedulerStressPlugin) Name() string {
+	return "scheduler-stress"
+}
+
+// Version returns the plugin version
+func (s *SchedulerStressPlugin) Version() string {
+	return "1.0.0"
+}
+
+// Description returns the plugin description
+func (s *SchedulerStressPlugin) Description() string {
+	return "Scheduler stress testing plugin generating heavy context-switch load"
+}
+
+// ConfigSchema returns the JSON schema for configuration
+func (s *SchedulerStressPlugin) ConfigSchema() []byte {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"tasks": {
+				"type": "integer",
+				"minimum": 2,
+				"maximum": 100000,
+				"default": 1000,
+				"description": "Number of goroutines (or OS threads in thread mode) waking each other"
+			},
+			"mode": {
+				"type": "string",
+				"enum": ["goroutine", "thread"],
+				"default": "goroutine",
+				"description": "goroutine multiplexes tasks on the Go scheduler; thread pins each task to its own OS thread so wakeups go through the kernel"
+			},
+			"wake_pattern": {
+				"type": "string",
+				"enum": ["pingpong", "ring", "broadcast", "random"],
+				"default": "pingpong",
+				"description": "How tasks wake each other"
+			},
+			"yield": {
+				"type": "boolean",
+				"default": false,
+				"description": "Yield the processor after every wakeup"
+			}
+		}
+	}`
+	return []byte(schema)
+}
+
+// Initialize initializes the plugin with configuration
+func (s *SchedulerStressPlugin) Initialize(config interface{}) error {
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := json.Unmarshal(configBytes, &s.config); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	// Set defaults
+	if s.config.Tasks <= 0 {
+		s.config.Tasks = 1000
+	}
+	if s.config.Tasks < 2 {
+		s.config.Tasks = 2
+	}
+	if s.config.Mode == "" {
+		s.config.Mode = "goroutine"
+	}
+	if s.config.WakePattern == "" {
+		s.config.WakePattern = "pingpong"
+	}
+
+	switch s.config.Mode {
+	case "goroutine", "thread":
+	default:
+		return fmt.Errorf("unsupported mode: %s", s.config.Mode)
+	}
+
+	switch s.config.WakePattern {
+	case "pingpong", "ring", "broadcast", "random":
+	default:
+		return fmt.Errorf("unsupported wake pattern: %s", s.config.WakePattern)
+	}
+
+	return nil
+}
+
+// Execute runs the scheduler stress test
+func (s *SchedulerStressPlugin) Execute(ctx context.Context, params models.TestParams) error {
+	s.mu.Lock()
+	s.wakeups = 0
+	s.latencies = s.latencies[:0]
+	s.metrics.ActiveTasks = s.config.Tasks
+	s.seed = params.Seed
+	s.mu.Unlock()
+
+	go s.collectMetrics(ctx)
+
+	var wg sync.WaitGroup
+
+	switch s.config.WakePattern {
+	case "pingpong":
+		s.startPingPong(ctx, &wg)
+	case "ring":
+		s.startRing(ctx, &wg)
+	case "broadcast":
+		s.startBroadcast(ctx, &wg)
+	case "random":
+		s.startRandom(ctx, &wg)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		<-done
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
+// FollowsDeadline reports that the wakeup workers run until the context ends
+func (s *SchedulerStressPlugin) FollowsDeadline() bool {
+	return true
+}
+
+// startPingPong pairs tasks up and bounces a message between each pair
+func (s *SchedulerStressPlugin) startPingPong(ctx context.Context, wg *sync.WaitGroup) {
+	for i := 0; i+1 < s.config.Tasks; i += 2 {
+		ping := make(chan wakeMessage)
+		pong := make(chan wakeMessage)
+
+		wg.Add(2)
+		go s.task(ctx, wg, i, ping, []chan wakeMessage{pong}, true)
+		go s.task(ctx, wg, i+1, pong, []chan wakeMessage{ping}, false)
+	}
+}
+
+// startRing passes a single token around a ring of tasks
+func (s *SchedulerStressPlugin) startRing(ctx context.Context, wg *sync.WaitGroup) {
+	channels := make([]chan wakeMessage, s.config.Tasks)
+	for i := range channels {
+		channels[i] = make(chan wakeMessage)
+	}
+
+	for i := range channels {
+		next := channels[(i+1)%len(channels)]
+		wg.Add(1)
+		go s.task(ctx, wg, i, channels[i], []chan wakeMessage{next}, i == 0)
+	}
+}
+
+// startBroadcast has one waker repeatedly wake every other task at once
+func (s *SchedulerStressPlugin) startBroadcast(ctx context.Context, wg *sync.WaitGroup) {
+	waiters := make([]chan wakeMessage, s.config.Tasks-1)
+	for i := range waiters {
+		waiters[i] = make(chan wakeMessage, 1)
+	}
+	acks := make(chan wakeMessage, len(waiters))
+
+	for i, waiter := range waiters {
+		wg.Add(1)
+		go s.task(ctx, wg, i, waiter, []chan wakeMessage{acks}, false)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.lockThread()
+		defer s.unlockThread()
+
+		var acked int
+		for {
+			now := time.Now()
+			for _, waiter := range waiters {
+				select {
+				case waiter <- wakeMessage{sent: now}:
+				case <-ctx.Done():
+					return
+				case <-s.stopChan:
+					return
+				}
+			}
+			for range waiters {
+				select {
+				case msg := <-acks:
+					acked++
+					s.recordWakeup(msg, acked)
+				case <-ctx.Done():
+					return
+				case <-s.stopChan:
+					return
+				}
+			}
+		}
+	}()
+}
+
+// startRandom has every task wake a randomly chosen peer, keeping a fixed number of tokens in flight
+func (s *SchedulerStressPlugin) startRandom(ctx context.Context, wg *sync.WaitGroup) {
+	channels := make([]chan wakeMessage, s.config.Tasks)
+	for i := range channels {
+		channels[i] = make(chan wakeMessage, 1)
+	}
+
+	for i := range channels {
+		wg.Add(1)
+		go s.task(ctx, wg, i, channels[i], channels, i%2 == 0)
+	}
+}
+
+// task waits to be woken and then wakes one of its peers. Its id picks its
+// stream of the run's random choices.
+func (s *SchedulerStressPlugin) task(ctx context.Context, wg *sync.WaitGroup, id int, inbox chan wakeMessage, peers []chan wakeMessage, starter bool) {
+	defer wg.Done()
+	s.lockThread()
+	defer s.unlockThread()
+
+	s.mu.RLock()
+	rng := seededRand(s.seed, id)
+	s.mu.RUnlock()
+	var count int
+
+	if starter {
+		if !s.wakePeer(ctx, peers, rng) {
+			return
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopChan:
+			return
+		case msg := <-inbox:
+			count++
+			s.recordWakeup(msg, count)
+
+			if s.config.Yield {
+				runtime.Gosched()
+			}
+
+			if !s.wakePeer(ctx, peers, rng) {
+				return
+			}
+		}
+	}
+}
+
+// wakePeer wakes one of the peers. With a single peer it blocks until the peer
+// is ready; with several it tries random peers until one has room in its inbox,
+// so tasks holding tokens can never wait on each other in a cycle.
+func (s *SchedulerStressPlugin) wakePeer(ctx context.Context, peers []chan wakeMessage, rng *rand.Rand) bool {
+	if len(peers) == 1 {
+		return s.wake(ctx, peers[0])
+	}
+
+	for {
+		select {
+		case peers[rng.Intn(len(peers))] <- wakeMessage{sent: time.Now()}:
+			return true
+		case <-ctx.Done():
+			return false
+		case <-s.stopChan:
+			return false
+		default:
+			runtime.Gosched()
+		}
+	}
+}
+
+// wake sends a wake message to a peer, returning false if the test is stopping
+func (s *SchedulerStressPlugin) wake(ctx context.Context, peer chan wakeMessage) bool {
+	select {
+	case peer <- wakeMessage{sent: time.Now()}:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-s.stopChan:
+		return false
+	}
+}
+
+// recordWakeup counts a wakeup and samples its latency
+func (s *SchedulerStressPlugin) recordWakeup(msg wakeMessage, count int) {
+	atomic.AddInt64(&s.wakeups, 1)
+
+	if count%latencySampleRate != 0 {
+		return
+	}
+
+	latency := time.Since(msg.sent)
+	s.mu.Lock()
+	if len(s.latencies) < maxLatencySamples {
+		s.latencies = append(s.latencies, latency)
+	}
+	s.mu.Unlock()
+}
+
+// lockThread pins the calling goroutine to its own OS thread in thread mode
+func (s *SchedulerStressPlugin) lockThread() {
+	if s.config.Mode == "thread" {
+		runtime.LockOSThread()
+	}
+}
+
+// unlockThread releases the OS thread pinned by lockThread
+func (s *SchedulerStressPlugin) unlockThread() {
+	if s.config.Mode == "thread" {
+		runtime.UnlockOSThread()
+	}
+}
+
+// collectMetrics collects performance metrics
+func (s *SchedulerStressPlugin) collectMetrics(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	var lastWakeups int64
+	lastSwitches, switchesErr := readContextSwitches()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			currentWakeups := atomic.LoadInt64(&s.wakeups)
+
+			var switchesPerSec int64
+			if switchesErr == nil {
+				if current, err := readContextSwitches(); err == nil {
+					switchesPerSec = current - lastSwitches
+					lastSwitches = current
+				}
+			}
+
+			s.mu.Lock()
+			samples := s.latencies
+			s.latencies = make([]time.Duration, 0, len(samples))
+
+			s.metrics.WakeupsPerSec = currentWakeups - lastWakeups
+			s.metrics.ContextSwitchesPerSec = switchesPerSec
+
+			if len(samples) > 0 {
+				sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+				s.metrics.LatencyP50Us = percentileMicros(samples, 0.50)
+				s.metrics.LatencyP90Us = percentileMicros(samples, 0.90)
+				s.metrics.LatencyP99Us = percentileMicros(samples, 0.99)
+				s.metrics.LatencyMaxUs = float64(samples[len(samples)-1]) / float64(time.Microsecond)
+			}
+			s.mu.Unlock()
+
+			lastWakeups = currentWakeups
+		}
+	}
+}
+
+// percentileMicros returns the p-th percentile of sorted samples in microseconds
+func percentileMicros(sorted []time.Duration, p float64) float64 {
+	index := int(float64(len(sorted)-1) * p)
+	return float64(sorted[index]) / float64(time.Microsecond)
+}
+
+// readContextSwitches reads the system-wide context switch counter from /proc/stat
+func readContextSwitches() (int64, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "ctxt" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("ctxt not found in /proc/stat")
+}
+
+// Cleanup cleans up resources
+func (s *SchedulerStressPlugin) Cleanup() error {
+	close(s.stopChan)
+	return nil
+}
+
+// GetMetrics returns current metrics
+func (s *SchedulerStressPlugin) GetMetrics() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return map[string]interface{}{
+		"context_switches_per_sec": s.metrics.ContextSwitchesPerSec,
+		"wakeups_per_sec":          s.metrics.WakeupsPerSec,
+		"latency_p50_us":           s.metrics.LatencyP50Us,
+		"latency_p90_us":           s.metrics.LatencyP90Us,
+		"latency_p99_us":           s.metrics.LatencyP99Us,
+		"latency_max_us":           s.metrics.LatencyMaxUs,
+		"active_tasks":             s.metrics.ActiveTasks,
+		"total_wakeups":            atomic.LoadInt64(&s.wakeups),
+	}
+}
+
+// GetSafetyLimits returns safety limits for scheduler testing
+func (s *SchedulerStressPlugin) GetSafetyLimits() models.SafetyLimits {
+	return models.SafetyLimits{
+		MaxCPUPercent:    90.0,
+		MaxMemoryPercent: 30.0, // Goroutine stacks and threads use some memory
+		MaxDiskPercent:   50.0,
+		MaxNetworkMbps:   10.0,
+	}
+}
+
+// HealthCheck performs a health check
+func (s *SchedulerStressPlugin) HealthCheck() error {
+	// Bounce a message between two goroutines to verify scheduling works
+	ping := make(chan struct{})
+	pong := make(chan struct{})
+	go func() {
+		<-ping
+		close(pong)
+	}()
+	close(ping)
+
+	select {
+	case <-pong:
+		return nil
+	case <-time.After(time.Second):
+		return fmt.Errorf("scheduler health check failed: goroutine was not woken within 1s")
+	}
+}
@@ -0,0 +1,116 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestSchedulerStressInitialize(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		want    SchedulerStressConfig
+		wantErr bool
+	}{
+		{"defaults", map[string]interface{}{}, SchedulerStressConfig{Tasks: 1000, Mode: "goroutine", WakePattern: "pingpong"}, false},
+		{"a single task gets a peer", map[string]interface{}{"tasks": 1}, SchedulerStressConfig{Tasks: 2, Mode: "goroutine", WakePattern: "pingpong"}, false},
+		{"negative tasks", map[string]interface{}{"tasks": -5}, SchedulerStressConfig{Tasks: 1000, Mode: "goroutine", WakePattern: "pingpong"}, false},
+		{"threads in a ring", map[string]interface{}{"tasks": 8, "mode": "thread", "wake_pattern": "ring", "yield": true}, SchedulerStressConfig{Tasks: 8, Mode: "thread", WakePattern: "ring", Yield: true}, false},
+		{"unknown mode", map[string]interface{}{"mode": "process"}, SchedulerStressConfig{}, true},
+		{"unknown wake pattern", map[string]interface{}{"wake_pattern": "star"}, SchedulerStressConfig{}, true},
+		{"tasks of the wrong type", map[string]interface{}{"tasks": "many"}, SchedulerStressConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := NewSchedulerStressPlugin()
+			err := plugin.Initialize(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Initialize accepted %v", tt.config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Initialize: %v", err)
+			}
+			if plugin.config != tt.want {
+				t.Errorf("config = %+v, want %+v", plugin.config, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerStressWakePatterns(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"tasks": 8, "wake_pattern": "pingpong"},
+		{"tasks": 7, "wake_pattern": "pingpong"}, // the odd task out sits idle
+		{"tasks": 8, "wake_pattern": "ring"},
+		{"tasks": 8, "wake_pattern": "broadcast"},
+		{"tasks": 8, "wake_pattern": "random", "yield": true},
+		{"tasks": 4, "wake_pattern": "ring", "mode": "thread"},
+	} {
+		plugin := NewSchedulerStressPlugin()
+		if err := plugin.Initialize(config); err != nil {
+			t.Fatalf("Initialize(%v): %v", config, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		err := plugin.Execute(ctx, models.TestParams{Duration: 100 * time.Millisecond})
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%v: Execute returned %v, want the context's error", config, err)
+		}
+
+		metrics := plugin.GetMetrics()
+		if wakeups := metrics["total_wakeups"].(int64); wakeups == 0 {
+			t.Errorf("%v: no task was woken", config)
+		}
+		if tasks := metrics["active_tasks"].(int); tasks != config["tasks"] {
+			t.Errorf("%v: active tasks = %d", config, tasks)
+		}
+	}
+}
+
+func TestSchedulerStressStopsOnCleanup(t *testing.T) {
+	plugin := NewSchedulerStressPlugin()
+	plugin.Initialize(map[string]interface{}{"tasks": 8, "wake_pattern": "broadcast"})
+
+	done := make(chan error, 1)
+	go func() { done <- plugin.Execute(context.Background(), models.TestParams{Duration: time.Minute}) }()
+	time.Sleep(20 * time.Millisecond)
+	plugin.Cleanup()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Execute after Cleanup: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tasks kept running after Cleanup")
+	}
+}
+
+func TestPercentileMicros(t *testing.T) {
+	samples := []time.Duration{time.Microsecond, 2 * time.Microsecond, 3 * time.Microsecond, 4 * time.Microsecond, 100 * time.Microsecond}
+	tests := []struct {
+		samples []time.Duration
+		p       float64
+		want    float64
+	}{
+		{samples, 0, 1},
+		{samples, 0.5, 3},
+		{samples, 0.9, 4},
+		{samples, 1, 100},
+		{samples[:1], 0.99, 1},
+		{[]time.Duration{1500 * time.Nanosecond}, 0.5, 1.5},
+	}
+	for _, tt := range tests {
+		if got := percentileMicros(tt.samples, tt.p); got != tt.want {
+			t.Errorf("percentileMicros(%v, %v) = %v, want %v", tt.samples, tt.p, got, tt.want)
+		}
+	}
+}
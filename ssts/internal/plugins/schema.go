@@ -0,0 +1,129 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SchemaError describes a single configuration field that failed schema validation
+type SchemaError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// configSchema is the subset of JSON schema used by the built-in plugins
+type configSchema struct {
+	Type       string                    `json:"type"`
+	Properties map[string]propertySchema `json:"properties"`
+	Required   []string                  `json:"required"`
+}
+
+// propertySchema describes a single property in a plugin configuration schema
+type propertySchema struct {
+	Type    string          `json:"type"`
+	Enum    []interface{}   `json:"enum"`
+	Minimum *float64        `json:"minimum"`
+	Maximum *float64        `json:"maximum"`
+	Items   *propertySchema `json:"items"`
+}
+
+// ValidateConfig validates a raw plugin configuration against the plugin's JSON schema.
+// It returns one SchemaError per offending field, or nil if the configuration is valid.
+func ValidateConfig(schema []byte, config json.RawMessage) ([]SchemaError, error) {
+	var s configSchema
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin schema: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	if len(config) > 0 && string(config) != "null" {
+		if err := json.Unmarshal(config, &values); err != nil {
+			return []SchemaError{{Field: "config", Message: "must be a JSON object"}}, nil
+		}
+	}
+
+	var errs []SchemaError
+
+	for _, name := range s.Required {
+		if _, ok := values[name]; !ok {
+			errs = append(errs, SchemaError{Field: name, Message: "is required"})
+		}
+	}
+
+	// Iterate in a stable order so error responses are deterministic
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, known := s.Properties[name]
+		if !known {
+			errs = append(errs, SchemaError{Field: name, Message: "is not a recognised option"})
+			continue
+		}
+		if msg := prop.validate(values[name]); msg != "" {
+			errs = append(errs, SchemaError{Field: name, Message: msg})
+		}
+	}
+
+	return errs, nil
+}
+
+// validate checks a single value against the property schema and returns a message on failure
+func (p propertySchema) validate(value interface{}) string {
+	switch p.Type {
+	case "string":
+		if _, ok := value.(string); !ok {
+			return "must be a string"
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return "must be an integer"
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return "must be a number"
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return "must be an array"
+		}
+		if p.Items != nil {
+			for i, item := range items {
+				if msg := p.Items.validate(item); msg != "" {
+					return fmt.Sprintf("item %d %s", i, msg)
+				}
+			}
+		}
+	}
+
+	if n, ok := value.(float64); ok {
+		if p.Minimum != nil && n < *p.Minimum {
+			return fmt.Sprintf("must be at least %v", *p.Minimum)
+		}
+		if p.Maximum != nil && n > *p.Maximum {
+			return fmt.Sprintf("must be at most %v", *p.Maximum)
+		}
+	}
+
+	if len(p.Enum) > 0 {
+		for _, allowed := range p.Enum {
+			if allowed == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %v", p.Enum)
+	}
+
+	return ""
+}
@@ -0,0 +1,41 @@
+package plugins
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateConfigAcceptsValidConfig(t *testing.T) {
+	schema := NewCPUStressPlugin().ConfigSchema()
+	config := json.RawMessage(`{"algorithm": "matrix", "workers": 4, "intensity": 80, "ramp_up": true}`)
+
+	errs, err := ValidateConfig(schema, config)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(errs) != 0 {
+		t.Errorf("Expected no validation errors, got %v", errs)
+	}
+}
+
+func TestValidateConfigReportsFieldErrors(t *testing.T) {
+	schema := NewCPUStressPlugin().ConfigSchema()
+	config := json.RawMessage(`{"workers": 2.5, "intensity": 150, "working_set_kb": [32, 0], "bogus": 1}`)
+
+	errs, err := ValidateConfig(schema, config)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+
+	for _, field := range []string{"algorithm", "workers", "intensity", "working_set_kb", "bogus"} {
+		if !fields[field] {
+			t.Errorf("Expected validation error for field %q, got %v", field, errs)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build linux && amd64
+
+package plugins
+
+import "golang.org/x/sys/unix"
+
+// seccompArch is the audit architecture system calls must come from; x32 calls,
+// which share it, are denied by number
+const seccompArch = unix.AUDIT_ARCH_X86_64
@@ -0,0 +1,8 @@
+//go:build linux && arm64
+
+package plugins
+
+import "golang.org/x/sys/unix"
+
+// seccompArch is the audit architecture system calls must come from
+const seccompArch = unix.AUDIT_ARCH_AARCH64
@@ -0,0 +1,6 @@
+//go:build linux && !amd64 && !arm64
+
+package plugins
+
+// seccompArch is unset where the seccomp filter hasn't been written for the architecture
+const seccompArch = 0
@@ -0,0 +1,21 @@
+package plugins
+
+import (
+	"math/rand"
+	"time"
+)
+
+// seedStride spreads the streams of one seed apart, so neighbouring workers
+// don't draw overlapping sequences
+const seedStride = 0x9E3779B97F4A7C15
+
+// seededRand returns the source of one stream of a run's random decisions, such
+// as a worker's offsets and read/write choices. Runs with the same seed, config
+// and streams make the same decisions, so a failing run can be replayed. A zero
+// seed, from callers that weren't given one, picks one from the clock.
+func seededRand(seed int64, stream int) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(int64(uint64(seed) + uint64(stream)*seedStride)))
+}
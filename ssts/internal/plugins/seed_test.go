@@ -0,0 +1,29 @@
+package plugins
+
+import "testing"
+
+func TestSeededRandReplaysStreams(t *testing.T) {
+	draw := func(seed int64, stream int) []int64 {
+		rng := seededRand(seed, stream)
+		values := make([]int64, 8)
+		for i := range values {
+			values[i] = rng.Int63n(1 << 40)
+		}
+		return values
+	}
+
+	first, replay := draw(42, 3), draw(42, 3)
+	for i := range first {
+		if first[i] != replay[i] {
+			t.Fatalf("draw %d = %d, replayed as %d", i, first[i], replay[i])
+		}
+	}
+
+	same := true
+	for i, value := range draw(42, 4) {
+		same = same && value == first[i]
+	}
+	if same {
+		t.Error("streams 3 and 4 of a seed draw the same values")
+	}
+}
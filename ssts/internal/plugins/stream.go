@@ -0,0 +1,121 @@
+package plugins
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// MetricsStreamer is implemented by plugins that can push high-frequency metrics,
+// such as per-operation latencies, instead of only exposing a GetMetrics snapshot.
+// MetricsStream is called before the plugin is executed; the returned channel is
+// closed once ctx is done.
+type MetricsStreamer interface {
+	MetricsStream(ctx context.Context) <-chan models.MetricPoint
+}
+
+// defaultStreamBuffer is the number of points buffered before publishers start dropping
+const defaultStreamBuffer = 4096
+
+// metricStream is a helper for plugins implementing MetricsStreamer. Publishing never
+// blocks the workload: points are dropped when the consumer falls behind.
+type metricStream struct {
+	mu      sync.RWMutex
+	ch      chan models.MetricPoint
+	closed  bool
+	dropped int64
+
+	tagsMu  sync.RWMutex
+	tagSets map[tagSet]map[string]string
+}
+
+// tagSet identifies the tags of a point by up to four name and value pairs
+type tagSet [8]string
+
+// newMetricStream creates a stream that is closed when ctx is done
+func newMetricStream(ctx context.Context, buffer int) *metricStream {
+	if buffer <= 0 {
+		buffer = defaultStreamBuffer
+	}
+
+	s := &metricStream{ch: make(chan models.MetricPoint, buffer)}
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		s.closed = true
+		close(s.ch)
+		s.mu.Unlock()
+	}()
+
+	return s
+}
+
+// publish sends a point to the stream without blocking. It is safe to call on a nil stream.
+func (s *metricStream) publish(pointType string, tags map[string]string, fields map[string]interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- models.MetricPoint{
+		Timestamp: time.Now(),
+		Type:      pointType,
+		Tags:      tags,
+		Fields:    fields,
+	}:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+// tags returns the tags map for the name and value pairs given, such as
+// "operation", "read". Points published with the same pairs share one map for
+// the life of the stream rather than allocating their own, so pairs should come
+// from a small set of values. It is safe to call on a nil stream.
+func (s *metricStream) tags(pairs ...string) map[string]string {
+	if s == nil {
+		return nil
+	}
+
+	var key tagSet
+	copy(key[:], pairs)
+	s.tagsMu.RLock()
+	tags, ok := s.tagSets[key]
+	s.tagsMu.RUnlock()
+	if ok {
+		return tags
+	}
+
+	s.tagsMu.Lock()
+	defer s.tagsMu.Unlock()
+	if tags, ok := s.tagSets[key]; ok {
+		return tags
+	}
+	// Leave room for the execution_id tag the collector adds
+	tags = make(map[string]string, len(pairs)/2+1)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		tags[pairs[i]] = pairs[i+1]
+	}
+	if s.tagSets == nil {
+		s.tagSets = make(map[tagSet]map[string]string)
+	}
+	s.tagSets[key] = tags
+	return tags
+}
+
+// droppedCount returns how many points have been dropped because the consumer fell behind
+func (s *metricStream) droppedCount() int64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&s.dropped)
+}
@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMetricStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		buffer      int
+		published   int
+		wantQueued  int
+		wantDropped int64
+	}{
+		{"within the buffer", 4, 3, 3, 0},
+		{"buffer full", 4, 6, 4, 2},
+		{"default buffer", 0, 10, 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			stream := newMetricStream(ctx, tt.buffer)
+			for i := 0; i < tt.published; i++ {
+				stream.publish("latency", map[string]string{"op": "read"}, map[string]interface{}{"us": i})
+			}
+			if dropped := stream.droppedCount(); dropped != tt.wantDropped {
+				t.Errorf("dropped %d points, want %d", dropped, tt.wantDropped)
+			}
+
+			cancel()
+			queued := 0
+			timeout := time.After(5 * time.Second)
+			for {
+				select {
+				case point, ok := <-stream.ch:
+					if !ok {
+						if queued != tt.wantQueued {
+							t.Errorf("received %d points, want %d", queued, tt.wantQueued)
+						}
+						// Publishing after the stream closed is a no-op rather than a panic
+						stream.publish("latency", nil, nil)
+						return
+					}
+					if point.Type != "latency" || point.Timestamp.IsZero() {
+						t.Errorf("point = %+v", point)
+					}
+					queued++
+				case <-timeout:
+					t.Fatal("stream not closed once the context was done")
+				}
+			}
+		})
+	}
+
+	var stream *metricStream
+	stream.publish("latency", nil, nil)
+	if stream.droppedCount() != 0 {
+		t.Error("nil stream reports dropped points")
+	}
+}
+
+func TestMetricStreamTags(t *testing.T) {
+	stream := newMetricStream(context.Background(), 1)
+	read := stream.tags("operation", "read", "target", "/tmp/a")
+	if len(read) != 2 || read["operation"] != "read" || read["target"] != "/tmp/a" {
+		t.Errorf("tags = %v", read)
+	}
+	if write := stream.tags("operation", "write", "target", "/tmp/a"); write["operation"] != "write" {
+		t.Errorf("tags for other values = %v", write)
+	}
+
+	// Publishing with known tags doesn't allocate a map
+	if allocs := testing.AllocsPerRun(100, func() { stream.tags("operation", "read", "target", "/tmp/a") }); allocs != 0 {
+		t.Errorf("looking up known tags allocated %v times", allocs)
+	}
+	read["execution_id"] = "e1"
+	if again := stream.tags("operation", "read", "target", "/tmp/a"); again["execution_id"] != "e1" {
+		t.Error("points with the same tags don't share a map")
+	}
+
+	var closed *metricStream
+	if tags := closed.tags("operation", "read"); tags != nil {
+		t.Errorf("nil stream returned tags %v", tags)
+	}
+}
@@ -0,0 +1,135 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+)
+
+// bigQueryEndpoint is the BigQuery REST API, used unless a sink sets its own URL
+const bigQueryEndpoint = "https://bigquery.googleapis.com"
+
+// bigQuerySink streams each result as a row into a table with tabledata.insertAll,
+// using the execution ID as insert ID so retries aren't stored twice. The table
+// needs the columns of bigQueryRow; nested values are stored as JSON strings.
+type bigQuerySink struct {
+	httpSink
+}
+
+// bigQueryRow is the row stored for a result
+type bigQueryRow struct {
+	ExecutionID        string   `json:"execution_id"`
+	TestID             string   `json:"test_id"`
+	TestName           string   `json:"test_name"`
+	Plugin             string   `json:"plugin"`
+	ProjectID          string   `json:"project_id"`
+	Hostname           string   `json:"hostname"`
+	Status             string   `json:"status"`
+	StartTime          *string  `json:"start_time"` // TIMESTAMP
+	EndTime            *string  `json:"end_time"`   // TIMESTAMP
+	DurationSeconds    float64  `json:"duration_seconds"`
+	Score              float64  `json:"score"`
+	Passed             bool     `json:"passed"`
+	Errors             []string `json:"errors"` // REPEATED STRING
+	Violations         int      `json:"violations"`
+	CriticalViolations int      `json:"critical_violations"`
+	EnergyJoules       *float64 `json:"energy_joules"`
+	Labels             string   `json:"labels"`  // JSON
+	Summary            string   `json:"summary"` // JSON
+	System             string   `json:"system"`  // JSON
+}
+
+// bigQueryInsertResponse is the part of insertAll's response reporting rejected rows
+type bigQueryInsertResponse struct {
+	InsertErrors []struct {
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+func newBigQuerySink(cfg config.ResultSinkConfig, client *http.Client) (Sink, error) {
+	if err := require(cfg, map[string]string{"project": cfg.Project, "dataset": cfg.Dataset, "table": cfg.Table}); err != nil {
+		return nil, err
+	}
+	if cfg.URL == "" {
+		cfg.URL = bigQueryEndpoint
+	}
+	return &bigQuerySink{httpSink{name: cfg.Name, cfg: cfg, client: client}}, nil
+}
+
+func (s *bigQuerySink) Publish(ctx context.Context, result Result) error {
+	target := fmt.Sprintf("%s/bigquery/v2/projects/%s/datasets/%s/tables/%s/insertAll",
+		strings.TrimRight(s.cfg.URL, "/"), url.PathEscape(s.cfg.Project), url.PathEscape(s.cfg.Dataset), url.PathEscape(s.cfg.Table))
+	body := map[string]interface{}{
+		"kind": "bigquery#tableDataInsertAllRequest",
+		"rows": []map[string]interface{}{
+			{"insertId": result.ExecutionID, "json": newBigQueryRow(result)},
+		},
+	}
+
+	data, err := s.send(ctx, http.MethodPost, target, "application/json", body)
+	if err != nil {
+		return err
+	}
+
+	var resp bigQueryInsertResponse
+	if json.Unmarshal(data, &resp) != nil || len(resp.InsertErrors) == 0 {
+		return nil
+	}
+	if errs := resp.InsertErrors[0].Errors; len(errs) > 0 {
+		return fmt.Errorf("bigquery rejected the row: %s: %s", errs[0].Reason, errs[0].Message)
+	}
+	return fmt.Errorf("bigquery rejected the row")
+}
+
+// newBigQueryRow flattens a result into a table row
+func newBigQueryRow(result Result) bigQueryRow {
+	row := bigQueryRow{
+		ExecutionID:     result.ExecutionID,
+		TestID:          result.TestID,
+		TestName:        result.TestName,
+		Plugin:          result.Plugin,
+		ProjectID:       result.ProjectID,
+		Hostname:        result.Hostname,
+		Status:          string(result.Result.Status),
+		DurationSeconds: result.Result.Duration.Seconds(),
+		Score:           result.Result.Score,
+		Passed:          result.Result.Passed,
+		Errors:          result.Result.Errors,
+		Labels:          jsonString(result.Labels),
+		Summary:         jsonString(result.Summary),
+		System:          jsonString(result.System),
+	}
+	if result.StartTime != nil {
+		start := result.StartTime.UTC().Format("2006-01-02 15:04:05.000000")
+		row.StartTime = &start
+	}
+	if result.EndTime != nil {
+		end := result.EndTime.UTC().Format("2006-01-02 15:04:05.000000")
+		row.EndTime = &end
+	}
+	if result.Summary != nil {
+		row.Violations = result.Summary.Violations
+		row.CriticalViolations = result.Summary.CriticalViolations
+	}
+	if result.Result.Energy != nil {
+		row.EnergyJoules = &result.Result.Energy.Joules
+	}
+	return row
+}
+
+// jsonString encodes a value for a JSON column, or returns "" for nil
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,29 @@
+package publish
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+)
+
+// elasticsearchSink indexes each result as a document whose ID is the execution
+// ID, so a retried publish replaces the document rather than duplicating it
+type elasticsearchSink struct {
+	httpSink
+}
+
+func newElasticsearchSink(cfg config.ResultSinkConfig, client *http.Client) (Sink, error) {
+	if err := require(cfg, map[string]string{"url": cfg.URL, "index": cfg.Index}); err != nil {
+		return nil, err
+	}
+	return &elasticsearchSink{httpSink{name: cfg.Name, cfg: cfg, client: client}}, nil
+}
+
+func (s *elasticsearchSink) Publish(ctx context.Context, result Result) error {
+	target := strings.TrimRight(s.cfg.URL, "/") + "/" + url.PathEscape(s.cfg.Index) + "/_doc/" + url.PathEscape(result.ExecutionID)
+	_, err := s.send(ctx, http.MethodPut, target, "application/json", result)
+	return err
+}
@@ -0,0 +1,58 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+)
+
+// kafkaSink produces each result to a topic through a Confluent-compatible Kafka
+// REST proxy, keyed by execution ID
+type kafkaSink struct {
+	httpSink
+}
+
+// kafkaProduceResponse is the part of the REST proxy's response reporting
+// per-record failures
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+func newKafkaSink(cfg config.ResultSinkConfig, client *http.Client) (Sink, error) {
+	if err := require(cfg, map[string]string{"url": cfg.URL, "topic": cfg.Topic}); err != nil {
+		return nil, err
+	}
+	return &kafkaSink{httpSink{name: cfg.Name, cfg: cfg, client: client}}, nil
+}
+
+func (s *kafkaSink) Publish(ctx context.Context, result Result) error {
+	target := strings.TrimRight(s.cfg.URL, "/") + "/topics/" + url.PathEscape(s.cfg.Topic)
+	body := map[string]interface{}{
+		"records": []map[string]interface{}{
+			{"key": result.ExecutionID, "value": result},
+		},
+	}
+
+	data, err := s.send(ctx, http.MethodPost, target, "application/vnd.kafka.json.v2+json", body)
+	if err != nil {
+		return err
+	}
+
+	var resp kafkaProduceResponse
+	if json.Unmarshal(data, &resp) == nil {
+		for _, offset := range resp.Offsets {
+			if offset.ErrorCode != nil || offset.Error != "" {
+				return fmt.Errorf("kafka rejected the result: %s", offset.Error)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Sink types
+const (
+	TypeElasticsearch = "elasticsearch"
+	TypeKafka         = "kafka"
+	TypeBigQuery      = "bigquery"
+	TypeWebhook       = "webhook"
+)
+
+// Result is what is published for a finished execution. Raw metric points aren't
+// included; the summary holds per-metric statistics over the run.
+type Result struct {
+	ExecutionID string                   `json:"execution_id"`
+	TestID      string                   `json:"test_id"`
+	TestName    string                   `json:"test_name,omitempty"`
+	Plugin      string                   `json:"plugin,omitempty"`
+	ProjectID   string                   `json:"project_id,omitempty"`
+	Hostname    string                   `json:"hostname,omitempty"`
+	Labels      models.Labels            `json:"labels,omitempty"`
+	CI          *models.CIMetadata       `json:"ci,omitempty"` // build the run was started for
+	StartTime   *time.Time               `json:"start_time,omitempty"`
+	EndTime     *time.Time               `json:"end_time,omitempty"`
+	Timestamp   time.Time                `json:"timestamp"` // when the execution finished
+	Result      models.TestResult        `json:"result"`
+	Summary     *models.ExecutionSummary `json:"summary,omitempty"`
+	System      *models.SystemSnapshot   `json:"system,omitempty"`
+}
+
+// Sink is an external system results are published to
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, result Result) error
+}
+
+// sinkTypes builds each type of sink from its configuration
+var sinkTypes = map[string]func(cfg config.ResultSinkConfig, client *http.Client) (Sink, error){
+	TypeElasticsearch: newElasticsearchSink,
+	TypeKafka:         newKafkaSink,
+	TypeBigQuery:      newBigQuerySink,
+	TypeWebhook:       newWebhookSink,
+}
+
+// Types lists the supported sink types in name order
+func Types() []string {
+	types := make([]string, 0, len(sinkTypes))
+	for name := range sinkTypes {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewSink creates a sink from its configuration
+func NewSink(cfg config.ResultSinkConfig, client *http.Client) (Sink, error) {
+	newSink, ok := sinkTypes[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported result sink type %q, want one of %s", cfg.Type, strings.Join(Types(), ", "))
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Type
+	}
+	return newSink(cfg, client)
+}
+
+// Publisher sends results to every configured sink in the background, retrying
+// failed attempts. A sink failing doesn't stop the others being published to.
+type Publisher struct {
+	sinks   []Sink
+	timeout time.Duration
+	retries int
+	backoff time.Duration // wait before the first retry, doubling for each further one
+	logger  *zap.Logger
+	wg      sync.WaitGroup
+}
+
+// NewPublisher creates a publisher for the configured sinks. It returns an error
+// naming the first sink whose configuration is invalid.
+func NewPublisher(cfg config.PublishConfig, logger *zap.Logger) (*Publisher, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	client := &http.Client{Timeout: timeout}
+	sinks := make([]Sink, 0, len(cfg.Sinks))
+	for i, sinkCfg := range cfg.Sinks {
+		sink, err := NewSink(sinkCfg, client)
+		if err != nil {
+			return nil, fmt.Errorf("result sink %d: %w", i+1, err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return &Publisher{
+		sinks:   sinks,
+		timeout: timeout,
+		retries: cfg.Retries,
+		backoff: time.Second,
+		logger:  logger,
+	}, nil
+}
+
+// Sinks returns the names of the configured sinks
+func (p *Publisher) Sinks() []string {
+	names := make([]string, len(p.sinks))
+	for i, sink := range p.sinks {
+		names[i] = sink.Name()
+	}
+	return names
+}
+
+// Publish sends a result to every sink without waiting for them
+func (p *Publisher) Publish(result Result) {
+	for _, sink := range p.sinks {
+		p.wg.Add(1)
+		go func(sink Sink) {
+			defer p.wg.Done()
+			p.publishTo(sink, result)
+		}(sink)
+	}
+}
+
+// publishTo sends a result to one sink, retrying with backoff
+func (p *Publisher) publishTo(sink Sink, result Result) {
+	backoff := p.backoff
+	var err error
+	for attempt := 0; attempt <= p.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		err = sink.Publish(ctx, result)
+		cancel()
+		if err == nil {
+			p.logger.Debug("Published execution result",
+				zap.String("sink", sink.Name()),
+				zap.String("execution_id", result.ExecutionID),
+			)
+			return
+		}
+	}
+
+	p.logger.Error("Failed to publish execution result",
+		zap.String("sink", sink.Name()),
+		zap.String("execution_id", result.ExecutionID),
+		zap.Int("attempts", p.retries+1),
+		zap.Error(err),
+	)
+}
+
+// Close waits for results being published to be sent, up to the timeout. It
+// reports whether they all were.
+func (p *Publisher) Close(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// httpSink holds what every sink needs to make authenticated HTTP requests
+type httpSink struct {
+	name   string
+	cfg    config.ResultSinkConfig
+	client *http.Client
+}
+
+func (s *httpSink) Name() string { return s.name }
+
+// send sends a JSON body and returns the response body, failing on non-2xx statuses
+func (s *httpSink) send(ctx context.Context, method, url, contentType string, body interface{}) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if err := s.authorize(req); err != nil {
+		return nil, err
+	}
+	for name, value := range s.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := strings.TrimSpace(string(respBody))
+		if len(message) > 200 {
+			message = message[:200] + "..."
+		}
+		return nil, fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, message)
+	}
+	return respBody, nil
+}
+
+// authorize adds the sink's credentials to a request
+func (s *httpSink) authorize(req *http.Request) error {
+	token := s.cfg.Token
+	if s.cfg.TokenFile != "" {
+		data, err := os.ReadFile(s.cfg.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	switch {
+	case token != "" && s.cfg.Type == TypeElasticsearch:
+		req.Header.Set("Authorization", "ApiKey "+token)
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case s.cfg.Username != "":
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+	return nil
+}
+
+// require returns an error naming the first of the settings that is empty
+func require(cfg config.ResultSinkConfig, settings map[string]string) error {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if settings[name] == "" {
+			return fmt.Errorf("%s sink %q requires %s", cfg.Type, cfg.Name, name)
+		}
+	}
+	return nil
+}
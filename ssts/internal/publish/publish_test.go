@@ -0,0 +1,158 @@
+package publish
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// request is what a fake sink server received
+type request struct {
+	method, path, contentType, auth string
+	body                            map[string]interface{}
+}
+
+// fakeServer records requests, failing the first failures of them
+func fakeServer(t *testing.T, failures int, response string) (*httptest.Server, func() []request) {
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req := request{method: r.Method, path: r.URL.Path, contentType: r.Header.Get("Content-Type"), auth: r.Header.Get("Authorization")}
+		if err := json.Unmarshal(data, &req.body); err != nil {
+			t.Errorf("request body is not JSON: %s", data)
+		}
+
+		mu.Lock()
+		requests = append(requests, req)
+		fail := len(requests) <= failures
+		mu.Unlock()
+
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), requests...)
+	}
+}
+
+func testResult() Result {
+	end := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	start := end.Add(-time.Minute)
+	return Result{
+		ExecutionID: "exec-1",
+		TestID:      "test-1",
+		TestName:    "burn-in",
+		Plugin:      "cpu-stress",
+		Hostname:    "node-7",
+		StartTime:   &start,
+		EndTime:     &end,
+		Timestamp:   end,
+		Result:      models.TestResult{TestID: "test-1", Status: models.StatusCompleted, Duration: time.Minute, Score: 90, Passed: true},
+		Summary:     &models.ExecutionSummary{DurationSeconds: 60, Violations: 2},
+	}
+}
+
+func publishOnce(t *testing.T, sinkCfg config.ResultSinkConfig, retries int) {
+	t.Helper()
+	publisher, err := NewPublisher(config.PublishConfig{Timeout: time.Second, Retries: retries, Sinks: []config.ResultSinkConfig{sinkCfg}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+	publisher.backoff = time.Millisecond
+	publisher.Publish(testResult())
+	if !publisher.Close(5 * time.Second) {
+		t.Fatal("result was not published in time")
+	}
+}
+
+func TestSinks(t *testing.T) {
+	t.Run("elasticsearch", func(t *testing.T) {
+		server, requests := fakeServer(t, 0, `{"result":"created"}`)
+		publishOnce(t, config.ResultSinkConfig{Type: TypeElasticsearch, URL: server.URL, Index: "results", Token: "key"}, 0)
+
+		reqs := requests()
+		if len(reqs) != 1 || reqs[0].method != http.MethodPut || reqs[0].path != "/results/_doc/exec-1" || reqs[0].auth != "ApiKey key" {
+			t.Fatalf("requests = %+v", reqs)
+		}
+		if reqs[0].body["test_name"] != "burn-in" || reqs[0].body["summary"] == nil {
+			t.Errorf("document = %v", reqs[0].body)
+		}
+	})
+
+	t.Run("kafka", func(t *testing.T) {
+		server, requests := fakeServer(t, 0, `{"offsets":[{"partition":0,"offset":12}]}`)
+		publishOnce(t, config.ResultSinkConfig{Type: TypeKafka, URL: server.URL, Topic: "ssts.results"}, 0)
+
+		reqs := requests()
+		if len(reqs) != 1 || reqs[0].path != "/topics/ssts.results" || reqs[0].contentType != "application/vnd.kafka.json.v2+json" {
+			t.Fatalf("requests = %+v", reqs)
+		}
+		records, _ := reqs[0].body["records"].([]interface{})
+		if len(records) != 1 || records[0].(map[string]interface{})["key"] != "exec-1" {
+			t.Errorf("records = %v", reqs[0].body["records"])
+		}
+	})
+
+	t.Run("bigquery", func(t *testing.T) {
+		server, requests := fakeServer(t, 0, `{"kind":"bigquery#tableDataInsertAllResponse"}`)
+		publishOnce(t, config.ResultSinkConfig{Type: TypeBigQuery, URL: server.URL, Project: "p", Dataset: "d", Table: "t", Token: "oauth"}, 0)
+
+		reqs := requests()
+		if len(reqs) != 1 || reqs[0].path != "/bigquery/v2/projects/p/datasets/d/tables/t/insertAll" || reqs[0].auth != "Bearer oauth" {
+			t.Fatalf("requests = %+v", reqs)
+		}
+		rows, _ := reqs[0].body["rows"].([]interface{})
+		if len(rows) != 1 {
+			t.Fatalf("rows = %v", reqs[0].body["rows"])
+		}
+		row := rows[0].(map[string]interface{})
+		values := row["json"].(map[string]interface{})
+		if row["insertId"] != "exec-1" || values["duration_seconds"] != 60.0 || values["violations"] != 2.0 || values["end_time"] != "2024-01-02 03:04:05.000000" {
+			t.Errorf("row = %v", row)
+		}
+		if !strings.Contains(values["summary"].(string), `"duration_seconds":60`) {
+			t.Errorf("summary column = %v", values["summary"])
+		}
+	})
+
+	t.Run("webhook retries", func(t *testing.T) {
+		server, requests := fakeServer(t, 2, `ok`)
+		publishOnce(t, config.ResultSinkConfig{Type: TypeWebhook, URL: server.URL + "/hook", Headers: map[string]string{"X-Fleet": "lab-a"}}, 3)
+
+		if reqs := requests(); len(reqs) != 3 || reqs[2].body["execution_id"] != "exec-1" {
+			t.Fatalf("requests = %+v, want two failures and a success", reqs)
+		}
+	})
+}
+
+func TestNewPublisherRejectsInvalidSinks(t *testing.T) {
+	for _, sinkCfg := range []config.ResultSinkConfig{
+		{Type: "s3"},
+		{Type: TypeElasticsearch, URL: "http://localhost:9200"},
+		{Type: TypeKafka, Topic: "results"},
+		{Type: TypeBigQuery, Project: "p", Dataset: "d"},
+		{Type: TypeWebhook},
+	} {
+		if _, err := NewPublisher(config.PublishConfig{Sinks: []config.ResultSinkConfig{sinkCfg}}, zap.NewNop()); err == nil {
+			t.Errorf("NewPublisher accepted %+v", sinkCfg)
+		}
+	}
+}
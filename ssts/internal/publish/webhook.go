@@ -0,0 +1,25 @@
+package publish
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+)
+
+// webhookSink posts each result as JSON to a URL
+type webhookSink struct {
+	httpSink
+}
+
+func newWebhookSink(cfg config.ResultSinkConfig, client *http.Client) (Sink, error) {
+	if err := require(cfg, map[string]string{"url": cfg.URL}); err != nil {
+		return nil, err
+	}
+	return &webhookSink{httpSink{name: cfg.Name, cfg: cfg, client: client}}, nil
+}
+
+func (s *webhookSink) Publish(ctx context.Context, result Result) error {
+	_, err := s.send(ctx, http.MethodPost, s.cfg.URL, "application/json", result)
+	return err
+}
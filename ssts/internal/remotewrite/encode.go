@@ -0,0 +1,188 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"strings"
+)
+
+// label is a name and value identifying a series
+type label struct {
+	name, value string
+}
+
+// sample is one value of a series, at a time in milliseconds since the epoch
+type sample struct {
+	labels    []label // sorted by name, including __name__
+	value     float64
+	timestamp int64
+}
+
+// series is the samples of one label set
+type series struct {
+	labels  []label
+	samples []sample
+}
+
+// groupSeries groups samples by their labels, ordering each series' samples by
+// time as remote-write receivers require
+func groupSeries(samples []sample) []series {
+	index := make(map[string]int)
+	var grouped []series
+	for _, s := range samples {
+		key := seriesKey(s.labels)
+		i, ok := index[key]
+		if !ok {
+			i = len(grouped)
+			index[key] = i
+			grouped = append(grouped, series{labels: s.labels})
+		}
+		grouped[i].samples = append(grouped[i].samples, s)
+	}
+	for _, ts := range grouped {
+		sort.SliceStable(ts.samples, func(i, j int) bool { return ts.samples[i].timestamp < ts.samples[j].timestamp })
+	}
+	return grouped
+}
+
+// seriesKey identifies a label set
+func seriesKey(labels []label) string {
+	var key strings.Builder
+	for _, l := range labels {
+		key.WriteString(l.name)
+		key.WriteByte(0)
+		key.WriteString(l.value)
+		key.WriteByte(0)
+	}
+	return key.String()
+}
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// encodeWriteRequest encodes series as a prometheus.WriteRequest message:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(all []series) []byte {
+	var request, ts, buf []byte
+	for _, s := range all {
+		ts = ts[:0]
+		for _, l := range s.labels {
+			buf = appendString(buf[:0], 1, l.name)
+			buf = appendString(buf, 2, l.value)
+			ts = appendBytes(ts, 1, buf)
+		}
+		for _, smp := range s.samples {
+			buf = appendTag(buf[:0], 1, wireFixed64)
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(smp.value))
+			buf = appendTag(buf, 2, wireVarint)
+			buf = binary.AppendUvarint(buf, uint64(smp.timestamp))
+			ts = appendBytes(ts, 2, buf)
+		}
+		request = appendBytes(request, 1, ts)
+	}
+	return request
+}
+
+func appendTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendBytes(b []byte, field int, value []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func appendString(b []byte, field int, value string) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// Snappy block format limits: input is compressed in independent blocks, and
+// copies refer back at most this far
+const (
+	snappyBlockSize = 1 << 16
+	snappyMinMatch  = 4
+	snappyTableBits = 14
+)
+
+// snappyEncode compresses src in the snappy block format remote-write bodies
+// are sent in. It finds repeats with a single-entry hash table, which is all the
+// label names and values that recur throughout a request need.
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(make([]byte, 0, len(src)/2+16), uint64(len(src)))
+	for len(src) > 0 {
+		block := src
+		if len(block) > snappyBlockSize {
+			block = block[:snappyBlockSize]
+		}
+		dst = snappyEncodeBlock(dst, block)
+		src = src[len(block):]
+	}
+	return dst
+}
+
+func snappyEncodeBlock(dst, src []byte) []byte {
+	var table [1 << snappyTableBits]int32 // position+1 of the last 4 bytes hashing to each entry
+	literal := 0
+	for i := 0; i+snappyMinMatch <= len(src); {
+		word := binary.LittleEndian.Uint32(src[i:])
+		hash := (word * 0x1e35a7bd) >> (32 - snappyTableBits)
+		candidate := int(table[hash]) - 1
+		table[hash] = int32(i + 1)
+		if candidate < 0 || binary.LittleEndian.Uint32(src[candidate:]) != word {
+			i++
+			continue
+		}
+
+		length := snappyMinMatch
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = snappyLiteral(dst, src[literal:i])
+		dst = snappyCopy(dst, i-candidate, length)
+		i += length
+		literal = i
+	}
+	return snappyLiteral(dst, src[literal:])
+}
+
+// snappyLiteral appends bytes copied as they are; blocks keep them under 64KiB
+func snappyLiteral(dst, literal []byte) []byte {
+	if len(literal) == 0 {
+		return dst
+	}
+	n := len(literal) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	default:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	}
+	return append(dst, literal...)
+}
+
+// snappyCopy appends copies of length bytes from offset back, 64 at a time
+func snappyCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		n := length
+		if n > 64 {
+			n = 64
+		}
+		dst = append(dst, byte(n-1)<<2|2, byte(offset), byte(offset>>8))
+		length -= n
+	}
+	return dst
+}
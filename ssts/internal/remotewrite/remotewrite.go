@@ -0,0 +1,433 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Defaults for targets that don't set their own
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultQueueSize = 100000
+	defaultBatchSize = 1000
+	defaultFlush     = 5 * time.Second
+)
+
+// metricPrefix starts the name of every metric shipped
+const metricPrefix = "ssts_"
+
+// Health is how shipping metrics to a remote-write target is going
+type Health struct {
+	Name        string     `json:"name"`
+	URL         string     `json:"url"`
+	Healthy     bool       `json:"healthy"` // the last request succeeded, or none was needed yet
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	Sent        int64      `json:"samples_sent"`
+	Rejected    int64      `json:"samples_rejected"` // refused by the target as invalid
+	Dropped     int64      `json:"samples_dropped"`  // beyond the queue while the target was down
+	Pending     int        `json:"samples_pending"`
+}
+
+// Writer ships metric points to a Prometheus remote-write endpoint. Points are
+// queued as samples and sent in batches in the background; batches the target
+// couldn't take are retried at the next flush, and the oldest samples dropped
+// once the queue is full.
+type Writer struct {
+	name          string
+	cfg           config.RemoteWriteConfig
+	client        *http.Client
+	batchSize     int
+	queueSize     int
+	flushInterval time.Duration
+	labels        []label // external labels, added to every series
+	logger        *zap.Logger
+
+	mu          sync.Mutex
+	queue       []sample
+	lastSuccess time.Time
+	lastError   string
+	lastErrorAt time.Time
+	sent        int64
+	rejected    int64
+	dropped     int64
+
+	flushNow chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewWriter creates a writer for the target and starts shipping in the
+// background. Batches of batchSize samples are sent every flushInterval, or as
+// soon as a batch is full.
+func NewWriter(cfg config.RemoteWriteConfig, batchSize int, flushInterval time.Duration, logger *zap.Logger) (*Writer, error) {
+	target, err := url.Parse(cfg.URL)
+	if err != nil || target.Host == "" || (target.Scheme != "http" && target.Scheme != "https") {
+		return nil, fmt.Errorf("invalid remote_write url %q", cfg.URL)
+	}
+	name := cfg.Name
+	if name == "" {
+		name = target.Host
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultFlush
+	}
+
+	// Series are told apart by the host they came from unless the target's
+	// external labels already say
+	external := make(map[string]string, len(cfg.ExternalLabels)+1)
+	if hostname, err := os.Hostname(); err == nil {
+		external["instance"] = hostname
+	}
+	for key, value := range cfg.ExternalLabels {
+		if key = labelName(key); key != "" {
+			external[key] = value
+		}
+	}
+	labels := make([]label, 0, len(external))
+	for key, value := range external {
+		labels = append(labels, label{key, value})
+	}
+
+	w := &Writer{
+		name:          name,
+		cfg:           cfg,
+		client:        &http.Client{Timeout: timeout},
+		batchSize:     batchSize,
+		queueSize:     queueSize,
+		flushInterval: flushInterval,
+		labels:        labels,
+		logger:        logger,
+		flushNow:      make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Name identifies the target
+func (w *Writer) Name() string { return w.name }
+
+// WriteMetricPoint queues every numeric field of the point as a sample of the
+// metric ssts_<type>_<field>, labelled with its source, test and tags
+func (w *Writer) WriteMetricPoint(point models.MetricPoint) error {
+	timestamp := point.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	base := make(map[string]string, len(point.Tags)+len(w.labels)+2)
+	for _, l := range w.labels {
+		base[l.name] = l.value
+	}
+	for name, value := range point.Tags {
+		if name = labelName(name); name != "" && value != "" {
+			base[name] = value
+		}
+	}
+	if point.Source != "" {
+		base["source"] = point.Source
+	}
+	if point.TestID != "" {
+		base["test_id"] = point.TestID
+	}
+
+	samples := make([]sample, 0, len(point.Fields))
+	for field, raw := range point.Fields {
+		value, ok := numericValue(raw)
+		if !ok {
+			continue
+		}
+		labels := make([]label, 0, len(base)+1)
+		labels = append(labels, label{"__name__", metricName(point.Type, field)})
+		for key, value := range base {
+			labels = append(labels, label{key, value})
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+		samples = append(samples, sample{labels: labels, value: value, timestamp: timestamp.UnixMilli()})
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	w.mu.Lock()
+	w.queue = append(w.queue, samples...)
+	w.trim()
+	full := len(w.queue) >= w.batchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.flushNow <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// trim drops the oldest samples beyond the queue size. The caller must hold w.mu.
+func (w *Writer) trim() {
+	if excess := len(w.queue) - w.queueSize; excess > 0 {
+		w.dropped += int64(excess)
+		w.queue = append(w.queue[:0], w.queue[excess:]...)
+	}
+}
+
+// Health reports how shipping to the target is going
+func (w *Writer) Health() Health {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	health := Health{
+		Name:     w.name,
+		URL:      w.cfg.URL,
+		Healthy:  w.lastError == "" || w.lastSuccess.After(w.lastErrorAt),
+		Sent:     w.sent,
+		Rejected: w.rejected,
+		Dropped:  w.dropped,
+		Pending:  len(w.queue),
+	}
+	if !w.lastSuccess.IsZero() {
+		success := w.lastSuccess
+		health.LastSuccess = &success
+	}
+	if w.lastError != "" {
+		at := w.lastErrorAt
+		health.LastError = w.lastError
+		health.LastErrorAt = &at
+	}
+	return health
+}
+
+// Close sends what is queued and stops shipping, waiting up to timeout. It
+// reports whether everything queued was sent.
+func (w *Writer) Close(timeout time.Duration) bool {
+	w.once.Do(func() { close(w.stop) })
+	select {
+	case <-w.done:
+	case <-time.After(timeout):
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.queue) == 0
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.flushNow:
+		case <-w.stop:
+			w.flush()
+			return
+		}
+		w.flush()
+	}
+}
+
+// flush sends the queue in batches until it is empty or the target can't take
+// more for now
+func (w *Writer) flush() {
+	for {
+		w.mu.Lock()
+		n := len(w.queue)
+		if n > w.batchSize {
+			n = w.batchSize
+		}
+		batch := append([]sample(nil), w.queue[:n]...)
+		w.queue = append(w.queue[:0], w.queue[n:]...)
+		w.mu.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+
+		retry, err := w.send(batch)
+
+		w.mu.Lock()
+		switch {
+		case err == nil:
+			w.sent += int64(len(batch))
+			w.lastSuccess = time.Now()
+		case retry:
+			// Put the batch back in front of what arrived meanwhile
+			w.queue = append(batch, w.queue...)
+			w.trim()
+		default:
+			w.rejected += int64(len(batch))
+		}
+		if err != nil {
+			w.lastError = err.Error()
+			w.lastErrorAt = time.Now()
+		}
+		w.mu.Unlock()
+
+		if err != nil {
+			w.logger.Warn("Failed to ship metrics with remote write",
+				zap.String("target", w.name), zap.Int("samples", len(batch)), zap.Bool("retrying", retry), zap.Error(err))
+			if retry {
+				return
+			}
+		}
+	}
+}
+
+// send posts a batch, reporting whether a failure is worth retrying: the
+// target being unreachable, overloaded or failing, rather than refusing the data
+func (w *Writer) send(batch []sample) (bool, error) {
+	body := snappyEncode(encodeWriteRequest(groupSeries(batch)))
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("User-Agent", "ssts")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if err := w.authorize(req); err != nil {
+		return true, err
+	}
+	for name, value := range w.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	message := strings.TrimSpace(string(respBody))
+	if len(message) > 200 {
+		message = message[:200] + "..."
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("POST %s: %s: %s", w.cfg.URL, resp.Status, message)
+}
+
+// authorize adds the target's credentials to a request
+func (w *Writer) authorize(req *http.Request) error {
+	token := w.cfg.Token
+	if w.cfg.TokenFile != "" {
+		data, err := os.ReadFile(w.cfg.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	switch {
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case w.cfg.Username != "":
+		req.SetBasicAuth(w.cfg.Username, w.cfg.Password)
+	}
+	return nil
+}
+
+// metricName builds a valid Prometheus metric name, ssts_<type>_<field>
+func metricName(pointType, field string) string {
+	name := metricPrefix + field
+	if pointType != "" {
+		name = metricPrefix + pointType + "_" + field
+	}
+	return sanitize(name, true)
+}
+
+// labelName makes a tag name a valid Prometheus label name. Names reserved for
+// Prometheus' own labels, starting with two underscores, give an empty name.
+func labelName(name string) string {
+	if strings.HasPrefix(name, "__") {
+		return ""
+	}
+	return sanitize(name, false)
+}
+
+// sanitize replaces the characters Prometheus doesn't allow in names with
+// underscores; colons are only allowed in metric names
+func sanitize(name string, metric bool) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', metric && r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// numericValue converts a field value to a sample value; booleans are 0 or 1
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
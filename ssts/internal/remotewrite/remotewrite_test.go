@@ -0,0 +1,258 @@
+package remotewrite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// received is a series as decoded by the test receiver
+type received struct {
+	labels map[string]string
+	values []float64
+}
+
+func TestWriterShipsSamples(t *testing.T) {
+	var mu sync.Mutex
+	var got []received
+	var failures int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if failures < 1 {
+			failures++
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		data, err := snappyDecode(body)
+		if err != nil {
+			t.Errorf("decode: %v", err)
+			return
+		}
+		got = append(got, decodeWriteRequest(t, data)...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	writer, err := NewWriter(config.RemoteWriteConfig{
+		Name:           "mimir",
+		URL:            srv.URL,
+		Token:          "secret",
+		ExternalLabels: map[string]string{"cluster": "lab", "__bad": "x"},
+	}, 10, time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	writer.WriteMetricPoint(models.MetricPoint{
+		Timestamp: now, TestID: "t1", Source: "plugin", Type: "io",
+		Tags:   map[string]string{"device": "sda", "1st": "a"},
+		Fields: map[string]interface{}{"latency-ms": 2.5, "ok": true, "label": "text"},
+	})
+	writer.WriteMetricPoint(models.MetricPoint{
+		Timestamp: now.Add(time.Second), TestID: "t1", Source: "plugin", Type: "io",
+		Tags:   map[string]string{"device": "sda", "1st": "a"},
+		Fields: map[string]interface{}{"latency-ms": 3},
+	})
+
+	// The first flush fails and is retried
+	writer.flush()
+	if health := writer.Health(); health.Healthy || health.Pending != 3 || health.LastError == "" {
+		t.Errorf("health after failure = %+v", health)
+	}
+	if !writer.Close(5 * time.Second) {
+		t.Fatal("samples were left unsent")
+	}
+	if health := writer.Health(); !health.Healthy || health.Sent != 3 || health.Pending != 0 {
+		t.Errorf("health after retry = %+v", health)
+	}
+
+	series := make(map[string]received)
+	for _, s := range got {
+		series[s.labels["__name__"]] = s
+	}
+	latency, ok := series["ssts_io_latency_ms"]
+	if !ok || len(series) != 2 {
+		t.Fatalf("series = %+v", got)
+	}
+	if len(latency.values) != 2 || latency.values[0] != 2.5 || latency.values[1] != 3 {
+		t.Errorf("latency values = %v", latency.values)
+	}
+	want := map[string]string{"cluster": "lab", "device": "sda", "_1st": "a", "source": "plugin", "test_id": "t1"}
+	for name, value := range want {
+		if latency.labels[name] != value {
+			t.Errorf("label %s = %q, want %q", name, latency.labels[name], value)
+		}
+	}
+	if _, ok := latency.labels["__bad"]; ok {
+		t.Error("reserved external label was shipped")
+	}
+	if series["ssts_io_ok"].values[0] != 1 {
+		t.Errorf("boolean field = %v, want 1", series["ssts_io_ok"].values)
+	}
+}
+
+func TestWriterDropsRejectedAndOldest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	writer, err := NewWriter(config.RemoteWriteConfig{URL: srv.URL, QueueSize: 2}, 10, time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close(time.Second)
+
+	writer.WriteMetricPoint(models.MetricPoint{Type: "system", Fields: map[string]interface{}{"a": 1, "b": 2, "c": 3}})
+	health := writer.Health()
+	if health.Dropped != 1 || health.Pending != 2 {
+		t.Errorf("health = %+v, want the oldest sample dropped", health)
+	}
+
+	// Data the target refuses isn't retried
+	writer.flush()
+	health = writer.Health()
+	if health.Healthy || health.Rejected != 2 || health.Pending != 0 || !strings.Contains(health.LastError, "400") {
+		t.Errorf("health = %+v, want the batch rejected", health)
+	}
+}
+
+func TestNewWriterRejectsInvalidURL(t *testing.T) {
+	for _, target := range []string{"", "localhost:9090", "ftp://mimir/api/v1/push"} {
+		if _, err := NewWriter(config.RemoteWriteConfig{URL: target}, 0, 0, zap.NewNop()); err == nil {
+			t.Errorf("NewWriter(%q) succeeded", target)
+		}
+	}
+}
+
+func TestSnappyRoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		[]byte("abc"),
+		bytes.Repeat([]byte("ssts_system_cpu_usage_percent"), 5000),
+	}
+	long := make([]byte, 200000)
+	for i := range long {
+		long[i] = byte(i * 7 % 251)
+	}
+	inputs = append(inputs, long)
+
+	for _, input := range inputs {
+		output, err := snappyDecode(snappyEncode(input))
+		if err != nil || !bytes.Equal(output, input) {
+			t.Errorf("round trip of %d bytes failed: %v", len(input), err)
+		}
+	}
+}
+
+// snappyDecode decompresses the subset of the snappy block format the encoder
+// produces: literals and two-byte offset copies
+func snappyDecode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	src = src[n:]
+	dst := make([]byte, 0, length)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case 0:
+			size := int(tag >> 2)
+			src = src[1:]
+			switch size {
+			case 60:
+				size, src = int(src[0]), src[1:]
+			case 61:
+				size, src = int(src[0])|int(src[1])<<8, src[2:]
+			}
+			size++
+			dst = append(dst, src[:size]...)
+			src = src[size:]
+		case 2:
+			size := int(tag>>2) + 1
+			offset := int(src[1]) | int(src[2])<<8
+			src = src[3:]
+			if offset == 0 || offset > len(dst) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			for i := 0; i < size; i++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		default:
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	if uint64(len(dst)) != length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return dst, nil
+}
+
+// decodeWriteRequest decodes the series of a prometheus.WriteRequest message
+func decodeWriteRequest(t *testing.T, data []byte) []received {
+	var all []received
+	for _, ts := range fields(t, data) {
+		s := received{labels: make(map[string]string)}
+		for _, f := range fields(t, ts.data) {
+			inner := fields(t, f.data)
+			switch f.number {
+			case 1:
+				s.labels[string(inner[0].data)] = string(inner[1].data)
+			case 2:
+				s.values = append(s.values, math.Float64frombits(binary.LittleEndian.Uint64(inner[0].data)))
+			}
+		}
+		all = append(all, s)
+	}
+	return all
+}
+
+type field struct {
+	number int
+	data   []byte
+}
+
+// fields splits a message into its fields; varints are skipped
+func fields(t *testing.T, data []byte) []field {
+	var out []field
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		data = data[n:]
+		number := int(tag >> 3)
+		switch tag & 7 {
+		case wireVarint:
+			_, n = binary.Uvarint(data)
+			data = data[n:]
+		case wireFixed64:
+			out = append(out, field{number, data[:8]})
+			data = data[8:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			data = data[n:]
+			out = append(out, field{number, data[:size]})
+			data = data[size:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+	return out
+}
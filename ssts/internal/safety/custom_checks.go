@@ -0,0 +1,395 @@
+package safety
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of custom safety checks
+const (
+	CustomCheckCommand = "command" // runs a command, exiting as Nagios checks do
+	CustomCheckMetric  = "metric"  // compares system metrics with thresholds
+	CustomCheckWebhook = "webhook" // asks a URL for the check's status
+)
+
+// CustomViolationPrefix starts the type of violations of custom checks, which
+// is followed by the check's name
+const CustomViolationPrefix = "custom_"
+
+// defaultCustomCheckTimeout bounds command and webhook checks without a timeout
+const defaultCustomCheckTimeout = 5 * time.Second
+
+// maxCustomCheckOutput bounds the output read from a command or webhook
+const maxCustomCheckOutput = 4096
+
+// Metrics custom metric checks can compare
+const (
+	MetricCPUPercent         = "cpu_percent"
+	MetricMemoryPercent      = "memory_percent"
+	MetricDiskPercent        = "disk_percent"
+	MetricNetworkMbps        = "network_mbps"
+	MetricTemperatureCelsius = "temperature_celsius"
+)
+
+// Statuses a webhook check answers with
+const (
+	CustomStatusOK        = "ok"
+	CustomStatusViolation = "violation"
+	CustomStatusCritical  = "critical"
+)
+
+// CustomCheckDefinition configures a custom safety check
+type CustomCheckDefinition struct {
+	Name       string
+	Kind       string            // command, metric or webhook
+	Command    []string          // program and arguments
+	Expression string            // e.g. "temperature_celsius > 80 && cpu_percent < 20"
+	URL        string            // webhook answering with a WebhookCheckResponse
+	Headers    map[string]string // sent to the webhook
+	Timeout    time.Duration     // of command and webhook checks
+	Critical   bool              // violations are critical, stopping the run
+}
+
+// WebhookCheckResponse is what webhook checks answer with
+type WebhookCheckResponse struct {
+	Status  string  `json:"status"` // ok, violation or critical
+	Message string  `json:"message,omitempty"`
+	Value   float64 `json:"value,omitempty"`
+	Limit   float64 `json:"limit,omitempty"`
+}
+
+// CustomCheck is a safety check defined by the operator rather than built in.
+// Command checks exit 0 when all is well, 1 on a violation and 2 on a critical
+// one, as Nagios checks do, with the first line of their output as the message.
+// Metric checks are violated while their expression holds. Webhook checks are
+// asked for their status with a GET request. Violations are errors, or critical
+// if the check says so or is configured to be; a check that fails to run is
+// reported as a warning, so a broken check is noticed without stopping runs.
+type CustomCheck struct {
+	definition CustomCheckDefinition
+	expression metricExpression
+	client     *http.Client
+}
+
+// NewCustomCheck validates a custom check's definition
+func NewCustomCheck(definition CustomCheckDefinition) (*CustomCheck, error) {
+	if definition.Name == "" {
+		return nil, fmt.Errorf("custom check has no name")
+	}
+	if definition.Timeout <= 0 {
+		definition.Timeout = defaultCustomCheckTimeout
+	}
+	check := &CustomCheck{definition: definition}
+
+	switch definition.Kind {
+	case CustomCheckCommand:
+		if len(definition.Command) == 0 || definition.Command[0] == "" {
+			return nil, fmt.Errorf("custom check %s has no command", definition.Name)
+		}
+	case CustomCheckMetric:
+		expression, err := parseMetricExpression(definition.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("custom check %s: %w", definition.Name, err)
+		}
+		check.expression = expression
+	case CustomCheckWebhook:
+		if !strings.HasPrefix(definition.URL, "http://") && !strings.HasPrefix(definition.URL, "https://") {
+			return nil, fmt.Errorf("custom check %s: url must be an http or https URL", definition.Name)
+		}
+		check.client = &http.Client{Timeout: definition.Timeout}
+	default:
+		return nil, fmt.Errorf("custom check %s: unknown type %q: must be command, metric or webhook", definition.Name, definition.Kind)
+	}
+	return check, nil
+}
+
+// Name returns the check's name
+func (c *CustomCheck) Name() string {
+	return c.definition.Name
+}
+
+// evaluate runs the check, returning its violation or nil. Metrics are read
+// from sample.
+func (c *CustomCheck) evaluate(ctx context.Context, sample *systemSample) *Violation {
+	switch c.definition.Kind {
+	case CustomCheckCommand:
+		return c.runCommand(ctx)
+	case CustomCheckWebhook:
+		return c.askWebhook(ctx)
+	default:
+		return c.compareMetrics(sample)
+	}
+}
+
+// violation builds a violation of the check
+func (c *CustomCheck) violation(message string, value, limit float64, critical bool) *Violation {
+	critical = critical || c.definition.Critical
+	severity := SeverityError
+	if critical {
+		severity = SeverityCritical
+	}
+	return &Violation{
+		Type:         CustomViolationPrefix + c.definition.Name,
+		CurrentValue: value,
+		Limit:        limit,
+		Severity:     severity,
+		Message:      fmt.Sprintf("Custom check %s: %s", c.definition.Name, message),
+		Timestamp:    time.Now(),
+		Critical:     critical,
+	}
+}
+
+// failure reports a check that couldn't be evaluated
+func (c *CustomCheck) failure(err error) *Violation {
+	return &Violation{
+		Type:      CustomViolationPrefix + c.definition.Name,
+		Severity:  SeverityWarning,
+		Message:   fmt.Sprintf("Custom check %s failed: %v", c.definition.Name, err),
+		Timestamp: time.Now(),
+	}
+}
+
+// runCommand runs a command check
+func (c *CustomCheck) runCommand(ctx context.Context) *Violation {
+	ctx, cancel := context.WithTimeout(ctx, c.definition.Timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.definition.Command[0], c.definition.Command[1:]...)
+	cmd.Stdout = &limitedBuffer{buf: &output, limit: maxCustomCheckOutput}
+	err := cmd.Run()
+	message, _, _ := strings.Cut(strings.TrimSpace(output.String()), "\n")
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return c.failure(fmt.Errorf("timed out after %s", c.definition.Timeout))
+	case !errors.As(err, &exitErr):
+		return c.failure(err)
+	}
+
+	if message == "" {
+		message = "exited with status " + strconv.Itoa(exitErr.ExitCode())
+	}
+	switch exitErr.ExitCode() {
+	case 1:
+		return c.violation(message, 1, 0, false)
+	case 2:
+		return c.violation(message, 2, 0, true)
+	default:
+		return c.failure(fmt.Errorf("status unknown: %s", message))
+	}
+}
+
+// askWebhook asks a webhook check for its status
+func (c *CustomCheck) askWebhook(ctx context.Context) *Violation {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.definition.URL, nil)
+	if err != nil {
+		return c.failure(err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range c.definition.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return c.failure(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCustomCheckOutput))
+	if err != nil {
+		return c.failure(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return c.failure(fmt.Errorf("webhook answered %s", resp.Status))
+	}
+
+	var result WebhookCheckResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return c.failure(fmt.Errorf("invalid webhook response: %w", err))
+	}
+	message := result.Message
+	if message == "" {
+		message = result.Status
+	}
+	switch result.Status {
+	case CustomStatusOK:
+		return nil
+	case CustomStatusViolation:
+		return c.violation(message, result.Value, result.Limit, false)
+	case CustomStatusCritical:
+		return c.violation(message, result.Value, result.Limit, true)
+	default:
+		return c.failure(fmt.Errorf("invalid webhook status %q", result.Status))
+	}
+}
+
+// compareMetrics evaluates a metric check's expression
+func (c *CustomCheck) compareMetrics(sample *systemSample) *Violation {
+	held, comparison, value, err := c.expression.evaluate(sample)
+	if err != nil {
+		return c.failure(err)
+	}
+	if !held {
+		return nil
+	}
+	message := fmt.Sprintf("%s (%s is %.1f)", c.definition.Expression, comparison.metric, value)
+	return c.violation(message, value, comparison.threshold, false)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest,
+// so a chatty command can't fill memory
+type limitedBuffer struct {
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// metricComparison compares one metric with a threshold
+type metricComparison struct {
+	metric    string
+	operator  string
+	threshold float64
+}
+
+// metricExpression is a disjunction of conjunctions of comparisons, e.g.
+// "a > 1 && b < 2 || c >= 3"
+type metricExpression [][]metricComparison
+
+// comparisonPattern matches a single comparison
+var comparisonPattern = regexp.MustCompile(`^\s*([a-z_]+)\s*(>=|<=|==|!=|>|<)\s*(-?[0-9]+(?:\.[0-9]+)?)\s*$`)
+
+// parseMetricExpression parses comparisons of metrics with numbers joined by
+// && and ||, where && binds tighter
+func parseMetricExpression(text string) (metricExpression, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("expression is required")
+	}
+	var expression metricExpression
+	for _, alternative := range strings.Split(text, "||") {
+		var conjunction []metricComparison
+		for _, term := range strings.Split(alternative, "&&") {
+			match := comparisonPattern.FindStringSubmatch(term)
+			if match == nil {
+				return nil, fmt.Errorf("invalid comparison %q in expression, want e.g. cpu_percent > 90", strings.TrimSpace(term))
+			}
+			switch match[1] {
+			case MetricCPUPercent, MetricMemoryPercent, MetricDiskPercent, MetricNetworkMbps, MetricTemperatureCelsius:
+			default:
+				return nil, fmt.Errorf("unknown metric %q in expression: must be %s, %s, %s, %s or %s", match[1],
+					MetricCPUPercent, MetricMemoryPercent, MetricDiskPercent, MetricNetworkMbps, MetricTemperatureCelsius)
+			}
+			threshold, _ := strconv.ParseFloat(match[3], 64)
+			conjunction = append(conjunction, metricComparison{metric: match[1], operator: match[2], threshold: threshold})
+		}
+		expression = append(expression, conjunction)
+	}
+	return expression, nil
+}
+
+// evaluate reports whether the expression holds, with the first comparison of
+// the conjunction that held and its metric's value
+func (e metricExpression) evaluate(sample *systemSample) (bool, metricComparison, float64, error) {
+	for _, conjunction := range e {
+		held := true
+		for _, comparison := range conjunction {
+			value, err := sample.value(comparison.metric)
+			if err != nil {
+				return false, metricComparison{}, 0, fmt.Errorf("failed to read %s: %w", comparison.metric, err)
+			}
+			if !comparison.holds(value) {
+				held = false
+				break
+			}
+		}
+		if held {
+			value, _ := sample.value(conjunction[0].metric)
+			return true, conjunction[0], value, nil
+		}
+	}
+	return false, metricComparison{}, 0, nil
+}
+
+// holds reports whether the comparison holds for a value of its metric
+func (c metricComparison) holds(value float64) bool {
+	switch c.operator {
+	case ">":
+		return value > c.threshold
+	case ">=":
+		return value >= c.threshold
+	case "<":
+		return value < c.threshold
+	case "<=":
+		return value <= c.threshold
+	case "==":
+		return value == c.threshold
+	default:
+		return value != c.threshold
+	}
+}
+
+// systemSample reads each system metric at most once, so the checks of one
+// round see the same values. CPU and network usage are rates since the previous
+// reading, which a second reading moments later would skew.
+type systemSample struct {
+	monitor SystemMonitor
+	mu      sync.Mutex
+	values  map[string]float64
+	errs    map[string]error
+}
+
+func newSystemSample(monitor SystemMonitor) *systemSample {
+	return &systemSample{monitor: monitor, values: make(map[string]float64), errs: make(map[string]error)}
+}
+
+// value returns a metric's value, reading it on first use
+func (s *systemSample) value(metric string) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value, ok := s.values[metric]; ok {
+		return value, s.errs[metric]
+	}
+
+	var value float64
+	var err error
+	switch metric {
+	case MetricCPUPercent:
+		value, err = s.monitor.GetCPUUsage()
+	case MetricMemoryPercent:
+		value, err = s.monitor.GetMemoryUsage()
+	case MetricDiskPercent:
+		value, err = s.monitor.GetDiskUsage()
+	case MetricNetworkMbps:
+		value, err = s.monitor.GetNetworkUsage()
+	case MetricTemperatureCelsius:
+		value, err = s.monitor.GetSystemTemperature()
+	default:
+		err = fmt.Errorf("unknown metric %q", metric)
+	}
+	s.values[metric], s.errs[metric] = value, err
+	return value, err
+}
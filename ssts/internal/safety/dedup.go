@@ -0,0 +1,56 @@
+package safety
+
+import (
+	"sync"
+	"time"
+)
+
+// DedupAlertManager drops repeats of an alert that was already sent for the same
+// dedup key within a window, before forwarding to the next alert manager. An
+// alert of higher severity than the one last sent for its key, or one resolving
+// the condition, always goes through. Alerts without a dedup key are never dropped.
+type DedupAlertManager struct {
+	next   AlertManager
+	window time.Duration
+	mu     sync.Mutex
+	sent   map[string]Alert // last alert forwarded for each key
+}
+
+// NewDedupAlertManager wraps an alert manager with deduplication; a window of 0
+// only drops repeats of an ongoing condition at the same or lower severity
+// until the condition resolves
+func NewDedupAlertManager(next AlertManager, window time.Duration) *DedupAlertManager {
+	return &DedupAlertManager{
+		next:   next,
+		window: window,
+		sent:   make(map[string]Alert),
+	}
+}
+
+// SendAlert forwards the alert unless it repeats one recently sent for its key
+func (d *DedupAlertManager) SendAlert(alert Alert) error {
+	if alert.DedupKey == "" {
+		return d.next.SendAlert(alert)
+	}
+
+	d.mu.Lock()
+	if alert.Resolved {
+		delete(d.sent, alert.DedupKey)
+	} else if last, ok := d.sent[alert.DedupKey]; ok && d.duplicate(last, alert) {
+		d.mu.Unlock()
+		return nil
+	} else {
+		d.sent[alert.DedupKey] = alert
+	}
+	d.mu.Unlock()
+
+	return d.next.SendAlert(alert)
+}
+
+// duplicate reports whether alert repeats last
+func (d *DedupAlertManager) duplicate(last, alert Alert) bool {
+	if severityRank(alert.Severity) > severityRank(last.Severity) {
+		return false
+	}
+	return d.window == 0 || alert.Timestamp.Sub(last.Timestamp) < d.window
+}
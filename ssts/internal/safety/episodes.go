@@ -0,0 +1,189 @@
+package safety
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// episodeHistory is how long closed episodes are kept for GetEpisodes
+const episodeHistory = time.Hour
+
+// ViolationEpisode is a stretch of time during which one safety limit stayed
+// exceeded. Every sample over the limit joins the open episode for that limit,
+// and the episode closes once the limit hasn't been exceeded for the episode gap.
+// Alerts go out when an episode opens, escalates, is still ongoing after the
+// re-alert interval, and closes, rather than for every sample.
+type ViolationEpisode struct {
+	ID       string     `json:"id"`
+	Key      string     `json:"key"` // type and limit, e.g. cpu>90; also the dedup key of its alerts
+	Type     string     `json:"type"`
+	Limit    float64    `json:"limit"`
+	Peak     float64    `json:"peak"`
+	Severity Severity   `json:"severity"` // highest seen
+	Critical bool       `json:"critical"`
+	Samples  int        `json:"samples"`
+	Start    time.Time  `json:"start"`
+	LastSeen time.Time  `json:"last_seen"`
+	End      *time.Time `json:"end,omitempty"` // nil while open; the last time the limit was exceeded
+
+	alertedAt       time.Time
+	alertedSeverity Severity
+}
+
+// Duration returns how long the limit has been exceeded
+func (e *ViolationEpisode) Duration() time.Duration {
+	return e.LastSeen.Sub(e.Start)
+}
+
+// episodeKey identifies the limit a violation is of
+func episodeKey(violation *Violation) string {
+	return fmt.Sprintf("%s>%g", violation.Type, violation.Limit)
+}
+
+// severityRank orders severities from least to most severe
+func severityRank(severity Severity) int {
+	switch severity {
+	case SeverityWarning:
+		return 1
+	case SeverityError:
+		return 2
+	case SeverityCritical:
+		return 3
+	}
+	return 0
+}
+
+// observeViolation adds a violation sample to its episode, opening one if none is
+// open, and returns the alert to send, if any. The caller must hold m.mu.
+func (m *Monitor) observeViolation(violation *Violation) *Alert {
+	key := episodeKey(violation)
+	episode := m.episodes[key]
+	if episode == nil {
+		episode = &ViolationEpisode{
+			ID:    uuid.New().String(),
+			Key:   key,
+			Type:  violation.Type,
+			Limit: violation.Limit,
+			Start: violation.Timestamp,
+		}
+		m.episodes[key] = episode
+		violation.NewEpisode = true
+	}
+	violation.EpisodeID = episode.ID
+
+	episode.Samples++
+	episode.LastSeen = violation.Timestamp
+	if violation.CurrentValue > episode.Peak {
+		episode.Peak = violation.CurrentValue
+	}
+	if severityRank(violation.Severity) > severityRank(episode.Severity) {
+		episode.Severity = violation.Severity
+	}
+	episode.Critical = episode.Critical || violation.Critical
+
+	var message string
+	switch {
+	case violation.NewEpisode:
+		message = violation.Message
+	case severityRank(episode.Severity) > severityRank(episode.alertedSeverity):
+		message = fmt.Sprintf("%s, escalated to %s after %s", violation.Message, episode.Severity, episode.Duration().Round(time.Second))
+	case m.config.ReAlertInterval > 0 && violation.Timestamp.Sub(episode.alertedAt) >= m.config.ReAlertInterval:
+		message = fmt.Sprintf("%s, ongoing for %s (peak %.1f)", violation.Message, episode.Duration().Round(time.Second), episode.Peak)
+	default:
+		return nil
+	}
+
+	episode.alertedAt = violation.Timestamp
+	episode.alertedSeverity = episode.Severity
+	return &Alert{
+		Type:      violation.Type,
+		Message:   message,
+		Severity:  episode.Severity,
+		Timestamp: violation.Timestamp,
+		DedupKey:  key,
+		Metadata:  episodeMetadata(episode, violation.CurrentValue),
+	}
+}
+
+// closeStaleEpisodes closes episodes whose limit hasn't been exceeded for the
+// episode gap, returning their resolution alerts
+func (m *Monitor) closeStaleEpisodes(now time.Time) []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var alerts []Alert
+	for key, episode := range m.episodes {
+		if now.Sub(episode.LastSeen) < m.config.EpisodeGap {
+			continue
+		}
+
+		end := episode.LastSeen
+		episode.End = &end
+		delete(m.episodes, key)
+		m.closedEpisodes = append(m.closedEpisodes, *episode)
+
+		m.logger.WithFields(episodeFields(episode)).Info("Safety violation episode closed")
+		alerts = append(alerts, Alert{
+			Type:      episode.Type,
+			Message:   fmt.Sprintf("%s back within limit %.1f after %s (peak %.1f)", episode.Type, episode.Limit, episode.Duration().Round(time.Second), episode.Peak),
+			Severity:  SeverityInfo,
+			Timestamp: now,
+			DedupKey:  key,
+			Resolved:  true,
+			Metadata:  episodeMetadata(episode, 0),
+		})
+	}
+
+	cutoff := now.Add(-episodeHistory)
+	kept := m.closedEpisodes[:0]
+	for _, episode := range m.closedEpisodes {
+		if episode.End.After(cutoff) {
+			kept = append(kept, episode)
+		}
+	}
+	m.closedEpisodes = kept
+
+	return alerts
+}
+
+// GetEpisodes returns the open violation episodes and those closed in the last hour
+func (m *Monitor) GetEpisodes() []ViolationEpisode {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	episodes := make([]ViolationEpisode, 0, len(m.episodes)+len(m.closedEpisodes))
+	episodes = append(episodes, m.closedEpisodes...)
+	for _, episode := range m.episodes {
+		episodes = append(episodes, *episode)
+	}
+	return episodes
+}
+
+func episodeFields(episode *ViolationEpisode) logrus.Fields {
+	return logrus.Fields{
+		"episode_id": episode.ID,
+		"type":       episode.Type,
+		"limit":      episode.Limit,
+		"peak":       episode.Peak,
+		"severity":   episode.Severity,
+		"duration":   episode.Duration(),
+	}
+}
+
+func episodeMetadata(episode *ViolationEpisode, current float64) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"episode_id":       episode.ID,
+		"limit":            episode.Limit,
+		"peak":             episode.Peak,
+		"critical":         episode.Critical,
+		"samples":          episode.Samples,
+		"duration_seconds": episode.Duration().Seconds(),
+	}
+	if current != 0 {
+		metadata["current_value"] = current
+	}
+	return metadata
+}
@@ -0,0 +1,119 @@
+package safety
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// KernelLogPath is the kernel's ring buffer device, read one record at a time
+const KernelLogPath = "/dev/kmsg"
+
+// kernelRecordSize is enough for any /dev/kmsg record, which must be read whole
+const kernelRecordSize = 8192
+
+// KernelViolationPrefix starts the type of violations flagged in the kernel
+// log, which is followed by the kind of event
+const KernelViolationPrefix = "kernel_"
+
+// Kinds of kernel log events flagged as violations
+const (
+	KernelEventMCE     = "mce"      // machine check exceptions and EDAC memory errors
+	KernelEventIOError = "io_error" // failed block device I/O
+	KernelEventOOMKill = "oom_kill" // a process killed for lack of memory
+	KernelEventThermal = "thermal"  // throttling or critical temperatures
+)
+
+// kernelEvents recognizes kernel log messages reporting each kind of event. They
+// match one line per event where the kernel logs several, such as the "Out of
+// memory" line ending an OOM report.
+var kernelEvents = []struct {
+	kind     string
+	pattern  *regexp.Regexp
+	severity Severity
+	critical bool
+}{
+	{KernelEventMCE, regexp.MustCompile(`(?i)machine check|\[hardware error\]|EDAC .*\b(CE|UE)\b`), SeverityCritical, true},
+	{KernelEventIOError, regexp.MustCompile(`(?i)I/O error|critical medium error|ata\d+(\.\d+)?: (failed command|exception Emask)|nvme\d+: .*timeout`), SeverityError, false},
+	{KernelEventOOMKill, regexp.MustCompile(`(?i)out of memory: kill`), SeverityError, false},
+	{KernelEventThermal, regexp.MustCompile(`(?i)temperature above threshold|critical temperature|clock throttled`), SeverityWarning, false},
+}
+
+// OpenKernelLog opens the kernel log after its last record, so only messages
+// logged from then on are read. Reading it usually needs privileges.
+func OpenKernelLog() (io.ReadCloser, error) {
+	f, err := os.Open(KernelLogPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open kernel log: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to seek to the end of the kernel log: %w", err)
+	}
+	return f, nil
+}
+
+// KernelViolation returns the violation a kernel log record reports, or nil.
+// Records are read as /dev/kmsg formats them, "priority,sequence,time,flags;
+// message", or as plain messages.
+func KernelViolation(record string, now time.Time) *Violation {
+	if strings.HasPrefix(record, " ") {
+		return nil // a continuation line of key=value pairs
+	}
+	message := record
+	if _, text, found := strings.Cut(record, ";"); found {
+		message = text
+	}
+	message = strings.TrimSpace(message)
+
+	for _, event := range kernelEvents {
+		if event.pattern.MatchString(message) {
+			return &Violation{
+				Type:      KernelViolationPrefix + event.kind,
+				Severity:  event.severity,
+				Message:   message,
+				Timestamp: now,
+				Critical:  event.critical,
+			}
+		}
+	}
+	return nil
+}
+
+// WatchKernelLog reads kernel log records until the log ends or fails, which
+// closing it makes it do, calling fn with each violation they report. It returns
+// nil once the log ends or is closed.
+func WatchKernelLog(kernelLog io.Reader, fn func(Violation)) error {
+	buf := make([]byte, kernelRecordSize)
+	var pending []byte
+	for {
+		n, err := kernelLog.Read(buf)
+		pending = append(pending, buf[:n]...)
+		for {
+			end := bytes.IndexByte(pending, '\n')
+			if end < 0 {
+				break
+			}
+			if violation := KernelViolation(string(pending[:end]), time.Now()); violation != nil {
+				fn(*violation)
+			}
+			pending = pending[end+1:]
+		}
+
+		switch {
+		case err == nil:
+		case errors.Is(err, syscall.EPIPE):
+			// Records were overwritten before they were read; carry on from the oldest left
+		case errors.Is(err, io.EOF), errors.Is(err, os.ErrClosed):
+			return nil
+		default:
+			return fmt.Errorf("failed to read kernel log: %w", err)
+		}
+	}
+}
@@ -18,6 +18,12 @@ type Monitor struct {
 	config         Config
 	emergencyStop  chan string
 	violations     []Violation
+	episodes       map[string]*ViolationEpisode // open episodes by key
+	closedEpisodes []ViolationEpisode
+	customChecks   []*CustomCheck
+	customResults  map[string]*Violation // latest results of command and webhook checks by name
+	customRunning  bool
+	customRunAt    time.Time
 	mu             sync.RWMutex
 	logger         *logrus.Logger
 }
@@ -31,8 +37,13 @@ type Config struct {
 	RampUpEnabled        bool          `yaml:"ramp_up_enabled"`
 	RampUpDuration       time.Duration `yaml:"ramp_up_duration"`
 	RampUpSteps          int           `yaml:"ramp_up_steps"`
+	RampDownEnabled      bool          `yaml:"ramp_down_enabled"`
+	RampDownDuration     time.Duration `yaml:"ramp_down_duration"`
+	PostTestCooldown     time.Duration `yaml:"post_test_cooldown"`
 	CooldownPeriod       time.Duration `yaml:"cooldown_period"`
 	MaxViolationsPerMin  int           `yaml:"max_violations_per_min"`
+	EpisodeGap           time.Duration `yaml:"episode_gap"`      // an episode closes once its limit hasn't been exceeded for this long
+	ReAlertInterval      time.Duration `yaml:"realert_interval"` // how often an ongoing episode is alerted again; 0 for never
 }
 
 // SystemMonitor interface for system monitoring
@@ -58,6 +69,8 @@ type Violation struct {
 	Message      string    `json:"message"`
 	Timestamp    time.Time `json:"timestamp"`
 	Critical     bool      `json:"critical"`
+	EpisodeID    string    `json:"episode_id,omitempty"`
+	NewEpisode   bool      `json:"new_episode,omitempty"` // the first sample of its episode
 }
 
 // Severity levels for violations
@@ -78,6 +91,8 @@ type Alert struct {
 	Severity  Severity               `json:"severity"`
 	Timestamp time.Time              `json:"timestamp"`
 	Metadata  map[string]interface{} `json:"metadata"`
+	DedupKey  string                 `json:"dedup_key,omitempty"` // alerts with the same key are about the same ongoing condition
+	Resolved  bool                   `json:"resolved,omitempty"`  // the condition has cleared
 }
 
 // NewMonitor creates a new safety monitor
@@ -103,6 +118,9 @@ func NewMonitor(systemMonitor SystemMonitor, alertManager AlertManager, config C
 	if config.MaxViolationsPerMin == 0 {
 		config.MaxViolationsPerMin = 5
 	}
+	if config.EpisodeGap == 0 {
+		config.EpisodeGap = 5 * time.Second
+	}
 
 	return &Monitor{
 		systemMonitor: systemMonitor,
@@ -110,10 +128,21 @@ func NewMonitor(systemMonitor SystemMonitor, alertManager AlertManager, config C
 		config:        config,
 		emergencyStop: make(chan string, 10),
 		violations:    make([]Violation, 0),
+		episodes:      make(map[string]*ViolationEpisode),
+		customResults: make(map[string]*Violation),
 		logger:        logger,
 	}
 }
 
+// SetCustomChecks sets the custom checks evaluated with the safety limits
+func (m *Monitor) SetCustomChecks(checks []*CustomCheck) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.customChecks = checks
+	m.customResults = make(map[string]*Violation)
+	m.customRunAt = time.Time{}
+}
+
 // Start starts the safety monitoring
 func (m *Monitor) Start(ctx context.Context) {
 	ticker := time.NewTicker(m.config.CheckInterval)
@@ -131,8 +160,11 @@ func (m *Monitor) Start(ctx context.Context) {
 
 // CheckSafetyLimits checks if current system state violates safety limits
 func (m *Monitor) CheckSafetyLimits(limits models.SafetyLimits) *Violation {
+	m.closeEpisodes()
+	sample := newSystemSample(m.systemMonitor)
+
 	// Check CPU usage
-	if cpuUsage, err := m.systemMonitor.GetCPUUsage(); err == nil {
+	if cpuUsage, err := sample.value(MetricCPUPercent); err == nil {
 		if cpuUsage > limits.MaxCPUPercent {
 			violation := &Violation{
 				Type:         "cpu",
@@ -151,13 +183,13 @@ func (m *Monitor) CheckSafetyLimits(limits models.SafetyLimits) *Violation {
 				violation.Severity = SeverityWarning
 			}
 
-			m.recordViolation(*violation)
+			m.recordViolation(violation)
 			return violation
 		}
 	}
 
 	// Check memory usage
-	if memUsage, err := m.systemMonitor.GetMemoryUsage(); err == nil {
+	if memUsage, err := sample.value(MetricMemoryPercent); err == nil {
 		if memUsage > limits.MaxMemoryPercent {
 			violation := &Violation{
 				Type:         "memory",
@@ -176,13 +208,13 @@ func (m *Monitor) CheckSafetyLimits(limits models.SafetyLimits) *Violation {
 				violation.Severity = SeverityWarning
 			}
 
-			m.recordViolation(*violation)
+			m.recordViolation(violation)
 			return violation
 		}
 	}
 
 	// Check disk usage
-	if diskUsage, err := m.systemMonitor.GetDiskUsage(); err == nil {
+	if diskUsage, err := sample.value(MetricDiskPercent); err == nil {
 		if diskUsage > limits.MaxDiskPercent {
 			violation := &Violation{
 				Type:         "disk",
@@ -201,13 +233,13 @@ func (m *Monitor) CheckSafetyLimits(limits models.SafetyLimits) *Violation {
 				violation.Severity = SeverityWarning
 			}
 
-			m.recordViolation(*violation)
+			m.recordViolation(violation)
 			return violation
 		}
 	}
 
 	// Check network usage
-	if netUsage, err := m.systemMonitor.GetNetworkUsage(); err == nil {
+	if netUsage, err := sample.value(MetricNetworkMbps); err == nil {
 		if netUsage > limits.MaxNetworkMbps {
 			violation := &Violation{
 				Type:         "network",
@@ -224,16 +256,88 @@ func (m *Monitor) CheckSafetyLimits(limits models.SafetyLimits) *Violation {
 				violation.Severity = SeverityWarning
 			}
 
-			m.recordViolation(*violation)
+			m.recordViolation(violation)
 			return violation
 		}
 	}
 
-	return nil
+	return m.checkCustom(sample)
+}
+
+// checkCustom evaluates the custom checks, recording each violation and
+// returning the most severe. Metric checks are evaluated against sample; command
+// and webhook checks run in the background at most once per check interval, and
+// their latest results are reported until they run again.
+func (m *Monitor) checkCustom(sample *systemSample) *Violation {
+	m.mu.Lock()
+	checks := m.customChecks
+	var background []*CustomCheck
+	for _, check := range checks {
+		if check.definition.Kind != CustomCheckMetric {
+			background = append(background, check)
+		}
+	}
+	if len(background) > 0 && !m.customRunning && time.Since(m.customRunAt) >= m.config.CheckInterval {
+		m.customRunning = true
+		m.customRunAt = time.Now()
+		go m.runCustomChecks(background)
+	}
+	results := make(map[string]*Violation, len(m.customResults))
+	for name, violation := range m.customResults {
+		results[name] = violation
+	}
+	m.mu.Unlock()
+
+	var worst *Violation
+	for _, check := range checks {
+		var violation *Violation
+		if check.definition.Kind == CustomCheckMetric {
+			violation = check.evaluate(context.Background(), sample)
+		} else if result := results[check.Name()]; result != nil {
+			copied := *result
+			copied.Timestamp = time.Now()
+			violation = &copied
+		}
+		if violation == nil {
+			continue
+		}
+		m.recordViolation(violation)
+		if worst == nil || severityRank(violation.Severity) > severityRank(worst.Severity) {
+			worst = violation
+		}
+	}
+	return worst
+}
+
+// runCustomChecks runs command and webhook checks in parallel and keeps their results
+func (m *Monitor) runCustomChecks(checks []*CustomCheck) {
+	results := make([]*Violation, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *CustomCheck) {
+			defer wg.Done()
+			results[i] = check.evaluate(context.Background(), nil)
+		}(i, check)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.customRunning = false
+	for i, check := range checks {
+		if results[i] == nil {
+			delete(m.customResults, check.Name())
+		} else {
+			m.customResults[check.Name()] = results[i]
+		}
+	}
 }
 
 // performSafetyCheck performs a comprehensive safety check
 func (m *Monitor) performSafetyCheck() {
+	m.closeEpisodes()
+
 	// Check system health
 	if temp, err := m.systemMonitor.GetSystemTemperature(); err == nil {
 		if temp > 85.0 { // High temperature threshold
@@ -247,7 +351,7 @@ func (m *Monitor) performSafetyCheck() {
 				Critical:     temp > 90.0,
 			}
 
-			m.recordViolation(violation)
+			m.recordViolation(&violation)
 
 			if violation.Critical {
 				m.sendEmergencyStop(fmt.Sprintf("Critical temperature: %.1f°C", temp))
@@ -277,15 +381,17 @@ func (m *Monitor) performSafetyCheck() {
 				Critical:     false,
 			}
 
-			m.recordViolation(violation)
+			m.recordViolation(&violation)
 		}
 	}
 }
 
-// recordViolation records a safety violation
-func (m *Monitor) recordViolation(violation Violation) {
+// recordViolation records a safety violation, alerting only when it opens an
+// episode or the episode escalates or is due a re-alert
+func (m *Monitor) recordViolation(violation *Violation) {
 	m.mu.Lock()
-	m.violations = append(m.violations, violation)
+	alert := m.observeViolation(violation)
+	m.violations = append(m.violations, *violation)
 	
 	// Keep only recent violations (last hour)
 	cutoff := time.Now().Add(-1 * time.Hour)
@@ -298,29 +404,36 @@ func (m *Monitor) recordViolation(violation Violation) {
 	m.violations = filtered
 	m.mu.Unlock()
 
-	// Send alert
-	alert := Alert{
-		Type:      violation.Type,
-		Message:   violation.Message,
-		Severity:  violation.Severity,
-		Timestamp: violation.Timestamp,
-		Metadata: map[string]interface{}{
-			"current_value": violation.CurrentValue,
-			"limit":         violation.Limit,
-			"critical":      violation.Critical,
-		},
+	if alert != nil {
+		m.sendAlert(*alert)
 	}
 
-	if err := m.alertManager.SendAlert(alert); err != nil {
-		m.logger.WithError(err).Error("Failed to send alert")
-	}
-
-	m.logger.WithFields(logrus.Fields{
+	fields := logrus.Fields{
 		"type":          violation.Type,
 		"current_value": violation.CurrentValue,
 		"limit":         violation.Limit,
 		"severity":      violation.Severity,
-	}).Warn("Safety violation recorded")
+		"episode_id":    violation.EpisodeID,
+	}
+	if violation.NewEpisode {
+		m.logger.WithFields(fields).Warn("Safety violation episode opened")
+	} else {
+		m.logger.WithFields(fields).Debug("Safety violation recorded")
+	}
+}
+
+// closeEpisodes closes the episodes whose limits are no longer exceeded and
+// sends their resolution alerts
+func (m *Monitor) closeEpisodes() {
+	for _, alert := range m.closeStaleEpisodes(time.Now()) {
+		m.sendAlert(alert)
+	}
+}
+
+func (m *Monitor) sendAlert(alert Alert) {
+	if err := m.alertManager.SendAlert(alert); err != nil {
+		m.logger.WithError(err).Error("Failed to send alert")
+	}
 }
 
 // getRecentViolations returns violations within the specified duration
@@ -377,6 +490,10 @@ func (m *Monitor) GetSafetyStatus() SafetyStatus {
 		Timestamp:         time.Now(),
 	}
 
+	m.mu.RLock()
+	status.OpenEpisodes = len(m.episodes)
+	m.mu.RUnlock()
+
 	if len(recentViolations) > 0 {
 		status.LastViolation = &recentViolations[len(recentViolations)-1]
 		
@@ -403,6 +520,7 @@ type SafetyStatus struct {
 	Overall          string      `json:"overall"`
 	RecentViolations int         `json:"recent_violations"`
 	LastViolation    *Violation  `json:"last_violation,omitempty"`
+	OpenEpisodes     int         `json:"open_episodes"`
 	SystemHealth     SystemHealth `json:"system_health"`
 	Timestamp        time.Time   `json:"timestamp"`
 }
@@ -456,6 +574,15 @@ func (m *Monitor) CalculateRampUpIntensity(elapsed time.Duration, targetIntensit
 	return intensity
 }
 
+// GetRampDownSettings returns the default ramp-down window and post-test cooldown period.
+// Both are zero when ramp-down is disabled.
+func (m *Monitor) GetRampDownSettings() (time.Duration, time.Duration) {
+	if !m.config.RampDownEnabled {
+		return 0, 0
+	}
+	return m.config.RampDownDuration, m.config.PostTestCooldown
+}
+
 // IsInCooldownPeriod checks if system is in cooldown period after a violation
 func (m *Monitor) IsInCooldownPeriod() bool {
 	recentViolations := m.getRecentViolations(m.config.CooldownPeriod)
@@ -0,0 +1,50 @@
+package safety
+
+import "fmt"
+
+// PreflightThresholds are the limits current resource usage must be under for a
+// test to start. A zero threshold skips its check.
+type PreflightThresholds struct {
+	MaxCPUPercent    float64
+	MaxMemoryPercent float64
+	MaxDiskPercent   float64
+	MaxTemperature   float64 // degrees Celsius
+}
+
+// Preflight checks that the machine is idle enough to start a test: resource usage
+// below the thresholds and no cooldown after a safety violation active. It returns
+// a reason for every check that failed, or nil if all passed. Metrics that can't be
+// read are not held against the machine.
+func (m *Monitor) Preflight(thresholds PreflightThresholds) []string {
+	var reasons []string
+
+	if thresholds.MaxCPUPercent > 0 {
+		if cpu, err := m.systemMonitor.GetCPUUsage(); err == nil && cpu > thresholds.MaxCPUPercent {
+			reasons = append(reasons, fmt.Sprintf("CPU usage %.1f%% is above %.1f%%", cpu, thresholds.MaxCPUPercent))
+		}
+	}
+
+	if thresholds.MaxMemoryPercent > 0 {
+		if mem, err := m.systemMonitor.GetMemoryUsage(); err == nil && mem > thresholds.MaxMemoryPercent {
+			reasons = append(reasons, fmt.Sprintf("memory usage %.1f%% is above %.1f%%", mem, thresholds.MaxMemoryPercent))
+		}
+	}
+
+	if thresholds.MaxDiskPercent > 0 {
+		if disk, err := m.systemMonitor.GetDiskUsage(); err == nil && disk > thresholds.MaxDiskPercent {
+			reasons = append(reasons, fmt.Sprintf("disk usage %.1f%% is above %.1f%%", disk, thresholds.MaxDiskPercent))
+		}
+	}
+
+	if thresholds.MaxTemperature > 0 {
+		if temp, err := m.systemMonitor.GetSystemTemperature(); err == nil && temp > thresholds.MaxTemperature {
+			reasons = append(reasons, fmt.Sprintf("temperature %.1f°C is above %.1f°C", temp, thresholds.MaxTemperature))
+		}
+	}
+
+	if m.IsInCooldownPeriod() {
+		reasons = append(reasons, fmt.Sprintf("cooling down after a safety violation in the last %s", m.config.CooldownPeriod))
+	}
+
+	return reasons
+}
@@ -11,12 +11,17 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/pranavgopavaram/ssts/internal/metrics"
 )
 
 // SystemMonitorImpl implements the SystemMonitor interface
 type SystemMonitorImpl struct {
 	lastCPUStats CPUStats
 	lastCheck    time.Time
+
+	limits   metrics.ResourceLimits // what usage is a percentage of; the host's totals unless set
+	cpuMeter *metrics.CPUMeter
 }
 
 // CPUStats holds CPU statistics
@@ -36,6 +41,14 @@ func NewSystemMonitor() *SystemMonitorImpl {
 	return &SystemMonitorImpl{}
 }
 
+// SetResourceLimits makes CPU and memory usage percentages of the limits, such
+// as a container's cgroup limits, so safety limits apply to what it is allowed
+// rather than to the host's totals
+func (s *SystemMonitorImpl) SetResourceLimits(limits metrics.ResourceLimits) {
+	s.limits = limits
+	s.cpuMeter = metrics.NewCPUMeter(limits)
+}
+
 // GetCPUUsage returns current CPU usage percentage
 func (s *SystemMonitorImpl) GetCPUUsage() (float64, error) {
 	stats, err := s.readCPUStats()
@@ -49,6 +62,9 @@ func (s *SystemMonitorImpl) GetCPUUsage() (float64, error) {
 	if s.lastCheck.IsZero() {
 		s.lastCPUStats = stats
 		s.lastCheck = now
+		if s.cpuMeter != nil {
+			s.cpuMeter.Percent(0)
+		}
 		return 0, nil
 	}
 
@@ -66,6 +82,9 @@ func (s *SystemMonitorImpl) GetCPUUsage() (float64, error) {
 	s.lastCPUStats = stats
 	s.lastCheck = now
 
+	if s.cpuMeter != nil {
+		usage = s.cpuMeter.Percent(usage)
+	}
 	return usage, nil
 }
 
@@ -104,8 +123,8 @@ func (s *SystemMonitorImpl) GetMemoryUsage() (float64, error) {
 		return s.getMemoryUsageRuntime()
 	}
 
-	used := memTotal - memAvailable
-	usage := float64(used) / float64(memTotal) * 100.0
+	total, used := s.limits.Memory(memTotal, memTotal-memAvailable)
+	usage := float64(used) / float64(total) * 100.0
 
 	return usage, nil
 }
@@ -197,40 +216,10 @@ func (s *SystemMonitorImpl) GetNetworkUsage() (float64, error) {
 	return mbps, nil
 }
 
-// GetSystemTemperature returns system temperature in Celsius
+// GetSystemTemperature returns the hottest temperature sensor reading in Celsius,
+// read the same way as the temperatures the metrics collector records
 func (s *SystemMonitorImpl) GetSystemTemperature() (float64, error) {
-	// Try to read from thermal zone (Linux)
-	tempFiles := []string{
-		"/sys/class/thermal/thermal_zone0/temp",
-		"/sys/class/thermal/thermal_zone1/temp",
-	}
-
-	for _, file := range tempFiles {
-		if temp, err := s.readTemperatureFile(file); err == nil {
-			return temp, nil
-		}
-	}
-
-	// If no thermal zone found, return a safe default
-	return 35.0, nil
-}
-
-// readTemperatureFile reads temperature from a thermal zone file
-func (s *SystemMonitorImpl) readTemperatureFile(filename string) (float64, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return 0, err
-	}
-
-	tempStr := strings.TrimSpace(string(data))
-	tempMilliC, err := strconv.ParseFloat(tempStr, 64)
-	if err != nil {
-		return 0, err
-	}
-
-	// Convert from millicelsius to celsius
-	tempC := tempMilliC / 1000.0
-	return tempC, nil
+	return metrics.MaxTemperature()
 }
 
 // readCPUStats reads CPU statistics from /proc/stat
@@ -0,0 +1,241 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+)
+
+// keyClient fetches master keys from Vault and KMS
+var keyClient = &http.Client{Timeout: 10 * time.Second}
+
+// Load returns a keyring using the master key from the configured source, or
+// nil when encryption is disabled
+func Load(ctx context.Context, cfg config.EncryptionConfig) (*Keyring, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var encoded string
+	var err error
+	switch cfg.KeySource {
+	case "env":
+		encoded = os.Getenv(cfg.KeyEnv)
+		if encoded == "" {
+			err = fmt.Errorf("%s is not set", cfg.KeyEnv)
+		}
+	case "file":
+		var data []byte
+		data, err = os.ReadFile(cfg.KeyFile)
+		encoded = string(data)
+	case "vault":
+		encoded, err = vaultKey(ctx, cfg.Vault)
+	case "kms":
+		encoded, err = kmsKey(ctx, cfg.KMS)
+	default:
+		err = fmt.Errorf("unknown key source %q", cfg.KeySource)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("master key from %s isn't base64: %w", cfg.KeySource, err)
+	}
+	return NewKeyring(key)
+}
+
+// vaultKey reads the base64 master key from a Vault KV version 2 secret
+func vaultKey(ctx context.Context, cfg config.VaultKeyConfig) (string, error) {
+	address := cfg.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if address == "" || token == "" {
+		return "", fmt.Errorf("vault address and VAULT_TOKEN are required")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+"/v1/"+strings.TrimPrefix(cfg.Path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := doJSON(req, &secret); err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	key, ok := secret.Data.Data[cfg.Field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %s", cfg.Path, cfg.Field)
+	}
+	return key, nil
+}
+
+// kmsKey decrypts the master key with AWS KMS, returning it base64 encoded
+func kmsKey(ctx context.Context, cfg config.KMSKeyConfig) (string, error) {
+	ciphertext, err := os.ReadFile(cfg.CiphertextFile)
+	if err != nil {
+		return "", err
+	}
+	creds := awsCredentials{
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return "", fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+	if cfg.Region == "" {
+		return "", fmt.Errorf("kms region is required")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + cfg.Region + ".amazonaws.com/"
+	}
+
+	body, err := json.Marshal(map[string]string{"CiphertextBlob": strings.TrimSpace(string(ciphertext))})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+	signAWS(req, body, cfg.Region, "kms", creds, time.Now())
+
+	var decrypted struct {
+		Plaintext string `json:"Plaintext"`
+	}
+	if err := doJSON(req, &decrypted); err != nil {
+		return "", fmt.Errorf("kms: %w", err)
+	}
+	return decrypted.Plaintext, nil
+}
+
+// doJSON sends a request and decodes its JSON response
+func doJSON(req *http.Request, v interface{}) error {
+	resp, err := keyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// The body may echo the request, so only its status is reported
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// signAWS signs a request with AWS Signature Version 4
+func signAWS(req *http.Request, body []byte, region, service string, creds awsCredentials, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name, as signatures require
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, awsEscape(name)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything but unreserved characters
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
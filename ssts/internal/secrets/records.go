@@ -0,0 +1,118 @@
+package secrets
+
+import (
+	"encoding/json"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// EncryptConfig encrypts every secret in a plugin configuration
+func (k *Keyring) EncryptConfig(raw json.RawMessage) (json.RawMessage, error) {
+	return transformConfig(raw, k.Encrypt)
+}
+
+// DecryptConfig decrypts the secrets EncryptConfig encrypted
+func (k *Keyring) DecryptConfig(raw json.RawMessage) (json.RawMessage, error) {
+	return transformConfig(raw, k.Decrypt)
+}
+
+// EncryptTest encrypts the secrets in a test configuration: those in its
+// plugin configuration and its secret probe targets. The slices it changes are
+// replaced rather than modified, so copies of the test are left as they were.
+func (k *Keyring) EncryptTest(test *models.TestConfiguration) error {
+	config, err := k.EncryptConfig(test.Config)
+	if err != nil {
+		return err
+	}
+	probes, err := transformProbes(test.Probes, func(probe models.VictimProbe) (string, error) {
+		if !secretProbe(probe) {
+			return probe.Target, nil
+		}
+		return k.Encrypt(probe.Target)
+	})
+	if err != nil {
+		return err
+	}
+	test.Config, test.Probes = config, probes
+	return nil
+}
+
+// DecryptTest decrypts the secrets EncryptTest encrypted
+func (k *Keyring) DecryptTest(test *models.TestConfiguration) error {
+	config, err := k.DecryptConfig(test.Config)
+	if err != nil {
+		return err
+	}
+	probes, err := transformProbes(test.Probes, func(probe models.VictimProbe) (string, error) {
+		return k.Decrypt(probe.Target)
+	})
+	if err != nil {
+		return err
+	}
+	test.Config, test.Probes = config, probes
+	return nil
+}
+
+func transformProbes(probes models.VictimProbes, target func(models.VictimProbe) (string, error)) (models.VictimProbes, error) {
+	if probes == nil {
+		return nil, nil
+	}
+	transformed := make(models.VictimProbes, len(probes))
+	for i, probe := range probes {
+		var err error
+		if probe.Target, err = target(probe); err != nil {
+			return nil, err
+		}
+		transformed[i] = probe
+	}
+	return transformed, nil
+}
+
+// EncryptUser encrypts the webhook URLs and push endpoint in a user's
+// notification preferences
+func (k *Keyring) EncryptUser(user *models.User) error {
+	return k.transformUser(user, k.Encrypt)
+}
+
+// DecryptUser decrypts what EncryptUser encrypted
+func (k *Keyring) DecryptUser(user *models.User) error {
+	return k.transformUser(user, k.Decrypt)
+}
+
+func (k *Keyring) transformUser(user *models.User, fn func(string) (string, error)) error {
+	if len(user.Preferences) == 0 {
+		return nil
+	}
+	prefs, err := user.NotificationPreferences()
+	if err != nil {
+		return err
+	}
+	slack, err := fn(prefs.SlackWebhookURL)
+	if err != nil {
+		return err
+	}
+	webhook, err := fn(prefs.WebhookURL)
+	if err != nil {
+		return err
+	}
+	var push *models.WebPushSubscription
+	if prefs.WebPush != nil {
+		endpoint, err := fn(prefs.WebPush.Endpoint)
+		if err != nil {
+			return err
+		}
+		if endpoint != prefs.WebPush.Endpoint {
+			transformed := *prefs.WebPush
+			transformed.Endpoint = endpoint
+			push = &transformed
+		}
+	}
+	if slack == prefs.SlackWebhookURL && webhook == prefs.WebhookURL && push == nil {
+		return nil
+	}
+	prefs.SlackWebhookURL, prefs.WebhookURL = slack, webhook
+	if push != nil {
+		prefs.WebPush = push
+	}
+	return user.SetNotificationPreferences(prefs)
+}
@@ -0,0 +1,335 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Redacted replaces secrets in API responses and logs. A client sending it back
+// in place of a secret, as when updating a test it fetched, keeps the secret.
+const Redacted = "********"
+
+// secretSuffixes end the names of configuration and log fields holding secrets,
+// e.g. password, db_password, client_secret and target_dsn, or lists of them,
+// e.g. api_keys
+var secretSuffixes = []string{
+	"password", "passwd", "secret", "token", "api_key", "apikey", "access_key",
+	"private_key", "credential", "dsn", "connection_string",
+}
+
+// IsSecretKey reports whether a configuration or log field of this name holds
+// a secret
+func IsSecretKey(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(strings.ReplaceAll(name, "-", "_")), "s")
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactConfig replaces every secret in a plugin configuration with Redacted
+func RedactConfig(raw json.RawMessage) json.RawMessage {
+	redacted, err := transformConfig(raw, func(string) (string, error) { return Redacted, nil })
+	if err != nil {
+		return raw
+	}
+	return redacted
+}
+
+// RestoreConfig puts the stored secrets back in an updated configuration where
+// the update has Redacted in their place
+func RestoreConfig(updated, stored json.RawMessage) json.RawMessage {
+	var u, s interface{}
+	if decodeJSON(updated, &u) != nil || decodeJSON(stored, &s) != nil {
+		return updated
+	}
+	restored, changed := restoreValue(u, s, false)
+	if !changed {
+		return updated
+	}
+	data, err := json.Marshal(restored)
+	if err != nil {
+		return updated
+	}
+	return data
+}
+
+// restoreValue returns u with Redacted strings under secret keys replaced by
+// the string at the same place in s
+func restoreValue(u, s interface{}, secret bool) (interface{}, bool) {
+	switch u := u.(type) {
+	case string:
+		if stored, ok := s.(string); ok && secret && u == Redacted {
+			return stored, true
+		}
+	case map[string]interface{}:
+		stored, _ := s.(map[string]interface{})
+		changed := false
+		for key, value := range u {
+			restored, ok := restoreValue(value, stored[key], secret || IsSecretKey(key))
+			if ok {
+				u[key] = restored
+				changed = true
+			}
+		}
+		return u, changed
+	case []interface{}:
+		stored, _ := s.([]interface{})
+		changed := false
+		for i, value := range u {
+			var storedValue interface{}
+			if i < len(stored) {
+				storedValue = stored[i]
+			}
+			restored, ok := restoreValue(value, storedValue, secret)
+			if ok {
+				u[i] = restored
+				changed = true
+			}
+		}
+		return u, changed
+	}
+	return u, false
+}
+
+// transformConfig applies fn to every string under a secret key in a JSON
+// configuration, at any depth. Configurations without secrets are returned
+// unchanged; others are re-encoded, which sorts their keys.
+func transformConfig(raw json.RawMessage, fn func(string) (string, error)) (json.RawMessage, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+	var value interface{}
+	if err := decodeJSON(raw, &value); err != nil {
+		return nil, err
+	}
+	transformed, changed, err := transformValue(value, false, fn)
+	if err != nil || !changed {
+		return raw, err
+	}
+	return json.Marshal(transformed)
+}
+
+func transformValue(value interface{}, secret bool, fn func(string) (string, error)) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case string:
+		if !secret {
+			return v, false, nil
+		}
+		transformed, err := fn(v)
+		return transformed, transformed != v, err
+	case map[string]interface{}:
+		changed := false
+		for key, item := range v {
+			transformed, ok, err := transformValue(item, secret || IsSecretKey(key), fn)
+			if err != nil {
+				return nil, false, err
+			}
+			v[key] = transformed
+			changed = changed || ok
+		}
+		return v, changed, nil
+	case []interface{}:
+		changed := false
+		for i, item := range v {
+			transformed, ok, err := transformValue(item, secret, fn)
+			if err != nil {
+				return nil, false, err
+			}
+			v[i] = transformed
+			changed = changed || ok
+		}
+		return v, changed, nil
+	}
+	return value, false, nil
+}
+
+// decodeJSON decodes keeping numbers as they were written, so re-encoding
+// doesn't change them
+func decodeJSON(raw json.RawMessage, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// secretProbe reports whether a probe's target is a secret: a command line,
+// which may carry a database password, or a URL with a password
+func secretProbe(probe models.VictimProbe) bool {
+	if probe.Type == models.ProbeCommand {
+		return true
+	}
+	parsed, err := url.Parse(probe.Target)
+	if err != nil {
+		return false
+	}
+	_, hasPassword := parsed.User.Password()
+	return hasPassword
+}
+
+// RedactProbes returns the probes with secret targets replaced by Redacted
+func RedactProbes(probes models.VictimProbes) models.VictimProbes {
+	if probes == nil {
+		return nil
+	}
+	redacted := make(models.VictimProbes, len(probes))
+	for i, probe := range probes {
+		if secretProbe(probe) {
+			probe.Target = Redacted
+		}
+		redacted[i] = probe
+	}
+	return redacted
+}
+
+// RestoreProbes puts back the stored target of each updated probe whose target
+// is Redacted, matching probes by name
+func RestoreProbes(updated, stored models.VictimProbes) models.VictimProbes {
+	if updated == nil {
+		return nil
+	}
+	targets := make(map[string]string, len(stored))
+	for _, probe := range stored {
+		targets[probe.Name] = probe.Target
+	}
+	restored := make(models.VictimProbes, len(updated))
+	for i, probe := range updated {
+		if target, ok := targets[probe.Name]; ok && probe.Target == Redacted {
+			probe.Target = target
+		}
+		restored[i] = probe
+	}
+	return restored
+}
+
+// RedactURL keeps a URL's scheme and host, replacing the rest, where webhook
+// URLs carry their secret, with Redacted
+func RedactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return Redacted
+	}
+	return parsed.Scheme + "://" + parsed.Host + "/" + Redacted
+}
+
+// RedactNotificationPreferences redacts the webhook URLs and push endpoint in
+// notification preferences. The push subscription's keys are left as they are,
+// since nothing can be sent without the endpoint.
+func RedactNotificationPreferences(prefs models.NotificationPreferences) models.NotificationPreferences {
+	prefs.SlackWebhookURL = RedactURL(prefs.SlackWebhookURL)
+	prefs.WebhookURL = RedactURL(prefs.WebhookURL)
+	if prefs.WebPush != nil {
+		push := *prefs.WebPush
+		push.Endpoint = RedactURL(push.Endpoint)
+		prefs.WebPush = &push
+	}
+	return prefs
+}
+
+// RestoreNotificationPreferences puts back the stored webhook URLs and push
+// endpoint where the updated preferences have them as redacted
+func RestoreNotificationPreferences(updated, stored models.NotificationPreferences) models.NotificationPreferences {
+	if updated.SlackWebhookURL != "" && updated.SlackWebhookURL == RedactURL(stored.SlackWebhookURL) {
+		updated.SlackWebhookURL = stored.SlackWebhookURL
+	}
+	if updated.WebhookURL != "" && updated.WebhookURL == RedactURL(stored.WebhookURL) {
+		updated.WebhookURL = stored.WebhookURL
+	}
+	// The push endpoint is only put back for the subscription it belongs to
+	if push := updated.WebPush; push != nil && stored.WebPush != nil && push.Keys == stored.WebPush.Keys &&
+		push.Endpoint != "" && push.Endpoint == RedactURL(stored.WebPush.Endpoint) {
+		restored := *push
+		restored.Endpoint = stored.WebPush.Endpoint
+		updated.WebPush = &restored
+	}
+	return updated
+}
+
+// RedactQuery redacts the values of secret parameters in a URL query string
+func RedactQuery(raw string) string {
+	if raw == "" {
+		return raw
+	}
+	query, err := url.ParseQuery(raw)
+	if err != nil {
+		return Redacted
+	}
+	changed := false
+	for name, values := range query {
+		if IsSecretKey(name) {
+			for i := range values {
+				values[i] = Redacted
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return raw
+	}
+	return query.Encode()
+}
+
+// EncryptedTestFields returns the fields of a test configuration that hold what
+// looks like a value a Keyring encrypted, such as "config.target.password" or
+// "probes.db.target". Clients can't supply those: they would be stored as they
+// are, and fail to decrypt when read.
+func EncryptedTestFields(test models.TestConfiguration) []string {
+	var fields []string
+	var config interface{}
+	if len(test.Config) > 0 && decodeJSON(test.Config, &config) == nil {
+		fields = encryptedPaths(config, "config", false, fields)
+	}
+	for _, probe := range test.Probes {
+		if IsEncrypted(probe.Target) {
+			fields = append(fields, "probes."+probe.Name+".target")
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// encryptedPaths appends the paths of the encrypted-looking strings under
+// secret keys in a decoded JSON value
+func encryptedPaths(value interface{}, path string, secret bool, fields []string) []string {
+	switch v := value.(type) {
+	case string:
+		if secret && IsEncrypted(v) {
+			fields = append(fields, path)
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			fields = encryptedPaths(item, path+"."+key, secret || IsSecretKey(key), fields)
+		}
+	case []interface{}:
+		for i, item := range v {
+			fields = encryptedPaths(item, path+"["+strconv.Itoa(i)+"]", secret, fields)
+		}
+	}
+	return fields
+}
+
+// EncryptedPreferenceFields returns the fields of notification preferences that
+// hold what looks like a value a Keyring encrypted, as EncryptedTestFields does
+func EncryptedPreferenceFields(prefs models.NotificationPreferences) []string {
+	var fields []string
+	if IsEncrypted(prefs.SlackWebhookURL) {
+		fields = append(fields, "slack_webhook_url")
+	}
+	if IsEncrypted(prefs.WebhookURL) {
+		fields = append(fields, "webhook_url")
+	}
+	if prefs.WebPush != nil && IsEncrypted(prefs.WebPush.Endpoint) {
+		fields = append(fields, "web_push.endpoint")
+	}
+	return fields
+}
@@ -0,0 +1,156 @@
+// Package secrets protects secrets the server keeps: it encrypts them before
+// they are stored in the database and redacts them from API responses and logs.
+//
+// Encryption is envelope encryption. Each value is encrypted with AES-256-GCM
+// under a data key of its own, and the data key is stored beside it encrypted
+// with the master key, which never leaves the server's memory. An encrypted
+// value is a string of the form
+//
+//	enc:v1:<master key ID>:<encrypted data key>:<encrypted value>
+//
+// so it fits wherever the plaintext did, including inside JSON columns.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks a value encrypted by a Keyring
+const prefix = "enc:v1:"
+
+// MasterKeySize is the size of a master key in bytes
+const MasterKeySize = 32
+
+// ErrWrongKey is returned when decrypting a value encrypted with another master key
+var ErrWrongKey = errors.New("value was encrypted with a different master key")
+
+// Keyring encrypts and decrypts values with a master key
+type Keyring struct {
+	id  string // identifies the master key in encrypted values, without revealing it
+	kek cipher.AEAD
+}
+
+// NewKeyring returns a keyring using a 32-byte master key
+func NewKeyring(masterKey []byte) (*Keyring, error) {
+	if len(masterKey) != MasterKeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", MasterKeySize, len(masterKey))
+	}
+	kek, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(append([]byte("ssts master key id:"), masterKey...))
+	return &Keyring{id: hex.EncodeToString(sum[:4]), kek: kek}, nil
+}
+
+// Encrypt encrypts a value under a new data key. Empty and already encrypted
+// values are returned as they are, so values from clients must be checked with
+// EncryptedTestFields or EncryptedPreferenceFields first.
+func (k *Keyring) Encrypt(value string) (string, error) {
+	if value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+
+	dataKey := make([]byte, MasterKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	dek, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(k.kek, dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dek, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return prefix + k.id + ":" + encode(wrappedKey) + ":" + encode(ciphertext), nil
+}
+
+// Decrypt decrypts a value encrypted by Encrypt. Values that aren't encrypted,
+// such as those stored before encryption was enabled, are returned as they are.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	if parts[0] != k.id {
+		return "", fmt.Errorf("%w (key %s)", ErrWrongKey, parts[0])
+	}
+	wrappedKey, err := decode(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	ciphertext, err := decode(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+
+	dataKey, err := open(k.kek, wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	dek, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dek, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether a value was encrypted by a Keyring
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext, returning the nonce followed by the ciphertext
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts what seal returned
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
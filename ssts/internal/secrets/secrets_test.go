@@ -0,0 +1,336 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/internal/config"
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func testKeyring(t *testing.T, seed byte) *Keyring {
+	t.Helper()
+	keys, err := NewKeyring(bytes.Repeat([]byte{seed}, MasterKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestKeyring(t *testing.T) {
+	keys := testKeyring(t, 1)
+
+	encrypted, err := keys.Encrypt("postgres://stress:hunter2@db:5432/load")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "hunter2") {
+		t.Fatalf("encrypted = %q", encrypted)
+	}
+	if again, _ := keys.Encrypt("postgres://stress:hunter2@db:5432/load"); again == encrypted {
+		t.Error("encrypting twice gave the same ciphertext; data keys and nonces must be fresh")
+	}
+	if same, _ := keys.Encrypt(encrypted); same != encrypted {
+		t.Error("encrypting an encrypted value encrypted it again")
+	}
+
+	tampered := []byte(encrypted)
+	tampered[len(tampered)-2] ^= 1
+	tests := []struct {
+		name    string
+		keys    *Keyring
+		value   string
+		want    string
+		wantErr error
+	}{
+		{"round trip", keys, encrypted, "postgres://stress:hunter2@db:5432/load", nil},
+		{"stored before encryption", keys, "plaintext", "plaintext", nil},
+		{"empty", keys, "", "", nil},
+		{"other master key", testKeyring(t, 2), encrypted, "", ErrWrongKey},
+		{"tampered", keys, string(tampered), "", errors.New("any")},
+		{"malformed", keys, prefix + "abc", "", errors.New("any")},
+	}
+	for _, tt := range tests {
+		got, err := tt.keys.Decrypt(tt.value)
+		if (err != nil) != (tt.wantErr != nil) || (tt.wantErr == ErrWrongKey && !errors.Is(err, ErrWrongKey)) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%s: decrypted = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := NewKeyring([]byte("short")); err == nil {
+		t.Error("NewKeyring accepted a 5-byte key")
+	}
+}
+
+func TestIsSecretKey(t *testing.T) {
+	for name, want := range map[string]bool{
+		"password":          true,
+		"db_password":       true,
+		"client-secret":     true,
+		"API_KEY":           true,
+		"auth_token":        true,
+		"target_dsn":        true,
+		"connection_string": true,
+		"credentials":       true,
+		"api_keys":          true,
+		"threads":           false,
+		"token_count":       false,
+		"username":          false,
+	} {
+		if got := IsSecretKey(name); got != want {
+			t.Errorf("IsSecretKey(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestConfigSecrets(t *testing.T) {
+	keys := testKeyring(t, 1)
+	config := json.RawMessage(`{"threads":4,"rate":1e6,"target":{"host":"db","password":"hunter2"},"hosts":["a"],"api_keys":["k1","k2"]}`)
+
+	encrypted, err := keys.EncryptConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(encrypted, []byte("hunter2")) || bytes.Contains(encrypted, []byte(`"k1"`)) {
+		t.Errorf("encrypted config = %s", encrypted)
+	}
+	if !bytes.Contains(encrypted, []byte(`"rate":1e6`)) || !bytes.Contains(encrypted, []byte(`"hosts":["a"]`)) {
+		t.Errorf("encrypting changed values that aren't secrets: %s", encrypted)
+	}
+	decrypted, err := keys.DecryptConfig(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameJSON(t, decrypted, config)
+
+	redacted := RedactConfig(config)
+	assertSameJSON(t, redacted, json.RawMessage(`{"threads":4,"rate":1e6,"target":{"host":"db","password":"********"},"hosts":["a"],"api_keys":["********","********"]}`))
+
+	// A client sending the redacted config back with changes keeps the secrets
+	updated := json.RawMessage(`{"threads":8,"target":{"host":"db2","password":"********"},"api_keys":["********","new"]}`)
+	assertSameJSON(t, RestoreConfig(updated, config), json.RawMessage(`{"threads":8,"target":{"host":"db2","password":"hunter2"},"api_keys":["k1","new"]}`))
+
+	for _, raw := range []string{"", `{"threads":4}`, `not json`} {
+		if got := RedactConfig(json.RawMessage(raw)); string(got) != raw {
+			t.Errorf("RedactConfig(%q) = %q", raw, got)
+		}
+	}
+}
+
+func assertSameJSON(t *testing.T, got, want json.RawMessage) {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("%s: %v", got, err)
+	}
+	json.Unmarshal(want, &w)
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestTestSecrets(t *testing.T) {
+	keys := testKeyring(t, 1)
+	test := models.TestConfiguration{
+		Config: json.RawMessage(`{"dsn":"postgres://u:p@db/x"}`),
+		Probes: models.VictimProbes{
+			{Name: "web", Type: models.ProbeHTTP, Target: "http://localhost/health"},
+			{Name: "admin", Type: models.ProbeHTTP, Target: "https://admin:pw@localhost/"},
+			{Name: "db", Type: models.ProbeCommand, Target: "psql -c 'select 1'"},
+		},
+	}
+	original := test
+
+	if err := keys.EncryptTest(&test); err != nil {
+		t.Fatal(err)
+	}
+	if IsEncrypted(test.Probes[0].Target) || !IsEncrypted(test.Probes[1].Target) || !IsEncrypted(test.Probes[2].Target) {
+		t.Errorf("encrypted probes = %+v", test.Probes)
+	}
+	if original.Probes[2].Target != "psql -c 'select 1'" {
+		t.Error("encrypting modified the probes of a copy of the test")
+	}
+	if err := keys.DecryptTest(&test); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(test.Probes, original.Probes) || string(test.Config) != string(original.Config) {
+		t.Errorf("decrypted test = %+v", test)
+	}
+
+	redacted := RedactProbes(original.Probes)
+	if redacted[0].Target != "http://localhost/health" || redacted[1].Target != Redacted || redacted[2].Target != Redacted {
+		t.Errorf("redacted probes = %+v", redacted)
+	}
+	if restored := RestoreProbes(redacted, original.Probes); !reflect.DeepEqual(restored, original.Probes) {
+		t.Errorf("restored probes = %+v", restored)
+	}
+}
+
+func TestUserSecrets(t *testing.T) {
+	keys := testKeyring(t, 1)
+	prefs := models.NotificationPreferences{
+		Enabled:         true,
+		Scope:           models.NotifyScopeOwn,
+		Channels:        []string{models.ChannelSlack},
+		SlackWebhookURL: "https://hooks.slack.com/services/T0/B0/secret",
+	}
+	user := models.User{Preferences: json.RawMessage(`{"theme":"dark"}`)}
+	if err := user.SetNotificationPreferences(prefs); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keys.EncryptUser(&user); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(user.Preferences, []byte("secret")) || !bytes.Contains(user.Preferences, []byte(`"theme":"dark"`)) {
+		t.Errorf("encrypted preferences = %s", user.Preferences)
+	}
+	if err := keys.DecryptUser(&user); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := user.NotificationPreferences(); got.SlackWebhookURL != prefs.SlackWebhookURL {
+		t.Errorf("decrypted Slack URL = %q", got.SlackWebhookURL)
+	}
+
+	redacted := RedactNotificationPreferences(prefs)
+	if redacted.SlackWebhookURL != "https://hooks.slack.com/********" || redacted.WebhookURL != "" {
+		t.Errorf("redacted = %+v", redacted)
+	}
+	if restored := RestoreNotificationPreferences(redacted, prefs); restored.SlackWebhookURL != prefs.SlackWebhookURL {
+		t.Errorf("restored Slack URL = %q", restored.SlackWebhookURL)
+	}
+	redacted.SlackWebhookURL = "https://hooks.slack.com/services/new"
+	if restored := RestoreNotificationPreferences(redacted, prefs); restored.SlackWebhookURL != "https://hooks.slack.com/services/new" {
+		t.Errorf("a changed URL was replaced by the stored one: %q", restored.SlackWebhookURL)
+	}
+
+	// Push endpoints are redacted and restored like the webhook URLs
+	prefs.WebPush = &models.WebPushSubscription{Endpoint: "https://push.example.com/send/secret-token"}
+	prefs.WebPush.Keys.Auth = "tBHItJI5svbpez7KI4CCXg"
+	redacted = RedactNotificationPreferences(prefs)
+	if redacted.WebPush.Endpoint != "https://push.example.com/********" || prefs.WebPush.Endpoint == redacted.WebPush.Endpoint {
+		t.Errorf("redacted push endpoint = %q, stored %q", redacted.WebPush.Endpoint, prefs.WebPush.Endpoint)
+	}
+	if restored := RestoreNotificationPreferences(redacted, prefs); restored.WebPush.Endpoint != prefs.WebPush.Endpoint {
+		t.Errorf("restored push endpoint = %q", restored.WebPush.Endpoint)
+	}
+	user = models.User{}
+	user.SetNotificationPreferences(prefs)
+	if err := keys.EncryptUser(&user); err != nil || bytes.Contains(user.Preferences, []byte("secret-token")) {
+		t.Errorf("encrypted preferences = %s, %v", user.Preferences, err)
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct{ raw, want string }{
+		{"", ""},
+		{"limit=10&sort=-created", "limit=10&sort=-created"},
+		{"limit=10&token=abc", "limit=10&token=%2A%2A%2A%2A%2A%2A%2A%2A"},
+		{"api_key=a&api_key=b", "api_key=%2A%2A%2A%2A%2A%2A%2A%2A&api_key=%2A%2A%2A%2A%2A%2A%2A%2A"},
+	}
+	for _, tt := range tests {
+		if got := RedactQuery(tt.raw); got != tt.want {
+			t.Errorf("RedactQuery(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, MasterKeySize)
+	encoded := base64.StdEncoding.EncodeToString(key)
+	want := testKeyring(t, 7)
+
+	keyFile := filepath.Join(t.TempDir(), "master.key")
+	os.WriteFile(keyFile, []byte(encoded+"\n"), 0o600)
+	ciphertextFile := filepath.Join(t.TempDir(), "master.key.kms")
+	os.WriteFile(ciphertextFile, []byte("Y2lwaGVydGV4dA=="), 0o600)
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/ssts" || r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": map[string]string{"key": encoded}}})
+	}))
+	defer vault.Close()
+
+	kms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Target") != "TrentService.Decrypt" || string(body) != `{"CiphertextBlob":"Y2lwaGVydGV4dA=="}` ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Plaintext": encoded})
+	}))
+	defer kms.Close()
+
+	t.Setenv("SSTS_TEST_MASTER_KEY", encoded)
+	t.Setenv("SSTS_TEST_BAD_KEY", "not base64!")
+	t.Setenv("VAULT_TOKEN", "vault-token")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	tests := []struct {
+		name    string
+		cfg     config.EncryptionConfig
+		wantErr bool
+	}{
+		{"env", config.EncryptionConfig{Enabled: true, KeySource: "env", KeyEnv: "SSTS_TEST_MASTER_KEY"}, false},
+		{"env unset", config.EncryptionConfig{Enabled: true, KeySource: "env", KeyEnv: "SSTS_TEST_UNSET_KEY"}, true},
+		{"env not base64", config.EncryptionConfig{Enabled: true, KeySource: "env", KeyEnv: "SSTS_TEST_BAD_KEY"}, true},
+		{"file", config.EncryptionConfig{Enabled: true, KeySource: "file", KeyFile: keyFile}, false},
+		{"missing file", config.EncryptionConfig{Enabled: true, KeySource: "file", KeyFile: keyFile + ".missing"}, true},
+		{"vault", config.EncryptionConfig{Enabled: true, KeySource: "vault", Vault: config.VaultKeyConfig{Address: vault.URL, Path: "secret/data/ssts", Field: "key"}}, false},
+		{"vault field missing", config.EncryptionConfig{Enabled: true, KeySource: "vault", Vault: config.VaultKeyConfig{Address: vault.URL, Path: "secret/data/ssts", Field: "other"}}, true},
+		{"vault refused", config.EncryptionConfig{Enabled: true, KeySource: "vault", Vault: config.VaultKeyConfig{Address: vault.URL, Path: "secret/data/other", Field: "key"}}, true},
+		{"kms", config.EncryptionConfig{Enabled: true, KeySource: "kms", KMS: config.KMSKeyConfig{Region: "eu-west-1", Endpoint: kms.URL, CiphertextFile: ciphertextFile}}, false},
+		{"kms without region", config.EncryptionConfig{Enabled: true, KeySource: "kms", KMS: config.KMSKeyConfig{Endpoint: kms.URL, CiphertextFile: ciphertextFile}}, true},
+		{"unknown source", config.EncryptionConfig{Enabled: true, KeySource: "hsm"}, true},
+	}
+	for _, tt := range tests {
+		keys, err := Load(context.Background(), tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && keys.id != want.id {
+			t.Errorf("%s: loaded a different master key", tt.name)
+		}
+	}
+
+	if keys, err := Load(context.Background(), config.EncryptionConfig{}); keys != nil || err != nil {
+		t.Errorf("disabled: Load = %v, %v", keys, err)
+	}
+}
+
+func TestSignAWS(t *testing.T) {
+	// The example from AWS's Signature Version 4 documentation for an IAM
+	// ListUsers request
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAWS(req, nil, "us-east-1", "iam", creds, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s\nwant %s", got, want)
+	}
+}
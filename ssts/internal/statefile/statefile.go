@@ -0,0 +1,122 @@
+// Package statefile keeps small state files that must survive a crash of the
+// server, such as the markers recording host settings a test changed so a later
+// process can restore them.
+//
+// The files live in a directory only the server's user can write, are never read
+// or written through symlinks, and are replaced atomically. Since their contents
+// drive privileged writes on recovery, a file another user could have planted is
+// refused rather than trusted.
+package statefile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Dir is the directory state files are kept in. It is on tmpfs on most systems,
+// which suits markers for settings the kernel also forgets on reboot.
+var Dir = "/run/ssts"
+
+// maxSize bounds how much of a state file is read
+const maxSize = 1 << 20
+
+// Path returns the path of a state file in Dir
+func Path(name string) string {
+	return filepath.Join(Dir, name)
+}
+
+// Write atomically replaces the state file at path, creating its directory if needed
+func Write(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := checkDir(dir); err != nil {
+		return err
+	}
+
+	// A fresh temporary file, which can't be a link planted beforehand, renamed
+	// over the old one; rename replaces a link rather than following it
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	os.Remove(tmp)
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL|noFollow, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Read returns the contents of the state file at path. It fails with an error
+// wrapping os.ErrNotExist when there is none, and refuses files that are links,
+// aren't regular files or belong to another user.
+func Read(path string) ([]byte, error) {
+	if err := checkDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDONLY|noFollow, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("state file %s is not a regular file", path)
+	}
+	if err := checkOwner(info); err != nil {
+		return nil, fmt.Errorf("state file %s: %w", path, err)
+	}
+
+	return io.ReadAll(io.LimitReader(file, maxSize))
+}
+
+// Remove deletes the state file at path; a missing file is not an error
+func Remove(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// checkDir verifies dir is a real directory owned by this user that no one else
+// can write to. A missing directory passes, since it holds no state.
+func checkDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("state directory %s is not a directory", dir)
+	}
+	if info.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("state directory %s is writable by other users", dir)
+	}
+	if err := checkOwner(info); err != nil {
+		return fmt.Errorf("state directory %s: %w", dir, err)
+	}
+	return nil
+}
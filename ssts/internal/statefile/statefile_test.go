@@ -0,0 +1,93 @@
+//go:build !windows
+
+package statefile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	path := filepath.Join(dir, "marker.json")
+
+	if _, err := Read(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Read before Write: error = %v, want os.ErrNotExist", err)
+	}
+	if err := Write(path, []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(path, []byte(`{"a":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := Read(path)
+	if err != nil || string(data) != `{"a":2}` {
+		t.Fatalf("Read = %q, %v", data, err)
+	}
+
+	info, _ := os.Stat(dir)
+	if info.Mode().Perm() != 0o700 {
+		t.Errorf("state directory mode = %v, want 0700", info.Mode().Perm())
+	}
+	if err := Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(path); err != nil {
+		t.Errorf("removing a missing file: %v", err)
+	}
+}
+
+func TestRefusesLinksAndSharedDirs(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(t.TempDir(), "target")
+	os.WriteFile(target, []byte("original"), 0o644)
+
+	// A planted link is neither read through nor written through
+	link := filepath.Join(dir, "marker.json")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(link); err == nil {
+		t.Error("Read followed a symlink")
+	}
+	if err := Write(link, []byte("replaced")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "original" {
+		t.Errorf("Write followed a symlink: target = %q", data)
+	}
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink != 0 {
+		t.Error("Write left the symlink in place")
+	}
+
+	// A directory other users can write to can't be trusted
+	shared := t.TempDir()
+	os.Chmod(shared, 0o777)
+	if err := Write(filepath.Join(shared, "marker.json"), []byte("{}")); err == nil {
+		t.Error("Write accepted a world-writable directory")
+	}
+	os.WriteFile(filepath.Join(shared, "planted.json"), []byte("{}"), 0o600)
+	if _, err := Read(filepath.Join(shared, "planted.json")); err == nil {
+		t.Error("Read accepted a world-writable directory")
+	}
+
+	// The state directory itself must not be a link
+	linkedDir := filepath.Join(t.TempDir(), "linked")
+	os.Symlink(dir, linkedDir)
+	if _, err := Read(filepath.Join(linkedDir, "marker.json")); err == nil {
+		t.Error("Read accepted a symlinked state directory")
+	}
+}
+
+func TestProcessAlive(t *testing.T) {
+	if !ProcessAlive(os.Getpid()) {
+		t.Error("ProcessAlive(self) = false")
+	}
+	for _, pid := range []int{0, -1, 1 << 30} {
+		if ProcessAlive(pid) {
+			t.Errorf("ProcessAlive(%d) = true", pid)
+		}
+	}
+}
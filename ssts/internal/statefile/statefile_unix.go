@@ -0,0 +1,33 @@
+//go:build !windows
+
+package statefile
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// noFollow makes opening a state file fail if it is a symlink
+const noFollow = syscall.O_NOFOLLOW
+
+// checkOwner verifies a file belongs to the user this process runs as
+func checkOwner(info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("owned by uid %d, not %d", stat.Uid, os.Geteuid())
+	}
+	return nil
+}
+
+// ProcessAlive reports whether a process with the given PID exists
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package statefile
+
+import "os"
+
+// noFollow is not needed on Windows, where creating symlinks takes a privilege
+const noFollow = 0
+
+// checkOwner accepts any file; ownership isn't exposed through os.FileInfo on Windows
+func checkOwner(info os.FileInfo) error {
+	return nil
+}
+
+// ProcessAlive reports whether a process with the given PID exists
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,198 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// projectHeader selects the project requests act on, matching the API server
+const projectHeader = "X-Project-ID"
+
+// client talks to an ssts server over its REST API and WebSocket stream
+type client struct {
+	baseURL *url.URL
+	token   string
+	project string
+	http    *http.Client
+
+	mu    sync.Mutex
+	tests map[string]*models.TestConfiguration // test configurations by ID; they rarely change during a run
+}
+
+func newClient(server, token, project string) (*client, error) {
+	base, err := url.Parse(strings.TrimSuffix(server, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server URL %q: scheme must be http or https", server)
+	}
+
+	return &client{
+		baseURL: base,
+		token:   token,
+		project: project,
+		http:    &http.Client{Timeout: 10 * time.Second},
+		tests:   make(map[string]*models.TestConfiguration),
+	}, nil
+}
+
+// header returns the headers sent with every request
+func (c *client) header() http.Header {
+	header := make(http.Header)
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.project != "" {
+		header.Set(projectHeader, c.project)
+	}
+	return header
+}
+
+// get decodes the JSON response of a GET request into out, returning the
+// server's error message for non-2xx responses
+func (c *client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header = c.header()
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&body) == nil && body.Error != "" {
+			return fmt.Errorf("GET %s: %s", path, body.Error)
+		}
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *client) systemMetrics(ctx context.Context) (models.SystemMetrics, error) {
+	var metrics models.SystemMetrics
+	err := c.get(ctx, "/api/v1/system/metrics", nil, &metrics)
+	return metrics, err
+}
+
+func (c *client) runningExecutions(ctx context.Context) ([]models.TestExecution, error) {
+	var executions []models.TestExecution
+	err := c.get(ctx, "/api/v1/executions/running", nil, &executions)
+	return executions, err
+}
+
+// test returns a test configuration, fetching it once and caching it after
+func (c *client) test(ctx context.Context, id string) (*models.TestConfiguration, error) {
+	c.mu.Lock()
+	test, ok := c.tests[id]
+	c.mu.Unlock()
+	if ok {
+		return test, nil
+	}
+
+	test = &models.TestConfiguration{}
+	if err := c.get(ctx, "/api/v1/tests/"+url.PathEscape(id), nil, test); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.tests[id] = test
+	c.mu.Unlock()
+	return test, nil
+}
+
+func (c *client) recentAlerts(ctx context.Context, limit int) ([]models.Alert, error) {
+	query := url.Values{}
+	query.Set("limit", fmt.Sprint(limit))
+	query.Set("sort", "-timestamp")
+
+	var alerts []models.Alert
+	err := c.get(ctx, "/api/v1/alerts", query, &alerts)
+	return alerts, err
+}
+
+// streamMessage is a message broadcast over the server's WebSocket
+type streamMessage struct {
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// stream reads the server's WebSocket until ctx is done, reconnecting after
+// errors. Messages are passed to onMessage and connection changes to onState.
+func (c *client) stream(ctx context.Context, onMessage func(streamMessage), onState func(connected bool, err error)) {
+	u := *c.baseURL
+	u.Path += "/ws"
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+
+	backoff := time.Second
+	for ctx.Err() == nil {
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), c.header())
+		if err != nil {
+			onState(false, err)
+		} else {
+			backoff = time.Second
+			onState(true, nil)
+
+			// Closing the connection unblocks ReadMessage when ctx is cancelled
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+					conn.Close()
+				case <-done:
+				}
+			}()
+
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					if ctx.Err() == nil {
+						onState(false, err)
+					}
+					break
+				}
+				var message streamMessage
+				if json.Unmarshal(data, &message) == nil {
+					onMessage(message)
+				}
+			}
+			close(done)
+			conn.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// executionView is a running execution as shown on the dashboard
+type executionView struct {
+	ID      string
+	Test    string
+	Plugin  string
+	Status  models.ExecutionStatus
+	Started *time.Time
+	Planned time.Duration // zero when unknown
+	Elapsed time.Duration // run time before the last resume, for soak runs
+
+	Progress *models.ExecutionProgress // as reported by the server; nil when it reports none
+}
+
+// progress estimates the elapsed run time and the fraction of the planned
+// duration it covers, or -1 when there is no planned duration, for servers
+// that don't report progress
+func (e executionView) progress(now time.Time) (time.Duration, float64) {
+	var elapsed time.Duration
+	if e.Started != nil {
+		elapsed = now.Sub(*e.Started)
+	}
+	if e.Elapsed > elapsed {
+		elapsed = e.Elapsed
+	}
+	if e.Planned <= 0 {
+		return elapsed, -1
+	}
+	fraction := float64(elapsed) / float64(e.Planned)
+	if fraction > 1 {
+		fraction = 1
+	}
+	return elapsed, fraction
+}
+
+// state is everything the dashboard shows
+type state struct {
+	server     string
+	system     *models.SystemMetrics
+	executions []executionView
+	alerts     []models.Alert
+	logs       []string // oldest first, at most logLines
+	logLines   int
+	connected  bool
+	lastError  string
+}
+
+// log appends a line to the log tail, dropping the oldest past the limit
+func (s *state) log(at time.Time, format string, args ...interface{}) {
+	s.logs = append(s.logs, at.Format("15:04:05")+" "+fmt.Sprintf(format, args...))
+	if len(s.logs) > s.logLines {
+		s.logs = s.logs[len(s.logs)-s.logLines:]
+	}
+}
+
+// logMessage adds a line for a WebSocket message worth an operator's attention.
+// Metric updates arrive every collection interval and aren't logged.
+func (s *state) logMessage(message streamMessage) {
+	at := message.Timestamp.Local()
+	switch message.Type {
+	case "alert":
+		var data struct {
+			Type     string `json:"type"`
+			Message  string `json:"message"`
+			Severity string `json:"severity"`
+		}
+		if json.Unmarshal(message.Data, &data) == nil {
+			s.log(at, "%s %s: %s", strings.ToUpper(data.Severity), data.Type, data.Message)
+		}
+	case "test_update":
+		var data struct {
+			TestID string `json:"test_id"`
+			Status string `json:"status"`
+		}
+		if json.Unmarshal(message.Data, &data) == nil {
+			s.log(at, "test %s %s", shortID(data.TestID), data.Status)
+		}
+	case "annotation":
+		var data struct {
+			Action     string            `json:"action"`
+			Annotation models.Annotation `json:"annotation"`
+		}
+		if json.Unmarshal(message.Data, &data) == nil {
+			s.log(at, "annotation %s on execution %s: %s", data.Action, shortID(data.Annotation.ExecutionID), data.Annotation.Text)
+		}
+	}
+}
+
+// Dashboard layout
+const (
+	barWidth       = 20
+	maxAlertLines  = 5
+	minLogLines    = 3
+	fixedLineCount = 8 // header, blank, SYSTEM and its 2 lines, blank, EXECUTIONS heading, blank before violations
+)
+
+// render draws the dashboard as width x height lines of text
+func render(s *state, now time.Time, width, height int) string {
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, truncate(fmt.Sprintf(format, args...), width))
+	}
+
+	stream := "connected"
+	if !s.connected {
+		stream = "disconnected"
+	}
+	add("ssts top - %s   %s   stream %s", s.server, now.Format("15:04:05"), stream)
+	if s.lastError != "" {
+		add("error: %s", s.lastError)
+	} else {
+		add("")
+	}
+
+	add("SYSTEM")
+	if s.system == nil {
+		add("  waiting for metrics...")
+		add("")
+	} else {
+		m := s.system
+		add("  CPU %s %5.1f%%   MEM %s %5.1f%% (%s / %s)",
+			progressBar(m.CPU.UsagePercent/100, barWidth), m.CPU.UsagePercent,
+			progressBar(m.Memory.UsagePercent/100, barWidth), m.Memory.UsagePercent,
+			formatBytes(m.Memory.UsedBytes), formatBytes(m.Memory.TotalBytes))
+		temp := "n/a"
+		if m.Thermal.MaxCelsius > 0 {
+			temp = fmt.Sprintf("%.1fC", m.Thermal.MaxCelsius)
+		}
+		add("  DISK r %s/s w %s/s   NET rx %s/s tx %s/s   TEMP %s",
+			formatBytes(m.Disk.ReadBytesPerSec), formatBytes(m.Disk.WriteBytesPerSec),
+			formatBytes(m.Network.RxBytesPerSec), formatBytes(m.Network.TxBytesPerSec), temp)
+	}
+	add("")
+
+	// Executions get what's left after the fixed lines, violations and a minimal log
+	alertLines := len(s.alerts)
+	if alertLines == 0 {
+		alertLines = 1
+	} else if alertLines > maxAlertLines {
+		alertLines = maxAlertLines
+	}
+	room := height - fixedLineCount - (alertLines + 1) - (minLogLines + 1)
+	if room < 1 {
+		room = 1
+	}
+
+	add("EXECUTIONS (%d)", len(s.executions))
+	executions := s.executions
+	if len(executions) > room {
+		executions = executions[:room-1]
+	}
+	for _, execution := range executions {
+		progress := ""
+		if p := execution.Progress; p != nil {
+			progress = fmt.Sprintf("%s %3.0f%% %-9s %s, %s left", progressBar(p.Percent/100, barWidth), p.Percent,
+				p.Phase, formatDuration(p.Elapsed), formatDuration(p.Remaining))
+		} else if execution.Status == models.StatusRunning {
+			elapsed, fraction := execution.progress(now)
+			if fraction >= 0 {
+				progress = fmt.Sprintf("%s %3.0f%% %s / %s", progressBar(fraction, barWidth), fraction*100,
+					formatDuration(elapsed), formatDuration(execution.Planned))
+			} else {
+				progress = formatDuration(elapsed)
+			}
+		}
+		add("  %-8s %-20s %-12s %-8s %s", shortID(execution.ID), truncate(execution.Test, 20),
+			truncate(execution.Plugin, 12), execution.Status, progress)
+	}
+	if len(executions) < len(s.executions) {
+		add("  ... %d more", len(s.executions)-len(executions))
+	}
+	if len(s.executions) == 0 {
+		add("  none")
+	}
+	add("")
+
+	add("RECENT VIOLATIONS")
+	if len(s.alerts) == 0 {
+		add("  none")
+	}
+	for i, alert := range s.alerts {
+		if i == alertLines {
+			break
+		}
+		add("  %s %-8s %-15s %s", alert.Timestamp.Local().Format("15:04:05"), alert.Severity, alert.Type, alert.Message)
+	}
+	add("")
+
+	add("LOG")
+	logRoom := height - len(lines)
+	if logRoom < minLogLines {
+		logRoom = minLogLines
+	}
+	logs := s.logs
+	if len(logs) > logRoom {
+		logs = logs[len(logs)-logRoom:]
+	}
+	for _, line := range logs {
+		add("  %s", line)
+	}
+
+	if len(lines) > height && height > 0 {
+		lines = lines[:height]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// progressBar draws fraction (0-1) of a bar width characters wide, brackets included
+func progressBar(fraction float64, width int) string {
+	inner := width - 2
+	if inner < 1 {
+		inner = 1
+	}
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction*float64(inner) + 0.5)
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", inner-filled) + "]"
+}
+
+// formatBytes formats a byte count with a binary unit, e.g. 1.5 GB
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value := float64(bytes)
+	suffixes := []string{"KB", "MB", "GB", "TB", "PB"}
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", value, suffixes[i])
+}
+
+// formatDuration formats a duration to the second, e.g. 1h2m3s
+func formatDuration(d time.Duration) string {
+	return d.Truncate(time.Second).String()
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// truncate cuts s to at most width runes
+func truncate(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width])
+}
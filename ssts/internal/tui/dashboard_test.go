@@ -0,0 +1,79 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		fraction float64
+		want     string
+	}{
+		{0, "[..........]"},
+		{0.5, "[#####.....]"},
+		{1, "[##########]"},
+		{1.7, "[##########]"},
+		{-1, "[..........]"},
+	}
+	for _, tt := range tests {
+		if got := progressBar(tt.fraction, 12); got != tt.want {
+			t.Errorf("progressBar(%v) = %q, want %q", tt.fraction, got, tt.want)
+		}
+	}
+}
+
+func TestRenderDashboard(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	started := now.Add(-5 * time.Minute)
+	s := &state{
+		server:   "http://rig-7:8080",
+		logLines: 2,
+		system: &models.SystemMetrics{
+			CPU:    models.CPUMetrics{UsagePercent: 50},
+			Memory: models.MemoryMetrics{UsagePercent: 25, UsedBytes: 4 << 30, TotalBytes: 16 << 30},
+		},
+		executions: []executionView{
+			{ID: "0123456789abcdef", Test: "burn-in", Plugin: "cpu-stress", Status: models.StatusRunning, Started: &started, Planned: 10 * time.Minute},
+			{ID: "fedcba9876543210", Test: "queued-test", Plugin: "io-stress", Status: models.StatusQueued},
+		},
+		alerts: []models.Alert{
+			{Type: "temperature", Severity: "critical", Message: "CPU at 95C", Timestamp: now},
+		},
+		connected: true,
+	}
+	for _, line := range []string{"first", "second", "third"} {
+		s.log(now, "%s", line)
+	}
+
+	out := render(s, now, 100, 40)
+	for _, want := range []string{
+		"stream connected",
+		"4.0 GB / 16.0 GB",
+		"EXECUTIONS (2)",
+		"01234567 burn-in",
+		"50% 5m0s / 10m0s",
+		"queued",
+		"critical temperature",
+		"12:00:00 third",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dashboard is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "first") {
+		t.Errorf("log tail kept more than %d lines:\n%s", s.logLines, out)
+	}
+
+	for i, line := range strings.Split(render(s, now, 30, 12), "\n") {
+		if len([]rune(line)) > 30 {
+			t.Errorf("line %d is wider than the terminal: %q", i, line)
+		}
+	}
+	if lines := strings.Count(render(s, now, 100, 12), "\n") + 1; lines > 12 {
+		t.Errorf("dashboard has %d lines, want at most 12", lines)
+	}
+}
@@ -0,0 +1,24 @@
+package tui
+
+import (
+	"os"
+	"strconv"
+)
+
+// Size used when the terminal size can't be determined
+const (
+	defaultWidth  = 120
+	defaultHeight = 40
+)
+
+// envTerminalSize reads the terminal size from $COLUMNS and $LINES
+func envTerminalSize() (width, height int) {
+	width, height = defaultWidth, defaultHeight
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		width = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 0 {
+		height = n
+	}
+	return width, height
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package tui
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminalSize returns the size of the terminal on stdout, falling back to
+// $COLUMNS and $LINES when stdout isn't a terminal
+func terminalSize() (width, height int) {
+	if ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ); err == nil && ws.Col > 0 && ws.Row > 0 {
+		return int(ws.Col), int(ws.Row)
+	}
+	return envTerminalSize()
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package tui
+
+// terminalSize returns the terminal size given by $COLUMNS and $LINES
+func terminalSize() (width, height int) {
+	return envTerminalSize()
+}
@@ -0,0 +1,194 @@
+// Package tui implements "ssts top", a terminal dashboard of a running ssts
+// server for machines without a browser, such as headless burn-in rigs. It
+// talks to the server only through its REST API and WebSocket stream.
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pranavgopavaram/ssts/pkg/models"
+)
+
+// Options configures the dashboard
+type Options struct {
+	Server   string        // server base URL, e.g. http://localhost:8080
+	Token    string        // sent as a bearer token when set
+	Project  string        // project ID; the server's default project when empty
+	Interval time.Duration // how often the dashboard polls the REST API
+	LogLines int           // log lines kept for the log tail
+	Out      io.Writer     // where the dashboard is drawn, normally a terminal
+}
+
+// ANSI escape sequences used to draw the dashboard
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	hideCursor  = "\x1b[?25l"
+	showCursor  = "\x1b[?25h"
+)
+
+// Run draws the dashboard until ctx is done
+func Run(ctx context.Context, opts Options) error {
+	if opts.Interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	if opts.LogLines <= 0 {
+		opts.LogLines = 200
+	}
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+
+	c, err := newClient(opts.Server, opts.Token, opts.Project)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	s := &state{server: opts.Server, logLines: opts.LogLines}
+
+	draw := func() {
+		width, height := terminalSize()
+		mu.Lock()
+		frame := render(s, time.Now(), width, height)
+		mu.Unlock()
+		fmt.Fprint(opts.Out, clearScreen+frame)
+	}
+
+	go c.stream(ctx, func(message streamMessage) {
+		mu.Lock()
+		s.logMessage(message)
+		mu.Unlock()
+	}, func(connected bool, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if connected {
+			s.log(time.Now(), "stream connected")
+		} else {
+			s.log(time.Now(), "stream disconnected: %v", err)
+		}
+		s.connected = connected
+	})
+
+	fmt.Fprint(opts.Out, hideCursor)
+	defer fmt.Fprint(opts.Out, showCursor+"\n")
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		refreshErr := refresh(ctx, c, s, &mu)
+		if ctx.Err() != nil {
+			return nil
+		}
+		mu.Lock()
+		if refreshErr != nil {
+			if s.lastError != refreshErr.Error() {
+				s.log(time.Now(), "refresh failed: %v", refreshErr)
+			}
+			s.lastError = refreshErr.Error()
+		} else {
+			s.lastError = ""
+		}
+		mu.Unlock()
+		draw()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh polls the REST API and updates the state with what it got, returning
+// the first error. Sections that failed keep their previous contents.
+func refresh(ctx context.Context, c *client, s *state, mu *sync.Mutex) error {
+	var firstErr error
+	record := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	system, err := c.systemMetrics(ctx)
+	record(err)
+	if err == nil {
+		mu.Lock()
+		s.system = &system
+		mu.Unlock()
+	}
+
+	executions, err := c.runningExecutions(ctx)
+	record(err)
+	if err == nil {
+		views := make([]executionView, 0, len(executions))
+		for _, execution := range executions {
+			views = append(views, executionViewOf(ctx, c, execution))
+		}
+		mu.Lock()
+		s.executions = views
+		mu.Unlock()
+	}
+
+	alerts, err := c.recentAlerts(ctx, maxAlertLines)
+	record(err)
+	if err == nil {
+		mu.Lock()
+		s.alerts = alerts
+		mu.Unlock()
+	}
+
+	return firstErr
+}
+
+// executionViewOf describes an execution for the dashboard, looking up its
+// test for the name and planned duration. Soak runs carry their own duration.
+func executionViewOf(ctx context.Context, c *client, execution models.TestExecution) executionView {
+	view := executionView{
+		ID:       execution.ID,
+		Test:     execution.TestID,
+		Status:   execution.Status,
+		Started:  execution.StartTime,
+		Elapsed:  execution.Elapsed,
+		Progress: execution.Progress,
+	}
+	if test, err := c.test(ctx, execution.TestID); err == nil {
+		view.Test = test.Name
+		view.Plugin = test.Plugin
+		view.Planned = test.Duration
+	}
+	if execution.Soak && len(execution.Params) > 0 {
+		var params models.TestParams
+		if json.Unmarshal(execution.Params, &params) == nil && params.Duration > 0 {
+			view.Planned = params.Duration
+		}
+	}
+	return view
+}
+
+// Main runs "ssts top" with the command-line arguments following "top"
+func Main(args []string) error {
+	flags := flag.NewFlagSet("top", flag.ContinueOnError)
+	opts := Options{}
+	flags.StringVar(&opts.Server, "server", "http://localhost:8080", "ssts server URL")
+	flags.StringVar(&opts.Token, "token", os.Getenv("SSTS_TOKEN"), "API token (default $SSTS_TOKEN)")
+	flags.StringVar(&opts.Project, "project", "", "project ID (default: the server's default project)")
+	flags.DurationVar(&opts.Interval, "interval", 2*time.Second, "refresh interval")
+	flags.IntVar(&opts.LogLines, "log-lines", 200, "log lines to keep")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return Run(ctx, opts)
+}
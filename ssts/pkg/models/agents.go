@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// AgentTokenPrefix starts every agent token, so leaked tokens are easy to recognise
+const AgentTokenPrefix = "ssts_agent_"
+
+// AgentToken authenticates a remote agent or sidecar pushing metrics to the
+// ingestion endpoint. Only a hash of the token is stored; the token itself is
+// returned once, when it is created. Points the agent pushes belong to Project.
+type AgentToken struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Name      string     `json:"name" gorm:"uniqueIndex;not null"`
+	ProjectID string     `json:"project_id" gorm:"type:uuid;index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	CreatedBy string     `json:"created_by"`
+	Created   time.Time  `json:"created" gorm:"autoCreateTime"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	// Reported by the agent in its last version handshake
+	AgentVersion    string     `json:"agent_version,omitempty"`
+	ProtocolVersion int        `json:"protocol_version,omitempty"`
+	Platform        string     `json:"platform,omitempty"` // os/arch
+	LastHandshake   *time.Time `json:"last_handshake,omitempty"`
+}
+
+// AgentHandshake is what an agent reports about itself in a version handshake
+type AgentHandshake struct {
+	Version         string
+	ProtocolVersion int
+	Platform        string
+	At              time.Time
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// AnnotationByAnomalyDetector is the CreatedBy of annotations marking anomalies
+// the detector found in an execution's live metrics
+const AnnotationByAnomalyDetector = "anomaly-detector"
+
+// AnomalyKind is the kind of unexpected change an anomaly is
+type AnomalyKind string
+
+const (
+	AnomalyThroughputDrop AnomalyKind = "throughput_drop"
+	AnomalyLatencySpike   AnomalyKind = "latency_spike"
+)
+
+// Anomaly is a live metric value of a running execution that strayed from the
+// metric's recent moving average by more than the configured number of
+// standard deviations
+type Anomaly struct {
+	ExecutionID string            `json:"execution_id"`
+	TestID      string            `json:"test_id"`
+	Kind        AnomalyKind       `json:"kind"`
+	Metric      string            `json:"metric"` // source.type.field
+	Tags        map[string]string `json:"tags,omitempty"`
+	Value       float64           `json:"value"`
+	Expected    float64           `json:"expected"` // moving average before the value
+	StdDev      float64           `json:"std_dev"`
+	ZScore      float64           `json:"z_score"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+// Message describes the anomaly, e.g. "plugin.io.iops dropped to 120 (expected 950, z=-6.1)"
+func (a Anomaly) Message() string {
+	change := "spiked"
+	if a.Kind == AnomalyThroughputDrop {
+		change = "dropped"
+	}
+	return fmt.Sprintf("%s %s to %.4g (expected %.4g, z=%.1f)", a.Metric, change, a.Value, a.Expected, a.ZScore)
+}
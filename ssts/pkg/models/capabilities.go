@@ -0,0 +1,13 @@
+package models
+
+// PluginCapabilities describe what a plugin can do while it runs and what it
+// needs from the machine, so callers can offer only the controls that work with
+// it. The first three follow from the interfaces the plugin implements; the
+// others are declared by the plugin or, for external plugins, its definition.
+type PluginCapabilities struct {
+	Pause        bool `json:"supports_pause"`    // runs can be paused and resumed
+	Throttle     bool `json:"supports_throttle"` // load can be varied while running, e.g. by a load profile
+	Progress     bool `json:"supports_progress"` // reports its own progress and phase
+	Destructive  bool `json:"destructive"`       // may destroy data on the machine, e.g. by writing to raw devices
+	RequiresRoot bool `json:"requires_root"`     // only runs with root privileges
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// maxCIValueLength bounds each CI metadata value
+const maxCIValueLength = 256
+
+// CIMetadata identifies the build a run was started for, such as the commit and
+// pipeline of a CI job, so its results can be traced back to the code or
+// firmware version under test
+type CIMetadata struct {
+	Commit      string `json:"commit,omitempty" gorm:"index"`       // revision under test, e.g. a git SHA or firmware version
+	PipelineURL string `json:"pipeline_url,omitempty"`              // link to the pipeline or job that started the run
+	BuildNumber string `json:"build_number,omitempty" gorm:"index"` // of the build under test
+}
+
+// Empty reports whether no CI metadata is given
+func (m *CIMetadata) Empty() bool {
+	return m == nil || (m.Commit == "" && m.PipelineURL == "" && m.BuildNumber == "")
+}
+
+// Validate checks CI metadata given with a run
+func (m *CIMetadata) Validate() error {
+	if m == nil {
+		return nil
+	}
+	for name, value := range map[string]string{"commit": m.Commit, "pipeline_url": m.PipelineURL, "build_number": m.BuildNumber} {
+		if len(value) > maxCIValueLength {
+			return fmt.Errorf("ci %s must be at most %d characters", name, maxCIValueLength)
+		}
+	}
+	if m.PipelineURL != "" {
+		if u, err := url.Parse(m.PipelineURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("ci pipeline_url must be an http or https URL")
+		}
+	}
+	return nil
+}
+
+// Tags returns the metadata as metric tags, ci_commit, ci_pipeline_url and
+// ci_build_number, leaving out those not given
+func (m *CIMetadata) Tags() map[string]string {
+	tags := make(map[string]string)
+	if m == nil {
+		return tags
+	}
+	for name, value := range map[string]string{"ci_commit": m.Commit, "ci_pipeline_url": m.PipelineURL, "ci_build_number": m.BuildNumber} {
+		if value != "" {
+			tags[name] = value
+		}
+	}
+	return tags
+}
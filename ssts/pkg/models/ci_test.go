@@ -0,0 +1,32 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCIMetadata(t *testing.T) {
+	var none *CIMetadata
+	if !none.Empty() || none.Validate() != nil || len(none.Tags()) != 0 {
+		t.Error("missing CI metadata isn't empty and valid")
+	}
+
+	ci := &CIMetadata{Commit: "9f2c41d", BuildNumber: "412"}
+	if err := ci.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	if want := map[string]string{"ci_commit": "9f2c41d", "ci_build_number": "412"}; !reflect.DeepEqual(ci.Tags(), want) {
+		t.Errorf("tags = %v, want %v", ci.Tags(), want)
+	}
+
+	for _, invalid := range []CIMetadata{
+		{PipelineURL: "ci.example.com/jobs/412"},
+		{PipelineURL: "javascript:alert(1)"},
+		{Commit: strings.Repeat("a", maxCIValueLength+1)},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// DeviceEndurance is the cumulative write load tests have put on a storage
+// device, tracked across executions so it can be compared with the device's
+// rated endurance (TBW)
+type DeviceEndurance struct {
+	ID              string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Device          string    `json:"device" gorm:"uniqueIndex;not null"` // serial number, or device name when it has none
+	BytesWritten    int64     `json:"bytes_written"`
+	Executions      int       `json:"executions"`
+	LastExecutionID string    `json:"last_execution_id"`
+	Created         time.Time `json:"created" gorm:"autoCreateTime"`
+	Updated         time.Time `json:"updated" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// HostStatus is what a host of the fleet is doing, as far as the server knows
+type HostStatus string
+
+const (
+	HostTesting HostStatus = "testing" // running executions, or an agent on it pushing metrics for a test
+	HostOnline  HostStatus = "online"  // seen recently, idle
+	HostStale   HostStatus = "stale"   // not seen for a few minutes
+	HostOffline HostStatus = "offline" // not seen for a long time, or never
+	HostRevoked HostStatus = "revoked" // its agent's token was revoked
+)
+
+// Fleet is every host the server knows of: the one it runs on, those with an
+// agent pushing metrics, and those executions were recorded on
+type Fleet struct {
+	Hosts     []FleetHost        `json:"hosts"`
+	Counts    map[HostStatus]int `json:"counts"`
+	Generated time.Time          `json:"generated"`
+}
+
+// FleetHost is the overview of one host
+type FleetHost struct {
+	Name        string           `json:"name"`            // hostname, or the agent's name for agents
+	Local       bool             `json:"local,omitempty"` // the host this server runs on
+	Agent       *FleetAgent      `json:"agent,omitempty"`
+	Status      HostStatus       `json:"status"`
+	LastSeen    *time.Time       `json:"last_seen,omitempty"`
+	Running     []FleetExecution `json:"running"`
+	ActiveTests []string         `json:"active_tests,omitempty"` // tests the agent recently pushed metrics for
+	Health      *HostHealth      `json:"health,omitempty"`
+	LastResults []FleetResult    `json:"last_results"` // latest finished execution of each test, newest first
+}
+
+// FleetAgent is the agent pushing a host's metrics
+type FleetAgent struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Version  string `json:"version,omitempty"`
+	Platform string `json:"platform,omitempty"`
+}
+
+// FleetExecution is an execution running on a host
+type FleetExecution struct {
+	ID        string             `json:"id"`
+	TestID    string             `json:"test_id"`
+	Status    ExecutionStatus    `json:"status"`
+	StartTime *time.Time         `json:"start_time,omitempty"`
+	Progress  *ExecutionProgress `json:"progress,omitempty"`
+}
+
+// HostHealth is the latest value of a host's health metrics, keyed type.field
+// such as system.cpu_usage_percent
+type HostHealth struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Metrics   map[string]float64 `json:"metrics"`
+}
+
+// FleetResult is the latest finished execution of a test on a host
+type FleetResult struct {
+	TestID       string          `json:"test_id"`
+	ExecutionID  string          `json:"execution_id"`
+	Status       ExecutionStatus `json:"status"`
+	Score        float64         `json:"score"`
+	GradePassed  *bool           `json:"grade_passed,omitempty"` // nil when no hardware profile matched
+	ReviewStatus ReviewStatus    `json:"review_status,omitempty"`
+	Finished     *time.Time      `json:"finished,omitempty"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Labels are free-form key=value pairs attached to tests and executions, e.g.
+// rack=r12 or cpu_gen=zen4, used to filter large catalogs
+type Labels map[string]string
+
+// MaxLabelValueLength is the longest accepted label value
+const MaxLabelValueLength = 255
+
+// labelKeyPattern accepts keys like rack, hw.generation or team/owner
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$`)
+
+// ValidLabelKey reports whether key is a valid label key: up to 63 letters, digits,
+// dots, dashes, underscores and slashes, starting and ending with a letter or digit
+func ValidLabelKey(key string) bool {
+	return labelKeyPattern.MatchString(key)
+}
+
+// Validate checks every key and value
+func (l Labels) Validate() error {
+	for key, value := range l {
+		if !ValidLabelKey(key) {
+			return fmt.Errorf("invalid label key %q", key)
+		}
+		if len(value) > MaxLabelValueLength {
+			return fmt.Errorf("label %s: value longer than %d characters", key, MaxLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// Merge returns the labels of l overridden by those of other
+func (l Labels) Merge(other Labels) Labels {
+	if len(l) == 0 && len(other) == 0 {
+		return nil
+	}
+	merged := make(Labels, len(l)+len(other))
+	for key, value := range l {
+		merged[key] = value
+	}
+	for key, value := range other {
+		merged[key] = value
+	}
+	return merged
+}
+
+// Value stores the labels as a JSON object
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads labels stored as a JSON object
+func (l *Labels) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Labels", value)
+	}
+	return json.Unmarshal(data, l)
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidLabelKey(t *testing.T) {
+	tests := map[string]bool{
+		"rack":                  true,
+		"hw.generation":         true,
+		"team/owner":            true,
+		"cpu_gen-2":             true,
+		"a":                     true,
+		strings.Repeat("k", 63): true,
+		strings.Repeat("k", 64): false,
+		"":                      false,
+		"-rack":                 false,
+		"rack.":                 false,
+		"rack id":               false,
+		`rack"]`:                false,
+		"rack'":                 false,
+	}
+	for key, want := range tests {
+		if got := ValidLabelKey(key); got != want {
+			t.Errorf("ValidLabelKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestLabelsValidate(t *testing.T) {
+	tests := []struct {
+		labels  Labels
+		wantErr bool
+	}{
+		{nil, false},
+		{Labels{"rack": "r12", "empty": ""}, false},
+		{Labels{"rack": strings.Repeat("v", MaxLabelValueLength)}, false},
+		{Labels{"rack": strings.Repeat("v", MaxLabelValueLength+1)}, true},
+		{Labels{"bad key": "v"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.labels.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%v) = %v, want error %v", tt.labels, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLabelsMerge(t *testing.T) {
+	base := Labels{"rack": "r1", "env": "lab"}
+	tests := []struct {
+		l, other Labels
+		want     Labels
+	}{
+		{nil, nil, nil},
+		{Labels{}, nil, nil},
+		{base, nil, Labels{"rack": "r1", "env": "lab"}},
+		{nil, base, Labels{"rack": "r1", "env": "lab"}},
+		{base, Labels{"rack": "r2", "run": "3"}, Labels{"rack": "r2", "env": "lab", "run": "3"}},
+	}
+	for _, tt := range tests {
+		if got := tt.l.Merge(tt.other); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v.Merge(%v) = %v, want %v", tt.l, tt.other, got, tt.want)
+		}
+	}
+	if base["rack"] != "r1" {
+		t.Error("Merge modified its receiver")
+	}
+}
+
+func TestLabelsValueAndScan(t *testing.T) {
+	for _, labels := range []Labels{nil, {}, {"rack": "r12", "gen": "zen4"}} {
+		value, err := labels.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var scanned Labels
+		if err := scanned.Scan(value); err != nil {
+			t.Fatalf("Scan(%v): %v", value, err)
+		}
+		if !reflect.DeepEqual(scanned, labels) {
+			t.Errorf("round trip of %v = %v", labels, scanned)
+		}
+	}
+
+	var labels Labels
+	if err := labels.Scan([]byte(`{"rack":"r1"}`)); err != nil || labels["rack"] != "r1" {
+		t.Errorf("Scan of bytes = %v, %v", labels, err)
+	}
+	for _, value := range []interface{}{42, `["rack"]`, `{"rack":`} {
+		if err := labels.Scan(value); err == nil {
+			t.Errorf("Scan(%v) succeeded", value)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Load profile shapes, how a run's intensity varies over each period
+const (
+	LoadShapeConstant = "constant" // full intensity throughout
+	LoadShapeStep     = "step"     // climbs in equal steps from the low level to full, then drops back
+	LoadShapeSpike    = "spike"    // the low level, with a burst at full intensity at the start of each period
+	LoadShapeSine     = "sine"     // swings smoothly between full intensity and the low level
+	LoadShapeSawtooth = "sawtooth" // climbs steadily from the low level to full, then drops back
+)
+
+// Defaults of the shape parameters
+const (
+	defaultLoadSteps       = 4
+	defaultSpikeFraction   = 10 // percent of the period a spike lasts
+	minLoadProfilePeriod   = time.Second
+	maxLoadProfileSteps    = 100
+	loadProfileFullPercent = 100
+)
+
+// LoadProfile shapes a run's load over time, so it can be bursty or cyclic like
+// real traffic rather than constant pressure. The level it gives is a
+// percentage of the run's intensity, between full and 100-Amplitude.
+type LoadProfile struct {
+	Shape     string        `json:"shape" binding:"omitempty,oneof=constant step spike sine sawtooth"` // constant, step, spike, sine or sawtooth
+	Period    time.Duration `json:"period,omitempty" binding:"gte=0"`                                  // length of one cycle of the shape
+	Amplitude int           `json:"amplitude,omitempty" binding:"gte=0,lte=100"`                       // percent of the intensity the load dips by, 1-100
+	Steps     int           `json:"steps,omitempty" binding:"gte=0,lte=100"`                           // levels of a step shape, 4 by default
+	Spike     time.Duration `json:"spike,omitempty" binding:"gte=0"`                                   // length of each spike, a tenth of the period by default
+}
+
+// Empty reports whether the profile leaves the load constant
+func (p *LoadProfile) Empty() bool {
+	return p == nil || p.Shape == "" || p.Shape == LoadShapeConstant
+}
+
+// Validate checks the profile's shape and parameters
+func (p *LoadProfile) Validate() error {
+	if p == nil {
+		return nil
+	}
+	switch p.Shape {
+	case "", LoadShapeConstant:
+		return nil
+	case LoadShapeStep, LoadShapeSpike, LoadShapeSine, LoadShapeSawtooth:
+	default:
+		return fmt.Errorf("load_profile shape must be constant, step, spike, sine or sawtooth")
+	}
+	if p.Period < minLoadProfilePeriod {
+		return fmt.Errorf("load_profile period must be at least %s", minLoadProfilePeriod)
+	}
+	if p.Amplitude < 1 || p.Amplitude > 100 {
+		return fmt.Errorf("load_profile amplitude must be between 1 and 100")
+	}
+	if p.Steps < 0 || p.Steps > maxLoadProfileSteps {
+		return fmt.Errorf("load_profile steps must be between 1 and %d", maxLoadProfileSteps)
+	}
+	if p.Spike < 0 || p.Spike >= p.Period {
+		return fmt.Errorf("load_profile spike must be shorter than the period")
+	}
+	return nil
+}
+
+// Level returns the percentage of the run's intensity the profile gives at
+// elapsed into the run
+func (p *LoadProfile) Level(elapsed time.Duration) int {
+	if p.Empty() || p.Period <= 0 {
+		return loadProfileFullPercent
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	low := float64(loadProfileFullPercent - p.Amplitude)
+	amplitude := float64(p.Amplitude)
+	phase := float64(elapsed%p.Period) / float64(p.Period) // 0 to 1 through the current cycle
+
+	var level float64
+	switch p.Shape {
+	case LoadShapeStep:
+		steps := p.Steps
+		if steps <= 0 {
+			steps = defaultLoadSteps
+		}
+		step := math.Floor(phase * float64(steps))
+		if steps == 1 {
+			level = loadProfileFullPercent
+		} else {
+			level = low + amplitude*step/float64(steps-1)
+		}
+	case LoadShapeSpike:
+		spike := p.Spike
+		if spike <= 0 {
+			spike = p.Period * defaultSpikeFraction / 100
+		}
+		level = low
+		if elapsed%p.Period < spike {
+			level = loadProfileFullPercent
+		}
+	case LoadShapeSine:
+		// Starts at full intensity, reaching the low level half way through
+		level = low + amplitude*(1+math.Cos(2*math.Pi*phase))/2
+	case LoadShapeSawtooth:
+		level = low + amplitude*phase
+	default:
+		level = loadProfileFullPercent
+	}
+	return int(math.Round(level))
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadProfileLevel(t *testing.T) {
+	tests := []struct {
+		profile LoadProfile
+		at      time.Duration
+		want    int
+	}{
+		{LoadProfile{Shape: LoadShapeConstant}, 5 * time.Second, 100},
+		{LoadProfile{Shape: LoadShapeStep, Period: 40 * time.Second, Amplitude: 60}, 5 * time.Second, 40},
+		{LoadProfile{Shape: LoadShapeStep, Period: 40 * time.Second, Amplitude: 60}, 15 * time.Second, 60},
+		{LoadProfile{Shape: LoadShapeStep, Period: 40 * time.Second, Amplitude: 60}, 35 * time.Second, 100},
+		{LoadProfile{Shape: LoadShapeStep, Period: 40 * time.Second, Amplitude: 60}, 45 * time.Second, 40},
+		{LoadProfile{Shape: LoadShapeSpike, Period: time.Minute, Amplitude: 80}, 3 * time.Second, 100},
+		{LoadProfile{Shape: LoadShapeSpike, Period: time.Minute, Amplitude: 80}, 30 * time.Second, 20},
+		{LoadProfile{Shape: LoadShapeSpike, Period: time.Minute, Amplitude: 80, Spike: 40 * time.Second}, 30 * time.Second, 100},
+		{LoadProfile{Shape: LoadShapeSine, Period: time.Minute, Amplitude: 50}, 0, 100},
+		{LoadProfile{Shape: LoadShapeSine, Period: time.Minute, Amplitude: 50}, 15 * time.Second, 75},
+		{LoadProfile{Shape: LoadShapeSine, Period: time.Minute, Amplitude: 50}, 30 * time.Second, 50},
+		{LoadProfile{Shape: LoadShapeSawtooth, Period: 10 * time.Second, Amplitude: 100}, 5 * time.Second, 50},
+		{LoadProfile{Shape: LoadShapeSawtooth, Period: 10 * time.Second, Amplitude: 100}, 10 * time.Second, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.profile.Level(tt.at); got != tt.want {
+			t.Errorf("%s at %s: level %d, want %d", tt.profile.Shape, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestLoadProfileValidate(t *testing.T) {
+	var none *LoadProfile
+	if !none.Empty() || none.Validate() != nil {
+		t.Error("missing load profile isn't empty and valid")
+	}
+	if err := (&LoadProfile{Shape: LoadShapeSine, Period: time.Minute, Amplitude: 30}).Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	for _, invalid := range []LoadProfile{
+		{Shape: "square", Period: time.Minute, Amplitude: 30},
+		{Shape: LoadShapeSine, Amplitude: 30},
+		{Shape: LoadShapeSine, Period: time.Minute},
+		{Shape: LoadShapeSine, Period: time.Minute, Amplitude: 101},
+		{Shape: LoadShapeStep, Period: time.Minute, Amplitude: 30, Steps: -1},
+		{Shape: LoadShapeSpike, Period: time.Minute, Amplitude: 30, Spike: time.Minute},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", invalid)
+		}
+	}
+}
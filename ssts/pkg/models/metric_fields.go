@@ -0,0 +1,167 @@
+package models
+
+// Typed fields of the built-in measurements. Points are built from these rather
+// than map literals so each measurement's field names live in one place and its
+// fields map is allocated once at its final size.
+
+// SystemFields are the fields of the metrics collector's system points
+type SystemFields struct {
+	CPUUsagePercent      float64
+	CPUCores             int
+	MemoryUsagePercent   float64
+	MemoryUsedBytes      uint64
+	MemoryAvailableBytes uint64
+	DiskUsagePercent     float64
+	DiskUsedBytes        uint64
+	NetworkSentBytes     uint64
+	NetworkRecvBytes     uint64
+}
+
+func (f SystemFields) Fields() map[string]interface{} {
+	fields := make(map[string]interface{}, 9)
+	fields["cpu_usage_percent"] = f.CPUUsagePercent
+	fields["cpu_cores"] = f.CPUCores
+	fields["memory_usage_percent"] = f.MemoryUsagePercent
+	fields["memory_used_bytes"] = f.MemoryUsedBytes
+	fields["memory_available_bytes"] = f.MemoryAvailableBytes
+	fields["disk_usage_percent"] = f.DiskUsagePercent
+	fields["disk_used_bytes"] = f.DiskUsedBytes
+	fields["network_sent_bytes"] = f.NetworkSentBytes
+	fields["network_recv_bytes"] = f.NetworkRecvBytes
+	return fields
+}
+
+// CooldownFields are the fields of the system points sampled while an execution
+// cools down
+type CooldownFields struct {
+	CPUUsagePercent    float64
+	TemperatureCelsius float64
+	MemoryUsagePercent float64
+	MemoryUsedBytes    int64
+	DiskUsagePercent   float64
+}
+
+func (f CooldownFields) Fields() map[string]interface{} {
+	fields := make(map[string]interface{}, 5)
+	fields["cpu_usage_percent"] = f.CPUUsagePercent
+	fields["temperature_celsius"] = f.TemperatureCelsius
+	fields["memory_usage_percent"] = f.MemoryUsagePercent
+	fields["memory_used_bytes"] = f.MemoryUsedBytes
+	fields["disk_usage_percent"] = f.DiskUsagePercent
+	return fields
+}
+
+// TemperatureFields are the fields of a temperature sensor's points
+type TemperatureFields struct {
+	Celsius         float64
+	HighCelsius     float64
+	CriticalCelsius float64
+}
+
+func (f TemperatureFields) Fields() map[string]interface{} {
+	fields := make(map[string]interface{}, 3)
+	fields["celsius"] = f.Celsius
+	fields["high_celsius"] = f.HighCelsius
+	fields["critical_celsius"] = f.CriticalCelsius
+	return fields
+}
+
+// FanFields are the fields of a fan's points
+type FanFields struct {
+	RPM int64
+}
+
+func (f FanFields) Fields() map[string]interface{} {
+	fields := make(map[string]interface{}, 1)
+	fields["rpm"] = f.RPM
+	return fields
+}
+
+// PowerFields are the fields of power draw points
+type PowerFields struct {
+	PackageWatts float64
+	CoreWatts    float64
+	DRAMWatts    float64
+	TotalWatts   float64
+	EnergyJoules float64
+}
+
+func (f PowerFields) Fields() map[string]interface{} {
+	fields := make(map[string]interface{}, 5)
+	fields["package_watts"] = f.PackageWatts
+	fields["core_watts"] = f.CoreWatts
+	fields["dram_watts"] = f.DRAMWatts
+	fields["total_watts"] = f.TotalWatts
+	fields["energy_joules"] = f.EnergyJoules
+	return fields
+}
+
+// PowerDomainFields are the fields of a power domain's points
+type PowerDomainFields struct {
+	Watts  float64
+	Joules float64
+}
+
+func (f PowerDomainFields) Fields() map[string]interface{} {
+	fields := make(map[string]interface{}, 2)
+	fields["watts"] = f.Watts
+	fields["joules"] = f.Joules
+	return fields
+}
+
+// ProbeFields are the fields of a victim probe sample's points. Latency is only
+// recorded for samples that succeeded.
+type ProbeFields struct {
+	OK        bool
+	LatencyMs float64
+}
+
+func (f ProbeFields) Fields() map[string]interface{} {
+	if !f.OK {
+		return map[string]interface{}{"ok": 0}
+	}
+	fields := make(map[string]interface{}, 2)
+	fields["ok"] = 1
+	fields["latency_ms"] = f.LatencyMs
+	return fields
+}
+
+// IOOperationFields are the fields of the io_operation points io-stress streams
+// for every I/O it completes. Submission and completion latency are only
+// recorded by the io_uring engine.
+type IOOperationFields struct {
+	LatencyUs           float64
+	Bytes               int64
+	SubmitLatencyUs     float64
+	CompletionLatencyUs float64
+	URing               bool
+}
+
+func (f IOOperationFields) Fields() map[string]interface{} {
+	if !f.URing {
+		fields := make(map[string]interface{}, 2)
+		fields["latency_us"] = f.LatencyUs
+		fields["bytes"] = f.Bytes
+		return fields
+	}
+	fields := make(map[string]interface{}, 4)
+	fields["latency_us"] = f.LatencyUs
+	fields["submit_latency_us"] = f.SubmitLatencyUs
+	fields["completion_latency_us"] = f.CompletionLatencyUs
+	fields["bytes"] = f.Bytes
+	return fields
+}
+
+// GRPCCallFields are the fields of the grpc_call points grpc-load streams for
+// every call, tagged with the method and status code
+type GRPCCallFields struct {
+	LatencyUs float64
+	Messages  int
+}
+
+func (f GRPCCallFields) Fields() map[string]interface{} {
+	fields := make(map[string]interface{}, 2)
+	fields["latency_us"] = f.LatencyUs
+	fields["messages"] = f.Messages
+	return fields
+}
@@ -11,47 +11,138 @@ import (
 type ExecutionStatus string
 
 const (
+	StatusQueued    ExecutionStatus = "queued" // waiting for the machine to pass the preflight checks
 	StatusPending   ExecutionStatus = "pending"
 	StatusRunning   ExecutionStatus = "running"
 	StatusCompleted ExecutionStatus = "completed"
 	StatusFailed    ExecutionStatus = "failed"
 	StatusStopped   ExecutionStatus = "stopped"
+	StatusTimedOut  ExecutionStatus = "timed_out" // stopped by the watchdog for exceeding its wall-clock budget
+)
+
+// FailureClass says where a failed or timed out execution went wrong
+type FailureClass string
+
+const (
+	FailureSetup   FailureClass = "setup"   // the run couldn't be prepared, e.g. its plugin config didn't parse
+	FailurePlugin  FailureClass = "plugin"  // the plugin returned an error
+	FailurePanic   FailureClass = "panic"   // the plugin or orchestrator panicked
+	FailureSafety  FailureClass = "safety"  // stopped for a critical safety violation
+	FailureTimeout FailureClass = "timeout" // stopped by the watchdog
 )
 
 // TestConfiguration represents a stress test configuration
 type TestConfiguration struct {
-	ID          string                 `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	Name        string                 `json:"name" gorm:"not null"`
-	Description string                 `json:"description"`
-	Plugin      string                 `json:"plugin" gorm:"not null"`
-	Config      json.RawMessage        `json:"config" gorm:"type:jsonb"`
-	Duration    time.Duration          `json:"duration"`
-	Safety      SafetyLimits          `json:"safety" gorm:"embedded"`
-	Created     time.Time             `json:"created" gorm:"autoCreateTime"`
-	Updated     time.Time             `json:"updated" gorm:"autoUpdateTime"`
-	CreatedBy   string                `json:"created_by"`
+	ID            string          `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Name          string          `json:"name" gorm:"not null" binding:"required"`
+	Description   string          `json:"description"`
+	ProjectID     string          `json:"project_id" gorm:"type:uuid;index"`
+	Plugin        string          `json:"plugin" gorm:"not null" binding:"required"`
+	Config        json.RawMessage `json:"config" gorm:"type:jsonb"`
+	Duration      time.Duration   `json:"duration"`
+	Safety        SafetyLimits    `json:"safety" gorm:"embedded"`
+	Created       time.Time       `json:"created" gorm:"autoCreateTime"`
+	Updated       time.Time       `json:"updated" gorm:"autoUpdateTime"`
+	CreatedBy     string          `json:"created_by"`
+	Labels        Labels          `json:"labels,omitempty" gorm:"type:jsonb"`
+	Prerequisites Prerequisites   `json:"prerequisites,omitempty" gorm:"type:jsonb"`
+	Probes        VictimProbes    `json:"probes,omitempty" gorm:"type:jsonb"` // services to measure while the test loads the machine
+	SLA           SLA             `json:"sla,omitempty" gorm:"type:jsonb"`    // what a run must meet to pass
 }
 
 // TestExecution represents a test execution instance
 type TestExecution struct {
 	ID           string            `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
 	TestID       string            `json:"test_id" gorm:"type:uuid;not null"`
+	ProjectID    string            `json:"project_id" gorm:"type:uuid;index"`
+	StartedBy    string            `json:"started_by" gorm:"index"` // user who ran the test; empty while auth is disabled
 	Status       ExecutionStatus   `json:"status" gorm:"default:pending"`
 	StartTime    *time.Time        `json:"start_time"`
 	EndTime      *time.Time        `json:"end_time"`
 	Duration     time.Duration     `json:"duration"`
+	Extended     time.Duration     `json:"extended,omitempty"` // run time added while it was running
 	ExitCode     *int              `json:"exit_code"`
 	ErrorMessage *string           `json:"error_message"`
+	FailureClass FailureClass      `json:"failure_class,omitempty"` // set for failed and timed out executions
+	Diagnostics  string            `json:"diagnostics,omitempty"`   // file name of the diagnostics bundle captured when it failed
 	Summary      json.RawMessage   `json:"summary" gorm:"type:jsonb"`
+	Labels       Labels            `json:"labels,omitempty" gorm:"type:jsonb"` // the test's labels plus any given at run time
+	ImportedFrom string            `json:"imported_from,omitempty" gorm:"index"` // tool whose result file the execution was imported from, e.g. fio
 	Created      time.Time         `json:"created" gorm:"autoCreateTime"`
+
+	// Finished executions await a human sign-off before the hardware is deployed
+	ReviewStatus ReviewStatus `json:"review_status,omitempty" gorm:"index"` // empty until the execution finishes
+	Reviewer     string       `json:"reviewer,omitempty"` // who last moved the review on
+	ReviewNotes  string       `json:"review_notes,omitempty"`
+	Reviewed     *time.Time   `json:"reviewed,omitempty"` // when the review status last changed
+
+	// Soak runs are checkpointed while running: Summary then holds the metric summary
+	// so far, and Params and Elapsed let a restarted server resume the run
+	Soak           bool            `json:"soak,omitempty" gorm:"index"`
+	Params         json.RawMessage `json:"params,omitempty" gorm:"type:jsonb"`
+	Elapsed        time.Duration   `json:"elapsed,omitempty"` // run time covered by Summary
+	CheckpointedAt *time.Time      `json:"checkpointed_at,omitempty"`
+	Resumes        int             `json:"resumes,omitempty"` // times the run was resumed after a restart
+
+	// System holds the kernel and OS settings the execution started under
+	System *SystemSnapshot `json:"system,omitempty" gorm:"type:jsonb"`
+
+	// Seed of the plugin's random decisions; running again with it replays them
+	Seed int64 `json:"seed,omitempty"`
+
+	// CI identifies the build the run was started for
+	CI CIMetadata `json:"ci" gorm:"embedded;embeddedPrefix:ci_"`
+
+	// Progress is reported while the execution is running; it isn't stored
+	Progress *ExecutionProgress `json:"progress,omitempty" gorm:"-"`
+}
+
+// ExecutionSummary is computed when an execution finishes and stored in TestExecution.Summary
+type ExecutionSummary struct {
+	DurationSeconds      float64                 `json:"duration_seconds"`
+	Samples              int                     `json:"samples"`
+	Metrics              map[string]MetricStats  `json:"metrics"` // keyed by source.type.field
+	Totals               map[string]float64      `json:"totals"`  // final value of cumulative counters
+	Violations           int                     `json:"violations"`
+	CriticalViolations   int                     `json:"critical_violations"`
+	ViolationsBySeverity map[string]int          `json:"violations_by_severity,omitempty"` // info, warning, error and critical
+	Energy               *EnergyUsage            `json:"energy,omitempty"`                 // nil when power can't be measured
+	Grade                *ProfileGrade           `json:"grade,omitempty"`                  // nil when no hardware profile matches
+	DeviceWrites         map[string]int64        `json:"device_writes,omitempty"`          // bytes written per storage device
+	Probes               map[string]ProbeSummary `json:"probes,omitempty"`                 // by victim probe name
+	KernelEvents         map[string]int          `json:"kernel_events,omitempty"`          // errors the kernel log reported while running, by kind, e.g. mce
+	Placement            *PlacementReport        `json:"placement,omitempty"`              // CPUs and priority the workload ran with, when the run asked for them
+	SteadyState          *TimeRange              `json:"steady_state,omitempty"`           // when the workload ran at full intensity, between ramp-up and ramp-down
+	SLA                  *SLAResult              `json:"sla,omitempty"`                    // nil when the test declares no SLA
+	Partial              *PartialRun             `json:"partial,omitempty"`                // set when the run failed or was stopped before finishing
+}
+
+// PartialRun records how far an execution that failed or was stopped got. The
+// rest of the summary covers the metrics collected up to that point.
+type PartialRun struct {
+	Status       ExecutionStatus `json:"status"`
+	Reason       string          `json:"reason"`
+	FailureClass FailureClass    `json:"failure_class,omitempty"`
+	Completion   float64         `json:"completion"` // fraction of the planned run time covered, 0 to 1
+	Score        float64         `json:"score"`      // scaled by completion
+}
+
+// MetricStats holds summary statistics for one metric over an execution
+type MetricStats struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
 }
 
 // SafetyLimits defines resource usage limits for safety
 type SafetyLimits struct {
-	MaxCPUPercent    float64 `json:"max_cpu_percent" gorm:"column:max_cpu_percent"`
-	MaxMemoryPercent float64 `json:"max_memory_percent" gorm:"column:max_memory_percent"`
-	MaxDiskPercent   float64 `json:"max_disk_percent" gorm:"column:max_disk_percent"`
-	MaxNetworkMbps   float64 `json:"max_network_mbps" gorm:"column:max_network_mbps"`
+	MaxCPUPercent    float64 `json:"max_cpu_percent" gorm:"column:max_cpu_percent" binding:"gte=0,lte=100"`
+	MaxMemoryPercent float64 `json:"max_memory_percent" gorm:"column:max_memory_percent" binding:"gte=0,lte=100"`
+	MaxDiskPercent   float64 `json:"max_disk_percent" gorm:"column:max_disk_percent" binding:"gte=0,lte=100"`
+	MaxNetworkMbps   float64 `json:"max_network_mbps" gorm:"column:max_network_mbps" binding:"gte=0"`
 }
 
 // DefaultSafetyLimits returns default safety limits
@@ -66,10 +157,53 @@ func DefaultSafetyLimits() SafetyLimits {
 
 // TestParams defines parameters for test execution
 type TestParams struct {
-	Duration     time.Duration          `json:"duration"`
-	Intensity    int                    `json:"intensity"` // 1-100 scale
-	Concurrency  int                    `json:"concurrency"`
+	Duration     time.Duration          `json:"duration" binding:"gte=0"`
+	Intensity    int                    `json:"intensity" binding:"gte=0,lte=100"` // 1-100 scale
+	Concurrency  int                    `json:"concurrency" binding:"gte=0"`
+	RampDown     time.Duration          `json:"ramp_down" binding:"gte=0"` // final window of Duration over which intensity decreases
+	Cooldown     time.Duration          `json:"cooldown" binding:"gte=0"`  // metrics keep being collected this long after the run ends
 	CustomParams map[string]interface{} `json:"custom_params"`
+	Labels       Labels                 `json:"labels,omitempty"` // added to the test's labels on the execution
+	Soak         bool                   `json:"soak,omitempty"`   // checkpoint progress and allow durations up to the soak maximum
+
+	// Pin CPU frequency while the test runs, for reproducible benchmark numbers
+	// (Linux). The previous settings are restored when it finishes.
+	PinCPUGovernor bool `json:"pin_cpu_governor,omitempty"` // set every CPU's governor to performance
+	DisableTurbo   bool `json:"disable_turbo,omitempty"`    // disable turbo boost
+
+	// Placement restricts the CPUs the workload runs on and its priority (Linux)
+	Placement *Placement `json:"placement,omitempty"`
+
+	// DiskQuotaMB caps what the run may write to disk, overwrites included,
+	// below the server's quota; once written the test files are removed and the
+	// run stopped. 0 leaves the server's quota.
+	DiskQuotaMB int64 `json:"disk_quota_mb,omitempty" binding:"gte=0"`
+
+	// LoadProfile varies the intensity over time, e.g. in spikes or a sine wave,
+	// for plugins whose load can be shaped while running
+	LoadProfile *LoadProfile `json:"load_profile,omitempty"`
+
+	// Seed drives the plugin's random decisions, such as offsets, patterns and
+	// read/write choices. One is picked if unset and recorded on the execution, so
+	// passing it again with the same configuration replays a run.
+	Seed int64 `json:"seed,omitempty"`
+
+	// Priority orders the run in the queue if it has to wait to start: higher
+	// priorities start first, and runs of equal priority in the order queued
+	Priority int `json:"priority,omitempty"`
+
+	// Polite makes the run give way to people using the machine: while
+	// interactive users are present it throttles its load or defers. It applies
+	// to this run only.
+	Polite *PolitePolicy `json:"polite,omitempty"`
+
+	// CI identifies the build under test, e.g. the commit, pipeline URL and build
+	// number of the CI job starting the run, and is stored on the execution
+	CI *CIMetadata `json:"ci,omitempty"`
+
+	// StartedBy is the user running the test, set by the API from the caller's
+	// identity rather than the request body
+	StartedBy string `json:"-"`
 }
 
 // MetricPoint represents a single metric data point
@@ -89,6 +223,7 @@ type SystemMetrics struct {
 	Memory    MemoryMetrics  `json:"memory"`
 	Disk      DiskMetrics    `json:"disk"`
 	Network   NetworkMetrics `json:"network"`
+	Thermal   ThermalMetrics `json:"thermal"`
 }
 
 // CPUMetrics represents CPU-related metrics
@@ -124,6 +259,22 @@ type DiskMetrics struct {
 	QueueDepth       int64   `json:"queue_depth"`
 	LatencyMs        float64 `json:"latency_ms"`
 	UsagePercent     float64 `json:"usage_percent"`
+	Devices          []DiskDeviceMetrics `json:"devices,omitempty"`
+}
+
+// DiskDeviceMetrics represents capacity and I/O rates for a single mounted filesystem
+type DiskDeviceMetrics struct {
+	Device           string  `json:"device"`
+	Mountpoint       string  `json:"mountpoint"`
+	Fstype           string  `json:"fstype"`
+	TotalBytes       int64   `json:"total_bytes"`
+	UsedBytes        int64   `json:"used_bytes"`
+	UsagePercent     float64 `json:"usage_percent"`
+	ReadBytesPerSec  int64   `json:"read_bytes_per_sec"`
+	WriteBytesPerSec int64   `json:"write_bytes_per_sec"`
+	ReadOpsPerSec    int64   `json:"read_ops_per_sec"`
+	WriteOpsPerSec   int64   `json:"write_ops_per_sec"`
+	LatencyMs        float64 `json:"latency_ms"`
 }
 
 // NetworkMetrics represents network-related metrics
@@ -135,6 +286,65 @@ type NetworkMetrics struct {
 	RxErrors        int64   `json:"rx_errors"`
 	TxErrors        int64   `json:"tx_errors"`
 	LatencyMs       float64 `json:"latency_ms"`
+	Interfaces      []NetworkInterfaceMetrics `json:"interfaces,omitempty"`
+}
+
+// NetworkInterfaceMetrics represents traffic rates for a single network interface
+type NetworkInterfaceMetrics struct {
+	Name            string `json:"name"`
+	RxBytesPerSec   int64  `json:"rx_bytes_per_sec"`
+	TxBytesPerSec   int64  `json:"tx_bytes_per_sec"`
+	RxPacketsPerSec int64  `json:"rx_packets_per_sec"`
+	TxPacketsPerSec int64  `json:"tx_packets_per_sec"`
+	RxErrors        int64  `json:"rx_errors"`
+	TxErrors        int64  `json:"tx_errors"`
+}
+
+// PowerMetrics represents power draw sampled from RAPL energy counters
+type PowerMetrics struct {
+	PackageWatts float64              `json:"package_watts"`
+	CoreWatts    float64              `json:"core_watts"`
+	DRAMWatts    float64              `json:"dram_watts"`
+	Domains      []PowerDomainMetrics `json:"domains"`
+}
+
+// PowerDomainMetrics represents the power draw of one RAPL domain, e.g. package-0 or package-0/core
+type PowerDomainMetrics struct {
+	Name   string  `json:"name"`
+	Watts  float64 `json:"watts"`
+	Joules float64 `json:"joules"` // consumed since sampling started
+}
+
+// ThermalMetrics represents temperature sensor and fan speed readings
+type ThermalMetrics struct {
+	MaxCelsius   float64             `json:"max_celsius"` // hottest sensor
+	Temperatures []TemperatureSensor `json:"temperatures,omitempty"`
+	Fans         []FanSensor         `json:"fans,omitempty"`
+}
+
+// TemperatureSensor represents one temperature sensor, e.g. coretemp_package_id_0
+type TemperatureSensor struct {
+	Name            string  `json:"name"`
+	Celsius         float64 `json:"celsius"`
+	HighCelsius     float64 `json:"high_celsius,omitempty"` // 0 if the sensor reports no threshold
+	CriticalCelsius float64 `json:"critical_celsius,omitempty"`
+}
+
+// FanSensor represents one fan, e.g. nct6775/fan2
+type FanSensor struct {
+	Name string `json:"name"`
+	RPM  int64  `json:"rpm"`
+}
+
+// EnergyUsage is the energy consumed by the system while an execution ran
+type EnergyUsage struct {
+	Source       string             `json:"source"` // intel-rapl or amd_energy
+	Joules       float64            `json:"joules"` // package plus DRAM energy
+	WattHours    float64            `json:"watt_hours"`
+	AvgWatts     float64            `json:"avg_watts"`
+	PeakWatts    float64            `json:"peak_watts"`
+	Domains      map[string]float64 `json:"domains"`                  // joules per RAPL domain
+	WorkPerJoule map[string]float64 `json:"work_per_joule,omitempty"` // summary totals divided by joules, for performance-per-watt comparisons
 }
 
 // Plugin represents a stress test plugin
@@ -149,6 +359,57 @@ type Plugin struct {
 	Checksum     string                 `json:"checksum"`
 	InstalledAt  time.Time             `json:"installed_at" gorm:"autoCreateTime"`
 	Enabled      bool                  `json:"enabled" gorm:"default:true"`
+	Capabilities PluginCapabilities    `json:"capabilities" gorm:"embedded;embeddedPrefix:capability_"` // external plugins may declare destructive and requires_root
+}
+
+// PluginsChange describes the plugins registered, re-registered and unregistered
+// when watched plugin directories changed, and the manifests that failed to load
+type PluginsChange struct {
+	Added   []string          `json:"added,omitempty"`
+	Updated []string          `json:"updated,omitempty"`
+	Removed []string          `json:"removed,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"` // by manifest path
+}
+
+// Empty reports whether no plugin was registered, re-registered or unregistered
+func (c PluginsChange) Empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0
+}
+
+// DefaultProjectName is the project that unscoped requests and pre-existing data belong to
+const DefaultProjectName = "default"
+
+// ProjectRole is a user's role within a project
+type ProjectRole string
+
+const (
+	ProjectRoleViewer ProjectRole = "viewer" // read tests and executions
+	ProjectRoleEditor ProjectRole = "editor" // also create, modify and run tests
+	ProjectRoleOwner  ProjectRole = "owner"  // also manage members
+)
+
+// Allows reports whether the role grants at least the required role
+func (r ProjectRole) Allows(required ProjectRole) bool {
+	rank := map[ProjectRole]int{ProjectRoleViewer: 1, ProjectRoleEditor: 2, ProjectRoleOwner: 3}
+	return rank[r] >= rank[required] && rank[required] > 0
+}
+
+// Project groups tests and executions so several teams can share one deployment
+type Project struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Name        string    `json:"name" gorm:"unique;not null" binding:"required"`
+	Description string    `json:"description"`
+	Created     time.Time `json:"created" gorm:"autoCreateTime"`
+	CreatedBy   string    `json:"created_by"`
+}
+
+// ProjectMember grants a user a role in a project
+type ProjectMember struct {
+	ID        string      `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ProjectID string      `json:"project_id" gorm:"type:uuid;not null;uniqueIndex:idx_project_members_project_user"`
+	UserID    string      `json:"user_id" gorm:"not null;uniqueIndex:idx_project_members_project_user"`
+	Role      ProjectRole `json:"role" gorm:"not null"`
+	Created   time.Time   `json:"created" gorm:"autoCreateTime"`
 }
 
 // User represents a system user
@@ -163,6 +424,99 @@ type User struct {
 	LastLogin    *time.Time      `json:"last_login"`
 }
 
+// Resources a saved search can list
+const (
+	SavedSearchTests      = "tests"
+	SavedSearchExecutions = "executions"
+)
+
+// SavedSearch is a named list query a user can re-run, e.g. all executions on rack r12
+type SavedSearch struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string    `json:"user_id" gorm:"index"`
+	ProjectID string    `json:"project_id" gorm:"index"`
+	Name      string    `json:"name" gorm:"not null"`
+	Resource  string    `json:"resource" gorm:"not null"` // tests or executions
+	Query     string    `json:"query"`                    // list endpoint query string, e.g. plugin=cpu-stress&label=rack=r12
+	Created   time.Time `json:"created" gorm:"autoCreateTime"`
+}
+
+// MaxAnnotationLength is the longest accepted annotation text
+const MaxAnnotationLength = 1000
+
+// Annotation is a timestamped note on an execution, e.g. "fan ramped up", shown
+// as a marker on its metric charts
+type Annotation struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ExecutionID string    `json:"execution_id" gorm:"type:uuid;index;not null"`
+	Timestamp   time.Time `json:"timestamp" gorm:"not null"` // the moment the note refers to
+	Text        string    `json:"text" gorm:"not null"`
+	CreatedBy   string    `json:"created_by"`
+	Created     time.Time `json:"created" gorm:"autoCreateTime"`
+}
+
+// Violation is a safety violation seen while an execution ran, persisted so it
+// outlives the safety monitor's in-memory history
+type Violation struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	ExecutionID  string    `json:"execution_id" gorm:"type:uuid;index;not null"`
+	Type         string    `json:"type" gorm:"not null"`
+	Severity     string    `json:"severity" gorm:"not null"`
+	CurrentValue float64   `json:"current_value"`
+	Limit        float64   `json:"limit" gorm:"column:limit_value"`
+	Message      string    `json:"message"`
+	Critical     bool      `json:"critical"`
+	EpisodeID    string    `json:"episode_id,omitempty"` // shared by the samples of one stretch over the limit
+	Timestamp    time.Time `json:"timestamp" gorm:"not null"`
+}
+
+// UserRoleAdmin is the User.Role allowed to manage server-wide settings such as plugins
+const UserRoleAdmin = "admin"
+
+// Alert represents a persisted safety alert
+type Alert struct {
+	ID             string          `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Type           string          `json:"type" gorm:"not null"`
+	Severity       string          `json:"severity" gorm:"not null"`
+	Message        string          `json:"message"`
+	Metadata       json.RawMessage `json:"metadata" gorm:"type:jsonb"`
+	ProjectID      string          `json:"project_id,omitempty" gorm:"index"` // project of the execution alerted about; empty for host-wide alerts
+	DedupKey       string          `json:"dedup_key,omitempty" gorm:"index"`  // shared by alerts about the same ongoing condition
+	Resolved       bool            `json:"resolved"`
+	Timestamp      time.Time       `json:"timestamp"`
+	Muted          bool            `json:"muted"`
+	MuteRuleID     *string         `json:"mute_rule_id"`
+	Acknowledged   bool            `json:"acknowledged"`
+	AcknowledgedBy *string         `json:"acknowledged_by"`
+	AcknowledgedAt *time.Time      `json:"acknowledged_at"`
+	Created        time.Time       `json:"created" gorm:"autoCreateTime"`
+}
+
+// AlertMuteRule suppresses alerts matching a type and/or severity until it expires
+type AlertMuteRule struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Type      string     `json:"type"`     // empty matches any type
+	Severity  string     `json:"severity"` // empty matches any severity
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"` // nil never expires
+	CreatedBy string     `json:"created_by"`
+	Created   time.Time  `json:"created" gorm:"autoCreateTime"`
+}
+
+// Matches reports whether the rule applies to an alert of the given type and severity at time t
+func (r *AlertMuteRule) Matches(alertType, severity string, t time.Time) bool {
+	if r.ExpiresAt != nil && !t.Before(*r.ExpiresAt) {
+		return false
+	}
+	if r.Type != "" && r.Type != alertType {
+		return false
+	}
+	if r.Severity != "" && r.Severity != severity {
+		return false
+	}
+	return true
+}
+
 // WebSocketMessage represents a WebSocket message
 type WebSocketMessage struct {
 	Type      string      `json:"type"`
@@ -174,6 +528,7 @@ type WebSocketMessage struct {
 // TestResult represents aggregated test results
 type TestResult struct {
 	TestID        string                 `json:"test_id"`
+	ExecutionID   string                 `json:"execution_id,omitempty"`
 	Status        ExecutionStatus        `json:"status"`
 	Duration      time.Duration          `json:"duration"`
 	Summary       map[string]interface{} `json:"summary"`
@@ -181,6 +536,12 @@ type TestResult struct {
 	Score         float64                `json:"score"`
 	Passed        bool                   `json:"passed"`
 	Errors        []string               `json:"errors,omitempty"`
+	Energy        *EnergyUsage           `json:"energy,omitempty"` // nil when power can't be measured
+
+	// Set for runs that failed or were stopped; the result covers what ran until then
+	Partial       bool    `json:"partial,omitempty"`
+	FailureReason string  `json:"failure_reason,omitempty"`
+	Completion    float64 `json:"completion,omitempty"` // fraction of the planned run time covered
 }
 
 // ExportRequest represents a data export request
@@ -221,4 +582,63 @@ func (u *User) BeforeCreate() {
 	if u.ID == "" {
 		u.ID = uuid.New().String()
 	}
-}
\ No newline at end of file
+}
+func (a *Alert) BeforeCreate() {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+}
+
+func (r *AlertMuteRule) BeforeCreate() {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+}
+
+func (p *Project) BeforeCreate() {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+}
+
+func (m *ProjectMember) BeforeCreate() {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+}
+
+func (s *SavedSearch) BeforeCreate() {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+}
+
+func (a *Annotation) BeforeCreate() {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+}
+
+func (v *Violation) BeforeCreate() {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+}
+
+func (p *HardwareProfile) BeforeCreate() {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+}
+
+func (d *DeviceEndurance) BeforeCreate() {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+}
+
+func (a *AgentToken) BeforeCreate() {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+}
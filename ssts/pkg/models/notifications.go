@@ -0,0 +1,230 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Events users can be notified about
+const (
+	NotifyAlert              = "alert"               // safety, hardware profile and endurance alerts
+	NotifyExecutionCompleted = "execution_completed" // an execution finished successfully
+	NotifyExecutionFailed    = "execution_failed"    // an execution failed or timed out
+)
+
+// NotificationEvents lists the events users can be notified about
+var NotificationEvents = []string{NotifyAlert, NotifyExecutionCompleted, NotifyExecutionFailed}
+
+// Notification scopes
+const (
+	NotifyScopeOwn = "own" // only about executions of tests the user created
+	NotifyScopeAll = "all" // about every execution, and alerts about the whole system
+)
+
+// Notification channels
+const (
+	ChannelEmail   = "email"    // to the user's email address
+	ChannelSlack   = "slack"    // to a Slack incoming webhook
+	ChannelWebhook = "webhook"  // JSON POSTed to a URL
+	ChannelBrowser = "browser"  // to the user's open dashboards, over the WebSocket
+	ChannelWebPush = "web_push" // to a browser push subscription, even with no dashboard open
+)
+
+// NotificationSeverities lists severities from least to most severe; completed
+// executions are info and failed ones error
+var NotificationSeverities = []string{"info", "warning", "error", "critical"}
+
+// notificationsKey is the key of User.Preferences holding notification preferences
+const notificationsKey = "notifications"
+
+// NotificationPreferences say what a user is notified about and how. They are
+// kept in User.Preferences beside any other preferences stored there.
+type NotificationPreferences struct {
+	Enabled         bool                 `json:"enabled"`
+	Scope           string               `json:"scope"`                // own or all
+	Events          []string             `json:"events"`               // all events when empty
+	Severities      []string             `json:"severities,omitempty"` // all severities when empty
+	Channels        []string             `json:"channels"`
+	SlackWebhookURL string               `json:"slack_webhook_url,omitempty"`
+	WebhookURL      string               `json:"webhook_url,omitempty"`
+	WebPush         *WebPushSubscription `json:"web_push,omitempty"`
+}
+
+// WebPushSubscription is a browser's push subscription, as its
+// PushSubscription.toJSON() returns it
+type WebPushSubscription struct {
+	Endpoint string      `json:"endpoint"`
+	Keys     WebPushKeys `json:"keys"`
+}
+
+// WebPushKeys are the keys notifications are encrypted for a browser with
+type WebPushKeys struct {
+	P256DH string `json:"p256dh"` // the browser's P-256 public key, base64url
+	Auth   string `json:"auth"`   // authentication secret, base64url
+}
+
+// Validate checks the subscription's endpoint and keys
+func (s *WebPushSubscription) Validate() error {
+	if s == nil {
+		return fmt.Errorf("required by the channel")
+	}
+	if err := validNotificationURL(s.Endpoint); err != nil {
+		return fmt.Errorf("endpoint %w", err)
+	}
+	if key, err := decodeBase64URL(s.Keys.P256DH); err != nil || len(key) != 65 || key[0] != 4 {
+		return fmt.Errorf("keys.p256dh must be an uncompressed P-256 public key")
+	}
+	if auth, err := decodeBase64URL(s.Keys.Auth); err != nil || len(auth) != 16 {
+		return fmt.Errorf("keys.auth must be 16 bytes")
+	}
+	return nil
+}
+
+// decodeBase64URL decodes base64url, padded or not, as browsers write keys
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// DefaultNotificationPreferences are used until a user sets their own: nothing
+// is sent until they opt in
+func DefaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{Scope: NotifyScopeOwn, Channels: []string{ChannelEmail}}
+}
+
+// Validate checks the preferences, including that channels have their URLs
+func (p NotificationPreferences) Validate() error {
+	if p.Scope != NotifyScopeOwn && p.Scope != NotifyScopeAll {
+		return fmt.Errorf("scope must be %s or %s", NotifyScopeOwn, NotifyScopeAll)
+	}
+	for _, event := range p.Events {
+		if !containsValue(NotificationEvents, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	for _, severity := range p.Severities {
+		if !containsValue(NotificationSeverities, severity) {
+			return fmt.Errorf("unknown severity %q", severity)
+		}
+	}
+	if p.Enabled && len(p.Channels) == 0 {
+		return fmt.Errorf("at least one channel is required")
+	}
+	for _, channel := range p.Channels {
+		switch channel {
+		case ChannelEmail, ChannelBrowser:
+		case ChannelSlack:
+			if err := validNotificationURL(p.SlackWebhookURL); err != nil {
+				return fmt.Errorf("slack_webhook_url: %w", err)
+			}
+		case ChannelWebhook:
+			if err := validNotificationURL(p.WebhookURL); err != nil {
+				return fmt.Errorf("webhook_url: %w", err)
+			}
+		case ChannelWebPush:
+			if err := p.WebPush.Validate(); err != nil {
+				return fmt.Errorf("web_push: %w", err)
+			}
+		default:
+			return fmt.Errorf("unknown channel %q", channel)
+		}
+	}
+	return nil
+}
+
+// Wants reports whether the preferences ask for an event of a severity. Scope
+// is checked separately since it depends on who owns the execution.
+func (p NotificationPreferences) Wants(event, severity string) bool {
+	if !p.Enabled {
+		return false
+	}
+	if len(p.Events) > 0 && !containsValue(p.Events, event) {
+		return false
+	}
+	return len(p.Severities) == 0 || containsValue(p.Severities, severity)
+}
+
+// NotificationPreferences returns the user's notification preferences, or the
+// defaults if they have none
+func (u *User) NotificationPreferences() (NotificationPreferences, error) {
+	prefs := DefaultNotificationPreferences()
+	if len(u.Preferences) == 0 {
+		return prefs, nil
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(u.Preferences, &all); err != nil {
+		return prefs, fmt.Errorf("invalid preferences: %w", err)
+	}
+	if raw, ok := all[notificationsKey]; ok {
+		if err := json.Unmarshal(raw, &prefs); err != nil {
+			return prefs, fmt.Errorf("invalid notification preferences: %w", err)
+		}
+	}
+	return prefs, nil
+}
+
+// SetNotificationPreferences stores notification preferences in User.Preferences,
+// keeping the other preferences there
+func (u *User) SetNotificationPreferences(prefs NotificationPreferences) error {
+	all := make(map[string]json.RawMessage)
+	if len(u.Preferences) > 0 {
+		if err := json.Unmarshal(u.Preferences, &all); err != nil {
+			return fmt.Errorf("invalid preferences: %w", err)
+		}
+	}
+
+	raw, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	all[notificationsKey] = raw
+
+	preferences, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	u.Preferences = preferences
+	return nil
+}
+
+// validNotificationURL requires an absolute https URL that doesn't name a host on
+// the server's own or a private network. Names resolving to such hosts are
+// refused when notifications are sent.
+func validNotificationURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("required by the channel")
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return fmt.Errorf("must be an https URL")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("must not name a local host")
+	}
+	if ip := net.ParseIP(host); ip != nil && !PublicIP(ip) {
+		return fmt.Errorf("must not name a loopback, link-local or private address")
+	}
+	return nil
+}
+
+// PublicIP reports whether notifications may be sent to an address: it must not
+// be loopback, link-local, private, multicast or unspecified
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// I/O scheduling classes a run's workload may be given
+const (
+	IOClassRealtime   = "realtime"
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+// MaxCPUs bounds the CPU numbers a CPU list may name
+const MaxCPUs = 1024
+
+// Placement restricts the CPUs a run's workload executes on and the priority
+// it runs at (Linux), e.g. to stress one socket while leaving another untouched
+type Placement struct {
+	CPUs       string `json:"cpus,omitempty"`                                                         // CPU list, e.g. "0-7,16-23"
+	NUMANode   *int   `json:"numa_node,omitempty"`                                                    // the node's CPUs, narrowed to those in CPUs if both are given
+	Nice       int    `json:"nice,omitempty" binding:"gte=-20,lte=19"`                                // -20 (highest priority) to 19; 0 leaves it unchanged
+	IOClass    string `json:"io_class,omitempty" binding:"omitempty,oneof=realtime best-effort idle"` // realtime, best-effort or idle; empty leaves it unchanged
+	IOPriority int    `json:"io_priority,omitempty" binding:"gte=0,lte=7"`                            // 0 (highest) to 7, within the realtime and best-effort classes
+}
+
+// PlacementReport is the placement a run's workload actually got, read back
+// from the kernel once it was applied
+type PlacementReport struct {
+	CPUs       string `json:"cpus"` // CPU list the workload may run on
+	NUMANode   *int   `json:"numa_node,omitempty"`
+	Nice       int    `json:"nice"`
+	IOClass    string `json:"io_class,omitempty"`
+	IOPriority int    `json:"io_priority,omitempty"`
+	Verified   bool   `json:"verified"` // every thread was found running with the placement asked for
+}
+
+// Empty reports whether the placement changes nothing
+func (p *Placement) Empty() bool {
+	return p == nil || (p.CPUs == "" && p.NUMANode == nil && p.Nice == 0 && p.IOClass == "")
+}
+
+// Validate checks the placement is well formed; whether the machine has the
+// CPUs and node it names is checked when the run starts
+func (p *Placement) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.CPUs != "" {
+		if _, err := ParseCPUList(p.CPUs); err != nil {
+			return fmt.Errorf("placement cpus: %w", err)
+		}
+	}
+	if p.NUMANode != nil && *p.NUMANode < 0 {
+		return fmt.Errorf("placement numa_node must not be negative")
+	}
+	if p.Nice < -20 || p.Nice > 19 {
+		return fmt.Errorf("placement nice must be between -20 and 19")
+	}
+	switch p.IOClass {
+	case "", IOClassRealtime, IOClassBestEffort, IOClassIdle:
+	default:
+		return fmt.Errorf("placement io_class must be realtime, best-effort or idle")
+	}
+	if p.IOPriority < 0 || p.IOPriority > 7 {
+		return fmt.Errorf("placement io_priority must be between 0 and 7")
+	}
+	return nil
+}
+
+// ParseCPUList reads a CPU list as the kernel writes them, e.g. "0-3,8,10-11",
+// returning the CPUs in ascending order
+func ParseCPUList(list string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CPU list %q", list)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(strings.TrimSpace(last)); err != nil {
+				return nil, fmt.Errorf("invalid CPU list %q", list)
+			}
+		}
+		if start < 0 || end < start || end >= MaxCPUs {
+			return nil, fmt.Errorf("invalid CPU range %q", part)
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			seen[cpu] = true
+		}
+	}
+
+	cpus := make([]int, 0, len(seen))
+	for cpu := range seen {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// FormatCPUList writes ascending CPUs as a CPU list, collapsing runs into ranges
+func FormatCPUList(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := map[string][]int{
+		"0":          {0},
+		"0-3":        {0, 1, 2, 3},
+		"8,0-2,2\n":  {0, 1, 2, 8},
+		"1-2,10-11 ": {1, 2, 10, 11},
+		"":           nil,
+		"3-1":        nil,
+		"0-":         nil,
+		"a":          nil,
+		"1024":       nil,
+		"0-1,,4":     nil,
+	}
+	for list, want := range tests {
+		got, err := ParseCPUList(list)
+		if want == nil {
+			if err == nil {
+				t.Errorf("ParseCPUList(%q) = %v, want an error", list, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseCPUList(%q) = %v, %v, want %v", list, got, err, want)
+		}
+		if again, _ := ParseCPUList(FormatCPUList(got)); !reflect.DeepEqual(again, want) {
+			t.Errorf("FormatCPUList(%v) = %q doesn't parse back", got, FormatCPUList(got))
+		}
+	}
+	if got := FormatCPUList([]int{0, 1, 2, 4, 6, 7}); got != "0-2,4,6-7" {
+		t.Errorf("FormatCPUList = %q, want 0-2,4,6-7", got)
+	}
+}
+
+func TestPlacementValidate(t *testing.T) {
+	node := 1
+	negative := -1
+	tests := []struct {
+		placement *Placement
+		wantErr   bool
+	}{
+		{nil, false},
+		{&Placement{CPUs: "0-3", NUMANode: &node, Nice: 10, IOClass: IOClassBestEffort, IOPriority: 7}, false},
+		{&Placement{CPUs: "0-"}, true},
+		{&Placement{NUMANode: &negative}, true},
+		{&Placement{Nice: 20}, true},
+		{&Placement{IOClass: "fast"}, true},
+		{&Placement{IOClass: IOClassRealtime, IOPriority: 8}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.placement.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) = %v, wantErr %v", tt.placement, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Polite mode actions, what a run does while interactive users are present
+const (
+	PoliteThrottle = "throttle" // run at throttle_percent of the intensity
+	PoliteDefer    = "defer"    // wait in the queue to start, and once running pause if the plugin can
+)
+
+// Kinds of interactive session polite mode makes way for
+const (
+	SessionSSH      = "ssh"      // a remote login
+	SessionTerminal = "terminal" // a login on a local console or terminal
+	SessionX11      = "x11"      // a graphical X session, including one displayed from a remote host
+)
+
+// defaultPoliteThrottle is the percentage of the intensity throttled runs keep
+const defaultPoliteThrottle = 25
+
+// PolitePolicy is polite mode, for runs on workstations: while someone is logged
+// in, over SSH, on a terminal or in an X session, the run throttles its load or
+// defers until they leave. It is set on each run, as runs aren't started on a
+// schedule in this version.
+type PolitePolicy struct {
+	Action          string   `json:"action,omitempty" binding:"omitempty,oneof=throttle defer"`          // throttle (default) or defer
+	ThrottlePercent int      `json:"throttle_percent,omitempty" binding:"gte=0,lte=100"`                 // of the intensity while throttled, 25 by default
+	Sessions        []string `json:"sessions,omitempty" binding:"omitempty,dive,oneof=ssh terminal x11"` // kinds of session that count, all by default
+	IgnoreUsers     []string `json:"ignore_users,omitempty"`                                             // users whose sessions don't count, such as the account CI logs in as
+}
+
+// InteractiveSession is a user logged in to the machine
+type InteractiveSession struct {
+	User     string    `json:"user"`
+	Kind     string    `json:"kind"` // ssh, terminal or x11
+	Terminal string    `json:"terminal,omitempty"`
+	Host     string    `json:"host,omitempty"` // where an SSH session comes from
+	Since    time.Time `json:"since"`
+}
+
+// Validate checks the policy's action, throttle and session kinds
+func (p *PolitePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	switch p.Action {
+	case "", PoliteThrottle, PoliteDefer:
+	default:
+		return fmt.Errorf("polite action must be throttle or defer")
+	}
+	if p.ThrottlePercent < 0 || p.ThrottlePercent > 100 {
+		return fmt.Errorf("polite throttle_percent must be between 0 and 100")
+	}
+	for _, kind := range p.Sessions {
+		switch kind {
+		case SessionSSH, SessionTerminal, SessionX11:
+		default:
+			return fmt.Errorf("polite sessions must be ssh, terminal or x11, got %q", kind)
+		}
+	}
+	return nil
+}
+
+// Throttles reports whether the policy throttles runs rather than deferring them
+func (p *PolitePolicy) Throttles() bool {
+	return p != nil && (p.Action == "" || p.Action == PoliteThrottle)
+}
+
+// Defers reports whether the policy defers runs
+func (p *PolitePolicy) Defers() bool {
+	return p != nil && p.Action == PoliteDefer
+}
+
+// Throttle returns the percentage of the intensity a throttled run keeps
+func (p *PolitePolicy) Throttle() int {
+	if p == nil || p.ThrottlePercent == 0 {
+		return defaultPoliteThrottle
+	}
+	return p.ThrottlePercent
+}
+
+// Counts reports whether the policy makes way for a session
+func (p *PolitePolicy) Counts(session InteractiveSession) bool {
+	if p == nil {
+		return false
+	}
+	for _, user := range p.IgnoreUsers {
+		if user == session.User {
+			return false
+		}
+	}
+	if len(p.Sessions) == 0 {
+		return true
+	}
+	for _, kind := range p.Sessions {
+		if kind == session.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the session, e.g. "alice (ssh from 10.0.0.5)"
+func (s InteractiveSession) String() string {
+	if s.Host != "" && s.Kind == SessionSSH {
+		return fmt.Sprintf("%s (%s from %s)", s.User, s.Kind, s.Host)
+	}
+	return fmt.Sprintf("%s (%s)", s.User, s.Kind)
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Prerequisites are conditions a test declares must hold before it may start,
+// e.g. a burn-in test that must have passed recently or a sysctl it relies on.
+// They are checked when the test is started.
+type Prerequisites struct {
+	Tests        []TestPrerequisite        `json:"tests,omitempty"`
+	Plugins      []PluginPrerequisite      `json:"plugins,omitempty"`
+	MinFreeDisk  []DiskPrerequisite        `json:"min_free_disk,omitempty"`
+	KernelParams []KernelParamPrerequisite `json:"kernel_params,omitempty"`
+}
+
+// TestPrerequisite requires another test to have passed: completed, without
+// failing its hardware profile grade or its SLA
+type TestPrerequisite struct {
+	TestID string        `json:"test_id"`
+	Within time.Duration `json:"within,omitempty"` // how recently it must have passed; 0 for any time
+}
+
+// PluginPrerequisite requires a plugin to be registered and enabled
+type PluginPrerequisite struct {
+	Name       string `json:"name"`
+	MinVersion string `json:"min_version,omitempty"` // e.g. 1.2.0
+}
+
+// DiskPrerequisite requires free space on the file system holding Path
+type DiskPrerequisite struct {
+	Path    string `json:"path"`
+	MinFree string `json:"min_free"` // e.g. 50GB
+}
+
+// KernelParamPrerequisite requires a kernel parameter to have a value
+type KernelParamPrerequisite struct {
+	Name  string `json:"name"`  // sysctl name, e.g. vm.swappiness
+	Value string `json:"value"` // compared ignoring surrounding and repeated whitespace
+}
+
+// Empty reports whether no prerequisites are declared
+func (p Prerequisites) Empty() bool {
+	return len(p.Tests) == 0 && len(p.Plugins) == 0 && len(p.MinFreeDisk) == 0 && len(p.KernelParams) == 0
+}
+
+// Validate checks every prerequisite names what it applies to
+func (p Prerequisites) Validate() error {
+	for i, test := range p.Tests {
+		if test.TestID == "" {
+			return fmt.Errorf("tests[%d]: test_id is required", i)
+		}
+		if test.Within < 0 {
+			return fmt.Errorf("tests[%d]: within must not be negative", i)
+		}
+	}
+	for i, plugin := range p.Plugins {
+		if plugin.Name == "" {
+			return fmt.Errorf("plugins[%d]: name is required", i)
+		}
+	}
+	for i, disk := range p.MinFreeDisk {
+		if disk.Path == "" {
+			return fmt.Errorf("min_free_disk[%d]: path is required", i)
+		}
+		if disk.MinFree == "" {
+			return fmt.Errorf("min_free_disk[%d]: min_free is required", i)
+		}
+	}
+	for i, param := range p.KernelParams {
+		if param.Name == "" {
+			return fmt.Errorf("kernel_params[%d]: name is required", i)
+		}
+	}
+	return nil
+}
+
+// Value stores prerequisites as a JSON object
+func (p Prerequisites) Value() (driver.Value, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads prerequisites stored as a JSON object
+func (p *Prerequisites) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*p = Prerequisites{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Prerequisites", value)
+	}
+	return json.Unmarshal(data, p)
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Victim probe types
+const (
+	ProbeHTTP    = "http"    // GET a URL; any response below 500 is a success
+	ProbeTCP     = "tcp"     // connect to host:port, e.g. a database's listener
+	ProbeCommand = "command" // run a shell command, e.g. a database client issuing a query
+)
+
+// Default victim probe timing
+const (
+	DefaultProbeInterval = 5 * time.Second
+	DefaultProbeTimeout  = 2 * time.Second
+)
+
+// VictimProbe measures the latency of a service sharing the machine, such as a
+// web server or database, to see how a test's load degrades it
+type VictimProbe struct {
+	Name     string        `json:"name"`
+	Type     string        `json:"type"`               // http, tcp or command
+	Target   string        `json:"target"`             // URL, host:port or command line
+	Interval time.Duration `json:"interval,omitempty"` // between samples under load; defaults to 5s
+	Timeout  time.Duration `json:"timeout,omitempty"`  // a slower sample fails; defaults to 2s
+}
+
+// VictimProbes are the probes sampled while a test runs
+type VictimProbes []VictimProbe
+
+// Validate checks every probe is named, uniquely, and has a known type and a target
+func (p VictimProbes) Validate() error {
+	names := make(map[string]bool, len(p))
+	for i, probe := range p {
+		switch {
+		case probe.Name == "":
+			return fmt.Errorf("[%d]: name is required", i)
+		case names[probe.Name]:
+			return fmt.Errorf("[%d]: name %s is used more than once", i, probe.Name)
+		case probe.Type != ProbeHTTP && probe.Type != ProbeTCP && probe.Type != ProbeCommand:
+			return fmt.Errorf("[%d]: type must be http, tcp or command", i)
+		case probe.Target == "":
+			return fmt.Errorf("[%d]: target is required", i)
+		case probe.Interval < 0 || probe.Timeout < 0:
+			return fmt.Errorf("[%d]: interval and timeout must not be negative", i)
+		}
+		names[probe.Name] = true
+	}
+	return nil
+}
+
+// Value stores probes as a JSON array
+func (p VictimProbes) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads probes stored as a JSON array
+func (p *VictimProbes) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into VictimProbes", value)
+	}
+	return json.Unmarshal(data, p)
+}
+
+// ProbeSummary is how a victim probe's latency changed under a test's load
+type ProbeSummary struct {
+	Type      string       `json:"type"`
+	Baseline  ProbeLatency `json:"baseline"`   // sampled before the load started
+	UnderLoad ProbeLatency `json:"under_load"` // sampled while the plugin ran
+	// Slowdown is the average latency under load over the baseline average; 0
+	// when either has no successful samples
+	Slowdown float64 `json:"slowdown,omitempty"`
+	// CPUCorrelation is the Pearson correlation of latency under load with CPU
+	// usage, from -1 to 1; nil when too few samples varied to compute it
+	CPUCorrelation *float64 `json:"cpu_correlation,omitempty"`
+}
+
+// ProbeLatency summarizes a probe's samples. Latencies cover the successful ones.
+type ProbeLatency struct {
+	Samples  int     `json:"samples"`
+	Failures int     `json:"failures"`
+	AvgMs    float64 `json:"avg_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+	MaxMs    float64 `json:"max_ms"`
+}
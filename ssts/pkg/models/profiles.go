@@ -0,0 +1,128 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Profile expectation stats, naming which ExecutionSummary figure is compared
+const (
+	ProfileStatAvg   = "avg"
+	ProfileStatMin   = "min"
+	ProfileStatMax   = "max"
+	ProfileStatP95   = "p95"
+	ProfileStatP99   = "p99"
+	ProfileStatTotal = "total" // a cumulative counter from ExecutionSummary.Totals
+)
+
+// HardwareProfile holds the results reference hardware is specified for, e.g. the
+// IOPS and latency of an SSD model or the GFLOPS of a CPU SKU. Finished executions
+// of Plugin whose labels include every Match label are graded against it.
+type HardwareProfile struct {
+	ID            string              `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Name          string              `json:"name" gorm:"uniqueIndex;not null"`
+	Description   string              `json:"description"`
+	Plugin        string              `json:"plugin" gorm:"index;not null"`
+	Match         Labels              `json:"match" gorm:"type:jsonb"` // e.g. ssd_model=samsung-980-pro
+	Expectations  ProfileExpectations `json:"expectations" gorm:"type:jsonb"`
+	MarginPercent *float64            `json:"margin_percent,omitempty"` // nil uses the configured default
+	CreatedBy     string              `json:"created_by"`
+	Created       time.Time           `json:"created" gorm:"autoCreateTime"`
+	Updated       time.Time           `json:"updated" gorm:"autoUpdateTime"`
+}
+
+// ProfileExpectation is one result the reference hardware is expected to reach
+type ProfileExpectation struct {
+	Metric        string  `json:"metric"`                    // summary metric key (source.type.field) or total name
+	Stat          string  `json:"stat,omitempty"`            // avg, min, max, p95, p99 or total; defaults to avg
+	Expected      float64 `json:"expected"`                  // the specified value
+	LowerIsBetter bool    `json:"lower_is_better,omitempty"` // e.g. latency
+}
+
+// Validate checks the profile can be used for grading
+func (p *HardwareProfile) Validate() error {
+	if p.Name == "" {
+		return errors.New("name is required")
+	}
+	if p.Plugin == "" {
+		return errors.New("plugin is required")
+	}
+	if err := p.Match.Validate(); err != nil {
+		return fmt.Errorf("match: %w", err)
+	}
+	if len(p.Expectations) == 0 {
+		return errors.New("at least one expectation is required")
+	}
+	for i, expectation := range p.Expectations {
+		if expectation.Metric == "" {
+			return fmt.Errorf("expectation %d: metric is required", i)
+		}
+		switch expectation.Stat {
+		case "", ProfileStatAvg, ProfileStatMin, ProfileStatMax, ProfileStatP95, ProfileStatP99, ProfileStatTotal:
+		default:
+			return fmt.Errorf("expectation %d: unknown stat %q", i, expectation.Stat)
+		}
+		if expectation.Expected <= 0 {
+			return fmt.Errorf("expectation %d: expected must be positive", i)
+		}
+	}
+	if p.MarginPercent != nil && (*p.MarginPercent < 0 || *p.MarginPercent >= 100) {
+		return errors.New("margin_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// ProfileExpectations is the list of results a profile expects, stored as JSON
+type ProfileExpectations []ProfileExpectation
+
+// Value stores the expectations as a JSON array
+func (e ProfileExpectations) Value() (driver.Value, error) {
+	if e == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads expectations stored as a JSON array
+func (e *ProfileExpectations) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*e = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ProfileExpectations", value)
+	}
+	return json.Unmarshal(data, e)
+}
+
+// ProfileGrade is the result of grading an execution against a hardware profile
+type ProfileGrade struct {
+	ProfileID     string         `json:"profile_id"`
+	ProfileName   string         `json:"profile_name"`
+	MarginPercent float64        `json:"margin_percent"`
+	Passed        bool           `json:"passed"` // false when any check underperforms the spec by more than the margin
+	Checks        []ProfileCheck `json:"checks"`
+}
+
+// ProfileCheck compares one result of an execution with the profile's expectation
+type ProfileCheck struct {
+	Metric           string  `json:"metric"`
+	Stat             string  `json:"stat"`
+	Expected         float64 `json:"expected"`
+	Actual           float64 `json:"actual"`
+	DeviationPercent float64 `json:"deviation_percent"` // positive when better than spec, negative when worse
+	Passed           bool    `json:"passed"`
+	Missing          bool    `json:"missing,omitempty"` // the execution didn't record the metric
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Phases of an execution reported in ExecutionProgress
+const (
+	PhaseRampUp   = "ramp-up"
+	PhaseSteady   = "steady"
+	PhaseRampDown = "ramp-down"
+	PhaseCooldown = "cooldown" // the workload has finished; recovery metrics are being collected
+)
+
+// ExecutionProgress is how far a running execution has got. Percent covers the
+// workload, reaching 100 when it finishes; Remaining includes any cooldown.
+type ExecutionProgress struct {
+	Percent   float64       `json:"percent"`
+	Phase     string        `json:"phase"`
+	Elapsed   time.Duration `json:"elapsed"`
+	Remaining time.Duration `json:"remaining"`            // estimated
+	LoadLevel int           `json:"load_level,omitempty"` // percent of the intensity the run's load profile gives now
+	Paused    bool          `json:"paused,omitempty"`     // the workload is paused; paused time isn't counted in Elapsed
+	Yielding  bool          `json:"yielding,omitempty"`   // polite mode is throttling or pausing the load for interactive users
+}
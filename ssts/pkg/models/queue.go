@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// QueueState is the state of the run queue: the runs waiting to start, in the
+// order they start, and the executions holding the machine and its resources
+type QueueState struct {
+	Queued    []QueuedRun     `json:"queued"`
+	Running   []RunningRun    `json:"running"`
+	Resources []ResourceUsage `json:"resources"` // resources running executions claim
+}
+
+// QueuedRun is a run waiting to start
+type QueuedRun struct {
+	ExecutionID    string     `json:"execution_id"`
+	TestID         string     `json:"test_id"`
+	TestName       string     `json:"test_name"`
+	Plugin         string     `json:"plugin"`
+	StartedBy      string     `json:"started_by,omitempty"`
+	Position       int        `json:"position"` // 1 for the next to start, counting runs of every project
+	Priority       int        `json:"priority"`
+	QueuedAt       time.Time  `json:"queued_at"`
+	Reason         string     `json:"reason,omitempty"`          // why it last failed to start
+	EstimatedStart *time.Time `json:"estimated_start,omitempty"` // unset when it waits on something with no known end, such as load from outside SSTS
+	Claims         []string   `json:"claims,omitempty"`          // resources it needs
+}
+
+// RunningRun is an execution out of the queue that hasn't finished
+type RunningRun struct {
+	ExecutionID string          `json:"execution_id"`
+	TestID      string          `json:"test_id"`
+	TestName    string          `json:"test_name"`
+	Plugin      string          `json:"plugin"`
+	StartedBy   string          `json:"started_by,omitempty"`
+	Status      ExecutionStatus `json:"status"`
+	ExpectedEnd *time.Time      `json:"expected_end,omitempty"` // cooldown included; unset until the run starts
+	Claims      []string        `json:"claims,omitempty"`
+}
+
+// ResourceUsage is how much of a resource running executions claim
+type ResourceUsage struct {
+	Resource  string   `json:"resource"`
+	Exclusive bool     `json:"exclusive"`          // held by a single execution
+	Used      float64  `json:"used,omitempty"`     // amounts claimed
+	Capacity  float64  `json:"capacity,omitempty"` // what the amounts may add up to
+	Holders   []string `json:"holders"`            // executions holding it
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// ReviewStatus is where a finished execution is in its human sign-off. Passed,
+// failed and needs_investigation are a reviewer's verdicts.
+type ReviewStatus string
+
+const (
+	ReviewPending            ReviewStatus = "pending"   // finished, awaiting a reviewer
+	ReviewInReview           ReviewStatus = "in_review" // claimed by a reviewer
+	ReviewPassed             ReviewStatus = "passed"
+	ReviewFailed             ReviewStatus = "failed"
+	ReviewNeedsInvestigation ReviewStatus = "needs_investigation"
+)
+
+// MaxReviewNotesLength is the longest accepted review notes
+const MaxReviewNotesLength = 4000
+
+// reviewTransitions lists the statuses each review status may move to. Passed
+// and failed are final until the review is reopened.
+var reviewTransitions = map[ReviewStatus][]ReviewStatus{
+	ReviewPending:            {ReviewInReview, ReviewPassed, ReviewFailed, ReviewNeedsInvestigation},
+	ReviewInReview:           {ReviewPending, ReviewPassed, ReviewFailed, ReviewNeedsInvestigation},
+	ReviewNeedsInvestigation: {ReviewInReview, ReviewPassed, ReviewFailed},
+	ReviewPassed:             {ReviewPending},
+	ReviewFailed:             {ReviewPending},
+}
+
+// Valid reports whether the status is one of the review statuses
+func (s ReviewStatus) Valid() bool {
+	_, ok := reviewTransitions[s]
+	return ok
+}
+
+// CanTransition reports whether a review may move from s to next
+func (s ReviewStatus) CanTransition(next ReviewStatus) bool {
+	for _, allowed := range reviewTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Verdict reports whether the status is a reviewer's verdict
+func (s ReviewStatus) Verdict() bool {
+	return s == ReviewPassed || s == ReviewFailed || s == ReviewNeedsInvestigation
+}
+
+// ExecutionReview is a change to the review of an execution
+type ExecutionReview struct {
+	Status   ReviewStatus
+	Reviewer string
+	Notes    string
+	At       time.Time
+}
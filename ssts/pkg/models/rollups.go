@@ -0,0 +1,51 @@
+package models
+
+// Hardware rollup groupings besides label keys, taken from the system snapshot
+// an execution started under
+const (
+	RollupByCPUModel  = "cpu_model"
+	RollupByDiskModel = "disk_model" // the distinct models of a machine's disks, joined with " + "
+)
+
+// HardwareRollup groups the results of many executions by hardware model, e.g. to
+// spot a batch of drives that underperforms the rest of the fleet
+type HardwareRollup struct {
+	GroupBy    string              `json:"group_by"` // cpu_model, disk_model or a label key
+	Metric     string              `json:"metric"`
+	Stat       string              `json:"stat"`
+	Fleet      ScoreDistribution   `json:"fleet"` // every scored execution, grouped or not
+	Groups     []HardwareGroupStat `json:"groups"`
+	Ungrouped  int                 `json:"ungrouped"` // scored executions without a value to group by
+	Unscored   int                 `json:"unscored"`  // executions whose summary lacks the metric
+	Executions int                 `json:"executions"`
+}
+
+// HardwareGroupStat is the distribution of results for one hardware model
+type HardwareGroupStat struct {
+	Model            string            `json:"model"`
+	Hosts            int               `json:"hosts"` // distinct hostnames the results came from
+	Distribution     ScoreDistribution `json:"distribution"`
+	DeviationPercent float64           `json:"deviation_percent"` // of the group's median from the rest of the fleet's
+	Outlier          bool              `json:"outlier"`           // the group's median is outside the rest of the fleet's outlier fences
+	Outliers         []RollupOutlier   `json:"outliers,omitempty"`
+}
+
+// ScoreDistribution summarizes a set of results
+type ScoreDistribution struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	P25    float64 `json:"p25"`
+	P50    float64 `json:"p50"`
+	P75    float64 `json:"p75"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+}
+
+// RollupOutlier is an execution whose result is outside its group's outlier
+// fences, 1.5 interquartile ranges beyond the quartiles
+type RollupOutlier struct {
+	ExecutionID string  `json:"execution_id"`
+	Hostname    string  `json:"hostname,omitempty"`
+	Value       float64 `json:"value"`
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SLA names, identifying which limit of an SLA a check is for
+const (
+	SLAMaxP99Latency          = "max_p99_latency"
+	SLAMinThroughput          = "min_throughput"
+	SLAMaxErrorRate           = "max_error_rate"
+	SLAZeroCriticalViolations = "zero_critical_violations"
+)
+
+// SLA is what a test's runs must meet to pass, checked against the execution
+// summary when a run finishes. Metrics are summary metric keys (source.type.field);
+// limits left at zero aren't checked.
+type SLA struct {
+	LatencyMetric          string   `json:"latency_metric,omitempty"`
+	MaxP99Latency          float64  `json:"max_p99_latency,omitempty"` // in the latency metric's unit
+	ThroughputMetric       string   `json:"throughput_metric,omitempty"`
+	MinThroughput          float64  `json:"min_throughput,omitempty"`         // average of the throughput metric
+	ErrorsMetric           string   `json:"errors_metric,omitempty"`          // counter of failed operations
+	OperationsMetric       string   `json:"operations_metric,omitempty"`      // counter of all operations
+	MaxErrorRatePercent    *float64 `json:"max_error_rate_percent,omitempty"` // failed per 100 operations; 0 allows none
+	ZeroCriticalViolations bool     `json:"zero_critical_violations,omitempty"`
+}
+
+// Empty reports whether no SLA is declared
+func (s SLA) Empty() bool {
+	return s.MaxP99Latency == 0 && s.MinThroughput == 0 && s.MaxErrorRatePercent == nil && !s.ZeroCriticalViolations
+}
+
+// Validate checks every limit names the metric it applies to
+func (s SLA) Validate() error {
+	if s.MaxP99Latency < 0 || s.MinThroughput < 0 {
+		return errors.New("limits must not be negative")
+	}
+	if s.MaxP99Latency > 0 && s.LatencyMetric == "" {
+		return errors.New("latency_metric is required for max_p99_latency")
+	}
+	if s.MinThroughput > 0 && s.ThroughputMetric == "" {
+		return errors.New("throughput_metric is required for min_throughput")
+	}
+	if s.MaxErrorRatePercent != nil {
+		if *s.MaxErrorRatePercent < 0 || *s.MaxErrorRatePercent > 100 {
+			return errors.New("max_error_rate_percent must be between 0 and 100")
+		}
+		if s.ErrorsMetric == "" || s.OperationsMetric == "" {
+			return errors.New("errors_metric and operations_metric are required for max_error_rate_percent")
+		}
+	}
+	return nil
+}
+
+// Value stores the SLA as a JSON object
+func (s SLA) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads an SLA stored as a JSON object
+func (s *SLA) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*s = SLA{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into SLA", value)
+	}
+	return json.Unmarshal(data, s)
+}
+
+// SLAResult is the outcome of checking an execution against its test's SLA
+type SLAResult struct {
+	Passed bool       `json:"passed"` // false when any check failed
+	Checks []SLACheck `json:"checks"`
+}
+
+// SLACheck is one limit of an SLA checked against an execution's result
+type SLACheck struct {
+	SLA     string  `json:"sla"` // e.g. max_p99_latency
+	Metric  string  `json:"metric,omitempty"`
+	Limit   float64 `json:"limit"`
+	Actual  float64 `json:"actual"`
+	Missing bool    `json:"missing,omitempty"` // the metric isn't in the summary, which fails the check
+	Passed  bool    `json:"passed"`
+}
+
+// Failures describes each failed check, e.g. "max_p99_latency: plugin.io.latency_ms 12.5 exceeds 10"
+func (r SLAResult) Failures() []string {
+	var failures []string
+	for _, check := range r.Checks {
+		if check.Passed {
+			continue
+		}
+		var failure string
+		switch {
+		case check.Missing:
+			failure = fmt.Sprintf("%s: %s was not reported", check.SLA, check.Metric)
+		case check.SLA == SLAMinThroughput:
+			failure = fmt.Sprintf("%s: %s %g is below %g", check.SLA, check.Metric, check.Actual, check.Limit)
+		case check.SLA == SLAMaxErrorRate:
+			failure = fmt.Sprintf("%s: %g%% of operations failed, over %g%%", check.SLA, check.Actual, check.Limit)
+		case check.SLA == SLAZeroCriticalViolations:
+			failure = fmt.Sprintf("%s: %g critical safety violations", check.SLA, check.Actual)
+		default:
+			failure = fmt.Sprintf("%s: %s %g exceeds %g", check.SLA, check.Metric, check.Actual, check.Limit)
+		}
+		failures = append(failures, "SLA failed: "+failure)
+	}
+	return failures
+}
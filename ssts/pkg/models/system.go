@@ -0,0 +1,86 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SystemSnapshot records the kernel and OS settings an execution started under,
+// so results from differently tuned machines or kernels can be told apart
+type SystemSnapshot struct {
+	Hostname             string            `json:"hostname,omitempty"`
+	OS                   string            `json:"os,omitempty"`             // distribution and version, e.g. ubuntu 22.04
+	KernelVersion        string            `json:"kernel_version,omitempty"` // e.g. 6.5.0-14-generic
+	Arch                 string            `json:"arch,omitempty"`           // e.g. x86_64
+	CPUModel             string            `json:"cpu_model,omitempty"`      // e.g. AMD EPYC 7763 64-Core Processor; comma-separated when sockets differ
+	DiskModels           map[string]string `json:"disk_models,omitempty"`    // model by block device
+	KernelParams         map[string]string `json:"kernel_params,omitempty"`  // sysctls relevant to stress tests, by name
+	IOSchedulers         map[string]string `json:"io_schedulers,omitempty"`  // active scheduler by block device
+	CPUGovernor          string            `json:"cpu_governor,omitempty"`   // comma-separated when CPUs differ
+	TransparentHugePages string            `json:"transparent_hugepages,omitempty"`
+	Container            *ContainerInfo    `json:"container,omitempty"` // nil when not running in a container
+	Captured             time.Time         `json:"captured"`
+}
+
+// ContainerInfo describes the container runtime the server runs under
+type ContainerInfo struct {
+	Runtime string `json:"runtime"`      // docker, podman, kubernetes, containerd or lxc
+	ID      string `json:"id,omitempty"` // container ID, when it can be found
+}
+
+// Settings flattens the snapshot into setting names and values, for comparing
+// snapshots: sysctls by name, I/O schedulers as io_scheduler.<device> and disk
+// models as disk_model.<device>
+func (s *SystemSnapshot) Settings() map[string]string {
+	settings := map[string]string{
+		"hostname":              s.Hostname,
+		"os":                    s.OS,
+		"kernel_version":        s.KernelVersion,
+		"arch":                  s.Arch,
+		"cpu_model":             s.CPUModel,
+		"cpu_governor":          s.CPUGovernor,
+		"transparent_hugepages": s.TransparentHugePages,
+		"container":             "",
+	}
+	for name, value := range s.KernelParams {
+		settings[name] = value
+	}
+	for device, scheduler := range s.IOSchedulers {
+		settings["io_scheduler."+device] = scheduler
+	}
+	for device, model := range s.DiskModels {
+		settings["disk_model."+device] = model
+	}
+	if s.Container != nil {
+		settings["container"] = s.Container.Runtime
+	}
+	return settings
+}
+
+// Value stores the snapshot as a JSON object
+func (s SystemSnapshot) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a snapshot stored as a JSON object
+func (s *SystemSnapshot) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*s = SystemSnapshot{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into SystemSnapshot", value)
+	}
+	return json.Unmarshal(data, s)
+}
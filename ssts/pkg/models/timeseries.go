@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Aggregation functions applied to each window of a time-series query
+const (
+	AggregateMean   = "mean"
+	AggregateMin    = "min"
+	AggregateMax    = "max"
+	AggregateSum    = "sum"
+	AggregateCount  = "count"
+	AggregateFirst  = "first"
+	AggregateLast   = "last"
+	AggregateMedian = "median"
+)
+
+// AggregateFunctions lists the supported aggregation functions
+var AggregateFunctions = []string{
+	AggregateMean, AggregateMin, AggregateMax, AggregateSum,
+	AggregateCount, AggregateFirst, AggregateLast, AggregateMedian,
+}
+
+// ValidAggregateFunction reports whether fn is one of AggregateFunctions
+func ValidAggregateFunction(fn string) bool {
+	return containsValue(AggregateFunctions, fn)
+}
+
+// TimeSeriesQuery selects points from the metric store independently of its
+// query language. Without a window raw points are returned; with one, each
+// window of every series is reduced to one point with Function.
+type TimeSeriesQuery struct {
+	Measurement string              `json:"measurement"`      // e.g. system_cpu
+	Fields      []string            `json:"fields,omitempty"` // all fields when empty
+	Tags        map[string][]string `json:"tags,omitempty"`   // every tag must have one of its values
+	Start       time.Time           `json:"start"`
+	End         time.Time           `json:"end"`
+	Window      time.Duration       `json:"window,omitempty"`
+	Function    string              `json:"function,omitempty"` // one of AggregateFunctions
+	Limit       int                 `json:"limit,omitempty"`    // points per series; 0 for no limit
+}
+
+// TimeSeries is the points of one field of one measurement with one set of tags
+type TimeSeries struct {
+	Measurement string            `json:"measurement"`
+	Field       string            `json:"field"`
+	Tags        map[string]string `json:"tags"`
+	Points      []TimeSeriesPoint `json:"points"`
+}
+
+// TimeSeriesPoint is one value of a series; aggregated points are timestamped
+// with the end of their window
+type TimeSeriesPoint struct {
+	Time  time.Time   `json:"time"`
+	Value interface{} `json:"value"`
+}
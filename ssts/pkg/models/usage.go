@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Periods usage is reported by
+const (
+	UsagePeriodDay   = "day"
+	UsagePeriodWeek  = "week" // starting on Monday
+	UsagePeriodMonth = "month"
+)
+
+// UsageReport is the machine time, energy and device writes executions used, by
+// project, user and period, so shared lab time can be charged back
+type UsageReport struct {
+	Period    string      `json:"period"` // day, week or month
+	Rows      []UsageRow  `json:"rows"`   // by period, then project and user
+	Totals    UsageTotals `json:"totals"`
+	Truncated bool        `json:"truncated,omitempty"` // more executions matched than a report reads; the oldest are left out
+	Generated time.Time   `json:"generated"`
+}
+
+// UsageRow is the usage of one user in one project over one period
+type UsageRow struct {
+	Period      time.Time `json:"period"` // start of the period, in UTC
+	ProjectID   string    `json:"project_id"`
+	ProjectName string    `json:"project_name,omitempty"`
+	User        string    `json:"user"` // empty for runs started while auth was disabled
+	UsageTotals
+}
+
+// UsageTotals adds up the usage of executions
+type UsageTotals struct {
+	Executions        int     `json:"executions"`
+	MachineHours      float64 `json:"machine_hours"`
+	WattHours         float64 `json:"watt_hours"`
+	MeteredExecutions int     `json:"metered_executions"` // executions whose power was measured; watt-hours cover only these
+	BytesWritten      int64   `json:"bytes_written"`      // to storage devices, as reported by the plugins
+}
+
+// Add adds other to the totals
+func (t *UsageTotals) Add(other UsageTotals) {
+	t.Executions += other.Executions
+	t.MachineHours += other.MachineHours
+	t.WattHours += other.WattHours
+	t.MeteredExecutions += other.MeteredExecutions
+	t.BytesWritten += other.BytesWritten
+}